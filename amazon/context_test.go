@@ -0,0 +1,24 @@
+package amazon
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContextUser(t *testing.T) {
+	expectedUser := &User{ID: "amzn1.account.K2LI23KL2LK2", Name: "Mork Hashimoto"}
+	ctx := WithUser(context.Background(), expectedUser)
+	user, err := UserFromContext(ctx)
+	assert.Equal(t, expectedUser, user)
+	assert.Nil(t, err)
+}
+
+func TestContextUser_Error(t *testing.T) {
+	user, err := UserFromContext(context.Background())
+	assert.Nil(t, user)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "amazon: Context missing Amazon User", err.Error())
+	}
+}
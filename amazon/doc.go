@@ -0,0 +1,2 @@
+// Package amazon provides Login with Amazon login and callback handlers.
+package amazon
@@ -0,0 +1,156 @@
+package amazon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dghubble/gologin"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+const (
+	testProfileJSON      = `{"user_id": "amzn1.account.K2LI23KL2LK2", "email": "mhashimoto@example.com", "name": "Mork Hashimoto", "postal_code": "98052"}`
+	testInvalidTokenJSON = `{"error": "invalid_token", "error_description": "The request has an invalid parameter : access_token"}`
+)
+
+func testConfig() *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     "client_id",
+		ClientSecret: "client_secret",
+		RedirectURL:  "https://example.com/amazon/callback",
+		Endpoint:     Endpoint,
+		Scopes:       []string{"profile", "postal_code"},
+	}
+}
+
+func TestCallbackHandler(t *testing.T) {
+	proxyClient, server := newAmazonTestServer(http.StatusOK, testProfileJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithState(ctx, "d4e5f6")
+
+	success := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "Atza|any-token", token.AccessToken)
+		}
+		user, err := UserFromContext(ctx)
+		if assert.Nil(t, err) {
+			expectedUser := &User{ID: "amzn1.account.K2LI23KL2LK2", Name: "Mork Hashimoto", Email: "mhashimoto@example.com", PostalCode: "98052"}
+			assert.Equal(t, expectedUser, user)
+		}
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// CallbackHandler assert that:
+	// - the Token is obtained from the Amazon token endpoint
+	// - the Amazon User is obtained from user/profile
+	// - success handler is called with the Token and User in the ctx
+	callbackHandler := CallbackHandler(testConfig(), http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestAmazonHandler_APIError(t *testing.T) {
+	proxyClient, server := newAmazonTestServer(http.StatusBadRequest, testInvalidTokenJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.True(t, errors.Is(err, ErrUnableToGetAmazonUser))
+			var apiErr *APIError
+			if assert.True(t, errors.As(err, &apiErr)) {
+				assert.Equal(t, &APIError{Code: "invalid_token", Description: "The request has an invalid parameter : access_token"}, apiErr)
+			}
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// AmazonHandler with an invalid token, assert that:
+	// - failure handler is called
+	// - error cannot get Amazon User (with the APIError) added to the ctx
+	amazonHandler := amazonHandler(testConfig(), success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	amazonHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestAmazonHandler_MissingUserID(t *testing.T) {
+	proxyClient, server := newAmazonTestServer(http.StatusOK, `{"name": "Mork Hashimoto", "email": "mhashimoto@example.com"}`)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.True(t, errors.Is(err, ErrUnableToGetAmazonUser))
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// AmazonHandler with a profile missing the user_id, assert that:
+	// - failure handler is called
+	// - error cannot get Amazon User added to the failure handler ctx
+	amazonHandler := amazonHandler(testConfig(), success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	amazonHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestAmazonHandler_MissingCtxToken(t *testing.T) {
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "oauth2: Context missing Token", err.Error())
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// AmazonHandler called without Token in ctx, assert that:
+	// - failure handler is called
+	// - error about ctx missing token is added to the failure handler ctx
+	amazonHandler := amazonHandler(testConfig(), success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	amazonHandler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestValidateResponse(t *testing.T) {
+	validUser := &User{ID: "amzn1.account.K2LI23KL2LK2"}
+	validResponse := &http.Response{StatusCode: 200}
+	invalidResponse := &http.Response{StatusCode: 500}
+	assert.Equal(t, nil, validateResponse(validUser, validResponse, nil))
+	assert.True(t, errors.Is(validateResponse(validUser, validResponse, fmt.Errorf("Server error")), ErrUnableToGetAmazonUser))
+	assert.True(t, errors.Is(validateResponse(validUser, invalidResponse, nil), ErrUnableToGetAmazonUser))
+	assert.True(t, errors.Is(validateResponse(&User{}, validResponse, nil), ErrUnableToGetAmazonUser))
+	assert.True(t, errors.Is(validateResponse(nil, validResponse, nil), ErrUnableToGetAmazonUser))
+}
+
+func TestAPIError(t *testing.T) {
+	assert.Equal(t, "amazon: invalid_token", (&APIError{Code: "invalid_token"}).Error())
+	assert.Equal(t, "amazon: invalid_token: expired", (&APIError{Code: "invalid_token", Description: "expired"}).Error())
+}
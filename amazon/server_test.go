@@ -0,0 +1,27 @@
+package amazon
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/dghubble/gologin/testutils"
+)
+
+// newAmazonTestServer returns a new httptest.Server which mocks the Amazon
+// token and user/profile endpoints and a client which proxies requests to the
+// server. The user/profile endpoint responds with the given status and json
+// data. The caller must close the server.
+func newAmazonTestServer(status int, jsonData string) (*http.Client, *httptest.Server) {
+	client, mux, server := testutils.TestServer()
+	mux.HandleFunc("/auth/o2/token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token": "Atza|any-token", "token_type": "bearer", "expires_in": 3600, "refresh_token": "Atzr|any-refresh"}`)
+	})
+	mux.HandleFunc("/user/profile", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		fmt.Fprintf(w, jsonData)
+	})
+	return client, server
+}
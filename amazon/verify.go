@@ -0,0 +1,57 @@
+package amazon
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/dghubble/gologin/internal/jsonclient"
+)
+
+const amazonAPI = "https://api.amazon.com/"
+
+// User is an Amazon customer profile.
+type User struct {
+	ID    string `json:"user_id"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+	// PostalCode requires the postal_code scope
+	PostalCode string `json:"postal_code"`
+}
+
+// APIError is a Login with Amazon API error.
+type APIError struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+func (e *APIError) Error() string {
+	if e.Description == "" {
+		return "amazon: " + e.Code
+	}
+	return fmt.Sprintf("amazon: %s: %s", e.Code, e.Description)
+}
+
+// client is an Amazon client for obtaining a User.
+type client struct {
+	json *jsonclient.Client
+}
+
+// newClient returns a new Amazon client.
+func newClient(httpClient *http.Client) *client {
+	return &client{
+		json: jsonclient.New(httpClient, amazonAPI),
+	}
+}
+
+// Profile gets the customer profile of the Amazon User.
+// https://developer.amazon.com/docs/login-with-amazon/obtain-customer-profile.html
+func (c *client) Profile(ctx context.Context) (*User, *http.Response, error) {
+	user := new(User)
+	apiErr := new(APIError)
+	resp, err := c.json.Get(ctx, "user/profile", nil, user, apiErr)
+	if err == nil && apiErr.Code != "" {
+		err = apiErr
+	}
+	return user, resp, err
+}
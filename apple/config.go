@@ -0,0 +1,191 @@
+package apple
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/dghubble/gologin"
+	"golang.org/x/oauth2"
+)
+
+const appleIssuer = "https://appleid.apple.com"
+
+const (
+	defaultSecretExpiry = 24 * time.Hour
+	// maxSecretExpiry is the longest client secret lifetime Apple allows
+	maxSecretExpiry = 15777000 * time.Second
+	// secretRefreshMargin is how long before expiry client secrets are
+	// regenerated
+	secretRefreshMargin = time.Minute
+)
+
+// Errors which may occur parsing private keys.
+var (
+	ErrInvalidPrivateKey = errors.New("apple: private key must be a PEM encoded PKCS #8 P-256 ECDSA key")
+)
+
+// Endpoint is Apple's OAuth2 endpoint.
+var Endpoint = oauth2.Endpoint{
+	AuthURL:   "https://appleid.apple.com/auth/authorize",
+	TokenURL:  "https://appleid.apple.com/auth/token",
+	AuthStyle: oauth2.AuthStyleInParams,
+}
+
+// Config configures Sign in with Apple.
+type Config struct {
+	// TeamID is the Apple developer team ID.
+	TeamID string
+	// KeyID is the ID of the Sign in with Apple private key.
+	KeyID string
+	// PrivateKey is the Sign in with Apple private key (see ParsePrivateKey).
+	PrivateKey *ecdsa.PrivateKey
+	// ClientID is the Services ID (or App ID) of the client.
+	ClientID string
+	// RedirectURL is the callback URL registered for the Services ID.
+	RedirectURL string
+	// Scopes are the requested scopes. If empty, "name" and "email" are
+	// requested.
+	Scopes []string
+	// SecretExpiry is the lifetime of generated client secrets. Secrets are
+	// regenerated shortly before they expire. Defaults to 24 hours and may be
+	// at most 6 months.
+	SecretExpiry time.Duration
+	// Clock is the source of client secret issue and expiry times. Defaults
+	// to the gologin.SystemClock.
+	Clock gologin.Clock
+}
+
+// mustNormalize returns a copy of the Config with default Scopes,
+// SecretExpiry, and Clock. Panics if the Config is missing a field or the SecretExpiry
+// exceeds 6 months so misconfiguration is caught when handlers are
+// constructed rather than when requests are served.
+func (c Config) mustNormalize() Config {
+	if c.TeamID == "" || c.KeyID == "" || c.ClientID == "" || c.PrivateKey == nil {
+		panic("apple: Config requires a TeamID, KeyID, ClientID, and PrivateKey")
+	}
+	if c.SecretExpiry > maxSecretExpiry {
+		panic("apple: Config SecretExpiry exceeds 6 months")
+	}
+	if c.SecretExpiry <= 0 {
+		c.SecretExpiry = defaultSecretExpiry
+	}
+	if len(c.Scopes) == 0 {
+		c.Scopes = []string{"name", "email"}
+	}
+	if c.Clock == nil {
+		c.Clock = gologin.SystemClock
+	}
+	return c
+}
+
+// oauth2Config returns the oauth2.Config of the Config. The client secret is
+// sent as a token exchange option instead (see clientSecretHandler).
+func (c Config) oauth2Config() *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:    c.ClientID,
+		RedirectURL: c.RedirectURL,
+		Endpoint:    Endpoint,
+		Scopes:      c.Scopes,
+	}
+}
+
+// ParsePrivateKey parses a PEM encoded PKCS #8 P-256 private key, such as the
+// .p8 file downloaded from the Apple developer portal.
+func ParsePrivateKey(pemData []byte) (*ecdsa.PrivateKey, error) {
+	block, _ := pem.Decode(pemData)
+	if block == nil {
+		return nil, ErrInvalidPrivateKey
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, ErrInvalidPrivateKey
+	}
+	ecKey, ok := key.(*ecdsa.PrivateKey)
+	if !ok || ecKey.Curve != elliptic.P256() {
+		return nil, ErrInvalidPrivateKey
+	}
+	return ecKey, nil
+}
+
+// ClientSecret returns a new client secret, an ES256 JWT issued by the TeamID
+// for the ClientID which expires after the SecretExpiry. Handlers generate
+// and refresh client secrets themselves, but they are also needed for other
+// Apple endpoints (e.g. token revocation). Panics if the Config is invalid.
+// https://developer.apple.com/documentation/accountorganizationaldatasharing/creating-a-client-secret
+func (c Config) ClientSecret() (string, error) {
+	c = c.mustNormalize()
+	secret, _, err := c.newClientSecret(c.Clock.Now())
+	return secret, err
+}
+
+// newClientSecret returns a client secret issued at now and its expiry.
+func (c Config) newClientSecret(now time.Time) (string, time.Time, error) {
+	expiry := now.Add(c.SecretExpiry)
+	header := map[string]string{"alg": "ES256", "kid": c.KeyID}
+	claims := map[string]interface{}{
+		"iss": c.TeamID,
+		"sub": c.ClientID,
+		"aud": appleIssuer,
+		"iat": now.Unix(),
+		"exp": expiry.Unix(),
+	}
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString(claimsJSON)
+	digest := sha256.Sum256([]byte(signingInput))
+	r, s, err := ecdsa.Sign(rand.Reader, c.PrivateKey, digest[:])
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	// JWS ES256 signatures are the 32 byte big-endian R and S
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), expiry, nil
+}
+
+// clientSecretCache caches a client secret until shortly before it expires.
+type clientSecretCache struct {
+	config Config
+	mu     sync.Mutex
+	secret string
+	expiry time.Time
+}
+
+// newClientSecretCache returns a clientSecretCache for the (normalized)
+// Config.
+func newClientSecretCache(config Config) *clientSecretCache {
+	return &clientSecretCache{config: config}
+}
+
+// get returns the cached client secret or generates a new one if it expires
+// soon.
+func (c *clientSecretCache) get() (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.config.Clock.Now()
+	if c.secret != "" && now.Add(secretRefreshMargin).Before(c.expiry) {
+		return c.secret, nil
+	}
+	secret, expiry, err := c.config.newClientSecret(now)
+	if err != nil {
+		return "", err
+	}
+	c.secret, c.expiry = secret, expiry
+	return secret, nil
+}
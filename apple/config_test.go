@@ -0,0 +1,106 @@
+package apple
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/dghubble/gologin/gologintest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig_MustNormalize(t *testing.T) {
+	config := testConfig().mustNormalize()
+	assert.Equal(t, []string{"name", "email"}, config.Scopes)
+	assert.Equal(t, defaultSecretExpiry, config.SecretExpiry)
+
+	invalid := []func(*Config){
+		func(c *Config) { c.TeamID = "" },
+		func(c *Config) { c.KeyID = "" },
+		func(c *Config) { c.ClientID = "" },
+		func(c *Config) { c.PrivateKey = nil },
+		func(c *Config) { c.SecretExpiry = 200 * 24 * time.Hour },
+	}
+	for _, modify := range invalid {
+		config := testConfig()
+		modify(&config)
+		assert.Panics(t, func() { config.mustNormalize() })
+	}
+}
+
+func TestConfig_ClientSecret(t *testing.T) {
+	config := testConfig()
+	config.SecretExpiry = time.Hour
+	secret, err := config.ClientSecret()
+	assert.Nil(t, err)
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	var claims struct {
+		Iss string `json:"iss"`
+		Sub string `json:"sub"`
+		Aud string `json:"aud"`
+		Iat int64  `json:"iat"`
+		Exp int64  `json:"exp"`
+	}
+	if assert.True(t, verifyES256(&testPrivateKey.PublicKey, secret, &header, &claims)) {
+		assert.Equal(t, "ES256", header.Alg)
+		assert.Equal(t, "KEY123", header.Kid)
+		assert.Equal(t, "TEAM123", claims.Iss)
+		assert.Equal(t, "com.example.web", claims.Sub)
+		assert.Equal(t, "https://appleid.apple.com", claims.Aud)
+		assert.Equal(t, int64(3600), claims.Exp-claims.Iat)
+	}
+}
+
+func TestClientSecretCache(t *testing.T) {
+	clock := gologintest.NewFakeClock(time.Unix(1500000000, 0))
+	config := testConfig()
+	config.Clock = clock
+	cache := newClientSecretCache(config.mustNormalize())
+	secret, err := cache.get()
+	assert.Nil(t, err)
+	assert.Equal(t, clock.Now().Add(defaultSecretExpiry), cache.expiry)
+	// cached secrets are reused
+	clock.Advance(defaultSecretExpiry - 2*secretRefreshMargin)
+	cached, err := cache.get()
+	assert.Nil(t, err)
+	assert.Equal(t, secret, cached)
+	// secrets expiring soon are regenerated
+	clock.Advance(secretRefreshMargin + secretRefreshMargin/2)
+	refreshed, err := cache.get()
+	assert.Nil(t, err)
+	assert.NotEqual(t, secret, refreshed)
+	assert.Equal(t, clock.Now().Add(defaultSecretExpiry), cache.expiry)
+}
+
+func TestParsePrivateKey(t *testing.T) {
+	der, _ := x509.MarshalPKCS8PrivateKey(testPrivateKey)
+	p8 := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	key, err := ParsePrivateKey(p8)
+	if assert.Nil(t, err) {
+		assert.True(t, testPrivateKey.Equal(key))
+	}
+
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	rsaDER, _ := x509.MarshalPKCS8PrivateKey(rsaKey)
+	p384Key, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	p384DER, _ := x509.MarshalPKCS8PrivateKey(p384Key)
+	invalid := [][]byte{
+		[]byte("not pem"),
+		pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte("not der")}),
+		pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: rsaDER}),
+		pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: p384DER}),
+	}
+	for _, data := range invalid {
+		_, err := ParsePrivateKey(data)
+		assert.Equal(t, ErrInvalidPrivateKey, err)
+	}
+}
@@ -0,0 +1,47 @@
+package apple
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/dghubble/gologin"
+)
+
+// unexported key type prevents collisions
+type key int
+
+const (
+	userKey key = iota
+)
+
+// WithUser returns a copy of ctx that stores the Apple User and its gologin
+// Profile.
+func WithUser(ctx context.Context, user *User) context.Context {
+	ctx = gologin.WithProfile(ctx, newProfile(user))
+	return context.WithValue(ctx, userKey, user)
+}
+
+// UserFromContext returns the Apple User from the ctx.
+func UserFromContext(ctx context.Context) (*User, error) {
+	user, ok := ctx.Value(userKey).(*User)
+	if !ok {
+		return nil, fmt.Errorf("apple: Context missing Apple User")
+	}
+	return user, nil
+}
+
+// newProfile returns the gologin Profile of the Apple User.
+func newProfile(user *User) *gologin.Profile {
+	if user == nil {
+		return nil
+	}
+	return &gologin.Profile{
+		Provider:      ProviderName,
+		ID:            user.ID,
+		Email:         user.Email,
+		EmailVerified: user.EmailVerified,
+		Name:          strings.TrimSpace(user.FirstName + " " + user.LastName),
+		Raw:           gologin.ProfileRaw(user, "ID", "Email", "EmailVerified"),
+	}
+}
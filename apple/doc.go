@@ -0,0 +1,8 @@
+// Package apple provides Sign in with Apple login and callback handlers.
+//
+// Apple authenticates clients with a client secret which is an ES256 JWT
+// signed by a private key (a .p8 file) of the developer team, POSTs callbacks
+// (response_mode=form_post), and only sends the user's name on the first
+// authorization. The handlers generate client secrets from the Config and
+// build the User from the verified id_token claims and the name.
+package apple
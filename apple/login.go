@@ -0,0 +1,137 @@
+package apple
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/dghubble/gologin"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/dghubble/gologin/oidc"
+	"golang.org/x/oauth2"
+)
+
+// ProviderName is the provider name set in the ctx (see
+// gologin.ProviderFromContext) and the Profile.
+const ProviderName = "apple"
+
+const appleJWKSURL = "https://appleid.apple.com/auth/keys"
+
+// Apple login errors
+var (
+	ErrInvalidUser = errors.New("apple: invalid user form field")
+)
+
+// ResponseModeFormPost is an AuthCodeOption which sets
+// response_mode=form_post, which Apple requires when requesting scopes.
+var ResponseModeFormPost = oauth2.SetAuthURLParam("response_mode", "form_post")
+
+// StateHandler checks for a state cookie. If found, the state value is read
+// and added to the ctx. Otherwise, a non-guessable value is added to the ctx
+// and to a (short-lived) state cookie issued to the requester.
+//
+// Apple POSTs callbacks cross-site, so the config should be
+// gologin.FormPostCookieConfig (SameSite=None, Secure) or the state cookie
+// will not be sent with callbacks.
+func StateHandler(config gologin.CookieConfig, success http.Handler) http.Handler {
+	return oauth2Login.StateHandler(config, success)
+}
+
+// LoginHandler handles Sign in with Apple login requests by reading the state
+// value from the ctx and redirecting requests to the AuthURL with that state
+// value and response_mode=form_post. Any AuthCodeOptions are added to the
+// AuthURL. Panics if the Config is invalid.
+func LoginHandler(config Config, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	config = config.mustNormalize()
+	opts = append(opts[:len(opts):len(opts)], ResponseModeFormPost)
+	return oauth2Login.LoginHandler(config.oauth2Config(), failure, opts...)
+}
+
+// CallbackHandler handles Sign in with Apple callback POSTs. It exchanges the
+// code (with a client secret generated from the Config), verifies the
+// id_token with Apple's keys, and adds the Token, id_token Claims (see oidc
+// IDTokenFromContext), and User to the ctx. If authentication succeeds,
+// handling delegates to the success handler, otherwise to the failure
+// handler. Any AuthCodeOptions are sent with the token exchange. Panics if
+// the Config is invalid.
+//
+// The User name is only sent on the user's first authorization, so store it
+// then. If the ctx contains an OpenID Connect nonce (see oauth2 NonceHandler),
+// the id_token nonce must match.
+func CallbackHandler(config Config, success, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	config = config.mustNormalize()
+	success = appleHandler(config, success, failure)
+	success = oauth2Login.CallbackHandler(config.oauth2Config(), success, failure, opts...)
+	return clientSecretHandler(newClientSecretCache(config), success, failure)
+}
+
+// clientSecretHandler adds the (cached) client secret to the exchange options
+// in the ctx. If the client secret cannot be generated, the failure handler
+// is called.
+func clientSecretHandler(secrets *clientSecretCache, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		secret, err := secrets.get()
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		opts, _ := oauth2Login.ExchangeOptionsFromContext(ctx)
+		opts = append(opts[:len(opts):len(opts)], oauth2.SetAuthURLParam("client_secret", secret))
+		ctx = oauth2Login.WithExchangeOptions(ctx, opts...)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// appleHandler is a http.Handler that gets the OAuth2 Token from the ctx and
+// verifies its id_token to get the corresponding Apple User, merging the
+// first login user form field (if any). If successful, the Claims and User
+// are added to the ctx and the success handler is called. Otherwise, the
+// failure handler is called.
+func appleHandler(config Config, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	verifier := oidc.NewIDTokenVerifier(appleJWKSURL, config.ClientID, appleIssuer)
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		rawIDToken, ok := token.Extra("id_token").(string)
+		if !ok || rawIDToken == "" {
+			ctx = gologin.WithError(ctx, oidc.ErrMissingIDToken)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		claims, err := verifier.Verify(ctx, rawIDToken)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		nonce, _ := oauth2Login.NonceFromContext(ctx)
+		if nonce != "" && claims.Nonce != nonce {
+			ctx = gologin.WithError(ctx, oidc.ErrInvalidNonce)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		firstLogin, err := parseFirstLoginUser(req.PostFormValue("user"))
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		ctx = oidc.WithIDToken(ctx, claims)
+		ctx = WithUser(ctx, newUser(claims, firstLogin))
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
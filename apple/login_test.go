@@ -0,0 +1,204 @@
+package apple
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/dghubble/gologin"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/dghubble/gologin/oidc"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+const testFirstLoginUserJSON = `{"name": {"firstName": "Jane", "lastName": "Appleseed"}, "email": "jane@example.com"}`
+
+func testConfig() Config {
+	return Config{
+		TeamID:      "TEAM123",
+		KeyID:       "KEY123",
+		PrivateKey:  testPrivateKey,
+		ClientID:    "com.example.web",
+		RedirectURL: "https://example.com/apple/callback",
+	}
+}
+
+// newCallbackRequest returns a response_mode=form_post callback POST with the
+// code, state, and (if non-empty) first login user form fields.
+func newCallbackRequest(user string) *http.Request {
+	form := url.Values{"code": {"any_code"}, "state": {"d4e5f6"}}
+	if user != "" {
+		form.Set("user", user)
+	}
+	req, _ := http.NewRequest("POST", "/", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req
+}
+
+func TestLoginHandler(t *testing.T) {
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// LoginHandler assert that:
+	// - redirects to the Apple AuthURL with the state
+	// - response_mode=form_post and the default scopes are requested
+	loginHandler := LoginHandler(testConfig(), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	ctx := oauth2Login.WithState(context.Background(), "d4e5f6")
+	loginHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, http.StatusFound, w.Code)
+	location, err := url.Parse(w.HeaderMap.Get("Location"))
+	if assert.Nil(t, err) {
+		assert.Equal(t, "appleid.apple.com", location.Host)
+		assert.Equal(t, "/auth/authorize", location.Path)
+		assert.Equal(t, "d4e5f6", location.Query().Get("state"))
+		assert.Equal(t, "form_post", location.Query().Get("response_mode"))
+		assert.Equal(t, "name email", location.Query().Get("scope"))
+		assert.Equal(t, "com.example.web", location.Query().Get("client_id"))
+	}
+}
+
+func TestCallbackHandler(t *testing.T) {
+	cases := []struct {
+		claims       string
+		user         string
+		expectedUser *User
+	}{
+		// first authorization includes the user's name
+		{testClaims("jane@example.com", false), testFirstLoginUserJSON, &User{ID: "001234.abcdef", Email: "jane@example.com", EmailVerified: true, FirstName: "Jane", LastName: "Appleseed"}},
+		// subsequent authorizations only have the id_token claims
+		{testClaims("jane@example.com", false), "", &User{ID: "001234.abcdef", Email: "jane@example.com", EmailVerified: true}},
+		// Hide My Email relay address
+		{testClaims("x7df9k2@privaterelay.appleid.com", true), "", &User{ID: "001234.abcdef", Email: "x7df9k2@privaterelay.appleid.com", EmailVerified: true, IsPrivateEmail: true}},
+	}
+	for _, c := range cases {
+		proxyClient, server := newAppleTestServer(testIDToken(c.claims))
+		// oauth2 Client will use the proxy client's base Transport
+		ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+		ctx = oauth2Login.WithState(ctx, "d4e5f6")
+
+		success := func(w http.ResponseWriter, req *http.Request) {
+			ctx := req.Context()
+			token, err := oauth2Login.TokenFromContext(ctx)
+			if assert.Nil(t, err) {
+				assert.Equal(t, "any-token", token.AccessToken)
+				assert.Equal(t, "any-refresh", token.RefreshToken)
+			}
+			claims, err := oidc.IDTokenFromContext(ctx)
+			if assert.Nil(t, err) {
+				assert.Equal(t, "001234.abcdef", claims.Subject)
+			}
+			user, err := UserFromContext(ctx)
+			if assert.Nil(t, err) {
+				assert.Equal(t, c.expectedUser, user)
+			}
+			fmt.Fprintf(w, "success handler called")
+		}
+		failure := testutils.AssertFailureNotCalled(t)
+
+		// CallbackHandler assert that:
+		// - the form_post callback code is exchanged with a generated client secret
+		// - the id_token is verified with Apple's keys
+		// - success handler is called with the Token, Claims, and merged User in the ctx
+		callbackHandler := CallbackHandler(testConfig(), http.HandlerFunc(success), failure)
+		w := httptest.NewRecorder()
+		callbackHandler.ServeHTTP(w, newCallbackRequest(c.user).WithContext(ctx))
+		assert.Equal(t, "success handler called", w.Body.String())
+		server.Close()
+	}
+}
+
+func TestCallbackHandler_InvalidClientSecret(t *testing.T) {
+	proxyClient, server := newAppleTestServer(testIDToken(testClaims("jane@example.com", false)))
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithState(ctx, "d4e5f6")
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		var retrieveErr *oauth2.RetrieveError
+		assert.True(t, errors.As(gologin.ErrorFromContext(req.Context()), &retrieveErr))
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// CallbackHandler with the wrong key ID, assert that:
+	// - the token exchange fails
+	// - failure handler is called
+	config := testConfig()
+	config.KeyID = "OTHERKEY"
+	callbackHandler := CallbackHandler(config, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	callbackHandler.ServeHTTP(w, newCallbackRequest("").WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestAppleHandler_Errors(t *testing.T) {
+	proxyClient, server := newAppleTestServer("")
+	defer server.Close()
+	tokenWithIDToken := func(idToken string) *oauth2.Token {
+		token := &oauth2.Token{AccessToken: "any-token"}
+		return token.WithExtra(map[string]interface{}{"id_token": idToken})
+	}
+	otherAudience := strings.Replace(testClaims("jane@example.com", false), "com.example.web", "com.example.other", 1)
+
+	cases := []struct {
+		token *oauth2.Token
+		nonce string
+		user  string
+		err   error
+	}{
+		{&oauth2.Token{AccessToken: "any-token"}, "", "", oidc.ErrMissingIDToken},
+		{tokenWithIDToken(testIDToken(otherAudience)), "", "", oidc.ErrInvalidAudience},
+		{tokenWithIDToken(signES256(testPrivateKey, `{"alg":"ES256","kid":"apple-key"}`, testClaims("jane@example.com", false))), "", "", oidc.ErrInvalidSignature},
+		{tokenWithIDToken(testIDToken(testClaims("jane@example.com", false))), "some_nonce", "", oidc.ErrInvalidNonce},
+		{tokenWithIDToken(testIDToken(testClaims("jane@example.com", false))), "", "not-json", ErrInvalidUser},
+	}
+	for _, c := range cases {
+		// oauth2 Client will use the proxy client's base Transport
+		ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+		ctx = oauth2Login.WithToken(ctx, c.token)
+		if c.nonce != "" {
+			ctx = oauth2Login.WithNonce(ctx, c.nonce)
+		}
+		success := testutils.AssertSuccessNotCalled(t)
+		failure := func(w http.ResponseWriter, req *http.Request) {
+			assert.Equal(t, c.err, gologin.ErrorFromContext(req.Context()))
+			fmt.Fprintf(w, "failure handler called")
+		}
+
+		// AppleHandler with an invalid id_token or user, assert that:
+		// - failure handler is called with the error
+		appleHandler := appleHandler(testConfig(), success, http.HandlerFunc(failure))
+		w := httptest.NewRecorder()
+		appleHandler.ServeHTTP(w, newCallbackRequest(c.user).WithContext(ctx))
+		assert.Equal(t, "failure handler called", w.Body.String())
+	}
+}
+
+func TestAppleHandler_MissingCtxToken(t *testing.T) {
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "oauth2: Context missing Token", err.Error())
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// AppleHandler called without Token in ctx, assert that:
+	// - failure handler is called
+	// - error about ctx missing token is added to the failure handler ctx
+	appleHandler := appleHandler(testConfig(), success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	appleHandler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
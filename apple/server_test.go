@@ -0,0 +1,114 @@
+package apple
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/dghubble/gologin/testutils"
+)
+
+// testAppleKey signs test id_tokens and is served by newAppleTestServer.
+var testAppleKey, _ = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+// testPrivateKey is the test developer team's Sign in with Apple key.
+var testPrivateKey, _ = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+// signES256 returns a JWT with the JSON header and claims signed by the key.
+func signES256(key *ecdsa.PrivateKey, header, claims string) string {
+	signingInput := base64.RawURLEncoding.EncodeToString([]byte(header)) + "." + base64.RawURLEncoding.EncodeToString([]byte(claims))
+	digest := sha256.Sum256([]byte(signingInput))
+	r, s, _ := ecdsa.Sign(rand.Reader, key, digest[:])
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// verifyES256 verifies the JWT signature with the key and decodes its header
+// and claims. Returns false if the JWT is invalid.
+func verifyES256(key *ecdsa.PublicKey, jwt string, header, claims interface{}) bool {
+	parts := strings.Split(jwt, ".")
+	if len(parts) != 3 {
+		return false
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || len(signature) != 64 {
+		return false
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	r := new(big.Int).SetBytes(signature[:32])
+	s := new(big.Int).SetBytes(signature[32:])
+	if !ecdsa.Verify(key, digest[:], r, s) {
+		return false
+	}
+	headerJSON, _ := base64.RawURLEncoding.DecodeString(parts[0])
+	claimsJSON, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	return json.Unmarshal(headerJSON, header) == nil && json.Unmarshal(claimsJSON, claims) == nil
+}
+
+// testIDToken returns an Apple id_token with the given JSON claims.
+func testIDToken(claims string) string {
+	return signES256(testAppleKey, `{"alg":"ES256","kid":"apple-key"}`, claims)
+}
+
+// testClaims returns valid Apple id_token JSON claims with the email and
+// is_private_email claims.
+func testClaims(email string, private bool) string {
+	return fmt.Sprintf(`{"iss":"https://appleid.apple.com","aud":"com.example.web","sub":"001234.abcdef","exp":%d,"iat":%d,"email":%q,"email_verified":"true","is_private_email":"%t"}`,
+		time.Now().Add(time.Hour).Unix(), time.Now().Unix(), email, private)
+}
+
+// testJWKS returns the JSON Web Key Set of the testAppleKey.
+func testJWKS() string {
+	x := base64.RawURLEncoding.EncodeToString(testAppleKey.X.FillBytes(make([]byte, 32)))
+	y := base64.RawURLEncoding.EncodeToString(testAppleKey.Y.FillBytes(make([]byte, 32)))
+	return fmt.Sprintf(`{"keys": [{"kty": "EC", "kid": "apple-key", "use": "sig", "crv": "P-256", "x": %q, "y": %q}]}`, x, y)
+}
+
+// newAppleTestServer returns a new httptest.Server which mocks the Apple
+// token and keys endpoints and a client which proxies requests to the server.
+// The token endpoint requires a client secret signed by the testPrivateKey
+// for the testConfig and responds with the idToken. The caller must close the
+// server.
+func newAppleTestServer(idToken string) (*http.Client, *httptest.Server) {
+	client, mux, server := testutils.TestServer()
+	mux.HandleFunc("/auth/token", func(w http.ResponseWriter, r *http.Request) {
+		var header struct {
+			Alg string `json:"alg"`
+			Kid string `json:"kid"`
+		}
+		var claims struct {
+			Iss string `json:"iss"`
+			Sub string `json:"sub"`
+			Aud string `json:"aud"`
+			Exp int64  `json:"exp"`
+		}
+		secret := r.PostFormValue("client_secret")
+		valid := verifyES256(&testPrivateKey.PublicKey, secret, &header, &claims) &&
+			header.Alg == "ES256" && header.Kid == "KEY123" &&
+			claims.Iss == "TEAM123" && claims.Sub == "com.example.web" && claims.Aud == "https://appleid.apple.com" &&
+			time.Now().Before(time.Unix(claims.Exp, 0)) && r.PostFormValue("client_id") == "com.example.web"
+		w.Header().Set("Content-Type", "application/json")
+		if !valid {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, `{"error": "invalid_client"}`)
+			return
+		}
+		fmt.Fprintf(w, `{"access_token": "any-token", "token_type": "Bearer", "expires_in": 3600, "refresh_token": "any-refresh", "id_token": %q}`, idToken)
+	})
+	mux.HandleFunc("/auth/keys", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, testJWKS())
+	})
+	return client, server
+}
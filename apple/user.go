@@ -0,0 +1,77 @@
+package apple
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/dghubble/gologin/oidc"
+)
+
+// privateRelayDomain is the domain of Hide My Email addresses.
+const privateRelayDomain = "@privaterelay.appleid.com"
+
+// User is a Sign in with Apple user.
+type User struct {
+	// ID is the id_token sub, which is stable for the team
+	ID string
+	// Email is the verified email (or private relay address) from the id_token
+	Email         string
+	EmailVerified bool
+	// IsPrivateEmail is true if Email is a Hide My Email relay address
+	IsPrivateEmail bool
+	// FirstName and LastName are only sent on the user's first authorization
+	FirstName string
+	LastName  string
+}
+
+// firstLoginUser is the "user" form field Apple POSTs on the first
+// authorization of a client.
+type firstLoginUser struct {
+	Name struct {
+		FirstName string `json:"firstName"`
+		LastName  string `json:"lastName"`
+	} `json:"name"`
+	Email string `json:"email"`
+}
+
+// parseFirstLoginUser parses the "user" form field JSON, if any.
+func parseFirstLoginUser(data string) (*firstLoginUser, error) {
+	if data == "" {
+		return nil, nil
+	}
+	user := new(firstLoginUser)
+	if err := json.Unmarshal([]byte(data), user); err != nil {
+		return nil, ErrInvalidUser
+	}
+	return user, nil
+}
+
+// newUser returns the User of the verified id_token Claims and the first
+// login user, if any. The unsigned first login user only provides the name.
+func newUser(claims *oidc.Claims, firstLogin *firstLoginUser) *User {
+	user := &User{
+		ID:             claims.Subject,
+		Email:          claims.Email,
+		EmailVerified:  claimBool(claims.Extra["email_verified"]),
+		IsPrivateEmail: claimBool(claims.Extra["is_private_email"]),
+	}
+	if strings.HasSuffix(strings.ToLower(user.Email), privateRelayDomain) {
+		user.IsPrivateEmail = true
+	}
+	if firstLogin != nil {
+		user.FirstName = firstLogin.Name.FirstName
+		user.LastName = firstLogin.Name.LastName
+	}
+	return user
+}
+
+// claimBool returns a boolean claim, which Apple may encode as a string.
+func claimBool(claim interface{}) bool {
+	switch v := claim.(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true"
+	}
+	return false
+}
@@ -0,0 +1,63 @@
+package atlassian
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dghubble/gologin"
+)
+
+// unexported key type prevents collisions
+type key int
+
+const (
+	userKey key = iota
+	resourcesKey
+)
+
+// WithUser returns a copy of ctx that stores the Atlassian User and its gologin
+// Profile.
+func WithUser(ctx context.Context, user *User) context.Context {
+	ctx = gologin.WithProfile(ctx, newProfile(user))
+	return context.WithValue(ctx, userKey, user)
+}
+
+// UserFromContext returns the Atlassian User from the ctx.
+func UserFromContext(ctx context.Context) (*User, error) {
+	user, ok := ctx.Value(userKey).(*User)
+	if !ok {
+		return nil, fmt.Errorf("atlassian: Context missing Atlassian User")
+	}
+	return user, nil
+}
+
+// newProfile returns the gologin Profile of the Atlassian User.
+func newProfile(user *User) *gologin.Profile {
+	if user == nil {
+		return nil
+	}
+	return &gologin.Profile{
+		Provider:  ProviderName,
+		ID:        user.AccountID,
+		Email:     user.Email,
+		Name:      user.Name,
+		AvatarURL: user.Picture,
+		Raw:       gologin.ProfileRaw(user, "account_id", "email", "name", "picture"),
+	}
+}
+
+// WithResources returns a copy of ctx that stores the Atlassian accessible
+// Resources.
+func WithResources(ctx context.Context, resources []Resource) context.Context {
+	return context.WithValue(ctx, resourcesKey, resources)
+}
+
+// ResourcesFromContext returns the Atlassian accessible Resources from the
+// ctx.
+func ResourcesFromContext(ctx context.Context) ([]Resource, error) {
+	resources, ok := ctx.Value(resourcesKey).([]Resource)
+	if !ok {
+		return nil, fmt.Errorf("atlassian: Context missing Atlassian Resources")
+	}
+	return resources, nil
+}
@@ -0,0 +1,40 @@
+package atlassian
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContextUser(t *testing.T) {
+	expectedUser := &User{AccountID: "5b10ac8d82e05b22cc7d4ef5", Email: "mia@example.com"}
+	ctx := WithUser(context.Background(), expectedUser)
+	user, err := UserFromContext(ctx)
+	assert.Equal(t, expectedUser, user)
+	assert.Nil(t, err)
+}
+
+func TestContextUser_Error(t *testing.T) {
+	user, err := UserFromContext(context.Background())
+	assert.Nil(t, user)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "atlassian: Context missing Atlassian User", err.Error())
+	}
+}
+
+func TestContextResources(t *testing.T) {
+	expectedResources := []Resource{{ID: "1324a887-45db-1bf4-1e99-ef0ff456d421", Name: "acme"}}
+	ctx := WithResources(context.Background(), expectedResources)
+	resources, err := ResourcesFromContext(ctx)
+	assert.Equal(t, expectedResources, resources)
+	assert.Nil(t, err)
+}
+
+func TestContextResources_Error(t *testing.T) {
+	resources, err := ResourcesFromContext(context.Background())
+	assert.Nil(t, resources)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "atlassian: Context missing Atlassian Resources", err.Error())
+	}
+}
@@ -0,0 +1,3 @@
+// Package atlassian provides Atlassian (Jira and Confluence Cloud) OAuth2
+// login and callback handlers.
+package atlassian
@@ -0,0 +1,192 @@
+package atlassian
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/internal"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"golang.org/x/oauth2"
+)
+
+// ProviderName is the provider name set in the ctx (see
+// gologin.ProviderFromContext) and the Profile.
+const ProviderName = "atlassian"
+
+// OfflineAccessScope is the scope for a refresh token.
+const OfflineAccessScope = "offline_access"
+
+// Atlassian login errors
+var (
+	ErrUnableToGetAtlassianUser      = errors.New("atlassian: unable to get Atlassian User")
+	ErrUnableToGetAtlassianResources = errors.New("atlassian: unable to get Atlassian accessible resources")
+)
+
+// Endpoint is Atlassian's OAuth2 endpoint.
+var Endpoint = oauth2.Endpoint{
+	AuthURL:   "https://auth.atlassian.com/authorize",
+	TokenURL:  "https://auth.atlassian.com/oauth/token",
+	AuthStyle: oauth2.AuthStyleInParams,
+}
+
+// authCodeOptions are the AuthURL parameters Atlassian requires.
+var authCodeOptions = []oauth2.AuthCodeOption{
+	oauth2.SetAuthURLParam("audience", "api.atlassian.com"),
+	oauth2.SetAuthURLParam("prompt", "consent"),
+}
+
+// Config configures Atlassian login.
+type Config struct {
+	// OfflineAccess adds the offline_access scope to the requested scopes so
+	// the Token has a refresh token.
+	OfflineAccess bool
+	// AccessibleResources gets the Resources (sites) the Token may access and
+	// adds them to the ctx (see ResourcesFromContext), since API requests
+	// require a site's cloud ID.
+	AccessibleResources bool
+}
+
+// StateHandler checks for a state cookie. If found, the state value is read
+// and added to the ctx. Otherwise, a non-guessable value is added to the ctx
+// and to a (short-lived) state cookie issued to the requester.
+//
+// Implements OAuth 2 RFC 6749 10.12 CSRF Protection. If you wish to issue
+// state params differently, write a http.Handler which sets the ctx state,
+// using oauth2 WithState(ctx, state) since it is required by LoginHandler
+// and CallbackHandler.
+func StateHandler(config gologin.CookieConfig, success http.Handler) http.Handler {
+	return oauth2Login.StateHandler(config, success)
+}
+
+// LoginHandler handles Atlassian login requests by reading the state value
+// from the ctx and redirecting requests to the AuthURL with that state value
+// and the audience=api.atlassian.com and prompt=consent parameters, without
+// which Atlassian fails the authorization. Any AuthCodeOptions are added to
+// the AuthURL.
+func LoginHandler(config *oauth2.Config, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	return LoginHandlerWithConfig(config, Config{}, failure, opts...)
+}
+
+// LoginHandlerWithConfig handles Atlassian login requests like LoginHandler,
+// but also requests the offline_access scope if the Config has OfflineAccess.
+func LoginHandlerWithConfig(config *oauth2.Config, atlassianConfig Config, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	opts = append(authCodeOptions[:len(authCodeOptions):len(authCodeOptions)], opts...)
+	success := oauth2Login.LoginHandler(config, failure, opts...)
+	if !atlassianConfig.OfflineAccess {
+		return success
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		scopes, err := oauth2Login.ScopesFromContext(ctx)
+		if err != nil || len(scopes) == 0 {
+			scopes = config.Scopes
+		}
+		if !hasScope(scopes, OfflineAccessScope) {
+			scopes = append(scopes[:len(scopes):len(scopes)], OfflineAccessScope)
+		}
+		ctx = oauth2Login.WithScopes(ctx, scopes...)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// CallbackHandler handles Atlassian redirection URI requests and adds the
+// Atlassian access token and User to the ctx. If authentication succeeds,
+// handling delegates to the success handler, otherwise to the failure
+// handler.
+// Any AuthCodeOptions are sent with the token exchange.
+func CallbackHandler(config *oauth2.Config, success, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	return CallbackHandlerWithConfig(config, Config{}, success, failure, opts...)
+}
+
+// CallbackHandlerWithConfig handles Atlassian redirection URI requests like
+// CallbackHandler, but also adds the accessible Resources to the ctx if the
+// Config has AccessibleResources.
+func CallbackHandlerWithConfig(config *oauth2.Config, atlassianConfig Config, success, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	success = atlassianHandler(config, atlassianConfig, success, failure)
+	return oauth2Login.CallbackHandler(config, success, failure, opts...)
+}
+
+// atlassianHandler is a http.Handler that gets the OAuth2 Token from the ctx
+// to get the corresponding Atlassian User (and, per the Config, accessible
+// Resources). If successful, they are added to the ctx and the success
+// handler is called. Otherwise, the failure handler is called.
+func atlassianHandler(config *oauth2.Config, atlassianConfig Config, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		httpClient := internal.OAuth2Client(ctx, config, token)
+		atlassianClient := newClient(httpClient)
+		user, resp, err := atlassianClient.Me(ctx)
+		err = validateResponse(user, resp, err)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		if atlassianConfig.AccessibleResources {
+			resources, resp, err := atlassianClient.AccessibleResources(ctx)
+			err = validateResourcesResponse(resp, err)
+			if err != nil {
+				ctx = gologin.WithError(ctx, err)
+				failure.ServeHTTP(w, req.WithContext(ctx))
+				return
+			}
+			ctx = WithResources(ctx, resources)
+		}
+		ctx = WithUser(ctx, user)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// hasScope returns true if the scopes include the scope.
+func hasScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// validateResponse returns an error if the given Atlassian User, raw
+// http.Response, or error are unexpected. Returns nil if they are valid, or a
+// *gologin.Error which preserves the cause and status code.
+func validateResponse(user *User, resp *http.Response, err error) error {
+	var status int
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	if err != nil || status != http.StatusOK {
+		return &gologin.Error{Provider: "atlassian", Op: "get user", StatusCode: status, Err: err, Kind: ErrUnableToGetAtlassianUser}
+	}
+	if user == nil || user.AccountID == "" {
+		return &gologin.Error{Provider: "atlassian", Op: "get user", StatusCode: status, Kind: ErrUnableToGetAtlassianUser}
+	}
+	return nil
+}
+
+// validateResourcesResponse returns an error if the raw http.Response or
+// error of an accessible resources request are unexpected. Returns nil if
+// they are valid, or a *gologin.Error which preserves the cause and status
+// code.
+func validateResourcesResponse(resp *http.Response, err error) error {
+	var status int
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	if err != nil || status != http.StatusOK {
+		return &gologin.Error{Provider: "atlassian", Op: "get accessible resources", StatusCode: status, Err: err, Kind: ErrUnableToGetAtlassianResources}
+	}
+	return nil
+}
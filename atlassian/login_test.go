@@ -0,0 +1,229 @@
+package atlassian
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/dghubble/gologin"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func testConfig() *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     "client_id",
+		ClientSecret: "client_secret",
+		RedirectURL:  "https://example.com/atlassian/callback",
+		Endpoint:     Endpoint,
+		Scopes:       []string{"read:me", "read:jira-user"},
+	}
+}
+
+func TestLoginHandler(t *testing.T) {
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// LoginHandler assert that:
+	// - redirects to the Atlassian AuthURL with the state
+	// - the required audience and prompt params are added
+	// - the configured scopes are requested
+	loginHandler := LoginHandler(testConfig(), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	ctx := oauth2Login.WithState(context.Background(), "d4e5f6")
+	loginHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, http.StatusFound, w.Code)
+	location, err := url.Parse(w.HeaderMap.Get("Location"))
+	if assert.Nil(t, err) {
+		assert.Equal(t, "auth.atlassian.com", location.Host)
+		assert.Equal(t, "/authorize", location.Path)
+		assert.Equal(t, "d4e5f6", location.Query().Get("state"))
+		assert.Equal(t, "api.atlassian.com", location.Query().Get("audience"))
+		assert.Equal(t, "consent", location.Query().Get("prompt"))
+		assert.Equal(t, "read:me read:jira-user", location.Query().Get("scope"))
+	}
+}
+
+func TestLoginHandlerWithConfig_OfflineAccess(t *testing.T) {
+	cases := []struct {
+		ctxScopes      []string
+		expectedScopes string
+	}{
+		{nil, "read:me read:jira-user offline_access"},
+		{[]string{"read:me"}, "read:me offline_access"},
+		{[]string{"read:me", "offline_access"}, "read:me offline_access"},
+	}
+	for _, c := range cases {
+		failure := testutils.AssertFailureNotCalled(t)
+
+		// LoginHandlerWithConfig with OfflineAccess, assert that:
+		// - the offline_access scope is added to the config or ctx scopes
+		// - the required audience and prompt params are still added
+		loginHandler := LoginHandlerWithConfig(testConfig(), Config{OfflineAccess: true}, failure)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		ctx := oauth2Login.WithState(context.Background(), "d4e5f6")
+		if c.ctxScopes != nil {
+			ctx = oauth2Login.WithScopes(ctx, c.ctxScopes...)
+		}
+		loginHandler.ServeHTTP(w, req.WithContext(ctx))
+		location, err := url.Parse(w.HeaderMap.Get("Location"))
+		if assert.Nil(t, err) {
+			assert.Equal(t, c.expectedScopes, location.Query().Get("scope"))
+			assert.Equal(t, "api.atlassian.com", location.Query().Get("audience"))
+			assert.Equal(t, "consent", location.Query().Get("prompt"))
+		}
+	}
+}
+
+func TestCallbackHandler(t *testing.T) {
+	proxyClient, server := newAtlassianTestServer(testUserJSON, http.StatusOK, testResourcesJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithState(ctx, "d4e5f6")
+
+	success := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "any-token", token.AccessToken)
+			assert.Equal(t, "any-refresh", token.RefreshToken)
+		}
+		user, err := UserFromContext(ctx)
+		if assert.Nil(t, err) {
+			expectedUser := &User{AccountID: "5b10ac8d82e05b22cc7d4ef5", Email: "mia@example.com", Name: "Mia Krystof", Picture: "https://avatar-management--avatars.us-west-2.prod.public.atl-paas.net/initials/MK-4.png"}
+			assert.Equal(t, expectedUser, user)
+		}
+		// Resources are only requested with Config AccessibleResources
+		_, err = ResourcesFromContext(ctx)
+		assert.NotNil(t, err)
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// CallbackHandler assert that:
+	// - success handler is called with the Token and User in the ctx
+	callbackHandler := CallbackHandler(testConfig(), http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestCallbackHandlerWithConfig_AccessibleResources(t *testing.T) {
+	proxyClient, server := newAtlassianTestServer(testUserJSON, http.StatusOK, testResourcesJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithState(ctx, "d4e5f6")
+
+	success := func(w http.ResponseWriter, req *http.Request) {
+		resources, err := ResourcesFromContext(req.Context())
+		if assert.Nil(t, err) {
+			expectedResources := []Resource{{ID: "1324a887-45db-1bf4-1e99-ef0ff456d421", URL: "https://acme.atlassian.net", Name: "acme", Scopes: []string{"read:jira-user", "read:jira-work"}, AvatarURL: "https://site-admin-avatar-cdn.prod.public.atl-paas.net/avatars/240/flag.png"}}
+			assert.Equal(t, expectedResources, resources)
+		}
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// CallbackHandlerWithConfig with AccessibleResources, assert that:
+	// - success handler is called with the accessible Resources in the ctx
+	callbackHandler := CallbackHandlerWithConfig(testConfig(), Config{AccessibleResources: true}, http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestAtlassianHandler_ErrorGettingResources(t *testing.T) {
+	proxyClient, server := newAtlassianTestServer(testUserJSON, http.StatusUnauthorized, `{"code": 401, "message": "Unauthorized"}`)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.True(t, errors.Is(err, ErrUnableToGetAtlassianResources))
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// AtlassianHandler cannot get the accessible resources, assert that:
+	// - failure handler is called
+	// - error cannot get Atlassian accessible resources added to the ctx
+	atlassianHandler := atlassianHandler(testConfig(), Config{AccessibleResources: true}, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	atlassianHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestAtlassianHandler_MissingCtxToken(t *testing.T) {
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "oauth2: Context missing Token", err.Error())
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// AtlassianHandler called without Token in ctx, assert that:
+	// - failure handler is called
+	// - error about ctx missing token is added to the failure handler ctx
+	atlassianHandler := atlassianHandler(testConfig(), Config{}, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	atlassianHandler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestAtlassianHandler_ErrorGettingUser(t *testing.T) {
+	proxyClient, server := testutils.NewErrorServer("Atlassian Service Down", http.StatusInternalServerError)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.True(t, errors.Is(err, ErrUnableToGetAtlassianUser))
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// AtlassianHandler cannot get Atlassian User, assert that:
+	// - failure handler is called
+	// - error cannot get Atlassian User added to the failure handler ctx
+	atlassianHandler := atlassianHandler(testConfig(), Config{}, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	atlassianHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestValidateResponse(t *testing.T) {
+	validUser := &User{AccountID: "5b10ac8d82e05b22cc7d4ef5"}
+	validResponse := &http.Response{StatusCode: 200}
+	invalidResponse := &http.Response{StatusCode: 500}
+	assert.Equal(t, nil, validateResponse(validUser, validResponse, nil))
+	assert.True(t, errors.Is(validateResponse(validUser, validResponse, fmt.Errorf("Server error")), ErrUnableToGetAtlassianUser))
+	assert.True(t, errors.Is(validateResponse(validUser, invalidResponse, nil), ErrUnableToGetAtlassianUser))
+	assert.True(t, errors.Is(validateResponse(&User{}, validResponse, nil), ErrUnableToGetAtlassianUser))
+	assert.True(t, errors.Is(validateResponse(nil, validResponse, nil), ErrUnableToGetAtlassianUser))
+	assert.Equal(t, nil, validateResourcesResponse(validResponse, nil))
+	assert.True(t, errors.Is(validateResourcesResponse(invalidResponse, nil), ErrUnableToGetAtlassianResources))
+}
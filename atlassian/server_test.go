@@ -0,0 +1,36 @@
+package atlassian
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/dghubble/gologin/testutils"
+)
+
+const (
+	testUserJSON      = `{"account_type": "atlassian", "account_id": "5b10ac8d82e05b22cc7d4ef5", "email": "mia@example.com", "name": "Mia Krystof", "picture": "https://avatar-management--avatars.us-west-2.prod.public.atl-paas.net/initials/MK-4.png", "account_status": "active", "nickname": "mia", "zoneinfo": "Australia/Sydney", "locale": "en-US", "extended_profile": {"job_title": "Designer"}}`
+	testResourcesJSON = `[{"id": "1324a887-45db-1bf4-1e99-ef0ff456d421", "url": "https://acme.atlassian.net", "name": "acme", "scopes": ["read:jira-user", "read:jira-work"], "avatarUrl": "https://site-admin-avatar-cdn.prod.public.atl-paas.net/avatars/240/flag.png"}]`
+)
+
+// newAtlassianTestServer returns a new httptest.Server which mocks the
+// Atlassian token, me, and accessible-resources endpoints and a client which
+// proxies requests to the server. The accessible-resources endpoint responds
+// with the given status and json data. The caller must close the server.
+func newAtlassianTestServer(userJSON string, status int, resourcesJSON string) (*http.Client, *httptest.Server) {
+	client, mux, server := testutils.TestServer()
+	mux.HandleFunc("/oauth/token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token": "any-token", "expires_in": 3600, "token_type": "Bearer", "refresh_token": "any-refresh", "scope": "read:me read:jira-user offline_access"}`)
+	})
+	mux.HandleFunc("/me", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, userJSON)
+	})
+	mux.HandleFunc("/oauth/token/accessible-resources", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		fmt.Fprintf(w, resourcesJSON)
+	})
+	return client, server
+}
@@ -0,0 +1,57 @@
+package atlassian
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/dghubble/gologin/internal/jsonclient"
+)
+
+const atlassianAPI = "https://api.atlassian.com/"
+
+// User is an Atlassian account.
+type User struct {
+	AccountID string `json:"account_id"`
+	Email     string `json:"email"`
+	Name      string `json:"name"`
+	Picture   string `json:"picture"`
+}
+
+// Resource is an Atlassian site (e.g. a Jira or Confluence Cloud instance)
+// the Token may access. API requests to the site use its ID (cloud ID), as
+// in https://api.atlassian.com/ex/jira/{ID}/rest/api/3/myself.
+type Resource struct {
+	ID        string   `json:"id"`
+	URL       string   `json:"url"`
+	Name      string   `json:"name"`
+	Scopes    []string `json:"scopes"`
+	AvatarURL string   `json:"avatarUrl"`
+}
+
+// client is an Atlassian client for obtaining the current User.
+type client struct {
+	json *jsonclient.Client
+}
+
+// newClient returns a new Atlassian client.
+func newClient(httpClient *http.Client) *client {
+	return &client{
+		json: jsonclient.New(httpClient, atlassianAPI),
+	}
+}
+
+// Me returns the current Atlassian User (requires the read:me scope).
+// https://developer.atlassian.com/cloud/jira/platform/oauth-2-3lo-apps/#how-do-i-retrieve-the-public-profile-of-the-authenticated-user-
+func (c *client) Me(ctx context.Context) (*User, *http.Response, error) {
+	user := new(User)
+	resp, err := c.json.Get(ctx, "me", nil, user, nil)
+	return user, resp, err
+}
+
+// AccessibleResources returns the Resources the Token may access.
+// https://developer.atlassian.com/cloud/jira/platform/oauth-2-3lo-apps/#3-1-get-the-cloudid-for-your-site
+func (c *client) AccessibleResources(ctx context.Context) ([]Resource, *http.Response, error) {
+	var resources []Resource
+	resp, err := c.json.Get(ctx, "oauth/token/accessible-resources", nil, &resources, nil)
+	return resources, resp, err
+}
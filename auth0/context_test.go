@@ -0,0 +1,24 @@
+package auth0
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContextUser(t *testing.T) {
+	expectedUser := &User{ID: "auth0|5f7c8ec7c33c6c004bbafe82", Name: "Jane Doe"}
+	ctx := WithUser(context.Background(), expectedUser)
+	user, err := UserFromContext(ctx)
+	assert.Equal(t, expectedUser, user)
+	assert.Nil(t, err)
+}
+
+func TestContextUser_Error(t *testing.T) {
+	user, err := UserFromContext(context.Background())
+	assert.Nil(t, user)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "auth0: Context missing Auth0 User", err.Error())
+	}
+}
@@ -0,0 +1,2 @@
+// Package auth0 provides Auth0 OAuth2 login and callback handlers.
+package auth0
@@ -0,0 +1,193 @@
+package auth0
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/internal"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"golang.org/x/oauth2"
+)
+
+// ProviderName is the provider name set in the ctx (see
+// gologin.ProviderFromContext) and the Profile.
+const ProviderName = "auth0"
+
+// Auth0 login errors
+var (
+	ErrUnableToGetAuth0User = errors.New("auth0: unable to get Auth0 User")
+	ErrRateLimited          = errors.New("auth0: Auth0 userinfo rate limit exceeded")
+)
+
+// RateLimitError is the error of rate limited Auth0 userinfo requests, which
+// Auth0 limits per user.
+type RateLimitError struct {
+	// Reset is when the rate limit resets (zero if unknown)
+	Reset time.Time
+}
+
+func (e *RateLimitError) Error() string {
+	if e.Reset.IsZero() {
+		return ErrRateLimited.Error()
+	}
+	return fmt.Sprintf("%s, resets at %s", ErrRateLimited, e.Reset.UTC().Format(time.RFC3339))
+}
+
+// Is returns true for ErrRateLimited.
+func (e *RateLimitError) Is(target error) bool {
+	return target == ErrRateLimited
+}
+
+// Config configures Auth0 login.
+type Config struct {
+	// Domain is the Auth0 tenant domain (e.g. "example.us.auth0.com").
+	Domain string
+	// CustomDomain is the tenant's custom domain (e.g. "login.example.com"),
+	// if any. If set, it's used instead of the Domain.
+	CustomDomain string
+}
+
+// BaseURL returns the base URL of the Config (custom) domain. Panics if the
+// domain is invalid.
+func (c Config) BaseURL() string {
+	domain := c.Domain
+	if c.CustomDomain != "" {
+		domain = c.CustomDomain
+	}
+	raw := domain
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil || domain == "" || u.Host == "" {
+		panic("auth0: invalid Config domain " + domain)
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// Endpoint returns the OAuth2 endpoint of the Config (custom) domain. Panics
+// if the domain is invalid.
+func (c Config) Endpoint() oauth2.Endpoint {
+	baseURL := c.BaseURL()
+	return oauth2.Endpoint{
+		AuthURL:   baseURL + "/authorize",
+		TokenURL:  baseURL + "/oauth/token",
+		AuthStyle: oauth2.AuthStyleInParams,
+	}
+}
+
+// Audience returns an AuthCodeOption which sets the audience to request an
+// access token (JWT) for the API with the identifier. Pass it to
+// LoginHandler.
+func Audience(audience string) oauth2.AuthCodeOption {
+	return oauth2.SetAuthURLParam("audience", audience)
+}
+
+// Connection returns an AuthCodeOption which sets the connection to send
+// users directly to the upstream connection (e.g. "google-oauth2"), skipping
+// the Auth0 login page. Pass it to LoginHandler.
+func Connection(connection string) oauth2.AuthCodeOption {
+	return oauth2.SetAuthURLParam("connection", connection)
+}
+
+// StateHandler checks for a state cookie. If found, the state value is read
+// and added to the ctx. Otherwise, a non-guessable value is added to the ctx
+// and to a (short-lived) state cookie issued to the requester.
+//
+// Implements OAuth 2 RFC 6749 10.12 CSRF Protection. If you wish to issue
+// state params differently, write a http.Handler which sets the ctx state,
+// using oauth2 WithState(ctx, state) since it is required by LoginHandler
+// and CallbackHandler.
+func StateHandler(config gologin.CookieConfig, success http.Handler) http.Handler {
+	return oauth2Login.StateHandler(config, success)
+}
+
+// LoginHandler handles Auth0 login requests by reading the state value from
+// the ctx and redirecting requests to the AuthURL with that state value.
+// Any AuthCodeOptions (e.g. Audience or Connection) are added to the AuthURL.
+//
+// The config Endpoint should be the auth0 Config Endpoint and Scopes should
+// include "openid" (and "profile" or "email" for those claims).
+func LoginHandler(config *oauth2.Config, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	return oauth2Login.LoginHandler(config, failure, opts...)
+}
+
+// CallbackHandler handles Auth0 redirection URI requests and adds the Auth0
+// access token and User to the ctx. If authentication succeeds, handling
+// delegates to the success handler, otherwise to the failure handler.
+// Any AuthCodeOptions are sent with the token exchange.
+//
+// Rate limited userinfo requests fail with a *RateLimitError. Panics if the
+// Config domain is invalid.
+func CallbackHandler(config *oauth2.Config, auth0Config Config, success, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	success = auth0Handler(config, auth0Config, success, failure)
+	return oauth2Login.CallbackHandler(config, success, failure, opts...)
+}
+
+// auth0Handler is a http.Handler that gets the OAuth2 Token from the ctx to
+// get the corresponding Auth0 User. If successful, the User is added to the
+// ctx and the success handler is called. Otherwise, the failure handler is
+// called.
+func auth0Handler(config *oauth2.Config, auth0Config Config, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	baseURL := auth0Config.BaseURL()
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		httpClient := internal.OAuth2Client(ctx, config, token)
+		user, resp, err := newClient(httpClient, baseURL).UserInfo(ctx)
+		err = validateResponse(user, resp, err)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		ctx = WithUser(ctx, user)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// validateResponse returns an error if the given Auth0 User, raw
+// http.Response, or error are unexpected. Returns nil if they are valid, a
+// *RateLimitError if the request was rate limited, or a *gologin.Error which
+// preserves the cause and status code.
+func validateResponse(user *User, resp *http.Response, err error) error {
+	var status int
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	if status == http.StatusTooManyRequests {
+		return newRateLimitError(resp)
+	}
+	if err != nil || status != http.StatusOK {
+		return &gologin.Error{Provider: "auth0", Op: "get user", StatusCode: status, Err: err, Kind: ErrUnableToGetAuth0User}
+	}
+	if user == nil || user.ID == "" {
+		return &gologin.Error{Provider: "auth0", Op: "get user", StatusCode: status, Kind: ErrUnableToGetAuth0User}
+	}
+	return nil
+}
+
+// newRateLimitError returns a *RateLimitError for the 429 response, whose
+// X-RateLimit-Reset header is the reset time in Unix seconds.
+func newRateLimitError(resp *http.Response) *RateLimitError {
+	reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return &RateLimitError{}
+	}
+	return &RateLimitError{Reset: time.Unix(reset, 0)}
+}
@@ -0,0 +1,199 @@
+package auth0
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/dghubble/gologin"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+var testAuth0Config = Config{Domain: "example.us.auth0.com"}
+
+func testConfig() *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     "client_id",
+		ClientSecret: "client_secret",
+		RedirectURL:  "https://example.com/auth0/callback",
+		Endpoint:     testAuth0Config.Endpoint(),
+		Scopes:       []string{"openid", "profile", "email"},
+	}
+}
+
+func TestConfigEndpoint(t *testing.T) {
+	cases := []struct {
+		config   Config
+		authURL  string
+		tokenURL string
+	}{
+		{Config{Domain: "example.us.auth0.com"}, "https://example.us.auth0.com/authorize", "https://example.us.auth0.com/oauth/token"},
+		{Config{Domain: "example.us.auth0.com", CustomDomain: "login.example.com"}, "https://login.example.com/authorize", "https://login.example.com/oauth/token"},
+		{Config{CustomDomain: "https://login.example.com/"}, "https://login.example.com/authorize", "https://login.example.com/oauth/token"},
+	}
+	for _, c := range cases {
+		endpoint := c.config.Endpoint()
+		assert.Equal(t, c.authURL, endpoint.AuthURL)
+		assert.Equal(t, c.tokenURL, endpoint.TokenURL)
+		assert.Equal(t, oauth2.AuthStyleInParams, endpoint.AuthStyle)
+	}
+	assert.Panics(t, func() { Config{}.Endpoint() })
+	assert.Panics(t, func() { CallbackHandler(testConfig(), Config{}, nil, nil) })
+}
+
+func TestLoginHandler_Options(t *testing.T) {
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// LoginHandler with Audience and Connection options, assert that:
+	// - redirects to the Auth0 AuthURL with the state, audience, and
+	// connection
+	loginHandler := LoginHandler(testConfig(), failure, Audience("https://api.example.com"), Connection("google-oauth2"))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	ctx := oauth2Login.WithState(context.Background(), "d4e5f6")
+	loginHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, http.StatusFound, w.Code)
+	location, err := url.Parse(w.HeaderMap.Get("Location"))
+	if assert.Nil(t, err) {
+		assert.Equal(t, "example.us.auth0.com", location.Host)
+		assert.Equal(t, "/authorize", location.Path)
+		assert.Equal(t, "d4e5f6", location.Query().Get("state"))
+		assert.Equal(t, "https://api.example.com", location.Query().Get("audience"))
+		assert.Equal(t, "google-oauth2", location.Query().Get("connection"))
+	}
+}
+
+func TestCallbackHandler(t *testing.T) {
+	proxyClient, server := newAuth0TestServer(testUserJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithState(ctx, "d4e5f6")
+
+	expectedUser := &User{
+		ID:            "auth0|5f7c8ec7c33c6c004bbafe82",
+		Email:         "janedoe@example.com",
+		EmailVerified: true,
+		Name:          "Jane Doe",
+		Nickname:      "janedoe",
+		Picture:       "https://example.com/janedoe/me.jpg",
+		RawClaims: map[string]interface{}{
+			"https://example.com/roles": []interface{}{"admin"},
+			"https://example.com/plan":  "pro",
+		},
+	}
+	success := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "any-token", token.AccessToken)
+		}
+		user, err := UserFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, expectedUser, user)
+		}
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// CallbackHandler assert that:
+	// - success handler is called
+	// - Auth0 Token and User (with namespaced custom claims) are added to the
+	// ctx of the success handler
+	callbackHandler := CallbackHandler(testConfig(), testAuth0Config, http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestAuth0Handler_RateLimited(t *testing.T) {
+	proxyClient, server := newAuth0TestServer(testUserJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "rate-limited-token"})
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		assert.True(t, errors.Is(err, ErrRateLimited))
+		assert.Equal(t, &RateLimitError{Reset: time.Unix(testRateLimitReset, 0)}, err)
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// Auth0Handler is rate limited getting the Auth0 User, assert that:
+	// - failure handler is called with a RateLimitError of the reset time
+	auth0Handler := auth0Handler(testConfig(), testAuth0Config, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	auth0Handler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestAuth0Handler_MissingCtxToken(t *testing.T) {
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "oauth2: Context missing Token", err.Error())
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// Auth0Handler called without Token in ctx, assert that:
+	// - failure handler is called
+	// - error about ctx missing token is added to the failure handler ctx
+	auth0Handler := auth0Handler(testConfig(), testAuth0Config, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	auth0Handler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestAuth0Handler_ErrorGettingUser(t *testing.T) {
+	proxyClient, server := newAuth0TestServer(testUserJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "invalid-token"})
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.True(t, errors.Is(err, ErrUnableToGetAuth0User))
+			assert.False(t, errors.Is(err, ErrRateLimited))
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// Auth0Handler cannot get Auth0 User, assert that:
+	// - failure handler is called
+	// - error cannot get Auth0 User added to the failure handler ctx
+	auth0Handler := auth0Handler(testConfig(), testAuth0Config, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	auth0Handler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestValidateResponse(t *testing.T) {
+	validUser := &User{ID: "auth0|5f7c8ec7c33c6c004bbafe82", Name: "Jane Doe"}
+	validResponse := &http.Response{StatusCode: 200}
+	invalidResponse := &http.Response{StatusCode: 500}
+	rateLimitedResponse := &http.Response{StatusCode: 429, Header: http.Header{}}
+	assert.Equal(t, nil, validateResponse(validUser, validResponse, nil))
+	assert.True(t, errors.Is(validateResponse(validUser, validResponse, fmt.Errorf("Server error")), ErrUnableToGetAuth0User))
+	assert.True(t, errors.Is(validateResponse(validUser, invalidResponse, nil), ErrUnableToGetAuth0User))
+	assert.True(t, errors.Is(validateResponse(&User{Name: "Jane Doe"}, validResponse, nil), ErrUnableToGetAuth0User))
+	assert.Equal(t, &RateLimitError{}, validateResponse(validUser, rateLimitedResponse, nil))
+}
@@ -0,0 +1,47 @@
+package auth0
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/dghubble/gologin/testutils"
+)
+
+const (
+	// testUserJSON is a userinfo response with namespaced custom claims.
+	testUserJSON = `{"sub": "auth0|5f7c8ec7c33c6c004bbafe82", "email": "janedoe@example.com", "email_verified": true, "name": "Jane Doe", "nickname": "janedoe", "picture": "https://example.com/janedoe/me.jpg", "updated_at": "2024-01-02T03:04:05.000Z", "https://example.com/roles": ["admin"], "https://example.com/plan": "pro"}`
+	// testRateLimitReset is the X-RateLimit-Reset of rate limited responses.
+	testRateLimitReset = 1700000000
+)
+
+// newAuth0TestServer returns a new httptest.Server which mocks the Auth0
+// token and userinfo endpoints and a client which proxies requests to the
+// server. The userinfo endpoint responds with the given json data, or a 429
+// for the "rate-limited-token". The caller must close the server.
+func newAuth0TestServer(jsonData string) (*http.Client, *httptest.Server) {
+	client, mux, server := testutils.TestServer()
+	mux.HandleFunc("/oauth/token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token": "any-token", "token_type": "Bearer", "expires_in": 86400, "scope": "openid profile email"}`)
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Header.Get("Authorization") {
+		case "Bearer any-token":
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			fmt.Fprintf(w, jsonData)
+		case "Bearer rate-limited-token":
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.Header().Set("X-RateLimit-Limit", "5")
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("X-RateLimit-Reset", fmt.Sprint(testRateLimitReset))
+			w.WriteHeader(http.StatusTooManyRequests)
+			fmt.Fprintf(w, `{"error": "too_many_requests", "error_description": "Too Many Requests"}`)
+		default:
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprintf(w, "Unauthorized")
+		}
+	})
+	return client, server
+}
@@ -0,0 +1,72 @@
+package auth0
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/dghubble/gologin/internal/jsonclient"
+)
+
+// User is an Auth0 user from the OpenID Connect userinfo endpoint.
+type User struct {
+	ID            string `json:"sub"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name"`
+	Nickname      string `json:"nickname"`
+	Picture       string `json:"picture"`
+	// RawClaims are the namespaced custom claims (e.g.
+	// "https://example.com/roles") added by Auth0 Actions, by claim name
+	RawClaims map[string]interface{} `json:"-"`
+}
+
+// UnmarshalJSON decodes the userinfo claims into the User, keeping namespaced
+// custom claims in the RawClaims.
+func (u *User) UnmarshalJSON(data []byte) error {
+	// alias type has no UnmarshalJSON method
+	type user User
+	if err := json.Unmarshal(data, (*user)(u)); err != nil {
+		return err
+	}
+	var claims map[string]interface{}
+	if err := json.Unmarshal(data, &claims); err != nil {
+		return err
+	}
+	for name, value := range claims {
+		if isNamespaced(name) {
+			if u.RawClaims == nil {
+				u.RawClaims = make(map[string]interface{})
+			}
+			u.RawClaims[name] = value
+		}
+	}
+	return nil
+}
+
+// isNamespaced returns true if the claim name is namespaced by a URL, as
+// Auth0 recommends for custom claims.
+func isNamespaced(name string) bool {
+	return strings.HasPrefix(name, "http://") || strings.HasPrefix(name, "https://")
+}
+
+// client is an Auth0 client for obtaining a User.
+type client struct {
+	json *jsonclient.Client
+}
+
+// newClient returns a new Auth0 client for the tenant base URL.
+func newClient(httpClient *http.Client, baseURL string) *client {
+	return &client{
+		json: jsonclient.New(httpClient, baseURL+"/"),
+	}
+}
+
+// UserInfo gets the current Auth0 User.
+// https://auth0.com/docs/api/authentication#get-user-info
+func (c *client) UserInfo(ctx context.Context) (*User, *http.Response, error) {
+	user := new(User)
+	resp, err := c.json.Get(ctx, "userinfo", nil, user, nil)
+	return user, resp, err
+}
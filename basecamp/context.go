@@ -0,0 +1,63 @@
+package basecamp
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/dghubble/gologin"
+)
+
+// unexported key type prevents collisions
+type key int
+
+const (
+	userKey key = iota
+	accountsKey
+)
+
+// WithUser returns a copy of ctx that stores the Basecamp User and its
+// gologin Profile.
+func WithUser(ctx context.Context, user *User) context.Context {
+	ctx = gologin.WithProfile(ctx, newProfile(user))
+	return context.WithValue(ctx, userKey, user)
+}
+
+// UserFromContext returns the Basecamp User from the ctx.
+func UserFromContext(ctx context.Context) (*User, error) {
+	user, ok := ctx.Value(userKey).(*User)
+	if !ok {
+		return nil, fmt.Errorf("basecamp: Context missing Basecamp User")
+	}
+	return user, nil
+}
+
+// WithAccounts returns a copy of ctx that stores the Accounts the User can
+// access.
+func WithAccounts(ctx context.Context, accounts []Account) context.Context {
+	return context.WithValue(ctx, accountsKey, accounts)
+}
+
+// AccountsFromContext returns the Accounts the User can access from the ctx.
+func AccountsFromContext(ctx context.Context) ([]Account, error) {
+	accounts, ok := ctx.Value(accountsKey).([]Account)
+	if !ok {
+		return nil, fmt.Errorf("basecamp: Context missing Basecamp Accounts")
+	}
+	return accounts, nil
+}
+
+// newProfile returns the gologin Profile of the Basecamp User.
+func newProfile(user *User) *gologin.Profile {
+	if user == nil {
+		return nil
+	}
+	return &gologin.Profile{
+		Provider: ProviderName,
+		ID:       strconv.FormatInt(user.ID, 10),
+		Email:    user.EmailAddress,
+		Name:     strings.TrimSpace(user.FirstName + " " + user.LastName),
+		Raw:      gologin.ProfileRaw(user, "id", "email_address"),
+	}
+}
@@ -0,0 +1,40 @@
+package basecamp
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContextUser(t *testing.T) {
+	expectedUser := &User{ID: 9999999, FirstName: "Jason"}
+	ctx := WithUser(context.Background(), expectedUser)
+	user, err := UserFromContext(ctx)
+	assert.Equal(t, expectedUser, user)
+	assert.Nil(t, err)
+}
+
+func TestContextUser_Error(t *testing.T) {
+	user, err := UserFromContext(context.Background())
+	assert.Nil(t, user)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "basecamp: Context missing Basecamp User", err.Error())
+	}
+}
+
+func TestContextAccounts(t *testing.T) {
+	expectedAccounts := []Account{{Product: "bc3", ID: 88888888}}
+	ctx := WithAccounts(context.Background(), expectedAccounts)
+	accounts, err := AccountsFromContext(ctx)
+	assert.Equal(t, expectedAccounts, accounts)
+	assert.Nil(t, err)
+}
+
+func TestContextAccounts_Error(t *testing.T) {
+	accounts, err := AccountsFromContext(context.Background())
+	assert.Nil(t, accounts)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "basecamp: Context missing Basecamp Accounts", err.Error())
+	}
+}
@@ -0,0 +1,3 @@
+// Package basecamp provides Basecamp (37signals Launchpad) OAuth2 login and
+// callback handlers.
+package basecamp
@@ -0,0 +1,118 @@
+package basecamp
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/internal"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"golang.org/x/oauth2"
+)
+
+// ProviderName is the provider name set in the ctx (see
+// gologin.ProviderFromContext) and the Profile.
+const ProviderName = "basecamp"
+
+// Basecamp login errors
+var (
+	ErrUnableToGetBasecampUser = errors.New("basecamp: unable to get Basecamp User")
+)
+
+// Endpoint is 37signals Launchpad's OAuth2 endpoint.
+var Endpoint = oauth2.Endpoint{
+	AuthURL:   "https://launchpad.37signals.com/authorization/new",
+	TokenURL:  "https://launchpad.37signals.com/authorization/token",
+	AuthStyle: oauth2.AuthStyleInParams,
+}
+
+// webServerType is the non-standard type parameter Launchpad requires on
+// authorization and token requests.
+var webServerType = oauth2.SetAuthURLParam("type", "web_server")
+
+// StateHandler checks for a state cookie. If found, the state value is read
+// and added to the ctx. Otherwise, a non-guessable value is added to the ctx
+// and to a (short-lived) state cookie issued to the requester.
+//
+// Implements OAuth 2 RFC 6749 10.12 CSRF Protection. If you wish to issue
+// state params differently, write a http.Handler which sets the ctx state,
+// using oauth2 WithState(ctx, state) since it is required by LoginHandler
+// and CallbackHandler.
+func StateHandler(config gologin.CookieConfig, success http.Handler) http.Handler {
+	return oauth2Login.StateHandler(config, success)
+}
+
+// LoginHandler handles Basecamp login requests by reading the state value
+// from the ctx and redirecting requests to the AuthURL with that state value
+// and the type=web_server parameter Launchpad requires.
+// Any AuthCodeOptions are added to the AuthURL.
+func LoginHandler(config *oauth2.Config, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	opts = append([]oauth2.AuthCodeOption{webServerType}, opts...)
+	return oauth2Login.LoginHandler(config, failure, opts...)
+}
+
+// CallbackHandler handles Basecamp redirection URI requests and adds the
+// Basecamp access token, User, and the Accounts the User can access (see
+// AccountsFromContext) to the ctx. If authentication succeeds, handling
+// delegates to the success handler, otherwise to the failure handler.
+// The token exchange sends type=web_server and any AuthCodeOptions.
+//
+// Apps should let users choose which of the Accounts to connect, since API
+// requests are made against an Account Href.
+func CallbackHandler(config *oauth2.Config, success, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	success = basecampHandler(config, success, failure)
+	opts = append([]oauth2.AuthCodeOption{webServerType}, opts...)
+	return oauth2Login.CallbackHandler(config, success, failure, opts...)
+}
+
+// basecampHandler is a http.Handler that gets the OAuth2 Token from the ctx
+// to get the corresponding Launchpad authorization. If successful, the User
+// and Accounts are added to the ctx and the success handler is called.
+// Otherwise, the failure handler is called.
+func basecampHandler(config *oauth2.Config, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		httpClient := internal.OAuth2Client(ctx, config, token)
+		auth, resp, err := newClient(httpClient).Authorization(ctx)
+		err = validateResponse(auth.Identity, resp, err)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		ctx = WithUser(ctx, auth.Identity)
+		accounts := auth.Accounts
+		if accounts == nil {
+			accounts = []Account{}
+		}
+		ctx = WithAccounts(ctx, accounts)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// validateResponse returns an error if the given Basecamp User, raw
+// http.Response, or error are unexpected. Returns nil if they are valid, or a
+// *gologin.Error which preserves the cause and status code.
+func validateResponse(user *User, resp *http.Response, err error) error {
+	var status int
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	if err != nil || status != http.StatusOK {
+		return &gologin.Error{Provider: "basecamp", Op: "get user", StatusCode: status, Err: err, Kind: ErrUnableToGetBasecampUser}
+	}
+	if user == nil || user.ID == 0 {
+		return &gologin.Error{Provider: "basecamp", Op: "get user", StatusCode: status, Kind: ErrUnableToGetBasecampUser}
+	}
+	return nil
+}
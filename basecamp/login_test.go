@@ -0,0 +1,190 @@
+package basecamp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/dghubble/gologin"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func testConfig() *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     "client_id",
+		ClientSecret: "client_secret",
+		RedirectURL:  "https://example.com/basecamp/callback",
+		Endpoint:     Endpoint,
+	}
+}
+
+func TestLoginHandler(t *testing.T) {
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// LoginHandler assert that:
+	// - redirects to the Launchpad AuthURL with the state
+	// - the AuthURL has type=web_server
+	loginHandler := LoginHandler(testConfig(), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	ctx := oauth2Login.WithState(context.Background(), "d4e5f6")
+	loginHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, http.StatusFound, w.Code)
+	location, err := url.Parse(w.HeaderMap.Get("Location"))
+	if assert.Nil(t, err) {
+		assert.Equal(t, "launchpad.37signals.com", location.Host)
+		assert.Equal(t, "/authorization/new", location.Path)
+		assert.Equal(t, "d4e5f6", location.Query().Get("state"))
+		assert.Equal(t, "web_server", location.Query().Get("type"))
+	}
+}
+
+func TestCallbackHandler(t *testing.T) {
+	proxyClient, server := newBasecampTestServer(http.StatusOK, testAuthorizationJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithState(ctx, "d4e5f6")
+
+	success := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "any-token", token.AccessToken)
+			assert.Equal(t, "any-refresh", token.RefreshToken)
+		}
+		user, err := UserFromContext(ctx)
+		if assert.Nil(t, err) {
+			expectedUser := &User{ID: 9999999, FirstName: "Jason", LastName: "Fried", EmailAddress: "jason@example.com"}
+			assert.Equal(t, expectedUser, user)
+		}
+		accounts, err := AccountsFromContext(ctx)
+		if assert.Nil(t, err) {
+			expectedAccounts := []Account{
+				{Product: "bc3", ID: 88888888, Name: "Wayne Enterprises, Ltd.", Href: "https://3.basecampapi.com/88888888", AppHref: "https://3.basecamp.com/88888888"},
+				{Product: "bc3", ID: 77777777, Name: "Hogwarts", Href: "https://3.basecampapi.com/77777777", AppHref: "https://3.basecamp.com/77777777"},
+			}
+			assert.Equal(t, expectedAccounts, accounts)
+		}
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// CallbackHandler assert that:
+	// - the token exchange sends type=web_server
+	// - the User and Accounts are obtained from authorization.json
+	// - success handler is called with the Token, User, and Accounts in the ctx
+	callbackHandler := CallbackHandler(testConfig(), http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestCallbackHandler_MissingType(t *testing.T) {
+	proxyClient, server := newBasecampTestServer(http.StatusOK, testAuthorizationJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithState(ctx, "d4e5f6")
+
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		assert.NotNil(t, gologin.ErrorFromContext(req.Context()))
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// the stock oauth2 CallbackHandler omits type=web_server, assert that:
+	// - Launchpad rejects the token exchange
+	callbackHandler := oauth2Login.CallbackHandler(testConfig(), testutils.AssertSuccessNotCalled(t), http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestBasecampHandler_NoAccounts(t *testing.T) {
+	proxyClient, server := newBasecampTestServer(http.StatusOK, `{"identity": {"id": 9999999, "first_name": "Jason"}}`)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+
+	success := func(w http.ResponseWriter, req *http.Request) {
+		accounts, err := AccountsFromContext(req.Context())
+		assert.Nil(t, err)
+		assert.Empty(t, accounts)
+		fmt.Fprintf(w, "success handler called")
+	}
+
+	// BasecampHandler for a User without accounts, assert that:
+	// - an empty list of Accounts is added to the ctx
+	basecampHandler := basecampHandler(testConfig(), http.HandlerFunc(success), testutils.AssertFailureNotCalled(t))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	basecampHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestBasecampHandler_MissingCtxToken(t *testing.T) {
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "oauth2: Context missing Token", err.Error())
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// BasecampHandler called without Token in ctx, assert that:
+	// - failure handler is called
+	// - error about ctx missing token is added to the failure handler ctx
+	basecampHandler := basecampHandler(testConfig(), success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	basecampHandler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestBasecampHandler_ErrorGettingUser(t *testing.T) {
+	proxyClient, server := testutils.NewErrorServer("Launchpad Service Down", http.StatusInternalServerError)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.True(t, errors.Is(err, ErrUnableToGetBasecampUser))
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// BasecampHandler cannot get Basecamp User, assert that:
+	// - failure handler is called
+	// - error cannot get Basecamp User added to the failure handler ctx
+	basecampHandler := basecampHandler(testConfig(), success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	basecampHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestValidateResponse(t *testing.T) {
+	validUser := &User{ID: 9999999}
+	validResponse := &http.Response{StatusCode: 200}
+	invalidResponse := &http.Response{StatusCode: 500}
+	assert.Equal(t, nil, validateResponse(validUser, validResponse, nil))
+	assert.True(t, errors.Is(validateResponse(validUser, validResponse, fmt.Errorf("Server error")), ErrUnableToGetBasecampUser))
+	assert.True(t, errors.Is(validateResponse(validUser, invalidResponse, nil), ErrUnableToGetBasecampUser))
+	assert.True(t, errors.Is(validateResponse(&User{}, validResponse, nil), ErrUnableToGetBasecampUser))
+	assert.True(t, errors.Is(validateResponse(nil, validResponse, nil), ErrUnableToGetBasecampUser))
+}
@@ -0,0 +1,38 @@
+package basecamp
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/dghubble/gologin/testutils"
+)
+
+const (
+	// testAuthorizationJSON is a Launchpad authorization.json response.
+	testAuthorizationJSON = `{"expires_at": "2026-10-28T16:12:05.000Z", "identity": {"id": 9999999, "first_name": "Jason", "last_name": "Fried", "email_address": "jason@example.com"}, "accounts": [{"product": "bc3", "id": 88888888, "name": "Wayne Enterprises, Ltd.", "href": "https://3.basecampapi.com/88888888", "app_href": "https://3.basecamp.com/88888888"}, {"product": "bc3", "id": 77777777, "name": "Hogwarts", "href": "https://3.basecampapi.com/77777777", "app_href": "https://3.basecamp.com/77777777"}]}`
+)
+
+// newBasecampTestServer returns a new httptest.Server which mocks the
+// Launchpad token and authorization.json endpoints and a client which proxies
+// requests to the server. The token endpoint requires type=web_server and the
+// authorization.json endpoint responds with the given status and json data.
+// The caller must close the server.
+func newBasecampTestServer(status int, jsonData string) (*http.Client, *httptest.Server) {
+	client, mux, server := testutils.TestServer()
+	mux.HandleFunc("/authorization/token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.FormValue("type") != "web_server" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, `{"error": "invalid_request", "error_description": "Unsupported type"}`)
+			return
+		}
+		fmt.Fprintf(w, `{"access_token": "any-token", "expires_in": 1209600, "refresh_token": "any-refresh"}`)
+	})
+	mux.HandleFunc("/authorization.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		fmt.Fprintf(w, jsonData)
+	})
+	return client, server
+}
@@ -0,0 +1,56 @@
+package basecamp
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/dghubble/gologin/internal/jsonclient"
+)
+
+const launchpadAPI = "https://launchpad.37signals.com/"
+
+// User is a 37signals Launchpad identity.
+type User struct {
+	ID           int64  `json:"id"`
+	EmailAddress string `json:"email_address"`
+	FirstName    string `json:"first_name"`
+	LastName     string `json:"last_name"`
+}
+
+// Account is a 37signals account the User can access.
+type Account struct {
+	// Product is the account's product (e.g. "bc3" for Basecamp 3 and 4)
+	Product string `json:"product"`
+	ID      int64  `json:"id"`
+	Name    string `json:"name"`
+	// Href is the account's API base URL
+	Href string `json:"href"`
+	// AppHref is the account's web URL
+	AppHref string `json:"app_href"`
+}
+
+// authorization is a Launchpad authorization.json response.
+type authorization struct {
+	Identity *User     `json:"identity"`
+	Accounts []Account `json:"accounts"`
+}
+
+// client is a Launchpad client for obtaining the current authorization.
+type client struct {
+	json *jsonclient.Client
+}
+
+// newClient returns a new Launchpad client.
+func newClient(httpClient *http.Client) *client {
+	return &client{
+		json: jsonclient.New(httpClient, launchpadAPI),
+	}
+}
+
+// Authorization returns the User and Accounts of the access token.
+// https://github.com/basecamp/api/blob/master/sections/authentication.md#get-authorization
+func (c *client) Authorization(ctx context.Context) (*authorization, *http.Response, error) {
+	auth := new(authorization)
+	resp, err := c.json.Get(ctx, "authorization.json", nil, auth, nil)
+	return auth, resp, err
+}
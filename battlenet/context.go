@@ -0,0 +1,61 @@
+package battlenet
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/dghubble/gologin"
+)
+
+// unexported key type prevents collisions
+type key int
+
+const (
+	userKey key = iota
+	regionKey
+)
+
+// WithUser returns a copy of ctx that stores the Battle.net User and its gologin
+// Profile.
+func WithUser(ctx context.Context, user *User) context.Context {
+	ctx = gologin.WithProfile(ctx, newProfile(user))
+	return context.WithValue(ctx, userKey, user)
+}
+
+// UserFromContext returns the Battle.net User from the ctx.
+func UserFromContext(ctx context.Context) (*User, error) {
+	user, ok := ctx.Value(userKey).(*User)
+	if !ok {
+		return nil, fmt.Errorf("battlenet: Context missing Battle.net User")
+	}
+	return user, nil
+}
+
+// newProfile returns the gologin Profile of the Battle.net User.
+func newProfile(user *User) *gologin.Profile {
+	if user == nil {
+		return nil
+	}
+	return &gologin.Profile{
+		Provider: ProviderName,
+		ID:       strconv.FormatInt(user.ID, 10),
+		Name:     user.BattleTag,
+		Raw:      gologin.ProfileRaw(user, "id", "battletag"),
+	}
+}
+
+// WithRegion returns a copy of ctx that stores the Battle.net region.
+func WithRegion(ctx context.Context, region string) context.Context {
+	return context.WithValue(ctx, regionKey, region)
+}
+
+// RegionFromContext returns the Battle.net region the User logged in to from
+// the ctx, for scoping Blizzard API requests.
+func RegionFromContext(ctx context.Context) (string, error) {
+	region, ok := ctx.Value(regionKey).(string)
+	if !ok {
+		return "", fmt.Errorf("battlenet: Context missing Battle.net region")
+	}
+	return region, nil
+}
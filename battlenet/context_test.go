@@ -0,0 +1,39 @@
+package battlenet
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContextUser(t *testing.T) {
+	expectedUser := &User{Sub: "123456789", ID: 123456789, BattleTag: "Thrall#1234"}
+	ctx := WithUser(context.Background(), expectedUser)
+	user, err := UserFromContext(ctx)
+	assert.Equal(t, expectedUser, user)
+	assert.Nil(t, err)
+}
+
+func TestContextUser_Error(t *testing.T) {
+	user, err := UserFromContext(context.Background())
+	assert.Nil(t, user)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "battlenet: Context missing Battle.net User", err.Error())
+	}
+}
+
+func TestContextRegion(t *testing.T) {
+	ctx := WithRegion(context.Background(), RegionEU)
+	region, err := RegionFromContext(ctx)
+	assert.Equal(t, RegionEU, region)
+	assert.Nil(t, err)
+}
+
+func TestContextRegion_Error(t *testing.T) {
+	region, err := RegionFromContext(context.Background())
+	assert.Equal(t, "", region)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "battlenet: Context missing Battle.net region", err.Error())
+	}
+}
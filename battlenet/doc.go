@@ -0,0 +1,3 @@
+// Package battlenet provides Battle.net (Blizzard) OAuth2 login and callback
+// handlers.
+package battlenet
@@ -0,0 +1,164 @@
+package battlenet
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/internal"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"golang.org/x/oauth2"
+)
+
+// ProviderName is the provider name set in the ctx (see
+// gologin.ProviderFromContext) and the Profile.
+const ProviderName = "battlenet"
+
+const (
+	// globalOAuthURL is the OAuth host of all regions but China.
+	globalOAuthURL = "https://oauth.battle.net/"
+	// chinaOAuthURL is the OAuth host of the China region.
+	chinaOAuthURL = "https://oauth.battlenet.com.cn/"
+)
+
+// Battle.net regions
+const (
+	RegionUS = "us"
+	RegionEU = "eu"
+	RegionKR = "kr"
+	RegionTW = "tw"
+	RegionCN = "cn"
+)
+
+// Battle.net login errors
+var (
+	ErrUnableToGetBattlenetUser = errors.New("battlenet: unable to get Battle.net User")
+)
+
+// Config configures Battle.net login.
+type Config struct {
+	// Region is the Battle.net region (e.g. RegionEU), which is added to the
+	// ctx to scope Blizzard API requests. RegionCN uses the China OAuth host.
+	// Defaults to RegionUS.
+	Region string
+}
+
+// region returns the Config Region or the default RegionUS.
+func (c Config) region() string {
+	if c.Region == "" {
+		return RegionUS
+	}
+	return c.Region
+}
+
+// oauthURL returns the OAuth host base URL of the Config Region.
+func (c Config) oauthURL() string {
+	if c.region() == RegionCN {
+		return chinaOAuthURL
+	}
+	return globalOAuthURL
+}
+
+// Endpoint returns the Battle.net OAuth2 endpoint of the Config Region.
+func (c Config) Endpoint() oauth2.Endpoint {
+	oauthURL := c.oauthURL()
+	return oauth2.Endpoint{
+		AuthURL:   oauthURL + "authorize",
+		TokenURL:  oauthURL + "token",
+		AuthStyle: oauth2.AuthStyleInHeader,
+	}
+}
+
+// Endpoint is the Battle.net OAuth2 endpoint of all regions but China (see
+// Config Endpoint).
+var Endpoint = Config{}.Endpoint()
+
+// StateHandler checks for a state cookie. If found, the state value is read
+// and added to the ctx. Otherwise, a non-guessable value is added to the ctx
+// and to a (short-lived) state cookie issued to the requester.
+//
+// Implements OAuth 2 RFC 6749 10.12 CSRF Protection. If you wish to issue
+// state params differently, write a http.Handler which sets the ctx state,
+// using oauth2 WithState(ctx, state) since it is required by LoginHandler
+// and CallbackHandler.
+func StateHandler(config gologin.CookieConfig, success http.Handler) http.Handler {
+	return oauth2Login.StateHandler(config, success)
+}
+
+// LoginHandler handles Battle.net login requests by reading the state value
+// from the ctx and redirecting requests to the AuthURL with that state value.
+// Any AuthCodeOptions are added to the AuthURL.
+//
+// The config Endpoint should be the battlenet (Config) Endpoint and Scopes
+// should include "openid".
+func LoginHandler(config *oauth2.Config, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	return oauth2Login.LoginHandler(config, failure, opts...)
+}
+
+// CallbackHandler handles Battle.net redirection URI requests and adds the
+// Battle.net access token, User, and region (RegionUS) to the ctx. If
+// authentication succeeds, handling delegates to the success handler,
+// otherwise to the failure handler.
+// Any AuthCodeOptions are sent with the token exchange.
+func CallbackHandler(config *oauth2.Config, success, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	return CallbackHandlerWithConfig(config, Config{}, success, failure, opts...)
+}
+
+// CallbackHandlerWithConfig handles Battle.net redirection URI requests like
+// CallbackHandler, but gets the User from the Config Region's OAuth host and
+// adds the Config Region to the ctx.
+func CallbackHandlerWithConfig(config *oauth2.Config, battlenetConfig Config, success, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	success = battlenetHandler(config, battlenetConfig, success, failure)
+	return oauth2Login.CallbackHandler(config, success, failure, opts...)
+}
+
+// battlenetHandler is a http.Handler that gets the OAuth2 Token from the ctx
+// to get the corresponding Battle.net User. If successful, the User and
+// region are added to the ctx and the success handler is called. Otherwise,
+// the failure handler is called.
+func battlenetHandler(config *oauth2.Config, battlenetConfig Config, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	region := battlenetConfig.region()
+	oauthURL := battlenetConfig.oauthURL()
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		httpClient := internal.OAuth2Client(ctx, config, token)
+		user, resp, err := newClient(httpClient, oauthURL).UserInfo(ctx)
+		err = validateResponse(user, resp, err)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		ctx = WithUser(ctx, user)
+		ctx = WithRegion(ctx, region)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// validateResponse returns an error if the given Battle.net User, raw
+// http.Response, or error are unexpected. Returns nil if they are valid, or a
+// *gologin.Error which preserves the cause and status code. Users without a
+// BattleTag are valid.
+func validateResponse(user *User, resp *http.Response, err error) error {
+	var status int
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	if err != nil || status != http.StatusOK {
+		return &gologin.Error{Provider: "battlenet", Op: "get user", StatusCode: status, Err: err, Kind: ErrUnableToGetBattlenetUser}
+	}
+	if user == nil || user.Sub == "" {
+		return &gologin.Error{Provider: "battlenet", Op: "get user", StatusCode: status, Kind: ErrUnableToGetBattlenetUser}
+	}
+	return nil
+}
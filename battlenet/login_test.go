@@ -0,0 +1,176 @@
+package battlenet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dghubble/gologin"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func testConfig(battlenetConfig Config) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     "client_id",
+		ClientSecret: "client_secret",
+		RedirectURL:  "https://example.com/battlenet/callback",
+		Endpoint:     battlenetConfig.Endpoint(),
+		Scopes:       []string{"openid"},
+	}
+}
+
+func TestConfigEndpoint(t *testing.T) {
+	cases := []struct {
+		config   Config
+		authURL  string
+		tokenURL string
+	}{
+		{Config{}, "https://oauth.battle.net/authorize", "https://oauth.battle.net/token"},
+		{Config{Region: RegionEU}, "https://oauth.battle.net/authorize", "https://oauth.battle.net/token"},
+		{Config{Region: RegionCN}, "https://oauth.battlenet.com.cn/authorize", "https://oauth.battlenet.com.cn/token"},
+	}
+	for _, c := range cases {
+		endpoint := c.config.Endpoint()
+		assert.Equal(t, c.authURL, endpoint.AuthURL)
+		assert.Equal(t, c.tokenURL, endpoint.TokenURL)
+	}
+	assert.Equal(t, Config{}.Endpoint(), Endpoint)
+}
+
+func TestCallbackHandler(t *testing.T) {
+	cases := []struct {
+		name           string
+		config         Config
+		globalJSON     string
+		cnJSON         string
+		expectedUser   *User
+		expectedRegion string
+	}{
+		{"default region", Config{}, testUserJSON, testCNUserJSON, &User{Sub: "123456789", ID: 123456789, BattleTag: "Thrall#1234"}, RegionUS},
+		{"EU region", Config{Region: RegionEU}, testUserJSON, testCNUserJSON, &User{Sub: "123456789", ID: 123456789, BattleTag: "Thrall#1234"}, RegionEU},
+		{"CN region", Config{Region: RegionCN}, testUserJSON, testCNUserJSON, &User{Sub: "987654321", ID: 987654321, BattleTag: "萨尔#5123"}, RegionCN},
+		{"missing battletag", Config{}, testNoBattleTagJSON, testCNUserJSON, &User{Sub: "123456789", ID: 123456789}, RegionUS},
+	}
+	for _, c := range cases {
+		proxyClient, server := newBattlenetTestServer(c.globalJSON, c.cnJSON)
+		// oauth2 Client will use the proxy client's base Transport
+		ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+		ctx = oauth2Login.WithState(ctx, "d4e5f6")
+
+		success := func(w http.ResponseWriter, req *http.Request) {
+			ctx := req.Context()
+			token, err := oauth2Login.TokenFromContext(ctx)
+			if assert.Nil(t, err, c.name) {
+				assert.Equal(t, "any-token", token.AccessToken, c.name)
+			}
+			user, err := UserFromContext(ctx)
+			if assert.Nil(t, err, c.name) {
+				assert.Equal(t, c.expectedUser, user, c.name)
+			}
+			region, err := RegionFromContext(ctx)
+			if assert.Nil(t, err, c.name) {
+				assert.Equal(t, c.expectedRegion, region, c.name)
+			}
+			fmt.Fprintf(w, "success handler called")
+		}
+		failure := testutils.AssertFailureNotCalled(t)
+
+		// CallbackHandlerWithConfig assert that:
+		// - the User is from the Config Region's OAuth host
+		// - success handler is called (even without a battletag)
+		// - Battle.net Token, User, and region are added to the ctx of the
+		// success handler
+		callbackHandler := CallbackHandlerWithConfig(testConfig(c.config), c.config, http.HandlerFunc(success), failure)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+		callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+		assert.Equal(t, "success handler called", w.Body.String(), c.name)
+		server.Close()
+	}
+}
+
+func TestBattlenetHandler_MissingSub(t *testing.T) {
+	proxyClient, server := newBattlenetTestServer(testNoSubJSON, testCNUserJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		assert.True(t, errors.Is(err, ErrUnableToGetBattlenetUser))
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// BattlenetHandler gets a User without a sub, assert that:
+	// - failure handler is called with ErrUnableToGetBattlenetUser
+	battlenetHandler := battlenetHandler(testConfig(Config{}), Config{}, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	battlenetHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestBattlenetHandler_MissingCtxToken(t *testing.T) {
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "oauth2: Context missing Token", err.Error())
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// BattlenetHandler called without Token in ctx, assert that:
+	// - failure handler is called
+	// - error about ctx missing token is added to the failure handler ctx
+	battlenetHandler := battlenetHandler(testConfig(Config{}), Config{}, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	battlenetHandler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestBattlenetHandler_ErrorGettingUser(t *testing.T) {
+	proxyClient, server := testutils.NewErrorServer("Battle.net Service Down", http.StatusInternalServerError)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.True(t, errors.Is(err, ErrUnableToGetBattlenetUser))
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// BattlenetHandler cannot get Battle.net User, assert that:
+	// - failure handler is called
+	// - error cannot get Battle.net User added to the failure handler ctx
+	battlenetHandler := battlenetHandler(testConfig(Config{}), Config{}, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	battlenetHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestValidateResponse(t *testing.T) {
+	validUser := &User{Sub: "123456789", ID: 123456789, BattleTag: "Thrall#1234"}
+	validResponse := &http.Response{StatusCode: 200}
+	invalidResponse := &http.Response{StatusCode: 500}
+	assert.Equal(t, nil, validateResponse(validUser, validResponse, nil))
+	assert.Equal(t, nil, validateResponse(&User{Sub: "123456789", ID: 123456789}, validResponse, nil))
+	assert.True(t, errors.Is(validateResponse(validUser, validResponse, fmt.Errorf("Server error")), ErrUnableToGetBattlenetUser))
+	assert.True(t, errors.Is(validateResponse(validUser, invalidResponse, nil), ErrUnableToGetBattlenetUser))
+	assert.True(t, errors.Is(validateResponse(&User{ID: 123456789, BattleTag: "Thrall#1234"}, validResponse, nil), ErrUnableToGetBattlenetUser))
+}
@@ -0,0 +1,50 @@
+package battlenet
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/dghubble/gologin/testutils"
+)
+
+const (
+	// testUserJSON is a global userinfo response.
+	testUserJSON = `{"sub": "123456789", "id": 123456789, "battletag": "Thrall#1234"}`
+	// testCNUserJSON is a China userinfo response.
+	testCNUserJSON = `{"sub": "987654321", "id": 987654321, "battletag": "萨尔#5123"}`
+	// testNoBattleTagJSON is a userinfo response without a battletag.
+	testNoBattleTagJSON = `{"sub": "123456789", "id": 123456789}`
+	// testNoSubJSON is a userinfo response without a sub.
+	testNoSubJSON = `{"id": 123456789, "battletag": "Thrall#1234"}`
+)
+
+// newBattlenetTestServer returns a new httptest.Server which mocks the
+// Battle.net token and userinfo endpoints of the global and China OAuth hosts
+// and a client which proxies requests to the server. The userinfo endpoint
+// responds with the globalJSON or cnJSON data by host. The caller must close
+// the server.
+func newBattlenetTestServer(globalJSON, cnJSON string) (*http.Client, *httptest.Server) {
+	client, mux, server := testutils.TestServer()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json;charset=UTF-8")
+		fmt.Fprintf(w, `{"access_token": "any-token", "token_type": "bearer", "expires_in": 86399, "scope": "openid", "sub": "123456789"}`)
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json;charset=UTF-8")
+		if r.Header.Get("Authorization") != "Bearer any-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprintf(w, `{"error": "invalid_token", "error_description": "Invalid access token"}`)
+			return
+		}
+		switch r.Host {
+		case "oauth.battle.net":
+			fmt.Fprintf(w, globalJSON)
+		case "oauth.battlenet.com.cn":
+			fmt.Fprintf(w, cnJSON)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	return client, server
+}
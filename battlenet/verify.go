@@ -0,0 +1,38 @@
+package battlenet
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/dghubble/gologin/internal/jsonclient"
+)
+
+// User is a Battle.net user from the OpenID Connect userinfo endpoint.
+type User struct {
+	Sub string `json:"sub"`
+	// ID is the numeric Battle.net account ID
+	ID int64 `json:"id"`
+	// BattleTag is the Battle.net display name (e.g. "Name#1234"), which
+	// may be empty in some regions
+	BattleTag string `json:"battletag"`
+}
+
+// client is a Battle.net client for obtaining a User.
+type client struct {
+	json *jsonclient.Client
+}
+
+// newClient returns a new Battle.net client for the OAuth host base URL.
+func newClient(httpClient *http.Client, baseURL string) *client {
+	return &client{
+		json: jsonclient.New(httpClient, baseURL),
+	}
+}
+
+// UserInfo gets the current Battle.net User.
+// https://develop.battle.net/documentation/battle-net/oauth-apis
+func (c *client) UserInfo(ctx context.Context) (*User, *http.Response, error) {
+	user := new(User)
+	resp, err := c.json.Get(ctx, "userinfo", nil, user, nil)
+	return user, resp, err
+}
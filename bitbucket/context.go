@@ -3,6 +3,8 @@ package bitbucket
 import (
 	"context"
 	"fmt"
+
+	"github.com/dghubble/gologin"
 )
 
 // unexported key type prevents collisions
@@ -12,8 +14,10 @@ const (
 	userKey key = iota
 )
 
-// WithUser returns a copy of ctx that stores the Bitbucket User.
+// WithUser returns a copy of ctx that stores the Bitbucket User and its gologin
+// Profile.
 func WithUser(ctx context.Context, user *User) context.Context {
+	ctx = gologin.WithProfile(ctx, newProfile(user))
 	return context.WithValue(ctx, userKey, user)
 }
 
@@ -25,3 +29,19 @@ func UserFromContext(ctx context.Context) (*User, error) {
 	}
 	return user, nil
 }
+
+// newProfile returns the gologin Profile of the Bitbucket User. Email is the
+// primary confirmed email.
+func newProfile(user *User) *gologin.Profile {
+	if user == nil {
+		return nil
+	}
+	return &gologin.Profile{
+		Provider:      ProviderName,
+		ID:            user.UUID,
+		Email:         user.Email,
+		EmailVerified: user.Email != "",
+		Name:          gologin.DisplayName(user.DisplayName, user.Username, user.Nickname),
+		Raw:           gologin.ProfileRaw(user, "uuid", "email", "display_name"),
+	}
+}
@@ -5,15 +5,30 @@ import (
 	"net/http"
 
 	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/internal"
 	oauth2Login "github.com/dghubble/gologin/oauth2"
 	"golang.org/x/oauth2"
 )
 
+// ProviderName is the provider name set in the ctx (see
+// gologin.ProviderFromContext) and the Profile.
+const ProviderName = "bitbucket"
+
 // Bitbucket login errors
 var (
-	ErrUnableToGetBitbucketUser = errors.New("bitbucket: unable to get Bitbucket User")
+	ErrUnableToGetBitbucketUser   = errors.New("bitbucket: unable to get Bitbucket User")
+	ErrUnableToGetBitbucketEmails = errors.New("bitbucket: unable to get Bitbucket User emails")
 )
 
+// Config configures Bitbucket API requests.
+type Config struct {
+	// Transport is the base transport of Bitbucket API requests. Handlers
+	// share it across logins so connections are reused. Defaults to a pooled
+	// transport per handler. The transport of a ctx oauth2.HTTPClient is
+	// used instead, if any.
+	Transport http.RoundTripper
+}
+
 // StateHandler checks for a state cookie. If found, the state value is read
 // and added to the ctx. Otherwise, a non-guessable value is added to the ctx
 // and to a (short-lived) state cookie issued to the requester.
@@ -28,27 +43,39 @@ func StateHandler(config gologin.CookieConfig, success http.Handler) http.Handle
 
 // LoginHandler handles Bitbucket login requests by reading the state value
 // from the ctx and redirecting requests to the AuthURL with that state value.
-func LoginHandler(config *oauth2.Config, failure http.Handler) http.Handler {
-	return oauth2Login.LoginHandler(config, failure)
+// Any AuthCodeOptions are added to the AuthURL.
+func LoginHandler(config *oauth2.Config, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	return oauth2Login.LoginHandler(config, failure, opts...)
 }
 
 // CallbackHandler handles Bitbucket redirection URI requests and adds the
 // Bitbucket access token and User to the ctx. If authentication succeeds,
 // handling delegates to the success handler, otherwise to the failure
 // handler.
-func CallbackHandler(config *oauth2.Config, success, failure http.Handler) http.Handler {
-	success = bitbucketHandler(config, success, failure)
-	return oauth2Login.CallbackHandler(config, success, failure)
+// Any AuthCodeOptions are sent with the token exchange.
+func CallbackHandler(config *oauth2.Config, success, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	return CallbackHandlerWithConfig(config, Config{}, success, failure, opts...)
+}
+
+// CallbackHandlerWithConfig handles Bitbucket redirection URI requests like
+// CallbackHandler, but makes Bitbucket API requests according to the Config.
+func CallbackHandlerWithConfig(config *oauth2.Config, bitbucketConfig Config, success, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	success = bitbucketHandler(config, bitbucketConfig, success, failure)
+	return oauth2Login.CallbackHandler(config, success, failure, opts...)
 }
 
 // bitbucketHandler is a http.Handler that gets the OAuth2 Token from the ctx
-// to get the corresponding Bitbucket User. If successful, the User is added to
-// the ctx and the success handler is called. Otherwise, the failure handler is
-// called.
-func bitbucketHandler(config *oauth2.Config, success, failure http.Handler) http.Handler {
+// to get the corresponding Bitbucket User and its primary confirmed email
+// address. If successful, the User is added to the ctx and the success handler
+// is called. Otherwise, the failure handler is called.
+//
+// Users without a primary confirmed email, or Tokens without the email scope,
+// get an empty User Email.
+func bitbucketHandler(config *oauth2.Config, bitbucketConfig Config, success, failure http.Handler) http.Handler {
 	if failure == nil {
 		failure = gologin.DefaultFailureHandler
 	}
+	transport := internal.TransportOrNew(bitbucketConfig.Transport)
 	fn := func(w http.ResponseWriter, req *http.Request) {
 		ctx := req.Context()
 		token, err := oauth2Login.TokenFromContext(ctx)
@@ -57,15 +84,23 @@ func bitbucketHandler(config *oauth2.Config, success, failure http.Handler) http
 			failure.ServeHTTP(w, req.WithContext(ctx))
 			return
 		}
-		httpClient := config.Client(ctx, token)
+		httpClient := internal.OAuth2TransportClient(ctx, transport, config, token)
 		bitbucketClient := newClient(httpClient)
-		user, resp, err := bitbucketClient.CurrentUser()
+		user, resp, err := bitbucketClient.CurrentUser(ctx)
 		err = validateResponse(user, resp, err)
 		if err != nil {
 			ctx = gologin.WithError(ctx, err)
 			failure.ServeHTTP(w, req.WithContext(ctx))
 			return
 		}
+		emails, resp, err := bitbucketClient.Emails(ctx)
+		err = validateEmailsResponse(resp, err)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		user.Email = primaryEmail(emails)
 		ctx = WithUser(ctx, user)
 		success.ServeHTTP(w, req.WithContext(ctx))
 	}
@@ -73,13 +108,35 @@ func bitbucketHandler(config *oauth2.Config, success, failure http.Handler) http
 }
 
 // validateResponse returns an error if the given Bitbucket User, raw
-// http.Response, or error are unexpected. Returns nil if they are valid.
+// http.Response, or error are unexpected. Returns nil if they are valid, or a
+// *gologin.Error which preserves the cause and status code.
 func validateResponse(user *User, resp *http.Response, err error) error {
-	if err != nil || resp.StatusCode != http.StatusOK {
-		return ErrUnableToGetBitbucketUser
+	var status int
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	if err != nil || status != http.StatusOK {
+		return &gologin.Error{Provider: "bitbucket", Op: "get user", StatusCode: status, Err: err, Kind: ErrUnableToGetBitbucketUser}
+	}
+	if user == nil || (user.UUID == "" && user.Username == "") {
+		return &gologin.Error{Provider: "bitbucket", Op: "get user", StatusCode: status, Kind: ErrUnableToGetBitbucketUser}
+	}
+	return nil
+}
+
+// validateEmailsResponse returns an error if the raw http.Response or error of
+// a user emails request are unexpected. Forbidden or not found responses (e.g.
+// the email scope was not granted) are valid and have no emails.
+func validateEmailsResponse(resp *http.Response, err error) error {
+	var status int
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	if status == http.StatusForbidden || status == http.StatusNotFound {
+		return nil
 	}
-	if user == nil || user.Username == "" {
-		return ErrUnableToGetBitbucketUser
+	if err != nil || status != http.StatusOK {
+		return &gologin.Error{Provider: "bitbucket", Op: "get emails", StatusCode: status, Err: err, Kind: ErrUnableToGetBitbucketEmails}
 	}
 	return nil
 }
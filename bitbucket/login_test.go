@@ -2,6 +2,8 @@ package bitbucket
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -16,7 +18,7 @@ import (
 
 func TestBitbucketHandler(t *testing.T) {
 	jsonData := `{"username": "bitster", "display_name": "Atlas Ian"}`
-	expectedUser := &User{Username: "bitster", DisplayName: "Atlas Ian"}
+	expectedUser := &User{Username: "bitster", DisplayName: "Atlas Ian", Raw: json.RawMessage(jsonData)}
 	proxyClient, server := newBitbucketTestServer(jsonData)
 	defer server.Close()
 	// oauth2 Client will use the proxy client's base Transport
@@ -39,13 +41,34 @@ func TestBitbucketHandler(t *testing.T) {
 	// - bitbucket User is obtained from the Bitbucket API
 	// - success handler is called
 	// - bitbucket User is added to the ctx of the success handler
-	bitbucketHandler := bitbucketHandler(config, http.HandlerFunc(success), failure)
+	bitbucketHandler := bitbucketHandler(config, Config{}, http.HandlerFunc(success), failure)
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest("GET", "/", nil)
 	bitbucketHandler.ServeHTTP(w, req.WithContext(ctx))
 	assert.Equal(t, "success handler called", w.Body.String())
 }
 
+func TestBitbucketHandler_ReusesConnections(t *testing.T) {
+	_, server := newBitbucketEmailsTestServer(`{"username": "bitster"}`, http.StatusOK, testEmailsJSON)
+	defer server.Close()
+	transport, dials := testutils.DialCountingTransport(server)
+	ctx := oauth2Login.WithToken(context.Background(), &oauth2.Token{AccessToken: "any-token"})
+	success := func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(w, "success handler called")
+	}
+
+	// BitbucketHandler with a Transport, assert that:
+	// - the user and emails requests of sequential logins share one connection
+	bitbucketHandler := bitbucketHandler(&oauth2.Config{}, Config{Transport: transport}, http.HandlerFunc(success), testutils.AssertFailureNotCalled(t))
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		bitbucketHandler.ServeHTTP(w, req.WithContext(ctx))
+		assert.Equal(t, "success handler called", w.Body.String())
+	}
+	assert.Equal(t, 1, dials())
+}
+
 func TestBitbucketHandler_MissingCtxToken(t *testing.T) {
 	config := &oauth2.Config{}
 	success := testutils.AssertSuccessNotCalled(t)
@@ -61,7 +84,7 @@ func TestBitbucketHandler_MissingCtxToken(t *testing.T) {
 	// BitbucketHandler called without Token in ctx, assert that:
 	// - failure handler is called
 	// - error about ctx missing token is added to the failure handler ctx
-	bitbucketHandler := bitbucketHandler(config, success, http.HandlerFunc(failure))
+	bitbucketHandler := bitbucketHandler(config, Config{}, success, http.HandlerFunc(failure))
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest("GET", "/", nil)
 	bitbucketHandler.ServeHTTP(w, req)
@@ -82,7 +105,7 @@ func TestBitbucketHandler_ErrorGettingUser(t *testing.T) {
 		ctx := req.Context()
 		err := gologin.ErrorFromContext(ctx)
 		if assert.NotNil(t, err) {
-			assert.Equal(t, ErrUnableToGetBitbucketUser, err)
+			assert.True(t, errors.Is(err, ErrUnableToGetBitbucketUser))
 		}
 		fmt.Fprintf(w, "failure handler called")
 	}
@@ -90,19 +113,92 @@ func TestBitbucketHandler_ErrorGettingUser(t *testing.T) {
 	// BitbucketHandler cannot get Bitbucket User, assert that:
 	// - failure handler is called
 	// - error cannot get Bitbucket User added to the failure handler ctx
-	bitbucketHandler := bitbucketHandler(config, success, http.HandlerFunc(failure))
+	bitbucketHandler := bitbucketHandler(config, Config{}, success, http.HandlerFunc(failure))
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest("GET", "/", nil)
 	bitbucketHandler.ServeHTTP(w, req.WithContext(ctx))
 	assert.Equal(t, "failure handler called", w.Body.String())
 }
 
+func TestUser_UnmarshalJSON(t *testing.T) {
+	cases := []struct {
+		data     string
+		expected User
+	}{
+		// extra fields are kept in Raw only
+		{`{"uuid": "{c0ffee}", "username": "bitster", "created_on": "2011-12-20T16:34:07", "links": {"avatar": {"href": "https://example.com/a.png"}}}`, User{UUID: "{c0ffee}", Username: "bitster"}},
+		// missing fields are zero valued
+		{`{"uuid": "{c0ffee}"}`, User{UUID: "{c0ffee}"}},
+	}
+	for _, c := range cases {
+		var user User
+		assert.Nil(t, json.Unmarshal([]byte(c.data), &user))
+		// Raw round-trips the exact bytes
+		assert.Equal(t, c.data, string(user.Raw))
+		user.Raw = nil
+		assert.Equal(t, c.expected, user)
+	}
+}
+
+func TestBitbucketHandler_Emails(t *testing.T) {
+	userJSON := `{"uuid": "{c0ffee}", "account_id": "557058:c0ffee", "nickname": "bitster", "display_name": "Atlas Ian", "type": "user"}`
+	cases := []struct {
+		emailsStatus int
+		emailsJSON   string
+		email        string
+		err          error
+	}{
+		{http.StatusOK, testEmailsJSON, "atlas@example.com", nil},
+		{http.StatusOK, `{"values": [{"email": "atlas@example.com", "is_primary": true, "is_confirmed": false}]}`, "", nil},
+		{http.StatusOK, `{"values": []}`, "", nil},
+		{http.StatusForbidden, `{"type": "error", "error": {"message": "Access denied"}}`, "", nil},
+		{http.StatusInternalServerError, `{"type": "error"}`, "", ErrUnableToGetBitbucketEmails},
+	}
+	config := &oauth2.Config{}
+	for _, c := range cases {
+		proxyClient, server := newBitbucketEmailsTestServer(userJSON, c.emailsStatus, c.emailsJSON)
+		// oauth2 Client will use the proxy client's base Transport
+		ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+		ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+		success := func(w http.ResponseWriter, req *http.Request) {
+			assert.Nil(t, c.err)
+			bitbucketUser, err := UserFromContext(req.Context())
+			if assert.Nil(t, err) {
+				expectedUser := &User{UUID: "{c0ffee}", AccountID: "557058:c0ffee", Nickname: "bitster", DisplayName: "Atlas Ian", Email: c.email, Type: "user", Raw: json.RawMessage(userJSON)}
+				assert.Equal(t, expectedUser, bitbucketUser)
+			}
+			fmt.Fprintf(w, "success handler called")
+		}
+		failure := func(w http.ResponseWriter, req *http.Request) {
+			err := gologin.ErrorFromContext(req.Context())
+			assert.True(t, errors.Is(err, c.err))
+			fmt.Fprintf(w, "failure handler called")
+		}
+
+		// BitbucketHandler assert that:
+		// - the primary confirmed email from the first emails page is added to the User
+		// - unconfirmed emails or a missing email scope leave the email empty
+		// - other emails errors call the failure handler
+		bitbucketHandler := bitbucketHandler(config, Config{}, http.HandlerFunc(success), http.HandlerFunc(failure))
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		bitbucketHandler.ServeHTTP(w, req.WithContext(ctx))
+		if c.err == nil {
+			assert.Equal(t, "success handler called", w.Body.String())
+		} else {
+			assert.Equal(t, "failure handler called", w.Body.String())
+		}
+		server.Close()
+	}
+}
+
 func TestValidateResponse(t *testing.T) {
 	validUser := &User{Username: "bitster"}
 	validResponse := &http.Response{StatusCode: 200}
 	invalidResponse := &http.Response{StatusCode: 500}
 	assert.Equal(t, nil, validateResponse(validUser, validResponse, nil))
-	assert.Equal(t, ErrUnableToGetBitbucketUser, validateResponse(validUser, validResponse, fmt.Errorf("Server error")))
-	assert.Equal(t, ErrUnableToGetBitbucketUser, validateResponse(validUser, invalidResponse, nil))
-	assert.Equal(t, ErrUnableToGetBitbucketUser, validateResponse(&User{}, validResponse, nil))
+	assert.True(t, errors.Is(validateResponse(validUser, validResponse, fmt.Errorf("Server error")), ErrUnableToGetBitbucketUser))
+	assert.True(t, errors.Is(validateResponse(validUser, invalidResponse, nil), ErrUnableToGetBitbucketUser))
+	assert.True(t, errors.Is(validateResponse(&User{}, validResponse, nil), ErrUnableToGetBitbucketUser))
+	assert.Equal(t, nil, validateResponse(&User{UUID: "{c0ffee}"}, validResponse, nil))
 }
@@ -14,9 +14,39 @@ import (
 // server.
 func newBitbucketTestServer(jsonData string) (*http.Client, *httptest.Server) {
 	client, mux, server := testutils.TestServer()
-	mux.HandleFunc("/api/2.0/user", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/2.0/user", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 		fmt.Fprintf(w, jsonData)
 	})
 	return client, server
 }
+
+// testEmailsJSON is a paginated Bitbucket user emails response.
+const testEmailsJSON = `{
+	"pagelen": 2,
+	"page": 1,
+	"size": 3,
+	"values": [
+		{"email": "old@example.com", "is_primary": false, "is_confirmed": true, "type": "email"},
+		{"email": "atlas@example.com", "is_primary": true, "is_confirmed": true, "type": "email"}
+	],
+	"next": "https://api.bitbucket.org/2.0/user/emails?page=2"
+}`
+
+// newBitbucketEmailsTestServer returns a new httptest.Server which mocks the
+// Bitbucket user and user emails endpoints and a client which proxies requests
+// to the server. The emails endpoint responds with the given status code and
+// json data. The caller must close the server.
+func newBitbucketEmailsTestServer(userJSON string, emailsStatus int, emailsJSON string) (*http.Client, *httptest.Server) {
+	client, mux, server := testutils.TestServer()
+	mux.HandleFunc("/2.0/user", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, userJSON)
+	})
+	mux.HandleFunc("/2.0/user/emails", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(emailsStatus)
+		fmt.Fprintf(w, emailsJSON)
+	})
+	return client, server
+}
@@ -1,39 +1,96 @@
 package bitbucket
 
 import (
+	"context"
+	"encoding/json"
 	"net/http"
 
-	"github.com/dghubble/sling"
+	"github.com/dghubble/gologin/internal/jsonclient"
 )
 
-const bitbucketAPI = "https://bitbucket.org/api/2.0/"
+const bitbucketAPI = "https://api.bitbucket.org/2.0/"
 
 // User is a Bitbucket user.
 type User struct {
+	UUID        string `json:"uuid"`
+	AccountID   string `json:"account_id"`
+	Nickname    string `json:"nickname"`
 	Username    string `json:"username"`
 	DisplayName string `json:"display_name"`
+	Email       string `json:"email"` // primary confirmed email, from user/emails
 	Website     string `json:"website"`
 	Location    string `json:"location"`
 	Type        string `json:"type"` // user, team
+	// Raw is the /user response body, including fields User does not
+	// decode, to decode into richer types.
+	Raw json.RawMessage `json:"-"`
+}
+
+// UnmarshalJSON decodes the User fields and keeps a copy of the data in Raw.
+func (u *User) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	// user has no methods, so decoding it does not recurse
+	type user User
+	var decoded user
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	*u = User(decoded)
+	u.Raw = append(json.RawMessage(nil), data...)
+	return nil
+}
+
+// Email is a Bitbucket user email address.
+type Email struct {
+	Email       string `json:"email"`
+	IsPrimary   bool   `json:"is_primary"`
+	IsConfirmed bool   `json:"is_confirmed"`
+	Type        string `json:"type"`
+}
+
+// emailsPage is a page of a paginated Bitbucket user emails response.
+type emailsPage struct {
+	Values []Email `json:"values"`
+	Next   string  `json:"next"`
 }
 
 // client is a Bitbucket client for obtaining a User.
 type client struct {
-	sling *sling.Sling
+	json *jsonclient.Client
 }
 
 // newClient returns a new Bitbucket client.
 func newClient(httpClient *http.Client) *client {
-	base := sling.New().Client(httpClient).Base(bitbucketAPI)
 	return &client{
-		sling: base,
+		json: jsonclient.New(httpClient, bitbucketAPI),
 	}
 }
 
 // CurrentUser gets the current user's profile information.
-// https://confluence.atlassian.com/bitbucket/users-endpoint-423626336.html
-func (c *client) CurrentUser() (*User, *http.Response, error) {
+// https://developer.atlassian.com/cloud/bitbucket/rest/api-group-users/#api-user-get
+func (c *client) CurrentUser(ctx context.Context) (*User, *http.Response, error) {
 	user := new(User)
-	resp, err := c.sling.New().Get("user").ReceiveSuccess(user)
+	resp, err := c.json.Get(ctx, "user", nil, user, nil)
 	return user, resp, err
 }
+
+// Emails gets the first page of the current user's email addresses. Requires
+// the email scope.
+// https://developer.atlassian.com/cloud/bitbucket/rest/api-group-users/#api-user-emails-get
+func (c *client) Emails(ctx context.Context) ([]Email, *http.Response, error) {
+	page := new(emailsPage)
+	resp, err := c.json.Get(ctx, "user/emails", nil, page, nil)
+	return page.Values, resp, err
+}
+
+// primaryEmail returns the primary confirmed email address, if any.
+func primaryEmail(emails []Email) string {
+	for _, email := range emails {
+		if email.IsPrimary && email.IsConfirmed {
+			return email.Email
+		}
+	}
+	return ""
+}
@@ -0,0 +1,114 @@
+package gologin
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// DefaultMaxBodyBytes is the default BodyConfig MaxBodyBytes.
+const DefaultMaxBodyBytes = 64 << 10
+
+// Errors for requests rejected by BodyHandler.
+var (
+	ErrMethodNotAllowed       = errors.New("gologin: method not allowed")
+	ErrBodyTooLarge           = errors.New("gologin: request body too large")
+	ErrUnsupportedContentType = errors.New("gologin: unsupported request content type")
+)
+
+// BodyConfig configures BodyHandler.
+type BodyConfig struct {
+	// MaxBodyBytes limits the size of request bodies. Defaults to
+	// DefaultMaxBodyBytes (64KB).
+	MaxBodyBytes int64
+	// ContentTypes are the media types accepted for request bodies. Defaults
+	// to application/x-www-form-urlencoded and application/json.
+	ContentTypes []string
+	// Methods are the allowed request methods. Defaults to POST.
+	Methods []string
+}
+
+// BodyHandler hardens handlers which accept tokens or form posts from
+// clients (e.g. TokenHandlers and form_post callbacks). Requests with a
+// method other than the config Methods call the failure handler with a 405,
+// an Allow header, and ErrMethodNotAllowed. Requests with a body must have
+// one of the config ContentTypes (else a 415 and ErrUnsupportedContentType)
+// and a body of at most MaxBodyBytes, read with http.MaxBytesReader before
+// the success handler parses it (else a 413 and ErrBodyTooLarge).
+func BodyHandler(config BodyConfig, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = DefaultFailureHandler
+	}
+	if config.MaxBodyBytes <= 0 {
+		config.MaxBodyBytes = DefaultMaxBodyBytes
+	}
+	if len(config.ContentTypes) == 0 {
+		config.ContentTypes = []string{"application/x-www-form-urlencoded", "application/json"}
+	}
+	if len(config.Methods) == 0 {
+		config.Methods = []string{"POST"}
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		if !containsFold(config.Methods, req.Method) {
+			w.Header().Set("Allow", strings.Join(config.Methods, ", "))
+			ctx = WithError(ctx, ErrMethodNotAllowed)
+			ctx = WithStatusCode(ctx, http.StatusMethodNotAllowed)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		if req.Body == nil || req.Body == http.NoBody || req.ContentLength == 0 {
+			success.ServeHTTP(w, req)
+			return
+		}
+		if !config.acceptsContentType(req.Header.Get("Content-Type")) {
+			ctx = WithError(ctx, ErrUnsupportedContentType)
+			ctx = WithStatusCode(ctx, http.StatusUnsupportedMediaType)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		if req.ContentLength > config.MaxBodyBytes {
+			ctx = WithError(ctx, ErrBodyTooLarge)
+			ctx = WithStatusCode(ctx, http.StatusRequestEntityTooLarge)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(w, req.Body, config.MaxBodyBytes))
+		if err != nil {
+			status := http.StatusBadRequest
+			if int64(len(body)) >= config.MaxBodyBytes {
+				err, status = ErrBodyTooLarge, http.StatusRequestEntityTooLarge
+			}
+			ctx = WithError(ctx, err)
+			ctx = WithStatusCode(ctx, status)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		success.ServeHTTP(w, req)
+	}
+	return http.HandlerFunc(fn)
+}
+
+// acceptsContentType returns true if the media type of the Content-Type
+// header is one of the ContentTypes.
+func (c BodyConfig) acceptsContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return containsFold(c.ContentTypes, mediaType)
+}
+
+// containsFold returns true if the values contain the value, ignoring case.
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+package gologin
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// chunkedReader hides the length of a body so requests have an unknown
+// ContentLength.
+type chunkedReader struct {
+	io.Reader
+}
+
+func TestBodyHandler(t *testing.T) {
+	success := func(w http.ResponseWriter, req *http.Request) {
+		req.ParseForm()
+		fmt.Fprintf(w, "success handler called with %s", req.PostForm.Get("access_token"))
+	}
+	handler := BodyHandler(BodyConfig{}, http.HandlerFunc(success), nil)
+
+	// BodyHandler with a small form POST, assert that:
+	// - the success handler can parse the body
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/token", strings.NewReader(url.Values{"access_token": {"some-token"}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "success handler called with some-token", w.Body.String())
+
+	// - POSTs without a body are passed through
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", "/token", nil))
+	assert.Equal(t, "success handler called with ", w.Body.String())
+}
+
+func TestBodyHandler_Errors(t *testing.T) {
+	oversized := url.Values{"access_token": {strings.Repeat("a", DefaultMaxBodyBytes)}}.Encode()
+	var multipartBody bytes.Buffer
+	writer := multipart.NewWriter(&multipartBody)
+	part, _ := writer.CreateFormFile("upload", "avatar.png")
+	part.Write([]byte("not really a png"))
+	writer.Close()
+
+	cases := []struct {
+		name        string
+		config      BodyConfig
+		method      string
+		contentType string
+		body        io.Reader
+		status      int
+		err         error
+		allow       string
+	}{
+		{"GET", BodyConfig{}, "GET", "", nil, http.StatusMethodNotAllowed, ErrMethodNotAllowed, "POST"},
+		{"PUT", BodyConfig{Methods: []string{"GET", "POST"}}, "PUT", "", nil, http.StatusMethodNotAllowed, ErrMethodNotAllowed, "GET, POST"},
+		{"oversized", BodyConfig{}, "POST", "application/x-www-form-urlencoded", strings.NewReader(oversized), http.StatusRequestEntityTooLarge, ErrBodyTooLarge, ""},
+		{"oversized chunked", BodyConfig{}, "POST", "application/x-www-form-urlencoded", chunkedReader{strings.NewReader(oversized)}, http.StatusRequestEntityTooLarge, ErrBodyTooLarge, ""},
+		{"custom max", BodyConfig{MaxBodyBytes: 8}, "POST", "application/json", strings.NewReader(`{"access_token":"some-token"}`), http.StatusRequestEntityTooLarge, ErrBodyTooLarge, ""},
+		{"multipart", BodyConfig{}, "POST", writer.FormDataContentType(), &multipartBody, http.StatusUnsupportedMediaType, ErrUnsupportedContentType, ""},
+		{"missing content type", BodyConfig{}, "POST", "", strings.NewReader("access_token=some-token"), http.StatusUnsupportedMediaType, ErrUnsupportedContentType, ""},
+		{"custom content types", BodyConfig{ContentTypes: []string{"application/json"}}, "POST", "application/x-www-form-urlencoded", strings.NewReader("access_token=some-token"), http.StatusUnsupportedMediaType, ErrUnsupportedContentType, ""},
+	}
+	for _, c := range cases {
+		failure := func(w http.ResponseWriter, req *http.Request) {
+			ctx := req.Context()
+			assert.Equal(t, c.err, ErrorFromContext(ctx), c.name)
+			w.WriteHeader(StatusCodeFromContext(ctx))
+		}
+
+		// BodyHandler with a rejected request, assert that:
+		// - the failure handler is called with the typed error and status
+		// - 405 responses have an Allow header of the config Methods
+		handler := BodyHandler(c.config, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			t.Errorf("unexpected call to success handler: %s", c.name)
+		}), http.HandlerFunc(failure))
+		req := httptest.NewRequest(c.method, "/token", c.body)
+		if c.contentType != "" {
+			req.Header.Set("Content-Type", c.contentType)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		assert.Equal(t, c.status, w.Code, c.name)
+		assert.Equal(t, c.allow, w.Header().Get("Allow"), c.name)
+	}
+}
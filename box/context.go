@@ -0,0 +1,45 @@
+package box
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dghubble/gologin"
+)
+
+// unexported key type prevents collisions
+type key int
+
+const (
+	userKey key = iota
+)
+
+// WithUser returns a copy of ctx that stores the Box User and its gologin
+// Profile.
+func WithUser(ctx context.Context, user *User) context.Context {
+	ctx = gologin.WithProfile(ctx, newProfile(user))
+	return context.WithValue(ctx, userKey, user)
+}
+
+// UserFromContext returns the Box User from the ctx.
+func UserFromContext(ctx context.Context) (*User, error) {
+	user, ok := ctx.Value(userKey).(*User)
+	if !ok {
+		return nil, fmt.Errorf("box: Context missing Box User")
+	}
+	return user, nil
+}
+
+// newProfile returns the gologin Profile of the Box User.
+func newProfile(user *User) *gologin.Profile {
+	if user == nil {
+		return nil
+	}
+	return &gologin.Profile{
+		Provider: ProviderName,
+		ID:       user.ID,
+		Email:    user.Login,
+		Name:     user.Name,
+		Raw:      gologin.ProfileRaw(user, "id", "login", "name"),
+	}
+}
@@ -0,0 +1,2 @@
+// Package box provides Box OAuth2 login, callback, and refresh handlers.
+package box
@@ -0,0 +1,127 @@
+package box
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/internal"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"golang.org/x/oauth2"
+)
+
+// ProviderName is the provider name set in the ctx (see
+// gologin.ProviderFromContext) and the Profile.
+const ProviderName = "box"
+
+// Box login errors
+var (
+	ErrUnableToGetBoxUser = errors.New("box: unable to get Box User")
+	ErrUserNotActive      = errors.New("box: Box User is not active")
+)
+
+// Endpoint is Box's OAuth2 endpoint.
+var Endpoint = oauth2.Endpoint{
+	AuthURL:   "https://account.box.com/api/oauth2/authorize",
+	TokenURL:  "https://api.box.com/oauth2/token",
+	AuthStyle: oauth2.AuthStyleInParams,
+}
+
+// StateHandler checks for a state cookie. If found, the state value is read
+// and added to the ctx. Otherwise, a non-guessable value is added to the ctx
+// and to a (short-lived) state cookie issued to the requester.
+//
+// Implements OAuth 2 RFC 6749 10.12 CSRF Protection. If you wish to issue
+// state params differently, write a http.Handler which sets the ctx state,
+// using oauth2 WithState(ctx, state) since it is required by LoginHandler
+// and CallbackHandler.
+func StateHandler(config gologin.CookieConfig, success http.Handler) http.Handler {
+	return oauth2Login.StateHandler(config, success)
+}
+
+// LoginHandler handles Box login requests by reading the state value from
+// the ctx and redirecting requests to the AuthURL with that state value.
+// Any AuthCodeOptions are added to the AuthURL.
+func LoginHandler(config *oauth2.Config, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	return oauth2Login.LoginHandler(config, failure, opts...)
+}
+
+// CallbackHandler handles Box redirection URI requests and adds the Box Token
+// and User to the ctx. If authentication succeeds, handling delegates to the
+// success handler, otherwise to the failure handler. Users whose status is not
+// active fail with ErrUserNotActive.
+// Any AuthCodeOptions are sent with the token exchange.
+//
+// Box access tokens expire after about an hour, so the success handler should
+// store the whole Token (see oauth2 TokenFromContext), including its refresh
+// token, for use with RefreshHandler.
+func CallbackHandler(config *oauth2.Config, success, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	success = boxHandler(config, success, failure)
+	return oauth2Login.CallbackHandler(config, success, failure, opts...)
+}
+
+// RefreshHandler loads the stored Box Token for the request from the provider
+// and refreshes it if it has expired, then adds the valid Token to the ctx
+// and calls the success handler (see oauth2 RefreshHandler).
+//
+// Box refresh tokens are single-use. Refreshing returns a new refresh token
+// and invalidates the old one, so the save func must persist the refreshed
+// Token. Refreshing with a used refresh token fails with oauth2
+// ErrRefreshTokenRevoked and the user must login again.
+func RefreshHandler(config *oauth2.Config, provider oauth2Login.TokenSourceProvider, save oauth2Login.TokenSaveFunc, success, failure http.Handler) http.Handler {
+	return oauth2Login.RefreshHandler(config, provider, save, success, failure)
+}
+
+// boxHandler is a http.Handler that gets the OAuth2 Token from the ctx to
+// get the corresponding Box User. If successful, the User is added to the
+// ctx and the success handler is called. Otherwise, the failure handler is
+// called. If Box rejects the request, the failure handler's error wraps the
+// *APIError.
+func boxHandler(config *oauth2.Config, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		httpClient := internal.OAuth2Client(ctx, config, token)
+		user, resp, err := newClient(httpClient).Me(ctx)
+		err = validateResponse(user, resp, err)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		if user.Status != StatusActive {
+			ctx = gologin.WithError(ctx, ErrUserNotActive)
+			ctx = gologin.WithStatusCode(ctx, http.StatusForbidden)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		ctx = WithUser(ctx, user)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// validateResponse returns an error if the given Box User, raw
+// http.Response, or error are unexpected. Returns nil if they are valid, or a
+// *gologin.Error which preserves the cause and status code.
+func validateResponse(user *User, resp *http.Response, err error) error {
+	var status int
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	if err != nil || status != http.StatusOK {
+		return &gologin.Error{Provider: "box", Op: "get user", StatusCode: status, Err: err, Kind: ErrUnableToGetBoxUser}
+	}
+	if user == nil || user.ID == "" {
+		return &gologin.Error{Provider: "box", Op: "get user", StatusCode: status, Kind: ErrUnableToGetBoxUser}
+	}
+	return nil
+}
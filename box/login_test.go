@@ -0,0 +1,234 @@
+package box
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dghubble/gologin"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func testConfig() *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     "client_id",
+		ClientSecret: "client_secret",
+		RedirectURL:  "https://example.com/box/callback",
+		Endpoint:     Endpoint,
+	}
+}
+
+func TestCallbackHandler(t *testing.T) {
+	proxyClient, server := newBoxTestServer(testUserJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithState(ctx, "d4e5f6")
+
+	success := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "any-token", token.AccessToken)
+			assert.Equal(t, "any-refresh", token.RefreshToken)
+			assert.False(t, token.Expiry.IsZero())
+		}
+		user, err := UserFromContext(ctx)
+		if assert.Nil(t, err) {
+			expectedUser := &User{ID: "11446498", Name: "Aaron Levie", Login: "ceo@example.com", Status: "active", Enterprise: &Enterprise{ID: "1910967", Name: "Acme Inc."}}
+			assert.Equal(t, expectedUser, user)
+		}
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// CallbackHandler assert that:
+	// - success handler is called with the whole Token (including the
+	// refresh token and expiry) and User in the ctx
+	callbackHandler := CallbackHandler(testConfig(), http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestBoxHandler_UserNotActive(t *testing.T) {
+	statuses := []string{"inactive", "cannot_delete_edit", "cannot_delete_edit_upload", ""}
+	for _, status := range statuses {
+		proxyClient, server := newBoxTestServer(fmt.Sprintf(`{"type": "user", "id": "11446498", "login": "ceo@example.com", "status": %q}`, status))
+		// oauth2 Client will use the proxy client's base Transport
+		ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+		ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+
+		success := testutils.AssertSuccessNotCalled(t)
+		failure := func(w http.ResponseWriter, req *http.Request) {
+			assert.Equal(t, ErrUserNotActive, gologin.ErrorFromContext(req.Context()), status)
+			fmt.Fprintf(w, "failure handler called")
+		}
+
+		// BoxHandler for a User who is not active, assert that:
+		// - failure handler is called with ErrUserNotActive
+		boxHandler := boxHandler(testConfig(), success, http.HandlerFunc(failure))
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		boxHandler.ServeHTTP(w, req.WithContext(ctx))
+		assert.Equal(t, "failure handler called", w.Body.String())
+		server.Close()
+	}
+}
+
+func TestBoxHandler_APIError(t *testing.T) {
+	proxyClient, server := newBoxTestServer(testUserJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "expired-token"})
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.True(t, errors.Is(err, ErrUnableToGetBoxUser))
+			var apiErr *APIError
+			if assert.True(t, errors.As(err, &apiErr)) {
+				assert.Equal(t, &APIError{Status: 401, Code: "unauthorized", Message: "Unauthorized", RequestID: "abcdef123456"}, apiErr)
+				assert.Equal(t, "box: Unauthorized (code unauthorized)", apiErr.Error())
+			}
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// BoxHandler with a Token Box rejects, assert that:
+	// - failure handler is called
+	// - the error wraps the Box *APIError
+	boxHandler := boxHandler(testConfig(), success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	boxHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestBoxHandler_MissingCtxToken(t *testing.T) {
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "oauth2: Context missing Token", err.Error())
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// BoxHandler called without Token in ctx, assert that:
+	// - failure handler is called
+	// - error about ctx missing token is added to the failure handler ctx
+	boxHandler := boxHandler(testConfig(), success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	boxHandler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestBoxHandler_ErrorGettingUser(t *testing.T) {
+	proxyClient, server := testutils.NewErrorServer("Box Service Down", http.StatusInternalServerError)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.True(t, errors.Is(err, ErrUnableToGetBoxUser))
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// BoxHandler cannot get Box User, assert that:
+	// - failure handler is called
+	// - error cannot get Box User added to the failure handler ctx
+	boxHandler := boxHandler(testConfig(), success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	boxHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestRefreshHandler(t *testing.T) {
+	proxyClient, server := newBoxTestServer(testUserJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+
+	// the stored Token from a previous CallbackHandler, now expired
+	stored := &oauth2.Token{AccessToken: "any-token", RefreshToken: "any-refresh", Expiry: time.Now().Add(-time.Minute)}
+	provider := oauth2Login.TokenSourceProviderFunc(func(req *http.Request) (*oauth2.Token, error) {
+		return stored, nil
+	})
+	save := func(req *http.Request, token *oauth2.Token) error {
+		stored = token
+		return nil
+	}
+	success := func(w http.ResponseWriter, req *http.Request) {
+		token, err := oauth2Login.TokenFromContext(req.Context())
+		if assert.Nil(t, err) {
+			assert.Equal(t, "next-token", token.AccessToken)
+		}
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// RefreshHandler with an expired Token, assert that:
+	// - the Token is refreshed and the new (single-use) refresh token saved
+	// - success handler is called with the refreshed Token in the ctx
+	refreshHandler := RefreshHandler(testConfig(), provider, save, http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	refreshHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+	assert.Equal(t, "next-refresh", stored.RefreshToken)
+}
+
+func TestRefreshHandler_UsedRefreshToken(t *testing.T) {
+	proxyClient, server := newBoxTestServer(testUserJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+
+	// a Token whose refresh token was already used, e.g. the refreshed Token
+	// was not saved
+	provider := oauth2Login.TokenSourceProviderFunc(func(req *http.Request) (*oauth2.Token, error) {
+		return &oauth2.Token{AccessToken: "old-token", RefreshToken: "used-refresh", Expiry: time.Now().Add(-time.Minute)}, nil
+	})
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, oauth2Login.ErrRefreshTokenRevoked, gologin.ErrorFromContext(req.Context()))
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// RefreshHandler with a used refresh token, assert that:
+	// - failure handler is called with ErrRefreshTokenRevoked
+	refreshHandler := RefreshHandler(testConfig(), provider, nil, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	refreshHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestValidateResponse(t *testing.T) {
+	validUser := &User{ID: "11446498"}
+	validResponse := &http.Response{StatusCode: 200}
+	invalidResponse := &http.Response{StatusCode: 500}
+	assert.Equal(t, nil, validateResponse(validUser, validResponse, nil))
+	assert.True(t, errors.Is(validateResponse(validUser, validResponse, fmt.Errorf("Server error")), ErrUnableToGetBoxUser))
+	assert.True(t, errors.Is(validateResponse(validUser, invalidResponse, nil), ErrUnableToGetBoxUser))
+	assert.True(t, errors.Is(validateResponse(&User{}, validResponse, nil), ErrUnableToGetBoxUser))
+	assert.True(t, errors.Is(validateResponse(nil, validResponse, nil), ErrUnableToGetBoxUser))
+}
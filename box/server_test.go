@@ -0,0 +1,53 @@
+package box
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/dghubble/gologin/testutils"
+)
+
+const (
+	// testUserJSON is a users/me response of an active enterprise user.
+	testUserJSON = `{"type": "user", "id": "11446498", "name": "Aaron Levie", "login": "ceo@example.com", "status": "active", "enterprise": {"type": "enterprise", "id": "1910967", "name": "Acme Inc."}}`
+	// testInvalidTokenJSON is a Box error response.
+	testInvalidTokenJSON = `{"type": "error", "status": 401, "code": "unauthorized", "message": "Unauthorized", "request_id": "abcdef123456"}`
+)
+
+// newBoxTestServer returns a new httptest.Server which mocks the Box token
+// and users/me endpoints and a client which proxies requests to the server.
+// Like Box, refresh tokens are single-use: refreshing "any-refresh" returns
+// "next-refresh" and any other refresh token is rejected. The users/me
+// endpoint responds with the given json data, or a Box error for tokens other
+// than "any-token". The caller must close the server.
+func newBoxTestServer(jsonData string) (*http.Client, *httptest.Server) {
+	client, mux, server := testutils.TestServer()
+	mux.HandleFunc("/oauth2/token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.PostFormValue("grant_type") {
+		case "authorization_code":
+			fmt.Fprintf(w, `{"access_token": "any-token", "expires_in": 3600, "restricted_to": [], "refresh_token": "any-refresh", "token_type": "bearer"}`)
+		case "refresh_token":
+			if r.PostFormValue("refresh_token") != "any-refresh" {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprintf(w, `{"error": "invalid_grant", "error_description": "Invalid refresh token"}`)
+				return
+			}
+			fmt.Fprintf(w, `{"access_token": "next-token", "expires_in": 3600, "restricted_to": [], "refresh_token": "next-refresh", "token_type": "bearer"}`)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, `{"error": "unsupported_grant_type"}`)
+		}
+	})
+	mux.HandleFunc("/2.0/users/me", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Header.Get("Authorization") != "Bearer any-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprintf(w, testInvalidTokenJSON)
+			return
+		}
+		fmt.Fprintf(w, jsonData)
+	})
+	return client, server
+}
@@ -0,0 +1,71 @@
+package box
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/dghubble/gologin/internal/jsonclient"
+)
+
+const boxAPI = "https://api.box.com/2.0/"
+
+// Box user statuses
+const (
+	StatusActive = "active"
+)
+
+// User is a Box user.
+type User struct {
+	ID         string      `json:"id"`
+	Name       string      `json:"name"`
+	Login      string      `json:"login"`
+	Status     string      `json:"status"`
+	Enterprise *Enterprise `json:"enterprise"`
+}
+
+// Enterprise is the Box enterprise a user belongs to.
+type Enterprise struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// APIError is a Box API error response.
+// https://developer.box.com/reference/resources/client-error/
+type APIError struct {
+	Status    int    `json:"status"`
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("box: %s (code %s)", e.Message, e.Code)
+}
+
+// client is a Box client for obtaining the current User.
+type client struct {
+	json *jsonclient.Client
+}
+
+// newClient returns a new Box client.
+func newClient(httpClient *http.Client) *client {
+	return &client{
+		json: jsonclient.New(httpClient, boxAPI),
+	}
+}
+
+// Me returns the current Box User. If Box responds with an error, it is
+// returned as an *APIError.
+// https://developer.box.com/reference/get-users-me/
+func (c *client) Me(ctx context.Context) (*User, *http.Response, error) {
+	user := new(User)
+	apiErr := new(APIError)
+	params := url.Values{"fields": {"id,name,login,status,enterprise"}}
+	resp, err := c.json.Get(ctx, "users/me", params, user, apiErr)
+	if err == nil && apiErr.Code != "" {
+		err = apiErr
+	}
+	return user, resp, err
+}
@@ -0,0 +1,105 @@
+package gologin
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Cache stores values for a TTL. Provider handlers may cache Users keyed by
+// a SHA-256 hash of the access token (e.g. facebook Config Cache), so keys
+// never contain raw tokens. Implementations must be safe for concurrent use.
+type Cache interface {
+	// Get returns the value of the key, if present and unexpired.
+	Get(key string) (interface{}, bool)
+	// Set stores the value of the key for the ttl.
+	Set(key string, value interface{}, ttl time.Duration)
+}
+
+// LRUCache is an in-memory Cache which evicts the least recently used entry
+// once it is full.
+type LRUCache struct {
+	// Now returns the current time (e.g. a fake clock in tests). Defaults to
+	// time.Now.
+	Now func() time.Time
+
+	size    int
+	mu      sync.Mutex
+	entries *list.List
+	items   map[string]*list.Element
+}
+
+// lruEntry is a LRUCache value and its expiry.
+type lruEntry struct {
+	key    string
+	value  interface{}
+	expiry time.Time
+}
+
+// NewLRUCache returns a new LRUCache which holds up to size entries.
+// Panics if size is not positive.
+func NewLRUCache(size int) *LRUCache {
+	if size <= 0 {
+		panic("gologin: LRUCache size must be positive")
+	}
+	return &LRUCache{
+		size:    size,
+		entries: list.New(),
+		items:   make(map[string]*list.Element),
+	}
+}
+
+// Get returns the value of the key, if present and unexpired.
+func (c *LRUCache) Get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*lruEntry)
+	if !c.now().Before(entry.expiry) {
+		c.remove(elem)
+		return nil, false
+	}
+	c.entries.MoveToFront(elem)
+	return entry.value, true
+}
+
+// Set stores the value of the key for the ttl, evicting the least recently
+// used entry if the cache is full. Non-positive ttls remove the key.
+func (c *LRUCache) Set(key string, value interface{}, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.items[key]; ok {
+		c.remove(elem)
+	}
+	if ttl <= 0 {
+		return
+	}
+	entry := &lruEntry{key: key, value: value, expiry: c.now().Add(ttl)}
+	c.items[key] = c.entries.PushFront(entry)
+	for c.entries.Len() > c.size {
+		c.remove(c.entries.Back())
+	}
+}
+
+// Len returns the number of entries, including expired entries which have
+// not been evicted yet.
+func (c *LRUCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.entries.Len()
+}
+
+func (c *LRUCache) remove(elem *list.Element) {
+	c.entries.Remove(elem)
+	delete(c.items, elem.Value.(*lruEntry).key)
+}
+
+func (c *LRUCache) now() time.Time {
+	if c.Now != nil {
+		return c.Now()
+	}
+	return time.Now()
+}
@@ -0,0 +1,79 @@
+package gologin
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeClock is a settable clock for LRUCache Now.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func TestLRUCache(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	cache := NewLRUCache(2)
+	cache.Now = clock.Now
+
+	// LRUCache assert that:
+	// - missing keys miss
+	// - set keys hit until their TTL passes
+	_, ok := cache.Get("a")
+	assert.False(t, ok)
+	cache.Set("a", "alice", time.Minute)
+	value, ok := cache.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, "alice", value)
+	clock.now = clock.now.Add(59 * time.Second)
+	_, ok = cache.Get("a")
+	assert.True(t, ok)
+	clock.now = clock.now.Add(time.Second)
+	_, ok = cache.Get("a")
+	assert.False(t, ok)
+	// - expired entries are removed on Get
+	assert.Equal(t, 0, cache.Len())
+
+	// - setting an existing key replaces its value and TTL
+	cache.Set("a", "alice", time.Minute)
+	cache.Set("a", "alan", time.Hour)
+	clock.now = clock.now.Add(2 * time.Minute)
+	value, ok = cache.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, "alan", value)
+
+	// - non-positive TTLs remove the key
+	cache.Set("a", "alice", 0)
+	_, ok = cache.Get("a")
+	assert.False(t, ok)
+}
+
+func TestLRUCache_Eviction(t *testing.T) {
+	cache := NewLRUCache(2)
+	cache.Set("a", 1, time.Minute)
+	cache.Set("b", 2, time.Minute)
+	// use "a" so "b" is least recently used
+	cache.Get("a")
+	cache.Set("c", 3, time.Minute)
+
+	// LRUCache when full, assert that:
+	// - the least recently used entry is evicted
+	assert.Equal(t, 2, cache.Len())
+	_, ok := cache.Get("b")
+	assert.False(t, ok)
+	value, ok := cache.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, value)
+	value, ok = cache.Get("c")
+	assert.True(t, ok)
+	assert.Equal(t, 3, value)
+}
+
+func TestNewLRUCache_InvalidSize(t *testing.T) {
+	assert.Panics(t, func() { NewLRUCache(0) })
+}
@@ -0,0 +1,67 @@
+package gologin
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// HTTPClientHandler sets the http.Client used by the chained login and
+// callback handlers (see WithHTTPClient), unless the ctx already has one.
+// Use it to set a default client with a Timeout:
+//
+//	client := &http.Client{Timeout: 10 * time.Second}
+//	mux.Handle("/callback", gologin.HTTPClientHandler(client, callbackHandler))
+func HTTPClientHandler(client *http.Client, success http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		if _, ok := ctx.Value(oauth2.HTTPClient).(*http.Client); !ok {
+			ctx = WithHTTPClient(ctx, client)
+		}
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// TimeoutHandler sets the timeout of each provider token exchange and user
+// request made by the chained login and callback handlers (see WithTimeout).
+// Requests are also canceled with the request's ctx (e.g. if the client
+// disconnects or the server's request deadline passes):
+//
+//	mux.Handle("/callback", gologin.TimeoutHandler(5*time.Second, callbackHandler))
+func TimeoutHandler(timeout time.Duration, success http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := WithTimeout(req.Context(), timeout)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// NewHandler returns a http.Handler which calls the handler with each
+// request's ctx derived from the base func (e.g. to seed request-scoped
+// loggers, deadlines, or a pre-resolved tenant), or from context.Background
+// if base is nil. The derived ctx is canceled when the request's ctx is (e.g.
+// the client disconnects), which aborts in-flight provider API requests.
+func NewHandler(h http.Handler, base func(*http.Request) context.Context) http.Handler {
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		parent := context.Background()
+		if base != nil {
+			parent = base(req)
+		}
+		ctx, cancel := context.WithCancel(parent)
+		defer cancel()
+		if done := req.Context().Done(); done != nil {
+			go func() {
+				select {
+				case <-done:
+					cancel()
+				case <-ctx.Done():
+				}
+			}()
+		}
+		h.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
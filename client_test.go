@@ -0,0 +1,136 @@
+package gologin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func TestHTTPClientHandler(t *testing.T) {
+	defaultClient := &http.Client{}
+	ctxClient := &http.Client{}
+	cases := []struct {
+		ctx      context.Context
+		expected *http.Client
+	}{
+		{context.Background(), defaultClient},
+		{WithHTTPClient(context.Background(), ctxClient), ctxClient},
+	}
+	for _, c := range cases {
+		success := func(w http.ResponseWriter, req *http.Request) {
+			assert.Equal(t, c.expected, req.Context().Value(oauth2.HTTPClient))
+			fmt.Fprintf(w, "success handler called")
+		}
+		// HTTPClientHandler assert that:
+		// - the default client is added to the ctx unless one is present
+		handler := HTTPClientHandler(defaultClient, http.HandlerFunc(success))
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		handler.ServeHTTP(w, req.WithContext(c.ctx))
+		assert.Equal(t, "success handler called", w.Body.String())
+	}
+}
+
+func TestNewHandler(t *testing.T) {
+	type baseKey struct{}
+	base := func(req *http.Request) context.Context {
+		return context.WithValue(context.Background(), baseKey{}, "tenant-"+req.URL.Query().Get("tenant"))
+	}
+	success := func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "tenant-acme", req.Context().Value(baseKey{}))
+		fmt.Fprintf(w, "success handler called")
+	}
+
+	// NewHandler assert that:
+	// - the request ctx is derived from the base func
+	// - values of the base ctx are readable by the handler
+	handler := NewHandler(http.HandlerFunc(success), base)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?tenant=acme", nil)
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestNewHandler_Cancel(t *testing.T) {
+	reqCtx, cancel := context.WithCancel(context.Background())
+	success := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		assert.Nil(t, ctx.Err())
+		// client disconnects
+		cancel()
+		select {
+		case <-ctx.Done():
+			fmt.Fprintf(w, "success handler called")
+		case <-time.After(time.Second):
+			t.Errorf("expected the derived ctx to be canceled")
+		}
+	}
+
+	// NewHandler with a nil base func, assert that:
+	// - the ctx derived from context.Background is canceled with the
+	// request's ctx
+	handler := NewHandler(http.HandlerFunc(success), nil)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	handler.ServeHTTP(w, req.WithContext(reqCtx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestTimeoutHandler(t *testing.T) {
+	success := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		_, ok := ctx.Deadline()
+		assert.False(t, ok)
+		for _, start := range []func(context.Context, string) (context.Context, func(error)){StartTokenExchange, StartUserFetch} {
+			opCtx, end := start(ctx, "example")
+			deadline, ok := opCtx.Deadline()
+			if assert.True(t, ok) {
+				assert.WithinDuration(t, time.Now().Add(time.Minute), deadline, time.Second)
+			}
+			end(nil)
+			assert.Equal(t, context.Canceled, opCtx.Err())
+		}
+		fmt.Fprintf(w, "success handler called")
+	}
+
+	// TimeoutHandler assert that:
+	// - the request ctx itself has no deadline
+	// - token exchange and user fetch ctxs have the timeout
+	// - operation ctxs are canceled when the operation ends
+	handler := TimeoutHandler(time.Minute, http.HandlerFunc(success))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestTimeoutHandler_Hooks(t *testing.T) {
+	var observed error
+	hooks := &Hooks{
+		OnUserFetch: func(ctx context.Context, provider string, duration time.Duration, err error) {
+			observed = err
+		},
+	}
+	success := func(w http.ResponseWriter, req *http.Request) {
+		opCtx, end := StartUserFetch(req.Context(), "example")
+		<-opCtx.Done()
+		end(opCtx.Err())
+		fmt.Fprintf(w, "success handler called")
+	}
+
+	// TimeoutHandler with Hooks, assert that:
+	// - the user fetch ctx is canceled after the timeout
+	// - the deadline error is reported to Hooks
+	handler := HooksHandler(hooks, TimeoutHandler(10*time.Millisecond, http.HandlerFunc(success)))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "success handler called", w.Body.String())
+	assert.Equal(t, context.DeadlineExceeded, observed)
+}
@@ -0,0 +1,87 @@
+package gologin
+
+import (
+	"context"
+	"crypto/rand"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Clock tells the current time. Handlers read the time from the ctx Clock
+// (see WithClock) to issue and expire states, nonces, and assertions, so
+// tests can control it (e.g. with a gologintest FakeClock).
+type Clock interface {
+	Now() time.Time
+}
+
+// Sleeper is a Clock which also waits, e.g. between retries. Clocks which
+// are not Sleepers wait with timers.
+type Sleeper interface {
+	Clock
+	// Sleep waits for the duration or until the ctx is done.
+	Sleep(ctx context.Context, d time.Duration) error
+}
+
+// SystemClock is the Clock of time.Now.
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// WithClock returns a copy of ctx that stores the Clock.
+func WithClock(ctx context.Context, clock Clock) context.Context {
+	return context.WithValue(ctx, clockKey, clock)
+}
+
+// ClockFromContext returns the Clock from the ctx, or the SystemClock.
+func ClockFromContext(ctx context.Context) Clock {
+	if clock, ok := ctx.Value(clockKey).(Clock); ok && clock != nil {
+		return clock
+	}
+	return SystemClock
+}
+
+// WithEntropy returns a copy of ctx that stores the source of random bytes
+// for states, nonces, PKCE verifiers, and retry jitter.
+func WithEntropy(ctx context.Context, entropy io.Reader) context.Context {
+	return context.WithValue(ctx, entropyKey, entropy)
+}
+
+// EntropyFromContext returns the source of random bytes from the ctx, or the
+// crypto/rand Reader.
+func EntropyFromContext(ctx context.Context) io.Reader {
+	if entropy, ok := ctx.Value(entropyKey).(io.Reader); ok && entropy != nil {
+		return entropy
+	}
+	return rand.Reader
+}
+
+// ClockHandler sets the Clock used by the chained login and callback handlers
+// (see WithClock). Use it in tests to issue and expire states without
+// waiting:
+//
+//	clock := gologintest.NewFakeClock(time.Now())
+//	mux.Handle("/callback", gologin.ClockHandler(clock, callbackHandler))
+func ClockHandler(clock Clock, success http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := WithClock(req.Context(), clock)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// EntropyHandler sets the source of random bytes used by the chained login
+// and callback handlers (see WithEntropy). Use a deterministic source (e.g.
+// a gologintest NewEntropy) only in tests; states and verifiers must be
+// non-guessable in production.
+func EntropyHandler(entropy io.Reader, success http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := WithEntropy(req.Context(), entropy)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
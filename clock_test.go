@@ -0,0 +1,49 @@
+package gologin
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fixedClock is a Clock which always returns the same time.
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time {
+	return time.Time(c)
+}
+
+func TestClockFromContext(t *testing.T) {
+	// defaults to the SystemClock and crypto/rand Reader
+	ctx := context.Background()
+	assert.Equal(t, SystemClock, ClockFromContext(ctx))
+	assert.WithinDuration(t, time.Now(), ClockFromContext(ctx).Now(), time.Second)
+	assert.Equal(t, rand.Reader, EntropyFromContext(ctx))
+
+	clock := fixedClock(time.Unix(1500000000, 0))
+	entropy := bytes.NewReader([]byte("deterministic"))
+	ctx = WithEntropy(WithClock(ctx, clock), entropy)
+	assert.Equal(t, clock, ClockFromContext(ctx))
+	assert.Equal(t, entropy, EntropyFromContext(ctx))
+}
+
+func TestClockHandler(t *testing.T) {
+	clock := fixedClock(time.Unix(1500000000, 0))
+	entropy := bytes.NewReader([]byte("deterministic"))
+	success := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		assert.Equal(t, time.Unix(1500000000, 0), ClockFromContext(ctx).Now())
+		assert.Equal(t, entropy, EntropyFromContext(ctx))
+	}
+
+	// ClockHandler and EntropyHandler, assert that:
+	// - the Clock and entropy are added to the ctx
+	handler := ClockHandler(clock, EntropyHandler(entropy, http.HandlerFunc(success)))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+}
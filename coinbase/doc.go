@@ -0,0 +1,2 @@
+// Package coinbase provides Coinbase OAuth2 login and callback handlers.
+package coinbase
@@ -0,0 +1,127 @@
+package coinbase
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/internal"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"golang.org/x/oauth2"
+)
+
+// ProviderName is the provider name set in the ctx (see
+// gologin.ProviderFromContext) and the Profile.
+const ProviderName = "coinbase"
+
+// Coinbase login errors
+var (
+	ErrUnableToGetCoinbaseUser = errors.New("coinbase: unable to get Coinbase User")
+)
+
+// Endpoint is Coinbase's OAuth2 endpoint.
+var Endpoint = oauth2.Endpoint{
+	AuthURL:   "https://www.coinbase.com/oauth/authorize",
+	TokenURL:  "https://www.coinbase.com/oauth/token",
+	AuthStyle: oauth2.AuthStyleInParams,
+}
+
+// Config configures Coinbase login.
+type Config struct {
+	// APIVersion is the CB-VERSION (a YYYY-MM-DD date) of Coinbase API
+	// requests. Defaults to "2024-01-01".
+	APIVersion string
+}
+
+// StateHandler checks for a state cookie. If found, the state value is read
+// and added to the ctx. Otherwise, a non-guessable value is added to the ctx
+// and to a (short-lived) state cookie issued to the requester.
+//
+// Implements OAuth 2 RFC 6749 10.12 CSRF Protection. If you wish to issue
+// state params differently, write a http.Handler which sets the ctx state,
+// using oauth2 WithState(ctx, state) since it is required by LoginHandler
+// and CallbackHandler.
+func StateHandler(config gologin.CookieConfig, success http.Handler) http.Handler {
+	return oauth2Login.StateHandler(config, success)
+}
+
+// LoginHandler handles Coinbase login requests by reading the state value
+// from the ctx and redirecting requests to the AuthURL with that state value.
+// Any AuthCodeOptions are added to the AuthURL.
+//
+// The config Endpoint should be the coinbase Endpoint and Scopes should
+// include "wallet:user:read" (and "wallet:user:email" for the User Email).
+func LoginHandler(config *oauth2.Config, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	return oauth2Login.LoginHandler(config, failure, opts...)
+}
+
+// CallbackHandler handles Coinbase redirection URI requests and adds the
+// Coinbase access token and User to the ctx. If authentication succeeds,
+// handling delegates to the success handler, otherwise to the failure
+// handler.
+// Any AuthCodeOptions are sent with the token exchange.
+//
+// Coinbase access tokens expire after 2 hours and refresh tokens after 2
+// weeks (or on use), so store the full ctx Token.
+func CallbackHandler(config *oauth2.Config, success, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	return CallbackHandlerWithConfig(config, Config{}, success, failure, opts...)
+}
+
+// CallbackHandlerWithConfig handles Coinbase redirection URI requests like
+// CallbackHandler, but sends the Config APIVersion with API requests.
+func CallbackHandlerWithConfig(config *oauth2.Config, coinbaseConfig Config, success, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	success = coinbaseHandler(config, coinbaseConfig, success, failure)
+	return oauth2Login.CallbackHandler(config, success, failure, opts...)
+}
+
+// coinbaseHandler is a http.Handler that gets the OAuth2 Token from the ctx
+// to get the corresponding Coinbase User. If successful, the User is added
+// to the ctx and the success handler is called. Otherwise, the failure
+// handler is called.
+func coinbaseHandler(config *oauth2.Config, coinbaseConfig Config, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	apiVersion := coinbaseConfig.APIVersion
+	if apiVersion == "" {
+		apiVersion = defaultAPIVersion
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		httpClient := internal.OAuth2Client(ctx, config, token)
+		user, resp, err := newClient(httpClient, apiVersion).CurrentUser(ctx)
+		err = validateResponse(user, resp, err)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		ctx = WithUser(ctx, user)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// validateResponse returns an error if the given Coinbase User, raw
+// http.Response, or error are unexpected. Returns nil if they are valid, or a
+// *gologin.Error which preserves the cause (e.g. an *APIError) and status
+// code.
+func validateResponse(user *User, resp *http.Response, err error) error {
+	var status int
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	if err != nil || status != http.StatusOK {
+		return &gologin.Error{Provider: "coinbase", Op: "get user", StatusCode: status, Err: err, Kind: ErrUnableToGetCoinbaseUser}
+	}
+	if user == nil || user.ID == "" {
+		return &gologin.Error{Provider: "coinbase", Op: "get user", StatusCode: status, Kind: ErrUnableToGetCoinbaseUser}
+	}
+	return nil
+}
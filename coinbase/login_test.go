@@ -0,0 +1,154 @@
+package coinbase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dghubble/gologin"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func testConfig() *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     "client_id",
+		ClientSecret: "client_secret",
+		RedirectURL:  "https://example.com/coinbase/callback",
+		Endpoint:     Endpoint,
+		Scopes:       []string{"wallet:user:read", "wallet:user:email"},
+	}
+}
+
+func TestCallbackHandler(t *testing.T) {
+	proxyClient, server := newCoinbaseTestServer(defaultAPIVersion, testUserJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithState(ctx, "d4e5f6")
+
+	expectedUser := &User{
+		ID:        "9da7a204-544e-5fd1-9a12-61176c5d4cd8",
+		Name:      "User One",
+		Email:     "user1@example.com",
+		AvatarURL: "https://images.coinbase.com/avatar?h=5c7f8a9b&s=128",
+		Country:   Country{Code: "US", Name: "United States"},
+	}
+	success := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "any-token", token.AccessToken)
+			assert.Equal(t, "any-refresh", token.RefreshToken)
+		}
+		user, err := UserFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, expectedUser, user)
+		}
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// CallbackHandler assert that:
+	// - the default CB-VERSION is sent and warnings are ignored
+	// - success handler is called
+	// - Coinbase Token and User are added to the ctx of the success handler
+	callbackHandler := CallbackHandler(testConfig(), http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestCallbackHandlerWithConfig(t *testing.T) {
+	proxyClient, server := newCoinbaseTestServer("2021-08-08", testUserJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithState(ctx, "d4e5f6")
+
+	success := func(w http.ResponseWriter, req *http.Request) {
+		user, err := UserFromContext(req.Context())
+		if assert.Nil(t, err) {
+			assert.Equal(t, "9da7a204-544e-5fd1-9a12-61176c5d4cd8", user.ID)
+		}
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// CallbackHandlerWithConfig with an APIVersion, assert that:
+	// - the Config CB-VERSION is sent
+	// - success handler is called with the User in the ctx
+	callbackHandler := CallbackHandlerWithConfig(testConfig(), Config{APIVersion: "2021-08-08"}, http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestCoinbaseHandler_MissingCtxToken(t *testing.T) {
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "oauth2: Context missing Token", err.Error())
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// CoinbaseHandler called without Token in ctx, assert that:
+	// - failure handler is called
+	// - error about ctx missing token is added to the failure handler ctx
+	coinbaseHandler := coinbaseHandler(testConfig(), Config{}, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	coinbaseHandler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestCoinbaseHandler_ErrorGettingUser(t *testing.T) {
+	proxyClient, server := newCoinbaseTestServer(defaultAPIVersion, testUserJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "invalid-token"})
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.True(t, errors.Is(err, ErrUnableToGetCoinbaseUser))
+			var apiErr *APIError
+			if assert.True(t, errors.As(err, &apiErr)) {
+				assert.Equal(t, &APIError{ID: "invalid_token", Message: "The access token is invalid"}, apiErr)
+			}
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// CoinbaseHandler cannot get Coinbase User, assert that:
+	// - failure handler is called
+	// - error cannot get Coinbase User (and the first APIError) added to the
+	// failure handler ctx
+	coinbaseHandler := coinbaseHandler(testConfig(), Config{}, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	coinbaseHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestValidateResponse(t *testing.T) {
+	validUser := &User{ID: "9da7a204-544e-5fd1-9a12-61176c5d4cd8", Name: "User One"}
+	validResponse := &http.Response{StatusCode: 200}
+	invalidResponse := &http.Response{StatusCode: 500}
+	assert.Equal(t, nil, validateResponse(validUser, validResponse, nil))
+	assert.True(t, errors.Is(validateResponse(validUser, validResponse, fmt.Errorf("Server error")), ErrUnableToGetCoinbaseUser))
+	assert.True(t, errors.Is(validateResponse(validUser, invalidResponse, nil), ErrUnableToGetCoinbaseUser))
+	assert.True(t, errors.Is(validateResponse(nil, validResponse, nil), ErrUnableToGetCoinbaseUser))
+	assert.True(t, errors.Is(validateResponse(&User{Name: "User One"}, validResponse, nil), ErrUnableToGetCoinbaseUser))
+}
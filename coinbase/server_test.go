@@ -0,0 +1,44 @@
+package coinbase
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/dghubble/gologin/testutils"
+)
+
+const (
+	// testUserJSON is a user response with a warning.
+	testUserJSON = `{"data": {"id": "9da7a204-544e-5fd1-9a12-61176c5d4cd8", "name": "User One", "username": "user1", "profile_location": null, "profile_bio": null, "profile_url": "https://coinbase.com/user1", "avatar_url": "https://images.coinbase.com/avatar?h=5c7f8a9b&s=128", "resource": "user", "resource_path": "/v2/user", "email": "user1@example.com", "country": {"code": "US", "name": "United States"}}, "warnings": [{"id": "missing_version", "message": "Please supply API version (YYYY-MM-DD) as CB-VERSION header", "url": "https://developers.coinbase.com/api#versioning"}]}`
+	// testInvalidTokenJSON is a Coinbase error response.
+	testInvalidTokenJSON = `{"errors": [{"id": "invalid_token", "message": "The access token is invalid"}]}`
+)
+
+// newCoinbaseTestServer returns a new httptest.Server which mocks the
+// Coinbase token and user endpoints and a client which proxies requests to
+// the server. The user endpoint responds with the given json data for
+// requests with the CB-VERSION apiVersion, or an invalid_token error for
+// tokens other than "any-token". The caller must close the server.
+func newCoinbaseTestServer(apiVersion, jsonData string) (*http.Client, *httptest.Server) {
+	client, mux, server := testutils.TestServer()
+	mux.HandleFunc("/oauth/token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token": "any-token", "token_type": "bearer", "expires_in": 7200, "refresh_token": "any-refresh", "scope": "wallet:user:read wallet:user:email"}`)
+	})
+	mux.HandleFunc("/v2/user", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		if r.Header.Get("Authorization") != "Bearer any-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprintf(w, testInvalidTokenJSON)
+			return
+		}
+		if r.Header.Get("CB-VERSION") != apiVersion {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, `{"errors": [{"id": "invalid_request", "message": "Invalid API version"}]}`)
+			return
+		}
+		fmt.Fprintf(w, jsonData)
+	})
+	return client, server
+}
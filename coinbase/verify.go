@@ -0,0 +1,76 @@
+package coinbase
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/dghubble/gologin/internal/jsonclient"
+)
+
+const (
+	coinbaseAPI = "https://api.coinbase.com/v2/"
+	// defaultAPIVersion is the default CB-VERSION of Coinbase API requests.
+	defaultAPIVersion = "2024-01-01"
+)
+
+// User is a Coinbase user.
+type User struct {
+	ID        string  `json:"id"`
+	Name      string  `json:"name"`
+	Email     string  `json:"email"`
+	AvatarURL string  `json:"avatar_url"`
+	Country   Country `json:"country"`
+}
+
+// Country is a Coinbase user's country.
+type Country struct {
+	Code string `json:"code"`
+	Name string `json:"name"`
+}
+
+// userResponse is a Coinbase user response, whose warnings are ignored.
+type userResponse struct {
+	Data *User `json:"data"`
+}
+
+// APIError is a Coinbase API error.
+type APIError struct {
+	ID      string `json:"id"`
+	Message string `json:"message"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("coinbase: %s (%s)", e.Message, e.ID)
+}
+
+// errorResponse is a Coinbase API error response.
+type errorResponse struct {
+	Errors []APIError `json:"errors"`
+}
+
+// client is a Coinbase client for obtaining the current User.
+type client struct {
+	json *jsonclient.Client
+}
+
+// newClient returns a new Coinbase client which sends the CB-VERSION
+// apiVersion.
+func newClient(httpClient *http.Client, apiVersion string) *client {
+	return &client{
+		json: jsonclient.New(httpClient, coinbaseAPI).Set("CB-VERSION", apiVersion),
+	}
+}
+
+// CurrentUser returns the current Coinbase User. If Coinbase responds with
+// errors, the first is returned as an *APIError.
+// https://docs.cdp.coinbase.com/coinbase-app/docs/api-users#show-current-user
+func (c *client) CurrentUser(ctx context.Context) (*User, *http.Response, error) {
+	userResp := new(userResponse)
+	errResp := new(errorResponse)
+	resp, err := c.json.Get(ctx, "user", nil, userResp, errResp)
+	if err == nil && len(errResp.Errors) > 0 {
+		err = &errResp.Errors[0]
+	}
+	return userResp.Data, resp, err
+}
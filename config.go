@@ -1,5 +1,10 @@
 package gologin
 
+import (
+	"net/http"
+	"time"
+)
+
 // CookieConfig configures http.Cookie creation.
 type CookieConfig struct {
 	// Name is the desired cookie name.
@@ -21,6 +26,10 @@ type CookieConfig struct {
 	// Secure flag indicating to the browser that the cookie should only be
 	// transmitted over a TLS HTTPS connection. Recommended true in production.
 	Secure bool
+	// SameSite restricts when the browser sends the cookie with cross-site
+	// requests. Lax allows the provider's top-level redirect back to the
+	// callback. Zero value means no 'SameSite' attribute should be set.
+	SameSite http.SameSite
 }
 
 // DefaultCookieConfig configures short-lived temporary http.Cookie creation.
@@ -30,6 +39,7 @@ var DefaultCookieConfig = CookieConfig{
 	MaxAge:   60, // 60 seconds
 	HTTPOnly: true,
 	Secure:   true, // HTTPS only
+	SameSite: http.SameSiteLaxMode,
 }
 
 // DebugOnlyCookieConfig configures creation of short-lived temporary
@@ -41,4 +51,38 @@ var DebugOnlyCookieConfig = CookieConfig{
 	MaxAge:   60, // 60 seconds
 	HTTPOnly: true,
 	Secure:   false, // allows cookies to be send over HTTP
+	SameSite: http.SameSiteLaxMode,
+}
+
+// FormPostCookieConfig configures short-lived temporary http.Cookie creation
+// for providers which POST callbacks (response_mode=form_post), such as Sign
+// in with Apple. Browsers only send cookies with cross-site POSTs if they are
+// SameSite=None and Secure, which requires HTTPS.
+var FormPostCookieConfig = CookieConfig{
+	Name:     "gologin-temporary-cookie",
+	Path:     "/",
+	MaxAge:   60, // 60 seconds
+	HTTPOnly: true,
+	Secure:   true, // required by SameSite=None
+	SameSite: http.SameSiteNoneMode,
+}
+
+// RetryPolicy configures retries of provider user requests (e.g. facebook
+// Config Retry). Idempotent GET requests which fail with a network error or
+// a 5xx response are retried with exponential backoff and jitter, waiting at
+// least any Retry-After delay. 4xx responses are never retried and retries
+// stop once the request ctx is done or its deadline would pass. Jitter is
+// read from the ctx entropy and delays are waited for with the ctx Clock, if
+// it is a Sleeper (see WithClock and WithEntropy).
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts, including the first.
+	// Values below 2 disable retries.
+	MaxAttempts int
+	// BaseDelay is the delay before the first retry, doubled for each later
+	// retry. Defaults to 100ms.
+	BaseDelay time.Duration
+	// MaxDelay caps the delay between attempts. Retry-After delays beyond it
+	// are not waited for; the failed response is returned instead. Defaults
+	// to 2s.
+	MaxDelay time.Duration
 }
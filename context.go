@@ -2,7 +2,13 @@ package gologin
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/dghubble/oauth1"
+	"golang.org/x/oauth2"
 )
 
 // unexported key type prevents collisions
@@ -10,6 +16,13 @@ type key int
 
 const (
 	errorKey key = iota
+	hooksKey
+	providerKey
+	profileKey
+	statusCodeKey
+	timeoutKey
+	clockKey
+	entropyKey
 )
 
 // WithError returns a copy of ctx that stores the given error value.
@@ -26,3 +39,45 @@ func ErrorFromContext(ctx context.Context) error {
 	}
 	return err
 }
+
+// WithStatusCode returns a copy of ctx that stores the HTTP status code a
+// failure handler should respond with for the ctx error.
+func WithStatusCode(ctx context.Context, statusCode int) context.Context {
+	return context.WithValue(ctx, statusCodeKey, statusCode)
+}
+
+// StatusCodeFromContext returns the HTTP status code for the ctx error. The
+// status code set by the failing handler (see WithStatusCode) is returned if
+// present. Otherwise, errors with a StatusCode() method give that code,
+// provider Errors give 502 Bad Gateway, and other errors 400 Bad Request.
+func StatusCodeFromContext(ctx context.Context) int {
+	if statusCode, ok := ctx.Value(statusCodeKey).(int); ok && statusCode != 0 {
+		return statusCode
+	}
+	err, _ := ctx.Value(errorKey).(error)
+	if statusErr, ok := err.(interface{ StatusCode() int }); ok {
+		return statusErr.StatusCode()
+	}
+	var providerErr *Error
+	if errors.As(err, &providerErr) {
+		return http.StatusBadGateway
+	}
+	return http.StatusBadRequest
+}
+
+// WithTimeout returns a copy of ctx that stores a timeout for each provider
+// operation (OAuth2 token exchanges and user requests, see StartTokenExchange
+// and StartUserFetch). Unlike an http.Client Timeout, the operation ctx is
+// canceled, so failure handlers receive an error which wraps
+// context.DeadlineExceeded.
+func WithTimeout(ctx context.Context, timeout time.Duration) context.Context {
+	return context.WithValue(ctx, timeoutKey, timeout)
+}
+
+// WithHTTPClient returns a copy of ctx that stores the http.Client to be used
+// by OAuth1 and OAuth2 handlers for token requests and provider user lookups.
+// Set a client Timeout so slow providers cannot tie up requests indefinitely.
+func WithHTTPClient(ctx context.Context, client *http.Client) context.Context {
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, client)
+	return context.WithValue(ctx, oauth1.HTTPClient, client)
+}
@@ -3,9 +3,12 @@ package gologin
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"testing"
 
+	"github.com/dghubble/oauth1"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
 )
 
 func TestContextError(t *testing.T) {
@@ -21,3 +24,10 @@ func TestErrorFromContext_Error(t *testing.T) {
 		assert.Equal(t, "Context missing error value", err.Error())
 	}
 }
+
+func TestContextHTTPClient(t *testing.T) {
+	expectedClient := &http.Client{}
+	ctx := WithHTTPClient(context.Background(), expectedClient)
+	assert.Equal(t, expectedClient, ctx.Value(oauth2.HTTPClient))
+	assert.Equal(t, expectedClient, ctx.Value(oauth1.HTTPClient))
+}
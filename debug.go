@@ -0,0 +1,151 @@
+package gologin
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"regexp"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// maxDebugBodyBytes limits the bodies DebugTransport logs. Longer bodies are
+// truncated, so JSON bodies are logged as Redacted.
+const maxDebugBodyBytes = 64 << 10
+
+// DebugRecord is a redacted provider request and response.
+type DebugRecord struct {
+	Method string
+	// URL is the request URL with secret query parameters redacted.
+	URL string
+	// StatusCode is the response status code, or 0 if the request failed.
+	StatusCode int
+	Duration   time.Duration
+	// RequestHeader is the request header with secret values redacted.
+	RequestHeader http.Header
+	// RequestBody and ResponseBody are the redacted bodies, if LogBodies.
+	RequestBody  string
+	ResponseBody string
+	// Err is the error of a failed request, if any.
+	Err error
+}
+
+// DebugLogger logs provider requests sent by a DebugTransport.
+type DebugLogger interface {
+	LogRequest(ctx context.Context, record DebugRecord)
+}
+
+// DebugLoggerFunc is an adapter to allow an ordinary function to be used as
+// a DebugLogger.
+type DebugLoggerFunc func(ctx context.Context, record DebugRecord)
+
+// LogRequest calls f(ctx, record).
+func (f DebugLoggerFunc) LogRequest(ctx context.Context, record DebugRecord) {
+	f(ctx, record)
+}
+
+// DebugTransport is an opt-in http.RoundTripper which logs the method, URL,
+// status code, duration, and (if LogBodies) bodies of provider requests
+// (e.g. token exchanges and user lookups) to the Logger. Records are
+// redacted with a Redactor of the RedactPatterns before the Logger sees
+// them, so client secrets, tokens, codes, and Authorization headers are
+// never logged. Use DebugHandler to enable it for login and callback
+// handlers.
+type DebugTransport struct {
+	// Base is the transport which sends requests. Defaults to the
+	// http.DefaultTransport.
+	Base http.RoundTripper
+	// Logger receives the redacted records. Required.
+	Logger DebugLogger
+	// RedactPatterns match additional parameter, JSON field, and header
+	// names to redact (see Redactor).
+	RedactPatterns []*regexp.Regexp
+	// LogBodies logs redacted request and response bodies.
+	LogBodies bool
+}
+
+// RoundTrip sends the request with the Base transport and logs it.
+func (t *DebugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.Logger == nil {
+		return t.base().RoundTrip(req)
+	}
+	redactor := Redactor{Patterns: t.RedactPatterns}
+	record := DebugRecord{
+		Method:        req.Method,
+		URL:           redactor.RedactURL(req.URL.String()),
+		RequestHeader: redactor.RedactHeader(req.Header),
+	}
+	if t.LogBodies && req.Body != nil && req.Body != http.NoBody {
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		// clone rather than modify the caller's request
+		req = req.Clone(req.Context())
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		record.RequestBody = redactor.RedactBody(req.Header.Get("Content-Type"), body)
+	}
+	start := time.Now()
+	resp, err := t.base().RoundTrip(req)
+	record.Duration = time.Since(start)
+	if err != nil {
+		record.Err = err
+		t.Logger.LogRequest(req.Context(), record)
+		return nil, err
+	}
+	record.StatusCode = resp.StatusCode
+	if t.LogBodies {
+		body, err := io.ReadAll(io.LimitReader(resp.Body, maxDebugBodyBytes))
+		if err != nil {
+			resp.Body.Close()
+			record.Err = err
+			t.Logger.LogRequest(req.Context(), record)
+			return nil, err
+		}
+		resp.Body = &replayBody{Reader: io.MultiReader(bytes.NewReader(body), resp.Body), Closer: resp.Body}
+		record.ResponseBody = redactor.RedactBody(resp.Header.Get("Content-Type"), body)
+	}
+	t.Logger.LogRequest(req.Context(), record)
+	return resp, nil
+}
+
+func (t *DebugTransport) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+	return http.DefaultTransport
+}
+
+// replayBody reads the logged part of a response body before the rest.
+type replayBody struct {
+	io.Reader
+	io.Closer
+}
+
+// DebugHandler enables the DebugTransport for the chained login and callback
+// handlers by adding an http.Client which sends requests with it to the ctx
+// (see WithHTTPClient). The transport and Timeout of any ctx http.Client are
+// kept, the transport becoming the DebugTransport Base.
+//
+//	debug := gologin.DebugTransport{Logger: logger, LogBodies: true}
+//	mux.Handle("/callback", gologin.DebugHandler(debug, callbackHandler))
+func DebugHandler(debug DebugTransport, success http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		client := &http.Client{}
+		if ctxClient, ok := ctx.Value(oauth2.HTTPClient).(*http.Client); ok && ctxClient != nil {
+			*client = *ctxClient
+		}
+		transport := debug
+		if client.Transport != nil {
+			transport.Base = client.Transport
+		}
+		client.Transport = &transport
+		ctx = WithHTTPClient(ctx, client)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
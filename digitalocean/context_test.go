@@ -0,0 +1,24 @@
+package digitalocean
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContextUser(t *testing.T) {
+	expectedUser := &User{UUID: "b6fr89dbf6d9156cace5f3c78dc9851d957381ef", Email: "sammy@digitalocean.com"}
+	ctx := WithUser(context.Background(), expectedUser)
+	user, err := UserFromContext(ctx)
+	assert.Equal(t, expectedUser, user)
+	assert.Nil(t, err)
+}
+
+func TestContextUser_Error(t *testing.T) {
+	user, err := UserFromContext(context.Background())
+	assert.Nil(t, user)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "digitalocean: Context missing DigitalOcean User", err.Error())
+	}
+}
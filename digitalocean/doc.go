@@ -0,0 +1,3 @@
+// Package digitalocean provides DigitalOcean OAuth2 login and callback
+// handlers.
+package digitalocean
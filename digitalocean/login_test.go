@@ -0,0 +1,181 @@
+package digitalocean
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dghubble/gologin"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+const testUnauthorizedJSON = `{"id": "unauthorized", "message": "Unable to authenticate you."}`
+
+func testConfig() *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     "client_id",
+		ClientSecret: "client_secret",
+		RedirectURL:  "https://example.com/digitalocean/callback",
+		Endpoint:     Endpoint,
+		Scopes:       []string{"read"},
+	}
+}
+
+func TestCallbackHandler(t *testing.T) {
+	cases := []struct {
+		name        string
+		tokenJSON   string
+		status      int
+		accountJSON string
+		user        *User
+	}{
+		{"account", testTokenJSON, http.StatusOK, testAccountJSON, &User{
+			UUID:          "b6fr89dbf6d9156cace5f3c78dc9851d957381ef",
+			Name:          "Sammy the Shark",
+			Email:         "sammy@digitalocean.com",
+			EmailVerified: true,
+			Status:        StatusActive,
+			Team:          &Team{UUID: "5df3e3004a17e242b7c20ca6c9fc25b701a47ece", Name: "My Team"},
+		}},
+		{"token info fallback", testTokenJSON, http.StatusUnauthorized, testUnauthorizedJSON, &User{
+			UUID:  "b6fr89dbf6d9156cace5f3c78dc9851d957381ef",
+			Name:  "Sammy the Shark",
+			Email: "sammy@digitalocean.com",
+		}},
+	}
+	for _, c := range cases {
+		proxyClient, server := newDigitalOceanTestServer(c.tokenJSON, c.status, c.accountJSON)
+		// oauth2 Client will use the proxy client's base Transport
+		ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+		ctx = oauth2Login.WithState(ctx, "d4e5f6")
+
+		success := func(w http.ResponseWriter, req *http.Request) {
+			ctx := req.Context()
+			token, err := oauth2Login.TokenFromContext(ctx)
+			if assert.Nil(t, err, c.name) {
+				assert.Equal(t, "any-token", token.AccessToken, c.name)
+			}
+			user, err := UserFromContext(ctx)
+			if assert.Nil(t, err, c.name) {
+				assert.Equal(t, c.user, user, c.name)
+			}
+			fmt.Fprintf(w, "success handler called")
+		}
+		failure := testutils.AssertFailureNotCalled(t)
+
+		// CallbackHandler assert that:
+		// - the User is read from the account, or the token info if the
+		// account request fails
+		// - success handler is called
+		// - DigitalOcean Token and User are added to the ctx of the success
+		// handler
+		callbackHandler := CallbackHandler(testConfig(), http.HandlerFunc(success), failure)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+		callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+		assert.Equal(t, "success handler called", w.Body.String(), c.name)
+		server.Close()
+	}
+}
+
+func TestCallbackHandler_AccountLocked(t *testing.T) {
+	proxyClient, server := newDigitalOceanTestServer(testTokenJSON, http.StatusOK, testLockedAccountJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithState(ctx, "d4e5f6")
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, ErrAccountLocked, gologin.ErrorFromContext(req.Context()))
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// CallbackHandler for a locked account, assert that:
+	// - failure handler is called with ErrAccountLocked
+	callbackHandler := CallbackHandler(testConfig(), success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestDigitalOceanHandler_MissingCtxToken(t *testing.T) {
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "oauth2: Context missing Token", err.Error())
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// DigitalOceanHandler called without Token in ctx, assert that:
+	// - failure handler is called
+	// - error about ctx missing token is added to the failure handler ctx
+	digitalOceanHandler := digitalOceanHandler(testConfig(), success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	digitalOceanHandler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestDigitalOceanHandler_ErrorGettingUser(t *testing.T) {
+	proxyClient, server := newDigitalOceanTestServer(testNoInfoTokenJSON, http.StatusUnauthorized, testUnauthorizedJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.True(t, errors.Is(err, ErrUnableToGetDigitalOceanUser))
+			var apiErr *APIError
+			if assert.True(t, errors.As(err, &apiErr)) {
+				assert.Equal(t, "unauthorized", apiErr.ID)
+			}
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// DigitalOceanHandler cannot get the account and the Token has no info,
+	// assert that:
+	// - failure handler is called
+	// - error cannot get DigitalOcean User added to the failure handler ctx
+	digitalOceanHandler := digitalOceanHandler(testConfig(), success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	digitalOceanHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestUserFromToken(t *testing.T) {
+	token := (&oauth2.Token{AccessToken: "any-token"}).WithExtra(map[string]interface{}{
+		"info": map[string]interface{}{"name": "Sammy the Shark", "email": "sammy@digitalocean.com", "uuid": "b6fr89dbf6d9156cace5f3c78dc9851d957381ef"},
+	})
+	assert.Equal(t, &User{UUID: "b6fr89dbf6d9156cace5f3c78dc9851d957381ef", Name: "Sammy the Shark", Email: "sammy@digitalocean.com"}, userFromToken(token))
+	assert.Nil(t, userFromToken(&oauth2.Token{AccessToken: "any-token"}))
+	noUUID := (&oauth2.Token{AccessToken: "any-token"}).WithExtra(map[string]interface{}{
+		"info": map[string]interface{}{"name": "Sammy the Shark"},
+	})
+	assert.Nil(t, userFromToken(noUUID))
+}
+
+func TestValidateResponse(t *testing.T) {
+	validUser := &User{UUID: "b6fr89dbf6d9156cace5f3c78dc9851d957381ef", Email: "sammy@digitalocean.com"}
+	validResponse := &http.Response{StatusCode: 200}
+	invalidResponse := &http.Response{StatusCode: 500}
+	assert.Equal(t, nil, validateResponse(validUser, validResponse, nil))
+	assert.True(t, errors.Is(validateResponse(validUser, validResponse, fmt.Errorf("Server error")), ErrUnableToGetDigitalOceanUser))
+	assert.True(t, errors.Is(validateResponse(validUser, invalidResponse, nil), ErrUnableToGetDigitalOceanUser))
+	assert.True(t, errors.Is(validateResponse(&User{Email: "sammy@digitalocean.com"}, validResponse, nil), ErrUnableToGetDigitalOceanUser))
+	assert.True(t, errors.Is(validateResponse(nil, validResponse, nil), ErrUnableToGetDigitalOceanUser))
+}
@@ -0,0 +1,39 @@
+package digitalocean
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/dghubble/gologin/testutils"
+)
+
+const (
+	// testTokenJSON is a token response with the account info.
+	testTokenJSON = `{"access_token": "any-token", "token_type": "bearer", "expires_in": 2592000, "refresh_token": "any-refresh", "scope": "read", "info": {"name": "Sammy the Shark", "email": "sammy@digitalocean.com", "uuid": "b6fr89dbf6d9156cace5f3c78dc9851d957381ef"}}`
+	// testNoInfoTokenJSON is a token response without the account info.
+	testNoInfoTokenJSON = `{"access_token": "any-token", "token_type": "bearer", "expires_in": 2592000, "refresh_token": "any-refresh", "scope": "read"}`
+	// testAccountJSON is a v2/account response of a team member.
+	testAccountJSON = `{"account": {"droplet_limit": 25, "floating_ip_limit": 5, "email": "sammy@digitalocean.com", "name": "Sammy the Shark", "uuid": "b6fr89dbf6d9156cace5f3c78dc9851d957381ef", "email_verified": true, "status": "active", "status_message": "", "team": {"uuid": "5df3e3004a17e242b7c20ca6c9fc25b701a47ece", "name": "My Team"}}}`
+	// testLockedAccountJSON is a v2/account response of a locked account.
+	testLockedAccountJSON = `{"account": {"email": "sammy@digitalocean.com", "name": "Sammy the Shark", "uuid": "b6fr89dbf6d9156cace5f3c78dc9851d957381ef", "email_verified": true, "status": "locked", "status_message": "Account locked for billing"}}`
+)
+
+// newDigitalOceanTestServer returns a new httptest.Server which mocks the
+// DigitalOcean token and v2/account endpoints and a client which proxies
+// requests to the server. The token endpoint responds with the given token
+// json. The account endpoint responds with the given status and account
+// json. The caller must close the server.
+func newDigitalOceanTestServer(tokenJSON string, status int, accountJSON string) (*http.Client, *httptest.Server) {
+	client, mux, server := testutils.TestServer()
+	mux.HandleFunc("/v1/oauth/token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, tokenJSON)
+	})
+	mux.HandleFunc("/v2/account", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		fmt.Fprintf(w, accountJSON)
+	})
+	return client, server
+}
@@ -0,0 +1,97 @@
+package digitalocean
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/dghubble/gologin/internal/jsonclient"
+	"golang.org/x/oauth2"
+)
+
+const digitalOceanAPI = "https://api.digitalocean.com/"
+
+// DigitalOcean account statuses
+const (
+	StatusActive  = "active"
+	StatusWarning = "warning"
+	StatusLocked  = "locked"
+)
+
+// User is a DigitalOcean account.
+type User struct {
+	UUID          string `json:"uuid"`
+	Name          string `json:"name"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Status        string `json:"status"`
+	StatusMessage string `json:"status_message"`
+	// Team is the current team, if the account is a team member
+	Team *Team `json:"team"`
+}
+
+// Team is a DigitalOcean team.
+type Team struct {
+	UUID string `json:"uuid"`
+	Name string `json:"name"`
+}
+
+// accountResponse is a DigitalOcean v2/account response.
+type accountResponse struct {
+	Account *User `json:"account"`
+}
+
+// APIError is a DigitalOcean API error response.
+type APIError struct {
+	ID      string `json:"id"`
+	Message string `json:"message"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("digitalocean: %s (%s)", e.Message, e.ID)
+}
+
+// userFromToken returns the User from the Token response info, or nil if
+// the Token has no info. Token info does not include the account status.
+func userFromToken(token *oauth2.Token) *User {
+	info, ok := token.Extra("info").(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	field := func(key string) string {
+		value, _ := info[key].(string)
+		return value
+	}
+	if field("uuid") == "" {
+		return nil
+	}
+	return &User{
+		UUID:  field("uuid"),
+		Name:  field("name"),
+		Email: field("email"),
+	}
+}
+
+// client is a DigitalOcean client for obtaining a User.
+type client struct {
+	json *jsonclient.Client
+}
+
+// newClient returns a new DigitalOcean client.
+func newClient(httpClient *http.Client) *client {
+	return &client{
+		json: jsonclient.New(httpClient, digitalOceanAPI),
+	}
+}
+
+// Account gets the current DigitalOcean User.
+// https://docs.digitalocean.com/reference/api/api-reference/#operation/account_get
+func (c *client) Account(ctx context.Context) (*User, *http.Response, error) {
+	account := new(accountResponse)
+	apiErr := new(APIError)
+	resp, err := c.json.Get(ctx, "v2/account", nil, account, apiErr)
+	if err == nil && apiErr.Message != "" {
+		err = apiErr
+	}
+	return account.Account, resp, err
+}
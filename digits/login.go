@@ -3,17 +3,26 @@ package digits
 import (
 	"fmt"
 	"net/http"
+	"net/url"
 	"regexp"
 	"strings"
 
 	"github.com/dghubble/go-digits/digits"
 	"github.com/dghubble/gologin"
-	"github.com/dghubble/sling"
+	"github.com/dghubble/gologin/internal"
+	"github.com/dghubble/gologin/internal/jsonclient"
 )
 
+// ProviderName is the provider name set in the ctx (see
+// gologin.ProviderFromContext).
+const ProviderName = "digits"
+
 const (
 	accountEndpointField      = "accountEndpoint"
 	accountRequestHeaderField = "accountRequestHeader"
+	// OAuth Echo headers, used if the fields are not POST'ed
+	serviceProviderHeader   = "X-Auth-Service-Provider"
+	verifyCredentialsHeader = "X-Verify-Credentials-Authorization"
 )
 
 // Digits login errors
@@ -30,6 +39,15 @@ var (
 type Config struct {
 	// Digits Consumer Key required to verify the OAuth Echo response.
 	ConsumerKey string
+	// VerifyConsumerKey reports whether the OAuth Echo header's consumer key
+	// is accepted. If nil, the consumer key must equal ConsumerKey.
+	VerifyConsumerKey func(consumerKey string) bool
+	// AllowedHosts are the OAuth Echo service provider hosts (e.g.
+	// "api.digits.com" or "verify.internal.example.com:8443") whose https
+	// endpoints may be called to get the Account. If empty, only the Digits
+	// API host is allowed. Endpoints are posted by clients, so allowing any
+	// host would let them make the server request arbitrary URLs.
+	AllowedHosts []string
 	// Client to use to make the Accounts Endpoint request. If nil, then
 	// http.DefaultClient is used.
 	Client *http.Client
@@ -39,6 +57,13 @@ type Config struct {
 // validates the echo, and calls the endpoint to get the corresponding Digits
 // Account. If successful, the Digits Account is added to the ctx and the
 // success handler is called. Otherwise, the failure handler is called.
+//
+// The endpoint and header are read from the accountEndpoint and
+// accountRequestHeader POST fields or, if absent, the X-Auth-Service-Provider
+// and X-Verify-Credentials-Authorization headers. Endpoints of hosts which are
+// not in the Config AllowedHosts fail with ErrInvalidDigitsEndpoint and
+// rejected consumer keys fail with ErrInvalidConsumerKey. Non-POST requests
+// fail with a 405 and bodies are limited by gologin.BodyHandler.
 func LoginHandler(config *Config, success, failure http.Handler) http.Handler {
 	success = getAccountViaEcho(config, success, failure)
 	if failure == nil {
@@ -46,16 +71,17 @@ func LoginHandler(config *Config, success, failure http.Handler) http.Handler {
 	}
 	fn := func(w http.ResponseWriter, req *http.Request) {
 		ctx := req.Context()
-		if req.Method != "POST" {
-			ctx = gologin.WithError(ctx, fmt.Errorf("Method not allowed"))
-			failure.ServeHTTP(w, req.WithContext(ctx))
-			return
-		}
 		req.ParseForm()
 		accountEndpoint := req.PostForm.Get(accountEndpointField)
+		if accountEndpoint == "" {
+			accountEndpoint = req.Header.Get(serviceProviderHeader)
+		}
 		accountRequestHeader := req.PostForm.Get(accountRequestHeaderField)
+		if accountRequestHeader == "" {
+			accountRequestHeader = req.Header.Get(verifyCredentialsHeader)
+		}
 		// validate POST'ed Digits OAuth Echo data
-		err := validateEcho(accountEndpoint, accountRequestHeader, config.ConsumerKey)
+		err := validateEcho(config, accountEndpoint, accountRequestHeader)
 		if err != nil {
 			ctx = gologin.WithError(ctx, err)
 			failure.ServeHTTP(w, req.WithContext(ctx))
@@ -64,7 +90,7 @@ func LoginHandler(config *Config, success, failure http.Handler) http.Handler {
 		ctx = WithEcho(ctx, accountEndpoint, accountRequestHeader)
 		success.ServeHTTP(w, req.WithContext(ctx))
 	}
-	return http.HandlerFunc(fn)
+	return gologin.BodyHandler(gologin.BodyConfig{}, http.HandlerFunc(fn), failure)
 }
 
 // getAccountViaEcho is a http.Handler that gets the Digits Echo endpoint and
@@ -97,33 +123,61 @@ func getAccountViaEcho(config *Config, success, failure http.Handler) http.Handl
 			return
 		}
 		ctx = WithAccount(ctx, account)
+		ctx = internal.WithDefaultProvider(ctx, ProviderName)
 		success.ServeHTTP(w, req.WithContext(ctx))
 	}
 	return http.HandlerFunc(fn)
 }
 
 // validateEcho checks that the Digits OAuth Echo arguments are valid. If the
-// endpoint does not match the Digits API or the header does not include the
-// correct consumer key, a non-nil error is returned.
-func validateEcho(accountEndpoint, accountRequestHeader, consumerKey string) error {
+// endpoint is not an https URL of an allowed host or the header does not
+// include an accepted consumer key, a non-nil error is returned.
+func validateEcho(config *Config, accountEndpoint, accountRequestHeader string) error {
 	if accountEndpoint == "" {
 		return ErrMissingAccountEndpoint
 	}
 	if accountRequestHeader == "" {
 		return ErrMissingAccountRequestHeader
 	}
-	// check accountEndpoint matches expected protocol/domain
-	if !strings.HasPrefix(accountEndpoint, digits.DigitsAPI) {
+	// check accountEndpoint matches expected protocol/host
+	if !config.allowedEndpoint(accountEndpoint) {
 		return ErrInvalidDigitsEndpoint
 	}
 	// validate the OAuth Echo data's auth header consumer key
 	matches := consumerKeyRegexp.FindStringSubmatch(accountRequestHeader)
-	if len(matches) != 2 || matches[1] != consumerKey {
+	if len(matches) != 2 || matches[1] == "" || !config.verifyConsumerKey(matches[1]) {
 		return ErrInvalidConsumerKey
 	}
 	return nil
 }
 
+// allowedEndpoint returns true if the endpoint is an https URL of one of the
+// AllowedHosts (or the Digits API host by default).
+func (c *Config) allowedEndpoint(endpoint string) bool {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Scheme != "https" || u.User != nil {
+		return false
+	}
+	hosts := c.AllowedHosts
+	if len(hosts) == 0 {
+		hosts = []string{strings.TrimPrefix(digits.DigitsAPI, "https://")}
+	}
+	for _, host := range hosts {
+		if strings.EqualFold(u.Host, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// verifyConsumerKey returns true if the OAuth Echo consumer key is accepted.
+func (c *Config) verifyConsumerKey(consumerKey string) bool {
+	if c.VerifyConsumerKey != nil {
+		return c.VerifyConsumerKey(consumerKey)
+	}
+	return consumerKey == c.ConsumerKey
+}
+
 // requestAccount makes a request to the Digits account endpoint using the
 // provided Authorization header.
 func requestAccount(client *http.Client, accountEndpoint, authorizationHeader string) (*digits.Account, *http.Response, error) {
@@ -133,19 +187,24 @@ func requestAccount(client *http.Client, accountEndpoint, authorizationHeader st
 	}
 	request.Header.Set("Authorization", authorizationHeader)
 	account := new(digits.Account)
-	resp, err := sling.New().Client(client).Do(request, account, nil)
+	resp, err := jsonclient.New(client, "").Do(request, account, nil)
 	return account, resp, err
 }
 
 // validateResponse returns an error if the given Digits Account, raw
-// http.Response, or error are unexpected. Returns nil if they are valid.
+// http.Response, or error are unexpected. Returns nil if they are valid, or a
+// *gologin.Error which preserves the cause and status code.
 func validateResponse(account *digits.Account, resp *http.Response, err error) error {
-	if err != nil || resp.StatusCode != http.StatusOK || account == nil {
-		return ErrUnableToGetDigitsAccount
+	var status int
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	if err != nil || status != http.StatusOK {
+		return &gologin.Error{Provider: "digits", Op: "get account", StatusCode: status, Err: err, Kind: ErrUnableToGetDigitsAccount}
 	}
-	if token := account.AccessToken; token.Token == "" || token.Secret == "" {
+	if account == nil || account.AccessToken.Token == "" || account.AccessToken.Secret == "" {
 		// JSON deserialized Digits account is missing fields
-		return ErrUnableToGetDigitsAccount
+		return &gologin.Error{Provider: "digits", Op: "get account", StatusCode: status, Kind: ErrUnableToGetDigitsAccount}
 	}
 	return nil
 }
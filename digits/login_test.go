@@ -2,12 +2,16 @@ package digits
 
 import (
 	"errors"
+	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
 
 	"github.com/dghubble/go-digits/digits"
+	"github.com/dghubble/gologin"
 	"github.com/dghubble/gologin/testutils"
 	"github.com/stretchr/testify/assert"
 )
@@ -21,15 +25,17 @@ const (
 	testDigitsSecret         = "some-secret"
 )
 
+var testConfig = &Config{ConsumerKey: testConsumerKey}
+
 func TestValidateEcho_missingAccountEndpoint(t *testing.T) {
-	err := validateEcho("", testAccountRequestHeader, testConsumerKey)
+	err := validateEcho(testConfig, "", testAccountRequestHeader)
 	if assert.Error(t, err) {
 		assert.Equal(t, ErrMissingAccountEndpoint, err)
 	}
 }
 
 func TestValidateEcho_missingAccountRequestHeader(t *testing.T) {
-	err := validateEcho(testAccountEndpoint, "", testConsumerKey)
+	err := validateEcho(testConfig, testAccountEndpoint, "")
 	if assert.Error(t, err) {
 		assert.Equal(t, ErrMissingAccountRequestHeader, err)
 	}
@@ -44,11 +50,14 @@ func TestValidateEcho_digitsEndpoint(t *testing.T) {
 		{"http://api.digits.com/1.1/sdk/account.json", false},
 		{"https://digits.com/1.1/sdk/account.json", false},
 		{"https://evil.com/1.1/sdk/account.json", false},
+		{"https://api.digits.com.evil.com/1.1/sdk/account.json", false},
+		{"https://api.digits.com@evil.com/1.1/sdk/account.json", false},
+		{"https://API.digits.com/1.1/sdk/account.json", true},
 		// respect the path defined in Digits javascript sdk
 		{"https://api.digits.com/2.0/future/so/cool.json", true},
 	}
 	for _, c := range cases {
-		err := validateEcho(c.endpoint, testAccountRequestHeader, testConsumerKey)
+		err := validateEcho(testConfig, c.endpoint, testAccountRequestHeader)
 		if c.valid {
 			assert.Nil(t, err)
 		} else {
@@ -76,7 +85,7 @@ func TestValidateEcho_headerConsumerKey(t *testing.T) {
 		{"OAuth", false},
 	}
 	for _, c := range cases {
-		err := validateEcho(testAccountEndpoint, c.header, testConsumerKey)
+		err := validateEcho(testConfig, testAccountEndpoint, c.header)
 		if c.valid {
 			assert.Nil(t, err)
 		} else {
@@ -87,6 +96,46 @@ func TestValidateEcho_headerConsumerKey(t *testing.T) {
 	}
 }
 
+func TestValidateEcho_allowedHosts(t *testing.T) {
+	config := &Config{
+		ConsumerKey:  testConsumerKey,
+		AllowedHosts: []string{"verify.example.com", "echo.example.com:8443"},
+	}
+	cases := []struct {
+		endpoint string
+		valid    bool
+	}{
+		{"https://verify.example.com/account", true},
+		{"https://echo.example.com:8443/1.1/account.json", true},
+		{"https://echo.example.com/1.1/account.json", false},
+		{"http://verify.example.com/account", false},
+		// Digits API is only allowed by default
+		{testAccountEndpoint, false},
+		{"https://169.254.169.254/latest/meta-data", false},
+		{"verify.example.com/account", false},
+	}
+	for _, c := range cases {
+		err := validateEcho(config, c.endpoint, testAccountRequestHeader)
+		if c.valid {
+			assert.Nil(t, err, c.endpoint)
+		} else {
+			assert.Equal(t, ErrInvalidDigitsEndpoint, err, c.endpoint)
+		}
+	}
+}
+
+func TestValidateEcho_verifyConsumerKey(t *testing.T) {
+	config := &Config{
+		VerifyConsumerKey: func(consumerKey string) bool {
+			return consumerKey == "app-one" || consumerKey == "app-two"
+		},
+	}
+	assert.Nil(t, validateEcho(config, testAccountEndpoint, `OAuth oauth_consumer_key="app-one"`))
+	assert.Nil(t, validateEcho(config, testAccountEndpoint, `OAuth oauth_consumer_key="app-two"`))
+	assert.Equal(t, ErrInvalidConsumerKey, validateEcho(config, testAccountEndpoint, `OAuth oauth_consumer_key="mykey"`))
+	assert.Equal(t, ErrInvalidConsumerKey, validateEcho(config, testAccountEndpoint, `OAuth oauth_consumer_key=""`))
+}
+
 func TestValidateResponse(t *testing.T) {
 	emptyAccount := new(digits.Account)
 	validAccount := &digits.Account{
@@ -114,12 +163,15 @@ func TestValidateResponse(t *testing.T) {
 		// Network error or JSON unmarshalling error
 		validateResponse(validAccount, successResp, respErr),
 		validateResponse(validAccount, badResp, respErr),
+		validateResponse(nil, nil, respErr),
 	}
 	for _, err := range errorCases {
-		if err != ErrUnableToGetDigitsAccount {
+		if !errors.Is(err, ErrUnableToGetDigitsAccount) {
 			t.Errorf("expected %v, got %v", ErrUnableToGetDigitsAccount, err)
 		}
 	}
+	// the cause is preserved
+	assert.Equal(t, respErr, errors.Unwrap(validateResponse(nil, nil, respErr)))
 }
 
 func TestWebHandler(t *testing.T) {
@@ -137,6 +189,7 @@ func TestWebHandler(t *testing.T) {
 		assert.Equal(t, testDigitsToken, account.AccessToken.Token)
 		assert.Equal(t, testDigitsSecret, account.AccessToken.Secret)
 		assert.Equal(t, "0123456789", account.PhoneNumber)
+		assert.Equal(t, ProviderName, gologin.ProviderFromContext(ctx, ""))
 
 		endpoint, header, err := EchoFromContext(ctx)
 		assert.Nil(t, err)
@@ -165,7 +218,7 @@ func TestWebHandler_ErrorGettingRequestToken(t *testing.T) {
 	ts := httptest.NewServer(handler)
 	// assert that error occurs indicating the Digits Account cound not be confirmed
 	resp, _ := http.PostForm(ts.URL, url.Values{accountEndpointField: {testAccountEndpoint}, accountRequestHeaderField: {testAccountRequestHeader}})
-	testutils.AssertBodyString(t, resp.Body, ErrUnableToGetDigitsAccount.Error()+"\n")
+	testutils.AssertBodyString(t, resp.Body, ErrUnableToGetDigitsAccount.Error()+" (status 500)\n")
 }
 
 func TestWebHandler_NonPost(t *testing.T) {
@@ -176,8 +229,8 @@ func TestWebHandler_NonPost(t *testing.T) {
 	assert.Nil(t, err)
 	// assert that default (nil) failure handler returns a 405 Method Not Allowed
 	if assert.NotNil(t, resp) {
-		// TODO: change to 405
-		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+		assert.Equal(t, "POST", resp.Header.Get("Allow"))
 	}
 }
 
@@ -204,7 +257,50 @@ func TestWebHandler_InvalidFields(t *testing.T) {
 	// valid, but incorrect Digits account endpoint
 	resp, err = http.PostForm(ts.URL, url.Values{accountEndpointField: {"https://api.digits.com/1.1/wrong.json"}, accountRequestHeaderField: {testAccountRequestHeader}})
 	assert.Nil(t, err)
-	testutils.AssertBodyString(t, resp.Body, ErrUnableToGetDigitsAccount.Error()+"\n")
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.True(t, strings.HasPrefix(string(body), ErrUnableToGetDigitsAccount.Error()), string(body))
+}
+
+func TestWebHandler_EchoHeaders(t *testing.T) {
+	client, mux, server := testutils.TestServer()
+	defer server.Close()
+	mux.HandleFunc("/account", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "verify.example.com", r.Host)
+		assert.Equal(t, testAccountRequestHeader, r.Header.Get("Authorization"))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token": {"token": "some-token", "secret": "some-secret"}, "id_str": "704478921", "phone_number": "+15555550123", "email_address": {"address": "alice@example.com", "is_verified": true}}`)
+	})
+
+	config := &Config{
+		ConsumerKey:  testConsumerKey,
+		Client:       client,
+		AllowedHosts: []string{"verify.example.com"},
+	}
+	success := func(w http.ResponseWriter, req *http.Request) {
+		account, err := AccountFromContext(req.Context())
+		if assert.Nil(t, err) {
+			assert.Equal(t, testDigitsToken, account.AccessToken.Token)
+			assert.Equal(t, testDigitsSecret, account.AccessToken.Secret)
+			assert.Equal(t, "704478921", account.IDStr)
+			assert.Equal(t, "+15555550123", account.PhoneNumber)
+			assert.Equal(t, "alice@example.com", account.Email.Address)
+			assert.True(t, account.Email.IsVerified)
+		}
+		fmt.Fprintf(w, "success handler called")
+	}
+
+	// LoginHandler with OAuth Echo headers and an allowed host, assert that:
+	// - the X-Auth-Service-Provider endpoint is called with the
+	// X-Verify-Credentials-Authorization header
+	// - the full Account is added to the ctx
+	handler := LoginHandler(config, http.HandlerFunc(success), testutils.AssertFailureNotCalled(t))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/", nil)
+	req.Header.Set("X-Auth-Service-Provider", "https://verify.example.com/account")
+	req.Header.Set("X-Verify-Credentials-Authorization", testAccountRequestHeader)
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "success handler called", w.Body.String())
 }
 
 func checkSuccess(t *testing.T) http.Handler {
@@ -24,7 +24,9 @@ var (
 // TokenHandler receives a Digits access token/secret and calls the Digits
 // accounts endpoint to get the corresponding Account. If successful, the
 // access token/secret and Account are added to the ctx and the success handler
-// is called. Otherwise, the failure handler is called.
+// is called. Otherwise, the failure handler is called. Requests are hardened
+// with gologin.BodyHandler, so non-POST requests, other content types, and
+// bodies over 64KB fail with a typed gologin error.
 func TokenHandler(config *oauth1.Config, success, failure http.Handler) http.Handler {
 	success = digitsHandler(config, success, failure)
 	if failure == nil {
@@ -32,11 +34,6 @@ func TokenHandler(config *oauth1.Config, success, failure http.Handler) http.Han
 	}
 	fn := func(w http.ResponseWriter, req *http.Request) {
 		ctx := req.Context()
-		if req.Method != "POST" {
-			ctx = gologin.WithError(ctx, fmt.Errorf("Method not allowed"))
-			failure.ServeHTTP(w, req.WithContext(ctx))
-			return
-		}
 		req.ParseForm()
 		accessToken := req.PostForm.Get(accessTokenField)
 		accessSecret := req.PostForm.Get(accessTokenSecretField)
@@ -49,7 +46,7 @@ func TokenHandler(config *oauth1.Config, success, failure http.Handler) http.Han
 		ctx = oauth1Login.WithAccessToken(ctx, accessToken, accessSecret)
 		success.ServeHTTP(w, req.WithContext(ctx))
 	}
-	return http.HandlerFunc(fn)
+	return gologin.BodyHandler(gologin.BodyConfig{}, http.HandlerFunc(fn), failure)
 }
 
 // digitsHandler is a http.Handler that gets the OAuth1 access token from the
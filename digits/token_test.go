@@ -2,6 +2,7 @@ package digits
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -80,7 +81,7 @@ func TestTokenHandler_ErrorVerifyingToken(t *testing.T) {
 		ctx := req.Context()
 		err := gologin.ErrorFromContext(ctx)
 		if assert.Error(t, err) {
-			assert.Equal(t, err, ErrUnableToGetDigitsAccount)
+			assert.True(t, errors.Is(err, ErrUnableToGetDigitsAccount))
 		}
 		fmt.Fprintf(w, "failure handler called")
 	}
@@ -105,8 +106,8 @@ func TestTokenHandler_NonPost(t *testing.T) {
 	assert.Nil(t, err)
 	// assert that default (nil) failure handler returns a 405 Method Not Allowed
 	if assert.NotNil(t, resp) {
-		// TODO: change to 405
-		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+		assert.Equal(t, "POST", resp.Header.Get("Allow"))
 	}
 }
 
@@ -0,0 +1,52 @@
+package discord
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dghubble/gologin"
+)
+
+// unexported key type prevents collisions
+type key int
+
+const (
+	userKey key = iota
+)
+
+// WithUser returns a copy of ctx that stores the Discord User and its gologin
+// Profile.
+func WithUser(ctx context.Context, user *User) context.Context {
+	ctx = gologin.WithProfile(ctx, newProfile(user))
+	return context.WithValue(ctx, userKey, user)
+}
+
+// UserFromContext returns the Discord User from the ctx.
+func UserFromContext(ctx context.Context) (*User, error) {
+	user, ok := ctx.Value(userKey).(*User)
+	if !ok {
+		return nil, fmt.Errorf("discord: Context missing Discord User")
+	}
+	return user, nil
+}
+
+// newProfile returns the gologin Profile of the Discord User.
+func newProfile(user *User) *gologin.Profile {
+	if user == nil {
+		return nil
+	}
+	// Discord returns an avatar hash
+	var avatarURL string
+	if user.Avatar != "" {
+		avatarURL = "https://cdn.discordapp.com/avatars/" + user.ID + "/" + user.Avatar + ".png"
+	}
+	return &gologin.Profile{
+		Provider:      ProviderName,
+		ID:            user.ID,
+		Email:         user.Email,
+		EmailVerified: user.Verified,
+		Name:          gologin.DisplayName(user.GlobalName, user.Username),
+		AvatarURL:     avatarURL,
+		Raw:           gologin.ProfileRaw(user, "id", "email", "verified", "global_name", "avatar"),
+	}
+}
@@ -0,0 +1,41 @@
+package discord
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dghubble/gologin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContextUser(t *testing.T) {
+	expectedUser := &User{ID: "80351110224678912", Username: "nelly"}
+	ctx := WithUser(context.Background(), expectedUser)
+	user, err := UserFromContext(ctx)
+	assert.Equal(t, expectedUser, user)
+	assert.Nil(t, err)
+}
+
+func TestContextUser_Error(t *testing.T) {
+	user, err := UserFromContext(context.Background())
+	assert.Nil(t, user)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "discord: Context missing Discord User", err.Error())
+	}
+}
+
+func TestContextProfile(t *testing.T) {
+	user := &User{ID: "80351110224678912", Username: "nelly", Email: "nelly@example.com", Verified: true, Avatar: "8342729096ea3675442027381ff50dfe"}
+	profile, err := gologin.ProfileFromContext(WithUser(context.Background(), user))
+	assert.Nil(t, err)
+	expected := &gologin.Profile{
+		Provider:      "discord",
+		ID:            "80351110224678912",
+		Email:         "nelly@example.com",
+		EmailVerified: true,
+		Name:          "nelly",
+		AvatarURL:     "https://cdn.discordapp.com/avatars/80351110224678912/8342729096ea3675442027381ff50dfe.png",
+		Raw:           map[string]interface{}{"username": "nelly"},
+	}
+	assert.Equal(t, expected, profile)
+}
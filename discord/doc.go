@@ -0,0 +1,2 @@
+// Package discord provides Discord OAuth2 login and callback handlers.
+package discord
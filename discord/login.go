@@ -0,0 +1,202 @@
+package discord
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/internal"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"golang.org/x/oauth2"
+)
+
+// ProviderName is the provider name set in the ctx (see
+// gologin.ProviderFromContext) and the Profile.
+const ProviderName = "discord"
+
+// Discord login errors
+var (
+	ErrUnableToGetDiscordUser   = errors.New("discord: unable to get Discord User")
+	ErrUnableToGetDiscordGuilds = errors.New("discord: unable to get Discord User guilds")
+	ErrNotGuildMember           = errors.New("discord: Discord User is not a member of the guild")
+	ErrRateLimited              = errors.New("discord: Discord rate limit exceeded")
+)
+
+// Endpoint is Discord's OAuth2 endpoint.
+var Endpoint = oauth2.Endpoint{
+	AuthURL:  "https://discord.com/oauth2/authorize",
+	TokenURL: "https://discord.com/api/oauth2/token",
+}
+
+// RateLimitError is the error of rate limited Discord API requests.
+type RateLimitError struct {
+	// RetryAfter is how long to wait before retrying
+	RetryAfter time.Duration
+	// Global is true if the global (rather than a per-route) limit was hit
+	Global bool
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("%s, retry after %s", ErrRateLimited, e.RetryAfter)
+}
+
+// Is returns true for ErrRateLimited.
+func (e *RateLimitError) Is(target error) bool {
+	return target == ErrRateLimited
+}
+
+// Config configures Discord login.
+type Config struct {
+	// RequireGuildID requires the Discord User to be a member of the guild
+	// (server) with the ID. Callbacks for other Users fail with
+	// ErrNotGuildMember. The oauth2 Config Scopes must include "guilds".
+	RequireGuildID string
+}
+
+// StateHandler checks for a state cookie. If found, the state value is read
+// and added to the ctx. Otherwise, a non-guessable value is added to the ctx
+// and to a (short-lived) state cookie issued to the requester.
+//
+// Implements OAuth 2 RFC 6749 10.12 CSRF Protection. If you wish to issue
+// state params differently, write a http.Handler which sets the ctx state,
+// using oauth2 WithState(ctx, state) since it is required by LoginHandler
+// and CallbackHandler.
+func StateHandler(config gologin.CookieConfig, success http.Handler) http.Handler {
+	return oauth2Login.StateHandler(config, success)
+}
+
+// LoginHandler handles Discord login requests by reading the state value
+// from the ctx and redirecting requests to the AuthURL with that state value.
+// Any AuthCodeOptions are added to the AuthURL.
+//
+// The config Endpoint should be the discord Endpoint and Scopes should include
+// "identify" (and "email" for the User Email).
+func LoginHandler(config *oauth2.Config, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	return oauth2Login.LoginHandler(config, failure, opts...)
+}
+
+// CallbackHandler handles Discord redirection URI requests and adds the
+// Discord access token and User to the ctx. If authentication succeeds,
+// handling delegates to the success handler, otherwise to the failure
+// handler.
+// Any AuthCodeOptions are sent with the token exchange.
+func CallbackHandler(config *oauth2.Config, success, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	return CallbackHandlerWithConfig(config, Config{}, success, failure, opts...)
+}
+
+// CallbackHandlerWithConfig handles Discord redirection URI requests like
+// CallbackHandler, but enforces the Config. If the Config has a
+// RequireGuildID, callbacks for Users who are not members of the guild fail
+// with ErrNotGuildMember.
+func CallbackHandlerWithConfig(config *oauth2.Config, discordConfig Config, success, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	success = discordHandler(config, discordConfig, success, failure)
+	return oauth2Login.CallbackHandler(config, success, failure, opts...)
+}
+
+// discordHandler is a http.Handler that gets the OAuth2 Token from the ctx
+// to get the corresponding Discord User (and, per the Config, check its guild
+// membership). If successful, the User is added to the ctx and the success
+// handler is called. Otherwise, the failure handler is called.
+//
+// Rate limited requests fail with a *RateLimitError.
+func discordHandler(config *oauth2.Config, discordConfig Config, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		httpClient := internal.OAuth2Client(ctx, config, token)
+		discordClient := newClient(httpClient)
+		user, rateLimit, resp, err := discordClient.CurrentUser(ctx)
+		err = validateResponse(user, rateLimit, resp, err)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		if discordConfig.RequireGuildID != "" {
+			guilds, rateLimit, resp, err := discordClient.Guilds(ctx)
+			err = validateGuildsResponse(rateLimit, resp, err)
+			if err == nil && !isGuildMember(guilds, discordConfig.RequireGuildID) {
+				err = ErrNotGuildMember
+			}
+			if err != nil {
+				ctx = gologin.WithError(ctx, err)
+				failure.ServeHTTP(w, req.WithContext(ctx))
+				return
+			}
+		}
+		ctx = WithUser(ctx, user)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// isGuildMember returns true if the guilds include the guild ID.
+func isGuildMember(guilds []Guild, guildID string) bool {
+	for _, guild := range guilds {
+		if guild.ID == guildID {
+			return true
+		}
+	}
+	return false
+}
+
+// validateResponse returns an error if the given Discord User, raw
+// http.Response, or error are unexpected. Returns nil if they are valid, a
+// *RateLimitError if the request was rate limited, or a *gologin.Error which
+// preserves the cause and status code.
+func validateResponse(user *User, rateLimit *rateLimitResponse, resp *http.Response, err error) error {
+	var status int
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	if status == http.StatusTooManyRequests {
+		return newRateLimitError(rateLimit)
+	}
+	if err != nil || status != http.StatusOK {
+		return &gologin.Error{Provider: "discord", Op: "get user", StatusCode: status, Err: err, Kind: ErrUnableToGetDiscordUser}
+	}
+	if user == nil || user.ID == "" {
+		return &gologin.Error{Provider: "discord", Op: "get user", StatusCode: status, Kind: ErrUnableToGetDiscordUser}
+	}
+	return nil
+}
+
+// validateGuildsResponse returns an error if the raw http.Response or error of
+// a user guilds request are unexpected. Returns nil if they are valid, a
+// *RateLimitError if the request was rate limited, or a *gologin.Error which
+// preserves the cause and status code.
+func validateGuildsResponse(rateLimit *rateLimitResponse, resp *http.Response, err error) error {
+	var status int
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	if status == http.StatusTooManyRequests {
+		return newRateLimitError(rateLimit)
+	}
+	if err != nil || status != http.StatusOK {
+		return &gologin.Error{Provider: "discord", Op: "get guilds", StatusCode: status, Err: err, Kind: ErrUnableToGetDiscordGuilds}
+	}
+	return nil
+}
+
+// newRateLimitError returns a *RateLimitError for the 429 response body,
+// whose retry_after is in seconds.
+func newRateLimitError(rateLimit *rateLimitResponse) *RateLimitError {
+	if rateLimit == nil {
+		return &RateLimitError{}
+	}
+	return &RateLimitError{
+		RetryAfter: time.Duration(rateLimit.RetryAfter * float64(time.Second)),
+		Global:     rateLimit.Global,
+	}
+}
@@ -0,0 +1,217 @@
+package discord
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dghubble/gologin"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+const (
+	testUserJSON   = `{"id": "80351110224678912", "username": "nelly", "global_name": "Nelly", "email": "nelly@example.com", "verified": true, "avatar": "8342729096ea3675442027381ff50dfe"}`
+	testGuildsJSON = `[{"id": "80351110224678913", "name": "Other Server", "owner": false}, {"id": "41771983423143937", "name": "Community", "owner": true}]`
+)
+
+func testConfig() *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     "client_id",
+		ClientSecret: "client_secret",
+		RedirectURL:  "https://example.com/discord/callback",
+		Endpoint:     Endpoint,
+		Scopes:       []string{"identify", "email", "guilds"},
+	}
+}
+
+func TestCallbackHandler(t *testing.T) {
+	proxyClient, server := newDiscordTestServer(testUserJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithState(ctx, "d4e5f6")
+
+	success := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "any-token", token.AccessToken)
+		}
+		user, err := UserFromContext(ctx)
+		if assert.Nil(t, err) {
+			expectedUser := &User{ID: "80351110224678912", Username: "nelly", GlobalName: "Nelly", Email: "nelly@example.com", Verified: true, Avatar: "8342729096ea3675442027381ff50dfe"}
+			assert.Equal(t, expectedUser, user)
+		}
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// CallbackHandler assert that:
+	// - the Token is obtained from the Discord token endpoint
+	// - the Discord User is obtained from users/@me
+	// - success handler is called with the Token and User in the ctx
+	callbackHandler := CallbackHandler(testConfig(), http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestDiscordHandler_RequireGuildID(t *testing.T) {
+	proxyClient, server := newDiscordGuildsTestServer(testUserJSON, http.StatusOK, testGuildsJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+
+	success := func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, ErrNotGuildMember, gologin.ErrorFromContext(req.Context()))
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// DiscordHandler with a RequireGuildID, assert that:
+	// - guild members call the success handler
+	// - other Users call the failure handler with ErrNotGuildMember
+	cases := []struct {
+		guildID  string
+		expected string
+	}{
+		{"", "success handler called"},
+		{"41771983423143937", "success handler called"},
+		{"99999999999999999", "failure handler called"},
+	}
+	for _, c := range cases {
+		discordHandler := discordHandler(testConfig(), Config{RequireGuildID: c.guildID}, http.HandlerFunc(success), http.HandlerFunc(failure))
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		discordHandler.ServeHTTP(w, req.WithContext(ctx))
+		assert.Equal(t, c.expected, w.Body.String())
+	}
+}
+
+func TestDiscordHandler_ErrorGettingGuilds(t *testing.T) {
+	proxyClient, server := newDiscordGuildsTestServer(testUserJSON, http.StatusUnauthorized, `{"message": "401: Unauthorized", "code": 0}`)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.True(t, errors.Is(err, ErrUnableToGetDiscordGuilds))
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// DiscordHandler cannot get the User's guilds (e.g. no guilds scope), assert that:
+	// - failure handler is called
+	// - error cannot get Discord guilds added to the failure handler ctx
+	discordHandler := discordHandler(testConfig(), Config{RequireGuildID: "41771983423143937"}, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	discordHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestDiscordHandler_RateLimited(t *testing.T) {
+	proxyClient, server := newDiscordRateLimitServer()
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.True(t, errors.Is(err, ErrRateLimited))
+			assert.False(t, errors.Is(err, ErrUnableToGetDiscordUser))
+			var rateLimitErr *RateLimitError
+			if assert.True(t, errors.As(err, &rateLimitErr)) {
+				assert.Equal(t, 2500*time.Millisecond, rateLimitErr.RetryAfter)
+				assert.False(t, rateLimitErr.Global)
+			}
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// DiscordHandler rate limited, assert that:
+	// - failure handler is called
+	// - a RateLimitError with the retry_after is added to the failure handler ctx
+	discordHandler := discordHandler(testConfig(), Config{}, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	discordHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestDiscordHandler_MissingCtxToken(t *testing.T) {
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "oauth2: Context missing Token", err.Error())
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// DiscordHandler called without Token in ctx, assert that:
+	// - failure handler is called
+	// - error about ctx missing token is added to the failure handler ctx
+	discordHandler := discordHandler(testConfig(), Config{}, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	discordHandler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestDiscordHandler_ErrorGettingUser(t *testing.T) {
+	proxyClient, server := testutils.NewErrorServer("Discord Service Down", http.StatusInternalServerError)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.True(t, errors.Is(err, ErrUnableToGetDiscordUser))
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// DiscordHandler cannot get Discord User, assert that:
+	// - failure handler is called
+	// - error cannot get Discord User added to the failure handler ctx
+	discordHandler := discordHandler(testConfig(), Config{}, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	discordHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestValidateResponse(t *testing.T) {
+	validUser := &User{ID: "80351110224678912"}
+	validResponse := &http.Response{StatusCode: 200}
+	invalidResponse := &http.Response{StatusCode: 500}
+	rateLimited := &http.Response{StatusCode: 429}
+	assert.Equal(t, nil, validateResponse(validUser, nil, validResponse, nil))
+	assert.True(t, errors.Is(validateResponse(validUser, nil, validResponse, fmt.Errorf("Server error")), ErrUnableToGetDiscordUser))
+	assert.True(t, errors.Is(validateResponse(validUser, nil, invalidResponse, nil), ErrUnableToGetDiscordUser))
+	assert.True(t, errors.Is(validateResponse(&User{}, nil, validResponse, nil), ErrUnableToGetDiscordUser))
+	assert.True(t, errors.Is(validateResponse(nil, nil, validResponse, nil), ErrUnableToGetDiscordUser))
+	assert.Equal(t, &RateLimitError{RetryAfter: time.Second, Global: true}, validateResponse(nil, &rateLimitResponse{RetryAfter: 1, Global: true}, rateLimited, nil))
+}
@@ -0,0 +1,57 @@
+package discord
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/dghubble/gologin/testutils"
+)
+
+// newDiscordTestServer returns a new httptest.Server which mocks the Discord
+// OAuth2 token and users/@me endpoints and a client which proxies requests to
+// the server. The users/@me endpoint responds with the given json data. The
+// caller must close the server.
+func newDiscordTestServer(jsonData string) (*http.Client, *httptest.Server) {
+	client, mux, server := testutils.TestServer()
+	mux.HandleFunc("/api/oauth2/token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token": "any-token", "token_type": "Bearer", "expires_in": 604800, "refresh_token": "any-refresh", "scope": "identify email guilds"}`)
+	})
+	mux.HandleFunc("/api/users/@me", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, jsonData)
+	})
+	return client, server
+}
+
+// newDiscordGuildsTestServer returns a new httptest.Server which mocks the
+// Discord users/@me and users/@me/guilds endpoints and a client which proxies
+// requests to the server. The guilds endpoint responds with the given status
+// and json data. The caller must close the server.
+func newDiscordGuildsTestServer(userJSON string, status int, guildsJSON string) (*http.Client, *httptest.Server) {
+	client, mux, server := testutils.TestServer()
+	mux.HandleFunc("/api/users/@me", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, userJSON)
+	})
+	mux.HandleFunc("/api/users/@me/guilds", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		fmt.Fprintf(w, guildsJSON)
+	})
+	return client, server
+}
+
+// newDiscordRateLimitServer returns a new httptest.Server which responds to
+// all requests with a Discord 429 rate limit response and a client which
+// proxies requests to the server. The caller must close the server.
+func newDiscordRateLimitServer() (*http.Client, *httptest.Server) {
+	client, mux, server := testutils.TestServer()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusTooManyRequests)
+		fmt.Fprintf(w, `{"message": "You are being rate limited.", "retry_after": 2.5, "global": false}`)
+	})
+	return client, server
+}
@@ -0,0 +1,64 @@
+package discord
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/dghubble/gologin/internal/jsonclient"
+)
+
+const discordAPI = "https://discord.com/api/"
+
+// User is a Discord user.
+type User struct {
+	ID         string `json:"id"`
+	Username   string `json:"username"`
+	GlobalName string `json:"global_name"`
+	Email      string `json:"email"`
+	Verified   bool   `json:"verified"`
+	Avatar     string `json:"avatar"`
+}
+
+// Guild is a Discord guild (server) the user is a member of.
+type Guild struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Owner bool   `json:"owner"`
+}
+
+// rateLimitResponse is a Discord 429 Too Many Requests response.
+type rateLimitResponse struct {
+	Message    string  `json:"message"`
+	RetryAfter float64 `json:"retry_after"`
+	Global     bool    `json:"global"`
+}
+
+// client is a Discord client for obtaining a User.
+type client struct {
+	json *jsonclient.Client
+}
+
+// newClient returns a new Discord client.
+func newClient(httpClient *http.Client) *client {
+	return &client{
+		json: jsonclient.New(httpClient, discordAPI),
+	}
+}
+
+// CurrentUser gets the current Discord User.
+// https://discord.com/developers/docs/resources/user#get-current-user
+func (c *client) CurrentUser(ctx context.Context) (*User, *rateLimitResponse, *http.Response, error) {
+	user := new(User)
+	rateLimit := new(rateLimitResponse)
+	resp, err := c.json.Get(ctx, "users/@me", nil, user, rateLimit)
+	return user, rateLimit, resp, err
+}
+
+// Guilds gets the current Discord User's Guilds (requires the guilds scope).
+// https://discord.com/developers/docs/resources/user#get-current-user-guilds
+func (c *client) Guilds(ctx context.Context) ([]Guild, *rateLimitResponse, *http.Response, error) {
+	var guilds []Guild
+	rateLimit := new(rateLimitResponse)
+	resp, err := c.json.Get(ctx, "users/@me/guilds", nil, &guilds, rateLimit)
+	return guilds, rateLimit, resp, err
+}
@@ -0,0 +1,47 @@
+package dropbox
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dghubble/gologin"
+)
+
+// unexported key type prevents collisions
+type key int
+
+const (
+	userKey key = iota
+)
+
+// WithUser returns a copy of ctx that stores the Dropbox User and its gologin
+// Profile.
+func WithUser(ctx context.Context, user *User) context.Context {
+	ctx = gologin.WithProfile(ctx, newProfile(user))
+	return context.WithValue(ctx, userKey, user)
+}
+
+// UserFromContext returns the Dropbox User from the ctx.
+func UserFromContext(ctx context.Context) (*User, error) {
+	user, ok := ctx.Value(userKey).(*User)
+	if !ok {
+		return nil, fmt.Errorf("dropbox: Context missing Dropbox User")
+	}
+	return user, nil
+}
+
+// newProfile returns the gologin Profile of the Dropbox User.
+func newProfile(user *User) *gologin.Profile {
+	if user == nil {
+		return nil
+	}
+	return &gologin.Profile{
+		Provider:      ProviderName,
+		ID:            user.AccountID,
+		Email:         user.Email,
+		EmailVerified: user.EmailVerified,
+		Name:          user.Name.DisplayName,
+		AvatarURL:     user.ProfilePhotoURL,
+		Raw:           gologin.ProfileRaw(user, "account_id", "email", "email_verified", "profile_photo_url"),
+	}
+}
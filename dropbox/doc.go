@@ -0,0 +1,2 @@
+// Package dropbox provides Dropbox OAuth2 login and callback handlers.
+package dropbox
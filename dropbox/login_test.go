@@ -0,0 +1,207 @@
+package dropbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/dghubble/gologin"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+const (
+	testAccountJSON         = `{"account_id": "dbid:AAH4f99T0taONIb-OurWxbNQ6ywGRopQngc", "name": {"given_name": "Franz", "surname": "Ferdinand", "familiar_name": "Franz", "display_name": "Franz Ferdinand (Personal)", "abbreviated_name": "FF"}, "email": "franz@example.com", "email_verified": true, "disabled": false, "locale": "en", "profile_photo_url": "https://dl-web.dropbox.com/account_photo/get/franz.jpg"}`
+	testDisabledAccountJSON = `{"account_id": "dbid:AAH4f99T0taONIb-OurWxbNQ6ywGRopQngc", "name": {"display_name": "Franz Ferdinand (Personal)"}, "email": "franz@example.com", "disabled": true}`
+)
+
+func testConfig() *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     "client_id",
+		ClientSecret: "client_secret",
+		RedirectURL:  "https://example.com/dropbox/callback",
+		Endpoint:     Endpoint,
+		Scopes:       []string{"account_info.read"},
+	}
+}
+
+func TestLoginHandler_OfflineAccess(t *testing.T) {
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// LoginHandler with OfflineAccess assert that:
+	// - redirects to the Dropbox AuthURL with the state
+	// - token_access_type=offline is requested
+	loginHandler := LoginHandler(testConfig(), failure, OfflineAccess)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	ctx := oauth2Login.WithState(context.Background(), "d4e5f6")
+	loginHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, http.StatusFound, w.Code)
+	location, err := url.Parse(w.HeaderMap.Get("Location"))
+	if assert.Nil(t, err) {
+		assert.Equal(t, "www.dropbox.com", location.Host)
+		assert.Equal(t, "d4e5f6", location.Query().Get("state"))
+		assert.Equal(t, "offline", location.Query().Get("token_access_type"))
+	}
+}
+
+func TestCallbackHandler(t *testing.T) {
+	proxyClient, server := newDropboxTestServer(testAccountJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithState(ctx, "d4e5f6")
+
+	success := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "sl.any-token", token.AccessToken)
+			assert.Equal(t, "any-refresh", token.RefreshToken)
+		}
+		user, err := UserFromContext(ctx)
+		if assert.Nil(t, err) {
+			expectedUser := &User{
+				AccountID:       "dbid:AAH4f99T0taONIb-OurWxbNQ6ywGRopQngc",
+				Name:            Name{GivenName: "Franz", Surname: "Ferdinand", FamiliarName: "Franz", DisplayName: "Franz Ferdinand (Personal)"},
+				Email:           "franz@example.com",
+				EmailVerified:   true,
+				ProfilePhotoURL: "https://dl-web.dropbox.com/account_photo/get/franz.jpg",
+			}
+			assert.Equal(t, expectedUser, user)
+		}
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// CallbackHandler assert that:
+	// - the Token is obtained from the Dropbox token endpoint
+	// - the Dropbox User is obtained with a JSON POST to get_current_account
+	// - success handler is called with the Token and User in the ctx
+	callbackHandler := CallbackHandler(testConfig(), http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestDropboxHandler_AccountDisabled(t *testing.T) {
+	proxyClient, server := newDropboxTestServer(testDisabledAccountJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "sl.any-token"})
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, ErrAccountDisabled, gologin.ErrorFromContext(req.Context()))
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// DropboxHandler for a disabled account, assert that:
+	// - failure handler is called
+	// - error Dropbox account disabled added to the failure handler ctx
+	dropboxHandler := dropboxHandler(testConfig(), success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	dropboxHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestDropboxHandler_APIError(t *testing.T) {
+	proxyClient, mux, server := testutils.TestServer()
+	defer server.Close()
+	mux.HandleFunc("/2/users/get_current_account", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprintf(w, `{"error_summary": "invalid_access_token/..", "error": {".tag": "invalid_access_token"}}`)
+	})
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "sl.any-token"})
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.True(t, errors.Is(err, ErrUnableToGetDropboxUser))
+			var apiErr *APIError
+			if assert.True(t, errors.As(err, &apiErr)) {
+				assert.Equal(t, "invalid_access_token/..", apiErr.Summary)
+			}
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// DropboxHandler with an invalid token, assert that:
+	// - failure handler is called
+	// - error cannot get Dropbox User (with the APIError) added to the ctx
+	dropboxHandler := dropboxHandler(testConfig(), success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	dropboxHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestDropboxHandler_MissingCtxToken(t *testing.T) {
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "oauth2: Context missing Token", err.Error())
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// DropboxHandler called without Token in ctx, assert that:
+	// - failure handler is called
+	// - error about ctx missing token is added to the failure handler ctx
+	dropboxHandler := dropboxHandler(testConfig(), success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	dropboxHandler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestDropboxHandler_ErrorGettingUser(t *testing.T) {
+	proxyClient, server := testutils.NewErrorServer("Dropbox Service Down", http.StatusInternalServerError)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "sl.any-token"})
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.True(t, errors.Is(err, ErrUnableToGetDropboxUser))
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// DropboxHandler cannot get Dropbox User, assert that:
+	// - failure handler is called
+	// - error cannot get Dropbox User added to the failure handler ctx
+	dropboxHandler := dropboxHandler(testConfig(), success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	dropboxHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestValidateResponse(t *testing.T) {
+	validUser := &User{AccountID: "dbid:AAH4f99T0taONIb-OurWxbNQ6ywGRopQngc"}
+	validResponse := &http.Response{StatusCode: 200}
+	invalidResponse := &http.Response{StatusCode: 400}
+	assert.Equal(t, nil, validateResponse(validUser, validResponse, nil))
+	assert.True(t, errors.Is(validateResponse(validUser, validResponse, fmt.Errorf("Server error")), ErrUnableToGetDropboxUser))
+	assert.True(t, errors.Is(validateResponse(validUser, invalidResponse, nil), ErrUnableToGetDropboxUser))
+	assert.True(t, errors.Is(validateResponse(&User{}, validResponse, nil), ErrUnableToGetDropboxUser))
+	assert.True(t, errors.Is(validateResponse(nil, validResponse, nil), ErrUnableToGetDropboxUser))
+}
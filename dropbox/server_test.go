@@ -0,0 +1,35 @@
+package dropbox
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/dghubble/gologin/testutils"
+)
+
+// newDropboxTestServer returns a new httptest.Server which mocks the Dropbox
+// token and users/get_current_account endpoints and a client which proxies
+// requests to the server. Like Dropbox, get_current_account rejects requests
+// which are not JSON POSTs and otherwise responds with the given json data.
+// The caller must close the server.
+func newDropboxTestServer(jsonData string) (*http.Client, *httptest.Server) {
+	client, mux, server := testutils.TestServer()
+	mux.HandleFunc("/oauth2/token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token": "sl.any-token", "token_type": "bearer", "expires_in": 14400, "refresh_token": "any-refresh", "account_id": "dbid:AAH4f99T0taONIb-OurWxbNQ6ywGRopQngc", "uid": "12345"}`)
+	})
+	mux.HandleFunc("/2/users/get_current_account", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Method != "POST" || r.Header.Get("Content-Type") != "application/json" || strings.TrimSpace(string(body)) != "null" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, "Error in call to API function \"users/get_current_account\": Bad HTTP \"Content-Type\" header")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, jsonData)
+	})
+	return client, server
+}
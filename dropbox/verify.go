@@ -0,0 +1,70 @@
+package dropbox
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/dghubble/gologin/internal/jsonclient"
+)
+
+const dropboxAPI = "https://api.dropboxapi.com/2/"
+
+// noArgs is the JSON body of Dropbox RPC endpoints which take no arguments.
+var noArgs = json.RawMessage("null")
+
+// User is a Dropbox account.
+type User struct {
+	AccountID       string `json:"account_id"`
+	Name            Name   `json:"name"`
+	Email           string `json:"email"`
+	EmailVerified   bool   `json:"email_verified"`
+	Disabled        bool   `json:"disabled"`
+	ProfilePhotoURL string `json:"profile_photo_url"`
+}
+
+// Name is a Dropbox account's name.
+type Name struct {
+	GivenName    string `json:"given_name"`
+	Surname      string `json:"surname"`
+	FamiliarName string `json:"familiar_name"`
+	DisplayName  string `json:"display_name"`
+}
+
+// APIError is a Dropbox API error.
+type APIError struct {
+	Summary string `json:"error_summary"`
+}
+
+func (e *APIError) Error() string {
+	return "dropbox: " + e.Summary
+}
+
+// client is a Dropbox client for obtaining a User.
+type client struct {
+	json *jsonclient.Client
+}
+
+// newClient returns a new Dropbox client.
+func newClient(httpClient *http.Client) *client {
+	return &client{
+		json: jsonclient.New(httpClient, dropboxAPI),
+	}
+}
+
+// CurrentAccount gets the current Dropbox User. Dropbox RPC endpoints must be
+// POSTed a JSON body, so a null body is sent.
+// https://www.dropbox.com/developers/documentation/http/documentation#users-get_current_account
+func (c *client) CurrentAccount(ctx context.Context) (*User, *http.Response, error) {
+	user := new(User)
+	apiErr := new(APIError)
+	req, err := c.json.NewRequest(ctx, "POST", "users/get_current_account", nil, noArgs)
+	if err != nil {
+		return user, nil, err
+	}
+	resp, err := c.json.Do(req, user, apiErr)
+	if err == nil && apiErr.Summary != "" {
+		err = apiErr
+	}
+	return user, resp, err
+}
@@ -0,0 +1,20 @@
+/*
+Package echocontext adapts gologin handlers to Echo.
+
+Wrap a LoginHandler chain as an echo.HandlerFunc with Wrap and use Callback
+to run a CallbackHandler chain as route middleware. On success, the gologin
+ctx is set on the echo.Context Request and the route handler is called. On
+failure, an *echo.HTTPError with the gologin error message is returned for
+Echo's HTTPErrorHandler to render.
+
+	e := echo.New()
+	stateConfig := gologin.DebugOnlyCookieConfig
+	e.GET("/facebook/login", echocontext.Wrap(facebook.StateHandler(stateConfig, facebook.LoginHandler(oauth2Config, nil))))
+	e.GET("/facebook/callback", func(c echo.Context) error {
+		user, _ := echocontext.FacebookUser(c)
+		return c.String(http.StatusOK, "Hello "+user.Name)
+	}, echocontext.Callback(func(success, failure http.Handler) http.Handler {
+		return facebook.StateHandler(stateConfig, facebook.CallbackHandler(oauth2Config, success, failure))
+	}, echocontext.Config{}))
+*/
+package echocontext
@@ -0,0 +1,105 @@
+package echocontext
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/facebook"
+	oauth1Login "github.com/dghubble/gologin/oauth1"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/oauth2"
+)
+
+// unexported key type prevents collisions
+type key int
+
+const (
+	callKey key = iota
+)
+
+// Config configures Callback failure handling.
+type Config struct {
+	// FailureStatus is the echo.HTTPError status of failed callbacks.
+	// Defaults to 401 Unauthorized.
+	FailureStatus int
+}
+
+// failureStatus returns the Config FailureStatus or 401 Unauthorized.
+func (c Config) failureStatus() int {
+	if c.FailureStatus == 0 {
+		return http.StatusUnauthorized
+	}
+	return c.FailureStatus
+}
+
+// callState is the state of a Callback request.
+type callState struct {
+	c    echo.Context
+	next echo.HandlerFunc
+	err  error
+}
+
+// Wrap returns an echo.HandlerFunc which calls the handler (e.g. a
+// LoginHandler chain) with the echo.Context request.
+func Wrap(handler http.Handler) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		handler.ServeHTTP(c.Response(), c.Request())
+		return nil
+	}
+}
+
+// Callback returns an echo.MiddlewareFunc which calls the handler chain
+// built by newHandler (e.g. a CallbackHandler chain) with the echo.Context
+// request ctx. The chain is built once per route with success and failure
+// handlers which return control to Echo.
+//
+// If the chain succeeds, the gologin ctx is set on the echo.Context Request
+// and the route handler is called. Providers' UserFromContext read the User
+// from c.Request().Context() (see FacebookUser). If the chain fails, an
+// *echo.HTTPError with the Config FailureStatus and the gologin error message
+// (and the error as its Internal error) is returned.
+func Callback(newHandler func(success, failure http.Handler) http.Handler, config Config) echo.MiddlewareFunc {
+	status := config.failureStatus()
+	success := func(w http.ResponseWriter, req *http.Request) {
+		state := req.Context().Value(callKey).(*callState)
+		state.c.SetRequest(req)
+		state.err = state.next(state.c)
+	}
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		state := req.Context().Value(callKey).(*callState)
+		err := gologin.ErrorFromContext(req.Context())
+		state.err = echo.NewHTTPError(status, err.Error()).SetInternal(err)
+	}
+	handler := newHandler(http.HandlerFunc(success), http.HandlerFunc(failure))
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			state := &callState{c: c, next: next}
+			ctx := context.WithValue(c.Request().Context(), callKey, state)
+			handler.ServeHTTP(c.Response(), c.Request().WithContext(ctx))
+			return state.err
+		}
+	}
+}
+
+// Token returns the OAuth2 Token of a successful Callback.
+func Token(c echo.Context) (*oauth2.Token, error) {
+	return oauth2Login.TokenFromContext(c.Request().Context())
+}
+
+// AccessToken returns the OAuth2 or OAuth1 access token of a successful
+// Callback.
+func AccessToken(c echo.Context) (string, error) {
+	ctx := c.Request().Context()
+	if token, err := oauth2Login.TokenFromContext(ctx); err == nil {
+		return token.AccessToken, nil
+	}
+	accessToken, _, err := oauth1Login.AccessTokenFromContext(ctx)
+	return accessToken, err
+}
+
+// FacebookUser returns the Facebook User of a successful facebook Callback.
+func FacebookUser(c echo.Context) (*facebook.User, error) {
+	return facebook.UserFromContext(c.Request().Context())
+}
@@ -0,0 +1,148 @@
+package echocontext
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/facebook"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+var testStateConfig = gologin.CookieConfig{
+	Name:   "facebook-state",
+	Path:   "/",
+	MaxAge: 60,
+}
+
+func testConfig() *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     "client_id",
+		ClientSecret: "client_secret",
+		RedirectURL:  "https://example.com/facebook/callback",
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  "https://www.facebook.com/dialog/oauth",
+			TokenURL: "https://graph.facebook.com/v2.9/oauth/access_token",
+		},
+	}
+}
+
+// newFacebookTestServer returns a new httptest.Server which mocks the
+// Facebook token and user endpoints and a client which proxies requests to
+// the server. The caller must close the server.
+func newFacebookTestServer() (*http.Client, *httptest.Server) {
+	client, mux, server := testutils.TestServer()
+	mux.HandleFunc("/v2.9/oauth/access_token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token": "any-token", "token_type": "bearer", "expires_in": 5183944}`)
+	})
+	mux.HandleFunc("/v2.9/me", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"id": "54638001", "name": "Ivy Crimson"}`)
+	})
+	return client, server
+}
+
+// testCallback returns the Callback middleware of the facebook callback
+// chain.
+func testCallback(config Config) echo.MiddlewareFunc {
+	return Callback(func(success, failure http.Handler) http.Handler {
+		return facebook.StateHandler(testStateConfig, facebook.CallbackHandler(testConfig(), success, failure))
+	}, config)
+}
+
+func TestWrap(t *testing.T) {
+	e := echo.New()
+
+	// Wrap assert that:
+	// - the facebook login chain redirects with the state
+	e.GET("/facebook/login", Wrap(facebook.StateHandler(testStateConfig, facebook.LoginHandler(testConfig(), nil))))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/facebook/login", nil)
+	e.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Contains(t, w.HeaderMap.Get("Location"), "https://www.facebook.com/dialog/oauth")
+}
+
+func TestCallback(t *testing.T) {
+	proxyClient, server := newFacebookTestServer()
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+
+	e := echo.New()
+	final := func(c echo.Context) error {
+		user, err := FacebookUser(c)
+		if assert.Nil(t, err) {
+			assert.Equal(t, &facebook.User{ID: "54638001", Name: "Ivy Crimson", Raw: json.RawMessage(`{"id": "54638001", "name": "Ivy Crimson"}`)}, user)
+		}
+		accessToken, err := AccessToken(c)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "any-token", accessToken)
+		}
+		token, err := Token(c)
+		if assert.Nil(t, err) {
+			assert.False(t, token.Expiry.IsZero())
+		}
+		return c.String(http.StatusOK, "success handler called")
+	}
+
+	// Callback assert that:
+	// - the facebook callback chain is run with the request ctx
+	// - the route handler is called with the Facebook User and Token
+	e.GET("/facebook/callback", final, testCallback(Config{}))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/facebook/callback?code=any_code&state=d4e5f6", nil)
+	req.AddCookie(&http.Cookie{Name: "facebook-state", Value: "d4e5f6"})
+	e.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestCallback_Failure(t *testing.T) {
+	cases := []struct {
+		config Config
+		status int
+	}{
+		{Config{}, http.StatusUnauthorized},
+		{Config{FailureStatus: http.StatusForbidden}, http.StatusForbidden},
+	}
+	for _, c := range cases {
+		var handlerErr error
+		e := echo.New()
+		e.HTTPErrorHandler = func(err error, c echo.Context) {
+			handlerErr = err
+			e.DefaultHTTPErrorHandler(err, c)
+		}
+		final := func(c echo.Context) error {
+			t.Errorf("unexpected call to route handler")
+			return nil
+		}
+
+		// Callback with a state mismatch, assert that:
+		// - the route handler is not called
+		// - an echo.HTTPError with the Config FailureStatus and gologin error
+		// message is rendered by the HTTPErrorHandler
+		e.GET("/facebook/callback", final, testCallback(c.config))
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/facebook/callback?code=any_code&state=d4e5f6", nil)
+		req.AddCookie(&http.Cookie{Name: "facebook-state", Value: "other"})
+		e.ServeHTTP(w, req)
+		assert.Equal(t, c.status, w.Code)
+		var httpErr *echo.HTTPError
+		if assert.True(t, errors.As(handlerErr, &httpErr)) {
+			assert.Equal(t, c.status, httpErr.Code)
+			assert.Equal(t, oauth2Login.ErrInvalidState.Error(), httpErr.Message)
+			assert.Equal(t, oauth2Login.ErrInvalidState, httpErr.Internal)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package epicgames
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dghubble/gologin"
+)
+
+// unexported key type prevents collisions
+type key int
+
+const (
+	userKey key = iota
+)
+
+// WithUser returns a copy of ctx that stores the Epic Games User and its gologin
+// Profile.
+func WithUser(ctx context.Context, user *User) context.Context {
+	ctx = gologin.WithProfile(ctx, newProfile(user))
+	return context.WithValue(ctx, userKey, user)
+}
+
+// UserFromContext returns the Epic Games User from the ctx.
+func UserFromContext(ctx context.Context) (*User, error) {
+	user, ok := ctx.Value(userKey).(*User)
+	if !ok {
+		return nil, fmt.Errorf("epicgames: Context missing Epic Games User")
+	}
+	return user, nil
+}
+
+// newProfile returns the gologin Profile of the Epic Games User.
+func newProfile(user *User) *gologin.Profile {
+	if user == nil {
+		return nil
+	}
+	return &gologin.Profile{
+		Provider: ProviderName,
+		ID:       user.AccountID,
+		Name:     user.DisplayName,
+		Raw:      gologin.ProfileRaw(user, "accountId", "displayName"),
+	}
+}
@@ -0,0 +1,2 @@
+// Package epicgames provides Epic Games OAuth2 login and callback handlers.
+package epicgames
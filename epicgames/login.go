@@ -0,0 +1,140 @@
+package epicgames
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/internal"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/dghubble/gologin/oidc"
+	"golang.org/x/oauth2"
+)
+
+// ProviderName is the provider name set in the ctx (see
+// gologin.ProviderFromContext) and the Profile.
+const ProviderName = "epicgames"
+
+const (
+	epicGamesIssuer  = "https://api.epicgames.dev/epic/oauth/v2"
+	epicGamesJWKSURL = "https://api.epicgames.dev/epic/oauth/v2/.well-known/jwks.json"
+)
+
+// Epic Games login errors
+var (
+	ErrUnableToGetEpicGamesUser = errors.New("epicgames: unable to get Epic Games User")
+	ErrMissingAccountID         = errors.New("epicgames: Epic Games token response missing account_id (check the basic_profile scope)")
+	ErrAccountIDMismatch        = errors.New("epicgames: Epic Games id_token subject does not match account_id")
+)
+
+// Endpoint is Epic Games' OAuth2 endpoint. Epic Games requires HTTP Basic
+// client authentication at the token endpoint.
+var Endpoint = oauth2.Endpoint{
+	AuthURL:   "https://www.epicgames.com/id/authorize",
+	TokenURL:  "https://api.epicgames.dev/epic/oauth/v2/token",
+	AuthStyle: oauth2.AuthStyleInHeader,
+}
+
+// StateHandler checks for a state cookie. If found, the state value is read
+// and added to the ctx. Otherwise, a non-guessable value is added to the ctx
+// and to a (short-lived) state cookie issued to the requester.
+//
+// Implements OAuth 2 RFC 6749 10.12 CSRF Protection. If you wish to issue
+// state params differently, write a http.Handler which sets the ctx state,
+// using oauth2 WithState(ctx, state) since it is required by LoginHandler
+// and CallbackHandler.
+func StateHandler(config gologin.CookieConfig, success http.Handler) http.Handler {
+	return oauth2Login.StateHandler(config, success)
+}
+
+// LoginHandler handles Epic Games login requests by reading the state value
+// from the ctx and redirecting requests to the AuthURL with that state value.
+// Any AuthCodeOptions are added to the AuthURL.
+//
+// The config Endpoint should be the epicgames Endpoint and Scopes should
+// include at least "basic_profile" (and "openid" for an id_token).
+func LoginHandler(config *oauth2.Config, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	return oauth2Login.LoginHandler(config, failure, opts...)
+}
+
+// CallbackHandler handles Epic Games redirection URI requests and adds the
+// Epic Games access token and User to the ctx (and the id_token Claims, see
+// oidc IDTokenFromContext, if the openid scope was granted). If
+// authentication succeeds, handling delegates to the success handler,
+// otherwise to the failure handler.
+// Any AuthCodeOptions are sent with the token exchange.
+//
+// Token responses without an account_id (from misconfigured scopes) fail
+// with ErrMissingAccountID.
+func CallbackHandler(config *oauth2.Config, success, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	success = epicGamesHandler(config, success, failure)
+	return oauth2Login.CallbackHandler(config, success, failure, opts...)
+}
+
+// epicGamesHandler is a http.Handler that gets the OAuth2 Token from the ctx,
+// verifies its id_token (if any), and gets the User of its account_id. If
+// successful, the User (and Claims) are added to the ctx and the success
+// handler is called. Otherwise, the failure handler is called.
+func epicGamesHandler(config *oauth2.Config, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	verifier := oidc.NewIDTokenVerifier(epicGamesJWKSURL, config.ClientID, epicGamesIssuer)
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		accountID, ok := token.Extra("account_id").(string)
+		if !ok || accountID == "" {
+			ctx = gologin.WithError(ctx, ErrMissingAccountID)
+			ctx = gologin.WithStatusCode(ctx, http.StatusBadGateway)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		if rawIDToken, ok := token.Extra("id_token").(string); ok && rawIDToken != "" {
+			claims, err := verifier.Verify(ctx, rawIDToken)
+			if err == nil && claims.Subject != accountID {
+				err = ErrAccountIDMismatch
+			}
+			if err != nil {
+				ctx = gologin.WithError(ctx, err)
+				failure.ServeHTTP(w, req.WithContext(ctx))
+				return
+			}
+			ctx = oidc.WithIDToken(ctx, claims)
+		}
+		httpClient := internal.OAuth2Client(ctx, config, token)
+		user, resp, err := newClient(httpClient).Account(ctx, accountID)
+		err = validateResponse(user, resp, err)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		ctx = WithUser(ctx, user)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// validateResponse returns an error if the given Epic Games User, raw
+// http.Response, or error are unexpected. Returns nil if they are valid, or a
+// *gologin.Error which preserves the cause (e.g. an *APIError) and status
+// code.
+func validateResponse(user *User, resp *http.Response, err error) error {
+	var status int
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	if err != nil || status != http.StatusOK {
+		return &gologin.Error{Provider: "epicgames", Op: "get user", StatusCode: status, Err: err, Kind: ErrUnableToGetEpicGamesUser}
+	}
+	if user == nil || user.AccountID == "" {
+		return &gologin.Error{Provider: "epicgames", Op: "get user", StatusCode: status, Kind: ErrUnableToGetEpicGamesUser}
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+package epicgames
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dghubble/gologin"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/dghubble/gologin/oidc"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func testConfig() *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     "client_id",
+		ClientSecret: "client_secret",
+		RedirectURL:  "https://example.com/epicgames/callback",
+		Endpoint:     Endpoint,
+		Scopes:       []string{"basic_profile", "openid"},
+	}
+}
+
+// testTokenExtras returns the JSON token extras with the account_id and an
+// id_token for the subject.
+func testTokenExtras(subject string) string {
+	return fmt.Sprintf(`, "account_id": %q, "application_id": "fghi4567FNFBKFz3E4TROb0bmPS8h1GW", "client_id": "client_id", "id_token": %q`, testAccountID, testIDToken(subject))
+}
+
+func TestCallbackHandler(t *testing.T) {
+	proxyClient, server := newEpicGamesTestServer(testTokenExtras(testAccountID))
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithState(ctx, "d4e5f6")
+
+	expectedUser := &User{
+		AccountID:         testAccountID,
+		DisplayName:       "Jonesy",
+		PreferredLanguage: "en",
+	}
+	success := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "any-token", token.AccessToken)
+			assert.Equal(t, testAccountID, token.Extra("account_id"))
+		}
+		claims, err := oidc.IDTokenFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, testAccountID, claims.Subject)
+		}
+		user, err := UserFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, expectedUser, user)
+		}
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// CallbackHandler assert that:
+	// - the id_token is verified with Epic Games' keys
+	// - success handler is called
+	// - Epic Games Token, id_token Claims, and User (of the account_id) are
+	// added to the ctx of the success handler
+	callbackHandler := CallbackHandler(testConfig(), http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestCallbackHandler_NoIDToken(t *testing.T) {
+	proxyClient, server := newEpicGamesTestServer(fmt.Sprintf(`, "account_id": %q`, testAccountID))
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithState(ctx, "d4e5f6")
+
+	success := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		_, err := oidc.IDTokenFromContext(ctx)
+		assert.NotNil(t, err)
+		user, err := UserFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "Jonesy", user.DisplayName)
+		}
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// CallbackHandler with only the basic_profile scope (no id_token), assert
+	// that:
+	// - success handler is called with the User in the ctx
+	callbackHandler := CallbackHandler(testConfig(), http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestEpicGamesHandler_TokenErrors(t *testing.T) {
+	proxyClient, server := newEpicGamesTestServer("")
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+
+	cases := []struct {
+		name   string
+		extras map[string]interface{}
+		err    error
+	}{
+		{"missing account_id", map[string]interface{}{}, ErrMissingAccountID},
+		{"invalid id_token", map[string]interface{}{"account_id": testAccountID, "id_token": "not.a.jwt"}, oidc.ErrInvalidIDToken},
+		{"other id_token subject", map[string]interface{}{"account_id": testAccountID, "id_token": testIDToken("f0e1d2c3b4a5968778695a4b3c2d1e0f")}, ErrAccountIDMismatch},
+	}
+	for _, c := range cases {
+		token := (&oauth2.Token{AccessToken: "any-token"}).WithExtra(c.extras)
+		success := testutils.AssertSuccessNotCalled(t)
+		failure := func(w http.ResponseWriter, req *http.Request) {
+			assert.Equal(t, c.err, gologin.ErrorFromContext(req.Context()), c.name)
+			fmt.Fprintf(w, "failure handler called")
+		}
+
+		// EpicGamesHandler with an invalid Token response, assert that:
+		// - failure handler is called with the distinct error
+		epicGamesHandler := epicGamesHandler(testConfig(), success, http.HandlerFunc(failure))
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		epicGamesHandler.ServeHTTP(w, req.WithContext(oauth2Login.WithToken(ctx, token)))
+		assert.Equal(t, "failure handler called", w.Body.String(), c.name)
+	}
+}
+
+func TestEpicGamesHandler_MissingCtxToken(t *testing.T) {
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "oauth2: Context missing Token", err.Error())
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// EpicGamesHandler called without Token in ctx, assert that:
+	// - failure handler is called
+	// - error about ctx missing token is added to the failure handler ctx
+	epicGamesHandler := epicGamesHandler(testConfig(), success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	epicGamesHandler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestEpicGamesHandler_ErrorGettingUser(t *testing.T) {
+	proxyClient, server := newEpicGamesTestServer("")
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	token := (&oauth2.Token{AccessToken: "invalid-token"}).WithExtra(map[string]interface{}{"account_id": testAccountID})
+	ctx = oauth2Login.WithToken(ctx, token)
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.True(t, errors.Is(err, ErrUnableToGetEpicGamesUser))
+			var apiErr *APIError
+			if assert.True(t, errors.As(err, &apiErr)) {
+				assert.Equal(t, 1014, apiErr.NumericErrorCode)
+			}
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// EpicGamesHandler cannot get Epic Games User, assert that:
+	// - failure handler is called
+	// - error cannot get Epic Games User (and the APIError) added to the
+	// failure handler ctx
+	epicGamesHandler := epicGamesHandler(testConfig(), success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	epicGamesHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestValidateResponse(t *testing.T) {
+	validUser := &User{AccountID: testAccountID, DisplayName: "Jonesy"}
+	validResponse := &http.Response{StatusCode: 200}
+	invalidResponse := &http.Response{StatusCode: 500}
+	assert.Equal(t, nil, validateResponse(validUser, validResponse, nil))
+	assert.True(t, errors.Is(validateResponse(validUser, validResponse, fmt.Errorf("Server error")), ErrUnableToGetEpicGamesUser))
+	assert.True(t, errors.Is(validateResponse(validUser, invalidResponse, nil), ErrUnableToGetEpicGamesUser))
+	assert.True(t, errors.Is(validateResponse(nil, validResponse, nil), ErrUnableToGetEpicGamesUser))
+}
@@ -0,0 +1,88 @@
+package epicgames
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/dghubble/gologin/testutils"
+)
+
+const (
+	// testAccountID is the account_id of the test user.
+	testAccountID = "4b8e1f2a3c4d5e6f7a8b9c0d1e2f3a4b"
+	// testAccountsJSON is an accounts response.
+	testAccountsJSON = `[{"accountId": "4b8e1f2a3c4d5e6f7a8b9c0d1e2f3a4b", "displayName": "Jonesy", "preferredLanguage": "en", "linkedAccounts": [{"identityProviderId": "steam", "displayName": "jonesy_steam"}]}]`
+	// testInvalidTokenJSON is an Epic Games error response.
+	testInvalidTokenJSON = `{"errorCode": "errors.com.epicgames.common.authentication.token_verification_failed", "errorMessage": "Sorry the token you are using is not valid", "messageVars": [], "numericErrorCode": 1014, "originatingService": "com.epicgames.account.public", "intent": "prod"}`
+)
+
+// testEpicGamesKey signs test id_tokens and is served by
+// newEpicGamesTestServer.
+var testEpicGamesKey, _ = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+// testIDToken returns an id_token for the subject signed by the
+// testEpicGamesKey.
+func testIDToken(subject string) string {
+	claims := fmt.Sprintf(`{"iss":"https://api.epicgames.dev/epic/oauth/v2","aud":"client_id","sub":%q,"exp":%d,"iat":%d,"preferred_username":"Jonesy"}`,
+		subject, time.Now().Add(time.Hour).Unix(), time.Now().Unix())
+	signingInput := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"ES256","kid":"epic-key"}`)) + "." + base64.RawURLEncoding.EncodeToString([]byte(claims))
+	digest := sha256.Sum256([]byte(signingInput))
+	r, s, _ := ecdsa.Sign(rand.Reader, testEpicGamesKey, digest[:])
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// testJWKS returns the JSON Web Key Set of the testEpicGamesKey.
+func testJWKS() string {
+	x := base64.RawURLEncoding.EncodeToString(testEpicGamesKey.X.FillBytes(make([]byte, 32)))
+	y := base64.RawURLEncoding.EncodeToString(testEpicGamesKey.Y.FillBytes(make([]byte, 32)))
+	return fmt.Sprintf(`{"keys": [{"kty": "EC", "kid": "epic-key", "use": "sig", "crv": "P-256", "x": %q, "y": %q}]}`, x, y)
+}
+
+// newEpicGamesTestServer returns a new httptest.Server which mocks the Epic
+// Games token, keys, and accounts endpoints and a client which proxies
+// requests to the server. Like Epic Games, the token endpoint requires HTTP
+// Basic client authentication and responds with the given token extras
+// (JSON fields). The accounts endpoint responds with testAccountsJSON for the
+// testAccountID, or an error for tokens other than "any-token". The caller
+// must close the server.
+func newEpicGamesTestServer(tokenExtras string) (*http.Client, *httptest.Server) {
+	client, mux, server := testutils.TestServer()
+	mux.HandleFunc("/epic/oauth/v2/token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		username, password, ok := r.BasicAuth()
+		if !ok || username != "client_id" || password != "client_secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprintf(w, `{"errorCode": "errors.com.epicgames.oauth.invalid_client", "error": "invalid_client"}`)
+			return
+		}
+		fmt.Fprintf(w, `{"access_token": "any-token", "token_type": "bearer", "expires_in": 7200, "refresh_token": "any-refresh", "refresh_expires_in": 28800, "scope": "basic_profile openid"%s}`, tokenExtras)
+	})
+	mux.HandleFunc("/epic/oauth/v2/.well-known/jwks.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, testJWKS())
+	})
+	mux.HandleFunc("/epic/id/v2/accounts", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Header.Get("Authorization") != "Bearer any-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprintf(w, testInvalidTokenJSON)
+			return
+		}
+		if r.URL.Query().Get("accountId") != testAccountID {
+			fmt.Fprintf(w, `[]`)
+			return
+		}
+		fmt.Fprintf(w, testAccountsJSON)
+	})
+	return client, server
+}
@@ -0,0 +1,61 @@
+package epicgames
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/dghubble/gologin/internal/jsonclient"
+)
+
+const epicGamesAPI = "https://api.epicgames.dev/epic/"
+
+// User is an Epic Games account.
+type User struct {
+	AccountID         string `json:"accountId"`
+	DisplayName       string `json:"displayName"`
+	PreferredLanguage string `json:"preferredLanguage"`
+}
+
+// APIError is an Epic Games API error response.
+type APIError struct {
+	ErrorCode        string `json:"errorCode"`
+	ErrorMessage     string `json:"errorMessage"`
+	NumericErrorCode int    `json:"numericErrorCode"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("epicgames: %s (%s)", e.ErrorMessage, e.ErrorCode)
+}
+
+// client is an Epic Games client for obtaining a User.
+type client struct {
+	json *jsonclient.Client
+}
+
+// newClient returns a new Epic Games client.
+func newClient(httpClient *http.Client) *client {
+	return &client{
+		json: jsonclient.New(httpClient, epicGamesAPI),
+	}
+}
+
+// Account gets the Epic Games User with the account ID. If Epic Games
+// responds with an error, it is returned as an *APIError.
+// https://dev.epicgames.com/docs/web-api-ref/connect-web-api
+func (c *client) Account(ctx context.Context, accountID string) (*User, *http.Response, error) {
+	var users []User
+	apiErr := new(APIError)
+	params := url.Values{"accountId": {accountID}}
+	resp, err := c.json.Get(ctx, "id/v2/accounts", params, &users, apiErr)
+	if err == nil && apiErr.ErrorCode != "" {
+		err = apiErr
+	}
+	for _, user := range users {
+		if user.AccountID == accountID {
+			return &user, resp, err
+		}
+	}
+	return nil, resp, err
+}
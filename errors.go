@@ -1,20 +1,147 @@
 package gologin
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
 	"net/http"
+	"strings"
 )
 
-// DefaultFailureHandler responds with a 400 status code and message parsed
-// from the ctx.
+// Error is a provider error which preserves the underlying cause and the
+// provider's HTTP status code. Use errors.As to inspect it or errors.Is to
+// match its Kind (e.g. facebook.ErrUnableToGetFacebookUser) or cause.
+type Error struct {
+	// Provider is the provider name (e.g. "facebook").
+	Provider string
+	// Op is the operation which failed (e.g. "get user").
+	Op string
+	// StatusCode is the provider's HTTP response status code, if any.
+	StatusCode int
+	// Err is the underlying cause, if any.
+	Err error
+	// Kind is the provider sentinel error describing the failure, if any.
+	Kind error
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("%s: %s failed", e.Provider, e.Op)
+	if e.Kind != nil {
+		msg = e.Kind.Error()
+	}
+	if e.StatusCode != 0 {
+		msg = fmt.Sprintf("%s (status %d)", msg, e.StatusCode)
+	}
+	if e.Err != nil {
+		msg = fmt.Sprintf("%s: %v", msg, e.Err)
+	}
+	return msg
+}
+
+// Unwrap returns the underlying cause.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether the target is the Error's Kind so existing provider
+// sentinel errors continue to match.
+func (e *Error) Is(target error) bool {
+	return e.Kind != nil && e.Kind == target
+}
+
+// DefaultFailureHandler responds with the status code (see
+// StatusCodeFromContext, 400 if the failing handler set none) and message
+// parsed from the ctx. Requests which Accept application/json (rather than
+// text/html) receive a JSON error like JSONFailureHandler.
+//
+// The oauth2 and oauth1 handlers set status codes at each failure point:
+//
+//	400 Bad Request: missing, invalid, expired, or reused state; callbacks
+//	    missing a code, state, oauth token, verifier, or request secret
+//	200 OK: the user denied authorization (OAuth2 access_denied or OAuth1
+//	    denied), an expected outcome rather than an error
+//	502 Bad Gateway: token exchange, request token, or access token requests
+//	    fail and provider user requests fail (a gologin Error)
+//	500 Internal Server Error: states or request secrets cannot be generated
+//	    or stored, or login handlers are missing their ctx state or request
+//	    token (a misconfigured handler chain)
+//	405, 413, 415: token handlers and form_post callbacks received an
+//	    unexpected method, an oversized body, or another content type (see
+//	    BodyHandler)
+//
+// Provider handlers respond 403 Forbidden when they reject a user by policy
+// (e.g. a google hosted domain or slack team mismatch) and 502 Bad Gateway
+// when the provider's token response lacks required fields.
 var DefaultFailureHandler = http.HandlerFunc(failureHandler)
 
+// JSONFailureHandler responds with the error from the ctx as JSON, e.g.
+// {"error":"facebook: unable to get Facebook User"}, and the ctx status code
+// (see StatusCodeFromContext).
+var JSONFailureHandler = FailureHandlerFunc(jsonFailureHandler)
+
+// FailureHandler is a failure http.Handler which also accepts the error
+// explicitly.
+type FailureHandler interface {
+	http.Handler
+	ServeError(ctx context.Context, w http.ResponseWriter, req *http.Request, err error)
+}
+
+// FailureHandlerFunc is an adapter to allow an ordinary function which
+// receives the error to be used as a failure handler. Handlers which call
+// the failure handler with the error in the ctx (all gologin handlers) invoke
+// the func with that error.
+type FailureHandlerFunc func(ctx context.Context, w http.ResponseWriter, req *http.Request, err error)
+
+// ServeHTTP calls f with the error from the ctx.
+func (f FailureHandlerFunc) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	f(ctx, w, req, ErrorFromContext(ctx))
+}
+
+// ServeError calls f(ctx, w, req, err).
+func (f FailureHandlerFunc) ServeError(ctx context.Context, w http.ResponseWriter, req *http.Request, err error) {
+	f(ctx, w, req, err)
+}
+
 func failureHandler(w http.ResponseWriter, req *http.Request) {
 	ctx := req.Context()
 	err := ErrorFromContext(ctx)
+	if acceptsJSON(req) {
+		jsonFailureHandler(ctx, w, req, err)
+		return
+	}
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		http.Error(w, err.Error(), StatusCodeFromContext(ctx))
 		return
 	}
 	// should be unreachable, ErrorFromContext always returns some non-nil error
-	http.Error(w, "", http.StatusBadRequest)
+	http.Error(w, "", StatusCodeFromContext(ctx))
+}
+
+func jsonFailureHandler(ctx context.Context, w http.ResponseWriter, req *http.Request, err error) {
+	// the err may be passed explicitly rather than from the ctx
+	status := StatusCodeFromContext(WithError(ctx, err))
+	message := ""
+	if err != nil {
+		message = err.Error()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
+
+// acceptsJSON returns true if the request Accept header lists
+// application/json before any text/html.
+func acceptsJSON(req *http.Request) bool {
+	for _, mediaRange := range strings.Split(req.Header.Get("Accept"), ",") {
+		mediaType := strings.TrimSpace(strings.SplitN(mediaRange, ";", 2)[0])
+		switch strings.ToLower(mediaType) {
+		case "application/json":
+			return true
+		case "text/html":
+			return false
+		}
+	}
+	return false
 }
@@ -2,6 +2,7 @@ package gologin
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -21,3 +22,134 @@ func TestDefaultFailureHandler(t *testing.T) {
 	// assert that error message was passed through
 	assert.Equal(t, expectedError.Error()+"\n", w.Body.String())
 }
+
+func TestDefaultFailureHandler_ContentNegotiation(t *testing.T) {
+	expectedError := fmt.Errorf("facebook: unable to get Facebook User")
+	cases := []struct {
+		accept      string
+		contentType string
+		body        string
+	}{
+		{"application/json", "application/json", `{"error":"facebook: unable to get Facebook User"}` + "\n"},
+		{"application/json;q=0.9, */*", "application/json", `{"error":"facebook: unable to get Facebook User"}` + "\n"},
+		{"text/html,application/xhtml+xml,application/json;q=0.8", "text/plain; charset=utf-8", expectedError.Error() + "\n"},
+		{"text/html", "text/plain; charset=utf-8", expectedError.Error() + "\n"},
+		{"", "text/plain; charset=utf-8", expectedError.Error() + "\n"},
+	}
+	for _, c := range cases {
+		ctx := WithError(context.Background(), expectedError)
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept", c.accept)
+		w := httptest.NewRecorder()
+		DefaultFailureHandler.ServeHTTP(w, req.WithContext(ctx))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, c.contentType, w.Header().Get("Content-Type"), c.accept)
+		assert.Equal(t, c.body, w.Body.String())
+	}
+}
+
+// statusError is an error with an HTTP status code.
+type statusError struct{}
+
+func (statusError) Error() string   { return "forbidden" }
+func (statusError) StatusCode() int { return http.StatusForbidden }
+
+func TestDefaultFailureHandler_StatusCode(t *testing.T) {
+	providerErr := &Error{Provider: "facebook", Op: "get user", StatusCode: 500}
+	cases := []struct {
+		ctx    context.Context
+		accept string
+		status int
+	}{
+		// status code set by the failing handler
+		{WithStatusCode(WithError(context.Background(), errors.New("state expired")), http.StatusBadRequest), "", http.StatusBadRequest},
+		{WithStatusCode(WithError(context.Background(), errors.New("access denied")), http.StatusOK), "", http.StatusOK},
+		{WithStatusCode(WithError(context.Background(), errors.New("exchange failed")), http.StatusBadGateway), "application/json", http.StatusBadGateway},
+		// status code set by the failing handler takes precedence
+		{WithStatusCode(WithError(context.Background(), statusError{}), http.StatusInternalServerError), "", http.StatusInternalServerError},
+		// inferred from the error
+		{WithError(context.Background(), statusError{}), "", http.StatusForbidden},
+		{WithError(context.Background(), providerErr), "", http.StatusBadGateway},
+		{WithError(context.Background(), fmt.Errorf("wrapped: %w", providerErr)), "application/json", http.StatusBadGateway},
+		// falls back to 400
+		{WithError(context.Background(), errors.New("some error")), "", http.StatusBadRequest},
+		{context.Background(), "", http.StatusBadRequest},
+	}
+	for _, c := range cases {
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept", c.accept)
+		w := httptest.NewRecorder()
+		DefaultFailureHandler.ServeHTTP(w, req.WithContext(c.ctx))
+		assert.Equal(t, c.status, w.Code)
+		assert.Equal(t, c.status, StatusCodeFromContext(c.ctx))
+		// the error message is still written
+		assert.Contains(t, w.Body.String(), ErrorFromContext(c.ctx).Error())
+	}
+}
+
+func TestJSONFailureHandler(t *testing.T) {
+	cases := []struct {
+		err    error
+		status int
+		body   string
+	}{
+		{fmt.Errorf("some error"), http.StatusBadRequest, `{"error":"some error"}`},
+		{statusError{}, http.StatusForbidden, `{"error":"forbidden"}`},
+	}
+	for _, c := range cases {
+		ctx := WithError(context.Background(), c.err)
+		req, _ := http.NewRequest("GET", "/", nil)
+		w := httptest.NewRecorder()
+		JSONFailureHandler.ServeHTTP(w, req.WithContext(ctx))
+		assert.Equal(t, c.status, w.Code)
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+		assert.JSONEq(t, c.body, w.Body.String())
+	}
+}
+
+func TestFailureHandlerFunc(t *testing.T) {
+	expectedError := fmt.Errorf("some error")
+	var handler FailureHandler = FailureHandlerFunc(func(ctx context.Context, w http.ResponseWriter, req *http.Request, err error) {
+		assert.Equal(t, expectedError, err)
+		fmt.Fprintf(w, "failure handler called")
+	})
+
+	// ServeHTTP passes the ctx error
+	ctx := WithError(context.Background(), expectedError)
+	req, _ := http.NewRequest("GET", "/", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+
+	// ServeError passes the error explicitly
+	w = httptest.NewRecorder()
+	handler.ServeError(context.Background(), w, req, expectedError)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestError(t *testing.T) {
+	errKind := errors.New("provider: unable to get User")
+	cause := errors.New("connection reset")
+	cases := []struct {
+		err     *Error
+		message string
+	}{
+		{&Error{Provider: "provider", Op: "get user"}, "provider: get user failed"},
+		{&Error{Provider: "provider", Op: "get user", Kind: errKind}, "provider: unable to get User"},
+		{&Error{Provider: "provider", Op: "get user", Kind: errKind, StatusCode: 500}, "provider: unable to get User (status 500)"},
+		{&Error{Provider: "provider", Op: "get user", Kind: errKind, Err: cause}, "provider: unable to get User: connection reset"},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.message, c.err.Error())
+	}
+
+	// errors.Is matches the Kind and the cause
+	var err error = &Error{Provider: "provider", Op: "get user", Kind: errKind, Err: cause}
+	assert.True(t, errors.Is(err, errKind))
+	assert.True(t, errors.Is(err, cause))
+	assert.False(t, errors.Is(err, errors.New("other")))
+	// errors.As reaches the Error through wrapping
+	var providerErr *Error
+	assert.True(t, errors.As(fmt.Errorf("wrapped: %w", err), &providerErr))
+	assert.Equal(t, "provider", providerErr.Provider)
+}
@@ -0,0 +1,48 @@
+package eventbrite
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/dghubble/gologin"
+)
+
+// unexported key type prevents collisions
+type key int
+
+const (
+	userKey key = iota
+)
+
+// WithUser returns a copy of ctx that stores the Eventbrite User and its gologin
+// Profile.
+func WithUser(ctx context.Context, user *User) context.Context {
+	ctx = gologin.WithProfile(ctx, newProfile(user))
+	return context.WithValue(ctx, userKey, user)
+}
+
+// UserFromContext returns the Eventbrite User from the ctx.
+func UserFromContext(ctx context.Context) (*User, error) {
+	user, ok := ctx.Value(userKey).(*User)
+	if !ok {
+		return nil, fmt.Errorf("eventbrite: Context missing Eventbrite User")
+	}
+	return user, nil
+}
+
+// newProfile returns the gologin Profile of the Eventbrite User. Email is the
+// primary verified email.
+func newProfile(user *User) *gologin.Profile {
+	if user == nil {
+		return nil
+	}
+	return &gologin.Profile{
+		Provider:      ProviderName,
+		ID:            user.ID,
+		Email:         user.Email,
+		EmailVerified: user.Email != "",
+		Name:          gologin.DisplayName(user.Name, strings.TrimSpace(user.FirstName+" "+user.LastName)),
+		Raw:           gologin.ProfileRaw(user, "id", "name"),
+	}
+}
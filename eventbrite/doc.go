@@ -0,0 +1,2 @@
+// Package eventbrite provides Eventbrite OAuth2 login and callback handlers.
+package eventbrite
@@ -0,0 +1,159 @@
+package eventbrite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/internal/jsonclient"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func testConfig() *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     "client_id",
+		ClientSecret: "client_secret",
+		RedirectURL:  "https://example.com/eventbrite/callback",
+		Endpoint:     Endpoint,
+	}
+}
+
+func TestCallbackHandler(t *testing.T) {
+	proxyClient, server := newEventbriteTestServer(testUserJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithState(ctx, "d4e5f6")
+
+	expectedUser := &User{
+		ID:        "1234567890",
+		Name:      "Ada Lovelace",
+		FirstName: "Ada",
+		LastName:  "Lovelace",
+		ImageID:   "98765432",
+		Emails: []Email{
+			{Email: "ada@old.example.com", Verified: true},
+			{Email: "ada@example.com", Verified: true, Primary: true},
+		},
+		Email: "ada@example.com",
+	}
+	success := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "any-token", token.AccessToken)
+		}
+		user, err := UserFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, expectedUser, user)
+		}
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// CallbackHandler assert that:
+	// - the User is requested from users/me/ (with the trailing slash)
+	// - success handler is called
+	// - Eventbrite Token and User (with the primary verified Email) are added
+	// to the ctx of the success handler
+	callbackHandler := CallbackHandler(testConfig(), http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestTrailingSlash(t *testing.T) {
+	proxyClient, server := newEventbriteTestServer(testUserJSON)
+	defer server.Close()
+
+	// Eventbrite API paths without a trailing slash are not found
+	apiErr := new(APIError)
+	resp, err := jsonclient.New(proxyClient, eventbriteAPI).Get(context.Background(), "users/me", nil, nil, apiErr)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Equal(t, "NOT_FOUND", apiErr.Code)
+}
+
+func TestPrimaryEmail(t *testing.T) {
+	cases := []struct {
+		emails   []Email
+		expected string
+	}{
+		{nil, ""},
+		{[]Email{{Email: "ada@example.com", Verified: true, Primary: true}}, "ada@example.com"},
+		{[]Email{{Email: "ada@example.com", Verified: false, Primary: true}}, ""},
+		{[]Email{{Email: "ada@old.example.com", Verified: true}, {Email: "ada@example.com", Verified: true, Primary: true}}, "ada@example.com"},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.expected, primaryEmail(c.emails))
+	}
+}
+
+func TestEventbriteHandler_MissingCtxToken(t *testing.T) {
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "oauth2: Context missing Token", err.Error())
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// EventbriteHandler called without Token in ctx, assert that:
+	// - failure handler is called
+	// - error about ctx missing token is added to the failure handler ctx
+	eventbriteHandler := eventbriteHandler(testConfig(), success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	eventbriteHandler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestEventbriteHandler_ErrorGettingUser(t *testing.T) {
+	proxyClient, server := newEventbriteTestServer(testUserJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "invalid-token"})
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.True(t, errors.Is(err, ErrUnableToGetEventbriteUser))
+			var apiErr *APIError
+			if assert.True(t, errors.As(err, &apiErr)) {
+				assert.Equal(t, &APIError{Code: "INVALID_AUTH", Description: "The OAuth token you provided was invalid.", StatusCode: 401}, apiErr)
+			}
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// EventbriteHandler cannot get Eventbrite User, assert that:
+	// - failure handler is called
+	// - error cannot get Eventbrite User (and the APIError) added to the
+	// failure handler ctx
+	eventbriteHandler := eventbriteHandler(testConfig(), success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	eventbriteHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestValidateResponse(t *testing.T) {
+	validUser := &User{ID: "1234567890", Name: "Ada Lovelace"}
+	validResponse := &http.Response{StatusCode: 200}
+	invalidResponse := &http.Response{StatusCode: 500}
+	assert.Equal(t, nil, validateResponse(validUser, validResponse, nil))
+	assert.True(t, errors.Is(validateResponse(validUser, validResponse, fmt.Errorf("Server error")), ErrUnableToGetEventbriteUser))
+	assert.True(t, errors.Is(validateResponse(validUser, invalidResponse, nil), ErrUnableToGetEventbriteUser))
+	assert.True(t, errors.Is(validateResponse(&User{Name: "Ada Lovelace"}, validResponse, nil), ErrUnableToGetEventbriteUser))
+}
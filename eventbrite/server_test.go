@@ -0,0 +1,53 @@
+package eventbrite
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/dghubble/gologin/testutils"
+)
+
+const (
+	// testUserJSON is a users/me response with a primary verified email.
+	testUserJSON = `{"emails": [{"email": "ada@old.example.com", "verified": true, "primary": false}, {"email": "ada@example.com", "verified": true, "primary": true}], "id": "1234567890", "name": "Ada Lovelace", "first_name": "Ada", "last_name": "Lovelace", "is_public": false, "image_id": "98765432"}`
+	// testInvalidTokenJSON is an Eventbrite error response.
+	testInvalidTokenJSON = `{"status_code": 401, "error_description": "The OAuth token you provided was invalid.", "error": "INVALID_AUTH"}`
+	// testNotFoundJSON is the Eventbrite response to API paths without a
+	// trailing slash.
+	testNotFoundJSON = `{"status_code": 404, "error_description": "The path you requested does not exist.", "error": "NOT_FOUND"}`
+)
+
+// newEventbriteTestServer returns a new httptest.Server which mocks the
+// Eventbrite token and users/me/ endpoints and a client which proxies
+// requests to the server. Like Eventbrite, users/me (without a trailing
+// slash) is not found. The users/me/ endpoint responds with the given json
+// data, or an INVALID_AUTH error for tokens other than "any-token". The
+// caller must close the server.
+func newEventbriteTestServer(jsonData string) (*http.Client, *httptest.Server) {
+	client, mux, server := testutils.TestServer()
+	mux.HandleFunc("/oauth/token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token": "any-token", "token_type": "bearer"}`)
+	})
+	mux.HandleFunc("/v3/users/me", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, testNotFoundJSON)
+	})
+	mux.HandleFunc("/v3/users/me/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path != "/v3/users/me/" {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(w, testNotFoundJSON)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer any-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprintf(w, testInvalidTokenJSON)
+			return
+		}
+		fmt.Fprintf(w, jsonData)
+	})
+	return client, server
+}
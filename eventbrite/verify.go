@@ -0,0 +1,79 @@
+package eventbrite
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/dghubble/gologin/internal/jsonclient"
+)
+
+// eventbriteAPI is the Eventbrite API base URL. Eventbrite API paths require
+// a trailing slash (e.g. "users/me/"), otherwise Eventbrite responds 404.
+const eventbriteAPI = "https://www.eventbriteapi.com/v3/"
+
+// User is an Eventbrite user.
+type User struct {
+	ID        string  `json:"id"`
+	Name      string  `json:"name"`
+	FirstName string  `json:"first_name"`
+	LastName  string  `json:"last_name"`
+	ImageID   string  `json:"image_id"`
+	Emails    []Email `json:"emails"`
+	// Email is the primary verified email address, from the Emails
+	Email string `json:"-"`
+}
+
+// Email is an Eventbrite user email address.
+type Email struct {
+	Email    string `json:"email"`
+	Verified bool   `json:"verified"`
+	Primary  bool   `json:"primary"`
+}
+
+// APIError is an Eventbrite API error response.
+type APIError struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+	StatusCode  int    `json:"status_code"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("eventbrite: %s: %s (status %d)", e.Code, e.Description, e.StatusCode)
+}
+
+// client is an Eventbrite client for obtaining the current User.
+type client struct {
+	json *jsonclient.Client
+}
+
+// newClient returns a new Eventbrite client.
+func newClient(httpClient *http.Client) *client {
+	return &client{
+		json: jsonclient.New(httpClient, eventbriteAPI),
+	}
+}
+
+// Me returns the current Eventbrite User. If Eventbrite responds with an
+// error, it is returned as an *APIError.
+// https://www.eventbrite.com/platform/api#/reference/user/retrieve-your-user/retrieve-your-user
+func (c *client) Me(ctx context.Context) (*User, *http.Response, error) {
+	user := new(User)
+	apiErr := new(APIError)
+	resp, err := c.json.Get(ctx, "users/me/", nil, user, apiErr)
+	if err == nil && apiErr.Code != "" {
+		err = apiErr
+	}
+	user.Email = primaryEmail(user.Emails)
+	return user, resp, err
+}
+
+// primaryEmail returns the primary verified email address, if any.
+func primaryEmail(emails []Email) string {
+	for _, email := range emails {
+		if email.Primary && email.Verified {
+			return email.Email
+		}
+	}
+	return ""
+}
@@ -61,7 +61,7 @@ func issueSession() http.Handler {
 		}
 		// 2. Implement a success handler to issue some form of session
 		session := sessionStore.New(sessionName)
-		session.Values[sessionUserKey] = *githubUser.ID
+		session.Values[sessionUserKey] = githubUser.ID
 		session.Save(w)
 		http.Redirect(w, req, "/profile", http.StatusFound)
 	}
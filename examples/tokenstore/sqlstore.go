@@ -0,0 +1,72 @@
+// Package tokenstore shows an oauth2.TokenStore which persists provider
+// Tokens in a SQL database with database/sql.
+package tokenstore
+
+import (
+	"context"
+	"database/sql"
+
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"golang.org/x/oauth2"
+)
+
+// Schema creates the tokens table (SQLite and PostgreSQL syntax).
+const Schema = `CREATE TABLE IF NOT EXISTS oauth2_tokens (
+	provider      TEXT NOT NULL,
+	user_id       TEXT NOT NULL,
+	access_token  TEXT NOT NULL,
+	token_type    TEXT NOT NULL,
+	refresh_token TEXT NOT NULL,
+	expiry        TIMESTAMP,
+	PRIMARY KEY (provider, user_id)
+)`
+
+// upsertToken replaces the stored Token, keeping the stored refresh token
+// when the new Token has none.
+const upsertToken = `INSERT INTO oauth2_tokens (provider, user_id, access_token, token_type, refresh_token, expiry)
+VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (provider, user_id) DO UPDATE SET
+	access_token = excluded.access_token,
+	token_type = excluded.token_type,
+	refresh_token = CASE WHEN excluded.refresh_token = '' THEN oauth2_tokens.refresh_token ELSE excluded.refresh_token END,
+	expiry = excluded.expiry`
+
+const selectToken = `SELECT access_token, token_type, refresh_token, expiry FROM oauth2_tokens
+WHERE provider = $1 AND user_id = $2`
+
+// SQLTokenStore is an oauth2.TokenStore backed by a SQL database.
+type SQLTokenStore struct {
+	db *sql.DB
+}
+
+var _ oauth2Login.TokenStore = (*SQLTokenStore)(nil)
+
+// NewSQLTokenStore returns a SQLTokenStore which uses the db, which must have
+// the Schema table.
+func NewSQLTokenStore(db *sql.DB) *SQLTokenStore {
+	return &SQLTokenStore{db: db}
+}
+
+// Save upserts the Token of the provider user.
+func (s *SQLTokenStore) Save(ctx context.Context, provider, userID string, token *oauth2.Token) error {
+	var expiry interface{}
+	if !token.Expiry.IsZero() {
+		expiry = token.Expiry.UTC()
+	}
+	_, err := s.db.ExecContext(ctx, upsertToken, provider, userID, token.AccessToken, token.TokenType, token.RefreshToken, expiry)
+	return err
+}
+
+// Token returns the stored Token of the provider user, or sql.ErrNoRows.
+func (s *SQLTokenStore) Token(ctx context.Context, provider, userID string) (*oauth2.Token, error) {
+	token := new(oauth2.Token)
+	var expiry sql.NullTime
+	err := s.db.QueryRowContext(ctx, selectToken, provider, userID).Scan(&token.AccessToken, &token.TokenType, &token.RefreshToken, &expiry)
+	if err != nil {
+		return nil, err
+	}
+	if expiry.Valid {
+		token.Expiry = expiry.Time
+	}
+	return token, nil
+}
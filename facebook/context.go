@@ -3,6 +3,8 @@ package facebook
 import (
 	"context"
 	"fmt"
+
+	"github.com/dghubble/gologin"
 )
 
 // unexported key type prevents collisions
@@ -10,10 +12,15 @@ type key int
 
 const (
 	userKey key = iota
+	tokenExchangeErrorKey
+	permissionsKey
+	signedRequestKey
 )
 
-// WithUser returns a copy of ctx that stores the Facebook User.
+// WithUser returns a copy of ctx that stores the Facebook User and its gologin
+// Profile.
 func WithUser(ctx context.Context, user *User) context.Context {
+	ctx = gologin.WithProfile(ctx, newProfile(user))
 	return context.WithValue(ctx, userKey, user)
 }
 
@@ -25,3 +32,60 @@ func UserFromContext(ctx context.Context) (*User, error) {
 	}
 	return user, nil
 }
+
+// newProfile returns the gologin Profile of the Facebook User.
+func newProfile(user *User) *gologin.Profile {
+	if user == nil {
+		return nil
+	}
+	return &gologin.Profile{
+		Provider:  ProviderName,
+		ID:        user.ID,
+		Email:     user.Email,
+		Name:      user.Name,
+		AvatarURL: user.Picture.Data.URL,
+		Raw:       gologin.ProfileRaw(user, "id", "email", "name", "picture"),
+	}
+}
+
+// WithTokenExchangeError returns a copy of ctx that stores the error of a
+// failed long-lived Token exchange.
+func WithTokenExchangeError(ctx context.Context, err error) context.Context {
+	return context.WithValue(ctx, tokenExchangeErrorKey, err)
+}
+
+// TokenExchangeErrorFromContext returns the error of a failed long-lived
+// Token exchange from the ctx or nil if the exchange succeeded (or was not
+// attempted).
+func TokenExchangeErrorFromContext(ctx context.Context) error {
+	err, _ := ctx.Value(tokenExchangeErrorKey).(error)
+	return err
+}
+
+// WithPermissions returns a copy of ctx that stores the Facebook Permissions.
+func WithPermissions(ctx context.Context, permissions Permissions) context.Context {
+	return context.WithValue(ctx, permissionsKey, permissions)
+}
+
+// PermissionsFromContext returns the Facebook Permissions from the ctx.
+func PermissionsFromContext(ctx context.Context) (Permissions, error) {
+	permissions, ok := ctx.Value(permissionsKey).(Permissions)
+	if !ok {
+		return nil, fmt.Errorf("facebook: Context missing Facebook Permissions")
+	}
+	return permissions, nil
+}
+
+// WithSignedRequest returns a copy of ctx that stores the SignedRequest.
+func WithSignedRequest(ctx context.Context, signedRequest *SignedRequest) context.Context {
+	return context.WithValue(ctx, signedRequestKey, signedRequest)
+}
+
+// SignedRequestFromContext returns the SignedRequest from the ctx.
+func SignedRequestFromContext(ctx context.Context) (*SignedRequest, error) {
+	signedRequest, ok := ctx.Value(signedRequestKey).(*SignedRequest)
+	if !ok {
+		return nil, fmt.Errorf("facebook: Context missing SignedRequest")
+	}
+	return signedRequest, nil
+}
@@ -0,0 +1,108 @@
+package facebook
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dghubble/gologin"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+// recordingHooks returns Hooks which record the sequence of events.
+func recordingHooks(events *[]string) *gologin.Hooks {
+	return &gologin.Hooks{
+		OnLoginRedirect: func(ctx context.Context, provider string, req *http.Request) {
+			*events = append(*events, "redirect "+provider)
+		},
+		OnTokenExchange: func(ctx context.Context, provider string, duration time.Duration, err error) {
+			*events = append(*events, fmt.Sprintf("exchange %s %t", provider, err == nil))
+		},
+		OnUserFetch: func(ctx context.Context, provider string, duration time.Duration, err error) {
+			*events = append(*events, fmt.Sprintf("userfetch %s %t", provider, err == nil))
+		},
+		OnCallbackSuccess: func(ctx context.Context, provider, userID string) {
+			*events = append(*events, fmt.Sprintf("success %s %s", provider, userID))
+		},
+		OnCallbackFailure: func(ctx context.Context, provider string, err error) {
+			*events = append(*events, fmt.Sprintf("failure %s %v", provider, err))
+		},
+	}
+}
+
+func TestHooks(t *testing.T) {
+	proxyClient, mux, server := testutils.TestServer()
+	defer server.Close()
+	mux.HandleFunc("/v2.9/oauth/access_token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token": "any-token", "token_type": "bearer", "expires_in": 5183944}`)
+	})
+	mux.HandleFunc("/v2.9/me", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"id": "54638001", "name": "Ivy Crimson"}`)
+	})
+	config := &oauth2.Config{
+		ClientID:     "client_id",
+		ClientSecret: "client_secret",
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  "https://www.facebook.com/dialog/oauth",
+			TokenURL: "https://graph.facebook.com/v2.9/oauth/access_token",
+		},
+	}
+	var events []string
+	success := func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(w, "failure handler called")
+	}
+	loginHandler := gologin.HooksHandler(recordingHooks(&events), LoginHandler(config, nil))
+	callbackHandler := gologin.HooksHandler(recordingHooks(&events), CallbackHandler(config, http.HandlerFunc(success), http.HandlerFunc(failure)))
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithState(ctx, "d4e5f6")
+
+	// Hooks on the happy path, assert that:
+	// - the redirect, token exchange, user fetch, and success with the
+	// Facebook User ID are reported as "facebook"
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	loginHandler.ServeHTTP(w, req.WithContext(ctx))
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+	assert.Equal(t, []string{"redirect facebook", "exchange facebook true", "userfetch facebook true", "success facebook 54638001"}, events)
+
+	// Hooks on the failure path, assert that:
+	// - the failure and its error are reported
+	events = nil
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/?code=any_code&state=other", nil)
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+	assert.Equal(t, []string{"failure facebook " + oauth2Login.ErrInvalidState.Error()}, events)
+
+	// - a failed token exchange is reported before the failure
+	events = nil
+	mux.HandleFunc("/v2.9/oauth/access_token/fail", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "server error", http.StatusInternalServerError)
+	})
+	failConfig := *config
+	failConfig.Endpoint.TokenURL = "https://graph.facebook.com/v2.9/oauth/access_token/fail"
+	callbackHandler = gologin.HooksHandler(recordingHooks(&events), CallbackHandler(&failConfig, http.HandlerFunc(success), http.HandlerFunc(failure)))
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+	if assert.Len(t, events, 2) {
+		assert.Equal(t, "exchange facebook false", events[0])
+		assert.Contains(t, events[1], "failure facebook")
+	}
+}
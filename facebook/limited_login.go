@@ -0,0 +1,80 @@
+package facebook
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/oidc"
+	"golang.org/x/oauth2"
+)
+
+const (
+	facebookJWKSURL = "https://www.facebook.com/.well-known/oauth/openid/jwks/"
+	facebookIssuer  = "https://www.facebook.com"
+	idTokenField    = "id_token"
+	nonceField      = "nonce"
+)
+
+// Facebook Limited Login errors
+var (
+	ErrMissingIDToken = errors.New("facebook: missing id_token field")
+	ErrInvalidNonce   = errors.New("facebook: id_token nonce does not match")
+)
+
+// LimitedLoginTokenHandler receives a Facebook Limited Login id_token and the
+// nonce the client used to obtain it as POSTed "id_token" and "nonce" fields.
+// The id_token is verified against Facebook's JSON Web Key Set, issuer,
+// expiry, and the config ClientID (app ID) audience, and its nonce claim must
+// match. If successful, the Claims (see oidc IDTokenFromContext) and a User
+// built from them are added to the ctx and the success handler is called.
+// Otherwise, the failure handler is called.
+//
+// Expired tokens, other audiences, and unknown keys are reported as oidc
+// ErrIDTokenExpired, ErrInvalidAudience, and ErrUnknownKey. Limited Login
+// tokens cannot call the Graph API, so no Token is added to the ctx. POSTs
+// are limited by gologin.BodyHandler defaults.
+func LimitedLoginTokenHandler(config *oauth2.Config, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	verifier := oidc.NewIDTokenVerifier(facebookJWKSURL, config.ClientID, facebookIssuer)
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		rawIDToken := req.PostFormValue(idTokenField)
+		if rawIDToken == "" {
+			ctx = gologin.WithError(ctx, ErrMissingIDToken)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		claims, err := verifier.Verify(ctx, rawIDToken)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		if claims.Nonce == "" || claims.Nonce != req.PostFormValue(nonceField) {
+			ctx = gologin.WithError(ctx, ErrInvalidNonce)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		ctx = oidc.WithIDToken(ctx, claims)
+		ctx = WithUser(ctx, userFromClaims(claims))
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return gologin.BodyHandler(gologin.BodyConfig{}, http.HandlerFunc(fn), failure)
+}
+
+// userFromClaims returns the User described by Limited Login id_token Claims.
+// Limited Login ids are app-scoped, like Graph API user ids.
+func userFromClaims(claims *oidc.Claims) *User {
+	user := &User{
+		ID:    claims.Subject,
+		Name:  claims.Name,
+		Email: claims.Email,
+	}
+	user.FirstName, _ = claims.Extra["given_name"].(string)
+	user.LastName, _ = claims.Extra["family_name"].(string)
+	user.Picture.Data.URL, _ = claims.Extra["picture"].(string)
+	return user
+}
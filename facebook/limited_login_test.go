@@ -0,0 +1,138 @@
+package facebook
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/oidc"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+const testAppID = "app_id"
+
+// testKey signs test id_tokens and is served by newLimitedLoginServer.
+var testKey, _ = rsa.GenerateKey(rand.Reader, 2048)
+
+// testIDToken returns an id_token with the given JSON claims signed by the
+// testKey with the key ID.
+func testIDToken(kid, claims string) string {
+	header := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"alg":"RS256","kid":%q}`, kid)))
+	payload := base64.RawURLEncoding.EncodeToString([]byte(claims))
+	digest := sha256.Sum256([]byte(header + "." + payload))
+	signature, _ := rsa.SignPKCS1v15(rand.Reader, testKey, crypto.SHA256, digest[:])
+	return header + "." + payload + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// testLimitedLoginClaims returns Limited Login id_token JSON claims.
+func testLimitedLoginClaims(aud string, exp time.Time) string {
+	return fmt.Sprintf(`{"iss":"https://www.facebook.com","sub":"54638001","aud":%q,"exp":%d,"nonce":"client-nonce",
+		"name":"Ivy Crimson","given_name":"Ivy","family_name":"Crimson","email":"ivy@harvard.edu","picture":"https://example.com/ivy.jpg"}`, aud, exp.Unix())
+}
+
+// newLimitedLoginServer returns a new httptest.Server which mocks Facebook's
+// JSON Web Key Set endpoint and a client which proxies requests to the
+// server. The caller must close the server.
+func newLimitedLoginServer() (*http.Client, *httptest.Server) {
+	client, mux, server := testutils.TestServer()
+	mux.HandleFunc("/.well-known/oauth/openid/jwks/", func(w http.ResponseWriter, r *http.Request) {
+		n := base64.RawURLEncoding.EncodeToString(testKey.N.Bytes())
+		e := base64.RawURLEncoding.EncodeToString(big.NewInt(int64(testKey.E)).Bytes())
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"keys": [{"kty": "RSA", "kid": "test-key", "use": "sig", "alg": "RS256", "n": %q, "e": %q}]}`, n, e)
+	})
+	return client, server
+}
+
+// postIDToken serves a POST of the id_token and nonce fields.
+func postIDToken(handler http.Handler, ctx context.Context, idToken, nonce string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	form := url.Values{"id_token": {idToken}, "nonce": {nonce}}
+	req, _ := http.NewRequest("POST", "/", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	handler.ServeHTTP(w, req.WithContext(ctx))
+	return w
+}
+
+func TestLimitedLoginTokenHandler(t *testing.T) {
+	proxyClient, server := newLimitedLoginServer()
+	defer server.Close()
+	// the verifier fetches Facebook's keys with the proxy client
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	idToken := testIDToken("test-key", testLimitedLoginClaims(testAppID, time.Now().Add(time.Hour)))
+
+	config := &oauth2.Config{ClientID: testAppID}
+	success := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		user, err := UserFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "54638001", user.ID)
+			assert.Equal(t, "Ivy Crimson", user.Name)
+			assert.Equal(t, "Ivy", user.FirstName)
+			assert.Equal(t, "Crimson", user.LastName)
+			assert.Equal(t, "ivy@harvard.edu", user.Email)
+			assert.Equal(t, "https://example.com/ivy.jpg", user.Picture.Data.URL)
+		}
+		claims, err := oidc.IDTokenFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, idToken, claims.RawIDToken)
+		}
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// LimitedLoginTokenHandler assert that:
+	// - the id_token is verified with Facebook's keys
+	// - a User is built from the claims and added to the ctx
+	// - success handler is called
+	w := postIDToken(LimitedLoginTokenHandler(config, http.HandlerFunc(success), failure), ctx, idToken, "client-nonce")
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestLimitedLoginTokenHandler_Errors(t *testing.T) {
+	proxyClient, server := newLimitedLoginServer()
+	defer server.Close()
+	// the verifier fetches Facebook's keys with the proxy client
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	valid := testLimitedLoginClaims(testAppID, time.Now().Add(time.Hour))
+	cases := []struct {
+		name    string
+		idToken string
+		nonce   string
+		err     error
+	}{
+		{"missing", "", "client-nonce", ErrMissingIDToken},
+		{"expired", testIDToken("test-key", testLimitedLoginClaims(testAppID, time.Now().Add(-time.Minute))), "client-nonce", oidc.ErrIDTokenExpired},
+		{"other audience", testIDToken("test-key", testLimitedLoginClaims("other_app", time.Now().Add(time.Hour))), "client-nonce", oidc.ErrInvalidAudience},
+		{"unknown kid", testIDToken("other-key", valid), "client-nonce", oidc.ErrUnknownKey},
+		{"nonce mismatch", testIDToken("test-key", valid), "other-nonce", ErrInvalidNonce},
+		{"missing nonce", testIDToken("test-key", valid), "", ErrInvalidNonce},
+	}
+	config := &oauth2.Config{ClientID: testAppID}
+	success := testutils.AssertSuccessNotCalled(t)
+	for _, c := range cases {
+		failure := func(w http.ResponseWriter, req *http.Request) {
+			assert.Equal(t, c.err, gologin.ErrorFromContext(req.Context()), c.name)
+			fmt.Fprintf(w, "failure handler called")
+		}
+
+		// LimitedLoginTokenHandler with an invalid id_token, assert that:
+		// - failure handler is called with a distinguishable error
+		w := postIDToken(LimitedLoginTokenHandler(config, success, http.HandlerFunc(failure)), ctx, c.idToken, c.nonce)
+		assert.Equal(t, "failure handler called", w.Body.String(), c.name)
+	}
+}
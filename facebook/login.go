@@ -1,17 +1,26 @@
 package facebook
 
 import (
+	"context"
 	"errors"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/internal"
 	oauth2Login "github.com/dghubble/gologin/oauth2"
 	"golang.org/x/oauth2"
 )
 
+// ProviderName is the provider name set in the ctx (see
+// gologin.ProviderFromContext) and reported to gologin Hooks.
+const ProviderName = "facebook"
+
 // Facebook login errors
 var (
-	ErrUnableToGetFacebookUser = errors.New("facebook: unable to get Facebook User")
+	ErrUnableToGetFacebookUser           = errors.New("facebook: unable to get Facebook User")
+	ErrUnableToRevokeFacebookPermissions = errors.New("facebook: unable to revoke Facebook permissions")
 )
 
 // StateHandler checks for a state cookie. If found, the state value is read
@@ -26,26 +35,205 @@ func StateHandler(config gologin.CookieConfig, success http.Handler) http.Handle
 	return oauth2Login.StateHandler(config, success)
 }
 
+// StatelessStateHandler issues and verifies states which are signed tokens
+// rather than cookies, for Facebook logins started where cookies cannot be set.
+// See oauth2 StatelessStateHandler.
+func StatelessStateHandler(config oauth2Login.StatelessStateConfig, success, failure http.Handler) http.Handler {
+	return oauth2Login.StatelessStateHandler(config, success, failure)
+}
+
+// Rerequest is an AuthCodeOption which sets auth_type=rerequest so Facebook
+// asks again for permissions the user previously declined.
+var Rerequest = oauth2.SetAuthURLParam("auth_type", "rerequest")
+
+// Displays of the Login Dialog for the Config Display.
+const (
+	// DisplayPage shows the dialog as a full page (the default).
+	DisplayPage = "page"
+	// DisplayPopup shows the dialog sized for a popup window.
+	DisplayPopup = "popup"
+)
+
 // LoginHandler handles Facebook login requests by reading the state value
 // from the ctx and redirecting requests to the AuthURL with that state value.
-func LoginHandler(config *oauth2.Config, failure http.Handler) http.Handler {
-	return oauth2Login.LoginHandler(config, failure)
+// Any AuthCodeOptions are added to the AuthURL. To re-ask for declined
+// permissions, pass Rerequest or wrap the LoginHandler in a RerequestHandler.
+// A ctx locale (see oauth2 WithLocale) is sent as locale.
+func LoginHandler(config *oauth2.Config, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	return LoginHandlerWithConfig(config, Config{}, failure, opts...)
+}
+
+// LoginHandlerWithConfig handles Facebook login requests like LoginHandler,
+// but adds the Config Locale and Display (if any) to the AuthURL. Panics if
+// the Display is not DisplayPage or DisplayPopup.
+func LoginHandlerWithConfig(config *oauth2.Config, fbConfig Config, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	opts = opts[:len(opts):len(opts)]
+	if fbConfig.Locale != "" {
+		opts = append(opts, oauth2.SetAuthURLParam("locale", facebookLocale(fbConfig.Locale)))
+	}
+	switch fbConfig.Display {
+	case "":
+	case DisplayPage, DisplayPopup:
+		opts = append(opts, oauth2.SetAuthURLParam("display", fbConfig.Display))
+	default:
+		panic("facebook: invalid Config Display " + fbConfig.Display)
+	}
+	success := oauth2Login.LocaleParamHandler("locale", facebookLocale, oauth2Login.LoginHandler(config, failure, opts...))
+	return gologin.ProviderHandler(ProviderName, success)
+}
+
+// facebookLocale returns the locale in Facebook's ll_CC form (e.g. "fr-CA"
+// is "fr_CA").
+func facebookLocale(locale string) string {
+	return strings.Replace(locale, "-", "_", -1)
+}
+
+// RerequestHandler adds the Rerequest AuthCodeOption to any ctx oauth2
+// AuthCodeOptions so a downstream LoginHandler re-asks for declined
+// permissions (e.g. on a "grant email" retry route).
+func RerequestHandler(success http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		opts, _ := oauth2Login.AuthCodeOptionsFromContext(ctx)
+		opts = append(opts[:len(opts):len(opts)], Rerequest)
+		ctx = oauth2Login.WithAuthCodeOptions(ctx, opts...)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// Config configures Facebook login and Graph API requests.
+type Config struct {
+	// Locale is the locale of the Login Dialog (e.g. "fr_FR" or "fr-FR"),
+	// sent as locale by LoginHandlerWithConfig. A ctx locale (see oauth2
+	// WithLocale) takes precedence.
+	Locale string
+	// Display is DisplayPage or DisplayPopup, sent as display by
+	// LoginHandlerWithConfig.
+	Display string
+	// AppSecret is the app secret used to send an appsecret_proof with each
+	// Graph API request, as required by the "Require App Secret" setting.
+	// If empty, no proof is sent.
+	AppSecret string
+	// APIVersion is the Graph API version (e.g. "v19.0"). Versions without
+	// the "v" prefix or minor version are normalized (e.g. "19" is "v19.0").
+	// If empty, v2.9 is used.
+	APIVersion string
+	// Fields are the User fields requested from /me (e.g. "first_name",
+	// "link"). If empty, the fields User decodes (id, name, email,
+	// first_name, last_name, picture, and locale) are requested. Fields
+	// which User does not decode are kept in User Raw.
+	Fields []string
+	// LongLivedToken exchanges the short-lived callback Token for a
+	// long-lived Token before fetching the User. See LongLivedTokenHandler.
+	LongLivedToken bool
+	// FetchPermissions gets the user's granted and declined Permissions
+	// after fetching the User and adds them to the ctx.
+	FetchPermissions bool
+	// RequiredPermissions are permissions (e.g. "email") which must be
+	// granted. If any were declined, the failure handler is called with
+	// ErrMissingRequiredPermissions. Implies FetchPermissions.
+	RequiredPermissions []string
+	// Retry configures retries of the /me User request on network errors and
+	// 5xx responses (e.g. transient 502s after the token exchange). The zero
+	// value does not retry.
+	Retry gologin.RetryPolicy
+	// Cache caches /me Users keyed by a SHA-256 hash of the access token, so
+	// repeated requests with a token (e.g. to TokenHandler) skip the Graph
+	// API. Cached Users are added to the ctx like fetched Users.
+	Cache gologin.Cache
+	// CacheTTL is how long Users are cached. Defaults to 5 minutes.
+	CacheTTL time.Duration
+	// NegativeCacheTTL is how long invalid token errors (see IsInvalidToken)
+	// are cached, to blunt probing with guessed tokens. It should be shorter
+	// than the CacheTTL. If zero, errors are not cached.
+	NegativeCacheTTL time.Duration
+	// Transport is the base transport of Graph API requests. Handlers share
+	// it across logins so connections are reused. Defaults to a pooled
+	// transport per handler. The transport of a ctx oauth2.HTTPClient is
+	// used instead, if any.
+	Transport http.RoundTripper
+}
+
+const defaultCacheTTL = 5 * time.Minute
+
+// cachedUser is a Cache value of a /me User or invalid token error.
+type cachedUser struct {
+	user *User
+	err  error
+}
+
+// mustNormalize returns a copy of the Config with a normalized APIVersion,
+// default Fields, and a Transport.
+// Panics if the APIVersion is invalid so misconfiguration is caught when
+// handlers are constructed rather than when requests are served.
+func (c Config) mustNormalize() Config {
+	version, err := normalizeAPIVersion(c.APIVersion)
+	if err != nil {
+		panic(err)
+	}
+	c.APIVersion = version
+	if len(c.Fields) == 0 {
+		c.Fields = defaultFields
+	}
+	c.Transport = internal.TransportOrNew(c.Transport)
+	return c
 }
 
 // CallbackHandler handles Facebook redirection URI requests and adds the
 // Facebook access token and User to the ctx. If authentication succeeds,
 // handling delegates to the success handler, otherwise to the failure
 // handler.
-func CallbackHandler(config *oauth2.Config, success, failure http.Handler) http.Handler {
-	success = facebookHandler(config, success, failure)
-	return oauth2Login.CallbackHandler(config, success, failure)
+// Any AuthCodeOptions are sent with the token exchange.
+func CallbackHandler(config *oauth2.Config, success, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	return CallbackHandlerWithConfig(config, Config{}, success, failure, opts...)
+}
+
+// CallbackHandlerWithConfig handles Facebook redirection URI requests like
+// CallbackHandler, but makes Graph API requests according to the Config. If
+// the Graph API rejects the request (e.g. due to an incorrect app secret),
+// the failure handler's error wraps the *GraphError (see IsInvalidToken,
+// IsTokenExpired, and IsRateLimited). Panics if the Config APIVersion is
+// invalid.
+//
+// The callback outcome, with the Facebook User ID, is reported to any ctx
+// gologin Hooks.
+func CallbackHandlerWithConfig(config *oauth2.Config, fbConfig Config, success, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	success, failure = gologin.CallbackHooks(ProviderName, userID, success, failure)
+	success = userHandler(config, fbConfig, success, failure)
+	return gologin.ProviderHandler(ProviderName, oauth2Login.CallbackHandler(config, success, failure, opts...))
+}
+
+// userID returns the ID of the Facebook User from the ctx, if any.
+func userID(ctx context.Context) string {
+	if user, err := UserFromContext(ctx); err == nil {
+		return user.ID
+	}
+	return ""
+}
+
+// userHandler chains the handlers which get the Facebook User (and, per the
+// Config, exchange the Token or get Permissions) for the ctx Token. The
+// handlers share the normalized Config Transport.
+func userHandler(config *oauth2.Config, fbConfig Config, success, failure http.Handler) http.Handler {
+	fbConfig = fbConfig.mustNormalize()
+	// [LongLivedTokenHandler] -> facebookHandler -> [permissionsHandler] -> success
+	if fbConfig.FetchPermissions || len(fbConfig.RequiredPermissions) > 0 {
+		success = permissionsHandler(config, fbConfig, success, failure)
+	}
+	success = facebookHandler(config, fbConfig, success, failure)
+	if fbConfig.LongLivedToken {
+		success = LongLivedTokenHandler(config, fbConfig, success)
+	}
+	return success
 }
 
 // facebookHandler is a http.Handler that gets the OAuth2 Token from the ctx
 // to get the corresponding Facebook User. If successful, the user is added to
 // the ctx and the success handler is called. Otherwise, the failure handler
 // is called.
-func facebookHandler(config *oauth2.Config, success, failure http.Handler) http.Handler {
+func facebookHandler(config *oauth2.Config, fbConfig Config, success, failure http.Handler) http.Handler {
+	fbConfig = fbConfig.mustNormalize()
 	if failure == nil {
 		failure = gologin.DefaultFailureHandler
 	}
@@ -57,10 +245,32 @@ func facebookHandler(config *oauth2.Config, success, failure http.Handler) http.
 			failure.ServeHTTP(w, req.WithContext(ctx))
 			return
 		}
-		httpClient := config.Client(ctx, token)
-		facebookService := newClient(httpClient)
-		user, resp, err := facebookService.Me()
+		cacheKey := internal.TokenCacheKey(ProviderName+":"+strings.Join(fbConfig.Fields, ","), token.AccessToken)
+		if fbConfig.Cache != nil {
+			if value, ok := fbConfig.Cache.Get(cacheKey); ok {
+				if cached, ok := value.(*cachedUser); ok {
+					if cached.err != nil {
+						ctx = gologin.WithError(ctx, cached.err)
+						failure.ServeHTTP(w, req.WithContext(ctx))
+						return
+					}
+					// copy so success handlers cannot modify the cached User
+					user := *cached.user
+					ctx = WithUser(ctx, &user)
+					success.ServeHTTP(w, req.WithContext(ctx))
+					return
+				}
+			}
+		}
+		fetchCtx, endFetch := gologin.StartUserFetch(ctx, ProviderName)
+		httpClient := internal.RetryClient(internal.OAuth2TransportClient(fetchCtx, fbConfig.Transport, config, token), fbConfig.Retry)
+		facebookService := newClient(httpClient, fbConfig.APIVersion, fbConfig.appSecretProof(token))
+		user, resp, err := facebookService.Me(fetchCtx, fbConfig.Fields)
 		err = validateResponse(user, resp, err)
+		endFetch(err)
+		if fbConfig.Cache != nil {
+			fbConfig.cacheUser(cacheKey, user, err)
+		}
 		if err != nil {
 			ctx = gologin.WithError(ctx, err)
 			failure.ServeHTTP(w, req.WithContext(ctx))
@@ -72,14 +282,88 @@ func facebookHandler(config *oauth2.Config, success, failure http.Handler) http.
 	return http.HandlerFunc(fn)
 }
 
+// cacheUser caches the fetched User, or the error if the token is invalid.
+// Other errors (e.g. rate limits or outages) are not cached.
+func (c Config) cacheUser(key string, user *User, err error) {
+	if err == nil {
+		ttl := c.CacheTTL
+		if ttl <= 0 {
+			ttl = defaultCacheTTL
+		}
+		cached := *user
+		c.Cache.Set(key, &cachedUser{user: &cached}, ttl)
+		return
+	}
+	if IsInvalidToken(err) && c.NegativeCacheTTL > 0 {
+		c.Cache.Set(key, &cachedUser{err: err}, c.NegativeCacheTTL)
+	}
+}
+
+// RevokeHandler revokes the app's permissions for the user of the Facebook
+// Token from the ctx (DELETE /me/permissions), then calls the success handler.
+// Tokens which are already invalid are treated as revoked. Otherwise, the
+// failure handler is called.
+func RevokeHandler(config *oauth2.Config, success, failure http.Handler) http.Handler {
+	return RevokeHandlerWithConfig(config, Config{}, success, failure)
+}
+
+// RevokeHandlerWithConfig revokes the app's permissions like RevokeHandler,
+// but makes Graph API requests according to the Config. Panics if the Config
+// APIVersion is invalid.
+func RevokeHandlerWithConfig(config *oauth2.Config, fbConfig Config, success, failure http.Handler) http.Handler {
+	fbConfig = fbConfig.mustNormalize()
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		httpClient := internal.OAuth2TransportClient(ctx, fbConfig.Transport, config, token)
+		facebookService := newClient(httpClient, fbConfig.APIVersion, fbConfig.appSecretProof(token))
+		apiErr, resp, err := facebookService.RevokePermissions(ctx)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		invalidToken := resp.StatusCode == http.StatusBadRequest && apiErr.Error.Code == errCodeInvalidToken
+		if resp.StatusCode != http.StatusOK && !invalidToken {
+			ctx = gologin.WithError(ctx, ErrUnableToRevokeFacebookPermissions)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		success.ServeHTTP(w, req)
+	}
+	return http.HandlerFunc(fn)
+}
+
+// appSecretProof returns the appsecret_proof for the Token's access token or
+// an empty string if the Config has no AppSecret.
+func (c Config) appSecretProof(token *oauth2.Token) string {
+	if c.AppSecret == "" {
+		return ""
+	}
+	return appSecretProof(token.AccessToken, c.AppSecret)
+}
+
 // validateResponse returns an error if the given Facebook User, raw
-// http.Response, or error are unexpected. Returns nil if they are valid.
+// http.Response, or error are unexpected. Returns nil if they are valid, or a
+// *gologin.Error which preserves the cause and status code.
 func validateResponse(user *User, resp *http.Response, err error) error {
-	if err != nil || resp.StatusCode != http.StatusOK {
-		return ErrUnableToGetFacebookUser
+	var status int
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	if err != nil || status != http.StatusOK {
+		return &gologin.Error{Provider: "facebook", Op: "get user", StatusCode: status, Err: err, Kind: ErrUnableToGetFacebookUser}
 	}
 	if user == nil || user.ID == "" {
-		return ErrUnableToGetFacebookUser
+		return &gologin.Error{Provider: "facebook", Op: "get user", StatusCode: status, Kind: ErrUnableToGetFacebookUser}
 	}
 	return nil
 }
@@ -2,26 +2,97 @@ package facebook
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/gologintest"
+	"github.com/dghubble/gologin/internal"
 	oauth2Login "github.com/dghubble/gologin/oauth2"
 	"github.com/dghubble/gologin/testutils"
 	"github.com/stretchr/testify/assert"
 	"golang.org/x/oauth2"
 )
 
+func TestLoginHandler(t *testing.T) {
+	expectedRedirect := "https://www.facebook.com/dialog/oauth?auth_type=rerequest&client_id=client_id&redirect_uri=redirect_url&response_type=code&state=state_val"
+	config := &oauth2.Config{
+		ClientID:    "client_id",
+		RedirectURL: "redirect_url",
+		Endpoint: oauth2.Endpoint{
+			AuthURL: "https://www.facebook.com/dialog/oauth",
+		},
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// LoginHandler assert that:
+	// - AuthCodeOptions are passed through to the redirect url
+	loginHandler := LoginHandler(config, failure, oauth2.SetAuthURLParam("auth_type", "rerequest"))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	ctx := oauth2Login.WithState(context.Background(), "state_val")
+	loginHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, expectedRedirect, w.HeaderMap.Get("Location"))
+}
+
+func TestLoginHandlerWithConfig(t *testing.T) {
+	config := &oauth2.Config{
+		ClientID: "client_id",
+		Endpoint: oauth2.Endpoint{AuthURL: "https://www.facebook.com/dialog/oauth"},
+	}
+	cases := []struct {
+		fbConfig Config
+		locale   string
+		expected url.Values
+	}{
+		{Config{Locale: "fr_FR", Display: DisplayPopup}, "", url.Values{"locale": {"fr_FR"}, "display": {"popup"}}},
+		// BCP 47 locales use Facebook's ll_CC form
+		{Config{Locale: "pt-BR", Display: DisplayPage}, "", url.Values{"locale": {"pt_BR"}, "display": {"page"}}},
+		// the ctx locale takes precedence over the Config Locale
+		{Config{Locale: "fr_FR"}, "de-DE", url.Values{"locale": {"de_DE"}}},
+		{Config{}, "", url.Values{}},
+	}
+	for _, c := range cases {
+		// LoginHandlerWithConfig with a Locale, Display, or ctx locale, assert
+		// that:
+		// - they are added to the AuthURL as locale and display
+		loginHandler := LoginHandlerWithConfig(config, c.fbConfig, testutils.AssertFailureNotCalled(t))
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		ctx := oauth2Login.WithState(context.Background(), "state_val")
+		if c.locale != "" {
+			ctx = oauth2Login.WithLocale(ctx, c.locale)
+		}
+		loginHandler.ServeHTTP(w, req.WithContext(ctx))
+		location, err := url.Parse(w.HeaderMap.Get("Location"))
+		if assert.Nil(t, err) {
+			query := location.Query()
+			for _, param := range []string{"locale", "display", "hl", "mkt"} {
+				assert.Equal(t, c.expected[param], query[param], param)
+			}
+		}
+	}
+	assert.Panics(t, func() { LoginHandlerWithConfig(config, Config{Display: "touch"}, nil) })
+}
+
 func TestFacebookHandler(t *testing.T) {
 	jsonData := `{"id": "54638001", "name": "Ivy Crimson", "email": "ivy@harvard.edu"}`
-	expectedUser := &User{ID: "54638001", Name: "Ivy Crimson", Email: "ivy@harvard.edu"}
+	expectedUser := &User{ID: "54638001", Name: "Ivy Crimson", Email: "ivy@harvard.edu", Raw: json.RawMessage(jsonData)}
 	proxyClient, server := newFacebookTestServer(jsonData)
 	defer server.Close()
 	// oauth2 Client will use the proxy client's base Transport
 	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
-	anyToken := &oauth2.Token{AccessToken: "any-token"}
+	expiry := time.Now().Add(time.Hour)
+	anyToken := &oauth2.Token{AccessToken: "any-token", RefreshToken: "any-refresh", Expiry: expiry}
 	ctx = oauth2Login.WithToken(ctx, anyToken)
 
 	config := &oauth2.Config{}
@@ -30,6 +101,11 @@ func TestFacebookHandler(t *testing.T) {
 		facebookUser, err := UserFromContext(ctx)
 		assert.Nil(t, err)
 		assert.Equal(t, expectedUser, facebookUser)
+		// the full Token is passed through to the success handler
+		token, err := oauth2Login.TokenFromContext(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, "any-refresh", token.RefreshToken)
+		assert.Equal(t, expiry, token.Expiry)
 		fmt.Fprintf(w, "success handler called")
 	}
 	failure := testutils.AssertFailureNotCalled(t)
@@ -39,13 +115,146 @@ func TestFacebookHandler(t *testing.T) {
 	// - facebook User is obtained from the facebook API
 	// - success handler is called
 	// - facebook User is added to the ctx of the success handler
-	facebookHandler := facebookHandler(config, http.HandlerFunc(success), failure)
+	facebookHandler := facebookHandler(config, Config{}, http.HandlerFunc(success), failure)
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest("GET", "/", nil)
 	facebookHandler.ServeHTTP(w, req.WithContext(ctx))
 	assert.Equal(t, "success handler called", w.Body.String())
 }
 
+func TestCallbackHandlerWithConfig_ReusesConnections(t *testing.T) {
+	_, mux, server := testutils.TestServer()
+	defer server.Close()
+	mux.HandleFunc("/oauth/token", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token": "any-token", "token_type": "bearer"}`)
+	})
+	mux.HandleFunc("/v2.9/me", func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "Bearer any-token", req.Header.Get("Authorization"))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"id": "54638001", "name": "Ivy Crimson"}`)
+	})
+	transport, dials := testutils.DialCountingTransport(server)
+	config := &oauth2.Config{
+		ClientID: "client_id",
+		Endpoint: oauth2.Endpoint{TokenURL: server.URL + "/oauth/token"},
+	}
+	success := func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(w, "success handler called")
+	}
+
+	// CallbackHandlerWithConfig with a Transport, assert that:
+	// - sequential callbacks get the User with the shared Transport
+	// - the keep-alive connection to the Graph API is reused across logins
+	handler := CallbackHandlerWithConfig(config, Config{Transport: transport}, http.HandlerFunc(success), testutils.AssertFailureNotCalled(t))
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/callback?code=any_code&state=state_val", nil)
+		handler.ServeHTTP(w, req.WithContext(oauth2Login.WithState(context.Background(), "state_val")))
+		assert.Equal(t, "success handler called", w.Body.String())
+	}
+	assert.Equal(t, 1, dials())
+}
+
+func TestUser_UnmarshalJSON(t *testing.T) {
+	cases := []struct {
+		data     string
+		expected User
+	}{
+		// extra fields are kept in Raw only
+		{`{"id": "54638001", "name": "Ivy Crimson", "link": "https://facebook.com/ivy", "age_range": {"min": 21}}`, User{ID: "54638001", Name: "Ivy Crimson"}},
+		// missing fields are zero valued
+		{`{"id": "54638001"}`, User{ID: "54638001"}},
+		{`{}`, User{}},
+	}
+	for _, c := range cases {
+		var user User
+		assert.Nil(t, json.Unmarshal([]byte(c.data), &user))
+		// Raw round-trips the exact bytes
+		assert.Equal(t, c.data, string(user.Raw))
+		user.Raw = nil
+		assert.Equal(t, c.expected, user)
+	}
+	var user User
+	assert.NotNil(t, json.Unmarshal([]byte(`{"id": 54638001}`), &user))
+	// Raw is not marshaled, so encoding and decoding a User keeps its fields
+	data, err := json.Marshal(&User{ID: "54638001", Raw: json.RawMessage(`{"id": "54638001", "link": "x"}`)})
+	assert.Nil(t, err)
+	assert.NotContains(t, string(data), "link")
+}
+
+func TestFacebookHandler_Profiles(t *testing.T) {
+	fullUser := User{ID: "54638001", Name: "Ivy Crimson", Email: "ivy@harvard.edu", FirstName: "Ivy", LastName: "Crimson", Locale: "en_US"}
+	fullUser.Picture.Data.URL = "https://example.com/ivy.jpg"
+	fullUser.Picture.Data.Width = 50
+	fullUser.Picture.Data.Height = 50
+	fullUser.Picture.Data.IsSilhouette = true
+	cases := []struct {
+		jsonData string
+		expected User
+	}{
+		{testFullUserJSON, fullUser},
+		{testMinimalUserJSON, User{ID: "54638001", Name: "Ivy Crimson"}},
+	}
+	for _, c := range cases {
+		proxyClient, server := newFacebookTestServer(c.jsonData)
+		ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+		ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+		expected := c.expected
+		expected.Raw = json.RawMessage(c.jsonData)
+		success := func(w http.ResponseWriter, req *http.Request) {
+			user, err := UserFromContext(req.Context())
+			assert.Nil(t, err)
+			assert.Equal(t, &expected, user)
+			fmt.Fprintf(w, "success handler called")
+		}
+		failure := testutils.AssertFailureNotCalled(t)
+
+		// FacebookHandler with the default Fields, assert that:
+		// - full profiles decode the name parts, picture, and locale
+		// - absent fields decode to zero values without error
+		facebookHandler := facebookHandler(&oauth2.Config{}, Config{}, http.HandlerFunc(success), failure)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		facebookHandler.ServeHTTP(w, req.WithContext(ctx))
+		assert.Equal(t, "success handler called", w.Body.String())
+		server.Close()
+	}
+}
+
+func TestFacebookHandler_RawFields(t *testing.T) {
+	jsonData := `{"id": "54638001", "name": "Ivy Crimson", "link": "https://facebook.com/ivy", "languages": [{"id": "1", "name": "English"}]}`
+	proxyClient, server := newFacebookTestServer(jsonData)
+	defer server.Close()
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+	success := func(w http.ResponseWriter, req *http.Request) {
+		user, err := UserFromContext(req.Context())
+		assert.Nil(t, err)
+		assert.Equal(t, "54638001", user.ID)
+		// richer types decode fields User does not declare
+		var extra struct {
+			Link      string `json:"link"`
+			Languages []struct {
+				Name string `json:"name"`
+			} `json:"languages"`
+		}
+		assert.Nil(t, json.Unmarshal(user.Raw, &extra))
+		assert.Equal(t, "https://facebook.com/ivy", extra.Link)
+		assert.Equal(t, "English", extra.Languages[0].Name)
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// FacebookHandler assert that:
+	// - the User Raw holds the full /me response body
+	handler := facebookHandler(&oauth2.Config{}, Config{Fields: []string{"id", "name", "link", "languages"}}, http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	handler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
 func TestFacebookHandler_MissingCtxToken(t *testing.T) {
 	config := &oauth2.Config{}
 	success := testutils.AssertSuccessNotCalled(t)
@@ -61,7 +270,7 @@ func TestFacebookHandler_MissingCtxToken(t *testing.T) {
 	// FacebookHandler called without Token in ctx, assert that:
 	// - failure handler is called
 	// - error about ctx missing token is added to the failure handler ctx
-	facebookHandler := facebookHandler(config, success, http.HandlerFunc(failure))
+	facebookHandler := facebookHandler(config, Config{}, success, http.HandlerFunc(failure))
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest("GET", "/", nil)
 	facebookHandler.ServeHTTP(w, req)
@@ -82,7 +291,12 @@ func TestFacebookHandler_ErrorGettingUser(t *testing.T) {
 		ctx := req.Context()
 		err := gologin.ErrorFromContext(ctx)
 		if assert.NotNil(t, err) {
-			assert.Equal(t, ErrUnableToGetFacebookUser, err)
+			assert.True(t, errors.Is(err, ErrUnableToGetFacebookUser))
+			var providerErr *gologin.Error
+			if assert.True(t, errors.As(err, &providerErr)) {
+				assert.Equal(t, "facebook", providerErr.Provider)
+				assert.Equal(t, http.StatusInternalServerError, providerErr.StatusCode)
+			}
 		}
 		fmt.Fprintf(w, "failure handler called")
 	}
@@ -90,11 +304,677 @@ func TestFacebookHandler_ErrorGettingUser(t *testing.T) {
 	// FacebookHandler cannot get Facebook User, assert that:
 	// - failure handler is called
 	// - error cannot get Facebook User added to the failure handler ctx
-	facebookHandler := facebookHandler(config, success, http.HandlerFunc(failure))
+	// - the error is a *gologin.Error with the Graph API status code
+	facebookHandler := facebookHandler(config, Config{}, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	facebookHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestFacebookHandler_RequestHeadersAndSizeLimit(t *testing.T) {
+	proxyClient, mux, server := testutils.TestServer()
+	defer server.Close()
+	mux.HandleFunc("/v2.9/me", func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "application/json", req.Header.Get("Accept"))
+		assert.Equal(t, "gologin", req.Header.Get("User-Agent"))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"id": "54638001", "name": "%s"}`, strings.Repeat("a", 2<<20))
+	})
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		assert.True(t, errors.Is(err, ErrUnableToGetFacebookUser))
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// FacebookHandler with an oversized /me response, assert that:
+	// - requests accept JSON and identify gologin
+	// - responses over the size limit call the failure handler
+	facebookHandler := facebookHandler(&oauth2.Config{}, Config{}, testutils.AssertSuccessNotCalled(t), http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	facebookHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestFacebookHandler_AppSecretProof(t *testing.T) {
+	proxyClient, mux, server := testutils.TestServer()
+	defer server.Close()
+	mux.HandleFunc("/v2.9/me", func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "id,name,email,first_name,last_name,picture,locale", req.URL.Query().Get("fields"))
+		// HMAC-SHA256("any-token") keyed by "app-secret"
+		assert.Equal(t, "c2b8c12476c8105c1328576ae08807c4d579baaea5deadf9aa598133cdb2e60c", req.URL.Query().Get("appsecret_proof"))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"id": "54638001", "name": "Ivy Crimson"}`)
+	})
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+
+	config := &oauth2.Config{}
+	success := func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// FacebookHandler with an AppSecret, assert that:
+	// - the appsecret_proof of the access token is sent to the Graph API
+	// - success handler is called
+	facebookHandler := facebookHandler(config, Config{AppSecret: "app-secret"}, http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	facebookHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestFacebookHandler_GraphErrors(t *testing.T) {
+	cases := []struct {
+		status  int
+		body    string
+		code    int
+		subcode int
+		expired bool
+		limited bool
+		invalid bool
+		traceID string
+		userMsg string
+	}{
+		{http.StatusBadRequest, `{"error": {"message": "Error validating access token: Session has expired on Tuesday, 01-Jan-19 12:00:00 PST.", "type": "OAuthException", "code": 190, "error_subcode": 463, "fbtrace_id": "EJplcsCHuLu", "error_user_title": "Session expired", "error_user_msg": "Please log in again."}}`,
+			190, 463, true, false, true, "EJplcsCHuLu", "Please log in again."},
+		{http.StatusBadRequest, `{"error": {"message": "Error validating access token: The user has not authorized application 123.", "type": "OAuthException", "code": 190, "error_subcode": 458, "fbtrace_id": "A5qB2Bk"}}`,
+			190, 458, false, false, true, "A5qB2Bk", ""},
+		{http.StatusForbidden, `{"error": {"message": "(#4) Application request limit reached", "type": "OAuthException", "code": 4, "fbtrace_id": "Fz54k3GZrio"}}`,
+			4, 0, false, true, false, "Fz54k3GZrio", ""},
+		{http.StatusForbidden, `{"error": {"message": "(#17) User request limit reached", "type": "OAuthException", "code": 17, "error_subcode": 2446079, "fbtrace_id": "B0Zbfw1"}}`,
+			17, 2446079, false, true, false, "B0Zbfw1", ""},
+	}
+	config := &oauth2.Config{}
+	for _, c := range cases {
+		proxyClient, mux, server := testutils.TestServer()
+		mux.HandleFunc("/v2.9/me", func(w http.ResponseWriter, req *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(c.status)
+			fmt.Fprintf(w, c.body)
+		})
+		// oauth2 Client will use the proxy client's base Transport
+		ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+		ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+		success := testutils.AssertSuccessNotCalled(t)
+		failure := func(w http.ResponseWriter, req *http.Request) {
+			err := gologin.ErrorFromContext(req.Context())
+			assert.True(t, errors.Is(err, ErrUnableToGetFacebookUser))
+			var graphErr *GraphError
+			if assert.True(t, errors.As(err, &graphErr)) {
+				assert.Equal(t, c.code, graphErr.Code)
+				assert.Equal(t, c.subcode, graphErr.Subcode)
+				assert.Equal(t, "OAuthException", graphErr.Type)
+				assert.Equal(t, c.traceID, graphErr.FBTraceID)
+				assert.Equal(t, c.userMsg, graphErr.UserMessage)
+			}
+			assert.Equal(t, c.expired, IsTokenExpired(err))
+			assert.Equal(t, c.invalid, IsInvalidToken(err))
+			assert.Equal(t, c.limited, IsRateLimited(err))
+			fmt.Fprintf(w, "failure handler called")
+		}
+
+		// FacebookHandler when the Graph API responds with an error, assert that:
+		// - failure handler is called
+		// - the error wraps the GraphError's code, subcode, and fbtrace_id
+		// - expired tokens and rate limits are distinguishable
+		facebookHandler := facebookHandler(config, Config{}, success, http.HandlerFunc(failure))
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		facebookHandler.ServeHTTP(w, req.WithContext(ctx))
+		assert.Equal(t, "failure handler called", w.Body.String())
+		server.Close()
+	}
+}
+
+func TestGraphError(t *testing.T) {
+	err := &GraphError{Message: "Invalid OAuth access token.", Type: "OAuthException", Code: 190, Subcode: 463, FBTraceID: "EJplcsCHuLu"}
+	assert.Equal(t, "facebook: Invalid OAuth access token. (OAuthException, code 190, subcode 463, fbtrace_id EJplcsCHuLu)", err.Error())
+	err = &GraphError{Message: "Unsupported get request.", Type: "GraphMethodException", Code: 100}
+	assert.Equal(t, "facebook: Unsupported get request. (GraphMethodException, code 100)", err.Error())
+	assert.False(t, IsTokenExpired(errors.New("other")))
+	assert.False(t, IsRateLimited(nil))
+}
+
+func TestFacebookHandler_InvalidAppSecretProof(t *testing.T) {
+	proxyClient, mux, server := testutils.TestServer()
+	defer server.Close()
+	mux.HandleFunc("/v2.9/me", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `{"error": {"message": "Invalid appsecret_proof provided in the API argument", "type": "GraphMethodException", "code": 100}}`)
+	})
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+
+	config := &oauth2.Config{}
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		var graphErr *GraphError
+		if assert.True(t, errors.As(err, &graphErr)) {
+			assert.Equal(t, "Invalid appsecret_proof provided in the API argument", graphErr.Message)
+			assert.Equal(t, 100, graphErr.Code)
+		}
+		assert.Contains(t, err.Error(), "Invalid appsecret_proof")
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// FacebookHandler with an incorrect AppSecret, assert that:
+	// - failure handler is called
+	// - the error wraps the Graph API error
+	facebookHandler := facebookHandler(config, Config{AppSecret: "wrong-secret"}, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	facebookHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestFacebookHandler_APIVersion(t *testing.T) {
+	cases := []struct {
+		version string
+		path    string
+	}{
+		{"", "/v2.9/me"},
+		{"v19.0", "/v19.0/me"},
+		{"19", "/v19.0/me"},
+		{"18.0", "/v18.0/me"},
+	}
+	for _, c := range cases {
+		proxyClient, mux, server := testutils.TestServer()
+		mux.HandleFunc("/", func(w http.ResponseWriter, req *http.Request) {
+			assert.Equal(t, c.path, req.URL.Path, c.version)
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"id": "54638001", "name": "Ivy Crimson"}`)
+		})
+		// oauth2 Client will use the proxy client's base Transport
+		ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+		ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+		success := func(w http.ResponseWriter, req *http.Request) {
+			fmt.Fprintf(w, "success handler called")
+		}
+		failure := testutils.AssertFailureNotCalled(t)
+
+		// FacebookHandler with an APIVersion, assert that:
+		// - the User is requested from the (normalized) Graph API version
+		// - success handler is called
+		facebookHandler := facebookHandler(&oauth2.Config{}, Config{APIVersion: c.version}, http.HandlerFunc(success), failure)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		facebookHandler.ServeHTTP(w, req.WithContext(ctx))
+		assert.Equal(t, "success handler called", w.Body.String())
+		server.Close()
+	}
+}
+
+func TestFacebookHandler_Fields(t *testing.T) {
+	proxyClient, mux, server := testutils.TestServer()
+	defer server.Close()
+	mux.HandleFunc("/v2.9/me", func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "id,email,first_name,last_name,picture,birthday", req.URL.Query().Get("fields"))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"id": "54638001", "email": "ivy@harvard.edu", "first_name": "Ivy", "last_name": "Crimson", "birthday": "01/01/1990",
+			"picture": {"data": {"url": "https://example.com/ivy.jpg", "width": 50, "height": 50, "is_silhouette": false}}}`)
+	})
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+
+	success := func(w http.ResponseWriter, req *http.Request) {
+		user, err := UserFromContext(req.Context())
+		if assert.Nil(t, err) {
+			assert.Equal(t, "54638001", user.ID)
+			assert.Equal(t, "ivy@harvard.edu", user.Email)
+			assert.Equal(t, "Ivy", user.FirstName)
+			assert.Equal(t, "Crimson", user.LastName)
+			assert.Equal(t, "https://example.com/ivy.jpg", user.Picture.Data.URL)
+			assert.Equal(t, 50, user.Picture.Data.Width)
+		}
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// FacebookHandler with Fields, assert that:
+	// - the fields are requested from /me
+	// - nested picture data is decoded and unknown fields are ignored
+	// - success handler is called
+	fbConfig := Config{Fields: []string{"id", "email", "first_name", "last_name", "picture", "birthday"}}
+	facebookHandler := facebookHandler(&oauth2.Config{}, fbConfig, http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	facebookHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestCallbackHandlerWithConfig_InvalidAPIVersion(t *testing.T) {
+	for _, version := range []string{"latest", "v19.0.1", "vv19"} {
+		assert.Panics(t, func() {
+			CallbackHandlerWithConfig(&oauth2.Config{}, Config{APIVersion: version}, testutils.AssertSuccessNotCalled(t), nil)
+		}, version)
+	}
+}
+
+func TestAppSecretProof(t *testing.T) {
+	assert.Equal(t, "c2b8c12476c8105c1328576ae08807c4d579baaea5deadf9aa598133cdb2e60c", appSecretProof("any-token", "app-secret"))
+	assert.Equal(t, "", Config{}.appSecretProof(&oauth2.Token{AccessToken: "any-token"}))
+	assert.NotEqual(t, appSecretProof("any-token", "app-secret"), appSecretProof("other-token", "app-secret"))
+}
+
+func TestFacebookHandler_FailureHandlerFunc(t *testing.T) {
+	proxyClient, server := testutils.NewErrorServer("Facebook Service Down", http.StatusInternalServerError)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+
+	config := &oauth2.Config{}
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := gologin.FailureHandlerFunc(func(ctx context.Context, w http.ResponseWriter, req *http.Request, err error) {
+		assert.True(t, errors.Is(err, ErrUnableToGetFacebookUser))
+		// the error is still added to the ctx
+		assert.True(t, errors.Is(gologin.ErrorFromContext(ctx), ErrUnableToGetFacebookUser))
+		fmt.Fprintf(w, "failure handler called")
+	})
+
+	// FacebookHandler with a FailureHandlerFunc, assert that:
+	// - the failure func receives the error explicitly
+	facebookHandler := facebookHandler(config, Config{}, success, failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	facebookHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestFacebookHandler_Timeout(t *testing.T) {
+	proxyClient, mux, server := testutils.TestServer()
+	release := make(chan struct{})
+	defer server.Close()
+	defer close(release)
+	mux.HandleFunc("/v2.9/me", func(w http.ResponseWriter, req *http.Request) {
+		// respond slower than the client timeout
+		select {
+		case <-release:
+		case <-time.After(5 * time.Second):
+		}
+	})
+	// oauth2 Client will use the proxy client's base Transport and Timeout
+	proxyClient.Timeout = 50 * time.Millisecond
+	ctx := gologin.WithHTTPClient(context.Background(), proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+
+	config := &oauth2.Config{}
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		assert.True(t, errors.Is(gologin.ErrorFromContext(req.Context()), ErrUnableToGetFacebookUser))
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// FacebookHandler with a ctx http.Client with a Timeout, assert that:
+	// - the client is used to get the Facebook User
+	// - failure handler is called rather than hanging
+	facebookHandler := facebookHandler(config, Config{}, success, http.HandlerFunc(failure))
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest("GET", "/", nil)
+	start := time.Now()
 	facebookHandler.ServeHTTP(w, req.WithContext(ctx))
 	assert.Equal(t, "failure handler called", w.Body.String())
+	assert.True(t, time.Since(start) < time.Second)
+}
+
+func TestFacebookHandler_Canceled(t *testing.T) {
+	proxyClient, mux, server := testutils.TestServer()
+	defer server.Close()
+	mux.HandleFunc("/v2.9/me", func(w http.ResponseWriter, req *http.Request) {
+		t.Errorf("expected the Graph API request to be aborted")
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	ctx = gologin.WithHTTPClient(ctx, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+	// client disconnected
+	cancel()
+
+	config := &oauth2.Config{}
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		assert.True(t, errors.Is(err, ErrUnableToGetFacebookUser))
+		assert.True(t, errors.Is(err, context.Canceled))
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// FacebookHandler with a canceled ctx, assert that:
+	// - the Graph API request is aborted
+	// - failure handler is called
+	facebookHandler := facebookHandler(config, Config{}, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	facebookHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestFacebookHandler_Deadline(t *testing.T) {
+	proxyClient, mux, server := testutils.TestServer()
+	release := make(chan struct{})
+	defer server.Close()
+	defer close(release)
+	mux.HandleFunc("/v2.9/me", func(w http.ResponseWriter, req *http.Request) {
+		// hang until the test ends
+		<-release
+	})
+	config := &oauth2.Config{}
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		assert.True(t, errors.Is(err, ErrUnableToGetFacebookUser))
+		assert.True(t, errors.Is(err, context.DeadlineExceeded), "%v", err)
+		fmt.Fprintf(w, "failure handler called")
+	}
+	requestDeadline := func(ctx context.Context) (context.Context, context.CancelFunc) {
+		return context.WithTimeout(ctx, 50*time.Millisecond)
+	}
+	timeoutHandler := func(ctx context.Context) (context.Context, context.CancelFunc) {
+		return gologin.WithTimeout(ctx, 50*time.Millisecond), func() {}
+	}
+
+	// FacebookHandler with a hanging Graph API, assert that:
+	// - the request deadline or a gologin timeout aborts the Graph API request
+	// - failure handler is called promptly with a DeadlineExceeded error
+	for _, withDeadline := range []func(context.Context) (context.Context, context.CancelFunc){requestDeadline, timeoutHandler} {
+		ctx, cancel := withDeadline(context.Background())
+		ctx = gologin.WithHTTPClient(ctx, proxyClient)
+		ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+		facebookHandler := facebookHandler(config, Config{}, success, http.HandlerFunc(failure))
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		start := time.Now()
+		facebookHandler.ServeHTTP(w, req.WithContext(ctx))
+		cancel()
+		assert.Equal(t, "failure handler called", w.Body.String())
+		assert.True(t, time.Since(start) < time.Second)
+	}
+}
+
+func TestFacebookHandler_Retry(t *testing.T) {
+	policy := gologin.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}
+	unavailable := func(w http.ResponseWriter, req *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}
+	disconnect := func(w http.ResponseWriter, req *http.Request) {
+		conn, _, _ := w.(http.Hijacker).Hijack()
+		conn.Close()
+	}
+	cases := []struct {
+		name     string
+		policy   gologin.RetryPolicy
+		timeout  time.Duration
+		failures []http.HandlerFunc
+		attempts int
+		success  bool
+	}{
+		{"5xx then success", policy, 0, []http.HandlerFunc{unavailable, unavailable}, 3, true},
+		{"network error then success", policy, 0, []http.HandlerFunc{disconnect}, 2, true},
+		{"attempts exhausted", policy, 0, []http.HandlerFunc{unavailable, unavailable, unavailable}, 3, false},
+		{"retries disabled", gologin.RetryPolicy{}, 0, []http.HandlerFunc{unavailable}, 1, false},
+		{"4xx not retried", policy, 0, []http.HandlerFunc{func(w http.ResponseWriter, req *http.Request) {
+			http.Error(w, "bad request", http.StatusBadRequest)
+		}}, 1, false},
+		{"Retry-After beyond MaxDelay", policy, 0, []http.HandlerFunc{func(w http.ResponseWriter, req *http.Request) {
+			w.Header().Set("Retry-After", "60")
+			unavailable(w, req)
+		}}, 1, false},
+		{"Retry-After within MaxDelay", gologin.RetryPolicy{MaxAttempts: 2, MaxDelay: 2 * time.Second}, 0, []http.HandlerFunc{func(w http.ResponseWriter, req *http.Request) {
+			w.Header().Set("Retry-After", "0")
+			unavailable(w, req)
+		}}, 2, true},
+		{"delay beyond ctx deadline", gologin.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: time.Second}, 100 * time.Millisecond, []http.HandlerFunc{unavailable}, 1, false},
+	}
+	for _, c := range cases {
+		proxyClient, mux, server := testutils.TestServer()
+		attempts := 0
+		mux.HandleFunc("/v2.9/me", func(w http.ResponseWriter, req *http.Request) {
+			attempts++
+			if attempts <= len(c.failures) {
+				c.failures[attempts-1](w, req)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"id": "54638001", "name": "Ivy Crimson"}`)
+		})
+		ctx := context.Background()
+		cancel := func() {}
+		if c.timeout > 0 {
+			ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		}
+		ctx = gologin.WithHTTPClient(ctx, proxyClient)
+		ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+		success := func(w http.ResponseWriter, req *http.Request) {
+			user, err := UserFromContext(req.Context())
+			assert.Nil(t, err)
+			assert.Equal(t, "54638001", user.ID)
+			fmt.Fprintf(w, "success handler called")
+		}
+		failure := func(w http.ResponseWriter, req *http.Request) {
+			assert.True(t, errors.Is(gologin.ErrorFromContext(req.Context()), ErrUnableToGetFacebookUser), c.name)
+			fmt.Fprintf(w, "failure handler called")
+		}
+
+		// FacebookHandler with a RetryPolicy, assert that:
+		// - 5xx responses and network errors are retried up to MaxAttempts
+		// - 4xx responses are not retried
+		// - Retry-After delays beyond the MaxDelay or ctx deadline are not waited for
+		handler := facebookHandler(&oauth2.Config{}, Config{Retry: c.policy}, http.HandlerFunc(success), http.HandlerFunc(failure))
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		start := time.Now()
+		handler.ServeHTTP(w, req.WithContext(ctx))
+		cancel()
+		server.Close()
+		expected := "failure handler called"
+		if c.success {
+			expected = "success handler called"
+		}
+		assert.Equal(t, expected, w.Body.String(), c.name)
+		assert.Equal(t, c.attempts, attempts, c.name)
+		assert.True(t, time.Since(start) < time.Second, c.name)
+	}
+}
+
+func TestFacebookHandler_RetryFakeClock(t *testing.T) {
+	// production-like delays which a real clock would wait for
+	policy := gologin.RetryPolicy{MaxAttempts: 4, BaseDelay: time.Second, MaxDelay: 30 * time.Second}
+	retry := func(entropy int64) (int, time.Duration) {
+		proxyClient, mux, server := testutils.TestServer()
+		defer server.Close()
+		attempts := 0
+		mux.HandleFunc("/v2.9/me", func(w http.ResponseWriter, req *http.Request) {
+			attempts++
+			if attempts < 4 {
+				http.Error(w, "unavailable", http.StatusServiceUnavailable)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"id": "54638001", "name": "Ivy Crimson"}`)
+		})
+		clock := gologintest.NewFakeClock(time.Unix(1500000000, 0))
+		ctx := gologin.WithHTTPClient(context.Background(), proxyClient)
+		ctx = gologin.WithClock(ctx, clock)
+		ctx = gologin.WithEntropy(ctx, gologintest.NewEntropy(entropy))
+		ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+		success := func(w http.ResponseWriter, req *http.Request) {
+			fmt.Fprintf(w, "success handler called")
+		}
+		handler := facebookHandler(&oauth2.Config{}, Config{Retry: policy}, http.HandlerFunc(success), testutils.AssertFailureNotCalled(t))
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		handler.ServeHTTP(w, req.WithContext(ctx))
+		assert.Equal(t, "success handler called", w.Body.String())
+		assert.Equal(t, 4, attempts)
+		return clock.Slept()
+	}
+
+	// FacebookHandler with a RetryPolicy and a FakeClock, assert that:
+	// - backoff sleeps advance the FakeClock rather than waiting
+	// - delays double from the BaseDelay, with jitter in [delay/2, delay]
+	// - the jitter is deterministic for the entropy
+	start := time.Now()
+	sleeps, slept := retry(1)
+	assert.True(t, time.Since(start) < time.Second)
+	assert.Equal(t, 3, sleeps)
+	assert.True(t, slept >= (1+2+4)*time.Second/2 && slept <= (1+2+4)*time.Second, slept.String())
+	_, again := retry(1)
+	assert.Equal(t, slept, again)
+}
+
+func TestFacebookHandler_Cache(t *testing.T) {
+	proxyClient, mux, server := testutils.TestServer()
+	defer server.Close()
+	requests := 0
+	mux.HandleFunc("/v2.9/me", func(w http.ResponseWriter, req *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "application/json")
+		if req.Header.Get("Authorization") == "Bearer invalid-token" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, `{"error": {"message": "Invalid OAuth access token.", "type": "OAuthException", "code": 190}}`)
+			return
+		}
+		fmt.Fprintf(w, `{"id": "54638001", "name": "Ivy Crimson"}`)
+	})
+	now := time.Unix(1700000000, 0)
+	cache := gologin.NewLRUCache(10)
+	cache.Now = func() time.Time { return now }
+	fbConfig := Config{Cache: cache, CacheTTL: time.Minute, NegativeCacheTTL: 10 * time.Second}
+	success := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		assert.Nil(t, err)
+		user, err := UserFromContext(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, "54638001", user.ID)
+		// modifying the User must not modify the cached User
+		user.Name = "modified"
+		fmt.Fprintf(w, "success %s", token.AccessToken)
+	}
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		assert.True(t, IsInvalidToken(gologin.ErrorFromContext(req.Context())))
+		fmt.Fprintf(w, "failure handler called")
+	}
+	handler := facebookHandler(&oauth2.Config{}, fbConfig, http.HandlerFunc(success), http.HandlerFunc(failure))
+	serve := func(accessToken string) string {
+		ctx := gologin.WithHTTPClient(context.Background(), proxyClient)
+		ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: accessToken})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		handler.ServeHTTP(w, req.WithContext(ctx))
+		return w.Body.String()
+	}
+
+	// FacebookHandler with a Cache, assert that:
+	// - a miss gets the User from the Graph API
+	assert.Equal(t, "success any-token", serve("any-token"))
+	assert.Equal(t, 1, requests)
+	// - a hit adds the Token and cached User to the ctx without a request
+	assert.Equal(t, "success any-token", serve("any-token"))
+	assert.Equal(t, 1, requests)
+	// - other tokens miss
+	assert.Equal(t, "success other-token", serve("other-token"))
+	assert.Equal(t, 2, requests)
+	// - keys are hashed, never the raw token
+	key := internal.TokenCacheKey("facebook:id,name,email,first_name,last_name,picture,locale", "any-token")
+	assert.NotContains(t, key, "any-token")
+	value, ok := cache.Get(key)
+	if assert.True(t, ok) {
+		assert.Equal(t, "Ivy Crimson", value.(*cachedUser).user.Name)
+	}
+	// - Users expire after the CacheTTL
+	now = now.Add(time.Minute)
+	assert.Equal(t, "success any-token", serve("any-token"))
+	assert.Equal(t, 3, requests)
+
+	// - invalid token errors are cached for the NegativeCacheTTL
+	assert.Equal(t, "failure handler called", serve("invalid-token"))
+	assert.Equal(t, "failure handler called", serve("invalid-token"))
+	assert.Equal(t, 4, requests)
+	now = now.Add(10 * time.Second)
+	assert.Equal(t, "failure handler called", serve("invalid-token"))
+	assert.Equal(t, 5, requests)
+}
+
+func TestFacebookHandler_CacheTransientErrors(t *testing.T) {
+	proxyClient, mux, server := testutils.TestServer()
+	defer server.Close()
+	requests := 0
+	mux.HandleFunc("/v2.9/me", func(w http.ResponseWriter, req *http.Request) {
+		requests++
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	})
+	fbConfig := Config{Cache: gologin.NewLRUCache(10), NegativeCacheTTL: time.Minute}
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(w, "failure handler called")
+	}
+	handler := facebookHandler(&oauth2.Config{}, fbConfig, success, http.HandlerFunc(failure))
+
+	// FacebookHandler with a Cache, assert that:
+	// - errors other than invalid tokens are not cached
+	for i := 0; i < 2; i++ {
+		ctx := gologin.WithHTTPClient(context.Background(), proxyClient)
+		ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		handler.ServeHTTP(w, req.WithContext(ctx))
+		assert.Equal(t, "failure handler called", w.Body.String())
+	}
+	assert.Equal(t, 2, requests)
+}
+
+func TestRevokeHandler(t *testing.T) {
+	cases := []struct {
+		status   int
+		jsonData string
+		err      error
+	}{
+		{http.StatusOK, `{"success": true}`, nil},
+		// token already invalid
+		{http.StatusBadRequest, `{"error": {"message": "Error validating access token", "type": "OAuthException", "code": 190}}`, nil},
+		{http.StatusBadRequest, `{"error": {"message": "Unsupported delete request", "type": "GraphMethodException", "code": 100}}`, ErrUnableToRevokeFacebookPermissions},
+		{http.StatusInternalServerError, `{}`, ErrUnableToRevokeFacebookPermissions},
+	}
+	for _, c := range cases {
+		proxyClient, server := newFacebookRevokeServer(c.status, c.jsonData)
+		// oauth2 Client will use the proxy client's base Transport
+		ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+		ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+
+		success := func(w http.ResponseWriter, req *http.Request) {
+			assert.Nil(t, c.err)
+			fmt.Fprintf(w, "success handler called")
+		}
+		failure := func(w http.ResponseWriter, req *http.Request) {
+			assert.Equal(t, c.err, gologin.ErrorFromContext(req.Context()))
+			fmt.Fprintf(w, "failure handler called")
+		}
+
+		// RevokeHandler assert that:
+		// - the Token's permissions are deleted with the facebook API
+		// - invalid tokens are treated as revoked
+		// - failure handler is called if permissions cannot be revoked
+		revokeHandler := RevokeHandler(&oauth2.Config{}, http.HandlerFunc(success), http.HandlerFunc(failure))
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/logout", nil)
+		revokeHandler.ServeHTTP(w, req.WithContext(ctx))
+		if c.err == nil {
+			assert.Equal(t, "success handler called", w.Body.String())
+		} else {
+			assert.Equal(t, "failure handler called", w.Body.String())
+		}
+		server.Close()
+	}
 }
 
 func TestValidateResponse(t *testing.T) {
@@ -102,7 +982,40 @@ func TestValidateResponse(t *testing.T) {
 	validResponse := &http.Response{StatusCode: 200}
 	invalidResponse := &http.Response{StatusCode: 500}
 	assert.Equal(t, nil, validateResponse(validUser, validResponse, nil))
-	assert.Equal(t, ErrUnableToGetFacebookUser, validateResponse(validUser, validResponse, fmt.Errorf("Server error")))
-	assert.Equal(t, ErrUnableToGetFacebookUser, validateResponse(validUser, invalidResponse, nil))
-	assert.Equal(t, ErrUnableToGetFacebookUser, validateResponse(&User{}, validResponse, nil))
+	assert.True(t, errors.Is(validateResponse(validUser, validResponse, fmt.Errorf("Server error")), ErrUnableToGetFacebookUser))
+	assert.True(t, errors.Is(validateResponse(validUser, invalidResponse, nil), ErrUnableToGetFacebookUser))
+	assert.True(t, errors.Is(validateResponse(&User{}, validResponse, nil), ErrUnableToGetFacebookUser))
+}
+
+// TestValidateResponse_Cause asserts that:
+// - errors match ErrUnableToGetFacebookUser and unwrap to the cause
+// - the status code is kept, including for nil responses (status 0)
+// - the cause survives gologin.WithError and ErrorFromContext
+func TestValidateResponse_Cause(t *testing.T) {
+	netErr := errors.New("dial tcp: connection refused")
+	graphErr := &GraphError{Message: "An unknown error has occurred.", Type: "OAuthException", Code: 1}
+	cases := []struct {
+		name    string
+		user    *User
+		resp    *http.Response
+		err     error
+		status  int
+		message string
+	}{
+		{"nil response", nil, nil, netErr, 0, "facebook: unable to get Facebook User: dial tcp: connection refused"},
+		{"non-200 with body", &User{}, &http.Response{StatusCode: 500, Body: ioutil.NopCloser(strings.NewReader(`{"error": {}}`))}, graphErr, 500, "facebook: unable to get Facebook User (status 500): " + graphErr.Error()},
+		{"non-200 without error", &User{}, &http.Response{StatusCode: 503}, nil, 503, "facebook: unable to get Facebook User (status 503)"},
+		{"empty ID", &User{Name: "Ivy Crimson"}, &http.Response{StatusCode: 200}, nil, 200, "facebook: unable to get Facebook User (status 200)"},
+	}
+	for _, c := range cases {
+		ctx := gologin.WithError(context.Background(), validateResponse(c.user, c.resp, c.err))
+		err := gologin.ErrorFromContext(ctx)
+		assert.True(t, errors.Is(err, ErrUnableToGetFacebookUser), c.name)
+		assert.Equal(t, c.err, errors.Unwrap(err), c.name)
+		var providerErr *gologin.Error
+		if assert.True(t, errors.As(err, &providerErr), c.name) {
+			assert.Equal(t, c.status, providerErr.StatusCode, c.name)
+		}
+		assert.Equal(t, c.message, err.Error(), c.name)
+	}
 }
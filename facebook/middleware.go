@@ -0,0 +1,52 @@
+package facebook
+
+import (
+	"net/http"
+
+	"github.com/dghubble/gologin"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"golang.org/x/oauth2"
+)
+
+// StateMiddleware returns middleware which wraps the next handler with a
+// StateHandler, so chains can be declared linearly (e.g. with alice or chi):
+//
+//	login := alice.New(facebook.StateMiddleware(stateConfig)).Then(facebook.LoginHandler(config, nil))
+//	callback := alice.New(facebook.StateMiddleware(stateConfig), facebook.CallbackMiddleware(config, nil)).Then(success)
+//
+// Each chain behaves the same as its nested form.
+func StateMiddleware(config gologin.CookieConfig) func(http.Handler) http.Handler {
+	return oauth2Login.StateMiddleware(config)
+}
+
+// CallbackMiddleware returns middleware which wraps the next handler with a
+// CallbackHandler, which calls the next handler if authentication succeeds,
+// otherwise the failure handler.
+func CallbackMiddleware(config *oauth2.Config, failure http.Handler, opts ...oauth2.AuthCodeOption) func(http.Handler) http.Handler {
+	return CallbackMiddlewareWithConfig(config, Config{}, failure, opts...)
+}
+
+// CallbackMiddlewareWithConfig returns middleware which wraps the next
+// handler with a CallbackHandlerWithConfig. Panics if the Config APIVersion
+// is invalid.
+func CallbackMiddlewareWithConfig(config *oauth2.Config, fbConfig Config, failure http.Handler, opts ...oauth2.AuthCodeOption) func(http.Handler) http.Handler {
+	fbConfig = fbConfig.mustNormalize()
+	return func(next http.Handler) http.Handler {
+		return CallbackHandlerWithConfig(config, fbConfig, next, failure, opts...)
+	}
+}
+
+// UserMiddleware returns middleware which gets the Facebook User (and, per
+// the Config, exchanges the Token or gets Permissions) for the ctx Token,
+// like CallbackHandlerWithConfig does after the oauth2 CallbackHandler. Use
+// it after an oauth2 CallbackMiddleware:
+//
+//	alice.New(oauth2.StateMiddleware(stateConfig), oauth2.CallbackMiddleware(config, failure), facebook.UserMiddleware(config, facebook.Config{}, failure)).Then(success)
+//
+// Panics if the Config APIVersion is invalid.
+func UserMiddleware(config *oauth2.Config, fbConfig Config, failure http.Handler) func(http.Handler) http.Handler {
+	fbConfig = fbConfig.mustNormalize()
+	return func(next http.Handler) http.Handler {
+		return userHandler(config, fbConfig, next, failure)
+	}
+}
@@ -0,0 +1,103 @@
+package facebook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dghubble/gologin"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+// chainResult is the ctx contents seen by the success or failure handler of
+// a callback chain.
+type chainResult struct {
+	called      string
+	accessToken string
+	user        *User
+	err         error
+}
+
+// runCallbackChain serves a callback request with the state cookie to the
+// chain built by newChain and returns the ctx contents seen by the success
+// or failure handler.
+func runCallbackChain(ctx context.Context, stateCookie string, newChain func(success, failure http.Handler) http.Handler) chainResult {
+	var result chainResult
+	success := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		result.called = "success"
+		if token, err := oauth2Login.TokenFromContext(ctx); err == nil {
+			result.accessToken = token.AccessToken
+		}
+		result.user, _ = UserFromContext(ctx)
+	}
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		result.called = "failure"
+		result.err = gologin.ErrorFromContext(req.Context())
+	}
+	handler := newChain(http.HandlerFunc(success), http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/facebook/callback?code=any_code&state=d4e5f6", nil)
+	req.AddCookie(&http.Cookie{Name: "facebook-state", Value: stateCookie})
+	handler.ServeHTTP(w, req.WithContext(ctx))
+	return result
+}
+
+// chain applies the middleware in order to the handler, like alice.
+func chain(handler http.Handler, middleware ...func(http.Handler) http.Handler) http.Handler {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		handler = middleware[i](handler)
+	}
+	return handler
+}
+
+func TestMiddleware(t *testing.T) {
+	proxyClient, mux, server := testutils.TestServer()
+	defer server.Close()
+	mux.HandleFunc("/v2.9/oauth/access_token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token": "any-token", "token_type": "bearer", "expires_in": 5183944}`)
+	})
+	mux.HandleFunc("/v2.9/me", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"id": "54638001", "name": "Ivy Crimson"}`)
+	})
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+
+	stateConfig := gologin.CookieConfig{Name: "facebook-state", Path: "/", MaxAge: 60}
+	config := &oauth2.Config{
+		ClientID:     "client_id",
+		ClientSecret: "client_secret",
+		Endpoint: oauth2.Endpoint{
+			TokenURL: "https://graph.facebook.com/v2.9/oauth/access_token",
+		},
+	}
+	forms := map[string]func(success, failure http.Handler) http.Handler{
+		"nested": func(success, failure http.Handler) http.Handler {
+			return StateHandler(stateConfig, CallbackHandler(config, success, failure))
+		},
+		"CallbackMiddleware": func(success, failure http.Handler) http.Handler {
+			return chain(success, StateMiddleware(stateConfig), CallbackMiddleware(config, failure))
+		},
+		"UserMiddleware": func(success, failure http.Handler) http.Handler {
+			return chain(success, oauth2Login.StateMiddleware(stateConfig), oauth2Login.CallbackMiddleware(config, failure), UserMiddleware(config, Config{}, failure))
+		},
+	}
+
+	// Middleware chains declared linearly, assert that:
+	// - the success handler sees the same Token and User as the nested form
+	// - the failure handler sees the same error as the nested form
+	expectedSuccess := chainResult{called: "success", accessToken: "any-token", user: &User{ID: "54638001", Name: "Ivy Crimson", Raw: json.RawMessage(`{"id": "54638001", "name": "Ivy Crimson"}`)}}
+	expectedFailure := chainResult{called: "failure", err: oauth2Login.ErrInvalidState}
+	for name, newChain := range forms {
+		assert.Equal(t, expectedSuccess, runCallbackChain(ctx, "d4e5f6", newChain), name)
+		assert.Equal(t, expectedFailure, runCallbackChain(ctx, "other", newChain), name)
+	}
+}
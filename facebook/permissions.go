@@ -0,0 +1,138 @@
+package facebook
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/internal"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"golang.org/x/oauth2"
+)
+
+// Errors which may occur checking a User's permissions.
+var (
+	ErrUnableToGetPermissions     = errors.New("facebook: unable to get Facebook permissions")
+	ErrMissingRequiredPermissions = errors.New("facebook: required Facebook permissions were declined")
+)
+
+// Permission statuses.
+const (
+	PermissionGranted  = "granted"
+	PermissionDeclined = "declined"
+)
+
+// Permission is the status of a permission (scope) the app requested.
+type Permission struct {
+	Permission string `json:"permission"`
+	Status     string `json:"status"`
+}
+
+// Permissions are the statuses of the permissions the app requested.
+type Permissions []Permission
+
+// permissionsResponse is a Facebook /me/permissions response.
+type permissionsResponse struct {
+	Data Permissions `json:"data"`
+}
+
+// Granted returns the granted permissions.
+func (p Permissions) Granted() []string {
+	return p.withStatus(PermissionGranted)
+}
+
+// Declined returns the declined permissions.
+func (p Permissions) Declined() []string {
+	return p.withStatus(PermissionDeclined)
+}
+
+// IsGranted returns true if the permission was granted.
+func (p Permissions) IsGranted(permission string) bool {
+	for _, perm := range p {
+		if perm.Permission == permission {
+			return perm.Status == PermissionGranted
+		}
+	}
+	return false
+}
+
+func (p Permissions) withStatus(status string) []string {
+	var permissions []string
+	for _, perm := range p {
+		if perm.Status == status {
+			permissions = append(permissions, perm.Permission)
+		}
+	}
+	return permissions
+}
+
+// Permissions returns the statuses of the permissions the app requested
+// from the current user.
+func (c *client) Permissions(ctx context.Context) (Permissions, *http.Response, error) {
+	permissionsResp := new(permissionsResponse)
+	apiErr := new(apiError)
+	resp, err := c.json.Get(ctx, "me/permissions", c.query(nil), permissionsResp, apiErr)
+	if err == nil && apiErr.Error.Message != "" {
+		err = &apiErr.Error
+	}
+	return permissionsResp.Data, resp, err
+}
+
+// permissionsHandler is a http.Handler that gets the Facebook Token from the
+// ctx and gets the user's Permissions. If successful, the Permissions (and
+// the granted permissions as oauth2 granted scopes) are added to the ctx. If
+// any of the Config RequiredPermissions were not granted, the failure handler
+// is called with ErrMissingRequiredPermissions, which also matches oauth2
+// ErrMissingScopes (the Permissions are still added to the ctx, e.g. to offer
+// a rerequest).
+// Otherwise, the success handler is called.
+func permissionsHandler(config *oauth2.Config, fbConfig Config, success, failure http.Handler) http.Handler {
+	fbConfig = fbConfig.mustNormalize()
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		httpClient := internal.OAuth2TransportClient(ctx, fbConfig.Transport, config, token)
+		facebookService := newClient(httpClient, fbConfig.APIVersion, fbConfig.appSecretProof(token))
+		permissions, resp, err := facebookService.Permissions(ctx)
+		if err != nil || resp.StatusCode != http.StatusOK {
+			var status int
+			if resp != nil {
+				status = resp.StatusCode
+			}
+			ctx = gologin.WithError(ctx, &gologin.Error{Provider: "facebook", Op: "get permissions", StatusCode: status, Err: err, Kind: ErrUnableToGetPermissions})
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		ctx = WithPermissions(ctx, permissions)
+		ctx = oauth2Login.WithGrantedScopes(ctx, permissions.Granted()...)
+		if missing := missingPermissions(permissions, fbConfig.RequiredPermissions); len(missing) > 0 {
+			err := oauth2Login.MissingScopesError(missing)
+			ctx = gologin.WithError(ctx, &gologin.Error{Provider: "facebook", Op: "check permissions", Err: err, Kind: ErrMissingRequiredPermissions})
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// missingPermissions returns the required permissions which were not
+// granted.
+func missingPermissions(permissions Permissions, required []string) []string {
+	var missing []string
+	for _, permission := range required {
+		if !permissions.IsGranted(permission) {
+			missing = append(missing, permission)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,149 @@
+package facebook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/dghubble/gologin"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+const testPermissionsJSON = `{"data": [{"permission": "public_profile", "status": "granted"}, {"permission": "email", "status": "declined"}]}`
+
+func TestPermissions(t *testing.T) {
+	permissions := Permissions{
+		{Permission: "public_profile", Status: PermissionGranted},
+		{Permission: "email", Status: PermissionDeclined},
+	}
+	assert.Equal(t, []string{"public_profile"}, permissions.Granted())
+	assert.Equal(t, []string{"email"}, permissions.Declined())
+	assert.True(t, permissions.IsGranted("public_profile"))
+	assert.False(t, permissions.IsGranted("email"))
+	assert.False(t, permissions.IsGranted("user_friends"))
+	assert.Equal(t, []string{"email", "user_friends"}, missingPermissions(permissions, []string{"public_profile", "email", "user_friends"}))
+}
+
+func TestPermissionsHandler(t *testing.T) {
+	proxyClient, mux, server := testutils.TestServer()
+	defer server.Close()
+	mux.HandleFunc("/v2.9/me/permissions", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, testPermissionsJSON)
+	})
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+
+	success := func(w http.ResponseWriter, req *http.Request) {
+		permissions, err := PermissionsFromContext(req.Context())
+		if assert.Nil(t, err) {
+			assert.Equal(t, []string{"public_profile"}, permissions.Granted())
+			assert.Equal(t, []string{"email"}, permissions.Declined())
+		}
+		scopes, err := oauth2Login.GrantedScopesFromContext(req.Context())
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"public_profile"}, scopes)
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// PermissionsHandler without RequiredPermissions, assert that:
+	// - granted and declined Permissions are added to the ctx
+	// - granted permissions are added to the ctx as granted scopes
+	// - success handler is called
+	handler := permissionsHandler(&oauth2.Config{}, Config{FetchPermissions: true}, http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	handler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestPermissionsHandler_MissingRequiredPermissions(t *testing.T) {
+	proxyClient, mux, server := testutils.TestServer()
+	defer server.Close()
+	mux.HandleFunc("/v2.9/me/permissions", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, testPermissionsJSON)
+	})
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		err := gologin.ErrorFromContext(ctx)
+		assert.True(t, errors.Is(err, ErrMissingRequiredPermissions))
+		assert.True(t, errors.Is(err, oauth2Login.ErrMissingScopes))
+		assert.Contains(t, err.Error(), "email")
+		// Permissions are available to offer a rerequest
+		permissions, err := PermissionsFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, []string{"email"}, permissions.Declined())
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// PermissionsHandler with a declined required permission, assert that:
+	// - failure handler is called with ErrMissingRequiredPermissions
+	handler := permissionsHandler(&oauth2.Config{}, Config{RequiredPermissions: []string{"email"}}, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	handler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestPermissionsHandler_Error(t *testing.T) {
+	proxyClient, server := testutils.NewErrorServer("Facebook Service Down", http.StatusInternalServerError)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		assert.True(t, errors.Is(gologin.ErrorFromContext(req.Context()), ErrUnableToGetPermissions))
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// PermissionsHandler cannot get Permissions, assert that:
+	// - failure handler is called with ErrUnableToGetPermissions
+	handler := permissionsHandler(&oauth2.Config{}, Config{FetchPermissions: true}, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	handler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestRerequestHandler(t *testing.T) {
+	config := &oauth2.Config{
+		ClientID: "client_id",
+		Scopes:   []string{"email"},
+		Endpoint: oauth2.Endpoint{AuthURL: "https://www.facebook.com/dialog/oauth"},
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// RerequestHandler before LoginHandler, assert that:
+	// - auth_type=rerequest is added to the AuthURL
+	// - ctx AuthCodeOptions are kept
+	handler := RerequestHandler(LoginHandler(config, failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	ctx := oauth2Login.WithState(context.Background(), "state_val")
+	ctx = oauth2Login.WithAuthCodeOptions(ctx, oauth2.SetAuthURLParam("display", "popup"))
+	handler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, http.StatusFound, w.Code)
+	location, err := url.Parse(w.HeaderMap.Get("Location"))
+	if assert.Nil(t, err) {
+		assert.Equal(t, "rerequest", location.Query().Get("auth_type"))
+		assert.Equal(t, "popup", location.Query().Get("display"))
+	}
+}
@@ -8,6 +8,15 @@ import (
 	"github.com/dghubble/gologin/testutils"
 )
 
+const (
+	// testFullUserJSON is a /me response with every default field
+	testFullUserJSON = `{"id": "54638001", "name": "Ivy Crimson", "email": "ivy@harvard.edu", "first_name": "Ivy", "last_name": "Crimson", "locale": "en_US",
+		"picture": {"data": {"url": "https://example.com/ivy.jpg", "width": 50, "height": 50, "is_silhouette": true}}}`
+	// testMinimalUserJSON is a /me response of a user who granted only
+	// public_profile and hides optional fields
+	testMinimalUserJSON = `{"id": "54638001", "name": "Ivy Crimson"}`
+)
+
 // newFacebookTestServer returns a new httptest.Server which mocks the Facebook
 // user endpoint and a client which proxies requests to the server. The server
 // responds with the given json data. The caller must close the server.
@@ -19,3 +28,21 @@ func newFacebookTestServer(jsonData string) (*http.Client, *httptest.Server) {
 	})
 	return client, server
 }
+
+// newFacebookRevokeServer returns a new httptest.Server which mocks the
+// Facebook permissions endpoint and a client which proxies requests to the
+// server. The server responds with the given status and json data. The
+// caller must close the server.
+func newFacebookRevokeServer(status int, jsonData string) (*http.Client, *httptest.Server) {
+	client, mux, server := testutils.TestServer()
+	mux.HandleFunc("/v2.9/me/permissions", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "DELETE" {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		fmt.Fprintf(w, jsonData)
+	})
+	return client, server
+}
@@ -0,0 +1,100 @@
+package facebook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/dghubble/gologin"
+)
+
+const signedRequestField = "signed_request"
+
+// Errors which may occur parsing a signed_request.
+var (
+	ErrMissingSignedRequest          = errors.New("facebook: missing signed_request")
+	ErrInvalidSignedRequest          = errors.New("facebook: malformed signed_request")
+	ErrInvalidSignedRequestSignature = errors.New("facebook: invalid signed_request signature")
+	ErrUnsupportedSignedRequestAlg   = errors.New("facebook: signed_request algorithm is not HMAC-SHA256")
+)
+
+// SignedRequest is the payload of a Facebook signed_request, such as those
+// POSTed to deauthorize and data deletion callbacks.
+type SignedRequest struct {
+	UserID    string `json:"user_id"`
+	Algorithm string `json:"algorithm"`
+	IssuedAt  int64  `json:"issued_at"`
+	Expires   int64  `json:"expires"`
+}
+
+// ParseSignedRequest verifies the raw signed_request (a base64url encoded
+// signature and payload separated by ".") is signed with HMAC-SHA256 using
+// the app secret, and returns its decoded payload.
+func ParseSignedRequest(raw, appSecret string) (*SignedRequest, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, ErrInvalidSignedRequest
+	}
+	signature, err := decodeBase64URL(parts[0])
+	if err != nil {
+		return nil, ErrInvalidSignedRequest
+	}
+	payload, err := decodeBase64URL(parts[1])
+	if err != nil {
+		return nil, ErrInvalidSignedRequest
+	}
+	signedRequest := new(SignedRequest)
+	if err := json.Unmarshal(payload, signedRequest); err != nil {
+		return nil, ErrInvalidSignedRequest
+	}
+	if strings.ToUpper(signedRequest.Algorithm) != "HMAC-SHA256" {
+		return nil, ErrUnsupportedSignedRequestAlg
+	}
+	// the signature is computed over the encoded payload
+	mac := hmac.New(sha256.New, []byte(appSecret))
+	mac.Write([]byte(parts[1]))
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return nil, ErrInvalidSignedRequestSignature
+	}
+	return signedRequest, nil
+}
+
+// decodeBase64URL decodes base64url with or without "=" padding.
+func decodeBase64URL(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
+
+// SignedRequestHandler handles Facebook callbacks which POST a
+// signed_request (e.g. deauthorize and data deletion callbacks). The
+// signed_request is verified with the app secret and the parsed
+// SignedRequest is added to the ctx. If successful, handling delegates to the
+// success handler (which should act on the SignedRequest UserID), otherwise
+// to the failure handler. Non-POST requests and oversized or non-form bodies
+// are rejected by gologin.BodyHandler.
+func SignedRequestHandler(appSecret string, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		raw := req.PostFormValue(signedRequestField)
+		if raw == "" {
+			ctx = gologin.WithError(ctx, ErrMissingSignedRequest)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		signedRequest, err := ParseSignedRequest(raw, appSecret)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		ctx = WithSignedRequest(ctx, signedRequest)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return gologin.BodyHandler(gologin.BodyConfig{}, http.HandlerFunc(fn), failure)
+}
@@ -0,0 +1,136 @@
+package facebook
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+)
+
+// signed_request fixtures signed with the secret "app-secret"
+const (
+	testSignedRequestSecret = "app-secret"
+	// payload {"algorithm":"HMAC-SHA256","issued_at":1700000000,"user_id":"54638001"}
+	testSignedRequest = "8OXmpSwB7OPCzOoN5vWjorENqY3SgrEVHY6z_5ZTCuc.eyJhbGdvcml0aG0iOiJITUFDLVNIQTI1NiIsImlzc3VlZF9hdCI6MTcwMDAwMDAwMCwidXNlcl9pZCI6IjU0NjM4MDAxIn0"
+	// payload {"algorithm":"HMAC-SHA256","issued_at":1700000000,"user_id":"1"}
+	// whose unpadded encoding length requires "==" padding
+	testSignedRequestShort = "5eA_qUOH0UpsdvHmY8Ua11KgZyNf7GPRNO8CFDYH2Mg.eyJhbGdvcml0aG0iOiJITUFDLVNIQTI1NiIsImlzc3VlZF9hdCI6MTcwMDAwMDAwMCwidXNlcl9pZCI6IjEifQ"
+	// payload {"algorithm":"HMAC-SHA1","issued_at":1700000000,"user_id":"54638001"}
+	testSignedRequestSHA1 = "8OXmpSwB7OPCzOoN5vWjorENqY3SgrEVHY6z_5ZTCuc.eyJhbGdvcml0aG0iOiJITUFDLVNIQTEiLCJpc3N1ZWRfYXQiOjE3MDAwMDAwMDAsInVzZXJfaWQiOiI1NDYzODAwMSJ9"
+)
+
+func TestParseSignedRequest(t *testing.T) {
+	signedRequest, err := ParseSignedRequest(testSignedRequest, testSignedRequestSecret)
+	if assert.Nil(t, err) {
+		assert.Equal(t, &SignedRequest{UserID: "54638001", Algorithm: "HMAC-SHA256", IssuedAt: 1700000000}, signedRequest)
+	}
+	signedRequest, err = ParseSignedRequest(testSignedRequestShort, testSignedRequestSecret)
+	if assert.Nil(t, err) {
+		assert.Equal(t, "1", signedRequest.UserID)
+	}
+	// padded signature
+	parts := strings.Split(testSignedRequest, ".")
+	signedRequest, err = ParseSignedRequest(parts[0]+"="+"."+parts[1], testSignedRequestSecret)
+	if assert.Nil(t, err) {
+		assert.Equal(t, "54638001", signedRequest.UserID)
+	}
+}
+
+func TestParseSignedRequest_Errors(t *testing.T) {
+	parts := strings.Split(testSignedRequest, ".")
+	// change the first signature character
+	tampered := "9" + parts[0][1:] + "." + parts[1]
+	cases := []struct {
+		raw    string
+		secret string
+		err    error
+	}{
+		{testSignedRequest, "wrong-secret", ErrInvalidSignedRequestSignature},
+		{tampered, testSignedRequestSecret, ErrInvalidSignedRequestSignature},
+		// payload signed for another user
+		{parts[0] + "." + strings.Split(testSignedRequestShort, ".")[1], testSignedRequestSecret, ErrInvalidSignedRequestSignature},
+		{testSignedRequestSHA1, testSignedRequestSecret, ErrUnsupportedSignedRequestAlg},
+		{"", testSignedRequestSecret, ErrInvalidSignedRequest},
+		{parts[0], testSignedRequestSecret, ErrInvalidSignedRequest},
+		{parts[0] + ".not+base64url", testSignedRequestSecret, ErrInvalidSignedRequest},
+		{parts[0] + ".bm90LWpzb24", testSignedRequestSecret, ErrInvalidSignedRequest},
+		{testSignedRequest + ".extra", testSignedRequestSecret, ErrInvalidSignedRequest},
+	}
+	for _, c := range cases {
+		_, err := ParseSignedRequest(c.raw, c.secret)
+		assert.Equal(t, c.err, err, c.raw)
+	}
+}
+
+func TestSignedRequestHandler(t *testing.T) {
+	success := func(w http.ResponseWriter, req *http.Request) {
+		signedRequest, err := SignedRequestFromContext(req.Context())
+		if assert.Nil(t, err) {
+			assert.Equal(t, "54638001", signedRequest.UserID)
+		}
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// SignedRequestHandler assert that:
+	// - the POSTed signed_request is verified
+	// - the SignedRequest is added to the ctx
+	// - success handler is called
+	handler := SignedRequestHandler(testSignedRequestSecret, http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	form := url.Values{"signed_request": {testSignedRequest}}
+	req, _ := http.NewRequest("POST", "/deauthorize", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestSignedRequestHandler_Errors(t *testing.T) {
+	cases := []struct {
+		method string
+		form   url.Values
+		err    error
+	}{
+		{"POST", url.Values{}, ErrMissingSignedRequest},
+		{"POST", url.Values{"signed_request": {testSignedRequestSHA1}}, ErrUnsupportedSignedRequestAlg},
+		{"POST", url.Values{"signed_request": {testSignedRequest + "x"}}, ErrInvalidSignedRequest},
+	}
+	success := testutils.AssertSuccessNotCalled(t)
+	for _, c := range cases {
+		failure := func(w http.ResponseWriter, req *http.Request) {
+			assert.Equal(t, c.err, gologin.ErrorFromContext(req.Context()))
+			fmt.Fprintf(w, "failure handler called")
+		}
+
+		// SignedRequestHandler with an invalid signed_request, assert that:
+		// - failure handler is called with a distinguishable error
+		handler := SignedRequestHandler(testSignedRequestSecret, success, http.HandlerFunc(failure))
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(c.method, "/deauthorize", strings.NewReader(c.form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		handler.ServeHTTP(w, req)
+		assert.Equal(t, "failure handler called", w.Body.String())
+	}
+}
+
+func TestSignedRequestHandler_NonPost(t *testing.T) {
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		assert.NotNil(t, gologin.ErrorFromContext(req.Context()))
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// SignedRequestHandler with a GET request, assert that:
+	// - failure handler is called
+	handler := SignedRequestHandler(testSignedRequestSecret, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/deauthorize?signed_request="+testSignedRequest, nil)
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
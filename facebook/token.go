@@ -0,0 +1,280 @@
+package facebook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/internal"
+	"github.com/dghubble/gologin/internal/jsonclient"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"golang.org/x/oauth2"
+)
+
+const accessTokenField = "access_token"
+
+// Facebook token errors
+var (
+	ErrUnableToExchangeToken = errors.New("facebook: unable to exchange for a long-lived token")
+	ErrMissingToken          = fmt.Errorf("facebook: missing token field %s", accessTokenField)
+	ErrUnableToDebugToken    = errors.New("facebook: unable to debug access token")
+	ErrTokenAppMismatch      = errors.New("facebook: access token was issued to a different app")
+	ErrTokenExpired          = errors.New("facebook: access token expired")
+	ErrInvalidToken          = errors.New("facebook: access token is not valid")
+)
+
+// debugTokenResponse is a Facebook /debug_token response.
+type debugTokenResponse struct {
+	Data struct {
+		AppID     string `json:"app_id"`
+		UserID    string `json:"user_id"`
+		IsValid   bool   `json:"is_valid"`
+		ExpiresAt int64  `json:"expires_at"`
+	} `json:"data"`
+}
+
+// TokenHandler receives a Facebook access token obtained natively by a mobile
+// app as a POSTed "access_token" form or JSON field and verifies it with
+// /debug_token using the app token (config ClientID|ClientSecret). The token
+// must be valid, unexpired, and issued to the config ClientID. If so, the
+// Token and User are added to the ctx like CallbackHandler and the success
+// handler is called. Otherwise, the failure handler is called with
+// ErrMissingToken, ErrTokenAppMismatch, ErrTokenExpired, ErrInvalidToken, or
+// ErrUnableToDebugToken. Requests are hardened with gologin.BodyHandler, so
+// non-POST requests, other content types, and bodies over 64KB fail with a
+// typed gologin error.
+func TokenHandler(config *oauth2.Config, success, failure http.Handler) http.Handler {
+	return TokenHandlerWithConfig(config, Config{}, success, failure)
+}
+
+// TokenHandlerWithConfig handles mobile access tokens like TokenHandler, but
+// makes Graph API requests according to the Config. Panics if the Config
+// APIVersion is invalid.
+func TokenHandlerWithConfig(config *oauth2.Config, fbConfig Config, success, failure http.Handler) http.Handler {
+	fbConfig = fbConfig.mustNormalize()
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	success = userHandler(config, fbConfig, success, failure)
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		accessToken := parseAccessToken(req)
+		if accessToken == "" {
+			ctx = gologin.WithError(ctx, ErrMissingToken)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		token, err := debugToken(ctx, internal.TransportClient(ctx, fbConfig.Transport), config, fbConfig.APIVersion, accessToken)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		ctx = oauth2Login.WithToken(ctx, token)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return gologin.BodyHandler(gologin.BodyConfig{}, http.HandlerFunc(fn), failure)
+}
+
+// NewTokenVerifier returns an oauth2 TokenVerifier which verifies access
+// tokens with /debug_token like TokenHandler and gets their User from /me
+// according to the Config (without caching). Use it with oauth2 TokenHandler,
+// which adds verified Users to the ctx with WithUser. Panics if the Config
+// APIVersion is invalid.
+func NewTokenVerifier(config *oauth2.Config, fbConfig Config) oauth2Login.UserContextVerifier {
+	return &tokenVerifier{config: config, fbConfig: fbConfig.mustNormalize()}
+}
+
+// tokenVerifier verifies Facebook access tokens.
+type tokenVerifier struct {
+	config   *oauth2.Config
+	fbConfig Config
+}
+
+// VerifyToken debugs the access token and returns its *User.
+func (v *tokenVerifier) VerifyToken(ctx context.Context, accessToken string) (interface{}, error) {
+	token, err := debugToken(ctx, internal.TransportClient(ctx, v.fbConfig.Transport), v.config, v.fbConfig.APIVersion, accessToken)
+	if err != nil {
+		return nil, err
+	}
+	fetchCtx, endFetch := gologin.StartUserFetch(ctx, ProviderName)
+	httpClient := internal.RetryClient(internal.OAuth2TransportClient(fetchCtx, v.fbConfig.Transport, v.config, token), v.fbConfig.Retry)
+	user, resp, err := newClient(httpClient, v.fbConfig.APIVersion, v.fbConfig.appSecretProof(token)).Me(fetchCtx, v.fbConfig.Fields)
+	err = validateResponse(user, resp, err)
+	endFetch(err)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// WithUser adds the verified *User to the ctx.
+func (v *tokenVerifier) WithUser(ctx context.Context, user interface{}) context.Context {
+	if user, ok := user.(*User); ok {
+		return WithUser(ctx, user)
+	}
+	return ctx
+}
+
+// parseAccessToken returns the "access_token" field of a JSON or form body.
+func parseAccessToken(req *http.Request) string {
+	if strings.HasPrefix(req.Header.Get("Content-Type"), "application/json") {
+		var body struct {
+			AccessToken string `json:"access_token"`
+		}
+		json.NewDecoder(req.Body).Decode(&body)
+		return body.AccessToken
+	}
+	return req.PostFormValue(accessTokenField)
+}
+
+// debugToken inspects the access token with GET /debug_token and returns it
+// as a Token if it is valid, unexpired, and was issued to the config
+// ClientID.
+func debugToken(ctx context.Context, httpClient *http.Client, config *oauth2.Config, apiVersion, accessToken string) (*oauth2.Token, error) {
+	params := url.Values{
+		"input_token":  {accessToken},
+		"access_token": {config.ClientID + "|" + config.ClientSecret},
+	}
+	debugResp := new(debugTokenResponse)
+	apiErr := new(apiError)
+	resp, err := jsonclient.New(httpClient, graphAPI+apiVersion+"/").Get(ctx, "debug_token", params, debugResp, apiErr)
+	if err == nil && apiErr.Error.Message != "" {
+		err = &apiErr.Error
+	}
+	var status int
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	if err != nil || status != http.StatusOK {
+		return nil, &gologin.Error{Provider: "facebook", Op: "debug token", StatusCode: status, Err: err, Kind: ErrUnableToDebugToken}
+	}
+	data := debugResp.Data
+	if data.AppID != config.ClientID {
+		return nil, ErrTokenAppMismatch
+	}
+	var expiry time.Time
+	if data.ExpiresAt > 0 {
+		expiry = time.Unix(data.ExpiresAt, 0)
+		if !gologin.ClockFromContext(ctx).Now().Before(expiry) {
+			return nil, ErrTokenExpired
+		}
+	}
+	if !data.IsValid {
+		return nil, ErrInvalidToken
+	}
+	return &oauth2.Token{AccessToken: accessToken, TokenType: "Bearer", Expiry: expiry}, nil
+}
+
+// exchangeResponse is a Facebook fb_exchange_token response. Facebook has
+// sent expires_in as both a number and a string.
+type exchangeResponse struct {
+	AccessToken string          `json:"access_token"`
+	TokenType   string          `json:"token_type"`
+	ExpiresIn   json.RawMessage `json:"expires_in"`
+}
+
+// LongLivedTokenHandler exchanges the short-lived Facebook Token from the ctx
+// for a long-lived (about 60 day) Token using the config ClientID and
+// ClientSecret (the app secret), and replaces the ctx Token with it. Chain it
+// after an oauth2 CallbackHandler, or set the Config LongLivedToken option of
+// CallbackHandlerWithConfig.
+//
+// If the exchange fails, the short-lived Token is kept so login can proceed
+// and the error is added to the ctx (see TokenExchangeErrorFromContext).
+// Handling always delegates to the success handler. Panics if the Config
+// APIVersion is invalid.
+func LongLivedTokenHandler(config *oauth2.Config, fbConfig Config, success http.Handler) http.Handler {
+	fbConfig = fbConfig.mustNormalize()
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if err != nil {
+			ctx = WithTokenExchangeError(ctx, err)
+			success.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		longLived, err := exchangeToken(ctx, internal.TransportClient(ctx, fbConfig.Transport), config, fbConfig.APIVersion, token)
+		if err != nil {
+			ctx = WithTokenExchangeError(ctx, err)
+			success.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		ctx = oauth2Login.WithToken(ctx, longLived)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// exchangeToken exchanges the short-lived Token for a long-lived Token with
+// GET /oauth/access_token. Returns a *gologin.Error of ErrUnableToExchangeToken
+// if the exchange fails.
+func exchangeToken(ctx context.Context, httpClient *http.Client, config *oauth2.Config, apiVersion string, token *oauth2.Token) (*oauth2.Token, error) {
+	params := url.Values{
+		"grant_type":        {"fb_exchange_token"},
+		"client_id":         {config.ClientID},
+		"client_secret":     {config.ClientSecret},
+		"fb_exchange_token": {token.AccessToken},
+	}
+	exchangeResp := new(exchangeResponse)
+	apiErr := new(apiError)
+	resp, err := jsonclient.New(httpClient, graphAPI+apiVersion+"/").Get(ctx, "oauth/access_token", params, exchangeResp, apiErr)
+	if err == nil && apiErr.Error.Message != "" {
+		err = &apiErr.Error
+	}
+	var status int
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	if err != nil || status != http.StatusOK {
+		return nil, &gologin.Error{Provider: "facebook", Op: "exchange token", StatusCode: status, Err: err, Kind: ErrUnableToExchangeToken}
+	}
+	if exchangeResp.AccessToken == "" {
+		return nil, &gologin.Error{Provider: "facebook", Op: "exchange token", StatusCode: status, Err: errors.New("missing access_token"), Kind: ErrUnableToExchangeToken}
+	}
+	expiresIn, err := parseExpiresIn(exchangeResp.ExpiresIn)
+	if err != nil {
+		return nil, &gologin.Error{Provider: "facebook", Op: "exchange token", StatusCode: status, Err: err, Kind: ErrUnableToExchangeToken}
+	}
+	longLived := &oauth2.Token{
+		AccessToken: exchangeResp.AccessToken,
+		TokenType:   exchangeResp.TokenType,
+	}
+	if expiresIn > 0 {
+		longLived.Expiry = gologin.ClockFromContext(ctx).Now().Add(time.Duration(expiresIn) * time.Second)
+	}
+	return longLived, nil
+}
+
+// parseExpiresIn parses an expires_in number or numeric string. A missing
+// expires_in is 0 (no known expiry).
+func parseExpiresIn(raw json.RawMessage) (int64, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return 0, nil
+	}
+	var value interface{}
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return 0, err
+	}
+	var expiresIn int64
+	var err error
+	switch v := value.(type) {
+	case float64:
+		expiresIn = int64(v)
+	case string:
+		expiresIn, err = strconv.ParseInt(v, 10, 64)
+	default:
+		err = fmt.Errorf("unexpected expires_in %s", raw)
+	}
+	if err != nil || expiresIn < 0 {
+		return 0, fmt.Errorf("facebook: invalid expires_in %s", raw)
+	}
+	return expiresIn, nil
+}
@@ -0,0 +1,283 @@
+package facebook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/gologintest"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func TestLongLivedTokenHandler(t *testing.T) {
+	cases := []struct {
+		name        string
+		status      int
+		exchange    string
+		accessToken string
+		expiresIn   time.Duration
+		err         bool
+	}{
+		{"success", http.StatusOK, `{"access_token": "long-token", "token_type": "bearer", "expires_in": 5183944}`, "long-token", 5183944 * time.Second, false},
+		{"string expires_in", http.StatusOK, `{"access_token": "long-token", "token_type": "bearer", "expires_in": "5183944"}`, "long-token", 5183944 * time.Second, false},
+		{"error", http.StatusBadRequest, `{"error": {"message": "Error validating client secret.", "type": "OAuthException", "code": 1}}`, "short-token", time.Hour, true},
+		{"malformed expires_in", http.StatusOK, `{"access_token": "long-token", "token_type": "bearer", "expires_in": "soon"}`, "short-token", time.Hour, true},
+	}
+	for _, c := range cases {
+		proxyClient, mux, server := testutils.TestServer()
+		mux.HandleFunc("/v2.9/oauth/access_token", func(w http.ResponseWriter, req *http.Request) {
+			query := req.URL.Query()
+			assert.Equal(t, "fb_exchange_token", query.Get("grant_type"), c.name)
+			assert.Equal(t, "client_id", query.Get("client_id"), c.name)
+			assert.Equal(t, "client_secret", query.Get("client_secret"), c.name)
+			assert.Equal(t, "short-token", query.Get("fb_exchange_token"), c.name)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(c.status)
+			fmt.Fprintf(w, c.exchange)
+		})
+		mux.HandleFunc("/v2.9/me", func(w http.ResponseWriter, req *http.Request) {
+			// the User is fetched with the resulting Token
+			assert.Equal(t, "Bearer "+c.accessToken, req.Header.Get("Authorization"), c.name)
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"id": "54638001", "name": "Ivy Crimson"}`)
+		})
+		// oauth2 Client will use the proxy client's base Transport
+		ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+		shortToken := &oauth2.Token{AccessToken: "short-token", Expiry: time.Now().Add(time.Hour)}
+		ctx = oauth2Login.WithToken(ctx, shortToken)
+
+		config := &oauth2.Config{ClientID: "client_id", ClientSecret: "client_secret"}
+		success := func(w http.ResponseWriter, req *http.Request) {
+			ctx := req.Context()
+			token, err := oauth2Login.TokenFromContext(ctx)
+			if assert.Nil(t, err, c.name) {
+				assert.Equal(t, c.accessToken, token.AccessToken, c.name)
+				assert.WithinDuration(t, time.Now().Add(c.expiresIn), token.Expiry, time.Minute, c.name)
+			}
+			exchangeErr := TokenExchangeErrorFromContext(ctx)
+			assert.Equal(t, c.err, errors.Is(exchangeErr, ErrUnableToExchangeToken), c.name)
+			assert.Equal(t, c.err, exchangeErr != nil, c.name)
+			fmt.Fprintf(w, "success handler called")
+		}
+		failure := testutils.AssertFailureNotCalled(t)
+
+		// LongLivedTokenHandler before FacebookHandler, assert that:
+		// - the short-lived token is exchanged with fb_exchange_token
+		// - the ctx Token is replaced by the long-lived Token, if successful
+		// - otherwise, the short-lived Token is kept and the error is added
+		// - success handler is called
+		fbConfig := Config{LongLivedToken: true}
+		handler := LongLivedTokenHandler(config, fbConfig, facebookHandler(config, fbConfig, http.HandlerFunc(success), failure))
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		handler.ServeHTTP(w, req.WithContext(ctx))
+		assert.Equal(t, "success handler called", w.Body.String(), c.name)
+		server.Close()
+	}
+}
+
+func TestParseExpiresIn(t *testing.T) {
+	cases := []struct {
+		raw       string
+		expiresIn int64
+		ok        bool
+	}{
+		{"", 0, true},
+		{"null", 0, true},
+		{"3600", 3600, true},
+		{`"3600"`, 3600, true},
+		{`"soon"`, 0, false},
+		{"-1", 0, false},
+		{"true", 0, false},
+	}
+	for _, c := range cases {
+		expiresIn, err := parseExpiresIn([]byte(c.raw))
+		assert.Equal(t, c.expiresIn, expiresIn, c.raw)
+		assert.Equal(t, c.ok, err == nil, c.raw)
+	}
+}
+
+// newDebugTokenServer returns a new httptest.Server which mocks the Facebook
+// debug_token and user endpoints and a client which proxies requests to the
+// server. The debug_token endpoint responds with the status and json data.
+// The caller must close the server.
+func newDebugTokenServer(t *testing.T, status int, debugJSON string) (*http.Client, *httptest.Server) {
+	client, mux, server := testutils.TestServer()
+	mux.HandleFunc("/v2.9/debug_token", func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "mobile-token", req.URL.Query().Get("input_token"))
+		assert.Equal(t, "client_id|client_secret", req.URL.Query().Get("access_token"))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		fmt.Fprintf(w, debugJSON)
+	})
+	mux.HandleFunc("/v2.9/me", func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "Bearer mobile-token", req.Header.Get("Authorization"))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"id": "54638001", "name": "Ivy Crimson"}`)
+	})
+	return client, server
+}
+
+func TestTokenHandler(t *testing.T) {
+	expiresAt := time.Now().Add(time.Hour).Unix()
+	debugJSON := fmt.Sprintf(`{"data": {"app_id": "client_id", "type": "USER", "is_valid": true, "expires_at": %d, "user_id": "54638001"}}`, expiresAt)
+	proxyClient, server := newDebugTokenServer(t, http.StatusOK, debugJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+
+	config := &oauth2.Config{ClientID: "client_id", ClientSecret: "client_secret"}
+	success := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "mobile-token", token.AccessToken)
+			assert.Equal(t, time.Unix(expiresAt, 0), token.Expiry)
+		}
+		user, err := UserFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "54638001", user.ID)
+		}
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+	handler := TokenHandler(config, http.HandlerFunc(success), failure)
+
+	// TokenHandler with a form or JSON access_token, assert that:
+	// - the access token is verified with debug_token
+	// - the Token and User are added to the ctx
+	// - success handler is called
+	form := url.Values{"access_token": {"mobile-token"}}
+	req, _ := http.NewRequest("POST", "/", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+
+	req, _ = http.NewRequest("POST", "/", strings.NewReader(`{"access_token": "mobile-token"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestTokenHandler_Errors(t *testing.T) {
+	// expires_at is compared to the ctx Clock, not the wall clock
+	clock := gologintest.NewFakeClock(time.Unix(1500000000, 0))
+	expired := clock.Now().Add(-time.Second).Unix()
+	unexpired := clock.Now().Add(time.Hour).Unix()
+	cases := []struct {
+		name      string
+		status    int
+		debugJSON string
+		token     string
+		err       error
+	}{
+		{"missing token", http.StatusOK, `{}`, "", ErrMissingToken},
+		{"other app", http.StatusOK, `{"data": {"app_id": "other_app", "is_valid": true, "user_id": "54638001"}}`, "mobile-token", ErrTokenAppMismatch},
+		{"expired", http.StatusOK, fmt.Sprintf(`{"data": {"app_id": "client_id", "is_valid": false, "expires_at": %d, "user_id": "54638001"}}`, expired), "mobile-token", ErrTokenExpired},
+		{"invalid", http.StatusOK, `{"data": {"app_id": "client_id", "is_valid": false, "user_id": "54638001"}}`, "mobile-token", ErrInvalidToken},
+		{"invalid unexpired", http.StatusOK, fmt.Sprintf(`{"data": {"app_id": "client_id", "is_valid": false, "expires_at": %d, "user_id": "54638001"}}`, unexpired), "mobile-token", ErrInvalidToken},
+		{"graph error", http.StatusBadRequest, `{"error": {"message": "Invalid OAuth access token.", "type": "OAuthException", "code": 190}}`, "mobile-token", ErrUnableToDebugToken},
+	}
+	config := &oauth2.Config{ClientID: "client_id", ClientSecret: "client_secret"}
+	success := testutils.AssertSuccessNotCalled(t)
+	for _, c := range cases {
+		proxyClient, server := newDebugTokenServer(t, c.status, c.debugJSON)
+		// oauth2 Client will use the proxy client's base Transport
+		ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+		ctx = gologin.WithClock(ctx, clock)
+		failure := func(w http.ResponseWriter, req *http.Request) {
+			err := gologin.ErrorFromContext(req.Context())
+			assert.True(t, errors.Is(err, c.err), c.name)
+			fmt.Fprintf(w, "failure handler called")
+		}
+
+		// TokenHandler with an unusable access token, assert that:
+		// - failure handler is called with a specific error
+		handler := TokenHandler(config, success, http.HandlerFunc(failure))
+		form := url.Values{"access_token": {c.token}}
+		req, _ := http.NewRequest("POST", "/", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req.WithContext(ctx))
+		assert.Equal(t, "failure handler called", w.Body.String(), c.name)
+		server.Close()
+	}
+}
+
+func TestTokenVerifier(t *testing.T) {
+	debugJSON := `{"data": {"app_id": "client_id", "type": "USER", "is_valid": true, "user_id": "54638001"}}`
+	proxyClient, server := newDebugTokenServer(t, http.StatusOK, debugJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+
+	config := &oauth2.Config{ClientID: "client_id", ClientSecret: "client_secret"}
+	success := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "mobile-token", token.AccessToken)
+		}
+		user, err := UserFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "54638001", user.ID)
+		}
+		profile, err := gologin.ProfileFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "Ivy Crimson", profile.Name)
+		}
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// oauth2 TokenHandler with a facebook TokenVerifier, assert that:
+	// - the access token is verified with debug_token
+	// - the Token, User, and Profile are added to the ctx
+	handler := oauth2Login.TokenHandler(NewTokenVerifier(config, Config{}), http.HandlerFunc(success), failure)
+	req, _ := http.NewRequest("POST", "/", strings.NewReader(`{"access_token": "mobile-token"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestTokenVerifier_Errors(t *testing.T) {
+	config := &oauth2.Config{ClientID: "client_id", ClientSecret: "client_secret"}
+	verifier := NewTokenVerifier(config, Config{})
+
+	// TokenVerifier assert that:
+	// - tokens issued to other apps are rejected before getting the User
+	proxyClient, server := newDebugTokenServer(t, http.StatusOK, `{"data": {"app_id": "other_app", "is_valid": true, "user_id": "54638001"}}`)
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	user, err := verifier.VerifyToken(ctx, "mobile-token")
+	assert.Nil(t, user)
+	assert.Equal(t, ErrTokenAppMismatch, err)
+	server.Close()
+
+	// - User errors are preserved
+	client, mux, server := testutils.TestServer()
+	defer server.Close()
+	mux.HandleFunc("/v2.9/debug_token", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"data": {"app_id": "client_id", "is_valid": true, "user_id": "54638001"}}`)
+	})
+	mux.HandleFunc("/v2.9/me", func(w http.ResponseWriter, req *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	})
+	ctx = context.WithValue(context.Background(), oauth2.HTTPClient, client)
+	user, err = verifier.VerifyToken(ctx, "mobile-token")
+	assert.Nil(t, user)
+	assert.True(t, errors.Is(err, ErrUnableToGetFacebookUser))
+}
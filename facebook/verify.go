@@ -1,41 +1,233 @@
 package facebook
 
 import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
 
-	"github.com/dghubble/sling"
+	"github.com/dghubble/gologin/internal/jsonclient"
 )
 
-const facebookAPI = "https://graph.facebook.com/v2.9/"
+const (
+	graphAPI          = "https://graph.facebook.com/"
+	defaultAPIVersion = "v2.9"
+)
+
+// apiVersionPattern matches Graph API versions, with an optional "v" prefix
+// and minor version.
+var apiVersionPattern = regexp.MustCompile(`^v?([0-9]+)(\.[0-9]+)?$`)
+
+// normalizeAPIVersion returns the Graph API version in the "vX.Y" form (e.g.
+// "19" becomes "v19.0") or the default version if it is empty. Returns an
+// error if the version is not a Graph API version.
+func normalizeAPIVersion(version string) (string, error) {
+	if version == "" {
+		return defaultAPIVersion, nil
+	}
+	match := apiVersionPattern.FindStringSubmatch(version)
+	if match == nil {
+		return "", fmt.Errorf("facebook: invalid Graph API version %q", version)
+	}
+	minor := match[2]
+	if minor == "" {
+		minor = ".0"
+	}
+	return "v" + match[1] + minor, nil
+}
 
 // User is a Facebook user.
 //
 // Note that user ids are unique to each app.
 type User struct {
-	ID    string `json:"id"`
-	Name  string `json:"name"`
-	Email string `json:"email"`
+	ID        string  `json:"id"`
+	Name      string  `json:"name"`
+	Email     string  `json:"email"`
+	FirstName string  `json:"first_name"`
+	LastName  string  `json:"last_name"`
+	Picture   Picture `json:"picture"`
+	Locale    string  `json:"locale"`
+	// Raw is the /me response body, including fields User does not decode
+	// (e.g. requested "link" or "age_range" Fields), to decode into richer
+	// types.
+	Raw json.RawMessage `json:"-"`
+}
+
+// UnmarshalJSON decodes the User fields and keeps a copy of the data in Raw.
+func (u *User) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	// user has no methods, so decoding it does not recurse
+	type user User
+	var decoded user
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	*u = User(decoded)
+	u.Raw = append(json.RawMessage(nil), data...)
+	return nil
 }
 
+// Picture is a Facebook user's profile picture.
+type Picture struct {
+	Data struct {
+		URL          string `json:"url"`
+		Width        int    `json:"width"`
+		Height       int    `json:"height"`
+		IsSilhouette bool   `json:"is_silhouette"`
+	} `json:"data"`
+}
+
+// defaultFields are the User fields requested if none are configured.
+var defaultFields = []string{"id", "name", "email", "first_name", "last_name", "picture", "locale"}
+
+// apiError is a Facebook Graph API error response.
+type apiError struct {
+	Error GraphError `json:"error"`
+}
+
+// GraphError is the error of a Facebook Graph API error response.
+// https://developers.facebook.com/docs/graph-api/guides/error-handling
+type GraphError struct {
+	Message string `json:"message"`
+	Type    string `json:"type"`
+	Code    int    `json:"code"`
+	Subcode int    `json:"error_subcode"`
+	// UserTitle and UserMessage are localized messages for users, if any
+	UserTitle   string `json:"error_user_title"`
+	UserMessage string `json:"error_user_msg"`
+	// FBTraceID identifies the request for Facebook support
+	FBTraceID string `json:"fbtrace_id"`
+}
+
+func (e *GraphError) Error() string {
+	msg := fmt.Sprintf("facebook: %s (%s, code %d", e.Message, e.Type, e.Code)
+	if e.Subcode != 0 {
+		msg = fmt.Sprintf("%s, subcode %d", msg, e.Subcode)
+	}
+	if e.FBTraceID != "" {
+		msg = fmt.Sprintf("%s, fbtrace_id %s", msg, e.FBTraceID)
+	}
+	return msg + ")"
+}
+
+// Graph API error codes and subcodes.
+const (
+	errCodeAPITooManyCalls     = 4
+	errCodeAPIUserTooManyCalls = 17
+	errCodePageRateLimit       = 32
+	errCodeAppRateLimit        = 341
+	errCodeRateLimit           = 613
+	errSubcodeTokenExpired     = 463
+)
+
+// IsInvalidToken returns true if the error wraps a *GraphError for an invalid
+// access token (code 190), such as an expired or revoked token. Users should
+// log in again.
+func IsInvalidToken(err error) bool {
+	var graphErr *GraphError
+	return errors.As(err, &graphErr) && graphErr.Code == errCodeInvalidToken
+}
+
+// IsTokenExpired returns true if the error wraps a *GraphError for an expired
+// access token (code 190, subcode 463).
+func IsTokenExpired(err error) bool {
+	var graphErr *GraphError
+	return errors.As(err, &graphErr) && graphErr.Code == errCodeInvalidToken && graphErr.Subcode == errSubcodeTokenExpired
+}
+
+// IsRateLimited returns true if the error wraps a *GraphError for an app,
+// user, or page rate limit (e.g. OAuthException code 4).
+func IsRateLimited(err error) bool {
+	var graphErr *GraphError
+	if !errors.As(err, &graphErr) {
+		return false
+	}
+	switch graphErr.Code {
+	case errCodeAPITooManyCalls, errCodeAPIUserTooManyCalls, errCodePageRateLimit, errCodeAppRateLimit, errCodeRateLimit:
+		return true
+	}
+	return false
+}
+
+// appSecretProof returns the hex encoded HMAC-SHA256 of the access token
+// keyed by the app secret.
+// https://developers.facebook.com/docs/graph-api/securing-requests
+func appSecretProof(accessToken, appSecret string) string {
+	mac := hmac.New(sha256.New, []byte(appSecret))
+	mac.Write([]byte(accessToken))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// errCodeInvalidToken is the Graph API error code for invalid or expired
+// access tokens.
+const errCodeInvalidToken = 190
+
 // client is a Facebook client for obtaining the current User.
 type client struct {
-	c     *http.Client
-	sling *sling.Sling
+	json   *jsonclient.Client
+	params url.Values
 }
 
-func newClient(httpClient *http.Client) *client {
-	base := sling.New().Client(httpClient).Base(facebookAPI)
+// newClient returns a client of the (normalized) Graph API version which
+// sends the appsecret_proof with each request, unless it is empty.
+func newClient(httpClient *http.Client, apiVersion, appSecretProof string) *client {
+	// Facebook returns JSON as Content-Type text/javascript unless requests
+	// Accept application/json, which jsonclient sets
+	params := url.Values{}
+	if appSecretProof != "" {
+		params.Set("appsecret_proof", appSecretProof)
+	}
 	return &client{
-		c:     httpClient,
-		sling: base,
+		json:   jsonclient.New(httpClient, graphAPI+apiVersion+"/"),
+		params: params,
+	}
+}
+
+// query returns the client params and the given params.
+func (c *client) query(params url.Values) url.Values {
+	query := url.Values{}
+	for key, values := range c.params {
+		query[key] = values
+	}
+	for key, values := range params {
+		query[key] = values
 	}
+	return query
 }
 
-func (c *client) Me() (*User, *http.Response, error) {
+// Me returns the current User with the requested fields. If the Graph API
+// responds with an error, it is returned as a *GraphError.
+func (c *client) Me(ctx context.Context, fields []string) (*User, *http.Response, error) {
 	user := new(User)
-	// Facebook returns JSON as Content-Type text/javascript :(
-	// Set Accept header to receive proper Content-Type application/json
-	// so Sling will decode into the struct
-	resp, err := c.sling.New().Set("Accept", "application/json").Get("me?fields=name,email").ReceiveSuccess(user)
+	apiErr := new(apiError)
+	params := url.Values{}
+	if len(fields) > 0 {
+		params.Set("fields", strings.Join(fields, ","))
+	}
+	resp, err := c.json.Get(ctx, "me", c.query(params), user, apiErr)
+	if err == nil && apiErr.Error.Message != "" {
+		err = &apiErr.Error
+	}
 	return user, resp, err
 }
+
+// RevokePermissions revokes all of the app's permissions for the current user,
+// de-authorizing the app.
+func (c *client) RevokePermissions(ctx context.Context) (*apiError, *http.Response, error) {
+	apiErr := new(apiError)
+	req, err := c.json.NewRequest(ctx, "DELETE", "me/permissions", c.query(nil), nil)
+	if err != nil {
+		return apiErr, nil, err
+	}
+	resp, err := c.json.Do(req, nil, apiErr)
+	return apiErr, resp, err
+}
@@ -0,0 +1,2 @@
+// Package figma provides Figma OAuth2 login and callback handlers.
+package figma
@@ -0,0 +1,99 @@
+package figma
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/internal"
+	"github.com/dghubble/gologin/internal/jsonclient"
+	"golang.org/x/oauth2"
+)
+
+// RefreshURL is Figma's token refresh endpoint. Figma refreshes tokens here
+// rather than with a refresh_token grant at the Endpoint TokenURL.
+const RefreshURL = "https://www.figma.com/api/oauth/refresh"
+
+// Figma refresh errors
+var (
+	ErrMissingRefreshToken  = errors.New("figma: Token has no refresh token")
+	ErrUnableToRefreshToken = errors.New("figma: unable to refresh Figma Token")
+)
+
+// refreshResponse is a Figma refresh response. Figma does not issue a new
+// refresh token.
+type refreshResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// RefreshToken refreshes the Token with the Figma RefreshURL, using the ctx
+// HTTP client (if any) and the config client credentials. The refreshed
+// Token keeps the refresh token of the given Token. Returns
+// ErrMissingRefreshToken if the Token has no refresh token, or a
+// *gologin.Error of ErrUnableToRefreshToken if the refresh fails.
+//
+// The golang.org/x/oauth2 Config TokenSource cannot be used since it sends a
+// refresh_token grant to the TokenURL.
+func RefreshToken(ctx context.Context, config *oauth2.Config, token *oauth2.Token) (*oauth2.Token, error) {
+	if token == nil || token.RefreshToken == "" {
+		return nil, ErrMissingRefreshToken
+	}
+	form := url.Values{
+		"client_id":     {config.ClientID},
+		"client_secret": {config.ClientSecret},
+		"refresh_token": {token.RefreshToken},
+	}
+	refreshResp := new(refreshResponse)
+	apiErr := new(APIError)
+	resp, err := jsonclient.New(internal.ContextClient(ctx), "").PostForm(ctx, RefreshURL, form, refreshResp, apiErr)
+	if err == nil && apiErr.Message != "" {
+		err = apiErr
+	}
+	var status int
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	if err != nil || status != http.StatusOK {
+		return nil, &gologin.Error{Provider: "figma", Op: "refresh token", StatusCode: status, Err: err, Kind: ErrUnableToRefreshToken}
+	}
+	if refreshResp.AccessToken == "" {
+		return nil, &gologin.Error{Provider: "figma", Op: "refresh token", StatusCode: status, Err: errors.New("missing access_token"), Kind: ErrUnableToRefreshToken}
+	}
+	refreshed := &oauth2.Token{
+		AccessToken:  refreshResp.AccessToken,
+		TokenType:    "Bearer",
+		RefreshToken: token.RefreshToken,
+	}
+	if refreshResp.ExpiresIn > 0 {
+		refreshed.Expiry = time.Now().Add(time.Duration(refreshResp.ExpiresIn) * time.Second)
+	}
+	return refreshed, nil
+}
+
+// TokenSource returns an oauth2.TokenSource which returns the Token until it
+// expires and then refreshes it with RefreshToken.
+func TokenSource(ctx context.Context, config *oauth2.Config, token *oauth2.Token) oauth2.TokenSource {
+	return oauth2.ReuseTokenSource(token, &refreshTokenSource{ctx: ctx, config: config, token: token})
+}
+
+// refreshTokenSource is an oauth2.TokenSource which refreshes with the Figma
+// RefreshURL.
+type refreshTokenSource struct {
+	ctx    context.Context
+	config *oauth2.Config
+	token  *oauth2.Token
+}
+
+// Token refreshes the Token.
+func (s *refreshTokenSource) Token() (*oauth2.Token, error) {
+	token, err := RefreshToken(s.ctx, s.config, s.token)
+	if err != nil {
+		return nil, err
+	}
+	s.token = token
+	return token, nil
+}
@@ -0,0 +1,66 @@
+package figma
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func TestRefreshToken(t *testing.T) {
+	proxyClient, server := newFigmaTestServer(testUserJSON)
+	defer server.Close()
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+
+	// RefreshToken assert that:
+	// - the refresh token is sent to the Figma RefreshURL
+	// - the refreshed Token keeps the refresh token
+	token, err := RefreshToken(ctx, testConfig(), &oauth2.Token{AccessToken: "any-token", RefreshToken: "any-refresh"})
+	if assert.Nil(t, err) {
+		assert.Equal(t, "next-token", token.AccessToken)
+		assert.Equal(t, "any-refresh", token.RefreshToken)
+		assert.WithinDuration(t, time.Now().Add(7776000*time.Second), token.Expiry, time.Minute)
+	}
+}
+
+func TestRefreshToken_Errors(t *testing.T) {
+	proxyClient, server := newFigmaTestServer(testUserJSON)
+	defer server.Close()
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+
+	// RefreshToken assert that:
+	// - Tokens without a refresh token fail with ErrMissingRefreshToken
+	// - rejected refresh tokens fail with ErrUnableToRefreshToken and the
+	// Figma APIError as the cause
+	_, err := RefreshToken(ctx, testConfig(), &oauth2.Token{AccessToken: "any-token"})
+	assert.Equal(t, ErrMissingRefreshToken, err)
+	_, err = RefreshToken(ctx, testConfig(), &oauth2.Token{AccessToken: "any-token", RefreshToken: "invalid-refresh"})
+	assert.True(t, errors.Is(err, ErrUnableToRefreshToken))
+	var apiErr *APIError
+	if assert.True(t, errors.As(err, &apiErr)) {
+		assert.Equal(t, "Invalid refresh token", apiErr.Message)
+	}
+}
+
+func TestTokenSource(t *testing.T) {
+	proxyClient, server := newFigmaTestServer(testUserJSON)
+	defer server.Close()
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+
+	// TokenSource assert that:
+	// - valid Tokens are returned as is
+	// - expired Tokens are refreshed with the Figma RefreshURL
+	valid := &oauth2.Token{AccessToken: "any-token", RefreshToken: "any-refresh", Expiry: time.Now().Add(time.Hour)}
+	token, err := TokenSource(ctx, testConfig(), valid).Token()
+	if assert.Nil(t, err) {
+		assert.Equal(t, "any-token", token.AccessToken)
+	}
+	expired := &oauth2.Token{AccessToken: "any-token", RefreshToken: "any-refresh", Expiry: time.Now().Add(-time.Hour)}
+	token, err = TokenSource(ctx, testConfig(), expired).Token()
+	if assert.Nil(t, err) {
+		assert.Equal(t, "next-token", token.AccessToken)
+	}
+}
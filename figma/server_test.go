@@ -0,0 +1,54 @@
+package figma
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/dghubble/gologin/testutils"
+)
+
+const (
+	// testUserJSON is a v1/me response.
+	testUserJSON = `{"id": "1234567890", "email": "ada@example.com", "handle": "Ada Lovelace", "img_url": "https://s3-alpha.figma.com/profile/ada.png"}`
+	// testInvalidTokenJSON is a Figma error response.
+	testInvalidTokenJSON = `{"status": 403, "err": "Invalid token"}`
+)
+
+// newFigmaTestServer returns a new httptest.Server which mocks the Figma
+// token, refresh, and v1/me endpoints and a client which proxies requests to
+// the server. Like Figma, client credentials must be sent as parameters and
+// refreshing "any-refresh" returns "next-token". The v1/me endpoint responds
+// with the given json data, or a Figma error for tokens other than
+// "any-token" and "next-token". The caller must close the server.
+func newFigmaTestServer(jsonData string) (*http.Client, *httptest.Server) {
+	client, mux, server := testutils.TestServer()
+	mux.HandleFunc("/api/oauth/token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if _, _, ok := r.BasicAuth(); ok || r.FormValue("client_id") != "client_id" || r.FormValue("client_secret") != "client_secret" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, `{"error": true, "status": 400, "message": "Invalid client"}`)
+			return
+		}
+		fmt.Fprintf(w, `{"user_id": 1234567890, "access_token": "any-token", "refresh_token": "any-refresh", "expires_in": 7776000}`)
+	})
+	mux.HandleFunc("/api/oauth/refresh", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != "POST" || r.PostFormValue("client_id") != "client_id" || r.PostFormValue("client_secret") != "client_secret" || r.PostFormValue("refresh_token") != "any-refresh" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, `{"status": 400, "err": "Invalid refresh token"}`)
+			return
+		}
+		fmt.Fprintf(w, `{"access_token": "next-token", "expires_in": 7776000}`)
+	})
+	mux.HandleFunc("/v1/me", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if auth := r.Header.Get("Authorization"); auth != "Bearer any-token" && auth != "Bearer next-token" {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprintf(w, testInvalidTokenJSON)
+			return
+		}
+		fmt.Fprintf(w, jsonData)
+	})
+	return client, server
+}
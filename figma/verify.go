@@ -0,0 +1,53 @@
+package figma
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/dghubble/gologin/internal/jsonclient"
+)
+
+const figmaAPI = "https://api.figma.com/"
+
+// User is a Figma user.
+type User struct {
+	ID     string `json:"id"`
+	Email  string `json:"email"`
+	Handle string `json:"handle"`
+	ImgURL string `json:"img_url"`
+}
+
+// APIError is a Figma API error response.
+type APIError struct {
+	Status  int    `json:"status"`
+	Message string `json:"err"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("figma: %s (status %d)", e.Message, e.Status)
+}
+
+// client is a Figma client for obtaining a User.
+type client struct {
+	json *jsonclient.Client
+}
+
+// newClient returns a new Figma client.
+func newClient(httpClient *http.Client) *client {
+	return &client{
+		json: jsonclient.New(httpClient, figmaAPI),
+	}
+}
+
+// Me gets the current Figma User.
+// https://www.figma.com/developers/api#get-me-endpoint
+func (c *client) Me(ctx context.Context) (*User, *http.Response, error) {
+	user := new(User)
+	apiErr := new(APIError)
+	resp, err := c.json.Get(ctx, "v1/me", nil, user, apiErr)
+	if err == nil && apiErr.Message != "" {
+		err = apiErr
+	}
+	return user, resp, err
+}
@@ -0,0 +1,2 @@
+// Package fitbit provides Fitbit OAuth2 login and callback handlers.
+package fitbit
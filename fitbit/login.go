@@ -0,0 +1,163 @@
+package fitbit
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/internal"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"golang.org/x/oauth2"
+)
+
+// ProviderName is the provider name set in the ctx (see
+// gologin.ProviderFromContext) and the Profile.
+const ProviderName = "fitbit"
+
+// Fitbit login errors
+var (
+	ErrUnableToGetFitbitUser = errors.New("fitbit: unable to get Fitbit User")
+	ErrRateLimited           = errors.New("fitbit: Fitbit rate limit exceeded")
+)
+
+// Endpoint is Fitbit's OAuth2 endpoint. Fitbit requires HTTP Basic client
+// authentication at the token endpoint.
+var Endpoint = oauth2.Endpoint{
+	AuthURL:   "https://www.fitbit.com/oauth2/authorize",
+	TokenURL:  "https://api.fitbit.com/oauth2/token",
+	AuthStyle: oauth2.AuthStyleInHeader,
+}
+
+// RateLimitError is the error of rate limited Fitbit API requests, from the
+// Fitbit-Rate-Limit-* response headers.
+type RateLimitError struct {
+	// Limit is the request quota of the client per user per hour
+	Limit int
+	// Remaining is the number of requests remaining in the quota
+	Remaining int
+	// Reset is how long until the quota resets
+	Reset time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("%s, resets in %s", ErrRateLimited, e.Reset)
+}
+
+// Is returns true for ErrRateLimited.
+func (e *RateLimitError) Is(target error) bool {
+	return target == ErrRateLimited
+}
+
+// StateHandler checks for a state cookie. If found, the state value is read
+// and added to the ctx. Otherwise, a non-guessable value is added to the ctx
+// and to a (short-lived) state cookie issued to the requester.
+//
+// Implements OAuth 2 RFC 6749 10.12 CSRF Protection. If you wish to issue
+// state params differently, write a http.Handler which sets the ctx state,
+// using oauth2 WithState(ctx, state) since it is required by LoginHandler
+// and CallbackHandler.
+func StateHandler(config gologin.CookieConfig, success http.Handler) http.Handler {
+	return oauth2Login.StateHandler(config, success)
+}
+
+// LoginHandler handles Fitbit login requests by reading the state value from
+// the ctx and redirecting requests to the AuthURL with that state value.
+// Any AuthCodeOptions are added to the AuthURL.
+func LoginHandler(config *oauth2.Config, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	return oauth2Login.LoginHandler(config, failure, opts...)
+}
+
+// LoginHandlerWithPKCE handles Fitbit login requests like LoginHandler, but
+// also sends a PKCE code challenge, which Fitbit requires for client
+// (public) apps. The PKCE code verifier is kept in a cookie per the
+// pkceConfig, whose Name must differ from the state cookie.
+func LoginHandlerWithPKCE(config *oauth2.Config, pkceConfig gologin.CookieConfig, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	return oauth2Login.LoginHandlerWithPKCE(config, pkceConfig, failure, opts...)
+}
+
+// CallbackHandler handles Fitbit redirection URI requests and adds the Fitbit
+// access token and User to the ctx. If authentication succeeds, handling
+// delegates to the success handler, otherwise to the failure handler.
+// Any AuthCodeOptions are sent with the token exchange.
+func CallbackHandler(config *oauth2.Config, success, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	success = fitbitHandler(config, success, failure)
+	return oauth2Login.CallbackHandler(config, success, failure, opts...)
+}
+
+// CallbackHandlerWithPKCE handles Fitbit redirection URI requests like
+// CallbackHandler, but sends the PKCE code verifier cookie set by
+// LoginHandlerWithPKCE with the token exchange.
+func CallbackHandlerWithPKCE(config *oauth2.Config, pkceConfig gologin.CookieConfig, success, failure http.Handler) http.Handler {
+	success = fitbitHandler(config, success, failure)
+	return oauth2Login.CallbackHandlerWithPKCE(config, pkceConfig, success, failure)
+}
+
+// fitbitHandler is a http.Handler that gets the OAuth2 Token from the ctx to
+// get the corresponding Fitbit User. If successful, the User is added to the
+// ctx and the success handler is called. Otherwise, the failure handler is
+// called.
+//
+// Rate limited requests fail with a *RateLimitError.
+func fitbitHandler(config *oauth2.Config, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		httpClient := internal.OAuth2Client(ctx, config, token)
+		user, resp, err := newClient(httpClient).Profile(ctx)
+		err = validateResponse(user, resp, err)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		ctx = WithUser(ctx, user)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// validateResponse returns an error if the given Fitbit User, raw
+// http.Response, or error are unexpected. Returns nil if they are valid, a
+// *RateLimitError if the request was rate limited, or a *gologin.Error which
+// preserves the cause and status code.
+func validateResponse(user *User, resp *http.Response, err error) error {
+	var status int
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	if status == http.StatusTooManyRequests {
+		return newRateLimitError(resp.Header)
+	}
+	if err != nil || status != http.StatusOK {
+		return &gologin.Error{Provider: "fitbit", Op: "get user", StatusCode: status, Err: err, Kind: ErrUnableToGetFitbitUser}
+	}
+	if user == nil || user.EncodedID == "" {
+		return &gologin.Error{Provider: "fitbit", Op: "get user", StatusCode: status, Kind: ErrUnableToGetFitbitUser}
+	}
+	return nil
+}
+
+// newRateLimitError returns a *RateLimitError for the 429 response headers,
+// whose Fitbit-Rate-Limit-Reset is in seconds.
+// https://dev.fitbit.com/build/reference/web-api/developer-guide/application-design/#Rate-Limits
+func newRateLimitError(header http.Header) *RateLimitError {
+	limit, _ := strconv.Atoi(header.Get("Fitbit-Rate-Limit-Limit"))
+	remaining, _ := strconv.Atoi(header.Get("Fitbit-Rate-Limit-Remaining"))
+	reset, _ := strconv.Atoi(header.Get("Fitbit-Rate-Limit-Reset"))
+	return &RateLimitError{
+		Limit:     limit,
+		Remaining: remaining,
+		Reset:     time.Duration(reset) * time.Second,
+	}
+}
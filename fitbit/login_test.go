@@ -0,0 +1,262 @@
+package fitbit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/dghubble/gologin"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+var testPKCEConfig = gologin.CookieConfig{
+	Name:   "fitbit-pkce",
+	Path:   "/",
+	MaxAge: 60,
+}
+
+func testConfig() *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     "client_id",
+		ClientSecret: "client_secret",
+		RedirectURL:  "https://example.com/fitbit/callback",
+		Endpoint:     Endpoint,
+		Scopes:       []string{"profile"},
+	}
+}
+
+func TestCallbackHandler(t *testing.T) {
+	proxyClient, server := newFitbitTestServer("", testUserJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithState(ctx, "d4e5f6")
+
+	success := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "any-token", token.AccessToken)
+		}
+		user, err := UserFromContext(ctx)
+		if assert.Nil(t, err) {
+			expectedUser := &User{EncodedID: "257V3V", DisplayName: "Alex", Avatar150: "https://static0.fitbit.com/images/profile/defaultProfile_150.png", MemberSince: "2015-06-05", Timezone: "America/Los_Angeles"}
+			assert.Equal(t, expectedUser, user)
+		}
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// CallbackHandler assert that:
+	// - client credentials are sent with HTTP Basic auth
+	// - the Fitbit User is unwrapped from the profile user
+	// - success handler is called with the Token and User in the ctx
+	callbackHandler := CallbackHandler(testConfig(), http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestLoginHandlerWithPKCE(t *testing.T) {
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// LoginHandlerWithPKCE assert that:
+	// - redirects to the Fitbit AuthURL with the state
+	// - the S256 PKCE code challenge is sent and its verifier kept in a cookie
+	loginHandler := LoginHandlerWithPKCE(testConfig(), testPKCEConfig, failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	ctx := oauth2Login.WithState(context.Background(), "d4e5f6")
+	loginHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, http.StatusFound, w.Code)
+	location, err := url.Parse(w.HeaderMap.Get("Location"))
+	if assert.Nil(t, err) {
+		assert.Equal(t, "www.fitbit.com", location.Host)
+		assert.Equal(t, "/oauth2/authorize", location.Path)
+		assert.Equal(t, "d4e5f6", location.Query().Get("state"))
+		assert.Equal(t, "S256", location.Query().Get("code_challenge_method"))
+		assert.NotEmpty(t, location.Query().Get("code_challenge"))
+	}
+	cookies := (&http.Response{Header: w.Header()}).Cookies()
+	if assert.Len(t, cookies, 1) {
+		assert.Equal(t, "fitbit-pkce", cookies[0].Name)
+		assert.NotEmpty(t, cookies[0].Value)
+	}
+}
+
+func TestCallbackHandlerWithPKCE(t *testing.T) {
+	proxyClient, server := newFitbitTestServer("some_verifier", testUserJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithState(ctx, "d4e5f6")
+
+	success := func(w http.ResponseWriter, req *http.Request) {
+		user, err := UserFromContext(req.Context())
+		if assert.Nil(t, err) {
+			assert.Equal(t, "257V3V", user.EncodedID)
+		}
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// CallbackHandlerWithPKCE assert that:
+	// - the PKCE code verifier is sent with the token exchange
+	// - success handler is called with the User in the ctx
+	callbackHandler := CallbackHandlerWithPKCE(testConfig(), testPKCEConfig, http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	req.AddCookie(&http.Cookie{Name: "fitbit-pkce", Value: "some_verifier"})
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestCallbackHandlerWithPKCE_InvalidVerifier(t *testing.T) {
+	proxyClient, server := newFitbitTestServer("some_verifier", testUserJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithState(ctx, "d4e5f6")
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		var retrieveErr *oauth2.RetrieveError
+		assert.True(t, errors.As(gologin.ErrorFromContext(req.Context()), &retrieveErr))
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// CallbackHandlerWithPKCE with the wrong code verifier, assert that:
+	// - the token exchange fails
+	// - failure handler is called
+	callbackHandler := CallbackHandlerWithPKCE(testConfig(), testPKCEConfig, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	req.AddCookie(&http.Cookie{Name: "fitbit-pkce", Value: "other_verifier"})
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestFitbitHandler_MissingEncodedID(t *testing.T) {
+	proxyClient, server := newFitbitTestServer("", `{"user": {"displayName": "Alex"}}`)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.True(t, errors.Is(err, ErrUnableToGetFitbitUser))
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// FitbitHandler gets a profile without an encodedId, assert that:
+	// - failure handler is called
+	fitbitHandler := fitbitHandler(testConfig(), success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	fitbitHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestFitbitHandler_RateLimited(t *testing.T) {
+	proxyClient, server := newFitbitRateLimitServer()
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.True(t, errors.Is(err, ErrRateLimited))
+			assert.False(t, errors.Is(err, ErrUnableToGetFitbitUser))
+			var rateLimitErr *RateLimitError
+			if assert.True(t, errors.As(err, &rateLimitErr)) {
+				assert.Equal(t, &RateLimitError{Limit: 150, Remaining: 0, Reset: 1284 * time.Second}, rateLimitErr)
+			}
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// FitbitHandler rate limited, assert that:
+	// - failure handler is called
+	// - a RateLimitError from the Fitbit-Rate-Limit headers is added to the
+	// failure handler ctx
+	fitbitHandler := fitbitHandler(testConfig(), success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	fitbitHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestFitbitHandler_MissingCtxToken(t *testing.T) {
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "oauth2: Context missing Token", err.Error())
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// FitbitHandler called without Token in ctx, assert that:
+	// - failure handler is called
+	// - error about ctx missing token is added to the failure handler ctx
+	fitbitHandler := fitbitHandler(testConfig(), success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	fitbitHandler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestFitbitHandler_ErrorGettingUser(t *testing.T) {
+	proxyClient, server := testutils.NewErrorServer("Fitbit Service Down", http.StatusInternalServerError)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.True(t, errors.Is(err, ErrUnableToGetFitbitUser))
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// FitbitHandler cannot get Fitbit User, assert that:
+	// - failure handler is called
+	// - error cannot get Fitbit User added to the failure handler ctx
+	fitbitHandler := fitbitHandler(testConfig(), success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	fitbitHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestValidateResponse(t *testing.T) {
+	validUser := &User{EncodedID: "257V3V"}
+	validResponse := &http.Response{StatusCode: 200}
+	invalidResponse := &http.Response{StatusCode: 500}
+	rateLimited := &http.Response{StatusCode: 429, Header: http.Header{"Fitbit-Rate-Limit-Reset": {"60"}}}
+	assert.Equal(t, nil, validateResponse(validUser, validResponse, nil))
+	assert.True(t, errors.Is(validateResponse(validUser, validResponse, fmt.Errorf("Server error")), ErrUnableToGetFitbitUser))
+	assert.True(t, errors.Is(validateResponse(validUser, invalidResponse, nil), ErrUnableToGetFitbitUser))
+	assert.True(t, errors.Is(validateResponse(&User{}, validResponse, nil), ErrUnableToGetFitbitUser))
+	assert.True(t, errors.Is(validateResponse(nil, validResponse, nil), ErrUnableToGetFitbitUser))
+	assert.Equal(t, &RateLimitError{Reset: time.Minute}, validateResponse(nil, rateLimited, nil))
+}
@@ -0,0 +1,62 @@
+package fitbit
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/dghubble/gologin/testutils"
+)
+
+const testUserJSON = `{"user": {"age": 30, "avatar": "https://static0.fitbit.com/images/profile/defaultProfile_100.png", "avatar150": "https://static0.fitbit.com/images/profile/defaultProfile_150.png", "displayName": "Alex", "encodedId": "257V3V", "fullName": "Alex Example", "memberSince": "2015-06-05", "timezone": "America/Los_Angeles", "offsetFromUTCMillis": -25200000}}`
+
+// newFitbitTestServer returns a new httptest.Server which mocks the Fitbit
+// token and profile endpoints and a client which proxies requests to the
+// server. Like Fitbit, the token endpoint requires HTTP Basic client
+// authentication. If the verifier is non-empty, the token endpoint also
+// requires it as the PKCE code_verifier. The profile endpoint responds with
+// the given json data. The caller must close the server.
+func newFitbitTestServer(verifier, jsonData string) (*http.Client, *httptest.Server) {
+	client, mux, server := testutils.TestServer()
+	mux.HandleFunc("/oauth2/token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		username, password, ok := r.BasicAuth()
+		if !ok || username != "client_id" || password != "client_secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprintf(w, `{"errors": [{"errorType": "invalid_client", "message": "Invalid authorization header format."}], "success": false}`)
+			return
+		}
+		if verifier != "" && r.PostFormValue("code_verifier") != verifier {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, `{"errors": [{"errorType": "invalid_grant", "message": "Invalid code_verifier."}], "success": false}`)
+			return
+		}
+		fmt.Fprintf(w, `{"access_token": "any-token", "expires_in": 28800, "refresh_token": "any-refresh", "scope": "profile", "token_type": "Bearer", "user_id": "257V3V"}`)
+	})
+	mux.HandleFunc("/1/user/-/profile.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Header.Get("Authorization") != "Bearer any-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprintf(w, `{"errors": [{"errorType": "invalid_token", "message": "Access token invalid"}], "success": false}`)
+			return
+		}
+		fmt.Fprintf(w, jsonData)
+	})
+	return client, server
+}
+
+// newFitbitRateLimitServer returns a new httptest.Server which responds to
+// all requests with a Fitbit 429 rate limit response and a client which
+// proxies requests to the server. The caller must close the server.
+func newFitbitRateLimitServer() (*http.Client, *httptest.Server) {
+	client, mux, server := testutils.TestServer()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Fitbit-Rate-Limit-Limit", "150")
+		w.Header().Set("Fitbit-Rate-Limit-Remaining", "0")
+		w.Header().Set("Fitbit-Rate-Limit-Reset", "1284")
+		w.WriteHeader(http.StatusTooManyRequests)
+		fmt.Fprintf(w, `{"errors": [{"errorType": "system", "fieldName": "n/a", "message": "Too Many Requests"}], "success": false}`)
+	})
+	return client, server
+}
@@ -0,0 +1,44 @@
+package fitbit
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/dghubble/gologin/internal/jsonclient"
+)
+
+const fitbitAPI = "https://api.fitbit.com/"
+
+// User is a Fitbit user profile. EncodedID is the stable user identifier.
+type User struct {
+	EncodedID   string `json:"encodedId"`
+	DisplayName string `json:"displayName"`
+	Avatar150   string `json:"avatar150"`
+	MemberSince string `json:"memberSince"`
+	Timezone    string `json:"timezone"`
+}
+
+// profileResponse is a Fitbit profile.json response.
+type profileResponse struct {
+	User *User `json:"user"`
+}
+
+// client is a Fitbit client for obtaining the current User.
+type client struct {
+	json *jsonclient.Client
+}
+
+// newClient returns a new Fitbit client.
+func newClient(httpClient *http.Client) *client {
+	return &client{
+		json: jsonclient.New(httpClient, fitbitAPI),
+	}
+}
+
+// Profile returns the current Fitbit User (requires the profile scope).
+// https://dev.fitbit.com/build/reference/web-api/user/get-profile/
+func (c *client) Profile(ctx context.Context) (*User, *http.Response, error) {
+	profile := new(profileResponse)
+	resp, err := c.json.Get(ctx, "1/user/-/profile.json", nil, profile, nil)
+	return profile.User, resp, err
+}
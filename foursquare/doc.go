@@ -0,0 +1,3 @@
+// Package foursquare provides Foursquare (Swarm) OAuth2 login and callback
+// handlers.
+package foursquare
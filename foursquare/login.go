@@ -0,0 +1,102 @@
+package foursquare
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/internal"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"golang.org/x/oauth2"
+)
+
+// ProviderName is the provider name set in the ctx (see
+// gologin.ProviderFromContext) and the Profile.
+const ProviderName = "foursquare"
+
+// Foursquare login errors
+var (
+	ErrUnableToGetFoursquareUser = errors.New("foursquare: unable to get Foursquare User")
+)
+
+// Endpoint is Foursquare's OAuth2 endpoint.
+var Endpoint = oauth2.Endpoint{
+	AuthURL:   "https://foursquare.com/oauth2/authenticate",
+	TokenURL:  "https://foursquare.com/oauth2/access_token",
+	AuthStyle: oauth2.AuthStyleInParams,
+}
+
+// StateHandler checks for a state cookie. If found, the state value is read
+// and added to the ctx. Otherwise, a non-guessable value is added to the ctx
+// and to a (short-lived) state cookie issued to the requester.
+//
+// Implements OAuth 2 RFC 6749 10.12 CSRF Protection. If you wish to issue
+// state params differently, write a http.Handler which sets the ctx state,
+// using oauth2 WithState(ctx, state) since it is required by LoginHandler
+// and CallbackHandler.
+func StateHandler(config gologin.CookieConfig, success http.Handler) http.Handler {
+	return oauth2Login.StateHandler(config, success)
+}
+
+// LoginHandler handles Foursquare login requests by reading the state value
+// from the ctx and redirecting requests to the AuthURL with that state value.
+// Any AuthCodeOptions are added to the AuthURL.
+func LoginHandler(config *oauth2.Config, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	return oauth2Login.LoginHandler(config, failure, opts...)
+}
+
+// CallbackHandler handles Foursquare redirection URI requests and adds the
+// Foursquare access token and User to the ctx. If authentication succeeds,
+// handling delegates to the success handler, otherwise to the failure
+// handler. Any AuthCodeOptions are sent with the token exchange.
+func CallbackHandler(config *oauth2.Config, success, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	success = foursquareHandler(success, failure)
+	return oauth2Login.CallbackHandler(config, success, failure, opts...)
+}
+
+// foursquareHandler is a http.Handler that gets the OAuth2 Token from the ctx
+// to get the corresponding Foursquare User. If successful, the User is added
+// to the ctx and the success handler is called. Otherwise, the failure
+// handler is called.
+func foursquareHandler(success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		// Foursquare API requests pass the oauth_token as a parameter
+		user, resp, err := newClient(internal.ContextClient(ctx)).UsersSelf(ctx, token.AccessToken)
+		err = validateResponse(user, resp, err)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		ctx = WithUser(ctx, user)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// validateResponse returns an error if the given Foursquare User, raw
+// http.Response, or error are unexpected. Returns nil if they are valid, or a
+// *gologin.Error which preserves the cause (e.g. a *Meta) and status code.
+func validateResponse(user *User, resp *http.Response, err error) error {
+	var status int
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	if err != nil || status != http.StatusOK {
+		return &gologin.Error{Provider: "foursquare", Op: "get user", StatusCode: status, Err: err, Kind: ErrUnableToGetFoursquareUser}
+	}
+	if user == nil || user.ID == "" {
+		return &gologin.Error{Provider: "foursquare", Op: "get user", StatusCode: status, Kind: ErrUnableToGetFoursquareUser}
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+package foursquare
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dghubble/gologin"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func testConfig() *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     "client_id",
+		ClientSecret: "client_secret",
+		RedirectURL:  "https://example.com/foursquare/callback",
+		Endpoint:     Endpoint,
+	}
+}
+
+func TestCallbackHandler(t *testing.T) {
+	proxyClient, server := newFoursquareTestServer(http.StatusOK, testUserJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithState(ctx, "d4e5f6")
+
+	success := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "any-token", token.AccessToken)
+		}
+		user, err := UserFromContext(ctx)
+		if assert.Nil(t, err) {
+			expectedUser := &User{
+				ID:        "1234567",
+				FirstName: "Jimmy",
+				LastName:  "Foursquare",
+				Contact:   Contact{Email: "jimmy@example.com"},
+				Photo:     Photo{Prefix: "https://fastly.4sqi.net/img/user/", Suffix: "/1234567-ABCDEFGHIJKLMNOP.jpg"},
+			}
+			assert.Equal(t, expectedUser, user)
+		}
+		profile, err := gologin.ProfileFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "1234567", profile.ID)
+			assert.Equal(t, "Jimmy Foursquare", profile.Name)
+			assert.Equal(t, "jimmy@example.com", profile.Email)
+			assert.Equal(t, "https://fastly.4sqi.net/img/user/original/1234567-ABCDEFGHIJKLMNOP.jpg", profile.AvatarURL)
+		}
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// CallbackHandler assert that:
+	// - the Foursquare User is obtained from users/self with oauth_token and v
+	// - the User is decoded from the response.user envelope
+	// - success handler is called with the Token and User in the ctx
+	callbackHandler := CallbackHandler(testConfig(), http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestPhotoURL(t *testing.T) {
+	cases := []struct {
+		photo    Photo
+		size     string
+		expected string
+	}{
+		{Photo{Prefix: "https://fastly.4sqi.net/img/user/", Suffix: "/1234567-ABC.jpg"}, "original", "https://fastly.4sqi.net/img/user/original/1234567-ABC.jpg"},
+		{Photo{Prefix: "https://fastly.4sqi.net/img/user/", Suffix: "/1234567-ABC.jpg"}, "100x100", "https://fastly.4sqi.net/img/user/100x100/1234567-ABC.jpg"},
+		{Photo{Prefix: "https://fastly.4sqi.net/img/user/"}, "original", ""},
+		{Photo{Suffix: "/1234567-ABC.jpg"}, "original", ""},
+		{Photo{}, "original", ""},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.expected, c.photo.URL(c.size))
+	}
+	user := &User{Photo: Photo{Prefix: "https://fastly.4sqi.net/img/user/", Suffix: "/blank_boy.png"}}
+	assert.Equal(t, "https://fastly.4sqi.net/img/user/original/blank_boy.png", user.AvatarURL())
+}
+
+func TestFoursquareHandler_MissingID(t *testing.T) {
+	proxyClient, server := newFoursquareTestServer(http.StatusOK, `{"meta": {"code": 200}, "response": {"user": {"firstName": "Jimmy"}}}`)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.True(t, errors.Is(err, ErrUnableToGetFoursquareUser))
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// FoursquareHandler gets a User without an id, assert that:
+	// - failure handler is called
+	foursquareHandler := foursquareHandler(success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	foursquareHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestFoursquareHandler_InvalidToken(t *testing.T) {
+	proxyClient, server := newFoursquareTestServer(http.StatusOK, testUserJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "revoked-token"})
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.True(t, errors.Is(err, ErrUnableToGetFoursquareUser))
+			var meta *Meta
+			if assert.True(t, errors.As(err, &meta)) {
+				assert.Equal(t, "invalid_auth", meta.ErrorType)
+				assert.Equal(t, "foursquare: OAuth token invalid or revoked. (invalid_auth)", meta.Error())
+			}
+			var gologinErr *gologin.Error
+			if assert.True(t, errors.As(err, &gologinErr)) {
+				assert.Equal(t, http.StatusUnauthorized, gologinErr.StatusCode)
+			}
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// FoursquareHandler gets an invalid_auth error response, assert that:
+	// - failure handler is called
+	// - the error wraps the Foursquare *Meta
+	foursquareHandler := foursquareHandler(success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	foursquareHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestFoursquareHandler_MissingCtxToken(t *testing.T) {
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "oauth2: Context missing Token", err.Error())
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// FoursquareHandler called without Token in ctx, assert that:
+	// - failure handler is called
+	// - error about ctx missing token is added to the failure handler ctx
+	foursquareHandler := foursquareHandler(success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	foursquareHandler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestFoursquareHandler_ErrorGettingUser(t *testing.T) {
+	proxyClient, server := testutils.NewErrorServer("Foursquare Service Down", http.StatusInternalServerError)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.True(t, errors.Is(err, ErrUnableToGetFoursquareUser))
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// FoursquareHandler cannot get Foursquare User, assert that:
+	// - failure handler is called
+	// - error cannot get Foursquare User added to the failure handler ctx
+	foursquareHandler := foursquareHandler(success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	foursquareHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestValidateResponse(t *testing.T) {
+	validUser := &User{ID: "1234567"}
+	validResponse := &http.Response{StatusCode: 200}
+	invalidResponse := &http.Response{StatusCode: 500}
+	assert.Equal(t, nil, validateResponse(validUser, validResponse, nil))
+	assert.True(t, errors.Is(validateResponse(validUser, validResponse, fmt.Errorf("Server error")), ErrUnableToGetFoursquareUser))
+	assert.True(t, errors.Is(validateResponse(validUser, invalidResponse, nil), ErrUnableToGetFoursquareUser))
+	assert.True(t, errors.Is(validateResponse(&User{}, validResponse, nil), ErrUnableToGetFoursquareUser))
+	assert.True(t, errors.Is(validateResponse(nil, validResponse, nil), ErrUnableToGetFoursquareUser))
+}
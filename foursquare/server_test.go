@@ -0,0 +1,47 @@
+package foursquare
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/dghubble/gologin/testutils"
+)
+
+const (
+	// testUserJSON is a users/self response, which nests the user under
+	// response.user.
+	testUserJSON = `{"meta": {"code": 200, "requestId": "5d5e08b8c1e1a4002c2a4d1b"}, "response": {"user": {"id": "1234567", "firstName": "Jimmy", "lastName": "Foursquare", "gender": "male", "relationship": "self", "photo": {"prefix": "https://fastly.4sqi.net/img/user/", "suffix": "/1234567-ABCDEFGHIJKLMNOP.jpg"}, "contact": {"email": "jimmy@example.com", "twitter": "jimmyfoursquare"}, "checkins": {"count": 42}}}}`
+	// testInvalidTokenJSON is a users/self error response.
+	testInvalidTokenJSON = `{"meta": {"code": 401, "errorType": "invalid_auth", "errorDetail": "OAuth token invalid or revoked.", "requestId": "5d5e08b8c1e1a4002c2a4d1c"}, "response": {}}`
+)
+
+// newFoursquareTestServer returns a new httptest.Server which mocks the
+// Foursquare access_token and users/self endpoints and a client which proxies
+// requests to the server. The users/self endpoint requires the oauth_token
+// and v query parameters and responds with the given status and json data.
+// The caller must close the server.
+func newFoursquareTestServer(status int, jsonData string) (*http.Client, *httptest.Server) {
+	client, mux, server := testutils.TestServer()
+	mux.HandleFunc("/oauth2/access_token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token": "any-token"}`)
+	})
+	mux.HandleFunc("/v2/users/self", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		w.Header().Set("Content-Type", "application/json")
+		if query.Get("v") != apiVersion {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, `{"meta": {"code": 400, "errorType": "param_error", "errorDetail": "Missing version parameter."}, "response": {}}`)
+			return
+		}
+		if query.Get("oauth_token") != "any-token" || r.Header.Get("Authorization") != "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprintf(w, testInvalidTokenJSON)
+			return
+		}
+		w.WriteHeader(status)
+		fmt.Fprintf(w, jsonData)
+	})
+	return client, server
+}
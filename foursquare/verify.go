@@ -0,0 +1,102 @@
+package foursquare
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/dghubble/gologin/internal/jsonclient"
+)
+
+const (
+	foursquareAPI = "https://api.foursquare.com/v2/"
+	// apiVersion is the (mandatory) v=YYYYMMDD version of API requests
+	apiVersion = "20231010"
+	// photoSize is the size of assembled avatar URLs
+	photoSize = "original"
+)
+
+// User is a Foursquare user.
+type User struct {
+	ID        string  `json:"id"`
+	FirstName string  `json:"firstName"`
+	LastName  string  `json:"lastName"`
+	Contact   Contact `json:"contact"`
+	Photo     Photo   `json:"photo"`
+}
+
+// Contact is a Foursquare user's contact information.
+type Contact struct {
+	Email string `json:"email"`
+}
+
+// Photo is a Foursquare photo, split into a URL prefix and suffix.
+type Photo struct {
+	Prefix string `json:"prefix"`
+	Suffix string `json:"suffix"`
+}
+
+// URL returns the photo URL of the size (e.g. "original" or "100x100"), the
+// prefix, size, and suffix concatenated. Returns "" if the photo is missing.
+// https://docs.foursquare.com/developer/reference/photos-v2
+func (p Photo) URL(size string) string {
+	if p.Prefix == "" || p.Suffix == "" {
+		return ""
+	}
+	return p.Prefix + size + p.Suffix
+}
+
+// AvatarURL returns the URL of the User's original size photo, if any.
+func (u *User) AvatarURL() string {
+	return u.Photo.URL(photoSize)
+}
+
+// Meta is the metadata of a Foursquare API response, which describes errors.
+// https://docs.foursquare.com/developer/reference/responses-errors
+type Meta struct {
+	Code        int    `json:"code"`
+	ErrorType   string `json:"errorType"`
+	ErrorDetail string `json:"errorDetail"`
+}
+
+func (m *Meta) Error() string {
+	return fmt.Sprintf("foursquare: %s (%s)", m.ErrorDetail, m.ErrorType)
+}
+
+// userResponse is a Foursquare users/self response, which nests the User
+// under response.user.
+type userResponse struct {
+	Meta     Meta `json:"meta"`
+	Response struct {
+		User *User `json:"user"`
+	} `json:"response"`
+}
+
+// client is a Foursquare client for obtaining the current User.
+type client struct {
+	json *jsonclient.Client
+}
+
+// newClient returns a new Foursquare client.
+func newClient(httpClient *http.Client) *client {
+	return &client{
+		json: jsonclient.New(httpClient, foursquareAPI),
+	}
+}
+
+// UsersSelf returns the User of the access token. Foursquare API requests
+// pass the oauth_token and API version as query parameters, rather than an
+// Authorization header. Error responses are returned as a *Meta.
+// https://docs.foursquare.com/developer/reference/users-self
+func (c *client) UsersSelf(ctx context.Context, accessToken string) (*User, *http.Response, error) {
+	params := url.Values{}
+	params.Set("oauth_token", accessToken)
+	params.Set("v", apiVersion)
+	success, failure := new(userResponse), new(userResponse)
+	resp, err := c.json.Get(ctx, "users/self", params, success, failure)
+	if err == nil && failure.Meta.ErrorType != "" {
+		err = &failure.Meta
+	}
+	return success.Response.User, resp, err
+}
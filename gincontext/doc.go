@@ -0,0 +1,19 @@
+/*
+Package gincontext adapts gologin handlers to Gin.
+
+Wrap a LoginHandler chain as a gin.HandlerFunc with Wrap and a
+CallbackHandler chain with Callback. On success, the gologin ctx is set on
+the gin.Context Request and the next handler is called. On failure, the error
+is added with c.Error and the request is aborted.
+
+	router := gin.Default()
+	stateConfig := gologin.DebugOnlyCookieConfig
+	router.GET("/facebook/login", gincontext.Wrap(facebook.StateHandler(stateConfig, facebook.LoginHandler(oauth2Config, nil))))
+	router.GET("/facebook/callback", gincontext.Callback(func(success, failure http.Handler) http.Handler {
+		return facebook.StateHandler(stateConfig, facebook.CallbackHandler(oauth2Config, success, failure))
+	}, gincontext.Config{}), func(c *gin.Context) {
+		user, _ := gincontext.FacebookUser(c)
+		c.String(http.StatusOK, "Hello %s", user.Name)
+	})
+*/
+package gincontext
@@ -0,0 +1,115 @@
+package gincontext
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/facebook"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/oauth2"
+)
+
+// TokenKey is the gin.Context key of the OAuth2 Token after a successful
+// Callback.
+const TokenKey = "gologin.token"
+
+// unexported key type prevents collisions
+type key int
+
+const (
+	ginKey key = iota
+)
+
+// Config configures Callback failure handling.
+type Config struct {
+	// FailureStatus is the status failed callbacks abort with. Defaults to
+	// 401 Unauthorized.
+	FailureStatus int
+}
+
+// failureStatus returns the Config FailureStatus or 401 Unauthorized.
+func (c Config) failureStatus() int {
+	if c.FailureStatus == 0 {
+		return http.StatusUnauthorized
+	}
+	return c.FailureStatus
+}
+
+// Wrap returns a gin.HandlerFunc which calls the handler (e.g. a
+// LoginHandler chain) with the gin.Context deadline and values in its ctx.
+func Wrap(handler http.Handler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		handler.ServeHTTP(c.Writer, c.Request.WithContext(newContext(c)))
+	}
+}
+
+// Callback returns a gin.HandlerFunc which calls the handler chain built by
+// newHandler (e.g. a CallbackHandler chain) with the gin.Context deadline and
+// values in its ctx. The chain is built once with success and failure
+// handlers which return control to Gin.
+//
+// If the chain succeeds, the gologin ctx is set on the gin.Context Request,
+// the OAuth2 Token (if any) is set as the TokenKey, and the next handler is
+// called. Providers' UserFromContext read the User from c.Request.Context()
+// (see FacebookUser). If the chain fails, the gologin error is added with
+// c.Error and the request is aborted with the Config FailureStatus.
+func Callback(newHandler func(success, failure http.Handler) http.Handler, config Config) gin.HandlerFunc {
+	status := config.failureStatus()
+	success := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		c := ginFromContext(ctx)
+		c.Request = req
+		if token, err := oauth2Login.TokenFromContext(ctx); err == nil {
+			c.Set(TokenKey, token)
+		}
+		c.Next()
+	}
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		c := ginFromContext(req.Context())
+		c.Error(gologin.ErrorFromContext(req.Context()))
+		c.AbortWithStatus(status)
+	}
+	handler := newHandler(http.HandlerFunc(success), http.HandlerFunc(failure))
+	return func(c *gin.Context) {
+		handler.ServeHTTP(c.Writer, c.Request.WithContext(newContext(c)))
+	}
+}
+
+// Token returns the OAuth2 Token of a successful Callback.
+func Token(c *gin.Context) (*oauth2.Token, error) {
+	return oauth2Login.TokenFromContext(c.Request.Context())
+}
+
+// FacebookUser returns the Facebook User of a successful facebook Callback.
+func FacebookUser(c *gin.Context) (*facebook.User, error) {
+	return facebook.UserFromContext(c.Request.Context())
+}
+
+// newContext returns a copy of the gin.Context Request ctx which stores the
+// gin.Context and reads values of its Keys.
+func newContext(c *gin.Context) context.Context {
+	return &ginContext{Context: context.WithValue(c.Request.Context(), ginKey, c), c: c}
+}
+
+// ginFromContext returns the gin.Context stored by newContext.
+func ginFromContext(ctx context.Context) *gin.Context {
+	return ctx.Value(ginKey).(*gin.Context)
+}
+
+// ginContext is a context.Context which reads string keys from the
+// gin.Context Keys before its parent.
+type ginContext struct {
+	context.Context
+	c *gin.Context
+}
+
+func (ctx *ginContext) Value(key interface{}) interface{} {
+	if name, ok := key.(string); ok {
+		if value, exists := ctx.c.Get(name); exists {
+			return value
+		}
+	}
+	return ctx.Context.Value(key)
+}
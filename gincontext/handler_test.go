@@ -0,0 +1,126 @@
+package gincontext
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/facebook"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// testCallbackHandler returns a callback chain which reads the "tenant"
+// value of the ctx and adds the Token and Facebook User to the ctx, or fails
+// if the request state is invalid.
+func testCallbackHandler(success, failure http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		if req.URL.Query().Get("state") != "d4e5f6" {
+			ctx = gologin.WithError(ctx, oauth2Login.ErrInvalidState)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		tenant, _ := ctx.Value("tenant").(string)
+		ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+		ctx = facebook.WithUser(ctx, &facebook.User{ID: "54638001", Name: "Ivy Crimson " + tenant})
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+func TestWrap(t *testing.T) {
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("tenant", "acme")
+	})
+	login := func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "acme", req.Context().Value("tenant"))
+		http.Redirect(w, req, "https://www.facebook.com/dialog/oauth", http.StatusFound)
+	}
+
+	// Wrap assert that:
+	// - the handler is called with the gin.Context values in its ctx
+	router.GET("/facebook/login", Wrap(http.HandlerFunc(login)))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/facebook/login", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusFound, w.Code)
+}
+
+func TestCallback(t *testing.T) {
+	type reqKey struct{}
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("tenant", "acme")
+	})
+	next := func(c *gin.Context) {
+		token, err := Token(c)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "any-token", token.AccessToken)
+		}
+		value, _ := c.Get(TokenKey)
+		assert.Equal(t, token, value)
+		user, err := FacebookUser(c)
+		if assert.Nil(t, err) {
+			assert.Equal(t, &facebook.User{ID: "54638001", Name: "Ivy Crimson acme"}, user)
+		}
+		// values of the original request ctx are kept
+		assert.Equal(t, "request value", c.Request.Context().Value(reqKey{}))
+		c.String(http.StatusOK, "success handler called")
+	}
+
+	// Callback assert that:
+	// - the chain is called with the gin.Context and request ctx values
+	// - the next handler is called with the Token and User
+	router.GET("/facebook/callback", Callback(testCallbackHandler, Config{}), next)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/facebook/callback?code=any_code&state=d4e5f6", nil)
+	req = req.WithContext(context.WithValue(req.Context(), reqKey{}, "request value"))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestCallback_Failure(t *testing.T) {
+	cases := []struct {
+		config Config
+		status int
+	}{
+		{Config{}, http.StatusUnauthorized},
+		{Config{FailureStatus: http.StatusForbidden}, http.StatusForbidden},
+	}
+	for _, c := range cases {
+		var ginErrors []*gin.Error
+		router := gin.New()
+		router.Use(func(c *gin.Context) {
+			c.Next()
+			ginErrors = c.Errors
+		})
+		next := func(c *gin.Context) {
+			t.Errorf("unexpected call to next handler")
+		}
+
+		// Callback with a failing chain, assert that:
+		// - the next handler is not called
+		// - the gologin error is added to the gin.Context Errors
+		// - the request is aborted with the Config FailureStatus
+		router.GET("/facebook/callback", Callback(testCallbackHandler, c.config), next)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/facebook/callback", nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, c.status, w.Code)
+		if assert.Len(t, ginErrors, 1) {
+			assert.True(t, errors.Is(ginErrors[0].Err, oauth2Login.ErrInvalidState))
+		}
+	}
+}
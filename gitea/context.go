@@ -0,0 +1,47 @@
+package gitea
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/dghubble/gologin"
+)
+
+// unexported key type prevents collisions
+type key int
+
+const (
+	userKey key = iota
+)
+
+// WithUser returns a copy of ctx that stores the Gitea User and its gologin
+// Profile.
+func WithUser(ctx context.Context, user *User) context.Context {
+	ctx = gologin.WithProfile(ctx, newProfile(user))
+	return context.WithValue(ctx, userKey, user)
+}
+
+// UserFromContext returns the Gitea User from the ctx.
+func UserFromContext(ctx context.Context) (*User, error) {
+	user, ok := ctx.Value(userKey).(*User)
+	if !ok {
+		return nil, fmt.Errorf("gitea: Context missing Gitea User")
+	}
+	return user, nil
+}
+
+// newProfile returns the gologin Profile of the Gitea User.
+func newProfile(user *User) *gologin.Profile {
+	if user == nil {
+		return nil
+	}
+	return &gologin.Profile{
+		Provider:  ProviderName,
+		ID:        strconv.FormatInt(user.ID, 10),
+		Email:     user.Email,
+		Name:      gologin.DisplayName(user.FullName, user.Login),
+		AvatarURL: user.AvatarURL,
+		Raw:       gologin.ProfileRaw(user, "id", "email", "full_name", "avatar_url"),
+	}
+}
@@ -0,0 +1,3 @@
+// Package gitea provides Gitea and Forgejo OAuth2 login and callback handlers
+// for self-hosted instances.
+package gitea
@@ -0,0 +1,151 @@
+package gitea
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/internal"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"golang.org/x/oauth2"
+)
+
+// ProviderName is the provider name set in the ctx (see
+// gologin.ProviderFromContext) and the Profile.
+const ProviderName = "gitea"
+
+const defaultBaseURL = "https://gitea.com"
+
+// Gitea login errors
+var (
+	ErrUnableToGetGiteaUser = errors.New("gitea: unable to get Gitea User")
+	ErrUserInactive         = errors.New("gitea: Gitea User is not active")
+)
+
+// Endpoint is gitea.com's OAuth2 endpoint.
+var Endpoint = NewEndpoint(defaultBaseURL)
+
+// NewEndpoint returns the OAuth2 endpoint of the Gitea or Forgejo instance at
+// the baseURL (e.g. "https://git.example.com" or
+// "https://git.example.com/gitea/"). If the baseURL is empty, gitea.com is
+// used. Panics if the baseURL is not an absolute URL.
+func NewEndpoint(baseURL string) oauth2.Endpoint {
+	baseURL = mustNormalizeBaseURL(baseURL)
+	return oauth2.Endpoint{
+		AuthURL:  baseURL + "/login/oauth/authorize",
+		TokenURL: baseURL + "/login/oauth/access_token",
+	}
+}
+
+// Config configures Gitea login.
+type Config struct {
+	// BaseURL is the URL of the Gitea or Forgejo instance, including any path
+	// prefix (e.g. "https://git.example.com/gitea/"). If empty, gitea.com is
+	// used. The oauth2 Config Endpoint should be NewEndpoint(BaseURL).
+	BaseURL string
+}
+
+// mustNormalizeBaseURL returns the baseURL (or the default) without a trailing
+// slash. It panics if the baseURL is invalid.
+func mustNormalizeBaseURL(baseURL string) string {
+	if baseURL == "" {
+		return defaultBaseURL
+	}
+	u, err := url.Parse(baseURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		panic("gitea: invalid Config BaseURL " + baseURL)
+	}
+	return strings.TrimRight(u.String(), "/")
+}
+
+// StateHandler checks for a state cookie. If found, the state value is read
+// and added to the ctx. Otherwise, a non-guessable value is added to the ctx
+// and to a (short-lived) state cookie issued to the requester.
+//
+// Implements OAuth 2 RFC 6749 10.12 CSRF Protection. If you wish to issue
+// state params differently, write a http.Handler which sets the ctx state,
+// using oauth2 WithState(ctx, state) since it is required by LoginHandler
+// and CallbackHandler.
+func StateHandler(config gologin.CookieConfig, success http.Handler) http.Handler {
+	return oauth2Login.StateHandler(config, success)
+}
+
+// LoginHandler handles Gitea login requests by reading the state value from
+// the ctx and redirecting requests to the AuthURL with that state value.
+// Any AuthCodeOptions are added to the AuthURL.
+func LoginHandler(config *oauth2.Config, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	return oauth2Login.LoginHandler(config, failure, opts...)
+}
+
+// CallbackHandler handles gitea.com redirection URI requests and adds the
+// Gitea access token and User to the ctx. If authentication succeeds,
+// handling delegates to the success handler, otherwise to the failure
+// handler.
+// Any AuthCodeOptions are sent with the token exchange.
+func CallbackHandler(config *oauth2.Config, success, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	return CallbackHandlerWithConfig(config, Config{}, success, failure, opts...)
+}
+
+// CallbackHandlerWithConfig handles Gitea redirection URI requests like
+// CallbackHandler, but gets the User from the instance at the Config
+// BaseURL. Panics if the BaseURL is invalid.
+func CallbackHandlerWithConfig(config *oauth2.Config, giteaConfig Config, success, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	success = giteaHandler(config, giteaConfig, success, failure)
+	return oauth2Login.CallbackHandler(config, success, failure, opts...)
+}
+
+// giteaHandler is a http.Handler that gets the OAuth2 Token from the ctx to
+// get the corresponding Gitea User. If successful, the User is added to the
+// ctx and the success handler is called. Otherwise, the failure handler is
+// called. Users who are not active fail with ErrUserInactive.
+func giteaHandler(config *oauth2.Config, giteaConfig Config, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	baseURL := mustNormalizeBaseURL(giteaConfig.BaseURL)
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		httpClient := internal.OAuth2Client(ctx, config, token)
+		user, resp, err := newClient(httpClient, baseURL).CurrentUser(ctx)
+		err = validateResponse(user, resp, err)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		if !user.Active {
+			ctx = gologin.WithError(ctx, ErrUserInactive)
+			ctx = gologin.WithStatusCode(ctx, http.StatusForbidden)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		ctx = WithUser(ctx, user)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// validateResponse returns an error if the given Gitea User, raw
+// http.Response, or error are unexpected. Returns nil if they are valid, or a
+// *gologin.Error which preserves the cause and status code.
+func validateResponse(user *User, resp *http.Response, err error) error {
+	var status int
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	if err != nil || status != http.StatusOK {
+		return &gologin.Error{Provider: "gitea", Op: "get user", StatusCode: status, Err: err, Kind: ErrUnableToGetGiteaUser}
+	}
+	if user == nil || user.ID == 0 {
+		return &gologin.Error{Provider: "gitea", Op: "get user", StatusCode: status, Kind: ErrUnableToGetGiteaUser}
+	}
+	return nil
+}
@@ -0,0 +1,204 @@
+package gitea
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dghubble/gologin"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+const (
+	testUserJSON         = `{"id": 917324, "login": "sparkle", "full_name": "Sparkle Pony", "email": "sparkle@example.com", "avatar_url": "https://git.example.com/gitea/avatars/1", "is_admin": true, "active": true}`
+	testInactiveUserJSON = `{"id": 917324, "login": "sparkle", "full_name": "Sparkle Pony", "is_admin": false, "active": false}`
+	// older instances do not report whether users are active
+	testUnreportedActiveUserJSON = `{"id": 917324, "login": "sparkle"}`
+)
+
+func testConfig(baseURL string) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     "client_id",
+		ClientSecret: "client_secret",
+		RedirectURL:  "https://app.example.com/gitea/callback",
+		Endpoint:     NewEndpoint(baseURL),
+	}
+}
+
+func TestNewEndpoint(t *testing.T) {
+	cases := []struct {
+		baseURL  string
+		authURL  string
+		tokenURL string
+	}{
+		{"", "https://gitea.com/login/oauth/authorize", "https://gitea.com/login/oauth/access_token"},
+		{"https://git.example.com", "https://git.example.com/login/oauth/authorize", "https://git.example.com/login/oauth/access_token"},
+		{"https://git.example.com/", "https://git.example.com/login/oauth/authorize", "https://git.example.com/login/oauth/access_token"},
+		{"https://git.example.com/gitea", "https://git.example.com/gitea/login/oauth/authorize", "https://git.example.com/gitea/login/oauth/access_token"},
+		{"https://git.example.com/gitea/", "https://git.example.com/gitea/login/oauth/authorize", "https://git.example.com/gitea/login/oauth/access_token"},
+		{"https://example.com/tools/forgejo//", "https://example.com/tools/forgejo/login/oauth/authorize", "https://example.com/tools/forgejo/login/oauth/access_token"},
+	}
+	for _, c := range cases {
+		endpoint := NewEndpoint(c.baseURL)
+		assert.Equal(t, c.authURL, endpoint.AuthURL, c.baseURL)
+		assert.Equal(t, c.tokenURL, endpoint.TokenURL, c.baseURL)
+	}
+	assert.Equal(t, NewEndpoint(""), Endpoint)
+	assert.Panics(t, func() { NewEndpoint("git.example.com/gitea") })
+	assert.Panics(t, func() { CallbackHandlerWithConfig(testConfig(""), Config{BaseURL: "/gitea"}, nil, nil) })
+}
+
+func TestCallbackHandler_PathPrefix(t *testing.T) {
+	// CallbackHandlerWithConfig for instances under a path prefix, assert that:
+	// - the Token is obtained from the prefixed /login/oauth/access_token
+	// - the Gitea User is obtained from the prefixed /api/v1/user
+	// - success handler is called with the Token and User in the ctx
+	cases := []struct {
+		baseURL string
+		prefix  string
+	}{
+		{"https://git.example.com", ""},
+		{"https://git.example.com/gitea", "/gitea"},
+		{"https://git.example.com/gitea/", "/gitea"},
+		{"https://example.com/tools/forgejo/", "/tools/forgejo"},
+	}
+	for _, c := range cases {
+		proxyClient, server := newGiteaTestServer(c.prefix, http.StatusOK, testUserJSON)
+		// oauth2 Client will use the proxy client's base Transport
+		ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+		ctx = oauth2Login.WithState(ctx, "d4e5f6")
+
+		success := func(w http.ResponseWriter, req *http.Request) {
+			ctx := req.Context()
+			token, err := oauth2Login.TokenFromContext(ctx)
+			if assert.Nil(t, err, c.baseURL) {
+				assert.Equal(t, "any-token", token.AccessToken, c.baseURL)
+			}
+			user, err := UserFromContext(ctx)
+			if assert.Nil(t, err, c.baseURL) {
+				expectedUser := &User{ID: 917324, Login: "sparkle", FullName: "Sparkle Pony", Email: "sparkle@example.com", AvatarURL: "https://git.example.com/gitea/avatars/1", IsAdmin: true, Active: true}
+				assert.Equal(t, expectedUser, user, c.baseURL)
+			}
+			fmt.Fprintf(w, "success handler called")
+		}
+		callbackHandler := CallbackHandlerWithConfig(testConfig(c.baseURL), Config{BaseURL: c.baseURL}, http.HandlerFunc(success), testutils.AssertFailureNotCalled(t))
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+		callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+		assert.Equal(t, "success handler called", w.Body.String(), c.baseURL)
+		server.Close()
+	}
+}
+
+func TestGiteaHandler_UnreportedActive(t *testing.T) {
+	proxyClient, server := newGiteaTestServer("/gitea", http.StatusOK, testUnreportedActiveUserJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+
+	success := func(w http.ResponseWriter, req *http.Request) {
+		user, err := UserFromContext(req.Context())
+		if assert.Nil(t, err) {
+			assert.True(t, user.Active)
+			assert.False(t, user.IsAdmin)
+		}
+		fmt.Fprintf(w, "success handler called")
+	}
+
+	// GiteaHandler for an instance which does not report active, assert that:
+	// - the User is treated as active
+	giteaHandler := giteaHandler(testConfig(""), Config{BaseURL: "https://git.example.com/gitea/"}, http.HandlerFunc(success), testutils.AssertFailureNotCalled(t))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	giteaHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestGiteaHandler_InactiveUser(t *testing.T) {
+	proxyClient, server := newGiteaTestServer("/gitea", http.StatusOK, testInactiveUserJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, ErrUserInactive, gologin.ErrorFromContext(req.Context()))
+		assert.Equal(t, http.StatusForbidden, gologin.StatusCodeFromContext(req.Context()))
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// GiteaHandler for an inactive User, assert that:
+	// - failure handler is called
+	// - error Gitea User is not active added to the failure handler ctx
+	// - the failure status code is 403 Forbidden
+	giteaHandler := giteaHandler(testConfig(""), Config{BaseURL: "https://git.example.com/gitea/"}, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	giteaHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestGiteaHandler_MissingCtxToken(t *testing.T) {
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "oauth2: Context missing Token", err.Error())
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// GiteaHandler called without Token in ctx, assert that:
+	// - failure handler is called
+	// - error about ctx missing token is added to the failure handler ctx
+	giteaHandler := giteaHandler(testConfig(""), Config{}, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	giteaHandler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestGiteaHandler_ErrorGettingUser(t *testing.T) {
+	proxyClient, server := testutils.NewErrorServer("Gitea Service Down", http.StatusInternalServerError)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.True(t, errors.Is(err, ErrUnableToGetGiteaUser))
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// GiteaHandler cannot get Gitea User, assert that:
+	// - failure handler is called
+	// - error cannot get Gitea User added to the failure handler ctx
+	giteaHandler := giteaHandler(testConfig(""), Config{BaseURL: "https://git.example.com/gitea/"}, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	giteaHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestValidateResponse(t *testing.T) {
+	validUser := &User{ID: 917324}
+	validResponse := &http.Response{StatusCode: 200}
+	invalidResponse := &http.Response{StatusCode: 500}
+	assert.Equal(t, nil, validateResponse(validUser, validResponse, nil))
+	assert.True(t, errors.Is(validateResponse(validUser, validResponse, fmt.Errorf("Server error")), ErrUnableToGetGiteaUser))
+	assert.True(t, errors.Is(validateResponse(validUser, invalidResponse, nil), ErrUnableToGetGiteaUser))
+	assert.True(t, errors.Is(validateResponse(&User{}, validResponse, nil), ErrUnableToGetGiteaUser))
+	assert.True(t, errors.Is(validateResponse(nil, validResponse, nil), ErrUnableToGetGiteaUser))
+}
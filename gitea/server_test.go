@@ -0,0 +1,28 @@
+package gitea
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/dghubble/gologin/testutils"
+)
+
+// newGiteaTestServer returns a new httptest.Server which mocks the Gitea
+// token and API endpoints of an instance mounted at the path prefix (e.g.
+// "/gitea") and a client which proxies requests to the server. The
+// /api/v1/user endpoint responds with the given status and user json data.
+// The caller must close the server.
+func newGiteaTestServer(prefix string, status int, userJSON string) (*http.Client, *httptest.Server) {
+	client, mux, server := testutils.TestServer()
+	mux.HandleFunc(prefix+"/login/oauth/access_token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token": "any-token", "token_type": "bearer", "expires_in": 3600, "refresh_token": "any-refresh"}`)
+	})
+	mux.HandleFunc(prefix+"/api/v1/user", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		fmt.Fprintf(w, userJSON)
+	})
+	return client, server
+}
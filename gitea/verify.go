@@ -0,0 +1,44 @@
+package gitea
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/dghubble/gologin/internal/jsonclient"
+)
+
+// User is a Gitea (or Forgejo) user.
+type User struct {
+	ID        int64  `json:"id"`
+	Login     string `json:"login"`
+	FullName  string `json:"full_name"`
+	Email     string `json:"email"`
+	AvatarURL string `json:"avatar_url"`
+	// IsAdmin is true for instance administrators
+	IsAdmin bool `json:"is_admin"`
+	// Active is false for users who have not activated their account (true
+	// if the instance does not report it)
+	Active bool `json:"active"`
+}
+
+// client is a Gitea client for obtaining a User.
+type client struct {
+	json *jsonclient.Client
+}
+
+// newClient returns a new Gitea client for the instance at the (normalized)
+// baseURL.
+func newClient(httpClient *http.Client, baseURL string) *client {
+	return &client{
+		// a trailing slash keeps the baseURL path prefix on relative paths
+		json: jsonclient.New(httpClient, baseURL+"/"),
+	}
+}
+
+// CurrentUser gets the authenticated Gitea User.
+// https://gitea.com/api/swagger#/user/userGetCurrent
+func (c *client) CurrentUser(ctx context.Context) (*User, *http.Response, error) {
+	user := &User{Active: true}
+	resp, err := c.json.Get(ctx, "api/v1/user", nil, user, nil)
+	return user, resp, err
+}
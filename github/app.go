@@ -0,0 +1,161 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/internal"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"golang.org/x/oauth2"
+)
+
+// installationRequiredCode is the error code of GitHub App authorizations
+// which require the App to be installed first.
+const installationRequiredCode = "installation_required"
+
+// ErrInstallationRequired is the Kind of InstallationRequiredError.
+var ErrInstallationRequired = errors.New("github: GitHub App installation required")
+
+// InstallationRequiredError is passed to the failure handler when a GitHub
+// App authorization requires the App to be installed first.
+type InstallationRequiredError struct {
+	// InstallURL is the Config InstallURL users should be redirected to
+	InstallURL string
+	// Err is the underlying authorization or token exchange error
+	Err error
+}
+
+func (e *InstallationRequiredError) Error() string {
+	return ErrInstallationRequired.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *InstallationRequiredError) Unwrap() error {
+	return e.Err
+}
+
+// Is returns true for ErrInstallationRequired.
+func (e *InstallationRequiredError) Is(target error) bool {
+	return target == ErrInstallationRequired
+}
+
+// AppToken is a GitHub App user-to-server Token. The access token (ghu_) and
+// its Expiry come from the embedded Token, the refresh token (ghr_) expires at
+// RefreshTokenExpiry.
+type AppToken struct {
+	*oauth2.Token
+	// RefreshTokenExpiry is when the RefreshToken expires. It is zero if the
+	// token response had no refresh_token_expires_in.
+	RefreshTokenExpiry time.Time
+}
+
+// AppCallbackHandler handles GitHub App user authorization redirection URI
+// requests like CallbackHandlerWithConfig, but also adds the expiring
+// user-to-server AppToken to the ctx (see AppTokenFromContext). The token
+// request asks for a JSON response (Accept: application/json).
+//
+// If the App must be installed before users may authorize it, the failure
+// handler is called with an *InstallationRequiredError with the Config
+// InstallURL.
+func AppCallbackHandler(config *oauth2.Config, githubConfig Config, success, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	failure = installationRequiredHandler(githubConfig.InstallURL, failure)
+	success = githubHandler(config, githubConfig, success, failure)
+	success = appTokenHandler(success, failure)
+	callback := oauth2Login.CallbackHandler(config, success, failure, opts...)
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		ctx = context.WithValue(ctx, oauth2.HTTPClient, acceptJSONClient(internal.ContextClient(ctx)))
+		callback.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// appTokenHandler is a http.Handler that gets the OAuth2 Token from the ctx
+// and adds it to the ctx as an AppToken.
+func appTokenHandler(success, failure http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		ctx = WithAppToken(ctx, newAppToken(token))
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// installationRequiredHandler is a failure http.Handler which replaces
+// installation_required authorization and token exchange errors in the ctx
+// with an *InstallationRequiredError.
+func installationRequiredHandler(installURL string, failure http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		err := gologin.ErrorFromContext(ctx)
+		var authErr *oauth2Login.AuthorizationError
+		var retrieveErr *oauth2.RetrieveError
+		if (errors.As(err, &authErr) && authErr.Code == installationRequiredCode) ||
+			(errors.As(err, &retrieveErr) && retrieveErr.ErrorCode == installationRequiredCode) {
+			ctx = gologin.WithError(ctx, &InstallationRequiredError{InstallURL: installURL, Err: err})
+		}
+		failure.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// newAppToken returns the AppToken of a Token. The refresh_token_expires_in
+// (seconds) of JSON responses is a float64 and of form responses is an int64
+// (or a string if unparseable).
+func newAppToken(token *oauth2.Token) *AppToken {
+	appToken := &AppToken{Token: token}
+	var seconds int64
+	switch v := token.Extra("refresh_token_expires_in").(type) {
+	case float64:
+		seconds = int64(v)
+	case int64:
+		seconds = v
+	case string:
+		seconds, _ = strconv.ParseInt(v, 10, 64)
+	}
+	if seconds > 0 {
+		appToken.RefreshTokenExpiry = time.Now().Add(time.Duration(seconds) * time.Second)
+	}
+	return appToken
+}
+
+// acceptJSONClient returns a copy of the http.Client which requests JSON
+// responses, unless requests set an Accept header.
+func acceptJSONClient(client *http.Client) *http.Client {
+	c := *client
+	c.Transport = &acceptJSONTransport{base: client.Transport}
+	return &c
+}
+
+// acceptJSONTransport is a http.RoundTripper which sets a default
+// Accept: application/json request header.
+type acceptJSONTransport struct {
+	base http.RoundTripper
+}
+
+func (t *acceptJSONTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if req.Header.Get("Accept") != "" {
+		return base.RoundTrip(req)
+	}
+	// RoundTrippers must not modify the request
+	req = req.Clone(req.Context())
+	req.Header.Set("Accept", "application/json")
+	return base.RoundTrip(req)
+}
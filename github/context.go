@@ -2,9 +2,11 @@ package github
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"strconv"
 
-	"github.com/google/go-github/github"
+	"github.com/dghubble/gologin"
 )
 
 // unexported key type prevents collisions
@@ -12,18 +14,83 @@ type key int
 
 const (
 	userKey key = iota
+	membershipKey
+	appTokenKey
+	rawUserKey
 )
 
-// WithUser returns a copy of ctx that stores the Github User.
-func WithUser(ctx context.Context, user *github.User) context.Context {
+// WithUser returns a copy of ctx that stores the Github User and its gologin
+// Profile.
+func WithUser(ctx context.Context, user *User) context.Context {
+	ctx = gologin.WithProfile(ctx, newProfile(user))
 	return context.WithValue(ctx, userKey, user)
 }
 
 // UserFromContext returns the Github User from the ctx.
-func UserFromContext(ctx context.Context) (*github.User, error) {
-	user, ok := ctx.Value(userKey).(*github.User)
+func UserFromContext(ctx context.Context) (*User, error) {
+	user, ok := ctx.Value(userKey).(*User)
 	if !ok {
 		return nil, fmt.Errorf("github: Context missing Github User")
 	}
 	return user, nil
 }
+
+// WithRawUser returns a copy of ctx that stores the raw Github /user response
+// body.
+func WithRawUser(ctx context.Context, raw json.RawMessage) context.Context {
+	return context.WithValue(ctx, rawUserKey, raw)
+}
+
+// RawUserFromContext returns the raw Github /user response body from the ctx,
+// including fields the User does not decode, to decode into richer
+// types.
+func RawUserFromContext(ctx context.Context) (json.RawMessage, error) {
+	raw, ok := ctx.Value(rawUserKey).(json.RawMessage)
+	if !ok {
+		return nil, fmt.Errorf("github: Context missing raw Github User")
+	}
+	return raw, nil
+}
+
+// newProfile returns the gologin Profile of the Github User.
+func newProfile(user *User) *gologin.Profile {
+	if user == nil {
+		return nil
+	}
+	return &gologin.Profile{
+		Provider:  ProviderName,
+		ID:        strconv.FormatInt(user.ID, 10),
+		Email:     user.Email,
+		Name:      gologin.DisplayName(user.Name, user.Login),
+		AvatarURL: user.AvatarURL,
+		Raw:       gologin.ProfileRaw(user, "id", "email", "name", "avatar_url"),
+	}
+}
+
+// WithMembership returns a copy of ctx that stores the Github Membership.
+func WithMembership(ctx context.Context, membership *Membership) context.Context {
+	return context.WithValue(ctx, membershipKey, membership)
+}
+
+// MembershipFromContext returns the Github Membership from the ctx.
+func MembershipFromContext(ctx context.Context) (*Membership, error) {
+	membership, ok := ctx.Value(membershipKey).(*Membership)
+	if !ok {
+		return nil, fmt.Errorf("github: Context missing Github Membership")
+	}
+	return membership, nil
+}
+
+// WithAppToken returns a copy of ctx that stores the GitHub App AppToken.
+func WithAppToken(ctx context.Context, token *AppToken) context.Context {
+	return context.WithValue(ctx, appTokenKey, token)
+}
+
+// AppTokenFromContext returns the GitHub App AppToken from the ctx.
+func AppTokenFromContext(ctx context.Context) (*AppToken, error) {
+	token, ok := ctx.Value(appTokenKey).(*AppToken)
+	if !ok {
+		return nil, fmt.Errorf("github: Context missing GitHub App Token")
+	}
+	return token, nil
+}
@@ -2,16 +2,18 @@ package github
 
 import (
 	"context"
+	"encoding/json"
 	"testing"
 
-	"github.com/google/go-github/github"
+	"github.com/dghubble/gologin"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
 )
 
 func TestContextUser(t *testing.T) {
-	expectedUser := &github.User{
-		ID:   github.Int64(917408),
-		Name: github.String("Github User"),
+	expectedUser := &User{
+		ID:   917408,
+		Name: "Github User",
 	}
 	ctx := WithUser(context.Background(), expectedUser)
 	user, err := UserFromContext(ctx)
@@ -26,3 +28,60 @@ func TestContextUser_Error(t *testing.T) {
 		assert.Equal(t, "github: Context missing Github User", err.Error())
 	}
 }
+
+func TestContextProfile(t *testing.T) {
+	user := &User{
+		ID:        917408,
+		Login:     "octocat",
+		AvatarURL: "https://avatars.githubusercontent.com/u/917408",
+	}
+	profile, err := gologin.ProfileFromContext(WithUser(context.Background(), user))
+	if assert.Nil(t, err) {
+		assert.Equal(t, ProviderName, profile.Provider)
+		assert.Equal(t, "917408", profile.ID)
+		assert.Equal(t, "", profile.Email)
+		assert.Equal(t, "octocat", profile.Name)
+		assert.Equal(t, "https://avatars.githubusercontent.com/u/917408", profile.AvatarURL)
+		assert.Equal(t, "octocat", profile.Raw["login"])
+	}
+}
+
+func TestContextMembership(t *testing.T) {
+	expected := &Membership{State: "active"}
+	ctx := WithMembership(context.Background(), expected)
+	membership, err := MembershipFromContext(ctx)
+	assert.Equal(t, expected, membership)
+	assert.Nil(t, err)
+
+	membership, err = MembershipFromContext(context.Background())
+	assert.Nil(t, membership)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "github: Context missing Github Membership", err.Error())
+	}
+}
+
+func TestContextAppToken(t *testing.T) {
+	expected := &AppToken{Token: &oauth2.Token{AccessToken: "ghu_token"}}
+	ctx := WithAppToken(context.Background(), expected)
+	token, err := AppTokenFromContext(ctx)
+	assert.Equal(t, expected, token)
+	assert.Nil(t, err)
+
+	token, err = AppTokenFromContext(context.Background())
+	assert.Nil(t, token)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "github: Context missing GitHub App Token", err.Error())
+	}
+}
+
+func TestContextRawUser(t *testing.T) {
+	ctx := WithRawUser(context.Background(), json.RawMessage(`{"id": "1"}`))
+	raw, err := RawUserFromContext(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"id": "1"}`, string(raw))
+
+	_, err = RawUserFromContext(context.Background())
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "github: Context missing raw Github User", err.Error())
+	}
+}
@@ -1,18 +1,28 @@
 package github
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 
 	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/internal"
 	oauth2Login "github.com/dghubble/gologin/oauth2"
-	"github.com/google/go-github/github"
 	"golang.org/x/oauth2"
 )
 
+// ProviderName is the provider name set in the ctx (see
+// gologin.ProviderFromContext) and reported to gologin Hooks.
+const ProviderName = "github"
+
 // Github login errors
 var (
-	ErrUnableToGetGithubUser = errors.New("github: unable to get Github User")
+	ErrUnableToGetGithubUser   = errors.New("github: unable to get Github User")
+	ErrUnableToGetGithubEmails = errors.New("github: unable to get Github User emails")
 )
 
 // StateHandler checks for a state cookie. If found, the state value is read
@@ -27,28 +37,146 @@ func StateHandler(config gologin.CookieConfig, success http.Handler) http.Handle
 	return oauth2Login.StateHandler(config, success)
 }
 
+// StatelessStateHandler issues and verifies states which are signed tokens
+// rather than cookies, for Github logins started where cookies cannot be set.
+// See oauth2 StatelessStateHandler.
+func StatelessStateHandler(config oauth2Login.StatelessStateConfig, success, failure http.Handler) http.Handler {
+	return oauth2Login.StatelessStateHandler(config, success, failure)
+}
+
+// Config configures Github login.
+type Config struct {
+	// BaseURL is the GitHub Enterprise Server API base URL used to get the
+	// Github User (e.g. "https://ghe.example.com/api/v3/"). URLs without a
+	// path use the Enterprise "api/v3/" path. Defaults to api.github.com.
+	BaseURL string
+	// UploadURL is the GitHub Enterprise Server upload URL. Defaults to the
+	// "api/uploads/" path of the BaseURL host. Login does not upload, so it
+	// is only normalized and validated.
+	UploadURL string
+	// FetchPrimaryEmail gets the primary verified email address from the
+	// /user/emails API when the Github User profile email is private (i.e.
+	// empty) and sets it as the User Email. Requires the user:email scope;
+	// if it was not granted, the User Email is left empty.
+	FetchPrimaryEmail bool
+	// RequiredScopes are scopes (e.g. "read:org") the user must grant. The
+	// granted scopes are read from the X-OAuth-Scopes header of the User
+	// response (or the token response) and compared case-insensitively. If
+	// any are missing, the failure handler is called with a 403 and an oauth2
+	// ErrMissingScopes. Scopes implied by broader scopes (e.g. "user:email"
+	// by "user") must be listed as granted by GitHub.
+	RequiredScopes []string
+	// InstallURL is the GitHub App installation URL (e.g.
+	// "https://github.com/apps/<app>/installations/new") reported by
+	// AppCallbackHandler's InstallationRequiredError.
+	InstallURL string
+	// Transport is the base transport of Github API requests. Handlers share
+	// it across logins so connections are reused. Defaults to a pooled
+	// transport per handler. The transport of a ctx oauth2.HTTPClient is
+	// used instead, if any.
+	Transport http.RoundTripper
+}
+
+// mustNormalize returns the Config with trailing slash terminated Enterprise
+// URLs. It panics if the BaseURL or UploadURL is invalid.
+func (c Config) mustNormalize() Config {
+	if c.BaseURL == "" {
+		return c
+	}
+	baseURL := mustParseEnterpriseURL("BaseURL", c.BaseURL, "api/v3/")
+	if c.UploadURL == "" {
+		uploadURL := *baseURL
+		uploadURL.Path = "/api/uploads/"
+		c.UploadURL = uploadURL.String()
+	} else {
+		c.UploadURL = mustParseEnterpriseURL("UploadURL", c.UploadURL, "api/uploads/").String()
+	}
+	c.BaseURL = baseURL.String()
+	return c
+}
+
+// withTransport returns the Config with a Transport for handlers to share.
+func (c Config) withTransport() Config {
+	c.Transport = internal.TransportOrNew(c.Transport)
+	return c
+}
+
+// mustParseEnterpriseURL parses an absolute Enterprise URL, defaulting an empty
+// path to defaultPath and adding a trailing slash so API paths resolve
+// beneath it.
+func mustParseEnterpriseURL(name, rawURL, defaultPath string) *url.URL {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		panic("github: invalid Config " + name + " " + rawURL)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/" + defaultPath
+	}
+	if !strings.HasSuffix(u.Path, "/") {
+		u.Path += "/"
+	}
+	return u
+}
+
 // LoginHandler handles Github login requests by reading the state value from
 // the ctx and redirecting requests to the AuthURL with that state value.
-func LoginHandler(config *oauth2.Config, failure http.Handler) http.Handler {
-	return oauth2Login.LoginHandler(config, failure)
+// Any AuthCodeOptions are added to the AuthURL.
+func LoginHandler(config *oauth2.Config, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	return gologin.ProviderHandler(ProviderName, oauth2Login.LoginHandler(config, failure, opts...))
 }
 
 // CallbackHandler handles Github redirection URI requests and adds the Github
 // access token and User to the ctx. If authentication succeeds, handling
 // delegates to the success handler, otherwise to the failure handler.
-func CallbackHandler(config *oauth2.Config, success, failure http.Handler) http.Handler {
-	success = githubHandler(config, success, failure)
-	return oauth2Login.CallbackHandler(config, success, failure)
+// Any AuthCodeOptions are sent with the token exchange.
+func CallbackHandler(config *oauth2.Config, success, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	return CallbackHandlerWithConfig(config, Config{}, success, failure, opts...)
+}
+
+// CallbackHandlerWithConfig handles Github redirection URI requests like
+// CallbackHandler, but applies the Config. If the Config has a BaseURL, the
+// Github User is obtained from it and the oauth2.Config Endpoint must be set to
+// the matching authorize and token URLs. If the Config has FetchPrimaryEmail,
+// private User emails are filled in from the /user/emails API. If the
+// Config has RequiredScopes, users who did not grant them fail with an
+// oauth2 ErrMissingScopes. It panics if the Config BaseURL or UploadURL is invalid.
+//
+// The callback outcome, with the Github User ID, is reported to any ctx
+// gologin Hooks.
+func CallbackHandlerWithConfig(config *oauth2.Config, githubConfig Config, success, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	success, failure = gologin.CallbackHooks(ProviderName, userID, success, failure)
+	if len(githubConfig.RequiredScopes) > 0 {
+		success = oauth2Login.RequiredScopesHandler(oauth2Login.ScopeConfig{Required: githubConfig.RequiredScopes, IgnoreCase: true}, success, failure)
+	}
+	success = githubHandler(config, githubConfig, success, failure)
+	return gologin.ProviderHandler(ProviderName, oauth2Login.CallbackHandler(config, success, failure, opts...))
+}
+
+// userID returns the ID of the Github User from the ctx, if any.
+func userID(ctx context.Context) string {
+	if user, err := UserFromContext(ctx); err == nil && user.ID != 0 {
+		return strconv.FormatInt(user.ID, 10)
+	}
+	return ""
+}
+
+// EnterpriseCallbackHandler handles GitHub Enterprise Server redirection URI
+// requests like CallbackHandler, but gets the Github User from the API at
+// apiBaseURL (e.g. "https://ghe.example.com/api/v3/").
+func EnterpriseCallbackHandler(config *oauth2.Config, apiBaseURL string, success, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	return CallbackHandlerWithConfig(config, Config{BaseURL: apiBaseURL}, success, failure, opts...)
 }
 
 // githubHandler is a http.Handler that gets the OAuth2 Token from the ctx to
-// get the corresponding Github User. If successful, the User is added to the
-// ctx and the success handler is called. Otherwise, the failure handler is
-// called.
-func githubHandler(config *oauth2.Config, success, failure http.Handler) http.Handler {
+// get the corresponding Github User. If successful, the User (and its raw
+// response body, see RawUserFromContext) is added to the ctx, along with the
+// X-OAuth-Scopes granted scopes, and the success handler is called.
+// Otherwise, the failure handler is called.
+func githubHandler(config *oauth2.Config, githubConfig Config, success, failure http.Handler) http.Handler {
 	if failure == nil {
 		failure = gologin.DefaultFailureHandler
 	}
+	githubConfig = githubConfig.mustNormalize().withTransport()
 	fn := func(w http.ResponseWriter, req *http.Request) {
 		ctx := req.Context()
 		token, err := oauth2Login.TokenFromContext(ctx)
@@ -57,29 +185,90 @@ func githubHandler(config *oauth2.Config, success, failure http.Handler) http.Ha
 			failure.ServeHTTP(w, req.WithContext(ctx))
 			return
 		}
-		httpClient := config.Client(ctx, token)
-		githubClient := github.NewClient(httpClient)
-		user, resp, err := githubClient.Users.Get(ctx, "")
-		err = validateResponse(user, resp, err)
+		user, raw, scopes, err := getUser(ctx, config, githubConfig, token)
 		if err != nil {
 			ctx = gologin.WithError(ctx, err)
 			failure.ServeHTTP(w, req.WithContext(ctx))
 			return
 		}
+		if raw != nil {
+			ctx = WithRawUser(ctx, raw)
+		}
+		if scopes != nil {
+			ctx = oauth2Login.WithGrantedScopes(ctx, scopes...)
+		}
 		ctx = WithUser(ctx, user)
 		success.ServeHTTP(w, req.WithContext(ctx))
 	}
 	return http.HandlerFunc(fn)
 }
 
+// getUser gets the Github User of the Token (with its primary email, if the
+// Config has FetchPrimaryEmail), its raw response body, and the Token's
+// scopes per the X-OAuth-Scopes header (nil if the header is absent, as for
+// GitHub App user tokens).
+func getUser(ctx context.Context, config *oauth2.Config, githubConfig Config, token *oauth2.Token) (*User, json.RawMessage, []string, error) {
+	httpClient, rawUser := internal.RecordBody(internal.OAuth2TransportClient(ctx, githubConfig.Transport, config, token), "/user")
+	githubClient := githubConfig.newClient(httpClient)
+	fetchCtx, endFetch := gologin.StartUserFetch(ctx, ProviderName)
+	user, resp, err := githubClient.CurrentUser(fetchCtx)
+	err = validateResponse(user, resp, err)
+	endFetch(err)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	var scopes []string
+	if values, ok := resp.Header["X-Oauth-Scopes"]; ok {
+		scopes = oauth2Login.ParseScopes(strings.Join(values, ","))
+	}
+	if githubConfig.FetchPrimaryEmail && user.Email == "" {
+		email, err := primaryEmail(ctx, githubClient)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if email != "" {
+			user.Email = email
+		}
+	}
+	return user, rawUser(), scopes, nil
+}
+
+// primaryEmail returns the primary verified email address of the authenticated
+// Github User, or an empty string if there is none or the Token lacks the
+// user:email scope (i.e. a 403 or 404 response).
+func primaryEmail(ctx context.Context, client *client) (string, error) {
+	emails, resp, err := client.Emails(ctx)
+	var status int
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	if status == http.StatusForbidden || status == http.StatusNotFound {
+		return "", nil
+	}
+	if err != nil || status != http.StatusOK {
+		return "", &gologin.Error{Provider: "github", Op: "get emails", StatusCode: status, Err: err, Kind: ErrUnableToGetGithubEmails}
+	}
+	for _, email := range emails {
+		if email.Primary && email.Verified {
+			return email.Email, nil
+		}
+	}
+	return "", nil
+}
+
 // validateResponse returns an error if the given Github user, raw
-// http.Response, or error are unexpected. Returns nil if they are valid.
-func validateResponse(user *github.User, resp *github.Response, err error) error {
-	if err != nil || resp.StatusCode != http.StatusOK {
-		return ErrUnableToGetGithubUser
+// http.Response, or error are unexpected. Returns nil if they are valid, or a
+// *gologin.Error which preserves the cause and status code.
+func validateResponse(user *User, resp *http.Response, err error) error {
+	var status int
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	if err != nil || status != http.StatusOK {
+		return &gologin.Error{Provider: "github", Op: "get user", StatusCode: status, Err: err, Kind: ErrUnableToGetGithubUser}
 	}
-	if user == nil || user.ID == nil {
-		return ErrUnableToGetGithubUser
+	if user == nil || user.ID == 0 {
+		return &gologin.Error{Provider: "github", Op: "get user", StatusCode: status, Kind: ErrUnableToGetGithubUser}
 	}
 	return nil
 }
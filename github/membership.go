@@ -0,0 +1,112 @@
+package github
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/internal"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"golang.org/x/oauth2"
+)
+
+const membershipStateActive = "active"
+
+// Github membership errors
+var (
+	ErrNotOrgMember          = errors.New("github: Github User is not a member of the organization")
+	ErrNotTeamMember         = errors.New("github: Github User is not a member of the team")
+	ErrMembershipPending     = errors.New("github: Github User membership invitation is pending")
+	ErrMissingReadOrgScope   = errors.New("github: membership lookup requires the read:org scope")
+	ErrUnableToGetMembership = errors.New("github: unable to get Github membership")
+)
+
+// MembershipHandler is a http.Handler that requires the Github User from the
+// ctx to be an active member of the org and, if team is non-empty, of the
+// team (by slug) within the org. It should be chained after CallbackHandler
+// (or CallbackHandlerWithConfig with the same Config) since the OAuth2 Token
+// and Github User are read from the ctx. If the memberships are active, the
+// Membership is added to the ctx and the success handler is called.
+// Otherwise, the failure handler is called.
+//
+// Non-members fail with ErrNotOrgMember or ErrNotTeamMember and unaccepted
+// invitations fail with ErrMembershipPending. The Token must have the read:org
+// scope; forbidden lookups fail with ErrMissingReadOrgScope. Other API errors
+// are reported as a *gologin.Error of kind ErrUnableToGetMembership.
+func MembershipHandler(config *oauth2.Config, githubConfig Config, org, team string, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	githubConfig = githubConfig.mustNormalize().withTransport()
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		user, err := UserFromContext(ctx)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		httpClient := internal.OAuth2TransportClient(ctx, githubConfig.Transport, config, token)
+		githubClient := githubConfig.newClient(httpClient)
+		membership, resp, err := githubClient.OrgMembership(ctx, org, user.Login)
+		err = validateMembership(membership, resp, err, ErrNotOrgMember)
+		if err == nil && team != "" {
+			membership, resp, err = githubClient.TeamMembership(ctx, org, team, user.Login)
+			err = validateMembership(membership, resp, err, ErrNotTeamMember)
+		}
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		ctx = WithMembership(ctx, membership)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// validateMembership returns an error if the given Github Membership, raw
+// http.Response, or error show the membership is missing or not active. Not
+// found memberships return notMember unless the Token's scopes (per the
+// X-OAuth-Scopes header) lack read:org.
+func validateMembership(membership *Membership, resp *http.Response, err error, notMember error) error {
+	var status int
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	switch {
+	case status == http.StatusForbidden:
+		return ErrMissingReadOrgScope
+	case status == http.StatusNotFound:
+		if scopes, ok := resp.Header["X-Oauth-Scopes"]; ok && !hasReadOrgScope(strings.Join(scopes, ",")) {
+			return ErrMissingReadOrgScope
+		}
+		return notMember
+	case err != nil || status != http.StatusOK || membership == nil:
+		return &gologin.Error{Provider: "github", Op: "get membership", StatusCode: status, Err: err, Kind: ErrUnableToGetMembership}
+	case membership.State == membershipStateActive:
+		return nil
+	case membership.State == "pending":
+		return ErrMembershipPending
+	}
+	return notMember
+}
+
+// hasReadOrgScope returns true if the comma separated OAuth2 scopes include
+// read:org or a scope which implies it.
+func hasReadOrgScope(scopes string) bool {
+	for _, scope := range strings.Split(scopes, ",") {
+		switch strings.TrimSpace(scope) {
+		case "read:org", "write:org", "admin:org":
+			return true
+		}
+	}
+	return false
+}
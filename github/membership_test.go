@@ -0,0 +1,127 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dghubble/gologin"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+// membershipResponse is a mock Github membership API response.
+type membershipResponse struct {
+	status int
+	scopes string
+	body   string
+}
+
+// newMembershipTestServer returns a new httptest.Server which mocks the
+// Github org and team membership endpoints of the user "alyssa" and a client
+// which proxies requests to the server. The caller must close the server.
+func newMembershipTestServer(org, team membershipResponse) (*http.Client, *httptest.Server) {
+	client, mux, server := testutils.TestServer()
+	handle := func(path string, resp membershipResponse) {
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			if resp.scopes != "" {
+				w.Header().Set("X-OAuth-Scopes", resp.scopes)
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(resp.status)
+			fmt.Fprintf(w, resp.body)
+		})
+	}
+	handle("/orgs/acme/memberships/alyssa", org)
+	handle("/orgs/acme/teams/platform/memberships/alyssa", team)
+	return client, server
+}
+
+func TestMembershipHandler(t *testing.T) {
+	active := membershipResponse{http.StatusOK, "read:org, user:email", `{"state": "active", "role": "member"}`}
+	admin := membershipResponse{http.StatusOK, "admin:org", `{"state": "active", "role": "admin"}`}
+	pending := membershipResponse{http.StatusOK, "read:org", `{"state": "pending", "role": "member"}`}
+	notFound := membershipResponse{http.StatusNotFound, "read:org", `{"message": "Not Found"}`}
+	noScope := membershipResponse{http.StatusNotFound, "user:email", `{"message": "Not Found"}`}
+	forbidden := membershipResponse{http.StatusForbidden, "", `{"message": "Must have admin rights"}`}
+	serverError := membershipResponse{http.StatusInternalServerError, "", `{"message": "Server Error"}`}
+	cases := []struct {
+		org  membershipResponse
+		team membershipResponse
+		slug string
+		role string
+		err  error
+	}{
+		{admin, notFound, "", "admin", nil},
+		{active, active, "platform", "member", nil},
+		{notFound, active, "", "", ErrNotOrgMember},
+		{active, notFound, "platform", "", ErrNotTeamMember},
+		{pending, active, "", "", ErrMembershipPending},
+		{active, pending, "platform", "", ErrMembershipPending},
+		{noScope, active, "", "", ErrMissingReadOrgScope},
+		{forbidden, active, "", "", ErrMissingReadOrgScope},
+		{serverError, active, "", "", ErrUnableToGetMembership},
+	}
+	config := &oauth2.Config{}
+	for _, c := range cases {
+		proxyClient, server := newMembershipTestServer(c.org, c.team)
+		// oauth2 Client will use the proxy client's base Transport
+		ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+		ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+		ctx = WithUser(ctx, &User{ID: 917408, Login: "alyssa"})
+		success := func(w http.ResponseWriter, req *http.Request) {
+			assert.Nil(t, c.err)
+			membership, err := MembershipFromContext(req.Context())
+			if assert.Nil(t, err) {
+				assert.Equal(t, c.role, membership.Role)
+			}
+			fmt.Fprintf(w, "success handler called")
+		}
+		failure := func(w http.ResponseWriter, req *http.Request) {
+			err := gologin.ErrorFromContext(req.Context())
+			assert.True(t, errors.Is(err, c.err), "expected %v, got %v", c.err, err)
+			fmt.Fprintf(w, "failure handler called")
+		}
+
+		// MembershipHandler assert that:
+		// - active org (and team) members call the success handler with the Membership
+		// - non-members, pending invitations, missing scopes, and API errors
+		// call the failure handler with distinct errors
+		handler := MembershipHandler(config, Config{}, "acme", c.slug, http.HandlerFunc(success), http.HandlerFunc(failure))
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		handler.ServeHTTP(w, req.WithContext(ctx))
+		if c.err == nil {
+			assert.Equal(t, "success handler called", w.Body.String())
+		} else {
+			assert.Equal(t, "failure handler called", w.Body.String())
+		}
+		server.Close()
+	}
+}
+
+func TestMembershipHandler_MissingCtxUser(t *testing.T) {
+	ctx := oauth2Login.WithToken(context.Background(), &oauth2.Token{AccessToken: "any-token"})
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "github: Context missing Github User", err.Error())
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// MembershipHandler without a ctx User, assert that:
+	// - failure handler is called
+	// - error about missing User is added to the ctx
+	handler := MembershipHandler(&oauth2.Config{}, Config{}, "acme", "", success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	handler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
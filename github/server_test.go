@@ -19,3 +19,21 @@ func newGithubTestServer(jsonData string) (*http.Client, *httptest.Server) {
 	})
 	return client, server
 }
+
+// newGithubEmailsTestServer returns a new httptest.Server which mocks the
+// Github user and user emails endpoints and a client which proxies requests to
+// the server. The emails endpoint responds with the given status code and json
+// data. The caller must close the server.
+func newGithubEmailsTestServer(userJSON string, emailsStatus int, emailsJSON string) (*http.Client, *httptest.Server) {
+	client, mux, server := testutils.TestServer()
+	mux.HandleFunc("/user", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, userJSON)
+	})
+	mux.HandleFunc("/user/emails", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(emailsStatus)
+		fmt.Fprintf(w, emailsJSON)
+	})
+	return client, server
+}
@@ -0,0 +1,93 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/internal"
+	"github.com/dghubble/gologin/internal/jsonclient"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"golang.org/x/oauth2"
+)
+
+const defaultAPIBaseURL = "https://api.github.com/"
+
+// Github token errors
+var (
+	ErrUnableToCheckToken = errors.New("github: unable to check access token")
+	ErrInvalidToken       = errors.New("github: access token is not valid for the app")
+)
+
+// checkTokenBody is the body of a check token request.
+type checkTokenBody struct {
+	AccessToken string `json:"access_token"`
+}
+
+// NewTokenVerifier returns an oauth2 TokenVerifier which checks that access
+// tokens were issued to the config OAuth App (with the ClientID and
+// ClientSecret) and gets their Github User from GET /user according to the
+// Config. Use it with oauth2 TokenHandler, which adds verified Users to the
+// ctx with WithUser. Panics if the Config BaseURL or UploadURL is invalid.
+func NewTokenVerifier(config *oauth2.Config, githubConfig Config) oauth2Login.UserContextVerifier {
+	return &tokenVerifier{config: config, githubConfig: githubConfig.mustNormalize().withTransport()}
+}
+
+// tokenVerifier verifies Github access tokens.
+type tokenVerifier struct {
+	config       *oauth2.Config
+	githubConfig Config
+}
+
+// VerifyToken checks the access token and returns its *User.
+func (v *tokenVerifier) VerifyToken(ctx context.Context, accessToken string) (interface{}, error) {
+	if err := checkToken(ctx, internal.TransportClient(ctx, v.githubConfig.Transport), v.config, v.githubConfig, accessToken); err != nil {
+		return nil, err
+	}
+	token := &oauth2.Token{AccessToken: accessToken, TokenType: "Bearer"}
+	user, _, _, err := getUser(ctx, v.config, v.githubConfig, token)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// WithUser adds the verified *User to the ctx.
+func (v *tokenVerifier) WithUser(ctx context.Context, user interface{}) context.Context {
+	if user, ok := user.(*User); ok {
+		return WithUser(ctx, user)
+	}
+	return ctx
+}
+
+// checkToken checks the access token was issued to the config OAuth App with
+// POST /applications/{client_id}/token, authenticated as the app. Github
+// responds 404 to tokens which are invalid or were issued to other apps.
+// https://docs.github.com/en/rest/apps/oauth-applications#check-a-token
+func checkToken(ctx context.Context, httpClient *http.Client, config *oauth2.Config, githubConfig Config, accessToken string) error {
+	baseURL := githubConfig.BaseURL
+	if baseURL == "" {
+		baseURL = defaultAPIBaseURL
+	}
+	path := "applications/" + url.PathEscape(config.ClientID) + "/token"
+	client := jsonclient.New(httpClient, baseURL).Set("Accept", "application/vnd.github+json")
+	req, err := client.NewRequest(ctx, "POST", path, nil, &checkTokenBody{AccessToken: accessToken})
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(config.ClientID, config.ClientSecret)
+	resp, err := client.Do(req, nil, nil)
+	var status int
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	if err == nil && (status == http.StatusNotFound || status == http.StatusUnprocessableEntity) {
+		return ErrInvalidToken
+	}
+	if err != nil || status != http.StatusOK {
+		return &gologin.Error{Provider: "github", Op: "check token", StatusCode: status, Err: err, Kind: ErrUnableToCheckToken}
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dghubble/gologin"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+// newGithubTokenServer returns a new httptest.Server which mocks the Github
+// check token and user endpoints and a client which proxies requests to the
+// server. The check token endpoint responds with the status. The caller must
+// close the server.
+func newGithubTokenServer(t *testing.T, checkStatus int) (*http.Client, *httptest.Server) {
+	client, mux, server := testutils.TestServer()
+	mux.HandleFunc("/applications/client_id/token", func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "POST", req.Method)
+		clientID, clientSecret, ok := req.BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "client_id", clientID)
+		assert.Equal(t, "client_secret", clientSecret)
+		var body checkTokenBody
+		assert.Nil(t, json.NewDecoder(req.Body).Decode(&body))
+		assert.Equal(t, "mobile-token", body.AccessToken)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(checkStatus)
+		fmt.Fprintf(w, `{}`)
+	})
+	mux.HandleFunc("/user", func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "Bearer mobile-token", req.Header.Get("Authorization"))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"id": 917408, "name": "Alyssa Hacker"}`)
+	})
+	return client, server
+}
+
+func TestTokenVerifier(t *testing.T) {
+	proxyClient, server := newGithubTokenServer(t, http.StatusOK)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+
+	config := &oauth2.Config{ClientID: "client_id", ClientSecret: "client_secret"}
+	success := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "mobile-token", token.AccessToken)
+		}
+		user, err := UserFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, int64(917408), user.ID)
+		}
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// oauth2 TokenHandler with a github TokenVerifier, assert that:
+	// - the access token is checked as the OAuth App
+	// - the Token and Github User are added to the ctx
+	handler := oauth2Login.TokenHandler(NewTokenVerifier(config, Config{}), http.HandlerFunc(success), failure)
+	req, _ := http.NewRequest("POST", "/", strings.NewReader(`{"access_token": "mobile-token"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestTokenVerifier_Errors(t *testing.T) {
+	cases := []struct {
+		status int
+		err    error
+	}{
+		{http.StatusNotFound, ErrInvalidToken},
+		{http.StatusUnprocessableEntity, ErrInvalidToken},
+		{http.StatusInternalServerError, ErrUnableToCheckToken},
+	}
+	config := &oauth2.Config{ClientID: "client_id", ClientSecret: "client_secret"}
+	verifier := NewTokenVerifier(config, Config{})
+	for _, c := range cases {
+		proxyClient, server := newGithubTokenServer(t, c.status)
+		ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+
+		// TokenVerifier with a rejected token, assert that:
+		// - the error describes the check token response
+		user, err := verifier.VerifyToken(ctx, "mobile-token")
+		assert.Nil(t, user)
+		assert.True(t, errors.Is(err, c.err), "%d", c.status)
+		var providerErr *gologin.Error
+		if errors.As(err, &providerErr) {
+			assert.Equal(t, c.status, providerErr.StatusCode)
+		}
+		server.Close()
+	}
+}
@@ -0,0 +1,112 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/dghubble/gologin/internal/jsonclient"
+)
+
+// User is a Github user.
+// https://docs.github.com/en/rest/users/users#get-the-authenticated-user
+type User struct {
+	ID              int64     `json:"id"`
+	NodeID          string    `json:"node_id"`
+	Login           string    `json:"login"`
+	Name            string    `json:"name"`
+	Email           string    `json:"email"`
+	AvatarURL       string    `json:"avatar_url"`
+	HTMLURL         string    `json:"html_url"`
+	Company         string    `json:"company"`
+	Blog            string    `json:"blog"`
+	Location        string    `json:"location"`
+	Bio             string    `json:"bio"`
+	TwitterUsername string    `json:"twitter_username"`
+	Type            string    `json:"type"`
+	SiteAdmin       bool      `json:"site_admin"`
+	PublicRepos     int       `json:"public_repos"`
+	PublicGists     int       `json:"public_gists"`
+	Followers       int       `json:"followers"`
+	Following       int       `json:"following"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// Membership is a Github User's organization or team membership.
+// https://docs.github.com/en/rest/orgs/members#get-organization-membership-for-a-user
+type Membership struct {
+	URL string `json:"url"`
+	// State is "active" or "pending"
+	State string `json:"state"`
+	// Role is "admin" or "member" for organizations and "maintainer" or
+	// "member" for teams
+	Role            string       `json:"role"`
+	OrganizationURL string       `json:"organization_url"`
+	Organization    Organization `json:"organization"`
+}
+
+// Organization is a Github organization.
+type Organization struct {
+	ID    int64  `json:"id"`
+	Login string `json:"login"`
+}
+
+// email is a Github User email address.
+type email struct {
+	Email    string `json:"email"`
+	Primary  bool   `json:"primary"`
+	Verified bool   `json:"verified"`
+}
+
+// client is a Github client for obtaining a User and its memberships.
+type client struct {
+	json *jsonclient.Client
+}
+
+// newClient returns a new Github client for the (normalized) Config.
+func (c Config) newClient(httpClient *http.Client) *client {
+	baseURL := c.BaseURL
+	if baseURL == "" {
+		baseURL = defaultAPIBaseURL
+	}
+	return &client{
+		json: jsonclient.New(httpClient, baseURL).Set("Accept", "application/vnd.github.v3+json"),
+	}
+}
+
+// CurrentUser gets the authenticated Github User.
+func (c *client) CurrentUser(ctx context.Context) (*User, *http.Response, error) {
+	user := new(User)
+	resp, err := c.json.Get(ctx, "user", nil, user, nil)
+	return user, resp, err
+}
+
+// Emails lists the email addresses of the authenticated Github User
+// (requires the user:email scope).
+// https://docs.github.com/en/rest/users/emails#list-email-addresses-for-the-authenticated-user
+func (c *client) Emails(ctx context.Context) ([]email, *http.Response, error) {
+	var emails []email
+	resp, err := c.json.Get(ctx, "user/emails", nil, &emails, nil)
+	return emails, resp, err
+}
+
+// OrgMembership gets the membership of the user in the org.
+func (c *client) OrgMembership(ctx context.Context, org, user string) (*Membership, *http.Response, error) {
+	path := fmt.Sprintf("orgs/%s/memberships/%s", url.PathEscape(org), url.PathEscape(user))
+	membership := new(Membership)
+	resp, err := c.json.Get(ctx, path, nil, membership, nil)
+	return membership, resp, err
+}
+
+// TeamMembership gets the membership of the user in the team of the org with
+// the given slug.
+// https://docs.github.com/en/rest/teams/members#get-team-membership-for-a-user
+func (c *client) TeamMembership(ctx context.Context, org, team, user string) (*Membership, *http.Response, error) {
+	path := fmt.Sprintf("orgs/%s/teams/%s/memberships/%s", url.PathEscape(org), url.PathEscape(team), url.PathEscape(user))
+	membership := new(Membership)
+	resp, err := c.json.Get(ctx, path, nil, membership, nil)
+	return membership, resp, err
+}
@@ -0,0 +1,161 @@
+package gitlab
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/internal"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"golang.org/x/oauth2"
+)
+
+// ProviderName is the provider name set in the ctx (see
+// gologin.ProviderFromContext) and the Profile.
+const ProviderName = "gitlab"
+
+const defaultBaseURL = "https://gitlab.com"
+
+// GitLab login errors
+var (
+	ErrUnableToGetGitLabUser = errors.New("gitlab: unable to get GitLab User")
+	ErrUserBlocked           = errors.New("gitlab: GitLab User is blocked")
+)
+
+// Endpoint is gitlab.com's OAuth2 endpoint.
+var Endpoint = NewEndpoint(defaultBaseURL)
+
+// NewEndpoint returns the OAuth2 endpoint of the GitLab instance at the
+// baseURL (e.g. "https://git.example.com" or "https://example.com/gitlab").
+// If the baseURL is empty, gitlab.com is used. Panics if the baseURL is not
+// an absolute URL.
+func NewEndpoint(baseURL string) oauth2.Endpoint {
+	baseURL = mustNormalizeBaseURL(baseURL)
+	return oauth2.Endpoint{
+		AuthURL:  baseURL + "/oauth/authorize",
+		TokenURL: baseURL + "/oauth/token",
+	}
+}
+
+// Config configures GitLab login.
+type Config struct {
+	// BaseURL is the URL of a self-hosted GitLab instance, including any path
+	// prefix (e.g. "https://example.com/gitlab"). If empty, gitlab.com is
+	// used. The oauth2 Config Endpoint should be NewEndpoint(BaseURL).
+	BaseURL string
+}
+
+// mustNormalizeBaseURL returns the baseURL (or the default) without a trailing
+// slash. It panics if the baseURL is invalid.
+func mustNormalizeBaseURL(baseURL string) string {
+	if baseURL == "" {
+		return defaultBaseURL
+	}
+	u, err := url.Parse(baseURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		panic("gitlab: invalid Config BaseURL " + baseURL)
+	}
+	return strings.TrimRight(u.String(), "/")
+}
+
+// StateHandler checks for a state cookie. If found, the state value is read
+// and added to the ctx. Otherwise, a non-guessable value is added to the ctx
+// and to a (short-lived) state cookie issued to the requester.
+//
+// Implements OAuth 2 RFC 6749 10.12 CSRF Protection. If you wish to issue
+// state params differently, write a http.Handler which sets the ctx state,
+// using oauth2 WithState(ctx, state) since it is required by LoginHandler
+// and CallbackHandler.
+func StateHandler(config gologin.CookieConfig, success http.Handler) http.Handler {
+	return oauth2Login.StateHandler(config, success)
+}
+
+// LoginHandler handles GitLab login requests by reading the state value from
+// the ctx and redirecting requests to the AuthURL with that state value.
+// Any AuthCodeOptions are added to the AuthURL.
+//
+// Scopes should include "read_user" or "openid" to get the GitLab User.
+func LoginHandler(config *oauth2.Config, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	return oauth2Login.LoginHandler(config, failure, opts...)
+}
+
+// CallbackHandler handles gitlab.com redirection URI requests and adds the
+// GitLab access token and User to the ctx. If authentication succeeds,
+// handling delegates to the success handler, otherwise to the failure
+// handler.
+// Any AuthCodeOptions are sent with the token exchange.
+func CallbackHandler(config *oauth2.Config, success, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	return CallbackHandlerWithConfig(config, Config{}, success, failure, opts...)
+}
+
+// CallbackHandlerWithConfig handles GitLab redirection URI requests like
+// CallbackHandler, but gets the User from the GitLab instance at the Config
+// BaseURL. Panics if the BaseURL is invalid.
+func CallbackHandlerWithConfig(config *oauth2.Config, gitlabConfig Config, success, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	success = gitlabHandler(config, gitlabConfig, success, failure)
+	return oauth2Login.CallbackHandler(config, success, failure, opts...)
+}
+
+// gitlabHandler is a http.Handler that gets the OAuth2 Token from the ctx to
+// get the corresponding GitLab User. If successful, the User is added to the
+// ctx and the success handler is called. Otherwise, the failure handler is
+// called.
+//
+// The User is read from /api/v4/user (the read_user scope). If the Token lacks
+// the scope (403 Forbidden), the User is read from the OpenID Connect userinfo
+// endpoint (the openid scope) instead. Blocked Users fail with ErrUserBlocked.
+func gitlabHandler(config *oauth2.Config, gitlabConfig Config, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	baseURL := mustNormalizeBaseURL(gitlabConfig.BaseURL)
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		httpClient := internal.OAuth2Client(ctx, config, token)
+		gitlabClient := newClient(httpClient, baseURL)
+		user, resp, err := gitlabClient.CurrentUser(ctx)
+		if resp != nil && resp.StatusCode == http.StatusForbidden {
+			user, resp, err = gitlabClient.UserInfo(ctx)
+		}
+		err = validateResponse(user, resp, err)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		if user.State == "blocked" {
+			ctx = gologin.WithError(ctx, ErrUserBlocked)
+			ctx = gologin.WithStatusCode(ctx, http.StatusForbidden)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		ctx = WithUser(ctx, user)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// validateResponse returns an error if the given GitLab User, raw
+// http.Response, or error are unexpected. Returns nil if they are valid, or a
+// *gologin.Error which preserves the cause and status code.
+func validateResponse(user *User, resp *http.Response, err error) error {
+	var status int
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	if err != nil || status != http.StatusOK {
+		return &gologin.Error{Provider: "gitlab", Op: "get user", StatusCode: status, Err: err, Kind: ErrUnableToGetGitLabUser}
+	}
+	if user == nil || user.ID == 0 {
+		return &gologin.Error{Provider: "gitlab", Op: "get user", StatusCode: status, Kind: ErrUnableToGetGitLabUser}
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+package gitlab
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dghubble/gologin"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+const (
+	testUserJSON        = `{"id": 917324, "username": "sparkle", "name": "Sparkle Pony", "email": "sparkle@example.com", "avatar_url": "https://example.com/gitlab/uploads/avatar.png", "web_url": "https://example.com/gitlab/sparkle", "state": "active"}`
+	testBlockedUserJSON = `{"id": 917324, "username": "sparkle", "name": "Sparkle Pony", "state": "blocked"}`
+	testScopeErrorJSON  = `{"error": "insufficient_scope", "error_description": "The request requires higher privileges than provided by the access token.", "scope": "read_user"}`
+	testUserInfoJSON    = `{"sub": "917324", "nickname": "sparkle", "preferred_username": "sparkle", "name": "Sparkle Pony", "email": "sparkle@example.com", "email_verified": true, "profile": "https://example.com/gitlab/sparkle", "picture": "https://example.com/gitlab/uploads/avatar.png"}`
+)
+
+func testConfig() *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     "client_id",
+		ClientSecret: "client_secret",
+		RedirectURL:  "https://example.com/gitlab/callback",
+		Endpoint:     NewEndpoint(testBaseURL),
+		Scopes:       []string{"read_user"},
+	}
+}
+
+func TestNewEndpoint(t *testing.T) {
+	cases := []struct {
+		baseURL  string
+		authURL  string
+		tokenURL string
+	}{
+		{"", "https://gitlab.com/oauth/authorize", "https://gitlab.com/oauth/token"},
+		{"https://git.example.com", "https://git.example.com/oauth/authorize", "https://git.example.com/oauth/token"},
+		{"https://example.com/gitlab", "https://example.com/gitlab/oauth/authorize", "https://example.com/gitlab/oauth/token"},
+		{"https://example.com/gitlab/", "https://example.com/gitlab/oauth/authorize", "https://example.com/gitlab/oauth/token"},
+	}
+	for _, c := range cases {
+		endpoint := NewEndpoint(c.baseURL)
+		assert.Equal(t, c.authURL, endpoint.AuthURL)
+		assert.Equal(t, c.tokenURL, endpoint.TokenURL)
+	}
+	assert.Equal(t, NewEndpoint(""), Endpoint)
+	assert.Panics(t, func() { NewEndpoint("example.com/gitlab") })
+	assert.Panics(t, func() { CallbackHandlerWithConfig(testConfig(), Config{BaseURL: "/gitlab"}, nil, nil) })
+}
+
+func TestCallbackHandler(t *testing.T) {
+	proxyClient, server := newGitlabTestServer(http.StatusOK, testUserJSON, `{}`)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithState(ctx, "d4e5f6")
+
+	success := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "any-token", token.AccessToken)
+		}
+		user, err := UserFromContext(ctx)
+		if assert.Nil(t, err) {
+			expectedUser := &User{ID: 917324, Username: "sparkle", Name: "Sparkle Pony", Email: "sparkle@example.com", AvatarURL: "https://example.com/gitlab/uploads/avatar.png", WebURL: "https://example.com/gitlab/sparkle", State: "active"}
+			assert.Equal(t, expectedUser, user)
+		}
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// CallbackHandlerWithConfig for an instance with a path prefix, assert that:
+	// - the Token is obtained from the prefixed /oauth/token
+	// - the GitLab User is obtained from the prefixed /api/v4/user
+	// - success handler is called with the Token and User in the ctx
+	callbackHandler := CallbackHandlerWithConfig(testConfig(), Config{BaseURL: testBaseURL}, http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestGitlabHandler_OpenIDScope(t *testing.T) {
+	proxyClient, server := newGitlabTestServer(http.StatusForbidden, testScopeErrorJSON, testUserInfoJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+
+	success := func(w http.ResponseWriter, req *http.Request) {
+		user, err := UserFromContext(req.Context())
+		if assert.Nil(t, err) {
+			expectedUser := &User{ID: 917324, Username: "sparkle", Name: "Sparkle Pony", Email: "sparkle@example.com", AvatarURL: "https://example.com/gitlab/uploads/avatar.png", WebURL: "https://example.com/gitlab/sparkle"}
+			assert.Equal(t, expectedUser, user)
+		}
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// GitlabHandler with an openid (not read_user) Token, assert that:
+	// - the GitLab User is obtained from the OpenID Connect userinfo endpoint
+	// - success handler is called with the User in the ctx
+	gitlabHandler := gitlabHandler(testConfig(), Config{BaseURL: testBaseURL}, http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	gitlabHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestGitlabHandler_BlockedUser(t *testing.T) {
+	proxyClient, server := newGitlabTestServer(http.StatusOK, testBlockedUserJSON, `{}`)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, ErrUserBlocked, gologin.ErrorFromContext(req.Context()))
+		assert.Equal(t, http.StatusForbidden, gologin.StatusCodeFromContext(req.Context()))
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// GitlabHandler for a blocked User, assert that:
+	// - failure handler is called
+	// - error GitLab User is blocked added to the failure handler ctx
+	// - the failure status code is 403 Forbidden
+	gitlabHandler := gitlabHandler(testConfig(), Config{BaseURL: testBaseURL}, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	gitlabHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestGitlabHandler_MissingCtxToken(t *testing.T) {
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "oauth2: Context missing Token", err.Error())
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// GitlabHandler called without Token in ctx, assert that:
+	// - failure handler is called
+	// - error about ctx missing token is added to the failure handler ctx
+	gitlabHandler := gitlabHandler(testConfig(), Config{}, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	gitlabHandler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestGitlabHandler_ErrorGettingUser(t *testing.T) {
+	proxyClient, server := testutils.NewErrorServer("GitLab Service Down", http.StatusInternalServerError)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.True(t, errors.Is(err, ErrUnableToGetGitLabUser))
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// GitlabHandler cannot get GitLab User, assert that:
+	// - failure handler is called
+	// - error cannot get GitLab User added to the failure handler ctx
+	gitlabHandler := gitlabHandler(testConfig(), Config{}, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	gitlabHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestValidateResponse(t *testing.T) {
+	validUser := &User{ID: 917324}
+	validResponse := &http.Response{StatusCode: 200}
+	invalidResponse := &http.Response{StatusCode: 500}
+	assert.Equal(t, nil, validateResponse(validUser, validResponse, nil))
+	assert.True(t, errors.Is(validateResponse(validUser, validResponse, fmt.Errorf("Server error")), ErrUnableToGetGitLabUser))
+	assert.True(t, errors.Is(validateResponse(validUser, invalidResponse, nil), ErrUnableToGetGitLabUser))
+	assert.True(t, errors.Is(validateResponse(&User{}, validResponse, nil), ErrUnableToGetGitLabUser))
+	assert.True(t, errors.Is(validateResponse(nil, validResponse, nil), ErrUnableToGetGitLabUser))
+}
@@ -0,0 +1,35 @@
+package gitlab
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/dghubble/gologin/testutils"
+)
+
+// testBaseURL is a self-hosted GitLab instance with a path prefix.
+const testBaseURL = "https://example.com/gitlab/"
+
+// newGitlabTestServer returns a new httptest.Server which mocks the GitLab
+// token and API endpoints of an instance mounted at /gitlab/ and a client
+// which proxies requests to the server. The /api/v4/user endpoint responds
+// with the given status and user json data and the OpenID Connect userinfo
+// endpoint with the userinfo json data. The caller must close the server.
+func newGitlabTestServer(status int, userJSON, userInfoJSON string) (*http.Client, *httptest.Server) {
+	client, mux, server := testutils.TestServer()
+	mux.HandleFunc("/gitlab/oauth/token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token": "any-token", "token_type": "Bearer", "expires_in": 7200, "refresh_token": "any-refresh", "scope": "read_user"}`)
+	})
+	mux.HandleFunc("/gitlab/api/v4/user", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		fmt.Fprintf(w, userJSON)
+	})
+	mux.HandleFunc("/gitlab/oauth/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, userInfoJSON)
+	})
+	return client, server
+}
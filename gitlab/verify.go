@@ -0,0 +1,70 @@
+package gitlab
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/dghubble/gologin/internal/jsonclient"
+)
+
+// User is a GitLab user.
+type User struct {
+	ID        int64  `json:"id"`
+	Username  string `json:"username"`
+	Name      string `json:"name"`
+	Email     string `json:"email"`
+	AvatarURL string `json:"avatar_url"`
+	WebURL    string `json:"web_url"`
+	// State is "active" or "blocked" (empty for openid-only Tokens)
+	State string `json:"state"`
+}
+
+// userInfo is a GitLab OpenID Connect userinfo response.
+type userInfo struct {
+	Sub      string `json:"sub"`
+	Nickname string `json:"nickname"`
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	Picture  string `json:"picture"`
+	Profile  string `json:"profile"`
+}
+
+// client is a GitLab client for obtaining a User.
+type client struct {
+	json *jsonclient.Client
+}
+
+// newClient returns a new GitLab client for the instance at the (normalized)
+// baseURL.
+func newClient(httpClient *http.Client, baseURL string) *client {
+	return &client{
+		json: jsonclient.New(httpClient, baseURL+"/"),
+	}
+}
+
+// CurrentUser gets the current GitLab User (requires the read_user scope).
+// https://docs.gitlab.com/ee/api/users.html#for-normal-users-1
+func (c *client) CurrentUser(ctx context.Context) (*User, *http.Response, error) {
+	user := new(User)
+	resp, err := c.json.Get(ctx, "api/v4/user", nil, user, nil)
+	return user, resp, err
+}
+
+// UserInfo gets the current GitLab User from the OpenID Connect userinfo
+// endpoint (requires the openid scope). The User State is unknown.
+// https://docs.gitlab.com/ee/integration/openid_connect_provider.html
+func (c *client) UserInfo(ctx context.Context) (*User, *http.Response, error) {
+	info := new(userInfo)
+	resp, err := c.json.Get(ctx, "oauth/userinfo", nil, info, nil)
+	id, _ := strconv.ParseInt(info.Sub, 10, 64)
+	user := &User{
+		ID:        id,
+		Username:  info.Nickname,
+		Name:      info.Name,
+		Email:     info.Email,
+		AvatarURL: info.Picture,
+		WebURL:    info.Profile,
+	}
+	return user, resp, err
+}
@@ -0,0 +1,80 @@
+package gologintest
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// FakeClock is a gologin Clock (and Sleeper) whose time only changes when
+// advanced. Add it to the ctx with gologin.ClockHandler or gologin.WithClock
+// to issue, expire, and retry without waiting. It is safe for concurrent use.
+type FakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	slept  time.Duration
+	sleeps int
+}
+
+// NewFakeClock returns a FakeClock set to the time.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now returns the FakeClock time.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance moves the FakeClock time forward by the duration.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// Sleep advances the FakeClock by the duration and returns immediately, or
+// returns the ctx error if it is done.
+func (c *FakeClock) Sleep(ctx context.Context, d time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	c.slept += d
+	c.sleeps++
+	return nil
+}
+
+// Slept returns the number of Sleep calls and their total duration.
+func (c *FakeClock) Slept() (int, time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.sleeps, c.slept
+}
+
+// NewEntropy returns a deterministic source of random bytes for the seed.
+// Add it to the ctx with gologin.EntropyHandler or gologin.WithEntropy so
+// tests see the same states and verifiers on every run. Never use it outside
+// of tests.
+func NewEntropy(seed int64) io.Reader {
+	return &lockedReader{reader: rand.New(rand.NewSource(seed))}
+}
+
+// lockedReader serializes reads, since math/rand sources are not safe for
+// concurrent use.
+type lockedReader struct {
+	mu     sync.Mutex
+	reader io.Reader
+}
+
+func (r *lockedReader) Read(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.reader.Read(p)
+}
@@ -0,0 +1,47 @@
+package gologintest
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/dghubble/gologin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Unix(1500000000, 0)
+	clock := NewFakeClock(start)
+	var _ gologin.Sleeper = clock
+
+	// FakeClock, assert that:
+	// - the time only changes when advanced or slept
+	// - Sleep returns immediately and records the sleeps
+	assert.Equal(t, start, clock.Now())
+	clock.Advance(time.Minute)
+	assert.Equal(t, start.Add(time.Minute), clock.Now())
+	assert.Nil(t, clock.Sleep(context.Background(), time.Hour))
+	assert.Equal(t, start.Add(time.Hour+time.Minute), clock.Now())
+	sleeps, slept := clock.Slept()
+	assert.Equal(t, 1, sleeps)
+	assert.Equal(t, time.Hour, slept)
+
+	// sleeping with a done ctx returns its error without advancing
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Equal(t, context.Canceled, clock.Sleep(ctx, time.Hour))
+	assert.Equal(t, start.Add(time.Hour+time.Minute), clock.Now())
+}
+
+func TestNewEntropy(t *testing.T) {
+	read := func(entropy io.Reader) []byte {
+		b := make([]byte, 32)
+		_, err := io.ReadFull(entropy, b)
+		assert.Nil(t, err)
+		return b
+	}
+	// the same seed reads the same bytes
+	assert.Equal(t, read(NewEntropy(1)), read(NewEntropy(1)))
+	assert.NotEqual(t, read(NewEntropy(1)), read(NewEntropy(2)))
+}
@@ -0,0 +1,3 @@
+// Package gologintest provides fake OAuth2 and OAuth1 providers for testing
+// apps which use gologin handlers end-to-end.
+package gologintest
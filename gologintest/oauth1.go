@@ -0,0 +1,207 @@
+package gologintest
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dghubble/oauth1"
+)
+
+// DefaultOAuth1UserInfoPath is the default user info path of a
+// FakeOAuth1Provider.
+const DefaultOAuth1UserInfoPath = "/userinfo"
+
+// FakeOAuth1Provider is an httptest.Server which implements an OAuth1
+// provider's request token, authorize, access token, and user info endpoints.
+//
+// The authorize endpoint auto-approves request tokens and redirects to their
+// oauth_callback with a verifier. The access token endpoint exchanges
+// verified request tokens for the AccessToken and AccessSecret. The user info
+// endpoint responds with the UserInfoJSON to requests with the AccessToken.
+// Request signatures are not verified.
+//
+// Set fields before requests are made.
+type FakeOAuth1Provider struct {
+	*httptest.Server
+	// AccessToken and AccessSecret are the issued access token credentials.
+	AccessToken  string
+	AccessSecret string
+	// UserInfoJSON is the user info response body.
+	UserInfoJSON string
+	// Denied makes the authorize endpoint redirect with a "denied" parameter
+	// instead of a verifier.
+	Denied bool
+	// Delay delays access token and user info responses.
+	Delay time.Duration
+	// AccessTokenStatus and UserInfoStatus are statuses (e.g. 500) which the
+	// access token and user info endpoints respond with instead of
+	// succeeding.
+	AccessTokenStatus int
+	UserInfoStatus    int
+
+	userInfoPath string
+	mu           sync.Mutex
+	requests     map[string]*requestToken
+}
+
+// requestToken is an issued request token.
+type requestToken struct {
+	secret   string
+	callback string
+	verifier string
+}
+
+// NewFakeOAuth1Provider returns a new started FakeOAuth1Provider with its
+// user info endpoint at DefaultOAuth1UserInfoPath. The caller must Close it.
+func NewFakeOAuth1Provider() *FakeOAuth1Provider {
+	return NewFakeOAuth1ProviderWithUserInfoPath(DefaultOAuth1UserInfoPath)
+}
+
+// NewFakeOAuth1ProviderWithUserInfoPath returns a new started
+// FakeOAuth1Provider with its user info endpoint at the path (e.g.
+// "/1.1/account/verify_credentials.json", see Client). The caller must Close
+// it.
+func NewFakeOAuth1ProviderWithUserInfoPath(path string) *FakeOAuth1Provider {
+	p := &FakeOAuth1Provider{
+		AccessToken:  "fake-access-token",
+		AccessSecret: "fake-access-secret",
+		UserInfoJSON: `{}`,
+		userInfoPath: path,
+		requests:     make(map[string]*requestToken),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/request_token", p.requestToken)
+	mux.HandleFunc("/authorize", p.authorize)
+	mux.HandleFunc("/access_token", p.accessToken)
+	mux.HandleFunc(path, p.userInfo)
+	p.Server = httptest.NewServer(mux)
+	return p
+}
+
+// Endpoint returns the OAuth1 Endpoint of the provider.
+func (p *FakeOAuth1Provider) Endpoint() oauth1.Endpoint {
+	return oauth1.Endpoint{
+		RequestTokenURL: p.URL + "/request_token",
+		AuthorizeURL:    p.URL + "/authorize",
+		AccessTokenURL:  p.URL + "/access_token",
+	}
+}
+
+// UserInfoURL returns the URL of the user info endpoint.
+func (p *FakeOAuth1Provider) UserInfoURL() string {
+	return p.URL + p.userInfoPath
+}
+
+// Client returns an http.Client which sends all requests (e.g. to a provider
+// package's fixed https API host) to the provider. Add it to the ctx with
+// gologin WithHTTPClient.
+func (p *FakeOAuth1Provider) Client() *http.Client {
+	return proxyClient(p.Server)
+}
+
+// requestToken issues a request token for the oauth_callback.
+func (p *FakeOAuth1Provider) requestToken(w http.ResponseWriter, req *http.Request) {
+	callback := authParams(req).Get("oauth_callback")
+	if req.Method != "POST" || callback == "" {
+		http.Error(w, "missing oauth_callback", http.StatusBadRequest)
+		return
+	}
+	token, secret := randomValue(), randomValue()
+	p.mu.Lock()
+	p.requests[token] = &requestToken{secret: secret, callback: callback}
+	p.mu.Unlock()
+	w.Header().Set("Content-Type", "application/x-www-form-urlencoded")
+	fmt.Fprint(w, url.Values{"oauth_token": {token}, "oauth_token_secret": {secret}, "oauth_callback_confirmed": {"true"}}.Encode())
+}
+
+// authorize auto-approves a request token.
+func (p *FakeOAuth1Provider) authorize(w http.ResponseWriter, req *http.Request) {
+	token := req.URL.Query().Get("oauth_token")
+	p.mu.Lock()
+	request, ok := p.requests[token]
+	if ok && !p.Denied {
+		request.verifier = randomValue()
+	}
+	p.mu.Unlock()
+	if !ok {
+		http.Error(w, "invalid oauth_token", http.StatusBadRequest)
+		return
+	}
+	callbackURL, err := url.Parse(request.callback)
+	if err != nil {
+		http.Error(w, "invalid oauth_callback", http.StatusBadRequest)
+		return
+	}
+	params := callbackURL.Query()
+	if p.Denied {
+		params.Set("denied", token)
+	} else {
+		params.Set("oauth_token", token)
+		params.Set("oauth_verifier", request.verifier)
+	}
+	callbackURL.RawQuery = params.Encode()
+	http.Redirect(w, req, callbackURL.String(), http.StatusFound)
+}
+
+// accessToken exchanges a verified request token for the access token.
+func (p *FakeOAuth1Provider) accessToken(w http.ResponseWriter, req *http.Request) {
+	time.Sleep(p.Delay)
+	if p.AccessTokenStatus != 0 {
+		http.Error(w, http.StatusText(p.AccessTokenStatus), p.AccessTokenStatus)
+		return
+	}
+	params := authParams(req)
+	token := params.Get("oauth_token")
+	p.mu.Lock()
+	request, ok := p.requests[token]
+	valid := ok && request.verifier != "" && request.verifier == params.Get("oauth_verifier")
+	if valid {
+		delete(p.requests, token)
+	}
+	p.mu.Unlock()
+	if req.Method != "POST" || !valid {
+		http.Error(w, "invalid oauth_token or oauth_verifier", http.StatusUnauthorized)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-www-form-urlencoded")
+	fmt.Fprint(w, url.Values{"oauth_token": {p.AccessToken}, "oauth_token_secret": {p.AccessSecret}}.Encode())
+}
+
+// userInfo responds with the UserInfoJSON to requests with the access token.
+func (p *FakeOAuth1Provider) userInfo(w http.ResponseWriter, req *http.Request) {
+	time.Sleep(p.Delay)
+	if p.UserInfoStatus != 0 {
+		http.Error(w, http.StatusText(p.UserInfoStatus), p.UserInfoStatus)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if authParams(req).Get("oauth_token") != p.AccessToken {
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{"errors": [{"code": 89, "message": "Invalid or expired token."}]}`)
+		return
+	}
+	fmt.Fprint(w, p.UserInfoJSON)
+}
+
+// authParams returns the parameters of an OAuth1 Authorization header.
+func authParams(req *http.Request) url.Values {
+	params := url.Values{}
+	header := strings.TrimPrefix(req.Header.Get("Authorization"), "OAuth ")
+	for _, pair := range strings.Split(header, ",") {
+		parts := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		value, err := url.QueryUnescape(strings.Trim(parts[1], `"`))
+		if err != nil {
+			continue
+		}
+		params.Set(parts[0], value)
+	}
+	return params
+}
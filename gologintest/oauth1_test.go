@@ -0,0 +1,110 @@
+package gologintest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/dghubble/gologin"
+	oauth1Login "github.com/dghubble/gologin/oauth1"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/dghubble/gologin/tumblr"
+	"github.com/dghubble/oauth1"
+	"github.com/stretchr/testify/assert"
+)
+
+var testTempConfig = gologin.CookieConfig{
+	Name:   "tumblr-temp",
+	Path:   "/",
+	MaxAge: 60,
+}
+
+// runTumblrLogin drives the tumblr login and callback chains through the
+// provider's authorize endpoint and returns the callback response.
+func runTumblrLogin(t *testing.T, provider *FakeOAuth1Provider, success, failure http.Handler) *httptest.ResponseRecorder {
+	config := &oauth1.Config{
+		ConsumerKey:    "consumer_key",
+		ConsumerSecret: "consumer_secret",
+		CallbackURL:    "https://example.com/tumblr/callback",
+		Endpoint:       provider.Endpoint(),
+	}
+	loginHandler := tumblr.LoginHandler(config, testTempConfig, nil)
+	callbackHandler := tumblr.CallbackHandler(config, testTempConfig, success, failure)
+
+	// login obtains a request token and redirects to the provider
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/tumblr/login", nil)
+	loginHandler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusFound, w.Code)
+	cookies := (&http.Response{Header: w.Header()}).Cookies()
+
+	// the provider auto-approves and redirects to the callback
+	noRedirects := &http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := noRedirects.Get(w.HeaderMap.Get("Location"))
+	if !assert.Nil(t, err) {
+		return w
+	}
+	resp.Body.Close()
+	callbackURL, err := url.Parse(resp.Header.Get("Location"))
+	assert.Nil(t, err)
+
+	// Tumblr API requests are sent to the provider
+	ctx := context.WithValue(context.Background(), oauth1.HTTPClient, provider.Client())
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", callbackURL.RequestURI(), nil)
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	return w
+}
+
+func TestFakeOAuth1Provider_Tumblr(t *testing.T) {
+	provider := NewFakeOAuth1ProviderWithUserInfoPath("/v2/user/info")
+	defer provider.Close()
+	provider.UserInfoJSON = `{"meta": {"status": 200, "msg": "OK"}, "response": {"user": {"name": "gopher", "blogs": [{"name": "gopher", "primary": true}]}}}`
+
+	success := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		accessToken, accessSecret, err := oauth1Login.AccessTokenFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "fake-access-token", accessToken)
+			assert.Equal(t, "fake-access-secret", accessSecret)
+		}
+		user, err := tumblr.UserFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "gopher", user.Name)
+		}
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// FakeOAuth1Provider with the tumblr chains, assert that:
+	// - the request token is authorized with a verifier
+	// - the verified request token is exchanged for the access token
+	// - the Tumblr User is read from the user info endpoint
+	w := runTumblrLogin(t, provider, http.HandlerFunc(success), failure)
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestFakeOAuth1Provider_Denied(t *testing.T) {
+	provider := NewFakeOAuth1Provider()
+	defer provider.Close()
+	provider.Denied = true
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, oauth1Login.ErrAccessDenied, gologin.ErrorFromContext(req.Context()))
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// FakeOAuth1Provider with Denied, assert that:
+	// - the callback fails with ErrAccessDenied
+	w := runTumblrLogin(t, provider, success, http.HandlerFunc(failure))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
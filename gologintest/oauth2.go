@@ -0,0 +1,195 @@
+package gologintest
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+const (
+	// DefaultTokenJSON is the default token response of a
+	// FakeOAuth2Provider.
+	DefaultTokenJSON = `{"access_token": "fake-access-token", "token_type": "Bearer", "expires_in": 3600, "refresh_token": "fake-refresh-token"}`
+	// DefaultUserInfoPath is the default user info path of a
+	// FakeOAuth2Provider.
+	DefaultUserInfoPath = "/userinfo"
+)
+
+// FakeOAuth2Provider is an httptest.Server which implements an OAuth2
+// provider's authorize, token, and user info endpoints.
+//
+// The authorize endpoint auto-approves requests and redirects to the
+// redirect_uri with a single use code and the state. The token endpoint
+// exchanges codes for the TokenJSON. The user info endpoint responds with the
+// UserInfoJSON to requests with the TokenJSON access_token.
+//
+// Set fields before requests are made.
+type FakeOAuth2Provider struct {
+	*httptest.Server
+	// TokenJSON is the token response. Defaults to DefaultTokenJSON.
+	TokenJSON string
+	// UserInfoJSON is the user info response body.
+	UserInfoJSON string
+	// AuthorizeError is an error the authorize endpoint redirects with
+	// instead of a code (e.g. "access_denied").
+	AuthorizeError string
+	// Delay delays token and user info responses (e.g. to test timeouts).
+	Delay time.Duration
+	// TokenStatus and UserInfoStatus are statuses (e.g. 500) which the token
+	// and user info endpoints respond with instead of succeeding.
+	TokenStatus    int
+	UserInfoStatus int
+
+	userInfoPath string
+	mu           sync.Mutex
+	codes        map[string]bool
+}
+
+// NewFakeOAuth2Provider returns a new started FakeOAuth2Provider with its
+// user info endpoint at DefaultUserInfoPath. The caller must Close it.
+func NewFakeOAuth2Provider() *FakeOAuth2Provider {
+	return NewFakeOAuth2ProviderWithUserInfoPath(DefaultUserInfoPath)
+}
+
+// NewFakeOAuth2ProviderWithUserInfoPath returns a new started
+// FakeOAuth2Provider with its user info endpoint at the path (e.g. "/v2.9/me"
+// for a provider package with a fixed user endpoint, see Client). The caller
+// must Close it.
+func NewFakeOAuth2ProviderWithUserInfoPath(path string) *FakeOAuth2Provider {
+	p := &FakeOAuth2Provider{
+		TokenJSON:    DefaultTokenJSON,
+		UserInfoJSON: `{}`,
+		userInfoPath: path,
+		codes:        make(map[string]bool),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/authorize", p.authorize)
+	mux.HandleFunc("/token", p.token)
+	mux.HandleFunc(path, p.userInfo)
+	p.Server = httptest.NewServer(mux)
+	return p
+}
+
+// Endpoint returns the OAuth2 Endpoint of the provider.
+func (p *FakeOAuth2Provider) Endpoint() oauth2.Endpoint {
+	return oauth2.Endpoint{
+		AuthURL:   p.URL + "/authorize",
+		TokenURL:  p.URL + "/token",
+		AuthStyle: oauth2.AuthStyleInParams,
+	}
+}
+
+// UserInfoURL returns the URL of the user info endpoint.
+func (p *FakeOAuth2Provider) UserInfoURL() string {
+	return p.URL + p.userInfoPath
+}
+
+// Client returns an http.Client which sends all requests (e.g. to a provider
+// package's fixed https API host) to the provider. Add it to the ctx with
+// gologin WithHTTPClient.
+func (p *FakeOAuth2Provider) Client() *http.Client {
+	return proxyClient(p.Server)
+}
+
+// authorize auto-approves authorization requests.
+func (p *FakeOAuth2Provider) authorize(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	redirectURL, err := url.Parse(query.Get("redirect_uri"))
+	if err != nil || redirectURL.String() == "" {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	params := redirectURL.Query()
+	if p.AuthorizeError != "" {
+		params.Set("error", p.AuthorizeError)
+	} else {
+		code := randomValue()
+		p.mu.Lock()
+		p.codes[code] = true
+		p.mu.Unlock()
+		params.Set("code", code)
+	}
+	if state := query.Get("state"); state != "" {
+		params.Set("state", state)
+	}
+	redirectURL.RawQuery = params.Encode()
+	http.Redirect(w, req, redirectURL.String(), http.StatusFound)
+}
+
+// token exchanges a valid code for the TokenJSON.
+func (p *FakeOAuth2Provider) token(w http.ResponseWriter, req *http.Request) {
+	time.Sleep(p.Delay)
+	if p.TokenStatus != 0 {
+		http.Error(w, http.StatusText(p.TokenStatus), p.TokenStatus)
+		return
+	}
+	code := req.PostFormValue("code")
+	p.mu.Lock()
+	valid := p.codes[code]
+	delete(p.codes, code)
+	p.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	if req.Method != "POST" || !valid {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"error": "invalid_grant"}`)
+		return
+	}
+	fmt.Fprint(w, p.TokenJSON)
+}
+
+// userInfo responds with the UserInfoJSON to requests with the access token.
+func (p *FakeOAuth2Provider) userInfo(w http.ResponseWriter, req *http.Request) {
+	time.Sleep(p.Delay)
+	if p.UserInfoStatus != 0 {
+		http.Error(w, http.StatusText(p.UserInfoStatus), p.UserInfoStatus)
+		return
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	json.Unmarshal([]byte(p.TokenJSON), &token)
+	w.Header().Set("Content-Type", "application/json")
+	if req.Header.Get("Authorization") != "Bearer "+token.AccessToken {
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{"error": "invalid_token"}`)
+		return
+	}
+	fmt.Fprint(w, p.UserInfoJSON)
+}
+
+// proxyClient returns an http.Client which sends all requests to the server
+// over http.
+func proxyClient(server *httptest.Server) *http.Client {
+	serverURL, _ := url.Parse(server.URL)
+	transport := &http.Transport{
+		Proxy: http.ProxyURL(serverURL),
+	}
+	return &http.Client{Transport: &rewriteTransport{transport}}
+}
+
+// rewriteTransport rewrites https requests to http so they can be proxied to
+// an httptest.Server.
+type rewriteTransport struct {
+	base http.RoundTripper
+}
+
+func (t *rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme = "http"
+	return t.base.RoundTrip(req)
+}
+
+// randomValue returns a random base64 value.
+func randomValue() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
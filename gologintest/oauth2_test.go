@@ -0,0 +1,158 @@
+package gologintest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/facebook"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+var testStateConfig = gologin.CookieConfig{
+	Name:   "facebook-state",
+	Path:   "/",
+	MaxAge: 60,
+}
+
+// newFacebookProvider returns a FakeOAuth2Provider of the Facebook Graph
+// API v2.9 me endpoint and an oauth2 Config which uses it.
+func newFacebookProvider() (*FakeOAuth2Provider, *oauth2.Config) {
+	provider := NewFakeOAuth2ProviderWithUserInfoPath("/v2.9/me")
+	provider.UserInfoJSON = `{"id": "54638001", "name": "Ivy Crimson", "email": "ivy@harvard.edu"}`
+	config := &oauth2.Config{
+		ClientID:     "client_id",
+		ClientSecret: "client_secret",
+		RedirectURL:  "https://example.com/facebook/callback",
+		Endpoint:     provider.Endpoint(),
+	}
+	return provider, config
+}
+
+// runFacebookLogin drives the facebook login and callback chains through
+// the provider's authorize endpoint and returns the callback response.
+func runFacebookLogin(t *testing.T, ctx context.Context, provider *FakeOAuth2Provider, config *oauth2.Config, success, failure http.Handler) *httptest.ResponseRecorder {
+	loginHandler := facebook.StateHandler(testStateConfig, facebook.LoginHandler(config, nil))
+	callbackHandler := facebook.StateHandler(testStateConfig, facebook.CallbackHandler(config, success, failure))
+
+	// login redirects to the provider
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/facebook/login", nil)
+	loginHandler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusFound, w.Code)
+	cookies := (&http.Response{Header: w.Header()}).Cookies()
+
+	// the provider auto-approves and redirects to the callback
+	noRedirects := &http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := noRedirects.Get(w.HeaderMap.Get("Location"))
+	if !assert.Nil(t, err) {
+		return w
+	}
+	resp.Body.Close()
+	callbackURL, err := url.Parse(resp.Header.Get("Location"))
+	assert.Nil(t, err)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", callbackURL.RequestURI(), nil)
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	return w
+}
+
+func TestFakeOAuth2Provider_Facebook(t *testing.T) {
+	provider, config := newFacebookProvider()
+	defer provider.Close()
+	// Facebook Graph API requests are sent to the provider
+	ctx := gologin.WithHTTPClient(context.Background(), provider.Client())
+
+	success := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "fake-access-token", token.AccessToken)
+			assert.Equal(t, "fake-refresh-token", token.RefreshToken)
+		}
+		user, err := facebook.UserFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, &facebook.User{ID: "54638001", Name: "Ivy Crimson", Email: "ivy@harvard.edu", Raw: json.RawMessage(provider.UserInfoJSON)}, user)
+		}
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// FakeOAuth2Provider with the facebook chains, assert that:
+	// - authorization is auto-approved with a code and the state
+	// - the code is exchanged for the token
+	// - the Facebook User is read from the user info endpoint
+	w := runFacebookLogin(t, ctx, provider, config, http.HandlerFunc(success), failure)
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestFakeOAuth2Provider_Errors(t *testing.T) {
+	cases := []struct {
+		name      string
+		configure func(*FakeOAuth2Provider)
+		timeout   time.Duration
+		check     func(error) bool
+	}{
+		{"access denied", func(p *FakeOAuth2Provider) { p.AuthorizeError = "access_denied" }, 0, oauth2Login.IsAccessDenied},
+		{"token server error", func(p *FakeOAuth2Provider) { p.TokenStatus = http.StatusInternalServerError }, 0, func(err error) bool {
+			return err != nil && !errors.Is(err, facebook.ErrUnableToGetFacebookUser)
+		}},
+		{"user info server error", func(p *FakeOAuth2Provider) { p.UserInfoStatus = http.StatusInternalServerError }, 0, func(err error) bool {
+			return errors.Is(err, facebook.ErrUnableToGetFacebookUser)
+		}},
+		{"slow user info", func(p *FakeOAuth2Provider) { p.Delay = 200 * time.Millisecond }, 50 * time.Millisecond, func(err error) bool {
+			return err != nil
+		}},
+	}
+	success := testutils.AssertSuccessNotCalled(t)
+	for _, c := range cases {
+		provider, config := newFacebookProvider()
+		c.configure(provider)
+		client := provider.Client()
+		client.Timeout = c.timeout
+		ctx := gologin.WithHTTPClient(context.Background(), client)
+		failure := func(w http.ResponseWriter, req *http.Request) {
+			err := gologin.ErrorFromContext(req.Context())
+			assert.True(t, c.check(err), "%s: %v", c.name, err)
+			fmt.Fprintf(w, "failure handler called")
+		}
+
+		// FakeOAuth2Provider with error knobs, assert that:
+		// - the facebook chain calls the failure handler
+		w := runFacebookLogin(t, ctx, provider, config, success, http.HandlerFunc(failure))
+		assert.Equal(t, "failure handler called", w.Body.String(), c.name)
+		provider.Close()
+	}
+}
+
+func TestFakeOAuth2Provider_CodeReuse(t *testing.T) {
+	provider := NewFakeOAuth2Provider()
+	defer provider.Close()
+	provider.codes["used-code"] = true
+	config := &oauth2.Config{ClientID: "client_id", Endpoint: provider.Endpoint()}
+
+	// FakeOAuth2Provider token endpoint assert that:
+	// - codes are single use
+	_, err := config.Exchange(context.Background(), "used-code")
+	assert.Nil(t, err)
+	_, err = config.Exchange(context.Background(), "used-code")
+	assert.NotNil(t, err)
+	_, err = config.Exchange(context.Background(), "unknown-code")
+	assert.NotNil(t, err)
+}
@@ -2,8 +2,10 @@ package google
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 
+	"github.com/dghubble/gologin"
 	google "google.golang.org/api/oauth2/v2"
 )
 
@@ -12,10 +14,14 @@ type key int
 
 const (
 	userKey key = iota
+	idTokenClaimsKey
+	rawUserKey
 )
 
-// WithUser returns a copy of ctx that stores the Google Userinfoplus.
+// WithUser returns a copy of ctx that stores the Google Userinfoplus and its gologin
+// Profile.
 func WithUser(ctx context.Context, user *google.Userinfoplus) context.Context {
+	ctx = gologin.WithProfile(ctx, newProfile(user))
 	return context.WithValue(ctx, userKey, user)
 }
 
@@ -27,3 +33,50 @@ func UserFromContext(ctx context.Context) (*google.Userinfoplus, error) {
 	}
 	return user, nil
 }
+
+// WithRawUser returns a copy of ctx that stores the raw Google userinfo response
+// body.
+func WithRawUser(ctx context.Context, raw json.RawMessage) context.Context {
+	return context.WithValue(ctx, rawUserKey, raw)
+}
+
+// RawUserFromContext returns the raw Google userinfo response body from the ctx,
+// including fields the Userinfoplus does not decode, to decode into richer
+// types.
+func RawUserFromContext(ctx context.Context) (json.RawMessage, error) {
+	raw, ok := ctx.Value(rawUserKey).(json.RawMessage)
+	if !ok {
+		return nil, fmt.Errorf("google: Context missing raw Google User")
+	}
+	return raw, nil
+}
+
+// newProfile returns the gologin Profile of the Google Userinfoplus.
+func newProfile(user *google.Userinfoplus) *gologin.Profile {
+	if user == nil {
+		return nil
+	}
+	return &gologin.Profile{
+		Provider:      ProviderName,
+		ID:            user.Id,
+		Email:         user.Email,
+		EmailVerified: user.VerifiedEmail != nil && *user.VerifiedEmail,
+		Name:          user.Name,
+		AvatarURL:     user.Picture,
+		Raw:           gologin.ProfileRaw(user, "id", "email", "verified_email", "name", "picture"),
+	}
+}
+
+// WithIDTokenClaims returns a copy of ctx that stores the Google IDTokenClaims.
+func WithIDTokenClaims(ctx context.Context, claims *IDTokenClaims) context.Context {
+	return context.WithValue(ctx, idTokenClaimsKey, claims)
+}
+
+// IDTokenClaimsFromContext returns the Google IDTokenClaims from the ctx.
+func IDTokenClaimsFromContext(ctx context.Context) (*IDTokenClaims, error) {
+	claims, ok := ctx.Value(idTokenClaimsKey).(*IDTokenClaims)
+	if !ok {
+		return nil, fmt.Errorf("google: Context missing Google IDTokenClaims")
+	}
+	return claims, nil
+}
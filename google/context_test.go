@@ -2,6 +2,7 @@ package google
 
 import (
 	"context"
+	"encoding/json"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -23,3 +24,15 @@ func TestContextUser_Error(t *testing.T) {
 		assert.Equal(t, "google: Context missing Google User", err.Error())
 	}
 }
+
+func TestContextRawUser(t *testing.T) {
+	ctx := WithRawUser(context.Background(), json.RawMessage(`{"id": "1"}`))
+	raw, err := RawUserFromContext(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"id": "1"}`, string(raw))
+
+	_, err = RawUserFromContext(context.Background())
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "google: Context missing raw Google User", err.Error())
+	}
+}
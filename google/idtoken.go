@@ -0,0 +1,75 @@
+package google
+
+import (
+	"context"
+	"errors"
+
+	"github.com/dghubble/gologin/oidc"
+	"golang.org/x/oauth2"
+)
+
+const googleJWKSURL = "https://www.googleapis.com/oauth2/v3/certs"
+
+// googleIssuers are the "iss" values of Google ID tokens.
+var googleIssuers = []string{"https://accounts.google.com", "accounts.google.com"}
+
+// Google ID token errors
+var (
+	ErrMissingIDToken       = errors.New("google: Token missing id_token")
+	ErrInvalidNonce         = errors.New("google: id_token nonce does not match")
+	ErrHostedDomainMismatch = errors.New("google: id_token hosted domain does not match")
+)
+
+// IDTokenClaims are the claims of a verified Google ID token.
+type IDTokenClaims struct {
+	*oidc.Claims
+	// EmailVerified is true if Google verified the user owns the Email.
+	EmailVerified bool
+	// HostedDomain is the Google Workspace domain of the user ("hd" claim)
+	// or empty for consumer accounts.
+	HostedDomain string
+	Picture      string
+}
+
+// newIDTokenClaims returns the Google IDTokenClaims of the verified Claims.
+func newIDTokenClaims(claims *oidc.Claims) *IDTokenClaims {
+	idTokenClaims := &IDTokenClaims{Claims: claims}
+	switch verified := claims.Extra["email_verified"].(type) {
+	case bool:
+		idTokenClaims.EmailVerified = verified
+	case string:
+		// older id_tokens encode booleans as strings
+		idTokenClaims.EmailVerified = verified == "true"
+	}
+	idTokenClaims.HostedDomain, _ = claims.Extra["hd"].(string)
+	idTokenClaims.Picture, _ = claims.Extra["picture"].(string)
+	return idTokenClaims
+}
+
+// newIDTokenVerifier returns an IDTokenVerifier for Google ID tokens issued
+// to the client.
+func newIDTokenVerifier(clientID string) *oidc.IDTokenVerifier {
+	return oidc.NewIDTokenVerifier(googleJWKSURL, clientID, googleIssuers...)
+}
+
+// verifyIDToken verifies the Token's id_token with Google's keys and checks
+// its nonce claim matches the nonce, if non-empty. Returns ErrMissingIDToken
+// if a nonce is expected but the Token has no id_token, or nil Claims if
+// neither is present.
+func verifyIDToken(ctx context.Context, verifier *oidc.IDTokenVerifier, token *oauth2.Token, nonce string) (*oidc.Claims, error) {
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		if nonce != "" {
+			return nil, ErrMissingIDToken
+		}
+		return nil, nil
+	}
+	claims, err := verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, err
+	}
+	if nonce != "" && claims.Nonce != nonce {
+		return nil, ErrInvalidNonce
+	}
+	return claims, nil
+}
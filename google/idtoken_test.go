@@ -0,0 +1,105 @@
+package google
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/dghubble/gologin/oidc"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+const testClientID = "client_id"
+
+// testKey signs test id_tokens and is served by newGoogleTestServer.
+var testKey, _ = rsa.GenerateKey(rand.Reader, 2048)
+
+// testJWKS returns the JSON Web Key Set of the testKey.
+func testJWKS() string {
+	n := base64.RawURLEncoding.EncodeToString(testKey.N.Bytes())
+	e := base64.RawURLEncoding.EncodeToString(big.NewInt(int64(testKey.E)).Bytes())
+	return fmt.Sprintf(`{"keys": [{"kty": "RSA", "kid": "test-key", "use": "sig", "n": %q, "e": %q}]}`, n, e)
+}
+
+// testIDToken returns an id_token with the given JSON claims signed by the
+// testKey.
+func testIDToken(claims string) string {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","kid":"test-key"}`))
+	payload := base64.RawURLEncoding.EncodeToString([]byte(claims))
+	digest := sha256.Sum256([]byte(header + "." + payload))
+	signature, _ := rsa.SignPKCS1v15(rand.Reader, testKey, crypto.SHA256, digest[:])
+	return header + "." + payload + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// testClaims returns valid Google id_token JSON claims with the nonce.
+func testClaims(nonce string) string {
+	return fmt.Sprintf(`{"iss":"https://accounts.google.com","sub":"900913","aud":%q,"exp":%d,"nonce":%q}`,
+		testClientID, time.Now().Add(time.Hour).Unix(), nonce)
+}
+
+func tokenWithIDToken(idToken string) *oauth2.Token {
+	token := &oauth2.Token{AccessToken: "any-token"}
+	return token.WithExtra(map[string]interface{}{"id_token": idToken})
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	proxyClient, server := newGoogleTestServer(`{}`)
+	defer server.Close()
+	// the verifier fetches Google's keys with the proxy client
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	expired := fmt.Sprintf(`{"iss":"accounts.google.com","sub":"900913","aud":%q,"exp":%d}`, testClientID, time.Now().Add(-time.Hour).Unix())
+	cases := []struct {
+		token *oauth2.Token
+		nonce string
+		err   error
+	}{
+		{tokenWithIDToken(testIDToken(testClaims("some_nonce"))), "some_nonce", nil},
+		{tokenWithIDToken(testIDToken(testClaims("some_nonce"))), "", nil},
+		{tokenWithIDToken(testIDToken(testClaims("other_nonce"))), "some_nonce", ErrInvalidNonce},
+		{tokenWithIDToken(testIDToken(testClaims(""))), "some_nonce", ErrInvalidNonce},
+		{&oauth2.Token{AccessToken: "any-token"}, "some_nonce", ErrMissingIDToken},
+		{tokenWithIDToken("not-a-jwt"), "some_nonce", oidc.ErrInvalidIDToken},
+		{tokenWithIDToken(testIDToken(expired)), "", oidc.ErrIDTokenExpired},
+		{tokenWithIDToken(testIDToken(`{"iss":"https://evil.example.com"}`)), "", oidc.ErrInvalidIssuer},
+	}
+	verifier := newIDTokenVerifier(testClientID)
+	for _, c := range cases {
+		claims, err := verifyIDToken(ctx, verifier, c.token, c.nonce)
+		assert.Equal(t, c.err, err)
+		if c.err == nil {
+			assert.Equal(t, "900913", claims.Subject)
+		}
+	}
+
+	// Tokens without an id_token are allowed when no nonce is expected
+	claims, err := verifyIDToken(ctx, verifier, &oauth2.Token{AccessToken: "any-token"}, "")
+	assert.Nil(t, claims)
+	assert.Nil(t, err)
+}
+
+func TestNewIDTokenClaims(t *testing.T) {
+	cases := []struct {
+		extra    map[string]interface{}
+		verified bool
+		hd       string
+	}{
+		{map[string]interface{}{"email_verified": true, "hd": "example.com"}, true, "example.com"},
+		{map[string]interface{}{"email_verified": "true"}, true, ""},
+		{map[string]interface{}{"email_verified": false}, false, ""},
+		{map[string]interface{}{}, false, ""},
+	}
+	for _, c := range cases {
+		claims := newIDTokenClaims(&oidc.Claims{Subject: "900913", Extra: c.extra})
+		assert.Equal(t, "900913", claims.Subject)
+		assert.Equal(t, c.verified, claims.EmailVerified)
+		assert.Equal(t, c.hd, claims.HostedDomain)
+	}
+}
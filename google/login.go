@@ -1,19 +1,30 @@
 package google
 
 import (
+	"context"
 	"errors"
 	"net/http"
+	"strings"
 
 	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/internal"
 	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/dghubble/gologin/oidc"
 	"golang.org/x/oauth2"
 	google "google.golang.org/api/oauth2/v2"
 )
 
+const googleRevocationURL = "https://oauth2.googleapis.com/revoke"
+
+// ProviderName is the provider name set in the ctx (see
+// gologin.ProviderFromContext) and reported to gologin Hooks.
+const ProviderName = "google"
+
 // Google login errors
 var (
 	ErrUnableToGetGoogleUser    = errors.New("google: unable to get Google User")
 	ErrCannotValidateGoogleUser = errors.New("google: could not validate Google User")
+	ErrEmailNotVerified         = errors.New("google: Google User email is not verified")
 )
 
 // StateHandler checks for a state cookie. If found, the state value is read
@@ -28,28 +39,122 @@ func StateHandler(config gologin.CookieConfig, success http.Handler) http.Handle
 	return oauth2Login.StateHandler(config, success)
 }
 
+// Config configures Google login.
+type Config struct {
+	// HostedDomain restricts login to users of a Google Workspace domain
+	// (e.g. "example.com"). LoginHandlerWithConfig adds hd=<domain> to the
+	// AuthURL and CallbackHandlerWithConfig requires a verified id_token
+	// (request the "openid" scope) whose hd claim matches.
+	HostedDomain string
+	// RequireVerifiedEmail requires the Google User email to be verified.
+	// CallbackHandlerWithConfig checks the userinfo verified_email field (or
+	// the id_token email_verified claim if absent) and fails with
+	// ErrEmailNotVerified when it is false or missing.
+	RequireVerifiedEmail bool
+	// Language is the language of the consent screen (e.g. "fr" or "pt-BR"),
+	// sent as hl by LoginHandlerWithConfig. A ctx locale (see oauth2
+	// WithLocale) takes precedence.
+	Language string
+	// Prompt lists the screens Google shows, e.g. PromptSelectAccount or
+	// PromptConsent (space separated), sent as prompt by
+	// LoginHandlerWithConfig.
+	Prompt string
+	// Transport is the base transport of Google API requests. Handlers share
+	// it across logins so connections are reused. Defaults to a pooled
+	// transport per handler. The transport of a ctx oauth2.HTTPClient is
+	// used instead, if any.
+	Transport http.RoundTripper
+}
+
+// Prompts which may be combined (space separated) in the Config Prompt.
+const (
+	PromptNone          = "none"
+	PromptConsent       = "consent"
+	PromptSelectAccount = "select_account"
+)
+
 // LoginHandler handles Google login requests by reading the state value from
 // the ctx and redirecting requests to the AuthURL with that state value.
-func LoginHandler(config *oauth2.Config, failure http.Handler) http.Handler {
-	return oauth2Login.LoginHandler(config, failure)
+// Any AuthCodeOptions are added to the AuthURL. A ctx locale (see oauth2
+// WithLocale) is sent as hl.
+func LoginHandler(config *oauth2.Config, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	return LoginHandlerWithConfig(config, Config{}, failure, opts...)
+}
+
+// LoginHandlerWithConfig handles Google login requests like LoginHandler, but
+// adds the Config HostedDomain, Language (hl), and Prompt (if any) to the
+// AuthURL. The hd parameter only optimizes the account chooser; callbacks
+// must still be checked by CallbackHandlerWithConfig.
+func LoginHandlerWithConfig(config *oauth2.Config, googleConfig Config, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	opts = opts[:len(opts):len(opts)]
+	if googleConfig.HostedDomain != "" {
+		opts = append(opts, oauth2.SetAuthURLParam("hd", googleConfig.HostedDomain))
+	}
+	if googleConfig.Language != "" {
+		opts = append(opts, oauth2.SetAuthURLParam("hl", googleConfig.Language))
+	}
+	if googleConfig.Prompt != "" {
+		opts = append(opts, oauth2.SetAuthURLParam("prompt", googleConfig.Prompt))
+	}
+	success := oauth2Login.LocaleParamHandler("hl", nil, oauth2Login.LoginHandler(config, failure, opts...))
+	return gologin.ProviderHandler(ProviderName, success)
+}
+
+// RevokeHandler revokes the Google Token from the ctx, then calls the success
+// handler. Tokens which are already invalid are treated as revoked.
+func RevokeHandler(config *oauth2.Config, success, failure http.Handler) http.Handler {
+	return oauth2Login.RevokeHandler(config, googleRevocationURL, success, failure)
 }
 
 // CallbackHandler handles Google redirection URI requests and adds the Google
 // access token and Userinfoplus to the ctx. If authentication succeeds,
 // handling delegates to the success handler, otherwise to the failure handler.
-func CallbackHandler(config *oauth2.Config, success, failure http.Handler) http.Handler {
-	success = googleHandler(config, success, failure)
-	return oauth2Login.CallbackHandler(config, success, failure)
+// Any AuthCodeOptions are sent with the token exchange.
+func CallbackHandler(config *oauth2.Config, success, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	return CallbackHandlerWithConfig(config, Config{}, success, failure, opts...)
+}
+
+// CallbackHandlerWithConfig handles Google redirection URI requests like
+// CallbackHandler, but enforces the Config. If the Config has a HostedDomain,
+// callbacks without an id_token fail with ErrMissingIDToken and callbacks
+// whose id_token hd claim does not match fail with ErrHostedDomainMismatch.
+// If the Config has RequireVerifiedEmail, callbacks for Google Users whose
+// email is not verified fail with ErrEmailNotVerified.
+//
+// The callback outcome, with the Google User ID, is reported to any ctx
+// gologin Hooks.
+func CallbackHandlerWithConfig(config *oauth2.Config, googleConfig Config, success, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	success, failure = gologin.CallbackHooks(ProviderName, userID, success, failure)
+	success = googleHandler(config, googleConfig, success, failure)
+	return gologin.ProviderHandler(ProviderName, oauth2Login.CallbackHandler(config, success, failure, opts...))
+}
+
+// userID returns the ID of the Google User from the ctx, if any.
+func userID(ctx context.Context) string {
+	if user, err := UserFromContext(ctx); err == nil {
+		return user.Id
+	}
+	return ""
 }
 
 // googleHandler is a http.Handler that gets the OAuth2 Token from the ctx
 // to get the corresponding Google Userinfoplus. If successful, the user info
-// is added to the ctx and the success handler is called. Otherwise, the
+// (and its raw response body, see RawUserFromContext) is added to the ctx and
+// the success handler is called. Otherwise, the
 // failure handler is called.
-func googleHandler(config *oauth2.Config, success, failure http.Handler) http.Handler {
+//
+// If the Token has an id_token (i.e. the openid scope was requested), it is
+// verified with Google's keys and its Claims are added to the ctx (see oidc
+// IDTokenFromContext and IDTokenClaimsFromContext). If the ctx contains an
+// OpenID Connect nonce (see oauth2 NonceHandler) or the Config has a
+// HostedDomain, the id_token is required and its nonce and hd claims must
+// match.
+func googleHandler(config *oauth2.Config, googleConfig Config, success, failure http.Handler) http.Handler {
 	if failure == nil {
 		failure = gologin.DefaultFailureHandler
 	}
+	verifier := newIDTokenVerifier(config.ClientID)
+	googleConfig.Transport = internal.TransportOrNew(googleConfig.Transport)
 	fn := func(w http.ResponseWriter, req *http.Request) {
 		ctx := req.Context()
 		token, err := oauth2Login.TokenFromContext(ctx)
@@ -58,34 +163,80 @@ func googleHandler(config *oauth2.Config, success, failure http.Handler) http.Ha
 			failure.ServeHTTP(w, req.WithContext(ctx))
 			return
 		}
-		httpClient := config.Client(ctx, token)
+		nonce, _ := oauth2Login.NonceFromContext(ctx)
+		claims, err := verifyIDToken(ctx, verifier, token, nonce)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		if claims == nil && googleConfig.HostedDomain != "" {
+			ctx = gologin.WithError(ctx, ErrMissingIDToken)
+			ctx = gologin.WithStatusCode(ctx, http.StatusBadGateway)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		var idTokenClaims *IDTokenClaims
+		if claims != nil {
+			idTokenClaims = newIDTokenClaims(claims)
+			if googleConfig.HostedDomain != "" && !strings.EqualFold(idTokenClaims.HostedDomain, googleConfig.HostedDomain) {
+				ctx = gologin.WithError(ctx, ErrHostedDomainMismatch)
+				ctx = gologin.WithStatusCode(ctx, http.StatusForbidden)
+				failure.ServeHTTP(w, req.WithContext(ctx))
+				return
+			}
+			ctx = oidc.WithIDToken(ctx, claims)
+			ctx = WithIDTokenClaims(ctx, idTokenClaims)
+		}
+		httpClient, rawUser := internal.RecordBody(internal.OAuth2TransportClient(ctx, googleConfig.Transport, config, token), "/userinfo")
 		googleService, err := google.New(httpClient)
 		if err != nil {
 			ctx = gologin.WithError(ctx, err)
 			failure.ServeHTTP(w, req.WithContext(ctx))
 			return
 		}
-		userInfoPlus, err := googleService.Userinfo.Get().Do()
+		fetchCtx, endFetch := gologin.StartUserFetch(ctx, ProviderName)
+		userInfoPlus, err := googleService.Userinfo.Get().Context(fetchCtx).Do()
 		err = validateResponse(userInfoPlus, err)
+		endFetch(err)
 		if err != nil {
 			ctx = gologin.WithError(ctx, err)
 			failure.ServeHTTP(w, req.WithContext(ctx))
 			return
 		}
+		if googleConfig.RequireVerifiedEmail && !emailVerified(userInfoPlus, idTokenClaims) {
+			ctx = gologin.WithError(ctx, ErrEmailNotVerified)
+			ctx = gologin.WithStatusCode(ctx, http.StatusForbidden)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		if raw := rawUser(); raw != nil {
+			ctx = WithRawUser(ctx, raw)
+		}
 		ctx = WithUser(ctx, userInfoPlus)
 		success.ServeHTTP(w, req.WithContext(ctx))
 	}
 	return http.HandlerFunc(fn)
 }
 
+// emailVerified returns the userinfo verified_email field, falling back to the
+// id_token email_verified claim (if any) when the field is absent.
+func emailVerified(user *google.Userinfoplus, claims *IDTokenClaims) bool {
+	if user.VerifiedEmail != nil {
+		return *user.VerifiedEmail
+	}
+	return claims != nil && claims.EmailVerified
+}
+
 // validateResponse returns an error if the given Google Userinfoplus, raw
-// http.Response, or error are unexpected. Returns nil if they are valid.
+// http.Response, or error are unexpected. Returns nil if they are valid, or a
+// *gologin.Error which preserves the cause.
 func validateResponse(user *google.Userinfoplus, err error) error {
 	if err != nil {
-		return ErrUnableToGetGoogleUser
+		return &gologin.Error{Provider: "google", Op: "get user", Err: err, Kind: ErrUnableToGetGoogleUser}
 	}
 	if user == nil || user.Id == "" {
-		return ErrCannotValidateGoogleUser
+		return &gologin.Error{Provider: "google", Op: "get user", Kind: ErrCannotValidateGoogleUser}
 	}
 	return nil
 }
@@ -2,13 +2,17 @@ package google
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"testing"
+	"time"
 
 	"github.com/dghubble/gologin"
 	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/dghubble/gologin/oidc"
 	"github.com/dghubble/gologin/testutils"
 	"github.com/stretchr/testify/assert"
 	"golang.org/x/oauth2"
@@ -42,7 +46,270 @@ func TestGoogleHandler(t *testing.T) {
 	// - google Userinfoplus is obtained from the Google API
 	// - success handler is called
 	// - google Userinfoplus is added to the ctx of the success handler
-	googleHandler := googleHandler(config, http.HandlerFunc(success), failure)
+	googleHandler := googleHandler(config, Config{}, http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	googleHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestGoogleHandler_RawUser(t *testing.T) {
+	jsonData := `{"id": "900913", "name": "Ben Bitdiddle", "locale": "en", "custom": {"team": "infra"}}`
+	proxyClient, server := newGoogleTestServer(jsonData)
+	defer server.Close()
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+	success := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		user, err := UserFromContext(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, "900913", user.Id)
+		raw, err := RawUserFromContext(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, jsonData, string(raw))
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// GoogleHandler assert that:
+	// - the exact userinfo response body is added to the ctx
+	handler := googleHandler(&oauth2.Config{}, Config{}, http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	handler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestGoogleHandler_Nonce(t *testing.T) {
+	proxyClient, server := newGoogleTestServer(`{"id": "900913", "name": "Ben Bitdiddle"}`)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	idToken := testIDToken(testClaims("some_nonce"))
+	ctx = oauth2Login.WithToken(ctx, tokenWithIDToken(idToken))
+
+	config := &oauth2.Config{ClientID: testClientID}
+	success := func(w http.ResponseWriter, req *http.Request) {
+		claims, err := oidc.IDTokenFromContext(req.Context())
+		if assert.Nil(t, err) {
+			assert.Equal(t, "900913", claims.Subject)
+			assert.Equal(t, idToken, claims.RawIDToken)
+		}
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.Equal(t, ErrInvalidNonce, err)
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// GoogleHandler with a ctx nonce matching the id_token, assert that:
+	// - the id_token is verified with Google's keys
+	// - the id_token Claims are added to the ctx
+	// - success handler is called
+	googleHandler := googleHandler(config, Config{}, http.HandlerFunc(success), http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	googleHandler.ServeHTTP(w, req.WithContext(oauth2Login.WithNonce(ctx, "some_nonce")))
+	assert.Equal(t, "success handler called", w.Body.String())
+
+	// GoogleHandler with a ctx nonce not matching the id_token, assert that:
+	// - failure handler is called with ErrInvalidNonce
+	w = httptest.NewRecorder()
+	googleHandler.ServeHTTP(w, req.WithContext(oauth2Login.WithNonce(ctx, "replayed_nonce")))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestLoginHandlerWithConfig(t *testing.T) {
+	config := &oauth2.Config{
+		ClientID: testClientID,
+		Endpoint: oauth2.Endpoint{AuthURL: "https://accounts.google.com/o/oauth2/v2/auth"},
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// LoginHandlerWithConfig with a HostedDomain, assert that:
+	// - hd is added to the AuthURL
+	loginHandler := LoginHandlerWithConfig(config, Config{HostedDomain: "example.com"}, failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	ctx := oauth2Login.WithState(context.Background(), "state_val")
+	loginHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, http.StatusFound, w.Code)
+	location, err := url.Parse(w.HeaderMap.Get("Location"))
+	if assert.Nil(t, err) {
+		assert.Equal(t, "example.com", location.Query().Get("hd"))
+	}
+}
+
+func TestLoginHandlerWithConfig_Locale(t *testing.T) {
+	config := &oauth2.Config{
+		ClientID: testClientID,
+		Endpoint: oauth2.Endpoint{AuthURL: "https://accounts.google.com/o/oauth2/v2/auth"},
+	}
+	cases := []struct {
+		googleConfig Config
+		locale       string
+		expected     url.Values
+	}{
+		{Config{Language: "pt-BR", Prompt: PromptSelectAccount + " " + PromptConsent}, "", url.Values{"hl": {"pt-BR"}, "prompt": {"select_account consent"}}},
+		// the ctx locale takes precedence over the Config Language
+		{Config{Language: "pt-BR"}, "fr-CA", url.Values{"hl": {"fr-CA"}}},
+		{Config{}, "de", url.Values{"hl": {"de"}}},
+		{Config{}, "", url.Values{}},
+	}
+	for _, c := range cases {
+		// LoginHandlerWithConfig with a Language, Prompt, or ctx locale,
+		// assert that:
+		// - they are added to the AuthURL as hl and prompt
+		loginHandler := LoginHandlerWithConfig(config, c.googleConfig, testutils.AssertFailureNotCalled(t))
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		ctx := oauth2Login.WithState(context.Background(), "state_val")
+		if c.locale != "" {
+			ctx = oauth2Login.WithLocale(ctx, c.locale)
+		}
+		loginHandler.ServeHTTP(w, req.WithContext(ctx))
+		location, err := url.Parse(w.HeaderMap.Get("Location"))
+		if assert.Nil(t, err) {
+			query := location.Query()
+			for _, param := range []string{"hl", "prompt", "locale", "mkt"} {
+				assert.Equal(t, c.expected[param], query[param], param)
+			}
+		}
+	}
+}
+
+func TestGoogleHandler_HostedDomain(t *testing.T) {
+	proxyClient, server := newGoogleTestServer(`{"id": "900913", "name": "Ben Bitdiddle"}`)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	withHD := func(hd string) *oauth2.Token {
+		claims := fmt.Sprintf(`{"iss":"https://accounts.google.com","sub":"900913","aud":%q,"exp":%d,"email":"ben@example.com","email_verified":true,"hd":%q}`,
+			testClientID, time.Now().Add(time.Hour).Unix(), hd)
+		return tokenWithIDToken(testIDToken(claims))
+	}
+	cases := []struct {
+		token *oauth2.Token
+		err   error
+	}{
+		{withHD("example.com"), nil},
+		{withHD("EXAMPLE.com"), nil},
+		{withHD("evil.example.com"), ErrHostedDomainMismatch},
+		{withHD(""), ErrHostedDomainMismatch},
+		{&oauth2.Token{AccessToken: "any-token"}, ErrMissingIDToken},
+	}
+	config := &oauth2.Config{ClientID: testClientID}
+	for _, c := range cases {
+		success := func(w http.ResponseWriter, req *http.Request) {
+			assert.Nil(t, c.err)
+			claims, err := IDTokenClaimsFromContext(req.Context())
+			if assert.Nil(t, err) {
+				assert.Equal(t, "900913", claims.Subject)
+				assert.Equal(t, "ben@example.com", claims.Email)
+				assert.True(t, claims.EmailVerified)
+			}
+			fmt.Fprintf(w, "success handler called")
+		}
+		failure := func(w http.ResponseWriter, req *http.Request) {
+			assert.Equal(t, c.err, gologin.ErrorFromContext(req.Context()))
+			fmt.Fprintf(w, "failure handler called")
+		}
+
+		// GoogleHandler with a HostedDomain, assert that:
+		// - id_tokens of the hosted domain succeed with IDTokenClaims
+		// - other or missing hd claims fail with ErrHostedDomainMismatch
+		// - Tokens without an id_token fail with ErrMissingIDToken
+		googleHandler := googleHandler(config, Config{HostedDomain: "example.com"}, http.HandlerFunc(success), http.HandlerFunc(failure))
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		googleHandler.ServeHTTP(w, req.WithContext(oauth2Login.WithToken(ctx, c.token)))
+		if c.err == nil {
+			assert.Equal(t, "success handler called", w.Body.String())
+		} else {
+			assert.Equal(t, "failure handler called", w.Body.String())
+		}
+	}
+}
+
+func TestGoogleHandler_RequireVerifiedEmail(t *testing.T) {
+	withVerified := func(verified bool) *oauth2.Token {
+		claims := fmt.Sprintf(`{"iss":"https://accounts.google.com","sub":"900913","aud":%q,"exp":%d,"email":"ben@example.com","email_verified":%t}`,
+			testClientID, time.Now().Add(time.Hour).Unix(), verified)
+		return tokenWithIDToken(testIDToken(claims))
+	}
+	anyToken := &oauth2.Token{AccessToken: "any-token"}
+	cases := []struct {
+		userJSON string
+		token    *oauth2.Token
+		err      error
+	}{
+		{`{"id": "900913", "email": "ben@example.com", "verified_email": true, "locale": "en", "picture": "https://example.com/ben.jpg"}`, anyToken, nil},
+		{`{"id": "900913", "email": "ben@example.com", "verified_email": false}`, anyToken, ErrEmailNotVerified},
+		{`{"id": "900913", "email": "ben@example.com"}`, anyToken, ErrEmailNotVerified},
+		{`{"id": "900913", "email": "ben@example.com"}`, withVerified(true), nil},
+		{`{"id": "900913", "email": "ben@example.com"}`, withVerified(false), ErrEmailNotVerified},
+		{`{"id": "900913", "email": "ben@example.com", "verified_email": false}`, withVerified(true), ErrEmailNotVerified},
+	}
+	config := &oauth2.Config{ClientID: testClientID}
+	for _, c := range cases {
+		proxyClient, server := newGoogleTestServer(c.userJSON)
+		// oauth2 Client will use the proxy client's base Transport
+		ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+		success := func(w http.ResponseWriter, req *http.Request) {
+			assert.Nil(t, c.err)
+			googleUser, err := UserFromContext(req.Context())
+			assert.Nil(t, err)
+			assert.Equal(t, "900913", googleUser.Id)
+			fmt.Fprintf(w, "success handler called")
+		}
+		failure := func(w http.ResponseWriter, req *http.Request) {
+			assert.Equal(t, c.err, gologin.ErrorFromContext(req.Context()))
+			fmt.Fprintf(w, "failure handler called")
+		}
+
+		// GoogleHandler with RequireVerifiedEmail, assert that:
+		// - verified emails succeed
+		// - unverified or unknown emails fail with ErrEmailNotVerified
+		// - the userinfo verified_email field takes precedence over the id_token
+		googleHandler := googleHandler(config, Config{RequireVerifiedEmail: true}, http.HandlerFunc(success), http.HandlerFunc(failure))
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		googleHandler.ServeHTTP(w, req.WithContext(oauth2Login.WithToken(ctx, c.token)))
+		if c.err == nil {
+			assert.Equal(t, "success handler called", w.Body.String())
+		} else {
+			assert.Equal(t, "failure handler called", w.Body.String())
+		}
+		server.Close()
+	}
+}
+
+func TestGoogleHandler_UserFields(t *testing.T) {
+	proxyClient, server := newGoogleTestServer(`{"id": "900913", "verified_email": false, "locale": "en", "picture": "https://example.com/ben.jpg"}`)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+	success := func(w http.ResponseWriter, req *http.Request) {
+		googleUser, err := UserFromContext(req.Context())
+		if assert.Nil(t, err) {
+			assert.Equal(t, "en", googleUser.Locale)
+			assert.Equal(t, "https://example.com/ben.jpg", googleUser.Picture)
+			if assert.NotNil(t, googleUser.VerifiedEmail) {
+				assert.False(t, *googleUser.VerifiedEmail)
+			}
+		}
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// GoogleHandler with the default Config, assert that:
+	// - unverified emails are permitted
+	// - locale, picture, and verified_email are decoded into the User
+	googleHandler := googleHandler(&oauth2.Config{}, Config{}, http.HandlerFunc(success), failure)
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest("GET", "/", nil)
 	googleHandler.ServeHTTP(w, req.WithContext(ctx))
@@ -64,7 +331,7 @@ func TestGoogleHandler_MissingCtxToken(t *testing.T) {
 	// GoogleHandler called without Token in ctx, assert that:
 	// - failure handler is called
 	// - error about ctx missing token is added to the failure handler ctx
-	googleHandler := googleHandler(config, success, http.HandlerFunc(failure))
+	googleHandler := googleHandler(config, Config{}, success, http.HandlerFunc(failure))
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest("GET", "/", nil)
 	googleHandler.ServeHTTP(w, req)
@@ -85,7 +352,7 @@ func TestGoogleHandler_ErrorGettingUser(t *testing.T) {
 		ctx := req.Context()
 		err := gologin.ErrorFromContext(ctx)
 		if assert.NotNil(t, err) {
-			assert.Equal(t, ErrUnableToGetGoogleUser, err)
+			assert.True(t, errors.Is(err, ErrUnableToGetGoogleUser))
 		}
 		fmt.Fprintf(w, "failure handler called")
 	}
@@ -93,16 +360,43 @@ func TestGoogleHandler_ErrorGettingUser(t *testing.T) {
 	// GoogleHandler cannot get Google User, assert that:
 	// - failure handler is called
 	// - error cannot get Google User added to the failure handler ctx
-	googleHandler := googleHandler(config, success, http.HandlerFunc(failure))
+	googleHandler := googleHandler(config, Config{}, success, http.HandlerFunc(failure))
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest("GET", "/", nil)
 	googleHandler.ServeHTTP(w, req.WithContext(ctx))
 	assert.Equal(t, "failure handler called", w.Body.String())
 }
 
+func TestRevokeHandler(t *testing.T) {
+	proxyClient, mux, server := testutils.TestServer()
+	defer server.Close()
+	mux.HandleFunc("/revoke", func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "POST", req.Method)
+		assert.Equal(t, "any-refresh", req.PostFormValue("token"))
+		w.WriteHeader(http.StatusOK)
+	})
+	// revocation requests use the proxy client
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token", RefreshToken: "any-refresh"})
+
+	success := func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// RevokeHandler assert that:
+	// - the Token is POSTed to the Google revocation endpoint
+	// - success handler is called
+	revokeHandler := RevokeHandler(&oauth2.Config{}, http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/logout", nil)
+	revokeHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
 func TestValidateResponse(t *testing.T) {
 	assert.Equal(t, nil, validateResponse(&google.Userinfoplus{Id: "123"}, nil))
-	assert.Equal(t, ErrUnableToGetGoogleUser, validateResponse(nil, fmt.Errorf("Server error")))
-	assert.Equal(t, ErrCannotValidateGoogleUser, validateResponse(nil, nil))
-	assert.Equal(t, ErrCannotValidateGoogleUser, validateResponse(&google.Userinfoplus{Name: "Ben"}, nil))
+	assert.True(t, errors.Is(validateResponse(nil, fmt.Errorf("Server error")), ErrUnableToGetGoogleUser))
+	assert.True(t, errors.Is(validateResponse(nil, nil), ErrCannotValidateGoogleUser))
+	assert.True(t, errors.Is(validateResponse(&google.Userinfoplus{Name: "Ben"}, nil), ErrCannotValidateGoogleUser))
 }
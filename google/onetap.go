@@ -0,0 +1,96 @@
+package google
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/oidc"
+	google "google.golang.org/api/oauth2/v2"
+)
+
+const (
+	credentialField = "credential"
+	csrfTokenName   = "g_csrf_token"
+)
+
+// Google One Tap errors
+var (
+	ErrMissingCredential = errors.New("google: missing credential field")
+	ErrCSRFTokenMismatch = errors.New("google: g_csrf_token cookie does not match the form value")
+)
+
+// OneTapHandler handles Google One Tap and Sign In With Google credential
+// POSTs. The g_csrf_token cookie must match the g_csrf_token form value
+// (double-submit CSRF protection) and the credential (an ID token) is
+// verified with Google's keys for the client ID audience. If successful, the
+// id_token Claims (see IDTokenClaimsFromContext) and a Userinfoplus built
+// from them are added to the ctx, like CallbackHandler, and the success
+// handler is called. Otherwise, the failure handler is called.
+//
+// CSRF mismatches are reported as ErrCSRFTokenMismatch, expired credentials
+// as oidc ErrIDTokenExpired, and other audiences as oidc ErrInvalidAudience.
+// Credential POSTs are limited by gologin.BodyHandler defaults.
+func OneTapHandler(clientID string, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	verifier := newIDTokenVerifier(clientID)
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		if err := verifyCSRFToken(req); err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		credential := req.PostFormValue(credentialField)
+		if credential == "" {
+			ctx = gologin.WithError(ctx, ErrMissingCredential)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		claims, err := verifier.Verify(ctx, credential)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		idTokenClaims := newIDTokenClaims(claims)
+		ctx = oidc.WithIDToken(ctx, claims)
+		ctx = WithIDTokenClaims(ctx, idTokenClaims)
+		ctx = WithUser(ctx, userFromClaims(idTokenClaims))
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return gologin.BodyHandler(gologin.BodyConfig{}, http.HandlerFunc(fn), failure)
+}
+
+// verifyCSRFToken returns ErrCSRFTokenMismatch unless the g_csrf_token cookie
+// is present and equals the g_csrf_token form value.
+func verifyCSRFToken(req *http.Request) error {
+	cookie, err := req.Cookie(csrfTokenName)
+	if err != nil || cookie.Value == "" {
+		return ErrCSRFTokenMismatch
+	}
+	formToken := req.PostFormValue(csrfTokenName)
+	if subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(formToken)) != 1 {
+		return ErrCSRFTokenMismatch
+	}
+	return nil
+}
+
+// userFromClaims returns the Userinfoplus described by Google IDTokenClaims.
+func userFromClaims(claims *IDTokenClaims) *google.Userinfoplus {
+	user := &google.Userinfoplus{
+		Id:            claims.Subject,
+		Email:         claims.Email,
+		VerifiedEmail: &claims.EmailVerified,
+		Name:          claims.Name,
+		Picture:       claims.Picture,
+		Hd:            claims.HostedDomain,
+	}
+	user.GivenName, _ = claims.Extra["given_name"].(string)
+	user.FamilyName, _ = claims.Extra["family_name"].(string)
+	user.Locale, _ = claims.Extra["locale"].(string)
+	return user
+}
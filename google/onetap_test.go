@@ -0,0 +1,107 @@
+package google
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/oidc"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+// testOneTapClaims returns Google One Tap credential JSON claims.
+func testOneTapClaims(aud string, exp time.Time) string {
+	return fmt.Sprintf(`{"iss":"https://accounts.google.com","sub":"900913","aud":%q,"exp":%d,
+		"email":"ben@example.com","email_verified":true,"name":"Ben Bitdiddle","picture":"https://example.com/ben.jpg"}`, aud, exp.Unix())
+}
+
+// postCredential serves a One Tap POST of the credential with the form and
+// cookie CSRF tokens.
+func postCredential(handler http.Handler, ctx context.Context, credential, formCSRF, cookieCSRF string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	form := url.Values{"credential": {credential}, "g_csrf_token": {formCSRF}}
+	req, _ := http.NewRequest("POST", "/", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if cookieCSRF != "" {
+		req.AddCookie(&http.Cookie{Name: "g_csrf_token", Value: cookieCSRF})
+	}
+	handler.ServeHTTP(w, req.WithContext(ctx))
+	return w
+}
+
+func TestOneTapHandler(t *testing.T) {
+	proxyClient, server := newGoogleTestServer(`{}`)
+	defer server.Close()
+	// the verifier fetches Google's keys with the proxy client
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	credential := testIDToken(testOneTapClaims(testClientID, time.Now().Add(time.Hour)))
+
+	success := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		user, err := UserFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "900913", user.Id)
+			assert.Equal(t, "ben@example.com", user.Email)
+			assert.Equal(t, "Ben Bitdiddle", user.Name)
+			assert.Equal(t, "https://example.com/ben.jpg", user.Picture)
+			if assert.NotNil(t, user.VerifiedEmail) {
+				assert.True(t, *user.VerifiedEmail)
+			}
+		}
+		claims, err := oidc.IDTokenFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, credential, claims.RawIDToken)
+		}
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// OneTapHandler assert that:
+	// - the CSRF tokens are compared
+	// - the credential is verified with Google's keys
+	// - a Userinfoplus is built from the claims and added to the ctx
+	// - success handler is called
+	w := postCredential(OneTapHandler(testClientID, http.HandlerFunc(success), failure), ctx, credential, "csrf", "csrf")
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestOneTapHandler_Errors(t *testing.T) {
+	proxyClient, server := newGoogleTestServer(`{}`)
+	defer server.Close()
+	// the verifier fetches Google's keys with the proxy client
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	valid := testIDToken(testOneTapClaims(testClientID, time.Now().Add(time.Hour)))
+	cases := []struct {
+		name       string
+		credential string
+		formCSRF   string
+		cookieCSRF string
+		err        error
+	}{
+		{"csrf mismatch", valid, "csrf", "other", ErrCSRFTokenMismatch},
+		{"csrf cookie missing", valid, "csrf", "", ErrCSRFTokenMismatch},
+		{"missing credential", "", "csrf", "csrf", ErrMissingCredential},
+		{"expired", testIDToken(testOneTapClaims(testClientID, time.Now().Add(-time.Minute))), "csrf", "csrf", oidc.ErrIDTokenExpired},
+		{"other audience", testIDToken(testOneTapClaims("other_client", time.Now().Add(time.Hour))), "csrf", "csrf", oidc.ErrInvalidAudience},
+	}
+	success := testutils.AssertSuccessNotCalled(t)
+	for _, c := range cases {
+		failure := func(w http.ResponseWriter, req *http.Request) {
+			assert.Equal(t, c.err, gologin.ErrorFromContext(req.Context()), c.name)
+			fmt.Fprintf(w, "failure handler called")
+		}
+
+		// OneTapHandler with an invalid POST, assert that:
+		// - failure handler is called with a distinguishable error
+		w := postCredential(OneTapHandler(testClientID, success, http.HandlerFunc(failure)), ctx, c.credential, c.formCSRF, c.cookieCSRF)
+		assert.Equal(t, "failure handler called", w.Body.String(), c.name)
+	}
+}
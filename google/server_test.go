@@ -9,7 +9,8 @@ import (
 )
 
 // newGoogleTestServer returns a new httptest.Server which mocks the Google
-// Userinfoplus endpoint and a client which proxies requests to the server.
+// Userinfoplus and JSON Web Key Set endpoints and a client which proxies
+// requests to the server.
 // The server responds with the given json data. The caller must close the
 // server.
 func newGoogleTestServer(jsonData string) (*http.Client, *httptest.Server) {
@@ -18,5 +19,9 @@ func newGoogleTestServer(jsonData string) (*http.Client, *httptest.Server) {
 		w.Header().Set("Content-Type", "application/json")
 		fmt.Fprintf(w, jsonData)
 	})
+	mux.HandleFunc("/oauth2/v3/certs", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, testJWKS())
+	})
 	return client, server
 }
@@ -0,0 +1,109 @@
+package google
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/internal"
+	"github.com/dghubble/gologin/internal/jsonclient"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"golang.org/x/oauth2"
+	google "google.golang.org/api/oauth2/v2"
+)
+
+const googleTokenInfoURL = "https://oauth2.googleapis.com/tokeninfo"
+
+// Google token errors
+var (
+	ErrUnableToGetTokenInfo  = errors.New("google: unable to get access token info")
+	ErrInvalidToken          = errors.New("google: access token is not valid")
+	ErrTokenAudienceMismatch = errors.New("google: access token was issued to a different client")
+)
+
+// tokenInfo is a Google tokeninfo response.
+type tokenInfo struct {
+	Audience string `json:"aud"`
+	Subject  string `json:"sub"`
+}
+
+// NewTokenVerifier returns an oauth2 TokenVerifier which checks that access
+// tokens were issued to the config ClientID with the tokeninfo endpoint and
+// gets their Userinfoplus. The Config HostedDomain (compared with the
+// userinfo hd field) and RequireVerifiedEmail are enforced. Use it with
+// oauth2 TokenHandler, which adds verified users to the ctx with WithUser.
+// To verify Sign In With Google id_token credentials instead, use
+// OneTapHandler.
+func NewTokenVerifier(config *oauth2.Config, googleConfig Config) oauth2Login.UserContextVerifier {
+	googleConfig.Transport = internal.TransportOrNew(googleConfig.Transport)
+	return &tokenVerifier{config: config, googleConfig: googleConfig}
+}
+
+// tokenVerifier verifies Google access tokens.
+type tokenVerifier struct {
+	config       *oauth2.Config
+	googleConfig Config
+}
+
+// VerifyToken checks the access token and returns its *Userinfoplus.
+func (v *tokenVerifier) VerifyToken(ctx context.Context, accessToken string) (interface{}, error) {
+	info, err := getTokenInfo(ctx, internal.TransportClient(ctx, v.googleConfig.Transport), accessToken)
+	if err != nil {
+		return nil, err
+	}
+	if info.Audience != v.config.ClientID {
+		return nil, ErrTokenAudienceMismatch
+	}
+	token := &oauth2.Token{AccessToken: accessToken, TokenType: "Bearer"}
+	googleService, err := google.New(internal.OAuth2TransportClient(ctx, v.googleConfig.Transport, v.config, token))
+	if err != nil {
+		return nil, err
+	}
+	fetchCtx, endFetch := gologin.StartUserFetch(ctx, ProviderName)
+	user, err := googleService.Userinfo.Get().Context(fetchCtx).Do()
+	err = validateResponse(user, err)
+	endFetch(err)
+	if err != nil {
+		return nil, err
+	}
+	if user.Id != info.Subject {
+		return nil, &gologin.Error{Provider: "google", Op: "get user", Kind: ErrCannotValidateGoogleUser}
+	}
+	if hd := v.googleConfig.HostedDomain; hd != "" && !strings.EqualFold(user.Hd, hd) {
+		return nil, ErrHostedDomainMismatch
+	}
+	if v.googleConfig.RequireVerifiedEmail && !emailVerified(user, nil) {
+		return nil, ErrEmailNotVerified
+	}
+	return user, nil
+}
+
+// WithUser adds the verified *Userinfoplus to the ctx.
+func (v *tokenVerifier) WithUser(ctx context.Context, user interface{}) context.Context {
+	if user, ok := user.(*google.Userinfoplus); ok {
+		return WithUser(ctx, user)
+	}
+	return ctx
+}
+
+// getTokenInfo gets the info of the access token from the tokeninfo
+// endpoint, which responds 400 to invalid or expired tokens.
+// https://developers.google.com/identity/sign-in/web/backend-auth
+func getTokenInfo(ctx context.Context, httpClient *http.Client, accessToken string) (*tokenInfo, error) {
+	info := new(tokenInfo)
+	resp, err := jsonclient.New(httpClient, googleTokenInfoURL).Get(ctx, "", url.Values{"access_token": {accessToken}}, info, nil)
+	var status int
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	if err == nil && status == http.StatusBadRequest {
+		return nil, ErrInvalidToken
+	}
+	if err != nil || status != http.StatusOK {
+		return nil, &gologin.Error{Provider: "google", Op: "get token info", StatusCode: status, Err: err, Kind: ErrUnableToGetTokenInfo}
+	}
+	return info, nil
+}
@@ -0,0 +1,107 @@
+package google
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dghubble/gologin"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+// newGoogleTokenServer returns a new httptest.Server which mocks the Google
+// tokeninfo and Userinfoplus endpoints and a client which proxies requests to
+// the server. The tokeninfo endpoint responds with the status and json data
+// and the userinfo endpoint with the user json data. The caller must close
+// the server.
+func newGoogleTokenServer(t *testing.T, status int, infoJSON, userJSON string) (*http.Client, *httptest.Server) {
+	client, mux, server := testutils.TestServer()
+	mux.HandleFunc("/tokeninfo", func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "mobile-token", req.URL.Query().Get("access_token"))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		fmt.Fprintf(w, infoJSON)
+	})
+	mux.HandleFunc("/oauth2/v2/userinfo", func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "Bearer mobile-token", req.Header.Get("Authorization"))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, userJSON)
+	})
+	return client, server
+}
+
+func TestTokenVerifier(t *testing.T) {
+	infoJSON := `{"aud": "client_id", "azp": "client_id", "sub": "900913", "expires_in": "3599", "scope": "openid email"}`
+	proxyClient, server := newGoogleTokenServer(t, http.StatusOK, infoJSON, `{"id": "900913", "name": "Ben Bitdiddle"}`)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+
+	config := &oauth2.Config{ClientID: "client_id"}
+	success := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "mobile-token", token.AccessToken)
+		}
+		user, err := UserFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "900913", user.Id)
+		}
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// oauth2 TokenHandler with a google TokenVerifier, assert that:
+	// - the access token audience is checked with tokeninfo
+	// - the Token and Userinfoplus are added to the ctx
+	handler := oauth2Login.TokenHandler(NewTokenVerifier(config, Config{}), http.HandlerFunc(success), failure)
+	req, _ := http.NewRequest("POST", "/", strings.NewReader(`{"access_token": "mobile-token"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestTokenVerifier_Errors(t *testing.T) {
+	validInfo := `{"aud": "client_id", "sub": "900913"}`
+	validUser := `{"id": "900913", "hd": "example.com", "verified_email": false}`
+	cases := []struct {
+		name         string
+		googleConfig Config
+		status       int
+		infoJSON     string
+		userJSON     string
+		err          error
+	}{
+		{"invalid token", Config{}, http.StatusBadRequest, `{"error": "invalid_token", "error_description": "Invalid Value"}`, validUser, ErrInvalidToken},
+		{"tokeninfo error", Config{}, http.StatusInternalServerError, `{}`, validUser, ErrUnableToGetTokenInfo},
+		{"other client", Config{}, http.StatusOK, `{"aud": "other_client", "sub": "900913"}`, validUser, ErrTokenAudienceMismatch},
+		{"other user", Config{}, http.StatusOK, `{"aud": "client_id", "sub": "123"}`, validUser, ErrCannotValidateGoogleUser},
+		{"hosted domain", Config{HostedDomain: "other.example.com"}, http.StatusOK, validInfo, validUser, ErrHostedDomainMismatch},
+		{"unverified email", Config{RequireVerifiedEmail: true}, http.StatusOK, validInfo, validUser, ErrEmailNotVerified},
+	}
+	config := &oauth2.Config{ClientID: "client_id"}
+	for _, c := range cases {
+		proxyClient, server := newGoogleTokenServer(t, c.status, c.infoJSON, c.userJSON)
+		ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+
+		// TokenVerifier with a rejected token, assert that:
+		// - the error describes why the token was rejected
+		user, err := NewTokenVerifier(config, c.googleConfig).VerifyToken(ctx, "mobile-token")
+		assert.Nil(t, user, c.name)
+		assert.True(t, errors.Is(err, c.err), c.name)
+		var providerErr *gologin.Error
+		if errors.As(err, &providerErr) && providerErr.StatusCode != 0 {
+			assert.Equal(t, c.status, providerErr.StatusCode, c.name)
+		}
+		server.Close()
+	}
+}
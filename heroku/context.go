@@ -0,0 +1,46 @@
+package heroku
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dghubble/gologin"
+)
+
+// unexported key type prevents collisions
+type key int
+
+const (
+	userKey key = iota
+)
+
+// WithUser returns a copy of ctx that stores the Heroku User and its gologin
+// Profile.
+func WithUser(ctx context.Context, user *User) context.Context {
+	ctx = gologin.WithProfile(ctx, newProfile(user))
+	return context.WithValue(ctx, userKey, user)
+}
+
+// UserFromContext returns the Heroku User from the ctx.
+func UserFromContext(ctx context.Context) (*User, error) {
+	user, ok := ctx.Value(userKey).(*User)
+	if !ok {
+		return nil, fmt.Errorf("heroku: Context missing Heroku User")
+	}
+	return user, nil
+}
+
+// newProfile returns the gologin Profile of the Heroku User.
+func newProfile(user *User) *gologin.Profile {
+	if user == nil {
+		return nil
+	}
+	return &gologin.Profile{
+		Provider:      ProviderName,
+		ID:            user.ID,
+		Email:         user.Email,
+		EmailVerified: user.Verified,
+		Name:          user.Name,
+		Raw:           gologin.ProfileRaw(user, "id", "email", "verified", "name"),
+	}
+}
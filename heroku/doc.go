@@ -0,0 +1,2 @@
+// Package heroku provides Heroku OAuth2 login and callback handlers.
+package heroku
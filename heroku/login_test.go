@@ -0,0 +1,150 @@
+package heroku
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dghubble/gologin"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func testConfig() *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     "client_id",
+		ClientSecret: "client_secret",
+		RedirectURL:  "https://example.com/heroku/callback",
+		Endpoint:     Endpoint,
+		Scopes:       []string{"identity"},
+	}
+}
+
+func TestCallbackHandler(t *testing.T) {
+	proxyClient, server := newHerokuTestServer(testAccountJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithState(ctx, "d4e5f6")
+
+	expectedUser := &User{
+		ID:                  "01234567-89ab-cdef-0123-456789abcdef",
+		Email:               "username@example.com",
+		Name:                "Tina Edmonds",
+		Verified:            true,
+		DefaultOrganization: &Organization{ID: "fedcba98-7654-3210-fedc-ba9876543210", Name: "example"},
+	}
+	success := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "any-token", token.AccessToken)
+			assert.Equal(t, "any-refresh", token.RefreshToken)
+			assert.WithinDuration(t, time.Now().Add(8*time.Hour), token.Expiry, time.Minute)
+		}
+		user, err := UserFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, expectedUser, user)
+		}
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// CallbackHandler assert that:
+	// - the account is requested with the version 3 Accept header
+	// - success handler is called
+	// - Heroku Token (with refresh token and expiry) and User are added to
+	// the ctx of the success handler
+	callbackHandler := CallbackHandler(testConfig(), http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestHerokuHandler_MissingCtxToken(t *testing.T) {
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "oauth2: Context missing Token", err.Error())
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// HerokuHandler called without Token in ctx, assert that:
+	// - failure handler is called
+	// - error about ctx missing token is added to the failure handler ctx
+	herokuHandler := herokuHandler(testConfig(), success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	herokuHandler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestHerokuHandler_ErrorGettingUser(t *testing.T) {
+	proxyClient, server := testutils.NewErrorServer("Heroku Service Down", http.StatusInternalServerError)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.True(t, errors.Is(err, ErrUnableToGetHerokuUser))
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// HerokuHandler cannot get Heroku User, assert that:
+	// - failure handler is called
+	// - error cannot get Heroku User added to the failure handler ctx
+	herokuHandler := herokuHandler(testConfig(), success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	herokuHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestAccount_NotAcceptable(t *testing.T) {
+	proxyClient, server := newHerokuTestServer(testAccountJSON)
+	defer server.Close()
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	httpClient := testConfig().Client(ctx, &oauth2.Token{AccessToken: "any-token"})
+
+	// Account request without the version 3 Accept header, assert that:
+	// - Heroku responds 406 Not Acceptable
+	// - validateResponse returns ErrUnableToGetHerokuUser with the status and
+	// the Heroku APIError as the cause
+	c := newClient(httpClient)
+	c.json.Set("Accept", "application/json")
+	user, resp, err := c.Account(ctx)
+	err = validateResponse(user, resp, err)
+	assert.True(t, errors.Is(err, ErrUnableToGetHerokuUser))
+	var loginErr *gologin.Error
+	if assert.True(t, errors.As(err, &loginErr)) {
+		assert.Equal(t, http.StatusNotAcceptable, loginErr.StatusCode)
+	}
+	var apiErr *APIError
+	if assert.True(t, errors.As(err, &apiErr)) {
+		assert.Equal(t, "not_acceptable", apiErr.ID)
+	}
+}
+
+func TestValidateResponse(t *testing.T) {
+	validUser := &User{ID: "01234567-89ab-cdef-0123-456789abcdef", Email: "username@example.com"}
+	validResponse := &http.Response{StatusCode: 200}
+	invalidResponse := &http.Response{StatusCode: 500}
+	assert.Equal(t, nil, validateResponse(validUser, validResponse, nil))
+	assert.True(t, errors.Is(validateResponse(validUser, validResponse, fmt.Errorf("Server error")), ErrUnableToGetHerokuUser))
+	assert.True(t, errors.Is(validateResponse(validUser, invalidResponse, nil), ErrUnableToGetHerokuUser))
+	assert.True(t, errors.Is(validateResponse(&User{Email: "username@example.com"}, validResponse, nil), ErrUnableToGetHerokuUser))
+}
@@ -0,0 +1,40 @@
+package heroku
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/dghubble/gologin/testutils"
+)
+
+const (
+	// testAccountJSON is an account response.
+	testAccountJSON = `{"id": "01234567-89ab-cdef-0123-456789abcdef", "email": "username@example.com", "name": "Tina Edmonds", "verified": true, "default_organization": {"id": "fedcba98-7654-3210-fedc-ba9876543210", "name": "example"}, "two_factor_authentication": false}`
+	// testNotAcceptableJSON is the Heroku error response to requests without
+	// the version 3 Accept header.
+	testNotAcceptableJSON = `{"id": "not_acceptable", "message": "Accept header must include a version, e.g. application/vnd.heroku+json; version=3"}`
+)
+
+// newHerokuTestServer returns a new httptest.Server which mocks the Heroku
+// token and account endpoints and a client which proxies requests to the
+// server. Like Heroku, the account endpoint responds 406 Not Acceptable to
+// requests without the version 3 Accept header. Otherwise, it responds with
+// the given json data. The caller must close the server.
+func newHerokuTestServer(jsonData string) (*http.Client, *httptest.Server) {
+	client, mux, server := testutils.TestServer()
+	mux.HandleFunc("/oauth/token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token": "any-token", "expires_in": 28799, "refresh_token": "any-refresh", "token_type": "Bearer", "user_id": "01234567-89ab-cdef-0123-456789abcdef", "session_nonce": "2bf3ec81"}`)
+	})
+	mux.HandleFunc("/account", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Header.Get("Accept") != "application/vnd.heroku+json; version=3" {
+			w.WriteHeader(http.StatusNotAcceptable)
+			fmt.Fprintf(w, testNotAcceptableJSON)
+			return
+		}
+		fmt.Fprintf(w, jsonData)
+	})
+	return client, server
+}
@@ -0,0 +1,65 @@
+package heroku
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/dghubble/gologin/internal/jsonclient"
+)
+
+const (
+	herokuAPI = "https://api.heroku.com/"
+	// acceptV3 selects version 3 of the Heroku Platform API. Requests without
+	// it fail with 406 Not Acceptable.
+	acceptV3 = "application/vnd.heroku+json; version=3"
+)
+
+// User is a Heroku account.
+type User struct {
+	ID                  string        `json:"id"`
+	Email               string        `json:"email"`
+	Name                string        `json:"name"`
+	Verified            bool          `json:"verified"`
+	DefaultOrganization *Organization `json:"default_organization"`
+}
+
+// Organization is a Heroku team (formerly organization).
+type Organization struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// APIError is a Heroku Platform API error response.
+type APIError struct {
+	ID      string `json:"id"`
+	Message string `json:"message"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("heroku: %s (%s)", e.Message, e.ID)
+}
+
+// client is a Heroku client for obtaining a User.
+type client struct {
+	json *jsonclient.Client
+}
+
+// newClient returns a new Heroku client.
+func newClient(httpClient *http.Client) *client {
+	return &client{
+		json: jsonclient.New(httpClient, herokuAPI).Set("Accept", acceptV3),
+	}
+}
+
+// Account gets the current Heroku User.
+// https://devcenter.heroku.com/articles/platform-api-reference#account-info-by-user
+func (c *client) Account(ctx context.Context) (*User, *http.Response, error) {
+	user := new(User)
+	apiErr := new(APIError)
+	resp, err := c.json.Get(ctx, "account", nil, user, apiErr)
+	if err == nil && apiErr.Message != "" {
+		err = apiErr
+	}
+	return user, resp, err
+}
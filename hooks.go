@@ -0,0 +1,265 @@
+package gologin
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Operations started by Hooks StartOp
+const (
+	OpExchange  = "exchange"
+	OpUserFetch = "userfetch"
+)
+
+// Hooks are callbacks which observe login lifecycle events (e.g. to count
+// redirects and callback outcomes). Any callback may be nil. Hooks run on the
+// request goroutine and cannot affect the login flow: panics are recovered.
+type Hooks struct {
+	// OnLoginRedirect is called when a login handler redirects the request to
+	// the provider's authorization URL.
+	OnLoginRedirect func(ctx context.Context, provider string, req *http.Request)
+	// StartOp is called before a token exchange (OpExchange) or provider
+	// user request (OpUserFetch). The returned ctx is used for the
+	// operation's requests (e.g. to parent trace spans) and end is called
+	// with the operation's error.
+	StartOp func(ctx context.Context, provider, op string) (opCtx context.Context, end func(err error))
+	// OnTokenExchange is called after an OAuth2 code exchange or OAuth1
+	// access token request with its duration and error, if any.
+	OnTokenExchange func(ctx context.Context, provider string, duration time.Duration, err error)
+	// OnUserFetch is called after a provider user request with its duration
+	// and error, if any.
+	OnUserFetch func(ctx context.Context, provider string, duration time.Duration, err error)
+	// OnCallbackSuccess is called when a provider callback chain succeeds,
+	// with the provider user ID (if known), before the success handler.
+	OnCallbackSuccess func(ctx context.Context, provider, userID string)
+	// OnCallbackFailure is called with the error when a provider callback
+	// chain fails, before the failure handler.
+	OnCallbackFailure func(ctx context.Context, provider string, err error)
+}
+
+// WithHooks returns a copy of ctx that stores the Hooks.
+func WithHooks(ctx context.Context, hooks *Hooks) context.Context {
+	return context.WithValue(ctx, hooksKey, hooks)
+}
+
+// HooksFromContext returns the Hooks from the ctx or nil if there are none.
+func HooksFromContext(ctx context.Context) *Hooks {
+	hooks, _ := ctx.Value(hooksKey).(*Hooks)
+	return hooks
+}
+
+// HooksHandler adds the Hooks to the ctx so the login and callback handlers of
+// the next http.Handler report lifecycle events to them.
+func HooksHandler(hooks *Hooks, next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := WithHooks(req.Context(), hooks)
+		next.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// WithProvider returns a copy of ctx that stores the provider name reported
+// to Hooks. Provider callbacks store their name (e.g. "github") before calling
+// success handlers, so handlers shared by providers can tell them apart.
+func WithProvider(ctx context.Context, provider string) context.Context {
+	return context.WithValue(ctx, providerKey, provider)
+}
+
+// ProviderFromContext returns the provider name from the ctx or the given
+// default if there is none.
+func ProviderFromContext(ctx context.Context, defaultProvider string) string {
+	if provider, ok := ctx.Value(providerKey).(string); ok {
+		return provider
+	}
+	return defaultProvider
+}
+
+// ProviderHandler adds the provider name to the ctx so generic oauth1 and
+// oauth2 handlers report events to Hooks as that provider.
+func ProviderHandler(provider string, next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := WithProvider(req.Context(), provider)
+		next.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// CallbackHooks wraps the success and failure handlers of a provider callback
+// chain to report OnCallbackSuccess, with the user ID returned by userID, and
+// OnCallbackFailure to the ctx Hooks. Without Hooks, the handlers are called
+// directly.
+func CallbackHooks(provider string, userID func(ctx context.Context) string, success, failure http.Handler) (http.Handler, http.Handler) {
+	if failure == nil {
+		failure = DefaultFailureHandler
+	}
+	reportSuccess := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		if hooks := HooksFromContext(ctx); hooks != nil && hooks.OnCallbackSuccess != nil {
+			safeCall(func() { hooks.OnCallbackSuccess(ctx, provider, userID(ctx)) })
+		}
+		success.ServeHTTP(w, req)
+	}
+	reportFailure := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		if hooks := HooksFromContext(ctx); hooks != nil && hooks.OnCallbackFailure != nil {
+			safeCall(func() { hooks.OnCallbackFailure(ctx, provider, ErrorFromContext(ctx)) })
+		}
+		failure.ServeHTTP(w, req)
+	}
+	return http.HandlerFunc(reportSuccess), http.HandlerFunc(reportFailure)
+}
+
+// ReportLoginRedirect reports OnLoginRedirect to the ctx Hooks, if any.
+func ReportLoginRedirect(ctx context.Context, defaultProvider string, req *http.Request) {
+	if hooks := HooksFromContext(ctx); hooks != nil && hooks.OnLoginRedirect != nil {
+		safeCall(func() { hooks.OnLoginRedirect(ctx, ProviderFromContext(ctx, defaultProvider), req) })
+	}
+}
+
+// StartTokenExchange starts a token exchange operation (see Hooks StartOp)
+// and returns the ctx for the exchange requests and a func to call with the
+// exchange error, which ends the operation and reports OnTokenExchange. If
+// the ctx has a timeout (see WithTimeout), the exchange ctx is canceled after
+// the timeout or when the operation ends.
+func StartTokenExchange(ctx context.Context, defaultProvider string) (context.Context, func(err error)) {
+	return startOp(ctx, defaultProvider, OpExchange)
+}
+
+// StartUserFetch starts a provider user request operation (see Hooks
+// StartOp) and returns the ctx for the user requests and a func to call with
+// the request error, which ends the operation and reports OnUserFetch. If the
+// ctx has a timeout (see WithTimeout), the user request ctx is canceled after
+// the timeout or when the operation ends.
+func StartUserFetch(ctx context.Context, defaultProvider string) (context.Context, func(err error)) {
+	return startOp(ctx, defaultProvider, OpUserFetch)
+}
+
+func startOp(ctx context.Context, defaultProvider, op string) (context.Context, func(err error)) {
+	ctx, cancel := withOpTimeout(ctx)
+	hooks := HooksFromContext(ctx)
+	if hooks == nil {
+		return ctx, func(err error) { cancel() }
+	}
+	provider := ProviderFromContext(ctx, defaultProvider)
+	start := time.Now()
+	opCtx, end := ctx, endNoop
+	if hooks.StartOp != nil {
+		safeCall(func() {
+			c, e := hooks.StartOp(ctx, provider, op)
+			if c != nil {
+				opCtx = c
+			}
+			if e != nil {
+				end = e
+			}
+		})
+	}
+	observe := hooks.OnTokenExchange
+	if op == OpUserFetch {
+		observe = hooks.OnUserFetch
+	}
+	return opCtx, func(err error) {
+		duration := time.Since(start)
+		safeCall(func() { end(err) })
+		if observe != nil {
+			safeCall(func() { observe(ctx, provider, duration, err) })
+		}
+		cancel()
+	}
+}
+
+// withOpTimeout returns a copy of ctx which is canceled after the ctx timeout
+// (see WithTimeout), if any, and its cancel func.
+func withOpTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if timeout, ok := ctx.Value(timeoutKey).(time.Duration); ok && timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return ctx, func() {}
+}
+
+func endNoop(err error) {}
+
+// CombineHooks returns Hooks which call each of the (non-nil) Hooks in order.
+// StartOp ctxs are chained, so each StartOp sees the ctx of the previous one.
+func CombineHooks(hooks ...*Hooks) *Hooks {
+	var all []*Hooks
+	for _, h := range hooks {
+		if h != nil {
+			all = append(all, h)
+		}
+	}
+	switch len(all) {
+	case 0:
+		return nil
+	case 1:
+		return all[0]
+	}
+	return &Hooks{
+		OnLoginRedirect: func(ctx context.Context, provider string, req *http.Request) {
+			for _, h := range all {
+				if h.OnLoginRedirect != nil {
+					safeCall(func() { h.OnLoginRedirect(ctx, provider, req) })
+				}
+			}
+		},
+		StartOp: func(ctx context.Context, provider, op string) (context.Context, func(err error)) {
+			var ends []func(err error)
+			for _, h := range all {
+				if h.StartOp != nil {
+					safeCall(func() {
+						c, end := h.StartOp(ctx, provider, op)
+						if c != nil {
+							ctx = c
+						}
+						if end != nil {
+							ends = append(ends, end)
+						}
+					})
+				}
+			}
+			return ctx, func(err error) {
+				for i := len(ends) - 1; i >= 0; i-- {
+					safeCall(func() { ends[i](err) })
+				}
+			}
+		},
+		OnTokenExchange: func(ctx context.Context, provider string, duration time.Duration, err error) {
+			for _, h := range all {
+				if h.OnTokenExchange != nil {
+					safeCall(func() { h.OnTokenExchange(ctx, provider, duration, err) })
+				}
+			}
+		},
+		OnUserFetch: func(ctx context.Context, provider string, duration time.Duration, err error) {
+			for _, h := range all {
+				if h.OnUserFetch != nil {
+					safeCall(func() { h.OnUserFetch(ctx, provider, duration, err) })
+				}
+			}
+		},
+		OnCallbackSuccess: func(ctx context.Context, provider, userID string) {
+			for _, h := range all {
+				if h.OnCallbackSuccess != nil {
+					safeCall(func() { h.OnCallbackSuccess(ctx, provider, userID) })
+				}
+			}
+		},
+		OnCallbackFailure: func(ctx context.Context, provider string, err error) {
+			for _, h := range all {
+				if h.OnCallbackFailure != nil {
+					safeCall(func() { h.OnCallbackFailure(ctx, provider, err) })
+				}
+			}
+		},
+	}
+}
+
+// safeCall calls the hook, recovering any panic so hooks cannot break the
+// login flow.
+func safeCall(hook func()) {
+	defer func() {
+		recover()
+	}()
+	hook()
+}
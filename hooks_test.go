@@ -0,0 +1,134 @@
+package gologin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHooksFromContext(t *testing.T) {
+	hooks := &Hooks{}
+	assert.Nil(t, HooksFromContext(context.Background()))
+	assert.Equal(t, hooks, HooksFromContext(WithHooks(context.Background(), hooks)))
+}
+
+func TestProviderFromContext(t *testing.T) {
+	assert.Equal(t, "oauth2", ProviderFromContext(context.Background(), "oauth2"))
+	assert.Equal(t, "facebook", ProviderFromContext(WithProvider(context.Background(), "facebook"), "oauth2"))
+}
+
+func TestCallbackHooks(t *testing.T) {
+	var events []string
+	hooks := &Hooks{
+		OnCallbackSuccess: func(ctx context.Context, provider, userID string) {
+			events = append(events, fmt.Sprintf("success %s %s", provider, userID))
+		},
+		OnCallbackFailure: func(ctx context.Context, provider string, err error) {
+			events = append(events, fmt.Sprintf("failure %s %v", provider, err))
+		},
+	}
+	userID := func(ctx context.Context) string { return "42" }
+	next := func(w http.ResponseWriter, req *http.Request) {
+		events = append(events, "next")
+	}
+	success, failure := CallbackHooks("example", userID, http.HandlerFunc(next), http.HandlerFunc(next))
+
+	// CallbackHooks assert that:
+	// - the outcome is reported to the ctx Hooks before the next handler
+	req, _ := http.NewRequest("GET", "/", nil)
+	ctx := WithHooks(context.Background(), hooks)
+	success.ServeHTTP(httptest.NewRecorder(), req.WithContext(ctx))
+	failure.ServeHTTP(httptest.NewRecorder(), req.WithContext(WithError(ctx, errors.New("some error"))))
+	assert.Equal(t, []string{"success example 42", "next", "failure example some error", "next"}, events)
+
+	// - without Hooks, the next handlers are called directly
+	events = nil
+	success.ServeHTTP(httptest.NewRecorder(), req)
+	failure.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, []string{"next", "next"}, events)
+}
+
+func TestHooks_Panics(t *testing.T) {
+	hooks := &Hooks{
+		OnLoginRedirect: func(ctx context.Context, provider string, req *http.Request) {
+			panic("hook panic")
+		},
+		StartOp: func(ctx context.Context, provider, op string) (context.Context, func(err error)) {
+			panic("hook panic")
+		},
+		OnTokenExchange: func(ctx context.Context, provider string, duration time.Duration, err error) {
+			panic("hook panic")
+		},
+		OnCallbackSuccess: func(ctx context.Context, provider, userID string) {
+			panic("hook panic")
+		},
+	}
+	called := false
+	next := func(w http.ResponseWriter, req *http.Request) {
+		called = true
+	}
+	success, _ := CallbackHooks("example", func(ctx context.Context) string { return "" }, http.HandlerFunc(next), nil)
+
+	// Hooks which panic, assert that:
+	// - panics are recovered
+	// - the next handler is still called
+	req, _ := http.NewRequest("GET", "/", nil)
+	ctx := WithHooks(context.Background(), hooks)
+	assert.NotPanics(t, func() {
+		ReportLoginRedirect(ctx, "oauth2", req)
+		exchangeCtx, end := StartTokenExchange(ctx, "oauth2")
+		assert.Equal(t, ctx, exchangeCtx)
+		end(nil)
+		success.ServeHTTP(httptest.NewRecorder(), req.WithContext(ctx))
+	})
+	assert.True(t, called)
+}
+
+type opKey string
+
+func TestStartOp(t *testing.T) {
+	var events []string
+	recording := func(name string) *Hooks {
+		return &Hooks{
+			StartOp: func(ctx context.Context, provider, op string) (context.Context, func(err error)) {
+				events = append(events, fmt.Sprintf("%s start %s %s", name, provider, op))
+				return context.WithValue(ctx, opKey(name), op), func(err error) {
+					events = append(events, fmt.Sprintf("%s end %v", name, err))
+				}
+			},
+			OnUserFetch: func(ctx context.Context, provider string, duration time.Duration, err error) {
+				events = append(events, fmt.Sprintf("%s userfetch %s", name, provider))
+			},
+		}
+	}
+	ctx := WithHooks(WithProvider(context.Background(), "example"), CombineHooks(recording("a"), nil, recording("b")))
+
+	// StartUserFetch with combined Hooks, assert that:
+	// - StartOp ctxs are chained and ends are called in reverse order
+	// - OnUserFetch is reported after the operation ends
+	fetchCtx, end := StartUserFetch(ctx, "oauth2")
+	assert.Equal(t, OpUserFetch, fetchCtx.Value(opKey("a")))
+	assert.Equal(t, OpUserFetch, fetchCtx.Value(opKey("b")))
+	end(errors.New("some error"))
+	expected := []string{
+		"a start example userfetch",
+		"b start example userfetch",
+		"b end some error",
+		"a end some error",
+		"a userfetch example",
+		"b userfetch example",
+	}
+	assert.Equal(t, expected, events)
+
+	// - without Hooks, the ctx is returned as is
+	fetchCtx, end = StartUserFetch(context.Background(), "oauth2")
+	assert.Equal(t, context.Background(), fetchCtx)
+	end(nil)
+	assert.Nil(t, CombineHooks(nil, nil))
+}
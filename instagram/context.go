@@ -0,0 +1,59 @@
+package instagram
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dghubble/gologin"
+)
+
+// unexported key type prevents collisions
+type key int
+
+const (
+	userKey key = iota
+	tokenExchangeErrorKey
+)
+
+// WithUser returns a copy of ctx that stores the Instagram User and its gologin
+// Profile.
+func WithUser(ctx context.Context, user *User) context.Context {
+	ctx = gologin.WithProfile(ctx, newProfile(user))
+	return context.WithValue(ctx, userKey, user)
+}
+
+// UserFromContext returns the Instagram User from the ctx.
+func UserFromContext(ctx context.Context) (*User, error) {
+	user, ok := ctx.Value(userKey).(*User)
+	if !ok {
+		return nil, fmt.Errorf("instagram: Context missing Instagram User")
+	}
+	return user, nil
+}
+
+// newProfile returns the gologin Profile of the Instagram User.
+func newProfile(user *User) *gologin.Profile {
+	if user == nil {
+		return nil
+	}
+	return &gologin.Profile{
+		Provider: ProviderName,
+		ID:       user.ID,
+		Name:     user.Username,
+		Raw:      gologin.ProfileRaw(user, "id", "username"),
+	}
+}
+
+// WithTokenExchangeError returns a copy of ctx that stores the error of a
+// failed long-lived Token exchange.
+func WithTokenExchangeError(ctx context.Context, err error) context.Context {
+	return context.WithValue(ctx, tokenExchangeErrorKey, err)
+}
+
+// TokenExchangeErrorFromContext returns the error of a failed long-lived
+// Token exchange from the ctx or nil if the exchange succeeded (or was not
+// attempted).
+func TokenExchangeErrorFromContext(ctx context.Context) error {
+	err, _ := ctx.Value(tokenExchangeErrorKey).(error)
+	return err
+}
@@ -0,0 +1,3 @@
+// Package instagram provides Instagram (Basic Display API) OAuth2 login and
+// callback handlers.
+package instagram
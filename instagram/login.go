@@ -0,0 +1,124 @@
+package instagram
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/internal"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"golang.org/x/oauth2"
+)
+
+// ProviderName is the provider name set in the ctx (see
+// gologin.ProviderFromContext) and the Profile.
+const ProviderName = "instagram"
+
+// Instagram login errors
+var (
+	ErrUnableToGetInstagramUser = errors.New("instagram: unable to get Instagram User")
+)
+
+// Endpoint is Instagram's (Basic Display API) OAuth2 endpoint.
+var Endpoint = oauth2.Endpoint{
+	AuthURL:   "https://api.instagram.com/oauth/authorize",
+	TokenURL:  "https://api.instagram.com/oauth/access_token",
+	AuthStyle: oauth2.AuthStyleInParams,
+}
+
+// Config configures Instagram login.
+type Config struct {
+	// LongLivedToken exchanges the short-lived callback Token for a
+	// long-lived Token before fetching the User. See LongLivedTokenHandler.
+	LongLivedToken bool
+}
+
+// StateHandler checks for a state cookie. If found, the state value is read
+// and added to the ctx. Otherwise, a non-guessable value is added to the ctx
+// and to a (short-lived) state cookie issued to the requester.
+//
+// Implements OAuth 2 RFC 6749 10.12 CSRF Protection. If you wish to issue
+// state params differently, write a http.Handler which sets the ctx state,
+// using oauth2 WithState(ctx, state) since it is required by LoginHandler
+// and CallbackHandler.
+func StateHandler(config gologin.CookieConfig, success http.Handler) http.Handler {
+	return oauth2Login.StateHandler(config, success)
+}
+
+// LoginHandler handles Instagram login requests by reading the state value
+// from the ctx and redirecting requests to the AuthURL with that state value.
+// Any AuthCodeOptions are added to the AuthURL.
+//
+// Scopes should include "user_profile" to get the Instagram User.
+func LoginHandler(config *oauth2.Config, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	return oauth2Login.LoginHandler(config, failure, opts...)
+}
+
+// CallbackHandler handles Instagram redirection URI requests and adds the
+// Instagram access token and User to the ctx. If authentication succeeds,
+// handling delegates to the success handler, otherwise to the failure
+// handler.
+// Any AuthCodeOptions are sent with the token exchange.
+func CallbackHandler(config *oauth2.Config, success, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	return CallbackHandlerWithConfig(config, Config{}, success, failure, opts...)
+}
+
+// CallbackHandlerWithConfig handles Instagram redirection URI requests like
+// CallbackHandler, but exchanges the Token per the Config. If the Graph API
+// rejects the User request, the failure handler's error wraps the
+// *GraphError (see IsInvalidToken).
+func CallbackHandlerWithConfig(config *oauth2.Config, igConfig Config, success, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	// [LongLivedTokenHandler] -> instagramHandler -> success
+	success = instagramHandler(success, failure)
+	if igConfig.LongLivedToken {
+		success = LongLivedTokenHandler(config, success)
+	}
+	return oauth2Login.CallbackHandler(config, success, failure, opts...)
+}
+
+// instagramHandler is a http.Handler that gets the OAuth2 Token from the ctx
+// to get the corresponding Instagram User. If successful, the User is added to
+// the ctx and the success handler is called. Otherwise, the failure handler is
+// called.
+func instagramHandler(success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		// Instagram Graph API requests pass the access_token as a parameter
+		user, resp, err := newClient(internal.ContextClient(ctx)).Me(ctx, token.AccessToken)
+		err = validateResponse(user, resp, err)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		ctx = WithUser(ctx, user)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// validateResponse returns an error if the given Instagram User, raw
+// http.Response, or error are unexpected. Returns nil if they are valid, or a
+// *gologin.Error which preserves the cause and status code.
+func validateResponse(user *User, resp *http.Response, err error) error {
+	var status int
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	if err != nil || status != http.StatusOK {
+		return &gologin.Error{Provider: "instagram", Op: "get user", StatusCode: status, Err: err, Kind: ErrUnableToGetInstagramUser}
+	}
+	if user == nil || user.ID == "" {
+		return &gologin.Error{Provider: "instagram", Op: "get user", StatusCode: status, Kind: ErrUnableToGetInstagramUser}
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package instagram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dghubble/gologin"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+const testUserJSON = `{"id": "17841405793187218", "username": "jayposiris", "account_type": "PERSONAL", "media_count": 42}`
+
+func testConfig() *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     "client_id",
+		ClientSecret: "client_secret",
+		RedirectURL:  "https://example.com/instagram/callback",
+		Endpoint:     Endpoint,
+		Scopes:       []string{"user_profile"},
+	}
+}
+
+func TestCallbackHandler(t *testing.T) {
+	proxyClient, server := newInstagramTestServer(http.StatusOK, testUserJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithState(ctx, "d4e5f6")
+
+	success := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "any-token", token.AccessToken)
+		}
+		user, err := UserFromContext(ctx)
+		if assert.Nil(t, err) {
+			expectedUser := &User{ID: "17841405793187218", Username: "jayposiris", AccountType: "PERSONAL", MediaCount: 42}
+			assert.Equal(t, expectedUser, user)
+		}
+		assert.Nil(t, TokenExchangeErrorFromContext(ctx))
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// CallbackHandler assert that:
+	// - the token response with a numeric user_id is accepted
+	// - the Instagram User is obtained from /me with the access_token param
+	// - success handler is called with the Token and User in the ctx
+	callbackHandler := CallbackHandler(testConfig(), http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestCallbackHandlerWithConfig_LongLivedToken(t *testing.T) {
+	proxyClient, server := newInstagramTestServer(http.StatusOK, testUserJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithState(ctx, "d4e5f6")
+
+	success := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "long-token", token.AccessToken)
+			assert.WithinDuration(t, time.Now().Add(5183944*time.Second), token.Expiry, time.Minute)
+		}
+		_, err = UserFromContext(ctx)
+		assert.Nil(t, err)
+		assert.Nil(t, TokenExchangeErrorFromContext(ctx))
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// CallbackHandlerWithConfig with LongLivedToken, assert that:
+	// - the short-lived Token is exchanged with ig_exchange_token
+	// - success handler is called with the long-lived Token and User in the ctx
+	callbackHandler := CallbackHandlerWithConfig(testConfig(), Config{LongLivedToken: true}, http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestInstagramHandler_GraphError(t *testing.T) {
+	proxyClient, server := newInstagramTestServer(http.StatusOK, testUserJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "revoked-token"})
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.True(t, errors.Is(err, ErrUnableToGetInstagramUser))
+			assert.True(t, IsInvalidToken(err))
+			var graphErr *GraphError
+			if assert.True(t, errors.As(err, &graphErr)) {
+				assert.Equal(t, &GraphError{Message: "Invalid OAuth access token.", Type: "OAuthException", Code: 190, FBTraceID: "A8mDyu2LUIA"}, graphErr)
+				assert.Equal(t, "instagram: Invalid OAuth access token. (OAuthException, code 190, fbtrace_id A8mDyu2LUIA)", graphErr.Error())
+			}
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// InstagramHandler with an invalid Token, assert that:
+	// - failure handler is called
+	// - the error wraps the Graph API *GraphError
+	instagramHandler := instagramHandler(success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	instagramHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestInstagramHandler_MissingCtxToken(t *testing.T) {
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "oauth2: Context missing Token", err.Error())
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// InstagramHandler called without Token in ctx, assert that:
+	// - failure handler is called
+	// - error about ctx missing token is added to the failure handler ctx
+	instagramHandler := instagramHandler(success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	instagramHandler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestInstagramHandler_ErrorGettingUser(t *testing.T) {
+	proxyClient, server := testutils.NewErrorServer("Instagram Service Down", http.StatusInternalServerError)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.True(t, errors.Is(err, ErrUnableToGetInstagramUser))
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// InstagramHandler cannot get Instagram User, assert that:
+	// - failure handler is called
+	// - error cannot get Instagram User added to the failure handler ctx
+	instagramHandler := instagramHandler(success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	instagramHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestValidateResponse(t *testing.T) {
+	validUser := &User{ID: "17841405793187218"}
+	validResponse := &http.Response{StatusCode: 200}
+	invalidResponse := &http.Response{StatusCode: 500}
+	assert.Equal(t, nil, validateResponse(validUser, validResponse, nil))
+	assert.True(t, errors.Is(validateResponse(validUser, validResponse, fmt.Errorf("Server error")), ErrUnableToGetInstagramUser))
+	assert.True(t, errors.Is(validateResponse(validUser, invalidResponse, nil), ErrUnableToGetInstagramUser))
+	assert.True(t, errors.Is(validateResponse(&User{}, validResponse, nil), ErrUnableToGetInstagramUser))
+	assert.True(t, errors.Is(validateResponse(nil, validResponse, nil), ErrUnableToGetInstagramUser))
+}
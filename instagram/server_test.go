@@ -0,0 +1,60 @@
+package instagram
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/dghubble/gologin/testutils"
+)
+
+// testTokenJSON is an Instagram token response, whose user_id is a number
+// (too large for a float64) rather than a string.
+const testTokenJSON = `{"access_token": "any-token", "user_id": 17841405793187218}`
+
+// newInstagramTestServer returns a new httptest.Server which mocks the
+// Instagram access_token and Graph API /me and long-lived token exchange
+// endpoints and a client which proxies requests to the server. The /me
+// endpoint responds with the given status and json data for the access_token
+// "any-token" or "long-token". The caller must close the server.
+func newInstagramTestServer(status int, jsonData string) (*http.Client, *httptest.Server) {
+	client, mux, server := testutils.TestServer()
+	mux.HandleFunc("/oauth/access_token", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.PostFormValue("grant_type") != "authorization_code" || r.PostFormValue("client_secret") != "client_secret" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, `{"error_type": "OAuthException", "code": 400, "error_message": "Invalid client_secret"}`)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, testTokenJSON)
+	})
+	mux.HandleFunc("/access_token", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("grant_type") != "ig_exchange_token" || query.Get("client_secret") != "client_secret" || query.Get("access_token") != "any-token" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, `{"error": {"message": "Error validating client secret.", "type": "OAuthException", "code": 1, "fbtrace_id": "A8mDyu2LUIA"}}`)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token": "long-token", "token_type": "bearer", "expires_in": 5183944}`)
+	})
+	mux.HandleFunc("/me", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("fields") != "id,username,account_type,media_count" {
+			http.Error(w, "unexpected fields", http.StatusBadRequest)
+			return
+		}
+		if token := query.Get("access_token"); token != "any-token" && token != "long-token" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, `{"error": {"message": "Invalid OAuth access token.", "type": "OAuthException", "code": 190, "fbtrace_id": "A8mDyu2LUIA"}}`)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		fmt.Fprintf(w, jsonData)
+	})
+	return client, server
+}
@@ -0,0 +1,92 @@
+package instagram
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/internal"
+	"github.com/dghubble/gologin/internal/jsonclient"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"golang.org/x/oauth2"
+)
+
+// Instagram token errors
+var (
+	ErrUnableToExchangeToken = errors.New("instagram: unable to exchange for a long-lived token")
+)
+
+// exchangeResponse is an Instagram ig_exchange_token response.
+type exchangeResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// LongLivedTokenHandler exchanges the short-lived (1 hour) Instagram Token
+// from the ctx for a long-lived (60 day) Token using the config ClientSecret
+// (the app secret), and replaces the ctx Token with it. Chain it after an
+// oauth2 CallbackHandler, or set the Config LongLivedToken option of
+// CallbackHandlerWithConfig.
+//
+// If the exchange fails, the short-lived Token is kept so login can proceed
+// and the error is added to the ctx (see TokenExchangeErrorFromContext).
+// Handling always delegates to the success handler.
+func LongLivedTokenHandler(config *oauth2.Config, success http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if err != nil {
+			ctx = WithTokenExchangeError(ctx, err)
+			success.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		longLived, err := exchangeToken(ctx, internal.ContextClient(ctx), config, token)
+		if err != nil {
+			ctx = WithTokenExchangeError(ctx, err)
+			success.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		ctx = oauth2Login.WithToken(ctx, longLived)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// exchangeToken exchanges the short-lived Token for a long-lived Token with
+// GET /access_token. Returns a *gologin.Error of ErrUnableToExchangeToken if
+// the exchange fails.
+func exchangeToken(ctx context.Context, httpClient *http.Client, config *oauth2.Config, token *oauth2.Token) (*oauth2.Token, error) {
+	params := url.Values{
+		"grant_type":    {"ig_exchange_token"},
+		"client_secret": {config.ClientSecret},
+		"access_token":  {token.AccessToken},
+	}
+	exchangeResp := new(exchangeResponse)
+	apiErr := new(apiError)
+	resp, err := jsonclient.New(httpClient, graphAPI).Get(ctx, "access_token", params, exchangeResp, apiErr)
+	if err == nil && apiErr.Error.Message != "" {
+		err = &apiErr.Error
+	}
+	var status int
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	if err != nil || status != http.StatusOK {
+		return nil, &gologin.Error{Provider: "instagram", Op: "exchange token", StatusCode: status, Err: err, Kind: ErrUnableToExchangeToken}
+	}
+	if exchangeResp.AccessToken == "" {
+		return nil, &gologin.Error{Provider: "instagram", Op: "exchange token", StatusCode: status, Err: errors.New("missing access_token"), Kind: ErrUnableToExchangeToken}
+	}
+	longLived := &oauth2.Token{
+		AccessToken: exchangeResp.AccessToken,
+		TokenType:   exchangeResp.TokenType,
+	}
+	if exchangeResp.ExpiresIn > 0 {
+		longLived.Expiry = gologin.ClockFromContext(ctx).Now().Add(time.Duration(exchangeResp.ExpiresIn) * time.Second)
+	}
+	return longLived, nil
+}
@@ -0,0 +1,63 @@
+package instagram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func TestLongLivedTokenHandler(t *testing.T) {
+	cases := []struct {
+		name         string
+		clientSecret string
+		accessToken  string
+		expiresIn    time.Duration
+		err          bool
+	}{
+		{"success", "client_secret", "long-token", 5183944 * time.Second, false},
+		{"error", "wrong_secret", "any-token", time.Hour, true},
+	}
+	for _, c := range cases {
+		proxyClient, server := newInstagramTestServer(http.StatusOK, testUserJSON)
+		// oauth2 Client will use the proxy client's base Transport
+		ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+		shortToken := &oauth2.Token{AccessToken: "any-token", Expiry: time.Now().Add(time.Hour)}
+		ctx = oauth2Login.WithToken(ctx, shortToken)
+
+		config := &oauth2.Config{ClientID: "client_id", ClientSecret: c.clientSecret}
+		success := func(w http.ResponseWriter, req *http.Request) {
+			ctx := req.Context()
+			token, err := oauth2Login.TokenFromContext(ctx)
+			if assert.Nil(t, err, c.name) {
+				assert.Equal(t, c.accessToken, token.AccessToken, c.name)
+				assert.WithinDuration(t, time.Now().Add(c.expiresIn), token.Expiry, time.Minute, c.name)
+			}
+			exchangeErr := TokenExchangeErrorFromContext(ctx)
+			assert.Equal(t, c.err, errors.Is(exchangeErr, ErrUnableToExchangeToken), c.name)
+			assert.Equal(t, c.err, exchangeErr != nil, c.name)
+			fmt.Fprintf(w, "success handler called")
+		}
+		failure := testutils.AssertFailureNotCalled(t)
+
+		// LongLivedTokenHandler before InstagramHandler, assert that:
+		// - the short-lived token is exchanged with ig_exchange_token
+		// - the ctx Token is replaced by the long-lived Token, if successful
+		// - otherwise, the short-lived Token is kept and the error is added
+		// - success handler is called
+		handler := LongLivedTokenHandler(config, instagramHandler(http.HandlerFunc(success), failure))
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		handler.ServeHTTP(w, req.WithContext(ctx))
+		assert.Equal(t, "success handler called", w.Body.String(), c.name)
+		server.Close()
+	}
+}
@@ -0,0 +1,94 @@
+package instagram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/dghubble/gologin/internal/jsonclient"
+)
+
+const graphAPI = "https://graph.instagram.com/"
+
+// User is an Instagram user.
+//
+// Note that the token response user_id is a JSON number which may exceed
+// float64 precision, so use the User ID (a string) instead.
+type User struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	// AccountType is "PERSONAL", "BUSINESS", or "MEDIA_CREATOR"
+	AccountType string `json:"account_type"`
+	MediaCount  int    `json:"media_count"`
+}
+
+// userFields are the User fields requested from /me.
+const userFields = "id,username,account_type,media_count"
+
+// apiError is an Instagram Graph API error response.
+type apiError struct {
+	Error GraphError `json:"error"`
+}
+
+// GraphError is the error of an Instagram Graph API error response, which
+// uses the Facebook Graph API error format.
+// https://developers.facebook.com/docs/graph-api/guides/error-handling
+type GraphError struct {
+	Message string `json:"message"`
+	Type    string `json:"type"`
+	Code    int    `json:"code"`
+	Subcode int    `json:"error_subcode"`
+	// FBTraceID identifies the request for Facebook support
+	FBTraceID string `json:"fbtrace_id"`
+}
+
+func (e *GraphError) Error() string {
+	msg := fmt.Sprintf("instagram: %s (%s, code %d", e.Message, e.Type, e.Code)
+	if e.Subcode != 0 {
+		msg = fmt.Sprintf("%s, subcode %d", msg, e.Subcode)
+	}
+	if e.FBTraceID != "" {
+		msg = fmt.Sprintf("%s, fbtrace_id %s", msg, e.FBTraceID)
+	}
+	return msg + ")"
+}
+
+// errCodeInvalidToken is the Graph API error code for invalid or expired
+// access tokens.
+const errCodeInvalidToken = 190
+
+// IsInvalidToken returns true if the error wraps a *GraphError for an invalid
+// access token (code 190), such as an expired or revoked token. Users should
+// log in again.
+func IsInvalidToken(err error) bool {
+	var graphErr *GraphError
+	return errors.As(err, &graphErr) && graphErr.Code == errCodeInvalidToken
+}
+
+// client is an Instagram client for obtaining the current User.
+type client struct {
+	json *jsonclient.Client
+}
+
+// newClient returns a new Instagram client.
+func newClient(httpClient *http.Client) *client {
+	return &client{
+		json: jsonclient.New(httpClient, graphAPI),
+	}
+}
+
+// Me returns the User of the access token. If the Graph API responds with an
+// error, it is returned as a *GraphError.
+// https://developers.facebook.com/docs/instagram-basic-display-api/reference/me
+func (c *client) Me(ctx context.Context, accessToken string) (*User, *http.Response, error) {
+	user := new(User)
+	apiErr := new(apiError)
+	params := url.Values{"fields": {userFields}, "access_token": {accessToken}}
+	resp, err := c.json.Get(ctx, "me", params, user, apiErr)
+	if err == nil && apiErr.Error.Message != "" {
+		err = &apiErr.Error
+	}
+	return user, resp, err
+}
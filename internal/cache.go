@@ -0,0 +1,14 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// TokenCacheKey returns a Cache key for the access token, which is the prefix
+// (e.g. a provider name) and the hex SHA-256 hash of the token, so the raw
+// token is never stored.
+func TokenCacheKey(prefix, accessToken string) string {
+	sum := sha256.Sum256([]byte(accessToken))
+	return prefix + ":" + hex.EncodeToString(sum[:])
+}
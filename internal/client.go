@@ -0,0 +1,100 @@
+package internal
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/dghubble/oauth1"
+	"golang.org/x/oauth2"
+)
+
+// maxIdleConnsPerHost is the number of idle keep-alive connections
+// NewTransport keeps per provider API host (http.DefaultTransport keeps 2).
+const maxIdleConnsPerHost = 32
+
+// NewTransport returns a pooled http.Transport for provider API requests,
+// which handlers create once and share across logins so keep-alive
+// connections are reused.
+func NewTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = maxIdleConnsPerHost
+	return transport
+}
+
+// TransportOrNew returns the transport, or a NewTransport if it is nil.
+func TransportOrNew(transport http.RoundTripper) http.RoundTripper {
+	if transport == nil {
+		return NewTransport()
+	}
+	return transport
+}
+
+// OAuth2Client returns an http.Client which authorizes requests with the
+// Token. Unlike config.Client, the Timeout of any ctx oauth2.HTTPClient is
+// kept, not just its Transport, and requests are canceled with the ctx.
+func OAuth2Client(ctx context.Context, config *oauth2.Config, token *oauth2.Token) *http.Client {
+	return OAuth2TransportClient(ctx, nil, config, token)
+}
+
+// OAuth2TransportClient returns an http.Client like OAuth2Client, which
+// authorizes requests to the base transport with the Token. The transport of
+// any ctx oauth2.HTTPClient is used instead. A nil base uses the
+// http.DefaultTransport.
+func OAuth2TransportClient(ctx context.Context, base http.RoundTripper, config *oauth2.Config, token *oauth2.Token) *http.Client {
+	client := &http.Client{}
+	if ctxClient, ok := ctx.Value(oauth2.HTTPClient).(*http.Client); ok && ctxClient != nil {
+		base = ctxClient.Transport
+		client.Timeout = ctxClient.Timeout
+	}
+	client.Transport = &contextTransport{
+		ctx: ctx,
+		base: &oauth2.Transport{
+			Source: config.TokenSource(ctx, token),
+			Base:   base,
+		},
+	}
+	return client
+}
+
+// contextTransport is a http.RoundTripper which sends requests without a
+// context (e.g. built with http.NewRequest) with the ctx, so they are
+// canceled with it.
+type contextTransport struct {
+	ctx  context.Context
+	base http.RoundTripper
+}
+
+func (t *contextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Context() == context.Background() {
+		req = req.WithContext(t.ctx)
+	}
+	return t.base.RoundTrip(req)
+}
+
+// OAuth1Client returns an http.Client which signs requests with the Token.
+// Unlike config.Client, the Timeout of any ctx oauth1.HTTPClient is kept, not
+// just its Transport.
+func OAuth1Client(ctx context.Context, config *oauth1.Config, token *oauth1.Token) *http.Client {
+	client := config.Client(ctx, token)
+	if ctxClient, ok := ctx.Value(oauth1.HTTPClient).(*http.Client); ok && ctxClient != nil {
+		client.Timeout = ctxClient.Timeout
+	}
+	return client
+}
+
+// ContextClient returns the ctx oauth2.HTTPClient or the http.DefaultClient.
+func ContextClient(ctx context.Context) *http.Client {
+	if client, ok := ctx.Value(oauth2.HTTPClient).(*http.Client); ok && client != nil {
+		return client
+	}
+	return http.DefaultClient
+}
+
+// TransportClient returns the ctx oauth2.HTTPClient or an http.Client which
+// sends requests with the transport.
+func TransportClient(ctx context.Context, transport http.RoundTripper) *http.Client {
+	if client, ok := ctx.Value(oauth2.HTTPClient).(*http.Client); ok && client != nil {
+		return client
+	}
+	return &http.Client{Transport: transport}
+}
@@ -0,0 +1,15 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+)
+
+// EqualSecrets reports whether the secrets (e.g. OAuth2 states) are equal in
+// constant time. The SHA-256 hashes are compared so the time taken does not
+// depend on where the secrets differ or on their lengths.
+func EqualSecrets(a, b string) bool {
+	aSum := sha256.Sum256([]byte(a))
+	bSum := sha256.Sum256([]byte(b))
+	return subtle.ConstantTimeCompare(aSum[:], bSum[:]) == 1
+}
@@ -21,6 +21,7 @@ func NewCookie(config gologin.CookieConfig, value string) *http.Cookie {
 		MaxAge:   config.MaxAge,
 		HttpOnly: config.HTTPOnly,
 		Secure:   config.Secure,
+		SameSite: config.SameSite,
 	}
 	// IE <9 does not understand MaxAge, set Expires if MaxAge is non-zero.
 	if expires, ok := expiresTime(config.MaxAge); ok {
@@ -29,6 +30,13 @@ func NewCookie(config gologin.CookieConfig, value string) *http.Cookie {
 	return cookie
 }
 
+// ExpiredCookie returns a new http.Cookie with the given CookieConfig name,
+// domain, and path which instructs the browser to delete the cookie.
+func ExpiredCookie(config gologin.CookieConfig) *http.Cookie {
+	config.MaxAge = -1
+	return NewCookie(config, "")
+}
+
 // expiresTime converts a maxAge time in seconds to a time.Time in the future
 // if the maxAge is positive or the beginning of the epoch if maxAge is
 // negative. If maxAge is exactly 0, an empty time and false are returned
@@ -0,0 +1,166 @@
+// Package jsonclient sends provider API requests with net/http and decodes
+// their JSON responses.
+package jsonclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	// DefaultMaxBodySize is the default limit of response body bytes read.
+	DefaultMaxBodySize = 1 << 20
+	// UserAgent is the User-Agent header of requests.
+	UserAgent = "gologin"
+)
+
+// Client sends requests relative to a base URL.
+type Client struct {
+	httpClient  *http.Client
+	baseURL     string
+	header      http.Header
+	maxBodySize int64
+}
+
+// New returns a Client which sends requests relative to the base URL (with a
+// trailing slash) with the http.Client. A nil http.Client uses the
+// http.DefaultClient. Requests accept application/json by default.
+func New(httpClient *http.Client, baseURL string) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	header := make(http.Header)
+	header.Set("Accept", "application/json")
+	header.Set("User-Agent", UserAgent)
+	return &Client{
+		httpClient:  httpClient,
+		baseURL:     baseURL,
+		header:      header,
+		maxBodySize: DefaultMaxBodySize,
+	}
+}
+
+// Set sets a header sent with every request (e.g. a vendor Accept type).
+func (c *Client) Set(key, value string) *Client {
+	c.header.Set(key, value)
+	return c
+}
+
+// MaxBodySize sets the limit of response body bytes read. Larger responses
+// are errors.
+func (c *Client) MaxBodySize(n int64) *Client {
+	c.maxBodySize = n
+	return c
+}
+
+// Get sends a GET request for the path with the query params and decodes the
+// response (see Do).
+func (c *Client) Get(ctx context.Context, path string, params url.Values, success, failure interface{}) (*http.Response, error) {
+	req, err := c.NewRequest(ctx, "GET", path, params, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(req, success, failure)
+}
+
+// PostForm sends a POST request for the path with the form as a URL encoded
+// body and decodes the response (see Do).
+func (c *Client) PostForm(ctx context.Context, path string, form url.Values, success, failure interface{}) (*http.Response, error) {
+	req, err := c.NewFormRequest(ctx, "POST", path, form)
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(req, success, failure)
+}
+
+// NewRequest returns a request for the path (resolved against the base URL,
+// or absolute) with the query params and the Client headers. A non-nil body
+// is sent as JSON.
+func (c *Client) NewRequest(ctx context.Context, method, path string, params url.Values, body interface{}) (*http.Request, error) {
+	if body == nil {
+		return c.newRequest(ctx, method, path, params, nil, "")
+	}
+	b, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	return c.newRequest(ctx, method, path, params, bytes.NewReader(b), "application/json")
+}
+
+// NewFormRequest returns a request for the path (see NewRequest) with the
+// form as an application/x-www-form-urlencoded body.
+func (c *Client) NewFormRequest(ctx context.Context, method, path string, form url.Values) (*http.Request, error) {
+	return c.newRequest(ctx, method, path, nil, strings.NewReader(form.Encode()), "application/x-www-form-urlencoded")
+}
+
+// newRequest returns a request for the path with the query params, the
+// Client headers, and the body, if any, of the content type.
+func (c *Client) newRequest(ctx context.Context, method, path string, params url.Values, body io.Reader, contentType string) (*http.Request, error) {
+	base, err := url.Parse(c.baseURL)
+	if err != nil {
+		return nil, err
+	}
+	ref, err := url.Parse(path)
+	if err != nil {
+		return nil, err
+	}
+	u := base.ResolveReference(ref)
+	if len(params) > 0 {
+		query := u.Query()
+		for key, values := range params {
+			for _, value := range values {
+				query.Add(key, value)
+			}
+		}
+		u.RawQuery = query.Encode()
+	}
+	req, err := http.NewRequest(method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range c.header {
+		req.Header[key] = append([]string(nil), values...)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	return req.WithContext(ctx), nil
+}
+
+// Do sends the request and reads the response body. JSON responses are
+// decoded into success for 2xx statuses or into failure otherwise (either
+// may be nil to skip decoding). The body is read (up to the limit) and closed
+// so connections are reused. The response is returned for status inspection,
+// along with any error.
+func (c *Client) Do(req *http.Request, success, failure interface{}) (*http.Response, error) {
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return resp, err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, c.maxBodySize+1))
+	if err != nil {
+		return resp, err
+	}
+	if int64(len(data)) > c.maxBodySize {
+		return resp, fmt.Errorf("jsonclient: response body exceeds %d bytes", c.maxBodySize)
+	}
+	if resp.StatusCode == http.StatusNoContent || len(data) == 0 || !strings.Contains(resp.Header.Get("Content-Type"), "application/json") {
+		return resp, nil
+	}
+	v := failure
+	if 200 <= resp.StatusCode && resp.StatusCode <= 299 {
+		v = success
+	}
+	if v == nil {
+		return resp, nil
+	}
+	return resp, json.Unmarshal(data, v)
+}
@@ -0,0 +1,287 @@
+package jsonclient
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type contextKey int
+
+const testKey contextKey = 0
+
+type testUser struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type testError struct {
+	Message string `json:"message"`
+}
+
+func TestNew_DefaultHeaders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "application/json", req.Header.Get("Accept"))
+		assert.Equal(t, UserAgent, req.Header.Get("User-Agent"))
+		assert.Equal(t, "", req.Header.Get("Content-Type"))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	// New Client, assert that:
+	// - requests accept JSON and have the gologin User-Agent
+	resp, err := New(server.Client(), server.URL+"/").Get(context.Background(), "user", nil, nil, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+}
+
+func TestClient_Set(t *testing.T) {
+	client := New(nil, "https://api.example.com/").Set("Accept", "application/vnd.example+json")
+	req, err := client.NewRequest(context.Background(), "GET", "user", nil, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "application/vnd.example+json", req.Header.Get("Accept"))
+	assert.Equal(t, UserAgent, req.Header.Get("User-Agent"))
+
+	// headers are copied, so changes to a request do not reach the Client
+	req.Header.Set("Accept", "text/plain")
+	next, err := client.NewRequest(context.Background(), "GET", "user", nil, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "application/vnd.example+json", next.Header.Get("Accept"))
+}
+
+func TestNewRequest(t *testing.T) {
+	cases := []struct {
+		baseURL  string
+		path     string
+		params   url.Values
+		expected string
+	}{
+		{"https://api.example.com/", "user", nil, "https://api.example.com/user"},
+		{"https://api.example.com/v1/", "user", nil, "https://api.example.com/v1/user"},
+		{"https://api.example.com/v1/", "users/1?fields=id", nil, "https://api.example.com/v1/users/1?fields=id"},
+		{"https://api.example.com/v1/", "users/1?fields=id", url.Values{"access_token": {"a b"}}, "https://api.example.com/v1/users/1?access_token=a+b&fields=id"},
+		{"https://api.example.com/v1/", "/user", nil, "https://api.example.com/user"},
+		{"https://api.example.com/v1/", "https://other.example.com/me", nil, "https://other.example.com/me"},
+		{"https://api.example.com/v1/", "user", url.Values{"scope": {"a", "b"}}, "https://api.example.com/v1/user?scope=a&scope=b"},
+	}
+	for _, c := range cases {
+		req, err := New(nil, c.baseURL).NewRequest(context.Background(), "GET", c.path, c.params, nil)
+		if assert.Nil(t, err, c.path) {
+			assert.Equal(t, c.expected, req.URL.String(), c.path)
+		}
+	}
+}
+
+func TestNewRequest_Body(t *testing.T) {
+	ctx := context.WithValue(context.Background(), testKey, "value")
+	req, err := New(nil, "https://api.example.com/").NewRequest(ctx, "POST", "users", nil, testUser{ID: "1", Name: "Alice"})
+	if assert.Nil(t, err) {
+		assert.Equal(t, "application/json", req.Header.Get("Content-Type"))
+		assert.Equal(t, ctx, req.Context())
+		body, err := ioutil.ReadAll(req.Body)
+		assert.Nil(t, err)
+		assert.Equal(t, `{"id":"1","name":"Alice"}`, string(body))
+	}
+}
+
+func TestNewFormRequest(t *testing.T) {
+	form := url.Values{"client_id": {"a b"}, "scope": {"read"}}
+	req, err := New(nil, "https://api.example.com/v1/").NewFormRequest(context.Background(), "POST", "apps", form)
+	if assert.Nil(t, err) {
+		assert.Equal(t, "https://api.example.com/v1/apps", req.URL.String())
+		assert.Equal(t, "application/x-www-form-urlencoded", req.Header.Get("Content-Type"))
+		assert.Equal(t, "application/json", req.Header.Get("Accept"))
+		body, err := ioutil.ReadAll(req.Body)
+		assert.Nil(t, err)
+		assert.Equal(t, "client_id=a+b&scope=read", string(body))
+	}
+}
+
+func TestPostForm(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "POST", req.Method)
+		assert.Equal(t, "/apps", req.URL.Path)
+		assert.Equal(t, "Alice", req.PostFormValue("name"))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"id": "1", "name": "Alice"}`)
+	}))
+	defer server.Close()
+
+	// PostForm, assert that:
+	// - the form is sent as the request body
+	// - the response is decoded into success
+	user := testUser{}
+	resp, err := New(server.Client(), server.URL+"/").PostForm(context.Background(), "apps", url.Values{"name": {"Alice"}}, &user, nil)
+	assert.Nil(t, err)
+	if assert.NotNil(t, resp) {
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	}
+	assert.Equal(t, testUser{ID: "1", Name: "Alice"}, user)
+}
+
+func TestNewRequest_Errors(t *testing.T) {
+	ctx := context.Background()
+	// invalid base URL
+	_, err := New(nil, "://api.example.com/").NewRequest(ctx, "GET", "user", nil, nil)
+	assert.NotNil(t, err)
+	// invalid path
+	_, err = New(nil, "https://api.example.com/").NewRequest(ctx, "GET", "%zz", nil, nil)
+	assert.NotNil(t, err)
+	// body which cannot be marshaled
+	_, err = New(nil, "https://api.example.com/").NewRequest(ctx, "POST", "user", nil, make(chan int))
+	assert.NotNil(t, err)
+	// invalid method
+	_, err = New(nil, "https://api.example.com/").NewRequest(ctx, "BAD METHOD", "user", nil, nil)
+	assert.NotNil(t, err)
+}
+
+func TestDo(t *testing.T) {
+	cases := []struct {
+		name        string
+		status      int
+		contentType string
+		body        string
+		user        testUser
+		apiErr      testError
+	}{
+		{"success", http.StatusOK, "application/json", `{"id": "1", "name": "Alice"}`, testUser{ID: "1", Name: "Alice"}, testError{}},
+		{"success charset", http.StatusCreated, "application/json; charset=utf-8", `{"id": "1"}`, testUser{ID: "1"}, testError{}},
+		{"failure", http.StatusBadRequest, "application/json", `{"message": "bad request"}`, testUser{}, testError{Message: "bad request"}},
+		{"server error", http.StatusInternalServerError, "application/json", `{"message": "oops"}`, testUser{}, testError{Message: "oops"}},
+		{"no content", http.StatusNoContent, "application/json", ``, testUser{}, testError{}},
+		{"empty body", http.StatusOK, "application/json", ``, testUser{}, testError{}},
+		{"not json", http.StatusOK, "text/html", `<html>{"id": "1"}</html>`, testUser{}, testError{}},
+		{"not json failure", http.StatusBadGateway, "text/plain", `bad gateway`, testUser{}, testError{}},
+	}
+	for _, c := range cases {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.Header().Set("Content-Type", c.contentType)
+			w.WriteHeader(c.status)
+			fmt.Fprint(w, c.body)
+		}))
+
+		// Get, assert that:
+		// - 2xx JSON responses are decoded into success
+		// - other JSON responses are decoded into failure, without an error
+		// - empty, 204, and non-JSON responses are not decoded
+		// - the response is returned for status inspection
+		user := testUser{}
+		apiErr := testError{}
+		resp, err := New(server.Client(), server.URL+"/").Get(context.Background(), "user", nil, &user, &apiErr)
+		assert.Nil(t, err, c.name)
+		if assert.NotNil(t, resp, c.name) {
+			assert.Equal(t, c.status, resp.StatusCode, c.name)
+		}
+		assert.Equal(t, c.user, user, c.name)
+		assert.Equal(t, c.apiErr, apiErr, c.name)
+		server.Close()
+	}
+}
+
+func TestDo_NilTargets(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"message": "bad request"}`)
+	}))
+	defer server.Close()
+
+	// nil failure skips decoding the error response
+	user := testUser{}
+	resp, err := New(server.Client(), server.URL+"/").Get(context.Background(), "user", nil, &user, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, testUser{}, user)
+}
+
+func TestDo_Errors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch req.URL.Path {
+		case "/invalid":
+			fmt.Fprint(w, `{"id": 1}`)
+		case "/truncated":
+			fmt.Fprint(w, `{"id": "1"`)
+		}
+	}))
+	defer server.Close()
+	client := New(server.Client(), server.URL+"/")
+
+	// Get, assert that:
+	// - JSON which does not decode into success is an error
+	// - the response is still returned
+	for _, path := range []string{"invalid", "truncated"} {
+		user := testUser{}
+		resp, err := client.Get(context.Background(), path, nil, &user, nil)
+		assert.NotNil(t, err, path)
+		if assert.NotNil(t, resp, path) {
+			assert.Equal(t, http.StatusOK, resp.StatusCode, path)
+		}
+	}
+
+	// transport errors are returned
+	server.Close()
+	_, err := client.Get(context.Background(), "invalid", nil, &testUser{}, nil)
+	assert.NotNil(t, err)
+}
+
+func TestMaxBodySize(t *testing.T) {
+	body := `{"id": "1", "name": "Alice"}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, body)
+	}))
+	defer server.Close()
+	size := int64(len(body))
+
+	cases := []struct {
+		name        string
+		maxBodySize int64
+		err         bool
+	}{
+		{"larger limit", size + 1, false},
+		{"exact limit", size, false},
+		{"smaller limit", size - 1, true},
+		{"tiny limit", 1, true},
+	}
+	for _, c := range cases {
+		// Get with a MaxBodySize, assert that:
+		// - bodies up to the limit are decoded
+		// - larger bodies are errors and are not decoded, even partially
+		user := testUser{}
+		client := New(server.Client(), server.URL+"/").MaxBodySize(c.maxBodySize)
+		resp, err := client.Get(context.Background(), "user", nil, &user, nil)
+		if c.err {
+			if assert.NotNil(t, err, c.name) {
+				assert.Equal(t, fmt.Sprintf("jsonclient: response body exceeds %d bytes", c.maxBodySize), err.Error(), c.name)
+			}
+			assert.Equal(t, testUser{}, user, c.name)
+		} else {
+			assert.Nil(t, err, c.name)
+			assert.Equal(t, testUser{ID: "1", Name: "Alice"}, user, c.name)
+		}
+		assert.NotNil(t, resp, c.name)
+	}
+}
+
+func TestMaxBodySize_Default(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"id": "%s"}`, strings.Repeat("a", DefaultMaxBodySize))
+	}))
+	defer server.Close()
+
+	// responses over the DefaultMaxBodySize are errors
+	_, err := New(server.Client(), server.URL+"/").Get(context.Background(), "user", nil, &testUser{}, nil)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "exceeds")
+	}
+}
@@ -0,0 +1,16 @@
+package internal
+
+import (
+	"context"
+
+	"github.com/dghubble/gologin"
+)
+
+// WithDefaultProvider returns a copy of ctx that stores the provider name,
+// unless the ctx already has one (e.g. from a gologin.ProviderHandler).
+func WithDefaultProvider(ctx context.Context, provider string) context.Context {
+	if gologin.ProviderFromContext(ctx, "") != "" {
+		return ctx
+	}
+	return gologin.WithProvider(ctx, provider)
+}
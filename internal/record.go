@@ -0,0 +1,49 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// RecordBody returns a copy of the client which records the body of the
+// first 2xx response to a GET request whose URL path has the suffix (e.g. a
+// provider user endpoint), and a func which returns the recorded body, if
+// any. Recorded responses are still read normally by the caller.
+func RecordBody(client *http.Client, pathSuffix string) (*http.Client, func() json.RawMessage) {
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	t := &recordTransport{base: base, pathSuffix: pathSuffix}
+	c := *client
+	c.Transport = t
+	return &c, func() json.RawMessage { return t.body }
+}
+
+// recordTransport is a http.RoundTripper which records a response body.
+type recordTransport struct {
+	base       http.RoundTripper
+	pathSuffix string
+	body       json.RawMessage
+}
+
+func (t *recordTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || t.body != nil || req.Method != "GET" || !strings.HasSuffix(req.URL.Path, t.pathSuffix) {
+		return resp, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp, err
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	t.body = body
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return resp, nil
+}
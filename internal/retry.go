@@ -0,0 +1,153 @@
+package internal
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dghubble/gologin"
+)
+
+const (
+	defaultRetryBaseDelay = 100 * time.Millisecond
+	defaultRetryMaxDelay  = 2 * time.Second
+)
+
+// RetryClient returns a copy of the client (e.g. from OAuth2Client) which
+// retries GET requests per the RetryPolicy. The client is returned as is if the policy disables
+// retries.
+func RetryClient(client *http.Client, policy gologin.RetryPolicy) *http.Client {
+	if policy.MaxAttempts < 2 {
+		return client
+	}
+	if policy.BaseDelay <= 0 {
+		policy.BaseDelay = defaultRetryBaseDelay
+	}
+	if policy.MaxDelay <= 0 {
+		policy.MaxDelay = defaultRetryMaxDelay
+	}
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	c := *client
+	if ct, ok := base.(*contextTransport); ok {
+		// retry beneath the contextTransport so retries see the ctx
+		c.Transport = &contextTransport{ctx: ct.ctx, base: &retryTransport{policy: policy, base: ct.base}}
+	} else {
+		c.Transport = &retryTransport{policy: policy, base: base}
+	}
+	return &c
+}
+
+// retryTransport is a http.RoundTripper which retries GET requests on
+// network errors and 5xx responses.
+type retryTransport struct {
+	policy gologin.RetryPolicy
+	base   http.RoundTripper
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != "GET" && req.Method != "" {
+		return t.base.RoundTrip(req)
+	}
+	ctx := req.Context()
+	clock := gologin.ClockFromContext(ctx)
+	for attempt := 1; ; attempt++ {
+		resp, err := t.base.RoundTrip(req)
+		if attempt >= t.policy.MaxAttempts || !retryable(resp, err) || ctx.Err() != nil {
+			return resp, err
+		}
+		delay := t.backoff(attempt, gologin.EntropyFromContext(ctx))
+		if resp != nil {
+			if after, ok := retryAfter(resp, clock.Now()); ok {
+				if after > t.policy.MaxDelay {
+					return resp, err
+				}
+				if after > delay {
+					delay = after
+				}
+			}
+		}
+		if deadline, ok := ctx.Deadline(); ok && clock.Now().Add(delay).After(deadline) {
+			return resp, err
+		}
+		if resp != nil {
+			// drain and close the failed response so its connection is reused
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		if err := Sleep(ctx, clock, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// backoff returns the delay before the retry after the attempt, doubling the
+// BaseDelay per attempt up to the MaxDelay, with jitter in [delay/2, delay]
+// read from the entropy.
+func (t *retryTransport) backoff(attempt int, entropy io.Reader) time.Duration {
+	delay := t.policy.MaxDelay
+	if attempt < 32 {
+		if d := t.policy.BaseDelay << uint(attempt-1); d > 0 && d < delay {
+			delay = d
+		}
+	}
+	half := delay / 2
+	return half + time.Duration(jitter(entropy, int64(delay-half)+1))
+}
+
+// jitter returns a random int64 in [0, n) read from the entropy, or n-1 if
+// the entropy cannot be read.
+func jitter(entropy io.Reader, n int64) int64 {
+	var b [8]byte
+	if _, err := io.ReadFull(entropy, b[:]); err != nil {
+		return n - 1
+	}
+	return int64(binary.BigEndian.Uint64(b[:]) % uint64(n))
+}
+
+// retryable returns true if the request failed with a network error or a
+// 5xx response.
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode >= 500
+}
+
+// retryAfter returns the delay from the response's Retry-After header (in
+// seconds or as an HTTP date relative to now), if any.
+func retryAfter(resp *http.Response, now time.Time) (time.Duration, bool) {
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return date.Sub(now), true
+	}
+	return 0, false
+}
+
+// Sleep waits for the delay or until the ctx is done, with the clock if it is
+// a gologin Sleeper.
+func Sleep(ctx context.Context, clock gologin.Clock, delay time.Duration) error {
+	if sleeper, ok := clock.(gologin.Sleeper); ok {
+		return sleeper.Sleep(ctx, delay)
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,65 @@
+package intuit
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/dghubble/gologin"
+)
+
+// unexported key type prevents collisions
+type key int
+
+const (
+	userKey key = iota
+	realmIDKey
+)
+
+// WithUser returns a copy of ctx that stores the Intuit User and its gologin
+// Profile.
+func WithUser(ctx context.Context, user *User) context.Context {
+	ctx = gologin.WithProfile(ctx, newProfile(user))
+	return context.WithValue(ctx, userKey, user)
+}
+
+// UserFromContext returns the Intuit User from the ctx.
+func UserFromContext(ctx context.Context) (*User, error) {
+	user, ok := ctx.Value(userKey).(*User)
+	if !ok {
+		return nil, fmt.Errorf("intuit: Context missing Intuit User")
+	}
+	return user, nil
+}
+
+// newProfile returns the gologin Profile of the Intuit User.
+func newProfile(user *User) *gologin.Profile {
+	if user == nil {
+		return nil
+	}
+	return &gologin.Profile{
+		Provider:      ProviderName,
+		ID:            user.ID,
+		Email:         user.Email,
+		EmailVerified: user.EmailVerified,
+		Name:          strings.TrimSpace(user.GivenName + " " + user.FamilyName),
+		Raw:           gologin.ProfileRaw(user, "sub", "email", "emailVerified"),
+	}
+}
+
+// WithRealmID returns a copy of ctx that stores the QuickBooks company realm
+// ID.
+func WithRealmID(ctx context.Context, realmID string) context.Context {
+	return context.WithValue(ctx, realmIDKey, realmID)
+}
+
+// RealmIDFromContext returns the realm ID of the QuickBooks company the User
+// connected from the ctx. QuickBooks Online accounting API requests require
+// the realm ID.
+func RealmIDFromContext(ctx context.Context) (string, error) {
+	realmID, ok := ctx.Value(realmIDKey).(string)
+	if !ok {
+		return "", fmt.Errorf("intuit: Context missing QuickBooks realm ID")
+	}
+	return realmID, nil
+}
@@ -0,0 +1,39 @@
+package intuit
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContextUser(t *testing.T) {
+	expectedUser := &User{ID: "1182d6ec-2a1f-4f9d-9b8c-7e0a1b2c3d4e", Email: "jane@example.com"}
+	ctx := WithUser(context.Background(), expectedUser)
+	user, err := UserFromContext(ctx)
+	assert.Equal(t, expectedUser, user)
+	assert.Nil(t, err)
+}
+
+func TestContextUser_Error(t *testing.T) {
+	user, err := UserFromContext(context.Background())
+	assert.Nil(t, user)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "intuit: Context missing Intuit User", err.Error())
+	}
+}
+
+func TestContextRealmID(t *testing.T) {
+	ctx := WithRealmID(context.Background(), "4620816365178083590")
+	realmID, err := RealmIDFromContext(ctx)
+	assert.Equal(t, "4620816365178083590", realmID)
+	assert.Nil(t, err)
+}
+
+func TestContextRealmID_Error(t *testing.T) {
+	realmID, err := RealmIDFromContext(context.Background())
+	assert.Equal(t, "", realmID)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "intuit: Context missing QuickBooks realm ID", err.Error())
+	}
+}
@@ -0,0 +1,3 @@
+// Package intuit provides Intuit (QuickBooks Online) OAuth2 login and callback
+// handlers.
+package intuit
@@ -0,0 +1,128 @@
+package intuit
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/internal"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"golang.org/x/oauth2"
+)
+
+// ProviderName is the provider name set in the ctx (see
+// gologin.ProviderFromContext) and the Profile.
+const ProviderName = "intuit"
+
+// Intuit login errors
+var (
+	ErrUnableToGetIntuitUser = errors.New("intuit: unable to get Intuit User")
+)
+
+// Endpoint is Intuit's OAuth2 endpoint, for production and sandbox apps.
+// Intuit requires HTTP Basic client authentication at the token endpoint.
+var Endpoint = oauth2.Endpoint{
+	AuthURL:   "https://appcenter.intuit.com/connect/oauth2",
+	TokenURL:  "https://oauth.platform.intuit.com/oauth2/v1/tokens/bearer",
+	AuthStyle: oauth2.AuthStyleInHeader,
+}
+
+// Config configures Intuit login.
+type Config struct {
+	// Sandbox gets the User from the sandbox (rather than production)
+	// userinfo endpoint, for apps with development keys.
+	Sandbox bool
+}
+
+// StateHandler checks for a state cookie. If found, the state value is read
+// and added to the ctx. Otherwise, a non-guessable value is added to the ctx
+// and to a (short-lived) state cookie issued to the requester.
+//
+// Implements OAuth 2 RFC 6749 10.12 CSRF Protection. If you wish to issue
+// state params differently, write a http.Handler which sets the ctx state,
+// using oauth2 WithState(ctx, state) since it is required by LoginHandler
+// and CallbackHandler.
+func StateHandler(config gologin.CookieConfig, success http.Handler) http.Handler {
+	return oauth2Login.StateHandler(config, success)
+}
+
+// LoginHandler handles Intuit login requests by reading the state value from
+// the ctx and redirecting requests to the AuthURL with that state value.
+// Any AuthCodeOptions are added to the AuthURL.
+//
+// The config Endpoint should be the intuit Endpoint and Scopes should include
+// "openid" and "email" or "profile" (and "com.intuit.quickbooks.accounting"
+// to connect a QuickBooks company).
+func LoginHandler(config *oauth2.Config, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	return oauth2Login.LoginHandler(config, failure, opts...)
+}
+
+// CallbackHandler handles Intuit redirection URI requests and adds the Intuit
+// access token, User, and QuickBooks realm ID to the ctx. If authentication
+// succeeds, handling delegates to the success handler, otherwise to the
+// failure handler.
+// Any AuthCodeOptions are sent with the token exchange.
+func CallbackHandler(config *oauth2.Config, success, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	return CallbackHandlerWithConfig(config, Config{}, success, failure, opts...)
+}
+
+// CallbackHandlerWithConfig handles Intuit redirection URI requests like
+// CallbackHandler, but gets the User from the sandbox userinfo endpoint if
+// the Config is Sandbox.
+func CallbackHandlerWithConfig(config *oauth2.Config, intuitConfig Config, success, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	success = intuitHandler(config, intuitConfig, success, failure)
+	return oauth2Login.CallbackHandler(config, success, failure, opts...)
+}
+
+// intuitHandler is a http.Handler that gets the OAuth2 Token from the ctx to
+// get the corresponding Intuit User. If successful, the User and the realmId
+// callback query parameter (if any) are added to the ctx and the success
+// handler is called. Otherwise, the failure handler is called.
+//
+// Intuit only sends a realmId when the user connects a QuickBooks company
+// (e.g. the com.intuit.quickbooks.accounting scope was requested).
+func intuitHandler(config *oauth2.Config, intuitConfig Config, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		httpClient := internal.OAuth2Client(ctx, config, token)
+		user, resp, err := newClient(httpClient, intuitConfig.Sandbox).UserInfo(ctx)
+		err = validateResponse(user, resp, err)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		ctx = WithUser(ctx, user)
+		if realmID := req.URL.Query().Get("realmId"); realmID != "" {
+			ctx = WithRealmID(ctx, realmID)
+		}
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// validateResponse returns an error if the given Intuit User, raw
+// http.Response, or error are unexpected. Returns nil if they are valid, or a
+// *gologin.Error which preserves the cause and status code.
+func validateResponse(user *User, resp *http.Response, err error) error {
+	var status int
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	if err != nil || status != http.StatusOK {
+		return &gologin.Error{Provider: "intuit", Op: "get user", StatusCode: status, Err: err, Kind: ErrUnableToGetIntuitUser}
+	}
+	if user == nil || user.ID == "" {
+		return &gologin.Error{Provider: "intuit", Op: "get user", StatusCode: status, Kind: ErrUnableToGetIntuitUser}
+	}
+	return nil
+}
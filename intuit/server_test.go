@@ -0,0 +1,53 @@
+package intuit
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/dghubble/gologin/testutils"
+)
+
+const (
+	// testUserJSON is a production userinfo response.
+	testUserJSON = `{"sub": "1182d6ec-2a1f-4f9d-9b8c-7e0a1b2c3d4e", "email": "jane@example.com", "emailVerified": true, "givenName": "Jane", "familyName": "Doe", "phoneNumber": "+1 6305555555", "phoneNumberVerified": false}`
+	// testSandboxUserJSON is a sandbox userinfo response.
+	testSandboxUserJSON = `{"sub": "9a8b7c6d-5e4f-4a3b-2c1d-0e9f8a7b6c5d", "email": "sandbox@example.com", "emailVerified": false, "givenName": "Sandy", "familyName": "Box"}`
+)
+
+// newIntuitTestServer returns a new httptest.Server which mocks the Intuit
+// token and (production and sandbox) userinfo endpoints and a client which
+// proxies requests to the server. Like Intuit, the token endpoint requires
+// HTTP Basic client authentication. The userinfo endpoint responds with
+// testUserJSON or testSandboxUserJSON by host. The caller must close the
+// server.
+func newIntuitTestServer() (*http.Client, *httptest.Server) {
+	client, mux, server := testutils.TestServer()
+	mux.HandleFunc("/oauth2/v1/tokens/bearer", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		username, password, ok := r.BasicAuth()
+		if !ok || username != "client_id" || password != "client_secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprintf(w, `{"error": "invalid_client"}`)
+			return
+		}
+		fmt.Fprintf(w, `{"access_token": "any-token", "token_type": "bearer", "refresh_token": "any-refresh", "expires_in": 3600, "x_refresh_token_expires_in": 8726400}`)
+	})
+	mux.HandleFunc("/v1/openid_connect/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json;charset=UTF-8")
+		if r.Header.Get("Authorization") != "Bearer any-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprintf(w, `{"error": "invalid_token"}`)
+			return
+		}
+		switch r.Host {
+		case "accounts.platform.intuit.com":
+			fmt.Fprintf(w, testUserJSON)
+		case "sandbox-accounts.platform.intuit.com":
+			fmt.Fprintf(w, testSandboxUserJSON)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	return client, server
+}
@@ -0,0 +1,47 @@
+package intuit
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/dghubble/gologin/internal/jsonclient"
+)
+
+const (
+	intuitAccountsAPI        = "https://accounts.platform.intuit.com/"
+	intuitSandboxAccountsAPI = "https://sandbox-accounts.platform.intuit.com/"
+)
+
+// User is an Intuit user from the OpenID Connect userinfo endpoint.
+type User struct {
+	ID            string `json:"sub"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"emailVerified"`
+	GivenName     string `json:"givenName"`
+	FamilyName    string `json:"familyName"`
+}
+
+// client is an Intuit client for obtaining a User.
+type client struct {
+	json *jsonclient.Client
+}
+
+// newClient returns a new Intuit client for the production or sandbox
+// accounts API.
+func newClient(httpClient *http.Client, sandbox bool) *client {
+	baseURL := intuitAccountsAPI
+	if sandbox {
+		baseURL = intuitSandboxAccountsAPI
+	}
+	return &client{
+		json: jsonclient.New(httpClient, baseURL).Set("Accept", "application/json"),
+	}
+}
+
+// UserInfo gets the current Intuit User.
+// https://developer.intuit.com/app/developer/qbo/docs/develop/authentication-and-authorization/openid-connect
+func (c *client) UserInfo(ctx context.Context) (*User, *http.Response, error) {
+	user := new(User)
+	resp, err := c.json.Get(ctx, "v1/openid_connect/userinfo", nil, user, nil)
+	return user, resp, err
+}
@@ -0,0 +1,20 @@
+/*
+Package jwtlogin provides a success handler which issues a signed JWT after a
+gologin provider login, for single-page apps whose API authenticates requests
+with bearer tokens rather than cookie sessions.
+
+IssueJWT builds claims from the gologin Profile (sub is "provider:id"), signs
+them with an HS256 secret or an RS256 or ES256 private key, and delivers the
+token as a cookie, a JSON response, or in the fragment of a redirect URL.
+
+	config := jwtlogin.Config{
+		Key:         privateKey,
+		Issuer:      "https://api.example.com",
+		Audience:    "example-spa",
+		TTL:         time.Hour,
+		Delivery:    jwtlogin.DeliverFragment,
+		RedirectURL: "https://app.example.com/login/done",
+	}
+	mux.Handle("/github/callback", github.StateHandler(stateConfig, github.CallbackHandler(oauth2Config, jwtlogin.IssueJWT(config, nil), nil)))
+*/
+package jwtlogin
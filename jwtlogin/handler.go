@@ -0,0 +1,118 @@
+package jwtlogin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/internal"
+)
+
+// Delivery is how IssueJWT returns the signed JWT.
+type Delivery int
+
+// Deliveries of the signed JWT
+const (
+	// DeliverJSON responds with {"access_token", "token_type", "expires_in"}.
+	DeliverJSON Delivery = iota
+	// DeliverCookie sets the JWT as the Cookie and redirects to the
+	// RedirectURL.
+	DeliverCookie
+	// DeliverFragment redirects to the RedirectURL with the access_token,
+	// token_type, and expires_in in the URL fragment, which browsers do not
+	// send to servers.
+	DeliverFragment
+)
+
+// Config configures IssueJWT.
+type Config struct {
+	// Key signs JWTs: a []byte secret (HS256), an *rsa.PrivateKey (RS256), or
+	// a P-256 *ecdsa.PrivateKey (ES256).
+	Key interface{}
+	// KeyID is the optional "kid" header identifying the verification key.
+	KeyID string
+	// Issuer is the "iss" claim, if set.
+	Issuer string
+	// Audience is the "aud" claim, if set.
+	Audience string
+	// TTL is the JWT lifetime. Defaults to 1 hour.
+	TTL time.Duration
+	// Claims returns extra claims for the Profile's JWT, if set. Registered
+	// claims (sub, iat, exp, iss, aud) and the profile email and name take
+	// precedence.
+	Claims func(ctx context.Context, profile *gologin.Profile) (map[string]interface{}, error)
+	// Delivery selects how the JWT is returned (default DeliverJSON).
+	Delivery Delivery
+	// RedirectURL is the redirect of DeliverCookie and DeliverFragment.
+	RedirectURL string
+	// Cookie configures the DeliverCookie cookie. A zero MaxAge is set to the
+	// TTL.
+	Cookie gologin.CookieConfig
+}
+
+// tokenResponse is the DeliverJSON response.
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// IssueJWT returns a login success handler which signs a JWT of the ctx
+// gologin Profile and delivers it as configured. If the Profile is missing or
+// the extra claims or signing fail, the error is added to the ctx and the
+// failure handler is called.
+func IssueJWT(config Config, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		profile, err := gologin.ProfileFromContext(ctx)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		claims, err := config.claims(ctx, profile, gologin.ClockFromContext(ctx).Now())
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		token, err := config.sign(claims)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		expiresIn := int64(config.ttl() / time.Second)
+		switch config.Delivery {
+		case DeliverCookie:
+			cookieConfig := config.Cookie
+			if cookieConfig.MaxAge == 0 {
+				cookieConfig.MaxAge = int(expiresIn)
+			}
+			http.SetCookie(w, internal.NewCookie(cookieConfig, token))
+			http.Redirect(w, req, config.RedirectURL, http.StatusFound)
+		case DeliverFragment:
+			fragment := url.Values{}
+			fragment.Set("access_token", token)
+			fragment.Set("token_type", "Bearer")
+			fragment.Set("expires_in", strconv.FormatInt(expiresIn, 10))
+			http.Redirect(w, req, config.RedirectURL+"#"+fragment.Encode(), http.StatusFound)
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Cache-Control", "no-store")
+			json.NewEncoder(w).Encode(tokenResponse{
+				AccessToken: token,
+				TokenType:   "Bearer",
+				ExpiresIn:   expiresIn,
+			})
+		}
+	}
+	return http.HandlerFunc(fn)
+}
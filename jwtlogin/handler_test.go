@@ -0,0 +1,245 @@
+package jwtlogin
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+)
+
+var testProfile = &gologin.Profile{
+	Provider:      "github",
+	ID:            "917408",
+	Email:         "octocat@example.com",
+	EmailVerified: true,
+	Name:          "Octocat",
+}
+
+func profileRequest(profile *gologin.Profile) *http.Request {
+	req, _ := http.NewRequest("GET", "/callback", nil)
+	if profile != nil {
+		req = req.WithContext(gologin.WithProfile(req.Context(), profile))
+	}
+	return req
+}
+
+// parseJWT verifies the JWT signature with the verification key (a []byte
+// secret or public key) and returns the header alg and claims.
+func parseJWT(t *testing.T, token string, key interface{}) (string, map[string]interface{}) {
+	parts := strings.Split(token, ".")
+	if !assert.Len(t, parts, 3) {
+		t.FailNow()
+	}
+	var header map[string]string
+	headerJSON, _ := base64.RawURLEncoding.DecodeString(parts[0])
+	assert.Nil(t, json.Unmarshal(headerJSON, &header))
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	assert.Nil(t, err)
+	signingInput := []byte(parts[0] + "." + parts[1])
+	digest := sha256.Sum256(signingInput)
+	switch key := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, key)
+		mac.Write(signingInput)
+		assert.True(t, hmac.Equal(mac.Sum(nil), signature), "invalid HS256 signature")
+	case *rsa.PublicKey:
+		assert.Nil(t, rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature))
+	case *ecdsa.PublicKey:
+		r, s := new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])
+		assert.True(t, ecdsa.Verify(key, digest[:], r, s), "invalid ES256 signature")
+	}
+	var claims map[string]interface{}
+	claimsJSON, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	assert.Nil(t, json.Unmarshal(claimsJSON, &claims))
+	return header["alg"], claims
+}
+
+func TestIssueJWT_Keys(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	cases := []struct {
+		key       interface{}
+		verifyKey interface{}
+		alg       string
+	}{
+		{[]byte("hmac secret"), []byte("hmac secret"), "HS256"},
+		{rsaKey, &rsaKey.PublicKey, "RS256"},
+		{ecKey, &ecKey.PublicKey, "ES256"},
+	}
+	for _, c := range cases {
+		config := Config{Key: c.key, Issuer: "https://api.example.com", Audience: "example-spa", TTL: 10 * time.Minute}
+		w := httptest.NewRecorder()
+		IssueJWT(config, testutils.AssertFailureNotCalled(t)).ServeHTTP(w, profileRequest(testProfile))
+
+		// IssueJWT assert that:
+		// - the JWT is written as JSON by default
+		// - the JWT is signed with the key's algorithm and verifies
+		// - claims include the Profile, issuer, audience, and TTL expiry
+		assert.Equal(t, "application/json", w.HeaderMap.Get("Content-Type"))
+		var resp tokenResponse
+		assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, "Bearer", resp.TokenType)
+		assert.Equal(t, int64(600), resp.ExpiresIn)
+		alg, claims := parseJWT(t, resp.AccessToken, c.verifyKey)
+		assert.Equal(t, c.alg, alg)
+		assert.Equal(t, "github:917408", claims["sub"])
+		assert.Equal(t, "https://api.example.com", claims["iss"])
+		assert.Equal(t, "example-spa", claims["aud"])
+		assert.Equal(t, "octocat@example.com", claims["email"])
+		assert.Equal(t, true, claims["email_verified"])
+		assert.Equal(t, "Octocat", claims["name"])
+		assert.Equal(t, float64(600), claims["exp"].(float64)-claims["iat"].(float64))
+		assert.True(t, time.Unix(int64(claims["exp"].(float64)), 0).After(time.Now()))
+	}
+}
+
+func TestIssueJWT_WrongKey(t *testing.T) {
+	w := httptest.NewRecorder()
+	IssueJWT(Config{Key: []byte("hmac secret")}, nil).ServeHTTP(w, profileRequest(testProfile))
+	var resp tokenResponse
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	parts := strings.Split(resp.AccessToken, ".")
+	mac := hmac.New(sha256.New, []byte("other secret"))
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	// IssueJWT assert that:
+	// - the JWT does not verify with another key
+	assert.NotEqual(t, base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), parts[2])
+}
+
+func TestIssueJWT_Claims(t *testing.T) {
+	config := Config{
+		Key: []byte("hmac secret"),
+		Claims: func(ctx context.Context, profile *gologin.Profile) (map[string]interface{}, error) {
+			return map[string]interface{}{"role": "admin", "sub": "forged"}, nil
+		},
+	}
+	w := httptest.NewRecorder()
+	IssueJWT(config, testutils.AssertFailureNotCalled(t)).ServeHTTP(w, profileRequest(&gologin.Profile{Provider: "github", ID: "917408"}))
+	var resp tokenResponse
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	_, claims := parseJWT(t, resp.AccessToken, []byte("hmac secret"))
+
+	// IssueJWT with a Claims hook assert that:
+	// - extra claims are added
+	// - registered claims cannot be overridden
+	// - empty Profile fields, issuer, and audience are omitted
+	assert.Equal(t, "admin", claims["role"])
+	assert.Equal(t, "github:917408", claims["sub"])
+	for _, name := range []string{"iss", "aud", "email", "email_verified", "name"} {
+		assert.NotContains(t, claims, name)
+	}
+	// - the default TTL is 1 hour
+	assert.Equal(t, int64(3600), resp.ExpiresIn)
+}
+
+func TestIssueJWT_Cookie(t *testing.T) {
+	key := []byte("hmac secret")
+	config := Config{
+		Key:         key,
+		Delivery:    DeliverCookie,
+		RedirectURL: "/app",
+		Cookie:      gologin.CookieConfig{Name: "jwt", Path: "/", HTTPOnly: true, Secure: true},
+	}
+	w := httptest.NewRecorder()
+	IssueJWT(config, testutils.AssertFailureNotCalled(t)).ServeHTTP(w, profileRequest(testProfile))
+
+	// IssueJWT with DeliverCookie assert that:
+	// - the JWT is set as the configured cookie, expiring with the TTL
+	// - the user is redirected to the RedirectURL
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "/app", w.HeaderMap.Get("Location"))
+	cookies := w.Result().Cookies()
+	if assert.Len(t, cookies, 1) {
+		assert.Equal(t, "jwt", cookies[0].Name)
+		assert.True(t, cookies[0].HttpOnly)
+		assert.True(t, cookies[0].Secure)
+		assert.Equal(t, 3600, cookies[0].MaxAge)
+		_, claims := parseJWT(t, cookies[0].Value, key)
+		assert.Equal(t, "github:917408", claims["sub"])
+	}
+}
+
+func TestIssueJWT_Fragment(t *testing.T) {
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	config := Config{
+		Key:         ecKey,
+		KeyID:       "key1",
+		Delivery:    DeliverFragment,
+		RedirectURL: "https://app.example.com/login/done",
+	}
+	w := httptest.NewRecorder()
+	IssueJWT(config, testutils.AssertFailureNotCalled(t)).ServeHTTP(w, profileRequest(testProfile))
+
+	// IssueJWT with DeliverFragment assert that:
+	// - the user is redirected with the JWT in the URL fragment
+	assert.Equal(t, http.StatusFound, w.Code)
+	location, err := url.Parse(w.HeaderMap.Get("Location"))
+	if assert.Nil(t, err) {
+		assert.Equal(t, "app.example.com", location.Host)
+		assert.Equal(t, "/login/done", location.Path)
+		assert.Empty(t, location.RawQuery)
+		fragment, _ := url.ParseQuery(location.Fragment)
+		assert.Equal(t, "Bearer", fragment.Get("token_type"))
+		assert.Equal(t, "3600", fragment.Get("expires_in"))
+		alg, claims := parseJWT(t, fragment.Get("access_token"), &ecKey.PublicKey)
+		assert.Equal(t, "ES256", alg)
+		assert.Equal(t, "github:917408", claims["sub"])
+	}
+}
+
+func TestIssueJWT_Errors(t *testing.T) {
+	p384Key, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	claimsErr := errors.New("unknown user")
+	cases := []struct {
+		config   Config
+		req      *http.Request
+		expected string
+	}{
+		// missing Profile
+		{Config{Key: []byte("hmac secret")}, profileRequest(nil), "Context missing Profile"},
+		// unsupported keys
+		{Config{}, profileRequest(testProfile), ErrUnsupportedKey.Error()},
+		{Config{Key: []byte{}}, profileRequest(testProfile), ErrUnsupportedKey.Error()},
+		{Config{Key: "hmac secret"}, profileRequest(testProfile), ErrUnsupportedKey.Error()},
+		{Config{Key: p384Key}, profileRequest(testProfile), ErrUnsupportedKey.Error()},
+		// Claims hook error
+		{Config{Key: []byte("hmac secret"), Claims: func(ctx context.Context, profile *gologin.Profile) (map[string]interface{}, error) {
+			return nil, claimsErr
+		}}, profileRequest(testProfile), claimsErr.Error()},
+	}
+	for _, c := range cases {
+		failure := func(w http.ResponseWriter, req *http.Request) {
+			err := gologin.ErrorFromContext(req.Context())
+			if assert.NotNil(t, err) {
+				assert.Equal(t, c.expected, err.Error())
+			}
+			fmt.Fprintf(w, "failure handler called")
+		}
+		w := httptest.NewRecorder()
+		IssueJWT(c.config, http.HandlerFunc(failure)).ServeHTTP(w, c.req)
+
+		// IssueJWT errors assert that:
+		// - the failure handler is called without writing a JWT
+		assert.Equal(t, "failure handler called", w.Body.String())
+		assert.Empty(t, w.Result().Cookies())
+	}
+}
@@ -0,0 +1,132 @@
+package jwtlogin
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/dghubble/gologin"
+)
+
+// ErrUnsupportedKey is returned when the Config Key is not an HS256 secret,
+// RSA private key, or P-256 ECDSA private key.
+var ErrUnsupportedKey = errors.New("jwtlogin: key must be a []byte secret, RSA, or ECDSA P-256 private key")
+
+const defaultTTL = time.Hour
+
+// signingAlg returns the JWS algorithm of the key.
+func signingAlg(key interface{}) (string, error) {
+	switch key := key.(type) {
+	case []byte:
+		if len(key) == 0 {
+			return "", ErrUnsupportedKey
+		}
+		return "HS256", nil
+	case *rsa.PrivateKey:
+		return "RS256", nil
+	case *ecdsa.PrivateKey:
+		if key.Curve != elliptic.P256() {
+			return "", ErrUnsupportedKey
+		}
+		return "ES256", nil
+	}
+	return "", ErrUnsupportedKey
+}
+
+// claims returns the JWT claims of the Profile, issued at now.
+func (c Config) claims(ctx context.Context, profile *gologin.Profile, now time.Time) (map[string]interface{}, error) {
+	claims := make(map[string]interface{})
+	if c.Claims != nil {
+		extra, err := c.Claims(ctx, profile)
+		if err != nil {
+			return nil, err
+		}
+		for name, value := range extra {
+			claims[name] = value
+		}
+	}
+	// registered claims are set last so extra claims cannot override them
+	claims["sub"] = profile.Provider + ":" + profile.ID
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(c.ttl()).Unix()
+	if c.Issuer != "" {
+		claims["iss"] = c.Issuer
+	}
+	if c.Audience != "" {
+		claims["aud"] = c.Audience
+	}
+	if profile.Email != "" {
+		claims["email"] = profile.Email
+		claims["email_verified"] = profile.EmailVerified
+	}
+	if profile.Name != "" {
+		claims["name"] = profile.Name
+	}
+	return claims, nil
+}
+
+func (c Config) ttl() time.Duration {
+	if c.TTL <= 0 {
+		return defaultTTL
+	}
+	return c.TTL
+}
+
+// sign returns the signed compact JWT of the claims.
+func (c Config) sign(claims map[string]interface{}) (string, error) {
+	alg, err := signingAlg(c.Key)
+	if err != nil {
+		return "", err
+	}
+	header := map[string]string{"alg": alg, "typ": "JWT"}
+	if c.KeyID != "" {
+		header["kid"] = c.KeyID
+	}
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString(claimsJSON)
+	signature, err := signature(c.Key, []byte(signingInput))
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// signature returns the JWS signature of the signing input.
+func signature(key interface{}, signingInput []byte) ([]byte, error) {
+	digest := sha256.Sum256(signingInput)
+	switch key := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, key)
+		mac.Write(signingInput)
+		return mac.Sum(nil), nil
+	case *rsa.PrivateKey:
+		return rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+		if err != nil {
+			return nil, err
+		}
+		// JWS ES256 signatures are the 32 byte big-endian R and S
+		signature := make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+		return signature, nil
+	}
+	return nil, ErrUnsupportedKey
+}
@@ -0,0 +1,2 @@
+// Package kakao provides Kakao OAuth2 login and callback handlers.
+package kakao
@@ -0,0 +1,41 @@
+package kakao
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/dghubble/gologin/testutils"
+)
+
+const (
+	// testUserJSON is a user/me response with the profile and email.
+	testUserJSON = `{"id": 1234567890, "connected_at": "2022-04-11T01:45:28Z", "kakao_account": {"profile_nickname_needs_agreement": false, "profile_image_needs_agreement": false, "profile": {"nickname": "Ryan", "thumbnail_image_url": "http://k.kakaocdn.net/img_110x110.jpg", "profile_image_url": "http://k.kakaocdn.net/img_640x640.jpg", "is_default_image": false}, "has_email": true, "email_needs_agreement": false, "is_email_valid": true, "is_email_verified": true, "email": "ryan@kakao.com"}}`
+	// testDeclinedUserJSON is a user/me response of a user who declined the
+	// email consent item.
+	testDeclinedUserJSON = `{"id": 1234567890, "connected_at": "2022-04-11T01:45:28Z", "kakao_account": {"profile": {"nickname": "Ryan"}, "has_email": true, "email_needs_agreement": true}}`
+	// testInvalidTokenJSON is a Kakao error response.
+	testInvalidTokenJSON = `{"msg": "this access token does not exist", "code": -401}`
+)
+
+// newKakaoTestServer returns a new httptest.Server which mocks the Kakao
+// token and user/me endpoints and a client which proxies requests to the
+// server. The user/me endpoint responds with the given json data, or a -401
+// error for tokens other than "any-token". The caller must close the server.
+func newKakaoTestServer(jsonData string) (*http.Client, *httptest.Server) {
+	client, mux, server := testutils.TestServer()
+	mux.HandleFunc("/oauth/token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token": "any-token", "token_type": "bearer", "refresh_token": "any-refresh", "expires_in": 21599, "scope": "profile_nickname account_email", "refresh_token_expires_in": 5183999}`)
+	})
+	mux.HandleFunc("/v2/user/me", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json;charset=UTF-8")
+		if r.Header.Get("Authorization") != "Bearer any-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprintf(w, testInvalidTokenJSON)
+			return
+		}
+		fmt.Fprintf(w, jsonData)
+	})
+	return client, server
+}
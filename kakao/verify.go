@@ -0,0 +1,81 @@
+package kakao
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/dghubble/gologin/internal/jsonclient"
+)
+
+const kakaoAPI = "https://kapi.kakao.com/"
+
+// User is a Kakao user, flattened from the user/me kakao_account. Users may
+// decline to share their profile or email, so those fields may be empty.
+type User struct {
+	ID              int64
+	Nickname        string
+	ProfileImageURL string
+	Email           string
+	EmailVerified   bool
+}
+
+// userResponse is a Kakao user/me response.
+type userResponse struct {
+	ID      int64 `json:"id"`
+	Account struct {
+		Profile struct {
+			Nickname        string `json:"nickname"`
+			ProfileImageURL string `json:"profile_image_url"`
+		} `json:"profile"`
+		Email           string `json:"email"`
+		IsEmailVerified bool   `json:"is_email_verified"`
+	} `json:"kakao_account"`
+}
+
+// user returns the flattened User.
+func (r *userResponse) user() *User {
+	return &User{
+		ID:              r.ID,
+		Nickname:        r.Account.Profile.Nickname,
+		ProfileImageURL: r.Account.Profile.ProfileImageURL,
+		Email:           r.Account.Email,
+		EmailVerified:   r.Account.IsEmailVerified,
+	}
+}
+
+// APIError is a Kakao API error response.
+// https://developers.kakao.com/docs/latest/en/rest-api/reference#response-code
+type APIError struct {
+	Code    int    `json:"code"`
+	Message string `json:"msg"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("kakao: %s (code %d)", e.Message, e.Code)
+}
+
+// client is a Kakao client for obtaining the current User.
+type client struct {
+	json *jsonclient.Client
+}
+
+// newClient returns a new Kakao client.
+func newClient(httpClient *http.Client) *client {
+	return &client{
+		json: jsonclient.New(httpClient, kakaoAPI),
+	}
+}
+
+// Me returns the current Kakao User. If Kakao responds with an error, it is
+// returned as an *APIError.
+// https://developers.kakao.com/docs/latest/en/kakaologin/rest-api#req-user-info
+func (c *client) Me(ctx context.Context) (*User, *http.Response, error) {
+	userResp := new(userResponse)
+	apiErr := new(APIError)
+	resp, err := c.json.Get(ctx, "v2/user/me", nil, userResp, apiErr)
+	if err == nil && apiErr.Code != 0 {
+		err = apiErr
+	}
+	return userResp.user(), resp, err
+}
@@ -0,0 +1,47 @@
+package keycloak
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/dghubble/gologin"
+)
+
+// unexported key type prevents collisions
+type key int
+
+const (
+	userKey key = iota
+)
+
+// WithUser returns a copy of ctx that stores the Keycloak User and its gologin
+// Profile.
+func WithUser(ctx context.Context, user *User) context.Context {
+	ctx = gologin.WithProfile(ctx, newProfile(user))
+	return context.WithValue(ctx, userKey, user)
+}
+
+// UserFromContext returns the Keycloak User from the ctx.
+func UserFromContext(ctx context.Context) (*User, error) {
+	user, ok := ctx.Value(userKey).(*User)
+	if !ok {
+		return nil, fmt.Errorf("keycloak: Context missing Keycloak User")
+	}
+	return user, nil
+}
+
+// newProfile returns the gologin Profile of the Keycloak User.
+func newProfile(user *User) *gologin.Profile {
+	if user == nil {
+		return nil
+	}
+	return &gologin.Profile{
+		Provider:      ProviderName,
+		ID:            user.ID,
+		Email:         user.Email,
+		EmailVerified: user.EmailVerified,
+		Name:          gologin.DisplayName(strings.TrimSpace(user.GivenName+" "+user.FamilyName), user.Username),
+		Raw:           gologin.ProfileRaw(user, "sub", "email", "email_verified"),
+	}
+}
@@ -0,0 +1,2 @@
+// Package keycloak provides Keycloak OAuth2 login and callback handlers.
+package keycloak
@@ -0,0 +1,167 @@
+package keycloak
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/internal"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"golang.org/x/oauth2"
+)
+
+// ProviderName is the provider name set in the ctx (see
+// gologin.ProviderFromContext) and the Profile.
+const ProviderName = "keycloak"
+
+// Keycloak login errors
+var (
+	ErrUnableToGetKeycloakUser = errors.New("keycloak: unable to get Keycloak User")
+	ErrMissingRole             = errors.New("keycloak: Keycloak User does not have the required role")
+)
+
+// Config configures Keycloak login.
+type Config struct {
+	// BaseURL is the URL of the Keycloak server, including any path prefix
+	// (e.g. "https://sso.example.com").
+	BaseURL string
+	// Realm is the name of the realm users belong to.
+	Realm string
+	// LegacyPathPrefix adds the /auth path prefix of Keycloak versions before
+	// 17 (WildFly distributions), e.g. /auth/realms/{realm}.
+	LegacyPathPrefix bool
+	// RequireRole requires the User to have the realm role (or the client
+	// role if RequireRoleClient is set). Callbacks for other Users fail with
+	// ErrMissingRole. The realm's role mappers must add roles to userinfo.
+	RequireRole string
+	// RequireRoleClient is the client ID whose client role RequireRole is.
+	RequireRoleClient string
+}
+
+// Endpoint returns the OAuth2 endpoint of the Config realm. Panics if the
+// BaseURL is not an absolute URL or the Realm is empty.
+func (c Config) Endpoint() oauth2.Endpoint {
+	realmURL := c.mustRealmURL()
+	return oauth2.Endpoint{
+		AuthURL:   realmURL + "/protocol/openid-connect/auth",
+		TokenURL:  realmURL + "/protocol/openid-connect/token",
+		AuthStyle: oauth2.AuthStyleInHeader,
+	}
+}
+
+// mustRealmURL returns the URL of the Config realm without a trailing slash.
+// It panics if the BaseURL or Realm is invalid.
+func (c Config) mustRealmURL() string {
+	u, err := url.Parse(c.BaseURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		panic("keycloak: invalid Config BaseURL " + c.BaseURL)
+	}
+	if c.Realm == "" {
+		panic("keycloak: missing Config Realm")
+	}
+	realmURL := strings.TrimRight(u.String(), "/")
+	if c.LegacyPathPrefix {
+		realmURL += "/auth"
+	}
+	return realmURL + "/realms/" + url.PathEscape(c.Realm)
+}
+
+// hasRequiredRole returns true if the User has the Config RequireRole (or if
+// no role is required).
+func (c Config) hasRequiredRole(user *User) bool {
+	if c.RequireRole == "" {
+		return true
+	}
+	if c.RequireRoleClient != "" {
+		return user.HasClientRole(c.RequireRoleClient, c.RequireRole)
+	}
+	return user.HasRealmRole(c.RequireRole)
+}
+
+// StateHandler checks for a state cookie. If found, the state value is read
+// and added to the ctx. Otherwise, a non-guessable value is added to the ctx
+// and to a (short-lived) state cookie issued to the requester.
+//
+// Implements OAuth 2 RFC 6749 10.12 CSRF Protection. If you wish to issue
+// state params differently, write a http.Handler which sets the ctx state,
+// using oauth2 WithState(ctx, state) since it is required by LoginHandler
+// and CallbackHandler.
+func StateHandler(config gologin.CookieConfig, success http.Handler) http.Handler {
+	return oauth2Login.StateHandler(config, success)
+}
+
+// LoginHandler handles Keycloak login requests by reading the state value
+// from the ctx and redirecting requests to the AuthURL with that state value.
+// Any AuthCodeOptions are added to the AuthURL.
+//
+// The config Endpoint should be the keycloak Config Endpoint and Scopes
+// should include "openid" to get the Keycloak User.
+func LoginHandler(config *oauth2.Config, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	return oauth2Login.LoginHandler(config, failure, opts...)
+}
+
+// CallbackHandler handles Keycloak redirection URI requests and adds the
+// Keycloak access token and User to the ctx. The User is read from the
+// userinfo endpoint of the Config realm. If authentication succeeds, handling
+// delegates to the success handler, otherwise to the failure handler.
+// Any AuthCodeOptions are sent with the token exchange.
+//
+// If the Config has a RequireRole, callbacks for Users without the role fail
+// with ErrMissingRole. Panics if the Config BaseURL or Realm is invalid.
+func CallbackHandler(config *oauth2.Config, keycloakConfig Config, success, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	success = keycloakHandler(config, keycloakConfig, success, failure)
+	return oauth2Login.CallbackHandler(config, success, failure, opts...)
+}
+
+// keycloakHandler is a http.Handler that gets the OAuth2 Token from the ctx
+// to get the corresponding Keycloak User (and, per the Config, check its
+// roles). If successful, the User is added to the ctx and the success handler
+// is called. Otherwise, the failure handler is called.
+func keycloakHandler(config *oauth2.Config, keycloakConfig Config, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	realmURL := keycloakConfig.mustRealmURL()
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		httpClient := internal.OAuth2Client(ctx, config, token)
+		user, resp, err := newClient(httpClient, realmURL).UserInfo(ctx)
+		err = validateResponse(user, resp, err)
+		if err == nil && !keycloakConfig.hasRequiredRole(user) {
+			err = ErrMissingRole
+		}
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		ctx = WithUser(ctx, user)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// validateResponse returns an error if the given Keycloak User, raw
+// http.Response, or error are unexpected. Returns nil if they are valid, or a
+// *gologin.Error which preserves the cause and status code.
+func validateResponse(user *User, resp *http.Response, err error) error {
+	var status int
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	if err != nil || status != http.StatusOK {
+		return &gologin.Error{Provider: "keycloak", Op: "get user", StatusCode: status, Err: err, Kind: ErrUnableToGetKeycloakUser}
+	}
+	if user == nil || user.ID == "" {
+		return &gologin.Error{Provider: "keycloak", Op: "get user", StatusCode: status, Kind: ErrUnableToGetKeycloakUser}
+	}
+	return nil
+}
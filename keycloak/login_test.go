@@ -0,0 +1,201 @@
+package keycloak
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dghubble/gologin"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+var testKeycloakConfig = Config{BaseURL: testBaseURL, Realm: "acme"}
+
+func testConfig(keycloakConfig Config) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     "my-app",
+		ClientSecret: "client_secret",
+		RedirectURL:  "https://example.com/keycloak/callback",
+		Endpoint:     keycloakConfig.Endpoint(),
+		Scopes:       []string{"openid", "profile", "email"},
+	}
+}
+
+func TestConfigEndpoint(t *testing.T) {
+	cases := []struct {
+		config   Config
+		authURL  string
+		tokenURL string
+	}{
+		{Config{BaseURL: "https://sso.example.com", Realm: "acme"}, "https://sso.example.com/realms/acme/protocol/openid-connect/auth", "https://sso.example.com/realms/acme/protocol/openid-connect/token"},
+		{Config{BaseURL: "https://sso.example.com/", Realm: "acme", LegacyPathPrefix: true}, "https://sso.example.com/auth/realms/acme/protocol/openid-connect/auth", "https://sso.example.com/auth/realms/acme/protocol/openid-connect/token"},
+		{Config{BaseURL: "https://example.com/keycloak", Realm: "my realm"}, "https://example.com/keycloak/realms/my%20realm/protocol/openid-connect/auth", "https://example.com/keycloak/realms/my%20realm/protocol/openid-connect/token"},
+	}
+	for _, c := range cases {
+		endpoint := c.config.Endpoint()
+		assert.Equal(t, c.authURL, endpoint.AuthURL)
+		assert.Equal(t, c.tokenURL, endpoint.TokenURL)
+		assert.Equal(t, oauth2.AuthStyleInHeader, endpoint.AuthStyle)
+	}
+	assert.Panics(t, func() { Config{BaseURL: "sso.example.com", Realm: "acme"}.Endpoint() })
+	assert.Panics(t, func() { Config{BaseURL: testBaseURL}.Endpoint() })
+	assert.Panics(t, func() { CallbackHandler(testConfig(testKeycloakConfig), Config{Realm: "acme"}, nil, nil) })
+}
+
+func TestCallbackHandler(t *testing.T) {
+	expectedUser := &User{
+		ID:             "f1a2b3c4-d5e6-4789-abcd-ef0123456789",
+		Username:       "jdoe",
+		Email:          "jdoe@example.com",
+		EmailVerified:  true,
+		GivenName:      "Jane",
+		FamilyName:     "Doe",
+		RealmAccess:    Access{Roles: []string{"offline_access", "admin"}},
+		ResourceAccess: map[string]Access{"my-app": {Roles: []string{"editor"}}, "account": {Roles: []string{"manage-account", "view-profile"}}},
+	}
+	cases := []struct {
+		pathPrefix     string
+		keycloakConfig Config
+	}{
+		{"", testKeycloakConfig},
+		{"/auth", Config{BaseURL: testBaseURL, Realm: "acme", LegacyPathPrefix: true}},
+	}
+	for _, c := range cases {
+		proxyClient, server := newKeycloakTestServer(c.pathPrefix, testUserInfoJSON)
+		// oauth2 Client will use the proxy client's base Transport
+		ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+		ctx = oauth2Login.WithState(ctx, "d4e5f6")
+
+		success := func(w http.ResponseWriter, req *http.Request) {
+			ctx := req.Context()
+			token, err := oauth2Login.TokenFromContext(ctx)
+			if assert.Nil(t, err) {
+				assert.Equal(t, "any-token", token.AccessToken)
+			}
+			user, err := UserFromContext(ctx)
+			if assert.Nil(t, err) {
+				assert.Equal(t, expectedUser, user)
+			}
+			fmt.Fprintf(w, "success handler called")
+		}
+		failure := testutils.AssertFailureNotCalled(t)
+
+		// CallbackHandler assert that:
+		// - the token and userinfo endpoints have the Config path prefix
+		// - success handler is called
+		// - Keycloak Token and User (with roles) are added to the ctx of the
+		// success handler
+		callbackHandler := CallbackHandler(testConfig(c.keycloakConfig), c.keycloakConfig, http.HandlerFunc(success), failure)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+		callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+		assert.Equal(t, "success handler called", w.Body.String(), c.pathPrefix)
+		server.Close()
+	}
+}
+
+func TestKeycloakHandler_RequireRole(t *testing.T) {
+	cases := []struct {
+		name     string
+		userInfo string
+		config   Config
+		err      error
+	}{
+		{"realm role", testUserInfoJSON, Config{RequireRole: "admin"}, nil},
+		{"client role", testUserInfoJSON, Config{RequireRole: "editor", RequireRoleClient: "my-app"}, nil},
+		{"missing realm role", testUserInfoJSON, Config{RequireRole: "editor"}, ErrMissingRole},
+		{"missing client role", testUserInfoJSON, Config{RequireRole: "admin", RequireRoleClient: "my-app"}, ErrMissingRole},
+		{"unknown client", testUserInfoJSON, Config{RequireRole: "editor", RequireRoleClient: "other-app"}, ErrMissingRole},
+		{"no roles", testNoRolesUserInfoJSON, Config{RequireRole: "admin"}, ErrMissingRole},
+		{"no required role", testNoRolesUserInfoJSON, Config{}, nil},
+	}
+	for _, c := range cases {
+		proxyClient, server := newKeycloakTestServer("", c.userInfo)
+		// oauth2 Client will use the proxy client's base Transport
+		ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+		ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+
+		success := func(w http.ResponseWriter, req *http.Request) {
+			assert.Nil(t, c.err, c.name)
+			fmt.Fprintf(w, "success handler called")
+		}
+		failure := func(w http.ResponseWriter, req *http.Request) {
+			assert.Equal(t, c.err, gologin.ErrorFromContext(req.Context()), c.name)
+			fmt.Fprintf(w, "failure handler called")
+		}
+
+		// KeycloakHandler with a Config RequireRole, assert that:
+		// - success handler is called for Users with the realm (or client)
+		// role
+		// - failure handler is called with ErrMissingRole otherwise
+		c.config.BaseURL, c.config.Realm = testBaseURL, "acme"
+		keycloakHandler := keycloakHandler(testConfig(c.config), c.config, http.HandlerFunc(success), http.HandlerFunc(failure))
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		keycloakHandler.ServeHTTP(w, req.WithContext(ctx))
+		assert.NotEmpty(t, w.Body.String(), c.name)
+		server.Close()
+	}
+}
+
+func TestKeycloakHandler_MissingCtxToken(t *testing.T) {
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "oauth2: Context missing Token", err.Error())
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// KeycloakHandler called without Token in ctx, assert that:
+	// - failure handler is called
+	// - error about ctx missing token is added to the failure handler ctx
+	keycloakHandler := keycloakHandler(testConfig(testKeycloakConfig), testKeycloakConfig, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	keycloakHandler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestKeycloakHandler_ErrorGettingUser(t *testing.T) {
+	proxyClient, server := newKeycloakTestServer("", testUserInfoJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "invalid-token"})
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.True(t, errors.Is(err, ErrUnableToGetKeycloakUser))
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// KeycloakHandler cannot get Keycloak User, assert that:
+	// - failure handler is called
+	// - error cannot get Keycloak User added to the failure handler ctx
+	keycloakHandler := keycloakHandler(testConfig(testKeycloakConfig), testKeycloakConfig, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	keycloakHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestValidateResponse(t *testing.T) {
+	validUser := &User{ID: "f1a2b3c4-d5e6-4789-abcd-ef0123456789", Username: "jdoe"}
+	validResponse := &http.Response{StatusCode: 200}
+	invalidResponse := &http.Response{StatusCode: 500}
+	assert.Equal(t, nil, validateResponse(validUser, validResponse, nil))
+	assert.True(t, errors.Is(validateResponse(validUser, validResponse, fmt.Errorf("Server error")), ErrUnableToGetKeycloakUser))
+	assert.True(t, errors.Is(validateResponse(validUser, invalidResponse, nil), ErrUnableToGetKeycloakUser))
+	assert.True(t, errors.Is(validateResponse(&User{Username: "jdoe"}, validResponse, nil), ErrUnableToGetKeycloakUser))
+}
@@ -0,0 +1,46 @@
+package keycloak
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/dghubble/gologin/testutils"
+)
+
+const (
+	// testBaseURL is the Keycloak server of tests.
+	testBaseURL = "https://sso.example.com"
+	// testUserInfoJSON is a userinfo response with realm and client roles.
+	testUserInfoJSON = `{"sub": "f1a2b3c4-d5e6-4789-abcd-ef0123456789", "email_verified": true, "name": "Jane Doe", "preferred_username": "jdoe", "given_name": "Jane", "family_name": "Doe", "email": "jdoe@example.com", "realm_access": {"roles": ["offline_access", "admin"]}, "resource_access": {"my-app": {"roles": ["editor"]}, "account": {"roles": ["manage-account", "view-profile"]}}}`
+	// testNoRolesUserInfoJSON is a userinfo response without roles.
+	testNoRolesUserInfoJSON = `{"sub": "f1a2b3c4-d5e6-4789-abcd-ef0123456789", "email_verified": false, "preferred_username": "jdoe", "email": "jdoe@example.com"}`
+)
+
+// newKeycloakTestServer returns a new httptest.Server which mocks the token
+// and userinfo endpoints of the "acme" realm, under the given path prefix
+// (e.g. "" or "/auth"), and a client which proxies requests to the server.
+// The userinfo endpoint responds with the given json data. The caller must
+// close the server.
+func newKeycloakTestServer(pathPrefix, jsonData string) (*http.Client, *httptest.Server) {
+	client, mux, server := testutils.TestServer()
+	mux.HandleFunc(pathPrefix+"/realms/acme/protocol/openid-connect/token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if _, _, ok := r.BasicAuth(); !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprintf(w, `{"error": "unauthorized_client", "error_description": "Invalid client or Invalid client credentials"}`)
+			return
+		}
+		fmt.Fprintf(w, `{"access_token": "any-token", "expires_in": 300, "refresh_expires_in": 1800, "refresh_token": "any-refresh", "token_type": "Bearer", "id_token": "any-id-token", "session_state": "7c3d8e8f", "scope": "openid profile email"}`)
+	})
+	mux.HandleFunc(pathPrefix+"/realms/acme/protocol/openid-connect/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Header.Get("Authorization") != "Bearer any-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprintf(w, `{"error": "invalid_token", "error_description": "Token verification failed"}`)
+			return
+		}
+		fmt.Fprintf(w, jsonData)
+	})
+	return client, server
+}
@@ -0,0 +1,68 @@
+package keycloak
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/dghubble/gologin/internal/jsonclient"
+)
+
+// User is a Keycloak user from the OpenID Connect userinfo endpoint. Roles
+// are only included if the realm's role mappers add them to userinfo.
+type User struct {
+	ID            string `json:"sub"`
+	Username      string `json:"preferred_username"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	GivenName     string `json:"given_name"`
+	FamilyName    string `json:"family_name"`
+	// RealmAccess has the User's realm roles
+	RealmAccess Access `json:"realm_access"`
+	// ResourceAccess has the User's client roles, by client ID
+	ResourceAccess map[string]Access `json:"resource_access"`
+}
+
+// Access is a set of Keycloak roles.
+type Access struct {
+	Roles []string `json:"roles"`
+}
+
+// HasRole returns true if the Access includes the role.
+func (a Access) HasRole(role string) bool {
+	for _, r := range a.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// HasRealmRole returns true if the User has the realm role.
+func (u *User) HasRealmRole(role string) bool {
+	return u.RealmAccess.HasRole(role)
+}
+
+// HasClientRole returns true if the User has the role of the client.
+func (u *User) HasClientRole(clientID, role string) bool {
+	return u.ResourceAccess[clientID].HasRole(role)
+}
+
+// client is a Keycloak client for obtaining a User.
+type client struct {
+	json *jsonclient.Client
+}
+
+// newClient returns a new Keycloak client for the (normalized) realm URL.
+func newClient(httpClient *http.Client, realmURL string) *client {
+	return &client{
+		json: jsonclient.New(httpClient, realmURL+"/"),
+	}
+}
+
+// UserInfo gets the current Keycloak User.
+// https://www.keycloak.org/docs/latest/securing_apps/#userinfo-endpoint
+func (c *client) UserInfo(ctx context.Context) (*User, *http.Response, error) {
+	user := new(User)
+	resp, err := c.json.Get(ctx, "protocol/openid-connect/userinfo", nil, user, nil)
+	return user, resp, err
+}
@@ -0,0 +1,2 @@
+// Package line provides LINE Login (v2.1) OAuth2 login and callback handlers.
+package line
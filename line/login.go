@@ -0,0 +1,178 @@
+package line
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/internal"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/dghubble/gologin/oidc"
+	"golang.org/x/oauth2"
+)
+
+// ProviderName is the provider name set in the ctx (see
+// gologin.ProviderFromContext) and the Profile.
+const ProviderName = "line"
+
+// LINE login errors
+var (
+	ErrUnableToGetLINEUser   = errors.New("line: unable to get LINE User")
+	ErrUnableToVerifyIDToken = errors.New("line: unable to verify LINE id_token")
+)
+
+// Endpoint is LINE Login's (v2.1) OAuth2 endpoint.
+var Endpoint = oauth2.Endpoint{
+	AuthURL:   "https://access.line.me/oauth2/v2.1/authorize",
+	TokenURL:  "https://api.line.me/oauth2/v2.1/token",
+	AuthStyle: oauth2.AuthStyleInParams,
+}
+
+// LINE bot_prompt values
+const (
+	// BotPromptNormal adds an option to add the channel's LINE Official
+	// Account as a friend to the consent screen.
+	BotPromptNormal = "normal"
+	// BotPromptAggressive asks to add the LINE Official Account as a friend
+	// on a separate screen after the consent screen.
+	BotPromptAggressive = "aggressive"
+)
+
+// BotPrompt returns an AuthCodeOption which sets bot_prompt to ask users to
+// add the LINE Official Account linked to the channel as a friend (see
+// BotPromptNormal and BotPromptAggressive).
+func BotPrompt(prompt string) oauth2.AuthCodeOption {
+	return oauth2.SetAuthURLParam("bot_prompt", prompt)
+}
+
+// StateHandler checks for a state cookie. If found, the state value is read
+// and added to the ctx. Otherwise, a non-guessable value is added to the ctx
+// and to a (short-lived) state cookie issued to the requester.
+//
+// Implements OAuth 2 RFC 6749 10.12 CSRF Protection. If you wish to issue
+// state params differently, write a http.Handler which sets the ctx state,
+// using oauth2 WithState(ctx, state) since it is required by LoginHandler
+// and CallbackHandler.
+func StateHandler(config gologin.CookieConfig, success http.Handler) http.Handler {
+	return oauth2Login.StateHandler(config, success)
+}
+
+// NonceHandler adds an OpenID Connect nonce to the ctx, which LoginHandler
+// sends and CallbackHandler checks against the id_token. LINE requires a
+// nonce when the openid scope is requested. See oauth2 NonceHandler.
+func NonceHandler(config gologin.CookieConfig, success, failure http.Handler) http.Handler {
+	return oauth2Login.NonceHandler(config, success, failure)
+}
+
+// LoginHandler handles LINE login requests by reading the state value (and
+// nonce, if any) from the ctx and redirecting requests to the AuthURL with
+// that state value. Any AuthCodeOptions (e.g. BotPrompt) are added to the
+// AuthURL.
+//
+// Scopes should include "profile" to get the LINE User, and "openid" and
+// "email" to get its email (with a NonceHandler).
+func LoginHandler(config *oauth2.Config, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	return oauth2Login.LoginHandler(config, failure, opts...)
+}
+
+// CallbackHandler handles LINE redirection URI requests and adds the LINE
+// access token and User to the ctx. If authentication succeeds, handling
+// delegates to the success handler, otherwise to the failure handler.
+// Any AuthCodeOptions are sent with the token exchange.
+func CallbackHandler(config *oauth2.Config, success, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	success = lineHandler(config, success, failure)
+	return oauth2Login.CallbackHandler(config, success, failure, opts...)
+}
+
+// lineHandler is a http.Handler that gets the OAuth2 Token from the ctx to
+// get the corresponding LINE User. If successful, the User is added to the
+// ctx and the success handler is called. Otherwise, the failure handler is
+// called.
+//
+// If the Token has an id_token (i.e. the openid scope was requested), it is
+// verified with LINE and its email is added to the User. If the ctx contains
+// an OpenID Connect nonce (see NonceHandler), the id_token is required and its
+// nonce must match.
+func lineHandler(config *oauth2.Config, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		httpClient := internal.OAuth2Client(ctx, config, token)
+		user, resp, err := newClient(httpClient).Profile(ctx)
+		err = validateResponse(user, resp, err)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		nonce, _ := oauth2Login.NonceFromContext(ctx)
+		rawIDToken, _ := token.Extra("id_token").(string)
+		if rawIDToken == "" && nonce != "" {
+			ctx = gologin.WithError(ctx, oidc.ErrMissingIDToken)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		if rawIDToken != "" {
+			claims, resp, err := newClient(internal.ContextClient(ctx)).VerifyIDToken(ctx, rawIDToken, config.ClientID, nonce)
+			err = validateIDTokenResponse(claims, resp, err)
+			if err == nil && nonce != "" && claims.Nonce != nonce {
+				err = oidc.ErrInvalidNonce
+			}
+			if err == nil && claims.Subject != user.UserID {
+				err = oidc.ErrUserInfoSubjectMismatch
+			}
+			if err != nil {
+				ctx = gologin.WithError(ctx, err)
+				failure.ServeHTTP(w, req.WithContext(ctx))
+				return
+			}
+			user.Email = claims.Email
+		}
+		ctx = WithUser(ctx, user)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// validateResponse returns an error if the given LINE User, raw
+// http.Response, or error are unexpected. Returns nil if they are valid, or a
+// *gologin.Error which preserves the cause and status code.
+func validateResponse(user *User, resp *http.Response, err error) error {
+	var status int
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	if err != nil || status != http.StatusOK {
+		return &gologin.Error{Provider: "line", Op: "get user", StatusCode: status, Err: err, Kind: ErrUnableToGetLINEUser}
+	}
+	if user == nil || user.UserID == "" {
+		return &gologin.Error{Provider: "line", Op: "get user", StatusCode: status, Kind: ErrUnableToGetLINEUser}
+	}
+	return nil
+}
+
+// validateIDTokenResponse returns an error if the given id_token claims, raw
+// http.Response, or error of a verify request are unexpected. Returns nil if
+// they are valid, or a *gologin.Error which preserves the cause and status
+// code.
+func validateIDTokenResponse(claims *idTokenClaims, resp *http.Response, err error) error {
+	var status int
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	if err != nil || status != http.StatusOK {
+		return &gologin.Error{Provider: "line", Op: "verify id_token", StatusCode: status, Err: err, Kind: ErrUnableToVerifyIDToken}
+	}
+	if claims == nil || claims.Subject == "" {
+		return &gologin.Error{Provider: "line", Op: "verify id_token", StatusCode: status, Kind: ErrUnableToVerifyIDToken}
+	}
+	return nil
+}
@@ -0,0 +1,267 @@
+package line
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/dghubble/gologin"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/dghubble/gologin/oidc"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func testConfig() *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     "client_id",
+		ClientSecret: "client_secret",
+		RedirectURL:  "https://example.com/line/callback",
+		Endpoint:     Endpoint,
+		Scopes:       []string{"profile", "openid", "email"},
+	}
+}
+
+func TestLoginHandler_Options(t *testing.T) {
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// LoginHandler with BotPrompt and a ctx nonce, assert that:
+	// - redirects to the LINE AuthURL with the state and nonce
+	// - bot_prompt is requested
+	loginHandler := LoginHandler(testConfig(), failure, BotPrompt(BotPromptAggressive))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	ctx := oauth2Login.WithState(context.Background(), "d4e5f6")
+	ctx = oauth2Login.WithNonce(ctx, "n0nce")
+	loginHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, http.StatusFound, w.Code)
+	location, err := url.Parse(w.HeaderMap.Get("Location"))
+	if assert.Nil(t, err) {
+		assert.Equal(t, "access.line.me", location.Host)
+		assert.Equal(t, "d4e5f6", location.Query().Get("state"))
+		assert.Equal(t, "n0nce", location.Query().Get("nonce"))
+		assert.Equal(t, "aggressive", location.Query().Get("bot_prompt"))
+	}
+}
+
+func TestCallbackHandler_Profile(t *testing.T) {
+	proxyClient, server := newLINETestServer(testTokenJSON, testProfileJSON, testClaimsJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithState(ctx, "d4e5f6")
+
+	success := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "any-token", token.AccessToken)
+		}
+		user, err := UserFromContext(ctx)
+		if assert.Nil(t, err) {
+			expectedUser := &User{UserID: "U4af4980629d1f3ec1f3a3f4ca4d4f2a0", DisplayName: "Taro Line", PictureURL: "https://profile.line-scdn.net/abcdefghijklmn", StatusMessage: "Hello, LINE!"}
+			assert.Equal(t, expectedUser, user)
+		}
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// CallbackHandler with a profile scope Token, assert that:
+	// - the LINE User is obtained from the profile
+	// - success handler is called with the Token and User (without email)
+	callbackHandler := CallbackHandler(testConfig(), http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestCallbackHandler_IDToken(t *testing.T) {
+	proxyClient, server := newLINETestServer(testIDTokenJSON, testProfileJSON, testClaimsJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithState(ctx, "d4e5f6")
+	ctx = oauth2Login.WithNonce(ctx, "n0nce")
+
+	success := func(w http.ResponseWriter, req *http.Request) {
+		user, err := UserFromContext(req.Context())
+		if assert.Nil(t, err) {
+			expectedUser := &User{UserID: "U4af4980629d1f3ec1f3a3f4ca4d4f2a0", DisplayName: "Taro Line", PictureURL: "https://profile.line-scdn.net/abcdefghijklmn", StatusMessage: "Hello, LINE!", Email: "taro.line@example.com"}
+			assert.Equal(t, expectedUser, user)
+		}
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// CallbackHandler with an openid scope Token and a ctx nonce, assert that:
+	// - the id_token is verified with the channel and nonce
+	// - success handler is called with the User with the id_token email
+	callbackHandler := CallbackHandler(testConfig(), http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestLineHandler_IDTokenErrors(t *testing.T) {
+	cases := []struct {
+		name       string
+		tokenExtra map[string]interface{}
+		nonce      string
+		claimsJSON string
+		err        error
+	}{
+		{"missing id_token", nil, "n0nce", testClaimsJSON, oidc.ErrMissingIDToken},
+		{"rejected id_token", map[string]interface{}{"id_token": "other-id-token"}, "", testClaimsJSON, ErrUnableToVerifyIDToken},
+		{"rejected nonce", map[string]interface{}{"id_token": "any-id-token"}, "other-nonce", testClaimsJSON, ErrUnableToVerifyIDToken},
+		{"nonce mismatch", map[string]interface{}{"id_token": "any-id-token"}, "n0nce", `{"sub": "U4af4980629d1f3ec1f3a3f4ca4d4f2a0", "nonce": "other-nonce"}`, oidc.ErrInvalidNonce},
+		{"subject mismatch", map[string]interface{}{"id_token": "any-id-token"}, "", `{"sub": "Uffffffffffffffffffffffffffffffff"}`, oidc.ErrUserInfoSubjectMismatch},
+		{"missing subject", map[string]interface{}{"id_token": "any-id-token"}, "", `{"email": "taro.line@example.com"}`, ErrUnableToVerifyIDToken},
+	}
+	for _, c := range cases {
+		proxyClient, server := newLINETestServer(testIDTokenJSON, testProfileJSON, c.claimsJSON)
+		token := &oauth2.Token{AccessToken: "any-token"}
+		if c.tokenExtra != nil {
+			token = token.WithExtra(c.tokenExtra)
+		}
+		// oauth2 Client will use the proxy client's base Transport
+		ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+		ctx = oauth2Login.WithToken(ctx, token)
+		if c.nonce != "" {
+			ctx = oauth2Login.WithNonce(ctx, c.nonce)
+		}
+
+		success := testutils.AssertSuccessNotCalled(t)
+		failure := func(w http.ResponseWriter, req *http.Request) {
+			err := gologin.ErrorFromContext(req.Context())
+			assert.True(t, errors.Is(err, c.err), c.name)
+			fmt.Fprintf(w, "failure handler called")
+		}
+
+		// LineHandler with an invalid id_token, assert that:
+		// - failure handler is called with the error
+		lineHandler := lineHandler(testConfig(), success, http.HandlerFunc(failure))
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		lineHandler.ServeHTTP(w, req.WithContext(ctx))
+		assert.Equal(t, "failure handler called", w.Body.String(), c.name)
+		server.Close()
+	}
+}
+
+func TestLineHandler_VerifyAPIError(t *testing.T) {
+	proxyClient, server := newLINETestServer(testIDTokenJSON, testProfileJSON, testClaimsJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, (&oauth2.Token{AccessToken: "any-token"}).WithExtra(map[string]interface{}{"id_token": "expired-id-token"}))
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		var apiErr *APIError
+		if assert.True(t, errors.As(err, &apiErr)) {
+			assert.Equal(t, &APIError{Code: "invalid_request", Description: "Invalid IdToken."}, apiErr)
+			assert.Equal(t, "line: invalid_request: Invalid IdToken.", apiErr.Error())
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// LineHandler with an id_token LINE rejects, assert that:
+	// - failure handler is called
+	// - the error wraps the LINE *APIError
+	lineHandler := lineHandler(testConfig(), success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	lineHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestLineHandler_MissingUserID(t *testing.T) {
+	proxyClient, server := newLINETestServer(testTokenJSON, `{"displayName": "Taro Line"}`, testClaimsJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.True(t, errors.Is(err, ErrUnableToGetLINEUser))
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// LineHandler gets a profile without a userId, assert that:
+	// - failure handler is called
+	// - error cannot get LINE User added to the failure handler ctx
+	lineHandler := lineHandler(testConfig(), success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	lineHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestLineHandler_MissingCtxToken(t *testing.T) {
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "oauth2: Context missing Token", err.Error())
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// LineHandler called without Token in ctx, assert that:
+	// - failure handler is called
+	// - error about ctx missing token is added to the failure handler ctx
+	lineHandler := lineHandler(testConfig(), success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	lineHandler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestLineHandler_ErrorGettingUser(t *testing.T) {
+	proxyClient, server := testutils.NewErrorServer("LINE Service Down", http.StatusInternalServerError)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.True(t, errors.Is(err, ErrUnableToGetLINEUser))
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// LineHandler cannot get LINE User, assert that:
+	// - failure handler is called
+	// - error cannot get LINE User added to the failure handler ctx
+	lineHandler := lineHandler(testConfig(), success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	lineHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestValidateResponse(t *testing.T) {
+	validUser := &User{UserID: "U4af4980629d1f3ec1f3a3f4ca4d4f2a0"}
+	validResponse := &http.Response{StatusCode: 200}
+	invalidResponse := &http.Response{StatusCode: 500}
+	assert.Equal(t, nil, validateResponse(validUser, validResponse, nil))
+	assert.True(t, errors.Is(validateResponse(validUser, validResponse, fmt.Errorf("Server error")), ErrUnableToGetLINEUser))
+	assert.True(t, errors.Is(validateResponse(validUser, invalidResponse, nil), ErrUnableToGetLINEUser))
+	assert.True(t, errors.Is(validateResponse(&User{}, validResponse, nil), ErrUnableToGetLINEUser))
+	assert.True(t, errors.Is(validateResponse(nil, validResponse, nil), ErrUnableToGetLINEUser))
+}
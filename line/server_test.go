@@ -0,0 +1,60 @@
+package line
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/dghubble/gologin/testutils"
+)
+
+const (
+	// testTokenJSON is a LINE token response for the profile scope.
+	testTokenJSON = `{"access_token": "any-token", "token_type": "Bearer", "expires_in": 2592000, "refresh_token": "any-refresh", "scope": "profile"}`
+	// testIDTokenJSON is a LINE token response for the openid scope.
+	testIDTokenJSON = `{"access_token": "any-token", "token_type": "Bearer", "expires_in": 2592000, "refresh_token": "any-refresh", "scope": "profile openid email", "id_token": "any-id-token"}`
+	// testProfileJSON is a LINE profile response.
+	testProfileJSON = `{"userId": "U4af4980629d1f3ec1f3a3f4ca4d4f2a0", "displayName": "Taro Line", "pictureUrl": "https://profile.line-scdn.net/abcdefghijklmn", "statusMessage": "Hello, LINE!"}`
+	// testClaimsJSON is a LINE id_token verify response.
+	testClaimsJSON = `{"iss": "https://access.line.me", "sub": "U4af4980629d1f3ec1f3a3f4ca4d4f2a0", "aud": "client_id", "exp": 1504169092, "iat": 1504263657, "nonce": "n0nce", "amr": ["pwd"], "name": "Taro Line", "picture": "https://profile.line-scdn.net/abcdefghijklmn", "email": "taro.line@example.com"}`
+)
+
+// newLINETestServer returns a new httptest.Server which mocks the LINE token,
+// profile, and id_token verify endpoints and a client which proxies requests
+// to the server. The token endpoint responds with the token json data and
+// the profile endpoint with the profile json data. Like LINE, the verify
+// endpoint only accepts the "any-id-token" id_token of the "client_id"
+// channel with the claims nonce (if sent) and responds with the claims json
+// data. The caller must close the server.
+func newLINETestServer(tokenJSON, profileJSON, claimsJSON string) (*http.Client, *httptest.Server) {
+	client, mux, server := testutils.TestServer()
+	mux.HandleFunc("/oauth2/v2.1/token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, tokenJSON)
+	})
+	mux.HandleFunc("/v2/profile", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer any-token" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprintf(w, `{"message": "invalid token"}`)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, profileJSON)
+	})
+	mux.HandleFunc("/oauth2/v2.1/verify", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != "POST" || r.PostFormValue("id_token") != "any-id-token" || r.PostFormValue("client_id") != "client_id" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, `{"error": "invalid_request", "error_description": "Invalid IdToken."}`)
+			return
+		}
+		if nonce := r.PostFormValue("nonce"); nonce != "" && nonce != "n0nce" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, `{"error": "invalid_request", "error_description": "Invalid IdToken Nonce."}`)
+			return
+		}
+		fmt.Fprintf(w, claimsJSON)
+	})
+	return client, server
+}
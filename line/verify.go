@@ -0,0 +1,83 @@
+package line
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/dghubble/gologin/internal/jsonclient"
+)
+
+const lineAPI = "https://api.line.me/"
+
+// User is a LINE user.
+type User struct {
+	UserID        string `json:"userId"`
+	DisplayName   string `json:"displayName"`
+	PictureURL    string `json:"pictureUrl"`
+	StatusMessage string `json:"statusMessage"`
+	// Email is read from the verified id_token (if the openid and email
+	// scopes were granted) since the profile does not include it
+	Email string `json:"-"`
+}
+
+// idTokenClaims are the claims of a verified LINE id_token.
+type idTokenClaims struct {
+	Issuer   string `json:"iss"`
+	Subject  string `json:"sub"`
+	Audience string `json:"aud"`
+	Nonce    string `json:"nonce"`
+	Name     string `json:"name"`
+	Picture  string `json:"picture"`
+	Email    string `json:"email"`
+}
+
+// APIError is a LINE Login API error response.
+type APIError struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("line: %s: %s", e.Code, e.Description)
+}
+
+// client is a LINE client for obtaining the current User.
+type client struct {
+	json *jsonclient.Client
+}
+
+// newClient returns a new LINE client.
+func newClient(httpClient *http.Client) *client {
+	return &client{
+		json: jsonclient.New(httpClient, lineAPI),
+	}
+}
+
+// Profile gets the current LINE User (requires the profile scope).
+// https://developers.line.biz/en/reference/line-login/#get-user-profile
+func (c *client) Profile(ctx context.Context) (*User, *http.Response, error) {
+	user := new(User)
+	resp, err := c.json.Get(ctx, "v2/profile", nil, user, nil)
+	return user, resp, err
+}
+
+// VerifyIDToken verifies the id_token was issued to the channel (clientID)
+// and has the nonce (if non-empty) with LINE's verify endpoint, which
+// supports both HS256 (web) and ES256 (native app) id_tokens. If LINE
+// rejects the id_token, the error is an *APIError.
+// https://developers.line.biz/en/reference/line-login/#verify-id-token
+func (c *client) VerifyIDToken(ctx context.Context, rawIDToken, clientID, nonce string) (*idTokenClaims, *http.Response, error) {
+	claims := new(idTokenClaims)
+	apiErr := new(APIError)
+	form := url.Values{"id_token": {rawIDToken}, "client_id": {clientID}}
+	if nonce != "" {
+		form.Set("nonce", nonce)
+	}
+	resp, err := c.json.PostForm(ctx, "oauth2/v2.1/verify", form, claims, apiErr)
+	if err == nil && apiErr.Code != "" {
+		err = apiErr
+	}
+	return claims, resp, err
+}
@@ -0,0 +1,2 @@
+// Package linkedin provides LinkedIn OAuth2 login and callback handlers.
+package linkedin
@@ -0,0 +1,156 @@
+package linkedin
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/internal"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"golang.org/x/oauth2"
+)
+
+// ProviderName is the provider name set in the ctx (see
+// gologin.ProviderFromContext) and the Profile.
+const ProviderName = "linkedin"
+
+// LinkedIn login errors
+var (
+	ErrUnableToGetLinkedInUser  = errors.New("linkedin: unable to get LinkedIn User")
+	ErrUnableToGetLinkedInEmail = errors.New("linkedin: unable to get LinkedIn User email")
+)
+
+// Endpoint is LinkedIn's OAuth2 endpoint.
+var Endpoint = oauth2.Endpoint{
+	AuthURL:   "https://www.linkedin.com/oauth/v2/authorization",
+	TokenURL:  "https://www.linkedin.com/oauth/v2/accessToken",
+	AuthStyle: oauth2.AuthStyleInParams,
+}
+
+// Config configures LinkedIn login.
+type Config struct {
+	// Legacy gets the User from the legacy /v2/me and /v2/emailAddress APIs
+	// (the r_liteprofile and r_emailaddress scopes) rather than the OpenID
+	// Connect userinfo endpoint (the openid, profile, and email scopes).
+	Legacy bool
+}
+
+// StateHandler checks for a state cookie. If found, the state value is read
+// and added to the ctx. Otherwise, a non-guessable value is added to the ctx
+// and to a (short-lived) state cookie issued to the requester.
+//
+// Implements OAuth 2 RFC 6749 10.12 CSRF Protection. If you wish to issue
+// state params differently, write a http.Handler which sets the ctx state,
+// using oauth2 WithState(ctx, state) since it is required by LoginHandler
+// and CallbackHandler.
+func StateHandler(config gologin.CookieConfig, success http.Handler) http.Handler {
+	return oauth2Login.StateHandler(config, success)
+}
+
+// LoginHandler handles LinkedIn login requests by reading the state value
+// from the ctx and redirecting requests to the AuthURL with that state value.
+// Any AuthCodeOptions are added to the AuthURL.
+func LoginHandler(config *oauth2.Config, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	return oauth2Login.LoginHandler(config, failure, opts...)
+}
+
+// CallbackHandler handles LinkedIn redirection URI requests and adds the
+// LinkedIn access token and User (from the OpenID Connect userinfo endpoint)
+// to the ctx. If authentication succeeds, handling delegates to the success
+// handler, otherwise to the failure handler.
+// Any AuthCodeOptions are sent with the token exchange.
+func CallbackHandler(config *oauth2.Config, success, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	return CallbackHandlerWithConfig(config, Config{}, success, failure, opts...)
+}
+
+// CallbackHandlerWithConfig handles LinkedIn redirection URI requests like
+// CallbackHandler, but gets the User according to the Config.
+func CallbackHandlerWithConfig(config *oauth2.Config, linkedinConfig Config, success, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	success = linkedinHandler(config, linkedinConfig, success, failure)
+	return oauth2Login.CallbackHandler(config, success, failure, opts...)
+}
+
+// linkedinHandler is a http.Handler that gets the OAuth2 Token from the ctx
+// to get the corresponding LinkedIn User. If successful, the User is added to
+// the ctx and the success handler is called. Otherwise, the failure handler
+// is called.
+//
+// In Legacy mode, Tokens without the r_emailaddress scope get an empty User
+// Email.
+func linkedinHandler(config *oauth2.Config, linkedinConfig Config, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		httpClient := internal.OAuth2Client(ctx, config, token)
+		linkedinClient := newClient(httpClient)
+		var user *User
+		var resp *http.Response
+		if linkedinConfig.Legacy {
+			user, resp, err = linkedinClient.Me(ctx)
+		} else {
+			user, resp, err = linkedinClient.UserInfo(ctx)
+		}
+		err = validateResponse(user, resp, err)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		if linkedinConfig.Legacy {
+			email, resp, err := linkedinClient.Email(ctx)
+			err = validateEmailResponse(resp, err)
+			if err != nil {
+				ctx = gologin.WithError(ctx, err)
+				failure.ServeHTTP(w, req.WithContext(ctx))
+				return
+			}
+			user.Email = email
+		}
+		ctx = WithUser(ctx, user)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// validateResponse returns an error if the given LinkedIn User, raw
+// http.Response, or error are unexpected. Returns nil if they are valid, or a
+// *gologin.Error which preserves the cause and status code.
+func validateResponse(user *User, resp *http.Response, err error) error {
+	var status int
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	if err != nil || status != http.StatusOK {
+		return &gologin.Error{Provider: "linkedin", Op: "get user", StatusCode: status, Err: err, Kind: ErrUnableToGetLinkedInUser}
+	}
+	if user == nil || user.ID == "" {
+		return &gologin.Error{Provider: "linkedin", Op: "get user", StatusCode: status, Kind: ErrUnableToGetLinkedInUser}
+	}
+	return nil
+}
+
+// validateEmailResponse returns an error if the raw http.Response or error of
+// a legacy email address request are unexpected. Responses rejecting Tokens
+// which lack the r_emailaddress scope are valid and have no email.
+func validateEmailResponse(resp *http.Response, err error) error {
+	var status int
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	var serviceErr *ServiceError
+	if (status == http.StatusUnauthorized || status == http.StatusForbidden) && errors.As(err, &serviceErr) && serviceErr.ServiceErrorCode == errCodeNotEnoughPermissions {
+		return nil
+	}
+	if err != nil || status != http.StatusOK {
+		return &gologin.Error{Provider: "linkedin", Op: "get email", StatusCode: status, Err: err, Kind: ErrUnableToGetLinkedInEmail}
+	}
+	return nil
+}
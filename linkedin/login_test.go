@@ -0,0 +1,223 @@
+package linkedin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dghubble/gologin"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+// OpenID Connect userinfo response
+const testUserInfoJSON = `{"sub": "782bbtaQ", "name": "Ada Lovelace", "given_name": "Ada", "family_name": "Lovelace", "picture": "https://media.licdn.com/dms/image/ada.jpg", "locale": {"country": "GB", "language": "en"}, "email": "ada@example.com", "email_verified": true}`
+
+// legacy /v2/me and /v2/emailAddress responses
+const (
+	testMeJSON    = `{"id": "yrZCpj2Z12", "localizedFirstName": "Ada", "localizedLastName": "Lovelace", "profilePicture": {"displayImage": "urn:li:digitalmediaAsset:C4D00AAAAbBCDEFGhiJ", "displayImage~": {"elements": [{"identifiers": [{"identifier": "https://media.licdn.com/dms/image/ada_100_100.jpg"}]}, {"identifiers": [{"identifier": "https://media.licdn.com/dms/image/ada_800_800.jpg"}]}]}}}`
+	testEmailJSON = `{"elements": [{"handle": "urn:li:emailAddress:3775708763", "handle~": {"emailAddress": "ada@example.com"}}]}`
+	// token lacks the r_emailaddress scope
+	testEmailScopeErrorJSON = `{"serviceErrorCode": 100, "message": "Not enough permissions to access: GET /emailAddress", "status": 401}`
+)
+
+func testConfig() *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     "client_id",
+		ClientSecret: "client_secret",
+		RedirectURL:  "https://example.com/linkedin/callback",
+		Endpoint:     Endpoint,
+		Scopes:       []string{"openid", "profile", "email"},
+	}
+}
+
+func TestCallbackHandler(t *testing.T) {
+	proxyClient, server := newLinkedInTestServer(testUserInfoJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithState(ctx, "d4e5f6")
+
+	success := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "any-token", token.AccessToken)
+		}
+		user, err := UserFromContext(ctx)
+		if assert.Nil(t, err) {
+			expectedUser := &User{
+				ID:            "782bbtaQ",
+				Name:          "Ada Lovelace",
+				FirstName:     "Ada",
+				LastName:      "Lovelace",
+				Email:         "ada@example.com",
+				EmailVerified: true,
+				Picture:       "https://media.licdn.com/dms/image/ada.jpg",
+				Locale:        Locale{Country: "GB", Language: "en"},
+			}
+			assert.Equal(t, expectedUser, user)
+		}
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// CallbackHandler assert that:
+	// - the Token is obtained from the accessToken endpoint
+	// - the LinkedIn User is obtained from the userinfo endpoint
+	// - success handler is called with the Token and User in the ctx
+	callbackHandler := CallbackHandler(testConfig(), http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestLinkedInHandler_Legacy(t *testing.T) {
+	cases := []struct {
+		emailStatus   int
+		emailJSON     string
+		expectedEmail string
+	}{
+		{http.StatusOK, testEmailJSON, "ada@example.com"},
+		{http.StatusUnauthorized, testEmailScopeErrorJSON, ""},
+		{http.StatusOK, `{"elements": []}`, ""},
+	}
+	for _, c := range cases {
+		proxyClient, server := newLinkedInLegacyTestServer(testMeJSON, c.emailStatus, c.emailJSON)
+		// oauth2 Client will use the proxy client's base Transport
+		ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+		ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+
+		success := func(w http.ResponseWriter, req *http.Request) {
+			user, err := UserFromContext(req.Context())
+			if assert.Nil(t, err) {
+				expectedUser := &User{
+					ID:        "yrZCpj2Z12",
+					Name:      "Ada Lovelace",
+					FirstName: "Ada",
+					LastName:  "Lovelace",
+					Email:     c.expectedEmail,
+					Picture:   "https://media.licdn.com/dms/image/ada_800_800.jpg",
+				}
+				assert.Equal(t, expectedUser, user)
+			}
+			fmt.Fprintf(w, "success handler called")
+		}
+		failure := testutils.AssertFailureNotCalled(t)
+
+		// LinkedInHandler in Legacy mode, assert that:
+		// - the LinkedIn User is obtained from /v2/me and /v2/emailAddress
+		// - Tokens without the r_emailaddress scope get an empty Email
+		// - success handler is called with the User in the ctx
+		linkedinHandler := linkedinHandler(testConfig(), Config{Legacy: true}, http.HandlerFunc(success), failure)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		linkedinHandler.ServeHTTP(w, req.WithContext(ctx))
+		assert.Equal(t, "success handler called", w.Body.String())
+		server.Close()
+	}
+}
+
+func TestLinkedInHandler_LegacyErrorGettingEmail(t *testing.T) {
+	invalidTokenJSON := `{"serviceErrorCode": 65600, "message": "Invalid access token", "status": 401}`
+	proxyClient, server := newLinkedInLegacyTestServer(testMeJSON, http.StatusUnauthorized, invalidTokenJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.True(t, errors.Is(err, ErrUnableToGetLinkedInEmail))
+			var serviceErr *ServiceError
+			if assert.True(t, errors.As(err, &serviceErr)) {
+				assert.Equal(t, 65600, serviceErr.ServiceErrorCode)
+			}
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// LinkedInHandler in Legacy mode with an unexpected email error, assert that:
+	// - failure handler is called
+	// - error cannot get LinkedIn email added to the failure handler ctx
+	linkedinHandler := linkedinHandler(testConfig(), Config{Legacy: true}, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	linkedinHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestLinkedInHandler_MissingCtxToken(t *testing.T) {
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "oauth2: Context missing Token", err.Error())
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// LinkedInHandler called without Token in ctx, assert that:
+	// - failure handler is called
+	// - error about ctx missing token is added to the failure handler ctx
+	linkedinHandler := linkedinHandler(testConfig(), Config{}, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	linkedinHandler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestLinkedInHandler_ErrorGettingUser(t *testing.T) {
+	proxyClient, server := testutils.NewErrorServer("LinkedIn Service Down", http.StatusInternalServerError)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.True(t, errors.Is(err, ErrUnableToGetLinkedInUser))
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// LinkedInHandler cannot get LinkedIn User, assert that:
+	// - failure handler is called
+	// - error cannot get LinkedIn User added to the failure handler ctx
+	linkedinHandler := linkedinHandler(testConfig(), Config{}, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	linkedinHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestValidateResponse(t *testing.T) {
+	validUser := &User{ID: "782bbtaQ"}
+	validResponse := &http.Response{StatusCode: 200}
+	invalidResponse := &http.Response{StatusCode: 500}
+	assert.Equal(t, nil, validateResponse(validUser, validResponse, nil))
+	assert.True(t, errors.Is(validateResponse(validUser, validResponse, fmt.Errorf("Server error")), ErrUnableToGetLinkedInUser))
+	assert.True(t, errors.Is(validateResponse(validUser, invalidResponse, nil), ErrUnableToGetLinkedInUser))
+	assert.True(t, errors.Is(validateResponse(&User{}, validResponse, nil), ErrUnableToGetLinkedInUser))
+	assert.True(t, errors.Is(validateResponse(nil, validResponse, nil), ErrUnableToGetLinkedInUser))
+}
+
+func TestValidateEmailResponse(t *testing.T) {
+	scopeErr := &ServiceError{ServiceErrorCode: errCodeNotEnoughPermissions, Status: 401}
+	assert.Nil(t, validateEmailResponse(&http.Response{StatusCode: 200}, nil))
+	assert.Nil(t, validateEmailResponse(&http.Response{StatusCode: 401}, scopeErr))
+	assert.Nil(t, validateEmailResponse(&http.Response{StatusCode: 403}, scopeErr))
+	assert.True(t, errors.Is(validateEmailResponse(&http.Response{StatusCode: 401}, &ServiceError{ServiceErrorCode: 65600}), ErrUnableToGetLinkedInEmail))
+	assert.True(t, errors.Is(validateEmailResponse(&http.Response{StatusCode: 500}, scopeErr), ErrUnableToGetLinkedInEmail))
+	assert.True(t, errors.Is(validateEmailResponse(nil, fmt.Errorf("Server error")), ErrUnableToGetLinkedInEmail))
+}
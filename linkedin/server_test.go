@@ -0,0 +1,57 @@
+package linkedin
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/dghubble/gologin/testutils"
+)
+
+// newLinkedInTestServer returns a new httptest.Server which mocks the
+// LinkedIn OAuth2 accessToken and OpenID Connect userinfo endpoints and a
+// client which proxies requests to the server. The userinfo endpoint responds
+// with the given json data. The caller must close the server.
+func newLinkedInTestServer(jsonData string) (*http.Client, *httptest.Server) {
+	client, mux, server := testutils.TestServer()
+	mux.HandleFunc("/oauth/v2/accessToken", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token": "any-token", "expires_in": 5184000, "scope": "openid,profile,email"}`)
+	})
+	mux.HandleFunc("/v2/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer any-token" {
+			http.Error(w, "unexpected userinfo request", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, jsonData)
+	})
+	return client, server
+}
+
+// newLinkedInLegacyTestServer returns a new httptest.Server which mocks the
+// LinkedIn legacy /v2/me and /v2/emailAddress endpoints and a client which
+// proxies requests to the server. The /v2/me endpoint responds with the
+// meJSON and /v2/emailAddress responds with the emailStatus and emailJSON.
+// The caller must close the server.
+func newLinkedInLegacyTestServer(meJSON string, emailStatus int, emailJSON string) (*http.Client, *httptest.Server) {
+	client, mux, server := testutils.TestServer()
+	mux.HandleFunc("/v2/me", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("projection") != meProjection {
+			http.Error(w, "unexpected me request", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, meJSON)
+	})
+	mux.HandleFunc("/v2/emailAddress", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("q") != "members" || r.URL.Query().Get("projection") != emailProjection {
+			http.Error(w, "unexpected emailAddress request", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(emailStatus)
+		fmt.Fprintf(w, emailJSON)
+	})
+	return client, server
+}
@@ -0,0 +1,150 @@
+package linkedin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/dghubble/gologin/internal/jsonclient"
+)
+
+const (
+	linkedinAPI = "https://api.linkedin.com/v2/"
+	// legacy profile and email projections
+	meProjection    = "(id,localizedFirstName,localizedLastName,profilePicture(displayImage~:playableStreams))"
+	emailProjection = "(elements*(handle~))"
+)
+
+// errCodeNotEnoughPermissions is the serviceErrorCode of requests whose token
+// lacks a required scope (e.g. r_emailaddress).
+const errCodeNotEnoughPermissions = 100
+
+// User is a LinkedIn member.
+type User struct {
+	// ID is the OpenID Connect sub or legacy member id
+	ID            string `json:"sub"`
+	Name          string `json:"name"`
+	FirstName     string `json:"given_name"`
+	LastName      string `json:"family_name"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Picture       string `json:"picture"`
+	Locale        Locale `json:"locale"`
+}
+
+// Locale is a LinkedIn member's locale.
+type Locale struct {
+	Country  string `json:"country"`
+	Language string `json:"language"`
+}
+
+// ServiceError is a LinkedIn API error response.
+type ServiceError struct {
+	ServiceErrorCode int    `json:"serviceErrorCode"`
+	Message          string `json:"message"`
+	Status           int    `json:"status"`
+}
+
+func (e *ServiceError) Error() string {
+	return fmt.Sprintf("linkedin: %s (serviceErrorCode %d)", e.Message, e.ServiceErrorCode)
+}
+
+// legacyProfile is a legacy /v2/me response.
+type legacyProfile struct {
+	ID             string `json:"id"`
+	FirstName      string `json:"localizedFirstName"`
+	LastName       string `json:"localizedLastName"`
+	ProfilePicture struct {
+		DisplayImage struct {
+			Elements []struct {
+				Identifiers []struct {
+					Identifier string `json:"identifier"`
+				} `json:"identifiers"`
+			} `json:"elements"`
+		} `json:"displayImage~"`
+	} `json:"profilePicture"`
+}
+
+// picture returns the last (largest) profile picture URL, if any.
+func (p *legacyProfile) picture() string {
+	elements := p.ProfilePicture.DisplayImage.Elements
+	for i := len(elements) - 1; i >= 0; i-- {
+		if ids := elements[i].Identifiers; len(ids) > 0 {
+			return ids[0].Identifier
+		}
+	}
+	return ""
+}
+
+// legacyEmails is a legacy /v2/emailAddress response.
+type legacyEmails struct {
+	Elements []struct {
+		Handle struct {
+			EmailAddress string `json:"emailAddress"`
+		} `json:"handle~"`
+	} `json:"elements"`
+}
+
+// client is a LinkedIn client for obtaining a User.
+type client struct {
+	json *jsonclient.Client
+}
+
+// newClient returns a new LinkedIn client.
+func newClient(httpClient *http.Client) *client {
+	return &client{
+		json: jsonclient.New(httpClient, linkedinAPI),
+	}
+}
+
+// UserInfo gets the member's OpenID Connect userinfo.
+// https://learn.microsoft.com/en-us/linkedin/consumer/integrations/self-serve/sign-in-with-linkedin-v2
+func (c *client) UserInfo(ctx context.Context) (*User, *http.Response, error) {
+	user := new(User)
+	serviceErr := new(ServiceError)
+	resp, err := c.json.Get(ctx, "userinfo", nil, user, serviceErr)
+	if err == nil && serviceErr.ServiceErrorCode != 0 {
+		err = serviceErr
+	}
+	return user, resp, err
+}
+
+// Me gets the member's legacy lite profile as a User.
+// https://learn.microsoft.com/en-us/linkedin/shared/integrations/people/lite-profile
+func (c *client) Me(ctx context.Context) (*User, *http.Response, error) {
+	profile := new(legacyProfile)
+	serviceErr := new(ServiceError)
+	params := url.Values{"projection": {meProjection}}
+	resp, err := c.json.Get(ctx, "me", params, profile, serviceErr)
+	if err == nil && serviceErr.ServiceErrorCode != 0 {
+		err = serviceErr
+	}
+	user := &User{
+		ID:        profile.ID,
+		Name:      strings.TrimSpace(profile.FirstName + " " + profile.LastName),
+		FirstName: profile.FirstName,
+		LastName:  profile.LastName,
+		Picture:   profile.picture(),
+	}
+	return user, resp, err
+}
+
+// Email gets the member's legacy primary email address.
+// https://learn.microsoft.com/en-us/linkedin/shared/integrations/people/primary-contact-api
+func (c *client) Email(ctx context.Context) (string, *http.Response, error) {
+	emails := new(legacyEmails)
+	serviceErr := new(ServiceError)
+	params := url.Values{"q": {"members"}, "projection": {emailProjection}}
+	resp, err := c.json.Get(ctx, "emailAddress", params, emails, serviceErr)
+	if err == nil && serviceErr.ServiceErrorCode != 0 {
+		err = serviceErr
+	}
+	for _, element := range emails.Elements {
+		if element.Handle.EmailAddress != "" {
+			return element.Handle.EmailAddress, resp, err
+		}
+	}
+	return "", resp, err
+}
@@ -0,0 +1,114 @@
+package gologin
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ErrLoginRequired is the error when an unauthenticated request is made to a
+// route which requires login.
+var ErrLoginRequired = errors.New("gologin: login required")
+
+const (
+	defaultLoginPath      = "/login"
+	defaultReturnURLParam = "next"
+)
+
+// RequireLoginOptions configures RequireLogin.
+type RequireLoginOptions struct {
+	// IsAuthenticated returns true if the request has an application
+	// session. Required.
+	IsAuthenticated func(req *http.Request) bool
+	// LoginPath is the login route, such as a provider login handler (e.g.
+	// "/github/login") or a page which lets users choose a provider.
+	// Defaults to "/login".
+	LoginPath string
+	// ChooseLogin returns the login route for the request, if any (e.g. a
+	// ProviderMux login path of the user's last provider). Empty paths fall
+	// back to LoginPath.
+	ChooseLogin func(req *http.Request) string
+	// ReturnURLParam is the login route query parameter with the original
+	// request URL, which oauth2 ReturnURLHandler restores after the
+	// callback. Defaults to "next", the ReturnURLHandler default.
+	ReturnURLParam string
+	// IsAPIRequest returns true if the request should receive an
+	// Unauthorized response rather than a redirect. Defaults to requests
+	// which Accept application/json or have an X-Requested-With header.
+	IsAPIRequest func(req *http.Request) bool
+	// Unauthorized handles API requests. Defaults to a 401 JSON response of
+	// ErrLoginRequired (e.g. {"error":"gologin: login required"}).
+	Unauthorized http.Handler
+}
+
+// RequireLogin returns middleware which calls the next handler for
+// authenticated requests. Unauthenticated requests are redirected (302) to
+// the login route with the original request path and query in the
+// ReturnURLParam (e.g. /login?next=%2Fsettings), so an oauth2
+// ReturnURLHandler on the login and callback routes can send the user back
+// after login. Unauthenticated API requests call the Unauthorized handler
+// instead. Panics if IsAuthenticated is nil.
+func RequireLogin(opts RequireLoginOptions) func(http.Handler) http.Handler {
+	if opts.IsAuthenticated == nil {
+		panic("gologin: RequireLogin requires an IsAuthenticated func")
+	}
+	if opts.LoginPath == "" {
+		opts.LoginPath = defaultLoginPath
+	}
+	if opts.ReturnURLParam == "" {
+		opts.ReturnURLParam = defaultReturnURLParam
+	}
+	if opts.IsAPIRequest == nil {
+		opts.IsAPIRequest = isAPIRequest
+	}
+	unauthorized := opts.Unauthorized
+	if unauthorized == nil {
+		unauthorized = http.HandlerFunc(unauthorizedHandler)
+	}
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, req *http.Request) {
+			if opts.IsAuthenticated(req) {
+				next.ServeHTTP(w, req)
+				return
+			}
+			ctx := req.Context()
+			if opts.IsAPIRequest(req) {
+				ctx = WithError(ctx, ErrLoginRequired)
+				ctx = WithStatusCode(ctx, http.StatusUnauthorized)
+				unauthorized.ServeHTTP(w, req.WithContext(ctx))
+				return
+			}
+			http.Redirect(w, req, opts.loginURL(req), http.StatusFound)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// loginURL returns the login route for the request with the request path and
+// query as the return URL parameter.
+func (o RequireLoginOptions) loginURL(req *http.Request) string {
+	loginPath := o.LoginPath
+	if o.ChooseLogin != nil {
+		if chosen := o.ChooseLogin(req); chosen != "" {
+			loginPath = chosen
+		}
+	}
+	separator := "?"
+	if strings.Contains(loginPath, "?") {
+		separator = "&"
+	}
+	return loginPath + separator + url.Values{o.ReturnURLParam: {req.URL.RequestURI()}}.Encode()
+}
+
+// isAPIRequest returns true if the request Accepts application/json or has an
+// X-Requested-With header (e.g. XMLHttpRequest).
+func isAPIRequest(req *http.Request) bool {
+	return acceptsJSON(req) || req.Header.Get("X-Requested-With") != ""
+}
+
+// unauthorizedHandler responds with the ctx error as JSON.
+func unauthorizedHandler(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	jsonFailureHandler(ctx, w, req, ErrorFromContext(ctx))
+}
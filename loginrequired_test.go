@@ -0,0 +1,102 @@
+package gologin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testSessionCookie marks authenticated test requests.
+const testSessionCookie = "app-session"
+
+func isTestAuthenticated(req *http.Request) bool {
+	_, err := req.Cookie(testSessionCookie)
+	return err == nil
+}
+
+func TestRequireLogin(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(w, "next handler called")
+	})
+	cases := []struct {
+		name     string
+		opts     RequireLoginOptions
+		target   string
+		header   http.Header
+		session  bool
+		status   int
+		location string
+	}{
+		{"authenticated", RequireLoginOptions{}, "/settings", nil, true, http.StatusOK, ""},
+		{"redirect", RequireLoginOptions{}, "/settings?tab=profile", nil, false, http.StatusFound, "/login?next=%2Fsettings%3Ftab%3Dprofile"},
+		{"login path", RequireLoginOptions{LoginPath: "/github/login"}, "/settings", nil, false, http.StatusFound, "/github/login?next=%2Fsettings"},
+		{"login path with query", RequireLoginOptions{LoginPath: "/login?prompt=consent", ReturnURLParam: "return_to"}, "/settings", nil, false, http.StatusFound, "/login?prompt=consent&return_to=%2Fsettings"},
+		{"chooser", RequireLoginOptions{ChooseLogin: func(req *http.Request) string { return "/auth/" + req.URL.Query().Get("provider") + "/login" }}, "/repos?provider=github", nil, false, http.StatusFound, "/auth/github/login?next=%2Frepos%3Fprovider%3Dgithub"},
+		{"chooser fallback", RequireLoginOptions{ChooseLogin: func(req *http.Request) string { return "" }}, "/settings", nil, false, http.StatusFound, "/login?next=%2Fsettings"},
+		{"html browser", RequireLoginOptions{}, "/settings", http.Header{"Accept": {"text/html,application/json"}}, false, http.StatusFound, "/login?next=%2Fsettings"},
+		{"json api", RequireLoginOptions{}, "/api/repos", http.Header{"Accept": {"application/json"}}, false, http.StatusUnauthorized, ""},
+		{"xhr api", RequireLoginOptions{}, "/api/repos", http.Header{"X-Requested-With": {"XMLHttpRequest"}}, false, http.StatusUnauthorized, ""},
+		{"api detection disabled", RequireLoginOptions{IsAPIRequest: func(req *http.Request) bool { return false }}, "/api/repos", http.Header{"Accept": {"application/json"}}, false, http.StatusFound, "/login?next=%2Fapi%2Frepos"},
+	}
+	for _, c := range cases {
+		c.opts.IsAuthenticated = isTestAuthenticated
+		// RequireLogin, assert that:
+		// - authenticated requests call the next handler
+		// - unauthenticated requests are redirected to the (chosen) login path
+		//   with the original request URL
+		// - unauthenticated API requests get a 401 JSON error
+		handler := RequireLogin(c.opts)(next)
+		req := httptest.NewRequest("GET", c.target, nil)
+		for key, values := range c.header {
+			req.Header[key] = values
+		}
+		if c.session {
+			req.AddCookie(&http.Cookie{Name: testSessionCookie, Value: "any"})
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		assert.Equal(t, c.status, w.Code, c.name)
+		assert.Equal(t, c.location, w.Header().Get("Location"), c.name)
+		switch c.status {
+		case http.StatusOK:
+			assert.Equal(t, "next handler called", w.Body.String(), c.name)
+		case http.StatusUnauthorized:
+			assert.Equal(t, "application/json", w.Header().Get("Content-Type"), c.name)
+			var body map[string]string
+			assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &body), c.name)
+			assert.Equal(t, map[string]string{"error": ErrLoginRequired.Error()}, body, c.name)
+		}
+	}
+}
+
+func TestRequireLogin_Unauthorized(t *testing.T) {
+	unauthorized := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		assert.Equal(t, ErrLoginRequired, ErrorFromContext(ctx))
+		w.WriteHeader(StatusCodeFromContext(ctx))
+		fmt.Fprintf(w, "unauthorized handler called")
+	}
+	opts := RequireLoginOptions{
+		IsAuthenticated: isTestAuthenticated,
+		Unauthorized:    http.HandlerFunc(unauthorized),
+	}
+
+	// RequireLogin with an Unauthorized handler, assert that:
+	// - API requests call the handler with ErrLoginRequired and a 401
+	next := func(w http.ResponseWriter, req *http.Request) {
+		assert.Fail(t, "unexpected call to next handler")
+	}
+	handler := RequireLogin(opts)(http.HandlerFunc(next))
+	req := httptest.NewRequest("GET", "/api/repos", nil)
+	req.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, "unauthorized handler called", w.Body.String())
+
+	assert.Panics(t, func() { RequireLogin(RequireLoginOptions{}) })
+}
@@ -0,0 +1,45 @@
+package mastodon
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dghubble/gologin"
+)
+
+// unexported key type prevents collisions
+type key int
+
+const (
+	userKey key = iota
+)
+
+// WithUser returns a copy of ctx that stores the Mastodon User and its gologin
+// Profile.
+func WithUser(ctx context.Context, user *User) context.Context {
+	ctx = gologin.WithProfile(ctx, newProfile(user))
+	return context.WithValue(ctx, userKey, user)
+}
+
+// UserFromContext returns the Mastodon User from the ctx.
+func UserFromContext(ctx context.Context) (*User, error) {
+	user, ok := ctx.Value(userKey).(*User)
+	if !ok {
+		return nil, fmt.Errorf("mastodon: Context missing Mastodon User")
+	}
+	return user, nil
+}
+
+// newProfile returns the gologin Profile of the Mastodon User.
+func newProfile(user *User) *gologin.Profile {
+	if user == nil {
+		return nil
+	}
+	return &gologin.Profile{
+		Provider:  ProviderName,
+		ID:        user.ID,
+		Name:      gologin.DisplayName(user.DisplayName, user.Username),
+		AvatarURL: user.Avatar,
+		Raw:       gologin.ProfileRaw(user, "id", "display_name", "avatar"),
+	}
+}
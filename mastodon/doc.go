@@ -0,0 +1,3 @@
+// Package mastodon provides Mastodon OAuth2 login and callback handlers for
+// any Mastodon instance.
+package mastodon
@@ -0,0 +1,145 @@
+package mastodon
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/internal"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"golang.org/x/oauth2"
+)
+
+// ProviderName is the provider name set in the ctx (see
+// gologin.ProviderFromContext) and the Profile.
+const ProviderName = "mastodon"
+
+// Mastodon login errors
+var (
+	ErrUnableToGetMastodonUser = errors.New("mastodon: unable to get Mastodon User")
+)
+
+// NewEndpoint returns the OAuth2 endpoint of the Mastodon instance at the
+// instanceURL (e.g. "https://mastodon.social"). Panics if the instanceURL is
+// not an absolute URL.
+func NewEndpoint(instanceURL string) oauth2.Endpoint {
+	instanceURL = mustNormalizeInstanceURL(instanceURL)
+	return oauth2.Endpoint{
+		AuthURL:   instanceURL + "/oauth/authorize",
+		TokenURL:  instanceURL + "/oauth/token",
+		AuthStyle: oauth2.AuthStyleInParams,
+	}
+}
+
+// Config configures Mastodon login.
+type Config struct {
+	// InstanceURL is the URL of the Mastodon instance users belong to (e.g.
+	// "https://mastodon.social"). The oauth2 Config Endpoint should be
+	// NewEndpoint(InstanceURL) and its client credentials those registered
+	// with the instance (see RegisterApp).
+	InstanceURL string
+}
+
+// normalizeInstanceURL returns the instanceURL without a trailing slash or
+// an error if it is not an absolute URL.
+func normalizeInstanceURL(instanceURL string) (string, error) {
+	u, err := url.Parse(instanceURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return "", fmt.Errorf("mastodon: invalid instance URL %q", instanceURL)
+	}
+	return strings.TrimRight(u.String(), "/"), nil
+}
+
+// mustNormalizeInstanceURL returns the normalized instanceURL. It panics if
+// the instanceURL is invalid.
+func mustNormalizeInstanceURL(instanceURL string) string {
+	normalized, err := normalizeInstanceURL(instanceURL)
+	if err != nil {
+		panic(err)
+	}
+	return normalized
+}
+
+// StateHandler checks for a state cookie. If found, the state value is read
+// and added to the ctx. Otherwise, a non-guessable value is added to the ctx
+// and to a (short-lived) state cookie issued to the requester.
+//
+// Implements OAuth 2 RFC 6749 10.12 CSRF Protection. If you wish to issue
+// state params differently, write a http.Handler which sets the ctx state,
+// using oauth2 WithState(ctx, state) since it is required by LoginHandler
+// and CallbackHandler.
+func StateHandler(config gologin.CookieConfig, success http.Handler) http.Handler {
+	return oauth2Login.StateHandler(config, success)
+}
+
+// LoginHandler handles Mastodon login requests by reading the state value
+// from the ctx and redirecting requests to the AuthURL with that state value.
+// Any AuthCodeOptions are added to the AuthURL.
+//
+// The config Endpoint should be NewEndpoint of the instance and Scopes should
+// include "read:accounts" (or "read") to get the Mastodon User.
+func LoginHandler(config *oauth2.Config, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	return oauth2Login.LoginHandler(config, failure, opts...)
+}
+
+// CallbackHandler handles Mastodon redirection URI requests and adds the
+// Mastodon access token and User to the ctx. The User is read from the
+// instance at the Config InstanceURL. If authentication succeeds, handling
+// delegates to the success handler, otherwise to the failure handler.
+// Any AuthCodeOptions are sent with the token exchange. Panics if the
+// InstanceURL is invalid.
+func CallbackHandler(config *oauth2.Config, mastodonConfig Config, success, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	success = mastodonHandler(config, mastodonConfig, success, failure)
+	return oauth2Login.CallbackHandler(config, success, failure, opts...)
+}
+
+// mastodonHandler is a http.Handler that gets the OAuth2 Token from the ctx
+// to get the corresponding Mastodon User. If successful, the User is added to
+// the ctx and the success handler is called. Otherwise, the failure handler
+// is called.
+func mastodonHandler(config *oauth2.Config, mastodonConfig Config, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	instanceURL := mustNormalizeInstanceURL(mastodonConfig.InstanceURL)
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		httpClient := internal.OAuth2Client(ctx, config, token)
+		user, resp, err := newClient(httpClient, instanceURL).VerifyCredentials(ctx)
+		err = validateResponse(user, resp, err)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		ctx = WithUser(ctx, user)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// validateResponse returns an error if the given Mastodon User, raw
+// http.Response, or error are unexpected. Returns nil if they are valid, or a
+// *gologin.Error which preserves the cause and status code.
+func validateResponse(user *User, resp *http.Response, err error) error {
+	var status int
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	if err != nil || status != http.StatusOK {
+		return &gologin.Error{Provider: "mastodon", Op: "get user", StatusCode: status, Err: err, Kind: ErrUnableToGetMastodonUser}
+	}
+	if user == nil || user.ID == "" {
+		return &gologin.Error{Provider: "mastodon", Op: "get user", StatusCode: status, Kind: ErrUnableToGetMastodonUser}
+	}
+	return nil
+}
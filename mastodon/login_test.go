@@ -0,0 +1,172 @@
+package mastodon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dghubble/gologin"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func testConfig() *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     "client_id",
+		ClientSecret: "client_secret",
+		RedirectURL:  "https://example.com/mastodon/callback",
+		Endpoint:     NewEndpoint(testInstanceURL),
+		Scopes:       []string{"read:accounts"},
+	}
+}
+
+func TestNewEndpoint(t *testing.T) {
+	endpoint := NewEndpoint("https://mastodon.social/")
+	assert.Equal(t, "https://mastodon.social/oauth/authorize", endpoint.AuthURL)
+	assert.Equal(t, "https://mastodon.social/oauth/token", endpoint.TokenURL)
+	assert.Equal(t, oauth2.AuthStyleInParams, endpoint.AuthStyle)
+	assert.Panics(t, func() { NewEndpoint("") })
+	assert.Panics(t, func() { NewEndpoint("mastodon.social") })
+}
+
+func TestCallbackHandler(t *testing.T) {
+	proxyClient, server := newMastodonTestServer(testUserJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithState(ctx, "d4e5f6")
+
+	expectedUser := &User{
+		ID:          "14715",
+		Username:    "trwnh",
+		Acct:        "trwnh",
+		DisplayName: "infinite love ⴳ",
+		Avatar:      "https://files.mastodon.example.com/accounts/avatars/000/014/715/original/34aa222f4ae2e0a9.png",
+		URL:         "https://mastodon.example.com/@trwnh",
+	}
+	success := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "any-token", token.AccessToken)
+		}
+		user, err := UserFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, expectedUser, user)
+		}
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// CallbackHandler assert that:
+	// - the User is read from the Config instance
+	// - success handler is called
+	// - Mastodon Token and User are added to the ctx of the success handler
+	callbackHandler := CallbackHandler(testConfig(), Config{InstanceURL: testInstanceURL}, http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestMastodonHandler_InvalidInstanceURL(t *testing.T) {
+	assert.Panics(t, func() {
+		mastodonHandler(testConfig(), Config{}, testutils.AssertSuccessNotCalled(t), nil)
+	})
+}
+
+func TestMastodonHandler_APIError(t *testing.T) {
+	proxyClient, server := newMastodonTestServer(testUserJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "invalid-token"})
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.True(t, errors.Is(err, ErrUnableToGetMastodonUser))
+			var apiErr *APIError
+			if assert.True(t, errors.As(err, &apiErr)) {
+				assert.Equal(t, "The access token is invalid", apiErr.Message)
+			}
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// MastodonHandler gets a Mastodon error response, assert that:
+	// - failure handler is called
+	// - error cannot get Mastodon User is added to the failure handler ctx,
+	// with the Mastodon APIError as the cause
+	mastodonHandler := mastodonHandler(testConfig(), Config{InstanceURL: testInstanceURL}, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	mastodonHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestMastodonHandler_NotJSON(t *testing.T) {
+	for _, status := range []int{http.StatusOK, http.StatusBadGateway} {
+		proxyClient, server := newHTMLErrorServer(status)
+		// oauth2 Client will use the proxy client's base Transport
+		ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+		ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+
+		success := testutils.AssertSuccessNotCalled(t)
+		failure := func(w http.ResponseWriter, req *http.Request) {
+			err := gologin.ErrorFromContext(req.Context())
+			if assert.NotNil(t, err) {
+				assert.True(t, errors.Is(err, ErrUnableToGetMastodonUser))
+				assert.True(t, errors.Is(err, ErrNotJSON))
+			}
+			fmt.Fprintf(w, "failure handler called")
+		}
+
+		// MastodonHandler gets an HTML page, assert that:
+		// - failure handler is called
+		// - error cannot get Mastodon User is added to the failure handler
+		// ctx, with ErrNotJSON as the cause
+		mastodonHandler := mastodonHandler(testConfig(), Config{InstanceURL: testInstanceURL}, success, http.HandlerFunc(failure))
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		mastodonHandler.ServeHTTP(w, req.WithContext(ctx))
+		assert.Equal(t, "failure handler called", w.Body.String())
+		server.Close()
+	}
+}
+
+func TestMastodonHandler_MissingCtxToken(t *testing.T) {
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "oauth2: Context missing Token", err.Error())
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// MastodonHandler called without Token in ctx, assert that:
+	// - failure handler is called
+	// - error about ctx missing token is added to the failure handler ctx
+	mastodonHandler := mastodonHandler(testConfig(), Config{InstanceURL: testInstanceURL}, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	mastodonHandler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestValidateResponse(t *testing.T) {
+	validUser := &User{ID: "14715", Username: "trwnh"}
+	validResponse := &http.Response{StatusCode: 200}
+	invalidResponse := &http.Response{StatusCode: 500}
+	assert.Equal(t, nil, validateResponse(validUser, validResponse, nil))
+	assert.True(t, errors.Is(validateResponse(validUser, validResponse, fmt.Errorf("Server error")), ErrUnableToGetMastodonUser))
+	assert.True(t, errors.Is(validateResponse(validUser, invalidResponse, nil), ErrUnableToGetMastodonUser))
+	assert.True(t, errors.Is(validateResponse(&User{Username: "trwnh"}, validResponse, nil), ErrUnableToGetMastodonUser))
+}
@@ -0,0 +1,63 @@
+package mastodon
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/internal"
+	"github.com/dghubble/gologin/internal/jsonclient"
+)
+
+// ErrUnableToRegisterApp is the error of a failed app registration.
+var ErrUnableToRegisterApp = errors.New("mastodon: unable to register Mastodon app")
+
+// App is an app (client) registered with a Mastodon instance.
+type App struct {
+	ID           string `json:"id"`
+	Name         string `json:"name"`
+	Website      string `json:"website"`
+	RedirectURI  string `json:"redirect_uri"`
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+}
+
+// RegisterApp registers an app with the Mastodon instance at the instanceURL
+// and returns its client credentials, using the ctx HTTP client (if any).
+// Each instance requires its own client, so apps which support many
+// instances should register once per instance and cache the App. The scopes
+// must include those requested by LoginHandler.
+//
+// Returns a *gologin.Error of ErrUnableToRegisterApp if registration fails.
+// https://docs.joinmastodon.org/methods/apps/#create
+func RegisterApp(ctx context.Context, instanceURL, appName, redirectURI string, scopes []string) (*App, error) {
+	instanceURL, err := normalizeInstanceURL(instanceURL)
+	if err != nil {
+		return nil, err
+	}
+	form := url.Values{"client_name": {appName}, "redirect_uris": {redirectURI}}
+	if len(scopes) > 0 {
+		form.Set("scopes", strings.Join(scopes, " "))
+	}
+	app := new(App)
+	apiErr := new(APIError)
+	resp, err := jsonclient.New(internal.ContextClient(ctx), instanceURL+"/").PostForm(ctx, "api/v1/apps", form, app, apiErr)
+	err = checkJSON(resp, err)
+	if err == nil && apiErr.Message != "" {
+		err = apiErr
+	}
+	var status int
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	if err != nil || status != http.StatusOK {
+		return nil, &gologin.Error{Provider: "mastodon", Op: "register app", StatusCode: status, Err: err, Kind: ErrUnableToRegisterApp}
+	}
+	if app.ClientID == "" || app.ClientSecret == "" {
+		return nil, &gologin.Error{Provider: "mastodon", Op: "register app", StatusCode: status, Err: errors.New("missing client_id or client_secret"), Kind: ErrUnableToRegisterApp}
+	}
+	return app, nil
+}
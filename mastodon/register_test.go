@@ -0,0 +1,65 @@
+package mastodon
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/dghubble/gologin"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func TestRegisterApp(t *testing.T) {
+	proxyClient, server := newMastodonTestServer(testUserJSON)
+	defer server.Close()
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+
+	// RegisterApp assert that:
+	// - the app name, redirect URI, and scopes are POSTed to api/v1/apps
+	// - the App client credentials are returned
+	app, err := RegisterApp(ctx, testInstanceURL+"/", "test app", "https://example.com/mastodon/callback", []string{"read:accounts", "profile"})
+	if assert.Nil(t, err) {
+		assert.Equal(t, &App{
+			ID:           "563419",
+			Name:         "test app",
+			RedirectURI:  "https://example.com/mastodon/callback",
+			ClientID:     "TWhM-tNSuncnqN7DBJmoyeLnk6K3iJJ71KKXxgL1hPM",
+			ClientSecret: "ZEaFUFmF0umgBX1qKJDjaU99Q31lDkOU8NutzTOoliw",
+		}, app)
+	}
+}
+
+func TestRegisterApp_Errors(t *testing.T) {
+	proxyClient, server := newMastodonTestServer(testUserJSON)
+	defer server.Close()
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+
+	// RegisterApp assert that:
+	// - invalid instance URLs fail without a request
+	// - rejected registrations fail with ErrUnableToRegisterApp and the
+	// Mastodon APIError as the cause
+	_, err := RegisterApp(ctx, "mastodon.example.com", "test app", "https://example.com/mastodon/callback", nil)
+	assert.Equal(t, `mastodon: invalid instance URL "mastodon.example.com"`, err.Error())
+	_, err = RegisterApp(ctx, testInstanceURL, "test app", "callback", []string{"read:accounts", "profile"})
+	assert.True(t, errors.Is(err, ErrUnableToRegisterApp))
+	var loginErr *gologin.Error
+	if assert.True(t, errors.As(err, &loginErr)) {
+		assert.Equal(t, http.StatusUnprocessableEntity, loginErr.StatusCode)
+	}
+	var apiErr *APIError
+	assert.True(t, errors.As(err, &apiErr))
+}
+
+func TestRegisterApp_NotJSON(t *testing.T) {
+	proxyClient, server := newHTMLErrorServer(http.StatusServiceUnavailable)
+	defer server.Close()
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+
+	// RegisterApp with an instance responding with an HTML page, assert that:
+	// - registration fails with ErrUnableToRegisterApp and ErrNotJSON
+	_, err := RegisterApp(ctx, testInstanceURL, "test app", "https://example.com/mastodon/callback", []string{"read:accounts", "profile"})
+	assert.True(t, errors.Is(err, ErrUnableToRegisterApp))
+	assert.True(t, errors.Is(err, ErrNotJSON))
+}
@@ -0,0 +1,69 @@
+package mastodon
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/dghubble/gologin/testutils"
+)
+
+const (
+	// testInstanceURL is the Mastodon instance of tests.
+	testInstanceURL = "https://mastodon.example.com"
+	// testUserJSON is a verify_credentials response.
+	testUserJSON = `{"id": "14715", "username": "trwnh", "acct": "trwnh", "display_name": "infinite love ⴳ", "locked": false, "bot": false, "created_at": "2016-11-24T10:02:12.085Z", "url": "https://mastodon.example.com/@trwnh", "avatar": "https://files.mastodon.example.com/accounts/avatars/000/014/715/original/34aa222f4ae2e0a9.png", "followers_count": 821, "following_count": 178}`
+	// testAppJSON is an api/v1/apps response.
+	testAppJSON = `{"id": "563419", "name": "test app", "website": null, "redirect_uri": "https://example.com/mastodon/callback", "client_id": "TWhM-tNSuncnqN7DBJmoyeLnk6K3iJJ71KKXxgL1hPM", "client_secret": "ZEaFUFmF0umgBX1qKJDjaU99Q31lDkOU8NutzTOoliw", "vapid_key": "BCk-QqERU0q-CfYZjcuB6lnyyOYfJ2AifKqfeGIm7Z-HiTU5T9eTG5GxVA0_OH5mMlI4UkkDTpaZwozy0TzdZ2M="}`
+	// testInvalidTokenJSON is a Mastodon error response.
+	testInvalidTokenJSON = `{"error": "The access token is invalid"}`
+	// testCloudflareHTML is an HTML error page of a CDN in front of an
+	// instance.
+	testCloudflareHTML = `<!DOCTYPE html><html><head><title>mastodon.example.com | 502: Bad gateway</title></head><body>Bad gateway</body></html>`
+)
+
+// newMastodonTestServer returns a new httptest.Server which mocks the
+// Mastodon instance token, verify_credentials, and apps endpoints and a
+// client which proxies requests to the server. The verify_credentials
+// endpoint responds with the given json data, or an error for tokens other
+// than "any-token". The caller must close the server.
+func newMastodonTestServer(jsonData string) (*http.Client, *httptest.Server) {
+	client, mux, server := testutils.TestServer()
+	mux.HandleFunc("/oauth/token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token": "any-token", "token_type": "Bearer", "scope": "read:accounts", "created_at": 1573979017}`)
+	})
+	mux.HandleFunc("/api/v1/accounts/verify_credentials", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		if r.Header.Get("Authorization") != "Bearer any-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprintf(w, testInvalidTokenJSON)
+			return
+		}
+		fmt.Fprintf(w, jsonData)
+	})
+	mux.HandleFunc("/api/v1/apps", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		if r.Method != "POST" || r.PostFormValue("client_name") != "test app" || r.PostFormValue("redirect_uris") != "https://example.com/mastodon/callback" || r.PostFormValue("scopes") != "read:accounts profile" {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			fmt.Fprintf(w, `{"error": "Validation failed: Redirect URI must be an absolute URI."}`)
+			return
+		}
+		fmt.Fprintf(w, testAppJSON)
+	})
+	return client, server
+}
+
+// newHTMLErrorServer returns a new httptest.Server which responds to all
+// requests with an HTML error page and the given status, like instances
+// behind a CDN, and a client which proxies requests to the server. The
+// caller must close the server.
+func newHTMLErrorServer(status int) (*http.Client, *httptest.Server) {
+	client, mux, server := testutils.TestServer()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+		w.WriteHeader(status)
+		fmt.Fprintf(w, testCloudflareHTML)
+	})
+	return client, server
+}
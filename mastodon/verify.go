@@ -0,0 +1,79 @@
+package mastodon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/dghubble/gologin/internal/jsonclient"
+)
+
+// ErrNotJSON is the error of Mastodon instance responses which are not JSON,
+// such as HTML error pages of a CDN or proxy in front of the instance.
+var ErrNotJSON = errors.New("mastodon: Mastodon instance response is not JSON")
+
+// User is a Mastodon account.
+type User struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	// Acct is the username on the instance or username@domain for remote
+	// accounts
+	Acct        string `json:"acct"`
+	DisplayName string `json:"display_name"`
+	Avatar      string `json:"avatar"`
+	URL         string `json:"url"`
+}
+
+// APIError is a Mastodon API error response.
+type APIError struct {
+	Message     string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+func (e *APIError) Error() string {
+	if e.Description != "" {
+		return fmt.Sprintf("mastodon: %s: %s", e.Message, e.Description)
+	}
+	return fmt.Sprintf("mastodon: %s", e.Message)
+}
+
+// client is a Mastodon client for obtaining a User.
+type client struct {
+	json *jsonclient.Client
+}
+
+// newClient returns a new Mastodon client for the instance at the
+// (normalized) instanceURL.
+func newClient(httpClient *http.Client, instanceURL string) *client {
+	return &client{
+		json: jsonclient.New(httpClient, instanceURL+"/"),
+	}
+}
+
+// VerifyCredentials gets the current Mastodon User.
+// https://docs.joinmastodon.org/methods/accounts/#verify_credentials
+func (c *client) VerifyCredentials(ctx context.Context) (*User, *http.Response, error) {
+	user := new(User)
+	apiErr := new(APIError)
+	resp, err := c.json.Get(ctx, "api/v1/accounts/verify_credentials", nil, user, apiErr)
+	err = checkJSON(resp, err)
+	if err == nil && apiErr.Message != "" {
+		err = apiErr
+	}
+	return user, resp, err
+}
+
+// checkJSON returns an error wrapping ErrNotJSON if the response has a body
+// which is not JSON (and so was not decoded). Otherwise, it returns err.
+func checkJSON(resp *http.Response, err error) error {
+	if err != nil || resp == nil || resp.StatusCode == http.StatusNoContent {
+		return err
+	}
+	contentType := resp.Header.Get("Content-Type")
+	if !strings.Contains(contentType, "application/json") {
+		return fmt.Errorf("%w (Content-Type %q)", ErrNotJSON, contentType)
+	}
+	return nil
+}
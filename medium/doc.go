@@ -0,0 +1,2 @@
+// Package medium provides Medium OAuth2 login and callback handlers.
+package medium
@@ -0,0 +1,120 @@
+package medium
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/internal"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"golang.org/x/oauth2"
+)
+
+// ProviderName is the provider name set in the ctx (see
+// gologin.ProviderFromContext) and the Profile.
+const ProviderName = "medium"
+
+// Medium login errors
+var (
+	ErrUnableToGetMediumUser = errors.New("medium: unable to get Medium User")
+)
+
+// Endpoint is Medium's OAuth2 endpoint. Medium requires client credentials in
+// the (form-encoded) request body.
+var Endpoint = oauth2.Endpoint{
+	AuthURL:   "https://medium.com/m/oauth/authorize",
+	TokenURL:  "https://api.medium.com/v1/tokens",
+	AuthStyle: oauth2.AuthStyleInParams,
+}
+
+// StateHandler checks for a state cookie. If found, the state value is read
+// and added to the ctx. Otherwise, a non-guessable value is added to the ctx
+// and to a (short-lived) state cookie issued to the requester.
+//
+// Implements OAuth 2 RFC 6749 10.12 CSRF Protection. If you wish to issue
+// state params differently, write a http.Handler which sets the ctx state,
+// using oauth2 WithState(ctx, state) since it is required by LoginHandler
+// and CallbackHandler.
+func StateHandler(config gologin.CookieConfig, success http.Handler) http.Handler {
+	return oauth2Login.StateHandler(config, success)
+}
+
+// LoginHandler handles Medium login requests by reading the state value from
+// the ctx and redirecting requests to the AuthURL with that state value.
+// Any AuthCodeOptions are added to the AuthURL.
+//
+// Medium rejects space-separated scopes, so the oauth2 Config Scopes (e.g.
+// "basicProfile", "publishPost") are sent comma-separated.
+func LoginHandler(config *oauth2.Config, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	if len(config.Scopes) > 0 {
+		opts = append([]oauth2.AuthCodeOption{oauth2.SetAuthURLParam("scope", strings.Join(config.Scopes, ","))}, opts...)
+	}
+	return oauth2Login.LoginHandler(config, failure, opts...)
+}
+
+// CallbackHandler handles Medium redirection URI requests and adds the Medium
+// access token and User to the ctx. If authentication succeeds, handling
+// delegates to the success handler, otherwise to the failure handler.
+// Any AuthCodeOptions are sent with the token exchange.
+//
+// Medium token responses are read regardless of their camelCase fields. The
+// ctx Token includes the refresh token, which does not expire.
+func CallbackHandler(config *oauth2.Config, success, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	success = mediumHandler(config, success, failure)
+	callback := oauth2Login.CallbackHandler(config, success, failure, opts...)
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		ctx = context.WithValue(ctx, oauth2.HTTPClient, tokenClient(internal.ContextClient(ctx)))
+		callback.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// mediumHandler is a http.Handler that gets the OAuth2 Token from the ctx to
+// get the corresponding Medium User. If successful, the User is added to the
+// ctx and the success handler is called. Otherwise, the failure handler is
+// called.
+func mediumHandler(config *oauth2.Config, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		httpClient := internal.OAuth2Client(ctx, config, token)
+		user, resp, err := newClient(httpClient).Me(ctx)
+		err = validateResponse(user, resp, err)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		ctx = WithUser(ctx, user)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// validateResponse returns an error if the given Medium User, raw
+// http.Response, or error are unexpected. Returns nil if they are valid, or a
+// *gologin.Error which preserves the cause and status code.
+func validateResponse(user *User, resp *http.Response, err error) error {
+	var status int
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	if err != nil || status != http.StatusOK {
+		return &gologin.Error{Provider: "medium", Op: "get user", StatusCode: status, Err: err, Kind: ErrUnableToGetMediumUser}
+	}
+	if user == nil || user.ID == "" {
+		return &gologin.Error{Provider: "medium", Op: "get user", StatusCode: status, Kind: ErrUnableToGetMediumUser}
+	}
+	return nil
+}
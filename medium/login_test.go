@@ -0,0 +1,192 @@
+package medium
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/dghubble/gologin"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func testConfig() *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     "client_id",
+		ClientSecret: "client_secret",
+		RedirectURL:  "https://example.com/medium/callback",
+		Endpoint:     Endpoint,
+		Scopes:       []string{"basicProfile", "publishPost"},
+	}
+}
+
+func TestLoginHandler(t *testing.T) {
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// LoginHandler assert that:
+	// - redirects to the Medium AuthURL with the state
+	// - the Config Scopes are comma-separated
+	loginHandler := LoginHandler(testConfig(), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	ctx := oauth2Login.WithState(context.Background(), "d4e5f6")
+	loginHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, http.StatusFound, w.Code)
+	location, err := url.Parse(w.HeaderMap.Get("Location"))
+	if assert.Nil(t, err) {
+		assert.Equal(t, "medium.com", location.Host)
+		assert.Equal(t, "/m/oauth/authorize", location.Path)
+		assert.Equal(t, "d4e5f6", location.Query().Get("state"))
+		assert.Equal(t, "basicProfile,publishPost", location.Query().Get("scope"))
+	}
+}
+
+func TestCallbackHandler(t *testing.T) {
+	proxyClient, server := newMediumTestServer(testTokenJSON, testUserJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithState(ctx, "d4e5f6")
+
+	expectedUser := &User{
+		ID:       "5303d74c64f66366f00cb9b2a94f3251bf5",
+		Username: "majelbstoat",
+		Name:     "Jamie Talbot",
+		URL:      "https://medium.com/@majelbstoat",
+		ImageURL: "https://images.medium.com/0*fkfQiTzT7TlUGGyI.png",
+	}
+	success := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "any-token", token.AccessToken)
+			assert.Equal(t, "any-refresh", token.RefreshToken)
+			assert.Equal(t, "Bearer", token.TokenType)
+			assert.Equal(t, 2060, token.Expiry.Year())
+		}
+		user, err := UserFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, expectedUser, user)
+		}
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// CallbackHandler assert that:
+	// - the camelCase token response is read
+	// - success handler is called
+	// - Medium Token (with refresh token) and User are added to the ctx of
+	// the success handler
+	callbackHandler := CallbackHandler(testConfig(), http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestCallbackHandler_InvalidClient(t *testing.T) {
+	proxyClient, server := newMediumTestServer(testTokenJSON, testUserJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithState(ctx, "d4e5f6")
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		var retrieveErr *oauth2.RetrieveError
+		if assert.True(t, errors.As(err, &retrieveErr)) {
+			assert.Equal(t, http.StatusUnauthorized, retrieveErr.Response.StatusCode)
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// CallbackHandler with invalid client credentials, assert that:
+	// - failure handler is called with the token error
+	config := testConfig()
+	config.ClientSecret = "other"
+	callbackHandler := CallbackHandler(config, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestMediumHandler_MissingCtxToken(t *testing.T) {
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "oauth2: Context missing Token", err.Error())
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// MediumHandler called without Token in ctx, assert that:
+	// - failure handler is called
+	// - error about ctx missing token is added to the failure handler ctx
+	mediumHandler := mediumHandler(testConfig(), success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	mediumHandler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestMediumHandler_ErrorGettingUser(t *testing.T) {
+	proxyClient, server := newMediumTestServer(testTokenJSON, testUserJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "invalid-token"})
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		assert.True(t, errors.Is(err, ErrUnableToGetMediumUser))
+		var apiErr *APIError
+		if assert.True(t, errors.As(err, &apiErr)) {
+			assert.Equal(t, 6003, apiErr.Code)
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// MediumHandler with an invalid token, assert that:
+	// - failure handler is called
+	// - error cannot get Medium User, caused by the APIError, is added to
+	// the failure handler ctx
+	mediumHandler := mediumHandler(testConfig(), success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	mediumHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestRewriteToken(t *testing.T) {
+	expiresAt := time.Now().Add(time.Hour).UnixNano() / int64(time.Millisecond)
+	body := rewriteToken([]byte(fmt.Sprintf(`{"tokenType": "Bearer", "accessToken": "any-token", "refreshToken": "any-refresh", "expiresAt": %d}`, expiresAt)))
+	assert.Contains(t, string(body), `"access_token":"any-token"`)
+	assert.Contains(t, string(body), `"refresh_token":"any-refresh"`)
+	assert.Contains(t, string(body), `"token_type":"Bearer"`)
+	assert.Regexp(t, `"expires_in":35\d\d`, string(body))
+	assert.NotContains(t, string(body), "accessToken")
+	// non-object bodies are unchanged
+	assert.Equal(t, "not json", string(rewriteToken([]byte("not json"))))
+}
+
+func TestValidateResponse(t *testing.T) {
+	validUser := &User{ID: "5303d74c64f66366f00cb9b2a94f3251bf5"}
+	validResponse := &http.Response{StatusCode: 200}
+	invalidResponse := &http.Response{StatusCode: 500}
+	assert.Equal(t, nil, validateResponse(validUser, validResponse, nil))
+	assert.True(t, errors.Is(validateResponse(validUser, validResponse, fmt.Errorf("Server error")), ErrUnableToGetMediumUser))
+	assert.True(t, errors.Is(validateResponse(validUser, invalidResponse, nil), ErrUnableToGetMediumUser))
+	assert.True(t, errors.Is(validateResponse(&User{}, validResponse, nil), ErrUnableToGetMediumUser))
+	assert.True(t, errors.Is(validateResponse(nil, validResponse, nil), ErrUnableToGetMediumUser))
+}
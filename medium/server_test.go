@@ -0,0 +1,47 @@
+package medium
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/dghubble/gologin/testutils"
+)
+
+const (
+	// testTokenJSON is a Medium token response, with camelCase fields and an
+	// expiresAt (in milliseconds) of 2060-07-01.
+	testTokenJSON = `{"tokenType": "Bearer", "accessToken": "any-token", "refreshToken": "any-refresh", "scope": ["basicProfile", "publishPost"], "expiresAt": 2855865600000}`
+	// testUserJSON is a Medium me response.
+	testUserJSON = `{"data": {"id": "5303d74c64f66366f00cb9b2a94f3251bf5", "username": "majelbstoat", "name": "Jamie Talbot", "url": "https://medium.com/@majelbstoat", "imageUrl": "https://images.medium.com/0*fkfQiTzT7TlUGGyI.png"}}`
+	// testInvalidTokenJSON is a Medium error response.
+	testInvalidTokenJSON = `{"errors": [{"message": "Token was invalid.", "code": 6003}]}`
+)
+
+// newMediumTestServer returns a new httptest.Server which mocks the Medium
+// token and me endpoints and a client which proxies requests to the server.
+// The token endpoint responds with the tokenJSON for form-encoded client
+// credentials. The me endpoint responds with the given json data, or a token
+// error for tokens other than "any-token". The caller must close the server.
+func newMediumTestServer(tokenJSON, jsonData string) (*http.Client, *httptest.Server) {
+	client, mux, server := testutils.TestServer()
+	mux.HandleFunc("/v1/tokens", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		if r.PostFormValue("client_id") != "client_id" || r.PostFormValue("client_secret") != "client_secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprintf(w, `{"errors": [{"message": "Client credentials were invalid.", "code": 6002}]}`)
+			return
+		}
+		fmt.Fprintf(w, tokenJSON)
+	})
+	mux.HandleFunc("/v1/me", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		if r.Header.Get("Authorization") != "Bearer any-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprintf(w, testInvalidTokenJSON)
+			return
+		}
+		fmt.Fprintf(w, jsonData)
+	})
+	return client, server
+}
@@ -0,0 +1,140 @@
+package medium
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dghubble/gologin/internal/jsonclient"
+)
+
+const mediumAPI = "https://api.medium.com/v1/"
+
+// User is a Medium user.
+type User struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	Name     string `json:"name"`
+	URL      string `json:"url"`
+	ImageURL string `json:"imageUrl"`
+}
+
+// APIError is a Medium API error.
+type APIError struct {
+	Message string `json:"message"`
+	Code    int    `json:"code"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("medium: %s (%d)", e.Message, e.Code)
+}
+
+// userResponse is a Medium me response.
+type userResponse struct {
+	Data *User `json:"data"`
+}
+
+// errorResponse is a Medium API error response.
+type errorResponse struct {
+	Errors []APIError `json:"errors"`
+}
+
+// client is a Medium client for obtaining the current User.
+type client struct {
+	json *jsonclient.Client
+}
+
+// newClient returns a new Medium client.
+func newClient(httpClient *http.Client) *client {
+	return &client{
+		json: jsonclient.New(httpClient, mediumAPI),
+	}
+}
+
+// Me returns the current Medium User. If Medium responds with errors, the
+// first is returned as an *APIError.
+// https://github.com/Medium/medium-api-docs#31-users
+func (c *client) Me(ctx context.Context) (*User, *http.Response, error) {
+	userResp := new(userResponse)
+	errResp := new(errorResponse)
+	resp, err := c.json.Get(ctx, "me", nil, userResp, errResp)
+	if err == nil && len(errResp.Errors) > 0 {
+		err = &errResp.Errors[0]
+	}
+	return userResp.Data, resp, err
+}
+
+// tokenFields maps the camelCase fields of Medium token responses to the
+// OAuth2 fields golang.org/x/oauth2 reads.
+var tokenFields = map[string]string{
+	"accessToken":  "access_token",
+	"refreshToken": "refresh_token",
+	"tokenType":    "token_type",
+	"expiresAt":    "expires_at",
+}
+
+// tokenClient returns a copy of the http.Client which rewrites Medium token
+// responses so they're read by golang.org/x/oauth2.
+func tokenClient(client *http.Client) *http.Client {
+	c := *client
+	c.Transport = &tokenTransport{base: client.Transport}
+	return &c
+}
+
+// tokenTransport is a http.RoundTripper which rewrites the camelCase fields
+// of JSON token responses (see tokenFields) and sets expires_in from the
+// expires_at time in milliseconds.
+type tokenTransport struct {
+	base http.RoundTripper
+}
+
+func (t *tokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	resp, err := base.RoundTrip(req)
+	if err != nil || !strings.HasSuffix(req.URL.Path, "/tokens") || !strings.Contains(resp.Header.Get("Content-Type"), "application/json") {
+		return resp, err
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(rewriteToken(body)))
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	return resp, nil
+}
+
+// rewriteToken returns the JSON token response with OAuth2 field names and an
+// expires_in. Bodies which aren't JSON objects are returned unchanged.
+func rewriteToken(body []byte) []byte {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return body
+	}
+	for camel, snake := range tokenFields {
+		if value, ok := fields[camel]; ok {
+			fields[snake] = value
+			delete(fields, camel)
+		}
+	}
+	if expiresAt, ok := fields["expires_at"].(float64); ok && fields["expires_in"] == nil {
+		expiry := time.Unix(0, int64(expiresAt)*int64(time.Millisecond))
+		if expiresIn := int64(time.Until(expiry) / time.Second); expiresIn > 0 {
+			fields["expires_in"] = expiresIn
+		}
+	}
+	rewritten, err := json.Marshal(fields)
+	if err != nil {
+		return body
+	}
+	return rewritten
+}
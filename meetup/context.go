@@ -0,0 +1,47 @@
+package meetup
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/dghubble/gologin"
+)
+
+// unexported key type prevents collisions
+type key int
+
+const (
+	userKey key = iota
+)
+
+// WithUser returns a copy of ctx that stores the Meetup User and its gologin
+// Profile.
+func WithUser(ctx context.Context, user *User) context.Context {
+	ctx = gologin.WithProfile(ctx, newProfile(user))
+	return context.WithValue(ctx, userKey, user)
+}
+
+// UserFromContext returns the Meetup User from the ctx.
+func UserFromContext(ctx context.Context) (*User, error) {
+	user, ok := ctx.Value(userKey).(*User)
+	if !ok {
+		return nil, fmt.Errorf("meetup: Context missing Meetup User")
+	}
+	return user, nil
+}
+
+// newProfile returns the gologin Profile of the Meetup User.
+func newProfile(user *User) *gologin.Profile {
+	if user == nil {
+		return nil
+	}
+	return &gologin.Profile{
+		Provider:  ProviderName,
+		ID:        strconv.FormatInt(user.ID, 10),
+		Email:     user.Email,
+		Name:      user.Name,
+		AvatarURL: user.Photo.PhotoLink,
+		Raw:       gologin.ProfileRaw(user, "id", "email", "name", "photo"),
+	}
+}
@@ -0,0 +1,2 @@
+// Package meetup provides Meetup OAuth2 login and callback handlers.
+package meetup
@@ -0,0 +1,36 @@
+package meetup
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/dghubble/gologin/testutils"
+)
+
+const (
+	// testTokenJSON is a Meetup token response with a one hour access token.
+	testTokenJSON = `{"access_token": "any-token", "token_type": "bearer", "expires_in": 3600, "refresh_token": "any-refresh"}`
+	// testUserJSON is a members/self response.
+	testUserJSON = `{"id": 221133, "name": "Casey Meetup", "email": "casey@example.com", "status": "active", "joined": 1284503472000, "city": "Brooklyn", "country": "us", "photo": {"id": 518211, "highres_link": "https://secure.meetupstatic.com/photos/member/highres_518211.jpeg", "photo_link": "https://secure.meetupstatic.com/photos/member/member_518211.jpeg", "thumb_link": "https://secure.meetupstatic.com/photos/member/thumb_518211.jpeg"}}`
+	// testInvalidTokenJSON is a members/self error response.
+	testInvalidTokenJSON = `{"errors": [{"code": "auth_fail", "message": "Invalid oauth credentials"}, {"code": "other", "message": "Other error"}]}`
+)
+
+// newMeetupTestServer returns a new httptest.Server which mocks the Meetup
+// /oauth2/access token and members/self endpoints and a client which proxies
+// requests to the server. The members/self endpoint responds with the given
+// status and json data. The caller must close the server.
+func newMeetupTestServer(status int, jsonData string) (*http.Client, *httptest.Server) {
+	client, mux, server := testutils.TestServer()
+	mux.HandleFunc("/oauth2/access", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, testTokenJSON)
+	})
+	mux.HandleFunc("/members/self", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		fmt.Fprintf(w, jsonData)
+	})
+	return client, server
+}
@@ -0,0 +1,68 @@
+package meetup
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/dghubble/gologin/internal/jsonclient"
+)
+
+const meetupAPI = "https://api.meetup.com/"
+
+// User is a Meetup member.
+type User struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+	// Email requires the "basic" scope
+	Email   string `json:"email"`
+	City    string `json:"city"`
+	Country string `json:"country"`
+	Photo   Photo  `json:"photo"`
+}
+
+// Photo is a Meetup member photo.
+type Photo struct {
+	PhotoLink string `json:"photo_link"`
+}
+
+// APIError is a Meetup API error.
+// https://www.meetup.com/meetup_api/#errors
+type APIError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("meetup: %s (%s)", e.Message, e.Code)
+}
+
+// errorResponse is a Meetup API error response.
+type errorResponse struct {
+	Errors []APIError `json:"errors"`
+}
+
+// client is a Meetup client for obtaining the current User.
+type client struct {
+	json *jsonclient.Client
+}
+
+// newClient returns a new Meetup client.
+func newClient(httpClient *http.Client) *client {
+	return &client{
+		json: jsonclient.New(httpClient, meetupAPI),
+	}
+}
+
+// MembersSelf returns the authenticated Meetup User. If Meetup responds with
+// errors, the first is returned as an *APIError.
+// https://www.meetup.com/meetup_api/docs/members/:member_id/#get
+func (c *client) MembersSelf(ctx context.Context) (*User, *http.Response, error) {
+	user := new(User)
+	errResp := new(errorResponse)
+	resp, err := c.json.Get(ctx, "members/self", nil, user, errResp)
+	if err == nil && len(errResp.Errors) > 0 {
+		err = &errResp.Errors[0]
+	}
+	return user, resp, err
+}
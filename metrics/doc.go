@@ -0,0 +1,26 @@
+/*
+Package metrics provides Prometheus metrics of gologin login lifecycle events.
+
+Metrics are registered with a caller-supplied prometheus.Registerer and
+implement gologin Hooks, which are added to the ctx of the login and callback
+chains.
+
+	m, err := metrics.New(registry)
+	if err != nil {
+		log.Fatal(err)
+	}
+	hooks := m.Hooks()
+	mux.Handle("/facebook/login", gologin.HooksHandler(hooks, facebook.StateHandler(stateConfig, facebook.LoginHandler(oauth2Config, nil))))
+	mux.Handle("/facebook/callback", gologin.HooksHandler(hooks, facebook.StateHandler(stateConfig, facebook.CallbackHandler(oauth2Config, issueSession(), nil))))
+
+The series are:
+
+	gologin_logins_started_total{provider}
+	gologin_callbacks_total{provider,result}
+	gologin_token_exchange_duration_seconds{provider}
+	gologin_userfetch_duration_seconds{provider}
+
+Callback results are "success", "denied", "state_mismatch", "exchange_failed",
+"userfetch_failed", or "error" for other failures.
+*/
+package metrics
@@ -0,0 +1,112 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/dghubble/gologin"
+	oauth1Login "github.com/dghubble/gologin/oauth1"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/oauth2"
+)
+
+// Callback results
+const (
+	ResultSuccess         = "success"
+	ResultDenied          = "denied"
+	ResultStateMismatch   = "state_mismatch"
+	ResultExchangeFailed  = "exchange_failed"
+	ResultUserFetchFailed = "userfetch_failed"
+	ResultError           = "error"
+)
+
+// Metrics are Prometheus collectors of gologin login lifecycle events.
+type Metrics struct {
+	loginsStarted *prometheus.CounterVec
+	callbacks     *prometheus.CounterVec
+	tokenExchange *prometheus.HistogramVec
+	userFetch     *prometheus.HistogramVec
+}
+
+// New returns Metrics whose collectors are registered with the Registerer.
+// Returns an error if a collector cannot be registered (e.g. the Registerer
+// already has Metrics).
+func New(registerer prometheus.Registerer) (*Metrics, error) {
+	m := &Metrics{
+		loginsStarted: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "gologin",
+			Name:      "logins_started_total",
+			Help:      "Login redirects to the provider.",
+		}, []string{"provider"}),
+		callbacks: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "gologin",
+			Name:      "callbacks_total",
+			Help:      "Provider callbacks by result.",
+		}, []string{"provider", "result"}),
+		tokenExchange: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "gologin",
+			Name:      "token_exchange_duration_seconds",
+			Help:      "Duration of token exchanges with the provider.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"provider"}),
+		userFetch: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "gologin",
+			Name:      "userfetch_duration_seconds",
+			Help:      "Duration of provider user requests.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"provider"}),
+	}
+	for _, collector := range []prometheus.Collector{m.loginsStarted, m.callbacks, m.tokenExchange, m.userFetch} {
+		if err := registerer.Register(collector); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// Hooks returns gologin Hooks which record the Metrics.
+func (m *Metrics) Hooks() *gologin.Hooks {
+	return &gologin.Hooks{
+		OnLoginRedirect: func(ctx context.Context, provider string, req *http.Request) {
+			m.loginsStarted.WithLabelValues(provider).Inc()
+		},
+		OnTokenExchange: func(ctx context.Context, provider string, duration time.Duration, err error) {
+			m.tokenExchange.WithLabelValues(provider).Observe(duration.Seconds())
+		},
+		OnUserFetch: func(ctx context.Context, provider string, duration time.Duration, err error) {
+			m.userFetch.WithLabelValues(provider).Observe(duration.Seconds())
+		},
+		OnCallbackSuccess: func(ctx context.Context, provider, userID string) {
+			m.callbacks.WithLabelValues(provider, ResultSuccess).Inc()
+		},
+		OnCallbackFailure: func(ctx context.Context, provider string, err error) {
+			m.callbacks.WithLabelValues(provider, result(err)).Inc()
+		},
+	}
+}
+
+// result returns the callback result label of a callback error.
+func result(err error) string {
+	var gologinErr *gologin.Error
+	var retrieveErr *oauth2.RetrieveError
+	var urlErr *url.Error
+	switch {
+	case oauth2Login.IsAccessDenied(err) || errors.Is(err, oauth1Login.ErrAccessDenied):
+		return ResultDenied
+	case errors.Is(err, oauth2Login.ErrInvalidState) || errors.Is(err, oauth2Login.ErrStateExpired) || errors.Is(err, oauth2Login.ErrStateAlreadyUsed) || errors.Is(err, oauth2Login.ErrMissingStateCookie):
+		return ResultStateMismatch
+	case errors.As(err, &gologinErr):
+		if gologinErr.Op == "get user" {
+			return ResultUserFetchFailed
+		}
+		return ResultError
+	case errors.As(err, &retrieveErr) || errors.As(err, &urlErr):
+		// token endpoint errors are not wrapped (user requests are)
+		return ResultExchangeFailed
+	}
+	return ResultError
+}
@@ -0,0 +1,163 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/facebook"
+	"github.com/dghubble/gologin/gologintest"
+	oauth1Login "github.com/dghubble/gologin/oauth1"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+var testStateConfig = gologin.CookieConfig{
+	Name:   "facebook-state",
+	Path:   "/",
+	MaxAge: 60,
+}
+
+// runFacebookLogin drives the facebook login and callback chains, with the
+// Hooks, through the provider. The callback state is replaced if non-empty.
+func runFacebookLogin(t *testing.T, hooks *gologin.Hooks, provider *gologintest.FakeOAuth2Provider, state string) {
+	config := &oauth2.Config{
+		ClientID:     "client_id",
+		ClientSecret: "client_secret",
+		RedirectURL:  "https://example.com/facebook/callback",
+		Endpoint:     provider.Endpoint(),
+	}
+	noop := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {})
+	loginHandler := gologin.HooksHandler(hooks, facebook.StateHandler(testStateConfig, facebook.LoginHandler(config, nil)))
+	callbackHandler := gologin.HooksHandler(hooks, facebook.StateHandler(testStateConfig, facebook.CallbackHandler(config, noop, noop)))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/facebook/login", nil)
+	loginHandler.ServeHTTP(w, req)
+	cookies := (&http.Response{Header: w.Header()}).Cookies()
+	noRedirects := &http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := noRedirects.Get(w.HeaderMap.Get("Location"))
+	if !assert.Nil(t, err) {
+		return
+	}
+	resp.Body.Close()
+	callbackURL, _ := url.Parse(resp.Header.Get("Location"))
+	if state != "" {
+		query := callbackURL.Query()
+		query.Set("state", state)
+		callbackURL.RawQuery = query.Encode()
+	}
+
+	ctx := gologin.WithHTTPClient(context.Background(), provider.Client())
+	req, _ = http.NewRequest("GET", callbackURL.RequestURI(), nil)
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	callbackHandler.ServeHTTP(httptest.NewRecorder(), req.WithContext(ctx))
+}
+
+// gather returns the registry series as "name{labels}" keys with counter
+// values or histogram sample counts.
+func gather(t *testing.T, registry *prometheus.Registry) map[string]float64 {
+	families, err := registry.Gather()
+	assert.Nil(t, err)
+	series := make(map[string]float64)
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			var labels []string
+			for _, label := range metric.GetLabel() {
+				labels = append(labels, fmt.Sprintf("%s=%q", label.GetName(), label.GetValue()))
+			}
+			key := fmt.Sprintf("%s{%s}", family.GetName(), strings.Join(labels, ","))
+			if metric.GetHistogram() != nil {
+				series[key] = float64(metric.GetHistogram().GetSampleCount())
+			} else {
+				series[key] = metric.GetCounter().GetValue()
+			}
+		}
+	}
+	return series
+}
+
+func TestMetrics(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	m, err := New(registry)
+	if !assert.Nil(t, err) {
+		return
+	}
+	hooks := m.Hooks()
+	cases := []struct {
+		configure func(*gologintest.FakeOAuth2Provider)
+		state     string
+	}{
+		{func(p *gologintest.FakeOAuth2Provider) {}, ""},
+		{func(p *gologintest.FakeOAuth2Provider) { p.AuthorizeError = "access_denied" }, ""},
+		{func(p *gologintest.FakeOAuth2Provider) {}, "tampered"},
+		{func(p *gologintest.FakeOAuth2Provider) { p.TokenStatus = http.StatusInternalServerError }, ""},
+		{func(p *gologintest.FakeOAuth2Provider) { p.UserInfoStatus = http.StatusInternalServerError }, ""},
+	}
+	for _, c := range cases {
+		provider := gologintest.NewFakeOAuth2ProviderWithUserInfoPath("/v2.9/me")
+		provider.UserInfoJSON = `{"id": "54638001", "name": "Ivy Crimson"}`
+		c.configure(provider)
+		runFacebookLogin(t, hooks, provider, c.state)
+		provider.Close()
+	}
+
+	// Metrics after fake flows, assert that:
+	// - login redirects are counted per provider
+	// - callbacks are counted by result
+	// - token exchanges and user fetches are observed
+	expected := map[string]float64{
+		`gologin_logins_started_total{provider="facebook"}`:                      5,
+		`gologin_callbacks_total{provider="facebook",result="success"}`:          1,
+		`gologin_callbacks_total{provider="facebook",result="denied"}`:           1,
+		`gologin_callbacks_total{provider="facebook",result="state_mismatch"}`:   1,
+		`gologin_callbacks_total{provider="facebook",result="exchange_failed"}`:  1,
+		`gologin_callbacks_total{provider="facebook",result="userfetch_failed"}`: 1,
+		`gologin_token_exchange_duration_seconds{provider="facebook"}`:           3,
+		`gologin_userfetch_duration_seconds{provider="facebook"}`:                2,
+	}
+	assert.Equal(t, expected, gather(t, registry))
+}
+
+func TestNew_AlreadyRegistered(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	_, err := New(registry)
+	assert.Nil(t, err)
+	// separate registries do not conflict
+	_, err = New(prometheus.NewRegistry())
+	assert.Nil(t, err)
+	_, err = New(registry)
+	assert.NotNil(t, err)
+}
+
+func TestResult(t *testing.T) {
+	cases := []struct {
+		err      error
+		expected string
+	}{
+		{&oauth2Login.AuthorizationError{Code: "access_denied"}, ResultDenied},
+		{oauth1Login.ErrAccessDenied, ResultDenied},
+		{oauth2Login.ErrInvalidState, ResultStateMismatch},
+		{oauth2Login.ErrStateExpired, ResultStateMismatch},
+		{oauth2Login.ErrMissingStateCookie, ResultStateMismatch},
+		{&oauth2.RetrieveError{Response: &http.Response{StatusCode: 500}}, ResultExchangeFailed},
+		{&gologin.Error{Provider: "facebook", Op: "get user", Kind: facebook.ErrUnableToGetFacebookUser}, ResultUserFetchFailed},
+		{&gologin.Error{Provider: "facebook", Op: "get permissions"}, ResultError},
+		{errors.New("other"), ResultError},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.expected, result(c.err), c.err.Error())
+	}
+}
@@ -0,0 +1,3 @@
+// Package microsoft provides Microsoft identity platform (Azure AD v2) OAuth2
+// login and callback handlers.
+package microsoft
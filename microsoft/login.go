@@ -0,0 +1,264 @@
+package microsoft
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/internal"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/dghubble/gologin/oidc"
+	"golang.org/x/oauth2"
+)
+
+// ProviderName is the provider name set in the ctx (see
+// gologin.ProviderFromContext) and the Profile.
+const ProviderName = "microsoft"
+
+const (
+	loginURL = "https://login.microsoftonline.com/"
+	// consumersTenantID is the tenant of personal Microsoft accounts
+	consumersTenantID = "9188040d-6c67-4c5b-b112-36a304b66dad"
+)
+
+// Microsoft identity platform tenants
+const (
+	// TenantCommon allows work, school, and personal accounts
+	TenantCommon = "common"
+	// TenantOrganizations allows work and school accounts
+	TenantOrganizations = "organizations"
+	// TenantConsumers allows personal accounts
+	TenantConsumers = "consumers"
+)
+
+// Microsoft login errors
+var (
+	ErrUnableToGetMicrosoftUser = errors.New("microsoft: unable to get Microsoft User")
+	ErrWrongTenant              = errors.New("microsoft: id_token tenant is not allowed")
+)
+
+// Config configures the Microsoft identity platform tenant and sign-in page.
+type Config struct {
+	// Tenant is TenantCommon, TenantOrganizations, TenantConsumers, or a
+	// tenant ID, which id_token tid claims must match. Defaults to
+	// TenantCommon.
+	Tenant string
+	// Market is the market and language of the sign-in page (e.g. "fr-FR"),
+	// sent as mkt by LoginHandlerWithConfig. A ctx locale (see oauth2
+	// WithLocale) takes precedence.
+	Market string
+	// LCID is the Windows locale ID of the personal account sign-in page
+	// (e.g. 1036 for French), sent as lc by LoginHandlerWithConfig.
+	LCID int
+}
+
+// tenant returns the Config Tenant or TenantCommon.
+func (c Config) tenant() string {
+	if c.Tenant == "" {
+		return TenantCommon
+	}
+	return c.Tenant
+}
+
+// Endpoint returns the Microsoft identity platform v2 OAuth2 endpoint of the
+// Config Tenant.
+func (c Config) Endpoint() oauth2.Endpoint {
+	return oauth2.Endpoint{
+		AuthURL:   loginURL + c.tenant() + "/oauth2/v2.0/authorize",
+		TokenURL:  loginURL + c.tenant() + "/oauth2/v2.0/token",
+		AuthStyle: oauth2.AuthStyleInParams,
+	}
+}
+
+// jwksURL returns the JSON Web Key Set URL of the Config Tenant.
+func (c Config) jwksURL() string {
+	return loginURL + c.tenant() + "/discovery/v2.0/keys"
+}
+
+// allowed returns true if the tenant ID is allowed by the Config Tenant.
+func (c Config) allowed(tenantID string) bool {
+	switch strings.ToLower(c.tenant()) {
+	case TenantCommon:
+		return true
+	case TenantOrganizations:
+		return tenantID != consumersTenantID
+	case TenantConsumers:
+		return tenantID == consumersTenantID
+	}
+	return strings.EqualFold(tenantID, c.Tenant)
+}
+
+// Endpoint is the Microsoft identity platform v2 OAuth2 endpoint of the
+// common tenant.
+var Endpoint = Config{}.Endpoint()
+
+// Scopes are the OpenID Connect scopes and the Graph User.Read scope.
+var Scopes = []string{"openid", "profile", "email", "User.Read"}
+
+// StateHandler checks for a state cookie. If found, the state value is read
+// and added to the ctx. Otherwise, a non-guessable value is added to the ctx
+// and to a (short-lived) state cookie issued to the requester.
+//
+// Implements OAuth 2 RFC 6749 10.12 CSRF Protection. If you wish to issue
+// state params differently, write a http.Handler which sets the ctx state,
+// using oauth2 WithState(ctx, state) since it is required by LoginHandler
+// and CallbackHandler.
+func StateHandler(config gologin.CookieConfig, success http.Handler) http.Handler {
+	return oauth2Login.StateHandler(config, success)
+}
+
+// LoginHandler handles Microsoft login requests by reading the state value
+// from the ctx and redirecting requests to the AuthURL with that state value.
+// Any AuthCodeOptions are added to the AuthURL.
+//
+// The config Endpoint should be the Endpoint of the microsoft Config and
+// Scopes should include the microsoft Scopes.
+func LoginHandler(config *oauth2.Config, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	return LoginHandlerWithConfig(config, Config{}, failure, opts...)
+}
+
+// LoginHandlerWithConfig handles Microsoft login requests like LoginHandler,
+// but adds the Config Market (mkt) and LCID (lc), if any, to the AuthURL. A
+// ctx locale (see oauth2 WithLocale) is sent as mkt.
+func LoginHandlerWithConfig(config *oauth2.Config, msConfig Config, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	opts = opts[:len(opts):len(opts)]
+	if msConfig.Market != "" {
+		opts = append(opts, oauth2.SetAuthURLParam("mkt", msConfig.Market))
+	}
+	if msConfig.LCID != 0 {
+		opts = append(opts, oauth2.SetAuthURLParam("lc", strconv.Itoa(msConfig.LCID)))
+	}
+	return oauth2Login.LocaleParamHandler("mkt", marketLocale, oauth2Login.LoginHandler(config, failure, opts...))
+}
+
+// marketLocale returns the locale in the ll-CC form of mkt (e.g. "fr_CA" is
+// "fr-CA").
+func marketLocale(locale string) string {
+	return strings.Replace(locale, "_", "-", -1)
+}
+
+// CallbackHandler handles Microsoft redirection URI requests of the common
+// tenant and adds the Microsoft Token, id_token Claims (see oidc
+// IDTokenFromContext), and User to the ctx. If authentication succeeds,
+// handling delegates to the success handler, otherwise to the failure
+// handler.
+// Any AuthCodeOptions are sent with the token exchange.
+func CallbackHandler(config *oauth2.Config, success, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	return CallbackHandlerWithConfig(config, Config{}, success, failure, opts...)
+}
+
+// CallbackHandlerWithConfig handles Microsoft redirection URI requests like
+// CallbackHandler, but verifies the id_token tid claim is allowed by the
+// microsoft Config Tenant. Otherwise, the failure handler is called with
+// ErrWrongTenant.
+func CallbackHandlerWithConfig(config *oauth2.Config, msConfig Config, success, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	success = microsoftHandler(config, msConfig, success, failure)
+	return oauth2Login.CallbackHandler(config, success, failure, opts...)
+}
+
+// microsoftHandler is a http.Handler that gets the OAuth2 Token from the ctx,
+// verifies its id_token and tenant, and gets the Microsoft Graph User. If
+// successful, the Claims and User are added to the ctx and the success
+// handler is called. Otherwise, the failure handler is called.
+func microsoftHandler(config *oauth2.Config, msConfig Config, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	// issuers depend on the id_token tenant (see ForIssuers)
+	verifier := oidc.NewIDTokenVerifier(msConfig.jwksURL(), config.ClientID)
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		rawIDToken, ok := token.Extra("id_token").(string)
+		if !ok || rawIDToken == "" {
+			ctx = gologin.WithError(ctx, oidc.ErrMissingIDToken)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		// the tid is read before verification to choose the issuer, which
+		// the signed id_token must then match
+		tenantID, err := unverifiedTenantID(rawIDToken)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		claims, err := verifier.ForIssuers(loginURL+tenantID+"/v2.0").Verify(ctx, rawIDToken)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		nonce, _ := oauth2Login.NonceFromContext(ctx)
+		if nonce != "" && claims.Nonce != nonce {
+			ctx = gologin.WithError(ctx, oidc.ErrInvalidNonce)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		if !msConfig.allowed(tenantID) {
+			ctx = gologin.WithError(ctx, ErrWrongTenant)
+			ctx = gologin.WithStatusCode(ctx, http.StatusForbidden)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		httpClient := internal.OAuth2Client(ctx, config, token)
+		user, resp, err := newClient(httpClient).Me(ctx)
+		err = validateResponse(user, resp, err)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		user.TenantID = tenantID
+		ctx = oidc.WithIDToken(ctx, claims)
+		ctx = WithUser(ctx, user)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// unverifiedTenantID returns the tid claim of the raw id_token without
+// verifying it.
+func unverifiedTenantID(rawIDToken string) (string, error) {
+	parts := strings.Split(rawIDToken, ".")
+	if len(parts) != 3 {
+		return "", oidc.ErrInvalidIDToken
+	}
+	data, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", oidc.ErrInvalidIDToken
+	}
+	var claims struct {
+		TenantID string `json:"tid"`
+	}
+	if err := json.Unmarshal(data, &claims); err != nil || claims.TenantID == "" {
+		return "", oidc.ErrInvalidIDToken
+	}
+	return claims.TenantID, nil
+}
+
+// validateResponse returns an error if the given Microsoft User, raw
+// http.Response, or error are unexpected. Returns nil if they are valid, or a
+// *gologin.Error which preserves the cause and status code.
+func validateResponse(user *User, resp *http.Response, err error) error {
+	var status int
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	if err != nil || status != http.StatusOK {
+		return &gologin.Error{Provider: "microsoft", Op: "get user", StatusCode: status, Err: err, Kind: ErrUnableToGetMicrosoftUser}
+	}
+	if user == nil || user.ID == "" {
+		return &gologin.Error{Provider: "microsoft", Op: "get user", StatusCode: status, Kind: ErrUnableToGetMicrosoftUser}
+	}
+	return nil
+}
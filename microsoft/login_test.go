@@ -0,0 +1,308 @@
+package microsoft
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/dghubble/gologin"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/dghubble/gologin/oidc"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func testConfig(msConfig Config) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     "client_id",
+		ClientSecret: "client_secret",
+		RedirectURL:  "https://example.com/microsoft/callback",
+		Endpoint:     msConfig.Endpoint(),
+		Scopes:       Scopes,
+	}
+}
+
+func TestConfigEndpoint(t *testing.T) {
+	assert.Equal(t, "https://login.microsoftonline.com/common/oauth2/v2.0/authorize", Endpoint.AuthURL)
+	assert.Equal(t, "https://login.microsoftonline.com/common/oauth2/v2.0/token", Endpoint.TokenURL)
+	endpoint := Config{Tenant: testTenantID}.Endpoint()
+	assert.Equal(t, "https://login.microsoftonline.com/"+testTenantID+"/oauth2/v2.0/authorize", endpoint.AuthURL)
+	assert.Equal(t, "https://login.microsoftonline.com/"+testTenantID+"/oauth2/v2.0/token", endpoint.TokenURL)
+	assert.Equal(t, oauth2.AuthStyleInParams, endpoint.AuthStyle)
+}
+
+func TestLoginHandlerWithConfig(t *testing.T) {
+	cases := []struct {
+		msConfig Config
+		locale   string
+		expected url.Values
+	}{
+		{Config{Market: "de-DE", LCID: 1031}, "", url.Values{"mkt": {"de-DE"}, "lc": {"1031"}}},
+		// the ctx locale takes precedence over the Config Market
+		{Config{Market: "de-DE"}, "fr_CA", url.Values{"mkt": {"fr-CA"}}},
+		{Config{}, "", url.Values{}},
+	}
+	for _, c := range cases {
+		// LoginHandlerWithConfig with a Market, LCID, or ctx locale, assert
+		// that:
+		// - they are added to the AuthURL as mkt and lc
+		loginHandler := LoginHandlerWithConfig(testConfig(c.msConfig), c.msConfig, testutils.AssertFailureNotCalled(t))
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		ctx := oauth2Login.WithState(context.Background(), "state_val")
+		if c.locale != "" {
+			ctx = oauth2Login.WithLocale(ctx, c.locale)
+		}
+		loginHandler.ServeHTTP(w, req.WithContext(ctx))
+		location, err := url.Parse(w.HeaderMap.Get("Location"))
+		if assert.Nil(t, err) {
+			query := location.Query()
+			for _, param := range []string{"mkt", "lc", "hl", "locale"} {
+				assert.Equal(t, c.expected[param], query[param], param)
+			}
+		}
+	}
+}
+
+func TestCallbackHandler(t *testing.T) {
+	proxyClient, server := newMicrosoftTestServer(testIDToken(testClaims(testTenantID)), testMemberJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithState(ctx, "d4e5f6")
+
+	expectedUser := &User{
+		ID:                "87d349ed-44d7-43e1-9a83-5f2406dee5bd",
+		DisplayName:       "Adele Vance",
+		Email:             "AdeleV@contoso.com",
+		UserPrincipalName: "AdeleV@contoso.onmicrosoft.com",
+		UserType:          "Member",
+		TenantID:          testTenantID,
+	}
+	success := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "any-token", token.AccessToken)
+		}
+		claims, err := oidc.IDTokenFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "https://login.microsoftonline.com/"+testTenantID+"/v2.0", claims.Issuer)
+		}
+		user, err := UserFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, expectedUser, user)
+			assert.False(t, user.IsGuest())
+		}
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// CallbackHandler assert that:
+	// - the common tenant accepts an id_token of any tenant, verified against
+	// the issuer of its tid
+	// - success handler is called
+	// - Microsoft Token, id_token Claims, and User are added to the ctx of the
+	// success handler
+	callbackHandler := CallbackHandler(testConfig(Config{}), http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestCallbackHandlerWithConfig_SingleTenant(t *testing.T) {
+	proxyClient, server := newMicrosoftTestServer(testIDToken(testClaims(testTenantID)), testMemberJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithState(ctx, "d4e5f6")
+
+	success := func(w http.ResponseWriter, req *http.Request) {
+		user, err := UserFromContext(req.Context())
+		if assert.Nil(t, err) {
+			assert.Equal(t, testTenantID, user.TenantID)
+		}
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// CallbackHandlerWithConfig of the tenant of the id_token, assert that:
+	// - the tenant's endpoint and keys are used
+	// - success handler is called
+	msConfig := Config{Tenant: testTenantID}
+	callbackHandler := CallbackHandlerWithConfig(testConfig(msConfig), msConfig, http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestMicrosoftHandler_PersonalAccount(t *testing.T) {
+	proxyClient, server := newMicrosoftTestServer("", testPersonalJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	token := (&oauth2.Token{AccessToken: "any-token"}).WithExtra(map[string]interface{}{"id_token": testIDToken(testClaims(consumersTenantID))})
+	ctx = oauth2Login.WithToken(ctx, token)
+
+	expectedUser := &User{
+		ID:                "a1b2c3d4e5f60718",
+		DisplayName:       "Pat Smith",
+		Email:             "pat.smith@outlook.com",
+		UserPrincipalName: "pat.smith@outlook.com",
+		TenantID:          consumersTenantID,
+	}
+	success := func(w http.ResponseWriter, req *http.Request) {
+		user, err := UserFromContext(req.Context())
+		if assert.Nil(t, err) {
+			assert.Equal(t, expectedUser, user)
+		}
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// MicrosoftHandler for a personal account with a null mail, assert that:
+	// - the User Email falls back to the userPrincipalName
+	// - the consumers tenant accepts personal accounts
+	for _, msConfig := range []Config{{}, {Tenant: TenantConsumers}} {
+		handler := microsoftHandler(testConfig(msConfig), msConfig, http.HandlerFunc(success), failure)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		handler.ServeHTTP(w, req.WithContext(ctx))
+		assert.Equal(t, "success handler called", w.Body.String())
+	}
+}
+
+func TestMicrosoftHandler_Guest(t *testing.T) {
+	proxyClient, server := newMicrosoftTestServer("", testGuestJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	token := (&oauth2.Token{AccessToken: "any-token"}).WithExtra(map[string]interface{}{"id_token": testIDToken(testClaims(testTenantID))})
+	ctx = oauth2Login.WithToken(ctx, token)
+
+	success := func(w http.ResponseWriter, req *http.Request) {
+		user, err := UserFromContext(req.Context())
+		if assert.Nil(t, err) {
+			assert.Equal(t, UserTypeGuest, user.UserType)
+			assert.True(t, user.IsGuest())
+		}
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// MicrosoftHandler for a guest user, assert that:
+	// - the User is a guest
+	msConfig := Config{Tenant: testTenantID}
+	handler := microsoftHandler(testConfig(msConfig), msConfig, http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	handler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestMicrosoftHandler_TenantErrors(t *testing.T) {
+	proxyClient, server := newMicrosoftTestServer("", testMemberJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	otherTenantID := "f8cdef31-a31e-4b4a-93e4-5f571e91255a"
+
+	cases := []struct {
+		name     string
+		msConfig Config
+		claims   string
+		err      error
+	}{
+		{"other tenant", Config{Tenant: testTenantID}, testClaims(otherTenantID), ErrWrongTenant},
+		{"personal account of organizations", Config{Tenant: TenantOrganizations}, testClaims(consumersTenantID), ErrWrongTenant},
+		{"work account of consumers", Config{Tenant: TenantConsumers}, testClaims(testTenantID), ErrWrongTenant},
+		{"tid of another issuer", Config{Tenant: testTenantID}, testIssuerClaims(otherTenantID, testTenantID), oidc.ErrInvalidIssuer},
+		{"missing tid", Config{}, `{"iss":"https://login.microsoftonline.com/common/v2.0","aud":"client_id"}`, oidc.ErrInvalidIDToken},
+	}
+	for _, c := range cases {
+		token := (&oauth2.Token{AccessToken: "any-token"}).WithExtra(map[string]interface{}{"id_token": testIDToken(c.claims)})
+		ctx := oauth2Login.WithToken(ctx, token)
+		success := testutils.AssertSuccessNotCalled(t)
+		failure := func(w http.ResponseWriter, req *http.Request) {
+			assert.Equal(t, c.err, gologin.ErrorFromContext(req.Context()), c.name)
+			fmt.Fprintf(w, "failure handler called")
+		}
+
+		// MicrosoftHandler with an id_token the Config Tenant rejects, assert
+		// that:
+		// - failure handler is called with the error
+		handler := microsoftHandler(testConfig(c.msConfig), c.msConfig, success, http.HandlerFunc(failure))
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		handler.ServeHTTP(w, req.WithContext(ctx))
+		assert.Equal(t, "failure handler called", w.Body.String(), c.name)
+	}
+}
+
+func TestMicrosoftHandler_MissingCtxToken(t *testing.T) {
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "oauth2: Context missing Token", err.Error())
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// MicrosoftHandler called without Token in ctx, assert that:
+	// - failure handler is called
+	// - error about ctx missing token is added to the failure handler ctx
+	microsoftHandler := microsoftHandler(testConfig(Config{}), Config{}, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	microsoftHandler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestMicrosoftHandler_ErrorGettingUser(t *testing.T) {
+	proxyClient, server := newMicrosoftTestServer("", testMemberJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	token := (&oauth2.Token{AccessToken: "revoked-token"}).WithExtra(map[string]interface{}{"id_token": testIDToken(testClaims(testTenantID))})
+	ctx = oauth2Login.WithToken(ctx, token)
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		assert.True(t, errors.Is(err, ErrUnableToGetMicrosoftUser))
+		var apiErr *APIError
+		if assert.True(t, errors.As(err, &apiErr)) {
+			assert.Equal(t, "InvalidAuthenticationToken", apiErr.Err.Code)
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// MicrosoftHandler with a token Graph rejects, assert that:
+	// - failure handler is called
+	// - error cannot get Microsoft User, caused by the APIError, is added to
+	// the failure handler ctx
+	microsoftHandler := microsoftHandler(testConfig(Config{}), Config{}, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	microsoftHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestValidateResponse(t *testing.T) {
+	validUser := &User{ID: "87d349ed-44d7-43e1-9a83-5f2406dee5bd", DisplayName: "Adele Vance"}
+	validResponse := &http.Response{StatusCode: 200}
+	invalidResponse := &http.Response{StatusCode: 401}
+	assert.Equal(t, nil, validateResponse(validUser, validResponse, nil))
+	assert.True(t, errors.Is(validateResponse(validUser, validResponse, fmt.Errorf("Server error")), ErrUnableToGetMicrosoftUser))
+	assert.True(t, errors.Is(validateResponse(validUser, invalidResponse, nil), ErrUnableToGetMicrosoftUser))
+	assert.True(t, errors.Is(validateResponse(&User{}, validResponse, nil), ErrUnableToGetMicrosoftUser))
+	assert.True(t, errors.Is(validateResponse(nil, validResponse, nil), ErrUnableToGetMicrosoftUser))
+}
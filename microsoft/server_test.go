@@ -0,0 +1,93 @@
+package microsoft
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/dghubble/gologin/testutils"
+)
+
+const (
+	// testTenantID is the tenant of the test work account.
+	testTenantID = "72f988bf-86f1-41af-91ab-2d7cd011db47"
+	// testMemberJSON is a Graph me response of a work account.
+	testMemberJSON = `{"@odata.context": "https://graph.microsoft.com/v1.0/$metadata#users(id,displayName,mail,userPrincipalName,userType)/$entity", "id": "87d349ed-44d7-43e1-9a83-5f2406dee5bd", "displayName": "Adele Vance", "mail": "AdeleV@contoso.com", "userPrincipalName": "AdeleV@contoso.onmicrosoft.com", "userType": "Member"}`
+	// testGuestJSON is a Graph me response of a guest user.
+	testGuestJSON = `{"id": "5a9d3e6b-2c1f-4f8e-9b7a-6d5c4b3a2f1e", "displayName": "Megan Bowen", "mail": "megan@fabrikam.com", "userPrincipalName": "megan_fabrikam.com#EXT#@contoso.onmicrosoft.com", "userType": "Guest"}`
+	// testPersonalJSON is a Graph me response of a personal account, which
+	// has no mail.
+	testPersonalJSON = `{"id": "a1b2c3d4e5f60718", "displayName": "Pat Smith", "mail": null, "userPrincipalName": "pat.smith@outlook.com", "userType": null}`
+	// testUnauthorizedJSON is a Graph error response.
+	testUnauthorizedJSON = `{"error": {"code": "InvalidAuthenticationToken", "message": "Access token validation failure. Invalid audience.", "innerError": {"request-id": "d0a7f5c1-3e2b-4a6d-8c9f-1b2e3d4c5a6b"}}}`
+)
+
+// testMicrosoftKey signs test id_tokens and is served by
+// newMicrosoftTestServer.
+var testMicrosoftKey, _ = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+// testIDToken returns an id_token with the given JSON claims signed by the
+// testMicrosoftKey.
+func testIDToken(claims string) string {
+	signingInput := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"ES256","kid":"microsoft-key"}`)) + "." + base64.RawURLEncoding.EncodeToString([]byte(claims))
+	digest := sha256.Sum256([]byte(signingInput))
+	r, s, _ := ecdsa.Sign(rand.Reader, testMicrosoftKey, digest[:])
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// testClaims returns valid id_token JSON claims issued by the tenant.
+func testClaims(tenantID string) string {
+	return testIssuerClaims(tenantID, tenantID)
+}
+
+// testIssuerClaims returns id_token JSON claims whose issuer is of the
+// issuerTenantID and whose tid is the tenantID.
+func testIssuerClaims(issuerTenantID, tenantID string) string {
+	return fmt.Sprintf(`{"iss":"https://login.microsoftonline.com/%s/v2.0","aud":"client_id","sub":"AAAAAAAAAAAAAAAAAAAAAIkzqFVrSaSaFHy782bbtaQ","exp":%d,"iat":%d,"oid":"87d349ed-44d7-43e1-9a83-5f2406dee5bd","tid":%q,"preferred_username":"AdeleV@contoso.onmicrosoft.com","ver":"2.0"}`,
+		issuerTenantID, time.Now().Add(5*time.Minute).Unix(), time.Now().Unix(), tenantID)
+}
+
+// testJWKS returns the JSON Web Key Set of the testMicrosoftKey.
+func testJWKS() string {
+	x := base64.RawURLEncoding.EncodeToString(testMicrosoftKey.X.FillBytes(make([]byte, 32)))
+	y := base64.RawURLEncoding.EncodeToString(testMicrosoftKey.Y.FillBytes(make([]byte, 32)))
+	return fmt.Sprintf(`{"keys": [{"kty": "EC", "kid": "microsoft-key", "use": "sig", "crv": "P-256", "x": %q, "y": %q}]}`, x, y)
+}
+
+// newMicrosoftTestServer returns a new httptest.Server which mocks the
+// Microsoft token and keys endpoints of each tenant and the Graph me endpoint
+// and a client which proxies requests to the server. The token endpoints
+// respond with the idToken and the me endpoint with the given json data. The
+// caller must close the server.
+func newMicrosoftTestServer(idToken, jsonData string) (*http.Client, *httptest.Server) {
+	client, mux, server := testutils.TestServer()
+	for _, tenant := range []string{TenantCommon, TenantOrganizations, TenantConsumers, testTenantID} {
+		mux.HandleFunc("/"+tenant+"/oauth2/v2.0/token", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"access_token": "any-token", "token_type": "Bearer", "expires_in": 3599, "scope": "openid profile email User.Read", "id_token": %q}`, idToken)
+		})
+		mux.HandleFunc("/"+tenant+"/discovery/v2.0/keys", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, testJWKS())
+		})
+	}
+	mux.HandleFunc("/v1.0/me", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; odata.metadata=minimal")
+		if r.Header.Get("Authorization") != "Bearer any-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprintf(w, testUnauthorizedJSON)
+			return
+		}
+		fmt.Fprintf(w, jsonData)
+	})
+	return client, server
+}
@@ -0,0 +1,74 @@
+package microsoft
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/dghubble/gologin/internal/jsonclient"
+)
+
+const graphAPI = "https://graph.microsoft.com/v1.0/"
+
+// UserTypeGuest is the userType of guest (B2B) users invited to a tenant.
+const UserTypeGuest = "Guest"
+
+// User is a Microsoft Graph user.
+type User struct {
+	ID          string `json:"id"`
+	DisplayName string `json:"displayName"`
+	// Email is the mail address or, if the user has none (e.g. personal
+	// accounts), the userPrincipalName
+	Email             string `json:"mail"`
+	UserPrincipalName string `json:"userPrincipalName"`
+	// UserType is "Member" or UserTypeGuest (empty for personal accounts)
+	UserType string `json:"userType"`
+	// TenantID is the id_token tid claim of the user's tenant
+	TenantID string `json:"-"`
+}
+
+// IsGuest returns true if the User is a guest of the tenant.
+func (u *User) IsGuest() bool {
+	return u.UserType == UserTypeGuest
+}
+
+// APIError is a Microsoft Graph error response.
+type APIError struct {
+	Err struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("microsoft: %s (%s)", e.Err.Message, e.Err.Code)
+}
+
+// client is a Microsoft Graph client for obtaining a User.
+type client struct {
+	json *jsonclient.Client
+}
+
+// newClient returns a new Microsoft Graph client.
+func newClient(httpClient *http.Client) *client {
+	return &client{
+		json: jsonclient.New(httpClient, graphAPI),
+	}
+}
+
+// Me gets the signed in User (requires the User.Read scope).
+// https://learn.microsoft.com/en-us/graph/api/user-get
+func (c *client) Me(ctx context.Context) (*User, *http.Response, error) {
+	user := new(User)
+	apiErr := new(APIError)
+	params := url.Values{"$select": {"id,displayName,mail,userPrincipalName,userType"}}
+	resp, err := c.json.Get(ctx, "me", params, user, apiErr)
+	if err == nil && apiErr.Err.Code != "" {
+		err = apiErr
+	}
+	if err == nil && user.Email == "" {
+		user.Email = user.UserPrincipalName
+	}
+	return user, resp, err
+}
@@ -0,0 +1,46 @@
+package naver
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dghubble/gologin"
+)
+
+// unexported key type prevents collisions
+type key int
+
+const (
+	userKey key = iota
+)
+
+// WithUser returns a copy of ctx that stores the Naver User and its gologin
+// Profile.
+func WithUser(ctx context.Context, user *User) context.Context {
+	ctx = gologin.WithProfile(ctx, newProfile(user))
+	return context.WithValue(ctx, userKey, user)
+}
+
+// UserFromContext returns the Naver User from the ctx.
+func UserFromContext(ctx context.Context) (*User, error) {
+	user, ok := ctx.Value(userKey).(*User)
+	if !ok {
+		return nil, fmt.Errorf("naver: Context missing Naver User")
+	}
+	return user, nil
+}
+
+// newProfile returns the gologin Profile of the Naver User.
+func newProfile(user *User) *gologin.Profile {
+	if user == nil {
+		return nil
+	}
+	return &gologin.Profile{
+		Provider:  ProviderName,
+		ID:        user.ID,
+		Email:     user.Email,
+		Name:      gologin.DisplayName(user.Name, user.Nickname),
+		AvatarURL: user.ProfileImage,
+		Raw:       gologin.ProfileRaw(user, "id", "email", "name", "profile_image"),
+	}
+}
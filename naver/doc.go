@@ -0,0 +1,2 @@
+// Package naver provides Naver OAuth2 login, callback, and revoke handlers.
+package naver
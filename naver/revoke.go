@@ -0,0 +1,80 @@
+package naver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/internal"
+	"github.com/dghubble/gologin/internal/jsonclient"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"golang.org/x/oauth2"
+)
+
+// Naver revoke errors
+var (
+	ErrUnableToRevokeNaverToken = errors.New("naver: unable to revoke Naver Token")
+)
+
+// revokeResponse is a Naver grant_type=delete token response.
+type revokeResponse struct {
+	AccessToken      string `json:"access_token"`
+	Result           string `json:"result"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// RevokeHandler revokes the Naver Token from the ctx (and the user's
+// authorization of the app) with a grant_type=delete request to the config
+// Endpoint TokenURL, then calls the success handler. Otherwise, the failure
+// handler is called with ErrUnableToRevokeNaverToken.
+// https://developers.naver.com/docs/login/devguide/devguide.md
+func RevokeHandler(config *oauth2.Config, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		if err := revokeToken(ctx, internal.ContextClient(ctx), config, token); err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		success.ServeHTTP(w, req)
+	}
+	return http.HandlerFunc(fn)
+}
+
+// revokeToken deletes the Token's access token. Returns a *gologin.Error of
+// ErrUnableToRevokeNaverToken if the revocation fails.
+func revokeToken(ctx context.Context, httpClient *http.Client, config *oauth2.Config, token *oauth2.Token) error {
+	form := url.Values{
+		"grant_type":       {"delete"},
+		"client_id":        {config.ClientID},
+		"client_secret":    {config.ClientSecret},
+		"access_token":     {token.AccessToken},
+		"service_provider": {"NAVER"},
+	}
+	revokeResp := new(revokeResponse)
+	resp, err := jsonclient.New(httpClient, "").PostForm(ctx, config.Endpoint.TokenURL, form, revokeResp, revokeResp)
+	if err == nil && revokeResp.Error != "" {
+		err = fmt.Errorf("naver: %s: %s", revokeResp.Error, revokeResp.ErrorDescription)
+	}
+	var status int
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	if err != nil || status != http.StatusOK || revokeResp.Result != "success" {
+		return &gologin.Error{Provider: "naver", Op: "revoke token", StatusCode: status, Err: err, Kind: ErrUnableToRevokeNaverToken}
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package naver
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/dghubble/gologin/testutils"
+)
+
+const (
+	// testUserJSON is a successful nid/me response.
+	testUserJSON = `{"resultcode": "00", "message": "success", "response": {"id": "32742776", "nickname": "OpenAPI", "name": "Kim Naver", "email": "openapi@naver.com", "gender": "F", "age": "40-49", "birthday": "10-01", "profile_image": "https://ssl.pstatic.net/static/pwe/address/nodata_33x33.gif", "birthyear": "1900", "mobile": "010-0000-0000"}}`
+	// testResultErrorJSON is a nid/me response with a failure resultcode,
+	// which Naver may send with 200 OK.
+	testResultErrorJSON = `{"resultcode": "024", "message": "Authentication failed"}`
+)
+
+// newNaverTestServer returns a new httptest.Server which mocks the Naver
+// token (issue and delete) and nid/me endpoints and a client which proxies
+// requests to the server. The nid/me endpoint responds with the given status
+// and json data. The caller must close the server.
+func newNaverTestServer(status int, jsonData string) (*http.Client, *httptest.Server) {
+	client, mux, server := testutils.TestServer()
+	mux.HandleFunc("/oauth2.0/token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.FormValue("grant_type") {
+		case "authorization_code":
+			fmt.Fprintf(w, `{"access_token": "any-token", "refresh_token": "any-refresh", "token_type": "bearer", "expires_in": "3600"}`)
+		case "delete":
+			if r.FormValue("access_token") != "any-token" || r.FormValue("client_secret") != "client_secret" || r.FormValue("service_provider") != "NAVER" {
+				fmt.Fprintf(w, `{"error": "invalid_request", "error_description": "no valid data in session"}`)
+				return
+			}
+			fmt.Fprintf(w, `{"access_token": "any-token", "result": "success"}`)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, `{"error": "unsupported_grant_type"}`)
+		}
+	})
+	mux.HandleFunc("/v1/nid/me", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		fmt.Fprintf(w, jsonData)
+	})
+	return client, server
+}
@@ -0,0 +1,69 @@
+package naver
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/dghubble/gologin/internal/jsonclient"
+)
+
+const (
+	naverAPI = "https://openapi.naver.com/"
+	// resultCodeSuccess is the resultcode of successful Naver API responses
+	resultCodeSuccess = "00"
+)
+
+// User is a Naver user.
+type User struct {
+	ID           string `json:"id"`
+	Email        string `json:"email"`
+	Name         string `json:"name"`
+	Nickname     string `json:"nickname"`
+	ProfileImage string `json:"profile_image"`
+	// Gender is "F", "M", or "U" (unknown)
+	Gender    string `json:"gender"`
+	BirthYear string `json:"birthyear"`
+}
+
+// userResponse is a Naver nid/me response, which wraps the User.
+type userResponse struct {
+	ResultCode string `json:"resultcode"`
+	Message    string `json:"message"`
+	Response   *User  `json:"response"`
+}
+
+// ResultError is a Naver API response whose resultcode is not "00". Naver may
+// return it with 200 OK.
+type ResultError struct {
+	ResultCode string
+	Message    string
+}
+
+func (e *ResultError) Error() string {
+	return fmt.Sprintf("naver: %s (resultcode %s)", e.Message, e.ResultCode)
+}
+
+// client is a Naver client for obtaining the current User.
+type client struct {
+	json *jsonclient.Client
+}
+
+// newClient returns a new Naver client.
+func newClient(httpClient *http.Client) *client {
+	return &client{
+		json: jsonclient.New(httpClient, naverAPI),
+	}
+}
+
+// Me returns the current Naver User. If the resultcode is not "00" (with any
+// status), the error is a *ResultError.
+// https://developers.naver.com/docs/login/profile/profile.md
+func (c *client) Me(ctx context.Context) (*User, *http.Response, error) {
+	userResp := new(userResponse)
+	resp, err := c.json.Get(ctx, "v1/nid/me", nil, userResp, userResp)
+	if err == nil && userResp.ResultCode != resultCodeSuccess {
+		err = &ResultError{ResultCode: userResp.ResultCode, Message: userResp.Message}
+	}
+	return userResp.Response, resp, err
+}
@@ -0,0 +1,61 @@
+package notion
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dghubble/gologin"
+)
+
+// unexported key type prevents collisions
+type key int
+
+const (
+	userKey key = iota
+	workspaceKey
+)
+
+// WithUser returns a copy of ctx that stores the Notion User and its gologin
+// Profile.
+func WithUser(ctx context.Context, user *User) context.Context {
+	ctx = gologin.WithProfile(ctx, newProfile(user))
+	return context.WithValue(ctx, userKey, user)
+}
+
+// UserFromContext returns the Notion User from the ctx.
+func UserFromContext(ctx context.Context) (*User, error) {
+	user, ok := ctx.Value(userKey).(*User)
+	if !ok {
+		return nil, fmt.Errorf("notion: Context missing Notion User")
+	}
+	return user, nil
+}
+
+// newProfile returns the gologin Profile of the Notion User.
+func newProfile(user *User) *gologin.Profile {
+	if user == nil {
+		return nil
+	}
+	return &gologin.Profile{
+		Provider:  ProviderName,
+		ID:        user.ID,
+		Email:     user.Email,
+		Name:      user.Name,
+		AvatarURL: user.AvatarURL,
+		Raw:       gologin.ProfileRaw(user, "ID", "Email", "Name", "AvatarURL"),
+	}
+}
+
+// WithWorkspace returns a copy of ctx that stores the Notion Workspace.
+func WithWorkspace(ctx context.Context, workspace *Workspace) context.Context {
+	return context.WithValue(ctx, workspaceKey, workspace)
+}
+
+// WorkspaceFromContext returns the Notion Workspace from the ctx.
+func WorkspaceFromContext(ctx context.Context) (*Workspace, error) {
+	workspace, ok := ctx.Value(workspaceKey).(*Workspace)
+	if !ok {
+		return nil, fmt.Errorf("notion: Context missing Notion Workspace")
+	}
+	return workspace, nil
+}
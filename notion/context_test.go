@@ -0,0 +1,40 @@
+package notion
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContextUser(t *testing.T) {
+	expectedUser := &User{ID: "e79a0b74-3aba-4149-9f74-0bb5791a6ee6", Name: "Avo Cado"}
+	ctx := WithUser(context.Background(), expectedUser)
+	user, err := UserFromContext(ctx)
+	assert.Equal(t, expectedUser, user)
+	assert.Nil(t, err)
+}
+
+func TestContextUser_Error(t *testing.T) {
+	user, err := UserFromContext(context.Background())
+	assert.Nil(t, user)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "notion: Context missing Notion User", err.Error())
+	}
+}
+
+func TestContextWorkspace(t *testing.T) {
+	expectedWorkspace := &Workspace{ID: "0ec55958-6b1b-4ad5-b8a6-3b2e62a0b4d1", Name: "Acme"}
+	ctx := WithWorkspace(context.Background(), expectedWorkspace)
+	workspace, err := WorkspaceFromContext(ctx)
+	assert.Equal(t, expectedWorkspace, workspace)
+	assert.Nil(t, err)
+}
+
+func TestContextWorkspace_Error(t *testing.T) {
+	workspace, err := WorkspaceFromContext(context.Background())
+	assert.Nil(t, workspace)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "notion: Context missing Notion Workspace", err.Error())
+	}
+}
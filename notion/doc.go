@@ -0,0 +1,2 @@
+// Package notion provides Notion OAuth2 login and callback handlers.
+package notion
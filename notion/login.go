@@ -0,0 +1,157 @@
+package notion
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/internal"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"golang.org/x/oauth2"
+)
+
+// ProviderName is the provider name set in the ctx (see
+// gologin.ProviderFromContext) and the Profile.
+const ProviderName = "notion"
+
+// Notion login errors
+var (
+	ErrUnableToGetNotionUser = errors.New("notion: unable to get Notion User")
+	ErrOwnerNotUser          = errors.New("notion: Notion token owner is not a user")
+)
+
+// Endpoint is Notion's OAuth2 endpoint. Notion requires HTTP Basic client
+// authentication at the token endpoint.
+var Endpoint = oauth2.Endpoint{
+	AuthURL:   "https://api.notion.com/v1/oauth/authorize",
+	TokenURL:  "https://api.notion.com/v1/oauth/token",
+	AuthStyle: oauth2.AuthStyleInHeader,
+}
+
+// ownerUser is the AuthCodeOption Notion requires to authorize public
+// integrations for a user.
+var ownerUser = oauth2.SetAuthURLParam("owner", "user")
+
+// StateHandler checks for a state cookie. If found, the state value is read
+// and added to the ctx. Otherwise, a non-guessable value is added to the ctx
+// and to a (short-lived) state cookie issued to the requester.
+//
+// Implements OAuth 2 RFC 6749 10.12 CSRF Protection. If you wish to issue
+// state params differently, write a http.Handler which sets the ctx state,
+// using oauth2 WithState(ctx, state) since it is required by LoginHandler
+// and CallbackHandler.
+func StateHandler(config gologin.CookieConfig, success http.Handler) http.Handler {
+	return oauth2Login.StateHandler(config, success)
+}
+
+// LoginHandler handles Notion login requests by reading the state value from
+// the ctx and redirecting requests to the AuthURL with that state value and
+// owner=user. Any AuthCodeOptions are added to the AuthURL.
+func LoginHandler(config *oauth2.Config, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	opts = append([]oauth2.AuthCodeOption{ownerUser}, opts...)
+	return oauth2Login.LoginHandler(config, failure, opts...)
+}
+
+// CallbackHandler handles Notion redirection URI requests and adds the Notion
+// access token, User, and Workspace to the ctx. If authentication succeeds,
+// handling delegates to the success handler, otherwise to the failure
+// handler. Tokens which are not owned by a user (e.g. internal integrations
+// owned by the workspace) fail with ErrOwnerNotUser.
+// Any AuthCodeOptions are sent with the token exchange.
+func CallbackHandler(config *oauth2.Config, success, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	success = notionHandler(config, success, failure)
+	return oauth2Login.CallbackHandler(config, success, failure, opts...)
+}
+
+// notionHandler is a http.Handler that gets the OAuth2 Token from the ctx to
+// get the Notion User and Workspace. If successful, they are added to the ctx
+// and the success handler is called. Otherwise, the failure handler is
+// called.
+//
+// Notion token responses include the owner and workspace, so the User is read
+// from the Token owner. If the Token has no owner, the User is read from the
+// owner of the token's bot user (users/me).
+func notionHandler(config *oauth2.Config, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		workspace := workspaceFromToken(token)
+		owner, err := ownerFromToken(token)
+		if err != nil {
+			err = &gologin.Error{Provider: "notion", Op: "get user", Err: err, Kind: ErrUnableToGetNotionUser}
+		} else if owner == nil {
+			httpClient := internal.OAuth2Client(ctx, config, token)
+			owner, err = botOwner(ctx, newClient(httpClient), workspace)
+		}
+		if err == nil {
+			err = validateOwner(owner)
+		}
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		ctx = WithUser(ctx, owner.user())
+		ctx = WithWorkspace(ctx, workspace)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// botOwner gets the token's bot user and returns its owner. The workspace
+// name and bot ID are filled in from the bot user if missing.
+func botOwner(ctx context.Context, c *client, workspace *Workspace) (*owner, error) {
+	bot, resp, err := c.Me(ctx)
+	err = validateResponse(bot, resp, err)
+	if err != nil {
+		return nil, err
+	}
+	if workspace.Name == "" {
+		workspace.Name = bot.Bot.WorkspaceName
+	}
+	if workspace.BotID == "" {
+		workspace.BotID = bot.ID
+	}
+	return bot.Bot.Owner, nil
+}
+
+// validateResponse returns an error if the given Notion bot user, raw
+// http.Response, or error are unexpected. Returns nil if they are valid, or a
+// *gologin.Error which preserves the cause and status code.
+func validateResponse(bot *botUser, resp *http.Response, err error) error {
+	var status int
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	if err != nil || status != http.StatusOK {
+		return &gologin.Error{Provider: "notion", Op: "get user", StatusCode: status, Err: err, Kind: ErrUnableToGetNotionUser}
+	}
+	if bot == nil || bot.ID == "" {
+		return &gologin.Error{Provider: "notion", Op: "get user", StatusCode: status, Kind: ErrUnableToGetNotionUser}
+	}
+	return nil
+}
+
+// validateOwner returns ErrOwnerNotUser if the owner is not a user, or a
+// *gologin.Error if the owner user is missing.
+func validateOwner(owner *owner) error {
+	if owner == nil {
+		return &gologin.Error{Provider: "notion", Op: "get user", Kind: ErrUnableToGetNotionUser}
+	}
+	if owner.Type != ownerTypeUser {
+		return ErrOwnerNotUser
+	}
+	if owner.User == nil || owner.User.ID == "" {
+		return &gologin.Error{Provider: "notion", Op: "get user", Kind: ErrUnableToGetNotionUser}
+	}
+	return nil
+}
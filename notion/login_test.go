@@ -0,0 +1,227 @@
+package notion
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/dghubble/gologin"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+var (
+	testUser      = &User{ID: "e79a0b74-3aba-4149-9f74-0bb5791a6ee6", Name: "Avo Cado", AvatarURL: "https://example.com/avatar.png", Email: "avo@example.org"}
+	testWorkspace = &Workspace{ID: "0ec55958-6b1b-4ad5-b8a6-3b2e62a0b4d1", Name: "Acme", Icon: "https://example.com/icon.png", BotID: "b3414d659-1224-5ty7-6ffr-cc9d8773drt6"}
+)
+
+func testConfig() *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     "client_id",
+		ClientSecret: "client_secret",
+		RedirectURL:  "https://example.com/notion/callback",
+		Endpoint:     Endpoint,
+	}
+}
+
+func TestLoginHandler(t *testing.T) {
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// LoginHandler assert that:
+	// - redirects to the Notion AuthURL with the state and owner=user
+	loginHandler := LoginHandler(testConfig(), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	ctx := oauth2Login.WithState(context.Background(), "d4e5f6")
+	loginHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, http.StatusFound, w.Code)
+	location, err := url.Parse(w.HeaderMap.Get("Location"))
+	if assert.Nil(t, err) {
+		assert.Equal(t, "api.notion.com", location.Host)
+		assert.Equal(t, "/v1/oauth/authorize", location.Path)
+		assert.Equal(t, "d4e5f6", location.Query().Get("state"))
+		assert.Equal(t, "user", location.Query().Get("owner"))
+	}
+}
+
+func TestCallbackHandler(t *testing.T) {
+	// users/me must not be needed when the token has the owner
+	proxyClient, server := newNotionTestServer(testTokenJSON, `{}`)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithState(ctx, "d4e5f6")
+
+	success := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "any-token", token.AccessToken)
+		}
+		user, err := UserFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, testUser, user)
+		}
+		workspace, err := WorkspaceFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, testWorkspace, workspace)
+		}
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// CallbackHandler assert that:
+	// - the User and Workspace are read from the token response
+	// - success handler is called with the Token, User, and Workspace in the
+	// ctx
+	callbackHandler := CallbackHandler(testConfig(), http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestCallbackHandler_UsersMeFallback(t *testing.T) {
+	proxyClient, server := newNotionTestServer(testNoOwnerTokenJSON, testBotUserJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithState(ctx, "d4e5f6")
+
+	success := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		user, err := UserFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, testUser, user)
+		}
+		workspace, err := WorkspaceFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, &Workspace{ID: "0ec55958-6b1b-4ad5-b8a6-3b2e62a0b4d1", Name: "Acme", BotID: "b3414d659-1224-5ty7-6ffr-cc9d8773drt6"}, workspace)
+		}
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// CallbackHandler with a token response without the owner, assert that:
+	// - the User is read from the users/me bot owner (with Notion-Version)
+	// - missing Workspace fields are filled in from the bot user
+	callbackHandler := CallbackHandler(testConfig(), http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestCallbackHandler_OwnerNotUser(t *testing.T) {
+	proxyClient, server := newNotionTestServer(testWorkspaceOwnerTokenJSON, testBotUserJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithState(ctx, "d4e5f6")
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, ErrOwnerNotUser, gologin.ErrorFromContext(req.Context()))
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// CallbackHandler with a token owned by the workspace, assert that:
+	// - failure handler is called with ErrOwnerNotUser
+	callbackHandler := CallbackHandler(testConfig(), success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestNotionHandler_BotOwnerNotUser(t *testing.T) {
+	proxyClient, server := newNotionTestServer(testNoOwnerTokenJSON, `{"object": "user", "id": "b3414d659-1224-5ty7-6ffr-cc9d8773drt6", "type": "bot", "bot": {"owner": {"type": "workspace", "workspace": true}, "workspace_name": "Acme"}}`)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, ErrOwnerNotUser, gologin.ErrorFromContext(req.Context()))
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// NotionHandler for a bot user owned by the workspace, assert that:
+	// - failure handler is called with ErrOwnerNotUser
+	notionHandler := notionHandler(testConfig(), success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	notionHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestNotionHandler_MissingCtxToken(t *testing.T) {
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "oauth2: Context missing Token", err.Error())
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// NotionHandler called without Token in ctx, assert that:
+	// - failure handler is called
+	// - error about ctx missing token is added to the failure handler ctx
+	notionHandler := notionHandler(testConfig(), success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	notionHandler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestNotionHandler_ErrorGettingUser(t *testing.T) {
+	proxyClient, server := testutils.NewErrorServer("Notion Service Down", http.StatusInternalServerError)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.True(t, errors.Is(err, ErrUnableToGetNotionUser))
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// NotionHandler with a Token without the owner cannot get the users/me
+	// bot user, assert that:
+	// - failure handler is called
+	// - error cannot get Notion User added to the failure handler ctx
+	notionHandler := notionHandler(testConfig(), success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	notionHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestValidateResponse(t *testing.T) {
+	validBot := &botUser{ID: "b3414d659-1224-5ty7-6ffr-cc9d8773drt6"}
+	validResponse := &http.Response{StatusCode: 200}
+	invalidResponse := &http.Response{StatusCode: 500}
+	assert.Equal(t, nil, validateResponse(validBot, validResponse, nil))
+	assert.True(t, errors.Is(validateResponse(validBot, validResponse, fmt.Errorf("Server error")), ErrUnableToGetNotionUser))
+	assert.True(t, errors.Is(validateResponse(validBot, invalidResponse, nil), ErrUnableToGetNotionUser))
+	assert.True(t, errors.Is(validateResponse(&botUser{}, validResponse, nil), ErrUnableToGetNotionUser))
+	assert.True(t, errors.Is(validateResponse(nil, validResponse, nil), ErrUnableToGetNotionUser))
+}
+
+func TestValidateOwner(t *testing.T) {
+	assert.True(t, errors.Is(validateOwner(nil), ErrUnableToGetNotionUser))
+	assert.Equal(t, ErrOwnerNotUser, validateOwner(&owner{Type: "workspace"}))
+	assert.True(t, errors.Is(validateOwner(&owner{Type: "user"}), ErrUnableToGetNotionUser))
+}
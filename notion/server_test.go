@@ -0,0 +1,57 @@
+package notion
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/dghubble/gologin/testutils"
+)
+
+const (
+	// testTokenJSON is a token response of a public integration authorized by
+	// a user.
+	testTokenJSON = `{"access_token": "any-token", "token_type": "bearer", "bot_id": "b3414d659-1224-5ty7-6ffr-cc9d8773drt6", "workspace_name": "Acme", "workspace_icon": "https://example.com/icon.png", "workspace_id": "0ec55958-6b1b-4ad5-b8a6-3b2e62a0b4d1", "owner": {"type": "user", "user": {"object": "user", "id": "e79a0b74-3aba-4149-9f74-0bb5791a6ee6", "name": "Avo Cado", "avatar_url": "https://example.com/avatar.png", "type": "person", "person": {"email": "avo@example.org"}}}, "duplicated_template_id": null}`
+	// testWorkspaceOwnerTokenJSON is a token response whose owner is the
+	// workspace rather than a user.
+	testWorkspaceOwnerTokenJSON = `{"access_token": "any-token", "token_type": "bearer", "bot_id": "b3414d659-1224-5ty7-6ffr-cc9d8773drt6", "workspace_name": "Acme", "workspace_id": "0ec55958-6b1b-4ad5-b8a6-3b2e62a0b4d1", "owner": {"type": "workspace", "workspace": true}}`
+	// testNoOwnerTokenJSON is a token response without the owner.
+	testNoOwnerTokenJSON = `{"access_token": "any-token", "token_type": "bearer", "workspace_id": "0ec55958-6b1b-4ad5-b8a6-3b2e62a0b4d1"}`
+	// testBotUserJSON is a users/me response of a bot owned by a user.
+	testBotUserJSON = `{"object": "user", "id": "b3414d659-1224-5ty7-6ffr-cc9d8773drt6", "name": "Acme Integration", "avatar_url": null, "type": "bot", "bot": {"owner": {"type": "user", "user": {"object": "user", "id": "e79a0b74-3aba-4149-9f74-0bb5791a6ee6", "name": "Avo Cado", "avatar_url": "https://example.com/avatar.png", "type": "person", "person": {"email": "avo@example.org"}}}, "workspace_name": "Acme"}}`
+)
+
+// newNotionTestServer returns a new httptest.Server which mocks the Notion
+// token and users/me endpoints and a client which proxies requests to the
+// server. Like Notion, the token endpoint requires HTTP Basic client
+// authentication and users/me requires the Notion-Version header. The
+// endpoints respond with the given json data. The caller must close the
+// server.
+func newNotionTestServer(tokenJSON, botUserJSON string) (*http.Client, *httptest.Server) {
+	client, mux, server := testutils.TestServer()
+	mux.HandleFunc("/v1/oauth/token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		username, password, ok := r.BasicAuth()
+		if !ok || username != "client_id" || password != "client_secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprintf(w, `{"error": "invalid_client"}`)
+			return
+		}
+		fmt.Fprintf(w, tokenJSON)
+	})
+	mux.HandleFunc("/v1/users/me", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Header.Get("Notion-Version") == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, `{"object": "error", "status": 400, "code": "missing_version", "message": "Notion-Version header failed validation: Notion-Version header should be defined, instead was undefined."}`)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer any-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprintf(w, `{"object": "error", "status": 401, "code": "unauthorized", "message": "API token is invalid."}`)
+			return
+		}
+		fmt.Fprintf(w, botUserJSON)
+	})
+	return client, server
+}
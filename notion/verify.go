@@ -0,0 +1,120 @@
+package notion
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/dghubble/gologin/internal/jsonclient"
+	"golang.org/x/oauth2"
+)
+
+const (
+	notionAPI = "https://api.notion.com/v1/"
+	// notionVersion is the Notion-Version header Notion requires with each
+	// API request.
+	notionVersion = "2022-06-28"
+	// ownerTypeUser is the owner type of user-owned (public) integrations.
+	ownerTypeUser = "user"
+)
+
+// User is the Notion user who authorized the integration.
+type User struct {
+	ID        string
+	Name      string
+	AvatarURL string
+	Email     string
+}
+
+// Workspace is the Notion workspace the integration was added to.
+type Workspace struct {
+	ID    string
+	Name  string
+	Icon  string
+	BotID string
+}
+
+// owner is the owner of a Notion token (or bot user). Public integrations
+// are owned by the user who authorized them.
+type owner struct {
+	Type string `json:"type"`
+	User *struct {
+		ID        string `json:"id"`
+		Name      string `json:"name"`
+		AvatarURL string `json:"avatar_url"`
+		Person    struct {
+			Email string `json:"email"`
+		} `json:"person"`
+	} `json:"user"`
+}
+
+// user returns the owner User.
+func (o *owner) user() *User {
+	return &User{
+		ID:        o.User.ID,
+		Name:      o.User.Name,
+		AvatarURL: o.User.AvatarURL,
+		Email:     o.User.Person.Email,
+	}
+}
+
+// botUser is the Notion bot user of a token, from users/me.
+type botUser struct {
+	ID  string `json:"id"`
+	Bot struct {
+		Owner         *owner `json:"owner"`
+		WorkspaceName string `json:"workspace_name"`
+	} `json:"bot"`
+}
+
+// ownerFromToken returns the owner from the Token response, or nil if the
+// Token has no owner.
+func ownerFromToken(token *oauth2.Token) (*owner, error) {
+	extra := token.Extra("owner")
+	if extra == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(extra)
+	if err != nil {
+		return nil, err
+	}
+	o := new(owner)
+	if err := json.Unmarshal(data, o); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// workspaceFromToken returns the Workspace from the Token response.
+func workspaceFromToken(token *oauth2.Token) *Workspace {
+	extra := func(key string) string {
+		value, _ := token.Extra(key).(string)
+		return value
+	}
+	return &Workspace{
+		ID:    extra("workspace_id"),
+		Name:  extra("workspace_name"),
+		Icon:  extra("workspace_icon"),
+		BotID: extra("bot_id"),
+	}
+}
+
+// client is a Notion client for obtaining the bot user.
+type client struct {
+	json *jsonclient.Client
+}
+
+// newClient returns a new Notion client.
+func newClient(httpClient *http.Client) *client {
+	return &client{
+		json: jsonclient.New(httpClient, notionAPI).Set("Notion-Version", notionVersion),
+	}
+}
+
+// Me returns the bot user of the token, whose owner is the authorizing user.
+// https://developers.notion.com/reference/get-self
+func (c *client) Me(ctx context.Context) (*botUser, *http.Response, error) {
+	bot := new(botUser)
+	resp, err := c.json.Get(ctx, "users/me", nil, bot, nil)
+	return bot, resp, err
+}
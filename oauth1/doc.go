@@ -1,2 +1,8 @@
 // Package oauth1 provides handles for OAuth1 login and callback requests.
+//
+// Handlers compose to implement any OAuth 1.0a provider. On login, chain
+// LoginHandler -> CookieTempHandler -> AuthRedirectHandler to obtain a request
+// token, keep its secret, and redirect to the authorization URL. On callback,
+// chain CookieTempHandler -> CallbackHandler -> success to restore the secret
+// and obtain the access token and secret (see AccessTokenFromContext).
 package oauth1
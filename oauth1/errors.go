@@ -0,0 +1,23 @@
+package oauth1
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Errors which may occur on callback.
+var (
+	ErrMissingTokenOrVerifier = errors.New("oauth1: Request missing oauth_token or oauth_verifier")
+	ErrAccessDenied           = errors.New("oauth1: resource owner denied the authorization request")
+	ErrMissingRequestSecret   = errors.New("oauth1: request token secret not found")
+	ErrRequestSecretMismatch  = errors.New("oauth1: stored request token secret does not match the oauth_token")
+)
+
+// callbackStatusCode returns the failure status code of a callback parse
+// error. Users who deny authorization are an expected outcome, not an error.
+func callbackStatusCode(err error) int {
+	if err == ErrAccessDenied {
+		return http.StatusOK
+	}
+	return http.StatusBadRequest
+}
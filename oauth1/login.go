@@ -23,6 +23,7 @@ func LoginHandler(config *oauth1.Config, success, failure http.Handler) http.Han
 		requestToken, requestSecret, err := config.RequestToken()
 		if err != nil {
 			ctx = gologin.WithError(ctx, err)
+			ctx = gologin.WithStatusCode(ctx, http.StatusBadGateway)
 			failure.ServeHTTP(w, req.WithContext(ctx))
 			return
 		}
@@ -33,7 +34,7 @@ func LoginHandler(config *oauth1.Config, success, failure http.Handler) http.Han
 }
 
 // AuthRedirectHandler reads the request token from the ctx and redirects
-// to the authorization URL.
+// to the authorization URL. The redirect is reported to any ctx gologin Hooks.
 func AuthRedirectHandler(config *oauth1.Config, failure http.Handler) http.Handler {
 	if failure == nil {
 		failure = gologin.DefaultFailureHandler
@@ -43,15 +44,18 @@ func AuthRedirectHandler(config *oauth1.Config, failure http.Handler) http.Handl
 		requestToken, _, err := RequestTokenFromContext(ctx)
 		if err != nil {
 			ctx = gologin.WithError(ctx, err)
+			ctx = gologin.WithStatusCode(ctx, http.StatusInternalServerError)
 			failure.ServeHTTP(w, req.WithContext(ctx))
 			return
 		}
 		authorizationURL, err := config.AuthorizationURL(requestToken)
 		if err != nil {
 			ctx = gologin.WithError(ctx, err)
+			ctx = gologin.WithStatusCode(ctx, http.StatusInternalServerError)
 			failure.ServeHTTP(w, req.WithContext(ctx))
 			return
 		}
+		gologin.ReportLoginRedirect(ctx, "oauth1", req)
 		http.Redirect(w, req, authorizationURL.String(), http.StatusFound)
 	}
 	return http.HandlerFunc(fn)
@@ -85,6 +89,7 @@ func CookieTempHandler(config gologin.CookieConfig, success, failure http.Handle
 		cookie, err := req.Cookie(config.Name)
 		if err != nil {
 			ctx = gologin.WithError(ctx, err)
+			ctx = gologin.WithStatusCode(ctx, http.StatusBadRequest)
 			failure.ServeHTTP(w, req.WithContext(ctx))
 			return
 		}
@@ -111,16 +116,21 @@ func EmptyTempHandler(success http.Handler) http.Handler {
 
 // CallbackHandler handles OAuth1 callback requests by parsing the oauth token
 // and verifier, reading the request token secret from the ctx, then obtaining
-// an access token and adding it to the ctx.
+// an access token and adding it to the ctx. If the user denied authorization
+// (a "denied" parameter), the failure handler is called with ErrAccessDenied.
+// If the oauth token or verifier is missing, it is called with
+// ErrMissingTokenOrVerifier. The access token request is reported to any ctx
+// gologin Hooks.
 func CallbackHandler(config *oauth1.Config, success, failure http.Handler) http.Handler {
 	if failure == nil {
 		failure = gologin.DefaultFailureHandler
 	}
 	fn := func(w http.ResponseWriter, req *http.Request) {
 		ctx := req.Context()
-		requestToken, verifier, err := oauth1.ParseAuthorizationCallback(req)
+		requestToken, verifier, err := parseCallback(req)
 		if err != nil {
 			ctx = gologin.WithError(ctx, err)
+			ctx = gologin.WithStatusCode(ctx, callbackStatusCode(err))
 			failure.ServeHTTP(w, req.WithContext(ctx))
 			return
 		}
@@ -129,13 +139,17 @@ func CallbackHandler(config *oauth1.Config, success, failure http.Handler) http.
 		_, requestSecret, err := RequestTokenFromContext(ctx)
 		if err != nil {
 			ctx = gologin.WithError(ctx, err)
+			ctx = gologin.WithStatusCode(ctx, http.StatusBadRequest)
 			failure.ServeHTTP(w, req.WithContext(ctx))
 			return
 		}
 
+		_, endExchange := gologin.StartTokenExchange(ctx, "oauth1")
 		accessToken, accessSecret, err := config.AccessToken(requestToken, requestSecret, verifier)
+		endExchange(err)
 		if err != nil {
 			ctx = gologin.WithError(ctx, err)
+			ctx = gologin.WithStatusCode(ctx, http.StatusBadGateway)
 			failure.ServeHTTP(w, req.WithContext(ctx))
 			return
 		}
@@ -144,3 +158,36 @@ func CallbackHandler(config *oauth1.Config, success, failure http.Handler) http.
 	}
 	return http.HandlerFunc(fn)
 }
+
+// NamedLoginHandler is a LoginHandler for the named provider (e.g.
+// "tumblr"), which is stored in the ctx (see gologin.ProviderFromContext) and
+// reported to gologin Hooks.
+func NamedLoginHandler(provider string, config *oauth1.Config, success, failure http.Handler) http.Handler {
+	return gologin.ProviderHandler(provider, LoginHandler(config, success, failure))
+}
+
+// NamedCallbackHandler is a CallbackHandler for the named provider (e.g.
+// "tumblr"), which is stored in the ctx (see gologin.ProviderFromContext) for
+// the success and failure handlers and reported to gologin Hooks.
+func NamedCallbackHandler(provider string, config *oauth1.Config, success, failure http.Handler) http.Handler {
+	return gologin.ProviderHandler(provider, CallbackHandler(config, success, failure))
+}
+
+// parseCallback parses the "oauth_token" and "oauth_verifier" parameters from
+// the http.Request and returns them. Providers such as Twitter redirect with a
+// "denied" parameter instead if the user denied authorization.
+func parseCallback(req *http.Request) (requestToken, verifier string, err error) {
+	err = req.ParseForm()
+	if err != nil {
+		return "", "", err
+	}
+	if req.Form.Get("denied") != "" {
+		return "", "", ErrAccessDenied
+	}
+	requestToken = req.Form.Get("oauth_token")
+	verifier = req.Form.Get("oauth_verifier")
+	if requestToken == "" || verifier == "" {
+		return "", "", ErrMissingTokenOrVerifier
+	}
+	return requestToken, verifier, nil
+}
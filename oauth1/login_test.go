@@ -194,6 +194,33 @@ func TestCallbackHandler(t *testing.T) {
 	assert.Equal(t, "success handler called", w.Body.String())
 }
 
+func TestNamedCallbackHandler(t *testing.T) {
+	data := url.Values{}
+	data.Add("oauth_token", "access_token")
+	data.Add("oauth_token_secret", "access_secret")
+	server := NewAccessTokenServer(t, data)
+	defer server.Close()
+
+	config := &oauth1.Config{
+		Endpoint: oauth1.Endpoint{
+			AccessTokenURL: server.URL,
+		},
+	}
+	success := func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "tumblr", gologin.ProviderFromContext(req.Context(), ""))
+		fmt.Fprintf(w, "success handler called")
+	}
+
+	// NamedCallbackHandler, assert that:
+	// - the provider name is added to the ctx of the success handler
+	callbackHandler := NamedCallbackHandler("tumblr", config, http.HandlerFunc(success), testutils.AssertFailureNotCalled(t))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?oauth_token=any_token&oauth_verifier=any_verifier", nil)
+	ctx := WithRequestToken(context.Background(), "", "request_secret")
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
 func TestCallbackHandler_ParseAuthorizationCallbackError(t *testing.T) {
 	config := &oauth1.Config{}
 	success := testutils.AssertSuccessNotCalled(t)
@@ -201,6 +228,7 @@ func TestCallbackHandler_ParseAuthorizationCallbackError(t *testing.T) {
 		ctx := req.Context()
 		err := gologin.ErrorFromContext(ctx)
 		if assert.NotNil(t, err) {
+			assert.Equal(t, ErrMissingTokenOrVerifier, err)
 			assert.Equal(t, "oauth1: Request missing oauth_token or oauth_verifier", err.Error())
 		}
 		fmt.Fprintf(w, "failure handler called")
@@ -216,6 +244,25 @@ func TestCallbackHandler_ParseAuthorizationCallbackError(t *testing.T) {
 	assert.Equal(t, "failure handler called", w.Body.String())
 }
 
+func TestCallbackHandler_Denied(t *testing.T) {
+	config := &oauth1.Config{}
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, ErrAccessDenied, gologin.ErrorFromContext(req.Context()))
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// CallbackHandler called after the user denied authorization, assert that:
+	// - failure handler is called
+	// - ErrAccessDenied is added to the ctx
+	callbackHandler := CallbackHandler(config, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?denied=any_token", nil)
+	ctx := WithRequestToken(context.Background(), "", "request_secret")
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
 func TestCallbackHandler_MissingCtxRequestSecret(t *testing.T) {
 	config := &oauth1.Config{}
 	success := testutils.AssertSuccessNotCalled(t)
@@ -269,3 +316,46 @@ func TestCallbackHandler_AccessTokenError(t *testing.T) {
 	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
 	assert.Equal(t, "failure handler called", w.Body.String())
 }
+
+func TestFailureStatusCodes(t *testing.T) {
+	_, errorServer := testutils.NewErrorServer("OAuth1 Server Error", http.StatusInternalServerError)
+	defer errorServer.Close()
+	config := &oauth1.Config{
+		Endpoint: oauth1.Endpoint{
+			RequestTokenURL: errorServer.URL,
+			AccessTokenURL:  errorServer.URL,
+		},
+	}
+	withSecret := func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, req *http.Request) {
+			ctx := WithRequestToken(req.Context(), "", "request_secret")
+			next.ServeHTTP(w, req.WithContext(ctx))
+		}
+		return http.HandlerFunc(fn)
+	}
+	callback := CallbackHandler(config, testutils.AssertSuccessNotCalled(t), nil)
+	cases := []struct {
+		name    string
+		handler http.Handler
+		url     string
+		status  int
+	}{
+		{"request token error", LoginHandler(config, testutils.AssertSuccessNotCalled(t), nil), "/login", http.StatusBadGateway},
+		{"redirect missing request token", AuthRedirectHandler(config, nil), "/login", http.StatusInternalServerError},
+		{"missing temp cookie", CookieTempHandler(gologin.DebugOnlyCookieConfig, callback, nil), "/?oauth_token=any_token&oauth_verifier=any_verifier", http.StatusBadRequest},
+		{"denied", callback, "/?denied=any_token", http.StatusOK},
+		{"missing verifier", callback, "/?oauth_token=any_token", http.StatusBadRequest},
+		{"missing request secret", callback, "/?oauth_token=any_token&oauth_verifier=any_verifier", http.StatusBadRequest},
+		{"access token error", withSecret(callback), "/?oauth_token=any_token&oauth_verifier=any_verifier", http.StatusBadGateway},
+		{"store denied", StoreTempHandler(NewMemorySecretStore(), callback, nil), "/?denied=any_token", http.StatusOK},
+		{"store missing secret", StoreTempHandler(NewMemorySecretStore(), callback, nil), "/?oauth_token=any_token&oauth_verifier=any_verifier", http.StatusBadRequest},
+	}
+	for _, c := range cases {
+		req, _ := http.NewRequest("GET", c.url, nil)
+		w := httptest.NewRecorder()
+		c.handler.ServeHTTP(w, req)
+		// failure points assert that:
+		// - the DefaultFailureHandler responds with the failure point's status
+		assert.Equal(t, c.status, w.Code, c.name)
+	}
+}
@@ -4,9 +4,11 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"sync"
 	"testing"
 
 	"github.com/dghubble/gologin/testutils"
+	"github.com/dghubble/oauth1"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -36,3 +38,54 @@ func NewAccessTokenServer(t *testing.T, data url.Values) *httptest.Server {
 		w.Write([]byte(data.Encode()))
 	})
 }
+
+// recordingSigner is an oauth1.Signer which records the token secrets used
+// to sign requests.
+type recordingSigner struct {
+	mu           sync.Mutex
+	tokenSecrets []string
+}
+
+func (s *recordingSigner) Name() string {
+	return "PLAINTEXT"
+}
+
+func (s *recordingSigner) Sign(tokenSecret, message string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokenSecrets = append(s.tokenSecrets, tokenSecret)
+	return "signature", nil
+}
+
+// NewProviderServer returns a new httptest.Server OAuth1 provider with
+// Request Token and Access Token endpoints and a Config for it which signs
+// requests with a recordingSigner. Caller must close the server.
+func NewProviderServer(t *testing.T) (*oauth1.Config, *recordingSigner, *httptest.Server) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	mux.HandleFunc("/request_token", func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "POST", req.Method)
+		w.Header().Set(contentType, formContentType)
+		w.Write([]byte(url.Values{"oauth_token": {"request_token"}, "oauth_token_secret": {"request_secret"}, "oauth_callback_confirmed": {"true"}}.Encode()))
+	})
+	mux.HandleFunc("/access_token", func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "POST", req.Method)
+		assert.Contains(t, req.Header.Get("Authorization"), `oauth_token="request_token"`)
+		assert.Contains(t, req.Header.Get("Authorization"), `oauth_verifier="verifier"`)
+		w.Header().Set(contentType, formContentType)
+		w.Write([]byte(url.Values{"oauth_token": {"access_token"}, "oauth_token_secret": {"access_secret"}}.Encode()))
+	})
+	signer := &recordingSigner{}
+	config := &oauth1.Config{
+		ConsumerKey:    "consumer_key",
+		ConsumerSecret: "consumer_secret",
+		CallbackURL:    "https://example.com/callback",
+		Endpoint: oauth1.Endpoint{
+			RequestTokenURL: server.URL + "/request_token",
+			AuthorizeURL:    server.URL + "/authorize",
+			AccessTokenURL:  server.URL + "/access_token",
+		},
+		Signer: signer,
+	}
+	return config, signer, server
+}
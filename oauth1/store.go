@@ -0,0 +1,183 @@
+package oauth1
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/internal"
+)
+
+const signedSecretSeparator = "|"
+
+// RequestSecretStore persists request token secrets (temporary credentials)
+// between the login phase and the callback phase.
+type RequestSecretStore interface {
+	// Save persists the secret of the request token issued to the requester.
+	Save(ctx context.Context, w http.ResponseWriter, req *http.Request, token, secret string) error
+	// Get returns the secret previously saved for the request token or an
+	// error (e.g. ErrMissingRequestSecret) if there is none.
+	Get(ctx context.Context, req *http.Request, token string) (string, error)
+	// Delete removes the secret saved for the request token.
+	Delete(ctx context.Context, w http.ResponseWriter, req *http.Request, token string) error
+}
+
+// StoreTempHandler persists or retrieves the request token secret (temporary
+// credentials) using the RequestSecretStore. If the request token can be read
+// from the ctx (login phase), the secret is saved in the store. Otherwise
+// (callback phase) the secret of the callback's oauth_token is read from the
+// store, deleted so it cannot be used again, and added to the ctx.
+//
+// If the secret cannot be saved or found, the failure handler is called with
+// the store's error (e.g. ErrMissingRequestSecret or
+// ErrRequestSecretMismatch).
+func StoreTempHandler(store RequestSecretStore, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		requestToken, requestSecret, err := RequestTokenFromContext(ctx)
+		if err == nil {
+			if err := store.Save(ctx, w, req, requestToken, requestSecret); err != nil {
+				ctx = gologin.WithError(ctx, err)
+				ctx = gologin.WithStatusCode(ctx, http.StatusInternalServerError)
+				failure.ServeHTTP(w, req.WithContext(ctx))
+				return
+			}
+			success.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		requestToken, _, err = parseCallback(req)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			ctx = gologin.WithStatusCode(ctx, callbackStatusCode(err))
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		requestSecret, err = store.Get(ctx, req, requestToken)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			ctx = gologin.WithStatusCode(ctx, http.StatusBadRequest)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		if err := store.Delete(ctx, w, req, requestToken); err != nil {
+			ctx = gologin.WithError(ctx, err)
+			ctx = gologin.WithStatusCode(ctx, http.StatusInternalServerError)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		ctx = WithRequestToken(ctx, requestToken, requestSecret)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// signedCookieSecretStore is a RequestSecretStore which keeps the request
+// token and secret in a short-lived cookie whose value is signed with
+// HMAC-SHA256.
+type signedCookieSecretStore struct {
+	config gologin.CookieConfig
+	key    []byte
+}
+
+// NewSignedCookieSecretStore returns a RequestSecretStore which keeps the
+// request token and secret in a short-lived cookie with the value format
+// token|secret|signature. The signature is an HMAC-SHA256 of the token and
+// secret using the server-side key.
+//
+// Get returns ErrMissingRequestSecret if there is no cookie and
+// ErrRequestSecretMismatch for tampered cookies or cookies issued for a
+// different request token.
+func NewSignedCookieSecretStore(config gologin.CookieConfig, key []byte) RequestSecretStore {
+	return &signedCookieSecretStore{
+		config: config,
+		key:    key,
+	}
+}
+
+func (s *signedCookieSecretStore) Save(ctx context.Context, w http.ResponseWriter, req *http.Request, token, secret string) error {
+	value := strings.Join([]string{token, secret, s.signature(token, secret)}, signedSecretSeparator)
+	http.SetCookie(w, internal.NewCookie(s.config, value))
+	return nil
+}
+
+func (s *signedCookieSecretStore) Get(ctx context.Context, req *http.Request, token string) (string, error) {
+	cookie, err := req.Cookie(s.config.Name)
+	if err != nil || cookie.Value == "" {
+		return "", ErrMissingRequestSecret
+	}
+	parts := strings.Split(cookie.Value, signedSecretSeparator)
+	if len(parts) != 3 {
+		return "", ErrRequestSecretMismatch
+	}
+	savedToken, secret, signature := parts[0], parts[1], parts[2]
+	if !hmac.Equal([]byte(signature), []byte(s.signature(savedToken, secret))) {
+		return "", ErrRequestSecretMismatch
+	}
+	if !hmac.Equal([]byte(savedToken), []byte(token)) {
+		return "", ErrRequestSecretMismatch
+	}
+	return secret, nil
+}
+
+func (s *signedCookieSecretStore) Delete(ctx context.Context, w http.ResponseWriter, req *http.Request, token string) error {
+	http.SetCookie(w, internal.ExpiredCookie(s.config))
+	return nil
+}
+
+// signature returns the base64 encoded HMAC-SHA256 of the token and secret.
+func (s *signedCookieSecretStore) signature(token, secret string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(token + signedSecretSeparator + secret))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// MemorySecretStore is an in-memory RequestSecretStore which keeps request
+// token secrets by request token. It is intended for tests and single
+// process development servers.
+type MemorySecretStore struct {
+	mu      sync.Mutex
+	secrets map[string]string
+}
+
+// NewMemorySecretStore returns a new, empty MemorySecretStore.
+func NewMemorySecretStore() *MemorySecretStore {
+	return &MemorySecretStore{
+		secrets: make(map[string]string),
+	}
+}
+
+// Save adds the request token secret to the store.
+func (s *MemorySecretStore) Save(ctx context.Context, w http.ResponseWriter, req *http.Request, token, secret string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.secrets[token] = secret
+	return nil
+}
+
+// Get returns the secret of the request token or ErrMissingRequestSecret if
+// it was not saved (or was deleted).
+func (s *MemorySecretStore) Get(ctx context.Context, req *http.Request, token string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	secret, ok := s.secrets[token]
+	if !ok {
+		return "", ErrMissingRequestSecret
+	}
+	return secret, nil
+}
+
+// Delete removes the secret of the request token from the store.
+func (s *MemorySecretStore) Delete(ctx context.Context, w http.ResponseWriter, req *http.Request, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.secrets, token)
+	return nil
+}
@@ -0,0 +1,132 @@
+package oauth1
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+)
+
+// testSecretStores returns a signed cookie and an in-memory
+// RequestSecretStore.
+func testSecretStores() map[string]RequestSecretStore {
+	return map[string]RequestSecretStore{
+		"signed cookie": NewSignedCookieSecretStore(gologin.DebugOnlyCookieConfig, []byte("secret-key")),
+		"memory":        NewMemorySecretStore(),
+	}
+}
+
+// login serves a login request with the handler and returns the response.
+func login(handler http.Handler) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/login", nil)
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+// callback serves a callback request for the request token with the login
+// response's cookies and returns the response.
+func callback(handler http.Handler, loginResp *httptest.ResponseRecorder, requestToken string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/callback?oauth_token="+requestToken+"&oauth_verifier=verifier", nil)
+	for _, cookie := range loginResp.Result().Cookies() {
+		req.AddCookie(cookie)
+	}
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+func TestStoreTempHandler_RoundTrip(t *testing.T) {
+	for name, store := range testSecretStores() {
+		config, signer, server := NewProviderServer(t)
+		failure := testutils.AssertFailureNotCalled(t)
+		success := func(w http.ResponseWriter, req *http.Request) {
+			accessToken, accessSecret, err := AccessTokenFromContext(req.Context())
+			assert.Nil(t, err)
+			assert.Equal(t, "access_token", accessToken)
+			assert.Equal(t, "access_secret", accessSecret)
+			fmt.Fprintf(w, "success handler called")
+		}
+		loginHandler := LoginHandler(config, StoreTempHandler(store, AuthRedirectHandler(config, failure), failure), failure)
+		callbackHandler := StoreTempHandler(store, CallbackHandler(config, http.HandlerFunc(success), failure), failure)
+
+		// Login and callback with a RequestSecretStore, assert that:
+		// - login redirects to the AuthorizeURL with the request token
+		// - callback obtains an access token signed with the stored secret
+		// - success handler is called
+		w := login(loginHandler)
+		assert.Equal(t, http.StatusFound, w.Code, name)
+		location, err := url.Parse(w.HeaderMap.Get("Location"))
+		if assert.Nil(t, err, name) {
+			assert.Equal(t, "request_token", location.Query().Get("oauth_token"), name)
+		}
+		resp := callback(callbackHandler, w, "request_token")
+		assert.Equal(t, "success handler called", resp.Body.String(), name)
+		assert.Equal(t, []string{"", "request_secret"}, signer.tokenSecrets, name)
+		server.Close()
+	}
+}
+
+func TestStoreTempHandler_SecretDeleted(t *testing.T) {
+	config, _, server := NewProviderServer(t)
+	defer server.Close()
+	store := NewMemorySecretStore()
+	success := func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, ErrMissingRequestSecret, gologin.ErrorFromContext(req.Context()))
+		fmt.Fprintf(w, "failure handler called")
+	}
+	loginHandler := LoginHandler(config, StoreTempHandler(store, AuthRedirectHandler(config, nil), nil), nil)
+	callbackHandler := StoreTempHandler(store, CallbackHandler(config, http.HandlerFunc(success), nil), http.HandlerFunc(failure))
+
+	// Callback replayed with a used request token, assert that:
+	// - the first callback succeeds
+	// - failure handler is called with ErrMissingRequestSecret
+	w := login(loginHandler)
+	assert.Equal(t, "success handler called", callback(callbackHandler, w, "request_token").Body.String())
+	assert.Equal(t, "failure handler called", callback(callbackHandler, w, "request_token").Body.String())
+}
+
+func TestStoreTempHandler_Errors(t *testing.T) {
+	config, _, server := NewProviderServer(t)
+	defer server.Close()
+	signedStore := NewSignedCookieSecretStore(gologin.DebugOnlyCookieConfig, []byte("secret-key"))
+	otherKeyStore := NewSignedCookieSecretStore(gologin.DebugOnlyCookieConfig, []byte("other-key"))
+	cases := []struct {
+		name       string
+		loginStore RequestSecretStore
+		store      RequestSecretStore
+		token      string
+		err        error
+	}{
+		{"signed cookie missing", nil, signedStore, "request_token", ErrMissingRequestSecret},
+		{"signed cookie other token", signedStore, signedStore, "other_token", ErrRequestSecretMismatch},
+		{"signed cookie tampered", otherKeyStore, signedStore, "request_token", ErrRequestSecretMismatch},
+		{"memory missing", nil, NewMemorySecretStore(), "request_token", ErrMissingRequestSecret},
+		{"memory other token", nil, NewMemorySecretStore(), "other_token", ErrMissingRequestSecret},
+	}
+	success := testutils.AssertSuccessNotCalled(t)
+	for _, c := range cases {
+		failure := func(w http.ResponseWriter, req *http.Request) {
+			assert.Equal(t, c.err, gologin.ErrorFromContext(req.Context()), c.name)
+			fmt.Fprintf(w, "failure handler called")
+		}
+		w := httptest.NewRecorder()
+		if c.loginStore != nil {
+			w = login(LoginHandler(config, StoreTempHandler(c.loginStore, AuthRedirectHandler(config, nil), nil), nil))
+		}
+		callbackHandler := StoreTempHandler(c.store, CallbackHandler(config, success, nil), http.HandlerFunc(failure))
+
+		// Callback with a missing or mismatched request secret, assert that:
+		// - failure handler is called with a dedicated error
+		resp := callback(callbackHandler, w, c.token)
+		assert.Equal(t, "failure handler called", resp.Body.String(), c.name)
+	}
+}
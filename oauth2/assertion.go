@@ -0,0 +1,149 @@
+package oauth2
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/dghubble/gologin"
+	"golang.org/x/oauth2"
+)
+
+// Errors which may occur when signing client assertions.
+var (
+	ErrUnsupportedAssertionKey = errors.New("oauth2: client assertion key must be RSA or ECDSA P-256")
+)
+
+const (
+	clientAssertionType    = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+	defaultAssertionExpiry = time.Minute
+)
+
+// ClientAssertionFunc returns a signed JWT which authenticates the client at
+// the token endpoint.
+type ClientAssertionFunc func(ctx context.Context) (string, error)
+
+// ClientAssertionHandler adds a client assertion (RFC 7523) to the exchange
+// options in the ctx so CallbackHandler authenticates with private_key_jwt
+// rather than a client secret. If the assertion cannot be created, the
+// failure handler is called.
+//
+// Set the oauth2.Config Endpoint AuthStyle to oauth2.AuthStyleInParams and
+// leave the ClientSecret empty.
+func ClientAssertionHandler(assertion ClientAssertionFunc, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		signed, err := assertion(ctx)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		opts, _ := ExchangeOptionsFromContext(ctx)
+		opts = append(opts,
+			oauth2.SetAuthURLParam("client_assertion_type", clientAssertionType),
+			oauth2.SetAuthURLParam("client_assertion", signed),
+		)
+		ctx = WithExchangeOptions(ctx, opts...)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// PrivateKeyJWT signs short-lived client assertions with a private key.
+type PrivateKeyJWT struct {
+	// Key is an *rsa.PrivateKey (RS256) or P-256 *ecdsa.PrivateKey (ES256).
+	Key crypto.Signer
+	// KeyID is the optional "kid" header identifying the key to the provider.
+	KeyID string
+	// ClientID is the issuer and subject of the assertion.
+	ClientID string
+	// TokenURL is the audience of the assertion.
+	TokenURL string
+	// Expiry is the assertion lifetime. Defaults to 1 minute.
+	Expiry time.Duration
+}
+
+// Assertion returns a signed client assertion JWT. It may be used as a
+// ClientAssertionFunc.
+func (p *PrivateKeyJWT) Assertion(ctx context.Context) (string, error) {
+	var alg string
+	switch key := p.Key.(type) {
+	case *rsa.PrivateKey:
+		alg = "RS256"
+	case *ecdsa.PrivateKey:
+		if key.Curve != elliptic.P256() {
+			return "", ErrUnsupportedAssertionKey
+		}
+		alg = "ES256"
+	default:
+		return "", ErrUnsupportedAssertionKey
+	}
+	expiry := p.Expiry
+	if expiry <= 0 {
+		expiry = defaultAssertionExpiry
+	}
+	jti, err := randomState(ctx)
+	if err != nil {
+		return "", err
+	}
+	now := gologin.ClockFromContext(ctx).Now()
+	header := map[string]string{"alg": alg, "typ": "JWT"}
+	if p.KeyID != "" {
+		header["kid"] = p.KeyID
+	}
+	claims := map[string]interface{}{
+		"iss": p.ClientID,
+		"sub": p.ClientID,
+		"aud": p.TokenURL,
+		"jti": jti,
+		"iat": now.Unix(),
+		"exp": now.Add(expiry).Unix(),
+	}
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString(claimsJSON)
+	signature, err := p.sign([]byte(signingInput))
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// sign returns the JWS signature of the signing input.
+func (p *PrivateKeyJWT) sign(signingInput []byte) ([]byte, error) {
+	digest := sha256.Sum256(signingInput)
+	switch key := p.Key.(type) {
+	case *rsa.PrivateKey:
+		return rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+		if err != nil {
+			return nil, err
+		}
+		// JWS ES256 signatures are the 32 byte big-endian R and S
+		signature := make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+		return signature, nil
+	}
+	return nil, ErrUnsupportedAssertionKey
+}
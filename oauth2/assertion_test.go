@@ -0,0 +1,157 @@
+package oauth2
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+// verifyAssertion checks the assertion signature with the public key and
+// returns the decoded header and claims.
+func verifyAssertion(t *testing.T, assertion string, public crypto.PublicKey) (map[string]interface{}, map[string]interface{}) {
+	parts := strings.Split(assertion, ".")
+	if !assert.Len(t, parts, 3) {
+		return nil, nil
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	assert.Nil(t, err)
+	switch key := public.(type) {
+	case *rsa.PublicKey:
+		assert.Nil(t, rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature))
+	case *ecdsa.PublicKey:
+		if assert.Len(t, signature, 64) {
+			r := new(big.Int).SetBytes(signature[:32])
+			s := new(big.Int).SetBytes(signature[32:])
+			assert.True(t, ecdsa.Verify(key, digest[:], r, s))
+		}
+	}
+	var header, claims map[string]interface{}
+	headerJSON, _ := base64.RawURLEncoding.DecodeString(parts[0])
+	claimsJSON, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	assert.Nil(t, json.Unmarshal(headerJSON, &header))
+	assert.Nil(t, json.Unmarshal(claimsJSON, &claims))
+	return header, claims
+}
+
+func TestPrivateKeyJWT_Assertion(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	cases := []struct {
+		key    crypto.Signer
+		public crypto.PublicKey
+		alg    string
+	}{
+		{rsaKey, &rsaKey.PublicKey, "RS256"},
+		{ecKey, &ecKey.PublicKey, "ES256"},
+	}
+	for _, c := range cases {
+		p := &PrivateKeyJWT{
+			Key:      c.key,
+			KeyID:    "key-1",
+			ClientID: "client_id",
+			TokenURL: "https://idp.example.com/token",
+		}
+		assertion, err := p.Assertion(context.Background())
+		assert.Nil(t, err)
+		header, claims := verifyAssertion(t, assertion, c.public)
+		assert.Equal(t, c.alg, header["alg"])
+		assert.Equal(t, "key-1", header["kid"])
+		assert.Equal(t, "client_id", claims["iss"])
+		assert.Equal(t, "client_id", claims["sub"])
+		assert.Equal(t, "https://idp.example.com/token", claims["aud"])
+		assert.NotEmpty(t, claims["jti"])
+		exp := time.Unix(int64(claims["exp"].(float64)), 0)
+		assert.WithinDuration(t, time.Now().Add(time.Minute), exp, 5*time.Second)
+	}
+}
+
+func TestPrivateKeyJWT_UnsupportedKey(t *testing.T) {
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	p384Key, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	for _, key := range []crypto.Signer{edKey, p384Key} {
+		p := &PrivateKeyJWT{Key: key, ClientID: "client_id"}
+		_, err := p.Assertion(context.Background())
+		assert.Equal(t, ErrUnsupportedAssertionKey, err)
+	}
+}
+
+func TestClientAssertionHandler(t *testing.T) {
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	server := NewTestServerFunc(func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, clientAssertionType, req.PostFormValue("client_assertion_type"))
+		_, claims := verifyAssertion(t, req.PostFormValue("client_assertion"), &ecKey.PublicKey)
+		assert.Equal(t, "client_id", claims["iss"])
+		assert.Empty(t, req.PostFormValue("client_secret"))
+		w.Header().Set(contentType, jsonContentType)
+		w.Write([]byte(`{"access_token":"2YotnFZFEjr1zCsicMWpAA","token_type":"example"}`))
+	})
+	defer server.Close()
+	config := &oauth2.Config{
+		ClientID: "client_id",
+		Endpoint: oauth2.Endpoint{
+			TokenURL:  server.URL,
+			AuthStyle: oauth2.AuthStyleInParams,
+		},
+	}
+	keyJWT := &PrivateKeyJWT{Key: ecKey, ClientID: "client_id", TokenURL: server.URL}
+	success := func(w http.ResponseWriter, req *http.Request) {
+		token, err := TokenFromContext(req.Context())
+		assert.Nil(t, err)
+		assert.Equal(t, "2YotnFZFEjr1zCsicMWpAA", token.AccessToken)
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// ClientAssertionHandler and CallbackHandler, assert that:
+	// - the client assertion is sent in the token request body
+	// - success handler is called
+	handler := ClientAssertionHandler(keyJWT.Assertion, CallbackHandler(config, http.HandlerFunc(success), failure), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	ctx := WithState(context.Background(), "d4e5f6")
+	handler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestClientAssertionHandler_SigningError(t *testing.T) {
+	assertion := func(ctx context.Context) (string, error) {
+		return "", errors.New("signer unavailable")
+	}
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "signer unavailable", err.Error())
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// ClientAssertionHandler cannot sign an assertion, assert that:
+	// - failure handler is called with the signing error
+	handler := ClientAssertionHandler(assertion, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
@@ -3,7 +3,9 @@ package oauth2
 import (
 	"context"
 	"fmt"
+	"time"
 
+	"github.com/dghubble/gologin"
 	"golang.org/x/oauth2"
 )
 
@@ -13,6 +15,23 @@ type key int
 const (
 	tokenKey key = iota
 	stateKey
+	verifierKey
+	authCodeOptionsKey
+	scopesKey
+	nonceKey
+	returnURLKey
+	deviceAuthKey
+	stateExpiryKey
+	stateCookieConfigKey
+	consumedStateKey
+	exchangeOptionsKey
+	linkTargetKey
+	redirectURLKey
+	missingStateCookieKey
+	claimsKey
+	grantedScopesKey
+	localeKey
+	stateUseKey
 )
 
 // WithState returns a copy of ctx that stores the state value.
@@ -29,12 +48,29 @@ func StateFromContext(ctx context.Context) (string, error) {
 	return state, nil
 }
 
+// WithStateExpiry returns a copy of ctx that stores the time at which the
+// state value expires.
+func WithStateExpiry(ctx context.Context, expiry time.Time) context.Context {
+	return context.WithValue(ctx, stateExpiryKey, expiry)
+}
+
+// StateExpiryFromContext returns the state expiry time from the ctx.
+func StateExpiryFromContext(ctx context.Context) (time.Time, error) {
+	expiry, ok := ctx.Value(stateExpiryKey).(time.Time)
+	if !ok {
+		return time.Time{}, fmt.Errorf("oauth2: Context missing state expiry")
+	}
+	return expiry, nil
+}
+
 // WithToken returns a copy of ctx that stores the Token.
 func WithToken(ctx context.Context, token *oauth2.Token) context.Context {
 	return context.WithValue(ctx, tokenKey, token)
 }
 
-// TokenFromContext returns the Token from the ctx.
+// TokenFromContext returns the Token from the ctx. The Token includes the
+// access token as well as the refresh token, token type, and expiry returned
+// by the provider.
 func TokenFromContext(ctx context.Context) (*oauth2.Token, error) {
 	token, ok := ctx.Value(tokenKey).(*oauth2.Token)
 	if !ok {
@@ -42,3 +78,242 @@ func TokenFromContext(ctx context.Context) (*oauth2.Token, error) {
 	}
 	return token, nil
 }
+
+// WithPKCEVerifier returns a copy of ctx that stores the PKCE code verifier.
+func WithPKCEVerifier(ctx context.Context, verifier string) context.Context {
+	return context.WithValue(ctx, verifierKey, verifier)
+}
+
+// PKCEVerifierFromContext returns the PKCE code verifier from the ctx.
+func PKCEVerifierFromContext(ctx context.Context) (string, error) {
+	verifier, ok := ctx.Value(verifierKey).(string)
+	if !ok {
+		return "", fmt.Errorf("oauth2: Context missing PKCE code verifier")
+	}
+	return verifier, nil
+}
+
+// WithAuthCodeOptions returns a copy of ctx that stores AuthCodeOptions to be
+// added to the AuthURL by LoginHandler.
+func WithAuthCodeOptions(ctx context.Context, opts ...oauth2.AuthCodeOption) context.Context {
+	return context.WithValue(ctx, authCodeOptionsKey, opts)
+}
+
+// AuthCodeOptionsFromContext returns the AuthCodeOptions from the ctx.
+func AuthCodeOptionsFromContext(ctx context.Context) ([]oauth2.AuthCodeOption, error) {
+	opts, ok := ctx.Value(authCodeOptionsKey).([]oauth2.AuthCodeOption)
+	if !ok {
+		return nil, fmt.Errorf("oauth2: Context missing AuthCodeOptions")
+	}
+	return opts, nil
+}
+
+// WithLocale returns a copy of ctx that stores the user's locale (e.g.
+// "fr-CA"), which provider login handlers send to show the consent screen in
+// that language.
+func WithLocale(ctx context.Context, locale string) context.Context {
+	return context.WithValue(ctx, localeKey, locale)
+}
+
+// LocaleFromContext returns the locale from the ctx.
+func LocaleFromContext(ctx context.Context) (string, error) {
+	locale, ok := ctx.Value(localeKey).(string)
+	if !ok {
+		return "", fmt.Errorf("oauth2: Context missing locale")
+	}
+	return locale, nil
+}
+
+// WithExchangeOptions returns a copy of ctx that stores AuthCodeOptions to be
+// sent with the code exchange by CallbackHandler (e.g. client assertions).
+func WithExchangeOptions(ctx context.Context, opts ...oauth2.AuthCodeOption) context.Context {
+	return context.WithValue(ctx, exchangeOptionsKey, opts)
+}
+
+// ExchangeOptionsFromContext returns the exchange AuthCodeOptions from the
+// ctx.
+func ExchangeOptionsFromContext(ctx context.Context) ([]oauth2.AuthCodeOption, error) {
+	opts, ok := ctx.Value(exchangeOptionsKey).([]oauth2.AuthCodeOption)
+	if !ok {
+		return nil, fmt.Errorf("oauth2: Context missing exchange AuthCodeOptions")
+	}
+	return opts, nil
+}
+
+// WithScopes returns a copy of ctx that stores scopes to be requested by
+// LoginHandler instead of the oauth2.Config Scopes.
+func WithScopes(ctx context.Context, scopes ...string) context.Context {
+	return context.WithValue(ctx, scopesKey, scopes)
+}
+
+// ScopesFromContext returns the scopes from the ctx.
+func ScopesFromContext(ctx context.Context) ([]string, error) {
+	scopes, ok := ctx.Value(scopesKey).([]string)
+	if !ok {
+		return nil, fmt.Errorf("oauth2: Context missing scopes")
+	}
+	return scopes, nil
+}
+
+// WithGrantedScopes returns a copy of ctx that stores the scopes the user
+// granted, which may differ from the requested scopes.
+func WithGrantedScopes(ctx context.Context, scopes ...string) context.Context {
+	return context.WithValue(ctx, grantedScopesKey, scopes)
+}
+
+// GrantedScopesFromContext returns the granted scopes from the ctx (see
+// CallbackHandler).
+func GrantedScopesFromContext(ctx context.Context) ([]string, error) {
+	scopes, ok := ctx.Value(grantedScopesKey).([]string)
+	if !ok {
+		return nil, fmt.Errorf("oauth2: Context missing granted scopes")
+	}
+	return scopes, nil
+}
+
+// WithNonce returns a copy of ctx that stores the OpenID Connect nonce.
+func WithNonce(ctx context.Context, nonce string) context.Context {
+	return context.WithValue(ctx, nonceKey, nonce)
+}
+
+// NonceFromContext returns the OpenID Connect nonce from the ctx.
+func NonceFromContext(ctx context.Context) (string, error) {
+	nonce, ok := ctx.Value(nonceKey).(string)
+	if !ok {
+		return "", fmt.Errorf("oauth2: Context missing nonce")
+	}
+	return nonce, nil
+}
+
+// WithReturnURL returns a copy of ctx that stores the return URL.
+func WithReturnURL(ctx context.Context, returnURL string) context.Context {
+	return context.WithValue(ctx, returnURLKey, returnURL)
+}
+
+// ReturnURLFromContext returns the return URL from the ctx.
+func ReturnURLFromContext(ctx context.Context) (string, error) {
+	returnURL, ok := ctx.Value(returnURLKey).(string)
+	if !ok {
+		return "", fmt.Errorf("oauth2: Context missing return URL")
+	}
+	return returnURL, nil
+}
+
+// WithDeviceAuthorization returns a copy of ctx that stores the
+// DeviceAuthorization.
+func WithDeviceAuthorization(ctx context.Context, deviceAuth *DeviceAuthorization) context.Context {
+	return context.WithValue(ctx, deviceAuthKey, deviceAuth)
+}
+
+// DeviceAuthorizationFromContext returns the DeviceAuthorization from the ctx.
+func DeviceAuthorizationFromContext(ctx context.Context) (*DeviceAuthorization, error) {
+	deviceAuth, ok := ctx.Value(deviceAuthKey).(*DeviceAuthorization)
+	if !ok {
+		return nil, fmt.Errorf("oauth2: Context missing DeviceAuthorization")
+	}
+	return deviceAuth, nil
+}
+
+// WithLinkTarget returns a copy of ctx that stores the LinkTarget.
+func WithLinkTarget(ctx context.Context, target *LinkTarget) context.Context {
+	return context.WithValue(ctx, linkTargetKey, target)
+}
+
+// LinkTargetFromContext returns the LinkTarget from the ctx.
+func LinkTargetFromContext(ctx context.Context) (*LinkTarget, error) {
+	target, ok := ctx.Value(linkTargetKey).(*LinkTarget)
+	if !ok {
+		return nil, fmt.Errorf("oauth2: Context missing LinkTarget")
+	}
+	return target, nil
+}
+
+// withStateCookieConfig returns a copy of ctx that stores the CookieConfig of
+// the state cookie so CallbackHandler can expire the state cookie.
+func withStateCookieConfig(ctx context.Context, config gologin.CookieConfig) context.Context {
+	return context.WithValue(ctx, stateCookieConfigKey, config)
+}
+
+// stateCookieConfigFromContext returns the state cookie CookieConfig from the
+// ctx.
+func stateCookieConfigFromContext(ctx context.Context) (gologin.CookieConfig, error) {
+	config, ok := ctx.Value(stateCookieConfigKey).(gologin.CookieConfig)
+	if !ok {
+		return gologin.CookieConfig{}, fmt.Errorf("oauth2: Context missing state CookieConfig")
+	}
+	return config, nil
+}
+
+// withConsumedState returns a copy of ctx that stores a state which was
+// already used.
+func withConsumedState(ctx context.Context, state string) context.Context {
+	return context.WithValue(ctx, consumedStateKey, state)
+}
+
+// consumedStateFromContext returns the already used state from the ctx.
+func consumedStateFromContext(ctx context.Context) (string, error) {
+	state, ok := ctx.Value(consumedStateKey).(string)
+	if !ok {
+		return "", fmt.Errorf("oauth2: Context missing consumed state")
+	}
+	return state, nil
+}
+
+// withStateUse returns a copy of ctx that stores a func which records the
+// callback state as used, for CallbackHandler to call once the code is
+// exchanged.
+func withStateUse(ctx context.Context, use func(ctx context.Context) error) context.Context {
+	return context.WithValue(ctx, stateUseKey, use)
+}
+
+// useState records the callback state of the ctx as used, if the ctx has a
+// state use func (see withStateUse).
+func useState(ctx context.Context) error {
+	if use, ok := ctx.Value(stateUseKey).(func(ctx context.Context) error); ok {
+		return use(ctx)
+	}
+	return nil
+}
+
+// withMissingStateCookie returns a copy of ctx which records that a callback
+// request had no state cookie, so CallbackHandler rejects it.
+func withMissingStateCookie(ctx context.Context) context.Context {
+	return context.WithValue(ctx, missingStateCookieKey, true)
+}
+
+// missingStateCookie returns true if the ctx callback had no state cookie.
+func missingStateCookie(ctx context.Context) bool {
+	missing, _ := ctx.Value(missingStateCookieKey).(bool)
+	return missing
+}
+
+// WithRedirectURL returns a copy of ctx that stores the redirect URL to be
+// used by LoginHandler and CallbackHandler instead of the oauth2.Config
+// RedirectURL.
+func WithRedirectURL(ctx context.Context, redirectURL string) context.Context {
+	return context.WithValue(ctx, redirectURLKey, redirectURL)
+}
+
+// RedirectURLFromContext returns the redirect URL from the ctx.
+func RedirectURLFromContext(ctx context.Context) (string, error) {
+	redirectURL, ok := ctx.Value(redirectURLKey).(string)
+	if !ok {
+		return "", fmt.Errorf("oauth2: Context missing redirect URL")
+	}
+	return redirectURL, nil
+}
+
+// WithClaims returns a copy of ctx that stores the IntrospectionClaims.
+func WithClaims(ctx context.Context, claims *IntrospectionClaims) context.Context {
+	return context.WithValue(ctx, claimsKey, claims)
+}
+
+// ClaimsFromContext returns the IntrospectionClaims of the bearer token from
+// the ctx (see IntrospectionHandler).
+func ClaimsFromContext(ctx context.Context) (*IntrospectionClaims, error) {
+	claims, ok := ctx.Value(claimsKey).(*IntrospectionClaims)
+	if !ok {
+		return nil, fmt.Errorf("oauth2: Context missing IntrospectionClaims")
+	}
+	return claims, nil
+}
@@ -3,6 +3,7 @@ package oauth2
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"golang.org/x/oauth2"
@@ -39,3 +40,131 @@ func TestTokenFromContext_Error(t *testing.T) {
 		assert.Equal(t, "oauth2: Context missing Token", err.Error())
 	}
 }
+
+func TestContext_PKCEVerifier(t *testing.T) {
+	expectedVerifier := "verifier"
+	ctx := WithPKCEVerifier(context.Background(), expectedVerifier)
+	verifier, err := PKCEVerifierFromContext(ctx)
+	assert.Equal(t, expectedVerifier, verifier)
+	assert.Nil(t, err)
+}
+
+func TestPKCEVerifierFromContext_Error(t *testing.T) {
+	verifier, err := PKCEVerifierFromContext(context.Background())
+	assert.Equal(t, "", verifier)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "oauth2: Context missing PKCE code verifier", err.Error())
+	}
+}
+
+func TestContext_AuthCodeOptions(t *testing.T) {
+	expectedOpts := []oauth2.AuthCodeOption{oauth2.AccessTypeOffline}
+	ctx := WithAuthCodeOptions(context.Background(), expectedOpts...)
+	opts, err := AuthCodeOptionsFromContext(ctx)
+	assert.Equal(t, expectedOpts, opts)
+	assert.Nil(t, err)
+}
+
+func TestAuthCodeOptionsFromContext_Error(t *testing.T) {
+	opts, err := AuthCodeOptionsFromContext(context.Background())
+	assert.Nil(t, opts)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "oauth2: Context missing AuthCodeOptions", err.Error())
+	}
+}
+
+func TestContext_Scopes(t *testing.T) {
+	expectedScopes := []string{"email", "repo"}
+	ctx := WithScopes(context.Background(), expectedScopes...)
+	scopes, err := ScopesFromContext(ctx)
+	assert.Equal(t, expectedScopes, scopes)
+	assert.Nil(t, err)
+}
+
+func TestScopesFromContext_Error(t *testing.T) {
+	scopes, err := ScopesFromContext(context.Background())
+	assert.Nil(t, scopes)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "oauth2: Context missing scopes", err.Error())
+	}
+}
+
+func TestContext_Nonce(t *testing.T) {
+	expectedNonce := "nonce"
+	ctx := WithNonce(context.Background(), expectedNonce)
+	nonce, err := NonceFromContext(ctx)
+	assert.Equal(t, expectedNonce, nonce)
+	assert.Nil(t, err)
+}
+
+func TestNonceFromContext_Error(t *testing.T) {
+	nonce, err := NonceFromContext(context.Background())
+	assert.Equal(t, "", nonce)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "oauth2: Context missing nonce", err.Error())
+	}
+}
+
+func TestContext_ReturnURL(t *testing.T) {
+	expectedReturnURL := "/settings"
+	ctx := WithReturnURL(context.Background(), expectedReturnURL)
+	returnURL, err := ReturnURLFromContext(ctx)
+	assert.Equal(t, expectedReturnURL, returnURL)
+	assert.Nil(t, err)
+}
+
+func TestReturnURLFromContext_Error(t *testing.T) {
+	returnURL, err := ReturnURLFromContext(context.Background())
+	assert.Equal(t, "", returnURL)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "oauth2: Context missing return URL", err.Error())
+	}
+}
+
+func TestContext_DeviceAuthorization(t *testing.T) {
+	expectedDeviceAuth := &DeviceAuthorization{DeviceCode: "device_code", UserCode: "WDJB-MJHT"}
+	ctx := WithDeviceAuthorization(context.Background(), expectedDeviceAuth)
+	deviceAuth, err := DeviceAuthorizationFromContext(ctx)
+	assert.Equal(t, expectedDeviceAuth, deviceAuth)
+	assert.Nil(t, err)
+}
+
+func TestDeviceAuthorizationFromContext_Error(t *testing.T) {
+	deviceAuth, err := DeviceAuthorizationFromContext(context.Background())
+	assert.Nil(t, deviceAuth)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "oauth2: Context missing DeviceAuthorization", err.Error())
+	}
+}
+
+func TestContext_StateExpiry(t *testing.T) {
+	expectedExpiry := time.Unix(1500000000, 0)
+	ctx := WithStateExpiry(context.Background(), expectedExpiry)
+	expiry, err := StateExpiryFromContext(ctx)
+	assert.Equal(t, expectedExpiry, expiry)
+	assert.Nil(t, err)
+}
+
+func TestStateExpiryFromContext_Error(t *testing.T) {
+	expiry, err := StateExpiryFromContext(context.Background())
+	assert.True(t, expiry.IsZero())
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "oauth2: Context missing state expiry", err.Error())
+	}
+}
+
+func TestContext_ExchangeOptions(t *testing.T) {
+	expectedOpts := []oauth2.AuthCodeOption{oauth2.SetAuthURLParam("client_assertion", "jwt")}
+	ctx := WithExchangeOptions(context.Background(), expectedOpts...)
+	opts, err := ExchangeOptionsFromContext(ctx)
+	assert.Equal(t, expectedOpts, opts)
+	assert.Nil(t, err)
+}
+
+func TestExchangeOptionsFromContext_Error(t *testing.T) {
+	opts, err := ExchangeOptionsFromContext(context.Background())
+	assert.Nil(t, opts)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "oauth2: Context missing exchange AuthCodeOptions", err.Error())
+	}
+}
@@ -0,0 +1,267 @@
+package oauth2
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/internal"
+	"golang.org/x/oauth2"
+)
+
+// Errors which may occur with the device authorization grant.
+var (
+	ErrMissingDeviceCode = errors.New("oauth2: missing device code")
+)
+
+const (
+	deviceCodeGrantType   = "urn:ietf:params:oauth:grant-type:device_code"
+	defaultDeviceInterval = 5
+	// slow_down responses increase the polling interval by 5 seconds
+	slowDownIncrement = 5
+)
+
+// pollIntervalUnit is the unit of device polling intervals and device code
+// lifetimes (overridden by tests).
+var pollIntervalUnit = time.Second
+
+// DeviceAuthorization is a device authorization response.
+// https://tools.ietf.org/html/rfc8628#section-3.2
+type DeviceAuthorization struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete,omitempty"`
+	// ExpiresIn is the lifetime of the device code in seconds.
+	ExpiresIn int `json:"expires_in"`
+	// Interval is the minimum polling interval in seconds.
+	Interval int `json:"interval,omitempty"`
+}
+
+// DeviceAuthHandler handles device authorization requests (RFC 8628) by
+// POSTing the client ID and scopes to the provider's device authorization
+// endpoint. If successful, the DeviceAuthorization (device code, user code,
+// and verification URI) is added to the ctx and the success handler is
+// called. Otherwise, the failure handler is called.
+//
+// If the ctx contains scopes, they are requested instead of the config
+// Scopes. Requests use the ctx oauth2.HTTPClient, if any.
+func DeviceAuthHandler(config *oauth2.Config, deviceAuthURL string, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		scopes := config.Scopes
+		if ctxScopes, err := ScopesFromContext(ctx); err == nil && len(ctxScopes) > 0 {
+			scopes = dedupeScopes(ctxScopes)
+		}
+		params := url.Values{"client_id": {config.ClientID}}
+		if len(scopes) > 0 {
+			params.Set("scope", strings.Join(scopes, " "))
+		}
+		deviceAuth := new(DeviceAuthorization)
+		if err := postForm(ctx, deviceAuthURL, params, deviceAuth); err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		if deviceAuth.DeviceCode == "" {
+			ctx = gologin.WithError(ctx, ErrMissingDeviceCode)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		ctx = WithDeviceAuthorization(ctx, deviceAuth)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// DeviceAuthJSONHandler renders the DeviceAuthorization from the ctx as JSON
+// so device clients can display the user code and verification URI. If the
+// ctx has no DeviceAuthorization, the failure handler is called.
+func DeviceAuthJSONHandler(failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		deviceAuth, err := DeviceAuthorizationFromContext(ctx)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(deviceAuth)
+	}
+	return http.HandlerFunc(fn)
+}
+
+// DevicePollHandler polls the provider's token endpoint to exchange a device
+// code for an OAuth2 Token. The device code is read from the ctx
+// DeviceAuthorization or, if absent, the "device_code" request parameter.
+// Polling continues while authorization is pending, slows down when asked,
+// and stops when the device code expires or the request ctx is done. Polls
+// wait with the ctx gologin Clock (see gologin.WithClock), so a Sleeper such
+// as gologintest.FakeClock polls without waiting.
+//
+// If a Token is obtained, it is added to the ctx (like CallbackHandler) and
+// the success handler is called. If the user denies authorization or the
+// device code expires, the failure handler is called with an
+// AuthorizationError (see IsAccessDenied).
+func DevicePollHandler(config *oauth2.Config, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		deviceAuth, err := DeviceAuthorizationFromContext(ctx)
+		if err != nil {
+			deviceAuth = &DeviceAuthorization{DeviceCode: req.FormValue("device_code")}
+		}
+		if deviceAuth.DeviceCode == "" {
+			ctx = gologin.WithError(ctx, ErrMissingDeviceCode)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		token, err := pollDeviceToken(ctx, config, deviceAuth)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		ctx = WithToken(ctx, token)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// pollDeviceToken polls the token endpoint until a Token is issued or a
+// terminal error occurs.
+// https://tools.ietf.org/html/rfc8628#section-3.5
+func pollDeviceToken(ctx context.Context, config *oauth2.Config, deviceAuth *DeviceAuthorization) (*oauth2.Token, error) {
+	interval := deviceAuth.Interval
+	if interval <= 0 {
+		interval = defaultDeviceInterval
+	}
+	clock := gologin.ClockFromContext(ctx)
+	var deadline time.Time
+	if deviceAuth.ExpiresIn > 0 {
+		deadline = clock.Now().Add(time.Duration(deviceAuth.ExpiresIn) * pollIntervalUnit)
+	}
+	params := url.Values{
+		"grant_type":  {deviceCodeGrantType},
+		"device_code": {deviceAuth.DeviceCode},
+		"client_id":   {config.ClientID},
+	}
+	if config.ClientSecret != "" {
+		params.Set("client_secret", config.ClientSecret)
+	}
+	for {
+		delay := time.Duration(interval) * pollIntervalUnit
+		if !deadline.IsZero() {
+			if remaining := deadline.Sub(clock.Now()); remaining < delay {
+				delay = remaining
+			}
+		}
+		if err := internal.Sleep(ctx, clock, delay); err != nil {
+			return nil, err
+		}
+		if !deadline.IsZero() && !clock.Now().Before(deadline) {
+			return nil, &AuthorizationError{Code: "expired_token"}
+		}
+
+		tokenJSON := new(deviceTokenJSON)
+		err := postForm(ctx, config.Endpoint.TokenURL, params, tokenJSON)
+		if authErr, ok := err.(*AuthorizationError); ok {
+			switch authErr.Code {
+			case "authorization_pending":
+				continue
+			case "slow_down":
+				interval += slowDownIncrement
+				continue
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		if tokenJSON.AccessToken == "" {
+			return nil, errors.New("oauth2: server response missing access_token")
+		}
+		return tokenJSON.token(), nil
+	}
+}
+
+// deviceTokenJSON is a token endpoint response.
+type deviceTokenJSON struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+	raw          map[string]interface{}
+}
+
+func (t *deviceTokenJSON) UnmarshalJSON(data []byte) error {
+	type fields deviceTokenJSON
+	if err := json.Unmarshal(data, (*fields)(t)); err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &t.raw)
+}
+
+func (t *deviceTokenJSON) token() *oauth2.Token {
+	token := &oauth2.Token{
+		AccessToken:  t.AccessToken,
+		TokenType:    t.TokenType,
+		RefreshToken: t.RefreshToken,
+	}
+	if t.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(t.ExpiresIn) * time.Second)
+	}
+	return token.WithExtra(t.raw)
+}
+
+// errorJSON is an OAuth2 error response.
+type errorJSON struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+	URI         string `json:"error_uri"`
+}
+
+// postForm POSTs the form params to the endpoint URL using the ctx
+// oauth2.HTTPClient, if any, and decodes the JSON response into v. OAuth2
+// error responses are returned as an AuthorizationError.
+func postForm(ctx context.Context, endpointURL string, params url.Values, v interface{}) error {
+	req, err := http.NewRequest("POST", endpointURL, strings.NewReader(params.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	resp, err := internal.ContextClient(ctx).Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	// some providers (e.g. GitHub) report errors with a 200 status
+	errResp := new(errorJSON)
+	if json.Unmarshal(body, errResp) == nil && errResp.Code != "" {
+		return &AuthorizationError{Code: errResp.Code, Description: errResp.Description, URI: errResp.URI}
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("oauth2: %s responded with %s", endpointURL, resp.Status)
+	}
+	return json.Unmarshal(body, v)
+}
@@ -0,0 +1,262 @@
+package oauth2
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/gologintest"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func init() {
+	// poll in milliseconds rather than seconds
+	pollIntervalUnit = time.Millisecond
+}
+
+const testDeviceAuthJSON = `{
+	"device_code": "GmRhmhcxhwAzkoEqiMEg_DnyEysNkuNhszIySk9eS",
+	"user_code": "WDJB-MJHT",
+	"verification_uri": "https://example.com/device",
+	"verification_uri_complete": "https://example.com/device?user_code=WDJB-MJHT",
+	"expires_in": 1800,
+	"interval": 5
+}`
+
+// newDeviceTokenServer returns a token endpoint server which responds with
+// the given responses in order, repeating the last one. It records the times
+// at which it was polled.
+func newDeviceTokenServer(t *testing.T, responses []string, polls *[]time.Time) *httptest.Server {
+	return NewTestServerFunc(func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "POST", req.Method)
+		assert.Equal(t, deviceCodeGrantType, req.FormValue("grant_type"))
+		assert.Equal(t, "GmRhmhcxhwAzkoEqiMEg_DnyEysNkuNhszIySk9eS", req.FormValue("device_code"))
+		i := len(*polls)
+		*polls = append(*polls, time.Now())
+		if i >= len(responses) {
+			i = len(responses) - 1
+		}
+		w.Header().Set(contentType, jsonContentType)
+		if strings.Contains(responses[i], `"error"`) {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+		w.Write([]byte(responses[i]))
+	})
+}
+
+func testDeviceAuthorization() *DeviceAuthorization {
+	return &DeviceAuthorization{
+		DeviceCode: "GmRhmhcxhwAzkoEqiMEg_DnyEysNkuNhszIySk9eS",
+		UserCode:   "WDJB-MJHT",
+		ExpiresIn:  1800,
+		Interval:   5,
+	}
+}
+
+func TestDeviceAuthHandler(t *testing.T) {
+	server := NewTestServerFunc(func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "POST", req.Method)
+		assert.Equal(t, "client_id", req.FormValue("client_id"))
+		assert.Equal(t, "read:user repo", req.FormValue("scope"))
+		w.Header().Set(contentType, jsonContentType)
+		w.Write([]byte(testDeviceAuthJSON))
+	})
+	defer server.Close()
+	config := &oauth2.Config{
+		ClientID: "client_id",
+		Scopes:   []string{"read:user"},
+	}
+	success := func(w http.ResponseWriter, req *http.Request) {
+		deviceAuth, err := DeviceAuthorizationFromContext(req.Context())
+		assert.Nil(t, err)
+		assert.Equal(t, "GmRhmhcxhwAzkoEqiMEg_DnyEysNkuNhszIySk9eS", deviceAuth.DeviceCode)
+		assert.Equal(t, "WDJB-MJHT", deviceAuth.UserCode)
+		assert.Equal(t, "https://example.com/device", deviceAuth.VerificationURI)
+		assert.Equal(t, 1800, deviceAuth.ExpiresIn)
+		assert.Equal(t, 5, deviceAuth.Interval)
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// DeviceAuthHandler assert that:
+	// - the client ID and ctx scopes are POSTed to the device endpoint
+	// - the DeviceAuthorization is added to the ctx
+	handler := DeviceAuthHandler(config, server.URL, http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/", nil)
+	ctx := WithScopes(context.Background(), "read:user", "repo")
+	handler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestDeviceAuthHandler_ErrorResponse(t *testing.T) {
+	server := NewTestServerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set(contentType, jsonContentType)
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "invalid_client"}`))
+	})
+	defer server.Close()
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.Equal(t, &AuthorizationError{Code: "invalid_client"}, err)
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	handler := DeviceAuthHandler(&oauth2.Config{}, server.URL, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/", nil)
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestDeviceAuthJSONHandler(t *testing.T) {
+	handler := DeviceAuthJSONHandler(testutils.AssertFailureNotCalled(t))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/", nil)
+	ctx := WithDeviceAuthorization(context.Background(), &DeviceAuthorization{
+		DeviceCode:      "device_code",
+		UserCode:        "WDJB-MJHT",
+		VerificationURI: "https://example.com/device",
+		ExpiresIn:       1800,
+	})
+	handler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, jsonContentType, w.HeaderMap.Get(contentType))
+	assert.JSONEq(t, `{"device_code":"device_code","user_code":"WDJB-MJHT","verification_uri":"https://example.com/device","expires_in":1800}`, w.Body.String())
+}
+
+func TestDevicePollHandler(t *testing.T) {
+	var polls []time.Time
+	server := newDeviceTokenServer(t, []string{
+		`{"error": "authorization_pending"}`,
+		`{"error": "slow_down"}`,
+		`{"error": "authorization_pending"}`,
+		`{"access_token": "2YotnFZFEjr1zCsicMWpAA", "token_type": "bearer", "refresh_token": "tGzv3JOkF0XG5Qx2TlKWIA", "expires_in": 3600, "scope": "repo"}`,
+	}, &polls)
+	defer server.Close()
+	config := &oauth2.Config{
+		ClientID: "client_id",
+		Endpoint: oauth2.Endpoint{
+			TokenURL: server.URL,
+		},
+	}
+	success := func(w http.ResponseWriter, req *http.Request) {
+		token, err := TokenFromContext(req.Context())
+		assert.Nil(t, err)
+		assert.Equal(t, "2YotnFZFEjr1zCsicMWpAA", token.AccessToken)
+		assert.Equal(t, "bearer", token.TokenType)
+		assert.Equal(t, "tGzv3JOkF0XG5Qx2TlKWIA", token.RefreshToken)
+		assert.False(t, token.Expiry.IsZero())
+		assert.Equal(t, "repo", token.Extra("scope"))
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// DevicePollHandler assert that:
+	// - polling continues while authorization is pending
+	// - the polling interval increases by 5 after slow_down
+	// - the Token is added to the ctx
+	// - polls wait with the ctx clock
+	clock := gologintest.NewFakeClock(time.Unix(1500000000, 0))
+	handler := DevicePollHandler(config, http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/", nil)
+	ctx := WithDeviceAuthorization(gologin.WithClock(context.Background(), clock), testDeviceAuthorization())
+	handler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+	assert.Len(t, polls, 4)
+	// interval was 5 units before slow_down and 10 units after
+	sleeps, slept := clock.Slept()
+	assert.Equal(t, 4, sleeps)
+	assert.Equal(t, 30*pollIntervalUnit, slept)
+}
+
+func TestDevicePollHandler_DeviceCodeParam(t *testing.T) {
+	var polls []time.Time
+	server := newDeviceTokenServer(t, []string{
+		`{"access_token": "2YotnFZFEjr1zCsicMWpAA", "token_type": "bearer"}`,
+	}, &polls)
+	defer server.Close()
+	config := &oauth2.Config{
+		Endpoint: oauth2.Endpoint{
+			TokenURL: server.URL,
+		},
+	}
+	success := func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// DevicePollHandler without a ctx DeviceAuthorization, assert that:
+	// - the device_code request parameter is used
+	handler := DevicePollHandler(config, http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/?device_code=GmRhmhcxhwAzkoEqiMEg_DnyEysNkuNhszIySk9eS", nil)
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestDevicePollHandler_Errors(t *testing.T) {
+	cases := []struct {
+		response string
+		err      error
+	}{
+		{`{"error": "access_denied"}`, &AuthorizationError{Code: "access_denied"}},
+		{`{"error": "expired_token"}`, &AuthorizationError{Code: "expired_token"}},
+		// the device code lifetime passes while authorization is pending
+		{`{"error": "authorization_pending"}`, &AuthorizationError{Code: "expired_token"}},
+	}
+	for _, c := range cases {
+		var polls []time.Time
+		server := newDeviceTokenServer(t, []string{c.response}, &polls)
+		config := &oauth2.Config{
+			Endpoint: oauth2.Endpoint{
+				TokenURL: server.URL,
+			},
+		}
+		success := testutils.AssertSuccessNotCalled(t)
+		failure := func(w http.ResponseWriter, req *http.Request) {
+			err := gologin.ErrorFromContext(req.Context())
+			if assert.NotNil(t, err) {
+				assert.Equal(t, c.err, err)
+			}
+			fmt.Fprintf(w, "failure handler called")
+		}
+
+		handler := DevicePollHandler(config, success, http.HandlerFunc(failure))
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/", nil)
+		deviceAuth := testDeviceAuthorization()
+		deviceAuth.ExpiresIn = 50
+		ctx := WithDeviceAuthorization(context.Background(), deviceAuth)
+		handler.ServeHTTP(w, req.WithContext(ctx))
+		assert.Equal(t, "failure handler called", w.Body.String())
+		server.Close()
+	}
+}
+
+func TestDevicePollHandler_MissingDeviceCode(t *testing.T) {
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.Equal(t, ErrMissingDeviceCode, err)
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	handler := DevicePollHandler(&oauth2.Config{}, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/", nil)
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
@@ -0,0 +1,41 @@
+package oauth2
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// AuthorizationError is an OAuth2 error response sent to the redirection URI
+// when the resource owner or provider denies an authorization request.
+// https://tools.ietf.org/html/rfc6749#section-4.1.2.1
+type AuthorizationError struct {
+	// Code is the "error" parameter (e.g. access_denied).
+	Code string
+	// Description is the "error_description" parameter, if any.
+	Description string
+	// URI is the "error_uri" parameter, if any.
+	URI string
+}
+
+func (e *AuthorizationError) Error() string {
+	if e.Description != "" {
+		return fmt.Sprintf("oauth2: authorization failed with %s: %s", e.Code, e.Description)
+	}
+	return fmt.Sprintf("oauth2: authorization failed with %s", e.Code)
+}
+
+// IsAccessDenied returns true if the error is an AuthorizationError because
+// the resource owner (user) denied the authorization request.
+func IsAccessDenied(err error) bool {
+	authErr, ok := err.(*AuthorizationError)
+	return ok && authErr.Code == "access_denied"
+}
+
+// callbackStatusCode returns the failure status code of a callback parse
+// error. Users who deny authorization are an expected outcome, not an error.
+func callbackStatusCode(err error) int {
+	if IsAccessDenied(err) {
+		return http.StatusOK
+	}
+	return http.StatusBadRequest
+}
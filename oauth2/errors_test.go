@@ -0,0 +1,22 @@
+package oauth2
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthorizationError(t *testing.T) {
+	err := &AuthorizationError{Code: "access_denied", Description: "Permissions error"}
+	assert.Equal(t, "oauth2: authorization failed with access_denied: Permissions error", err.Error())
+	err = &AuthorizationError{Code: "server_error"}
+	assert.Equal(t, "oauth2: authorization failed with server_error", err.Error())
+}
+
+func TestIsAccessDenied(t *testing.T) {
+	assert.True(t, IsAccessDenied(&AuthorizationError{Code: "access_denied"}))
+	assert.False(t, IsAccessDenied(&AuthorizationError{Code: "invalid_scope"}))
+	assert.False(t, IsAccessDenied(fmt.Errorf("access_denied")))
+	assert.False(t, IsAccessDenied(nil))
+}
@@ -0,0 +1,307 @@
+package oauth2
+
+import (
+	"container/list"
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/internal"
+)
+
+const (
+	defaultIdempotencyTTL  = time.Minute
+	defaultIdempotencyWait = 5 * time.Second
+	defaultCodeStoreSize   = 1000
+	// idempotencyPollInterval is how often duplicates check for the outcome
+	// of an in-progress callback
+	idempotencyPollInterval = 50 * time.Millisecond
+)
+
+// CodeRecord is the outcome of a callback for an authorization code.
+type CodeRecord struct {
+	// Completed is true once the callback succeeded, false while it is in
+	// progress.
+	Completed bool
+	// StatusCode is the status of the callback response (e.g. 302).
+	StatusCode int
+	// Location is the Location header of the callback response, if any.
+	Location string
+	// Binding is a hash of the callback's state and state cookie, which
+	// duplicates must match to have the redirect replayed. Empty if the
+	// callback had no state cookie.
+	Binding string
+}
+
+// CodeStore records recently processed authorization codes by key (a hash
+// of the code, never the code itself). Implementations must be safe for
+// concurrent use, since browsers may send duplicate callbacks concurrently.
+type CodeStore interface {
+	// Claim records the key as in progress for the ttl and returns true. If
+	// the key is already recorded, it returns its CodeRecord and false.
+	Claim(ctx context.Context, key string, ttl time.Duration) (CodeRecord, bool, error)
+	// Complete records the CodeRecord of a claimed key for the ttl.
+	Complete(ctx context.Context, key string, record CodeRecord, ttl time.Duration) error
+	// Release removes a claimed key so the code may be processed again.
+	Release(ctx context.Context, key string) error
+}
+
+// IdempotencyConfig configures IdempotentCallbackHandler.
+type IdempotencyConfig struct {
+	// Store records processed codes. Defaults to a MemoryCodeStore of 1000
+	// codes, for a single server.
+	Store CodeStore
+	// TTL is how long processed codes are remembered. Defaults to 1 minute.
+	TTL time.Duration
+	// Wait is how long duplicates wait for the outcome of an in-progress
+	// callback. Defaults to 5 seconds.
+	Wait time.Duration
+	// Completed, if set, handles duplicates instead of replaying the
+	// original redirect. Defaults to replaying the redirect to duplicates
+	// from the same browser, or a 409 "login already completed" response.
+	Completed http.Handler
+	// CookieName is the name of the state cookie which binds duplicates to
+	// the browser of the first callback. Defaults to the
+	// gologin.DefaultCookieConfig Name.
+	CookieName string
+}
+
+// IdempotentCallbackHandler guards a callback handler chain (e.g. a
+// StateHandler and CallbackHandler) from callbacks which are sent twice with
+// the same authorization code, as browsers and link-scanning proxies
+// sometimes do. The second token exchange would fail at the provider and
+// show an error to a user who just logged in.
+//
+// The first callback for a code is handled by the callback handler. Until
+// the TTL, duplicates never reach it: they wait for the first callback and
+// then call the config Completed handler. A duplicate with the same state
+// and state cookie as the first callback (i.e. from the same browser) has the
+// redirect (e.g. to the page the success handler chose) replayed instead.
+// Other duplicates, such as a link scanner or proxy replaying the callback
+// URL, never see the redirect, which may carry a token (e.g. a jwtlogin
+// fragment), or response headers such as session cookies. Callbacks which
+// fail (status 400 or above) are forgotten, so their duplicates are handled
+// as usual. Store errors call the failure handler with a 500.
+func IdempotentCallbackHandler(config IdempotencyConfig, callback, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	if config.Store == nil {
+		config.Store = NewMemoryCodeStore(defaultCodeStoreSize)
+	}
+	if config.TTL <= 0 {
+		config.TTL = defaultIdempotencyTTL
+	}
+	if config.Wait <= 0 {
+		config.Wait = defaultIdempotencyWait
+	}
+	if config.CookieName == "" {
+		config.CookieName = gologin.DefaultCookieConfig.Name
+	}
+	// replay redirects unless duplicates have a Completed handler
+	replay := config.Completed == nil
+	if config.Completed == nil {
+		config.Completed = http.HandlerFunc(completedHandler)
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		authCode, state, err := parseCallback(req)
+		if err != nil {
+			// the callback handler reports invalid callbacks
+			callback.ServeHTTP(w, req)
+			return
+		}
+		key := internal.TokenCacheKey("code", authCode)
+		binding := callbackBinding(req, config.CookieName, state)
+		clock := gologin.ClockFromContext(ctx)
+		deadline := clock.Now().Add(config.Wait)
+		for {
+			record, claimed, err := config.Store.Claim(ctx, key, config.TTL)
+			if err != nil {
+				ctx = gologin.WithError(ctx, err)
+				ctx = gologin.WithStatusCode(ctx, http.StatusInternalServerError)
+				failure.ServeHTTP(w, req.WithContext(ctx))
+				return
+			}
+			if claimed {
+				break
+			}
+			if record.Completed && replay && isRedirect(record) && sameBinding(record.Binding, binding) {
+				http.Redirect(w, req, record.Location, record.StatusCode)
+				return
+			}
+			if record.Completed {
+				config.Completed.ServeHTTP(w, req)
+				return
+			}
+			if !clock.Now().Before(deadline) {
+				config.Completed.ServeHTTP(w, req)
+				return
+			}
+			if err := internal.Sleep(ctx, clock, idempotencyPollInterval); err != nil {
+				// the duplicate was canceled
+				return
+			}
+		}
+
+		recorder := &statusRecorder{ResponseWriter: w}
+		callback.ServeHTTP(recorder, req)
+		status := recorder.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		if status >= http.StatusBadRequest {
+			// forget failed callbacks, so duplicates are handled as usual
+			config.Store.Release(ctx, key)
+			return
+		}
+		// the response was written, so store errors are ignored (duplicates
+		// wait for the outcome until the claim expires)
+		config.Store.Complete(ctx, key, CodeRecord{
+			Completed:  true,
+			StatusCode: status,
+			Location:   w.Header().Get("Location"),
+			Binding:    binding,
+		}, config.TTL)
+	}
+	return gologin.BodyHandler(callbackBodyConfig, http.HandlerFunc(fn), failure)
+}
+
+// callbackBinding returns a hash of the callback state and state cookie, or
+// "" if the callback has no state cookie to bind duplicates to a browser.
+func callbackBinding(req *http.Request, cookieName, state string) string {
+	cookie, err := req.Cookie(cookieName)
+	if err != nil || cookie.Value == "" {
+		return ""
+	}
+	return internal.TokenCacheKey("binding", state+"\x00"+cookie.Value)
+}
+
+// sameBinding returns true if a duplicate's binding matches the binding of
+// the first callback. Callbacks without a binding never match.
+func sameBinding(recorded, binding string) bool {
+	return recorded != "" && internal.EqualSecrets(recorded, binding)
+}
+
+// isRedirect returns true if the CodeRecord is of a redirect.
+func isRedirect(record CodeRecord) bool {
+	return record.Location != "" && record.StatusCode >= 300 && record.StatusCode < 400
+}
+
+// completedHandler responds to duplicate callbacks with a 409.
+func completedHandler(w http.ResponseWriter, req *http.Request) {
+	http.Error(w, "login already completed", http.StatusConflict)
+}
+
+// statusRecorder records the status of a response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(p []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(p)
+}
+
+// MemoryCodeStore is an in-memory CodeStore for a single server, which
+// evicts the least recently used code once it is full.
+type MemoryCodeStore struct {
+	size    int
+	mu      sync.Mutex
+	entries *list.List
+	items   map[string]*list.Element
+}
+
+// codeEntry is a MemoryCodeStore CodeRecord and its expiry.
+type codeEntry struct {
+	key    string
+	record CodeRecord
+	expiry time.Time
+}
+
+// NewMemoryCodeStore returns a new MemoryCodeStore which holds up to size
+// codes. Panics if size is not positive.
+func NewMemoryCodeStore(size int) *MemoryCodeStore {
+	if size <= 0 {
+		panic("oauth2: MemoryCodeStore size must be positive")
+	}
+	return &MemoryCodeStore{
+		size:    size,
+		entries: list.New(),
+		items:   make(map[string]*list.Element),
+	}
+}
+
+// Claim records the key as in progress, or returns the CodeRecord of the
+// key if it is recorded and unexpired.
+func (s *MemoryCodeStore) Claim(ctx context.Context, key string, ttl time.Duration) (CodeRecord, bool, error) {
+	now := gologin.ClockFromContext(ctx).Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if elem, ok := s.items[key]; ok {
+		entry := elem.Value.(*codeEntry)
+		if now.Before(entry.expiry) {
+			s.entries.MoveToFront(elem)
+			return entry.record, false, nil
+		}
+		s.remove(elem)
+	}
+	s.set(key, CodeRecord{}, now.Add(ttl))
+	return CodeRecord{}, true, nil
+}
+
+// Complete records the CodeRecord of the key.
+func (s *MemoryCodeStore) Complete(ctx context.Context, key string, record CodeRecord, ttl time.Duration) error {
+	now := gologin.ClockFromContext(ctx).Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.set(key, record, now.Add(ttl))
+	return nil
+}
+
+// Release removes the key.
+func (s *MemoryCodeStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if elem, ok := s.items[key]; ok {
+		s.remove(elem)
+	}
+	return nil
+}
+
+// Len returns the number of codes, including expired codes which have not
+// been evicted yet.
+func (s *MemoryCodeStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.entries.Len()
+}
+
+// set stores the key, evicting the least recently used key if the store is
+// full. The caller must hold the lock.
+func (s *MemoryCodeStore) set(key string, record CodeRecord, expiry time.Time) {
+	if elem, ok := s.items[key]; ok {
+		s.remove(elem)
+	}
+	s.items[key] = s.entries.PushFront(&codeEntry{key: key, record: record, expiry: expiry})
+	for s.entries.Len() > s.size {
+		s.remove(s.entries.Back())
+	}
+}
+
+func (s *MemoryCodeStore) remove(elem *list.Element) {
+	s.entries.Remove(elem)
+	delete(s.items, elem.Value.(*codeEntry).key)
+}
@@ -0,0 +1,339 @@
+package oauth2
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/gologintest"
+	"github.com/dghubble/gologin/internal"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+const testTokenJSON = `{"access_token":"2YotnFZFEjr1zCsicMWpAA","token_type":"example"}`
+
+// newIdempotencyTestCallback returns a CallbackHandler whose success handler
+// sets a session cookie and redirects to /dashboard.
+func newIdempotencyTestCallback(t *testing.T, tokenURL string) http.Handler {
+	config := &oauth2.Config{
+		ClientID: "client_id",
+		Endpoint: oauth2.Endpoint{TokenURL: tokenURL},
+	}
+	success := func(w http.ResponseWriter, req *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "session_val"})
+		http.Redirect(w, req, "/dashboard", http.StatusFound)
+	}
+	return CallbackHandler(config, http.HandlerFunc(success), testutils.AssertFailureNotCalled(t))
+}
+
+// newCallbackRequest returns a callback request for the code from the
+// browser with the state cookie.
+func newCallbackRequest(code string) *http.Request {
+	req := newCallbackRequestWithoutCookie(code)
+	req.AddCookie(&http.Cookie{Name: gologin.DefaultCookieConfig.Name, Value: "state_val"})
+	return req
+}
+
+// newCallbackRequestWithoutCookie returns a callback request for the code
+// without a state cookie, as a link scanner replaying the URL would send.
+func newCallbackRequestWithoutCookie(code string) *http.Request {
+	req := httptest.NewRequest("GET", "/callback?code="+code+"&state=state_val", nil)
+	return req.WithContext(WithState(req.Context(), "state_val"))
+}
+
+// recordingCodeStore is a MemoryCodeStore which records the keys it was
+// given.
+type recordingCodeStore struct {
+	*MemoryCodeStore
+	mu   sync.Mutex
+	keys []string
+}
+
+func (s *recordingCodeStore) Claim(ctx context.Context, key string, ttl time.Duration) (CodeRecord, bool, error) {
+	s.mu.Lock()
+	s.keys = append(s.keys, key)
+	s.mu.Unlock()
+	return s.MemoryCodeStore.Claim(ctx, key, ttl)
+}
+
+func TestIdempotentCallbackHandler_Sequential(t *testing.T) {
+	var exchanges int32
+	server := NewTestServerFunc(func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&exchanges, 1)
+		w.Header().Set(contentType, jsonContentType)
+		w.Write([]byte(testTokenJSON))
+	})
+	defer server.Close()
+	store := &recordingCodeStore{MemoryCodeStore: NewMemoryCodeStore(10)}
+	handler := IdempotentCallbackHandler(IdempotencyConfig{Store: store}, newIdempotencyTestCallback(t, server.URL), testutils.AssertFailureNotCalled(t))
+
+	// IdempotentCallbackHandler with the same code twice, assert that:
+	// - the first callback exchanges the code and logs in
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, newCallbackRequest("any_code"))
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "/dashboard", w.HeaderMap.Get("Location"))
+	assert.Len(t, w.Result().Cookies(), 1)
+
+	// - the duplicate replays the redirect, without the session cookie
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, newCallbackRequest("any_code"))
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "/dashboard", w.HeaderMap.Get("Location"))
+	assert.Empty(t, w.Result().Cookies())
+
+	// - only one token exchange reaches the provider
+	assert.Equal(t, int32(1), atomic.LoadInt32(&exchanges))
+
+	// - the store key is a hash of the code, never the code itself
+	if assert.Len(t, store.keys, 2) {
+		assert.Equal(t, internal.TokenCacheKey("code", "any_code"), store.keys[0])
+		assert.False(t, strings.Contains(store.keys[0], "any_code"))
+	}
+
+	// - other codes are exchanged
+	handler.ServeHTTP(httptest.NewRecorder(), newCallbackRequest("other_code"))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&exchanges))
+}
+
+func TestIdempotentCallbackHandler_OtherClient(t *testing.T) {
+	var exchanges int32
+	server := NewTestServerFunc(func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&exchanges, 1)
+		w.Header().Set(contentType, jsonContentType)
+		w.Write([]byte(testTokenJSON))
+	})
+	defer server.Close()
+	handler := IdempotentCallbackHandler(IdempotencyConfig{}, newIdempotencyTestCallback(t, server.URL), testutils.AssertFailureNotCalled(t))
+	handler.ServeHTTP(httptest.NewRecorder(), newCallbackRequest("any_code"))
+
+	// IdempotentCallbackHandler with the code replayed by another client,
+	// assert that:
+	// - a duplicate without the state cookie gets no Location or cookies
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, newCallbackRequestWithoutCookie("any_code"))
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Empty(t, w.HeaderMap.Get("Location"))
+	assert.Empty(t, w.Result().Cookies())
+
+	// - a duplicate with another state cookie gets no Location
+	req := newCallbackRequestWithoutCookie("any_code")
+	req.AddCookie(&http.Cookie{Name: gologin.DefaultCookieConfig.Name, Value: "other_state"})
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Empty(t, w.HeaderMap.Get("Location"))
+
+	// - a duplicate with another state param gets no Location
+	req = httptest.NewRequest("GET", "/callback?code=any_code&state=other_state", nil)
+	req.AddCookie(&http.Cookie{Name: gologin.DefaultCookieConfig.Name, Value: "state_val"})
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Empty(t, w.HeaderMap.Get("Location"))
+
+	// - callbacks without a state cookie never have their redirect replayed
+	handler.ServeHTTP(httptest.NewRecorder(), newCallbackRequestWithoutCookie("other_code"))
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, newCallbackRequestWithoutCookie("other_code"))
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Empty(t, w.HeaderMap.Get("Location"))
+
+	// - none of the duplicates reach the provider
+	assert.Equal(t, int32(2), atomic.LoadInt32(&exchanges))
+}
+
+func TestIdempotentCallbackHandler_Concurrent(t *testing.T) {
+	var exchanges int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+	server := NewTestServerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if atomic.AddInt32(&exchanges, 1) == 1 {
+			close(started)
+		}
+		<-release
+		w.Header().Set(contentType, jsonContentType)
+		w.Write([]byte(testTokenJSON))
+	})
+	defer server.Close()
+	handler := IdempotentCallbackHandler(IdempotencyConfig{}, newIdempotencyTestCallback(t, server.URL), testutils.AssertFailureNotCalled(t))
+
+	// IdempotentCallbackHandler with concurrent callbacks for a code, assert
+	// that:
+	// - duplicates wait for the in-progress callback and replay its redirect
+	// - only one token exchange reaches the provider
+	const hits = 5
+	codes := make([]int, hits)
+	locations := make([]string, hits)
+	var wg sync.WaitGroup
+	for i := 0; i < hits; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, newCallbackRequest("any_code"))
+			codes[i], locations[i] = w.Code, w.HeaderMap.Get("Location")
+		}(i)
+	}
+	<-started
+	close(release)
+	wg.Wait()
+	for i := 0; i < hits; i++ {
+		assert.Equal(t, http.StatusFound, codes[i])
+		assert.Equal(t, "/dashboard", locations[i])
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&exchanges))
+}
+
+func TestIdempotentCallbackHandler_Completed(t *testing.T) {
+	server := NewAccessTokenServer(t, testTokenJSON)
+	defer server.Close()
+	completed := func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(w, "login already completed")
+	}
+	config := IdempotencyConfig{Completed: http.HandlerFunc(completed)}
+	handler := IdempotentCallbackHandler(config, newIdempotencyTestCallback(t, server.URL), testutils.AssertFailureNotCalled(t))
+
+	// IdempotentCallbackHandler with a Completed handler, assert that:
+	// - the duplicate calls the Completed handler instead of a replay
+	handler.ServeHTTP(httptest.NewRecorder(), newCallbackRequest("any_code"))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, newCallbackRequest("any_code"))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "login already completed", w.Body.String())
+
+	// - callbacks which do not redirect respond 409 by default
+	page := func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(w, "welcome")
+	}
+	handler = IdempotentCallbackHandler(IdempotencyConfig{}, http.HandlerFunc(page), nil)
+	handler.ServeHTTP(httptest.NewRecorder(), newCallbackRequest("any_code"))
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, newCallbackRequest("any_code"))
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, "login already completed\n", w.Body.String())
+}
+
+func TestIdempotentCallbackHandler_FailedCallback(t *testing.T) {
+	var exchanges int32
+	server := NewTestServerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set(contentType, jsonContentType)
+		if atomic.AddInt32(&exchanges, 1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(testTokenJSON))
+	})
+	defer server.Close()
+	config := &oauth2.Config{
+		ClientID: "client_id",
+		// avoid retrying the failed exchange with another AuthStyle
+		Endpoint: oauth2.Endpoint{TokenURL: server.URL, AuthStyle: oauth2.AuthStyleInParams},
+	}
+	success := func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(gologin.StatusCodeFromContext(req.Context()))
+	}
+	callback := CallbackHandler(config, http.HandlerFunc(success), http.HandlerFunc(failure))
+	handler := IdempotentCallbackHandler(IdempotencyConfig{}, callback, testutils.AssertFailureNotCalled(t))
+
+	// IdempotentCallbackHandler when the first callback fails, assert that:
+	// - the code is forgotten, so the duplicate is handled as usual
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, newCallbackRequest("any_code"))
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, newCallbackRequest("any_code"))
+	assert.Equal(t, "success handler called", w.Body.String())
+	assert.Equal(t, int32(2), atomic.LoadInt32(&exchanges))
+}
+
+// errCodeStore is a CodeStore which fails.
+type errCodeStore struct{}
+
+func (errCodeStore) Claim(ctx context.Context, key string, ttl time.Duration) (CodeRecord, bool, error) {
+	return CodeRecord{}, false, errors.New("store unavailable")
+}
+
+func (errCodeStore) Complete(ctx context.Context, key string, record CodeRecord, ttl time.Duration) error {
+	return nil
+}
+
+func (errCodeStore) Release(ctx context.Context, key string) error {
+	return nil
+}
+
+func TestIdempotentCallbackHandler_Errors(t *testing.T) {
+	// IdempotentCallbackHandler with a failing store, assert that:
+	// - the failure handler is called with a 500
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		assert.Equal(t, "store unavailable", gologin.ErrorFromContext(ctx).Error())
+		w.WriteHeader(gologin.StatusCodeFromContext(ctx))
+	}
+	handler := IdempotentCallbackHandler(IdempotencyConfig{Store: errCodeStore{}}, testutils.AssertSuccessNotCalled(t), http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, newCallbackRequest("any_code"))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+
+	// - callbacks without a code are handled by the callback handler
+	var called bool
+	callback := func(w http.ResponseWriter, req *http.Request) {
+		called = true
+	}
+	handler = IdempotentCallbackHandler(IdempotencyConfig{Store: errCodeStore{}}, http.HandlerFunc(callback), testutils.AssertFailureNotCalled(t))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/callback?state=state_val", nil))
+	assert.True(t, called)
+}
+
+func TestMemoryCodeStore(t *testing.T) {
+	clock := gologintest.NewFakeClock(time.Unix(1500000000, 0))
+	ctx := gologin.WithClock(context.Background(), clock)
+	store := NewMemoryCodeStore(2)
+
+	// MemoryCodeStore, assert that:
+	// - keys are claimed once
+	_, claimed, err := store.Claim(ctx, "a", time.Minute)
+	assert.Nil(t, err)
+	assert.True(t, claimed)
+	record, claimed, _ := store.Claim(ctx, "a", time.Minute)
+	assert.False(t, claimed)
+	assert.False(t, record.Completed)
+
+	// - completed records are returned
+	completed := CodeRecord{Completed: true, StatusCode: http.StatusFound, Location: "/dashboard"}
+	assert.Nil(t, store.Complete(ctx, "a", completed, time.Minute))
+	record, claimed, _ = store.Claim(ctx, "a", time.Minute)
+	assert.False(t, claimed)
+	assert.Equal(t, completed, record)
+
+	// - released and expired keys may be claimed again
+	assert.Nil(t, store.Release(ctx, "a"))
+	_, claimed, _ = store.Claim(ctx, "a", time.Minute)
+	assert.True(t, claimed)
+	clock.Advance(time.Minute)
+	_, claimed, _ = store.Claim(ctx, "a", time.Minute)
+	assert.True(t, claimed)
+
+	// - the least recently used key is evicted once full
+	store.Claim(ctx, "b", time.Minute)
+	store.Claim(ctx, "a", time.Minute)
+	store.Claim(ctx, "c", time.Minute)
+	assert.Equal(t, 2, store.Len())
+	_, claimed, _ = store.Claim(ctx, "b", time.Minute)
+	assert.True(t, claimed)
+
+	assert.Panics(t, func() { NewMemoryCodeStore(0) })
+}
@@ -0,0 +1,249 @@
+package oauth2
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/internal"
+	"golang.org/x/oauth2"
+)
+
+// Errors which may occur when introspecting bearer tokens.
+var (
+	ErrMissingBearerToken = errors.New("oauth2: missing bearer token")
+	ErrInactiveToken      = errors.New("oauth2: token is not active")
+	ErrTokenExpired       = errors.New("oauth2: token is expired")
+	ErrAudienceMismatch   = errors.New("oauth2: token audience does not include the required audience")
+)
+
+const (
+	// maxIntrospectionBodySize limits introspection responses read
+	maxIntrospectionBodySize = 1 << 20
+	defaultIntrospectionTTL  = 30 * time.Second
+)
+
+// IntrospectionConfig configures IntrospectionHandler.
+type IntrospectionConfig struct {
+	// URL is the authorization server's introspection endpoint. Required.
+	URL string
+	// ClientID and ClientSecret authenticate introspection requests with
+	// HTTP Basic authentication, if set.
+	ClientID     string
+	ClientSecret string
+	// Field is the form body field of the token when requests have no
+	// Authorization bearer token (e.g. "access_token"), if set.
+	Field string
+	// Audience, if set, must be one of the token's "aud" values.
+	Audience string
+	// Cache, if set, caches active introspection results keyed by a SHA-256
+	// hash of the token, so repeated requests skip the endpoint.
+	Cache gologin.Cache
+	// CacheTTL is how long active results are cached, at most until the
+	// token's "exp". Defaults to 30 seconds.
+	CacheTTL time.Duration
+}
+
+// IntrospectionClaims are the claims of an introspected token.
+// https://tools.ietf.org/html/rfc7662#section-2.2
+type IntrospectionClaims struct {
+	Active    bool                  `json:"active"`
+	Subject   string                `json:"sub,omitempty"`
+	Scope     string                `json:"scope,omitempty"`
+	ClientID  string                `json:"client_id,omitempty"`
+	Username  string                `json:"username,omitempty"`
+	TokenType string                `json:"token_type,omitempty"`
+	Expiry    int64                 `json:"exp,omitempty"`
+	IssuedAt  int64                 `json:"iat,omitempty"`
+	Issuer    string                `json:"iss,omitempty"`
+	Audience  IntrospectionAudience `json:"aud,omitempty"`
+}
+
+// Scopes returns the space-separated scopes of the Scope claim.
+func (c *IntrospectionClaims) Scopes() []string {
+	return strings.Fields(c.Scope)
+}
+
+// IntrospectionAudience is the "aud" claim, which may be a string or an array
+// of strings.
+type IntrospectionAudience []string
+
+// UnmarshalJSON decodes a string or an array of strings.
+func (a *IntrospectionAudience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = IntrospectionAudience{single}
+		return nil
+	}
+	var values []string
+	if err := json.Unmarshal(data, &values); err != nil {
+		return err
+	}
+	*a = values
+	return nil
+}
+
+// contains returns true if the audience includes the value.
+func (a IntrospectionAudience) contains(value string) bool {
+	for _, aud := range a {
+		if aud == value {
+			return true
+		}
+	}
+	return false
+}
+
+// IntrospectionHandler protects API routes with bearer tokens introspected
+// at an authorization server (RFC 7662). The token is read from the
+// Authorization header (or the config Field) and POSTed to the config URL. If
+// the token is active, unexpired, and (if the config has an Audience) issued
+// for the Audience, the Token and IntrospectionClaims are added to the ctx
+// (see ClaimsFromContext) and the success handler is called. Requests use the
+// ctx oauth2.HTTPClient, if any.
+//
+// Otherwise, the failure handler is called with a 401 and
+// ErrMissingBearerToken, ErrInactiveToken, ErrTokenExpired, or
+// ErrAudienceMismatch, or with a 502 if the introspection endpoint fails.
+// Panics if the config has no URL.
+func IntrospectionHandler(config IntrospectionConfig, success, failure http.Handler) http.Handler {
+	if config.URL == "" {
+		panic("oauth2: IntrospectionConfig requires a URL")
+	}
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	if config.CacheTTL <= 0 {
+		config.CacheTTL = defaultIntrospectionTTL
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token := config.bearerToken(req)
+		if token == "" {
+			ctx = gologin.WithError(ctx, ErrMissingBearerToken)
+			ctx = gologin.WithStatusCode(ctx, http.StatusUnauthorized)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		claims, err := config.introspect(ctx, token)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			ctx = gologin.WithStatusCode(ctx, introspectionStatusCode(err))
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		ctx = WithToken(ctx, &oauth2.Token{AccessToken: token, TokenType: "Bearer", Expiry: claims.expiry()})
+		ctx = WithClaims(ctx, claims)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// bearerToken returns the Authorization bearer token or the Field value.
+// https://tools.ietf.org/html/rfc6750#section-2
+func (c IntrospectionConfig) bearerToken(req *http.Request) string {
+	parts := strings.SplitN(req.Header.Get("Authorization"), " ", 2)
+	if len(parts) == 2 && strings.EqualFold(parts[0], "Bearer") {
+		return strings.TrimSpace(parts[1])
+	}
+	if c.Field != "" {
+		return req.PostFormValue(c.Field)
+	}
+	return ""
+}
+
+// introspect returns the validated claims of the token, from the Cache if
+// present.
+func (c IntrospectionConfig) introspect(ctx context.Context, token string) (*IntrospectionClaims, error) {
+	cacheKey := internal.TokenCacheKey("oauth2-introspection:"+c.URL, token)
+	if c.Cache != nil {
+		if cached, ok := c.Cache.Get(cacheKey); ok {
+			if claims, ok := cached.(*IntrospectionClaims); ok {
+				return claims, c.validate(ctx, claims)
+			}
+		}
+	}
+	claims, err := c.request(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.validate(ctx, claims); err != nil {
+		return nil, err
+	}
+	if c.Cache != nil {
+		ttl := c.CacheTTL
+		if expiry := claims.expiry(); !expiry.IsZero() && expiry.Sub(gologin.ClockFromContext(ctx).Now()) < ttl {
+			ttl = expiry.Sub(gologin.ClockFromContext(ctx).Now())
+		}
+		c.Cache.Set(cacheKey, claims, ttl)
+	}
+	return claims, nil
+}
+
+// request POSTs the token to the introspection endpoint.
+// https://tools.ietf.org/html/rfc7662#section-2.1
+func (c IntrospectionConfig) request(ctx context.Context, token string) (*IntrospectionClaims, error) {
+	params := url.Values{"token": {token}, "token_type_hint": {"access_token"}}
+	req, err := http.NewRequest("POST", c.URL, strings.NewReader(params.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if c.ClientID != "" {
+		req.SetBasicAuth(url.QueryEscape(c.ClientID), url.QueryEscape(c.ClientSecret))
+	}
+	resp, err := internal.ContextClient(ctx).Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, maxIntrospectionBodySize))
+		return nil, fmt.Errorf("oauth2: %s responded with %s", c.URL, resp.Status)
+	}
+	claims := new(IntrospectionClaims)
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxIntrospectionBodySize)).Decode(claims); err != nil {
+		return nil, fmt.Errorf("oauth2: invalid introspection response: %v", err)
+	}
+	return claims, nil
+}
+
+// validate returns an error unless the claims are active, unexpired (per the
+// ctx gologin Clock), and include the Audience, if any.
+func (c IntrospectionConfig) validate(ctx context.Context, claims *IntrospectionClaims) error {
+	if !claims.Active {
+		return ErrInactiveToken
+	}
+	if expiry := claims.expiry(); !expiry.IsZero() && !gologin.ClockFromContext(ctx).Now().Before(expiry) {
+		return ErrTokenExpired
+	}
+	if c.Audience != "" && !claims.Audience.contains(c.Audience) {
+		return ErrAudienceMismatch
+	}
+	return nil
+}
+
+// expiry returns the time of the "exp" claim, or the zero time if unset.
+func (c *IntrospectionClaims) expiry() time.Time {
+	if c.Expiry == 0 {
+		return time.Time{}
+	}
+	return time.Unix(c.Expiry, 0)
+}
+
+// introspectionStatusCode returns the failure status code of an
+// introspection error.
+func introspectionStatusCode(err error) int {
+	switch err {
+	case ErrInactiveToken, ErrTokenExpired, ErrAudienceMismatch:
+		return http.StatusUnauthorized
+	}
+	return http.StatusBadGateway
+}
@@ -0,0 +1,132 @@
+package oauth2
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/internal"
+)
+
+// Errors which may occur in account linking flows.
+var (
+	ErrMissingLinkTarget = errors.New("oauth2: missing link target")
+	ErrInvalidLinkTarget = errors.New("oauth2: invalid link target")
+)
+
+const linkSeparator = "|"
+
+// LinkTarget is the already authenticated user an account linking flow
+// attaches the provider identity to.
+type LinkTarget struct {
+	// UserID is the application's ID of the user who started the link.
+	UserID string
+}
+
+// LinkConfig configures LinkHandler.
+type LinkConfig struct {
+	// StateCookie configures the link flow's state cookie. Its Name must
+	// differ from the login flow's state cookie so concurrent login and link
+	// flows (e.g. in two tabs) cannot replace or consume each other's state.
+	StateCookie gologin.CookieConfig
+	// Cookie configures the short-lived cookie which holds the signed link
+	// target. Its Name must differ from the state cookie names.
+	Cookie gologin.CookieConfig
+	// Key signs the link cookie with HMAC-SHA256 so the user ID cannot be
+	// forged.
+	Key []byte
+	// UserID returns the ID of the authenticated user starting a link (e.g.
+	// from their session). An error calls the failure handler.
+	UserID func(req *http.Request) (string, error)
+}
+
+// LinkHandler tags a login flow as an account link of the authenticated
+// user. Use it in place of StateHandler on the link route and on a separate
+// link redirection URI route (with an oauth2.Config RedirectURL of its own),
+// so the login flow's routes and cookies are untouched.
+//
+// On link requests, a state is issued like StateHandler and the UserID is
+// set in a link cookie signed together with the state. On callback requests
+// (which carry a "state" parameter), the link cookie is verified against the
+// callback state, cleared, and the LinkTarget is added to the ctx for the
+// success handler (see LinkTargetFromContext). A missing or tampered link
+// cookie, or one issued for another state, calls the failure handler with
+// ErrMissingLinkTarget or ErrInvalidLinkTarget.
+func LinkHandler(config LinkConfig, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		if req.FormValue("state") == "" {
+			// link phase, bind the user ID to the issued state
+			state, err := StateFromContext(ctx)
+			if err != nil {
+				ctx = gologin.WithError(ctx, err)
+				failure.ServeHTTP(w, req.WithContext(ctx))
+				return
+			}
+			userID, err := config.UserID(req)
+			if err == nil && userID == "" {
+				err = ErrMissingLinkTarget
+			}
+			if err != nil {
+				ctx = gologin.WithError(ctx, err)
+				failure.ServeHTTP(w, req.WithContext(ctx))
+				return
+			}
+			encoded := base64.RawURLEncoding.EncodeToString([]byte(userID))
+			value := encoded + linkSeparator + linkSignature(config.Key, userID, state)
+			http.SetCookie(w, internal.NewCookie(config.Cookie, value))
+			success.ServeHTTP(w, req)
+			return
+		}
+		// callback phase, read and clear the link cookie
+		cookie, err := req.Cookie(config.Cookie.Name)
+		if err != nil || cookie.Value == "" {
+			ctx = gologin.WithError(ctx, ErrMissingLinkTarget)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		http.SetCookie(w, internal.ExpiredCookie(config.Cookie))
+		userID, err := verifyLink(config.Key, cookie.Value, req.FormValue("state"))
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		ctx = WithLinkTarget(ctx, &LinkTarget{UserID: userID})
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return StateHandlerWithGenerator(config.StateCookie, DefaultStateGenerator, http.HandlerFunc(fn), failure)
+}
+
+// verifyLink returns the user ID of the link cookie value if it was signed
+// for the state.
+func verifyLink(key []byte, value, state string) (string, error) {
+	parts := strings.Split(value, linkSeparator)
+	if len(parts) != 2 {
+		return "", ErrInvalidLinkTarget
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return "", ErrInvalidLinkTarget
+	}
+	userID := string(decoded)
+	if userID == "" || !hmac.Equal([]byte(parts[1]), []byte(linkSignature(key, userID, state))) {
+		return "", ErrInvalidLinkTarget
+	}
+	return userID, nil
+}
+
+// linkSignature returns the base64 encoded HMAC-SHA256 of the user ID and
+// the state of its link flow.
+func linkSignature(key []byte, userID, state string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("link" + linkSeparator + userID + linkSeparator + state))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,203 @@
+package oauth2
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+var testLinkConfig = LinkConfig{
+	StateCookie: gologin.CookieConfig{Name: "link-state", Path: "/", MaxAge: 60},
+	Cookie:      gologin.CookieConfig{Name: "link", Path: "/", MaxAge: 60},
+	Key:         []byte("link signing key"),
+	UserID: func(req *http.Request) (string, error) {
+		return "user-42", nil
+	},
+}
+
+var testLoginStateConfig = gologin.CookieConfig{Name: "login-state", Path: "/", MaxAge: 60}
+
+// startFlow serves a login phase request and returns the issued state and
+// response cookies.
+func startFlow(t *testing.T, handler func(success http.Handler) http.Handler) (string, []*http.Cookie) {
+	var state string
+	success := func(w http.ResponseWriter, req *http.Request) {
+		state, _ = StateFromContext(req.Context())
+	}
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/login", nil)
+	handler(http.HandlerFunc(success)).ServeHTTP(w, req)
+	return state, w.Result().Cookies()
+}
+
+// callbackRequest returns a callback request for the state with the cookies.
+func callbackRequest(state string, cookies ...[]*http.Cookie) *http.Request {
+	req, _ := http.NewRequest("GET", "/callback?code=any_code&state="+url.QueryEscape(state), nil)
+	for _, set := range cookies {
+		for _, cookie := range set {
+			req.AddCookie(cookie)
+		}
+	}
+	return req
+}
+
+func TestLinkHandler_RoundTrip(t *testing.T) {
+	state, cookies := startFlow(t, func(success http.Handler) http.Handler {
+		return LinkHandler(testLinkConfig, success, testutils.AssertFailureNotCalled(t))
+	})
+	// LinkHandler on a link request, assert that:
+	// - a state is issued in the link state cookie
+	// - a link cookie is set
+	assert.NotEmpty(t, state)
+	if assert.Len(t, cookies, 2) {
+		assert.Equal(t, "link-state", cookies[0].Name)
+		assert.Equal(t, state, cookies[0].Value)
+		assert.Equal(t, "link", cookies[1].Name)
+		assert.NotContains(t, cookies[1].Value, "user-42")
+	}
+
+	success := func(w http.ResponseWriter, req *http.Request) {
+		target, err := LinkTargetFromContext(req.Context())
+		if assert.Nil(t, err) {
+			assert.Equal(t, &LinkTarget{UserID: "user-42"}, target)
+		}
+		ownerState, _ := StateFromContext(req.Context())
+		assert.Equal(t, state, ownerState)
+		fmt.Fprintf(w, "success handler called")
+	}
+	w := httptest.NewRecorder()
+	LinkHandler(testLinkConfig, http.HandlerFunc(success), testutils.AssertFailureNotCalled(t)).ServeHTTP(w, callbackRequest(state, cookies))
+
+	// LinkHandler on the callback, assert that:
+	// - the LinkTarget and state are added to the ctx
+	// - the link cookie is cleared
+	assert.Equal(t, "success handler called", w.Body.String())
+	if cleared := w.Result().Cookies(); assert.Len(t, cleared, 1) {
+		assert.Equal(t, "link", cleared[0].Name)
+		assert.Equal(t, -1, cleared[0].MaxAge)
+	}
+}
+
+func TestLinkHandler_ConcurrentLogin(t *testing.T) {
+	server := NewAccessTokenServer(t, `{"access_token":"2YotnFZFEjr1zCsicMWpAA","token_type":"example"}`)
+	defer server.Close()
+	config := &oauth2.Config{
+		Endpoint: oauth2.Endpoint{TokenURL: server.URL},
+	}
+	// the browser starts a login flow and a link flow in two tabs
+	loginState, loginCookies := startFlow(t, func(success http.Handler) http.Handler {
+		return StateHandler(testLoginStateConfig, success)
+	})
+	linkState, linkCookies := startFlow(t, func(success http.Handler) http.Handler {
+		return LinkHandler(testLinkConfig, success, testutils.AssertFailureNotCalled(t))
+	})
+	// LinkHandler with a concurrent login flow, assert that:
+	// - the flows have distinct states
+	assert.NotEqual(t, loginState, linkState)
+
+	// - the login callback is not a link and consumes only the login state
+	loginSuccess := func(w http.ResponseWriter, req *http.Request) {
+		_, err := LinkTargetFromContext(req.Context())
+		assert.NotNil(t, err)
+		fmt.Fprintf(w, "login success handler called")
+	}
+	w := httptest.NewRecorder()
+	StateHandler(testLoginStateConfig, CallbackHandler(config, http.HandlerFunc(loginSuccess), testutils.AssertFailureNotCalled(t))).ServeHTTP(w, callbackRequest(loginState, loginCookies, linkCookies))
+	assert.Equal(t, "login success handler called", w.Body.String())
+	for _, cookie := range w.Result().Cookies() {
+		assert.Equal(t, "login-state", cookie.Name)
+	}
+
+	// - the link callback still succeeds with the LinkTarget
+	linkSuccess := func(w http.ResponseWriter, req *http.Request) {
+		target, err := LinkTargetFromContext(req.Context())
+		if assert.Nil(t, err) {
+			assert.Equal(t, "user-42", target.UserID)
+		}
+		fmt.Fprintf(w, "link success handler called")
+	}
+	w = httptest.NewRecorder()
+	LinkHandler(testLinkConfig, CallbackHandler(config, http.HandlerFunc(linkSuccess), testutils.AssertFailureNotCalled(t)), nil).ServeHTTP(w, callbackRequest(linkState, loginCookies, linkCookies))
+	assert.Equal(t, "link success handler called", w.Body.String())
+}
+
+func TestLinkHandler_CallbackErrors(t *testing.T) {
+	linkState, linkCookies := startFlow(t, func(success http.Handler) http.Handler {
+		return LinkHandler(testLinkConfig, success, testutils.AssertFailureNotCalled(t))
+	})
+	forgedConfig := testLinkConfig
+	forgedConfig.Key = []byte("other key")
+	_, forgedCookies := startFlow(t, func(success http.Handler) http.Handler {
+		return LinkHandler(forgedConfig, success, testutils.AssertFailureNotCalled(t))
+	})
+	loginState, loginCookies := startFlow(t, func(success http.Handler) http.Handler {
+		return StateHandler(testLoginStateConfig, success)
+	})
+	cases := []struct {
+		req      *http.Request
+		expected error
+	}{
+		// link callback without a link cookie
+		{callbackRequest(linkState, linkCookies[:1]), ErrMissingLinkTarget},
+		// link cookie signed with another key
+		{callbackRequest(linkState, linkCookies[:1], forgedCookies[1:]), ErrInvalidLinkTarget},
+		// login flow state at the link callback
+		{callbackRequest(loginState, loginCookies, linkCookies[1:]), ErrInvalidLinkTarget},
+		// malformed link cookie
+		{callbackRequest(linkState, linkCookies[:1], []*http.Cookie{{Name: "link", Value: "user-42"}}), ErrInvalidLinkTarget},
+	}
+	for _, c := range cases {
+		failure := func(w http.ResponseWriter, req *http.Request) {
+			assert.Equal(t, c.expected, gologin.ErrorFromContext(req.Context()))
+			fmt.Fprintf(w, "failure handler called")
+		}
+		w := httptest.NewRecorder()
+		LinkHandler(testLinkConfig, testutils.AssertSuccessNotCalled(t), http.HandlerFunc(failure)).ServeHTTP(w, c.req)
+		assert.Equal(t, "failure handler called", w.Body.String())
+	}
+}
+
+func TestLinkHandler_UserIDError(t *testing.T) {
+	errNotLoggedIn := errors.New("not logged in")
+	cases := []struct {
+		userID   func(req *http.Request) (string, error)
+		expected error
+	}{
+		{func(req *http.Request) (string, error) { return "", errNotLoggedIn }, errNotLoggedIn},
+		{func(req *http.Request) (string, error) { return "", nil }, ErrMissingLinkTarget},
+	}
+	for _, c := range cases {
+		config := testLinkConfig
+		config.UserID = c.userID
+		failure := func(w http.ResponseWriter, req *http.Request) {
+			assert.Equal(t, c.expected, gologin.ErrorFromContext(req.Context()))
+			fmt.Fprintf(w, "failure handler called")
+		}
+		// LinkHandler without an authenticated user, assert that:
+		// - the failure handler is called without a link cookie
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/link", nil)
+		LinkHandler(config, testutils.AssertSuccessNotCalled(t), http.HandlerFunc(failure)).ServeHTTP(w, req)
+		assert.Equal(t, "failure handler called", w.Body.String())
+		for _, cookie := range w.Result().Cookies() {
+			assert.NotEqual(t, "link", cookie.Name)
+		}
+	}
+}
+
+func TestLinkTargetFromContext_Error(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/", nil)
+	target, err := LinkTargetFromContext(req.Context())
+	assert.Nil(t, target)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "oauth2: Context missing LinkTarget", err.Error())
+	}
+}
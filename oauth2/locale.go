@@ -0,0 +1,76 @@
+package oauth2
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"golang.org/x/oauth2"
+)
+
+// AcceptLanguageHandler adds the requester's preferred Accept-Language tag
+// (e.g. "fr-CA") to the ctx as the locale (see WithLocale), unless the ctx
+// already has a locale. Wrap a provider LoginHandler with it so the consent
+// screen is shown in the user's language.
+func AcceptLanguageHandler(success http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		if _, err := LocaleFromContext(ctx); err != nil {
+			if locale := preferredLanguage(req.Header.Get("Accept-Language")); locale != "" {
+				ctx = WithLocale(ctx, locale)
+			}
+		}
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// LocaleParamHandler adds the ctx locale (see WithLocale), passed through
+// format if non-nil, to the ctx AuthCodeOptions as the AuthURL parameter
+// (e.g. "hl"). Per-request AuthCodeOptions follow the LoginHandler options,
+// so the ctx locale takes precedence over a static locale option. Provider
+// packages use it to send the locale with their parameter name.
+func LocaleParamHandler(param string, format func(locale string) string, success http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		if locale, err := LocaleFromContext(ctx); err == nil && locale != "" {
+			if format != nil {
+				locale = format(locale)
+			}
+			opts, _ := AuthCodeOptionsFromContext(ctx)
+			opts = append(opts[:len(opts):len(opts)], oauth2.SetAuthURLParam(param, locale))
+			ctx = WithAuthCodeOptions(ctx, opts...)
+		}
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// preferredLanguage returns the language tag of the Accept-Language header
+// with the highest quality, the first on ties. Wildcards are ignored.
+func preferredLanguage(header string) string {
+	var preferred string
+	best := 0.0
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		tag := strings.TrimSpace(fields[0])
+		if tag == "" || tag == "*" {
+			continue
+		}
+		quality := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				q, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64)
+				if err != nil {
+					q = 0
+				}
+				quality = q
+			}
+		}
+		if quality > best {
+			preferred, best = tag, quality
+		}
+	}
+	return preferred
+}
@@ -0,0 +1,77 @@
+package oauth2
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func TestPreferredLanguage(t *testing.T) {
+	cases := []struct {
+		header   string
+		expected string
+	}{
+		{"fr-CA", "fr-CA"},
+		{"fr-CA,fr;q=0.9,en;q=0.8", "fr-CA"},
+		{"en;q=0.5, de-DE;q=0.7, *;q=0.9", "de-DE"},
+		{"pt-BR;q=0.8, es;q=0.8", "pt-BR"},
+		{"*", ""},
+		{"en;q=0", ""},
+		{"", ""},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.expected, preferredLanguage(c.header), c.header)
+	}
+}
+
+func TestAcceptLanguageHandler(t *testing.T) {
+	var locale string
+	success := func(w http.ResponseWriter, req *http.Request) {
+		locale, _ = LocaleFromContext(req.Context())
+	}
+	handler := AcceptLanguageHandler(http.HandlerFunc(success))
+
+	// AcceptLanguageHandler, assert that:
+	// - the preferred Accept-Language tag is added to the ctx
+	req := httptest.NewRequest("GET", "/login", nil)
+	req.Header.Set("Accept-Language", "en;q=0.8, fr-CA")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, "fr-CA", locale)
+
+	// - an existing ctx locale is kept
+	handler.ServeHTTP(httptest.NewRecorder(), req.WithContext(WithLocale(req.Context(), "de-DE")))
+	assert.Equal(t, "de-DE", locale)
+}
+
+func TestLocaleParamHandler(t *testing.T) {
+	config := &oauth2.Config{
+		ClientID: "client_id",
+		Endpoint: oauth2.Endpoint{AuthURL: "https://provider.example.com/authorize"},
+	}
+	login := LoginHandler(config, testutils.AssertFailureNotCalled(t), oauth2.SetAuthURLParam("ui_locales", "en"))
+	handler := LocaleParamHandler("ui_locales", nil, login)
+	cases := []struct {
+		ctx      context.Context
+		expected string
+	}{
+		// the static AuthCodeOption without a ctx locale
+		{WithState(context.Background(), "state_val"), "en"},
+		// the ctx locale takes precedence
+		{WithLocale(WithState(context.Background(), "state_val"), "fr-CA"), "fr-CA"},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		handler.ServeHTTP(w, req.WithContext(c.ctx))
+		location, err := url.Parse(w.HeaderMap.Get("Location"))
+		if assert.Nil(t, err) {
+			assert.Equal(t, []string{c.expected}, location.Query()["ui_locales"])
+		}
+	}
+}
@@ -1,50 +1,207 @@
 package oauth2
 
 import (
-	"crypto/rand"
+	"context"
 	"encoding/base64"
 	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/dghubble/gologin"
 	"github.com/dghubble/gologin/internal"
 	"golang.org/x/oauth2"
 )
 
+// stateTimestampSeparator separates the random state from its issue time.
+// It does not occur in base64url encoded random states.
+const stateTimestampSeparator = "."
+
 // Errors which may occur on login.
 var (
-	ErrInvalidState = errors.New("oauth2: Invalid OAuth2 state parameter")
+	ErrInvalidState     = errors.New("oauth2: Invalid OAuth2 state parameter")
+	ErrStateExpired     = errors.New("oauth2: state expired")
+	ErrStateAlreadyUsed = errors.New("oauth2: state already used")
+	ErrEmptyState       = errors.New("oauth2: state generator returned an empty state")
+)
+
+// Errors of malformed callback requests. Each is a 400 for the failure
+// handler. ErrDuplicateParam and ErrParamTooLong (and ErrMissingStateCookie
+// with a state parameter) are unlikely from real providers and browsers and
+// may indicate forged callbacks.
+var (
+	// ErrMissingCode is the error when a callback has no (or an empty) code.
+	ErrMissingCode = errors.New("oauth2: Request missing code")
+	// ErrMissingState is the error when a callback has no (or an empty)
+	// state.
+	ErrMissingState = errors.New("oauth2: Request missing state")
+	// ErrDuplicateParam is the error when a callback repeats a parameter with
+	// different values. Errors wrap it with the parameter name.
+	ErrDuplicateParam = errors.New("oauth2: Request has a duplicate parameter")
+	// ErrParamTooLong is the error when a callback parameter is longer than
+	// MaxCallbackParamLength. Errors wrap it with the parameter name.
+	ErrParamTooLong = errors.New("oauth2: Request parameter too long")
+	// ErrMissingStateCookie is the error when a callback has a state but the
+	// requester has no StateHandler state cookie (e.g. it expired, cookies
+	// are blocked, or the callback was forged).
+	ErrMissingStateCookie = errors.New("oauth2: Request missing state cookie")
 )
 
+// MaxCallbackParamLength is the maximum length of callback parameters, which
+// are rejected before being compared or exchanged.
+const MaxCallbackParamLength = 4096
+
+// callbackParams are the parameters checked for duplicates and lengths.
+var callbackParams = []string{"code", "state", "error", "error_description", "error_uri"}
+
+// callbackBodyConfig limits callbacks to GET redirects and form_post POSTs of
+// at most gologin.DefaultMaxBodyBytes.
+var callbackBodyConfig = gologin.BodyConfig{
+	ContentTypes: []string{"application/x-www-form-urlencoded"},
+	Methods:      []string{"GET", "POST"},
+}
+
+// callbackFormHandler applies the callbackBodyConfig limits to requests whose
+// body is parsed as a form (e.g. form_post callbacks), so handlers which read
+// the state before CallbackHandler (e.g. StateHandler) never parse a body
+// without them.
+func callbackFormHandler(success, failure http.Handler) http.Handler {
+	limited := gologin.BodyHandler(callbackBodyConfig, success, failure)
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		switch req.Method {
+		case "POST", "PUT", "PATCH":
+			limited.ServeHTTP(w, req)
+		default:
+			success.ServeHTTP(w, req)
+		}
+	}
+	return http.HandlerFunc(fn)
+}
+
+// StateGenerator returns a new non-guessable state value.
+type StateGenerator func() (string, error)
+
 // StateHandler checks for a state cookie. If found, the state value is read
 // and added to the ctx. Otherwise, a non-guessable value is added to the ctx
 // and to a (short-lived) state cookie issued to the requester.
 //
+// States are single use. Once CallbackHandler compares the callback state,
+// the state cookie is expired whether or not the callback succeeds, so
+// replayed callbacks are rejected. State cookies marked consumed (e.g. by
+// CookieStateStore) are rejected with ErrStateAlreadyUsed.
+//
+// A browser has one state cookie, so logins started in several tabs share a
+// state and only the first callback succeeds. Use StateHandlerWithStore with
+// a NewMultiStateCookieStore to allow concurrent logins.
+//
+// Issued states embed their issue time. If the CookieConfig MaxAge is
+// positive, states older than MaxAge seconds are replaced on login requests
+// and rejected by CallbackHandler with ErrStateExpired, even if the browser
+// still sends the cookie.
+//
 // Implements OAuth 2 RFC 6749 10.12 CSRF Protection. If you wish to issue
 // state params differently, write a http.Handler which sets the ctx state,
 // using oauth2 WithState(ctx, state) since it is required by LoginHandler
 // and CallbackHandler.
 func StateHandler(config gologin.CookieConfig, success http.Handler) http.Handler {
+	return StateHandlerWithGenerator(config, nil, success, nil)
+}
+
+// StateHandlerWithGenerator is a StateHandler which issues states from the
+// generator (e.g. to use a particular RNG, length, or alphabet). Generated
+// states are used as-is, so only states with an issue time (such as those of
+// DefaultStateGenerator) expire before the state cookie. If the generator
+// returns an error or an empty state, the failure handler is called. A nil
+// generator issues DefaultStateGenerator states with the ctx gologin Clock
+// and entropy (see gologin.WithClock and gologin.WithEntropy).
+func StateHandlerWithGenerator(config gologin.CookieConfig, generate StateGenerator, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	return stateHandler(config, generate, nil, success, failure)
+}
+
+// stateHandler returns a StateHandler which issues states from the generator,
+// or from the ctx Clock and entropy if it is nil. If reuse is non-nil, cookie
+// states it rejects are replaced on login requests, like expired states.
+func stateHandler(config gologin.CookieConfig, generate StateGenerator, reuse func(state string) bool, success, failure http.Handler) http.Handler {
 	fn := func(w http.ResponseWriter, req *http.Request) {
 		ctx := req.Context()
-		cookie, err := req.Cookie(config.Name)
-		if err == nil {
+		callback := req.FormValue("state") != ""
+		var state string
+		if cookie, err := req.Cookie(config.Name); err == nil {
+			state = cookie.Value
+		}
+		if callback && state == "" {
+			// CallbackHandler rejects the callback with ErrMissingStateCookie
+			ctx = withMissingStateCookie(ctx)
+		}
+		if used, ok := consumedState(state); ok {
+			if callback {
+				// CallbackHandler rejects the replayed state
+				ctx = withConsumedState(ctx, used)
+				ctx = withStateCookieConfig(ctx, config)
+				success.ServeHTTP(w, req.WithContext(ctx))
+				return
+			}
+			state = ""
+		}
+		expiry := stateExpiry(state, config.MaxAge)
+		// replace expired states, except on callbacks which must reject them
+		if !expiry.IsZero() && gologin.ClockFromContext(ctx).Now().After(expiry) && !callback {
+			state = ""
+		}
+		if state != "" && reuse != nil && !reuse(state) && !callback {
+			state = ""
+		}
+		if state != "" {
 			// add the cookie state to the ctx
-			ctx = WithState(ctx, cookie.Value)
+			ctx = WithState(ctx, state)
+			if !expiry.IsZero() {
+				ctx = WithStateExpiry(ctx, expiry)
+			}
 		} else {
-			// add Cookie with a random state
-			val := randomState()
-			http.SetCookie(w, internal.NewCookie(config, val))
-			ctx = WithState(ctx, val)
+			// add Cookie with a new state
+			var err error
+			if generate != nil {
+				state, err = generate()
+			} else {
+				state, err = GenerateState(contextStateConfig(ctx))
+			}
+			if err == nil && state == "" {
+				err = ErrEmptyState
+			}
+			if err != nil {
+				ctx = gologin.WithError(ctx, err)
+				ctx = gologin.WithStatusCode(ctx, http.StatusInternalServerError)
+				failure.ServeHTTP(w, req.WithContext(ctx))
+				return
+			}
+			http.SetCookie(w, internal.NewCookie(config, state))
+			ctx = WithState(ctx, state)
 		}
+		ctx = withStateCookieConfig(ctx, config)
 		success.ServeHTTP(w, req.WithContext(ctx))
 	}
-	return http.HandlerFunc(fn)
+	return callbackFormHandler(http.HandlerFunc(fn), failure)
 }
 
 // LoginHandler handles OAuth2 login requests by reading the state value from
-// the ctx and redirecting requests to the AuthURL with that state value.
-func LoginHandler(config *oauth2.Config, failure http.Handler) http.Handler {
+// the ctx and redirecting requests to the AuthURL with that state value. If
+// the ctx contains a PKCE code verifier, its S256 code challenge is added. If
+// the ctx contains an OpenID Connect nonce, it is added.
+//
+// The given AuthCodeOptions (e.g. access_type=offline) are added to every
+// AuthURL, followed by any per-request AuthCodeOptions from the ctx. If the
+// ctx contains scopes, they are requested instead of the config Scopes. If the
+// ctx contains a redirect URL (see RedirectURLHandler), it is used instead of
+// the config RedirectURL.
+//
+// The redirect is reported to any ctx gologin Hooks.
+func LoginHandler(config *oauth2.Config, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
 	if failure == nil {
 		failure = gologin.DefaultFailureHandler
 	}
@@ -53,10 +210,32 @@ func LoginHandler(config *oauth2.Config, failure http.Handler) http.Handler {
 		state, err := StateFromContext(ctx)
 		if err != nil {
 			ctx = gologin.WithError(ctx, err)
+			ctx = gologin.WithStatusCode(ctx, http.StatusInternalServerError)
 			failure.ServeHTTP(w, req.WithContext(ctx))
 			return
 		}
-		authURL := config.AuthCodeURL(state)
+		authOpts := append([]oauth2.AuthCodeOption{}, opts...)
+		if ctxOpts, err := AuthCodeOptionsFromContext(ctx); err == nil {
+			authOpts = append(authOpts, ctxOpts...)
+		}
+		if nonce, err := NonceFromContext(ctx); err == nil {
+			authOpts = append(authOpts, oauth2.SetAuthURLParam("nonce", nonce))
+		}
+		if verifier, err := PKCEVerifierFromContext(ctx); err == nil {
+			authOpts = append(authOpts,
+				oauth2.SetAuthURLParam("code_challenge", codeChallengeS256(verifier)),
+				oauth2.SetAuthURLParam("code_challenge_method", codeChallengeMethodS256),
+			)
+		}
+		authConfig := redirectConfig(req, config)
+		if scopes, err := ScopesFromContext(ctx); err == nil && len(scopes) > 0 {
+			// shallow copy the config to request per-request scopes
+			c := *authConfig
+			c.Scopes = dedupeScopes(scopes)
+			authConfig = &c
+		}
+		authURL := authConfig.AuthCodeURL(state, authOpts...)
+		gologin.ReportLoginRedirect(ctx, "oauth2", req)
 		http.Redirect(w, req, authURL, http.StatusFound)
 	}
 	return http.HandlerFunc(fn)
@@ -64,8 +243,38 @@ func LoginHandler(config *oauth2.Config, failure http.Handler) http.Handler {
 
 // CallbackHandler handles OAuth2 redirection URI requests by parsing the auth
 // code and state, comparing with the state value from the ctx, and obtaining
-// an OAuth2 Token.
-func CallbackHandler(config *oauth2.Config, success, failure http.Handler) http.Handler {
+// an OAuth2 Token. The StateHandler state cookie is expired (with the same
+// name, domain, and path) once the code is exchanged, or when the state does
+// not match or has expired. It is kept if the code exchange fails, so the
+// user may retry the callback. Callbacks may be GET requests with query
+// parameters or response_mode=form_post POST requests with form parameters
+// (use a CookieConfig like gologin.FormPostCookieConfig so the state cookie
+// is sent with the cross-site POST). If the provider redirected with an error (e.g.
+// the user denied access), the failure handler is called with an
+// AuthorizationError.
+//
+// Malformed callbacks call the failure handler with a 400 and a distinct
+// error: ErrMissingCode, ErrMissingState, ErrDuplicateParam (a parameter
+// repeated with different values), ErrParamTooLong (longer than
+// MaxCallbackParamLength), or ErrMissingStateCookie (a StateHandler callback
+// without a state cookie). Methods other than GET and POST fail with a 405
+// and gologin.ErrMethodNotAllowed, and form_post bodies which are not
+// form-encoded or exceed 64KB fail with gologin.ErrUnsupportedContentType or
+// gologin.ErrBodyTooLarge (see gologin.BodyHandler).
+//
+// The given AuthCodeOptions (e.g. a resource or audience parameter) are sent
+// with every code exchange, followed by the ctx PKCE code verifier, if any,
+// and any per-request exchange options from the ctx (see WithExchangeOptions).
+// They are distinct from the AuthCodeOptions LoginHandler adds to the AuthURL.
+// If the ctx contains a redirect URL (see RedirectURLHandler), it is sent
+// instead of the config RedirectURL.
+//
+// The scopes granted with the Token (its "scope" field, or the config Scopes
+// if the provider omitted it) are added to the ctx (see
+// GrantedScopesFromContext). Chain RequiredScopesHandler to require scopes.
+//
+// The code exchange is reported to any ctx gologin Hooks.
+func CallbackHandler(config *oauth2.Config, success, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
 	if failure == nil {
 		failure = gologin.DefaultFailureHandler
 	}
@@ -74,51 +283,196 @@ func CallbackHandler(config *oauth2.Config, success, failure http.Handler) http.
 		authCode, state, err := parseCallback(req)
 		if err != nil {
 			ctx = gologin.WithError(ctx, err)
+			ctx = gologin.WithStatusCode(ctx, callbackStatusCode(err))
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		if missingStateCookie(ctx) {
+			ExpireStateCookie(ctx, w)
+			ctx = gologin.WithError(ctx, ErrMissingStateCookie)
+			ctx = gologin.WithStatusCode(ctx, http.StatusBadRequest)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		if used, err := consumedStateFromContext(ctx); err == nil && state == used {
+			ExpireStateCookie(ctx, w)
+			ctx = gologin.WithError(ctx, ErrStateAlreadyUsed)
+			ctx = gologin.WithStatusCode(ctx, http.StatusBadRequest)
 			failure.ServeHTTP(w, req.WithContext(ctx))
 			return
 		}
 		ownerState, err := StateFromContext(ctx)
 		if err != nil {
 			ctx = gologin.WithError(ctx, err)
+			ctx = gologin.WithStatusCode(ctx, http.StatusBadRequest)
 			failure.ServeHTTP(w, req.WithContext(ctx))
 			return
 		}
-		if state != ownerState || state == "" {
+		if state == "" || !internal.EqualSecrets(state, ownerState) {
+			ExpireStateCookie(ctx, w)
 			ctx = gologin.WithError(ctx, ErrInvalidState)
+			ctx = gologin.WithStatusCode(ctx, http.StatusBadRequest)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		if expiry, err := StateExpiryFromContext(ctx); err == nil && gologin.ClockFromContext(ctx).Now().After(expiry) {
+			ExpireStateCookie(ctx, w)
+			ctx = gologin.WithError(ctx, ErrStateExpired)
+			ctx = gologin.WithStatusCode(ctx, http.StatusBadRequest)
 			failure.ServeHTTP(w, req.WithContext(ctx))
 			return
 		}
+		exchangeOpts := append([]oauth2.AuthCodeOption{}, opts...)
+		if verifier, err := PKCEVerifierFromContext(ctx); err == nil {
+			exchangeOpts = append(exchangeOpts, oauth2.SetAuthURLParam("code_verifier", verifier))
+		}
+		if ctxOpts, err := ExchangeOptionsFromContext(ctx); err == nil {
+			exchangeOpts = append(exchangeOpts, ctxOpts...)
+		}
 		// use the authorization code to get a Token
-		token, err := config.Exchange(ctx, authCode)
+		exchangeCtx, endExchange := gologin.StartTokenExchange(ctx, "oauth2")
+		token, err := redirectConfig(req, config).Exchange(exchangeCtx, authCode, exchangeOpts...)
+		endExchange(err)
 		if err != nil {
 			ctx = gologin.WithError(ctx, err)
+			ctx = gologin.WithStatusCode(ctx, http.StatusBadGateway)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		// consume the state only once the code is exchanged, so failed
+		// exchanges may be retried
+		if err := useState(ctx); err != nil {
+			ctx = gologin.WithError(ctx, err)
+			ctx = gologin.WithStatusCode(ctx, http.StatusBadRequest)
 			failure.ServeHTTP(w, req.WithContext(ctx))
 			return
 		}
+		ExpireStateCookie(ctx, w)
 		ctx = WithToken(ctx, token)
+		ctx = WithGrantedScopes(ctx, grantedScopes(token, config.Scopes)...)
 		success.ServeHTTP(w, req.WithContext(ctx))
 	}
-	return http.HandlerFunc(fn)
+	return gologin.BodyHandler(callbackBodyConfig, http.HandlerFunc(fn), failure)
+}
+
+// NamedLoginHandler is a LoginHandler for the named provider (e.g. "gitea"),
+// which is stored in the ctx (see gologin.ProviderFromContext) and reported
+// to gologin Hooks.
+func NamedLoginHandler(provider string, config *oauth2.Config, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	return gologin.ProviderHandler(provider, LoginHandler(config, failure, opts...))
+}
+
+// NamedCallbackHandler is a CallbackHandler for the named provider (e.g.
+// "gitea"), which is stored in the ctx (see gologin.ProviderFromContext) for
+// the success and failure handlers and reported to gologin Hooks.
+func NamedCallbackHandler(provider string, config *oauth2.Config, success, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	return gologin.ProviderHandler(provider, CallbackHandler(config, success, failure, opts...))
+}
+
+// ExpireStateCookie expires the StateHandler state cookie of the ctx, if
+// any. CallbackHandler calls it once the code is exchanged (or the state is
+// rejected), so a state is used for one successful callback. Callback handlers of other protocols (e.g.
+// Steam OpenID) which check the StateHandler state should call it too.
+func ExpireStateCookie(ctx context.Context, w http.ResponseWriter) {
+	if cookieConfig, err := stateCookieConfigFromContext(ctx); err == nil {
+		http.SetCookie(w, internal.ExpiredCookie(cookieConfig))
+	}
+}
+
+// DefaultStateGenerator returns a base64url encoded random 32 byte state from
+// crypto/rand, followed by its issue time so that states expire. Use
+// NewStateGenerator to choose the length, encoding, or randomness source.
+func DefaultStateGenerator() (string, error) {
+	return GenerateState(StateConfig{})
 }
 
-// Returns a base64 encoded random 32 byte string.
-func randomState() string {
+// stateExpiry returns the time at which a state issued by DefaultStateGenerator
+// expires given a maxAge in seconds. A zero time is returned if the state has
+// no issue time or maxAge is not positive.
+func stateExpiry(state string, maxAge int) time.Time {
+	i := strings.LastIndex(state, stateTimestampSeparator)
+	if i < 0 || maxAge <= 0 {
+		return time.Time{}
+	}
+	issued, err := strconv.ParseInt(state[i+1:], 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(issued, 0).Add(time.Duration(maxAge) * time.Second)
+}
+
+// Returns a base64 encoded random 32 byte string from the ctx entropy (see
+// gologin.WithEntropy), or an error if the entropy cannot be read.
+func randomState(ctx context.Context) (string, error) {
 	b := make([]byte, 32)
-	rand.Read(b)
-	return base64.RawURLEncoding.EncodeToString(b)
+	if _, err := io.ReadFull(gologin.EntropyFromContext(ctx), b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// dedupeScopes returns the scopes with duplicates removed, preserving order.
+func dedupeScopes(scopes []string) []string {
+	seen := make(map[string]bool, len(scopes))
+	deduped := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		if !seen[scope] {
+			seen[scope] = true
+			deduped = append(deduped, scope)
+		}
+	}
+	return deduped
 }
 
 // parseCallback parses the "code" and "state" parameters from the http.Request
-// and returns them.
+// and returns them. If the provider redirected with an "error" parameter, an
+// AuthorizationError is returned instead. Parameters which are too long or
+// repeated with different values (e.g. in both the query and a form_post
+// body) are rejected first.
 func parseCallback(req *http.Request) (authCode, state string, err error) {
 	err = req.ParseForm()
 	if err != nil {
 		return "", "", err
 	}
-	authCode = req.Form.Get("code")
-	state = req.Form.Get("state")
-	if authCode == "" || state == "" {
-		return "", "", errors.New("oauth2: Request missing code or state")
+	for _, name := range callbackParams {
+		if err := checkCallbackParam(name, req.Form[name]); err != nil {
+			return "", "", err
+		}
+	}
+	params := req.Form
+	if req.Method == "POST" {
+		// response_mode=form_post callbacks send parameters in the body
+		params = req.PostForm
+	}
+	if code := params.Get("error"); code != "" {
+		return "", "", &AuthorizationError{
+			Code:        code,
+			Description: params.Get("error_description"),
+			URI:         params.Get("error_uri"),
+		}
+	}
+	authCode = params.Get("code")
+	state = params.Get("state")
+	if state == "" {
+		return "", "", ErrMissingState
+	}
+	if authCode == "" {
+		return "", "", ErrMissingCode
 	}
 	return authCode, state, nil
 }
+
+// checkCallbackParam returns ErrParamTooLong or ErrDuplicateParam (wrapped
+// with the name) if any value of the parameter is too long or the values
+// differ.
+func checkCallbackParam(name string, values []string) error {
+	for _, value := range values {
+		if len(value) > MaxCallbackParamLength {
+			return fmt.Errorf("%w: %s", ErrParamTooLong, name)
+		}
+		if value != values[0] {
+			return fmt.Errorf("%w: %s", ErrDuplicateParam, name)
+		}
+	}
+	return nil
+}
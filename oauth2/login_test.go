@@ -2,18 +2,221 @@ package oauth2
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strconv"
 	"strings"
 	"testing"
+	"testing/iotest"
+	"time"
 
 	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/gologintest"
 	"github.com/dghubble/gologin/testutils"
 	"github.com/stretchr/testify/assert"
 	"golang.org/x/oauth2"
 )
 
+// StateHandler
+
+func TestStateHandler(t *testing.T) {
+	config := gologin.CookieConfig{
+		Name:     "provider-state",
+		Domain:   "example.com",
+		Path:     "/auth",
+		MaxAge:   120,
+		HTTPOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteStrictMode,
+	}
+	var state string
+	success := func(w http.ResponseWriter, req *http.Request) {
+		var err error
+		state, err = StateFromContext(req.Context())
+		assert.Nil(t, err)
+		assert.NotEmpty(t, state)
+	}
+
+	// StateHandler without a state cookie, assert that:
+	// - a random state is added to the ctx
+	// - a state cookie is set with the CookieConfig attributes
+	handler := StateHandler(config, http.HandlerFunc(success))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	handler.ServeHTTP(w, req)
+	cookies := (&http.Response{Header: w.Header()}).Cookies()
+	if assert.Len(t, cookies, 1) {
+		cookie := cookies[0]
+		assert.Equal(t, "provider-state", cookie.Name)
+		assert.Equal(t, state, cookie.Value)
+		assert.Equal(t, "example.com", cookie.Domain)
+		assert.Equal(t, "/auth", cookie.Path)
+		assert.Equal(t, 120, cookie.MaxAge)
+		assert.True(t, cookie.HttpOnly)
+		assert.True(t, cookie.Secure)
+		assert.Equal(t, http.SameSiteStrictMode, cookie.SameSite)
+	}
+}
+
+func TestStateHandler_ExistingCookie(t *testing.T) {
+	config := gologin.DebugOnlyCookieConfig
+	success := func(w http.ResponseWriter, req *http.Request) {
+		state, err := StateFromContext(req.Context())
+		assert.Nil(t, err)
+		assert.Equal(t, "cookie_state", state)
+		fmt.Fprintf(w, "success handler called")
+	}
+
+	// StateHandler with a state cookie, assert that:
+	// - the cookie state (matching the CookieConfig name) is added to the ctx
+	// - no new cookie is set
+	handler := StateHandler(config, http.HandlerFunc(success))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.AddCookie(&http.Cookie{Name: "other-provider-state", Value: "other_state"})
+	req.AddCookie(&http.Cookie{Name: config.Name, Value: "cookie_state"})
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "success handler called", w.Body.String())
+	assert.Empty(t, w.Header().Get("Set-Cookie"))
+}
+
+// expiredStateCookie returns a state cookie issued long enough ago to have
+// expired under DebugOnlyCookieConfig.
+func expiredStateCookie(state string) *http.Cookie {
+	issued := time.Now().Add(-2 * time.Minute).Unix()
+	value := state + stateTimestampSeparator + strconv.FormatInt(issued, 10)
+	return &http.Cookie{Name: gologin.DebugOnlyCookieConfig.Name, Value: value}
+}
+
+func TestStateHandler_IssueTime(t *testing.T) {
+	config := gologin.DebugOnlyCookieConfig
+	success := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		state, err := StateFromContext(ctx)
+		assert.Nil(t, err)
+		expiry := stateExpiry(state, config.MaxAge)
+		assert.WithinDuration(t, time.Now().Add(time.Minute), expiry, 5*time.Second)
+		fmt.Fprintf(w, "success handler called")
+	}
+
+	// StateHandler without a state cookie, assert that:
+	// - the issued state embeds its issue time
+	handler := StateHandler(config, http.HandlerFunc(success))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestStateHandlerWithGenerator(t *testing.T) {
+	config := gologin.DebugOnlyCookieConfig
+	success := func(w http.ResponseWriter, req *http.Request) {
+		state, err := StateFromContext(req.Context())
+		assert.Nil(t, err)
+		assert.Equal(t, "ABCDEFGH", state)
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// StateHandlerWithGenerator assert that:
+	// - the generated state is added to the ctx and state cookie as-is
+	generator := func() (string, error) { return "ABCDEFGH", nil }
+	handler := StateHandlerWithGenerator(config, generator, http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "success handler called", w.Body.String())
+	cookies := (&http.Response{Header: w.Header()}).Cookies()
+	if assert.Len(t, cookies, 1) {
+		assert.Equal(t, "ABCDEFGH", cookies[0].Value)
+	}
+}
+
+func TestStateHandlerWithGenerator_Error(t *testing.T) {
+	errRNG := errors.New("rng: entropy source unavailable")
+	cases := []struct {
+		generator StateGenerator
+		err       error
+	}{
+		{func() (string, error) { return "", errRNG }, errRNG},
+		{func() (string, error) { return "", nil }, ErrEmptyState},
+	}
+	config := gologin.DebugOnlyCookieConfig
+	success := testutils.AssertSuccessNotCalled(t)
+	for _, c := range cases {
+		failure := func(w http.ResponseWriter, req *http.Request) {
+			assert.Equal(t, c.err, gologin.ErrorFromContext(req.Context()))
+			fmt.Fprintf(w, "failure handler called")
+		}
+
+		// StateHandlerWithGenerator with a failing generator, assert that:
+		// - failure handler is called with the error
+		// - no state cookie is set
+		handler := StateHandlerWithGenerator(config, c.generator, success, http.HandlerFunc(failure))
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		handler.ServeHTTP(w, req)
+		assert.Equal(t, "failure handler called", w.Body.String())
+		assert.Empty(t, w.Header().Get("Set-Cookie"))
+	}
+}
+
+func TestDefaultStateGenerator(t *testing.T) {
+	state, err := DefaultStateGenerator()
+	assert.Nil(t, err)
+	other, err := DefaultStateGenerator()
+	assert.Nil(t, err)
+	assert.NotEqual(t, state, other)
+	// states embed their issue time
+	assert.False(t, stateExpiry(state, 60).IsZero())
+}
+
+func TestStateHandler_ExpiredCookie(t *testing.T) {
+	config := gologin.DebugOnlyCookieConfig
+	expired := expiredStateCookie("cookie_state")
+	success := func(w http.ResponseWriter, req *http.Request) {
+		state, err := StateFromContext(req.Context())
+		assert.Nil(t, err)
+		assert.NotEqual(t, expired.Value, state)
+		fmt.Fprintf(w, "success handler called")
+	}
+
+	// StateHandler on a login request with an expired state cookie, assert that:
+	// - a new state is added to the ctx
+	// - a new state cookie is set
+	handler := StateHandler(config, http.HandlerFunc(success))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.AddCookie(expired)
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "success handler called", w.Body.String())
+	assert.NotEmpty(t, w.Header().Get("Set-Cookie"))
+}
+
+func TestStateExpiry(t *testing.T) {
+	issued := time.Unix(1500000000, 0)
+	cases := []struct {
+		state  string
+		maxAge int
+		expiry time.Time
+	}{
+		{"abc.1500000000", 60, issued.Add(time.Minute)},
+		{"abc.1500000000", 0, time.Time{}},
+		{"abc", 60, time.Time{}},
+		{"abc.notatime", 60, time.Time{}},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.expiry, stateExpiry(c.state, c.maxAge))
+	}
+}
+
 // LoginHandler
 
 func TestLoginHandler(t *testing.T) {
@@ -42,6 +245,79 @@ func TestLoginHandler(t *testing.T) {
 	assert.Equal(t, expectedRedirect, w.HeaderMap.Get("Location"))
 }
 
+func TestLoginHandler_AuthCodeOptions(t *testing.T) {
+	config := &oauth2.Config{
+		ClientID:    "client_id",
+		RedirectURL: "redirect_url",
+		Endpoint: oauth2.Endpoint{
+			AuthURL: "https://api.example.com/authorize",
+		},
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// LoginHandler with static and ctx AuthCodeOptions, assert that:
+	// - static AuthCodeOptions are added to the redirect url
+	// - per-request ctx AuthCodeOptions are added to the redirect url
+	loginHandler := LoginHandler(config, failure, oauth2.AccessTypeOffline, oauth2.SetAuthURLParam("prompt", "consent"))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	ctx := WithState(context.Background(), "state_val")
+	ctx = WithAuthCodeOptions(ctx, oauth2.SetAuthURLParam("login_hint", "user@example.com"))
+	loginHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, http.StatusFound, w.Code)
+	location, err := url.Parse(w.HeaderMap.Get("Location"))
+	if assert.Nil(t, err) {
+		query := location.Query()
+		assert.Equal(t, "state_val", query.Get("state"))
+		assert.Equal(t, "offline", query.Get("access_type"))
+		assert.Equal(t, "consent", query.Get("prompt"))
+		assert.Equal(t, "user@example.com", query.Get("login_hint"))
+	}
+
+	// options from one request's ctx do not leak into later requests
+	w = httptest.NewRecorder()
+	loginHandler.ServeHTTP(w, req.WithContext(WithState(context.Background(), "state_val")))
+	location, err = url.Parse(w.HeaderMap.Get("Location"))
+	if assert.Nil(t, err) {
+		assert.Equal(t, "", location.Query().Get("login_hint"))
+	}
+}
+
+func TestLoginHandler_Scopes(t *testing.T) {
+	config := &oauth2.Config{
+		ClientID: "client_id",
+		Endpoint: oauth2.Endpoint{
+			AuthURL: "https://api.example.com/authorize",
+		},
+		Scopes: []string{"email"},
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+	loginHandler := LoginHandler(config, failure)
+
+	cases := []struct {
+		ctx           context.Context
+		expectedScope string
+	}{
+		// default config scopes
+		{WithState(context.Background(), "state_val"), "email"},
+		// empty scopes means use the defaults
+		{WithScopes(WithState(context.Background(), "state_val")), "email"},
+		// overridden scopes are deduplicated
+		{WithScopes(WithState(context.Background(), "state_val"), "email", "repo", "email"), "email repo"},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		loginHandler.ServeHTTP(w, req.WithContext(c.ctx))
+		location, err := url.Parse(w.HeaderMap.Get("Location"))
+		if assert.Nil(t, err) {
+			assert.Equal(t, c.expectedScope, location.Query().Get("scope"))
+		}
+	}
+	// config scopes are never modified
+	assert.Equal(t, []string{"email"}, config.Scopes)
+}
+
 func TestLoginHandler_MissingCtxState(t *testing.T) {
 	config := &oauth2.Config{}
 	failure := func(w http.ResponseWriter, req *http.Request) {
@@ -109,33 +385,438 @@ func TestCallbackHandler(t *testing.T) {
 	assert.Equal(t, "success handler called", w.Body.String())
 }
 
+func TestNamedCallbackHandler(t *testing.T) {
+	server := NewAccessTokenServer(t, `{"access_token":"2YotnFZFEjr1zCsicMWpAA","token_type":"example"}`)
+	defer server.Close()
+	config := &oauth2.Config{
+		Endpoint: oauth2.Endpoint{
+			TokenURL: server.URL,
+		},
+	}
+	var exchangeProvider string
+	hooks := &gologin.Hooks{
+		OnTokenExchange: func(ctx context.Context, provider string, duration time.Duration, err error) {
+			exchangeProvider = provider
+		},
+	}
+	success := func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "gitea", gologin.ProviderFromContext(req.Context(), ""))
+		fmt.Fprintf(w, "success handler called")
+	}
+
+	// NamedCallbackHandler, assert that:
+	// - the provider name is added to the ctx of the success handler
+	// - the code exchange is reported to Hooks as the provider
+	callbackHandler := gologin.HooksHandler(hooks, NamedCallbackHandler("gitea", config, http.HandlerFunc(success), testutils.AssertFailureNotCalled(t)))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	ctx := WithState(context.Background(), "d4e5f6")
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+	assert.Equal(t, "gitea", exchangeProvider)
+}
+
+func TestNamedLoginHandler(t *testing.T) {
+	var redirectProvider string
+	hooks := &gologin.Hooks{
+		OnLoginRedirect: func(ctx context.Context, provider string, req *http.Request) {
+			redirectProvider = provider
+		},
+	}
+	config := &oauth2.Config{
+		Endpoint: oauth2.Endpoint{
+			AuthURL: "https://api.example.com/authorize",
+		},
+	}
+
+	// NamedLoginHandler, assert that:
+	// - the login redirect is reported to Hooks as the provider
+	loginHandler := gologin.HooksHandler(hooks, NamedLoginHandler("gitea", config, testutils.AssertFailureNotCalled(t)))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	ctx := WithState(context.Background(), "d4e5f6")
+	loginHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "gitea", redirectProvider)
+}
+
+func TestCallbackHandler_FormPost(t *testing.T) {
+	server := NewAccessTokenServer(t, `{"access_token":"2YotnFZFEjr1zCsicMWpAA","token_type":"example"}`)
+	defer server.Close()
+	config := &oauth2.Config{
+		Endpoint: oauth2.Endpoint{
+			TokenURL: server.URL,
+		},
+	}
+	success := func(w http.ResponseWriter, req *http.Request) {
+		token, err := TokenFromContext(req.Context())
+		assert.Nil(t, err)
+		assert.Equal(t, "2YotnFZFEjr1zCsicMWpAA", token.AccessToken)
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// CallbackHandler with a response_mode=form_post callback, assert that:
+	// - code and state are read from the POST form body
+	// - success handler is called
+	callbackHandler := CallbackHandler(config, http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	body := url.Values{"code": {"any_code"}, "state": {"d4e5f6"}}.Encode()
+	req, _ := http.NewRequest("POST", "/", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	ctx := WithState(context.Background(), "d4e5f6")
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestCallbackHandler_FormPostMissingCode(t *testing.T) {
+	config := &oauth2.Config{}
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.Equal(t, ErrMissingCode, err)
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// CallbackHandler with a form_post callback missing the code, assert that:
+	// - query parameters are not mixed with the POST form
+	// - failure handler is called
+	callbackHandler := CallbackHandler(config, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	body := url.Values{"state": {"d4e5f6"}}.Encode()
+	req, _ := http.NewRequest("POST", "/?code=any_code", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	ctx := WithState(context.Background(), "d4e5f6")
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestCallbackHandler_FormPostBody(t *testing.T) {
+	oversized := url.Values{"code": {"any_code"}, "state": {"d4e5f6"}, "padding": {strings.Repeat("a", gologin.DefaultMaxBodyBytes)}}.Encode()
+	cases := []struct {
+		name        string
+		method      string
+		contentType string
+		body        string
+		status      int
+		err         error
+	}{
+		{"PUT", "PUT", "application/x-www-form-urlencoded", "code=any_code&state=d4e5f6", http.StatusMethodNotAllowed, gologin.ErrMethodNotAllowed},
+		{"JSON", "POST", "application/json", `{"code":"any_code","state":"d4e5f6"}`, http.StatusUnsupportedMediaType, gologin.ErrUnsupportedContentType},
+		{"oversized", "POST", "application/x-www-form-urlencoded", oversized, http.StatusRequestEntityTooLarge, gologin.ErrBodyTooLarge},
+	}
+	for _, c := range cases {
+		failure := func(w http.ResponseWriter, req *http.Request) {
+			ctx := req.Context()
+			assert.Equal(t, c.err, gologin.ErrorFromContext(ctx), c.name)
+			w.WriteHeader(gologin.StatusCodeFromContext(ctx))
+		}
+
+		// CallbackHandler with an unexpected form_post request, assert that:
+		// - the failure handler is called with the typed error and status
+		callbackHandler := CallbackHandler(&oauth2.Config{}, testutils.AssertSuccessNotCalled(t), http.HandlerFunc(failure))
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(c.method, "/", strings.NewReader(c.body))
+		req.Header.Set("Content-Type", c.contentType)
+		ctx := WithState(context.Background(), "d4e5f6")
+		callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+		assert.Equal(t, c.status, w.Code, c.name)
+	}
+}
+
+func TestStateHandler_FormPostBody(t *testing.T) {
+	server := NewAccessTokenServer(t, `{"access_token":"2YotnFZFEjr1zCsicMWpAA","token_type":"example"}`)
+	defer server.Close()
+	config := &oauth2.Config{Endpoint: oauth2.Endpoint{TokenURL: server.URL}}
+	stateConfig := gologin.DebugOnlyCookieConfig
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		assert.Equal(t, gologin.ErrBodyTooLarge, gologin.ErrorFromContext(ctx))
+		w.WriteHeader(gologin.StatusCodeFromContext(ctx))
+	}
+	callback := CallbackHandler(config, testutils.AssertSuccessNotCalled(t), http.HandlerFunc(failure))
+	returnURLConfig := ReturnURLConfig{Cookie: gologin.CookieConfig{Name: "return-url"}}
+	handlers := map[string]http.Handler{
+		"StateHandler":     StateHandler(stateConfig, callback),
+		"ReturnURLHandler": ReturnURLHandler(returnURLConfig, StateHandler(stateConfig, callback), http.HandlerFunc(failure)),
+	}
+	padding := strings.Repeat("a", 1<<20)
+	for name, handler := range handlers {
+		// StateHandler (or ReturnURLHandler) and CallbackHandler with a
+		// chunked oversized form_post, assert that:
+		// - the body limit applies before the state is read
+		// - the failure handler is called with a 413 and no code exchange
+		body := io.MultiReader(strings.NewReader("code=any_code&state=d4e5f6&padding="), strings.NewReader(padding))
+		req, _ := http.NewRequest("POST", "/callback", body)
+		req.ContentLength = -1
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(&http.Cookie{Name: stateConfig.Name, Value: "d4e5f6"})
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code, name)
+	}
+}
+
+func TestRandomState_EntropyErrors(t *testing.T) {
+	config := &oauth2.Config{Endpoint: oauth2.Endpoint{AuthURL: "https://provider.example.com/authorize"}}
+	success := testutils.AssertSuccessNotCalled(t)
+	handlers := map[string]func(failure http.Handler) http.Handler{
+		"StateHandlerWithStore": func(failure http.Handler) http.Handler {
+			return StateHandlerWithStore(NewMemoryStateStore(), success, failure)
+		},
+		"LoginHandlerWithPKCE": func(failure http.Handler) http.Handler {
+			return LoginHandlerWithPKCE(config, gologin.CookieConfig{Name: "pkce"}, failure)
+		},
+		"NonceHandler": func(failure http.Handler) http.Handler {
+			return NonceHandler(gologin.CookieConfig{Name: "nonce"}, success, failure)
+		},
+		"StatelessStateHandler": func(failure http.Handler) http.Handler {
+			return StatelessStateHandler(StatelessStateConfig{Key: []byte("stateless-key")}, success, failure)
+		},
+	}
+	entropies := map[string]func() io.Reader{
+		"failing": func() io.Reader { return iotest.ErrReader(errors.New("entropy unavailable")) },
+		"short":   func() io.Reader { return strings.NewReader("short") },
+	}
+	for name, newHandler := range handlers {
+		for entropyName, entropy := range entropies {
+			failure := func(w http.ResponseWriter, req *http.Request) {
+				assert.NotNil(t, gologin.ErrorFromContext(req.Context()))
+				w.WriteHeader(gologin.StatusCodeFromContext(req.Context()))
+			}
+
+			// handlers which issue random values with unreadable entropy,
+			// assert that:
+			// - the failure handler is called with a 500, no zero value issued
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/login", nil)
+			req = req.WithContext(gologin.WithEntropy(req.Context(), entropy()))
+			newHandler(http.HandlerFunc(failure)).ServeHTTP(w, req)
+			assert.Equal(t, http.StatusInternalServerError, w.Code, name+" "+entropyName)
+			assert.Empty(t, w.Result().Cookies(), name+" "+entropyName)
+		}
+	}
+
+	// PrivateKeyJWT assertions return the entropy error
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	p := &PrivateKeyJWT{Key: ecKey, ClientID: "client_id"}
+	_, err := p.Assertion(gologin.WithEntropy(context.Background(), strings.NewReader("short")))
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+}
+
+func TestCallbackHandler_TokenFields(t *testing.T) {
+	jsonData := `{
+       "access_token":"2YotnFZFEjr1zCsicMWpAA",
+       "token_type":"Bearer",
+       "refresh_token":"tGzv3JOkF0XG5Qx2TlKWIA",
+       "expires_in":3600
+     }`
+	server := NewAccessTokenServer(t, jsonData)
+	defer server.Close()
+
+	config := &oauth2.Config{
+		Endpoint: oauth2.Endpoint{
+			TokenURL: server.URL,
+		},
+	}
+	success := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := TokenFromContext(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, "2YotnFZFEjr1zCsicMWpAA", token.AccessToken)
+		assert.Equal(t, "Bearer", token.Type())
+		assert.Equal(t, "tGzv3JOkF0XG5Qx2TlKWIA", token.RefreshToken)
+		assert.WithinDuration(t, time.Now().Add(time.Hour), token.Expiry, time.Minute)
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// CallbackHandler gets OAuth2 Token, assert that:
+	// - the refresh token and expiry are added to the ctx with the Token
+	callbackHandler := CallbackHandler(config, http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	ctx := WithState(context.Background(), "d4e5f6")
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestCallbackHandler_GrantedScopes(t *testing.T) {
+	cases := []struct {
+		tokenJSON string
+		expected  []string
+	}{
+		// space separated scope field
+		{`{"access_token":"2YotnFZFEjr1zCsicMWpAA","token_type":"Bearer","scope":"openid email"}`, []string{"openid", "email"}},
+		// comma separated scope field (e.g. GitHub)
+		{`{"access_token":"2YotnFZFEjr1zCsicMWpAA","token_type":"bearer","scope":"repo,gist"}`, []string{"repo", "gist"}},
+		// no scopes granted
+		{`{"access_token":"2YotnFZFEjr1zCsicMWpAA","token_type":"bearer","scope":""}`, []string{}},
+		// omitted scope field means the requested scopes were granted
+		{`{"access_token":"2YotnFZFEjr1zCsicMWpAA","token_type":"Bearer"}`, []string{"profile", "email"}},
+	}
+	for _, c := range cases {
+		server := NewAccessTokenServer(t, c.tokenJSON)
+		config := &oauth2.Config{
+			Scopes: []string{"profile", "email"},
+			Endpoint: oauth2.Endpoint{
+				TokenURL: server.URL,
+			},
+		}
+		var granted []string
+		success := func(w http.ResponseWriter, req *http.Request) {
+			var err error
+			granted, err = GrantedScopesFromContext(req.Context())
+			assert.Nil(t, err)
+		}
+
+		// CallbackHandler gets OAuth2 Token, assert that:
+		// - the granted scopes of the token response are added to the ctx
+		callbackHandler := CallbackHandler(config, http.HandlerFunc(success), testutils.AssertFailureNotCalled(t))
+		req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+		ctx := WithState(context.Background(), "d4e5f6")
+		callbackHandler.ServeHTTP(httptest.NewRecorder(), req.WithContext(ctx))
+		assert.Equal(t, c.expected, granted, c.tokenJSON)
+		server.Close()
+	}
+}
+
 func TestCallbackHandler_ParseCallbackError(t *testing.T) {
 	config := &oauth2.Config{}
 	success := testutils.AssertSuccessNotCalled(t)
+	var expected error
 	failure := func(w http.ResponseWriter, req *http.Request) {
 		ctx := req.Context()
 		err := gologin.ErrorFromContext(ctx)
 		if assert.NotNil(t, err) {
-			assert.Equal(t, "oauth2: Request missing code or state", err.Error())
+			assert.Equal(t, expected, err)
 		}
 		fmt.Fprintf(w, "failure handler called")
 	}
 
 	// CallbackHandler called without code or state, assert that:
 	// - failure handler is called
-	// - error about missing code or state is added to the ctx
+	// - error about the missing code or state is added to the ctx
 	callbackHandler := CallbackHandler(config, success, http.HandlerFunc(failure))
 	w := httptest.NewRecorder()
+	expected = ErrMissingState
 	req, _ := http.NewRequest("GET", "/?code=any_code", nil)
 	callbackHandler.ServeHTTP(w, req)
 	assert.Equal(t, "failure handler called", w.Body.String())
 
 	w = httptest.NewRecorder()
+	expected = ErrMissingCode
 	req, _ = http.NewRequest("GET", "/?state=any_state", nil)
 	callbackHandler.ServeHTTP(w, req)
 	assert.Equal(t, "failure handler called", w.Body.String())
 }
 
+func TestCallbackHandler_MalformedCallbacks(t *testing.T) {
+	server := NewAccessTokenServer(t, `{"access_token":"2YotnFZFEjr1zCsicMWpAA","token_type":"example"}`)
+	defer server.Close()
+	config := &oauth2.Config{
+		Endpoint: oauth2.Endpoint{
+			TokenURL: server.URL,
+		},
+	}
+	long := strings.Repeat("a", MaxCallbackParamLength+1)
+	cookie := &http.Cookie{Name: gologin.DebugOnlyCookieConfig.Name, Value: "d4e5f6"}
+	cases := []struct {
+		name   string
+		method string
+		target string
+		form   url.Values
+		cookie *http.Cookie
+		err    error
+	}{
+		{"valid", "GET", "/?code=any_code&state=d4e5f6", nil, cookie, nil},
+		{"missing code", "GET", "/?state=d4e5f6", nil, cookie, ErrMissingCode},
+		{"empty code", "GET", "/?code=&state=d4e5f6", nil, cookie, ErrMissingCode},
+		{"missing state", "GET", "/?code=any_code", nil, cookie, ErrMissingState},
+		{"empty state", "GET", "/?code=any_code&state=", nil, cookie, ErrMissingState},
+		{"duplicate states", "GET", "/?code=any_code&state=d4e5f6&state=other", nil, cookie, ErrDuplicateParam},
+		{"duplicate codes", "GET", "/?code=any_code&code=other&state=d4e5f6", nil, cookie, ErrDuplicateParam},
+		{"repeated equal states", "GET", "/?code=any_code&state=d4e5f6&state=d4e5f6", nil, cookie, nil},
+		{"form_post and query states", "POST", "/?state=other", url.Values{"code": {"any_code"}, "state": {"d4e5f6"}}, cookie, ErrDuplicateParam},
+		{"missing state cookie", "GET", "/?code=any_code&state=d4e5f6", nil, nil, ErrMissingStateCookie},
+		{"long state", "GET", "/?code=any_code&state=" + long, nil, cookie, ErrParamTooLong},
+		{"long code", "GET", "/?code=" + long + "&state=d4e5f6", nil, cookie, ErrParamTooLong},
+		{"long error description", "GET", "/?error=access_denied&error_description=" + long + "&state=d4e5f6", nil, cookie, ErrParamTooLong},
+	}
+	for _, c := range cases {
+		var err error
+		success := func(w http.ResponseWriter, req *http.Request) {
+			fmt.Fprintf(w, "success handler called")
+		}
+		failure := func(w http.ResponseWriter, req *http.Request) {
+			ctx := req.Context()
+			err = gologin.ErrorFromContext(ctx)
+			w.WriteHeader(gologin.StatusCodeFromContext(ctx))
+		}
+		// StateHandler and CallbackHandler with a malformed callback, assert
+		// that:
+		// - the failure handler is called with a distinct error and a 400
+		handler := StateHandler(gologin.DebugOnlyCookieConfig, CallbackHandler(config, http.HandlerFunc(success), http.HandlerFunc(failure)))
+		var body io.Reader
+		if c.form != nil {
+			body = strings.NewReader(c.form.Encode())
+		}
+		req := httptest.NewRequest(c.method, c.target, body)
+		if c.form != nil {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		if c.cookie != nil {
+			req.AddCookie(c.cookie)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if c.err == nil {
+			assert.Equal(t, "success handler called", w.Body.String(), c.name)
+			continue
+		}
+		assert.True(t, errors.Is(err, c.err), "%s: %v", c.name, err)
+		assert.Equal(t, http.StatusBadRequest, w.Code, c.name)
+	}
+}
+
+func TestCallbackHandler_AuthorizationError(t *testing.T) {
+	config := &oauth2.Config{}
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		err := gologin.ErrorFromContext(ctx)
+		if assert.NotNil(t, err) {
+			assert.Equal(t, &AuthorizationError{
+				Code:        "access_denied",
+				Description: "Permissions error",
+				URI:         "https://example.com/error",
+			}, err)
+			assert.True(t, IsAccessDenied(err))
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// CallbackHandler called with an error response, assert that:
+	// - failure handler is called
+	// - an AuthorizationError is added to the ctx
+	callbackHandler := CallbackHandler(config, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?error=access_denied&error_description=Permissions+error&error_uri=https%3A%2F%2Fexample.com%2Ferror&state=d4e5f6", nil)
+	ctx := WithState(context.Background(), "d4e5f6")
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+
+	// error responses POSTed by form_post providers are also detected
+	w = httptest.NewRecorder()
+	form := url.Values{"error": {"access_denied"}, "error_description": {"Permissions error"}, "error_uri": {"https://example.com/error"}, "state": {"d4e5f6"}}
+	req, _ = http.NewRequest("POST", "/", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
 func TestCallbackHandler_MissingCtxState(t *testing.T) {
 	config := &oauth2.Config{}
 	success := testutils.AssertSuccessNotCalled(t)
@@ -181,6 +862,391 @@ func TestCallbackHandler_StateMismatch(t *testing.T) {
 	assert.Equal(t, "failure handler called", w.Body.String())
 }
 
+// TestCallbackHandler_StateMismatchLengths asserts that:
+// - states which differ in length or content fail the same way, since
+// states are compared in constant time regardless of length
+// - the ctx status code is the same
+func TestCallbackHandler_StateMismatchLengths(t *testing.T) {
+	ownerState := "d4e5f6a1b2c3"
+	cases := []string{"d4e5f6a1b2c4", "d4e5f6a1b2c", "d4e5f6a1b2c3d", "d", strings.Repeat("d4e5f6a1b2c3", 100)}
+	for _, state := range cases {
+		failure := func(w http.ResponseWriter, req *http.Request) {
+			ctx := req.Context()
+			assert.Equal(t, ErrInvalidState, gologin.ErrorFromContext(ctx), state)
+			assert.Equal(t, http.StatusBadRequest, gologin.StatusCodeFromContext(ctx), state)
+			fmt.Fprintf(w, "failure handler called")
+		}
+		callbackHandler := CallbackHandler(&oauth2.Config{}, testutils.AssertSuccessNotCalled(t), http.HandlerFunc(failure))
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/?code=any_code&state="+state, nil)
+		ctx := WithState(context.Background(), ownerState)
+		callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+		assert.Equal(t, "failure handler called", w.Body.String(), state)
+	}
+}
+
+func TestCallbackHandler_ExpiredState(t *testing.T) {
+	config := &oauth2.Config{}
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		err := gologin.ErrorFromContext(ctx)
+		if assert.NotNil(t, err) {
+			assert.Equal(t, ErrStateExpired, err)
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// CallbackHandler with a state cookie older than its MaxAge, assert that:
+	// - failure handler is called
+	// - ErrStateExpired is added to the ctx
+	cookie := expiredStateCookie("d4e5f6")
+	handler := StateHandler(gologin.DebugOnlyCookieConfig, CallbackHandler(config, success, http.HandlerFunc(failure)))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state="+url.QueryEscape(cookie.Value), nil)
+	req.AddCookie(cookie)
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestCallbackHandler_FakeClock(t *testing.T) {
+	server := NewAccessTokenServer(t, `{"access_token":"2YotnFZFEjr1zCsicMWpAA","token_type":"Bearer"}`)
+	defer server.Close()
+	config := &oauth2.Config{
+		Endpoint: oauth2.Endpoint{
+			TokenURL: server.URL,
+		},
+	}
+	// DebugOnlyCookieConfig states expire after 60 seconds
+	cookieConfig := gologin.DebugOnlyCookieConfig
+	success := func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, ErrStateExpired, gologin.ErrorFromContext(req.Context()))
+		fmt.Fprintf(w, "failure handler called")
+	}
+	login := func(clock *gologintest.FakeClock) *http.Cookie {
+		var state string
+		handler := StateHandler(cookieConfig, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			state, _ = StateFromContext(req.Context())
+		}))
+		handler = gologin.EntropyHandler(gologintest.NewEntropy(1), gologin.ClockHandler(clock, handler))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "/login", nil))
+		cookies := w.Result().Cookies()
+		if assert.Len(t, cookies, 1) {
+			assert.Equal(t, state, cookies[0].Value)
+			return cookies[0]
+		}
+		return nil
+	}
+
+	cases := []struct {
+		elapsed  time.Duration
+		expected string
+	}{
+		{59 * time.Second, "success handler called"},
+		{61 * time.Second, "failure handler called"},
+		{24 * time.Hour, "failure handler called"},
+	}
+	for _, c := range cases {
+		// StateHandler and CallbackHandler with a FakeClock, assert that:
+		// - states issued with the same entropy are deterministic
+		// - callbacks after the state MaxAge fail with ErrStateExpired,
+		//   without waiting
+		clock := gologintest.NewFakeClock(time.Unix(1500000000, 0))
+		cookie := login(clock)
+		if cookie == nil {
+			continue
+		}
+		assert.Equal(t, cookie.Value, login(gologintest.NewFakeClock(time.Unix(1500000000, 0))).Value)
+		assert.True(t, strings.HasSuffix(cookie.Value, ".1500000000"))
+		clock.Advance(c.elapsed)
+		handler := gologin.ClockHandler(clock, StateHandler(cookieConfig, CallbackHandler(config, http.HandlerFunc(success), http.HandlerFunc(failure))))
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/callback?code=any_code&state="+url.QueryEscape(cookie.Value), nil)
+		req.AddCookie(cookie)
+		handler.ServeHTTP(w, req)
+		assert.Equal(t, c.expected, w.Body.String(), c.elapsed.String())
+	}
+}
+
+func TestCallbackHandler_Replay(t *testing.T) {
+	server := NewAccessTokenServer(t, `{"access_token":"2YotnFZFEjr1zCsicMWpAA","token_type":"example"}`)
+	defer server.Close()
+	config := &oauth2.Config{
+		Endpoint: oauth2.Endpoint{
+			TokenURL: server.URL,
+		},
+	}
+	cookieConfig := gologin.DebugOnlyCookieConfig
+	success := func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(w, "success handler called")
+	}
+	var failureErr error
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		failureErr = gologin.ErrorFromContext(req.Context())
+		fmt.Fprintf(w, "failure handler called")
+	}
+	handler := StateHandler(cookieConfig, CallbackHandler(config, http.HandlerFunc(success), http.HandlerFunc(failure)))
+
+	// StateHandler and CallbackHandler, assert that:
+	// - the state cookie is expired after a successful callback
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	req.AddCookie(&http.Cookie{Name: cookieConfig.Name, Value: "d4e5f6"})
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "success handler called", w.Body.String())
+	cookies := (&http.Response{Header: w.Header()}).Cookies()
+	if !assert.Len(t, cookies, 1) {
+		return
+	}
+	assert.Equal(t, cookieConfig.Name, cookies[0].Name)
+	assert.Equal(t, -1, cookies[0].MaxAge)
+
+	// - a replayed callback without the expired cookie fails with ErrMissingStateCookie
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+	assert.Equal(t, ErrMissingStateCookie, failureErr)
+
+	// - a replayed callback with a consumed cookie fails with ErrStateAlreadyUsed
+	consumed := consumedStateCookie(cookieConfig, "d4e5f6")
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	req.AddCookie(consumed)
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+	assert.Equal(t, ErrStateAlreadyUsed, failureErr)
+
+	// - a login request with the consumed cookie is issued a new state
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/", nil)
+	req.AddCookie(consumed)
+	StateHandler(cookieConfig, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		state, err := StateFromContext(req.Context())
+		assert.Nil(t, err)
+		assert.NotEqual(t, "d4e5f6", state)
+	})).ServeHTTP(w, req)
+	assert.NotEmpty(t, w.Header().Get("Set-Cookie"))
+}
+
+func TestCallbackHandler_ExpiresStateCookie(t *testing.T) {
+	server := NewAccessTokenServer(t, `{"access_token":"2YotnFZFEjr1zCsicMWpAA","token_type":"example"}`)
+	defer server.Close()
+	config := &oauth2.Config{
+		Endpoint: oauth2.Endpoint{
+			TokenURL: server.URL,
+		},
+	}
+	cookieConfig := gologin.CookieConfig{
+		Name:     "custom-state",
+		Domain:   "example.com",
+		Path:     "/auth",
+		MaxAge:   600,
+		HTTPOnly: true,
+	}
+	success := func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(w, "failure handler called")
+	}
+	handler := StateHandler(cookieConfig, CallbackHandler(config, http.HandlerFunc(success), http.HandlerFunc(failure)))
+	state, _ := DefaultStateGenerator()
+	cases := []struct {
+		name  string
+		state string
+		body  string
+	}{
+		{"success", state, "success handler called"},
+		{"state mismatch", "other_state", "failure handler called"},
+	}
+
+	// CallbackHandler assert that:
+	// - the state cookie is expired in the success and failure paths
+	// - the expired cookie has the same name, domain, and path
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/auth/callback?code=any_code&state="+c.state, nil)
+		req.AddCookie(&http.Cookie{Name: cookieConfig.Name, Value: state})
+		handler.ServeHTTP(w, req)
+		assert.Equal(t, c.body, w.Body.String(), c.name)
+		cookies := (&http.Response{Header: w.Header()}).Cookies()
+		if assert.Len(t, cookies, 1, c.name) {
+			assert.Equal(t, "custom-state", cookies[0].Name, c.name)
+			assert.Equal(t, "example.com", cookies[0].Domain, c.name)
+			assert.Equal(t, "/auth", cookies[0].Path, c.name)
+			assert.Equal(t, -1, cookies[0].MaxAge, c.name)
+			assert.Equal(t, "", cookies[0].Value, c.name)
+		}
+	}
+}
+
+func TestCallbackHandler_ExchangeOptions(t *testing.T) {
+	server := NewTestServerFunc(func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "any_code", req.PostFormValue("code"))
+		assert.Equal(t, "https://api.example.com", req.PostFormValue("resource"))
+		assert.Equal(t, "api", req.PostFormValue("audience"))
+		w.Header().Set(contentType, jsonContentType)
+		w.Write([]byte(`{"access_token":"2YotnFZFEjr1zCsicMWpAA","token_type":"example"}`))
+	})
+	defer server.Close()
+	config := &oauth2.Config{
+		Endpoint: oauth2.Endpoint{
+			TokenURL: server.URL,
+		},
+	}
+	success := func(w http.ResponseWriter, req *http.Request) {
+		token, err := TokenFromContext(req.Context())
+		assert.Nil(t, err)
+		assert.Equal(t, "2YotnFZFEjr1zCsicMWpAA", token.AccessToken)
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// CallbackHandler with static and ctx exchange options, assert that:
+	// - static AuthCodeOptions are sent with the code exchange
+	// - ctx exchange options are sent with the code exchange
+	// - the Token is added to the ctx of the success handler
+	callbackHandler := CallbackHandler(config, http.HandlerFunc(success), failure, oauth2.SetAuthURLParam("resource", "https://api.example.com"))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	ctx := WithState(req.Context(), "d4e5f6")
+	ctx = WithExchangeOptions(ctx, oauth2.SetAuthURLParam("audience", "api"))
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestCallbackHandler_ExchangeErrorRetry(t *testing.T) {
+	unavailable := true
+	server := NewTestServerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if unavailable {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set(contentType, jsonContentType)
+		w.Write([]byte(`{"access_token":"2YotnFZFEjr1zCsicMWpAA","token_type":"example"}`))
+	})
+	defer server.Close()
+	config := &oauth2.Config{
+		Endpoint: oauth2.Endpoint{
+			TokenURL: server.URL,
+		},
+	}
+	cookieConfig := gologin.DebugOnlyCookieConfig
+	success := func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(w, "failure handler called")
+	}
+	handler := StateHandler(cookieConfig, CallbackHandler(config, http.HandlerFunc(success), http.HandlerFunc(failure)))
+
+	// CallbackHandler with a failed code exchange, assert that:
+	// - the failure handler is called
+	// - the state cookie is kept
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	req.AddCookie(&http.Cookie{Name: cookieConfig.Name, Value: "d4e5f6"})
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+	assert.Empty(t, w.Header().Get("Set-Cookie"))
+
+	// - retrying with the same state cookie succeeds and expires the cookie
+	unavailable = false
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	req.AddCookie(&http.Cookie{Name: cookieConfig.Name, Value: "d4e5f6"})
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "success handler called", w.Body.String())
+	cookies := (&http.Response{Header: w.Header()}).Cookies()
+	if assert.Len(t, cookies, 1) {
+		assert.Equal(t, -1, cookies[0].MaxAge)
+	}
+
+	// - replaying the successful callback with the expired cookie fails
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestCallbackHandler_ExchangeTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := NewTestServerFunc(func(w http.ResponseWriter, req *http.Request) {
+		// respond slower than the client timeout
+		select {
+		case <-release:
+		case <-time.After(5 * time.Second):
+		}
+	})
+	defer server.Close()
+	defer close(release)
+	config := &oauth2.Config{
+		Endpoint: oauth2.Endpoint{
+			TokenURL: server.URL,
+		},
+	}
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			timeoutErr, ok := err.(interface{ Timeout() bool })
+			assert.True(t, ok && timeoutErr.Timeout(), err.Error())
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// CallbackHandler with a ctx http.Client with a Timeout, assert that:
+	// - the client is used for the code exchange
+	// - failure handler is called with a timeout error
+	client := &http.Client{Timeout: 50 * time.Millisecond}
+	callbackHandler := CallbackHandler(config, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	ctx := gologin.WithHTTPClient(WithState(context.Background(), "d4e5f6"), client)
+	start := time.Now()
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+	assert.True(t, time.Since(start) < time.Second)
+}
+
+func TestCallbackHandler_ExchangeDeadline(t *testing.T) {
+	release := make(chan struct{})
+	server := NewTestServerFunc(func(w http.ResponseWriter, req *http.Request) {
+		// hang until the test ends
+		<-release
+	})
+	defer server.Close()
+	defer close(release)
+	config := &oauth2.Config{
+		Endpoint: oauth2.Endpoint{
+			TokenURL: server.URL,
+		},
+	}
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		assert.True(t, errors.Is(err, context.DeadlineExceeded), "%v", err)
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// CallbackHandler with a TimeoutHandler, assert that:
+	// - the code exchange is aborted after the timeout
+	// - failure handler is called promptly with a DeadlineExceeded error
+	callbackHandler := gologin.TimeoutHandler(50*time.Millisecond, CallbackHandler(config, success, http.HandlerFunc(failure)))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	ctx := WithState(context.Background(), "d4e5f6")
+	start := time.Now()
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+	assert.True(t, time.Since(start) < time.Second)
+}
+
 func TestCallbackHandler_ExchangeError(t *testing.T) {
 	_, server := testutils.NewErrorServer("OAuth2 Service Down", http.StatusInternalServerError)
 	defer server.Close()
@@ -211,3 +1277,48 @@ func TestCallbackHandler_ExchangeError(t *testing.T) {
 	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
 	assert.Equal(t, "failure handler called", w.Body.String())
 }
+
+func TestFailureStatusCodes(t *testing.T) {
+	_, errorServer := testutils.NewErrorServer("OAuth2 Service Down", http.StatusInternalServerError)
+	defer errorServer.Close()
+	config := &oauth2.Config{
+		Endpoint: oauth2.Endpoint{
+			TokenURL: errorServer.URL,
+		},
+	}
+	stateConfig := gologin.CookieConfig{Name: "state", MaxAge: 60}
+	failingGenerator := func() (string, error) {
+		return "", errors.New("entropy unavailable")
+	}
+	expiredState := "d4e5f6" + stateTimestampSeparator + strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+	callback := StateHandler(stateConfig, CallbackHandler(config, testutils.AssertSuccessNotCalled(t), nil))
+	cases := []struct {
+		name    string
+		handler http.Handler
+		url     string
+		cookie  string
+		status  int
+	}{
+		{"login missing state", LoginHandler(config, nil), "/login", "", http.StatusInternalServerError},
+		{"state generator error", StateHandlerWithGenerator(stateConfig, failingGenerator, LoginHandler(config, nil), nil), "/login", "", http.StatusInternalServerError},
+		{"access denied", callback, "/?error=access_denied&state=d4e5f6", "d4e5f6", http.StatusOK},
+		{"authorization error", callback, "/?error=invalid_scope&state=d4e5f6", "d4e5f6", http.StatusBadRequest},
+		{"missing code", callback, "/?state=d4e5f6", "d4e5f6", http.StatusBadRequest},
+		{"missing ctx state", CallbackHandler(config, testutils.AssertSuccessNotCalled(t), nil), "/?code=any_code&state=d4e5f6", "", http.StatusBadRequest},
+		{"state mismatch", callback, "/?code=any_code&state=d4e5f6", "a1b2c3", http.StatusBadRequest},
+		{"state expired", callback, "/?code=any_code&state=" + expiredState, expiredState, http.StatusBadRequest},
+		{"state already used", callback, "/?code=any_code&state=d4e5f6", consumedStatePrefix + "d4e5f6", http.StatusBadRequest},
+		{"exchange error", callback, "/?code=any_code&state=d4e5f6", "d4e5f6", http.StatusBadGateway},
+	}
+	for _, c := range cases {
+		req, _ := http.NewRequest("GET", c.url, nil)
+		if c.cookie != "" {
+			req.AddCookie(&http.Cookie{Name: "state", Value: c.cookie})
+		}
+		w := httptest.NewRecorder()
+		c.handler.ServeHTTP(w, req)
+		// failure points assert that:
+		// - the DefaultFailureHandler responds with the failure point's status
+		assert.Equal(t, c.status, w.Code, c.name)
+	}
+}
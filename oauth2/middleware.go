@@ -0,0 +1,27 @@
+package oauth2
+
+import (
+	"net/http"
+
+	"github.com/dghubble/gologin"
+	"golang.org/x/oauth2"
+)
+
+// StateMiddleware returns middleware which wraps the next handler with a
+// StateHandler, so chains can be declared linearly (e.g. with alice or chi):
+//
+//	chain := alice.New(oauth2.StateMiddleware(stateConfig)).Then(oauth2.LoginHandler(config, nil))
+func StateMiddleware(config gologin.CookieConfig) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return StateHandler(config, next)
+	}
+}
+
+// CallbackMiddleware returns middleware which wraps the next handler with a
+// CallbackHandler, which calls the next handler if authentication succeeds,
+// otherwise the failure handler.
+func CallbackMiddleware(config *oauth2.Config, failure http.Handler, opts ...oauth2.AuthCodeOption) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return CallbackHandler(config, next, failure, opts...)
+	}
+}
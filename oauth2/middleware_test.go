@@ -0,0 +1,48 @@
+package oauth2
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func TestMiddleware(t *testing.T) {
+	server := NewAccessTokenServer(t, `{"access_token": "2YotnFZFEjr1zCsicMWpAA", "token_type": "example"}`)
+	defer server.Close()
+
+	stateConfig := gologin.CookieConfig{Name: "state", Path: "/", MaxAge: 60}
+	config := &oauth2.Config{
+		Endpoint: oauth2.Endpoint{
+			TokenURL: server.URL,
+		},
+	}
+	success := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		state, err := StateFromContext(ctx)
+		assert.Nil(t, err)
+		token, err := TokenFromContext(ctx)
+		assert.Nil(t, err)
+		fmt.Fprintf(w, "%s %s", state, token.AccessToken)
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+	nested := StateHandler(stateConfig, CallbackHandler(config, http.HandlerFunc(success), failure))
+	linear := StateMiddleware(stateConfig)(CallbackMiddleware(config, failure)(http.HandlerFunc(success)))
+
+	// StateMiddleware and CallbackMiddleware assert that:
+	// - the linear chain adds the same state and Token to the ctx as the
+	// nested form
+	for _, handler := range []http.Handler{nested, linear} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+		req.AddCookie(&http.Cookie{Name: "state", Value: "d4e5f6"})
+		handler.ServeHTTP(w, req.WithContext(context.Background()))
+		assert.Equal(t, "d4e5f6 2YotnFZFEjr1zCsicMWpAA", w.Body.String())
+	}
+}
@@ -0,0 +1,194 @@
+package oauth2
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/internal"
+)
+
+const (
+	// multiStateSeparator separates the states of a multi-state cookie
+	multiStateSeparator = "~"
+	// multiStateTimestampSeparator separates a state from its issue time
+	multiStateTimestampSeparator = ":"
+	defaultMaxStates             = 5
+	// maxMultiStateCookieSize keeps multi-state cookies well under the 4096
+	// byte limit browsers apply to a cookie's name, value, and attributes
+	maxMultiStateCookieSize = 3072
+)
+
+// multiStateCookieStore is a StateStore which keeps a bounded set of
+// outstanding states in a signed cookie.
+type multiStateCookieStore struct {
+	config    gologin.CookieConfig
+	key       []byte
+	maxStates int
+}
+
+// multiState is a state and its issue time.
+type multiState struct {
+	state  string
+	issued int64
+}
+
+// NewMultiStateCookieStore returns a StateStore which keeps up to maxStates
+// (default 5) outstanding states in a short-lived cookie signed with
+// HMAC-SHA256 using the server-side key, so users may complete logins started
+// in several tabs. Saving a state evicts the oldest states beyond maxStates
+// (or beyond the cookie size limit) and expired states. Verify accepts the
+// callback "state" parameter if it is any outstanding state and Clear
+// consumes only that state.
+//
+// Verify returns ErrInvalidStateSignature for tampered or malformed cookies,
+// ErrStateNotFound if the state is not outstanding (e.g. it was evicted or
+// already consumed), and, if the CookieConfig MaxAge is positive,
+// ErrStateExpired for states issued more than MaxAge seconds ago.
+func NewMultiStateCookieStore(config gologin.CookieConfig, key []byte, maxStates int) StateStore {
+	if maxStates <= 0 {
+		maxStates = defaultMaxStates
+	}
+	return &multiStateCookieStore{
+		config:    config,
+		key:       key,
+		maxStates: maxStates,
+	}
+}
+
+func (s *multiStateCookieStore) Save(ctx context.Context, w http.ResponseWriter, req *http.Request, state string) error {
+	if state == "" || strings.ContainsAny(state, multiStateSeparator+multiStateTimestampSeparator+signedStateSeparator) {
+		return fmt.Errorf("oauth2: state %q cannot be saved in a multi-state cookie", state)
+	}
+	// invalid cookies (e.g. signed with a rotated key) are replaced
+	states, _ := s.read(req)
+	var outstanding []multiState
+	for _, saved := range states {
+		if !s.expired(ctx, saved) {
+			outstanding = append(outstanding, saved)
+		}
+	}
+	outstanding = append(outstanding, multiState{state: state, issued: gologin.ClockFromContext(ctx).Now().Unix()})
+	if len(outstanding) > s.maxStates {
+		outstanding = outstanding[len(outstanding)-s.maxStates:]
+	}
+	value := s.encode(outstanding)
+	for len(value) > maxMultiStateCookieSize && len(outstanding) > 1 {
+		outstanding = outstanding[1:]
+		value = s.encode(outstanding)
+	}
+	http.SetCookie(w, internal.NewCookie(s.config, value))
+	return nil
+}
+
+func (s *multiStateCookieStore) Verify(ctx context.Context, req *http.Request) (string, error) {
+	states, err := s.read(req)
+	if err != nil {
+		return "", err
+	}
+	i := s.match(states, req.FormValue("state"))
+	if i < 0 {
+		return "", ErrStateNotFound
+	}
+	if s.expired(ctx, states[i]) {
+		return "", ErrStateExpired
+	}
+	return states[i].state, nil
+}
+
+// Clear removes the callback request's state parameter from the cookie,
+// keeping the other outstanding states.
+func (s *multiStateCookieStore) Clear(ctx context.Context, w http.ResponseWriter, req *http.Request) error {
+	states, err := s.read(req)
+	if err != nil {
+		http.SetCookie(w, internal.ExpiredCookie(s.config))
+		return nil
+	}
+	if i := s.match(states, req.FormValue("state")); i >= 0 {
+		states = append(states[:i:i], states[i+1:]...)
+	}
+	if len(states) == 0 {
+		http.SetCookie(w, internal.ExpiredCookie(s.config))
+		return nil
+	}
+	http.SetCookie(w, internal.NewCookie(s.config, s.encode(states)))
+	return nil
+}
+
+// read returns the states of the requester's cookie, oldest first. Returns
+// ErrStateNotFound if there is no cookie or ErrInvalidStateSignature if it is
+// tampered or malformed.
+func (s *multiStateCookieStore) read(req *http.Request) ([]multiState, error) {
+	cookie, err := req.Cookie(s.config.Name)
+	if err != nil || cookie.Value == "" {
+		return nil, ErrStateNotFound
+	}
+	i := strings.LastIndex(cookie.Value, signedStateSeparator)
+	if i < 0 {
+		return nil, ErrInvalidStateSignature
+	}
+	payload, signature := cookie.Value[:i], cookie.Value[i+1:]
+	if !hmac.Equal([]byte(signature), []byte(s.signature(payload))) {
+		return nil, ErrInvalidStateSignature
+	}
+	var states []multiState
+	for _, entry := range strings.Split(payload, multiStateSeparator) {
+		parts := strings.Split(entry, multiStateTimestampSeparator)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, ErrInvalidStateSignature
+		}
+		issued, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil {
+			return nil, ErrInvalidStateSignature
+		}
+		states = append(states, multiState{state: parts[0], issued: issued})
+	}
+	return states, nil
+}
+
+// match returns the index of the state among the states, or -1. Every state
+// is compared in constant time so the time taken does not reveal which
+// states are outstanding.
+func (s *multiStateCookieStore) match(states []multiState, state string) int {
+	matched := -1
+	if state == "" {
+		return matched
+	}
+	for i, saved := range states {
+		if internal.EqualSecrets(saved.state, state) && matched < 0 {
+			matched = i
+		}
+	}
+	return matched
+}
+
+// expired returns true if the state is older than the CookieConfig MaxAge,
+// per the ctx gologin Clock.
+func (s *multiStateCookieStore) expired(ctx context.Context, state multiState) bool {
+	maxAge := time.Duration(s.config.MaxAge) * time.Second
+	return maxAge > 0 && gologin.ClockFromContext(ctx).Now().Sub(time.Unix(state.issued, 0)) > maxAge
+}
+
+// encode returns the signed cookie value of the states.
+func (s *multiStateCookieStore) encode(states []multiState) string {
+	entries := make([]string, len(states))
+	for i, state := range states {
+		entries[i] = state.state + multiStateTimestampSeparator + strconv.FormatInt(state.issued, 10)
+	}
+	payload := strings.Join(entries, multiStateSeparator)
+	return payload + signedStateSeparator + s.signature(payload)
+}
+
+// signature returns the base64 encoded HMAC-SHA256 of the payload.
+func (s *multiStateCookieStore) signature(payload string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
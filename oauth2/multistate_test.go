@@ -0,0 +1,206 @@
+package oauth2
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dghubble/gologin"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+// browser carries the state cookie between requests, like a browser.
+type browser struct {
+	cookie *http.Cookie
+}
+
+// do serves the request with the browser's cookie and stores any state
+// cookie the response sets.
+func (b *browser) do(handler http.Handler, method, target string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	if b.cookie != nil {
+		req.AddCookie(b.cookie)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	for _, cookie := range (&http.Response{Header: w.Header()}).Cookies() {
+		if cookie.Name != gologin.DebugOnlyCookieConfig.Name {
+			continue
+		}
+		if cookie.MaxAge < 0 {
+			b.cookie = nil
+		} else {
+			b.cookie = cookie
+		}
+	}
+	return w
+}
+
+// TestMultiStateCookieStore_Tabs simulates logins started in two tabs and
+// asserts that:
+// - the second login does not replace the first tab's state
+// - callbacks for either tab succeed, in any order
+// - each callback consumes only its own state, so replays fail
+func TestMultiStateCookieStore_Tabs(t *testing.T) {
+	server := NewTestServerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set(contentType, jsonContentType)
+		w.Write([]byte(`{"access_token":"2YotnFZFEjr1zCsicMWpAA","token_type":"example"}`))
+	})
+	defer server.Close()
+	config := &oauth2.Config{
+		ClientID: "client_id",
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  "https://api.example.com/authorize",
+			TokenURL: server.URL,
+		},
+	}
+	store := NewMultiStateCookieStore(gologin.DebugOnlyCookieConfig, testSigningKey, 0)
+	login := StateHandlerWithStore(store, LoginHandler(config, nil), nil)
+	var failures []error
+	failure := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		failures = append(failures, gologin.ErrorFromContext(req.Context()))
+		fmt.Fprintf(w, "failure handler called")
+	})
+	success := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(w, "success handler called")
+	})
+	callback := CallbackHandlerWithStore(config, store, success, failure)
+
+	b := &browser{}
+	authState := func(w *httptest.ResponseRecorder) string {
+		location, err := url.Parse(w.HeaderMap.Get("Location"))
+		assert.Nil(t, err)
+		return location.Query().Get("state")
+	}
+	tab1 := authState(b.do(login, "GET", "/login"))
+	tab2 := authState(b.do(login, "GET", "/login"))
+	assert.NotEqual(t, tab1, tab2)
+
+	// the first tab completes its consent screen after the second tab opened
+	w := b.do(callback, "GET", "/callback?code=any_code&state="+tab1)
+	assert.Equal(t, "success handler called", w.Body.String())
+	w = b.do(callback, "GET", "/callback?code=any_code&state="+tab2)
+	assert.Equal(t, "success handler called", w.Body.String())
+	assert.Nil(t, b.cookie)
+
+	// replayed callbacks are rejected
+	b.do(login, "GET", "/login")
+	w = b.do(callback, "GET", "/callback?code=any_code&state="+tab1)
+	assert.Equal(t, "failure handler called", w.Body.String())
+	assert.Equal(t, []error{ErrStateNotFound}, failures)
+}
+
+func TestMultiStateCookieStore_Eviction(t *testing.T) {
+	store := NewMultiStateCookieStore(gologin.DebugOnlyCookieConfig, testSigningKey, 3)
+	save := StateHandlerWithStore(store, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		state, _ := StateFromContext(req.Context())
+		fmt.Fprint(w, state)
+	}), nil)
+	b := &browser{}
+	var states []string
+	for i := 0; i < 5; i++ {
+		states = append(states, b.do(save, "GET", "/login").Body.String())
+	}
+
+	// NewMultiStateCookieStore with a maxStates of 3, assert that:
+	// - the oldest states are evicted
+	// - outstanding states are verified
+	cases := []struct {
+		state string
+		err   error
+	}{
+		{states[0], ErrStateNotFound},
+		{states[1], ErrStateNotFound},
+		{states[2], nil},
+		{states[3], nil},
+		{states[4], nil},
+		{"", ErrStateNotFound},
+		{"unknown", ErrStateNotFound},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest("GET", "/callback?state="+c.state, nil)
+		req.AddCookie(b.cookie)
+		state, err := store.Verify(context.Background(), req)
+		assert.Equal(t, c.err, err, c.state)
+		if err == nil {
+			assert.Equal(t, c.state, state)
+		}
+	}
+}
+
+func TestMultiStateCookieStore_CookieSize(t *testing.T) {
+	store := NewMultiStateCookieStore(gologin.DebugOnlyCookieConfig, testSigningKey, 100)
+	ctx := context.Background()
+	b := &browser{}
+	for i := 0; i < 20; i++ {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			assert.Nil(t, store.Save(ctx, w, req, strings.Repeat("s", 500)+strconv.Itoa(i)))
+		})
+		b.do(handler, "GET", "/login")
+	}
+
+	// Save with large states, assert that:
+	// - the oldest states are evicted to keep the cookie under the size limit
+	assert.True(t, len(b.cookie.Value) <= maxMultiStateCookieSize, "%d", len(b.cookie.Value))
+	assert.Equal(t, 5, strings.Count(b.cookie.Value, multiStateSeparator)+1)
+
+	// states containing separators cannot be saved
+	w := httptest.NewRecorder()
+	assert.NotNil(t, store.Save(ctx, w, httptest.NewRequest("GET", "/", nil), "a~b"))
+	assert.NotNil(t, store.Save(ctx, w, httptest.NewRequest("GET", "/", nil), ""))
+}
+
+func TestMultiStateCookieStore_Verify(t *testing.T) {
+	store := NewMultiStateCookieStore(gologin.DebugOnlyCookieConfig, testSigningKey, 0).(*multiStateCookieStore)
+	now := time.Now().Unix()
+	expired := time.Now().Add(-2 * time.Minute).Unix()
+	valid := store.encode([]multiState{{"old_state", expired}, {"some_state", now}})
+	cases := []struct {
+		value string
+		state string
+		err   error
+	}{
+		{valid, "some_state", nil},
+		{valid, "old_state", ErrStateExpired},
+		{"", "some_state", ErrStateNotFound},
+		{strings.Replace(valid, "some_state", "other_state", 1), "other_state", ErrInvalidStateSignature},
+		{"some_state:" + strconv.FormatInt(now, 10), "some_state", ErrInvalidStateSignature},
+		{NewMultiStateCookieStore(gologin.DebugOnlyCookieConfig, []byte("other-key"), 0).(*multiStateCookieStore).encode([]multiState{{"some_state", now}}), "some_state", ErrInvalidStateSignature},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest("GET", "/callback?state="+c.state, nil)
+		req.AddCookie(&http.Cookie{Name: gologin.DebugOnlyCookieConfig.Name, Value: c.value})
+		state, err := store.Verify(context.Background(), req)
+		assert.Equal(t, c.err, err, c.value)
+		if err == nil {
+			assert.Equal(t, c.state, state)
+		}
+	}
+}
+
+func TestMultiStateCookieStore_Clear(t *testing.T) {
+	store := NewMultiStateCookieStore(gologin.DebugOnlyCookieConfig, testSigningKey, 0).(*multiStateCookieStore)
+	now := time.Now().Unix()
+	value := store.encode([]multiState{{"state_a", now}, {"state_b", now}})
+
+	// Clear, assert that:
+	// - only the callback state is removed
+	// - the cookie is expired once no states are outstanding
+	b := &browser{cookie: &http.Cookie{Name: gologin.DebugOnlyCookieConfig.Name, Value: value}}
+	clear := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		assert.Nil(t, store.Clear(req.Context(), w, req))
+	})
+	b.do(clear, "GET", "/callback?state=state_a")
+	if assert.NotNil(t, b.cookie) {
+		assert.Equal(t, store.encode([]multiState{{"state_b", now}}), b.cookie.Value)
+	}
+	b.do(clear, "GET", "/callback?state=state_b")
+	assert.Nil(t, b.cookie)
+}
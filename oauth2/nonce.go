@@ -0,0 +1,58 @@
+package oauth2
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/internal"
+)
+
+// Errors which may occur with OpenID Connect nonces.
+var (
+	ErrMissingNonce = errors.New("oauth2: missing OpenID Connect nonce")
+)
+
+// NonceHandler adds an OpenID Connect nonce to the ctx. On login requests, a
+// non-guessable nonce is generated and set in a short-lived cookie so that
+// LoginHandler sends it in the authorization request. On callback requests
+// (which carry a "state" parameter), the nonce is read from the cookie and
+// the cookie is cleared so each nonce is used only once. Provider handlers
+// compare NonceFromContext against the id_token "nonce" claim.
+//
+// If a callback request has no nonce cookie, the failure handler is called
+// with ErrMissingNonce. The config Name must differ from the state cookie
+// name.
+func NonceHandler(config gologin.CookieConfig, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		if req.FormValue("state") == "" {
+			// login phase, issue a new nonce
+			nonce, err := randomState(ctx)
+			if err != nil {
+				ctx = gologin.WithError(ctx, err)
+				ctx = gologin.WithStatusCode(ctx, http.StatusInternalServerError)
+				failure.ServeHTTP(w, req.WithContext(ctx))
+				return
+			}
+			http.SetCookie(w, internal.NewCookie(config, nonce))
+			ctx = WithNonce(ctx, nonce)
+			success.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		// callback phase, read and clear the nonce
+		cookie, err := req.Cookie(config.Name)
+		if err != nil || cookie.Value == "" {
+			ctx = gologin.WithError(ctx, ErrMissingNonce)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		http.SetCookie(w, internal.ExpiredCookie(config))
+		ctx = WithNonce(ctx, cookie.Value)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return callbackFormHandler(http.HandlerFunc(fn), failure)
+}
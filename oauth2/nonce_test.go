@@ -0,0 +1,95 @@
+package oauth2
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+var testNonceCookieConfig = gologin.CookieConfig{
+	Name:   "nonce",
+	Path:   "/",
+	MaxAge: 60,
+}
+
+func TestNonceHandler_Login(t *testing.T) {
+	config := &oauth2.Config{
+		ClientID: "client_id",
+		Endpoint: oauth2.Endpoint{
+			AuthURL: "https://api.example.com/authorize",
+		},
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// NonceHandler on a login request, assert that:
+	// - a nonce cookie is set
+	// - LoginHandler sends the nonce in the redirect url
+	handler := NonceHandler(testNonceCookieConfig, LoginHandler(config, failure), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	ctx := WithState(context.Background(), "state_val")
+	handler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, http.StatusFound, w.Code)
+	cookies := (&http.Response{Header: w.Header()}).Cookies()
+	if assert.Len(t, cookies, 1) {
+		assert.Equal(t, "nonce", cookies[0].Name)
+		assert.NotEmpty(t, cookies[0].Value)
+		location, err := url.Parse(w.HeaderMap.Get("Location"))
+		if assert.Nil(t, err) {
+			assert.Equal(t, cookies[0].Value, location.Query().Get("nonce"))
+		}
+	}
+}
+
+func TestNonceHandler_Callback(t *testing.T) {
+	success := func(w http.ResponseWriter, req *http.Request) {
+		nonce, err := NonceFromContext(req.Context())
+		assert.Nil(t, err)
+		assert.Equal(t, "some_nonce", nonce)
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// NonceHandler on a callback request, assert that:
+	// - the cookie nonce is added to the ctx
+	// - the nonce cookie is cleared
+	handler := NonceHandler(testNonceCookieConfig, http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	req.AddCookie(&http.Cookie{Name: "nonce", Value: "some_nonce"})
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "success handler called", w.Body.String())
+	cookies := (&http.Response{Header: w.Header()}).Cookies()
+	if assert.Len(t, cookies, 1) {
+		assert.Equal(t, "", cookies[0].Value)
+		assert.True(t, cookies[0].MaxAge < 0)
+	}
+}
+
+func TestNonceHandler_CallbackMissingNonce(t *testing.T) {
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.Equal(t, ErrMissingNonce, err)
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// NonceHandler on a callback request without a nonce cookie, assert that:
+	// - failure handler is called
+	// - ErrMissingNonce is added to the ctx
+	handler := NonceHandler(testNonceCookieConfig, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
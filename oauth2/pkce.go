@@ -0,0 +1,82 @@
+package oauth2
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/internal"
+	"golang.org/x/oauth2"
+)
+
+// Errors which may occur with PKCE.
+var (
+	ErrMissingPKCEVerifier = errors.New("oauth2: missing PKCE code verifier")
+)
+
+const codeChallengeMethodS256 = "S256"
+
+// LoginHandlerWithPKCE handles OAuth2 login requests like LoginHandler, but
+// also generates a PKCE (RFC 7636) code verifier, persists it in a
+// short-lived cookie, and sends the S256 code challenge in the redirect.
+//
+// The cookieConfig Name must differ from the name of the state cookie so the
+// two cookies do not clobber one another.
+func LoginHandlerWithPKCE(config *oauth2.Config, cookieConfig gologin.CookieConfig, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	success := LoginHandler(config, failure, opts...)
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		verifier, err := randomState(ctx)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			ctx = gologin.WithStatusCode(ctx, http.StatusInternalServerError)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		http.SetCookie(w, internal.NewCookie(cookieConfig, verifier))
+		ctx = WithPKCEVerifier(ctx, verifier)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// CallbackHandlerWithPKCE handles OAuth2 redirection URI requests like
+// CallbackHandler, but reads the PKCE code verifier from the cookie set by
+// LoginHandlerWithPKCE and sends it with the code exchange. The verifier
+// cookie is cleared so each verifier is used only once.
+//
+// If the verifier cookie is missing (or has expired), the failure handler is
+// called with a 400 and ErrMissingPKCEVerifier.
+func CallbackHandlerWithPKCE(config *oauth2.Config, cookieConfig gologin.CookieConfig, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	success = CallbackHandler(config, success, failure)
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		cookie, err := req.Cookie(cookieConfig.Name)
+		if err != nil || cookie.Value == "" {
+			ctx = gologin.WithError(ctx, ErrMissingPKCEVerifier)
+			ctx = gologin.WithStatusCode(ctx, http.StatusBadRequest)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		// expire the verifier cookie, verifiers are single use
+		http.SetCookie(w, internal.ExpiredCookie(cookieConfig))
+		ctx = WithPKCEVerifier(ctx, cookie.Value)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// codeChallengeS256 returns the S256 code challenge for the code verifier.
+// https://tools.ietf.org/html/rfc7636#section-4.2
+func codeChallengeS256(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
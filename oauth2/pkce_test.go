@@ -0,0 +1,146 @@
+package oauth2
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+var testPKCECookieConfig = gologin.CookieConfig{
+	Name:   "pkce",
+	Path:   "/",
+	MaxAge: 60,
+}
+
+func TestLoginHandlerWithPKCE(t *testing.T) {
+	config := &oauth2.Config{
+		ClientID:    "client_id",
+		RedirectURL: "redirect_url",
+		Endpoint: oauth2.Endpoint{
+			AuthURL: "https://api.example.com/authorize",
+		},
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// LoginHandlerWithPKCE assert that:
+	// - redirects to the oauth2.Config AuthURL
+	// - a code verifier cookie is set
+	// - redirect url contains the S256 code challenge of the verifier
+	loginHandler := LoginHandlerWithPKCE(config, testPKCECookieConfig, failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	ctx := WithState(context.Background(), "state_val")
+	loginHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, http.StatusFound, w.Code)
+
+	cookies := (&http.Response{Header: w.Header()}).Cookies()
+	if assert.Len(t, cookies, 1) {
+		assert.Equal(t, "pkce", cookies[0].Name)
+		assert.Len(t, cookies[0].Value, 43)
+		location, err := url.Parse(w.HeaderMap.Get("Location"))
+		assert.Nil(t, err)
+		assert.Equal(t, "state_val", location.Query().Get("state"))
+		assert.Equal(t, codeChallengeS256(cookies[0].Value), location.Query().Get("code_challenge"))
+		assert.Equal(t, "S256", location.Query().Get("code_challenge_method"))
+	}
+}
+
+func TestLoginHandlerWithPKCE_MissingCtxState(t *testing.T) {
+	config := &oauth2.Config{}
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		err := gologin.ErrorFromContext(ctx)
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "oauth2: Context missing state value", err.Error())
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	loginHandler := LoginHandlerWithPKCE(config, testPKCECookieConfig, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	loginHandler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestCallbackHandlerWithPKCE(t *testing.T) {
+	server := NewTestServerFunc(func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "POST", req.Method)
+		assert.Equal(t, "some_verifier", req.PostFormValue("code_verifier"))
+		w.Header().Set(contentType, jsonContentType)
+		w.Write([]byte(`{"access_token":"2YotnFZFEjr1zCsicMWpAA","token_type":"example"}`))
+	})
+	defer server.Close()
+
+	config := &oauth2.Config{
+		Endpoint: oauth2.Endpoint{
+			TokenURL: server.URL,
+		},
+	}
+	success := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := TokenFromContext(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, "2YotnFZFEjr1zCsicMWpAA", token.AccessToken)
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// CallbackHandlerWithPKCE assert that:
+	// - the code verifier cookie is sent with the code exchange
+	// - the code verifier cookie is cleared
+	// - success handler is called
+	callbackHandler := CallbackHandlerWithPKCE(config, testPKCECookieConfig, http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	req.AddCookie(&http.Cookie{Name: "pkce", Value: "some_verifier"})
+	ctx := WithState(context.Background(), "d4e5f6")
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+
+	cookies := (&http.Response{Header: w.Header()}).Cookies()
+	if assert.Len(t, cookies, 1) {
+		assert.Equal(t, "pkce", cookies[0].Name)
+		assert.Equal(t, "", cookies[0].Value)
+		assert.True(t, cookies[0].MaxAge < 0)
+	}
+}
+
+func TestCallbackHandlerWithPKCE_MissingVerifier(t *testing.T) {
+	config := &oauth2.Config{}
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		err := gologin.ErrorFromContext(ctx)
+		if assert.NotNil(t, err) {
+			assert.Equal(t, ErrMissingPKCEVerifier, err)
+		}
+		assert.Equal(t, http.StatusBadRequest, gologin.StatusCodeFromContext(ctx))
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// CallbackHandlerWithPKCE called without a verifier cookie (e.g. expired),
+	// assert that:
+	// - failure handler is called
+	// - ErrMissingPKCEVerifier and a 400 status code are added to the ctx
+	callbackHandler := CallbackHandlerWithPKCE(config, testPKCECookieConfig, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	ctx := WithState(context.Background(), "d4e5f6")
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestCodeChallengeS256(t *testing.T) {
+	// https://tools.ietf.org/html/rfc7636#appendix-B
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	assert.Equal(t, "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM", codeChallengeS256(verifier))
+}
@@ -0,0 +1,91 @@
+package oauth2
+
+import (
+	"net/http"
+	"strings"
+
+	"golang.org/x/oauth2"
+)
+
+// RedirectURLConfig configures RedirectURLHandler.
+type RedirectURLConfig struct {
+	// CallbackPath is the path of the callback route (e.g. "/github/callback").
+	CallbackPath string
+	// TrustForwardedHeaders derives the scheme and host from the
+	// X-Forwarded-Proto and X-Forwarded-Host headers, if present. Only enable
+	// it behind a proxy which sets (or strips) these headers, otherwise
+	// clients may choose the redirect URL host.
+	TrustForwardedHeaders bool
+}
+
+// RedirectURLHandler derives the redirect URL from the request's scheme and
+// Host and the config CallbackPath (e.g. https://tenant.example.com/callback)
+// and adds it to the ctx. LoginHandler and CallbackHandler use the ctx
+// redirect URL instead of the oauth2.Config RedirectURL, so one config may
+// serve several hostnames. Wrap both the login and callback handlers so the
+// same redirect URL is sent in the authorization request and code exchange.
+func RedirectURLHandler(config RedirectURLConfig, success http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := WithRedirectURL(req.Context(), requestRedirectURL(req, config))
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// requestRedirectURL returns the redirect URL for the request.
+func requestRedirectURL(req *http.Request, config RedirectURLConfig) string {
+	scheme, host := requestOrigin(req, config.TrustForwardedHeaders)
+	path := config.CallbackPath
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return scheme + "://" + host + path
+}
+
+// requestOrigin returns the scheme and host of the request, or of the
+// X-Forwarded-Proto and X-Forwarded-Host headers if trusted.
+func requestOrigin(req *http.Request, trustForwardedHeaders bool) (scheme, host string) {
+	scheme = "http"
+	if req.TLS != nil {
+		scheme = "https"
+	}
+	host = req.Host
+	if trustForwardedHeaders {
+		if proto := strings.ToLower(forwardedValue(req, "X-Forwarded-Proto")); proto == "http" || proto == "https" {
+			scheme = proto
+		}
+		if forwardedHost := forwardedValue(req, "X-Forwarded-Host"); validHost(forwardedHost) {
+			host = forwardedHost
+		}
+	}
+	return scheme, host
+}
+
+// forwardedValue returns the first (client-most) value of a forwarding
+// header, which proxies may append to as a comma separated list.
+func forwardedValue(req *http.Request, header string) string {
+	value := req.Header.Get(header)
+	if i := strings.Index(value, ","); i >= 0 {
+		value = value[:i]
+	}
+	return strings.TrimSpace(value)
+}
+
+// validHost returns true if the host is a non-empty host[:port] without URL
+// delimiters or whitespace.
+func validHost(host string) bool {
+	return host != "" && !strings.ContainsAny(host, "/\\?#@ \t\r\n")
+}
+
+// redirectConfig returns the config, or a shallow copy with the ctx redirect
+// URL, if any. Configs are copied rather than mutated since they are shared
+// by concurrent requests.
+func redirectConfig(req *http.Request, config *oauth2.Config) *oauth2.Config {
+	redirectURL, err := RedirectURLFromContext(req.Context())
+	if err != nil || redirectURL == "" {
+		return config
+	}
+	c := *config
+	c.RedirectURL = redirectURL
+	return &c
+}
@@ -0,0 +1,166 @@
+package oauth2
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func TestRedirectURLHandler(t *testing.T) {
+	cases := []struct {
+		name     string
+		config   RedirectURLConfig
+		target   string
+		headers  map[string]string
+		expected string
+	}{
+		{
+			name:     "direct",
+			config:   RedirectURLConfig{CallbackPath: "/callback"},
+			target:   "http://tenant.example.com/login",
+			expected: "http://tenant.example.com/callback",
+		},
+		{
+			name:     "direct with port",
+			config:   RedirectURLConfig{CallbackPath: "callback"},
+			target:   "http://localhost:8080/login",
+			expected: "http://localhost:8080/callback",
+		},
+		{
+			name:     "direct TLS",
+			config:   RedirectURLConfig{CallbackPath: "/callback"},
+			target:   "https://tenant.example.com/login",
+			expected: "https://tenant.example.com/callback",
+		},
+		{
+			name:   "trusted proxy",
+			config: RedirectURLConfig{CallbackPath: "/callback", TrustForwardedHeaders: true},
+			target: "http://10.0.0.1:8080/login",
+			headers: map[string]string{
+				"X-Forwarded-Proto": "https",
+				"X-Forwarded-Host":  "tenant.example.com",
+			},
+			expected: "https://tenant.example.com/callback",
+		},
+		{
+			name:   "trusted proxy chain",
+			config: RedirectURLConfig{CallbackPath: "/callback", TrustForwardedHeaders: true},
+			target: "http://10.0.0.1:8080/login",
+			headers: map[string]string{
+				"X-Forwarded-Proto": "HTTPS, http",
+				"X-Forwarded-Host":  "tenant.example.com, 10.0.0.2",
+			},
+			expected: "https://tenant.example.com/callback",
+		},
+		{
+			name:     "trusted proxy without headers",
+			config:   RedirectURLConfig{CallbackPath: "/callback", TrustForwardedHeaders: true},
+			target:   "http://tenant.example.com/login",
+			expected: "http://tenant.example.com/callback",
+		},
+		{
+			name:   "trusted proxy invalid headers",
+			config: RedirectURLConfig{CallbackPath: "/callback", TrustForwardedHeaders: true},
+			target: "http://tenant.example.com/login",
+			headers: map[string]string{
+				"X-Forwarded-Proto": "javascript",
+				"X-Forwarded-Host":  "evil.example.com/path",
+			},
+			expected: "http://tenant.example.com/callback",
+		},
+		{
+			name:   "untrusted proxy",
+			config: RedirectURLConfig{CallbackPath: "/callback"},
+			target: "http://tenant.example.com/login",
+			headers: map[string]string{
+				"X-Forwarded-Proto": "https",
+				"X-Forwarded-Host":  "evil.example.com",
+			},
+			expected: "http://tenant.example.com/callback",
+		},
+	}
+	for _, c := range cases {
+		var redirectURL string
+		success := func(w http.ResponseWriter, req *http.Request) {
+			var err error
+			redirectURL, err = RedirectURLFromContext(req.Context())
+			assert.Nil(t, err)
+		}
+		req := httptest.NewRequest("GET", c.target, nil)
+		for name, value := range c.headers {
+			req.Header.Set(name, value)
+		}
+		RedirectURLHandler(c.config, http.HandlerFunc(success)).ServeHTTP(httptest.NewRecorder(), req)
+		assert.Equal(t, c.expected, redirectURL, c.name)
+	}
+}
+
+func TestLoginHandler_RedirectURL(t *testing.T) {
+	config := &oauth2.Config{
+		ClientID:    "client_id",
+		RedirectURL: "https://static.example.com/callback",
+		Endpoint: oauth2.Endpoint{
+			AuthURL: "https://api.example.com/authorize",
+		},
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// LoginHandler with a RedirectURLHandler, assert that:
+	// - the AuthURL redirect_uri is derived from the request
+	// - the shared oauth2.Config is not mutated
+	handler := RedirectURLHandler(RedirectURLConfig{CallbackPath: "/callback"}, LoginHandler(config, failure))
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "http://tenant.example.com/login", nil)
+	ctx := WithState(req.Context(), "state_val")
+	handler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, http.StatusFound, w.Code)
+	location, err := url.Parse(w.HeaderMap.Get("Location"))
+	assert.Nil(t, err)
+	assert.Equal(t, "http://tenant.example.com/callback", location.Query().Get("redirect_uri"))
+	assert.Equal(t, "https://static.example.com/callback", config.RedirectURL)
+}
+
+func TestCallbackHandler_RedirectURL(t *testing.T) {
+	server := NewTestServerFunc(func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "https://tenant.example.com/callback", req.PostFormValue("redirect_uri"))
+		w.Header().Set(contentType, jsonContentType)
+		w.Write([]byte(`{"access_token":"2YotnFZFEjr1zCsicMWpAA","token_type":"example"}`))
+	})
+	defer server.Close()
+	config := &oauth2.Config{
+		RedirectURL: "https://static.example.com/callback",
+		Endpoint: oauth2.Endpoint{
+			TokenURL: server.URL,
+		},
+	}
+	success := func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// CallbackHandler with a proxied RedirectURLHandler, assert that:
+	// - the code exchange redirect_uri is derived from the forwarding headers
+	// - the shared oauth2.Config is not mutated
+	urlConfig := RedirectURLConfig{CallbackPath: "/callback", TrustForwardedHeaders: true}
+	handler := RedirectURLHandler(urlConfig, CallbackHandler(config, http.HandlerFunc(success), failure))
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "http://10.0.0.1/callback?code=any_code&state=d4e5f6", nil)
+	req.Header.Set("X-Forwarded-Proto", "https")
+	req.Header.Set("X-Forwarded-Host", "tenant.example.com")
+	ctx := WithState(req.Context(), "d4e5f6")
+	handler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+	assert.Equal(t, "https://static.example.com/callback", config.RedirectURL)
+}
+
+func TestRedirectURLFromContext(t *testing.T) {
+	_, err := RedirectURLFromContext(context.Background())
+	assert.Equal(t, fmt.Errorf("oauth2: Context missing redirect URL"), err)
+}
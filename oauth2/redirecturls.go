@@ -0,0 +1,168 @@
+package oauth2
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/dghubble/gologin"
+)
+
+// Errors which may occur with allowed redirect URLs.
+var (
+	ErrRedirectHostNotAllowed = errors.New("oauth2: request host is not the host of an allowed redirect URL")
+	ErrInvalidRedirectState   = errors.New("oauth2: state is not signed for an allowed redirect URL")
+)
+
+const redirectStateSeparator = "~"
+
+// RedirectURLsConfig configures RedirectURLsHandler.
+type RedirectURLsConfig struct {
+	// StateCookie configures the state cookie, like the StateHandler
+	// CookieConfig.
+	StateCookie gologin.CookieConfig
+	// RedirectURLs are the redirect URLs registered with the provider (e.g.
+	// https://www.example.com/callback and https://app.example.com/callback),
+	// one per host. Required.
+	RedirectURLs []string
+	// Key signs the redirect URL a state was issued for with HMAC-SHA256.
+	// Required.
+	Key []byte
+	// TrustForwardedHeaders matches the X-Forwarded-Host header, if present,
+	// rather than the request Host. Only enable it behind a proxy which sets
+	// (or strips) the header.
+	TrustForwardedHeaders bool
+}
+
+// RedirectURLsHandler lets one oauth2.Config and handler chain serve several
+// registered redirect URLs. Use it in place of StateHandler on both the
+// login and callback routes.
+//
+// On login requests, the RedirectURL whose host matches the request host is
+// added to the ctx (see WithRedirectURL) for LoginHandler, and a state is
+// issued like StateHandler with the index of that RedirectURL signed into
+// it. Hosts without a RedirectURL call the failure handler with a 400 and
+// ErrRedirectHostNotAllowed, rather than sending users to the provider for a
+// redirect_uri mismatch. On callback requests (which carry a "state"
+// parameter), the RedirectURL signed into the state is added to the ctx, so
+// CallbackHandler exchanges the code with the same redirect URL. States
+// which are not signed for a RedirectURL fail with a 400 and
+// ErrInvalidRedirectState.
+//
+// Panics if the config has no Key, no RedirectURLs, or RedirectURLs which
+// are not absolute or share a host.
+func RedirectURLsHandler(config RedirectURLsConfig, success, failure http.Handler) http.Handler {
+	if len(config.Key) == 0 {
+		panic("oauth2: RedirectURLsConfig requires a Key")
+	}
+	hosts := config.mustHosts()
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	callbackStates := stateHandler(config.StateCookie, nil, nil, success, failure)
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		if state := req.FormValue("state"); state != "" {
+			// callback phase, use the redirect URL signed into the state
+			index, err := config.index(state)
+			if err != nil {
+				ctx = gologin.WithError(ctx, err)
+				ctx = gologin.WithStatusCode(ctx, http.StatusBadRequest)
+				failure.ServeHTTP(w, req.WithContext(ctx))
+				return
+			}
+			ctx = WithRedirectURL(ctx, config.RedirectURLs[index])
+			callbackStates.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		// login phase, choose the redirect URL of the request host
+		_, host := requestOrigin(req, config.TrustForwardedHeaders)
+		index := -1
+		for i, allowed := range hosts {
+			if strings.EqualFold(allowed, host) {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			ctx = gologin.WithError(ctx, fmt.Errorf("%w: %s", ErrRedirectHostNotAllowed, host))
+			ctx = gologin.WithStatusCode(ctx, http.StatusBadRequest)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		ctx = WithRedirectURL(ctx, config.RedirectURLs[index])
+		generate := func() (string, error) {
+			state, err := GenerateState(contextStateConfig(ctx))
+			if err != nil {
+				return "", err
+			}
+			return config.sign(index, state), nil
+		}
+		// reuse cookie states only if they were issued for this redirect URL
+		reuse := func(state string) bool {
+			i, err := config.index(state)
+			return err == nil && i == index
+		}
+		stateHandler(config.StateCookie, generate, reuse, success, failure).ServeHTTP(w, req.WithContext(ctx))
+	}
+	return callbackFormHandler(http.HandlerFunc(fn), failure)
+}
+
+// mustHosts returns the hosts of the RedirectURLs. Panics if there are no
+// RedirectURLs or they are not absolute or share a host.
+func (c RedirectURLsConfig) mustHosts() []string {
+	if len(c.RedirectURLs) == 0 {
+		panic("oauth2: RedirectURLsConfig requires RedirectURLs")
+	}
+	hosts := make([]string, len(c.RedirectURLs))
+	for i, redirectURL := range c.RedirectURLs {
+		u, err := url.Parse(redirectURL)
+		if err != nil || !u.IsAbs() || u.Host == "" {
+			panic(fmt.Sprintf("oauth2: RedirectURLsConfig RedirectURL %q is not an absolute URL", redirectURL))
+		}
+		for _, host := range hosts[:i] {
+			if strings.EqualFold(host, u.Host) {
+				panic(fmt.Sprintf("oauth2: RedirectURLsConfig has several RedirectURLs for host %s", u.Host))
+			}
+		}
+		hosts[i] = u.Host
+	}
+	return hosts
+}
+
+// sign returns the state with the index of its redirect URL and their
+// signature, as index~signature~state.
+func (c RedirectURLsConfig) sign(index int, state string) string {
+	i := strconv.Itoa(index)
+	return i + redirectStateSeparator + c.signature(index, state) + redirectStateSeparator + state
+}
+
+// index returns the index of the redirect URL signed into the state.
+func (c RedirectURLsConfig) index(state string) (int, error) {
+	parts := strings.SplitN(state, redirectStateSeparator, 3)
+	if len(parts) != 3 {
+		return 0, ErrInvalidRedirectState
+	}
+	index, err := strconv.Atoi(parts[0])
+	if err != nil || index < 0 || index >= len(c.RedirectURLs) {
+		return 0, ErrInvalidRedirectState
+	}
+	if !hmac.Equal([]byte(parts[1]), []byte(c.signature(index, parts[2]))) {
+		return 0, ErrInvalidRedirectState
+	}
+	return index, nil
+}
+
+// signature returns the base64url encoded HMAC-SHA256 of the redirect URL
+// and the state.
+func (c RedirectURLsConfig) signature(index int, state string) string {
+	mac := hmac.New(sha256.New, c.Key)
+	mac.Write([]byte("redirect" + redirectStateSeparator + c.RedirectURLs[index] + redirectStateSeparator + state))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
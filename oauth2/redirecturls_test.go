@@ -0,0 +1,184 @@
+package oauth2
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+var testRedirectURLsConfig = RedirectURLsConfig{
+	StateCookie: gologin.CookieConfig{Name: "redirect-state", Path: "/", MaxAge: 600},
+	RedirectURLs: []string{
+		"https://www.example.com/callback",
+		"https://app.example.com/auth/callback",
+	},
+	Key: []byte("redirect-state-signing-key"),
+}
+
+func TestRedirectURLsHandler(t *testing.T) {
+	var exchanged []string
+	server := NewTestServerFunc(func(w http.ResponseWriter, req *http.Request) {
+		exchanged = append(exchanged, req.PostFormValue("redirect_uri"))
+		w.Header().Set(contentType, jsonContentType)
+		w.Write([]byte(`{"access_token":"2YotnFZFEjr1zCsicMWpAA","token_type":"example"}`))
+	})
+	defer server.Close()
+	config := &oauth2.Config{
+		ClientID:    "client_id",
+		RedirectURL: "https://unused.example.com/callback",
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  "https://provider.example.com/authorize",
+			TokenURL: server.URL,
+		},
+	}
+	success := func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+	login := RedirectURLsHandler(testRedirectURLsConfig, LoginHandler(config, failure), failure)
+	callback := RedirectURLsHandler(testRedirectURLsConfig, CallbackHandler(config, http.HandlerFunc(success), failure), failure)
+
+	cases := []struct {
+		loginURL    string
+		redirectURL string
+	}{
+		{"https://www.example.com/login", "https://www.example.com/callback"},
+		{"https://APP.example.com/login", "https://app.example.com/auth/callback"},
+	}
+	for _, c := range cases {
+		// RedirectURLsHandler on one login and callback chain, assert that:
+		// - the login redirect has the redirect URL of the request host
+		w := httptest.NewRecorder()
+		login.ServeHTTP(w, httptest.NewRequest("GET", c.loginURL, nil))
+		assert.Equal(t, http.StatusFound, w.Code)
+		location, err := url.Parse(w.HeaderMap.Get("Location"))
+		assert.Nil(t, err)
+		assert.Equal(t, c.redirectURL, location.Query().Get("redirect_uri"))
+		state := location.Query().Get("state")
+		cookies := w.Result().Cookies()
+		if assert.Len(t, cookies, 1) {
+			assert.Equal(t, state, cookies[0].Value)
+		}
+
+		// - the code exchange carries the same redirect URL
+		w = httptest.NewRecorder()
+		req := httptest.NewRequest("GET", c.redirectURL+"?code=any_code&state="+url.QueryEscape(state), nil)
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+		callback.ServeHTTP(w, req)
+		assert.Equal(t, "success handler called", w.Body.String())
+		if assert.NotEmpty(t, exchanged) {
+			assert.Equal(t, c.redirectURL, exchanged[len(exchanged)-1])
+		}
+	}
+	assert.Len(t, exchanged, 2)
+}
+
+func TestRedirectURLsHandler_ReuseState(t *testing.T) {
+	var states []string
+	success := func(w http.ResponseWriter, req *http.Request) {
+		state, _ := StateFromContext(req.Context())
+		states = append(states, state)
+	}
+	handler := RedirectURLsHandler(testRedirectURLsConfig, http.HandlerFunc(success), testutils.AssertFailureNotCalled(t))
+
+	// RedirectURLsHandler with a state cookie shared by hosts, assert that:
+	// - the cookie state is reused for its own redirect URL
+	// - a new state is issued for another redirect URL
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "https://www.example.com/login", nil))
+	cookies := w.Result().Cookies()
+	for _, target := range []string{"https://www.example.com/login", "https://app.example.com/login"} {
+		req := httptest.NewRequest("GET", target, nil)
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	if assert.Len(t, states, 3) {
+		assert.Equal(t, states[0], states[1])
+		assert.NotEqual(t, states[0], states[2])
+		index, err := testRedirectURLsConfig.index(states[2])
+		assert.Nil(t, err)
+		assert.Equal(t, 1, index)
+	}
+}
+
+func TestRedirectURLsHandler_Errors(t *testing.T) {
+	valid := testRedirectURLsConfig.sign(0, "d4e5f6")
+	// swap the index, keeping the signature for the first redirect URL
+	swapped := "1" + strings.TrimPrefix(valid, "0")
+	cases := []struct {
+		name   string
+		target string
+		err    error
+	}{
+		{"unknown host", "https://staging.example.com/login", ErrRedirectHostNotAllowed},
+		{"unsigned state", "https://www.example.com/callback?code=any_code&state=d4e5f6", ErrInvalidRedirectState},
+		{"swapped index", "https://app.example.com/auth/callback?code=any_code&state=" + url.QueryEscape(swapped), ErrInvalidRedirectState},
+		{"out of range index", "https://app.example.com/auth/callback?code=any_code&state=" + url.QueryEscape("7"+strings.TrimPrefix(valid, "0")), ErrInvalidRedirectState},
+	}
+	for _, c := range cases {
+		failure := func(w http.ResponseWriter, req *http.Request) {
+			ctx := req.Context()
+			err := gologin.ErrorFromContext(ctx)
+			assert.True(t, errors.Is(err, c.err), c.name)
+			w.WriteHeader(gologin.StatusCodeFromContext(ctx))
+		}
+
+		// RedirectURLsHandler with an unknown host or state, assert that:
+		// - the failure handler is called with a 400 before any redirect
+		handler := RedirectURLsHandler(testRedirectURLsConfig, testutils.AssertSuccessNotCalled(t), http.HandlerFunc(failure))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", c.target, nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code, c.name)
+		assert.Empty(t, w.HeaderMap.Get("Location"), c.name)
+	}
+
+	// unknown hosts are named in the error
+	var err error
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err = gologin.ErrorFromContext(req.Context())
+	}
+	RedirectURLsHandler(testRedirectURLsConfig, nil, http.HandlerFunc(failure)).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "https://staging.example.com/login", nil))
+	assert.Equal(t, "oauth2: request host is not the host of an allowed redirect URL: staging.example.com", err.Error())
+
+	// invalid configs panic
+	assert.Panics(t, func() {
+		RedirectURLsHandler(RedirectURLsConfig{RedirectURLs: []string{"https://www.example.com/callback"}}, nil, nil)
+	})
+	assert.Panics(t, func() { RedirectURLsHandler(RedirectURLsConfig{Key: []byte("key")}, nil, nil) })
+	assert.Panics(t, func() {
+		RedirectURLsHandler(RedirectURLsConfig{Key: []byte("key"), RedirectURLs: []string{"/callback"}}, nil, nil)
+	})
+	assert.Panics(t, func() {
+		RedirectURLsHandler(RedirectURLsConfig{Key: []byte("key"), RedirectURLs: []string{"https://www.example.com/a", "https://WWW.example.com/b"}}, nil, nil)
+	})
+}
+
+func TestRedirectURLsHandler_TrustForwardedHeaders(t *testing.T) {
+	config := testRedirectURLsConfig
+	config.TrustForwardedHeaders = true
+	var redirectURL string
+	success := func(w http.ResponseWriter, req *http.Request) {
+		redirectURL, _ = RedirectURLFromContext(req.Context())
+	}
+
+	// RedirectURLsHandler behind a trusted proxy, assert that:
+	// - the X-Forwarded-Host chooses the redirect URL
+	handler := RedirectURLsHandler(config, http.HandlerFunc(success), testutils.AssertFailureNotCalled(t))
+	req := httptest.NewRequest("GET", "http://10.0.0.1:8080/login", nil)
+	req.Header.Set("X-Forwarded-Host", "app.example.com")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, "https://app.example.com/auth/callback", redirectURL)
+}
@@ -0,0 +1,6 @@
+// Package redisstate provides a Redis-backed oauth2 StateStore.
+//
+// States (and any PKCE code verifier or OpenID Connect nonce in the ctx) are
+// stored under a random ID with a TTL and the ID is set in a small cookie.
+// Callback verification deletes the entry so each state is used only once.
+package redisstate
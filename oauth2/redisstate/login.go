@@ -0,0 +1,48 @@
+package redisstate
+
+import (
+	"net/http"
+
+	"github.com/dghubble/gologin"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"golang.org/x/oauth2"
+)
+
+// CallbackHandler handles OAuth2 redirection URI requests like oauth2
+// CallbackHandlerWithStore, but also adds any PKCE code verifier or nonce
+// saved with the state to the ctx.
+func CallbackHandler(config *oauth2.Config, store *Store, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	success = clearCookieHandler(store, success)
+	success = oauth2Login.CallbackHandler(config, success, failure)
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		entry, err := store.VerifyEntry(ctx, req)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		ctx = oauth2Login.WithState(ctx, entry.State)
+		if entry.Verifier != "" {
+			ctx = oauth2Login.WithPKCEVerifier(ctx, entry.Verifier)
+		}
+		if entry.Nonce != "" {
+			ctx = oauth2Login.WithNonce(ctx, entry.Nonce)
+		}
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// clearCookieHandler expires the state cookie before calling the success
+// handler.
+func clearCookieHandler(store *Store, success http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		store.Clear(req.Context(), w, req)
+		success.ServeHTTP(w, req)
+	}
+	return http.HandlerFunc(fn)
+}
@@ -0,0 +1,172 @@
+package redisstate
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/internal"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+)
+
+// Errors which may occur when verifying a state.
+var (
+	ErrInvalidStateCookie = errors.New("redisstate: missing or invalid state cookie")
+	ErrStateExpired       = errors.New("redisstate: state expired")
+	ErrStateNotFound      = errors.New("redisstate: state not found")
+	ErrStateReplayed      = errors.New("redisstate: state already used")
+)
+
+const (
+	defaultTTL       = 10 * time.Minute
+	defaultKeyPrefix = "gologin:state:"
+	cookieSeparator  = "|"
+)
+
+// Client is the minimal Redis client used by Store. Small adapters allow
+// go-redis, redigo, or other clients to be used.
+type Client interface {
+	// Get returns the value of the key or "" and a nil error if the key does
+	// not exist.
+	Get(ctx context.Context, key string) (string, error)
+	// Set sets the value of the key with the given expiry.
+	Set(ctx context.Context, key, value string, ttl time.Duration) error
+	// Del deletes the keys and returns the number of keys deleted.
+	Del(ctx context.Context, keys ...string) (int64, error)
+}
+
+// Config configures a Store.
+type Config struct {
+	// Cookie configures the cookie which holds the state ID.
+	Cookie gologin.CookieConfig
+	// TTL is how long a state remains valid. Defaults to 10 minutes.
+	TTL time.Duration
+	// KeyPrefix is prepended to state IDs to form Redis keys. Defaults to
+	// "gologin:state:".
+	KeyPrefix string
+}
+
+// Entry is the data stored for a state ID.
+type Entry struct {
+	State    string `json:"state"`
+	Verifier string `json:"verifier,omitempty"`
+	Nonce    string `json:"nonce,omitempty"`
+}
+
+// Store is an oauth2 StateStore backed by Redis.
+type Store struct {
+	client Client
+	config Config
+}
+
+// NewStore returns a new Store which uses the given Redis Client.
+func NewStore(client Client, config Config) *Store {
+	if config.TTL <= 0 {
+		config.TTL = defaultTTL
+	}
+	if config.KeyPrefix == "" {
+		config.KeyPrefix = defaultKeyPrefix
+	}
+	return &Store{
+		client: client,
+		config: config,
+	}
+}
+
+// Save stores the state, along with any PKCE code verifier or nonce in the
+// ctx, under a random ID and sets the ID in the state cookie.
+func (s *Store) Save(ctx context.Context, w http.ResponseWriter, req *http.Request, state string) error {
+	entry := &Entry{State: state}
+	entry.Verifier, _ = oauth2Login.PKCEVerifierFromContext(ctx)
+	entry.Nonce, _ = oauth2Login.NonceFromContext(ctx)
+	value, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	id, err := randomID(ctx)
+	if err != nil {
+		return err
+	}
+	if err := s.client.Set(ctx, s.config.KeyPrefix+id, string(value), s.config.TTL); err != nil {
+		return err
+	}
+	issued := strconv.FormatInt(gologin.ClockFromContext(ctx).Now().Unix(), 10)
+	http.SetCookie(w, internal.NewCookie(s.config.Cookie, id+cookieSeparator+issued))
+	return nil
+}
+
+// Verify returns the state saved for the requester's state cookie, deleting
+// it from Redis so it cannot be replayed.
+func (s *Store) Verify(ctx context.Context, req *http.Request) (string, error) {
+	entry, err := s.VerifyEntry(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	return entry.State, nil
+}
+
+// VerifyEntry returns the Entry saved for the requester's state cookie,
+// deleting it from Redis so it cannot be replayed.
+func (s *Store) VerifyEntry(ctx context.Context, req *http.Request) (*Entry, error) {
+	cookie, err := req.Cookie(s.config.Cookie.Name)
+	if err != nil {
+		return nil, ErrInvalidStateCookie
+	}
+	parts := strings.Split(cookie.Value, cookieSeparator)
+	if len(parts) != 2 || parts[0] == "" {
+		return nil, ErrInvalidStateCookie
+	}
+	id := parts[0]
+	issued, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return nil, ErrInvalidStateCookie
+	}
+	if gologin.ClockFromContext(ctx).Now().Sub(time.Unix(issued, 0)) > s.config.TTL {
+		return nil, ErrStateExpired
+	}
+
+	key := s.config.KeyPrefix + id
+	value, err := s.client.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if value == "" {
+		return nil, ErrStateNotFound
+	}
+	// only the request which deletes the entry may use it
+	deleted, err := s.client.Del(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if deleted == 0 {
+		return nil, ErrStateReplayed
+	}
+	entry := new(Entry)
+	if err := json.Unmarshal([]byte(value), entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// Clear expires the state cookie. The Redis entry is deleted by Verify.
+func (s *Store) Clear(ctx context.Context, w http.ResponseWriter, req *http.Request) error {
+	http.SetCookie(w, internal.ExpiredCookie(s.config.Cookie))
+	return nil
+}
+
+// randomID returns a non-guessable state ID from the ctx entropy (see
+// gologin.WithEntropy), or an error if the entropy cannot be read.
+func randomID(ctx context.Context) (string, error) {
+	b := make([]byte, 32)
+	if _, err := io.ReadFull(gologin.EntropyFromContext(ctx), b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
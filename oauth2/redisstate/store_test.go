@@ -0,0 +1,293 @@
+package redisstate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/dghubble/gologin"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+// Store must be usable with oauth2 StateHandlerWithStore
+var _ oauth2Login.StateStore = (*Store)(nil)
+
+var testConfig = Config{
+	Cookie: gologin.CookieConfig{
+		Name:   "state-id",
+		Path:   "/",
+		MaxAge: 600,
+	},
+	TTL: time.Minute,
+}
+
+// fakeClient is an in-memory Client with key expiry.
+type fakeClient struct {
+	mu      sync.Mutex
+	values  map[string]string
+	expires map[string]time.Time
+	// beforeDel is called before Del to simulate concurrent requests
+	beforeDel func(key string)
+}
+
+func newFakeClient() *fakeClient {
+	return &fakeClient{
+		values:  make(map[string]string),
+		expires: make(map[string]time.Time),
+	}
+}
+
+func (c *fakeClient) Get(ctx context.Context, key string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if time.Now().After(c.expires[key]) {
+		delete(c.values, key)
+	}
+	return c.values[key], nil
+}
+
+func (c *fakeClient) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[key] = value
+	c.expires[key] = time.Now().Add(ttl)
+	return nil
+}
+
+func (c *fakeClient) Del(ctx context.Context, keys ...string) (int64, error) {
+	if c.beforeDel != nil {
+		for _, key := range keys {
+			c.beforeDel(key)
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var deleted int64
+	for _, key := range keys {
+		if _, ok := c.values[key]; ok {
+			delete(c.values, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// saveState saves a state with the Store and returns the state cookie.
+func saveState(t *testing.T, store *Store, ctx context.Context, state string) *http.Cookie {
+	w := httptest.NewRecorder()
+	err := store.Save(ctx, w, nil, state)
+	assert.Nil(t, err)
+	cookies := (&http.Response{Header: w.Header()}).Cookies()
+	if assert.Len(t, cookies, 1) {
+		return cookies[0]
+	}
+	return nil
+}
+
+func TestStore(t *testing.T) {
+	client := newFakeClient()
+	store := NewStore(client, testConfig)
+	ctx := oauth2Login.WithPKCEVerifier(context.Background(), "some_verifier")
+	ctx = oauth2Login.WithNonce(ctx, "some_nonce")
+
+	// Save stores the entry and sets a state ID cookie
+	cookie := saveState(t, store, ctx, "some_state")
+	assert.Equal(t, "state-id", cookie.Name)
+	assert.Len(t, client.values, 1)
+
+	// VerifyEntry returns the entry and deletes it
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.AddCookie(cookie)
+	entry, err := store.VerifyEntry(ctx, req)
+	assert.Nil(t, err)
+	assert.Equal(t, &Entry{State: "some_state", Verifier: "some_verifier", Nonce: "some_nonce"}, entry)
+	assert.Len(t, client.values, 0)
+}
+
+func TestStore_SaveEntropyError(t *testing.T) {
+	client := newFakeClient()
+	store := NewStore(client, testConfig)
+	ctx := gologin.WithEntropy(context.Background(), iotest.ErrReader(errors.New("entropy unavailable")))
+
+	// Save with unreadable entropy, assert that:
+	// - the error is returned
+	// - no entry or cookie is saved
+	w := httptest.NewRecorder()
+	err := store.Save(ctx, w, nil, "some_state")
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "entropy unavailable", err.Error())
+	}
+	assert.Len(t, client.values, 0)
+	assert.Empty(t, w.Header().Get("Set-Cookie"))
+
+	// StateHandlerWithStore fails with a 500
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, http.StatusInternalServerError, gologin.StatusCodeFromContext(req.Context()))
+		fmt.Fprintf(w, "failure handler called")
+	}
+	w = httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/login", nil)
+	oauth2Login.StateHandlerWithStore(store, testutils.AssertSuccessNotCalled(t), http.HandlerFunc(failure)).ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestStore_Verify(t *testing.T) {
+	store := NewStore(newFakeClient(), testConfig)
+	ctx := context.Background()
+	cookie := saveState(t, store, ctx, "some_state")
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.AddCookie(cookie)
+	state, err := store.Verify(ctx, req)
+	assert.Nil(t, err)
+	assert.Equal(t, "some_state", state)
+
+	// verifying the same state cookie again fails
+	_, err = store.Verify(ctx, req)
+	assert.Equal(t, ErrStateNotFound, err)
+}
+
+func TestStore_VerifyErrors(t *testing.T) {
+	ctx := context.Background()
+	issued := func(d time.Duration) string {
+		return strconv.FormatInt(time.Now().Add(d).Unix(), 10)
+	}
+	cases := []struct {
+		cookie *http.Cookie
+		err    error
+	}{
+		{nil, ErrInvalidStateCookie},
+		{&http.Cookie{Name: "state-id", Value: "malformed"}, ErrInvalidStateCookie},
+		{&http.Cookie{Name: "state-id", Value: "abc|notatime"}, ErrInvalidStateCookie},
+		{&http.Cookie{Name: "state-id", Value: "abc|" + issued(-2*time.Minute)}, ErrStateExpired},
+		{&http.Cookie{Name: "state-id", Value: "abc|" + issued(0)}, ErrStateNotFound},
+	}
+	store := NewStore(newFakeClient(), testConfig)
+	for _, c := range cases {
+		req, _ := http.NewRequest("GET", "/", nil)
+		if c.cookie != nil {
+			req.AddCookie(c.cookie)
+		}
+		_, err := store.Verify(ctx, req)
+		assert.Equal(t, c.err, err)
+	}
+}
+
+func TestStore_VerifyExpiredKey(t *testing.T) {
+	config := testConfig
+	config.TTL = 10 * time.Millisecond
+	store := NewStore(newFakeClient(), config)
+	cookie := saveState(t, store, context.Background(), "some_state")
+	time.Sleep(20 * time.Millisecond)
+
+	// the Redis key has expired, though the cookie issue time (in seconds)
+	// may not appear to have
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.AddCookie(cookie)
+	_, err := store.Verify(context.Background(), req)
+	assert.Contains(t, []error{ErrStateExpired, ErrStateNotFound}, err)
+}
+
+func TestStore_VerifyReplayed(t *testing.T) {
+	client := newFakeClient()
+	store := NewStore(client, testConfig)
+	ctx := context.Background()
+	cookie := saveState(t, store, ctx, "some_state")
+	// a concurrent request deletes the entry between Get and Del
+	client.beforeDel = func(key string) {
+		client.mu.Lock()
+		defer client.mu.Unlock()
+		delete(client.values, key)
+	}
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.AddCookie(cookie)
+	_, err := store.Verify(ctx, req)
+	assert.Equal(t, ErrStateReplayed, err)
+}
+
+func TestCallbackHandler(t *testing.T) {
+	var exchange url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		req.ParseForm()
+		exchange = req.PostForm
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"2YotnFZFEjr1zCsicMWpAA","token_type":"example"}`))
+	}))
+	defer server.Close()
+	config := &oauth2.Config{
+		Endpoint: oauth2.Endpoint{
+			TokenURL: server.URL,
+		},
+	}
+	store := NewStore(newFakeClient(), testConfig)
+	ctx := oauth2Login.WithPKCEVerifier(context.Background(), "some_verifier")
+	ctx = oauth2Login.WithNonce(ctx, "some_nonce")
+	cookie := saveState(t, store, ctx, "d4e5f6")
+
+	success := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, "2YotnFZFEjr1zCsicMWpAA", token.AccessToken)
+		nonce, err := oauth2Login.NonceFromContext(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, "some_nonce", nonce)
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// CallbackHandler assert that:
+	// - the saved PKCE code verifier is sent with the code exchange
+	// - the saved nonce and the Token are added to the ctx
+	// - the state cookie is cleared
+	callbackHandler := CallbackHandler(config, store, http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	req.AddCookie(cookie)
+	callbackHandler.ServeHTTP(w, req)
+	assert.Equal(t, "success handler called", w.Body.String())
+	assert.Equal(t, "some_verifier", exchange.Get("code_verifier"))
+	cookies := (&http.Response{Header: w.Header()}).Cookies()
+	if assert.Len(t, cookies, 1) {
+		assert.Equal(t, "", cookies[0].Value)
+		assert.True(t, cookies[0].MaxAge < 0)
+	}
+}
+
+func TestCallbackHandler_Replayed(t *testing.T) {
+	config := &oauth2.Config{}
+	store := NewStore(newFakeClient(), testConfig)
+	cookie := saveState(t, store, context.Background(), "d4e5f6")
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	req.AddCookie(cookie)
+	// consume the state
+	store.Verify(context.Background(), req)
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.Equal(t, ErrStateNotFound, err)
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// CallbackHandler with a state cookie which was already used, assert that:
+	// - failure handler is called
+	callbackHandler := CallbackHandler(config, store, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	callbackHandler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
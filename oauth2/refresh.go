@@ -0,0 +1,93 @@
+package oauth2
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/dghubble/gologin"
+	"golang.org/x/oauth2"
+)
+
+// Errors which may occur when refreshing a Token.
+var (
+	ErrRefreshTokenRevoked = errors.New("oauth2: refresh token revoked or expired")
+)
+
+// TokenSourceProvider loads the stored Token for a request.
+type TokenSourceProvider interface {
+	Token(req *http.Request) (*oauth2.Token, error)
+}
+
+// TokenSourceProviderFunc is an adapter to allow an ordinary function to be
+// used as a TokenSourceProvider.
+type TokenSourceProviderFunc func(req *http.Request) (*oauth2.Token, error)
+
+// Token calls f(req).
+func (f TokenSourceProviderFunc) Token(req *http.Request) (*oauth2.Token, error) {
+	return f(req)
+}
+
+// TokenSaveFunc writes a refreshed Token back to storage.
+type TokenSaveFunc func(req *http.Request, token *oauth2.Token) error
+
+// RefreshHandler loads the stored Token for the request from the provider and
+// refreshes it with the config TokenSource if it has expired. Refreshed
+// Tokens are passed to the save func (if non-nil) so they can be persisted.
+// The valid Token is added to the ctx and the success handler is called.
+//
+// If the provider rejects the refresh token (invalid_grant), e.g. because the
+// user revoked access, the failure handler is called with
+// ErrRefreshTokenRevoked and the user should login again. Other errors, such
+// as transient network errors, are passed to the failure handler unchanged.
+func RefreshHandler(config *oauth2.Config, provider TokenSourceProvider, save TokenSaveFunc, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := provider.Token(req)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		refreshed, err := config.TokenSource(ctx, token).Token()
+		if err != nil {
+			if isInvalidGrant(err) {
+				err = ErrRefreshTokenRevoked
+			}
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		if save != nil && refreshed.AccessToken != token.AccessToken {
+			if err := save(req, refreshed); err != nil {
+				ctx = gologin.WithError(ctx, err)
+				failure.ServeHTTP(w, req.WithContext(ctx))
+				return
+			}
+		}
+		ctx = WithToken(ctx, refreshed)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// isInvalidGrant returns true if the error is a token endpoint invalid_grant
+// error response.
+// https://tools.ietf.org/html/rfc6749#section-5.2
+func isInvalidGrant(err error) bool {
+	retrieveErr, ok := err.(*oauth2.RetrieveError)
+	if !ok {
+		return false
+	}
+	errResp := new(errorJSON)
+	if json.Unmarshal(retrieveErr.Body, errResp) == nil && errResp.Code != "" {
+		return errResp.Code == "invalid_grant"
+	}
+	// form encoded error responses
+	values, err := url.ParseQuery(string(retrieveErr.Body))
+	return err == nil && values.Get("error") == "invalid_grant"
+}
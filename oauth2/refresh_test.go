@@ -0,0 +1,167 @@
+package oauth2
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func expiredTokenProvider() TokenSourceProvider {
+	return TokenSourceProviderFunc(func(req *http.Request) (*oauth2.Token, error) {
+		return &oauth2.Token{
+			AccessToken:  "expired_token",
+			RefreshToken: "tGzv3JOkF0XG5Qx2TlKWIA",
+			Expiry:       time.Now().Add(-time.Hour),
+		}, nil
+	})
+}
+
+func TestRefreshHandler(t *testing.T) {
+	server := NewAccessTokenServer(t, `{"access_token":"2YotnFZFEjr1zCsicMWpAA","token_type":"example","expires_in":3600}`)
+	defer server.Close()
+	config := &oauth2.Config{
+		Endpoint: oauth2.Endpoint{
+			TokenURL: server.URL,
+		},
+	}
+	var saved *oauth2.Token
+	save := func(req *http.Request, token *oauth2.Token) error {
+		saved = token
+		return nil
+	}
+	success := func(w http.ResponseWriter, req *http.Request) {
+		token, err := TokenFromContext(req.Context())
+		assert.Nil(t, err)
+		assert.Equal(t, "2YotnFZFEjr1zCsicMWpAA", token.AccessToken)
+		assert.True(t, token.Valid())
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// RefreshHandler with an expired Token, assert that:
+	// - the Token is refreshed
+	// - the refreshed Token is saved
+	// - success handler is called with the refreshed Token
+	handler := RefreshHandler(config, expiredTokenProvider(), save, http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "success handler called", w.Body.String())
+	if assert.NotNil(t, saved) {
+		assert.Equal(t, "2YotnFZFEjr1zCsicMWpAA", saved.AccessToken)
+		// the refresh token is kept if the provider does not issue a new one
+		assert.Equal(t, "tGzv3JOkF0XG5Qx2TlKWIA", saved.RefreshToken)
+	}
+}
+
+func TestRefreshHandler_ValidToken(t *testing.T) {
+	provider := TokenSourceProviderFunc(func(req *http.Request) (*oauth2.Token, error) {
+		return &oauth2.Token{AccessToken: "valid_token", Expiry: time.Now().Add(time.Hour)}, nil
+	})
+	save := func(req *http.Request, token *oauth2.Token) error {
+		assert.Fail(t, "save func should not be called")
+		return nil
+	}
+	success := func(w http.ResponseWriter, req *http.Request) {
+		token, err := TokenFromContext(req.Context())
+		assert.Nil(t, err)
+		assert.Equal(t, "valid_token", token.AccessToken)
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// RefreshHandler with a valid Token, assert that:
+	// - the Token is not refreshed or saved
+	handler := RefreshHandler(&oauth2.Config{}, provider, save, http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestRefreshHandler_InvalidGrant(t *testing.T) {
+	server := NewTestServerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set(contentType, jsonContentType)
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"invalid_grant","error_description":"Token has been expired or revoked."}`))
+	})
+	defer server.Close()
+	config := &oauth2.Config{
+		Endpoint: oauth2.Endpoint{
+			TokenURL: server.URL,
+		},
+	}
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.Equal(t, ErrRefreshTokenRevoked, err)
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// RefreshHandler with a revoked refresh token, assert that:
+	// - failure handler is called with ErrRefreshTokenRevoked
+	handler := RefreshHandler(config, expiredTokenProvider(), nil, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestRefreshHandler_ServerError(t *testing.T) {
+	server := NewTestServerFunc(func(w http.ResponseWriter, req *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	})
+	defer server.Close()
+	config := &oauth2.Config{
+		Endpoint: oauth2.Endpoint{
+			TokenURL: server.URL,
+		},
+	}
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.NotEqual(t, ErrRefreshTokenRevoked, err)
+			assert.IsType(t, &oauth2.RetrieveError{}, err)
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// RefreshHandler when the token endpoint is unavailable, assert that:
+	// - failure handler is called with the transient error
+	handler := RefreshHandler(config, expiredTokenProvider(), nil, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestRefreshHandler_ProviderError(t *testing.T) {
+	provider := TokenSourceProviderFunc(func(req *http.Request) (*oauth2.Token, error) {
+		return nil, errors.New("no stored token")
+	})
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "no stored token", err.Error())
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	handler := RefreshHandler(&oauth2.Config{}, provider, nil, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
@@ -0,0 +1,109 @@
+package oauth2
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/internal"
+)
+
+// Errors which may occur with return URLs.
+var (
+	ErrInvalidReturnURL = errors.New("oauth2: invalid return URL")
+)
+
+const defaultReturnURLParam = "next"
+
+// ReturnURLConfig configures ReturnURLHandler.
+type ReturnURLConfig struct {
+	// Cookie configures the short-lived cookie which holds the return URL.
+	// The Name must differ from the state cookie name.
+	Cookie gologin.CookieConfig
+	// Param is the login request query parameter with the return URL.
+	// Defaults to "next".
+	Param string
+	// AllowedHosts lists hosts to which absolute http(s) return URLs may
+	// point. Relative paths are always allowed.
+	AllowedHosts []string
+}
+
+// ReturnURLHandler preserves a "return to" URL through the login round trip.
+// On login requests, the URL in the configured query parameter (e.g.
+// ?next=/settings) is validated, set in a short-lived cookie, and added to the
+// ctx. On callback requests (which carry a "state" parameter), the URL is read
+// from the cookie, validated again, added to the ctx, and the cookie is
+// cleared. Use ReturnURLFromContext or ReturnURLRedirectHandler in the
+// success handler.
+//
+// Return URLs must be relative paths or absolute http(s) URLs on one of the
+// AllowedHosts to prevent open redirects. Otherwise, the failure handler is
+// called with ErrInvalidReturnURL. Requests without a return URL are passed
+// through unchanged.
+func ReturnURLHandler(config ReturnURLConfig, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	param := config.Param
+	if param == "" {
+		param = defaultReturnURLParam
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		var returnURL string
+		if req.FormValue("state") == "" {
+			// login phase, read the return URL parameter
+			returnURL = req.FormValue(param)
+			if returnURL == "" {
+				success.ServeHTTP(w, req)
+				return
+			}
+			if !validReturnURL(returnURL, config.AllowedHosts) {
+				ctx = gologin.WithError(ctx, ErrInvalidReturnURL)
+				failure.ServeHTTP(w, req.WithContext(ctx))
+				return
+			}
+			value := base64.RawURLEncoding.EncodeToString([]byte(returnURL))
+			http.SetCookie(w, internal.NewCookie(config.Cookie, value))
+		} else {
+			// callback phase, read and clear the return URL cookie
+			cookie, err := req.Cookie(config.Cookie.Name)
+			if err != nil || cookie.Value == "" {
+				success.ServeHTTP(w, req)
+				return
+			}
+			http.SetCookie(w, internal.ExpiredCookie(config.Cookie))
+			decoded, err := base64.RawURLEncoding.DecodeString(cookie.Value)
+			returnURL = string(decoded)
+			if err != nil || !validReturnURL(returnURL, config.AllowedHosts) {
+				ctx = gologin.WithError(ctx, ErrInvalidReturnURL)
+				failure.ServeHTTP(w, req.WithContext(ctx))
+				return
+			}
+		}
+		ctx = WithReturnURL(ctx, returnURL)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return callbackFormHandler(http.HandlerFunc(fn), failure)
+}
+
+// ReturnURLRedirectHandler returns a success handler which redirects to the
+// return URL from the ctx, if present, or to the defaultPath otherwise.
+func ReturnURLRedirectHandler(defaultPath string) http.Handler {
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		returnURL, err := ReturnURLFromContext(req.Context())
+		if err != nil {
+			returnURL = defaultPath
+		}
+		http.Redirect(w, req, returnURL, http.StatusFound)
+	}
+	return http.HandlerFunc(fn)
+}
+
+// validReturnURL returns true if the return URL is a relative path or an
+// absolute http(s) URL on one of the allowed hosts (see gologin
+// SafeRedirectPath).
+func validReturnURL(returnURL string, allowedHosts []string) bool {
+	return gologin.SafeRedirectPath(returnURL, allowedHosts)
+}
@@ -0,0 +1,222 @@
+package oauth2
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+var testReturnURLConfig = ReturnURLConfig{
+	Cookie: gologin.CookieConfig{
+		Name:   "return-to",
+		Path:   "/",
+		MaxAge: 60,
+	},
+	AllowedHosts: []string{"app.example.com"},
+}
+
+func TestReturnURLHandler_Login(t *testing.T) {
+	success := func(w http.ResponseWriter, req *http.Request) {
+		returnURL, err := ReturnURLFromContext(req.Context())
+		assert.Nil(t, err)
+		assert.Equal(t, "/settings?tab=profile", returnURL)
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// ReturnURLHandler on a login request with a next parameter, assert that:
+	// - a return URL cookie is set
+	// - the return URL is added to the ctx
+	handler := ReturnURLHandler(testReturnURLConfig, http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?next="+url.QueryEscape("/settings?tab=profile"), nil)
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "success handler called", w.Body.String())
+	cookies := (&http.Response{Header: w.Header()}).Cookies()
+	if assert.Len(t, cookies, 1) {
+		assert.Equal(t, "return-to", cookies[0].Name)
+		assert.NotEmpty(t, cookies[0].Value)
+	}
+}
+
+func TestReturnURLHandler_LoginNoReturnURL(t *testing.T) {
+	success := func(w http.ResponseWriter, req *http.Request) {
+		_, err := ReturnURLFromContext(req.Context())
+		assert.NotNil(t, err)
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// ReturnURLHandler on a login request without a next parameter, assert that:
+	// - success handler is called without a return URL
+	// - no cookie is set
+	handler := ReturnURLHandler(testReturnURLConfig, http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "success handler called", w.Body.String())
+	assert.Empty(t, w.Header().Get("Set-Cookie"))
+}
+
+func TestReturnURLHandler_LoginInvalidReturnURL(t *testing.T) {
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.Equal(t, ErrInvalidReturnURL, err)
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// ReturnURLHandler on a login request with an off-site next parameter,
+	// assert that:
+	// - failure handler is called with ErrInvalidReturnURL
+	handler := ReturnURLHandler(testReturnURLConfig, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?next="+url.QueryEscape("https://evil.example.com/"), nil)
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestReturnURLHandler_Callback(t *testing.T) {
+	success := func(w http.ResponseWriter, req *http.Request) {
+		returnURL, err := ReturnURLFromContext(req.Context())
+		assert.Nil(t, err)
+		assert.Equal(t, "https://app.example.com/settings", returnURL)
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// ReturnURLHandler on a callback request, assert that:
+	// - the cookie return URL is added to the ctx
+	// - the return URL cookie is cleared
+	handler := ReturnURLHandler(testReturnURLConfig, http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	value := base64.RawURLEncoding.EncodeToString([]byte("https://app.example.com/settings"))
+	req.AddCookie(&http.Cookie{Name: "return-to", Value: value})
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "success handler called", w.Body.String())
+	cookies := (&http.Response{Header: w.Header()}).Cookies()
+	if assert.Len(t, cookies, 1) {
+		assert.Equal(t, "", cookies[0].Value)
+		assert.True(t, cookies[0].MaxAge < 0)
+	}
+}
+
+func TestReturnURLHandler_CallbackInvalidCookie(t *testing.T) {
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.Equal(t, ErrInvalidReturnURL, err)
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// ReturnURLHandler on a callback request with a forged cookie, assert that:
+	// - failure handler is called with ErrInvalidReturnURL
+	handler := ReturnURLHandler(testReturnURLConfig, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	value := base64.RawURLEncoding.EncodeToString([]byte("//evil.example.com"))
+	req.AddCookie(&http.Cookie{Name: "return-to", Value: value})
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestReturnURLRedirectHandler(t *testing.T) {
+	handler := ReturnURLRedirectHandler("/home")
+
+	// with a return URL in the ctx
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	ctx := WithReturnURL(context.Background(), "/settings")
+	handler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "/settings", w.HeaderMap.Get("Location"))
+
+	// without a return URL in the ctx
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "/home", w.HeaderMap.Get("Location"))
+}
+
+func TestReturnURLHandler_RequireLogin(t *testing.T) {
+	server := NewAccessTokenServer(t, `{"access_token":"2YotnFZFEjr1zCsicMWpAA","token_type":"example"}`)
+	defer server.Close()
+	config := &oauth2.Config{
+		ClientID: "client_id",
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  "https://api.example.com/authorize",
+			TokenURL: server.URL,
+		},
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+	requireLogin := gologin.RequireLogin(gologin.RequireLoginOptions{
+		IsAuthenticated: func(req *http.Request) bool { return false },
+	})
+	protected := requireLogin(testutils.AssertSuccessNotCalled(t))
+	login := ReturnURLHandler(testReturnURLConfig, StateHandler(gologin.DebugOnlyCookieConfig, LoginHandler(config, failure)), failure)
+	callback := StateHandler(gologin.DebugOnlyCookieConfig, ReturnURLHandler(testReturnURLConfig, CallbackHandler(config, ReturnURLRedirectHandler("/"), failure), failure))
+
+	// RequireLogin with ReturnURLHandler on the login and callback routes,
+	// assert that:
+	// - the protected route redirects to the login route with the request URL
+	// - the callback redirects to the original request URL
+	w := httptest.NewRecorder()
+	protected.ServeHTTP(w, httptest.NewRequest("GET", "/settings?tab=profile", nil))
+	assert.Equal(t, http.StatusFound, w.Code)
+	loginURL := w.HeaderMap.Get("Location")
+
+	w = httptest.NewRecorder()
+	login.ServeHTTP(w, httptest.NewRequest("GET", loginURL, nil))
+	assert.Equal(t, http.StatusFound, w.Code)
+	location, err := url.Parse(w.HeaderMap.Get("Location"))
+	assert.Nil(t, err)
+	state := location.Query().Get("state")
+
+	req := httptest.NewRequest("GET", "/callback?code=any_code&state="+url.QueryEscape(state), nil)
+	for _, cookie := range (&http.Response{Header: w.Header()}).Cookies() {
+		req.AddCookie(cookie)
+	}
+	w = httptest.NewRecorder()
+	callback.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "/settings?tab=profile", w.HeaderMap.Get("Location"))
+}
+
+func TestValidReturnURL(t *testing.T) {
+	allowedHosts := []string{"app.example.com"}
+	cases := []struct {
+		returnURL string
+		valid     bool
+	}{
+		{"/", true},
+		{"/settings?tab=profile#top", true},
+		{"https://app.example.com/settings", true},
+		{"http://APP.example.com:8080/", true},
+		{"", false},
+		{"settings", false},
+		{"//evil.example.com", false},
+		{"/\\evil.example.com", false},
+		{"/\t/evil.example.com", false},
+		{"https://evil.example.com/", false},
+		{"https://app.example.com.evil.example.com/", false},
+		{"javascript:alert(1)", false},
+		{"ftp://app.example.com/", false},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.valid, validReturnURL(c.returnURL, allowedHosts), c.returnURL)
+	}
+}
@@ -0,0 +1,102 @@
+package oauth2
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/internal"
+	"golang.org/x/oauth2"
+)
+
+// RevokeHandler revokes the Token from the ctx at the provider's token
+// revocation endpoint (RFC 7009), then calls the success handler. The refresh
+// token is revoked if present (which also revokes its access tokens), the
+// access token otherwise. Requests use the ctx oauth2.HTTPClient, if any.
+//
+// Tokens which are already invalid are treated as revoked. If the provider
+// returns an error response or is unavailable, the failure handler is called.
+func RevokeHandler(config *oauth2.Config, revocationURL string, success, failure http.Handler) http.Handler {
+	provider := TokenSourceProviderFunc(func(req *http.Request) (*oauth2.Token, error) {
+		return TokenFromContext(req.Context())
+	})
+	return RevokeHandlerWithProvider(config, revocationURL, provider, success, failure)
+}
+
+// RevokeHandlerWithProvider revokes the Token loaded by the provider, e.g.
+// from a session, like RevokeHandler.
+func RevokeHandlerWithProvider(config *oauth2.Config, revocationURL string, provider TokenSourceProvider, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := provider.Token(req)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		if err := revokeToken(ctx, config, revocationURL, token); err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		success.ServeHTTP(w, req)
+	}
+	return http.HandlerFunc(fn)
+}
+
+// revokeToken POSTs the token to the revocation endpoint with client
+// authentication.
+// https://tools.ietf.org/html/rfc7009#section-2.1
+func revokeToken(ctx context.Context, config *oauth2.Config, revocationURL string, token *oauth2.Token) error {
+	params := url.Values{}
+	if token.RefreshToken != "" {
+		params.Set("token", token.RefreshToken)
+		params.Set("token_type_hint", "refresh_token")
+	} else {
+		params.Set("token", token.AccessToken)
+		params.Set("token_type_hint", "access_token")
+	}
+	basicAuth := config.ClientSecret != "" && config.Endpoint.AuthStyle != oauth2.AuthStyleInParams
+	if !basicAuth && config.ClientID != "" {
+		params.Set("client_id", config.ClientID)
+		if config.ClientSecret != "" {
+			params.Set("client_secret", config.ClientSecret)
+		}
+	}
+	req, err := http.NewRequest("POST", revocationURL, strings.NewReader(params.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if basicAuth {
+		req.SetBasicAuth(url.QueryEscape(config.ClientID), url.QueryEscape(config.ClientSecret))
+	}
+	resp, err := internal.ContextClient(ctx).Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 {
+		errResp := new(errorJSON)
+		if json.Unmarshal(body, errResp) == nil && errResp.Code != "" {
+			// some providers reject tokens which are already invalid
+			if errResp.Code == "invalid_token" {
+				return nil
+			}
+			return &AuthorizationError{Code: errResp.Code, Description: errResp.Description, URI: errResp.URI}
+		}
+	}
+	return fmt.Errorf("oauth2: %s responded with %s", revocationURL, resp.Status)
+}
@@ -0,0 +1,132 @@
+package oauth2
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func TestRevokeHandler(t *testing.T) {
+	server := NewTestServerFunc(func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "POST", req.Method)
+		assert.Equal(t, "tGzv3JOkF0XG5Qx2TlKWIA", req.PostFormValue("token"))
+		assert.Equal(t, "refresh_token", req.PostFormValue("token_type_hint"))
+		clientID, clientSecret, ok := req.BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "client_id", clientID)
+		assert.Equal(t, "client_secret", clientSecret)
+		w.WriteHeader(http.StatusOK)
+	})
+	defer server.Close()
+	config := &oauth2.Config{
+		ClientID:     "client_id",
+		ClientSecret: "client_secret",
+	}
+	success := func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// RevokeHandler assert that:
+	// - the ctx refresh token is POSTed with client authentication
+	// - success handler is called
+	handler := RevokeHandler(config, server.URL, http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/logout", nil)
+	ctx := WithToken(context.Background(), &oauth2.Token{AccessToken: "2YotnFZFEjr1zCsicMWpAA", RefreshToken: "tGzv3JOkF0XG5Qx2TlKWIA"})
+	handler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestRevokeHandlerWithProvider(t *testing.T) {
+	server := NewTestServerFunc(func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "2YotnFZFEjr1zCsicMWpAA", req.PostFormValue("token"))
+		assert.Equal(t, "access_token", req.PostFormValue("token_type_hint"))
+		// public clients identify themselves with a client_id param
+		assert.Equal(t, "client_id", req.PostFormValue("client_id"))
+		w.WriteHeader(http.StatusOK)
+	})
+	defer server.Close()
+	config := &oauth2.Config{ClientID: "client_id"}
+	provider := TokenSourceProviderFunc(func(req *http.Request) (*oauth2.Token, error) {
+		return &oauth2.Token{AccessToken: "2YotnFZFEjr1zCsicMWpAA"}, nil
+	})
+	success := func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	handler := RevokeHandlerWithProvider(config, server.URL, provider, http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/logout", nil)
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestRevokeHandler_Responses(t *testing.T) {
+	cases := []struct {
+		status int
+		body   string
+		err    bool
+	}{
+		{http.StatusOK, ``, false},
+		// token already invalid
+		{http.StatusBadRequest, `{"error": "invalid_token"}`, false},
+		{http.StatusBadRequest, `{"error": "unsupported_token_type"}`, true},
+		{http.StatusUnauthorized, `{"error": "invalid_client"}`, true},
+		{http.StatusServiceUnavailable, ``, true},
+		{http.StatusInternalServerError, `{"error": "invalid_token"}`, true},
+	}
+	for _, c := range cases {
+		server := NewTestServerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.Header().Set(contentType, jsonContentType)
+			w.WriteHeader(c.status)
+			w.Write([]byte(c.body))
+		})
+		var handler http.Handler
+		if c.err {
+			handler = RevokeHandler(&oauth2.Config{}, server.URL, testutils.AssertSuccessNotCalled(t), http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				assert.NotNil(t, gologin.ErrorFromContext(req.Context()))
+				fmt.Fprintf(w, "failure handler called")
+			}))
+		} else {
+			handler = RevokeHandler(&oauth2.Config{}, server.URL, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				fmt.Fprintf(w, "success handler called")
+			}), testutils.AssertFailureNotCalled(t))
+		}
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/logout", nil)
+		ctx := WithToken(context.Background(), &oauth2.Token{AccessToken: "2YotnFZFEjr1zCsicMWpAA"})
+		handler.ServeHTTP(w, req.WithContext(ctx))
+		if c.err {
+			assert.Equal(t, "failure handler called", w.Body.String())
+		} else {
+			assert.Equal(t, "success handler called", w.Body.String())
+		}
+		server.Close()
+	}
+}
+
+func TestRevokeHandler_MissingCtxToken(t *testing.T) {
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "oauth2: Context missing Token", err.Error())
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	handler := RevokeHandler(&oauth2.Config{}, "https://example.com/revoke", success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/logout", nil)
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
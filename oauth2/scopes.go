@@ -0,0 +1,95 @@
+package oauth2
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode"
+
+	"github.com/dghubble/gologin"
+	"golang.org/x/oauth2"
+)
+
+// ErrMissingScopes is the error when the user did not grant required scopes.
+// Errors name the missing scopes, so match them with errors.Is.
+var ErrMissingScopes = errors.New("oauth2: missing required scopes")
+
+// ScopeConfig configures RequiredScopesHandler.
+type ScopeConfig struct {
+	// Required are the scopes (e.g. "email") which must be granted.
+	Required []string
+	// IgnoreCase compares scopes case-insensitively (e.g. for GitHub, which
+	// lowercases requested scopes).
+	IgnoreCase bool
+}
+
+// Missing returns the Required scopes which are not among the granted
+// scopes, in order.
+func (c ScopeConfig) Missing(granted []string) []string {
+	var missing []string
+	for _, scope := range c.Required {
+		if !c.contains(granted, scope) {
+			missing = append(missing, scope)
+		}
+	}
+	return missing
+}
+
+func (c ScopeConfig) contains(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope || (c.IgnoreCase && strings.EqualFold(s, scope)) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseScopes splits a list of scopes separated by spaces (RFC 6749 3.3) or
+// commas (e.g. GitHub's "repo,gist" and X-OAuth-Scopes "repo, gist").
+func ParseScopes(scopes string) []string {
+	return strings.FieldsFunc(scopes, func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	})
+}
+
+// RequiredScopesHandler checks the granted scopes in the ctx (see
+// GrantedScopesFromContext) include the config Required scopes. If so,
+// handling delegates to the success handler. Otherwise, the failure handler
+// is called with a 403 and an ErrMissingScopes which names the missing
+// scopes. Chain it after CallbackHandler (or a provider callback handler)
+// since users may decline scopes on the consent screen.
+func RequiredScopesHandler(config ScopeConfig, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		// without granted scopes, none of the Required scopes are granted
+		granted, _ := GrantedScopesFromContext(ctx)
+		if missing := config.Missing(granted); len(missing) > 0 {
+			ctx = gologin.WithError(ctx, MissingScopesError(missing))
+			ctx = gologin.WithStatusCode(ctx, http.StatusForbidden)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// MissingScopesError returns an ErrMissingScopes which names the missing
+// scopes.
+func MissingScopesError(missing []string) error {
+	return fmt.Errorf("%w: %s", ErrMissingScopes, strings.Join(missing, ", "))
+}
+
+// grantedScopes returns the scopes granted with the Token, per its "scope"
+// field. Providers may omit the field if the requested scopes were granted
+// (RFC 6749 5.1), so the requested scopes are returned instead.
+func grantedScopes(token *oauth2.Token, requested []string) []string {
+	if scope, ok := token.Extra("scope").(string); ok {
+		return ParseScopes(scope)
+	}
+	return requested
+}
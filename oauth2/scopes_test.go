@@ -0,0 +1,99 @@
+package oauth2
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseScopes(t *testing.T) {
+	cases := []struct {
+		scopes   string
+		expected []string
+	}{
+		{"openid email profile", []string{"openid", "email", "profile"}},
+		{"repo,gist", []string{"repo", "gist"}},
+		{"repo, read:org", []string{"repo", "read:org"}},
+		{"  user:email\tread:org,, ", []string{"user:email", "read:org"}},
+		{"", []string{}},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.expected, ParseScopes(c.scopes), c.scopes)
+	}
+}
+
+func TestScopeConfig_Missing(t *testing.T) {
+	cases := []struct {
+		config   ScopeConfig
+		granted  string
+		expected []string
+	}{
+		// all granted, space or comma separated
+		{ScopeConfig{Required: []string{"email", "openid"}}, "openid email", nil},
+		{ScopeConfig{Required: []string{"repo", "read:org"}}, "repo, read:org", nil},
+		// declined scopes are missing, in Required order
+		{ScopeConfig{Required: []string{"repo", "read:org", "gist"}}, "repo", []string{"read:org", "gist"}},
+		// case-sensitive by default
+		{ScopeConfig{Required: []string{"Read:Org"}}, "repo,read:org", []string{"Read:Org"}},
+		// IgnoreCase (e.g. GitHub)
+		{ScopeConfig{Required: []string{"Read:Org"}, IgnoreCase: true}, "repo,read:org", nil},
+		{ScopeConfig{Required: []string{"Read:Org"}, IgnoreCase: true}, "repo", []string{"Read:Org"}},
+		// nothing required
+		{ScopeConfig{}, "", nil},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.expected, c.config.Missing(ParseScopes(c.granted)), c.granted)
+	}
+}
+
+func TestRequiredScopesHandler(t *testing.T) {
+	config := ScopeConfig{Required: []string{"repo", "read:org"}, IgnoreCase: true}
+	success := func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(w, "success handler called")
+	}
+
+	// RequiredScopesHandler with the Required scopes granted, assert that:
+	// - success handler is called
+	handler := RequiredScopesHandler(config, http.HandlerFunc(success), testutils.AssertFailureNotCalled(t))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	ctx := WithGrantedScopes(context.Background(), "REPO", "read:org")
+	handler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestRequiredScopesHandler_MissingScopes(t *testing.T) {
+	config := ScopeConfig{Required: []string{"repo", "read:org", "gist"}}
+	cases := []struct {
+		ctx     context.Context
+		message string
+	}{
+		{WithGrantedScopes(context.Background(), "repo"), "oauth2: missing required scopes: read:org, gist"},
+		// without granted scopes, all Required scopes are missing
+		{context.Background(), "oauth2: missing required scopes: repo, read:org, gist"},
+	}
+	for _, c := range cases {
+		failure := func(w http.ResponseWriter, req *http.Request) {
+			ctx := req.Context()
+			err := gologin.ErrorFromContext(ctx)
+			assert.True(t, errors.Is(err, ErrMissingScopes))
+			assert.Equal(t, c.message, err.Error())
+			w.WriteHeader(gologin.StatusCodeFromContext(ctx))
+		}
+
+		// RequiredScopesHandler with missing scopes, assert that:
+		// - failure handler is called with a 403 naming the missing scopes
+		handler := RequiredScopesHandler(config, testutils.AssertSuccessNotCalled(t), http.HandlerFunc(failure))
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		handler.ServeHTTP(w, req.WithContext(c.ctx))
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	}
+}
@@ -0,0 +1,195 @@
+package oauth2
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/dghubble/gologin"
+	"golang.org/x/oauth2"
+)
+
+// sessionBindingSeparator separates a random nonce from its session binding.
+// It does not occur in base64url or hex encoded nonces.
+const sessionBindingSeparator = "~"
+
+// Errors which may occur when states are bound to sessions.
+var (
+	ErrMissingSession       = errors.New("oauth2: state requires an application session")
+	ErrStateSessionMismatch = errors.New("oauth2: state is not bound to the session")
+)
+
+// SessionIDSource returns the requester's application session ID, or an empty
+// ID if the requester has no session.
+type SessionIDSource func(req *http.Request) (string, error)
+
+// SessionBinding configures binding states to an existing application session.
+type SessionBinding struct {
+	// SessionID returns the requester's session ID. Required.
+	SessionID SessionIDSource
+	// Key is the server-side HMAC-SHA256 key. Required.
+	Key []byte
+	// RequireSession calls the failure handler with ErrMissingSession when
+	// the requester has no session. By default, requesters without a session
+	// are issued (and may complete logins with) unbound states.
+	RequireSession bool
+	// Generator generates the random nonces. Defaults to
+	// DefaultStateGenerator.
+	Generator StateGenerator
+}
+
+// StateHandlerWithSessionBinding is a StateHandler which binds states to the
+// requester's application session, so a state issued against one session
+// cannot be used with another (e.g. a state cookie fixated by a sibling
+// subdomain). States are a random nonce, "~", and the base64url HMAC-SHA256 of
+// the nonce and session ID, followed by the nonce's issue time, if any. Cookie
+// states which are not bound to the current session are replaced.
+//
+// Requesters without a session are issued unbound states, unless the binding
+// RequireSession, which calls the failure handler with ErrMissingSession.
+// SessionID errors call the failure handler with a 500. Verify the binding with
+// CallbackHandlerWithSessionBinding or SessionBindingHandler. Panics if the
+// binding has no SessionID or Key.
+func StateHandlerWithSessionBinding(config gologin.CookieConfig, binding SessionBinding, success, failure http.Handler) http.Handler {
+	binding = binding.mustNormalize()
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		sessionID, err := binding.sessionID(req)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			ctx = gologin.WithStatusCode(ctx, sessionErrorStatusCode(err))
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		generate := func() (string, error) {
+			state, err := binding.Generator()
+			if err != nil || sessionID == "" {
+				return state, err
+			}
+			return binding.bind(state, sessionID), nil
+		}
+		reuse := func(state string) bool {
+			return binding.verify(state, sessionID) == nil
+		}
+		stateHandler(config, generate, reuse, success, failure).ServeHTTP(w, req)
+	}
+	return http.HandlerFunc(fn)
+}
+
+// CallbackHandlerWithSessionBinding is a CallbackHandler which first verifies
+// that the callback state is bound to the requester's application session
+// (see SessionBindingHandler).
+func CallbackHandlerWithSessionBinding(config *oauth2.Config, binding SessionBinding, success, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	return SessionBindingHandler(binding, CallbackHandler(config, success, failure, opts...), failure)
+}
+
+// SessionBindingHandler verifies that the callback "state" parameter was
+// issued by StateHandlerWithSessionBinding against the requester's current
+// application session before calling the success handler (e.g. a provider
+// CallbackHandler). Otherwise, the failure handler is called with
+// ErrStateSessionMismatch. Requesters without a session may only use unbound
+// states, unless the binding RequireSession. Panics if the binding has no
+// SessionID or Key.
+func SessionBindingHandler(binding SessionBinding, success, failure http.Handler) http.Handler {
+	binding = binding.mustNormalize()
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		sessionID, err := binding.sessionID(req)
+		if err == nil {
+			err = binding.verify(req.FormValue("state"), sessionID)
+		}
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			ctx = gologin.WithStatusCode(ctx, sessionErrorStatusCode(err))
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		success.ServeHTTP(w, req)
+	}
+	return callbackFormHandler(http.HandlerFunc(fn), failure)
+}
+
+// mustNormalize returns the SessionBinding with defaults. Panics if the
+// SessionID or Key are missing.
+func (b SessionBinding) mustNormalize() SessionBinding {
+	if b.SessionID == nil {
+		panic("oauth2: SessionBinding requires a SessionID source")
+	}
+	if len(b.Key) == 0 {
+		panic("oauth2: SessionBinding requires a Key")
+	}
+	if b.Generator == nil {
+		b.Generator = DefaultStateGenerator
+	}
+	return b
+}
+
+// sessionID returns the requester's session ID, or ErrMissingSession if the
+// requester has no session and the binding requires one.
+func (b SessionBinding) sessionID(req *http.Request) (string, error) {
+	sessionID, err := b.SessionID(req)
+	if err != nil {
+		return "", err
+	}
+	if sessionID == "" && b.RequireSession {
+		return "", ErrMissingSession
+	}
+	return sessionID, nil
+}
+
+// bind returns the state with the random nonce bound to the session ID. Any
+// issue time is kept as the suffix.
+func (b SessionBinding) bind(state, sessionID string) string {
+	nonce, suffix := state, ""
+	if i := strings.LastIndex(state, stateTimestampSeparator); i >= 0 {
+		nonce, suffix = state[:i], state[i:]
+	}
+	return nonce + sessionBindingSeparator + b.mac(nonce, sessionID) + suffix
+}
+
+// verify returns ErrStateSessionMismatch unless the state is bound to the
+// session ID or, if there is no session, the state is unbound.
+func (b SessionBinding) verify(state, sessionID string) error {
+	nonce := state
+	if i := strings.LastIndex(state, stateTimestampSeparator); i >= 0 {
+		nonce = state[:i]
+	}
+	i := strings.LastIndex(nonce, sessionBindingSeparator)
+	if sessionID == "" {
+		if i >= 0 {
+			return ErrStateSessionMismatch
+		}
+		return nil
+	}
+	if i < 0 || !hmac.Equal([]byte(nonce[i+1:]), []byte(b.mac(nonce[:i], sessionID))) {
+		return ErrStateSessionMismatch
+	}
+	return nil
+}
+
+// mac returns the base64url encoded HMAC-SHA256 of the nonce and session ID.
+func (b SessionBinding) mac(nonce, sessionID string) string {
+	mac := hmac.New(sha256.New, b.Key)
+	// length prefix the nonce so nonce and session ID boundaries are unambiguous
+	mac.Write([]byte{byte(len(nonce) >> 8), byte(len(nonce))})
+	mac.Write([]byte(nonce))
+	mac.Write([]byte(sessionID))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// sessionErrorStatusCode returns the status code for a session binding error.
+func sessionErrorStatusCode(err error) int {
+	if err == ErrMissingSession || err == ErrStateSessionMismatch {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
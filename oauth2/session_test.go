@@ -0,0 +1,185 @@
+package oauth2
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+const testSessionCookie = "app-session"
+
+// testSessionBinding binds states to the testSessionCookie value.
+func testSessionBinding(requireSession bool) SessionBinding {
+	return SessionBinding{
+		SessionID: func(req *http.Request) (string, error) {
+			if cookie, err := req.Cookie(testSessionCookie); err == nil {
+				return cookie.Value, nil
+			}
+			return "", nil
+		},
+		Key:            testSigningKey,
+		RequireSession: requireSession,
+	}
+}
+
+// sessionRequest returns a request with the session and state cookies, if
+// non-empty.
+func sessionRequest(target, sessionID, cookieState string) *http.Request {
+	req := httptest.NewRequest("GET", target, nil)
+	if sessionID != "" {
+		req.AddCookie(&http.Cookie{Name: testSessionCookie, Value: sessionID})
+	}
+	if cookieState != "" {
+		req.AddCookie(&http.Cookie{Name: gologin.DebugOnlyCookieConfig.Name, Value: cookieState})
+	}
+	return req
+}
+
+func TestSessionBinding_Replay(t *testing.T) {
+	server := NewTestServerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set(contentType, jsonContentType)
+		w.Write([]byte(`{"access_token":"2YotnFZFEjr1zCsicMWpAA","token_type":"example"}`))
+	})
+	defer server.Close()
+	config := &oauth2.Config{
+		ClientID: "client_id",
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  "https://api.example.com/authorize",
+			TokenURL: server.URL,
+		},
+	}
+	binding := testSessionBinding(false)
+	login := StateHandlerWithSessionBinding(gologin.DebugOnlyCookieConfig, binding, LoginHandler(config, nil), nil)
+	var failures []error
+	failure := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		failures = append(failures, gologin.ErrorFromContext(req.Context()))
+		fmt.Fprintf(w, "failure handler called")
+	})
+	success := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(w, "success handler called")
+	})
+	callback := StateHandlerWithSessionBinding(gologin.DebugOnlyCookieConfig, binding, CallbackHandlerWithSessionBinding(config, binding, success, failure), failure)
+
+	// an attacker starts a login with their own session
+	w := httptest.NewRecorder()
+	login.ServeHTTP(w, sessionRequest("/login", "attacker-session", ""))
+	location, err := url.Parse(w.HeaderMap.Get("Location"))
+	assert.Nil(t, err)
+	state := location.Query().Get("state")
+	assert.Contains(t, state, sessionBindingSeparator)
+
+	// StateHandlerWithSessionBinding and CallbackHandlerWithSessionBinding,
+	// assert that:
+	// - the state completes the attacker's own login
+	// - the state (fixated in the victim's cookie) fails with the victim's session
+	// - the state fails without a session
+	cases := []struct {
+		sessionID string
+		body      string
+		err       error
+	}{
+		{"attacker-session", "success handler called", nil},
+		{"victim-session", "failure handler called", ErrStateSessionMismatch},
+		{"", "failure handler called", ErrStateSessionMismatch},
+	}
+	for _, c := range cases {
+		failures = nil
+		w := httptest.NewRecorder()
+		callback.ServeHTTP(w, sessionRequest("/callback?code=any_code&state="+url.QueryEscape(state), c.sessionID, state))
+		assert.Equal(t, c.body, w.Body.String(), c.sessionID)
+		if c.err != nil {
+			assert.Equal(t, []error{c.err}, failures, c.sessionID)
+		}
+	}
+}
+
+func TestStateHandlerWithSessionBinding(t *testing.T) {
+	binding := testSessionBinding(false)
+	var ctxState string
+	success := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ctxState, _ = StateFromContext(req.Context())
+	})
+	handler := StateHandlerWithSessionBinding(gologin.DebugOnlyCookieConfig, binding, success, nil)
+	bound := binding.bind("nonce.1500000000", "some-session")
+	assert.Equal(t, "nonce~"+binding.mac("nonce", "some-session")+".1500000000", bound)
+
+	// StateHandlerWithSessionBinding, assert that:
+	// - requesters with a session are issued bound states
+	// - requesters without a session are issued unbound states
+	// - cookie states bound to the session are reused
+	// - cookie states bound to another session, or unbound, are replaced
+	cases := []struct {
+		sessionID   string
+		cookieState string
+		reused      bool
+	}{
+		{"some-session", "", false},
+		{"", "", false},
+		{"some-session", binding.bind("nonce", "some-session"), true},
+		{"", "unbound-nonce", true},
+		{"some-session", binding.bind("nonce", "other-session"), false},
+		{"some-session", "unbound-nonce", false},
+		{"", binding.bind("nonce", "some-session"), false},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, sessionRequest("/login", c.sessionID, c.cookieState))
+		if c.reused {
+			assert.Equal(t, c.cookieState, ctxState)
+			continue
+		}
+		assert.NotEqual(t, c.cookieState, ctxState)
+		assert.Equal(t, c.sessionID != "", strings.Contains(ctxState, sessionBindingSeparator))
+		assert.Nil(t, binding.verify(ctxState, c.sessionID))
+	}
+}
+
+func TestSessionBinding_Errors(t *testing.T) {
+	errSessionStore := errors.New("session store unavailable")
+	failingSource := testSessionBinding(false)
+	failingSource.SessionID = func(req *http.Request) (string, error) {
+		return "", errSessionStore
+	}
+	cases := []struct {
+		name    string
+		binding SessionBinding
+		status  int
+		err     error
+	}{
+		{"required session", testSessionBinding(true), http.StatusBadRequest, ErrMissingSession},
+		{"session source error", failingSource, http.StatusInternalServerError, errSessionStore},
+	}
+	for _, c := range cases {
+		failure := func(w http.ResponseWriter, req *http.Request) {
+			ctx := req.Context()
+			assert.Equal(t, c.err, gologin.ErrorFromContext(ctx), c.name)
+			w.WriteHeader(gologin.StatusCodeFromContext(ctx))
+		}
+		// StateHandlerWithSessionBinding and SessionBindingHandler without a
+		// session, assert that:
+		// - the failure handler is called with the error and status
+		login := StateHandlerWithSessionBinding(gologin.DebugOnlyCookieConfig, c.binding, testutils.AssertSuccessNotCalled(t), http.HandlerFunc(failure))
+		w := httptest.NewRecorder()
+		login.ServeHTTP(w, sessionRequest("/login", "", ""))
+		assert.Equal(t, c.status, w.Code, c.name)
+
+		callback := SessionBindingHandler(c.binding, testutils.AssertSuccessNotCalled(t), http.HandlerFunc(failure))
+		w = httptest.NewRecorder()
+		callback.ServeHTTP(w, sessionRequest("/callback?code=any_code&state=unbound-nonce", "", ""))
+		assert.Equal(t, c.status, w.Code, c.name)
+	}
+
+	assert.Panics(t, func() { SessionBindingHandler(SessionBinding{Key: testSigningKey}, nil, nil) })
+	assert.Panics(t, func() {
+		StateHandlerWithSessionBinding(gologin.DebugOnlyCookieConfig, SessionBinding{SessionID: failingSource.SessionID}, nil, nil)
+	})
+}
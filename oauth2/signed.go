@@ -0,0 +1,98 @@
+package oauth2
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/internal"
+)
+
+// Errors which may occur when verifying a signed state cookie.
+var (
+	ErrInvalidStateSignature = errors.New("oauth2: invalid state cookie signature")
+)
+
+const signedStateSeparator = "|"
+
+// MinSigningKeyBytes is the minimum length of a NewSignedCookieStateStore key.
+const MinSigningKeyBytes = 32
+
+// signedCookieStateStore is a StateStore which keeps the state in a
+// short-lived cookie whose value is signed with HMAC-SHA256.
+type signedCookieStateStore struct {
+	config gologin.CookieConfig
+	key    []byte
+}
+
+// NewSignedCookieStateStore returns a StateStore which keeps the state in a
+// short-lived cookie with the value format state|timestamp|signature. The
+// signature is an HMAC-SHA256 of the state and issue timestamp using the
+// server-side key.
+//
+// Verify returns ErrInvalidStateSignature for tampered or malformed cookies
+// and, if the CookieConfig MaxAge is positive, ErrStateExpired for cookies
+// issued more than MaxAge seconds ago. Panics if the key is shorter than
+// MinSigningKeyBytes.
+func NewSignedCookieStateStore(config gologin.CookieConfig, key []byte) StateStore {
+	if len(key) < MinSigningKeyBytes {
+		panic("oauth2: NewSignedCookieStateStore requires a key of at least 32 bytes")
+	}
+	return &signedCookieStateStore{
+		config: config,
+		key:    key,
+	}
+}
+
+func (s *signedCookieStateStore) Save(ctx context.Context, w http.ResponseWriter, req *http.Request, state string) error {
+	timestamp := strconv.FormatInt(gologin.ClockFromContext(ctx).Now().Unix(), 10)
+	value := strings.Join([]string{state, timestamp, s.signature(state, timestamp)}, signedStateSeparator)
+	http.SetCookie(w, internal.NewCookie(s.config, value))
+	return nil
+}
+
+func (s *signedCookieStateStore) Verify(ctx context.Context, req *http.Request) (string, error) {
+	cookie, err := req.Cookie(s.config.Name)
+	if err != nil || cookie.Value == "" {
+		return "", ErrStateNotFound
+	}
+	if _, ok := consumedState(cookie.Value); ok {
+		return "", ErrStateAlreadyUsed
+	}
+	parts := strings.Split(cookie.Value, signedStateSeparator)
+	if len(parts) != 3 {
+		return "", ErrInvalidStateSignature
+	}
+	state, timestamp, signature := parts[0], parts[1], parts[2]
+	if !hmac.Equal([]byte(signature), []byte(s.signature(state, timestamp))) {
+		return "", ErrInvalidStateSignature
+	}
+	issued, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return "", ErrInvalidStateSignature
+	}
+	maxAge := time.Duration(s.config.MaxAge) * time.Second
+	if maxAge > 0 && gologin.ClockFromContext(ctx).Now().Sub(time.Unix(issued, 0)) > maxAge {
+		return "", ErrStateExpired
+	}
+	return state, nil
+}
+
+func (s *signedCookieStateStore) Clear(ctx context.Context, w http.ResponseWriter, req *http.Request) error {
+	return clearStateCookie(s.config, w, req)
+}
+
+// signature returns the base64 encoded HMAC-SHA256 of the state and
+// timestamp.
+func (s *signedCookieStateStore) signature(state, timestamp string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(state + signedStateSeparator + timestamp))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,110 @@
+package oauth2
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+var testSigningKey = []byte("test-state-cookie-hmac-signing-key")
+
+// signedStateCookie returns a state cookie value issued at the given time.
+func signedStateCookie(key []byte, state string, issued time.Time) *http.Cookie {
+	store := &signedCookieStateStore{config: gologin.DebugOnlyCookieConfig, key: key}
+	timestamp := strconv.FormatInt(issued.Unix(), 10)
+	value := strings.Join([]string{state, timestamp, store.signature(state, timestamp)}, "|")
+	return &http.Cookie{Name: gologin.DebugOnlyCookieConfig.Name, Value: value}
+}
+
+func TestSignedCookieStateStore(t *testing.T) {
+	store := NewSignedCookieStateStore(gologin.DebugOnlyCookieConfig, testSigningKey)
+	ctx := context.Background()
+
+	// Save sets a signed state cookie
+	w := httptest.NewRecorder()
+	err := store.Save(ctx, w, nil, "some_state")
+	assert.Nil(t, err)
+	cookies := (&http.Response{Header: w.Header()}).Cookies()
+	if assert.Len(t, cookies, 1) {
+		parts := strings.Split(cookies[0].Value, "|")
+		assert.Len(t, parts, 3)
+		assert.Equal(t, "some_state", parts[0])
+	}
+
+	// Verify checks the signature and returns the state
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.AddCookie(cookies[0])
+	state, err := store.Verify(ctx, req)
+	assert.Nil(t, err)
+	assert.Equal(t, "some_state", state)
+}
+
+func TestSignedCookieStateStore_Verify(t *testing.T) {
+	now := time.Now()
+	valid := signedStateCookie(testSigningKey, "some_state", now)
+	tampered := *valid
+	tampered.Value = strings.Replace(valid.Value, "some_state", "other_state", 1)
+
+	cases := []struct {
+		cookie *http.Cookie
+		err    error
+	}{
+		{valid, nil},
+		{nil, ErrStateNotFound},
+		{&tampered, ErrInvalidStateSignature},
+		{signedStateCookie([]byte("other-key"), "some_state", now), ErrInvalidStateSignature},
+		{&http.Cookie{Name: valid.Name, Value: "some_state"}, ErrInvalidStateSignature},
+		{signedStateCookie(testSigningKey, "some_state", now.Add(-2*time.Minute)), ErrStateExpired},
+	}
+	store := NewSignedCookieStateStore(gologin.DebugOnlyCookieConfig, testSigningKey)
+	for _, c := range cases {
+		req, _ := http.NewRequest("GET", "/", nil)
+		if c.cookie != nil {
+			req.AddCookie(c.cookie)
+		}
+		_, err := store.Verify(context.Background(), req)
+		assert.Equal(t, c.err, err)
+	}
+}
+
+func TestCallbackHandlerWithStore_InvalidSignature(t *testing.T) {
+	config := &oauth2.Config{}
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		err := gologin.ErrorFromContext(ctx)
+		if assert.NotNil(t, err) {
+			assert.Equal(t, ErrInvalidStateSignature, err)
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// CallbackHandlerWithStore called with a forged state cookie, assert that:
+	// - failure handler is called
+	// - ErrInvalidStateSignature is added to the ctx
+	store := NewSignedCookieStateStore(gologin.DebugOnlyCookieConfig, testSigningKey)
+	callbackHandler := CallbackHandlerWithStore(config, store, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	req.AddCookie(signedStateCookie([]byte("attacker-key"), "d4e5f6", time.Now()))
+	callbackHandler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestNewSignedCookieStateStore_ShortKey(t *testing.T) {
+	// NewSignedCookieStateStore panics without a key of MinSigningKeyBytes
+	for _, key := range [][]byte{nil, {}, []byte("signing-key"), make([]byte, MinSigningKeyBytes-1)} {
+		assert.Panics(t, func() { NewSignedCookieStateStore(gologin.DebugOnlyCookieConfig, key) })
+	}
+	assert.NotPanics(t, func() { NewSignedCookieStateStore(gologin.DebugOnlyCookieConfig, make([]byte, MinSigningKeyBytes)) })
+}
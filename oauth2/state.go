@@ -0,0 +1,130 @@
+package oauth2
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/dghubble/gologin"
+)
+
+// MinStateBytes is the minimum number of random bytes in generated states.
+const MinStateBytes = 16
+
+const defaultStateBytes = 32
+
+// ErrInvalidStateFormat is the error when a state is not an encoded random
+// state of at least MinStateBytes bytes, optionally followed by its issue
+// time.
+var ErrInvalidStateFormat = errors.New("oauth2: state is not a valid generated state")
+
+// StateEncoding is the encoding of generated state bytes.
+type StateEncoding int
+
+const (
+	// StateEncodingBase64URL encodes states as unpadded base64url.
+	StateEncodingBase64URL StateEncoding = iota
+	// StateEncodingHex encodes states as lowercase hex.
+	StateEncodingHex
+)
+
+// StateConfig configures generated states.
+type StateConfig struct {
+	// Bytes is the number of random bytes. Defaults to 32, must be at least
+	// MinStateBytes.
+	Bytes int
+	// Encoding is the encoding of the random bytes. Defaults to
+	// StateEncodingBase64URL.
+	Encoding StateEncoding
+	// Rand is the source of random bytes. Defaults to crypto/rand Reader.
+	// Use a cryptographically secure source outside of tests.
+	Rand io.Reader
+	// Clock is the source of the issue time. Defaults to the
+	// gologin.SystemClock.
+	Clock gologin.Clock
+}
+
+// contextStateConfig returns the default StateConfig with the ctx gologin
+// Clock and entropy.
+func contextStateConfig(ctx context.Context) StateConfig {
+	return StateConfig{Rand: gologin.EntropyFromContext(ctx), Clock: gologin.ClockFromContext(ctx)}
+}
+
+// GenerateState returns a state of the config's random bytes and encoding,
+// followed by its issue time so that StateHandler states expire. Returns an
+// error if the config is invalid or the random bytes cannot be read.
+func GenerateState(config StateConfig) (string, error) {
+	config, err := config.normalize()
+	if err != nil {
+		return "", err
+	}
+	b := make([]byte, config.Bytes)
+	if _, err := io.ReadFull(config.Rand, b); err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(b)
+	if config.Encoding == StateEncodingHex {
+		encoded = hex.EncodeToString(b)
+	}
+	return encoded + stateTimestampSeparator + strconv.FormatInt(config.Clock.Now().Unix(), 10), nil
+}
+
+// NewStateGenerator returns a StateGenerator which generates states with
+// GenerateState. Panics if the config is invalid.
+func NewStateGenerator(config StateConfig) StateGenerator {
+	config, err := config.normalize()
+	if err != nil {
+		panic(err)
+	}
+	return func() (string, error) {
+		return GenerateState(config)
+	}
+}
+
+// normalize returns the StateConfig with defaults, or an error if the Bytes
+// or Encoding are invalid.
+func (c StateConfig) normalize() (StateConfig, error) {
+	if c.Bytes == 0 {
+		c.Bytes = defaultStateBytes
+	}
+	if c.Bytes < MinStateBytes {
+		return c, fmt.Errorf("oauth2: StateConfig Bytes %d is less than %d", c.Bytes, MinStateBytes)
+	}
+	if c.Encoding != StateEncodingBase64URL && c.Encoding != StateEncodingHex {
+		return c, fmt.Errorf("oauth2: invalid StateConfig Encoding %d", c.Encoding)
+	}
+	if c.Rand == nil {
+		c.Rand = rand.Reader
+	}
+	if c.Clock == nil {
+		c.Clock = gologin.SystemClock
+	}
+	return c, nil
+}
+
+// ValidateStateFormat returns ErrInvalidStateFormat unless the state is a
+// base64url or hex encoded random state of at least MinStateBytes bytes,
+// optionally followed by its issue time (as generated by GenerateState).
+// It does not check that the state was issued to the requester.
+func ValidateStateFormat(state string) error {
+	random := state
+	if i := strings.LastIndex(state, stateTimestampSeparator); i >= 0 {
+		if _, err := strconv.ParseInt(state[i+1:], 10, 64); err != nil {
+			return ErrInvalidStateFormat
+		}
+		random = state[:i]
+	}
+	if b, err := hex.DecodeString(random); err == nil && len(b) >= MinStateBytes {
+		return nil
+	}
+	if b, err := base64.RawURLEncoding.DecodeString(random); err == nil && len(b) >= MinStateBytes {
+		return nil
+	}
+	return ErrInvalidStateFormat
+}
@@ -0,0 +1,99 @@
+package oauth2
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dghubble/gologin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateState(t *testing.T) {
+	random := bytes.Repeat([]byte{0xfb}, 64)
+	cases := []struct {
+		config StateConfig
+		prefix string
+	}{
+		{StateConfig{Rand: bytes.NewReader(random)}, base64.RawURLEncoding.EncodeToString(random[:32]) + "."},
+		{StateConfig{Bytes: 16, Rand: bytes.NewReader(random)}, base64.RawURLEncoding.EncodeToString(random[:16]) + "."},
+		{StateConfig{Bytes: 16, Encoding: StateEncodingHex, Rand: bytes.NewReader(random)}, strings.Repeat("fb", 16) + "."},
+	}
+	for _, c := range cases {
+		// GenerateState with a deterministic Rand, assert that:
+		// - states encode the configured number of random bytes
+		// - states embed their issue time
+		state, err := GenerateState(c.config)
+		assert.Nil(t, err)
+		assert.True(t, strings.HasPrefix(state, c.prefix), state)
+		assert.False(t, stateExpiry(state, 60).IsZero())
+		assert.Nil(t, ValidateStateFormat(state))
+	}
+}
+
+func TestGenerateState_Errors(t *testing.T) {
+	_, err := GenerateState(StateConfig{Bytes: 15})
+	assert.Equal(t, "oauth2: StateConfig Bytes 15 is less than 16", err.Error())
+	_, err = GenerateState(StateConfig{Encoding: StateEncoding(7)})
+	assert.Equal(t, "oauth2: invalid StateConfig Encoding 7", err.Error())
+	// short reads of the randomness source are errors
+	_, err = GenerateState(StateConfig{Rand: bytes.NewReader(make([]byte, 8))})
+	assert.NotNil(t, err)
+	assert.Panics(t, func() { NewStateGenerator(StateConfig{Bytes: 8}) })
+}
+
+func TestNewStateGenerator(t *testing.T) {
+	generate := NewStateGenerator(StateConfig{Bytes: 24, Encoding: StateEncodingHex})
+	state, err := generate()
+	assert.Nil(t, err)
+	other, err := generate()
+	assert.Nil(t, err)
+	assert.NotEqual(t, state, other)
+	assert.Equal(t, 48, strings.Index(state, stateTimestampSeparator))
+
+	// StateHandlerWithGenerator with a NewStateGenerator, assert that:
+	// - randomness errors call the failure handler with a 500
+	failingRand := NewStateGenerator(StateConfig{Rand: bytes.NewReader(nil)})
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		assert.NotNil(t, gologin.ErrorFromContext(req.Context()))
+		w.WriteHeader(gologin.StatusCodeFromContext(req.Context()))
+	}
+	handler := StateHandlerWithGenerator(gologin.DebugOnlyCookieConfig, failingRand, nil, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/login", nil))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+func TestValidateStateFormat(t *testing.T) {
+	cases := []struct {
+		state string
+		valid bool
+	}{
+		{strings.Repeat("a", 43), true},
+		{strings.Repeat("a", 43) + ".1500000000", true},
+		{strings.Repeat("0f", 16), true},
+		{strings.Repeat("0f", 16) + ".1500000000", true},
+		{strings.Repeat("-_", 11), true},
+		// too short
+		{"", false},
+		{"abc", false},
+		{strings.Repeat("0f", 10), false},
+		{strings.Repeat("a", 20), false},
+		// invalid issue times or characters
+		{strings.Repeat("a", 43) + ".", false},
+		{strings.Repeat("a", 43) + ".soon", false},
+		{strings.Repeat("a", 42) + "+", false},
+		{strings.Repeat("a", 42) + "=", false},
+	}
+	for _, c := range cases {
+		err := ValidateStateFormat(c.state)
+		assert.Equal(t, c.valid, err == nil, c.state)
+		if err != nil {
+			assert.True(t, errors.Is(err, ErrInvalidStateFormat))
+		}
+	}
+}
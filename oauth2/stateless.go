@@ -0,0 +1,255 @@
+package oauth2
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dghubble/gologin"
+)
+
+const (
+	defaultStatelessStateMaxAge = 10 * time.Minute
+	// statelessStateHeader is the base64url JOSE header of stateless states
+	statelessStateHeader = "eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9" // {"alg":"HS256","typ":"JWT"}
+)
+
+// ReplayCache records the IDs of used stateless states so each state is
+// accepted once. Implementations must be safe for concurrent use.
+type ReplayCache interface {
+	// Used returns true if the state ID was already used.
+	Used(ctx context.Context, id string) (bool, error)
+	// Use records the state ID until its expiry. It returns
+	// ErrStateAlreadyUsed if the ID was already used.
+	Use(ctx context.Context, id string, expiry time.Time) error
+}
+
+// StatelessStateConfig configures StatelessStateHandler.
+type StatelessStateConfig struct {
+	// Key is the server-side HMAC-SHA256 key which signs states. Required.
+	Key []byte
+	// MaxAge is how long states may be used after they are issued. Defaults
+	// to 10 minutes.
+	MaxAge time.Duration
+	// ReplayCache, if set, rejects states which were already used with
+	// ErrStateAlreadyUsed. States are recorded as used once CallbackHandler
+	// exchanges the code, so a failed exchange may be retried. Without one,
+	// a state may be reused until it expires.
+	ReplayCache ReplayCache
+	// PKCE sends a PKCE (RFC 7636) code challenge whose code verifier is
+	// derived from the state ID and Key, so the callback can recompute it
+	// without a cookie.
+	PKCE bool
+}
+
+// statelessClaims are the claims of a stateless state.
+type statelessClaims struct {
+	ID        string `json:"jti"`
+	IssuedAt  int64  `json:"iat"`
+	Expiry    int64  `json:"exp"`
+	ReturnURL string `json:"ret,omitempty"`
+	PKCE      bool   `json:"pkce,omitempty"`
+}
+
+// StatelessStateHandler protects logins which cannot set cookies (e.g. links
+// in emails or native webviews which partition cookies) with states which
+// are themselves compact HMAC-SHA256 signed JWTs of a random ID (jti), an
+// expiry, and the ctx return URL (see ReturnURLHandler), if any. Use it in
+// place of StateHandler on both the login and callback routes.
+//
+// On login requests, a new state is added to the ctx for LoginHandler (and,
+// if the config has PKCE, a derived PKCE code verifier). On callback requests
+// (with a "state" parameter), the state's signature and expiry are verified
+// and its ID is checked against the ReplayCache, if any, before the state
+// (and any return URL and PKCE verifier) is added to the ctx for
+// CallbackHandler. Invalid states call the failure handler with a 400 and
+// ErrInvalidState, ErrStateExpired, or ErrStateAlreadyUsed. CallbackHandler
+// records the ID with the ReplayCache only once the code is exchanged, so
+// users may retry callbacks whose exchange failed.
+//
+// Stateless states are not bound to a browser, so they give no login CSRF
+// protection: an attacker can start a login to get a valid state, log in to
+// their own account, and hand a victim the callback URL, which logs the
+// victim in as the attacker. Whenever cookies are available, use StateHandler
+// or a SessionBinding (see StateHandlerWithSessionBinding) instead. Panics if
+// the config has no Key.
+func StatelessStateHandler(config StatelessStateConfig, success, failure http.Handler) http.Handler {
+	if len(config.Key) == 0 {
+		panic("oauth2: StatelessStateConfig requires a Key")
+	}
+	if config.MaxAge <= 0 {
+		config.MaxAge = defaultStatelessStateMaxAge
+	}
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		if state := req.FormValue("state"); state != "" {
+			claims, err := config.verify(ctx, state)
+			if err != nil {
+				ctx = gologin.WithError(ctx, err)
+				ctx = gologin.WithStatusCode(ctx, http.StatusBadRequest)
+				failure.ServeHTTP(w, req.WithContext(ctx))
+				return
+			}
+			ctx = WithState(ctx, state)
+			ctx = config.withClaims(ctx, claims)
+			if config.ReplayCache != nil {
+				ctx = withStateUse(ctx, func(ctx context.Context) error {
+					return config.ReplayCache.Use(ctx, claims.ID, time.Unix(claims.Expiry, 0))
+				})
+			}
+			success.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		id, err := randomState(ctx)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			ctx = gologin.WithStatusCode(ctx, http.StatusInternalServerError)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		now := gologin.ClockFromContext(ctx).Now()
+		claims := &statelessClaims{
+			ID:       id,
+			IssuedAt: now.Unix(),
+			Expiry:   now.Add(config.MaxAge).Unix(),
+			PKCE:     config.PKCE,
+		}
+		if returnURL, err := ReturnURLFromContext(ctx); err == nil {
+			claims.ReturnURL = returnURL
+		}
+		state, err := config.sign(claims)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			ctx = gologin.WithStatusCode(ctx, http.StatusInternalServerError)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		ctx = WithState(ctx, state)
+		ctx = config.withClaims(ctx, claims)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return callbackFormHandler(http.HandlerFunc(fn), failure)
+}
+
+// withClaims returns a copy of ctx with the state's expiry, return URL, and
+// PKCE verifier, if any.
+func (c StatelessStateConfig) withClaims(ctx context.Context, claims *statelessClaims) context.Context {
+	ctx = WithStateExpiry(ctx, time.Unix(claims.Expiry, 0))
+	if claims.ReturnURL != "" {
+		ctx = WithReturnURL(ctx, claims.ReturnURL)
+	}
+	if claims.PKCE {
+		ctx = WithPKCEVerifier(ctx, c.pkceVerifier(claims.ID))
+	}
+	return ctx
+}
+
+// sign returns the signed JWT of the claims.
+func (c StatelessStateConfig) sign(claims *statelessClaims) (string, error) {
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signingInput := statelessStateHeader + "." + base64.RawURLEncoding.EncodeToString(claimsJSON)
+	return signingInput + "." + c.signature(signingInput), nil
+}
+
+// verify returns the claims of the signed, unexpired, and (with a
+// ReplayCache) unused state. It does not record the state as used.
+func (c StatelessStateConfig) verify(ctx context.Context, state string) (*statelessClaims, error) {
+	parts := strings.Split(state, ".")
+	if len(parts) != 3 || parts[0] != statelessStateHeader {
+		return nil, ErrInvalidState
+	}
+	if !hmac.Equal([]byte(parts[2]), []byte(c.signature(parts[0]+"."+parts[1]))) {
+		return nil, ErrInvalidState
+	}
+	claimsJSON, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrInvalidState
+	}
+	claims := new(statelessClaims)
+	if err := json.Unmarshal(claimsJSON, claims); err != nil || claims.ID == "" {
+		return nil, ErrInvalidState
+	}
+	expiry := time.Unix(claims.Expiry, 0)
+	// reject states which outlive the MaxAge, e.g. signed with a longer one
+	if !gologin.ClockFromContext(ctx).Now().Before(expiry) || expiry.Sub(time.Unix(claims.IssuedAt, 0)) > c.MaxAge {
+		return nil, ErrStateExpired
+	}
+	if c.ReplayCache != nil {
+		used, err := c.ReplayCache.Used(ctx, claims.ID)
+		if err != nil {
+			return nil, err
+		}
+		if used {
+			return nil, ErrStateAlreadyUsed
+		}
+	}
+	return claims, nil
+}
+
+// signature returns the base64url encoded HMAC-SHA256 of the signing input.
+func (c StatelessStateConfig) signature(signingInput string) string {
+	mac := hmac.New(sha256.New, c.Key)
+	mac.Write([]byte(signingInput))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// pkceVerifier returns the PKCE code verifier of the state ID, the base64url
+// encoded HMAC-SHA256 of the ID (43 characters, as RFC 7636 requires).
+func (c StatelessStateConfig) pkceVerifier(id string) string {
+	mac := hmac.New(sha256.New, c.Key)
+	mac.Write([]byte("pkce:" + id))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// MemoryReplayCache is an in-memory ReplayCache for a single server.
+type MemoryReplayCache struct {
+	mu sync.Mutex
+	// used maps used state IDs to their expiry
+	used map[string]time.Time
+}
+
+// NewMemoryReplayCache returns a new, empty MemoryReplayCache.
+func NewMemoryReplayCache() *MemoryReplayCache {
+	return &MemoryReplayCache{
+		used: make(map[string]time.Time),
+	}
+}
+
+// Used returns true if the state ID is recorded and unexpired.
+func (c *MemoryReplayCache) Used(ctx context.Context, id string) (bool, error) {
+	now := gologin.ClockFromContext(ctx).Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	expiry, ok := c.used[id]
+	return ok && !now.After(expiry), nil
+}
+
+// Use records the state ID, or returns ErrStateAlreadyUsed if it is already
+// recorded. Expired IDs are removed.
+func (c *MemoryReplayCache) Use(ctx context.Context, id string, expiry time.Time) error {
+	now := gologin.ClockFromContext(ctx).Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for usedID, usedExpiry := range c.used {
+		if now.After(usedExpiry) {
+			delete(c.used, usedID)
+		}
+	}
+	if _, ok := c.used[id]; ok {
+		return ErrStateAlreadyUsed
+	}
+	c.used[id] = expiry
+	return nil
+}
@@ -0,0 +1,197 @@
+package oauth2
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/gologintest"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+var testStatelessConfig = StatelessStateConfig{
+	Key: []byte("stateless-state-signing-key"),
+}
+
+// statelessLogin runs the login handler and returns the redirect's state and
+// code challenge.
+func statelessLogin(t *testing.T, login http.Handler, target string) (state, challenge string) {
+	w := httptest.NewRecorder()
+	login.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+	assert.Equal(t, http.StatusFound, w.Code)
+	location, err := url.Parse(w.HeaderMap.Get("Location"))
+	assert.Nil(t, err)
+	return location.Query().Get("state"), location.Query().Get("code_challenge")
+}
+
+func TestStatelessStateHandler(t *testing.T) {
+	var verifier string
+	server := NewTestServerFunc(func(w http.ResponseWriter, req *http.Request) {
+		verifier = req.PostFormValue("code_verifier")
+		w.Header().Set(contentType, jsonContentType)
+		w.Write([]byte(`{"access_token":"2YotnFZFEjr1zCsicMWpAA","token_type":"example"}`))
+	})
+	defer server.Close()
+	config := &oauth2.Config{
+		ClientID: "client_id",
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  "https://api.example.com/authorize",
+			TokenURL: server.URL,
+		},
+	}
+	stateConfig := testStatelessConfig
+	stateConfig.PKCE = true
+	failure := testutils.AssertFailureNotCalled(t)
+	login := ReturnURLHandler(testReturnURLConfig, StatelessStateHandler(stateConfig, LoginHandler(config, failure), failure), failure)
+	callback := StatelessStateHandler(stateConfig, CallbackHandler(config, ReturnURLRedirectHandler("/"), failure), failure)
+
+	// StatelessStateHandler on the login and callback routes, assert that:
+	// - the login redirect has a signed state and a PKCE code challenge
+	// - the callback succeeds without cookies
+	// - the derived PKCE verifier matches the code challenge
+	// - the return URL is carried in the state
+	state, challenge := statelessLogin(t, login, "/login?next="+url.QueryEscape("/settings"))
+	assert.Len(t, strings.Split(state, "."), 3)
+	assert.NotEmpty(t, challenge)
+	w := httptest.NewRecorder()
+	callback.ServeHTTP(w, httptest.NewRequest("GET", "/callback?code=any_code&state="+url.QueryEscape(state), nil))
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "/settings", w.HeaderMap.Get("Location"))
+	assert.Empty(t, w.Header().Get("Set-Cookie"))
+	assert.Equal(t, challenge, codeChallengeS256(verifier))
+}
+
+func TestStatelessStateHandler_Errors(t *testing.T) {
+	clock := gologintest.NewFakeClock(time.Unix(1500000000, 0))
+	now := clock.Now()
+	valid, err := testStatelessConfig.sign(&statelessClaims{ID: "a1b2c3", IssuedAt: now.Unix(), Expiry: now.Add(time.Minute).Unix()})
+	assert.Nil(t, err)
+	expired, err := testStatelessConfig.sign(&statelessClaims{ID: "a1b2c3", IssuedAt: now.Add(-time.Hour).Unix(), Expiry: now.Add(-time.Minute).Unix()})
+	assert.Nil(t, err)
+	tooLong, err := testStatelessConfig.sign(&statelessClaims{ID: "a1b2c3", IssuedAt: now.Unix(), Expiry: now.Add(24 * time.Hour).Unix()})
+	assert.Nil(t, err)
+	wrongKey := StatelessStateConfig{Key: []byte("other-key"), MaxAge: time.Minute}
+	otherKey, err := wrongKey.sign(&statelessClaims{ID: "a1b2c3", IssuedAt: now.Unix(), Expiry: now.Add(time.Minute).Unix()})
+	assert.Nil(t, err)
+	// swap in claims with a later expiry, keeping the original signature
+	parts := strings.Split(valid, ".")
+	parts[1] = base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"jti":"a1b2c3","iat":%d,"exp":%d}`, now.Unix(), now.Add(5*time.Minute).Unix())))
+	tampered := strings.Join(parts, ".")
+
+	cases := []struct {
+		name  string
+		state string
+		err   error
+	}{
+		{"tampered claims", tampered, ErrInvalidState},
+		{"wrong key", otherKey, ErrInvalidState},
+		{"malformed", "not-a-signed-state", ErrInvalidState},
+		{"truncated signature", valid[:len(valid)-2], ErrInvalidState},
+		{"expired", expired, ErrStateExpired},
+		{"exceeds max age", tooLong, ErrStateExpired},
+	}
+	for _, c := range cases {
+		failure := func(w http.ResponseWriter, req *http.Request) {
+			ctx := req.Context()
+			assert.Equal(t, c.err, gologin.ErrorFromContext(ctx), c.name)
+			w.WriteHeader(gologin.StatusCodeFromContext(ctx))
+		}
+		// StatelessStateHandler with an invalid callback state, assert that:
+		// - the failure handler is called with the error and a 400
+		handler := StatelessStateHandler(testStatelessConfig, testutils.AssertSuccessNotCalled(t), http.HandlerFunc(failure))
+		w := httptest.NewRecorder()
+		gologin.ClockHandler(clock, handler).ServeHTTP(w, httptest.NewRequest("GET", "/callback?code=any_code&state="+url.QueryEscape(c.state), nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code, c.name)
+	}
+
+	// a valid state expires once the clock passes its expiry
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, ErrStateExpired, gologin.ErrorFromContext(req.Context()))
+		w.WriteHeader(gologin.StatusCodeFromContext(req.Context()))
+	}
+	clock.Advance(time.Minute)
+	w := httptest.NewRecorder()
+	handler := StatelessStateHandler(testStatelessConfig, testutils.AssertSuccessNotCalled(t), http.HandlerFunc(failure))
+	gologin.ClockHandler(clock, handler).ServeHTTP(w, httptest.NewRequest("GET", "/callback?code=any_code&state="+url.QueryEscape(valid), nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	assert.Panics(t, func() { StatelessStateHandler(StatelessStateConfig{}, nil, nil) })
+}
+
+func TestStatelessStateHandler_Replay(t *testing.T) {
+	unavailable := true
+	server := NewTestServerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if unavailable {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set(contentType, jsonContentType)
+		w.Write([]byte(`{"access_token":"2YotnFZFEjr1zCsicMWpAA","token_type":"example"}`))
+	})
+	defer server.Close()
+	config := &oauth2.Config{
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  "https://api.example.com/authorize",
+			TokenURL: server.URL,
+		},
+	}
+	stateConfig := testStatelessConfig
+	stateConfig.ReplayCache = NewMemoryReplayCache()
+	success := func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(w, "success handler called")
+	}
+	var failures []error
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		failures = append(failures, gologin.ErrorFromContext(req.Context()))
+		fmt.Fprintf(w, "failure handler called")
+	}
+	login := StatelessStateHandler(stateConfig, LoginHandler(config, testutils.AssertFailureNotCalled(t)), http.HandlerFunc(failure))
+	callback := StatelessStateHandler(stateConfig, CallbackHandler(config, http.HandlerFunc(success), http.HandlerFunc(failure)), http.HandlerFunc(failure))
+	state, _ := statelessLogin(t, login, "/login")
+	target := "/callback?code=any_code&state=" + url.QueryEscape(state)
+
+	// StatelessStateHandler with a ReplayCache, assert that:
+	// - a callback whose code exchange fails does not use the state
+	// - retrying the callback succeeds
+	// - replaying the state fails with ErrStateAlreadyUsed
+	w := httptest.NewRecorder()
+	callback.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+	assert.Equal(t, "failure handler called", w.Body.String())
+	unavailable = false
+	w = httptest.NewRecorder()
+	callback.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+	assert.Equal(t, "success handler called", w.Body.String())
+	w = httptest.NewRecorder()
+	callback.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+	assert.Equal(t, "failure handler called", w.Body.String())
+	if assert.Len(t, failures, 2) {
+		assert.NotEqual(t, ErrStateAlreadyUsed, failures[0])
+		assert.Equal(t, ErrStateAlreadyUsed, failures[1])
+	}
+}
+
+func TestMemoryReplayCache(t *testing.T) {
+	cache := NewMemoryReplayCache()
+	ctx := context.Background()
+	used, err := cache.Used(ctx, "a1")
+	assert.Nil(t, err)
+	assert.False(t, used)
+	assert.Nil(t, cache.Use(ctx, "a1", time.Now().Add(time.Minute)))
+	used, err = cache.Used(ctx, "a1")
+	assert.Nil(t, err)
+	assert.True(t, used)
+	assert.Equal(t, ErrStateAlreadyUsed, cache.Use(ctx, "a1", time.Now().Add(time.Minute)))
+	assert.Nil(t, cache.Use(ctx, "b2", time.Now().Add(-time.Second)))
+	// expired IDs are removed
+	assert.Nil(t, cache.Use(ctx, "c3", time.Now().Add(time.Minute)))
+	assert.Len(t, cache.used, 2)
+}
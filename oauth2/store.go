@@ -0,0 +1,219 @@
+package oauth2
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/internal"
+	"golang.org/x/oauth2"
+)
+
+// Errors which may occur when verifying a stored state.
+var (
+	ErrStateNotFound = errors.New("oauth2: state not found")
+)
+
+// consumedStatePrefix marks state cookie values which were already used.
+const consumedStatePrefix = "used:"
+
+// StateStore persists OAuth2 state values between the login phase and the
+// callback phase.
+type StateStore interface {
+	// Save persists the state issued to the requester.
+	Save(ctx context.Context, w http.ResponseWriter, req *http.Request, state string) error
+	// Verify returns the state previously saved for the requester or an
+	// error (e.g. ErrStateNotFound) if there is none. States which were
+	// cleared should be reported with ErrStateAlreadyUsed, if possible.
+	Verify(ctx context.Context, req *http.Request) (string, error)
+	// Clear marks the state saved for the requester consumed (or removes it).
+	Clear(ctx context.Context, w http.ResponseWriter, req *http.Request) error
+}
+
+// StateHandlerWithStore generates a non-guessable state value, saves it in
+// the StateStore, and adds it to the ctx. If the state cannot be saved, the
+// failure handler is called.
+//
+// Use StateHandlerWithStore on the login route and CallbackHandlerWithStore
+// on the redirection URI route.
+func StateHandlerWithStore(store StateStore, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		state, err := randomState(ctx)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			ctx = gologin.WithStatusCode(ctx, http.StatusInternalServerError)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		if err := store.Save(ctx, w, req, state); err != nil {
+			ctx = gologin.WithError(ctx, err)
+			ctx = gologin.WithStatusCode(ctx, http.StatusInternalServerError)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		ctx = WithState(ctx, state)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// CallbackHandlerWithStore handles OAuth2 redirection URI requests like
+// CallbackHandler, but reads the expected state from the StateStore rather
+// than the ctx. Once a Token is obtained, the state is cleared from the store
+// so it cannot be used again. The state is not cleared if the code exchange
+// fails so users may retry.
+func CallbackHandlerWithStore(config *oauth2.Config, store StateStore, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	success = clearStateHandler(store, success, failure)
+	success = CallbackHandler(config, success, failure)
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		state, err := store.Verify(ctx, req)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			ctx = gologin.WithStatusCode(ctx, http.StatusBadRequest)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		ctx = WithState(ctx, state)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return callbackFormHandler(http.HandlerFunc(fn), failure)
+}
+
+// clearStateHandler clears the requester's state from the StateStore before
+// calling the success handler. If the state cannot be cleared, the failure
+// handler is called.
+func clearStateHandler(store StateStore, success, failure http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		if err := store.Clear(ctx, w, req); err != nil {
+			ctx = gologin.WithError(ctx, err)
+			ctx = gologin.WithStatusCode(ctx, http.StatusInternalServerError)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		success.ServeHTTP(w, req)
+	}
+	return http.HandlerFunc(fn)
+}
+
+// cookieStateStore is a StateStore which keeps the state in a short-lived
+// cookie, like StateHandler.
+type cookieStateStore struct {
+	config gologin.CookieConfig
+}
+
+// NewCookieStateStore returns a StateStore which keeps the state in a
+// short-lived cookie configured by the CookieConfig.
+func NewCookieStateStore(config gologin.CookieConfig) StateStore {
+	return &cookieStateStore{config: config}
+}
+
+func (s *cookieStateStore) Save(ctx context.Context, w http.ResponseWriter, req *http.Request, state string) error {
+	http.SetCookie(w, internal.NewCookie(s.config, state))
+	return nil
+}
+
+func (s *cookieStateStore) Verify(ctx context.Context, req *http.Request) (string, error) {
+	cookie, err := req.Cookie(s.config.Name)
+	if err != nil || cookie.Value == "" {
+		return "", ErrStateNotFound
+	}
+	if _, ok := consumedState(cookie.Value); ok {
+		return "", ErrStateAlreadyUsed
+	}
+	return cookie.Value, nil
+}
+
+func (s *cookieStateStore) Clear(ctx context.Context, w http.ResponseWriter, req *http.Request) error {
+	return clearStateCookie(s.config, w, req)
+}
+
+// MemoryStateStore is an in-memory StateStore which recognizes saved states
+// by the "state" parameter of callback requests. It is intended for tests
+// and single process development servers.
+type MemoryStateStore struct {
+	mu sync.Mutex
+	// states maps saved states to whether they were consumed
+	states map[string]bool
+}
+
+// NewMemoryStateStore returns a new, empty MemoryStateStore.
+func NewMemoryStateStore() *MemoryStateStore {
+	return &MemoryStateStore{
+		states: make(map[string]bool),
+	}
+}
+
+// Save adds the state to the store.
+func (s *MemoryStateStore) Save(ctx context.Context, w http.ResponseWriter, req *http.Request, state string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.states[state] = false
+	return nil
+}
+
+// Verify returns the callback request's state parameter if it was saved.
+// Otherwise, ErrStateNotFound is returned, or ErrStateAlreadyUsed if the
+// state was cleared.
+func (s *MemoryStateStore) Verify(ctx context.Context, req *http.Request) (string, error) {
+	state := req.FormValue("state")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	consumed, ok := s.states[state]
+	if !ok || state == "" {
+		return "", ErrStateNotFound
+	}
+	if consumed {
+		return "", ErrStateAlreadyUsed
+	}
+	return state, nil
+}
+
+// Clear marks the callback request's state parameter consumed.
+func (s *MemoryStateStore) Clear(ctx context.Context, w http.ResponseWriter, req *http.Request) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.states[req.FormValue("state")]; ok {
+		s.states[req.FormValue("state")] = true
+	}
+	return nil
+}
+
+// consumedStateCookie returns a state cookie which marks the state consumed.
+func consumedStateCookie(config gologin.CookieConfig, state string) *http.Cookie {
+	return internal.NewCookie(config, consumedStatePrefix+state)
+}
+
+// consumedState returns the state of a consumed state cookie value and true,
+// or false if the value is not consumed.
+func consumedState(value string) (string, bool) {
+	if !strings.HasPrefix(value, consumedStatePrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(value, consumedStatePrefix), true
+}
+
+// clearStateCookie replaces the requester's state cookie with one which marks
+// the state consumed.
+func clearStateCookie(config gologin.CookieConfig, w http.ResponseWriter, req *http.Request) error {
+	cookie, err := req.Cookie(config.Name)
+	if err != nil || cookie.Value == "" {
+		http.SetCookie(w, internal.ExpiredCookie(config))
+		return nil
+	}
+	if _, ok := consumedState(cookie.Value); !ok {
+		http.SetCookie(w, consumedStateCookie(config, cookie.Value))
+	}
+	return nil
+}
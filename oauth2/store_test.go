@@ -0,0 +1,224 @@
+package oauth2
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+// failingStateStore is a StateStore whose operations always fail.
+type failingStateStore struct{}
+
+func (failingStateStore) Save(ctx context.Context, w http.ResponseWriter, req *http.Request, state string) error {
+	return errors.New("store unavailable")
+}
+
+func (failingStateStore) Verify(ctx context.Context, req *http.Request) (string, error) {
+	return "", errors.New("store unavailable")
+}
+
+func (failingStateStore) Clear(ctx context.Context, w http.ResponseWriter, req *http.Request) error {
+	return errors.New("store unavailable")
+}
+
+func TestStateHandlerWithStore(t *testing.T) {
+	store := NewMemoryStateStore()
+	success := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		state, err := StateFromContext(ctx)
+		assert.Nil(t, err)
+		// state was saved to the store
+		verifyReq, _ := http.NewRequest("GET", "/?state="+state, nil)
+		saved, err := store.Verify(ctx, verifyReq)
+		assert.Nil(t, err)
+		assert.Equal(t, state, saved)
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// StateHandlerWithStore assert that:
+	// - a random state is saved to the StateStore
+	// - the state is added to the ctx of the success handler
+	handler := StateHandlerWithStore(store, http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestStateHandlerWithStore_SaveError(t *testing.T) {
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		err := gologin.ErrorFromContext(ctx)
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "store unavailable", err.Error())
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	handler := StateHandlerWithStore(failingStateStore{}, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestCallbackHandlerWithStore(t *testing.T) {
+	server := NewAccessTokenServer(t, `{"access_token":"2YotnFZFEjr1zCsicMWpAA","token_type":"example"}`)
+	defer server.Close()
+	config := &oauth2.Config{
+		Endpoint: oauth2.Endpoint{
+			TokenURL: server.URL,
+		},
+	}
+	store := NewMemoryStateStore()
+	store.Save(context.Background(), nil, nil, "d4e5f6")
+
+	success := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := TokenFromContext(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, "2YotnFZFEjr1zCsicMWpAA", token.AccessToken)
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// CallbackHandlerWithStore assert that:
+	// - the state is verified against the StateStore
+	// - success handler is called with the Token
+	// - the state is consumed in the StateStore
+	callbackHandler := CallbackHandlerWithStore(config, store, http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	callbackHandler.ServeHTTP(w, req)
+	assert.Equal(t, "success handler called", w.Body.String())
+	_, err := store.Verify(context.Background(), req)
+	assert.Equal(t, ErrStateAlreadyUsed, err)
+}
+
+func TestCallbackHandlerWithStore_UnsavedState(t *testing.T) {
+	config := &oauth2.Config{}
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		err := gologin.ErrorFromContext(ctx)
+		if assert.NotNil(t, err) {
+			assert.Equal(t, ErrStateNotFound, err)
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// CallbackHandlerWithStore called with a state that was never saved,
+	// assert that:
+	// - failure handler is called
+	// - ErrStateNotFound is added to the ctx
+	callbackHandler := CallbackHandlerWithStore(config, NewMemoryStateStore(), success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	callbackHandler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestCookieStateStore(t *testing.T) {
+	store := NewCookieStateStore(gologin.DebugOnlyCookieConfig)
+	ctx := context.Background()
+
+	// Save sets a state cookie
+	w := httptest.NewRecorder()
+	err := store.Save(ctx, w, nil, "some_state")
+	assert.Nil(t, err)
+	cookies := (&http.Response{Header: w.Header()}).Cookies()
+	if assert.Len(t, cookies, 1) {
+		assert.Equal(t, gologin.DebugOnlyCookieConfig.Name, cookies[0].Name)
+		assert.Equal(t, "some_state", cookies[0].Value)
+	}
+
+	// Verify reads the state cookie
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.AddCookie(cookies[0])
+	state, err := store.Verify(ctx, req)
+	assert.Nil(t, err)
+	assert.Equal(t, "some_state", state)
+
+	// Clear marks the state cookie consumed
+	w = httptest.NewRecorder()
+	err = store.Clear(ctx, w, req)
+	assert.Nil(t, err)
+	cookies = (&http.Response{Header: w.Header()}).Cookies()
+	if assert.Len(t, cookies, 1) {
+		assert.Equal(t, consumedStatePrefix+"some_state", cookies[0].Value)
+		req, _ = http.NewRequest("GET", "/", nil)
+		req.AddCookie(cookies[0])
+		_, err = store.Verify(ctx, req)
+		assert.Equal(t, ErrStateAlreadyUsed, err)
+	}
+
+	// Verify without a state cookie
+	req, _ = http.NewRequest("GET", "/", nil)
+	_, err = store.Verify(ctx, req)
+	assert.Equal(t, ErrStateNotFound, err)
+}
+
+func TestCallbackHandlerWithStore_Replay(t *testing.T) {
+	server := NewAccessTokenServer(t, `{"access_token":"2YotnFZFEjr1zCsicMWpAA","token_type":"example"}`)
+	defer server.Close()
+	config := &oauth2.Config{
+		Endpoint: oauth2.Endpoint{
+			TokenURL: server.URL,
+		},
+	}
+	store := NewMemoryStateStore()
+	store.Save(context.Background(), nil, nil, "d4e5f6")
+	success := func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.Equal(t, ErrStateAlreadyUsed, err)
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// CallbackHandlerWithStore called twice with the same state, assert that:
+	// - the first callback succeeds
+	// - the replayed callback fails with ErrStateAlreadyUsed
+	callbackHandler := CallbackHandlerWithStore(config, store, http.HandlerFunc(success), http.HandlerFunc(failure))
+	for _, expected := range []string{"success handler called", "failure handler called"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+		callbackHandler.ServeHTTP(w, req)
+		assert.Equal(t, expected, w.Body.String())
+	}
+}
+
+func TestStateStore_FailureStatusCodes(t *testing.T) {
+	config := &oauth2.Config{}
+	store := failingStateStore{}
+	cases := []struct {
+		name    string
+		handler http.Handler
+		status  int
+	}{
+		{"save error", StateHandlerWithStore(store, testutils.AssertSuccessNotCalled(t), nil), http.StatusInternalServerError},
+		{"verify error", CallbackHandlerWithStore(config, store, testutils.AssertSuccessNotCalled(t), nil), http.StatusBadRequest},
+		{"clear error", clearStateHandler(store, testutils.AssertSuccessNotCalled(t), gologin.DefaultFailureHandler), http.StatusInternalServerError},
+	}
+	for _, c := range cases {
+		req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+		w := httptest.NewRecorder()
+		c.handler.ServeHTTP(w, req)
+		// StateStore failures assert that:
+		// - the DefaultFailureHandler responds with the failure point's status
+		assert.Equal(t, c.status, w.Code, c.name)
+	}
+}
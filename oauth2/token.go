@@ -0,0 +1,125 @@
+package oauth2
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/dghubble/gologin"
+	"golang.org/x/oauth2"
+)
+
+const defaultTokenField = "access_token"
+
+// ErrMissingToken is the error when a TokenHandler request has no access
+// token field.
+var ErrMissingToken = errors.New("oauth2: missing access token field")
+
+// TokenVerifier verifies provider access tokens which clients (e.g. mobile
+// or single-page apps) obtained natively and sent to the backend.
+type TokenVerifier interface {
+	// VerifyToken returns the provider user of the access token, or an error
+	// if the token is invalid or was not issued to the app.
+	VerifyToken(ctx context.Context, accessToken string) (user interface{}, err error)
+}
+
+// TokenVerifierFunc is an adapter to allow an ordinary function to be used as
+// a TokenVerifier.
+type TokenVerifierFunc func(ctx context.Context, accessToken string) (interface{}, error)
+
+// VerifyToken calls f(ctx, accessToken).
+func (f TokenVerifierFunc) VerifyToken(ctx context.Context, accessToken string) (interface{}, error) {
+	return f(ctx, accessToken)
+}
+
+// UserContextVerifier is a TokenVerifier which adds the users it verifies to
+// a ctx, using its provider's WithUser (e.g. facebook NewTokenVerifier).
+type UserContextVerifier interface {
+	TokenVerifier
+	// WithUser returns a copy of ctx that stores the verified user.
+	WithUser(ctx context.Context, user interface{}) context.Context
+}
+
+// TokenConfig configures TokenHandlerWithConfig.
+type TokenConfig struct {
+	// Field is the JSON or form field of the access token. Defaults to
+	// "access_token".
+	Field string
+	// Precheck is called before the token is read and verified (e.g. to rate
+	// limit requests by client address, since access tokens may be guessed).
+	// If it returns an error, the failure handler is called with the error
+	// and status 429 Too Many Requests.
+	Precheck func(req *http.Request) error
+	// Body limits the methods, content types, and size of token requests.
+	// Defaults to form or JSON POSTs of at most gologin.DefaultMaxBodyBytes.
+	Body gologin.BodyConfig
+}
+
+// TokenHandler receives a provider access token obtained natively by a mobile
+// or single-page app as a POSTed "access_token" JSON or form field and
+// verifies it with the TokenVerifier. If the token is valid, the Token is
+// added to the ctx (and the user, if the verifier is a UserContextVerifier)
+// and the success handler is called. Otherwise, the failure handler is
+// called with ErrMissingToken or the verifier error. Requests are hardened
+// with gologin.BodyHandler, so other methods, content types, and bodies
+// over 64KB fail with gologin.ErrMethodNotAllowed,
+// gologin.ErrUnsupportedContentType, and gologin.ErrBodyTooLarge.
+func TokenHandler(verifier TokenVerifier, success, failure http.Handler) http.Handler {
+	return TokenHandlerWithConfig(verifier, TokenConfig{}, success, failure)
+}
+
+// TokenHandlerWithConfig handles access tokens like TokenHandler, but reads
+// the token from the Config Field, calls the Config Precheck before reading
+// the token, and
+// limits requests with the Config Body.
+func TokenHandlerWithConfig(verifier TokenVerifier, config TokenConfig, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	field := config.Field
+	if field == "" {
+		field = defaultTokenField
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		if config.Precheck != nil {
+			if err := config.Precheck(req); err != nil {
+				ctx = gologin.WithError(ctx, err)
+				ctx = gologin.WithStatusCode(ctx, http.StatusTooManyRequests)
+				failure.ServeHTTP(w, req.WithContext(ctx))
+				return
+			}
+		}
+		accessToken := parseToken(req, field)
+		if accessToken == "" {
+			ctx = gologin.WithError(ctx, ErrMissingToken)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		user, err := verifier.VerifyToken(ctx, accessToken)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		ctx = WithToken(ctx, &oauth2.Token{AccessToken: accessToken, TokenType: "Bearer"})
+		if v, ok := verifier.(UserContextVerifier); ok {
+			ctx = v.WithUser(ctx, user)
+		}
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return gologin.BodyHandler(config.Body, http.HandlerFunc(fn), failure)
+}
+
+// parseToken returns the string field of a JSON or form body.
+func parseToken(req *http.Request, field string) string {
+	if strings.HasPrefix(req.Header.Get("Content-Type"), "application/json") {
+		var body map[string]interface{}
+		json.NewDecoder(req.Body).Decode(&body)
+		token, _ := body[field].(string)
+		return token
+	}
+	return req.PostFormValue(field)
+}
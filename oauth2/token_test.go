@@ -0,0 +1,188 @@
+package oauth2
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+)
+
+type testUserKey struct{}
+
+// testVerifier accepts the token "valid-token" as the user "ivy".
+type testVerifier struct{}
+
+func (v testVerifier) VerifyToken(ctx context.Context, accessToken string) (interface{}, error) {
+	if accessToken != "valid-token" {
+		return nil, errors.New("invalid token")
+	}
+	return "ivy", nil
+}
+
+func (v testVerifier) WithUser(ctx context.Context, user interface{}) context.Context {
+	return context.WithValue(ctx, testUserKey{}, user)
+}
+
+func TestTokenHandler(t *testing.T) {
+	cases := []struct {
+		contentType string
+		body        string
+	}{
+		{"application/json", `{"access_token": "valid-token"}`},
+		{"application/json; charset=utf-8", `{"access_token": "valid-token", "other": 1}`},
+		{"application/x-www-form-urlencoded", url.Values{"access_token": {"valid-token"}}.Encode()},
+	}
+	for _, c := range cases {
+		success := func(w http.ResponseWriter, req *http.Request) {
+			ctx := req.Context()
+			token, err := TokenFromContext(ctx)
+			assert.Nil(t, err)
+			assert.Equal(t, "valid-token", token.AccessToken)
+			assert.Equal(t, "Bearer", token.TokenType)
+			assert.Equal(t, "ivy", ctx.Value(testUserKey{}))
+			fmt.Fprintf(w, "success handler called")
+		}
+		failure := testutils.AssertFailureNotCalled(t)
+
+		// TokenHandler assert that:
+		// - the access token is read from a JSON or form body
+		// - the Token and verified user are added to the ctx
+		// - success handler is called
+		handler := TokenHandler(testVerifier{}, http.HandlerFunc(success), failure)
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/token", strings.NewReader(c.body))
+		req.Header.Set("Content-Type", c.contentType)
+		handler.ServeHTTP(w, req)
+		assert.Equal(t, "success handler called", w.Body.String(), c.contentType)
+	}
+}
+
+func TestTokenHandler_Field(t *testing.T) {
+	success := func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// TokenHandlerWithConfig with a Field, assert that:
+	// - the access token is read from the field
+	// - TokenVerifierFunc verifiers are supported
+	verifier := TokenVerifierFunc(func(ctx context.Context, accessToken string) (interface{}, error) {
+		assert.Equal(t, "valid-token", accessToken)
+		return "ivy", nil
+	})
+	handler := TokenHandlerWithConfig(verifier, TokenConfig{Field: "token"}, http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/token", strings.NewReader(`{"access_token": "other", "token": "valid-token"}`))
+	req.Header.Set("Content-Type", "application/json")
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestTokenHandler_Errors(t *testing.T) {
+	cases := []struct {
+		name   string
+		method string
+		body   string
+		err    string
+	}{
+		{"GET", "GET", `{"access_token": "valid-token"}`, gologin.ErrMethodNotAllowed.Error()},
+		{"missing token", "POST", `{"token": "valid-token"}`, ErrMissingToken.Error()},
+		{"non-string token", "POST", `{"access_token": 123}`, ErrMissingToken.Error()},
+		{"invalid JSON", "POST", `{"access_token": `, ErrMissingToken.Error()},
+		{"invalid token", "POST", `{"access_token": "guess"}`, "invalid token"},
+	}
+	for _, c := range cases {
+		failure := func(w http.ResponseWriter, req *http.Request) {
+			err := gologin.ErrorFromContext(req.Context())
+			if assert.NotNil(t, err, c.name) {
+				assert.Equal(t, c.err, err.Error(), c.name)
+			}
+			fmt.Fprintf(w, "failure handler called")
+		}
+
+		// TokenHandler with invalid requests, assert that:
+		// - GET requests and missing or invalid tokens call the failure handler
+		handler := TokenHandler(testVerifier{}, testutils.AssertSuccessNotCalled(t), http.HandlerFunc(failure))
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(c.method, "/token", strings.NewReader(c.body))
+		req.Header.Set("Content-Type", "application/json")
+		handler.ServeHTTP(w, req)
+		assert.Equal(t, "failure handler called", w.Body.String(), c.name)
+	}
+}
+
+func TestTokenHandler_Body(t *testing.T) {
+	oversized := `{"access_token": "valid-token", "padding": "` + strings.Repeat("a", gologin.DefaultMaxBodyBytes) + `"}`
+	var upload bytes.Buffer
+	writer := multipart.NewWriter(&upload)
+	writer.WriteField("access_token", "valid-token")
+	writer.Close()
+	cases := []struct {
+		name        string
+		config      TokenConfig
+		contentType string
+		body        string
+		status      int
+		err         error
+	}{
+		{"oversized", TokenConfig{}, "application/json", oversized, http.StatusRequestEntityTooLarge, gologin.ErrBodyTooLarge},
+		{"custom max", TokenConfig{Body: gologin.BodyConfig{MaxBodyBytes: 16}}, "application/json", `{"access_token": "valid-token"}`, http.StatusRequestEntityTooLarge, gologin.ErrBodyTooLarge},
+		{"multipart", TokenConfig{}, writer.FormDataContentType(), upload.String(), http.StatusUnsupportedMediaType, gologin.ErrUnsupportedContentType},
+	}
+	for _, c := range cases {
+		failure := func(w http.ResponseWriter, req *http.Request) {
+			ctx := req.Context()
+			assert.Equal(t, c.err, gologin.ErrorFromContext(ctx), c.name)
+			w.WriteHeader(gologin.StatusCodeFromContext(ctx))
+		}
+
+		// TokenHandler with an oversized or multipart body, assert that:
+		// - the token is not verified
+		// - the failure handler is called with the typed error and status
+		handler := TokenHandlerWithConfig(testVerifier{}, c.config, testutils.AssertSuccessNotCalled(t), http.HandlerFunc(failure))
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/token", strings.NewReader(c.body))
+		req.Header.Set("Content-Type", c.contentType)
+		handler.ServeHTTP(w, req)
+		assert.Equal(t, c.status, w.Code, c.name)
+	}
+}
+
+func TestTokenHandler_Precheck(t *testing.T) {
+	errRateLimited := errors.New("too many login attempts")
+	verifier := TokenVerifierFunc(func(ctx context.Context, accessToken string) (interface{}, error) {
+		t.Errorf("unexpected call to VerifyToken")
+		return nil, nil
+	})
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		assert.Equal(t, errRateLimited, gologin.ErrorFromContext(ctx))
+		assert.Equal(t, http.StatusTooManyRequests, gologin.StatusCodeFromContext(ctx))
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// TokenHandlerWithConfig with a failing Precheck, assert that:
+	// - the token is not verified
+	// - failure handler is called with the error and a 429 status
+	config := TokenConfig{
+		Precheck: func(req *http.Request) error {
+			return errRateLimited
+		},
+	}
+	handler := TokenHandlerWithConfig(verifier, config, testutils.AssertSuccessNotCalled(t), http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/token", strings.NewReader(`{"access_token": "valid-token"}`))
+	req.Header.Set("Content-Type", "application/json")
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
@@ -0,0 +1,155 @@
+package oauth2
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+
+	"github.com/dghubble/gologin"
+	"golang.org/x/oauth2"
+)
+
+// Errors which may occur when saving Tokens to a TokenStore.
+var (
+	ErrMissingTokenUserID = errors.New("oauth2: missing user ID to save the Token")
+	ErrTokenNotFound      = errors.New("oauth2: Token not found")
+)
+
+// TokenStore persists users' provider Tokens.
+type TokenStore interface {
+	// Save stores the Token of the provider user, replacing any stored
+	// Token. Tokens without a RefreshToken (e.g. providers which only issue
+	// one on first consent, or refreshes which do not rotate it) should keep
+	// the stored refresh token.
+	Save(ctx context.Context, provider, userID string, token *oauth2.Token) error
+}
+
+// TokenStoreConfig configures saving Tokens to a TokenStore.
+type TokenStoreConfig struct {
+	// UserID returns the user ID of the Token. Defaults to the ID of the ctx
+	// gologin Profile, which provider callback handlers add.
+	UserID func(ctx context.Context) (string, error)
+	// OnError, if set, is called with Save errors (e.g. to log them) and the
+	// request continues to the success handler. By default, Save errors call
+	// the failure handler.
+	OnError func(ctx context.Context, err error)
+}
+
+// TokenStoreHandler returns a login success handler which saves the ctx Token
+// to the store, with the provider name and user ID of the ctx gologin Profile
+// (see TokenStoreConfig UserID), then calls the success handler. Use it as the
+// success handler of a provider CallbackHandler so every successful callback
+// persists the Token. The provider name falls back to the ctx provider (see
+// gologin.ProviderHandler) or "oauth2".
+//
+// Missing Tokens or user IDs and Save errors are added to the ctx and call the
+// failure handler with a 500, unless the config OnError is set.
+func TokenStoreHandler(store TokenStore, config TokenStoreConfig, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := TokenFromContext(ctx)
+		if err == nil {
+			err = config.save(ctx, store, token)
+		}
+		if err != nil && config.OnError == nil {
+			ctx = gologin.WithError(ctx, err)
+			ctx = gologin.WithStatusCode(ctx, http.StatusInternalServerError)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		if err != nil {
+			config.OnError(ctx, err)
+		}
+		success.ServeHTTP(w, req)
+	}
+	return http.HandlerFunc(fn)
+}
+
+// TokenStoreSaveFunc returns a RefreshHandler TokenSaveFunc which saves
+// refreshed Tokens to the store. RefreshHandler requests usually have no
+// gologin Profile, so set the config UserID (e.g. from the application
+// session) and a gologin.ProviderHandler. Save errors are returned to
+// RefreshHandler, unless the config OnError is set.
+func TokenStoreSaveFunc(store TokenStore, config TokenStoreConfig) TokenSaveFunc {
+	return func(req *http.Request, token *oauth2.Token) error {
+		ctx := req.Context()
+		err := config.save(ctx, store, token)
+		if err != nil && config.OnError != nil {
+			config.OnError(ctx, err)
+			return nil
+		}
+		return err
+	}
+}
+
+// save saves the Token with the ctx provider and user ID.
+func (c TokenStoreConfig) save(ctx context.Context, store TokenStore, token *oauth2.Token) error {
+	provider := gologin.ProviderFromContext(ctx, "oauth2")
+	var userID string
+	if c.UserID != nil {
+		id, err := c.UserID(ctx)
+		if err != nil {
+			return err
+		}
+		userID = id
+	}
+	if profile, err := gologin.ProfileFromContext(ctx); err == nil {
+		provider = profile.Provider
+		if c.UserID == nil {
+			userID = profile.ID
+		}
+	}
+	if userID == "" {
+		return ErrMissingTokenUserID
+	}
+	return store.Save(ctx, provider, userID, token)
+}
+
+// MemoryTokenStore is an in-memory TokenStore for tests and development.
+type MemoryTokenStore struct {
+	mu sync.Mutex
+	// tokens maps provider and user IDs to Tokens
+	tokens map[memoryTokenKey]oauth2.Token
+}
+
+// memoryTokenKey identifies the Token of a provider user.
+type memoryTokenKey struct {
+	provider, userID string
+}
+
+// NewMemoryTokenStore returns a new, empty MemoryTokenStore.
+func NewMemoryTokenStore() *MemoryTokenStore {
+	return &MemoryTokenStore{
+		tokens: make(map[memoryTokenKey]oauth2.Token),
+	}
+}
+
+// Save stores a copy of the Token, keeping the stored refresh token if the
+// Token has none.
+func (s *MemoryTokenStore) Save(ctx context.Context, provider, userID string, token *oauth2.Token) error {
+	key := memoryTokenKey{provider, userID}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	saved := *token
+	if saved.RefreshToken == "" {
+		saved.RefreshToken = s.tokens[key].RefreshToken
+	}
+	s.tokens[key] = saved
+	return nil
+}
+
+// Token returns a copy of the stored Token of the provider user, or
+// ErrTokenNotFound.
+func (s *MemoryTokenStore) Token(ctx context.Context, provider, userID string) (*oauth2.Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	token, ok := s.tokens[memoryTokenKey{provider, userID}]
+	if !ok {
+		return nil, ErrTokenNotFound
+	}
+	return &token, nil
+}
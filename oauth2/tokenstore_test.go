@@ -0,0 +1,237 @@
+package oauth2
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+// errorTokenStore is a TokenStore whose Save fails.
+type errorTokenStore struct {
+	err error
+}
+
+func (s errorTokenStore) Save(ctx context.Context, provider, userID string, token *oauth2.Token) error {
+	return s.err
+}
+
+// profileHandler adds a gologin Profile to the ctx, like a provider callback
+// handler.
+func profileHandler(profile *gologin.Profile, success http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := gologin.WithProfile(req.Context(), profile)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+func TestTokenStoreHandler(t *testing.T) {
+	server := NewAccessTokenServer(t, `{"access_token":"2YotnFZFEjr1zCsicMWpAA","token_type":"example","refresh_token":"tGzv3JOkF0XG5Qx2TlKWIA"}`)
+	defer server.Close()
+	config := &oauth2.Config{
+		Endpoint: oauth2.Endpoint{
+			TokenURL: server.URL,
+		},
+	}
+	store := NewMemoryTokenStore()
+	success := func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+	profile := &gologin.Profile{Provider: "github", ID: "917408"}
+
+	// CallbackHandler with a TokenStoreHandler success handler, assert that:
+	// - the exchanged Token is saved with the Profile provider and ID
+	// - the success handler is called
+	tokenStore := TokenStoreHandler(store, TokenStoreConfig{}, http.HandlerFunc(success), failure)
+	handler := CallbackHandler(config, profileHandler(profile, tokenStore), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	ctx := WithState(req.Context(), "d4e5f6")
+	handler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+	token, err := store.Token(ctx, "github", "917408")
+	if assert.Nil(t, err) {
+		assert.Equal(t, "2YotnFZFEjr1zCsicMWpAA", token.AccessToken)
+		assert.Equal(t, "tGzv3JOkF0XG5Qx2TlKWIA", token.RefreshToken)
+	}
+}
+
+func TestTokenStoreHandler_UserID(t *testing.T) {
+	store := NewMemoryTokenStore()
+	config := TokenStoreConfig{
+		UserID: func(ctx context.Context) (string, error) {
+			return "app-user-1", nil
+		},
+	}
+	success := func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(w, "success handler called")
+	}
+
+	// TokenStoreHandler with a UserID func and no Profile, assert that:
+	// - the Token is saved with the UserID and ctx provider
+	handler := gologin.ProviderHandler("gitea", TokenStoreHandler(store, config, http.HandlerFunc(success), testutils.AssertFailureNotCalled(t)))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	ctx := WithToken(req.Context(), &oauth2.Token{AccessToken: "some-token"})
+	handler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+	token, err := store.Token(ctx, "gitea", "app-user-1")
+	if assert.Nil(t, err) {
+		assert.Equal(t, "some-token", token.AccessToken)
+	}
+}
+
+func TestTokenStoreHandler_Errors(t *testing.T) {
+	errSave := errors.New("database unavailable")
+	profile := &gologin.Profile{Provider: "github", ID: "917408"}
+	cases := []struct {
+		name    string
+		store   TokenStore
+		profile *gologin.Profile
+		token   *oauth2.Token
+		err     error
+	}{
+		{"save error", errorTokenStore{errSave}, profile, &oauth2.Token{AccessToken: "some-token"}, errSave},
+		{"missing user ID", NewMemoryTokenStore(), nil, &oauth2.Token{AccessToken: "some-token"}, ErrMissingTokenUserID},
+		{"missing token", NewMemoryTokenStore(), profile, nil, nil},
+	}
+	for _, c := range cases {
+		failure := func(w http.ResponseWriter, req *http.Request) {
+			ctx := req.Context()
+			err := gologin.ErrorFromContext(ctx)
+			assert.NotNil(t, err, c.name)
+			if c.err != nil {
+				assert.Equal(t, c.err, err, c.name)
+			}
+			w.WriteHeader(gologin.StatusCodeFromContext(ctx))
+		}
+		req, _ := http.NewRequest("GET", "/", nil)
+		ctx := req.Context()
+		if c.profile != nil {
+			ctx = gologin.WithProfile(ctx, c.profile)
+		}
+		if c.token != nil {
+			ctx = WithToken(ctx, c.token)
+		}
+		req = req.WithContext(ctx)
+
+		// TokenStoreHandler, assert that:
+		// - errors call the failure handler with a 500 by default
+		handler := TokenStoreHandler(c.store, TokenStoreConfig{}, testutils.AssertSuccessNotCalled(t), http.HandlerFunc(failure))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusInternalServerError, w.Code, c.name)
+
+		// - errors are passed to OnError and the success handler is called
+		var onError error
+		config := TokenStoreConfig{
+			OnError: func(ctx context.Context, err error) { onError = err },
+		}
+		success := func(w http.ResponseWriter, req *http.Request) {
+			fmt.Fprintf(w, "success handler called")
+		}
+		handler = TokenStoreHandler(c.store, config, http.HandlerFunc(success), testutils.AssertFailureNotCalled(t))
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		assert.Equal(t, "success handler called", w.Body.String(), c.name)
+		assert.NotNil(t, onError, c.name)
+		if c.err != nil {
+			assert.Equal(t, c.err, onError, c.name)
+		}
+	}
+}
+
+func TestTokenStoreSaveFunc_RefreshRotation(t *testing.T) {
+	responses := []string{
+		`{"access_token":"first_access","token_type":"example","expires_in":3600,"refresh_token":"rotated_refresh"}`,
+		`{"access_token":"second_access","token_type":"example","expires_in":3600}`,
+	}
+	server := NewTestServerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set(contentType, jsonContentType)
+		w.Write([]byte(responses[0]))
+		responses = responses[1:]
+	})
+	defer server.Close()
+	config := &oauth2.Config{
+		Endpoint: oauth2.Endpoint{
+			TokenURL: server.URL,
+		},
+	}
+	store := NewMemoryTokenStore()
+	ctx := context.Background()
+	assert.Nil(t, store.Save(ctx, "example", "app-user-1", &oauth2.Token{
+		AccessToken:  "expired_token",
+		RefreshToken: "original_refresh",
+		Expiry:       time.Now().Add(-time.Hour),
+	}))
+	provider := TokenSourceProviderFunc(func(req *http.Request) (*oauth2.Token, error) {
+		token, err := store.Token(req.Context(), "example", "app-user-1")
+		if err == nil {
+			// expire the stored token so each request refreshes
+			token.Expiry = time.Now().Add(-time.Hour)
+		}
+		return token, err
+	})
+	save := TokenStoreSaveFunc(store, TokenStoreConfig{
+		UserID: func(ctx context.Context) (string, error) {
+			return "app-user-1", nil
+		},
+	})
+	success := func(w http.ResponseWriter, req *http.Request) {}
+	handler := gologin.ProviderHandler("example", RefreshHandler(config, provider, save, http.HandlerFunc(success), testutils.AssertFailureNotCalled(t)))
+
+	// RefreshHandler with a TokenStoreSaveFunc, assert that:
+	// - a rotated refresh token overwrites the stored refresh token
+	// - a refresh without a new refresh token keeps the rotated refresh token
+	cases := []struct {
+		accessToken  string
+		refreshToken string
+	}{
+		{"first_access", "rotated_refresh"},
+		{"second_access", "rotated_refresh"},
+	}
+	for _, c := range cases {
+		req, _ := http.NewRequest("GET", "/", nil)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		token, err := store.Token(ctx, "example", "app-user-1")
+		if assert.Nil(t, err) {
+			assert.Equal(t, c.accessToken, token.AccessToken)
+			assert.Equal(t, c.refreshToken, token.RefreshToken)
+		}
+	}
+}
+
+func TestMemoryTokenStore(t *testing.T) {
+	store := NewMemoryTokenStore()
+	ctx := context.Background()
+	_, err := store.Token(ctx, "github", "917408")
+	assert.Equal(t, ErrTokenNotFound, err)
+
+	// MemoryTokenStore, assert that:
+	// - Tokens are stored per provider user
+	// - saving a Token replaces the stored Token
+	// - the stored refresh token is kept if the Token has none
+	assert.Nil(t, store.Save(ctx, "github", "917408", &oauth2.Token{AccessToken: "a1", RefreshToken: "r1"}))
+	assert.Nil(t, store.Save(ctx, "google", "917408", &oauth2.Token{AccessToken: "g1"}))
+	assert.Nil(t, store.Save(ctx, "github", "917408", &oauth2.Token{AccessToken: "a2"}))
+	token, err := store.Token(ctx, "github", "917408")
+	assert.Nil(t, err)
+	assert.Equal(t, &oauth2.Token{AccessToken: "a2", RefreshToken: "r1"}, token)
+	assert.Nil(t, store.Save(ctx, "github", "917408", &oauth2.Token{AccessToken: "a3", RefreshToken: "r2"}))
+	token, err = store.Token(ctx, "github", "917408")
+	assert.Nil(t, err)
+	assert.Equal(t, &oauth2.Token{AccessToken: "a3", RefreshToken: "r2"}, token)
+	token, err = store.Token(ctx, "google", "917408")
+	assert.Nil(t, err)
+	assert.Equal(t, "g1", token.AccessToken)
+}
@@ -0,0 +1,56 @@
+package oidc
+
+import (
+	"context"
+	"fmt"
+)
+
+// unexported key type prevents collisions
+type key int
+
+const (
+	claimsKey key = iota
+	userInfoKey
+)
+
+// WithClaims returns a copy of ctx that stores the verified ID token Claims.
+func WithClaims(ctx context.Context, claims *Claims) context.Context {
+	return context.WithValue(ctx, claimsKey, claims)
+}
+
+// ClaimsFromContext returns the verified ID token Claims from the ctx. The
+// raw id_token is available as the Claims RawIDToken.
+func ClaimsFromContext(ctx context.Context) (*Claims, error) {
+	claims, ok := ctx.Value(claimsKey).(*Claims)
+	if !ok {
+		return nil, fmt.Errorf("oidc: Context missing Claims")
+	}
+	return claims, nil
+}
+
+// WithUserInfo returns a copy of ctx that stores the UserInfo.
+func WithUserInfo(ctx context.Context, userInfo *UserInfo) context.Context {
+	return context.WithValue(ctx, userInfoKey, userInfo)
+}
+
+// UserInfoFromContext returns the UserInfo from the ctx.
+func UserInfoFromContext(ctx context.Context) (*UserInfo, error) {
+	userInfo, ok := ctx.Value(userInfoKey).(*UserInfo)
+	if !ok {
+		return nil, fmt.Errorf("oidc: Context missing UserInfo")
+	}
+	return userInfo, nil
+}
+
+// WithIDToken returns a copy of ctx that stores the Claims of a verified ID
+// token. Provider handlers which verify id_tokens with an IDTokenVerifier
+// (e.g. google) use it so the Claims are read the same way for any provider.
+func WithIDToken(ctx context.Context, claims *Claims) context.Context {
+	return WithClaims(ctx, claims)
+}
+
+// IDTokenFromContext returns the Claims of the verified ID token from the
+// ctx. The raw id_token is available as the Claims RawIDToken.
+func IDTokenFromContext(ctx context.Context) (*Claims, error) {
+	return ClaimsFromContext(ctx)
+}
@@ -0,0 +1,3 @@
+// Package oidc provides login and callback handlers for OpenID Connect
+// providers (e.g. Okta, Keycloak, Azure AD, Dex) configured by discovery.
+package oidc
@@ -0,0 +1,222 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/dghubble/gologin"
+)
+
+// Errors which may occur verifying an ID token.
+var (
+	ErrMissingIDToken   = errors.New("oidc: Token missing id_token")
+	ErrInvalidIDToken   = errors.New("oidc: invalid id_token")
+	ErrInvalidSignature = errors.New("oidc: invalid id_token signature")
+	ErrInvalidIssuer    = errors.New("oidc: id_token issuer does not match")
+	ErrInvalidAudience  = errors.New("oidc: id_token audience does not match")
+	ErrIDTokenExpired   = errors.New("oidc: id_token expired")
+	ErrInvalidNonce     = errors.New("oidc: id_token nonce does not match")
+)
+
+// Claims are the claims of a verified ID token.
+// https://openid.net/specs/openid-connect-core-1_0.html#IDToken
+type Claims struct {
+	Issuer          string   `json:"iss"`
+	Subject         string   `json:"sub"`
+	Audience        Audience `json:"aud"`
+	Expiry          int64    `json:"exp"`
+	IssuedAt        int64    `json:"iat"`
+	Nonce           string   `json:"nonce"`
+	AuthorizedParty string   `json:"azp"`
+	Email           string   `json:"email"`
+	Name            string   `json:"name"`
+	// RawIDToken is the signed id_token.
+	RawIDToken string `json:"-"`
+	// Extra holds all claims, including non-standard claims.
+	Extra map[string]interface{} `json:"-"`
+}
+
+// Audience is the "aud" claim, which may be a string or an array of strings.
+type Audience []string
+
+// UnmarshalJSON decodes a string or an array of strings.
+func (a *Audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = Audience{single}
+		return nil
+	}
+	var multiple []string
+	if err := json.Unmarshal(data, &multiple); err != nil {
+		return err
+	}
+	*a = Audience(multiple)
+	return nil
+}
+
+// Contains returns true if the audience contains the client ID.
+func (a Audience) Contains(clientID string) bool {
+	for _, aud := range a {
+		if aud == clientID {
+			return true
+		}
+	}
+	return false
+}
+
+// jwtHeader is the JOSE header of an ID token.
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// IDTokenVerifier verifies ID tokens signed by an issuer's JSON Web Key Set.
+// Keys are cached (see NewIDTokenVerifier).
+type IDTokenVerifier struct {
+	issuers  []string
+	audience string
+	keys     *remoteKeySet
+}
+
+// NewIDTokenVerifier returns an IDTokenVerifier which accepts ID tokens signed
+// by a key in the JSON Web Key Set at the JWKS URL, for the audience (i.e. the
+// OAuth2 client ID), from one of the issuers.
+//
+// Keys are cached for the JWKS response's Cache-Control max-age. An unknown
+// key ID fetches the keys again since the issuer may have rotated its keys,
+// but at most once a minute so forged key IDs cannot hammer the JWKS URL.
+func NewIDTokenVerifier(jwksURL, audience string, issuers ...string) *IDTokenVerifier {
+	return &IDTokenVerifier{
+		issuers:  issuers,
+		audience: audience,
+		keys:     newRemoteKeySet(jwksURL),
+	}
+}
+
+// ForIssuers returns a copy of the IDTokenVerifier which accepts ID tokens
+// from one of the issuers instead, sharing the cached keys. It suits
+// multi-tenant providers whose issuer depends on the ID token's tenant.
+func (v *IDTokenVerifier) ForIssuers(issuers ...string) *IDTokenVerifier {
+	return &IDTokenVerifier{
+		issuers:  issuers,
+		audience: v.audience,
+		keys:     v.keys,
+	}
+}
+
+// Verify checks the RS256 or ES256 signature of the raw id_token and its
+// issuer, audience, and expiry (per the ctx gologin Clock) claims, then
+// returns its Claims. Keys are fetched with the ctx oauth2.HTTPClient.
+func (v *IDTokenVerifier) Verify(ctx context.Context, rawIDToken string) (*Claims, error) {
+	parts := strings.Split(rawIDToken, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidIDToken
+	}
+	header := new(jwtHeader)
+	if err := decodeSegment(parts[0], header); err != nil {
+		return nil, ErrInvalidIDToken
+	}
+	// reject "none" and unsupported algorithms before fetching keys
+	if header.Alg != "RS256" && header.Alg != "ES256" {
+		return nil, ErrInvalidIDToken
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidIDToken
+	}
+	key, err := v.keys.key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySignature(header.Alg, key, []byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return nil, err
+	}
+	claims := new(Claims)
+	if err := decodeSegment(parts[1], claims); err != nil {
+		return nil, ErrInvalidIDToken
+	}
+	if err := decodeSegment(parts[1], &claims.Extra); err != nil {
+		return nil, ErrInvalidIDToken
+	}
+	claims.RawIDToken = rawIDToken
+	if !v.validIssuer(claims.Issuer) {
+		return nil, ErrInvalidIssuer
+	}
+	// the audience must contain the client ID and, if there are multiple
+	// audiences, the authorized party must be the client (Core 3.1.3.7)
+	if !claims.Audience.Contains(v.audience) {
+		return nil, ErrInvalidAudience
+	}
+	if len(claims.Audience) > 1 && claims.AuthorizedParty != v.audience {
+		return nil, ErrInvalidAudience
+	}
+	if !gologin.ClockFromContext(ctx).Now().Before(time.Unix(claims.Expiry, 0)) {
+		return nil, ErrIDTokenExpired
+	}
+	return claims, nil
+}
+
+// validIssuer returns true if the issuer is one of the verifier's issuers.
+func (v *IDTokenVerifier) validIssuer(issuer string) bool {
+	for _, iss := range v.issuers {
+		if strings.TrimSuffix(issuer, "/") == strings.TrimSuffix(iss, "/") {
+			return true
+		}
+	}
+	return false
+}
+
+// verifySignature verifies an RS256 or ES256 JWS signature.
+func verifySignature(alg string, key crypto.PublicKey, signingInput, signature []byte) error {
+	digest := sha256.Sum256(signingInput)
+	switch alg {
+	case "RS256":
+		rsaKey, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return ErrInvalidSignature
+		}
+		if err := rsa.VerifyPKCS1v15(rsaKey, crypto.SHA256, digest[:], signature); err != nil {
+			return ErrInvalidSignature
+		}
+		return nil
+	case "ES256":
+		ecKey, ok := key.(*ecdsa.PublicKey)
+		if !ok || len(signature) != 64 {
+			return ErrInvalidSignature
+		}
+		// JWS ES256 signatures are the 32 byte big-endian R and S
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		if !ecdsa.Verify(ecKey, digest[:], r, s) {
+			return ErrInvalidSignature
+		}
+		return nil
+	}
+	return ErrInvalidIDToken
+}
+
+// decodeSegment decodes a base64url encoded JSON JWT segment into v.
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// stateNonce returns a nonce bound to the state, used when the ctx has no
+// nonce from an oauth2 NonceHandler. States are non-guessable and single use,
+// so a matching nonce binds the id_token to the login request.
+func stateNonce(state string) string {
+	sum := sha256.Sum256([]byte(state))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
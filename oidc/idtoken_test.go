@@ -0,0 +1,155 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/gologintest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAudience_UnmarshalJSON(t *testing.T) {
+	var claims Claims
+	assert.Nil(t, json.Unmarshal([]byte(`{"aud": "client_id"}`), &claims))
+	assert.Equal(t, Audience{"client_id"}, claims.Audience)
+	assert.Nil(t, json.Unmarshal([]byte(`{"aud": ["client_id", "other"]}`), &claims))
+	assert.Equal(t, Audience{"client_id", "other"}, claims.Audience)
+	assert.True(t, claims.Audience.Contains("other"))
+	assert.False(t, claims.Audience.Contains("unknown"))
+	assert.NotNil(t, json.Unmarshal([]byte(`{"aud": 42}`), &claims))
+}
+
+func TestIDTokenVerifier(t *testing.T) {
+	rsaKey, ecKey := newRSAKey("key1"), newECKey("key2")
+	issuer := newTestIssuer(rsaKey, ecKey)
+	defer issuer.Close()
+	verifier := NewIDTokenVerifier(issuer.URL+"/keys", testClientID, "https://other.example.com", issuer.URL)
+
+	// IDTokenVerifier assert that:
+	// - RS256 and ES256 id_tokens are verified
+	// - any of the issuers is accepted
+	// - typed Claims are returned
+	for _, key := range []testKey{rsaKey, ecKey} {
+		claims, err := verifier.Verify(context.Background(), key.sign(issuer.validClaims()))
+		if assert.Nil(t, err) {
+			assert.Equal(t, "248289761001", claims.Subject)
+			assert.Equal(t, Audience{testClientID}, claims.Audience)
+		}
+	}
+	// - keys are fetched once and cached
+	assert.Equal(t, 1, issuer.jwksRequests)
+}
+
+func TestIDTokenVerifier_ForIssuers(t *testing.T) {
+	key := newRSAKey("key1")
+	issuer := newTestIssuer(key)
+	defer issuer.Close()
+	verifier := NewIDTokenVerifier(issuer.URL+"/keys", testClientID)
+	idToken := key.sign(issuer.validClaims())
+
+	// IDTokenVerifier ForIssuers assert that:
+	// - only the given issuers are accepted
+	// - keys are shared with the original verifier
+	_, err := verifier.Verify(context.Background(), idToken)
+	assert.Equal(t, ErrInvalidIssuer, err)
+	_, err = verifier.ForIssuers(issuer.URL).Verify(context.Background(), idToken)
+	assert.Nil(t, err)
+	_, err = verifier.ForIssuers("https://other.example.com").Verify(context.Background(), idToken)
+	assert.Equal(t, ErrInvalidIssuer, err)
+	assert.Equal(t, 1, issuer.jwksRequests)
+}
+
+func TestIDTokenVerifier_CacheControl(t *testing.T) {
+	key := newRSAKey("key1")
+	issuer := newTestIssuer(key)
+	defer issuer.Close()
+	idToken := key.sign(issuer.validClaims())
+	cases := []struct {
+		cacheControl string
+		requests     int
+	}{
+		{"public, max-age=3600", 1},
+		{"no-store", 2},
+		{"max-age=0", 2},
+	}
+	for _, c := range cases {
+		issuer.cacheControl = c.cacheControl
+		issuer.jwksRequests = 0
+		verifier := NewIDTokenVerifier(issuer.URL+"/keys", testClientID, issuer.URL)
+
+		// IDTokenVerifier assert that:
+		// - keys are cached for the Cache-Control max-age
+		for i := 0; i < 2; i++ {
+			_, err := verifier.Verify(context.Background(), idToken)
+			assert.Nil(t, err)
+		}
+		assert.Equal(t, c.requests, issuer.jwksRequests, c.cacheControl)
+	}
+}
+
+func TestIDTokenVerifier_UnknownKeyRateLimit(t *testing.T) {
+	key := newRSAKey("key1")
+	issuer := newTestIssuer(key)
+	defer issuer.Close()
+	clock := gologintest.NewFakeClock(time.Now())
+	ctx := gologin.WithClock(context.Background(), clock)
+	verifier := NewIDTokenVerifier(issuer.URL+"/keys", testClientID, issuer.URL)
+	_, err := verifier.Verify(ctx, key.sign(issuer.validClaims()))
+	assert.Nil(t, err)
+
+	// IDTokenVerifier with forged key IDs, assert that:
+	// - the keys are fetched again for the first unknown key ID
+	// - later unknown key IDs do not fetch the keys again
+	forged := newRSAKey("forged").sign(issuer.validClaims())
+	for i := 0; i < 3; i++ {
+		_, err = verifier.Verify(ctx, forged)
+		assert.Equal(t, ErrUnknownKey, err)
+	}
+	assert.Equal(t, 2, issuer.jwksRequests)
+
+	// - the keys are fetched again once the ctx clock passes the refresh
+	// interval
+	clock.Advance(keyRefreshInterval)
+	_, err = verifier.Verify(ctx, forged)
+	assert.Equal(t, ErrUnknownKey, err)
+	assert.Equal(t, 3, issuer.jwksRequests)
+
+	// - cached keys expire after the max-age per the ctx clock
+	idToken := func() string {
+		claims := issuer.validClaims()
+		claims["exp"] = clock.Now().Add(time.Hour).Unix()
+		return key.sign(claims)
+	}
+	issuer.cacheControl = "max-age=600"
+	clock.Advance(defaultKeysMaxAge)
+	for _, requests := range []int{4, 4, 5} {
+		_, err = verifier.Verify(ctx, idToken())
+		assert.Nil(t, err)
+		assert.Equal(t, requests, issuer.jwksRequests)
+		clock.Advance(5 * time.Minute)
+	}
+}
+
+func TestCacheExpiry(t *testing.T) {
+	now := time.Unix(1500000000, 0)
+	cases := []struct {
+		cacheControl string
+		expiry       time.Time
+	}{
+		{"", now.Add(defaultKeysMaxAge)},
+		{"public, max-age=600, must-revalidate", now.Add(10 * time.Minute)},
+		{"Max-Age=60", now.Add(time.Minute)},
+		{"max-age=invalid", now.Add(defaultKeysMaxAge)},
+		{"no-cache", now},
+		{"private, no-store", now},
+	}
+	for _, c := range cases {
+		header := http.Header{}
+		header.Set("Cache-Control", c.cacheControl)
+		assert.Equal(t, c.expiry, cacheExpiry(header, now), c.cacheControl)
+	}
+}
@@ -0,0 +1,203 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/internal"
+)
+
+// Errors which may occur getting the issuer's signing keys.
+var (
+	ErrUnableToGetKeys = errors.New("oidc: unable to get issuer JSON Web Key Set")
+	ErrUnknownKey      = errors.New("oidc: id_token signed by an unknown key")
+)
+
+// jsonWebKey is an RSA or EC public JSON Web Key (RFC 7517).
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+	// EC
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// defaultKeysMaxAge is how long keys are cached if the JWKS response has no
+// Cache-Control max-age.
+const defaultKeysMaxAge = time.Hour
+
+// keyRefreshInterval is the minimum time between fetches for unknown key IDs.
+var keyRefreshInterval = time.Minute
+
+// remoteKeySet caches an issuer's signing keys by key ID.
+type remoteKeySet struct {
+	jwksURL string
+
+	mu        sync.Mutex
+	keys      map[string]crypto.PublicKey
+	expiry    time.Time
+	refreshed time.Time
+}
+
+func newRemoteKeySet(jwksURL string) *remoteKeySet {
+	return &remoteKeySet{jwksURL: jwksURL}
+}
+
+// key returns the public key with the key ID. Keys are fetched if they have
+// not been fetched or their cache lifetime has passed, per the ctx gologin
+// Clock. If the key ID is unknown, the keys are fetched again since the
+// issuer may have rotated its keys, at most once per keyRefreshInterval.
+func (s *remoteKeySet) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	// fetch while locked so concurrent verifications share a fetch
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := gologin.ClockFromContext(ctx).Now()
+	if s.keys != nil && now.Before(s.expiry) {
+		if key, ok := lookupKey(s.keys, kid); ok {
+			return key, nil
+		}
+		if now.Sub(s.refreshed) < keyRefreshInterval {
+			return nil, ErrUnknownKey
+		}
+		s.refreshed = now
+	}
+	keys, expiry, err := fetchKeys(ctx, s.jwksURL)
+	if err != nil {
+		return nil, err
+	}
+	s.keys, s.expiry = keys, expiry
+	if key, ok := lookupKey(keys, kid); ok {
+		return key, nil
+	}
+	return nil, ErrUnknownKey
+}
+
+// lookupKey returns the key with the key ID. An empty key ID matches the only
+// key of a single key set.
+func lookupKey(keys map[string]crypto.PublicKey, kid string) (crypto.PublicKey, bool) {
+	if key, ok := keys[kid]; ok {
+		return key, true
+	}
+	if kid == "" && len(keys) == 1 {
+		for _, key := range keys {
+			return key, true
+		}
+	}
+	return nil, false
+}
+
+// fetchKeys gets the JSON Web Key Set and parses its RSA and P-256 signing
+// keys. Other keys are skipped. Returns the keys and their cache expiry.
+func fetchKeys(ctx context.Context, jwksURL string) (map[string]crypto.PublicKey, time.Time, error) {
+	req, err := http.NewRequest("GET", jwksURL, nil)
+	if err != nil {
+		return nil, time.Time{}, &gologin.Error{Provider: "oidc", Op: "get keys", Err: err, Kind: ErrUnableToGetKeys}
+	}
+	resp, err := internal.ContextClient(ctx).Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, time.Time{}, &gologin.Error{Provider: "oidc", Op: "get keys", Err: err, Kind: ErrUnableToGetKeys}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, time.Time{}, &gologin.Error{Provider: "oidc", Op: "get keys", StatusCode: resp.StatusCode, Kind: ErrUnableToGetKeys}
+	}
+	var keySet struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&keySet); err != nil {
+		return nil, time.Time{}, &gologin.Error{Provider: "oidc", Op: "get keys", StatusCode: resp.StatusCode, Err: err, Kind: ErrUnableToGetKeys}
+	}
+	keys := make(map[string]crypto.PublicKey, len(keySet.Keys))
+	for _, jwk := range keySet.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		if key, err := jwk.publicKey(); err == nil {
+			keys[jwk.Kid] = key
+		}
+	}
+	return keys, cacheExpiry(resp.Header, gologin.ClockFromContext(ctx).Now()), nil
+}
+
+// cacheExpiry returns when a response expires according to its Cache-Control
+// header. Responses without a max-age expire after defaultKeysMaxAge.
+func cacheExpiry(header http.Header, now time.Time) time.Time {
+	for _, directive := range strings.Split(header.Get("Cache-Control"), ",") {
+		directive = strings.ToLower(strings.TrimSpace(directive))
+		switch {
+		case directive == "no-store" || directive == "no-cache":
+			return now
+		case strings.HasPrefix(directive, "max-age="):
+			if maxAge, err := strconv.Atoi(strings.TrimPrefix(directive, "max-age=")); err == nil && maxAge >= 0 {
+				return now.Add(time.Duration(maxAge) * time.Second)
+			}
+		}
+	}
+	return now.Add(defaultKeysMaxAge)
+}
+
+// publicKey returns the *rsa.PublicKey or P-256 *ecdsa.PublicKey.
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, errors.New("oidc: invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, errors.New("oidc: unsupported EC curve")
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !elliptic.P256().IsOnCurve(x, y) {
+			return nil, errors.New("oidc: invalid EC point")
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+	}
+	return nil, errors.New("oidc: unsupported key type")
+}
+
+// decodeBigInt decodes a base64url encoded big-endian integer.
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, errors.New("oidc: empty key parameter")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,131 @@
+package oidc
+
+import (
+	"net/http"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/internal"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"golang.org/x/oauth2"
+)
+
+// ProviderName is the provider name set in the ctx (see
+// gologin.ProviderFromContext). Wrap handlers with a gologin.ProviderHandler
+// to name the issuer instead.
+const ProviderName = "oidc"
+
+// StateHandler checks for a state cookie. If found, the state value is read
+// and added to the ctx. Otherwise, a non-guessable value is added to the ctx
+// and to a (short-lived) state cookie issued to the requester.
+//
+// Implements OAuth 2 RFC 6749 10.12 CSRF Protection. If you wish to issue
+// state params differently, write a http.Handler which sets the ctx state,
+// using oauth2 WithState(ctx, state) since it is required by LoginHandler
+// and CallbackHandler.
+func (p *Provider) StateHandler(config gologin.CookieConfig, success http.Handler) http.Handler {
+	return oauth2Login.StateHandler(config, success)
+}
+
+// LoginHandler handles OpenID Connect login requests by reading the state
+// value from the ctx and redirecting requests to the discovered AuthURL with
+// that state value and a nonce. The nonce is read from the ctx (see oauth2
+// NonceHandler) or derived from the state. Any AuthCodeOptions are added to
+// the AuthURL.
+func (p *Provider) LoginHandler(failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		config, err := p.oauth2Config(ctx)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		if _, err := oauth2Login.NonceFromContext(ctx); err != nil {
+			if state, err := oauth2Login.StateFromContext(ctx); err == nil {
+				ctx = oauth2Login.WithNonce(ctx, stateNonce(state))
+			}
+		}
+		oauth2Login.LoginHandler(config, failure, opts...).ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// CallbackHandler handles OpenID Connect redirection URI requests. The auth
+// code is exchanged for a Token and its id_token is verified against the
+// issuer's JSON Web Key Set, issuer, client ID audience, expiry, and nonce.
+// The verified Claims (including the raw id_token) are added to the ctx. If
+// the config Scopes request profile data (profile, email, address, or phone)
+// and the issuer has a userinfo endpoint, the UserInfo is also fetched and
+// added to the ctx. If authentication succeeds, handling delegates to the
+// success handler, otherwise to the failure handler. Any AuthCodeOptions are
+// sent with the token exchange.
+func (p *Provider) CallbackHandler(success, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		config, err := p.oauth2Config(ctx)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		handler := oauth2Login.CallbackHandler(config, p.idTokenHandler(config, success, failure), failure, opts...)
+		handler.ServeHTTP(w, req)
+	}
+	return http.HandlerFunc(fn)
+}
+
+// idTokenHandler is a http.Handler that verifies the id_token of the ctx
+// Token and adds its Claims (and the UserInfo, if requested) to the ctx.
+func (p *Provider) idTokenHandler(config *oauth2.Config, success, failure http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		rawIDToken, ok := token.Extra("id_token").(string)
+		if !ok || rawIDToken == "" {
+			ctx = gologin.WithError(ctx, ErrMissingIDToken)
+			ctx = gologin.WithStatusCode(ctx, http.StatusBadGateway)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		claims, err := p.verifyIDToken(ctx, rawIDToken)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		nonce, err := oauth2Login.NonceFromContext(ctx)
+		if err != nil {
+			state, _ := oauth2Login.StateFromContext(ctx)
+			nonce = stateNonce(state)
+		}
+		if claims.Nonce != nonce {
+			ctx = gologin.WithError(ctx, ErrInvalidNonce)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		ctx = WithIDToken(ctx, claims)
+		if userInfoURL := p.userInfoURL(); userInfoURL != "" && wantsUserInfo(config.Scopes) {
+			userInfo, err := fetchUserInfo(ctx, config, token, userInfoURL, claims.Subject)
+			if err != nil {
+				ctx = gologin.WithError(ctx, err)
+				failure.ServeHTTP(w, req.WithContext(ctx))
+				return
+			}
+			ctx = WithUserInfo(ctx, userInfo)
+		}
+		ctx = internal.WithDefaultProvider(ctx, ProviderName)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
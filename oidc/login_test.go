@@ -0,0 +1,212 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/dghubble/gologin"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+// callback serves an OpenID Connect callback request with the testState.
+func callback(handler http.Handler) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state="+testState, nil)
+	ctx := oauth2Login.WithState(context.Background(), testState)
+	handler.ServeHTTP(w, req.WithContext(ctx))
+	return w
+}
+
+func TestLoginHandler(t *testing.T) {
+	issuer := newTestIssuer(newRSAKey("key1"))
+	defer issuer.Close()
+	config := &oauth2.Config{
+		ClientID:    testClientID,
+		RedirectURL: "redirect_url",
+		Scopes:      []string{"profile"},
+	}
+	provider := New(issuer.URL, config)
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// LoginHandler assert that:
+	// - redirects to the discovered AuthURL
+	// - the "openid" scope is requested
+	// - the nonce is derived from the state
+	loginHandler := provider.LoginHandler(failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	ctx := oauth2Login.WithState(context.Background(), testState)
+	loginHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, http.StatusFound, w.Code)
+	location, err := url.Parse(w.HeaderMap.Get("Location"))
+	if assert.Nil(t, err) {
+		assert.Equal(t, issuer.URL+"/authorize", location.Scheme+"://"+location.Host+location.Path)
+		assert.Equal(t, "openid profile", location.Query().Get("scope"))
+		assert.Equal(t, testState, location.Query().Get("state"))
+		assert.Equal(t, stateNonce(testState), location.Query().Get("nonce"))
+	}
+}
+
+func TestLoginHandler_DiscoveryNotFound(t *testing.T) {
+	_, mux, server := testutils.TestServer()
+	defer server.Close()
+	mux.HandleFunc("/", http.NotFound)
+	provider := New(server.URL, &oauth2.Config{ClientID: testClientID})
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		assert.True(t, errors.Is(err, ErrDiscoveryFailed))
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// LoginHandler with an issuer without discovery, assert that:
+	// - failure handler is called with ErrDiscoveryFailed
+	loginHandler := provider.LoginHandler(http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	ctx := oauth2Login.WithState(context.Background(), testState)
+	loginHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestCallbackHandler(t *testing.T) {
+	key := newRSAKey("key1")
+	issuer := newTestIssuer(key)
+	defer issuer.Close()
+	idToken := key.sign(issuer.validClaims())
+	issuer.setIDToken(idToken)
+	issuer.userInfo = `{"sub": "248289761001", "name": "Jane Doe", "email": "janedoe@example.com", "locale": "en"}`
+	config := &oauth2.Config{ClientID: testClientID, Scopes: []string{"profile"}}
+	provider := New(issuer.URL, config)
+
+	success := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		claims, err := ClaimsFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "248289761001", claims.Subject)
+			assert.Equal(t, "janedoe@example.com", claims.Email)
+			assert.Equal(t, idToken, claims.RawIDToken)
+			assert.Equal(t, "janedoe@example.com", claims.Extra["email"])
+		}
+		assert.Equal(t, ProviderName, gologin.ProviderFromContext(ctx, ""))
+		userInfo, err := UserInfoFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "Jane Doe", userInfo.Name)
+			assert.Equal(t, "en", userInfo.Claims["locale"])
+		}
+		token, err := oauth2Login.TokenFromContext(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, "any-token", token.AccessToken)
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// CallbackHandler assert that:
+	// - the code is exchanged at the discovered TokenURL
+	// - the id_token is verified and its Claims are added to the ctx
+	// - the UserInfo is fetched since the profile scope was requested
+	// - success handler is called
+	w := callback(provider.CallbackHandler(http.HandlerFunc(success), failure))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestCallbackHandler_KeyRotation(t *testing.T) {
+	oldKey, newKey := newRSAKey("key1"), newECKey("key2")
+	issuer := newTestIssuer(oldKey)
+	defer issuer.Close()
+	provider := New(issuer.URL, &oauth2.Config{ClientID: testClientID})
+	success := func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+	handler := provider.CallbackHandler(http.HandlerFunc(success), failure)
+
+	issuer.setIDToken(oldKey.sign(issuer.validClaims()))
+	w := callback(handler)
+	assert.Equal(t, "success handler called", w.Body.String())
+
+	// CallbackHandler after the issuer rotates its keys, assert that:
+	// - the JWKS is fetched again for the unknown key ID
+	// - the id_token signed by the new key is verified
+	issuer.setKeys(newKey)
+	issuer.setIDToken(newKey.sign(issuer.validClaims()))
+	w = callback(handler)
+	assert.Equal(t, "success handler called", w.Body.String())
+	assert.Equal(t, 2, issuer.jwksRequests)
+}
+
+func TestCallbackHandler_InvalidIDToken(t *testing.T) {
+	key, otherKey := newRSAKey("key1"), newRSAKey("key1")
+	issuer := newTestIssuer(key)
+	defer issuer.Close()
+	withClaim := func(name string, value interface{}) map[string]interface{} {
+		claims := issuer.validClaims()
+		claims[name] = value
+		return claims
+	}
+	cases := []struct {
+		idToken string
+		err     error
+	}{
+		{"", ErrMissingIDToken},
+		{"not-a-jwt", ErrInvalidIDToken},
+		{"eyJhbGciOiJub25lIn0.e30.", ErrInvalidIDToken},
+		{key.sign(withClaim("exp", time.Now().Add(-time.Minute).Unix())), ErrIDTokenExpired},
+		{key.sign(withClaim("iss", "https://evil.example.com")), ErrInvalidIssuer},
+		{key.sign(withClaim("aud", "other_client")), ErrInvalidAudience},
+		{key.sign(withClaim("aud", []string{testClientID, "other_client"})), ErrInvalidAudience},
+		{key.sign(withClaim("nonce", "other_nonce")), ErrInvalidNonce},
+		{otherKey.sign(issuer.validClaims()), ErrInvalidSignature},
+		{newRSAKey("forged").sign(issuer.validClaims()), ErrUnknownKey},
+	}
+	provider := New(issuer.URL, &oauth2.Config{ClientID: testClientID})
+	success := testutils.AssertSuccessNotCalled(t)
+	for _, c := range cases {
+		issuer.setIDToken(c.idToken)
+		failure := func(w http.ResponseWriter, req *http.Request) {
+			assert.Equal(t, c.err, gologin.ErrorFromContext(req.Context()))
+			fmt.Fprintf(w, "failure handler called")
+		}
+
+		// CallbackHandler with an invalid id_token, assert that:
+		// - failure handler is called with a distinguishable error
+		w := callback(provider.CallbackHandler(success, http.HandlerFunc(failure)))
+		assert.Equal(t, "failure handler called", w.Body.String())
+	}
+}
+
+func TestCallbackHandler_UserInfoError(t *testing.T) {
+	key := newRSAKey("key1")
+	issuer := newTestIssuer(key)
+	defer issuer.Close()
+	issuer.setIDToken(key.sign(issuer.validClaims()))
+	cases := []struct {
+		userInfo string
+		err      error
+	}{
+		{"", ErrUnableToGetUserInfo},
+		{`{"sub": "other_subject"}`, ErrUserInfoSubjectMismatch},
+	}
+	provider := New(issuer.URL, &oauth2.Config{ClientID: testClientID, Scopes: []string{"email"}})
+	success := testutils.AssertSuccessNotCalled(t)
+	for _, c := range cases {
+		issuer.userInfo = c.userInfo
+		failure := func(w http.ResponseWriter, req *http.Request) {
+			assert.True(t, errors.Is(gologin.ErrorFromContext(req.Context()), c.err))
+			fmt.Fprintf(w, "failure handler called")
+		}
+
+		// CallbackHandler with an unusable UserInfo response, assert that:
+		// - failure handler is called
+		w := callback(provider.CallbackHandler(success, http.HandlerFunc(failure)))
+		assert.Equal(t, "failure handler called", w.Body.String())
+	}
+}
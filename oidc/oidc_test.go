@@ -0,0 +1,164 @@
+package oidc
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+)
+
+const (
+	testClientID = "client_id"
+	testState    = "d4e5f6"
+)
+
+// testKey is a signing key of a testIssuer.
+type testKey struct {
+	kid string
+	key crypto.Signer
+}
+
+func newRSAKey(kid string) testKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	return testKey{kid: kid, key: key}
+}
+
+func newECKey(kid string) testKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	return testKey{kid: kid, key: key}
+}
+
+// jwk returns the public JSON Web Key.
+func (k testKey) jwk() map[string]string {
+	encode := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+	switch key := k.key.(type) {
+	case *rsa.PrivateKey:
+		return map[string]string{"kty": "RSA", "kid": k.kid, "use": "sig", "n": encode(key.N.Bytes()), "e": encode(big.NewInt(int64(key.E)).Bytes())}
+	case *ecdsa.PrivateKey:
+		x, y := make([]byte, 32), make([]byte, 32)
+		key.X.FillBytes(x)
+		key.Y.FillBytes(y)
+		return map[string]string{"kty": "EC", "kid": k.kid, "crv": "P-256", "x": encode(x), "y": encode(y)}
+	}
+	panic("unsupported key")
+}
+
+// sign returns a JWT with the claims signed by the key.
+func (k testKey) sign(claims map[string]interface{}) string {
+	alg := "RS256"
+	if _, ok := k.key.(*ecdsa.PrivateKey); ok {
+		alg = "ES256"
+	}
+	headerJSON, _ := json.Marshal(map[string]string{"alg": alg, "kid": k.kid, "typ": "JWT"})
+	claimsJSON, _ := json.Marshal(claims)
+	signingInput := base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString(claimsJSON)
+	digest := sha256.Sum256([]byte(signingInput))
+	var signature []byte
+	switch key := k.key.(type) {
+	case *rsa.PrivateKey:
+		signature, _ = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	case *ecdsa.PrivateKey:
+		r, s, _ := ecdsa.Sign(rand.Reader, key, digest[:])
+		signature = make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// testIssuer is a fake OpenID Connect issuer with discovery, JWKS, token, and
+// userinfo endpoints.
+type testIssuer struct {
+	*httptest.Server
+	mu           sync.Mutex
+	keys         []testKey
+	idToken      string
+	userInfo     string
+	cacheControl string
+	jwksRequests int
+}
+
+func newTestIssuer(keys ...testKey) *testIssuer {
+	issuer := &testIssuer{keys: keys}
+	mux := http.NewServeMux()
+	issuer.Server = httptest.NewServer(mux)
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"issuer":%q,"authorization_endpoint":%q,"token_endpoint":%q,"userinfo_endpoint":%q,"jwks_uri":%q}`,
+			issuer.URL, issuer.URL+"/authorize", issuer.URL+"/token", issuer.URL+"/userinfo", issuer.URL+"/keys")
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, req *http.Request) {
+		issuer.mu.Lock()
+		defer issuer.mu.Unlock()
+		issuer.jwksRequests++
+		jwks := make([]map[string]string, len(issuer.keys))
+		for i, key := range issuer.keys {
+			jwks[i] = key.jwk()
+		}
+		if issuer.cacheControl != "" {
+			w.Header().Set("Cache-Control", issuer.cacheControl)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": jwks})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, req *http.Request) {
+		issuer.mu.Lock()
+		defer issuer.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "any-token", "token_type": "Bearer", "id_token": issuer.idToken})
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, req *http.Request) {
+		issuer.mu.Lock()
+		defer issuer.mu.Unlock()
+		if req.Header.Get("Authorization") != "Bearer any-token" || issuer.userInfo == "" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(issuer.userInfo))
+	})
+	return issuer
+}
+
+// setIDToken sets the id_token returned by the token endpoint.
+func (i *testIssuer) setIDToken(idToken string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.idToken = idToken
+}
+
+// setKeys sets the keys served by the JWKS endpoint.
+func (i *testIssuer) setKeys(keys ...testKey) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.keys = keys
+}
+
+// validClaims returns claims which pass verification for testState.
+func (i *testIssuer) validClaims() map[string]interface{} {
+	return map[string]interface{}{
+		"iss":   i.URL,
+		"sub":   "248289761001",
+		"aud":   testClientID,
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"iat":   time.Now().Unix(),
+		"nonce": stateNonce(testState),
+		"email": "janedoe@example.com",
+	}
+}
@@ -0,0 +1,168 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/internal"
+	"golang.org/x/oauth2"
+)
+
+const discoveryPath = "/.well-known/openid-configuration"
+
+// Errors which may occur during discovery.
+var (
+	ErrDiscoveryFailed = errors.New("oidc: unable to get OpenID Connect discovery document")
+	ErrIssuerMismatch  = errors.New("oidc: discovery issuer does not match the issuer URL")
+)
+
+// discovery is the subset of the OpenID Connect discovery document used by
+// the Provider.
+// https://openid.net/specs/openid-connect-discovery-1_0.html#ProviderMetadata
+type discovery struct {
+	Issuer      string `json:"issuer"`
+	AuthURL     string `json:"authorization_endpoint"`
+	TokenURL    string `json:"token_endpoint"`
+	UserInfoURL string `json:"userinfo_endpoint"`
+	JWKSURL     string `json:"jwks_uri"`
+}
+
+// Provider is an OpenID Connect provider configured by discovery.
+type Provider struct {
+	issuerURL string
+	config    *oauth2.Config
+
+	mu         sync.Mutex
+	discovered *discovery
+	verifier   *IDTokenVerifier
+}
+
+// New returns a Provider for the issuer URL. The config ClientID,
+// ClientSecret, RedirectURL, and Scopes are used, while the Endpoint is filled
+// in from the issuer's discovery document (/.well-known/openid-configuration).
+//
+// The discovery document is fetched on first use, or by Discover, using the
+// ctx oauth2.HTTPClient. Failures are retried on later requests and passed to
+// failure handlers as ErrDiscoveryFailed or ErrIssuerMismatch.
+func New(issuerURL string, config *oauth2.Config) *Provider {
+	return &Provider{
+		issuerURL: strings.TrimSuffix(issuerURL, "/"),
+		config:    config,
+	}
+}
+
+// Discover fetches the issuer's discovery document if it has not been fetched
+// yet. Call it on startup to check the issuer is reachable.
+func (p *Provider) Discover(ctx context.Context) error {
+	_, err := p.discover(ctx)
+	return err
+}
+
+// Endpoint returns the discovered oauth2.Endpoint, fetching the discovery
+// document if needed.
+func (p *Provider) Endpoint(ctx context.Context) (oauth2.Endpoint, error) {
+	config, err := p.oauth2Config(ctx)
+	if err != nil {
+		return oauth2.Endpoint{}, err
+	}
+	return config.Endpoint, nil
+}
+
+// discover returns the cached discovery document or fetches it.
+func (p *Provider) discover(ctx context.Context) (*discovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovered != nil {
+		return p.discovered, nil
+	}
+	d, err := fetchDiscovery(ctx, p.issuerURL)
+	if err != nil {
+		return nil, err
+	}
+	p.discovered = d
+	p.verifier = NewIDTokenVerifier(d.JWKSURL, p.config.ClientID, d.Issuer)
+	return d, nil
+}
+
+// verifyIDToken verifies the raw id_token with the discovered issuer's keys.
+func (p *Provider) verifyIDToken(ctx context.Context, rawIDToken string) (*Claims, error) {
+	if _, err := p.discover(ctx); err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	verifier := p.verifier
+	p.mu.Unlock()
+	return verifier.Verify(ctx, rawIDToken)
+}
+
+// userInfoURL returns the discovered userinfo endpoint, if any.
+func (p *Provider) userInfoURL() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovered == nil {
+		return ""
+	}
+	return p.discovered.UserInfoURL
+}
+
+// oauth2Config returns a copy of the config with the discovered Endpoint and
+// the "openid" scope.
+func (p *Provider) oauth2Config(ctx context.Context) (*oauth2.Config, error) {
+	d, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	config := *p.config
+	config.Endpoint = oauth2.Endpoint{
+		AuthURL:   d.AuthURL,
+		TokenURL:  d.TokenURL,
+		AuthStyle: p.config.Endpoint.AuthStyle,
+	}
+	if !hasScope(config.Scopes, "openid") {
+		config.Scopes = append([]string{"openid"}, config.Scopes...)
+	}
+	return &config, nil
+}
+
+// fetchDiscovery gets and validates the issuer's discovery document.
+func fetchDiscovery(ctx context.Context, issuerURL string) (*discovery, error) {
+	req, err := http.NewRequest("GET", issuerURL+discoveryPath, nil)
+	if err != nil {
+		return nil, &gologin.Error{Provider: "oidc", Op: "discovery", Err: err, Kind: ErrDiscoveryFailed}
+	}
+	resp, err := internal.ContextClient(ctx).Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, &gologin.Error{Provider: "oidc", Op: "discovery", Err: err, Kind: ErrDiscoveryFailed}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, &gologin.Error{Provider: "oidc", Op: "discovery", StatusCode: resp.StatusCode, Kind: ErrDiscoveryFailed}
+	}
+	d := new(discovery)
+	if err := json.NewDecoder(resp.Body).Decode(d); err != nil {
+		return nil, &gologin.Error{Provider: "oidc", Op: "discovery", StatusCode: resp.StatusCode, Err: err, Kind: ErrDiscoveryFailed}
+	}
+	if d.AuthURL == "" || d.TokenURL == "" || d.JWKSURL == "" {
+		return nil, &gologin.Error{Provider: "oidc", Op: "discovery", StatusCode: resp.StatusCode, Kind: ErrDiscoveryFailed}
+	}
+	// the issuer must exactly match the issuer URL (Discovery 4.3)
+	if strings.TrimSuffix(d.Issuer, "/") != issuerURL {
+		return nil, ErrIssuerMismatch
+	}
+	return d, nil
+}
+
+// hasScope returns true if the scopes contain the scope.
+func hasScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
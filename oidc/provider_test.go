@@ -0,0 +1,78 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func TestProvider_Endpoint(t *testing.T) {
+	issuer := newTestIssuer(newRSAKey("key1"))
+	defer issuer.Close()
+
+	// Provider assert that:
+	// - the Endpoint is filled in from the discovery document
+	provider := New(issuer.URL+"/", &oauth2.Config{ClientID: testClientID})
+	endpoint, err := provider.Endpoint(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, issuer.URL+"/authorize", endpoint.AuthURL)
+	assert.Equal(t, issuer.URL+"/token", endpoint.TokenURL)
+}
+
+func TestProvider_DiscoveryNotFound(t *testing.T) {
+	_, mux, server := testutils.TestServer()
+	defer server.Close()
+	mux.HandleFunc("/", http.NotFound)
+
+	// Provider with an issuer without discovery, assert that:
+	// - Discover returns ErrDiscoveryFailed with the status code
+	provider := New(server.URL, &oauth2.Config{ClientID: testClientID})
+	err := provider.Discover(context.Background())
+	assert.True(t, errors.Is(err, ErrDiscoveryFailed))
+	var providerErr *gologin.Error
+	if assert.True(t, errors.As(err, &providerErr)) {
+		assert.Equal(t, http.StatusNotFound, providerErr.StatusCode)
+	}
+}
+
+func TestProvider_IssuerMismatch(t *testing.T) {
+	_, mux, server := testutils.TestServer()
+	defer server.Close()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"issuer":"https://evil.example.com","authorization_endpoint":"%[1]s/authorize","token_endpoint":"%[1]s/token","jwks_uri":"%[1]s/keys"}`, server.URL)
+	})
+
+	// Provider whose discovery document has a different issuer, assert that:
+	// - Discover returns ErrIssuerMismatch
+	provider := New(server.URL, &oauth2.Config{ClientID: testClientID})
+	assert.Equal(t, ErrIssuerMismatch, provider.Discover(context.Background()))
+}
+
+func TestProvider_DiscoveryRetry(t *testing.T) {
+	unavailable := true
+	_, mux, server := testutils.TestServer()
+	defer server.Close()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, req *http.Request) {
+		if unavailable {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"issuer":"%[1]s","authorization_endpoint":"%[1]s/authorize","token_endpoint":"%[1]s/token","jwks_uri":"%[1]s/keys"}`, server.URL)
+	})
+
+	// Provider with a failed discovery, assert that:
+	// - discovery failures are not cached and are retried
+	provider := New(server.URL, &oauth2.Config{ClientID: testClientID})
+	assert.True(t, errors.Is(provider.Discover(context.Background()), ErrDiscoveryFailed))
+	unavailable = false
+	assert.Nil(t, provider.Discover(context.Background()))
+}
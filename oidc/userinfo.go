@@ -0,0 +1,71 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/internal"
+	"golang.org/x/oauth2"
+)
+
+// Errors which may occur getting the UserInfo.
+var (
+	ErrUnableToGetUserInfo     = errors.New("oidc: unable to get UserInfo")
+	ErrUserInfoSubjectMismatch = errors.New("oidc: UserInfo subject does not match the id_token")
+)
+
+// profileScopes are the scopes which request UserInfo claims.
+var profileScopes = []string{"profile", "email", "address", "phone"}
+
+// UserInfo is an OpenID Connect UserInfo response.
+// https://openid.net/specs/openid-connect-core-1_0.html#UserInfoResponse
+type UserInfo struct {
+	Subject string `json:"sub"`
+	Name    string `json:"name"`
+	Email   string `json:"email"`
+	Picture string `json:"picture"`
+	// Claims holds all claims, including non-standard claims.
+	Claims map[string]interface{} `json:"-"`
+}
+
+// wantsUserInfo returns true if the scopes request UserInfo claims.
+func wantsUserInfo(scopes []string) bool {
+	for _, scope := range profileScopes {
+		if hasScope(scopes, scope) {
+			return true
+		}
+	}
+	return false
+}
+
+// fetchUserInfo gets the UserInfo with the Token and checks its subject
+// matches the id_token subject (Core 5.3.2).
+func fetchUserInfo(ctx context.Context, config *oauth2.Config, token *oauth2.Token, userInfoURL, subject string) (*UserInfo, error) {
+	httpClient := internal.OAuth2Client(ctx, config, token)
+	resp, err := httpClient.Get(userInfoURL)
+	if err != nil {
+		return nil, &gologin.Error{Provider: "oidc", Op: "get userinfo", Err: err, Kind: ErrUnableToGetUserInfo}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, &gologin.Error{Provider: "oidc", Op: "get userinfo", StatusCode: resp.StatusCode, Kind: ErrUnableToGetUserInfo}
+	}
+	var raw json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		return nil, &gologin.Error{Provider: "oidc", Op: "get userinfo", StatusCode: resp.StatusCode, Err: err, Kind: ErrUnableToGetUserInfo}
+	}
+	userInfo := new(UserInfo)
+	if err := json.Unmarshal(raw, userInfo); err != nil {
+		return nil, &gologin.Error{Provider: "oidc", Op: "get userinfo", StatusCode: resp.StatusCode, Err: err, Kind: ErrUnableToGetUserInfo}
+	}
+	if err := json.Unmarshal(raw, &userInfo.Claims); err != nil {
+		return nil, &gologin.Error{Provider: "oidc", Op: "get userinfo", StatusCode: resp.StatusCode, Err: err, Kind: ErrUnableToGetUserInfo}
+	}
+	if userInfo.Subject != subject {
+		return nil, ErrUserInfoSubjectMismatch
+	}
+	return userInfo, nil
+}
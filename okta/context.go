@@ -0,0 +1,62 @@
+package okta
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dghubble/gologin"
+)
+
+// unexported key type prevents collisions
+type key int
+
+const (
+	userKey key = iota
+	groupsKey
+)
+
+// WithUser returns a copy of ctx that stores the Okta User and its gologin
+// Profile.
+func WithUser(ctx context.Context, user *User) context.Context {
+	ctx = gologin.WithProfile(ctx, newProfile(user))
+	return context.WithValue(ctx, userKey, user)
+}
+
+// UserFromContext returns the Okta User from the ctx.
+func UserFromContext(ctx context.Context) (*User, error) {
+	user, ok := ctx.Value(userKey).(*User)
+	if !ok {
+		return nil, fmt.Errorf("okta: Context missing Okta User")
+	}
+	return user, nil
+}
+
+// newProfile returns the gologin Profile of the Okta User.
+func newProfile(user *User) *gologin.Profile {
+	if user == nil {
+		return nil
+	}
+	return &gologin.Profile{
+		Provider:      ProviderName,
+		ID:            user.ID,
+		Email:         user.Email,
+		EmailVerified: user.EmailVerified,
+		Name:          gologin.DisplayName(user.Name, user.Username),
+		Raw:           gologin.ProfileRaw(user, "sub", "email", "email_verified", "name"),
+	}
+}
+
+// WithGroups returns a copy of ctx that stores the Okta User's groups.
+func WithGroups(ctx context.Context, groups []string) context.Context {
+	return context.WithValue(ctx, groupsKey, groups)
+}
+
+// GroupsFromContext returns the Okta User's groups from the ctx. The groups
+// are empty unless the authorization server has a groups claim.
+func GroupsFromContext(ctx context.Context) ([]string, error) {
+	groups, ok := ctx.Value(groupsKey).([]string)
+	if !ok {
+		return nil, fmt.Errorf("okta: Context missing Okta groups")
+	}
+	return groups, nil
+}
@@ -0,0 +1,40 @@
+package okta
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContextUser(t *testing.T) {
+	expectedUser := &User{ID: "00uid4BxXw6I6TV4m0g3", Username: "john.doe@example.com"}
+	ctx := WithUser(context.Background(), expectedUser)
+	user, err := UserFromContext(ctx)
+	assert.Equal(t, expectedUser, user)
+	assert.Nil(t, err)
+}
+
+func TestContextUser_Error(t *testing.T) {
+	user, err := UserFromContext(context.Background())
+	assert.Nil(t, user)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "okta: Context missing Okta User", err.Error())
+	}
+}
+
+func TestContextGroups(t *testing.T) {
+	expectedGroups := []string{"Everyone", "Admins"}
+	ctx := WithGroups(context.Background(), expectedGroups)
+	groups, err := GroupsFromContext(ctx)
+	assert.Equal(t, expectedGroups, groups)
+	assert.Nil(t, err)
+}
+
+func TestContextGroups_Error(t *testing.T) {
+	groups, err := GroupsFromContext(context.Background())
+	assert.Nil(t, groups)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "okta: Context missing Okta groups", err.Error())
+	}
+}
@@ -0,0 +1,2 @@
+// Package okta provides Okta OAuth2 login and callback handlers.
+package okta
@@ -0,0 +1,212 @@
+package okta
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/internal"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/dghubble/gologin/oidc"
+	"golang.org/x/oauth2"
+)
+
+// ProviderName is the provider name set in the ctx (see
+// gologin.ProviderFromContext) and the Profile.
+const ProviderName = "okta"
+
+// defaultAuthorizationServerID is the ID of Okta's default custom
+// authorization server.
+const defaultAuthorizationServerID = "default"
+
+// Okta login errors
+var (
+	ErrUnableToGetOktaUser = errors.New("okta: unable to get Okta User")
+	ErrIssuerMismatch      = errors.New("okta: id_token issuer does not match the Okta authorization server")
+)
+
+// IssuerError is the error of an id_token from a different issuer than the
+// Config authorization server, which usually means the Config
+// AuthorizationServerID is wrong.
+type IssuerError struct {
+	// Expected is the issuer of the Config authorization server
+	Expected string
+	// Issuer is the (unverified) issuer of the id_token
+	Issuer string
+}
+
+func (e *IssuerError) Error() string {
+	return fmt.Sprintf("%s: got %q, expected %q (check the Config AuthorizationServerID)", ErrIssuerMismatch, e.Issuer, e.Expected)
+}
+
+// Is returns true for ErrIssuerMismatch.
+func (e *IssuerError) Is(target error) bool {
+	return target == ErrIssuerMismatch
+}
+
+// Config configures Okta login.
+type Config struct {
+	// Domain is the Okta org domain (e.g. "dev-123456.okta.com") or URL.
+	Domain string
+	// AuthorizationServerID is the ID of the custom authorization server.
+	// Defaults to "default".
+	AuthorizationServerID string
+}
+
+// Issuer returns the issuer URL of the Config authorization server. Panics
+// if the Domain is invalid.
+func (c Config) Issuer() string {
+	domain := c.Domain
+	if !strings.Contains(domain, "://") {
+		domain = "https://" + domain
+	}
+	u, err := url.Parse(domain)
+	if err != nil || c.Domain == "" || u.Host == "" {
+		panic("okta: invalid Config Domain " + c.Domain)
+	}
+	serverID := c.AuthorizationServerID
+	if serverID == "" {
+		serverID = defaultAuthorizationServerID
+	}
+	return u.Scheme + "://" + u.Host + "/oauth2/" + url.PathEscape(serverID)
+}
+
+// Endpoint returns the OAuth2 endpoint of the Config authorization server.
+// Panics if the Domain is invalid.
+func (c Config) Endpoint() oauth2.Endpoint {
+	issuer := c.Issuer()
+	return oauth2.Endpoint{
+		AuthURL:  issuer + "/v1/authorize",
+		TokenURL: issuer + "/v1/token",
+	}
+}
+
+// StateHandler checks for a state cookie. If found, the state value is read
+// and added to the ctx. Otherwise, a non-guessable value is added to the ctx
+// and to a (short-lived) state cookie issued to the requester.
+//
+// Implements OAuth 2 RFC 6749 10.12 CSRF Protection. If you wish to issue
+// state params differently, write a http.Handler which sets the ctx state,
+// using oauth2 WithState(ctx, state) since it is required by LoginHandler
+// and CallbackHandler.
+func StateHandler(config gologin.CookieConfig, success http.Handler) http.Handler {
+	return oauth2Login.StateHandler(config, success)
+}
+
+// LoginHandler handles Okta login requests by reading the state value from
+// the ctx and redirecting requests to the AuthURL with that state value.
+// Any AuthCodeOptions are added to the AuthURL.
+//
+// The config Endpoint should be the okta Config Endpoint and Scopes should
+// include "openid" (and "profile", "email", or "groups" for those claims).
+func LoginHandler(config *oauth2.Config, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	return oauth2Login.LoginHandler(config, failure, opts...)
+}
+
+// LoginHandlerWithPKCE handles Okta login requests like LoginHandler, but
+// also sends a PKCE code challenge, which Okta requires for SPA and native
+// apps. The PKCE code verifier is kept in a cookie per the pkceConfig, whose
+// Name must differ from the state cookie.
+func LoginHandlerWithPKCE(config *oauth2.Config, pkceConfig gologin.CookieConfig, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	return oauth2Login.LoginHandlerWithPKCE(config, pkceConfig, failure, opts...)
+}
+
+// CallbackHandler handles Okta redirection URI requests and adds the Okta
+// access token, id_token Claims (see oidc IDTokenFromContext), User, and
+// groups to the ctx. If authentication succeeds, handling delegates to the
+// success handler, otherwise to the failure handler.
+// Any AuthCodeOptions are sent with the token exchange.
+//
+// The id_token must be issued by the Config authorization server, otherwise
+// the failure handler is called with an *IssuerError. Panics if the Config
+// Domain is invalid.
+func CallbackHandler(config *oauth2.Config, oktaConfig Config, success, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	success = oktaHandler(config, oktaConfig, success, failure)
+	return oauth2Login.CallbackHandler(config, success, failure, opts...)
+}
+
+// CallbackHandlerWithPKCE handles Okta redirection URI requests like
+// CallbackHandler, but sends the PKCE code verifier cookie set by
+// LoginHandlerWithPKCE with the token exchange.
+func CallbackHandlerWithPKCE(config *oauth2.Config, oktaConfig Config, pkceConfig gologin.CookieConfig, success, failure http.Handler) http.Handler {
+	success = oktaHandler(config, oktaConfig, success, failure)
+	return oauth2Login.CallbackHandlerWithPKCE(config, pkceConfig, success, failure)
+}
+
+// oktaHandler is a http.Handler that gets the OAuth2 Token from the ctx,
+// verifies its id_token, and gets the corresponding Okta User. If
+// successful, the Claims, User, and groups are added to the ctx and the
+// success handler is called. Otherwise, the failure handler is called.
+func oktaHandler(config *oauth2.Config, oktaConfig Config, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	issuer := oktaConfig.Issuer()
+	verifier := oidc.NewIDTokenVerifier(issuer+"/v1/keys", config.ClientID, issuer)
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		rawIDToken, ok := token.Extra("id_token").(string)
+		if !ok || rawIDToken == "" {
+			ctx = gologin.WithError(ctx, oidc.ErrMissingIDToken)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		claims, err := verifier.Verify(ctx, rawIDToken)
+		if err == oidc.ErrInvalidIssuer {
+			err = &IssuerError{Expected: issuer, Issuer: unverifiedIssuer(rawIDToken)}
+		}
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		nonce, _ := oauth2Login.NonceFromContext(ctx)
+		if nonce != "" && claims.Nonce != nonce {
+			ctx = gologin.WithError(ctx, oidc.ErrInvalidNonce)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		httpClient := internal.OAuth2Client(ctx, config, token)
+		user, resp, err := newClient(httpClient, issuer).UserInfo(ctx)
+		err = validateResponse(user, resp, err)
+		if err == nil && user.ID != claims.Subject {
+			err = &gologin.Error{Provider: "okta", Op: "get user", StatusCode: resp.StatusCode, Err: errors.New("userinfo sub does not match id_token"), Kind: ErrUnableToGetOktaUser}
+		}
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		ctx = oidc.WithIDToken(ctx, claims)
+		ctx = WithUser(ctx, user)
+		ctx = WithGroups(ctx, user.Groups)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// validateResponse returns an error if the given Okta User, raw
+// http.Response, or error are unexpected. Returns nil if they are valid, or a
+// *gologin.Error which preserves the cause and status code.
+func validateResponse(user *User, resp *http.Response, err error) error {
+	var status int
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	if err != nil || status != http.StatusOK {
+		return &gologin.Error{Provider: "okta", Op: "get user", StatusCode: status, Err: err, Kind: ErrUnableToGetOktaUser}
+	}
+	if user == nil || user.ID == "" {
+		return &gologin.Error{Provider: "okta", Op: "get user", StatusCode: status, Kind: ErrUnableToGetOktaUser}
+	}
+	return nil
+}
@@ -0,0 +1,298 @@
+package okta
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/dghubble/gologin"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/dghubble/gologin/oidc"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+var testOktaConfig = Config{Domain: "dev-123456.okta.com"}
+
+var testPKCEConfig = gologin.CookieConfig{
+	Name:   "okta-pkce",
+	Path:   "/",
+	MaxAge: 60,
+}
+
+func testConfig() *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     "client_id",
+		ClientSecret: "client_secret",
+		RedirectURL:  "https://example.com/okta/callback",
+		Endpoint:     testOktaConfig.Endpoint(),
+		Scopes:       []string{"openid", "profile", "email", "groups"},
+	}
+}
+
+func TestConfigEndpoint(t *testing.T) {
+	cases := []struct {
+		config   Config
+		issuer   string
+		authURL  string
+		tokenURL string
+	}{
+		{Config{Domain: "dev-123456.okta.com"}, "https://dev-123456.okta.com/oauth2/default", "https://dev-123456.okta.com/oauth2/default/v1/authorize", "https://dev-123456.okta.com/oauth2/default/v1/token"},
+		{Config{Domain: "https://login.example.com/", AuthorizationServerID: "aus8w23r13NvyUwln1d7"}, "https://login.example.com/oauth2/aus8w23r13NvyUwln1d7", "https://login.example.com/oauth2/aus8w23r13NvyUwln1d7/v1/authorize", "https://login.example.com/oauth2/aus8w23r13NvyUwln1d7/v1/token"},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.issuer, c.config.Issuer())
+		endpoint := c.config.Endpoint()
+		assert.Equal(t, c.authURL, endpoint.AuthURL)
+		assert.Equal(t, c.tokenURL, endpoint.TokenURL)
+	}
+	assert.Panics(t, func() { Config{}.Endpoint() })
+	assert.Panics(t, func() { CallbackHandler(testConfig(), Config{}, nil, nil) })
+}
+
+func TestCallbackHandler(t *testing.T) {
+	proxyClient, server := newOktaTestServer(testIDToken(testClaims(testIssuer)), "", testUserInfoJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithState(ctx, "d4e5f6")
+
+	expectedUser := &User{
+		ID:            "00uid4BxXw6I6TV4m0g3",
+		Email:         "john.doe@example.com",
+		EmailVerified: true,
+		Name:          "John Doe",
+		Username:      "john.doe@example.com",
+		Groups:        []string{"Everyone", "Admins"},
+	}
+	success := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "any-token", token.AccessToken)
+		}
+		claims, err := oidc.IDTokenFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, testIssuer, claims.Issuer)
+		}
+		user, err := UserFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, expectedUser, user)
+		}
+		groups, err := GroupsFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, []string{"Everyone", "Admins"}, groups)
+		}
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// CallbackHandler assert that:
+	// - the id_token is verified with the authorization server keys
+	// - success handler is called
+	// - Okta Token, id_token Claims, User, and groups are added to the ctx of
+	// the success handler
+	callbackHandler := CallbackHandler(testConfig(), testOktaConfig, http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestLoginHandlerWithPKCE(t *testing.T) {
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// LoginHandlerWithPKCE assert that:
+	// - redirects to the authorization server AuthURL with the state
+	// - the S256 PKCE code challenge is sent and its verifier kept in a cookie
+	loginHandler := LoginHandlerWithPKCE(testConfig(), testPKCEConfig, failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	ctx := oauth2Login.WithState(context.Background(), "d4e5f6")
+	loginHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, http.StatusFound, w.Code)
+	location, err := url.Parse(w.HeaderMap.Get("Location"))
+	if assert.Nil(t, err) {
+		assert.Equal(t, "dev-123456.okta.com", location.Host)
+		assert.Equal(t, "/oauth2/default/v1/authorize", location.Path)
+		assert.Equal(t, "d4e5f6", location.Query().Get("state"))
+		assert.Equal(t, "S256", location.Query().Get("code_challenge_method"))
+		assert.NotEmpty(t, location.Query().Get("code_challenge"))
+	}
+	cookies := (&http.Response{Header: w.Header()}).Cookies()
+	if assert.Len(t, cookies, 1) {
+		assert.Equal(t, "okta-pkce", cookies[0].Name)
+		assert.NotEmpty(t, cookies[0].Value)
+	}
+}
+
+func TestCallbackHandlerWithPKCE(t *testing.T) {
+	proxyClient, server := newOktaTestServer(testIDToken(testClaims(testIssuer)), "some_verifier", testUserInfoJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithState(ctx, "d4e5f6")
+
+	success := func(w http.ResponseWriter, req *http.Request) {
+		user, err := UserFromContext(req.Context())
+		if assert.Nil(t, err) {
+			assert.Equal(t, "00uid4BxXw6I6TV4m0g3", user.ID)
+		}
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// CallbackHandlerWithPKCE assert that:
+	// - the PKCE code verifier is sent with the token exchange
+	// - success handler is called with the User in the ctx
+	callbackHandler := CallbackHandlerWithPKCE(testConfig(), testOktaConfig, testPKCEConfig, http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	req.AddCookie(&http.Cookie{Name: "okta-pkce", Value: "some_verifier"})
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestOktaHandler_IssuerMismatch(t *testing.T) {
+	orgIssuer := "https://dev-123456.okta.com"
+	proxyClient, server := newOktaTestServer(testIDToken(testClaims(orgIssuer)), "", testUserInfoJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithState(ctx, "d4e5f6")
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		assert.True(t, errors.Is(err, ErrIssuerMismatch))
+		assert.Equal(t, &IssuerError{Expected: testIssuer, Issuer: orgIssuer}, err)
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// CallbackHandler with an id_token from another authorization server,
+	// assert that:
+	// - failure handler is called with an IssuerError of both issuers
+	callbackHandler := CallbackHandler(testConfig(), testOktaConfig, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestOktaHandler_IDTokenErrors(t *testing.T) {
+	proxyClient, server := newOktaTestServer("", "", testUserInfoJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+
+	cases := []struct {
+		name  string
+		token *oauth2.Token
+		err   error
+	}{
+		{"missing id_token", &oauth2.Token{AccessToken: "any-token"}, oidc.ErrMissingIDToken},
+		{"invalid id_token", (&oauth2.Token{AccessToken: "any-token"}).WithExtra(map[string]interface{}{"id_token": "not.a.jwt"}), oidc.ErrInvalidIDToken},
+	}
+	for _, c := range cases {
+		success := testutils.AssertSuccessNotCalled(t)
+		failure := func(w http.ResponseWriter, req *http.Request) {
+			assert.Equal(t, c.err, gologin.ErrorFromContext(req.Context()), c.name)
+			fmt.Fprintf(w, "failure handler called")
+		}
+
+		// OktaHandler with a Token without a valid id_token, assert that:
+		// - failure handler is called with the id_token error
+		oktaHandler := oktaHandler(testConfig(), testOktaConfig, success, http.HandlerFunc(failure))
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		oktaHandler.ServeHTTP(w, req.WithContext(oauth2Login.WithToken(ctx, c.token)))
+		assert.Equal(t, "failure handler called", w.Body.String(), c.name)
+	}
+}
+
+func TestOktaHandler_SubjectMismatch(t *testing.T) {
+	proxyClient, server := newOktaTestServer("", "", `{"sub": "00uOtherUser", "email": "other@example.com"}`)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	token := (&oauth2.Token{AccessToken: "any-token"}).WithExtra(map[string]interface{}{"id_token": testIDToken(testClaims(testIssuer))})
+	ctx = oauth2Login.WithToken(ctx, token)
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		assert.True(t, errors.Is(err, ErrUnableToGetOktaUser))
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// OktaHandler gets a userinfo User other than the id_token subject, assert
+	// that:
+	// - failure handler is called with ErrUnableToGetOktaUser
+	oktaHandler := oktaHandler(testConfig(), testOktaConfig, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	oktaHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestOktaHandler_MissingCtxToken(t *testing.T) {
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "oauth2: Context missing Token", err.Error())
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// OktaHandler called without Token in ctx, assert that:
+	// - failure handler is called
+	// - error about ctx missing token is added to the failure handler ctx
+	oktaHandler := oktaHandler(testConfig(), testOktaConfig, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	oktaHandler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestOktaHandler_ErrorGettingUser(t *testing.T) {
+	proxyClient, server := newOktaTestServer("", "", testUserInfoJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	token := (&oauth2.Token{AccessToken: "invalid-token"}).WithExtra(map[string]interface{}{"id_token": testIDToken(testClaims(testIssuer))})
+	ctx = oauth2Login.WithToken(ctx, token)
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.True(t, errors.Is(err, ErrUnableToGetOktaUser))
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// OktaHandler cannot get Okta User, assert that:
+	// - failure handler is called
+	// - error cannot get Okta User added to the failure handler ctx
+	oktaHandler := oktaHandler(testConfig(), testOktaConfig, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	oktaHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestValidateResponse(t *testing.T) {
+	validUser := &User{ID: "00uid4BxXw6I6TV4m0g3", Email: "john.doe@example.com"}
+	validResponse := &http.Response{StatusCode: 200}
+	invalidResponse := &http.Response{StatusCode: 500}
+	assert.Equal(t, nil, validateResponse(validUser, validResponse, nil))
+	assert.True(t, errors.Is(validateResponse(validUser, validResponse, fmt.Errorf("Server error")), ErrUnableToGetOktaUser))
+	assert.True(t, errors.Is(validateResponse(validUser, invalidResponse, nil), ErrUnableToGetOktaUser))
+	assert.True(t, errors.Is(validateResponse(&User{Email: "john.doe@example.com"}, validResponse, nil), ErrUnableToGetOktaUser))
+}
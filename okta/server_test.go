@@ -0,0 +1,83 @@
+package okta
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/dghubble/gologin/testutils"
+)
+
+const (
+	// testIssuer is the issuer of the testOktaConfig authorization server.
+	testIssuer = "https://dev-123456.okta.com/oauth2/default"
+	// testUserInfoJSON is a userinfo response with a groups claim.
+	testUserInfoJSON = `{"sub": "00uid4BxXw6I6TV4m0g3", "name": "John Doe", "preferred_username": "john.doe@example.com", "email": "john.doe@example.com", "email_verified": true, "zoneinfo": "America/Los_Angeles", "groups": ["Everyone", "Admins"]}`
+)
+
+// testOktaKey signs test id_tokens and is served by newOktaTestServer.
+var testOktaKey, _ = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+// testIDToken returns an id_token with the given JSON claims signed by the
+// testOktaKey.
+func testIDToken(claims string) string {
+	signingInput := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"ES256","kid":"okta-key"}`)) + "." + base64.RawURLEncoding.EncodeToString([]byte(claims))
+	digest := sha256.Sum256([]byte(signingInput))
+	r, s, _ := ecdsa.Sign(rand.Reader, testOktaKey, digest[:])
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// testClaims returns valid id_token JSON claims from the issuer.
+func testClaims(issuer string) string {
+	return fmt.Sprintf(`{"iss":%q,"aud":"client_id","sub":"00uid4BxXw6I6TV4m0g3","exp":%d,"iat":%d,"email":"john.doe@example.com","name":"John Doe"}`,
+		issuer, time.Now().Add(time.Hour).Unix(), time.Now().Unix())
+}
+
+// testJWKS returns the JSON Web Key Set of the testOktaKey.
+func testJWKS() string {
+	x := base64.RawURLEncoding.EncodeToString(testOktaKey.X.FillBytes(make([]byte, 32)))
+	y := base64.RawURLEncoding.EncodeToString(testOktaKey.Y.FillBytes(make([]byte, 32)))
+	return fmt.Sprintf(`{"keys": [{"kty": "EC", "kid": "okta-key", "use": "sig", "crv": "P-256", "x": %q, "y": %q}]}`, x, y)
+}
+
+// newOktaTestServer returns a new httptest.Server which mocks the token,
+// keys, and userinfo endpoints of the "default" authorization server and a
+// client which proxies requests to the server. The token endpoint responds
+// with the idToken and, if the verifier is non-empty, requires it as the PKCE
+// code_verifier. The userinfo endpoint responds with the given json data. The
+// caller must close the server.
+func newOktaTestServer(idToken, verifier, jsonData string) (*http.Client, *httptest.Server) {
+	client, mux, server := testutils.TestServer()
+	mux.HandleFunc("/oauth2/default/v1/token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if verifier != "" && r.PostFormValue("code_verifier") != verifier {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, `{"error": "invalid_grant", "error_description": "PKCE verification failed."}`)
+			return
+		}
+		fmt.Fprintf(w, `{"access_token": "any-token", "token_type": "Bearer", "expires_in": 3600, "scope": "openid profile email groups", "id_token": %q}`, idToken)
+	})
+	mux.HandleFunc("/oauth2/default/v1/keys", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, testJWKS())
+	})
+	mux.HandleFunc("/oauth2/default/v1/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Header.Get("Authorization") != "Bearer any-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprintf(w, `{"error": "invalid_token", "error_description": "The access token is invalid."}`)
+			return
+		}
+		fmt.Fprintf(w, jsonData)
+	})
+	return client, server
+}
@@ -0,0 +1,61 @@
+package okta
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/dghubble/gologin/internal/jsonclient"
+)
+
+// User is an Okta user from the OpenID Connect userinfo endpoint.
+type User struct {
+	ID            string `json:"sub"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name"`
+	Username      string `json:"preferred_username"`
+	// Groups are the User's groups, if the authorization server has a
+	// groups claim
+	Groups []string `json:"groups"`
+}
+
+// client is an Okta client for obtaining a User.
+type client struct {
+	json *jsonclient.Client
+}
+
+// newClient returns a new Okta client for the authorization server issuer.
+func newClient(httpClient *http.Client, issuer string) *client {
+	return &client{
+		json: jsonclient.New(httpClient, issuer+"/"),
+	}
+}
+
+// UserInfo gets the current Okta User.
+// https://developer.okta.com/docs/api/openapi/okta-oauth/oauth/tag/OrgAS/#tag/OrgAS/operation/userinfo
+func (c *client) UserInfo(ctx context.Context) (*User, *http.Response, error) {
+	user := new(User)
+	resp, err := c.json.Get(ctx, "v1/userinfo", nil, user, nil)
+	return user, resp, err
+}
+
+// unverifiedIssuer returns the "iss" claim of the raw id_token without
+// verifying it, for describing issuer mismatches only.
+func unverifiedIssuer(rawIDToken string) string {
+	parts := strings.Split(rawIDToken, ".")
+	if len(parts) != 3 {
+		return ""
+	}
+	data, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return ""
+	}
+	var claims struct {
+		Issuer string `json:"iss"`
+	}
+	json.Unmarshal(data, &claims)
+	return claims.Issuer
+}
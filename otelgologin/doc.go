@@ -0,0 +1,13 @@
+/*
+Package otelgologin traces gologin callbacks with OpenTelemetry.
+
+Handler wraps a callback chain so token exchanges and provider user requests
+are traced as "gologin.exchange" and "gologin.userfetch" spans, which parent
+the spans of their outbound HTTP requests. Spans are children of the
+request's trace context (e.g. from otelhttp server middleware) or of one
+propagated in the request headers.
+
+	callback := facebook.StateHandler(stateConfig, facebook.CallbackHandler(oauth2Config, issueSession(), nil))
+	mux.Handle("/facebook/callback", otelgologin.Handler(otelgologin.Config{}, callback))
+*/
+package otelgologin
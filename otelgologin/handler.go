@@ -0,0 +1,112 @@
+package otelgologin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/internal"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/oauth2"
+)
+
+const tracerName = "github.com/dghubble/gologin/otelgologin"
+
+// Span attribute keys
+const (
+	ProviderKey   = attribute.Key("gologin.provider")
+	StatusCodeKey = attribute.Key("http.status_code")
+)
+
+// Config configures tracing.
+type Config struct {
+	// TracerProvider creates the spans. If nil, the global TracerProvider
+	// is used.
+	TracerProvider trace.TracerProvider
+	// Propagators extract the trace context from request headers and inject
+	// it into outbound requests. If nil, the global propagators are used.
+	Propagators propagation.TextMapPropagator
+}
+
+func (c Config) tracerProvider() trace.TracerProvider {
+	if c.TracerProvider != nil {
+		return c.TracerProvider
+	}
+	return otel.GetTracerProvider()
+}
+
+func (c Config) propagators() propagation.TextMapPropagator {
+	if c.Propagators != nil {
+		return c.Propagators
+	}
+	return otel.GetTextMapPropagator()
+}
+
+// Handler traces the token exchanges and provider user requests of the next
+// gologin handler chain. The trace context is read from the request headers
+// unless the ctx already has a span. Outbound requests use the ctx
+// oauth2.HTTPClient (or the http.DefaultClient) with an otelhttp Transport.
+// Any ctx gologin Hooks are kept.
+func Handler(config Config, next http.Handler) http.Handler {
+	hooks := Hooks(config)
+	tracerProvider, propagators := config.tracerProvider(), config.propagators()
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		if !trace.SpanContextFromContext(ctx).IsValid() {
+			ctx = propagators.Extract(ctx, propagation.HeaderCarrier(req.Header))
+		}
+		ctx = gologin.WithHooks(ctx, gologin.CombineHooks(gologin.HooksFromContext(ctx), hooks))
+		client := tracedClient(internal.ContextClient(ctx), otelhttp.WithTracerProvider(tracerProvider), otelhttp.WithPropagators(propagators))
+		ctx = gologin.WithHTTPClient(ctx, client)
+		next.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// Hooks returns gologin Hooks which trace token exchanges and provider user
+// requests as spans. Use Handler to also trace outbound requests.
+func Hooks(config Config) *gologin.Hooks {
+	tracer := config.tracerProvider().Tracer(tracerName)
+	return &gologin.Hooks{
+		StartOp: func(ctx context.Context, provider, op string) (context.Context, func(err error)) {
+			ctx, span := tracer.Start(ctx, "gologin."+op, trace.WithSpanKind(trace.SpanKindInternal), trace.WithAttributes(ProviderKey.String(provider)))
+			return ctx, func(err error) {
+				if err != nil {
+					if status := statusCode(err); status != 0 {
+						span.SetAttributes(StatusCodeKey.Int(status))
+					}
+					span.RecordError(err)
+					span.SetStatus(codes.Error, err.Error())
+				}
+				span.End()
+			}
+		},
+	}
+}
+
+// statusCode returns the provider HTTP status code of an error, if any.
+func statusCode(err error) int {
+	var gologinErr *gologin.Error
+	if errors.As(err, &gologinErr) {
+		return gologinErr.StatusCode
+	}
+	var retrieveErr *oauth2.RetrieveError
+	if errors.As(err, &retrieveErr) && retrieveErr.Response != nil {
+		return retrieveErr.Response.StatusCode
+	}
+	return 0
+}
+
+// tracedClient returns a copy of the client whose Transport is wrapped with
+// an otelhttp Transport.
+func tracedClient(client *http.Client, opts ...otelhttp.Option) *http.Client {
+	traced := *client
+	traced.Transport = otelhttp.NewTransport(client.Transport, opts...)
+	return &traced
+}
@@ -0,0 +1,167 @@
+package otelgologin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/facebook"
+	"github.com/dghubble/gologin/gologintest"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/oauth2"
+)
+
+const (
+	testTraceParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	testTraceID     = "4bf92f3577b34da6a3ce929d0e0e4736"
+	testParentID    = "00f067aa0ba902b7"
+)
+
+// runCallback authorizes with the provider, then serves the traced facebook
+// CallbackHandler, within any Hooks, with the traceparent header and returns
+// the response body.
+func runCallback(t *testing.T, config Config, provider *gologintest.FakeOAuth2Provider, hooks *gologin.Hooks) string {
+	oauth2Config := &oauth2.Config{
+		ClientID:     "client_id",
+		ClientSecret: "client_secret",
+		RedirectURL:  "https://example.com/facebook/callback",
+		Endpoint:     provider.Endpoint(),
+	}
+	noRedirects := &http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := noRedirects.Get(oauth2Config.AuthCodeURL("d4e5f6"))
+	if !assert.Nil(t, err) {
+		return ""
+	}
+	resp.Body.Close()
+	callbackURL, _ := url.Parse(resp.Header.Get("Location"))
+
+	success := func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("success handler called"))
+	}
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("failure handler called"))
+	}
+	handler := Handler(config, facebook.CallbackHandler(oauth2Config, http.HandlerFunc(success), http.HandlerFunc(failure)))
+	if hooks != nil {
+		handler = gologin.HooksHandler(hooks, handler)
+	}
+	ctx := gologin.WithHTTPClient(context.Background(), provider.Client())
+	ctx = oauth2Login.WithState(ctx, "d4e5f6")
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", callbackURL.RequestURI(), nil)
+	req.Header.Set("traceparent", testTraceParent)
+	handler.ServeHTTP(w, req.WithContext(ctx))
+	return w.Body.String()
+}
+
+// spansByName returns the ended spans keyed by name.
+func spansByName(exporter *tracetest.InMemoryExporter) map[string]tracetest.SpanStub {
+	spans := make(map[string]tracetest.SpanStub)
+	for _, span := range exporter.GetSpans() {
+		spans[span.Name] = span
+	}
+	return spans
+}
+
+// attributeValue returns the value of the span attribute, if any.
+func attributeValue(span tracetest.SpanStub, key attribute.Key) interface{} {
+	for _, kv := range span.Attributes {
+		if kv.Key == key {
+			return kv.Value.AsInterface()
+		}
+	}
+	return nil
+}
+
+func newTestConfig() (Config, *tracetest.InMemoryExporter) {
+	exporter := tracetest.NewInMemoryExporter()
+	return Config{
+		TracerProvider: sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)),
+		Propagators:    propagation.TraceContext{},
+	}, exporter
+}
+
+func TestHandler(t *testing.T) {
+	provider := gologintest.NewFakeOAuth2ProviderWithUserInfoPath("/v2.9/me")
+	defer provider.Close()
+	provider.UserInfoJSON = `{"id": "54638001", "name": "Ivy Crimson"}`
+	config, exporter := newTestConfig()
+
+	// Handler with a facebook callback, assert that:
+	// - exchange and userfetch spans are children of the propagated trace
+	// - outbound HTTP request spans are children of those spans
+	// - spans have the provider attribute
+	assert.Equal(t, "success handler called", runCallback(t, config, provider, nil))
+	var httpSpans []tracetest.SpanStub
+	for _, span := range exporter.GetSpans() {
+		if span.SpanKind == trace.SpanKindClient {
+			httpSpans = append(httpSpans, span)
+		}
+	}
+	spans := spansByName(exporter)
+	exchange, userFetch := spans["gologin.exchange"], spans["gologin.userfetch"]
+	for _, span := range []tracetest.SpanStub{exchange, userFetch} {
+		assert.Equal(t, testTraceID, span.SpanContext.TraceID().String())
+		assert.Equal(t, testParentID, span.Parent.SpanID().String())
+		assert.Equal(t, "facebook", attributeValue(span, ProviderKey))
+		assert.Equal(t, codes.Unset, span.Status.Code)
+	}
+	if assert.Len(t, httpSpans, 2) {
+		assert.Equal(t, exchange.SpanContext.SpanID(), httpSpans[0].Parent.SpanID())
+		assert.Equal(t, userFetch.SpanContext.SpanID(), httpSpans[1].Parent.SpanID())
+	}
+}
+
+func TestHandler_UserFetchError(t *testing.T) {
+	provider := gologintest.NewFakeOAuth2ProviderWithUserInfoPath("/v2.9/me")
+	defer provider.Close()
+	provider.UserInfoStatus = http.StatusInternalServerError
+	config, exporter := newTestConfig()
+
+	// Handler with a failed user request, assert that:
+	// - the userfetch span has an error status and the http.status_code
+	assert.Equal(t, "failure handler called", runCallback(t, config, provider, nil))
+	userFetch := spansByName(exporter)["gologin.userfetch"]
+	assert.Equal(t, codes.Error, userFetch.Status.Code)
+	assert.Equal(t, int64(http.StatusInternalServerError), attributeValue(userFetch, StatusCodeKey))
+	assert.Len(t, userFetch.Events, 1)
+}
+
+func TestHandler_KeepsHooks(t *testing.T) {
+	provider := gologintest.NewFakeOAuth2ProviderWithUserInfoPath("/v2.9/me")
+	defer provider.Close()
+	provider.UserInfoJSON = `{"id": "54638001", "name": "Ivy Crimson"}`
+	config, exporter := newTestConfig()
+	var succeeded string
+	hooks := &gologin.Hooks{
+		OnCallbackSuccess: func(ctx context.Context, provider, userID string) {
+			succeeded = userID
+		},
+	}
+
+	// Handler within a HooksHandler, assert that:
+	// - the existing Hooks are still called
+	// - spans are still recorded
+	assert.Equal(t, "success handler called", runCallback(t, config, provider, hooks))
+	assert.Equal(t, "54638001", succeeded)
+	assert.Contains(t, spansByName(exporter), "gologin.userfetch")
+}
+
+func TestStatusCode(t *testing.T) {
+	assert.Equal(t, 401, statusCode(&gologin.Error{StatusCode: 401}))
+	assert.Equal(t, 500, statusCode(&oauth2.RetrieveError{Response: &http.Response{StatusCode: 500}}))
+	assert.Equal(t, 0, statusCode(errors.New("other")))
+}
@@ -0,0 +1,65 @@
+package patreon
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dghubble/gologin"
+)
+
+// unexported key type prevents collisions
+type key int
+
+const (
+	userKey key = iota
+	membershipsKey
+)
+
+// WithUser returns a copy of ctx that stores the Patreon User and its gologin
+// Profile.
+func WithUser(ctx context.Context, user *User) context.Context {
+	ctx = gologin.WithProfile(ctx, newProfile(user))
+	return context.WithValue(ctx, userKey, user)
+}
+
+// UserFromContext returns the Patreon User from the ctx.
+func UserFromContext(ctx context.Context) (*User, error) {
+	user, ok := ctx.Value(userKey).(*User)
+	if !ok {
+		return nil, fmt.Errorf("patreon: Context missing Patreon User")
+	}
+	return user, nil
+}
+
+// newProfile returns the gologin Profile of the Patreon User.
+func newProfile(user *User) *gologin.Profile {
+	if user == nil {
+		return nil
+	}
+	return &gologin.Profile{
+		Provider:      ProviderName,
+		ID:            user.ID,
+		Email:         user.Email,
+		EmailVerified: user.IsEmailVerified,
+		Name:          user.FullName,
+		AvatarURL:     user.ImageURL,
+		Raw:           gologin.ProfileRaw(user, "ID", "Email", "IsEmailVerified", "FullName", "ImageURL"),
+	}
+}
+
+// WithMemberships returns a copy of ctx that stores the Patreon User's
+// Memberships.
+func WithMemberships(ctx context.Context, memberships []Membership) context.Context {
+	return context.WithValue(ctx, membershipsKey, memberships)
+}
+
+// MembershipsFromContext returns the Patreon User's Memberships from the ctx.
+// The Memberships are empty unless the identity.memberships scope was
+// granted.
+func MembershipsFromContext(ctx context.Context) ([]Membership, error) {
+	memberships, ok := ctx.Value(membershipsKey).([]Membership)
+	if !ok {
+		return nil, fmt.Errorf("patreon: Context missing Patreon Memberships")
+	}
+	return memberships, nil
+}
@@ -0,0 +1,40 @@
+package patreon
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContextUser(t *testing.T) {
+	expectedUser := &User{ID: "32187", FullName: "Corgi The Dev"}
+	ctx := WithUser(context.Background(), expectedUser)
+	user, err := UserFromContext(ctx)
+	assert.Equal(t, expectedUser, user)
+	assert.Nil(t, err)
+}
+
+func TestContextUser_Error(t *testing.T) {
+	user, err := UserFromContext(context.Background())
+	assert.Nil(t, user)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "patreon: Context missing Patreon User", err.Error())
+	}
+}
+
+func TestContextMemberships(t *testing.T) {
+	expectedMemberships := []Membership{{ID: "03ca69c3-ebea-4b9a-8fac-e4a837873254", CurrentlyEntitledAmountCents: 500}}
+	ctx := WithMemberships(context.Background(), expectedMemberships)
+	memberships, err := MembershipsFromContext(ctx)
+	assert.Equal(t, expectedMemberships, memberships)
+	assert.Nil(t, err)
+}
+
+func TestContextMemberships_Error(t *testing.T) {
+	memberships, err := MembershipsFromContext(context.Background())
+	assert.Nil(t, memberships)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "patreon: Context missing Patreon Memberships", err.Error())
+	}
+}
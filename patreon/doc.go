@@ -0,0 +1,2 @@
+// Package patreon provides Patreon OAuth2 login and callback handlers.
+package patreon
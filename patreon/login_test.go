@@ -0,0 +1,185 @@
+package patreon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dghubble/gologin"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func testConfig() *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     "client_id",
+		ClientSecret: "client_secret",
+		RedirectURL:  "https://example.com/patreon/callback",
+		Endpoint:     Endpoint,
+		Scopes:       []string{"identity", "identity[email]", "identity.memberships"},
+	}
+}
+
+func TestCallbackHandler(t *testing.T) {
+	proxyClient, server := newPatreonTestServer(testIdentityJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithState(ctx, "d4e5f6")
+
+	expectedUser := &User{
+		ID:              "32187",
+		Email:           "corgi@example.com",
+		FullName:        "Corgi The Dev",
+		ImageURL:        "https://c8.patreon.com/2/200/32187",
+		IsEmailVerified: true,
+	}
+	expectedMemberships := []Membership{
+		{ID: "03ca69c3-ebea-4b9a-8fac-e4a837873254", PatronStatus: PatronStatusActive, CurrentlyEntitledAmountCents: 500},
+		{ID: "7f1e2d3c-4b5a-6978-8a9b-0c1d2e3f4a5b", PatronStatus: PatronStatusFormer},
+	}
+	success := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "any-token", token.AccessToken)
+		}
+		user, err := UserFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, expectedUser, user)
+		}
+		memberships, err := MembershipsFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, expectedMemberships, memberships)
+		}
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// CallbackHandler assert that:
+	// - success handler is called
+	// - Patreon Token, flattened User, and Memberships (from the included
+	// members) are added to the ctx of the success handler
+	callbackHandler := CallbackHandler(testConfig(), http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestCallbackHandler_NoMembershipsUnverifiedEmail(t *testing.T) {
+	proxyClient, server := newPatreonTestServer(testIdentityNoMembershipsJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithState(ctx, "d4e5f6")
+
+	success := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		user, err := UserFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "corgi@example.com", user.Email)
+			assert.False(t, user.IsEmailVerified)
+		}
+		memberships, err := MembershipsFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Empty(t, memberships)
+		}
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// CallbackHandler without the identity.memberships scope and an
+	// unverified email, assert that:
+	// - success handler is called with the unverified User flagged
+	// - empty Memberships are added to the ctx
+	callbackHandler := CallbackHandler(testConfig(), http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestIdentityFlatten_MissingIncluded(t *testing.T) {
+	identity := new(identityResponse)
+	identity.Data.ID = "32187"
+	identity.Data.Relationships.Memberships.Data = []resourceIdentifier{{ID: "03ca69c3-ebea-4b9a-8fac-e4a837873254", Type: "member"}}
+	user, memberships, err := identity.flatten()
+	assert.Nil(t, err)
+	assert.Equal(t, &User{ID: "32187"}, user)
+	assert.Equal(t, []Membership{{ID: "03ca69c3-ebea-4b9a-8fac-e4a837873254"}}, memberships)
+}
+
+func TestMembershipIsActive(t *testing.T) {
+	assert.True(t, Membership{PatronStatus: PatronStatusActive}.IsActive())
+	assert.False(t, Membership{PatronStatus: PatronStatusDeclined}.IsActive())
+	assert.False(t, Membership{}.IsActive())
+}
+
+func TestPatreonHandler_MissingCtxToken(t *testing.T) {
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "oauth2: Context missing Token", err.Error())
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// PatreonHandler called without Token in ctx, assert that:
+	// - failure handler is called
+	// - error about ctx missing token is added to the failure handler ctx
+	patreonHandler := patreonHandler(testConfig(), success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	patreonHandler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestPatreonHandler_ErrorGettingUser(t *testing.T) {
+	proxyClient, server := newPatreonTestServer(testIdentityJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "invalid-token"})
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.True(t, errors.Is(err, ErrUnableToGetPatreonUser))
+			var apiErr *APIError
+			if assert.True(t, errors.As(err, &apiErr)) {
+				assert.Equal(t, "Unauthorized", apiErr.CodeName)
+				assert.Equal(t, "401", apiErr.Status)
+			}
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// PatreonHandler cannot get Patreon User, assert that:
+	// - failure handler is called
+	// - error cannot get Patreon User (and the APIError) added to the
+	// failure handler ctx
+	patreonHandler := patreonHandler(testConfig(), success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	patreonHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestValidateResponse(t *testing.T) {
+	validUser := &User{ID: "32187", FullName: "Corgi The Dev"}
+	validResponse := &http.Response{StatusCode: 200}
+	invalidResponse := &http.Response{StatusCode: 500}
+	assert.Equal(t, nil, validateResponse(validUser, validResponse, nil))
+	assert.True(t, errors.Is(validateResponse(validUser, validResponse, fmt.Errorf("Server error")), ErrUnableToGetPatreonUser))
+	assert.True(t, errors.Is(validateResponse(validUser, invalidResponse, nil), ErrUnableToGetPatreonUser))
+	assert.True(t, errors.Is(validateResponse(nil, validResponse, nil), ErrUnableToGetPatreonUser))
+	assert.True(t, errors.Is(validateResponse(&User{FullName: "Corgi The Dev"}, validResponse, nil), ErrUnableToGetPatreonUser))
+}
@@ -0,0 +1,51 @@
+package patreon
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/dghubble/gologin/testutils"
+)
+
+const (
+	// testIdentityJSON is an identity response with two memberships.
+	testIdentityJSON = `{"data": {"attributes": {"email": "corgi@example.com", "full_name": "Corgi The Dev", "image_url": "https://c8.patreon.com/2/200/32187", "is_email_verified": true}, "id": "32187", "relationships": {"memberships": {"data": [{"id": "03ca69c3-ebea-4b9a-8fac-e4a837873254", "type": "member"}, {"id": "7f1e2d3c-4b5a-6978-8a9b-0c1d2e3f4a5b", "type": "member"}]}}, "type": "user"}, "included": [{"attributes": {"currently_entitled_amount_cents": 500, "patron_status": "active_patron"}, "id": "03ca69c3-ebea-4b9a-8fac-e4a837873254", "type": "member"}, {"attributes": {"currently_entitled_amount_cents": 0, "patron_status": "former_patron"}, "id": "7f1e2d3c-4b5a-6978-8a9b-0c1d2e3f4a5b", "type": "member"}], "links": {"self": "https://www.patreon.com/api/oauth2/v2/user/32187"}}`
+	// testIdentityNoMembershipsJSON is an identity response without the
+	// identity.memberships scope and with an unverified email.
+	testIdentityNoMembershipsJSON = `{"data": {"attributes": {"email": "corgi@example.com", "full_name": "Corgi The Dev", "image_url": "https://c8.patreon.com/2/200/32187", "is_email_verified": false}, "id": "32187", "type": "user"}, "links": {"self": "https://www.patreon.com/api/oauth2/v2/user/32187"}}`
+	// testUnauthorizedJSON is a Patreon error response.
+	testUnauthorizedJSON = `{"errors": [{"code": 1, "code_name": "Unauthorized", "detail": "The server could not verify that you are authorized to access the URL requested.", "id": "b298d8b1-73db-46ab-b3f4-545e6f934599", "status": "401", "title": "Unauthorized"}]}`
+)
+
+// newPatreonTestServer returns a new httptest.Server which mocks the Patreon
+// token and identity endpoints and a client which proxies requests to the
+// server. Like Patreon, the identity endpoint responds with JSON:API
+// documents. It responds with the given json data for requests with the
+// User fields and memberships included, or an Unauthorized error for tokens
+// other than "any-token". The caller must close the server.
+func newPatreonTestServer(jsonData string) (*http.Client, *httptest.Server) {
+	client, mux, server := testutils.TestServer()
+	mux.HandleFunc("/api/oauth2/token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token": "any-token", "token_type": "Bearer", "refresh_token": "any-refresh", "expires_in": 2678400, "scope": "identity identity[email] identity.memberships"}`)
+	})
+	mux.HandleFunc("/api/oauth2/v2/identity", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/vnd.api+json")
+		if r.Header.Get("Authorization") != "Bearer any-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprintf(w, testUnauthorizedJSON)
+			return
+		}
+		query := r.URL.Query()
+		if query.Get("fields[user]") != "email,full_name,image_url,is_email_verified" ||
+			query.Get("fields[member]") != "currently_entitled_amount_cents,patron_status" ||
+			query.Get("include") != "memberships" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, `{"errors": [{"code": 3, "code_name": "ParameterInvalidOnType", "detail": "unexpected query", "status": "400", "title": "Invalid parameter"}]}`)
+			return
+		}
+		fmt.Fprintf(w, jsonData)
+	})
+	return client, server
+}
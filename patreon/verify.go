@@ -0,0 +1,205 @@
+package patreon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/dghubble/gologin/internal/jsonclient"
+)
+
+const (
+	patreonAPI = "https://www.patreon.com/api/oauth2/v2/"
+	// jsonAPIMediaType is the media type of JSON:API documents.
+	jsonAPIMediaType = "application/vnd.api+json"
+)
+
+// Patreon patron statuses
+const (
+	PatronStatusActive   = "active_patron"
+	PatronStatusDeclined = "declined_patron"
+	PatronStatusFormer   = "former_patron"
+)
+
+// User is a Patreon user.
+type User struct {
+	ID       string
+	Email    string
+	FullName string
+	ImageURL string
+	// IsEmailVerified is false if the User has not verified their Email
+	IsEmailVerified bool
+}
+
+// Membership is a Patreon User's membership of a campaign.
+type Membership struct {
+	ID string
+	// PatronStatus is the PatronStatus* of the membership, or empty if the
+	// User never pledged
+	PatronStatus string
+	// CurrentlyEntitledAmountCents is the amount (in the campaign currency)
+	// of the tiers the User is entitled to
+	CurrentlyEntitledAmountCents int
+}
+
+// IsActive returns true if the Membership is of an active patron.
+func (m Membership) IsActive() bool {
+	return m.PatronStatus == PatronStatusActive
+}
+
+// APIError is a Patreon API error.
+type APIError struct {
+	Code     int    `json:"code"`
+	CodeName string `json:"code_name"`
+	Detail   string `json:"detail"`
+	Status   string `json:"status"`
+	Title    string `json:"title"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("patreon: %s: %s (code %d)", e.Title, e.Detail, e.Code)
+}
+
+// errorResponse is a Patreon API error response.
+type errorResponse struct {
+	Errors []APIError `json:"errors"`
+}
+
+// resourceIdentifier identifies a JSON:API resource object.
+type resourceIdentifier struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+}
+
+// resource is a JSON:API resource object.
+type resource struct {
+	ID            string          `json:"id"`
+	Type          string          `json:"type"`
+	Attributes    json.RawMessage `json:"attributes"`
+	Relationships struct {
+		Memberships struct {
+			Data []resourceIdentifier `json:"data"`
+		} `json:"memberships"`
+	} `json:"relationships"`
+}
+
+// identityResponse is the JSON:API document of a Patreon identity request.
+type identityResponse struct {
+	Data     resource   `json:"data"`
+	Included []resource `json:"included"`
+}
+
+// userAttributes are the attributes of a Patreon user resource.
+type userAttributes struct {
+	Email           string `json:"email"`
+	FullName        string `json:"full_name"`
+	ImageURL        string `json:"image_url"`
+	IsEmailVerified bool   `json:"is_email_verified"`
+}
+
+// memberAttributes are the attributes of a Patreon member resource.
+type memberAttributes struct {
+	PatronStatus                 string `json:"patron_status"`
+	CurrentlyEntitledAmountCents int    `json:"currently_entitled_amount_cents"`
+}
+
+// flatten returns the User and Memberships of the identity document.
+func (r *identityResponse) flatten() (*User, []Membership, error) {
+	attrs := new(userAttributes)
+	if len(r.Data.Attributes) > 0 {
+		if err := json.Unmarshal(r.Data.Attributes, attrs); err != nil {
+			return nil, nil, err
+		}
+	}
+	user := &User{
+		ID:              r.Data.ID,
+		Email:           attrs.Email,
+		FullName:        attrs.FullName,
+		ImageURL:        attrs.ImageURL,
+		IsEmailVerified: attrs.IsEmailVerified,
+	}
+	included := make(map[string]resource)
+	for _, res := range r.Included {
+		included[res.Type+"/"+res.ID] = res
+	}
+	memberships := []Membership{}
+	for _, ref := range r.Data.Relationships.Memberships.Data {
+		membership := Membership{ID: ref.ID}
+		if res, ok := included[ref.Type+"/"+ref.ID]; ok && len(res.Attributes) > 0 {
+			attrs := new(memberAttributes)
+			if err := json.Unmarshal(res.Attributes, attrs); err != nil {
+				return nil, nil, err
+			}
+			membership.PatronStatus = attrs.PatronStatus
+			membership.CurrentlyEntitledAmountCents = attrs.CurrentlyEntitledAmountCents
+		}
+		memberships = append(memberships, membership)
+	}
+	return user, memberships, nil
+}
+
+// client is a Patreon client for obtaining the current User.
+type client struct {
+	json *jsonclient.Client
+}
+
+// newClient returns a new Patreon client. Patreon responds with JSON:API
+// media type documents, which are decoded as JSON regardless of the
+// transport.
+func newClient(httpClient *http.Client) *client {
+	jsonAPIClient := *httpClient
+	jsonAPIClient.Transport = &jsonAPITransport{base: httpClient.Transport}
+	return &client{
+		json: jsonclient.New(&jsonAPIClient, patreonAPI),
+	}
+}
+
+// jsonAPITransport is a http.RoundTripper which rewrites the Content-Type of
+// JSON:API (application/vnd.api+json) responses to application/json, so
+// they're decoded as JSON.
+type jsonAPITransport struct {
+	base http.RoundTripper
+}
+
+// RoundTrip calls the base RoundTripper (or http.DefaultTransport) and
+// rewrites the Content-Type of JSON:API responses.
+func (t *jsonAPITransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), jsonAPIMediaType) {
+		resp.Header.Set("Content-Type", "application/json")
+	}
+	return resp, nil
+}
+
+// Identity returns the current Patreon User and their Memberships (if the
+// identity.memberships scope was granted). If Patreon responds with an
+// error, it is returned as an *APIError.
+// https://docs.patreon.com/#get-api-oauth2-v2-identity
+func (c *client) Identity(ctx context.Context) (*User, []Membership, *http.Response, error) {
+	identity := new(identityResponse)
+	errResp := new(errorResponse)
+	params := url.Values{
+		"fields[user]":   {"email,full_name,image_url,is_email_verified"},
+		"fields[member]": {"currently_entitled_amount_cents,patron_status"},
+		"include":        {"memberships"},
+	}
+	resp, err := c.json.Get(ctx, "identity", params, identity, errResp)
+	if err == nil && len(errResp.Errors) > 0 {
+		err = &errResp.Errors[0]
+	}
+	if err != nil {
+		return nil, nil, resp, err
+	}
+	user, memberships, err := identity.flatten()
+	return user, memberships, resp, err
+}
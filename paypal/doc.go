@@ -0,0 +1,3 @@
+// Package paypal provides Log in with PayPal OAuth2 login and callback
+// handlers.
+package paypal
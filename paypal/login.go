@@ -0,0 +1,142 @@
+package paypal
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/internal"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"golang.org/x/oauth2"
+)
+
+// ProviderName is the provider name set in the ctx (see
+// gologin.ProviderFromContext) and the Profile.
+const ProviderName = "paypal"
+
+// PayPal login errors
+var (
+	ErrUnableToGetPayPalUser = errors.New("paypal: unable to get PayPal User")
+)
+
+// Endpoint is PayPal's OAuth2 endpoint for live accounts. PayPal requires
+// HTTP Basic client authentication at the token endpoint.
+var Endpoint = oauth2.Endpoint{
+	AuthURL:   "https://www.paypal.com/signin/authorize",
+	TokenURL:  "https://api-m.paypal.com/v1/oauth2/token",
+	AuthStyle: oauth2.AuthStyleInHeader,
+}
+
+// SandboxEndpoint is PayPal's OAuth2 endpoint for sandbox accounts.
+var SandboxEndpoint = oauth2.Endpoint{
+	AuthURL:   "https://www.sandbox.paypal.com/signin/authorize",
+	TokenURL:  "https://api-m.sandbox.paypal.com/v1/oauth2/token",
+	AuthStyle: oauth2.AuthStyleInHeader,
+}
+
+// Config configures PayPal login.
+type Config struct {
+	// Sandbox uses the PayPal sandbox. The oauth2.Config Endpoint is replaced
+	// by SandboxEndpoint and userinfo is requested from the sandbox API.
+	Sandbox bool
+}
+
+// oauth2Config returns the oauth2.Config, or a copy with the SandboxEndpoint
+// if the Config is for the sandbox.
+func (c Config) oauth2Config(config *oauth2.Config) *oauth2.Config {
+	if !c.Sandbox {
+		return config
+	}
+	sandboxConfig := *config
+	sandboxConfig.Endpoint = SandboxEndpoint
+	return &sandboxConfig
+}
+
+// StateHandler checks for a state cookie. If found, the state value is read
+// and added to the ctx. Otherwise, a non-guessable value is added to the ctx
+// and to a (short-lived) state cookie issued to the requester.
+//
+// Implements OAuth 2 RFC 6749 10.12 CSRF Protection. If you wish to issue
+// state params differently, write a http.Handler which sets the ctx state,
+// using oauth2 WithState(ctx, state) since it is required by LoginHandler
+// and CallbackHandler.
+func StateHandler(config gologin.CookieConfig, success http.Handler) http.Handler {
+	return oauth2Login.StateHandler(config, success)
+}
+
+// LoginHandler handles PayPal login requests by reading the state value from
+// the ctx and redirecting requests to the AuthURL with that state value.
+// Any AuthCodeOptions are added to the AuthURL.
+func LoginHandler(config *oauth2.Config, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	return LoginHandlerWithConfig(config, Config{}, failure, opts...)
+}
+
+// LoginHandlerWithConfig handles PayPal login requests like LoginHandler, but
+// redirects to the sandbox AuthURL if the Config is for the sandbox.
+func LoginHandlerWithConfig(config *oauth2.Config, paypalConfig Config, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	return oauth2Login.LoginHandler(paypalConfig.oauth2Config(config), failure, opts...)
+}
+
+// CallbackHandler handles PayPal redirection URI requests and adds the PayPal
+// access token and User to the ctx. If authentication succeeds, handling
+// delegates to the success handler, otherwise to the failure handler.
+// Any AuthCodeOptions are sent with the token exchange.
+func CallbackHandler(config *oauth2.Config, success, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	return CallbackHandlerWithConfig(config, Config{}, success, failure, opts...)
+}
+
+// CallbackHandlerWithConfig handles PayPal redirection URI requests like
+// CallbackHandler, but exchanges the code and gets the User from the sandbox
+// if the Config is for the sandbox.
+func CallbackHandlerWithConfig(config *oauth2.Config, paypalConfig Config, success, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	config = paypalConfig.oauth2Config(config)
+	success = paypalHandler(config, paypalConfig, success, failure)
+	return oauth2Login.CallbackHandler(config, success, failure, opts...)
+}
+
+// paypalHandler is a http.Handler that gets the OAuth2 Token from the ctx to
+// get the corresponding PayPal User. If successful, the User is added to the
+// ctx and the success handler is called. Otherwise, the failure handler is
+// called.
+func paypalHandler(config *oauth2.Config, paypalConfig Config, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		httpClient := internal.OAuth2Client(ctx, config, token)
+		user, resp, err := newClient(httpClient, paypalConfig.Sandbox).UserInfo(ctx)
+		err = validateResponse(user, resp, err)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		ctx = WithUser(ctx, user)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// validateResponse returns an error if the given PayPal User, raw
+// http.Response, or error are unexpected. Returns nil if they are valid, or a
+// *gologin.Error which preserves the cause and status code.
+func validateResponse(user *User, resp *http.Response, err error) error {
+	var status int
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	if err != nil || status != http.StatusOK {
+		return &gologin.Error{Provider: "paypal", Op: "get user", StatusCode: status, Err: err, Kind: ErrUnableToGetPayPalUser}
+	}
+	if user == nil || user.ID == "" {
+		return &gologin.Error{Provider: "paypal", Op: "get user", StatusCode: status, Kind: ErrUnableToGetPayPalUser}
+	}
+	return nil
+}
@@ -0,0 +1,242 @@
+package paypal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/dghubble/gologin"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func testConfig() *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     "client_id",
+		ClientSecret: "client_secret",
+		RedirectURL:  "https://example.com/paypal/callback",
+		Endpoint:     Endpoint,
+		Scopes:       []string{"openid", "email", "https://uri.paypal.com/services/paypalattributes"},
+	}
+}
+
+func TestLoginHandler(t *testing.T) {
+	cases := []struct {
+		paypalConfig Config
+		expectedHost string
+	}{
+		{Config{}, "www.paypal.com"},
+		{Config{Sandbox: true}, "www.sandbox.paypal.com"},
+	}
+	for _, c := range cases {
+		failure := testutils.AssertFailureNotCalled(t)
+
+		// LoginHandlerWithConfig assert that:
+		// - redirects to the live or sandbox AuthURL with the state
+		loginHandler := LoginHandlerWithConfig(testConfig(), c.paypalConfig, failure)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		ctx := oauth2Login.WithState(context.Background(), "d4e5f6")
+		loginHandler.ServeHTTP(w, req.WithContext(ctx))
+		assert.Equal(t, http.StatusFound, w.Code)
+		location, err := url.Parse(w.HeaderMap.Get("Location"))
+		if assert.Nil(t, err) {
+			assert.Equal(t, c.expectedHost, location.Host)
+			assert.Equal(t, "/signin/authorize", location.Path)
+			assert.Equal(t, "d4e5f6", location.Query().Get("state"))
+		}
+	}
+}
+
+func TestCallbackHandler(t *testing.T) {
+	cases := []struct {
+		paypalConfig Config
+		apiHost      string
+	}{
+		{Config{}, "api-m.paypal.com"},
+		{Config{Sandbox: true}, "api-m.sandbox.paypal.com"},
+	}
+	for _, c := range cases {
+		proxyClient, server := newPayPalTestServer(c.apiHost, testUserJSON)
+		// oauth2 Client will use the proxy client's base Transport
+		ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+		ctx = oauth2Login.WithState(ctx, "d4e5f6")
+
+		success := func(w http.ResponseWriter, req *http.Request) {
+			ctx := req.Context()
+			token, err := oauth2Login.TokenFromContext(ctx)
+			if assert.Nil(t, err) {
+				assert.Equal(t, "any-token", token.AccessToken)
+			}
+			user, err := UserFromContext(ctx)
+			if assert.Nil(t, err) {
+				expectedUser := &User{ID: "https://www.paypal.com/webapps/auth/identity/user/mWq6_1sU85v5EG9yHdPxJRrhGHrnMJ-1PQKtX6pcsmA", Email: "user@example.com", Verified: true, Name: "Identity Test", PayerID: "WDJJHEBZ4X2LY"}
+				assert.Equal(t, expectedUser, user)
+			}
+			fmt.Fprintf(w, "success handler called")
+		}
+		failure := testutils.AssertFailureNotCalled(t)
+
+		// CallbackHandlerWithConfig assert that:
+		// - the code is exchanged on the live or sandbox API host
+		// - the userinfo is requested from the same API host
+		// - success handler is called with the Token and User in the ctx
+		callbackHandler := CallbackHandlerWithConfig(testConfig(), c.paypalConfig, http.HandlerFunc(success), failure)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+		callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+		assert.Equal(t, "success handler called", w.Body.String(), c.apiHost)
+		server.Close()
+	}
+}
+
+func TestCallbackHandler_SandboxMismatch(t *testing.T) {
+	proxyClient, server := newPayPalTestServer("api-m.sandbox.paypal.com", testUserJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithState(ctx, "d4e5f6")
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		var retrieveErr *oauth2.RetrieveError
+		assert.True(t, errors.As(gologin.ErrorFromContext(req.Context()), &retrieveErr))
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// CallbackHandler (live) for a sandbox app, assert that:
+	// - the code is exchanged on the live API host, which rejects it
+	// - failure handler is called
+	callbackHandler := CallbackHandler(testConfig(), success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestPayPalHandler_Verified(t *testing.T) {
+	cases := []struct {
+		json     string
+		verified bool
+	}{
+		{`{"user_id": "any-id", "verified": "true"}`, true},
+		{`{"user_id": "any-id", "verified": "false"}`, false},
+		{`{"user_id": "any-id", "verified": true}`, true},
+		{`{"user_id": "any-id", "verified": false}`, false},
+		{`{"user_id": "any-id", "verified_account": "true"}`, true},
+		{`{"user_id": "any-id", "verified": ""}`, false},
+		{`{"user_id": "any-id"}`, false},
+	}
+	for _, c := range cases {
+		proxyClient, server := newPayPalTestServer("api-m.paypal.com", c.json)
+		// oauth2 Client will use the proxy client's base Transport
+		ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+		ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+
+		success := func(w http.ResponseWriter, req *http.Request) {
+			user, err := UserFromContext(req.Context())
+			if assert.Nil(t, err) {
+				assert.Equal(t, c.verified, user.Verified, c.json)
+			}
+			fmt.Fprintf(w, "success handler called")
+		}
+		failure := testutils.AssertFailureNotCalled(t)
+
+		// PayPalHandler assert that:
+		// - PayPal string (or bool) verified values are normalized to a bool
+		paypalHandler := paypalHandler(testConfig(), Config{}, http.HandlerFunc(success), failure)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		paypalHandler.ServeHTTP(w, req.WithContext(ctx))
+		assert.Equal(t, "success handler called", w.Body.String())
+		server.Close()
+	}
+}
+
+func TestPayPalHandler_InvalidVerified(t *testing.T) {
+	proxyClient, server := newPayPalTestServer("api-m.paypal.com", `{"user_id": "any-id", "verified": "maybe"}`)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.True(t, errors.Is(err, ErrUnableToGetPayPalUser))
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// PayPalHandler with a verified value which is not a bool, assert that:
+	// - failure handler is called
+	paypalHandler := paypalHandler(testConfig(), Config{}, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	paypalHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestPayPalHandler_MissingCtxToken(t *testing.T) {
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "oauth2: Context missing Token", err.Error())
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// PayPalHandler called without Token in ctx, assert that:
+	// - failure handler is called
+	// - error about ctx missing token is added to the failure handler ctx
+	paypalHandler := paypalHandler(testConfig(), Config{}, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	paypalHandler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestPayPalHandler_ErrorGettingUser(t *testing.T) {
+	proxyClient, server := testutils.NewErrorServer("PayPal Service Down", http.StatusInternalServerError)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.True(t, errors.Is(err, ErrUnableToGetPayPalUser))
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// PayPalHandler cannot get PayPal User, assert that:
+	// - failure handler is called
+	// - error cannot get PayPal User added to the failure handler ctx
+	paypalHandler := paypalHandler(testConfig(), Config{}, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	paypalHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestValidateResponse(t *testing.T) {
+	validUser := &User{ID: "any-id"}
+	validResponse := &http.Response{StatusCode: 200}
+	invalidResponse := &http.Response{StatusCode: 500}
+	assert.Equal(t, nil, validateResponse(validUser, validResponse, nil))
+	assert.True(t, errors.Is(validateResponse(validUser, validResponse, fmt.Errorf("Server error")), ErrUnableToGetPayPalUser))
+	assert.True(t, errors.Is(validateResponse(validUser, invalidResponse, nil), ErrUnableToGetPayPalUser))
+	assert.True(t, errors.Is(validateResponse(&User{}, validResponse, nil), ErrUnableToGetPayPalUser))
+	assert.True(t, errors.Is(validateResponse(nil, validResponse, nil), ErrUnableToGetPayPalUser))
+}
@@ -0,0 +1,41 @@
+package paypal
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/dghubble/gologin/testutils"
+)
+
+const testUserJSON = `{"user_id": "https://www.paypal.com/webapps/auth/identity/user/mWq6_1sU85v5EG9yHdPxJRrhGHrnMJ-1PQKtX6pcsmA", "name": "Identity Test", "email": "user@example.com", "verified": "true", "payer_id": "WDJJHEBZ4X2LY"}`
+
+// newPayPalTestServer returns a new httptest.Server which mocks the PayPal
+// token and userinfo endpoints of the API host (e.g. "api-m.paypal.com") and
+// a client which proxies requests to the server. Like PayPal, the token
+// endpoint requires HTTP Basic client authentication. The userinfo endpoint
+// requires schema=openid and responds with the given json data. Requests to
+// other hosts fail. The caller must close the server.
+func newPayPalTestServer(host, jsonData string) (*http.Client, *httptest.Server) {
+	client, mux, server := testutils.TestServer()
+	mux.HandleFunc("/v1/oauth2/token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		username, password, ok := r.BasicAuth()
+		if r.Host != host || !ok || username != "client_id" || password != "client_secret" || r.PostFormValue("grant_type") != "authorization_code" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprintf(w, `{"error": "invalid_client", "error_description": "Client Authentication failed"}`)
+			return
+		}
+		fmt.Fprintf(w, `{"token_type": "Bearer", "expires_in": "28800", "refresh_token": "any-refresh", "id_token": "any-id-token", "access_token": "any-token"}`)
+	})
+	mux.HandleFunc("/v1/identity/openidconnect/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Host != host || r.Header.Get("Authorization") != "Bearer any-token" || r.URL.Query().Get("schema") != "openid" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprintf(w, `{"error": "invalid_token", "error_description": "The token passed in was not found in the system"}`)
+			return
+		}
+		fmt.Fprintf(w, jsonData)
+	})
+	return client, server
+}
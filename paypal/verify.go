@@ -0,0 +1,101 @@
+package paypal
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/dghubble/gologin/internal/jsonclient"
+)
+
+// PayPal API URLs
+const (
+	liveAPI    = "https://api-m.paypal.com/"
+	sandboxAPI = "https://api-m.sandbox.paypal.com/"
+)
+
+// User is a PayPal user from the OpenID Connect userinfo endpoint.
+type User struct {
+	ID       string `json:"user_id"`
+	Email    string `json:"email"`
+	Verified bool   `json:"verified"`
+	Name     string `json:"name"`
+	PayerID  string `json:"payer_id"`
+}
+
+// userinfo is a PayPal userinfo response. PayPal encodes booleans as the
+// strings "true" and "false" and has sent verification as both verified and
+// verified_account.
+type userinfo struct {
+	UserID          string     `json:"user_id"`
+	Email           string     `json:"email"`
+	Verified        stringBool `json:"verified"`
+	VerifiedAccount stringBool `json:"verified_account"`
+	Name            string     `json:"name"`
+	PayerID         string     `json:"payer_id"`
+}
+
+// user returns the normalized User.
+func (u *userinfo) user() *User {
+	return &User{
+		ID:       u.UserID,
+		Email:    u.Email,
+		Verified: bool(u.Verified || u.VerifiedAccount),
+		Name:     u.Name,
+		PayerID:  u.PayerID,
+	}
+}
+
+// stringBool is a bool which may be encoded as a JSON bool or string.
+type stringBool bool
+
+func (b *stringBool) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		var v bool
+		if err := json.Unmarshal(data, &v); err != nil {
+			return err
+		}
+		*b = stringBool(v)
+		return nil
+	}
+	if s == "" {
+		*b = false
+		return nil
+	}
+	v, err := strconv.ParseBool(s)
+	if err != nil {
+		return err
+	}
+	*b = stringBool(v)
+	return nil
+}
+
+// client is a PayPal client for obtaining a User.
+type client struct {
+	json *jsonclient.Client
+}
+
+// newClient returns a new PayPal client for the live or sandbox API.
+func newClient(httpClient *http.Client, sandbox bool) *client {
+	api := liveAPI
+	if sandbox {
+		api = sandboxAPI
+	}
+	return &client{
+		json: jsonclient.New(httpClient, api),
+	}
+}
+
+// UserInfo gets the current PayPal User (requires the openid scope, plus the
+// email and https://uri.paypal.com/services/paypalattributes scopes for the
+// email, verification, and payer_id).
+// https://developer.paypal.com/docs/api/identity/v1/#userinfo_get
+func (c *client) UserInfo(ctx context.Context) (*User, *http.Response, error) {
+	info := new(userinfo)
+	params := url.Values{"schema": {"openid"}}
+	resp, err := c.json.Get(ctx, "v1/identity/openidconnect/userinfo", params, info, nil)
+	return info.user(), resp, err
+}
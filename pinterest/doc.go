@@ -0,0 +1,2 @@
+// Package pinterest provides Pinterest OAuth2 login and callback handlers.
+package pinterest
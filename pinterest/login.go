@@ -0,0 +1,115 @@
+package pinterest
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/internal"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"golang.org/x/oauth2"
+)
+
+// ProviderName is the provider name set in the ctx (see
+// gologin.ProviderFromContext) and the Profile.
+const ProviderName = "pinterest"
+
+// Pinterest login errors
+var (
+	ErrUnableToGetPinterestUser = errors.New("pinterest: unable to get Pinterest User")
+)
+
+// Endpoint is Pinterest's v5 OAuth2 endpoint. Pinterest requires HTTP Basic
+// client authentication.
+var Endpoint = oauth2.Endpoint{
+	AuthURL:   "https://www.pinterest.com/oauth/",
+	TokenURL:  "https://api.pinterest.com/v5/oauth/token",
+	AuthStyle: oauth2.AuthStyleInHeader,
+}
+
+// ContinuousRefresh is an AuthCodeOption which sets continuous_refresh=true
+// on the token exchange so Pinterest issues a refresh token which is renewed
+// on each refresh. Pass it to CallbackHandler.
+var ContinuousRefresh = oauth2.SetAuthURLParam("continuous_refresh", "true")
+
+// StateHandler checks for a state cookie. If found, the state value is read
+// and added to the ctx. Otherwise, a non-guessable value is added to the ctx
+// and to a (short-lived) state cookie issued to the requester.
+//
+// Implements OAuth 2 RFC 6749 10.12 CSRF Protection. If you wish to issue
+// state params differently, write a http.Handler which sets the ctx state,
+// using oauth2 WithState(ctx, state) since it is required by LoginHandler
+// and CallbackHandler.
+func StateHandler(config gologin.CookieConfig, success http.Handler) http.Handler {
+	return oauth2Login.StateHandler(config, success)
+}
+
+// LoginHandler handles Pinterest login requests by reading the state value
+// from the ctx and redirecting requests to the AuthURL with that state value.
+// Any AuthCodeOptions are added to the AuthURL.
+//
+// Scopes should include "user_accounts:read" to get the Pinterest User.
+func LoginHandler(config *oauth2.Config, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	return oauth2Login.LoginHandler(config, failure, opts...)
+}
+
+// CallbackHandler handles Pinterest redirection URI requests and adds the
+// Pinterest access token and User to the ctx. If authentication succeeds,
+// handling delegates to the success handler, otherwise to the failure
+// handler.
+// Any AuthCodeOptions (e.g. ContinuousRefresh) are sent with the token
+// exchange.
+//
+// Pinterest refresh tokens expire (see the Token extra
+// "refresh_token_expires_in"), so store the full ctx Token.
+func CallbackHandler(config *oauth2.Config, success, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	success = pinterestHandler(config, success, failure)
+	return oauth2Login.CallbackHandler(config, success, failure, opts...)
+}
+
+// pinterestHandler is a http.Handler that gets the OAuth2 Token from the ctx
+// to get the corresponding Pinterest User. If successful, the User is added
+// to the ctx and the success handler is called. Otherwise, the failure
+// handler is called.
+func pinterestHandler(config *oauth2.Config, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		httpClient := internal.OAuth2Client(ctx, config, token)
+		user, resp, err := newClient(httpClient).UserAccount(ctx)
+		err = validateResponse(user, resp, err)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		ctx = WithUser(ctx, user)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// validateResponse returns an error if the given Pinterest User, raw
+// http.Response, or error are unexpected. Returns nil if they are valid, or a
+// *gologin.Error which preserves the cause and status code.
+func validateResponse(user *User, resp *http.Response, err error) error {
+	var status int
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	if err != nil || status != http.StatusOK {
+		return &gologin.Error{Provider: "pinterest", Op: "get user", StatusCode: status, Err: err, Kind: ErrUnableToGetPinterestUser}
+	}
+	if user == nil || user.ID == "" {
+		return &gologin.Error{Provider: "pinterest", Op: "get user", StatusCode: status, Kind: ErrUnableToGetPinterestUser}
+	}
+	return nil
+}
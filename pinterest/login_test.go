@@ -0,0 +1,161 @@
+package pinterest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dghubble/gologin"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func testConfig() *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     "client_id",
+		ClientSecret: "client_secret",
+		RedirectURL:  "https://example.com/pinterest/callback",
+		Endpoint:     Endpoint,
+		Scopes:       []string{"user_accounts:read"},
+	}
+}
+
+func TestCallbackHandler(t *testing.T) {
+	proxyClient, server := newPinterestTestServer(testUserJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithState(ctx, "d4e5f6")
+
+	expectedUser := &User{
+		ID:           "549755885175",
+		Username:     "pin_fan",
+		AccountType:  AccountTypeBusiness,
+		ProfileImage: "https://i.pinimg.com/280x280_RS/fc/5e/06/fc5e0672101fe1fc4a1d0c1770bdad4d.jpg",
+		WebsiteURL:   "https://example.com",
+	}
+	success := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "any-token", token.AccessToken)
+			assert.Equal(t, "continuous-refresh", token.RefreshToken)
+			assert.Equal(t, float64(31536000), token.Extra("refresh_token_expires_in"))
+		}
+		user, err := UserFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, expectedUser, user)
+			assert.True(t, user.IsBusiness())
+		}
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// CallbackHandler with ContinuousRefresh assert that:
+	// - continuous_refresh is sent with the token exchange
+	// - success handler is called
+	// - Pinterest Token (with refresh token) and User are added to the ctx of
+	// the success handler
+	callbackHandler := CallbackHandler(testConfig(), http.HandlerFunc(success), failure, ContinuousRefresh)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestPinterestHandler_APIError(t *testing.T) {
+	proxyClient, server := newPinterestTestServer(testUserJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "invalid-token"})
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.True(t, errors.Is(err, ErrUnableToGetPinterestUser))
+			var apiErr *APIError
+			if assert.True(t, errors.As(err, &apiErr)) {
+				assert.Equal(t, &APIError{Code: 2, Message: "Authentication failed."}, apiErr)
+			}
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// PinterestHandler gets a Pinterest error response, assert that:
+	// - failure handler is called
+	// - error cannot get Pinterest User is added to the failure handler ctx,
+	// with the Pinterest APIError as the cause
+	pinterestHandler := pinterestHandler(testConfig(), success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	pinterestHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestPinterestHandler_MissingCtxToken(t *testing.T) {
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "oauth2: Context missing Token", err.Error())
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// PinterestHandler called without Token in ctx, assert that:
+	// - failure handler is called
+	// - error about ctx missing token is added to the failure handler ctx
+	pinterestHandler := pinterestHandler(testConfig(), success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	pinterestHandler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestPinterestHandler_ErrorGettingUser(t *testing.T) {
+	proxyClient, server := testutils.NewErrorServer("Pinterest Service Down", http.StatusInternalServerError)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.True(t, errors.Is(err, ErrUnableToGetPinterestUser))
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// PinterestHandler cannot get Pinterest User, assert that:
+	// - failure handler is called
+	// - error cannot get Pinterest User added to the failure handler ctx
+	pinterestHandler := pinterestHandler(testConfig(), success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	pinterestHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestUser_IsBusiness(t *testing.T) {
+	assert.True(t, (&User{AccountType: AccountTypeBusiness}).IsBusiness())
+	assert.False(t, (&User{AccountType: AccountTypePinner}).IsBusiness())
+}
+
+func TestValidateResponse(t *testing.T) {
+	validUser := &User{ID: "549755885175", Username: "pin_fan"}
+	validResponse := &http.Response{StatusCode: 200}
+	invalidResponse := &http.Response{StatusCode: 500}
+	assert.Equal(t, nil, validateResponse(validUser, validResponse, nil))
+	assert.True(t, errors.Is(validateResponse(validUser, validResponse, fmt.Errorf("Server error")), ErrUnableToGetPinterestUser))
+	assert.True(t, errors.Is(validateResponse(validUser, invalidResponse, nil), ErrUnableToGetPinterestUser))
+	assert.True(t, errors.Is(validateResponse(&User{Username: "pin_fan"}, validResponse, nil), ErrUnableToGetPinterestUser))
+}
@@ -0,0 +1,51 @@
+package pinterest
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/dghubble/gologin/testutils"
+)
+
+const (
+	// testUserJSON is a user_account response of a business account.
+	testUserJSON = `{"account_type": "BUSINESS", "id": "549755885175", "profile_image": "https://i.pinimg.com/280x280_RS/fc/5e/06/fc5e0672101fe1fc4a1d0c1770bdad4d.jpg", "website_url": "https://example.com", "username": "pin_fan", "business_name": "Pin Fan Co", "board_count": 11, "pin_count": 120}`
+	// testAuthFailedJSON is a Pinterest error response.
+	testAuthFailedJSON = `{"code": 2, "message": "Authentication failed."}`
+)
+
+// newPinterestTestServer returns a new httptest.Server which mocks the
+// Pinterest token and user_account endpoints and a client which proxies
+// requests to the server. Like Pinterest, the token endpoint requires HTTP
+// Basic client authentication. Token requests with continuous_refresh=true
+// get a continuous refresh token. The user_account endpoint responds with the
+// given json data, or an authentication error for tokens other than
+// "any-token". The caller must close the server.
+func newPinterestTestServer(jsonData string) (*http.Client, *httptest.Server) {
+	client, mux, server := testutils.TestServer()
+	mux.HandleFunc("/v5/oauth/token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		username, password, ok := r.BasicAuth()
+		if !ok || username != "client_id" || password != "client_secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprintf(w, testAuthFailedJSON)
+			return
+		}
+		refreshToken := "any-refresh"
+		if r.PostFormValue("continuous_refresh") == "true" {
+			refreshToken = "continuous-refresh"
+		}
+		fmt.Fprintf(w, `{"access_token": "any-token", "refresh_token": "%s", "response_type": "authorization_code", "token_type": "bearer", "expires_in": 2592000, "refresh_token_expires_in": 31536000, "scope": "user_accounts:read"}`, refreshToken)
+	})
+	mux.HandleFunc("/v5/user_account", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Header.Get("Authorization") != "Bearer any-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprintf(w, testAuthFailedJSON)
+			return
+		}
+		fmt.Fprintf(w, jsonData)
+	})
+	return client, server
+}
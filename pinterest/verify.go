@@ -0,0 +1,69 @@
+package pinterest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/dghubble/gologin/internal/jsonclient"
+)
+
+const pinterestAPI = "https://api.pinterest.com/v5/"
+
+// AccountType is a Pinterest account type.
+type AccountType string
+
+// Pinterest account types
+const (
+	AccountTypePinner   AccountType = "PINNER"
+	AccountTypeBusiness AccountType = "BUSINESS"
+)
+
+// User is a Pinterest user account.
+type User struct {
+	ID           string      `json:"id"`
+	Username     string      `json:"username"`
+	AccountType  AccountType `json:"account_type"`
+	ProfileImage string      `json:"profile_image"`
+	WebsiteURL   string      `json:"website_url"`
+}
+
+// IsBusiness returns true if the User has a business account.
+func (u *User) IsBusiness() bool {
+	return u.AccountType == AccountTypeBusiness
+}
+
+// APIError is a Pinterest API error response.
+type APIError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("pinterest: %s (code %d)", e.Message, e.Code)
+}
+
+// client is a Pinterest client for obtaining the current User.
+type client struct {
+	json *jsonclient.Client
+}
+
+// newClient returns a new Pinterest client.
+func newClient(httpClient *http.Client) *client {
+	return &client{
+		json: jsonclient.New(httpClient, pinterestAPI),
+	}
+}
+
+// UserAccount returns the current Pinterest User. If Pinterest responds with
+// an error, it is returned as an *APIError.
+// https://developers.pinterest.com/docs/api/v5/user_account-get
+func (c *client) UserAccount(ctx context.Context) (*User, *http.Response, error) {
+	user := new(User)
+	apiErr := new(APIError)
+	resp, err := c.json.Get(ctx, "user_account", nil, user, apiErr)
+	if err == nil && apiErr.Message != "" {
+		err = apiErr
+	}
+	return user, resp, err
+}
@@ -0,0 +1,76 @@
+package gologin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// Profile is a provider-independent user profile. Provider packages add it to
+// the ctx alongside their native User (see each provider's WithUser) so login
+// success handlers can handle users of any provider.
+type Profile struct {
+	// Provider is the provider name (e.g. "facebook").
+	Provider string
+	// ID is the provider's unique user ID.
+	ID string
+	// Email is the user's email address, if the provider returned one (e.g.
+	// Twitter only returns emails to allowlisted apps).
+	Email string
+	// EmailVerified is true if the provider asserts the Email is verified.
+	EmailVerified bool
+	// Name is the user's display name, falling back to the username.
+	Name string
+	// AvatarURL is the URL of the user's profile image, if any.
+	AvatarURL string
+	// Raw holds the native User's fields which are not mapped to a Profile
+	// field, keyed by their JSON names.
+	Raw map[string]interface{}
+}
+
+// WithProfile returns a copy of ctx that stores the Profile. The Profile
+// Provider is also stored as the ctx provider name (see ProviderFromContext),
+// unless the ctx already has one (e.g. from a ProviderHandler).
+func WithProfile(ctx context.Context, profile *Profile) context.Context {
+	if profile != nil && profile.Provider != "" && ProviderFromContext(ctx, "") == "" {
+		ctx = WithProvider(ctx, profile.Provider)
+	}
+	return context.WithValue(ctx, profileKey, profile)
+}
+
+// ProfileFromContext returns the Profile from the ctx.
+func ProfileFromContext(ctx context.Context) (*Profile, error) {
+	profile, ok := ctx.Value(profileKey).(*Profile)
+	if !ok || profile == nil {
+		return nil, fmt.Errorf("Context missing Profile")
+	}
+	return profile, nil
+}
+
+// ProfileRaw returns the JSON fields of the native user, except the mapped
+// fields, for a Profile Raw map. Empty fields are kept as JSON encodes them.
+func ProfileRaw(user interface{}, mapped ...string) map[string]interface{} {
+	raw := make(map[string]interface{})
+	data, err := json.Marshal(user)
+	if err != nil {
+		return raw
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return make(map[string]interface{})
+	}
+	for _, name := range mapped {
+		delete(raw, name)
+	}
+	return raw
+}
+
+// DisplayName returns the first non-empty name (e.g. a full name, then a
+// username), for a Profile Name.
+func DisplayName(names ...string) string {
+	for _, name := range names {
+		if name != "" {
+			return name
+		}
+	}
+	return ""
+}
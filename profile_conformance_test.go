@@ -0,0 +1,234 @@
+package gologin_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dghubble/go-twitter/twitter"
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/amazon"
+	"github.com/dghubble/gologin/apple"
+	"github.com/dghubble/gologin/atlassian"
+	"github.com/dghubble/gologin/auth0"
+	"github.com/dghubble/gologin/basecamp"
+	"github.com/dghubble/gologin/battlenet"
+	"github.com/dghubble/gologin/bitbucket"
+	"github.com/dghubble/gologin/box"
+	"github.com/dghubble/gologin/coinbase"
+	"github.com/dghubble/gologin/digitalocean"
+	"github.com/dghubble/gologin/digits"
+	"github.com/dghubble/gologin/discord"
+	"github.com/dghubble/gologin/dropbox"
+	"github.com/dghubble/gologin/epicgames"
+	"github.com/dghubble/gologin/eventbrite"
+	"github.com/dghubble/gologin/facebook"
+	"github.com/dghubble/gologin/figma"
+	"github.com/dghubble/gologin/fitbit"
+	"github.com/dghubble/gologin/foursquare"
+	"github.com/dghubble/gologin/gitea"
+	githubLogin "github.com/dghubble/gologin/github"
+	"github.com/dghubble/gologin/gitlab"
+	googleLogin "github.com/dghubble/gologin/google"
+	"github.com/dghubble/gologin/heroku"
+	"github.com/dghubble/gologin/instagram"
+	"github.com/dghubble/gologin/intuit"
+	"github.com/dghubble/gologin/kakao"
+	"github.com/dghubble/gologin/keycloak"
+	"github.com/dghubble/gologin/line"
+	"github.com/dghubble/gologin/linkedin"
+	"github.com/dghubble/gologin/mastodon"
+	"github.com/dghubble/gologin/medium"
+	"github.com/dghubble/gologin/meetup"
+	"github.com/dghubble/gologin/microsoft"
+	"github.com/dghubble/gologin/naver"
+	"github.com/dghubble/gologin/notion"
+	"github.com/dghubble/gologin/oidc"
+	"github.com/dghubble/gologin/okta"
+	"github.com/dghubble/gologin/patreon"
+	"github.com/dghubble/gologin/paypal"
+	"github.com/dghubble/gologin/pinterest"
+	"github.com/dghubble/gologin/reddit"
+	"github.com/dghubble/gologin/salesforce"
+	"github.com/dghubble/gologin/shopify"
+	"github.com/dghubble/gologin/slack"
+	"github.com/dghubble/gologin/soundcloud"
+	"github.com/dghubble/gologin/spotify"
+	"github.com/dghubble/gologin/stackexchange"
+	"github.com/dghubble/gologin/steam"
+	"github.com/dghubble/gologin/strava"
+	"github.com/dghubble/gologin/trello"
+	"github.com/dghubble/gologin/tumblr"
+	"github.com/dghubble/gologin/twitch"
+	twitterLogin "github.com/dghubble/gologin/twitter"
+	"github.com/dghubble/gologin/twitterv2"
+	"github.com/dghubble/gologin/vimeo"
+	"github.com/dghubble/gologin/vk"
+	"github.com/dghubble/gologin/wechat"
+	"github.com/dghubble/gologin/weibo"
+	"github.com/dghubble/gologin/xero"
+	"github.com/dghubble/gologin/yahoo"
+	"github.com/dghubble/gologin/yandex"
+	"github.com/dghubble/gologin/zoom"
+	"github.com/stretchr/testify/assert"
+	google "google.golang.org/api/oauth2/v2"
+)
+
+// TestProfile_Conformance checks every provider package adds a Profile with
+// the WithUser of its native User.
+func TestProfile_Conformance(t *testing.T) {
+	ctx := context.Background()
+	cases := []struct {
+		provider string
+		ctx      context.Context
+	}{
+		{"amazon", amazon.WithUser(ctx, &amazon.User{ID: "1"})},
+		{"apple", apple.WithUser(ctx, &apple.User{ID: "1"})},
+		{"atlassian", atlassian.WithUser(ctx, &atlassian.User{AccountID: "1"})},
+		{"auth0", auth0.WithUser(ctx, &auth0.User{ID: "1"})},
+		{"basecamp", basecamp.WithUser(ctx, &basecamp.User{ID: 1})},
+		{"battlenet", battlenet.WithUser(ctx, &battlenet.User{ID: 1})},
+		{"bitbucket", bitbucket.WithUser(ctx, &bitbucket.User{UUID: "1"})},
+		{"box", box.WithUser(ctx, &box.User{ID: "1"})},
+		{"coinbase", coinbase.WithUser(ctx, &coinbase.User{ID: "1"})},
+		{"digitalocean", digitalocean.WithUser(ctx, &digitalocean.User{UUID: "1"})},
+		{"discord", discord.WithUser(ctx, &discord.User{ID: "1"})},
+		{"dropbox", dropbox.WithUser(ctx, &dropbox.User{AccountID: "1"})},
+		{"epicgames", epicgames.WithUser(ctx, &epicgames.User{AccountID: "1"})},
+		{"eventbrite", eventbrite.WithUser(ctx, &eventbrite.User{ID: "1"})},
+		{"facebook", facebook.WithUser(ctx, &facebook.User{ID: "1"})},
+		{"figma", figma.WithUser(ctx, &figma.User{ID: "1"})},
+		{"fitbit", fitbit.WithUser(ctx, &fitbit.User{EncodedID: "1"})},
+		{"foursquare", foursquare.WithUser(ctx, &foursquare.User{ID: "1"})},
+		{"gitea", gitea.WithUser(ctx, &gitea.User{ID: 1})},
+		{"github", githubLogin.WithUser(ctx, &githubLogin.User{ID: 1})},
+		{"gitlab", gitlab.WithUser(ctx, &gitlab.User{ID: 1})},
+		{"google", googleLogin.WithUser(ctx, &google.Userinfoplus{Id: "1"})},
+		{"heroku", heroku.WithUser(ctx, &heroku.User{ID: "1"})},
+		{"instagram", instagram.WithUser(ctx, &instagram.User{ID: "1"})},
+		{"intuit", intuit.WithUser(ctx, &intuit.User{ID: "1"})},
+		{"kakao", kakao.WithUser(ctx, &kakao.User{ID: 1})},
+		{"keycloak", keycloak.WithUser(ctx, &keycloak.User{ID: "1"})},
+		{"line", line.WithUser(ctx, &line.User{UserID: "1"})},
+		{"linkedin", linkedin.WithUser(ctx, &linkedin.User{ID: "1"})},
+		{"mastodon", mastodon.WithUser(ctx, &mastodon.User{ID: "1"})},
+		{"medium", medium.WithUser(ctx, &medium.User{ID: "1"})},
+		{"meetup", meetup.WithUser(ctx, &meetup.User{ID: 1})},
+		{"microsoft", microsoft.WithUser(ctx, &microsoft.User{ID: "1"})},
+		{"naver", naver.WithUser(ctx, &naver.User{ID: "1"})},
+		{"notion", notion.WithUser(ctx, &notion.User{ID: "1"})},
+		{"okta", okta.WithUser(ctx, &okta.User{ID: "1"})},
+		{"patreon", patreon.WithUser(ctx, &patreon.User{ID: "1"})},
+		{"paypal", paypal.WithUser(ctx, &paypal.User{ID: "1"})},
+		{"pinterest", pinterest.WithUser(ctx, &pinterest.User{ID: "1"})},
+		{"reddit", reddit.WithUser(ctx, &reddit.User{ID: "1"})},
+		{"salesforce", salesforce.WithUser(ctx, &salesforce.User{UserID: "1"})},
+		{"slack", slack.WithUser(ctx, &slack.User{ID: "1"})},
+		{"soundcloud", soundcloud.WithUser(ctx, &soundcloud.User{ID: 1})},
+		{"spotify", spotify.WithUser(ctx, &spotify.User{ID: "1"})},
+		{"stackexchange", stackexchange.WithUser(ctx, &stackexchange.User{UserID: 1})},
+		{"steam", steam.WithUser(ctx, &steam.User{SteamID: "1"})},
+		{"strava", strava.WithUser(ctx, &strava.User{ID: 1})},
+		{"trello", trello.WithUser(ctx, &trello.User{ID: "1"})},
+		{"tumblr", tumblr.WithUser(ctx, &tumblr.User{Name: "1"})},
+		{"twitch", twitch.WithUser(ctx, &twitch.User{ID: "1"})},
+		{"twitter", twitterLogin.WithUser(ctx, &twitter.User{ID: 1})},
+		{"twitterv2", twitterv2.WithUser(ctx, &twitterv2.User{ID: "1"})},
+		{"vimeo", vimeo.WithUser(ctx, &vimeo.User{ID: 1})},
+		{"vk", vk.WithUser(ctx, &vk.User{ID: 1})},
+		{"wechat", wechat.WithUser(ctx, &wechat.User{OpenID: "1"})},
+		{"weibo", weibo.WithUser(ctx, &weibo.User{IDStr: "1"})},
+		{"xero", xero.WithUser(ctx, &xero.User{ID: "1"})},
+		{"yahoo", yahoo.WithUser(ctx, &yahoo.User{ID: "1"})},
+		{"yandex", yandex.WithUser(ctx, &yandex.User{ID: "1"})},
+		{"zoom", zoom.WithUser(ctx, &zoom.User{ID: "1"})},
+	}
+	for _, c := range cases {
+		// WithUser assert that:
+		// - the Profile Provider is the provider name
+		// - the Profile ID is the User ID
+		// - the ctx provider name is the provider name
+		profile, err := gologin.ProfileFromContext(c.ctx)
+		if assert.Nil(t, err, c.provider) {
+			assert.Equal(t, c.provider, profile.Provider)
+			assert.Equal(t, "1", profile.ID, c.provider)
+			assert.NotNil(t, profile.Raw, c.provider)
+		}
+		assert.Equal(t, c.provider, gologin.ProviderFromContext(c.ctx, ""))
+	}
+}
+
+// TestProviderName_Conformance checks the provider names of the provider
+// packages, which are set in the ctx for success handlers, stay stable.
+func TestProviderName_Conformance(t *testing.T) {
+	cases := []struct {
+		expected string
+		name     string
+	}{
+		{"amazon", amazon.ProviderName},
+		{"apple", apple.ProviderName},
+		{"atlassian", atlassian.ProviderName},
+		{"auth0", auth0.ProviderName},
+		{"basecamp", basecamp.ProviderName},
+		{"battlenet", battlenet.ProviderName},
+		{"bitbucket", bitbucket.ProviderName},
+		{"box", box.ProviderName},
+		{"coinbase", coinbase.ProviderName},
+		{"digitalocean", digitalocean.ProviderName},
+		{"digits", digits.ProviderName},
+		{"discord", discord.ProviderName},
+		{"dropbox", dropbox.ProviderName},
+		{"epicgames", epicgames.ProviderName},
+		{"eventbrite", eventbrite.ProviderName},
+		{"facebook", facebook.ProviderName},
+		{"figma", figma.ProviderName},
+		{"fitbit", fitbit.ProviderName},
+		{"foursquare", foursquare.ProviderName},
+		{"gitea", gitea.ProviderName},
+		{"github", githubLogin.ProviderName},
+		{"gitlab", gitlab.ProviderName},
+		{"google", googleLogin.ProviderName},
+		{"heroku", heroku.ProviderName},
+		{"instagram", instagram.ProviderName},
+		{"intuit", intuit.ProviderName},
+		{"kakao", kakao.ProviderName},
+		{"keycloak", keycloak.ProviderName},
+		{"line", line.ProviderName},
+		{"linkedin", linkedin.ProviderName},
+		{"mastodon", mastodon.ProviderName},
+		{"medium", medium.ProviderName},
+		{"meetup", meetup.ProviderName},
+		{"microsoft", microsoft.ProviderName},
+		{"naver", naver.ProviderName},
+		{"notion", notion.ProviderName},
+		{"oidc", oidc.ProviderName},
+		{"okta", okta.ProviderName},
+		{"patreon", patreon.ProviderName},
+		{"paypal", paypal.ProviderName},
+		{"pinterest", pinterest.ProviderName},
+		{"reddit", reddit.ProviderName},
+		{"salesforce", salesforce.ProviderName},
+		{"shopify", shopify.ProviderName},
+		{"slack", slack.ProviderName},
+		{"soundcloud", soundcloud.ProviderName},
+		{"spotify", spotify.ProviderName},
+		{"stackexchange", stackexchange.ProviderName},
+		{"steam", steam.ProviderName},
+		{"strava", strava.ProviderName},
+		{"trello", trello.ProviderName},
+		{"tumblr", tumblr.ProviderName},
+		{"twitch", twitch.ProviderName},
+		{"twitter", twitterLogin.ProviderName},
+		{"twitterv2", twitterv2.ProviderName},
+		{"vimeo", vimeo.ProviderName},
+		{"vk", vk.ProviderName},
+		{"wechat", wechat.ProviderName},
+		{"weibo", weibo.ProviderName},
+		{"xero", xero.ProviderName},
+		{"yahoo", yahoo.ProviderName},
+		{"yandex", yandex.ProviderName},
+		{"zoom", zoom.ProviderName},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.expected, c.name)
+	}
+}
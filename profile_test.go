@@ -0,0 +1,64 @@
+package gologin
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProfileFromContext(t *testing.T) {
+	expected := &Profile{Provider: "facebook", ID: "54638001"}
+	ctx := WithProfile(context.Background(), expected)
+	profile, err := ProfileFromContext(ctx)
+	assert.Equal(t, expected, profile)
+	assert.Nil(t, err)
+}
+
+func TestProfileFromContext_Error(t *testing.T) {
+	profile, err := ProfileFromContext(context.Background())
+	assert.Nil(t, profile)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "Context missing Profile", err.Error())
+	}
+	// a provider WithUser of a nil User stores a nil Profile
+	profile, err = ProfileFromContext(WithProfile(context.Background(), nil))
+	assert.Nil(t, profile)
+	assert.NotNil(t, err)
+}
+
+func TestWithProfile_Provider(t *testing.T) {
+	// WithProfile, assert that:
+	// - the Profile Provider is stored as the ctx provider name
+	// - a ctx provider name (e.g. from a ProviderHandler) is kept
+	ctx := WithProfile(context.Background(), &Profile{Provider: "facebook", ID: "54638001"})
+	assert.Equal(t, "facebook", ProviderFromContext(ctx, ""))
+	ctx = WithProfile(WithProvider(context.Background(), "facebook-workplace"), &Profile{Provider: "facebook", ID: "54638001"})
+	assert.Equal(t, "facebook-workplace", ProviderFromContext(ctx, ""))
+	ctx = WithProfile(context.Background(), nil)
+	assert.Equal(t, "", ProviderFromContext(ctx, ""))
+}
+
+func TestProfileRaw(t *testing.T) {
+	user := struct {
+		ID        string `json:"id"`
+		Name      string `json:"name"`
+		Location  string `json:"location"`
+		Followers int    `json:"followers"`
+	}{"54638001", "Ivy Crimson", "Seattle", 3}
+	// ProfileRaw assert that:
+	// - mapped fields are removed
+	// - other fields are kept by JSON name
+	raw := ProfileRaw(user, "id", "name")
+	assert.Equal(t, map[string]interface{}{"location": "Seattle", "followers": float64(3)}, raw)
+	// - a value which does not encode as a JSON object gives an empty map
+	assert.Equal(t, map[string]interface{}{}, ProfileRaw(make(chan int)))
+	assert.Equal(t, map[string]interface{}{}, ProfileRaw("name"))
+}
+
+func TestDisplayName(t *testing.T) {
+	assert.Equal(t, "Ivy Crimson", DisplayName("Ivy Crimson", "ivy"))
+	assert.Equal(t, "ivy", DisplayName("", "ivy"))
+	assert.Equal(t, "", DisplayName("", ""))
+	assert.Equal(t, "", DisplayName())
+}
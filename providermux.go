@@ -0,0 +1,137 @@
+package gologin
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"golang.org/x/oauth2"
+)
+
+// Provider is the handler constructors of an OAuth2 provider package, which a
+// ProviderMux uses to build the provider's login and callback chains. Most
+// provider packages fit as is, e.g.
+//
+//	gologin.Provider{
+//		StateHandler:    github.StateHandler,
+//		LoginHandler:    github.LoginHandler,
+//		CallbackHandler: github.CallbackHandler,
+//	}
+type Provider struct {
+	StateHandler    func(config CookieConfig, success http.Handler) http.Handler
+	LoginHandler    func(config *oauth2.Config, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler
+	CallbackHandler func(config *oauth2.Config, success, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler
+}
+
+// ProviderMuxConfig configures a ProviderMux.
+type ProviderMuxConfig struct {
+	// BaseURL is the external URL of the server (e.g. "https://example.com")
+	// from which provider RedirectURLs are derived.
+	BaseURL string
+	// Prefix is the path under which providers are mounted (e.g. "/auth").
+	Prefix string
+	// StateConfig configures the state cookies. Each provider's cookie Name
+	// is suffixed with the provider name so concurrent logins with different
+	// providers do not collide. Defaults to DefaultCookieConfig.
+	StateConfig CookieConfig
+	// Failure handles login and callback failures. Defaults to
+	// DefaultFailureHandler.
+	Failure http.Handler
+}
+
+// ProviderMux serves the login and callback routes of registered providers at
+// {Prefix}/{provider}/login and {Prefix}/{provider}/callback. Requests for
+// other paths, including unknown providers, respond 404 Not Found.
+type ProviderMux struct {
+	config    ProviderMuxConfig
+	mu        sync.RWMutex
+	providers map[string]*providerRoutes
+}
+
+// providerRoutes are the login and callback chains of a provider.
+type providerRoutes struct {
+	login    http.Handler
+	callback http.Handler
+}
+
+// NewProviderMux returns a new ProviderMux.
+func NewProviderMux(config ProviderMuxConfig) *ProviderMux {
+	if config.StateConfig == (CookieConfig{}) {
+		config.StateConfig = DefaultCookieConfig
+	}
+	if config.Failure == nil {
+		config.Failure = DefaultFailureHandler
+	}
+	config.BaseURL = strings.TrimSuffix(config.BaseURL, "/")
+	config.Prefix = "/" + strings.Trim(config.Prefix, "/")
+	if config.Prefix == "/" {
+		config.Prefix = ""
+	}
+	return &ProviderMux{
+		config:    config,
+		providers: make(map[string]*providerRoutes),
+	}
+}
+
+// RedirectURL returns the callback URL of the named provider, which Handle
+// sets as its oauth2.Config RedirectURL. Register it with the provider.
+func (m *ProviderMux) RedirectURL(name string) string {
+	return m.config.BaseURL + m.config.Prefix + "/" + name + "/callback"
+}
+
+// Handle registers the named provider's login and callback routes. A copy of
+// the oauth2.Config is used with its RedirectURL set to RedirectURL(name). The
+// provider name is added to the ctx (see ProviderFromContext) so one success
+// handler can serve every provider. Handle panics if the name is empty,
+// contains a "/", or is already registered, like http.ServeMux.
+func (m *ProviderMux) Handle(name string, provider Provider, config *oauth2.Config, success http.Handler) {
+	if name == "" || strings.Contains(name, "/") {
+		panic(fmt.Sprintf("gologin: invalid provider name %q", name))
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.providers[name]; ok {
+		panic(fmt.Sprintf("gologin: provider %q already registered", name))
+	}
+	redirectConfig := *config
+	redirectConfig.RedirectURL = m.RedirectURL(name)
+	stateConfig := m.config.StateConfig
+	stateConfig.Name = stateConfig.Name + "-" + name
+	login := provider.StateHandler(stateConfig, provider.LoginHandler(&redirectConfig, m.config.Failure))
+	callback := provider.StateHandler(stateConfig, provider.CallbackHandler(&redirectConfig, success, m.config.Failure))
+	m.providers[name] = &providerRoutes{
+		login:    ProviderHandler(name, login),
+		callback: ProviderHandler(name, callback),
+	}
+}
+
+// ServeHTTP dispatches the request to the login or callback chain of the
+// provider named in its path.
+func (m *ProviderMux) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	path := req.URL.Path
+	if !strings.HasPrefix(path, m.config.Prefix+"/") {
+		http.NotFound(w, req)
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(path, m.config.Prefix+"/"), "/")
+	if len(parts) != 2 {
+		http.NotFound(w, req)
+		return
+	}
+	m.mu.RLock()
+	routes, ok := m.providers[parts[0]]
+	m.mu.RUnlock()
+	if !ok {
+		http.NotFound(w, req)
+		return
+	}
+	switch parts[1] {
+	case "login":
+		routes.login.ServeHTTP(w, req)
+	case "callback":
+		routes.callback.ServeHTTP(w, req)
+	default:
+		http.NotFound(w, req)
+	}
+}
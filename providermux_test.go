@@ -0,0 +1,143 @@
+package gologin_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/facebook"
+	githubLogin "github.com/dghubble/gologin/github"
+	"github.com/dghubble/gologin/gologintest"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+// hostTransport sends requests to the http.RoundTripper of their host.
+type hostTransport map[string]http.RoundTripper
+
+func (t hostTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if rt, ok := t[req.URL.Host]; ok {
+		return rt.RoundTrip(req)
+	}
+	return nil, fmt.Errorf("unexpected request to %s", req.URL.Host)
+}
+
+func hostOf(rawURL string) string {
+	u, _ := url.Parse(rawURL)
+	return u.Host
+}
+
+func TestProviderMux(t *testing.T) {
+	facebookProvider := gologintest.NewFakeOAuth2ProviderWithUserInfoPath("/v2.9/me")
+	defer facebookProvider.Close()
+	facebookProvider.UserInfoJSON = `{"id": "54638001", "name": "Ivy Crimson"}`
+	githubProvider := gologintest.NewFakeOAuth2ProviderWithUserInfoPath("/user")
+	defer githubProvider.Close()
+	githubProvider.UserInfoJSON = `{"id": 917408, "login": "octocat", "email": "octocat@example.com"}`
+	// provider token and API requests are sent to the fake providers
+	client := &http.Client{Transport: hostTransport{
+		hostOf(facebookProvider.URL): facebookProvider.Client().Transport,
+		"graph.facebook.com":         facebookProvider.Client().Transport,
+		hostOf(githubProvider.URL):   githubProvider.Client().Transport,
+		"api.github.com":             githubProvider.Client().Transport,
+	}}
+
+	var stateCookies []string
+	success := func(w http.ResponseWriter, req *http.Request) {
+		for _, cookie := range req.Cookies() {
+			stateCookies = append(stateCookies, cookie.Name)
+		}
+		ctx := req.Context()
+		profile, err := gologin.ProfileFromContext(ctx)
+		if assert.Nil(t, err) {
+			fmt.Fprintf(w, "%s %s %s", gologin.ProviderFromContext(ctx, ""), profile.Provider, profile.ID)
+		}
+	}
+	var mux *gologin.ProviderMux
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ctx := gologin.WithHTTPClient(req.Context(), client)
+		mux.ServeHTTP(w, req.WithContext(ctx))
+	}))
+	defer server.Close()
+	mux = gologin.NewProviderMux(gologin.ProviderMuxConfig{
+		BaseURL:     server.URL,
+		Prefix:      "/auth/",
+		StateConfig: gologin.DebugOnlyCookieConfig,
+	})
+	facebookConfig := &oauth2.Config{ClientID: "facebook_client", Endpoint: facebookProvider.Endpoint()}
+	githubConfig := &oauth2.Config{ClientID: "github_client", Endpoint: githubProvider.Endpoint()}
+	mux.Handle("facebook", gologin.Provider{
+		StateHandler:    facebook.StateHandler,
+		LoginHandler:    facebook.LoginHandler,
+		CallbackHandler: facebook.CallbackHandler,
+	}, facebookConfig, http.HandlerFunc(success))
+	mux.Handle("github", gologin.Provider{
+		StateHandler:    githubLogin.StateHandler,
+		LoginHandler:    githubLogin.LoginHandler,
+		CallbackHandler: githubLogin.CallbackHandler,
+	}, githubConfig, http.HandlerFunc(success))
+
+	// ProviderMux assert that:
+	// - RedirectURLs are derived from the BaseURL and Prefix
+	// - the registered oauth2.Configs are not modified
+	assert.Equal(t, server.URL+"/auth/github/callback", mux.RedirectURL("github"))
+	assert.Empty(t, facebookConfig.RedirectURL)
+
+	// - login and callback flows of both providers run through the mux
+	// - the shared success handler sees the provider name and Profile
+	jar, _ := cookiejar.New(nil)
+	browser := &http.Client{Jar: jar}
+	cases := []struct {
+		provider string
+		body     string
+	}{
+		{"facebook", "facebook facebook 54638001"},
+		{"github", "github github 917408"},
+	}
+	for _, c := range cases {
+		resp, err := browser.Get(server.URL + "/auth/" + c.provider + "/login")
+		if assert.Nil(t, err) {
+			body, _ := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			assert.Equal(t, http.StatusOK, resp.StatusCode, c.provider)
+			assert.Equal(t, c.body, string(body))
+			assert.Equal(t, "/auth/"+c.provider+"/callback", resp.Request.URL.Path)
+		}
+	}
+	// - each provider has its own state cookie
+	// - state cookies are expired after the callbacks
+	assert.Equal(t, []string{"gologin-temporary-cookie-facebook", "gologin-temporary-cookie-github"}, stateCookies)
+	assert.Empty(t, jar.Cookies(&url.URL{Scheme: "http", Host: hostOf(server.URL), Path: "/"}))
+
+	// - unknown providers and routes respond 404
+	for _, path := range []string{"/auth/twitter/login", "/auth/github/logout", "/auth/github", "/auth/github/login/extra", "/other/github/login"} {
+		resp, err := browser.Get(server.URL + path)
+		if assert.Nil(t, err) {
+			resp.Body.Close()
+			assert.Equal(t, http.StatusNotFound, resp.StatusCode, path)
+		}
+	}
+}
+
+func TestProviderMux_HandlePanics(t *testing.T) {
+	mux := gologin.NewProviderMux(gologin.ProviderMuxConfig{BaseURL: "https://example.com"})
+	provider := gologin.Provider{
+		StateHandler:    facebook.StateHandler,
+		LoginHandler:    facebook.LoginHandler,
+		CallbackHandler: facebook.CallbackHandler,
+	}
+	success := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {})
+	mux.Handle("facebook", provider, &oauth2.Config{}, success)
+	assert.Equal(t, "https://example.com/facebook/callback", mux.RedirectURL("facebook"))
+
+	// ProviderMux Handle assert that:
+	// - empty, nested, and duplicate provider names panic
+	for _, name := range []string{"", "facebook/v2", "facebook"} {
+		assert.Panics(t, func() { mux.Handle(name, provider, &oauth2.Config{}, success) }, name)
+	}
+}
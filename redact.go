@@ -0,0 +1,188 @@
+package gologin
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Redacted replaces redacted secrets.
+const Redacted = "REDACTED"
+
+// defaultRedactNames are the parameter, JSON field, and header names whose
+// values are always redacted.
+var defaultRedactNames = []string{
+	"access_token",
+	"appsecret_proof",
+	"authorization",
+	"client_secret",
+	"code",
+	"code_verifier",
+	"cookie",
+	"id_token",
+	"input_token",
+	"oauth_token",
+	"oauth_token_secret",
+	"oauth_verifier",
+	"password",
+	"proxy-authorization",
+	"refresh_token",
+	"set-cookie",
+	"token",
+}
+
+// secretHeaderWords are the lowercase words which mark a header name (e.g.
+// X-Shopify-Access-Token) as secret.
+var secretHeaderWords = []string{"token", "authorization", "secret"}
+
+// Redactor redacts secrets from provider request URLs, headers, and form or
+// JSON bodies. Values of the default names (e.g. client_secret,
+// access_token, refresh_token, id_token, code, and the Authorization header),
+// of headers whose names contain token, authorization, or secret, and of
+// names matching any Patterns are replaced with Redacted. The zero Redactor
+// redacts the default names.
+type Redactor struct {
+	// Patterns match additional names to redact.
+	Patterns []*regexp.Regexp
+}
+
+// redacts returns true if values of the name should be redacted.
+func (r Redactor) redacts(name string) bool {
+	for _, secret := range defaultRedactNames {
+		if strings.EqualFold(name, secret) {
+			return true
+		}
+	}
+	for _, pattern := range r.Patterns {
+		if pattern.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactsHeader returns true if values of the header name should be redacted.
+func (r Redactor) redactsHeader(name string) bool {
+	lower := strings.ToLower(name)
+	for _, word := range secretHeaderWords {
+		if strings.Contains(lower, word) {
+			return true
+		}
+	}
+	return r.redacts(name)
+}
+
+// RedactURL returns the URL with secret query parameters and any user
+// password redacted.
+func (r Redactor) RedactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return Redacted
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), Redacted)
+	}
+	u.RawQuery = r.redactForm(u.RawQuery)
+	return u.String()
+}
+
+// RedactHeader returns a copy of the header with secret values redacted.
+func (r Redactor) RedactHeader(header http.Header) http.Header {
+	redacted := make(http.Header, len(header))
+	for name, values := range header {
+		if r.redactsHeader(name) {
+			values = []string{Redacted}
+		}
+		redacted[name] = append([]string(nil), values...)
+	}
+	return redacted
+}
+
+// RedactBody returns the JSON or form body with secret values redacted. JSON
+// fields are redacted at any depth. Bodies which are neither valid JSON nor
+// form-encoded (per the Content-Type) are replaced with Redacted entirely,
+// since secrets cannot be found in them.
+func (r Redactor) RedactBody(contentType string, body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+	if json.Valid(body) {
+		return r.redactJSON(body)
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "application/x-www-form-urlencoded", "text/plain":
+		// providers like GitHub respond with form-encoded tokens by default
+		trimmed := bytes.TrimSpace(body)
+		if bytes.Contains(trimmed, []byte("=")) && trimmed[0] != '{' && trimmed[0] != '[' {
+			return r.redactForm(string(body))
+		}
+	}
+	return Redacted
+}
+
+// redactJSON returns the JSON with values of secret fields redacted.
+func (r Redactor) redactJSON(body []byte) string {
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return Redacted
+	}
+	switch value.(type) {
+	case map[string]interface{}, []interface{}:
+	default:
+		// a bare string may itself be a token
+		return Redacted
+	}
+	redacted, err := json.Marshal(r.redactValue(value))
+	if err != nil {
+		return Redacted
+	}
+	return string(redacted)
+}
+
+// redactValue redacts the values of secret fields of JSON objects.
+func (r Redactor) redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for name, field := range v {
+			if r.redacts(name) {
+				v[name] = Redacted
+			} else {
+				v[name] = r.redactValue(field)
+			}
+		}
+	case []interface{}:
+		for i, elem := range v {
+			v[i] = r.redactValue(elem)
+		}
+	}
+	return value
+}
+
+// redactForm returns the form-encoded query with values of secret
+// parameters redacted, preserving parameter order.
+func (r Redactor) redactForm(query string) string {
+	if query == "" {
+		return ""
+	}
+	pairs := strings.Split(query, "&")
+	for i, pair := range pairs {
+		name := pair
+		if j := strings.Index(pair, "="); j >= 0 {
+			name = pair[:j]
+		}
+		if unescaped, err := url.QueryUnescape(name); err == nil {
+			name = unescaped
+		}
+		if r.redacts(strings.TrimSpace(name)) {
+			pairs[i] = url.QueryEscape(name) + "=" + Redacted
+		}
+	}
+	return strings.Join(pairs, "&")
+}
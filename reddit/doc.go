@@ -0,0 +1,2 @@
+// Package reddit provides Reddit OAuth2 login and callback handlers.
+package reddit
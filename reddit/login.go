@@ -0,0 +1,140 @@
+package reddit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/internal"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"golang.org/x/oauth2"
+)
+
+// ProviderName is the provider name set in the ctx (see
+// gologin.ProviderFromContext) and the Profile.
+const ProviderName = "reddit"
+
+// defaultUserAgent identifies gologin if the Config has no UserAgent.
+const defaultUserAgent = "go:github.com/dghubble/gologin:v2"
+
+// Reddit login errors
+var (
+	ErrUnableToGetRedditUser = errors.New("reddit: unable to get Reddit User")
+)
+
+// Endpoint is Reddit's OAuth2 endpoint. Reddit requires HTTP Basic client
+// authentication.
+var Endpoint = oauth2.Endpoint{
+	AuthURL:   "https://www.reddit.com/api/v1/authorize",
+	TokenURL:  "https://www.reddit.com/api/v1/access_token",
+	AuthStyle: oauth2.AuthStyleInHeader,
+}
+
+// Permanent is an AuthCodeOption which sets duration=permanent so Reddit
+// returns a refresh token. By default, Reddit tokens are temporary.
+var Permanent = oauth2.SetAuthURLParam("duration", "permanent")
+
+// Config configures Reddit requests.
+type Config struct {
+	// UserAgent is the User-Agent sent with token exchanges and API requests.
+	// Reddit rate limits generic user agents and asks for the form
+	// "<platform>:<app ID>:<version> (by /u/<username>)". If empty, a gologin
+	// user agent is used.
+	UserAgent string
+}
+
+// StateHandler checks for a state cookie. If found, the state value is read
+// and added to the ctx. Otherwise, a non-guessable value is added to the ctx
+// and to a (short-lived) state cookie issued to the requester.
+//
+// Implements OAuth 2 RFC 6749 10.12 CSRF Protection. If you wish to issue
+// state params differently, write a http.Handler which sets the ctx state,
+// using oauth2 WithState(ctx, state) since it is required by LoginHandler
+// and CallbackHandler.
+func StateHandler(config gologin.CookieConfig, success http.Handler) http.Handler {
+	return oauth2Login.StateHandler(config, success)
+}
+
+// LoginHandler handles Reddit login requests by reading the state value from
+// the ctx and redirecting requests to the AuthURL with that state value.
+// Any AuthCodeOptions are added to the AuthURL. Pass Permanent to get a
+// refresh token.
+//
+// Scopes should include "identity" to get the Reddit User.
+func LoginHandler(config *oauth2.Config, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	return oauth2Login.LoginHandler(config, failure, opts...)
+}
+
+// CallbackHandler handles Reddit redirection URI requests and adds the Reddit
+// access token and User to the ctx. If authentication succeeds, handling
+// delegates to the success handler, otherwise to the failure handler.
+// Any AuthCodeOptions are sent with the token exchange.
+func CallbackHandler(config *oauth2.Config, success, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	return CallbackHandlerWithConfig(config, Config{}, success, failure, opts...)
+}
+
+// CallbackHandlerWithConfig handles Reddit redirection URI requests like
+// CallbackHandler, but sends the Config UserAgent with the token exchange and
+// User request.
+func CallbackHandlerWithConfig(config *oauth2.Config, redditConfig Config, success, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	userAgent := redditConfig.UserAgent
+	if userAgent == "" {
+		userAgent = defaultUserAgent
+	}
+	success = redditHandler(config, success, failure)
+	callback := oauth2Login.CallbackHandler(config, success, failure, opts...)
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		ctx = context.WithValue(ctx, oauth2.HTTPClient, userAgentClient(internal.ContextClient(ctx), userAgent))
+		callback.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// redditHandler is a http.Handler that gets the OAuth2 Token from the ctx to
+// get the corresponding Reddit User. If successful, the User is added to the
+// ctx and the success handler is called. Otherwise, the failure handler is
+// called.
+func redditHandler(config *oauth2.Config, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		httpClient := internal.OAuth2Client(ctx, config, token)
+		user, resp, err := newClient(httpClient).Me(ctx)
+		err = validateResponse(user, resp, err)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		ctx = WithUser(ctx, user)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// validateResponse returns an error if the given Reddit User, raw
+// http.Response, or error are unexpected. Returns nil if they are valid, or a
+// *gologin.Error which preserves the cause and status code.
+func validateResponse(user *User, resp *http.Response, err error) error {
+	var status int
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	if err != nil || status != http.StatusOK {
+		return &gologin.Error{Provider: "reddit", Op: "get user", StatusCode: status, Err: err, Kind: ErrUnableToGetRedditUser}
+	}
+	if user == nil || user.ID == "" {
+		return &gologin.Error{Provider: "reddit", Op: "get user", StatusCode: status, Kind: ErrUnableToGetRedditUser}
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package reddit
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/dghubble/gologin/testutils"
+)
+
+// newRedditTestServer returns a new httptest.Server which mocks the Reddit
+// access_token and /api/v1/me endpoints and a client which proxies requests
+// to the server. Like Reddit, the endpoints reject requests without the
+// userAgent (429 Too Many Requests) and the access_token endpoint requires
+// HTTP Basic client authentication. The /api/v1/me endpoint responds with the
+// given json data. The caller must close the server.
+func newRedditTestServer(userAgent, jsonData string) (*http.Client, *httptest.Server) {
+	client, mux, server := testutils.TestServer()
+	mux.HandleFunc("/api/v1/access_token", func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		if r.UserAgent() != userAgent {
+			http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+			return
+		}
+		if !ok || username != "client_id" || password != "client_secret" || r.PostFormValue("client_secret") != "" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprintf(w, `{"message": "Unauthorized", "error": 401}`)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token": "any-token", "token_type": "bearer", "expires_in": 86400, "refresh_token": "any-refresh", "scope": "identity"}`)
+	})
+	mux.HandleFunc("/api/v1/me", func(w http.ResponseWriter, r *http.Request) {
+		if r.UserAgent() != userAgent {
+			http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer any-token" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, jsonData)
+	})
+	return client, server
+}
@@ -0,0 +1,65 @@
+package reddit
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/dghubble/gologin/internal/jsonclient"
+)
+
+const redditAPI = "https://oauth.reddit.com/api/v1/"
+
+// User is a Reddit account.
+type User struct {
+	ID               string  `json:"id"`
+	Name             string  `json:"name"`
+	IconImg          string  `json:"icon_img"`
+	HasVerifiedEmail bool    `json:"has_verified_email"`
+	CreatedUTC       float64 `json:"created_utc"`
+}
+
+// client is a Reddit client for obtaining a User.
+type client struct {
+	json *jsonclient.Client
+}
+
+// newClient returns a new Reddit client.
+func newClient(httpClient *http.Client) *client {
+	return &client{
+		json: jsonclient.New(httpClient, redditAPI),
+	}
+}
+
+// Me gets the current Reddit User (requires the identity scope).
+// https://www.reddit.com/dev/api/oauth#GET_api_v1_me
+func (c *client) Me(ctx context.Context) (*User, *http.Response, error) {
+	user := new(User)
+	resp, err := c.json.Get(ctx, "me", nil, user, nil)
+	return user, resp, err
+}
+
+// userAgentClient returns a copy of the http.Client which sets the
+// User-Agent header of requests.
+func userAgentClient(client *http.Client, userAgent string) *http.Client {
+	c := *client
+	c.Transport = &userAgentTransport{base: client.Transport, userAgent: userAgent}
+	return &c
+}
+
+// userAgentTransport is a http.RoundTripper which sets the User-Agent request
+// header.
+type userAgentTransport struct {
+	base      http.RoundTripper
+	userAgent string
+}
+
+func (t *userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	// RoundTrippers must not modify the request
+	req = req.Clone(req.Context())
+	req.Header.Set("User-Agent", t.userAgent)
+	return base.RoundTrip(req)
+}
@@ -0,0 +1,114 @@
+package gologin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ErrUnsafeRedirect is the error when a redirect target is not a safe path
+// or a URL on an allowed host.
+var ErrUnsafeRedirect = errors.New("gologin: unsafe redirect target")
+
+const defaultRedirectPath = "/"
+
+// RedirectOptions configures RedirectHandler.
+type RedirectOptions struct {
+	// Path is the fixed redirect path, used when Target is nil or returns no
+	// target. Defaults to "/".
+	Path string
+	// Target returns the redirect target from the ctx, if any (e.g. oauth2
+	// ReturnURLFromContext). Errors or empty targets fall back to Path.
+	Target func(ctx context.Context) (string, error)
+	// AllowedHosts lists hosts to which absolute http(s) targets may point.
+	// Relative paths are always allowed.
+	AllowedHosts []string
+	// Before is called before redirecting (e.g. to issue a session). If it
+	// returns an error, the Failure handler is called instead.
+	Before func(w http.ResponseWriter, req *http.Request) error
+	// Failure handles unsafe targets and Before errors. Defaults to
+	// DefaultFailureHandler.
+	Failure http.Handler
+}
+
+// RedirectHandler returns a success handler which redirects (302) to the
+// Target from the ctx, if any, or to the fixed Path otherwise. Targets are
+// checked with SafeRedirectPath; unsafe targets call the Failure handler with
+// ErrUnsafeRedirect rather than redirecting.
+func RedirectHandler(opts RedirectOptions) http.Handler {
+	failure := opts.Failure
+	if failure == nil {
+		failure = DefaultFailureHandler
+	}
+	path := opts.Path
+	if path == "" {
+		path = defaultRedirectPath
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		target := path
+		if opts.Target != nil {
+			if t, err := opts.Target(ctx); err == nil && t != "" {
+				target = t
+			}
+		}
+		if !SafeRedirectPath(target, opts.AllowedHosts) {
+			ctx = WithError(ctx, ErrUnsafeRedirect)
+			ctx = WithStatusCode(ctx, http.StatusBadRequest)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		if opts.Before != nil {
+			if err := opts.Before(w, req); err != nil {
+				ctx = WithError(ctx, err)
+				ctx = WithStatusCode(ctx, http.StatusInternalServerError)
+				failure.ServeHTTP(w, req.WithContext(ctx))
+				return
+			}
+		}
+		http.Redirect(w, req, target, http.StatusFound)
+	}
+	return http.HandlerFunc(fn)
+}
+
+// SafeRedirectPath returns true if the target is safe to redirect to: a
+// relative path (e.g. "/settings") or an absolute http(s) URL on one of the
+// allowed hosts. Scheme-relative URLs (e.g. "//evil.com"), backslashes, and
+// control characters (e.g. CR/LF) are rejected.
+func SafeRedirectPath(target string, allowedHosts []string) bool {
+	for _, r := range target {
+		// browsers ignore some control characters (e.g. "/\t/example.com")
+		if r < 0x20 || r == 0x7f {
+			return false
+		}
+	}
+	// browsers treat "\" like "/" (e.g. "/\example.com" is "//example.com")
+	if strings.Contains(target, "\\") {
+		return false
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		return false
+	}
+	if strings.HasPrefix(target, "/") {
+		// reject scheme-relative URLs
+		if strings.HasPrefix(target, "//") {
+			return false
+		}
+		return u.Scheme == "" && u.Host == ""
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return false
+	}
+	if u.User != nil {
+		return false
+	}
+	for _, host := range allowedHosts {
+		if host != "" && strings.EqualFold(u.Hostname(), host) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,63 @@
+package salesforce
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dghubble/gologin"
+)
+
+// unexported key type prevents collisions
+type key int
+
+const (
+	userKey key = iota
+	instanceURLKey
+)
+
+// WithUser returns a copy of ctx that stores the Salesforce User and its gologin
+// Profile.
+func WithUser(ctx context.Context, user *User) context.Context {
+	ctx = gologin.WithProfile(ctx, newProfile(user))
+	return context.WithValue(ctx, userKey, user)
+}
+
+// UserFromContext returns the Salesforce User from the ctx.
+func UserFromContext(ctx context.Context) (*User, error) {
+	user, ok := ctx.Value(userKey).(*User)
+	if !ok {
+		return nil, fmt.Errorf("salesforce: Context missing Salesforce User")
+	}
+	return user, nil
+}
+
+// newProfile returns the gologin Profile of the Salesforce User.
+func newProfile(user *User) *gologin.Profile {
+	if user == nil {
+		return nil
+	}
+	return &gologin.Profile{
+		Provider: ProviderName,
+		ID:       user.UserID,
+		Email:    user.Email,
+		Name:     gologin.DisplayName(user.DisplayName, user.Username),
+		Raw:      gologin.ProfileRaw(user, "user_id", "email", "display_name"),
+	}
+}
+
+// WithInstanceURL returns a copy of ctx that stores the Salesforce instance
+// URL.
+func WithInstanceURL(ctx context.Context, instanceURL string) context.Context {
+	return context.WithValue(ctx, instanceURLKey, instanceURL)
+}
+
+// InstanceURLFromContext returns the Salesforce instance URL (e.g.
+// "https://yourInstance.my.salesforce.com") from the ctx. Salesforce REST API
+// requests with the Token must be made to the instance URL.
+func InstanceURLFromContext(ctx context.Context) (string, error) {
+	instanceURL, ok := ctx.Value(instanceURLKey).(string)
+	if !ok {
+		return "", fmt.Errorf("salesforce: Context missing Salesforce instance URL")
+	}
+	return instanceURL, nil
+}
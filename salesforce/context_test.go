@@ -0,0 +1,39 @@
+package salesforce
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContextUser(t *testing.T) {
+	expectedUser := &User{UserID: "0055e000001AbCdAAK", Username: "ada@acme.com"}
+	ctx := WithUser(context.Background(), expectedUser)
+	user, err := UserFromContext(ctx)
+	assert.Equal(t, expectedUser, user)
+	assert.Nil(t, err)
+}
+
+func TestContextUser_Error(t *testing.T) {
+	user, err := UserFromContext(context.Background())
+	assert.Nil(t, user)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "salesforce: Context missing Salesforce User", err.Error())
+	}
+}
+
+func TestContextInstanceURL(t *testing.T) {
+	ctx := WithInstanceURL(context.Background(), "https://acme.my.salesforce.com")
+	instanceURL, err := InstanceURLFromContext(ctx)
+	assert.Equal(t, "https://acme.my.salesforce.com", instanceURL)
+	assert.Nil(t, err)
+}
+
+func TestContextInstanceURL_Error(t *testing.T) {
+	instanceURL, err := InstanceURLFromContext(context.Background())
+	assert.Equal(t, "", instanceURL)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "salesforce: Context missing Salesforce instance URL", err.Error())
+	}
+}
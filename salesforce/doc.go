@@ -0,0 +1,2 @@
+// Package salesforce provides Salesforce OAuth2 login and callback handlers.
+package salesforce
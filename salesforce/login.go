@@ -0,0 +1,202 @@
+package salesforce
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/internal"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"golang.org/x/oauth2"
+)
+
+// ProviderName is the provider name set in the ctx (see
+// gologin.ProviderFromContext) and the Profile.
+const ProviderName = "salesforce"
+
+// Salesforce login URLs
+const (
+	ProductionURL = "https://login.salesforce.com"
+	SandboxURL    = "https://test.salesforce.com"
+)
+
+// Salesforce login errors
+var (
+	ErrUnableToGetSalesforceUser = errors.New("salesforce: unable to get Salesforce User")
+	ErrMissingInstanceURL        = errors.New("salesforce: Token missing instance_url")
+	ErrInvalidIdentityURL        = errors.New("salesforce: Token id is not a trusted identity URL")
+)
+
+// Endpoint is Salesforce's OAuth2 endpoint for production orgs.
+var Endpoint = NewEndpoint(ProductionURL)
+
+// SandboxEndpoint is Salesforce's OAuth2 endpoint for sandbox orgs.
+var SandboxEndpoint = NewEndpoint(SandboxURL)
+
+// NewEndpoint returns the OAuth2 endpoint of the Salesforce login URL (e.g.
+// SandboxURL or a My Domain URL such as "https://acme.my.salesforce.com").
+// If the loginURL is empty, ProductionURL is used. Panics if the loginURL is
+// not an absolute URL.
+func NewEndpoint(loginURL string) oauth2.Endpoint {
+	loginURL = mustNormalizeLoginURL(loginURL)
+	return oauth2.Endpoint{
+		AuthURL:  loginURL + "/services/oauth2/authorize",
+		TokenURL: loginURL + "/services/oauth2/token",
+	}
+}
+
+// Config configures Salesforce login.
+type Config struct {
+	// LoginURL is the Salesforce login URL (e.g. SandboxURL or a My Domain
+	// URL). If empty, ProductionURL is used. The oauth2 Config Endpoint should
+	// be NewEndpoint(LoginURL).
+	LoginURL string
+	// IdentityHosts are additional hosts the token response id (identity URL)
+	// may be on. The LoginURL host and the production and sandbox login hosts
+	// are always trusted.
+	IdentityHosts []string
+}
+
+// mustNormalizeLoginURL returns the loginURL (or the default) without a
+// trailing slash. It panics if the loginURL is invalid.
+func mustNormalizeLoginURL(loginURL string) string {
+	if loginURL == "" {
+		return ProductionURL
+	}
+	u, err := url.Parse(loginURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		panic("salesforce: invalid Config LoginURL " + loginURL)
+	}
+	return strings.TrimRight(u.String(), "/")
+}
+
+// identityHosts returns the set of hosts trusted to serve identity URLs.
+func (c Config) identityHosts() map[string]bool {
+	hosts := map[string]bool{
+		"login.salesforce.com": true,
+		"test.salesforce.com":  true,
+	}
+	u, _ := url.Parse(mustNormalizeLoginURL(c.LoginURL))
+	hosts[strings.ToLower(u.Host)] = true
+	for _, host := range c.IdentityHosts {
+		hosts[strings.ToLower(host)] = true
+	}
+	return hosts
+}
+
+// StateHandler checks for a state cookie. If found, the state value is read
+// and added to the ctx. Otherwise, a non-guessable value is added to the ctx
+// and to a (short-lived) state cookie issued to the requester.
+//
+// Implements OAuth 2 RFC 6749 10.12 CSRF Protection. If you wish to issue
+// state params differently, write a http.Handler which sets the ctx state,
+// using oauth2 WithState(ctx, state) since it is required by LoginHandler
+// and CallbackHandler.
+func StateHandler(config gologin.CookieConfig, success http.Handler) http.Handler {
+	return oauth2Login.StateHandler(config, success)
+}
+
+// LoginHandler handles Salesforce login requests by reading the state value
+// from the ctx and redirecting requests to the AuthURL with that state value.
+// Any AuthCodeOptions are added to the AuthURL.
+//
+// Scopes should include "id" (or "openid") to get the Salesforce User.
+func LoginHandler(config *oauth2.Config, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	return oauth2Login.LoginHandler(config, failure, opts...)
+}
+
+// CallbackHandler handles Salesforce production org redirection URI requests
+// and adds the Salesforce access token, instance URL, and User to the ctx. If
+// authentication succeeds, handling delegates to the success handler,
+// otherwise to the failure handler.
+// Any AuthCodeOptions are sent with the token exchange.
+func CallbackHandler(config *oauth2.Config, success, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	return CallbackHandlerWithConfig(config, Config{}, success, failure, opts...)
+}
+
+// CallbackHandlerWithConfig handles Salesforce redirection URI requests like
+// CallbackHandler, but trusts identity URLs on the Config LoginURL host and
+// IdentityHosts. Panics if the LoginURL is invalid.
+func CallbackHandlerWithConfig(config *oauth2.Config, sfConfig Config, success, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	success = salesforceHandler(config, sfConfig, success, failure)
+	return oauth2Login.CallbackHandler(config, success, failure, opts...)
+}
+
+// salesforceHandler is a http.Handler that gets the OAuth2 Token from the ctx
+// to get the corresponding Salesforce User from the Token's identity URL (id).
+// If successful, the instance URL and User are added to the ctx and the
+// success handler is called. Otherwise, the failure handler is called.
+//
+// Tokens without an instance_url fail with ErrMissingInstanceURL. Identity
+// URLs which are not https URLs on a trusted host fail with
+// ErrInvalidIdentityURL (and are not requested).
+func salesforceHandler(config *oauth2.Config, sfConfig Config, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	identityHosts := sfConfig.identityHosts()
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		instanceURL, _ := token.Extra("instance_url").(string)
+		if instanceURL == "" {
+			ctx = gologin.WithError(ctx, ErrMissingInstanceURL)
+			ctx = gologin.WithStatusCode(ctx, http.StatusBadGateway)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		identityURL, _ := token.Extra("id").(string)
+		if !validIdentityURL(identityURL, identityHosts) {
+			ctx = gologin.WithError(ctx, ErrInvalidIdentityURL)
+			ctx = gologin.WithStatusCode(ctx, http.StatusBadGateway)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		httpClient := internal.OAuth2Client(ctx, config, token)
+		user, resp, err := newClient(httpClient).Identity(ctx, identityURL)
+		err = validateResponse(user, resp, err)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		ctx = WithInstanceURL(ctx, instanceURL)
+		ctx = WithUser(ctx, user)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// validIdentityURL returns true if the identityURL is an https URL (without
+// credentials) on one of the trusted hosts.
+func validIdentityURL(identityURL string, hosts map[string]bool) bool {
+	u, err := url.Parse(identityURL)
+	if err != nil || u.Scheme != "https" || u.User != nil {
+		return false
+	}
+	return hosts[strings.ToLower(u.Host)]
+}
+
+// validateResponse returns an error if the given Salesforce User, raw
+// http.Response, or error are unexpected. Returns nil if they are valid, or a
+// *gologin.Error which preserves the cause and status code.
+func validateResponse(user *User, resp *http.Response, err error) error {
+	var status int
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	if err != nil || status != http.StatusOK {
+		return &gologin.Error{Provider: "salesforce", Op: "get user", StatusCode: status, Err: err, Kind: ErrUnableToGetSalesforceUser}
+	}
+	if user == nil || user.UserID == "" {
+		return &gologin.Error{Provider: "salesforce", Op: "get user", StatusCode: status, Kind: ErrUnableToGetSalesforceUser}
+	}
+	return nil
+}
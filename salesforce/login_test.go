@@ -0,0 +1,259 @@
+package salesforce
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dghubble/gologin"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func testConfig(endpoint oauth2.Endpoint) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     "client_id",
+		ClientSecret: "client_secret",
+		RedirectURL:  "https://example.com/salesforce/callback",
+		Endpoint:     endpoint,
+		Scopes:       []string{"id", "api"},
+	}
+}
+
+func TestNewEndpoint(t *testing.T) {
+	cases := []struct {
+		loginURL string
+		authURL  string
+		tokenURL string
+	}{
+		{"", "https://login.salesforce.com/services/oauth2/authorize", "https://login.salesforce.com/services/oauth2/token"},
+		{SandboxURL, "https://test.salesforce.com/services/oauth2/authorize", "https://test.salesforce.com/services/oauth2/token"},
+		{"https://acme.my.salesforce.com/", "https://acme.my.salesforce.com/services/oauth2/authorize", "https://acme.my.salesforce.com/services/oauth2/token"},
+	}
+	for _, c := range cases {
+		endpoint := NewEndpoint(c.loginURL)
+		assert.Equal(t, c.authURL, endpoint.AuthURL)
+		assert.Equal(t, c.tokenURL, endpoint.TokenURL)
+	}
+	assert.Equal(t, NewEndpoint(ProductionURL), Endpoint)
+	assert.Equal(t, NewEndpoint(SandboxURL), SandboxEndpoint)
+	assert.Panics(t, func() { NewEndpoint("login.salesforce.com") })
+	assert.Panics(t, func() { CallbackHandlerWithConfig(testConfig(Endpoint), Config{LoginURL: "/login"}, nil, nil) })
+}
+
+func TestCallbackHandler(t *testing.T) {
+	proxyClient, server := newSalesforceTestServer(testProductionTokenJSON, testProductionIdentityJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithState(ctx, "d4e5f6")
+
+	success := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "any-token", token.AccessToken)
+		}
+		instanceURL, err := InstanceURLFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "https://acme.my.salesforce.com", instanceURL)
+		}
+		user, err := UserFromContext(ctx)
+		if assert.Nil(t, err) {
+			expectedUser := &User{UserID: "0055e000001AbCdAAK", OrganizationID: "00D5e000000XyZaEAK", Username: "ada@acme.com", DisplayName: "Ada Lovelace", Email: "ada@acme.com"}
+			assert.Equal(t, expectedUser, user)
+		}
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// CallbackHandler for a production org, assert that:
+	// - the Salesforce User is obtained from the token response identity URL
+	// - success handler is called with the Token, instance URL, and User in the ctx
+	callbackHandler := CallbackHandler(testConfig(Endpoint), http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestCallbackHandlerWithConfig_Sandbox(t *testing.T) {
+	proxyClient, server := newSalesforceTestServer(testSandboxTokenJSON, testSandboxIdentityJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithState(ctx, "d4e5f6")
+
+	success := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		instanceURL, err := InstanceURLFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "https://acme--dev.sandbox.my.salesforce.com", instanceURL)
+		}
+		user, err := UserFromContext(ctx)
+		if assert.Nil(t, err) {
+			expectedUser := &User{UserID: "0057x000000SbXyAAK", OrganizationID: "00D7x000000DeVaEAK", Username: "ada@acme.com.dev", DisplayName: "Ada Lovelace", Email: "ada@acme.com"}
+			assert.Equal(t, expectedUser, user)
+		}
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// CallbackHandlerWithConfig for a sandbox org, assert that:
+	// - the test.salesforce.com identity URL is trusted
+	// - success handler is called with the sandbox instance URL and User in the ctx
+	callbackHandler := CallbackHandlerWithConfig(testConfig(SandboxEndpoint), Config{LoginURL: SandboxURL}, http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestSalesforceHandler_InvalidIdentityURL(t *testing.T) {
+	proxyClient, server := newSalesforceTestServer(`{}`, testProductionIdentityJSON)
+	defer server.Close()
+	identityURLs := []string{
+		"",
+		"http://login.salesforce.com/id/00D5e000000XyZaEAK/0055e000001AbCdAAK",
+		"https://169.254.169.254/id/00D5e000000XyZaEAK/0055e000001AbCdAAK",
+		"https://login.salesforce.com.example.com/id/00D5e000000XyZaEAK/0055e000001AbCdAAK",
+		"https://login.salesforce.com@example.com/id/00D5e000000XyZaEAK/0055e000001AbCdAAK",
+		"https://login.salesforce.com:8443/id/00D5e000000XyZaEAK/0055e000001AbCdAAK",
+	}
+	for _, identityURL := range identityURLs {
+		token := (&oauth2.Token{AccessToken: "any-token"}).WithExtra(map[string]interface{}{
+			"instance_url": "https://acme.my.salesforce.com",
+			"id":           identityURL,
+		})
+		// oauth2 Client will use the proxy client's base Transport
+		ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+		ctx = oauth2Login.WithToken(ctx, token)
+
+		success := testutils.AssertSuccessNotCalled(t)
+		failure := func(w http.ResponseWriter, req *http.Request) {
+			assert.Equal(t, ErrInvalidIdentityURL, gologin.ErrorFromContext(req.Context()))
+			fmt.Fprintf(w, "failure handler called")
+		}
+
+		// SalesforceHandler with an untrusted identity URL, assert that:
+		// - failure handler is called with ErrInvalidIdentityURL
+		salesforceHandler := salesforceHandler(testConfig(Endpoint), Config{}, success, http.HandlerFunc(failure))
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		salesforceHandler.ServeHTTP(w, req.WithContext(ctx))
+		assert.Equal(t, "failure handler called", w.Body.String(), identityURL)
+	}
+}
+
+func TestSalesforceHandler_IdentityHosts(t *testing.T) {
+	proxyClient, server := newSalesforceTestServer(`{}`, testProductionIdentityJSON)
+	defer server.Close()
+	token := (&oauth2.Token{AccessToken: "any-token"}).WithExtra(map[string]interface{}{
+		"instance_url": "https://acme.my.salesforce.com",
+		"id":           "https://acme.my.salesforce.com/id/00D5e000000XyZaEAK/0055e000001AbCdAAK",
+	})
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, token)
+
+	success := func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// SalesforceHandler with an identity URL on a Config IdentityHosts host,
+	// assert that:
+	// - success handler is called
+	salesforceHandler := salesforceHandler(testConfig(Endpoint), Config{IdentityHosts: []string{"ACME.my.salesforce.com"}}, http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	salesforceHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestSalesforceHandler_MissingInstanceURL(t *testing.T) {
+	token := (&oauth2.Token{AccessToken: "any-token"}).WithExtra(map[string]interface{}{
+		"id": "https://login.salesforce.com/id/00D5e000000XyZaEAK/0055e000001AbCdAAK",
+	})
+	ctx := oauth2Login.WithToken(context.Background(), token)
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, ErrMissingInstanceURL, gologin.ErrorFromContext(req.Context()))
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// SalesforceHandler with a Token without an instance_url, assert that:
+	// - failure handler is called with ErrMissingInstanceURL
+	salesforceHandler := salesforceHandler(testConfig(Endpoint), Config{}, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	salesforceHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestSalesforceHandler_MissingCtxToken(t *testing.T) {
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "oauth2: Context missing Token", err.Error())
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// SalesforceHandler called without Token in ctx, assert that:
+	// - failure handler is called
+	// - error about ctx missing token is added to the failure handler ctx
+	salesforceHandler := salesforceHandler(testConfig(Endpoint), Config{}, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	salesforceHandler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestSalesforceHandler_ErrorGettingUser(t *testing.T) {
+	proxyClient, server := testutils.NewErrorServer("Salesforce Service Down", http.StatusInternalServerError)
+	defer server.Close()
+	token := (&oauth2.Token{AccessToken: "any-token"}).WithExtra(map[string]interface{}{
+		"instance_url": "https://acme.my.salesforce.com",
+		"id":           "https://login.salesforce.com/id/00D5e000000XyZaEAK/0055e000001AbCdAAK",
+	})
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, token)
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.True(t, errors.Is(err, ErrUnableToGetSalesforceUser))
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// SalesforceHandler cannot get Salesforce User, assert that:
+	// - failure handler is called
+	// - error cannot get Salesforce User added to the failure handler ctx
+	salesforceHandler := salesforceHandler(testConfig(Endpoint), Config{}, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	salesforceHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestValidateResponse(t *testing.T) {
+	validUser := &User{UserID: "0055e000001AbCdAAK"}
+	validResponse := &http.Response{StatusCode: 200}
+	invalidResponse := &http.Response{StatusCode: 403}
+	assert.Equal(t, nil, validateResponse(validUser, validResponse, nil))
+	assert.True(t, errors.Is(validateResponse(validUser, validResponse, fmt.Errorf("Server error")), ErrUnableToGetSalesforceUser))
+	assert.True(t, errors.Is(validateResponse(validUser, invalidResponse, nil), ErrUnableToGetSalesforceUser))
+	assert.True(t, errors.Is(validateResponse(&User{}, validResponse, nil), ErrUnableToGetSalesforceUser))
+	assert.True(t, errors.Is(validateResponse(nil, validResponse, nil), ErrUnableToGetSalesforceUser))
+}
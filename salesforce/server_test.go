@@ -0,0 +1,45 @@
+package salesforce
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/dghubble/gologin/testutils"
+)
+
+// Production org token and identity responses.
+const (
+	testProductionTokenJSON    = `{"access_token": "any-token", "token_type": "Bearer", "refresh_token": "any-refresh", "scope": "id api refresh_token", "instance_url": "https://acme.my.salesforce.com", "id": "https://login.salesforce.com/id/00D5e000000XyZaEAK/0055e000001AbCdAAK", "issued_at": "1700000000000", "signature": "c2lnbmF0dXJl"}`
+	testProductionIdentityJSON = `{"id": "https://login.salesforce.com/id/00D5e000000XyZaEAK/0055e000001AbCdAAK", "user_id": "0055e000001AbCdAAK", "organization_id": "00D5e000000XyZaEAK", "username": "ada@acme.com", "display_name": "Ada Lovelace", "email": "ada@acme.com", "active": true, "user_type": "STANDARD"}`
+)
+
+// Sandbox org token and identity responses.
+const (
+	testSandboxTokenJSON    = `{"access_token": "any-token", "token_type": "Bearer", "scope": "id api", "instance_url": "https://acme--dev.sandbox.my.salesforce.com", "id": "https://test.salesforce.com/id/00D7x000000DeVaEAK/0057x000000SbXyAAK", "issued_at": "1700000000000", "signature": "c2lnbmF0dXJl"}`
+	testSandboxIdentityJSON = `{"id": "https://test.salesforce.com/id/00D7x000000DeVaEAK/0057x000000SbXyAAK", "user_id": "0057x000000SbXyAAK", "organization_id": "00D7x000000DeVaEAK", "username": "ada@acme.com.dev", "display_name": "Ada Lovelace", "email": "ada@acme.com", "active": true, "user_type": "STANDARD"}`
+)
+
+// newSalesforceTestServer returns a new httptest.Server which mocks the
+// Salesforce token endpoint and identity URLs and a client which proxies
+// requests to the server. The token endpoint responds with the token json
+// data and any identity URL with the identity json data. The caller must
+// close the server.
+func newSalesforceTestServer(tokenJSON, identityJSON string) (*http.Client, *httptest.Server) {
+	client, mux, server := testutils.TestServer()
+	mux.HandleFunc("/services/oauth2/token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, tokenJSON)
+	})
+	mux.HandleFunc("/id/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer any-token" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprintf(w, `[{"message": "Bad_OAuth_Token", "errorCode": "Bad_OAuth_Token"}]`)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, identityJSON)
+	})
+	return client, server
+}
@@ -0,0 +1,38 @@
+package salesforce
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/dghubble/gologin/internal/jsonclient"
+)
+
+// User is a Salesforce user from the identity URL.
+type User struct {
+	UserID         string `json:"user_id"`
+	OrganizationID string `json:"organization_id"`
+	Username       string `json:"username"`
+	DisplayName    string `json:"display_name"`
+	Email          string `json:"email"`
+}
+
+// client is a Salesforce client for obtaining a User.
+type client struct {
+	json *jsonclient.Client
+}
+
+// newClient returns a new Salesforce client for absolute identity URLs.
+func newClient(httpClient *http.Client) *client {
+	return &client{
+		json: jsonclient.New(httpClient, ""),
+	}
+}
+
+// Identity gets the Salesforce User from the (validated) identity URL of the
+// token response.
+// https://help.salesforce.com/s/articleView?id=sf.remoteaccess_using_openid.htm
+func (c *client) Identity(ctx context.Context, identityURL string) (*User, *http.Response, error) {
+	user := new(User)
+	resp, err := c.json.Get(ctx, identityURL, nil, user, nil)
+	return user, resp, err
+}
@@ -0,0 +1,17 @@
+/*
+Package sessionlogin provides a success handler which issues a session after a
+gologin provider login, plus handlers to require and destroy sessions.
+
+IssueSession reads the user ID and claims of the gologin Profile (or of an
+Extractor), saves them with a SessionStore, and redirects. NewSessionsStore
+stores sessions with a github.com/dghubble/sessions Store (e.g. signed
+cookies).
+
+	store := sessionlogin.NewSessionsStore(sessions.NewCookieStore([]byte(sessionSecret), nil), "example-app")
+	mux.Handle("/github/callback", github.StateHandler(stateConfig, github.CallbackHandler(oauth2Config, sessionlogin.IssueSession(store, nil), nil)))
+	mux.Handle("/profile", sessionlogin.RequireSession(store, "/", http.HandlerFunc(profileHandler)))
+	mux.Handle("/logout", sessionlogin.LogoutHandler(store, "/"))
+
+Protected handlers read the Session with SessionFromContext.
+*/
+package sessionlogin
@@ -0,0 +1,120 @@
+package sessionlogin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/dghubble/gologin"
+)
+
+// ErrMissingUserID is the failure handler error when an Extractor returns an
+// empty user ID.
+var ErrMissingUserID = errors.New("sessionlogin: missing user ID")
+
+// Extractor returns the user ID and session claims of a successful login
+// from the ctx.
+type Extractor func(ctx context.Context) (userID string, claims map[string]interface{}, err error)
+
+// ProfileExtractor is an Extractor of the gologin Profile, which provider
+// handlers add to the ctx. The user ID is the Profile ID and the claims are
+// the non-empty "provider", "email", and "name".
+func ProfileExtractor(ctx context.Context) (string, map[string]interface{}, error) {
+	profile, err := gologin.ProfileFromContext(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+	claims := map[string]interface{}{"provider": profile.Provider}
+	if profile.Email != "" {
+		claims["email"] = profile.Email
+	}
+	if profile.Name != "" {
+		claims["name"] = profile.Name
+	}
+	return profile.ID, claims, nil
+}
+
+// Options configure IssueSession.
+type Options struct {
+	// Extractor returns the session user ID and claims (default
+	// ProfileExtractor).
+	Extractor Extractor
+	// RedirectURL is where users are redirected after a session is issued
+	// (default "/").
+	RedirectURL string
+	// Failure handles extractor and store errors, which are added to the ctx
+	// (default gologin.DefaultFailureHandler).
+	Failure http.Handler
+}
+
+// IssueSession returns a login success handler which saves a Session of the
+// extracted user ID and claims in the store, then redirects to the
+// RedirectURL. If the extractor or store fail, no session is written and the
+// error is added to the ctx of the failure handler. Nil Options use the
+// defaults.
+func IssueSession(store SessionStore, opts *Options) http.Handler {
+	if opts == nil {
+		opts = &Options{}
+	}
+	extractor := opts.Extractor
+	if extractor == nil {
+		extractor = ProfileExtractor
+	}
+	redirectURL := opts.RedirectURL
+	if redirectURL == "" {
+		redirectURL = "/"
+	}
+	failure := opts.Failure
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		userID, claims, err := extractor(ctx)
+		if err == nil && userID == "" {
+			err = ErrMissingUserID
+		}
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		err = store.Save(w, &Session{UserID: userID, Claims: claims})
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		http.Redirect(w, req, redirectURL, http.StatusFound)
+	}
+	return http.HandlerFunc(fn)
+}
+
+// RequireSession adds the request's Session in the store to the ctx and calls
+// the next http.Handler. Requests without a Session are redirected to the
+// loginURL.
+func RequireSession(store SessionStore, loginURL string, next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		session, err := store.Get(req)
+		if err != nil {
+			http.Redirect(w, req, loginURL, http.StatusFound)
+			return
+		}
+		ctx := WithSession(req.Context(), session)
+		next.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// LogoutHandler destroys the Session in the store on POST requests, then
+// redirects to the redirectURL. Other methods only redirect so links cannot
+// log users out.
+func LogoutHandler(store SessionStore, redirectURL string) http.Handler {
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		if req.Method == "POST" {
+			store.Destroy(w)
+		}
+		http.Redirect(w, req, redirectURL, http.StatusFound)
+	}
+	return http.HandlerFunc(fn)
+}
@@ -0,0 +1,191 @@
+package sessionlogin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/dghubble/sessions"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestStore() SessionStore {
+	return NewSessionsStore(sessions.NewCookieStore([]byte("test cookie signing secret"), nil), "test-app")
+}
+
+func profileRequest(profile *gologin.Profile) *http.Request {
+	req, _ := http.NewRequest("GET", "/callback", nil)
+	ctx := gologin.WithProfile(req.Context(), profile)
+	return req.WithContext(ctx)
+}
+
+// errorStore is a SessionStore whose Save fails.
+type errorStore struct {
+	SessionStore
+}
+
+func (s errorStore) Save(w http.ResponseWriter, session *Session) error {
+	return errors.New("store unavailable")
+}
+
+func TestIssueSession(t *testing.T) {
+	store := newTestStore()
+	profile := &gologin.Profile{Provider: "github", ID: "917408", Name: "Github User"}
+	handler := IssueSession(store, &Options{RedirectURL: "/profile", Failure: testutils.AssertFailureNotCalled(t)})
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, profileRequest(profile))
+
+	// IssueSession assert that:
+	// - the user is redirected to the RedirectURL
+	// - a session of the Profile ID and claims is saved
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "/profile", w.HeaderMap.Get("Location"))
+	req, _ := http.NewRequest("GET", "/profile", nil)
+	for _, cookie := range w.Result().Cookies() {
+		req.AddCookie(cookie)
+	}
+	session, err := store.Get(req)
+	if assert.Nil(t, err) {
+		assert.Equal(t, "917408", session.UserID)
+		assert.Equal(t, map[string]interface{}{"provider": "github", "name": "Github User"}, session.Claims)
+	}
+}
+
+func TestIssueSession_Extractor(t *testing.T) {
+	store := newTestStore()
+	extractor := func(ctx context.Context) (string, map[string]interface{}, error) {
+		return "custom-id", map[string]interface{}{"role": "admin"}, nil
+	}
+	handler := IssueSession(store, &Options{Extractor: extractor})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/callback", nil)
+	handler.ServeHTTP(w, req)
+
+	// IssueSession with an Extractor assert that:
+	// - the extracted user ID and claims are saved
+	// - the user is redirected to the default "/"
+	assert.Equal(t, "/", w.HeaderMap.Get("Location"))
+	req, _ = http.NewRequest("GET", "/", nil)
+	for _, cookie := range w.Result().Cookies() {
+		req.AddCookie(cookie)
+	}
+	session, err := store.Get(req)
+	if assert.Nil(t, err) {
+		assert.Equal(t, &Session{UserID: "custom-id", Claims: map[string]interface{}{"role": "admin"}}, session)
+	}
+}
+
+func TestIssueSession_Errors(t *testing.T) {
+	extractorErr := errors.New("unknown user")
+	cases := []struct {
+		store    SessionStore
+		opts     *Options
+		req      *http.Request
+		expected string
+	}{
+		// missing Profile
+		{newTestStore(), nil, profileRequest(nil), "Context missing Profile"},
+		// empty user ID
+		{newTestStore(), nil, profileRequest(&gologin.Profile{Provider: "github"}), ErrMissingUserID.Error()},
+		// extractor error
+		{newTestStore(), &Options{Extractor: func(ctx context.Context) (string, map[string]interface{}, error) {
+			return "", nil, extractorErr
+		}}, profileRequest(nil), extractorErr.Error()},
+		// store error
+		{errorStore{newTestStore()}, nil, profileRequest(&gologin.Profile{Provider: "github", ID: "917408"}), "store unavailable"},
+	}
+	for _, c := range cases {
+		failure := func(w http.ResponseWriter, req *http.Request) {
+			err := gologin.ErrorFromContext(req.Context())
+			if assert.NotNil(t, err) {
+				assert.Equal(t, c.expected, err.Error())
+			}
+			fmt.Fprintf(w, "failure handler called")
+		}
+		opts := &Options{Failure: http.HandlerFunc(failure)}
+		if c.opts != nil {
+			opts.Extractor = c.opts.Extractor
+		}
+		w := httptest.NewRecorder()
+		IssueSession(c.store, opts).ServeHTTP(w, c.req)
+
+		// IssueSession errors assert that:
+		// - the failure handler is called
+		// - no session cookie is written
+		assert.Equal(t, "failure handler called", w.Body.String())
+		assert.Empty(t, w.Result().Cookies())
+	}
+}
+
+func TestRequireSession(t *testing.T) {
+	store := newTestStore()
+	w := httptest.NewRecorder()
+	store.Save(w, &Session{UserID: "917408", Claims: map[string]interface{}{"provider": "github"}})
+	cookies := w.Result().Cookies()
+
+	next := func(w http.ResponseWriter, req *http.Request) {
+		session, err := SessionFromContext(req.Context())
+		if assert.Nil(t, err) {
+			assert.Equal(t, "917408", session.UserID)
+			assert.Equal(t, "github", session.Claims["provider"])
+		}
+		fmt.Fprintf(w, "next handler called")
+	}
+	handler := RequireSession(store, "/login", http.HandlerFunc(next))
+
+	// RequireSession assert that:
+	// - requests with a session call next with the Session in the ctx
+	req, _ := http.NewRequest("GET", "/profile", nil)
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "next handler called", w.Body.String())
+	// - requests without a session are redirected to the loginURL
+	req, _ = http.NewRequest("GET", "/profile", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "/login", w.HeaderMap.Get("Location"))
+}
+
+func TestLogoutHandler(t *testing.T) {
+	handler := LogoutHandler(newTestStore(), "/")
+
+	// LogoutHandler assert that:
+	// - POST requests expire the session cookie and redirect
+	req, _ := http.NewRequest("POST", "/logout", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "/", w.HeaderMap.Get("Location"))
+	if cookies := w.Result().Cookies(); assert.Len(t, cookies, 1) {
+		assert.Equal(t, "test-app", cookies[0].Name)
+		assert.True(t, cookies[0].MaxAge < 0)
+	}
+	// - other requests only redirect
+	req, _ = http.NewRequest("GET", "/logout", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Empty(t, w.Result().Cookies())
+}
+
+func TestSessionFromContext(t *testing.T) {
+	expected := &Session{UserID: "917408"}
+	session, err := SessionFromContext(WithSession(context.Background(), expected))
+	assert.Equal(t, expected, session)
+	assert.Nil(t, err)
+
+	session, err = SessionFromContext(context.Background())
+	assert.Nil(t, session)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "sessionlogin: Context missing Session", err.Error())
+	}
+}
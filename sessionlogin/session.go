@@ -0,0 +1,104 @@
+package sessionlogin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/dghubble/sessions"
+)
+
+// ErrMissingSession is returned by a SessionStore when the request has no
+// session.
+var ErrMissingSession = errors.New("sessionlogin: missing session")
+
+// Session is an issued login session.
+type Session struct {
+	// UserID is the ID of the logged in user (e.g. the Profile ID).
+	UserID string
+	// Claims are other values about the user (e.g. the provider name).
+	Claims map[string]interface{}
+}
+
+// SessionStore saves, reads, and destroys Sessions.
+type SessionStore interface {
+	// Save writes the Session to the response.
+	Save(w http.ResponseWriter, session *Session) error
+	// Get returns the Session of the request or an error if there is none.
+	Get(req *http.Request) (*Session, error)
+	// Destroy removes the Session.
+	Destroy(w http.ResponseWriter)
+}
+
+// userIDKey is the sessions Values key of the Session UserID
+const userIDKey = "userID"
+
+// sessionsStore is a SessionStore of dghubble/sessions.
+type sessionsStore struct {
+	store sessions.Store
+	name  string
+}
+
+// NewSessionsStore returns a SessionStore which stores Sessions with the
+// dghubble/sessions Store as the named session. Claims are stored as
+// session Values beside the user ID, so they should be encodable by the
+// Store (e.g. strings).
+func NewSessionsStore(store sessions.Store, name string) SessionStore {
+	return &sessionsStore{
+		store: store,
+		name:  name,
+	}
+}
+
+func (s *sessionsStore) Save(w http.ResponseWriter, session *Session) error {
+	stored := s.store.New(s.name)
+	for key, value := range session.Claims {
+		stored.Values[key] = value
+	}
+	stored.Values[userIDKey] = session.UserID
+	return stored.Save(w)
+}
+
+func (s *sessionsStore) Get(req *http.Request) (*Session, error) {
+	stored, err := s.store.Get(req, s.name)
+	if err != nil {
+		return nil, ErrMissingSession
+	}
+	userID, _ := stored.Values[userIDKey].(string)
+	if userID == "" {
+		return nil, ErrMissingSession
+	}
+	session := &Session{UserID: userID, Claims: make(map[string]interface{})}
+	for key, value := range stored.Values {
+		if key != userIDKey {
+			session.Claims[key] = value
+		}
+	}
+	return session, nil
+}
+
+func (s *sessionsStore) Destroy(w http.ResponseWriter) {
+	s.store.Destroy(w, s.name)
+}
+
+// unexported key type prevents collisions
+type key int
+
+const (
+	sessionKey key = iota
+)
+
+// WithSession returns a copy of ctx that stores the Session.
+func WithSession(ctx context.Context, session *Session) context.Context {
+	return context.WithValue(ctx, sessionKey, session)
+}
+
+// SessionFromContext returns the Session from the ctx.
+func SessionFromContext(ctx context.Context) (*Session, error) {
+	session, ok := ctx.Value(sessionKey).(*Session)
+	if !ok {
+		return nil, fmt.Errorf("sessionlogin: Context missing Session")
+	}
+	return session, nil
+}
@@ -0,0 +1,24 @@
+package shopify
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContextShop(t *testing.T) {
+	expectedShop := &Shop{ID: 690933842, Name: "Snowdevil", MyshopifyDomain: "snowdevil.myshopify.com"}
+	ctx := WithShop(context.Background(), expectedShop)
+	shop, err := ShopFromContext(ctx)
+	assert.Equal(t, expectedShop, shop)
+	assert.Nil(t, err)
+}
+
+func TestContextShop_Error(t *testing.T) {
+	shop, err := ShopFromContext(context.Background())
+	assert.Nil(t, shop)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "shopify: Context missing Shopify Shop", err.Error())
+	}
+}
@@ -0,0 +1,3 @@
+// Package shopify provides Shopify OAuth2 login and callback handlers for
+// per-shop authorization.
+package shopify
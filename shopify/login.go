@@ -0,0 +1,174 @@
+package shopify
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/internal"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"golang.org/x/oauth2"
+)
+
+// ProviderName is the provider name set in the ctx (see
+// gologin.ProviderFromContext).
+const ProviderName = "shopify"
+
+// Shopify login errors
+var (
+	ErrUnableToGetShopifyShop = errors.New("shopify: unable to get Shopify Shop")
+	ErrInvalidShop            = errors.New("shopify: invalid shop domain")
+	ErrInvalidHMAC            = errors.New("shopify: invalid callback hmac")
+	ErrShopMismatch           = errors.New("shopify: callback shop does not match login shop")
+)
+
+// shopPattern matches valid shop domains.
+var shopPattern = regexp.MustCompile(`^[a-zA-Z0-9-]+\.myshopify\.com$`)
+
+// NewEndpoint returns the OAuth2 endpoint of the shop domain (e.g.
+// "example.myshopify.com"). Shopify expects client credentials in the token
+// request body.
+func NewEndpoint(shop string) oauth2.Endpoint {
+	return oauth2.Endpoint{
+		AuthURL:   "https://" + shop + "/admin/oauth/authorize",
+		TokenURL:  "https://" + shop + "/admin/oauth/access_token",
+		AuthStyle: oauth2.AuthStyleInParams,
+	}
+}
+
+// shopConfig returns a copy of the oauth2.Config with the shop's Endpoint.
+func shopConfig(config *oauth2.Config, shop string) *oauth2.Config {
+	c := *config
+	c.Endpoint = NewEndpoint(shop)
+	return &c
+}
+
+// StateHandler checks for a state cookie. If found, the state value is read
+// and added to the ctx. Otherwise, a non-guessable value is added to the ctx
+// and to a (short-lived) state cookie issued to the requester.
+//
+// Implements OAuth 2 RFC 6749 10.12 CSRF Protection. If you wish to issue
+// state params differently, write a http.Handler which sets the ctx state,
+// using oauth2 WithState(ctx, state) since it is required by LoginHandler
+// and CallbackHandler.
+func StateHandler(config gologin.CookieConfig, success http.Handler) http.Handler {
+	return oauth2Login.StateHandler(config, success)
+}
+
+// LoginHandler handles Shopify login requests by reading the target shop
+// domain from the "shop" query parameter and the state value from the ctx
+// and redirecting requests to the shop's AuthURL with that state value. The
+// shop is kept in a short-lived cookie so CallbackHandler can check the
+// callback is for the same shop. Any AuthCodeOptions are added to the AuthURL.
+//
+// The oauth2.Config Endpoint is ignored in favor of the shop's endpoint. The
+// cookieConfig Name must differ from the name of the state cookie. If the
+// shop is not a myshopify.com domain, the failure handler is called with
+// ErrInvalidShop.
+func LoginHandler(config *oauth2.Config, cookieConfig gologin.CookieConfig, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		shop := req.URL.Query().Get("shop")
+		if !shopPattern.MatchString(shop) {
+			ctx = gologin.WithError(ctx, ErrInvalidShop)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		http.SetCookie(w, internal.NewCookie(cookieConfig, shop))
+		oauth2Login.LoginHandler(shopConfig(config, shop), failure, opts...).ServeHTTP(w, req)
+	}
+	return http.HandlerFunc(fn)
+}
+
+// CallbackHandler handles Shopify redirection URI requests and adds the
+// Shopify access token and Shop to the ctx. Before the code is exchanged at
+// the shop's token endpoint, the callback hmac is verified with the
+// oauth2.Config ClientSecret and the callback shop must match the shop in the
+// cookie set by LoginHandler. If authentication succeeds, handling delegates
+// to the success handler, otherwise to the failure handler.
+//
+// Callbacks with an invalid hmac fail with ErrInvalidHMAC. Callbacks for
+// another shop (or without the shop cookie) fail with ErrShopMismatch.
+func CallbackHandler(config *oauth2.Config, cookieConfig gologin.CookieConfig, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		query := req.URL.Query()
+		if !validHMAC(query, config.ClientSecret) {
+			ctx = gologin.WithError(ctx, ErrInvalidHMAC)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		shop := query.Get("shop")
+		if !shopPattern.MatchString(shop) {
+			ctx = gologin.WithError(ctx, ErrInvalidShop)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		cookie, err := req.Cookie(cookieConfig.Name)
+		if err != nil || cookie.Value != shop {
+			ctx = gologin.WithError(ctx, ErrShopMismatch)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		// expire the shop cookie, each login is for a single callback
+		http.SetCookie(w, internal.ExpiredCookie(cookieConfig))
+		callback := oauth2Login.CallbackHandler(shopConfig(config, shop), shopifyHandler(shop, success, failure), failure)
+		callback.ServeHTTP(w, req)
+	}
+	return http.HandlerFunc(fn)
+}
+
+// shopifyHandler is a http.Handler that gets the OAuth2 Token from the ctx to
+// get the Shopify Shop from the shop domain. If successful, the Shop is added to the
+// ctx and the success handler is called. Otherwise, the failure handler is
+// called.
+func shopifyHandler(shopDomain string, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		httpClient := internal.ContextClient(ctx)
+		shop, resp, err := newClient(httpClient, shopDomain).Shop(ctx, token.AccessToken)
+		err = validateResponse(shop, resp, err)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		ctx = WithShop(ctx, shop)
+		ctx = internal.WithDefaultProvider(ctx, ProviderName)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// validateResponse returns an error if the given Shopify Shop, raw
+// http.Response, or error are unexpected. Returns nil if they are valid, or a
+// *gologin.Error which preserves the cause and status code.
+func validateResponse(shop *Shop, resp *http.Response, err error) error {
+	var status int
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	if err != nil || status != http.StatusOK {
+		return &gologin.Error{Provider: "shopify", Op: "get shop", StatusCode: status, Err: err, Kind: ErrUnableToGetShopifyShop}
+	}
+	if shop == nil || shop.ID == 0 {
+		return &gologin.Error{Provider: "shopify", Op: "get shop", StatusCode: status, Kind: ErrUnableToGetShopifyShop}
+	}
+	return nil
+}
@@ -0,0 +1,316 @@
+package shopify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/dghubble/gologin"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+var testShopCookieConfig = gologin.CookieConfig{
+	Name:   "shopify-shop",
+	Path:   "/",
+	MaxAge: 60,
+}
+
+func testConfig() *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     "client_id",
+		ClientSecret: "client_secret",
+		RedirectURL:  "https://example.com/shopify/callback",
+		Scopes:       []string{"read_products"},
+	}
+}
+
+// testCallbackParams returns the params of a Shopify callback for the shop.
+func testCallbackParams(shop string) url.Values {
+	return url.Values{
+		"code":      {"any_code"},
+		"host":      {"c25vd2RldmlsLm15c2hvcGlmeS5jb20vYWRtaW4"},
+		"shop":      {shop},
+		"state":     {"d4e5f6"},
+		"timestamp": {"1337178173"},
+	}
+}
+
+func TestNewEndpoint(t *testing.T) {
+	endpoint := NewEndpoint("snowdevil.myshopify.com")
+	assert.Equal(t, "https://snowdevil.myshopify.com/admin/oauth/authorize", endpoint.AuthURL)
+	assert.Equal(t, "https://snowdevil.myshopify.com/admin/oauth/access_token", endpoint.TokenURL)
+	assert.Equal(t, oauth2.AuthStyleInParams, endpoint.AuthStyle)
+}
+
+func TestLoginHandler(t *testing.T) {
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// LoginHandler assert that:
+	// - redirects to the shop's AuthURL with the state
+	// - the shop is kept in a cookie
+	loginHandler := LoginHandler(testConfig(), testShopCookieConfig, failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?shop=snowdevil.myshopify.com", nil)
+	ctx := oauth2Login.WithState(context.Background(), "d4e5f6")
+	loginHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, http.StatusFound, w.Code)
+	location, err := url.Parse(w.HeaderMap.Get("Location"))
+	if assert.Nil(t, err) {
+		assert.Equal(t, "snowdevil.myshopify.com", location.Host)
+		assert.Equal(t, "/admin/oauth/authorize", location.Path)
+		assert.Equal(t, "client_id", location.Query().Get("client_id"))
+		assert.Equal(t, "d4e5f6", location.Query().Get("state"))
+	}
+	cookies := (&http.Response{Header: w.Header()}).Cookies()
+	if assert.Len(t, cookies, 1) {
+		assert.Equal(t, "shopify-shop", cookies[0].Name)
+		assert.Equal(t, "snowdevil.myshopify.com", cookies[0].Value)
+	}
+}
+
+func TestLoginHandler_InvalidShop(t *testing.T) {
+	shops := []string{"", "snowdevil", "example.com", "snowdevil.myshopify.com.example.com", "evil.com/.myshopify.com", "snow_devil.myshopify.com"}
+	for _, shop := range shops {
+		failure := func(w http.ResponseWriter, req *http.Request) {
+			assert.Equal(t, ErrInvalidShop, gologin.ErrorFromContext(req.Context()), shop)
+			fmt.Fprintf(w, "failure handler called")
+		}
+
+		// LoginHandler with an invalid shop, assert that:
+		// - failure handler is called with ErrInvalidShop
+		// - no redirect or shop cookie is issued
+		loginHandler := LoginHandler(testConfig(), testShopCookieConfig, http.HandlerFunc(failure))
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/?"+url.Values{"shop": {shop}}.Encode(), nil)
+		ctx := oauth2Login.WithState(context.Background(), "d4e5f6")
+		loginHandler.ServeHTTP(w, req.WithContext(ctx))
+		assert.Equal(t, "failure handler called", w.Body.String())
+		assert.Empty(t, w.HeaderMap.Get("Set-Cookie"))
+	}
+}
+
+func TestCallbackHandler(t *testing.T) {
+	proxyClient, server := newShopifyTestServer(testShopJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithState(ctx, "d4e5f6")
+
+	success := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "any-token", token.AccessToken)
+		}
+		shop, err := ShopFromContext(ctx)
+		if assert.Nil(t, err) {
+			expectedShop := &Shop{ID: 690933842, Name: "Snowdevil", Email: "steve@snowdevil.ca", Domain: "snowdevil.ca", MyshopifyDomain: "snowdevil.myshopify.com", ShopOwner: "Steve Jobs", PlanName: "shopify_plus", Currency: "CAD", Country: "CA"}
+			assert.Equal(t, expectedShop, shop)
+		}
+		assert.Equal(t, ProviderName, gologin.ProviderFromContext(ctx, ""))
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// CallbackHandler assert that:
+	// - the hmac and shop are verified
+	// - the code is exchanged at the shop's token endpoint
+	// - success handler is called with the Token, Shop, and provider name in
+	// the ctx
+	// - the shop cookie is expired
+	callbackHandler := CallbackHandler(testConfig(), testShopCookieConfig, http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?"+signedQuery(testCallbackParams("snowdevil.myshopify.com"), "client_secret"), nil)
+	req.AddCookie(&http.Cookie{Name: "shopify-shop", Value: "snowdevil.myshopify.com"})
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+	cookies := (&http.Response{Header: w.Header()}).Cookies()
+	if assert.NotEmpty(t, cookies) {
+		assert.Equal(t, "shopify-shop", cookies[0].Name)
+		assert.Equal(t, -1, cookies[0].MaxAge)
+	}
+}
+
+func TestCallbackHandler_InvalidHMAC(t *testing.T) {
+	params := testCallbackParams("snowdevil.myshopify.com")
+	tampered, _ := url.ParseQuery(signedQuery(params, "client_secret"))
+	tampered.Set("code", "other_code")
+	cases := []string{
+		// signed with another secret
+		signedQuery(params, "other_secret"),
+		// params changed after signing
+		tampered.Encode(),
+		// missing hmac
+		params.Encode(),
+	}
+	for _, query := range cases {
+		success := testutils.AssertSuccessNotCalled(t)
+		failure := func(w http.ResponseWriter, req *http.Request) {
+			assert.Equal(t, ErrInvalidHMAC, gologin.ErrorFromContext(req.Context()))
+			fmt.Fprintf(w, "failure handler called")
+		}
+
+		// CallbackHandler with an invalid hmac, assert that:
+		// - failure handler is called with ErrInvalidHMAC
+		callbackHandler := CallbackHandler(testConfig(), testShopCookieConfig, success, http.HandlerFunc(failure))
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/?"+query, nil)
+		req.AddCookie(&http.Cookie{Name: "shopify-shop", Value: "snowdevil.myshopify.com"})
+		ctx := oauth2Login.WithState(context.Background(), "d4e5f6")
+		callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+		assert.Equal(t, "failure handler called", w.Body.String())
+	}
+}
+
+func TestCallbackHandler_ShopMismatch(t *testing.T) {
+	query := signedQuery(testCallbackParams("icedevil.myshopify.com"), "client_secret")
+	cookies := []*http.Cookie{
+		{Name: "shopify-shop", Value: "snowdevil.myshopify.com"},
+		nil,
+	}
+	for _, cookie := range cookies {
+		success := testutils.AssertSuccessNotCalled(t)
+		failure := func(w http.ResponseWriter, req *http.Request) {
+			assert.Equal(t, ErrShopMismatch, gologin.ErrorFromContext(req.Context()))
+			fmt.Fprintf(w, "failure handler called")
+		}
+
+		// CallbackHandler for a signed callback of another shop, assert that:
+		// - failure handler is called with ErrShopMismatch
+		callbackHandler := CallbackHandler(testConfig(), testShopCookieConfig, success, http.HandlerFunc(failure))
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/?"+query, nil)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		ctx := oauth2Login.WithState(context.Background(), "d4e5f6")
+		callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+		assert.Equal(t, "failure handler called", w.Body.String())
+	}
+}
+
+func TestCallbackHandler_InvalidShop(t *testing.T) {
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, ErrInvalidShop, gologin.ErrorFromContext(req.Context()))
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// CallbackHandler for a signed callback of an invalid shop, assert that:
+	// - failure handler is called with ErrInvalidShop
+	callbackHandler := CallbackHandler(testConfig(), testShopCookieConfig, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?"+signedQuery(testCallbackParams("example.com"), "client_secret"), nil)
+	req.AddCookie(&http.Cookie{Name: "shopify-shop", Value: "example.com"})
+	ctx := oauth2Login.WithState(context.Background(), "d4e5f6")
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestShopifyHandler_MissingCtxToken(t *testing.T) {
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "oauth2: Context missing Token", err.Error())
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// ShopifyHandler called without Token in ctx, assert that:
+	// - failure handler is called
+	// - error about ctx missing token is added to the failure handler ctx
+	shopifyHandler := shopifyHandler("snowdevil.myshopify.com", success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	shopifyHandler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestShopifyHandler_ErrorGettingShop(t *testing.T) {
+	proxyClient, server := newShopifyTestServer(testShopJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "other-token"})
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.True(t, errors.Is(err, ErrUnableToGetShopifyShop))
+			var gologinErr *gologin.Error
+			if assert.True(t, errors.As(err, &gologinErr)) {
+				assert.Equal(t, http.StatusUnauthorized, gologinErr.StatusCode)
+			}
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// ShopifyHandler with a Token Shopify rejects, assert that:
+	// - failure handler is called
+	// - error cannot get Shopify Shop added to the failure handler ctx
+	shopifyHandler := shopifyHandler("snowdevil.myshopify.com", success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	shopifyHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestShopifyHandler_DebugHandler(t *testing.T) {
+	proxyClient, server := newShopifyTestServer(testShopJSON)
+	defer server.Close()
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+	var records []gologin.DebugRecord
+	debug := gologin.DebugTransport{
+		Logger: gologin.DebugLoggerFunc(func(ctx context.Context, record gologin.DebugRecord) {
+			records = append(records, record)
+		}),
+	}
+
+	// ShopifyHandler with a DebugHandler, assert that:
+	// - the shop request is logged
+	// - the X-Shopify-Access-Token header is redacted
+	success := func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(w, "success handler called")
+	}
+	handler := gologin.DebugHandler(debug, shopifyHandler("snowdevil.myshopify.com", http.HandlerFunc(success), testutils.AssertFailureNotCalled(t)))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	handler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+	if assert.Len(t, records, 1) {
+		assert.Equal(t, "https://snowdevil.myshopify.com/admin/api/2024-01/shop.json", records[0].URL)
+		assert.Equal(t, gologin.Redacted, records[0].RequestHeader.Get("X-Shopify-Access-Token"))
+	}
+}
+
+func TestValidHMAC(t *testing.T) {
+	// example from the Shopify docs
+	query, _ := url.ParseQuery("code=0907a61c0c8d55e99db179b68161bc00&hmac=700e2dadb827fcc8609e9d5ce208b2e9cdaab9df07390d2cbca10d7c328fc4bf&shop=some-shop.myshopify.com&state=0.6784241404160823&timestamp=1337178173")
+	assert.True(t, validHMAC(query, "hush"))
+	assert.False(t, validHMAC(query, "other"))
+	query.Set("hmac", "not-hex")
+	assert.False(t, validHMAC(query, "hush"))
+	query.Del("hmac")
+	assert.False(t, validHMAC(query, "hush"))
+}
+
+func TestValidateResponse(t *testing.T) {
+	validShop := &Shop{ID: 690933842}
+	validResponse := &http.Response{StatusCode: 200}
+	invalidResponse := &http.Response{StatusCode: 500}
+	assert.Equal(t, nil, validateResponse(validShop, validResponse, nil))
+	assert.True(t, errors.Is(validateResponse(validShop, validResponse, fmt.Errorf("Server error")), ErrUnableToGetShopifyShop))
+	assert.True(t, errors.Is(validateResponse(validShop, invalidResponse, nil), ErrUnableToGetShopifyShop))
+	assert.True(t, errors.Is(validateResponse(&Shop{}, validResponse, nil), ErrUnableToGetShopifyShop))
+	assert.True(t, errors.Is(validateResponse(nil, validResponse, nil), ErrUnableToGetShopifyShop))
+}
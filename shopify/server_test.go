@@ -0,0 +1,57 @@
+package shopify
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	"github.com/dghubble/gologin/testutils"
+)
+
+const testShopJSON = `{"shop": {"id": 690933842, "name": "Snowdevil", "email": "steve@snowdevil.ca", "domain": "snowdevil.ca", "province": "Alberta", "country": "CA", "country_code": "CA", "currency": "CAD", "shop_owner": "Steve Jobs", "plan_name": "shopify_plus", "myshopify_domain": "snowdevil.myshopify.com"}}`
+
+// newShopifyTestServer returns a new httptest.Server which mocks a shop's
+// access_token and shop.json endpoints and a client which proxies requests
+// to the server. The access_token endpoint requires client credentials in
+// the form body. The shop.json endpoint responds with the given json data
+// for the X-Shopify-Access-Token "any-token". The caller must close the
+// server.
+func newShopifyTestServer(jsonData string) (*http.Client, *httptest.Server) {
+	client, mux, server := testutils.TestServer()
+	mux.HandleFunc("/admin/oauth/access_token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Host != "snowdevil.myshopify.com" || r.PostFormValue("client_id") != "client_id" || r.PostFormValue("client_secret") != "client_secret" || r.PostFormValue("code") != "any_code" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, `{"error": "invalid_request", "error_description": "The authorization code was not found or was already used"}`)
+			return
+		}
+		fmt.Fprintf(w, `{"access_token": "any-token", "scope": "read_products"}`)
+	})
+	mux.HandleFunc("/admin/api/2024-01/shop.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Host != "snowdevil.myshopify.com" || r.Header.Get("X-Shopify-Access-Token") != "any-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprintf(w, `{"errors": "[API] Invalid API key or access token (unrecognized login or wrong password)"}`)
+			return
+		}
+		fmt.Fprintf(w, jsonData)
+	})
+	return client, server
+}
+
+// signedQuery returns the encoded query params with the hmac Shopify would
+// add for the client secret.
+func signedQuery(params url.Values, clientSecret string) string {
+	mac := hmac.New(sha256.New, []byte(clientSecret))
+	mac.Write([]byte(params.Encode()))
+	signed := url.Values{}
+	for k, v := range params {
+		signed[k] = v
+	}
+	signed.Set("hmac", hex.EncodeToString(mac.Sum(nil)))
+	return signed.Encode()
+}
@@ -0,0 +1,80 @@
+package shopify
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+
+	"github.com/dghubble/gologin/internal/jsonclient"
+)
+
+// apiVersion is the Shopify Admin API version used to get the Shop.
+const apiVersion = "2024-01"
+
+// Shop is a Shopify shop.
+// https://shopify.dev/docs/api/admin-rest/2024-01/resources/shop
+type Shop struct {
+	ID              int64  `json:"id"`
+	Name            string `json:"name"`
+	Email           string `json:"email"`
+	Domain          string `json:"domain"`
+	MyshopifyDomain string `json:"myshopify_domain"`
+	ShopOwner       string `json:"shop_owner"`
+	PlanName        string `json:"plan_name"`
+	Currency        string `json:"currency"`
+	Country         string `json:"country_code"`
+}
+
+// shopResponse is a Shopify shop.json response.
+type shopResponse struct {
+	Shop *Shop `json:"shop"`
+}
+
+// client is a Shopify Admin API client for obtaining the Shop.
+type client struct {
+	json *jsonclient.Client
+}
+
+// newClient returns a new Shopify Admin API client for the shop domain.
+func newClient(httpClient *http.Client, shop string) *client {
+	return &client{
+		json: jsonclient.New(httpClient, "https://"+shop+"/admin/api/"+apiVersion+"/"),
+	}
+}
+
+// Shop returns the Shop of the access token. Shopify Admin API requests
+// authenticate with an X-Shopify-Access-Token header, not a Bearer token.
+func (c *client) Shop(ctx context.Context, accessToken string) (*Shop, *http.Response, error) {
+	shopResp := new(shopResponse)
+	req, err := c.json.NewRequest(ctx, "GET", "shop.json", nil, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("X-Shopify-Access-Token", accessToken)
+	resp, err := c.json.Do(req, shopResp, nil)
+	return shopResp.Shop, resp, err
+}
+
+// validHMAC returns true if the query's hmac parameter is the hex encoded
+// HMAC-SHA256 (keyed by the client secret) of the other parameters, sorted
+// by key and joined as a query string.
+// https://shopify.dev/docs/apps/build/authentication-authorization/access-tokens/authorization-code-grant#step-1-verify-the-installation-request
+func validHMAC(query url.Values, clientSecret string) bool {
+	sum, err := hex.DecodeString(query.Get("hmac"))
+	if err != nil || len(sum) == 0 {
+		return false
+	}
+	params := url.Values{}
+	for k, v := range query {
+		if k != "hmac" && k != "signature" {
+			params[k] = v
+		}
+	}
+	mac := hmac.New(sha256.New, []byte(clientSecret))
+	// Encode sorts the params by key
+	mac.Write([]byte(params.Encode()))
+	return hmac.Equal(sum, mac.Sum(nil))
+}
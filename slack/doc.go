@@ -0,0 +1,3 @@
+// Package slack provides Sign in with Slack (OpenID Connect) login and
+// callback handlers.
+package slack
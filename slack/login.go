@@ -0,0 +1,141 @@
+package slack
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/internal"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"golang.org/x/oauth2"
+)
+
+// ProviderName is the provider name set in the ctx (see
+// gologin.ProviderFromContext) and the Profile.
+const ProviderName = "slack"
+
+// Slack login errors
+var (
+	ErrUnableToGetSlackUser = errors.New("slack: unable to get Slack User")
+	ErrTeamNotAllowed       = errors.New("slack: Slack User team is not allowed")
+)
+
+// Endpoint is Slack's OpenID Connect endpoint.
+var Endpoint = oauth2.Endpoint{
+	AuthURL:  "https://slack.com/openid/connect/authorize",
+	TokenURL: "https://slack.com/api/openid.connect.token",
+}
+
+// Config configures Slack login.
+type Config struct {
+	// AllowedTeamIDs restricts login to users of the given Slack workspace
+	// (team) IDs (e.g. "T0R7GR"). Users of other workspaces fail with
+	// ErrTeamNotAllowed. If empty, users of any workspace are allowed.
+	AllowedTeamIDs []string
+}
+
+// allowedTeam returns true if the Config allows the team ID.
+func (c Config) allowedTeam(teamID string) bool {
+	if len(c.AllowedTeamIDs) == 0 {
+		return true
+	}
+	for _, allowed := range c.AllowedTeamIDs {
+		if teamID == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// StateHandler checks for a state cookie. If found, the state value is read
+// and added to the ctx. Otherwise, a non-guessable value is added to the ctx
+// and to a (short-lived) state cookie issued to the requester.
+//
+// Implements OAuth 2 RFC 6749 10.12 CSRF Protection. If you wish to issue
+// state params differently, write a http.Handler which sets the ctx state,
+// using oauth2 WithState(ctx, state) since it is required by LoginHandler
+// and CallbackHandler.
+func StateHandler(config gologin.CookieConfig, success http.Handler) http.Handler {
+	return oauth2Login.StateHandler(config, success)
+}
+
+// LoginHandler handles Slack login requests by reading the state value from
+// the ctx and redirecting requests to the AuthURL with that state value.
+// Any AuthCodeOptions are added to the AuthURL.
+//
+// The config Endpoint should be the slack Endpoint and Scopes should include
+// "openid" (and "email" and "profile" for the User Email, Name, and Picture).
+func LoginHandler(config *oauth2.Config, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	return oauth2Login.LoginHandler(config, failure, opts...)
+}
+
+// CallbackHandler handles Slack redirection URI requests and adds the Slack
+// access token and User to the ctx. If authentication succeeds, handling
+// delegates to the success handler, otherwise to the failure handler.
+// Any AuthCodeOptions are sent with the token exchange.
+func CallbackHandler(config *oauth2.Config, success, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	return CallbackHandlerWithConfig(config, Config{}, success, failure, opts...)
+}
+
+// CallbackHandlerWithConfig handles Slack redirection URI requests like
+// CallbackHandler, but enforces the Config. If the Config has AllowedTeamIDs,
+// callbacks for Users of other workspaces fail with ErrTeamNotAllowed.
+func CallbackHandlerWithConfig(config *oauth2.Config, slackConfig Config, success, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	success = slackHandler(config, slackConfig, success, failure)
+	return oauth2Login.CallbackHandler(config, success, failure, opts...)
+}
+
+// slackHandler is a http.Handler that gets the OAuth2 Token from the ctx to
+// get the corresponding Slack User. If successful, the User is added to the
+// ctx and the success handler is called. Otherwise, the failure handler is
+// called.
+func slackHandler(config *oauth2.Config, slackConfig Config, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		httpClient := internal.OAuth2Client(ctx, config, token)
+		user, resp, err := newClient(httpClient).UserInfo(ctx)
+		err = validateResponse(user, resp, err)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		if !slackConfig.allowedTeam(user.TeamID) {
+			ctx = gologin.WithError(ctx, ErrTeamNotAllowed)
+			ctx = gologin.WithStatusCode(ctx, http.StatusForbidden)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		ctx = WithUser(ctx, user)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// validateResponse returns an error if the given Slack User, raw
+// http.Response, or error are unexpected. Slack API errors ("ok": false) are
+// unexpected even with a 200 OK status. Returns nil if they are valid, or a
+// *gologin.Error which preserves the cause (e.g. an *APIError) and status
+// code.
+func validateResponse(user *User, resp *http.Response, err error) error {
+	var status int
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	if err != nil || status != http.StatusOK {
+		return &gologin.Error{Provider: "slack", Op: "get user", StatusCode: status, Err: err, Kind: ErrUnableToGetSlackUser}
+	}
+	if user == nil || user.ID == "" {
+		return &gologin.Error{Provider: "slack", Op: "get user", StatusCode: status, Kind: ErrUnableToGetSlackUser}
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package slack
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/dghubble/gologin/testutils"
+)
+
+// newSlackTestServer returns a new httptest.Server which mocks the Slack
+// openid.connect.token and openid.connect.userInfo methods and a client which
+// proxies requests to the server. The userInfo method responds with the given
+// json data. The caller must close the server.
+func newSlackTestServer(jsonData string) (*http.Client, *httptest.Server) {
+	client, mux, server := testutils.TestServer()
+	mux.HandleFunc("/api/openid.connect.token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"ok": true, "access_token": "any-token", "token_type": "Bearer", "id_token": "any.id.token"}`)
+	})
+	mux.HandleFunc("/api/openid.connect.userInfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer any-token" {
+			// Slack reports errors with a 200 OK status
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"ok": false, "error": "invalid_auth"}`)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, jsonData)
+	})
+	return client, server
+}
@@ -0,0 +1,66 @@
+package slack
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/dghubble/gologin/internal/jsonclient"
+)
+
+const slackAPI = "https://slack.com/api/"
+
+// User is a Slack user's OpenID Connect userinfo.
+type User struct {
+	// ID is the OpenID Connect sub (the Slack user ID)
+	ID            string `json:"sub"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name"`
+	GivenName     string `json:"given_name"`
+	FamilyName    string `json:"family_name"`
+	Picture       string `json:"picture"`
+	Locale        string `json:"locale"`
+	TeamID        string `json:"https://slack.com/team_id"`
+	TeamName      string `json:"https://slack.com/team_name"`
+	TeamDomain    string `json:"https://slack.com/team_domain"`
+}
+
+// APIError is a Slack Web API error. Slack reports errors with "ok": false
+// and an error code, usually with a 200 OK status.
+type APIError struct {
+	Code string `json:"error"`
+}
+
+func (e *APIError) Error() string {
+	return "slack: " + e.Code
+}
+
+// userInfoResponse is an openid.connect.userInfo response.
+type userInfoResponse struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error"`
+	User
+}
+
+// client is a Slack client for obtaining a User.
+type client struct {
+	json *jsonclient.Client
+}
+
+// newClient returns a new Slack client.
+func newClient(httpClient *http.Client) *client {
+	return &client{
+		json: jsonclient.New(httpClient, slackAPI),
+	}
+}
+
+// UserInfo gets the Slack User with openid.connect.userInfo.
+// https://api.slack.com/methods/openid.connect.userInfo
+func (c *client) UserInfo(ctx context.Context) (*User, *http.Response, error) {
+	body := new(userInfoResponse)
+	resp, err := c.json.Get(ctx, "openid.connect.userInfo", nil, body, body)
+	if err == nil && !body.OK {
+		err = &APIError{Code: body.Error}
+	}
+	return &body.User, resp, err
+}
@@ -0,0 +1,46 @@
+package soundcloud
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/dghubble/gologin"
+)
+
+// unexported key type prevents collisions
+type key int
+
+const (
+	userKey key = iota
+)
+
+// WithUser returns a copy of ctx that stores the SoundCloud User and its gologin
+// Profile.
+func WithUser(ctx context.Context, user *User) context.Context {
+	ctx = gologin.WithProfile(ctx, newProfile(user))
+	return context.WithValue(ctx, userKey, user)
+}
+
+// UserFromContext returns the SoundCloud User from the ctx.
+func UserFromContext(ctx context.Context) (*User, error) {
+	user, ok := ctx.Value(userKey).(*User)
+	if !ok {
+		return nil, fmt.Errorf("soundcloud: Context missing SoundCloud User")
+	}
+	return user, nil
+}
+
+// newProfile returns the gologin Profile of the SoundCloud User.
+func newProfile(user *User) *gologin.Profile {
+	if user == nil {
+		return nil
+	}
+	return &gologin.Profile{
+		Provider:  ProviderName,
+		ID:        strconv.FormatInt(user.ID, 10),
+		Name:      gologin.DisplayName(user.FullName, user.Username),
+		AvatarURL: user.AvatarURL,
+		Raw:       gologin.ProfileRaw(user, "id", "full_name", "avatar_url"),
+	}
+}
@@ -0,0 +1,2 @@
+// Package soundcloud provides SoundCloud OAuth2 login and callback handlers.
+package soundcloud
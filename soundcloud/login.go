@@ -0,0 +1,125 @@
+package soundcloud
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/internal"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"golang.org/x/oauth2"
+)
+
+// ProviderName is the provider name set in the ctx (see
+// gologin.ProviderFromContext) and the Profile.
+const ProviderName = "soundcloud"
+
+// SoundCloud login errors
+var (
+	ErrUnableToGetSoundCloudUser = errors.New("soundcloud: unable to get SoundCloud User")
+)
+
+// Endpoint is SoundCloud's OAuth2 endpoint.
+var Endpoint = oauth2.Endpoint{
+	AuthURL:   "https://secure.soundcloud.com/authorize",
+	TokenURL:  "https://secure.soundcloud.com/oauth/token",
+	AuthStyle: oauth2.AuthStyleInParams,
+}
+
+// StateHandler checks for a state cookie. If found, the state value is read
+// and added to the ctx. Otherwise, a non-guessable value is added to the ctx
+// and to a (short-lived) state cookie issued to the requester.
+//
+// Implements OAuth 2 RFC 6749 10.12 CSRF Protection. If you wish to issue
+// state params differently, write a http.Handler which sets the ctx state,
+// using oauth2 WithState(ctx, state) since it is required by LoginHandler
+// and CallbackHandler.
+func StateHandler(config gologin.CookieConfig, success http.Handler) http.Handler {
+	return oauth2Login.StateHandler(config, success)
+}
+
+// LoginHandler handles SoundCloud login requests by reading the state value
+// from the ctx and redirecting requests to the AuthURL with that state value.
+// Any AuthCodeOptions are added to the AuthURL.
+//
+// SoundCloud requires PKCE, so prefer LoginHandlerWithPKCE.
+func LoginHandler(config *oauth2.Config, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	return oauth2Login.LoginHandler(config, failure, opts...)
+}
+
+// LoginHandlerWithPKCE handles SoundCloud login requests like LoginHandler,
+// but also sends a PKCE code challenge, which SoundCloud requires. The PKCE
+// code verifier is kept in a cookie per the pkceConfig, whose Name must
+// differ from the state cookie.
+func LoginHandlerWithPKCE(config *oauth2.Config, pkceConfig gologin.CookieConfig, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	return oauth2Login.LoginHandlerWithPKCE(config, pkceConfig, failure, opts...)
+}
+
+// CallbackHandler handles SoundCloud redirection URI requests and adds the
+// SoundCloud access token and User to the ctx. If authentication succeeds,
+// handling delegates to the success handler, otherwise to the failure
+// handler. Any AuthCodeOptions are sent with the token exchange.
+//
+// SoundCloud rotates refresh tokens, so the ctx Token's refresh token may be
+// used only once. Persist the whole Token, and replace it with the Token of
+// each refresh.
+func CallbackHandler(config *oauth2.Config, success, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	success = soundCloudHandler(success, failure)
+	return oauth2Login.CallbackHandler(config, success, failure, opts...)
+}
+
+// CallbackHandlerWithPKCE handles SoundCloud redirection URI requests like
+// CallbackHandler, but sends the PKCE code verifier cookie set by
+// LoginHandlerWithPKCE with the token exchange.
+func CallbackHandlerWithPKCE(config *oauth2.Config, pkceConfig gologin.CookieConfig, success, failure http.Handler) http.Handler {
+	success = soundCloudHandler(success, failure)
+	return oauth2Login.CallbackHandlerWithPKCE(config, pkceConfig, success, failure)
+}
+
+// soundCloudHandler is a http.Handler that gets the OAuth2 Token from the ctx
+// to get the corresponding SoundCloud User. If successful, the User is added
+// to the ctx and the success handler is called. Otherwise, the failure
+// handler is called.
+func soundCloudHandler(success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		// the client sets the "OAuth" Authorization scheme itself, and must
+		// not refresh the (single use) refresh token
+		user, resp, err := newClient(internal.ContextClient(ctx)).Me(ctx, token.AccessToken)
+		err = validateResponse(user, resp, err)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		ctx = WithUser(ctx, user)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// validateResponse returns an error if the given SoundCloud User, raw
+// http.Response, or error are unexpected. Returns nil if they are valid, or a
+// *gologin.Error which preserves the cause and status code.
+func validateResponse(user *User, resp *http.Response, err error) error {
+	var status int
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	if err != nil || status != http.StatusOK {
+		return &gologin.Error{Provider: "soundcloud", Op: "get user", StatusCode: status, Err: err, Kind: ErrUnableToGetSoundCloudUser}
+	}
+	if user == nil || user.ID == 0 {
+		return &gologin.Error{Provider: "soundcloud", Op: "get user", StatusCode: status, Kind: ErrUnableToGetSoundCloudUser}
+	}
+	return nil
+}
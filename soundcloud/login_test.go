@@ -0,0 +1,204 @@
+package soundcloud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/dghubble/gologin"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+var testPKCEConfig = gologin.CookieConfig{
+	Name:   "soundcloud-pkce",
+	Path:   "/",
+	MaxAge: 60,
+}
+
+func testConfig() *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     "client_id",
+		ClientSecret: "client_secret",
+		RedirectURL:  "https://example.com/soundcloud/callback",
+		Endpoint:     Endpoint,
+	}
+}
+
+func TestLoginHandlerWithPKCE(t *testing.T) {
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// LoginHandlerWithPKCE assert that:
+	// - redirects to the SoundCloud AuthURL with the state
+	// - the S256 PKCE code challenge is sent and its verifier kept in a cookie
+	loginHandler := LoginHandlerWithPKCE(testConfig(), testPKCEConfig, failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	ctx := oauth2Login.WithState(context.Background(), "d4e5f6")
+	loginHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, http.StatusFound, w.Code)
+	location, err := url.Parse(w.HeaderMap.Get("Location"))
+	if assert.Nil(t, err) {
+		assert.Equal(t, "secure.soundcloud.com", location.Host)
+		assert.Equal(t, "/authorize", location.Path)
+		assert.Equal(t, "d4e5f6", location.Query().Get("state"))
+		assert.Equal(t, "S256", location.Query().Get("code_challenge_method"))
+		assert.NotEmpty(t, location.Query().Get("code_challenge"))
+	}
+	cookies := (&http.Response{Header: w.Header()}).Cookies()
+	if assert.Len(t, cookies, 1) {
+		assert.Equal(t, "soundcloud-pkce", cookies[0].Name)
+		assert.NotEmpty(t, cookies[0].Value)
+	}
+}
+
+func TestCallbackHandlerWithPKCE(t *testing.T) {
+	proxyClient, server := newSoundCloudTestServer("some_verifier")
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithState(ctx, "d4e5f6")
+
+	expectedUser := &User{
+		ID:           3207,
+		URN:          "soundcloud:users:3207",
+		Username:     "jwagener",
+		FullName:     "Johannes Wagener",
+		AvatarURL:    "https://i1.sndcdn.com/avatars-000000003207-abcdef-large.jpg",
+		PermalinkURL: "https://soundcloud.com/jwagener",
+	}
+	success := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "any-token", token.AccessToken)
+			// refresh tokens are single use, the full Token must be kept
+			assert.Equal(t, "single-use-refresh", token.RefreshToken)
+			assert.False(t, token.Expiry.IsZero())
+		}
+		user, err := UserFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, expectedUser, user)
+		}
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// CallbackHandlerWithPKCE assert that:
+	// - the PKCE code verifier is sent with the token exchange
+	// - the access token is sent with the "OAuth" scheme to get the User
+	// - success handler is called
+	// - SoundCloud Token (with its refresh token) and User are added to the
+	// ctx of the success handler
+	callbackHandler := CallbackHandlerWithPKCE(testConfig(), testPKCEConfig, http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	req.AddCookie(&http.Cookie{Name: "soundcloud-pkce", Value: "some_verifier"})
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestCallbackHandlerWithPKCE_MissingVerifier(t *testing.T) {
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		assert.Equal(t, oauth2Login.ErrMissingPKCEVerifier, err)
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// CallbackHandlerWithPKCE without the verifier cookie, assert that:
+	// - failure handler is called with ErrMissingPKCEVerifier
+	callbackHandler := CallbackHandlerWithPKCE(testConfig(), testPKCEConfig, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	callbackHandler.ServeHTTP(w, req.WithContext(oauth2Login.WithState(context.Background(), "d4e5f6")))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestSoundCloudHandler_MissingCtxToken(t *testing.T) {
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "oauth2: Context missing Token", err.Error())
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// SoundCloudHandler called without Token in ctx, assert that:
+	// - failure handler is called
+	// - error about ctx missing token is added to the failure handler ctx
+	soundCloudHandler := soundCloudHandler(success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	soundCloudHandler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestSoundCloudHandler_Unauthorized(t *testing.T) {
+	proxyClient, server := newSoundCloudTestServer("")
+	defer server.Close()
+	// SoundCloud requests use the ctx client's Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "expired-token"})
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		assert.True(t, errors.Is(err, ErrUnableToGetSoundCloudUser))
+		var gologinErr *gologin.Error
+		if assert.True(t, errors.As(err, &gologinErr)) {
+			assert.Equal(t, http.StatusUnauthorized, gologinErr.StatusCode)
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// SoundCloudHandler with a token SoundCloud rejects, assert that:
+	// - failure handler is called
+	// - error cannot get SoundCloud User, with the 401 status, is added to
+	// the failure handler ctx
+	soundCloudHandler := soundCloudHandler(success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	soundCloudHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestMe_OAuthScheme(t *testing.T) {
+	proxyClient, server := newSoundCloudTestServer("")
+	defer server.Close()
+
+	// SoundCloud responds 401 to Bearer tokens
+	resp, err := proxyClient.Do(bearerRequest("https://api.soundcloud.com/me", "any-token"))
+	if assert.Nil(t, err) {
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		resp.Body.Close()
+	}
+	// Me sends the "OAuth" scheme
+	user, resp, err := newClient(proxyClient).Me(context.Background(), "any-token")
+	assert.Nil(t, validateResponse(user, resp, err))
+}
+
+// bearerRequest returns a GET request with the Bearer access token.
+func bearerRequest(url, accessToken string) *http.Request {
+	req, _ := http.NewRequest("GET", url, nil)
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	return req
+}
+
+func TestValidateResponse(t *testing.T) {
+	validUser := &User{ID: 3207, Username: "jwagener"}
+	validResponse := &http.Response{StatusCode: 200}
+	invalidResponse := &http.Response{StatusCode: 401}
+	assert.Equal(t, nil, validateResponse(validUser, validResponse, nil))
+	assert.True(t, errors.Is(validateResponse(validUser, validResponse, fmt.Errorf("Server error")), ErrUnableToGetSoundCloudUser))
+	assert.True(t, errors.Is(validateResponse(validUser, invalidResponse, nil), ErrUnableToGetSoundCloudUser))
+	assert.True(t, errors.Is(validateResponse(&User{}, validResponse, nil), ErrUnableToGetSoundCloudUser))
+	assert.True(t, errors.Is(validateResponse(nil, validResponse, nil), ErrUnableToGetSoundCloudUser))
+}
@@ -0,0 +1,45 @@
+package soundcloud
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/dghubble/gologin/testutils"
+)
+
+const (
+	// testUserJSON is a SoundCloud me response.
+	testUserJSON = `{"id": 3207, "urn": "soundcloud:users:3207", "kind": "user", "username": "jwagener", "full_name": "Johannes Wagener", "avatar_url": "https://i1.sndcdn.com/avatars-000000003207-abcdef-large.jpg", "permalink_url": "https://soundcloud.com/jwagener", "followers_count": 1632}`
+	// testUnauthorizedJSON is a SoundCloud 401 response.
+	testUnauthorizedJSON = `{"code": 401, "message": "", "link": "https://developers.soundcloud.com/docs/api/explorer/open-api", "status": "401 - Unauthorized", "errors": [], "error": null}`
+)
+
+// newSoundCloudTestServer returns a new httptest.Server which mocks the
+// SoundCloud token and me endpoints and a client which proxies requests to
+// the server. The token endpoint requires the verifier as the PKCE
+// code_verifier and responds with a rotated refresh token. Like SoundCloud,
+// the me endpoint responds 401 unless the "any-token" access token is sent
+// with the "OAuth" authorization scheme. The caller must close the server.
+func newSoundCloudTestServer(verifier string) (*http.Client, *httptest.Server) {
+	client, mux, server := testutils.TestServer()
+	mux.HandleFunc("/oauth/token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		if r.PostFormValue("client_id") != "client_id" || r.PostFormValue("code_verifier") != verifier {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, `{"error": "invalid_grant"}`)
+			return
+		}
+		fmt.Fprintf(w, `{"access_token": "any-token", "expires_in": 3599, "refresh_token": "single-use-refresh", "scope": "", "token_type": "bearer"}`)
+	})
+	mux.HandleFunc("/me", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		if r.Header.Get("Authorization") != "OAuth any-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprintf(w, testUnauthorizedJSON)
+			return
+		}
+		fmt.Fprintf(w, testUserJSON)
+	})
+	return client, server
+}
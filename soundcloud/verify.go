@@ -0,0 +1,47 @@
+package soundcloud
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/dghubble/gologin/internal/jsonclient"
+)
+
+const soundCloudAPI = "https://api.soundcloud.com/"
+
+// User is a SoundCloud user.
+type User struct {
+	ID int64 `json:"id"`
+	// URN is the user's identifier (e.g. soundcloud:users:3207)
+	URN          string `json:"urn"`
+	Username     string `json:"username"`
+	FullName     string `json:"full_name"`
+	AvatarURL    string `json:"avatar_url"`
+	PermalinkURL string `json:"permalink_url"`
+}
+
+// client is a SoundCloud client for obtaining the current User.
+type client struct {
+	json *jsonclient.Client
+}
+
+// newClient returns a new SoundCloud client.
+func newClient(httpClient *http.Client) *client {
+	return &client{
+		json: jsonclient.New(httpClient, soundCloudAPI).Set("Accept", "application/json; charset=utf-8"),
+	}
+}
+
+// Me returns the User of the access token. SoundCloud expects the token with
+// the "OAuth" authorization scheme, rather than "Bearer".
+// https://developers.soundcloud.com/docs/api/explorer/open-api#/me/get_me
+func (c *client) Me(ctx context.Context, accessToken string) (*User, *http.Response, error) {
+	user := new(User)
+	req, err := c.json.NewRequest(ctx, "GET", "me", nil, nil)
+	if err != nil {
+		return user, nil, err
+	}
+	req.Header.Set("Authorization", "OAuth "+accessToken)
+	resp, err := c.json.Do(req, user, nil)
+	return user, resp, err
+}
@@ -0,0 +1,50 @@
+package spotify
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dghubble/gologin"
+)
+
+// unexported key type prevents collisions
+type key int
+
+const (
+	userKey key = iota
+)
+
+// WithUser returns a copy of ctx that stores the Spotify User and its gologin
+// Profile.
+func WithUser(ctx context.Context, user *User) context.Context {
+	ctx = gologin.WithProfile(ctx, newProfile(user))
+	return context.WithValue(ctx, userKey, user)
+}
+
+// UserFromContext returns the Spotify User from the ctx.
+func UserFromContext(ctx context.Context) (*User, error) {
+	user, ok := ctx.Value(userKey).(*User)
+	if !ok {
+		return nil, fmt.Errorf("spotify: Context missing Spotify User")
+	}
+	return user, nil
+}
+
+// newProfile returns the gologin Profile of the Spotify User.
+func newProfile(user *User) *gologin.Profile {
+	if user == nil {
+		return nil
+	}
+	var avatarURL string
+	if len(user.Images) > 0 {
+		avatarURL = user.Images[0].URL
+	}
+	return &gologin.Profile{
+		Provider:  ProviderName,
+		ID:        user.ID,
+		Email:     user.Email,
+		Name:      user.DisplayName,
+		AvatarURL: avatarURL,
+		Raw:       gologin.ProfileRaw(user, "id", "email", "display_name"),
+	}
+}
@@ -0,0 +1,2 @@
+// Package spotify provides Spotify OAuth2 login and callback handlers.
+package spotify
@@ -0,0 +1,125 @@
+package spotify
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/internal"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"golang.org/x/oauth2"
+)
+
+// ProviderName is the provider name set in the ctx (see
+// gologin.ProviderFromContext) and the Profile.
+const ProviderName = "spotify"
+
+// Spotify login errors
+var (
+	ErrUnableToGetSpotifyUser = errors.New("spotify: unable to get Spotify User")
+	ErrUserNotRegistered      = errors.New("spotify: Spotify User is not registered for the development mode app")
+)
+
+// Endpoint is Spotify's OAuth2 endpoint.
+var Endpoint = oauth2.Endpoint{
+	AuthURL:  "https://accounts.spotify.com/authorize",
+	TokenURL: "https://accounts.spotify.com/api/token",
+}
+
+// StateHandler checks for a state cookie. If found, the state value is read
+// and added to the ctx. Otherwise, a non-guessable value is added to the ctx
+// and to a (short-lived) state cookie issued to the requester.
+//
+// Implements OAuth 2 RFC 6749 10.12 CSRF Protection. If you wish to issue
+// state params differently, write a http.Handler which sets the ctx state,
+// using oauth2 WithState(ctx, state) since it is required by LoginHandler
+// and CallbackHandler.
+func StateHandler(config gologin.CookieConfig, success http.Handler) http.Handler {
+	return oauth2Login.StateHandler(config, success)
+}
+
+// LoginHandler handles Spotify login requests by reading the state value from
+// the ctx and redirecting requests to the AuthURL with that state value.
+// Any AuthCodeOptions are added to the AuthURL.
+//
+// Scopes should include "user-read-email" for the User Email and
+// "user-read-private" for the User Country and Product.
+func LoginHandler(config *oauth2.Config, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	return oauth2Login.LoginHandler(config, failure, opts...)
+}
+
+// CallbackHandler handles Spotify redirection URI requests and adds the
+// Spotify Token and User to the ctx. If authentication succeeds, handling
+// delegates to the success handler, otherwise to the failure handler.
+// Any AuthCodeOptions are sent with the token exchange.
+//
+// Spotify access tokens expire after an hour. The ctx Token includes the
+// refresh token and expiry so it can be refreshed (e.g. by an oauth2
+// TokenSource or oauth2 RefreshHandler).
+func CallbackHandler(config *oauth2.Config, success, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	success = spotifyHandler(config, success, failure)
+	return oauth2Login.CallbackHandler(config, success, failure, opts...)
+}
+
+// spotifyHandler is a http.Handler that gets the OAuth2 Token from the ctx
+// to get the corresponding Spotify User. If successful, the User is added to
+// the ctx and the success handler is called. Otherwise, the failure handler
+// is called.
+//
+// Users who are not allowlisted for a development mode app fail with
+// ErrUserNotRegistered.
+func spotifyHandler(config *oauth2.Config, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		httpClient := internal.OAuth2Client(ctx, config, token)
+		user, resp, err := newClient(httpClient).CurrentUser(ctx)
+		err = validateResponse(user, resp, err)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		ctx = WithUser(ctx, user)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// validateResponse returns an error if the given Spotify User, raw
+// http.Response, or error are unexpected. Returns nil if they are valid, or a
+// *gologin.Error which preserves the cause and status code. Development mode
+// rejections have Kind ErrUserNotRegistered.
+func validateResponse(user *User, resp *http.Response, err error) error {
+	var status int
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	if status == http.StatusForbidden && isNotRegistered(err) {
+		return &gologin.Error{Provider: "spotify", Op: "get user", StatusCode: status, Err: err, Kind: ErrUserNotRegistered}
+	}
+	if err != nil || status != http.StatusOK {
+		return &gologin.Error{Provider: "spotify", Op: "get user", StatusCode: status, Err: err, Kind: ErrUnableToGetSpotifyUser}
+	}
+	if user == nil || user.ID == "" {
+		return &gologin.Error{Provider: "spotify", Op: "get user", StatusCode: status, Kind: ErrUnableToGetSpotifyUser}
+	}
+	return nil
+}
+
+// isNotRegistered returns true if the error is Spotify's rejection of users
+// missing from a development mode app's allowlist (e.g. "Check settings on
+// developer.spotify.com/dashboard, the user may not be registered.").
+func isNotRegistered(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && strings.Contains(strings.ToLower(apiErr.Message), "registered")
+}
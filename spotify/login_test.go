@@ -0,0 +1,167 @@
+package spotify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dghubble/gologin"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+const (
+	testUserJSON = `{"id": "wizzler", "display_name": "Wizzler", "email": "wizzler@example.com", "country": "SE", "product": "premium", "images": [{"url": "https://i.scdn.co/image/wizzler.jpg", "height": 300, "width": 300}], "type": "user", "uri": "spotify:user:wizzler"}`
+	// user missing from a development mode app's allowlist
+	testNotRegisteredJSON = `{"error": {"status": 403, "message": "Check settings on developer.spotify.com/dashboard, the user may not be registered."}}`
+)
+
+func testConfig() *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     "client_id",
+		ClientSecret: "client_secret",
+		RedirectURL:  "https://example.com/spotify/callback",
+		Endpoint:     Endpoint,
+		Scopes:       []string{"user-read-email", "user-read-private"},
+	}
+}
+
+func TestCallbackHandler(t *testing.T) {
+	proxyClient, server := newSpotifyTestServer(http.StatusOK, testUserJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithState(ctx, "d4e5f6")
+
+	success := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "any-token", token.AccessToken)
+			assert.Equal(t, "any-refresh-token", token.RefreshToken)
+			assert.WithinDuration(t, time.Now().Add(time.Hour), token.Expiry, time.Minute)
+		}
+		user, err := UserFromContext(ctx)
+		if assert.Nil(t, err) {
+			expectedUser := &User{
+				ID:          "wizzler",
+				DisplayName: "Wizzler",
+				Email:       "wizzler@example.com",
+				Country:     "SE",
+				Product:     "premium",
+				Images:      []Image{{URL: "https://i.scdn.co/image/wizzler.jpg", Height: 300, Width: 300}},
+			}
+			assert.Equal(t, expectedUser, user)
+		}
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// CallbackHandler assert that:
+	// - the Token (with refresh token and expiry) is added to the ctx
+	// - the Spotify User is obtained from /v1/me
+	// - success handler is called with the Token and User in the ctx
+	callbackHandler := CallbackHandler(testConfig(), http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestSpotifyHandler_UserNotRegistered(t *testing.T) {
+	proxyClient, server := newSpotifyTestServer(http.StatusForbidden, testNotRegisteredJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.True(t, errors.Is(err, ErrUserNotRegistered))
+			assert.False(t, errors.Is(err, ErrUnableToGetSpotifyUser))
+			var apiErr *APIError
+			if assert.True(t, errors.As(err, &apiErr)) {
+				assert.Equal(t, 403, apiErr.Status)
+			}
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// SpotifyHandler for a User missing from a development mode app's allowlist, assert that:
+	// - failure handler is called
+	// - error Spotify User not registered added to the failure handler ctx
+	spotifyHandler := spotifyHandler(testConfig(), success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	spotifyHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestSpotifyHandler_MissingCtxToken(t *testing.T) {
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "oauth2: Context missing Token", err.Error())
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// SpotifyHandler called without Token in ctx, assert that:
+	// - failure handler is called
+	// - error about ctx missing token is added to the failure handler ctx
+	spotifyHandler := spotifyHandler(testConfig(), success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	spotifyHandler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestSpotifyHandler_ErrorGettingUser(t *testing.T) {
+	proxyClient, server := newSpotifyTestServer(http.StatusUnauthorized, `{"error": {"status": 401, "message": "Invalid access token"}}`)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.True(t, errors.Is(err, ErrUnableToGetSpotifyUser))
+			assert.Equal(t, "spotify: 401 Invalid access token", errors.Unwrap(err).Error())
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// SpotifyHandler cannot get Spotify User, assert that:
+	// - failure handler is called
+	// - error cannot get Spotify User added to the failure handler ctx
+	spotifyHandler := spotifyHandler(testConfig(), success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	spotifyHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestValidateResponse(t *testing.T) {
+	validUser := &User{ID: "wizzler"}
+	validResponse := &http.Response{StatusCode: 200}
+	invalidResponse := &http.Response{StatusCode: 500}
+	forbiddenResponse := &http.Response{StatusCode: 403}
+	assert.Equal(t, nil, validateResponse(validUser, validResponse, nil))
+	assert.True(t, errors.Is(validateResponse(validUser, validResponse, fmt.Errorf("Server error")), ErrUnableToGetSpotifyUser))
+	assert.True(t, errors.Is(validateResponse(validUser, invalidResponse, nil), ErrUnableToGetSpotifyUser))
+	assert.True(t, errors.Is(validateResponse(&User{}, validResponse, nil), ErrUnableToGetSpotifyUser))
+	assert.True(t, errors.Is(validateResponse(nil, validResponse, nil), ErrUnableToGetSpotifyUser))
+	assert.True(t, errors.Is(validateResponse(nil, forbiddenResponse, &APIError{Status: 403, Message: "User not registered in the Developer Dashboard"}), ErrUserNotRegistered))
+	assert.True(t, errors.Is(validateResponse(nil, forbiddenResponse, &APIError{Status: 403, Message: "Insufficient client scope"}), ErrUnableToGetSpotifyUser))
+}
@@ -0,0 +1,27 @@
+package spotify
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/dghubble/gologin/testutils"
+)
+
+// newSpotifyTestServer returns a new httptest.Server which mocks the Spotify
+// token and /v1/me endpoints and a client which proxies requests to the
+// server. The /v1/me endpoint responds with the given status and json data.
+// The caller must close the server.
+func newSpotifyTestServer(status int, jsonData string) (*http.Client, *httptest.Server) {
+	client, mux, server := testutils.TestServer()
+	mux.HandleFunc("/api/token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token": "any-token", "token_type": "Bearer", "scope": "user-read-email user-read-private", "expires_in": 3600, "refresh_token": "any-refresh-token"}`)
+	})
+	mux.HandleFunc("/v1/me", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		fmt.Fprintf(w, jsonData)
+	})
+	return client, server
+}
@@ -0,0 +1,68 @@
+package spotify
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/dghubble/gologin/internal/jsonclient"
+)
+
+const spotifyAPI = "https://api.spotify.com/v1/"
+
+// User is a Spotify user's profile.
+type User struct {
+	ID          string `json:"id"`
+	DisplayName string `json:"display_name"`
+	Email       string `json:"email"`
+	Country     string `json:"country"`
+	// Product is the subscription level (e.g. "free" or "premium")
+	Product string  `json:"product"`
+	Images  []Image `json:"images"`
+}
+
+// Image is a Spotify profile image.
+type Image struct {
+	URL    string `json:"url"`
+	Height int    `json:"height"`
+	Width  int    `json:"width"`
+}
+
+// APIError is a Spotify Web API error.
+type APIError struct {
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("spotify: %d %s", e.Status, e.Message)
+}
+
+// errorResponse is a Spotify Web API error response.
+type errorResponse struct {
+	Error *APIError `json:"error"`
+}
+
+// client is a Spotify client for obtaining a User.
+type client struct {
+	json *jsonclient.Client
+}
+
+// newClient returns a new Spotify client.
+func newClient(httpClient *http.Client) *client {
+	return &client{
+		json: jsonclient.New(httpClient, spotifyAPI),
+	}
+}
+
+// CurrentUser gets the current Spotify User.
+// https://developer.spotify.com/documentation/web-api/reference/get-current-users-profile
+func (c *client) CurrentUser(ctx context.Context) (*User, *http.Response, error) {
+	user := new(User)
+	errResp := new(errorResponse)
+	resp, err := c.json.Get(ctx, "me", nil, user, errResp)
+	if err == nil && errResp.Error != nil {
+		err = errResp.Error
+	}
+	return user, resp, err
+}
@@ -0,0 +1,46 @@
+package stackexchange
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/dghubble/gologin"
+)
+
+// unexported key type prevents collisions
+type key int
+
+const (
+	userKey key = iota
+)
+
+// WithUser returns a copy of ctx that stores the Stack Exchange User and its gologin
+// Profile.
+func WithUser(ctx context.Context, user *User) context.Context {
+	ctx = gologin.WithProfile(ctx, newProfile(user))
+	return context.WithValue(ctx, userKey, user)
+}
+
+// UserFromContext returns the Stack Exchange User from the ctx.
+func UserFromContext(ctx context.Context) (*User, error) {
+	user, ok := ctx.Value(userKey).(*User)
+	if !ok {
+		return nil, fmt.Errorf("stackexchange: Context missing Stack Exchange User")
+	}
+	return user, nil
+}
+
+// newProfile returns the gologin Profile of the Stack Exchange User.
+func newProfile(user *User) *gologin.Profile {
+	if user == nil {
+		return nil
+	}
+	return &gologin.Profile{
+		Provider:  ProviderName,
+		ID:        strconv.Itoa(user.UserID),
+		Name:      user.DisplayName,
+		AvatarURL: user.ProfileImage,
+		Raw:       gologin.ProfileRaw(user, "user_id", "display_name", "profile_image"),
+	}
+}
@@ -0,0 +1,3 @@
+// Package stackexchange provides Stack Exchange OAuth2 login and callback
+// handlers.
+package stackexchange
@@ -0,0 +1,167 @@
+package stackexchange
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/internal"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"golang.org/x/oauth2"
+)
+
+// ProviderName is the provider name set in the ctx (see
+// gologin.ProviderFromContext) and the Profile.
+const ProviderName = "stackexchange"
+
+// defaultSite is the Stack Exchange site of Users if none is configured.
+const defaultSite = "stackoverflow"
+
+// Stack Exchange login errors
+var (
+	ErrUnableToGetStackExchangeUser = errors.New("stackexchange: unable to get Stack Exchange User")
+	ErrNoSiteProfile                = errors.New("stackexchange: Stack Exchange User has no profile on the site")
+)
+
+// Endpoint is Stack Exchange's OAuth2 endpoint. The TokenURL returns JSON
+// (rather than form encoded) token responses.
+var Endpoint = oauth2.Endpoint{
+	AuthURL:   "https://stackoverflow.com/oauth",
+	TokenURL:  "https://stackoverflow.com/oauth/access_token/json",
+	AuthStyle: oauth2.AuthStyleInParams,
+}
+
+// Config configures Stack Exchange login.
+type Config struct {
+	// Site is the API site parameter of the site to get the User from
+	// (e.g. "superuser"). Defaults to "stackoverflow".
+	Site string
+	// Key is the app's key (not its secret), which grants a higher request
+	// quota.
+	Key string
+}
+
+// site returns the Config Site or the default site.
+func (c Config) site() string {
+	if c.Site == "" {
+		return defaultSite
+	}
+	return c.Site
+}
+
+// ProfileError is the error of a valid Token whose user has no profile on
+// the Config Site.
+type ProfileError struct {
+	// Site is the API site parameter
+	Site string
+	// QuotaMax and QuotaRemaining are the app key's daily request quota
+	QuotaMax       int
+	QuotaRemaining int
+}
+
+func (e *ProfileError) Error() string {
+	return fmt.Sprintf("%s %s (quota_remaining %d)", ErrNoSiteProfile, e.Site, e.QuotaRemaining)
+}
+
+// Is returns true for ErrNoSiteProfile.
+func (e *ProfileError) Is(target error) bool {
+	return target == ErrNoSiteProfile
+}
+
+// StateHandler checks for a state cookie. If found, the state value is read
+// and added to the ctx. Otherwise, a non-guessable value is added to the ctx
+// and to a (short-lived) state cookie issued to the requester.
+//
+// Implements OAuth 2 RFC 6749 10.12 CSRF Protection. If you wish to issue
+// state params differently, write a http.Handler which sets the ctx state,
+// using oauth2 WithState(ctx, state) since it is required by LoginHandler
+// and CallbackHandler.
+func StateHandler(config gologin.CookieConfig, success http.Handler) http.Handler {
+	return oauth2Login.StateHandler(config, success)
+}
+
+// LoginHandler handles Stack Exchange login requests by reading the state
+// value from the ctx and redirecting requests to the AuthURL with that state
+// value. Any AuthCodeOptions are added to the AuthURL.
+func LoginHandler(config *oauth2.Config, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	return oauth2Login.LoginHandler(config, failure, opts...)
+}
+
+// CallbackHandler handles Stack Exchange redirection URI requests and adds
+// the Stack Exchange access token and Stack Overflow User to the ctx. If
+// authentication succeeds, handling delegates to the success handler,
+// otherwise to the failure handler.
+// Any AuthCodeOptions are sent with the token exchange.
+func CallbackHandler(config *oauth2.Config, success, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	return CallbackHandlerWithConfig(config, Config{}, success, failure, opts...)
+}
+
+// CallbackHandlerWithConfig handles Stack Exchange redirection URI requests
+// like CallbackHandler, but gets the User from the Config Site with the
+// Config Key. If the user has no profile on the site, the failure handler is
+// called with a *ProfileError (matching ErrNoSiteProfile).
+func CallbackHandlerWithConfig(config *oauth2.Config, seConfig Config, success, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	success = stackExchangeHandler(seConfig, success, failure)
+	return oauth2Login.CallbackHandler(config, success, failure, opts...)
+}
+
+// stackExchangeHandler is a http.Handler that gets the OAuth2 Token from the
+// ctx to get the corresponding Stack Exchange User. If successful, the User
+// is added to the ctx and the success handler is called. Otherwise, the
+// failure handler is called.
+func stackExchangeHandler(seConfig Config, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		params := &meParams{
+			Site:        seConfig.site(),
+			Key:         seConfig.Key,
+			AccessToken: token.AccessToken,
+		}
+		me, resp, err := newClient(internal.ContextClient(ctx)).Me(ctx, params)
+		err = validateResponse(me, resp, err)
+		if err == nil && len(me.Items) == 0 {
+			err = &ProfileError{Site: params.Site, QuotaMax: me.QuotaMax, QuotaRemaining: me.QuotaRemaining}
+		}
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		ctx = WithUser(ctx, &me.Items[0])
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// validateResponse returns an error if the given Stack Exchange response,
+// raw http.Response, or error are unexpected. Returns nil if they are valid,
+// or a *gologin.Error which preserves the cause (e.g. an *APIError with the
+// quota) and status code.
+func validateResponse(me *wrapper, resp *http.Response, err error) error {
+	var status int
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	if err != nil || status != http.StatusOK {
+		return &gologin.Error{Provider: "stackexchange", Op: "get user", StatusCode: status, Err: err, Kind: ErrUnableToGetStackExchangeUser}
+	}
+	if me == nil {
+		return &gologin.Error{Provider: "stackexchange", Op: "get user", StatusCode: status, Kind: ErrUnableToGetStackExchangeUser}
+	}
+	for _, user := range me.Items {
+		if user.UserID == 0 {
+			return &gologin.Error{Provider: "stackexchange", Op: "get user", StatusCode: status, Kind: ErrUnableToGetStackExchangeUser}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package stackexchange
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dghubble/gologin"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+var testSEConfig = Config{Site: "superuser", Key: "app_key"}
+
+func testConfig() *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     "client_id",
+		ClientSecret: "client_secret",
+		RedirectURL:  "https://example.com/stackexchange/callback",
+		Endpoint:     Endpoint,
+	}
+}
+
+func TestCallbackHandler(t *testing.T) {
+	proxyClient, server := newStackExchangeTestServer(testMeJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithState(ctx, "d4e5f6")
+
+	expectedUser := &User{
+		UserID:       22656,
+		AccountID:    11683,
+		DisplayName:  "Jon Skeet",
+		ProfileImage: "https://www.gravatar.com/avatar/6d8ebb117e8d83d74ea95fbdd0f87e13",
+		Reputation:   1454978,
+	}
+	success := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "any-token", token.AccessToken)
+		}
+		user, err := UserFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, expectedUser, user)
+		}
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// CallbackHandlerWithConfig assert that:
+	// - the User is requested from the Config Site with the Config Key and
+	// the access token as query parameters
+	// - the gzip compressed response is decompressed
+	// - success handler is called
+	// - Stack Exchange Token and User are added to the ctx of the success
+	// handler
+	callbackHandler := CallbackHandlerWithConfig(testConfig(), testSEConfig, http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestStackExchangeHandler_NoSiteProfile(t *testing.T) {
+	proxyClient, server := newStackExchangeTestServer(testNoProfileJSON)
+	defer server.Close()
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		assert.True(t, errors.Is(err, ErrNoSiteProfile))
+		assert.Equal(t, &ProfileError{Site: "superuser", QuotaMax: 10000, QuotaRemaining: 9997}, err)
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// StackExchangeHandler for a user without a profile on the site, assert
+	// that:
+	// - failure handler is called with a ProfileError with the quota
+	stackExchangeHandler := stackExchangeHandler(testSEConfig, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	stackExchangeHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestStackExchangeHandler_MissingCtxToken(t *testing.T) {
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "oauth2: Context missing Token", err.Error())
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// StackExchangeHandler called without Token in ctx, assert that:
+	// - failure handler is called
+	// - error about ctx missing token is added to the failure handler ctx
+	stackExchangeHandler := stackExchangeHandler(testSEConfig, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	stackExchangeHandler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestStackExchangeHandler_ErrorGettingUser(t *testing.T) {
+	proxyClient, server := newStackExchangeTestServer(testMeJSON)
+	defer server.Close()
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "invalid-token"})
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.True(t, errors.Is(err, ErrUnableToGetStackExchangeUser))
+			var apiErr *APIError
+			if assert.True(t, errors.As(err, &apiErr)) {
+				assert.Equal(t, &APIError{ID: 401, Name: "access_token_invalid", Message: "No matching access token found", QuotaMax: 10000, QuotaRemaining: 9996}, apiErr)
+			}
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// StackExchangeHandler cannot get Stack Exchange User, assert that:
+	// - failure handler is called
+	// - error cannot get Stack Exchange User added to the failure handler
+	// ctx, with the APIError and quota as the cause
+	stackExchangeHandler := stackExchangeHandler(testSEConfig, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	stackExchangeHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestConfigSite(t *testing.T) {
+	assert.Equal(t, "stackoverflow", Config{}.site())
+	assert.Equal(t, "superuser", testSEConfig.site())
+}
+
+func TestValidateResponse(t *testing.T) {
+	validMe := &wrapper{Items: []User{{UserID: 22656, DisplayName: "Jon Skeet"}}}
+	validResponse := &http.Response{StatusCode: 200}
+	invalidResponse := &http.Response{StatusCode: 500}
+	assert.Equal(t, nil, validateResponse(validMe, validResponse, nil))
+	assert.Equal(t, nil, validateResponse(&wrapper{}, validResponse, nil))
+	assert.True(t, errors.Is(validateResponse(validMe, validResponse, fmt.Errorf("Server error")), ErrUnableToGetStackExchangeUser))
+	assert.True(t, errors.Is(validateResponse(validMe, invalidResponse, nil), ErrUnableToGetStackExchangeUser))
+	assert.True(t, errors.Is(validateResponse(&wrapper{Items: []User{{DisplayName: "Jon Skeet"}}}, validResponse, nil), ErrUnableToGetStackExchangeUser))
+	assert.True(t, errors.Is(validateResponse(nil, validResponse, nil), ErrUnableToGetStackExchangeUser))
+}
@@ -0,0 +1,51 @@
+package stackexchange
+
+import (
+	"compress/gzip"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/dghubble/gologin/testutils"
+)
+
+const (
+	// testMeJSON is a /me response.
+	testMeJSON = `{"items": [{"user_id": 22656, "account_id": 11683, "display_name": "Jon Skeet", "profile_image": "https://www.gravatar.com/avatar/6d8ebb117e8d83d74ea95fbdd0f87e13", "reputation": 1454978, "user_type": "registered"}], "has_more": false, "quota_max": 10000, "quota_remaining": 9998}`
+	// testNoProfileJSON is a /me response of a user without a profile on the
+	// site.
+	testNoProfileJSON = `{"items": [], "has_more": false, "quota_max": 10000, "quota_remaining": 9997}`
+	// testInvalidTokenJSON is a Stack Exchange error response.
+	testInvalidTokenJSON = `{"error_id": 401, "error_message": "No matching access token found", "error_name": "access_token_invalid", "quota_max": 10000, "quota_remaining": 9996}`
+)
+
+// newStackExchangeTestServer returns a new httptest.Server which mocks the
+// Stack Exchange token and /me endpoints and a client which proxies requests
+// to the server. Like Stack Exchange, /me responses are gzip compressed
+// regardless of the request Accept-Encoding and access tokens must be query
+// parameters. The /me endpoint responds with the given json data for the
+// "superuser" site, or an error for tokens other than "any-token". The
+// caller must close the server.
+func newStackExchangeTestServer(jsonData string) (*http.Client, *httptest.Server) {
+	client, mux, server := testutils.TestServer()
+	mux.HandleFunc("/oauth/access_token/json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token": "any-token", "expires": 86400}`)
+	})
+	mux.HandleFunc("/2.3/me", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Header().Set("Content-Encoding", "gzip")
+		query := r.URL.Query()
+		data := jsonData
+		status := http.StatusOK
+		if query.Get("access_token") != "any-token" || query.Get("key") != "app_key" || query.Get("site") != "superuser" {
+			data = testInvalidTokenJSON
+			status = http.StatusBadRequest
+		}
+		w.WriteHeader(status)
+		gz := gzip.NewWriter(w)
+		defer gz.Close()
+		fmt.Fprintf(gz, data)
+	})
+	return client, server
+}
@@ -0,0 +1,147 @@
+package stackexchange
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/dghubble/gologin/internal/jsonclient"
+)
+
+const stackExchangeAPI = "https://api.stackexchange.com/2.3/"
+
+// User is a Stack Exchange user on a site.
+type User struct {
+	// UserID is the user's ID on the site
+	UserID int `json:"user_id"`
+	// AccountID is the user's network-wide Stack Exchange account ID
+	AccountID    int    `json:"account_id"`
+	DisplayName  string `json:"display_name"`
+	ProfileImage string `json:"profile_image"`
+	Reputation   int    `json:"reputation"`
+}
+
+// wrapper is the Stack Exchange API common response wrapper, of successful
+// and error responses.
+// https://api.stackexchange.com/docs/wrapper
+type wrapper struct {
+	Items          []User `json:"items"`
+	QuotaMax       int    `json:"quota_max"`
+	QuotaRemaining int    `json:"quota_remaining"`
+	ErrorID        int    `json:"error_id"`
+	ErrorName      string `json:"error_name"`
+	ErrorMessage   string `json:"error_message"`
+}
+
+// APIError is a Stack Exchange API error response.
+type APIError struct {
+	ID      int
+	Name    string
+	Message string
+	// QuotaMax and QuotaRemaining are the app key's daily request quota
+	QuotaMax       int
+	QuotaRemaining int
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("stackexchange: %s (%s %d, quota_remaining %d)", e.Message, e.Name, e.ID, e.QuotaRemaining)
+}
+
+// meParams are query parameters of /me requests.
+type meParams struct {
+	Site        string
+	Key         string
+	AccessToken string
+}
+
+// values returns the query parameters, without an empty key.
+func (p *meParams) values() url.Values {
+	values := url.Values{"site": {p.Site}, "access_token": {p.AccessToken}}
+	if p.Key != "" {
+		values.Set("key", p.Key)
+	}
+	return values
+}
+
+// client is a Stack Exchange client for obtaining a User.
+type client struct {
+	json *jsonclient.Client
+}
+
+// newClient returns a new Stack Exchange client. Stack Exchange gzip
+// compresses responses unconditionally, so responses are decompressed
+// regardless of the transport.
+func newClient(httpClient *http.Client) *client {
+	gzipClient := *httpClient
+	gzipClient.Transport = &gzipTransport{base: httpClient.Transport}
+	return &client{
+		json: jsonclient.New(&gzipClient, stackExchangeAPI).Set("Accept-Encoding", "gzip"),
+	}
+}
+
+// Me gets the current Stack Exchange User's items on the site, with the
+// quota. The access token and key are sent as query parameters.
+// https://api.stackexchange.com/docs/me
+func (c *client) Me(ctx context.Context, params *meParams) (*wrapper, *http.Response, error) {
+	resp := new(wrapper)
+	apiErr := new(wrapper)
+	httpResp, err := c.json.Get(ctx, "me", params.values(), resp, apiErr)
+	if err == nil && apiErr.ErrorID != 0 {
+		resp = apiErr
+		err = &APIError{
+			ID:             apiErr.ErrorID,
+			Name:           apiErr.ErrorName,
+			Message:        apiErr.ErrorMessage,
+			QuotaMax:       apiErr.QuotaMax,
+			QuotaRemaining: apiErr.QuotaRemaining,
+		}
+	}
+	return resp, httpResp, err
+}
+
+// gzipTransport is a http.RoundTripper which decompresses gzip encoded
+// response bodies the base RoundTripper did not already decompress.
+type gzipTransport struct {
+	base http.RoundTripper
+}
+
+// RoundTrip calls the base RoundTripper (or http.DefaultTransport) and
+// decompresses gzip encoded responses.
+func (t *gzipTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	resp, err := base.RoundTrip(req)
+	if err != nil || !strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		return resp, err
+	}
+	reader, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	resp.Body = &gzipReadCloser{Reader: reader, body: resp.Body}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return resp, nil
+}
+
+// gzipReadCloser reads a decompressed body and closes the gzip reader and
+// the underlying body.
+type gzipReadCloser struct {
+	*gzip.Reader
+	body io.ReadCloser
+}
+
+// Close closes the gzip reader and the underlying body.
+func (r *gzipReadCloser) Close() error {
+	r.Reader.Close()
+	return r.body.Close()
+}
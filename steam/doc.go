@@ -0,0 +1,2 @@
+// Package steam provides Steam OpenID 2.0 login and callback handlers.
+package steam
@@ -0,0 +1,276 @@
+package steam
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/internal"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+)
+
+// ProviderName is the provider name set in the ctx (see
+// gologin.ProviderFromContext) and the Profile.
+const ProviderName = "steam"
+
+// Steam login errors
+var (
+	ErrInvalidResponse      = errors.New("steam: invalid Steam OpenID response")
+	ErrInvalidSignature     = errors.New("steam: Steam OpenID signature verification failed")
+	ErrInvalidClaimedID     = errors.New("steam: invalid Steam OpenID claimed_id")
+	ErrUnableToGetSteamUser = errors.New("steam: unable to get Steam User")
+	ErrInvalidReturnURL     = errors.New("steam: Config ReturnURL must be an absolute URL")
+)
+
+// requiredSignedFields are the assertion fields (without the "openid."
+// prefix) which must be signed, so check_authentication vouches for the
+// values checked locally.
+// https://openid.net/specs/openid-authentication-2_0.html#positive_assertions
+var requiredSignedFields = []string{"op_endpoint", "claimed_id", "identity", "return_to", "response_nonce", "assoc_handle"}
+
+// claimedIDPattern matches Steam claimed_id URLs, capturing the 64-bit
+// SteamID.
+var claimedIDPattern = regexp.MustCompile(`^https://steamcommunity\.com/openid/id/([0-9]{17})$`)
+
+// Config configures Steam login.
+type Config struct {
+	// ReturnURL is the absolute URL of the CallbackHandler, to which Steam
+	// redirects users.
+	ReturnURL string
+	// Realm is the URL pattern Steam shows users they are signing in to.
+	// Defaults to the scheme and host of the ReturnURL.
+	Realm string
+	// APIKey is an optional Steam Web API key to get the User's player
+	// summary. Without one, only the User SteamID is set.
+	APIKey string
+}
+
+// StateHandler checks for a state cookie. If found, the state value is read
+// and added to the ctx. Otherwise, a non-guessable value is added to the ctx
+// and to a (short-lived) state cookie issued to the requester.
+//
+// Steam OpenID has no state parameter, so LoginHandler adds the state to the
+// (signed) return URL and CallbackHandler checks it to prevent CSRF. Use
+// oauth2 WithState(ctx, state) to issue state params differently.
+func StateHandler(config gologin.CookieConfig, success http.Handler) http.Handler {
+	return oauth2Login.StateHandler(config, success)
+}
+
+// LoginHandler handles Steam login requests by reading the state value from
+// the ctx and redirecting requests to the Steam OpenID login URL with a
+// return URL which carries that state value.
+func LoginHandler(config Config, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		state, err := oauth2Login.StateFromContext(ctx)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		loginURL, err := config.loginURL(state)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		http.Redirect(w, req, loginURL, http.StatusFound)
+	}
+	return http.HandlerFunc(fn)
+}
+
+// CallbackHandler handles Steam return URL requests by checking the state,
+// verifying the OpenID assertion with Steam (check_authentication), and
+// adding the Steam User to the ctx. If authentication succeeds, handling
+// delegates to the success handler, otherwise to the failure handler.
+//
+// Assertions with repeated openid parameters or an openid.signed list which
+// omits a checked field fail with ErrInvalidResponse. Assertions Steam does
+// not verify fail with ErrInvalidSignature and unexpected claimed_id values
+// with ErrInvalidClaimedID. The StateHandler state cookie is expired once the
+// state is compared, so each state is used for one callback.
+func CallbackHandler(config Config, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		params := req.URL.Query()
+		state, err := validateAssertion(config, params)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		ownerState, err := oauth2Login.StateFromContext(ctx)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		// expire the state cookie once compared, even if the callback fails
+		oauth2Login.ExpireStateCookie(ctx, w)
+		if state == "" || !internal.EqualSecrets(state, ownerState) {
+			ctx = gologin.WithError(ctx, oauth2Login.ErrInvalidState)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		steamID, err := parseClaimedID(params)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		httpClient := internal.ContextClient(ctx)
+		valid, resp, err := checkAuthentication(httpClient, params)
+		err = validateSignature(valid, resp, err)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		user := &User{SteamID: steamID}
+		if config.APIKey != "" {
+			user, resp, err = newClient(httpClient).PlayerSummary(ctx, config.APIKey, steamID)
+			err = validateResponse(user, steamID, resp, err)
+			if err != nil {
+				ctx = gologin.WithError(ctx, err)
+				failure.ServeHTTP(w, req.WithContext(ctx))
+				return
+			}
+		}
+		ctx = WithUser(ctx, user)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// loginURL returns the Steam OpenID login URL with a return URL carrying the
+// state.
+func (c Config) loginURL(state string) (string, error) {
+	returnTo, err := url.Parse(c.ReturnURL)
+	if err != nil || !returnTo.IsAbs() {
+		return "", ErrInvalidReturnURL
+	}
+	query := returnTo.Query()
+	query.Set("state", state)
+	returnTo.RawQuery = query.Encode()
+	realm := c.Realm
+	if realm == "" {
+		realm = returnTo.Scheme + "://" + returnTo.Host
+	}
+	params := url.Values{
+		"openid.ns":         {openIDNamespace},
+		"openid.mode":       {"checkid_setup"},
+		"openid.return_to":  {returnTo.String()},
+		"openid.realm":      {realm},
+		"openid.identity":   {identifierSelect},
+		"openid.claimed_id": {identifierSelect},
+	}
+	return steamOpenIDURL + "?" + params.Encode(), nil
+}
+
+// validateAssertion returns the state of the return URL if the params are a
+// positive assertion from Steam for the Config ReturnURL whose signature
+// covers the checked fields. Otherwise, returns ErrInvalidResponse.
+func validateAssertion(config Config, params url.Values) (string, error) {
+	// all openid params are sent to check_authentication, so each must have
+	// the single value checked here
+	for name, values := range params {
+		if strings.HasPrefix(name, "openid.") && len(values) != 1 {
+			return "", ErrInvalidResponse
+		}
+	}
+	if !signsFields(params.Get("openid.signed"), requiredSignedFields) {
+		return "", ErrInvalidResponse
+	}
+	if params.Get("openid.ns") != openIDNamespace || params.Get("openid.mode") != "id_res" || params.Get("openid.op_endpoint") != steamOpenIDURL {
+		return "", ErrInvalidResponse
+	}
+	expected, err := url.Parse(config.ReturnURL)
+	if err != nil {
+		return "", ErrInvalidResponse
+	}
+	returnTo, err := url.Parse(params.Get("openid.return_to"))
+	if err != nil || returnTo.Scheme != expected.Scheme || returnTo.Host != expected.Host || returnTo.Path != expected.Path {
+		return "", ErrInvalidResponse
+	}
+	states := returnTo.Query()["state"]
+	if len(states) != 1 {
+		return "", ErrInvalidResponse
+	}
+	return states[0], nil
+}
+
+// signsFields returns true if the openid.signed list includes the fields.
+func signsFields(signed string, fields []string) bool {
+	signedFields := strings.Split(signed, ",")
+	for _, field := range fields {
+		found := false
+		for _, signedField := range signedFields {
+			if signedField == field {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// parseClaimedID returns the 64-bit SteamID of the assertion claimed_id, or
+// ErrInvalidClaimedID if it isn't a Steam identity.
+func parseClaimedID(params url.Values) (string, error) {
+	claimedID := params.Get("openid.claimed_id")
+	if claimedID != params.Get("openid.identity") {
+		return "", ErrInvalidClaimedID
+	}
+	match := claimedIDPattern.FindStringSubmatch(claimedID)
+	if match == nil {
+		return "", ErrInvalidClaimedID
+	}
+	return match[1], nil
+}
+
+// validateSignature returns an error if the check_authentication result, raw
+// http.Response, or error are unexpected. Returns nil if Steam verified the
+// assertion, ErrInvalidSignature if it did not, or a *gologin.Error of
+// ErrInvalidSignature if the request failed.
+func validateSignature(valid bool, resp *http.Response, err error) error {
+	var status int
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	if err != nil || status != http.StatusOK {
+		return &gologin.Error{Provider: "steam", Op: "check authentication", StatusCode: status, Err: err, Kind: ErrInvalidSignature}
+	}
+	if !valid {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// validateResponse returns an error if the given Steam User, raw
+// http.Response, or error are unexpected. Returns nil if they are valid, or a
+// *gologin.Error which preserves the cause and status code.
+func validateResponse(user *User, steamID string, resp *http.Response, err error) error {
+	var status int
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	if err != nil || status != http.StatusOK {
+		return &gologin.Error{Provider: "steam", Op: "get user", StatusCode: status, Err: err, Kind: ErrUnableToGetSteamUser}
+	}
+	if user == nil || user.SteamID != steamID {
+		return &gologin.Error{Provider: "steam", Op: "get user", StatusCode: status, Kind: ErrUnableToGetSteamUser}
+	}
+	return nil
+}
@@ -0,0 +1,280 @@
+package steam
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/dghubble/gologin"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+var testSteamConfig = Config{
+	ReturnURL: "https://example.com/steam/callback",
+	APIKey:    "api_key",
+}
+
+// testCallbackRequest returns a callback request of the assertion params.
+func testCallbackRequest(params url.Values) *http.Request {
+	req, _ := http.NewRequest("GET", "/steam/callback?"+params.Encode(), nil)
+	return req
+}
+
+func TestLoginHandler(t *testing.T) {
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// LoginHandler assert that:
+	// - redirects to the Steam OpenID login URL
+	// - the return URL carries the state and the realm defaults to its host
+	loginHandler := LoginHandler(testSteamConfig, failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	ctx := oauth2Login.WithState(context.Background(), "d4e5f6")
+	loginHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, http.StatusFound, w.Code)
+	location, err := url.Parse(w.HeaderMap.Get("Location"))
+	if assert.Nil(t, err) {
+		assert.Equal(t, "steamcommunity.com", location.Host)
+		assert.Equal(t, "/openid/login", location.Path)
+		query := location.Query()
+		assert.Equal(t, openIDNamespace, query.Get("openid.ns"))
+		assert.Equal(t, "checkid_setup", query.Get("openid.mode"))
+		assert.Equal(t, "https://example.com/steam/callback?state=d4e5f6", query.Get("openid.return_to"))
+		assert.Equal(t, "https://example.com", query.Get("openid.realm"))
+		assert.Equal(t, identifierSelect, query.Get("openid.identity"))
+		assert.Equal(t, identifierSelect, query.Get("openid.claimed_id"))
+	}
+}
+
+func TestLoginHandler_Errors(t *testing.T) {
+	cases := []struct {
+		config Config
+		ctx    context.Context
+		err    string
+	}{
+		{testSteamConfig, context.Background(), "oauth2: Context missing state value"},
+		{Config{ReturnURL: "/steam/callback"}, oauth2Login.WithState(context.Background(), "d4e5f6"), ErrInvalidReturnURL.Error()},
+	}
+	for _, c := range cases {
+		failure := func(w http.ResponseWriter, req *http.Request) {
+			err := gologin.ErrorFromContext(req.Context())
+			if assert.NotNil(t, err) {
+				assert.Equal(t, c.err, err.Error())
+			}
+			fmt.Fprintf(w, "failure handler called")
+		}
+
+		// LoginHandler without state or with an invalid ReturnURL, assert
+		// that:
+		// - failure handler is called
+		loginHandler := LoginHandler(c.config, http.HandlerFunc(failure))
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		loginHandler.ServeHTTP(w, req.WithContext(c.ctx))
+		assert.Equal(t, "failure handler called", w.Body.String())
+	}
+}
+
+func TestCallbackHandler(t *testing.T) {
+	proxyClient, server := newSteamTestServer(testPlayerSummariesJSON)
+	defer server.Close()
+	// Steam requests use the ctx client's Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithState(ctx, "d4e5f6")
+
+	expectedUser := &User{
+		SteamID:     testSteamID,
+		PersonaName: "Robin",
+		AvatarFull:  "https://avatars.steamstatic.com/f1dd60a188883caf82d0cbfccfe6aba0af1732d4_full.jpg",
+		ProfileURL:  "https://steamcommunity.com/id/robinwalker/",
+	}
+	success := func(w http.ResponseWriter, req *http.Request) {
+		user, err := UserFromContext(req.Context())
+		if assert.Nil(t, err) {
+			assert.Equal(t, expectedUser, user)
+		}
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// CallbackHandler assert that:
+	// - the assertion is verified with check_authentication
+	// - success handler is called
+	// - Steam User player summary is added to the ctx of the success handler
+	callbackHandler := CallbackHandler(testSteamConfig, http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	callbackHandler.ServeHTTP(w, testCallbackRequest(testAssertion("d4e5f6")).WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestCallbackHandler_NoAPIKey(t *testing.T) {
+	proxyClient, server := newSteamTestServer(testPlayerSummariesJSON)
+	defer server.Close()
+	// Steam requests use the ctx client's Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithState(ctx, "d4e5f6")
+
+	success := func(w http.ResponseWriter, req *http.Request) {
+		user, err := UserFromContext(req.Context())
+		if assert.Nil(t, err) {
+			assert.Equal(t, &User{SteamID: testSteamID}, user)
+		}
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// CallbackHandler without an APIKey, assert that:
+	// - success handler is called with a User of only the SteamID
+	callbackHandler := CallbackHandler(Config{ReturnURL: testSteamConfig.ReturnURL}, http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	callbackHandler.ServeHTTP(w, testCallbackRequest(testAssertion("d4e5f6")).WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestCallbackHandler_Errors(t *testing.T) {
+	proxyClient, server := newSteamTestServer(testPlayerSummariesJSON)
+	defer server.Close()
+	// Steam requests use the ctx client's Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithState(ctx, "d4e5f6")
+
+	with := func(name, value string) url.Values {
+		params := testAssertion("d4e5f6")
+		params.Set(name, value)
+		return params
+	}
+	withClaimedID := func(claimedID string) url.Values {
+		params := with("openid.claimed_id", claimedID)
+		params.Set("openid.identity", claimedID)
+		return params
+	}
+	withAdded := func(name, value string) url.Values {
+		params := testAssertion("d4e5f6")
+		params.Add(name, value)
+		return params
+	}
+	cases := []struct {
+		name   string
+		config Config
+		params url.Values
+		err    error
+	}{
+		{"repeated claimed_id", testSteamConfig, withAdded("openid.claimed_id", "https://steamcommunity.com/openid/id/76561197960287930"), ErrInvalidResponse},
+		{"repeated sig", testSteamConfig, withAdded("openid.sig", "forged"), ErrInvalidResponse},
+		{"claimed_id not signed", testSteamConfig, with("openid.signed", "signed,op_endpoint,identity,return_to,response_nonce,assoc_handle"), ErrInvalidResponse},
+		{"return_to not signed", testSteamConfig, with("openid.signed", "signed,op_endpoint,claimed_id,identity,response_nonce,assoc_handle"), ErrInvalidResponse},
+		{"nothing signed", testSteamConfig, with("openid.signed", ""), ErrInvalidResponse},
+		{"repeated return_to state", testSteamConfig, with("openid.return_to", "https://example.com/steam/callback?state=d4e5f6&state=other"), ErrInvalidResponse},
+		{"canceled", testSteamConfig, with("openid.mode", "cancel"), ErrInvalidResponse},
+		{"other provider", testSteamConfig, with("openid.op_endpoint", "https://openid.example.com/login"), ErrInvalidResponse},
+		{"other return URL", testSteamConfig, with("openid.return_to", "https://evil.example.com/steam/callback?state=d4e5f6"), ErrInvalidResponse},
+		{"invalid state", testSteamConfig, testAssertion("other"), oauth2Login.ErrInvalidState},
+		{"invalid signature", testSteamConfig, with("openid.sig", "forged"), ErrInvalidSignature},
+		{"other claimed_id host", testSteamConfig, withClaimedID("https://evil.example.com/openid/id/" + testSteamID), ErrInvalidClaimedID},
+		{"non-numeric claimed_id", testSteamConfig, withClaimedID("https://steamcommunity.com/openid/id/robinwalker"), ErrInvalidClaimedID},
+		{"claimed_id not identity", testSteamConfig, with("openid.identity", "https://steamcommunity.com/openid/id/76561197960287930"), ErrInvalidClaimedID},
+		{"invalid API key", Config{ReturnURL: testSteamConfig.ReturnURL, APIKey: "invalid"}, testAssertion("d4e5f6"), ErrUnableToGetSteamUser},
+	}
+	for _, c := range cases {
+		success := testutils.AssertSuccessNotCalled(t)
+		failure := func(w http.ResponseWriter, req *http.Request) {
+			err := gologin.ErrorFromContext(req.Context())
+			assert.True(t, errors.Is(err, c.err), c.name)
+			fmt.Fprintf(w, "failure handler called")
+		}
+
+		// CallbackHandler with an invalid assertion, assert that:
+		// - failure handler is called with the distinct error
+		callbackHandler := CallbackHandler(c.config, success, http.HandlerFunc(failure))
+		w := httptest.NewRecorder()
+		callbackHandler.ServeHTTP(w, testCallbackRequest(c.params).WithContext(ctx))
+		assert.Equal(t, "failure handler called", w.Body.String(), c.name)
+	}
+}
+
+func TestCallbackHandler_ExpiresStateCookie(t *testing.T) {
+	proxyClient, server := newSteamTestServer(testPlayerSummariesJSON)
+	defer server.Close()
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	stateConfig := gologin.DebugOnlyCookieConfig
+	success := func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(w, "failure handler called")
+	}
+	handler := StateHandler(stateConfig, CallbackHandler(testSteamConfig, http.HandlerFunc(success), http.HandlerFunc(failure)))
+
+	cases := []struct {
+		state    string
+		expected string
+	}{
+		{"d4e5f6", "success handler called"},
+		{"other", "failure handler called"},
+	}
+	for _, c := range cases {
+		// StateHandler and CallbackHandler with a state cookie, assert that:
+		// - the state cookie is expired, even if the callback fails
+		req := testCallbackRequest(testAssertion(c.state)).WithContext(ctx)
+		req.AddCookie(&http.Cookie{Name: stateConfig.Name, Value: "d4e5f6"})
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		assert.Equal(t, c.expected, w.Body.String())
+		cookies := w.Result().Cookies()
+		if assert.Len(t, cookies, 1) {
+			assert.Equal(t, stateConfig.Name, cookies[0].Name)
+			assert.Equal(t, -1, cookies[0].MaxAge)
+		}
+	}
+
+	// - a replayed callback without the state cookie fails
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, testCallbackRequest(testAssertion("d4e5f6")).WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestCallbackHandler_MissingCtxState(t *testing.T) {
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "oauth2: Context missing state value", err.Error())
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// CallbackHandler called without state in ctx, assert that:
+	// - failure handler is called
+	// - error about ctx missing state is added to the failure handler ctx
+	callbackHandler := CallbackHandler(testSteamConfig, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	callbackHandler.ServeHTTP(w, testCallbackRequest(testAssertion("d4e5f6")))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestValidateSignature(t *testing.T) {
+	validResponse := &http.Response{StatusCode: 200}
+	invalidResponse := &http.Response{StatusCode: 500}
+	assert.Equal(t, nil, validateSignature(true, validResponse, nil))
+	assert.Equal(t, ErrInvalidSignature, validateSignature(false, validResponse, nil))
+	assert.True(t, errors.Is(validateSignature(true, validResponse, fmt.Errorf("Server error")), ErrInvalidSignature))
+	assert.True(t, errors.Is(validateSignature(true, invalidResponse, nil), ErrInvalidSignature))
+}
+
+func TestValidateResponse(t *testing.T) {
+	validUser := &User{SteamID: testSteamID, PersonaName: "Robin"}
+	validResponse := &http.Response{StatusCode: 200}
+	invalidResponse := &http.Response{StatusCode: 500}
+	assert.Equal(t, nil, validateResponse(validUser, testSteamID, validResponse, nil))
+	assert.True(t, errors.Is(validateResponse(validUser, testSteamID, validResponse, fmt.Errorf("Server error")), ErrUnableToGetSteamUser))
+	assert.True(t, errors.Is(validateResponse(validUser, testSteamID, invalidResponse, nil), ErrUnableToGetSteamUser))
+	assert.True(t, errors.Is(validateResponse(nil, testSteamID, validResponse, nil), ErrUnableToGetSteamUser))
+	assert.True(t, errors.Is(validateResponse(validUser, "76561197960287930", validResponse, nil), ErrUnableToGetSteamUser))
+}
@@ -0,0 +1,62 @@
+package steam
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	"github.com/dghubble/gologin/testutils"
+)
+
+const (
+	// testSteamID is the 64-bit SteamID of the test user.
+	testSteamID = "76561197960435530"
+	// testPlayerSummariesJSON is a GetPlayerSummaries response.
+	testPlayerSummariesJSON = `{"response": {"players": [{"steamid": "76561197960435530", "communityvisibilitystate": 3, "profilestate": 1, "personaname": "Robin", "profileurl": "https://steamcommunity.com/id/robinwalker/", "avatar": "https://avatars.steamstatic.com/f1dd60a188883caf82d0cbfccfe6aba0af1732d4.jpg", "avatarfull": "https://avatars.steamstatic.com/f1dd60a188883caf82d0cbfccfe6aba0af1732d4_full.jpg", "personastate": 0}]}}`
+)
+
+// testAssertion returns the params of a positive assertion for the
+// testSteamID, returning to the testSteamConfig ReturnURL with the state.
+func testAssertion(state string) url.Values {
+	return url.Values{
+		"openid.ns":             {openIDNamespace},
+		"openid.mode":           {"id_res"},
+		"openid.op_endpoint":    {steamOpenIDURL},
+		"openid.claimed_id":     {"https://steamcommunity.com/openid/id/" + testSteamID},
+		"openid.identity":       {"https://steamcommunity.com/openid/id/" + testSteamID},
+		"openid.return_to":      {"https://example.com/steam/callback?state=" + state},
+		"openid.response_nonce": {"2024-01-02T03:04:05Z8xN3u0qP2G9VpL0b6o7VwQ2m0c4="},
+		"openid.assoc_handle":   {"1234567890"},
+		"openid.signed":         {"signed,op_endpoint,claimed_id,identity,return_to,response_nonce,assoc_handle"},
+		"openid.sig":            {"W0xl3sZ5qKH0S3eT3bJ5sup8oa4="},
+	}
+}
+
+// newSteamTestServer returns a new httptest.Server which mocks the Steam
+// OpenID check_authentication and GetPlayerSummaries endpoints and a client
+// which proxies requests to the server. check_authentication responds that
+// assertions with the testAssertion signature are valid. GetPlayerSummaries
+// responds with the given json data for the "api_key". The caller must close
+// the server.
+func newSteamTestServer(jsonData string) (*http.Client, *httptest.Server) {
+	client, mux, server := testutils.TestServer()
+	mux.HandleFunc("/openid/login", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain;charset=utf-8")
+		valid := r.Method == "POST" &&
+			r.PostFormValue("openid.mode") == "check_authentication" &&
+			r.PostFormValue("openid.sig") == "W0xl3sZ5qKH0S3eT3bJ5sup8oa4="
+		fmt.Fprintf(w, "ns:%s\nis_valid:%t\n", openIDNamespace, valid)
+	})
+	mux.HandleFunc("/ISteamUser/GetPlayerSummaries/v0002/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("key") != "api_key" {
+			w.Header().Set("Content-Type", "text/html")
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprintf(w, "<html><head><title>Forbidden</title></head><body><h1>Forbidden</h1></body></html>")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		fmt.Fprintf(w, jsonData)
+	})
+	return client, server
+}
@@ -0,0 +1,88 @@
+package steam
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/dghubble/gologin/internal/jsonclient"
+)
+
+const (
+	steamOpenIDURL = "https://steamcommunity.com/openid/login"
+	steamAPI       = "https://api.steampowered.com/"
+	// openIDNamespace is the OpenID 2.0 openid.ns value.
+	openIDNamespace = "http://specs.openid.net/auth/2.0"
+	// identifierSelect lets Steam select the identifier of the signed in
+	// user.
+	identifierSelect = "http://specs.openid.net/auth/2.0/identifier_select"
+)
+
+// User is a Steam user. Only the SteamID is set unless the Config has an
+// APIKey to get the player summary.
+type User struct {
+	// SteamID is the 64-bit SteamID of the user
+	SteamID     string `json:"steamid"`
+	PersonaName string `json:"personaname"`
+	AvatarFull  string `json:"avatarfull"`
+	ProfileURL  string `json:"profileurl"`
+}
+
+// playerSummariesResponse is a GetPlayerSummaries response.
+type playerSummariesResponse struct {
+	Response struct {
+		Players []User `json:"players"`
+	} `json:"response"`
+}
+
+// client is a Steam Web API client for obtaining a User.
+type client struct {
+	json *jsonclient.Client
+}
+
+// newClient returns a new Steam Web API client.
+func newClient(httpClient *http.Client) *client {
+	return &client{
+		json: jsonclient.New(httpClient, steamAPI),
+	}
+}
+
+// PlayerSummary gets the Steam User with the 64-bit SteamID.
+// https://developer.valvesoftware.com/wiki/Steam_Web_API#GetPlayerSummaries_.28v0002.29
+func (c *client) PlayerSummary(ctx context.Context, apiKey, steamID string) (*User, *http.Response, error) {
+	summaries := new(playerSummariesResponse)
+	params := url.Values{"key": {apiKey}, "steamids": {steamID}}
+	resp, err := c.json.Get(ctx, "ISteamUser/GetPlayerSummaries/v0002/", params, summaries, nil)
+	if len(summaries.Response.Players) == 0 {
+		return nil, resp, err
+	}
+	return &summaries.Response.Players[0], resp, err
+}
+
+// checkAuthentication asks Steam to verify the signature of the positive
+// assertion params and returns true if Steam responds is_valid:true.
+// https://openid.net/specs/openid-authentication-2_0.html#verifying_signatures
+func checkAuthentication(httpClient *http.Client, params url.Values) (bool, *http.Response, error) {
+	form := url.Values{}
+	for name, values := range params {
+		if strings.HasPrefix(name, "openid.") {
+			form[name] = values
+		}
+	}
+	form.Set("openid.mode", "check_authentication")
+	resp, err := httpClient.PostForm(steamOpenIDURL, form)
+	if err != nil {
+		return false, resp, err
+	}
+	defer resp.Body.Close()
+	// key-value form, one "key:value" per line
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if strings.TrimSpace(scanner.Text()) == "is_valid:true" {
+			return true, resp, nil
+		}
+	}
+	return false, resp, scanner.Err()
+}
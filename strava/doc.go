@@ -0,0 +1,2 @@
+// Package strava provides Strava OAuth2 login and callback handlers.
+package strava
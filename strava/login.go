@@ -0,0 +1,128 @@
+package strava
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/internal"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"golang.org/x/oauth2"
+)
+
+// ProviderName is the provider name set in the ctx (see
+// gologin.ProviderFromContext) and the Profile.
+const ProviderName = "strava"
+
+// Strava login errors
+var (
+	ErrUnableToGetStravaUser = errors.New("strava: unable to get Strava User")
+)
+
+// Endpoint is Strava's OAuth2 endpoint.
+var Endpoint = oauth2.Endpoint{
+	AuthURL:   "https://www.strava.com/oauth/authorize",
+	TokenURL:  "https://www.strava.com/oauth/token",
+	AuthStyle: oauth2.AuthStyleInParams,
+}
+
+// ApprovalPromptForce is an AuthCodeOption which sets approval_prompt=force
+// so Strava shows the authorization page even if the user already authorized
+// the app.
+var ApprovalPromptForce = oauth2.SetAuthURLParam("approval_prompt", "force")
+
+// Scopes returns an AuthCodeOption which requests the scopes (e.g. "read",
+// "activity:read_all") as the comma-separated scope Strava expects. It
+// replaces any oauth2 Config Scopes, which would be space-separated.
+func Scopes(scopes ...string) oauth2.AuthCodeOption {
+	return oauth2.SetAuthURLParam("scope", strings.Join(scopes, ","))
+}
+
+// StateHandler checks for a state cookie. If found, the state value is read
+// and added to the ctx. Otherwise, a non-guessable value is added to the ctx
+// and to a (short-lived) state cookie issued to the requester.
+//
+// Implements OAuth 2 RFC 6749 10.12 CSRF Protection. If you wish to issue
+// state params differently, write a http.Handler which sets the ctx state,
+// using oauth2 WithState(ctx, state) since it is required by LoginHandler
+// and CallbackHandler.
+func StateHandler(config gologin.CookieConfig, success http.Handler) http.Handler {
+	return oauth2Login.StateHandler(config, success)
+}
+
+// LoginHandler handles Strava login requests by reading the state value from
+// the ctx and redirecting requests to the AuthURL with that state value.
+// Any AuthCodeOptions are added to the AuthURL. Pass Scopes to request
+// multiple scopes and ApprovalPromptForce to always show the authorization
+// page.
+func LoginHandler(config *oauth2.Config, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	return oauth2Login.LoginHandler(config, failure, opts...)
+}
+
+// CallbackHandler handles Strava redirection URI requests and adds the Strava
+// access token and User to the ctx. If authentication succeeds, handling
+// delegates to the success handler, otherwise to the failure handler.
+// Any AuthCodeOptions are sent with the token exchange.
+//
+// Strava access tokens expire after six hours. The ctx Token includes the
+// refresh token and Expiry (and the expires_at Extra).
+func CallbackHandler(config *oauth2.Config, success, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	success = stravaHandler(config, success, failure)
+	return oauth2Login.CallbackHandler(config, success, failure, opts...)
+}
+
+// stravaHandler is a http.Handler that gets the OAuth2 Token from the ctx to
+// get the corresponding Strava User. If successful, the User is added to the
+// ctx and the success handler is called. Otherwise, the failure handler is
+// called.
+//
+// The User is read from the athlete embedded in the token response. If the
+// Token has no athlete (e.g. a refreshed Token), the User is read from
+// /api/v3/athlete instead.
+func stravaHandler(config *oauth2.Config, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		user := userFromAthlete(token.Extra("athlete"))
+		if user == nil {
+			httpClient := internal.OAuth2Client(ctx, config, token)
+			var resp *http.Response
+			user, resp, err = newClient(httpClient).CurrentAthlete(ctx)
+			err = validateResponse(user, resp, err)
+			if err != nil {
+				ctx = gologin.WithError(ctx, err)
+				failure.ServeHTTP(w, req.WithContext(ctx))
+				return
+			}
+		}
+		ctx = WithUser(ctx, user)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// validateResponse returns an error if the given Strava User, raw
+// http.Response, or error are unexpected. Returns nil if they are valid, or a
+// *gologin.Error which preserves the cause and status code.
+func validateResponse(user *User, resp *http.Response, err error) error {
+	var status int
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	if err != nil || status != http.StatusOK {
+		return &gologin.Error{Provider: "strava", Op: "get user", StatusCode: status, Err: err, Kind: ErrUnableToGetStravaUser}
+	}
+	if user == nil || user.ID == 0 {
+		return &gologin.Error{Provider: "strava", Op: "get user", StatusCode: status, Kind: ErrUnableToGetStravaUser}
+	}
+	return nil
+}
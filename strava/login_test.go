@@ -0,0 +1,178 @@
+package strava
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/dghubble/gologin"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+const (
+	testTokenJSON      = `{"token_type": "Bearer", "expires_at": 1700021600, "expires_in": 21600, "refresh_token": "any-refresh", "access_token": "any-token", "athlete": {"id": 134815, "username": "marianne_t", "resource_state": 2, "firstname": "Marianne", "lastname": "Teutenberg", "city": "San Francisco", "profile": "https://dgalywyr863hv.cloudfront.net/pictures/athletes/134815/large.jpg", "premium": true}}`
+	testNoAthleteJSON  = `{"token_type": "Bearer", "expires_at": 1700021600, "expires_in": 21600, "refresh_token": "any-refresh", "access_token": "any-token"}`
+	testAthleteJSON    = `{"id": 227615, "username": "john_a", "resource_state": 3, "firstname": "John", "lastname": "Applestrava", "profile": "https://dgalywyr863hv.cloudfront.net/pictures/athletes/227615/large.jpg", "premium": false}`
+	testInvalidAthlete = `{"token_type": "Bearer", "expires_in": 21600, "access_token": "any-token", "athlete": "none"}`
+)
+
+func testConfig() *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     "client_id",
+		ClientSecret: "client_secret",
+		RedirectURL:  "https://example.com/strava/callback",
+		Endpoint:     Endpoint,
+	}
+}
+
+func TestLoginHandler_Options(t *testing.T) {
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// LoginHandler with Scopes and ApprovalPromptForce assert that:
+	// - scopes are comma-separated
+	// - approval_prompt=force is requested
+	config := testConfig()
+	config.Scopes = []string{"ignored"}
+	loginHandler := LoginHandler(config, failure, Scopes("read", "activity:read_all"), ApprovalPromptForce)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	ctx := oauth2Login.WithState(context.Background(), "d4e5f6")
+	loginHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, http.StatusFound, w.Code)
+	location, err := url.Parse(w.HeaderMap.Get("Location"))
+	if assert.Nil(t, err) {
+		assert.Equal(t, "www.strava.com", location.Host)
+		assert.Equal(t, "d4e5f6", location.Query().Get("state"))
+		assert.Equal(t, "read,activity:read_all", location.Query().Get("scope"))
+		assert.Equal(t, "force", location.Query().Get("approval_prompt"))
+	}
+}
+
+func TestCallbackHandler(t *testing.T) {
+	proxyClient, server := newStravaTestServer(testTokenJSON, testAthleteJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithState(ctx, "d4e5f6")
+
+	success := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "any-token", token.AccessToken)
+			assert.Equal(t, "any-refresh", token.RefreshToken)
+			assert.WithinDuration(t, time.Now().Add(6*time.Hour), token.Expiry, time.Minute)
+			assert.Equal(t, float64(1700021600), token.Extra("expires_at"))
+		}
+		user, err := UserFromContext(ctx)
+		if assert.Nil(t, err) {
+			expectedUser := &User{ID: 134815, Username: "marianne_t", FirstName: "Marianne", LastName: "Teutenberg", Profile: "https://dgalywyr863hv.cloudfront.net/pictures/athletes/134815/large.jpg", Premium: true}
+			assert.Equal(t, expectedUser, user)
+		}
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// CallbackHandler assert that:
+	// - the Strava User is read from the token response athlete
+	// - success handler is called with the full Token and User in the ctx
+	callbackHandler := CallbackHandler(testConfig(), http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestCallbackHandler_MissingAthlete(t *testing.T) {
+	for _, tokenJSON := range []string{testNoAthleteJSON, testInvalidAthlete} {
+		proxyClient, server := newStravaTestServer(tokenJSON, testAthleteJSON)
+		// oauth2 Client will use the proxy client's base Transport
+		ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+		ctx = oauth2Login.WithState(ctx, "d4e5f6")
+
+		success := func(w http.ResponseWriter, req *http.Request) {
+			user, err := UserFromContext(req.Context())
+			if assert.Nil(t, err) {
+				expectedUser := &User{ID: 227615, Username: "john_a", FirstName: "John", LastName: "Applestrava", Profile: "https://dgalywyr863hv.cloudfront.net/pictures/athletes/227615/large.jpg"}
+				assert.Equal(t, expectedUser, user)
+			}
+			fmt.Fprintf(w, "success handler called")
+		}
+		failure := testutils.AssertFailureNotCalled(t)
+
+		// CallbackHandler without a token response athlete, assert that:
+		// - the Strava User is obtained from /api/v3/athlete
+		// - success handler is called with the User in the ctx
+		callbackHandler := CallbackHandler(testConfig(), http.HandlerFunc(success), failure)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+		callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+		assert.Equal(t, "success handler called", w.Body.String())
+		server.Close()
+	}
+}
+
+func TestStravaHandler_MissingCtxToken(t *testing.T) {
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "oauth2: Context missing Token", err.Error())
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// StravaHandler called without Token in ctx, assert that:
+	// - failure handler is called
+	// - error about ctx missing token is added to the failure handler ctx
+	stravaHandler := stravaHandler(testConfig(), success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	stravaHandler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestStravaHandler_ErrorGettingUser(t *testing.T) {
+	proxyClient, server := testutils.NewErrorServer("Strava Service Down", http.StatusInternalServerError)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.True(t, errors.Is(err, ErrUnableToGetStravaUser))
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// StravaHandler without an athlete cannot get Strava User, assert that:
+	// - failure handler is called
+	// - error cannot get Strava User added to the failure handler ctx
+	stravaHandler := stravaHandler(testConfig(), success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	stravaHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestValidateResponse(t *testing.T) {
+	validUser := &User{ID: 134815}
+	validResponse := &http.Response{StatusCode: 200}
+	invalidResponse := &http.Response{StatusCode: 500}
+	assert.Equal(t, nil, validateResponse(validUser, validResponse, nil))
+	assert.True(t, errors.Is(validateResponse(validUser, validResponse, fmt.Errorf("Server error")), ErrUnableToGetStravaUser))
+	assert.True(t, errors.Is(validateResponse(validUser, invalidResponse, nil), ErrUnableToGetStravaUser))
+	assert.True(t, errors.Is(validateResponse(&User{}, validResponse, nil), ErrUnableToGetStravaUser))
+	assert.True(t, errors.Is(validateResponse(nil, validResponse, nil), ErrUnableToGetStravaUser))
+}
@@ -0,0 +1,31 @@
+package strava
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/dghubble/gologin/testutils"
+)
+
+// newStravaTestServer returns a new httptest.Server which mocks the Strava
+// token and /api/v3/athlete endpoints and a client which proxies requests to
+// the server. The token endpoint responds with the given token json data and
+// the athlete endpoint with the athlete json data. The caller must close the
+// server.
+func newStravaTestServer(tokenJSON, athleteJSON string) (*http.Client, *httptest.Server) {
+	client, mux, server := testutils.TestServer()
+	mux.HandleFunc("/oauth/token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, tokenJSON)
+	})
+	mux.HandleFunc("/api/v3/athlete", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer any-token" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, athleteJSON)
+	})
+	return client, server
+}
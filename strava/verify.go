@@ -0,0 +1,59 @@
+package strava
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/dghubble/gologin/internal/jsonclient"
+)
+
+const stravaAPI = "https://www.strava.com/api/v3/"
+
+// User is a Strava athlete.
+type User struct {
+	ID        int64  `json:"id"`
+	Username  string `json:"username"`
+	FirstName string `json:"firstname"`
+	LastName  string `json:"lastname"`
+	// Profile is the URL of the (124x124) profile photo
+	Profile string `json:"profile"`
+	Premium bool   `json:"premium"`
+}
+
+// userFromAthlete returns the User from the athlete the Strava token response
+// embeds or nil if the athlete is absent or invalid.
+func userFromAthlete(athlete interface{}) *User {
+	if athlete == nil {
+		return nil
+	}
+	data, err := json.Marshal(athlete)
+	if err != nil {
+		return nil
+	}
+	user := new(User)
+	if err := json.Unmarshal(data, user); err != nil || user.ID == 0 {
+		return nil
+	}
+	return user
+}
+
+// client is a Strava client for obtaining a User.
+type client struct {
+	json *jsonclient.Client
+}
+
+// newClient returns a new Strava client.
+func newClient(httpClient *http.Client) *client {
+	return &client{
+		json: jsonclient.New(httpClient, stravaAPI),
+	}
+}
+
+// CurrentAthlete gets the authenticated Strava User.
+// https://developers.strava.com/docs/reference/#api-Athletes-getLoggedInAthlete
+func (c *client) CurrentAthlete(ctx context.Context) (*User, *http.Response, error) {
+	user := new(User)
+	resp, err := c.json.Get(ctx, "athlete", nil, user, nil)
+	return user, resp, err
+}
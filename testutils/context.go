@@ -0,0 +1,48 @@
+package testutils
+
+import (
+	"context"
+	"errors"
+	"reflect"
+
+	"github.com/dghubble/gologin"
+	"github.com/stretchr/testify/assert"
+)
+
+// Contexts for unit testing success and failure handlers are built with the
+// exported ctx constructors, without running a handler chain. For example,
+//
+//	ctx := oauth2Login.WithToken(context.Background(), token)
+//	ctx = facebook.WithUser(ctx, user)
+//	handler.ServeHTTP(w, req.WithContext(ctx))
+//
+// Every provider package has the same WithUser and UserFromContext pair, a
+// failure ctx is built with gologin.WithError.
+
+var contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+
+// AssertUserInContext asserts that the provider UserFromContext func (e.g.
+// facebook.UserFromContext) returns the expected User from the ctx. It
+// reports a diff of the Users if they differ.
+func AssertUserInContext(t assert.TestingT, ctx context.Context, userFromContext interface{}, expected interface{}) bool {
+	fn := reflect.ValueOf(userFromContext)
+	fnType := fn.Type()
+	if fnType.Kind() != reflect.Func || fnType.NumIn() != 1 || fnType.In(0) != contextType || fnType.NumOut() != 2 {
+		return assert.Fail(t, "userFromContext must be a func(context.Context) (*User, error)")
+	}
+	out := fn.Call([]reflect.Value{reflect.ValueOf(ctx)})
+	if err, _ := out[1].Interface().(error); err != nil {
+		return assert.Fail(t, "expected User in ctx", err.Error())
+	}
+	return assert.Equal(t, expected, out[0].Interface())
+}
+
+// AssertErrorInContext asserts that the gologin error in the ctx is (or
+// wraps) the expected error. It reports both errors if they differ.
+func AssertErrorInContext(t assert.TestingT, ctx context.Context, expected error) bool {
+	err := gologin.ErrorFromContext(ctx)
+	if errors.Is(err, expected) {
+		return true
+	}
+	return assert.Equal(t, expected, err)
+}
@@ -0,0 +1,61 @@
+package testutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/facebook"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingT records whether an assertion failed.
+type recordingT struct {
+	failed bool
+}
+
+func (t *recordingT) Errorf(format string, args ...interface{}) {
+	t.failed = true
+}
+
+func TestAssertUserInContext(t *testing.T) {
+	user := &facebook.User{ID: "54638001", Name: "Ivy Crimson"}
+	ctx := facebook.WithUser(context.Background(), user)
+
+	// AssertUserInContext assert that:
+	// - passes for the expected User
+	// - fails for a different User, a missing User, or an invalid func
+	assert.True(t, AssertUserInContext(t, ctx, facebook.UserFromContext, &facebook.User{ID: "54638001", Name: "Ivy Crimson"}))
+	cases := []struct {
+		ctx             context.Context
+		userFromContext interface{}
+	}{
+		{facebook.WithUser(context.Background(), &facebook.User{ID: "other"}), facebook.UserFromContext},
+		{context.Background(), facebook.UserFromContext},
+		{ctx, "not a func"},
+		{ctx, gologin.ErrorFromContext},
+	}
+	for _, c := range cases {
+		rt := &recordingT{}
+		assert.False(t, AssertUserInContext(rt, c.ctx, c.userFromContext, user))
+		assert.True(t, rt.failed)
+	}
+}
+
+func TestAssertErrorInContext(t *testing.T) {
+	errExpected := errors.New("some error")
+	wrapped := fmt.Errorf("get user: %w", errExpected)
+
+	// AssertErrorInContext assert that:
+	// - passes for the expected error or an error wrapping it
+	// - fails for a different or missing error
+	assert.True(t, AssertErrorInContext(t, gologin.WithError(context.Background(), errExpected), errExpected))
+	assert.True(t, AssertErrorInContext(t, gologin.WithError(context.Background(), wrapped), errExpected))
+	for _, ctx := range []context.Context{gologin.WithError(context.Background(), errors.New("other")), context.Background()} {
+		rt := &recordingT{}
+		assert.False(t, AssertErrorInContext(rt, ctx, errExpected))
+		assert.True(t, rt.failed)
+	}
+}
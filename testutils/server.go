@@ -1,9 +1,12 @@
 package testutils
 
 import (
+	"context"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"sync/atomic"
 )
 
 // TestServer returns a new httptest.Server, its ServeMux for adding handlers,
@@ -21,6 +24,26 @@ func TestServer() (*http.Client, *http.ServeMux, *httptest.Server) {
 	return client, mux, server
 }
 
+// DialCountingTransport returns a transport which proxies requests to the
+// server, like the TestServer client, and a func which returns the number of
+// connections it has dialed (e.g. to check keep-alive connections are reused).
+func DialCountingTransport(server *httptest.Server) (http.RoundTripper, func() int) {
+	var dials int64
+	dialer := &net.Dialer{}
+	transport := &RewriteTransport{&http.Transport{
+		Proxy: func(req *http.Request) (*url.URL, error) {
+			return url.Parse(server.URL)
+		},
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			atomic.AddInt64(&dials, 1)
+			return dialer.DialContext(ctx, network, addr)
+		},
+	}}
+	return transport, func() int {
+		return int(atomic.LoadInt64(&dials))
+	}
+}
+
 // NewErrorServer returns a new httptest.Server, which responds with the given
 // error message and code, and a client which proxies requests to the server
 // using a custom transport. The caller must close the server.
@@ -0,0 +1,16 @@
+/*
+Package tokenseal seals OAuth2 tokens with authenticated encryption so they
+may be persisted client-side (e.g. in a cookie) without server-side storage.
+
+Seal encrypts an oauth2.Token with AES-GCM and Open decrypts it. A KeyRing
+seals with its first key and opens with any of its keys, so keys can be
+rotated without invalidating tokens sealed with the previous key.
+
+	keys := tokenseal.KeyRing{currentKey, previousKey}
+	config := tokenseal.Config{Keys: keys, Cookie: cookieConfig}
+	mux.Handle("/github/callback", github.StateHandler(stateConfig, github.CallbackHandler(oauth2Config, tokenseal.SealHandler(config, issueSession(), nil), nil)))
+	mux.Handle("/repos", tokenseal.OpenMiddleware(config, nil)(http.HandlerFunc(reposHandler)))
+
+Handlers behind OpenMiddleware read the token with oauth2.TokenFromContext.
+*/
+package tokenseal
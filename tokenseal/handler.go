@@ -0,0 +1,112 @@
+package tokenseal
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/internal"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+)
+
+// ErrTokenTooLarge is the SealHandler error when the sealed token exceeds the
+// cookie size browsers accept.
+var ErrTokenTooLarge = errors.New("tokenseal: sealed token too large for a cookie")
+
+const (
+	defaultCookieName = "gologin-token"
+	// maxCookieValueSize keeps sealed token cookies under the 4096 byte limit
+	// browsers apply to a cookie's name, value, and attributes
+	maxCookieValueSize = 3800
+)
+
+// Config configures SealHandler and OpenMiddleware.
+type Config struct {
+	// Keys seal and open tokens (see KeyRing). Required.
+	Keys KeyRing
+	// Cookie configures the sealed token cookie. The Name defaults to
+	// "gologin-token". Use HTTPOnly and Secure cookies in production.
+	Cookie gologin.CookieConfig
+}
+
+// SealHandler returns a login success handler which seals the ctx oauth2
+// Token into the Cookie and calls the success handler. If the Token is
+// missing or cannot be sealed, the error is added to the ctx and the failure
+// handler is called. Panics if the Keys are missing or invalid.
+func SealHandler(config Config, success, failure http.Handler) http.Handler {
+	config = config.mustNormalize()
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			ctx = gologin.WithStatusCode(ctx, http.StatusInternalServerError)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		sealed, err := config.Keys.Seal(token)
+		if err == nil && len(sealed) > maxCookieValueSize {
+			err = ErrTokenTooLarge
+		}
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			ctx = gologin.WithStatusCode(ctx, http.StatusInternalServerError)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		http.SetCookie(w, internal.NewCookie(config.Cookie, sealed))
+		success.ServeHTTP(w, req)
+	}
+	return http.HandlerFunc(fn)
+}
+
+// OpenMiddleware returns middleware which opens the sealed token Cookie and
+// adds the oauth2 Token to the ctx of the next handler (see
+// oauth2.TokenFromContext). Requests without the cookie call the failure
+// handler with ErrMissingToken. Tampered cookies, or cookies sealed with a
+// key no longer in the Keys, are expired and call the failure handler with
+// ErrInvalidToken. Panics if the Keys are missing or invalid.
+func OpenMiddleware(config Config, failure http.Handler) func(http.Handler) http.Handler {
+	config = config.mustNormalize()
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, req *http.Request) {
+			ctx := req.Context()
+			cookie, err := req.Cookie(config.Cookie.Name)
+			if err != nil || cookie.Value == "" {
+				ctx = gologin.WithError(ctx, ErrMissingToken)
+				ctx = gologin.WithStatusCode(ctx, http.StatusUnauthorized)
+				failure.ServeHTTP(w, req.WithContext(ctx))
+				return
+			}
+			token, err := config.Keys.Open(cookie.Value)
+			if err != nil {
+				http.SetCookie(w, internal.ExpiredCookie(config.Cookie))
+				ctx = gologin.WithError(ctx, err)
+				ctx = gologin.WithStatusCode(ctx, http.StatusUnauthorized)
+				failure.ServeHTTP(w, req.WithContext(ctx))
+				return
+			}
+			ctx = oauth2Login.WithToken(ctx, token)
+			next.ServeHTTP(w, req.WithContext(ctx))
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// mustNormalize returns the Config with defaults. Panics if the Keys are
+// missing or invalid.
+func (c Config) mustNormalize() Config {
+	if err := c.Keys.validate(); err != nil {
+		panic(err)
+	}
+	if c.Cookie.Name == "" {
+		c.Cookie.Name = defaultCookieName
+	}
+	return c
+}
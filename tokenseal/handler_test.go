@@ -0,0 +1,158 @@
+package tokenseal
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dghubble/gologin"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+var testConfig = Config{
+	Keys: KeyRing{testKey, testOldKey},
+	Cookie: gologin.CookieConfig{
+		Name:     "sealed-token",
+		Path:     "/",
+		MaxAge:   3600,
+		HTTPOnly: true,
+		Secure:   true,
+	},
+}
+
+func TestSealHandler(t *testing.T) {
+	success := func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// SealHandler, assert that:
+	// - the ctx token is sealed into the configured cookie
+	// - the success handler is called
+	handler := SealHandler(testConfig, http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/callback", nil)
+	req = req.WithContext(oauth2Login.WithToken(req.Context(), testToken))
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "success handler called", w.Body.String())
+	cookies := (&http.Response{Header: w.Header()}).Cookies()
+	if assert.Len(t, cookies, 1) {
+		assert.Equal(t, "sealed-token", cookies[0].Name)
+		assert.Equal(t, 3600, cookies[0].MaxAge)
+		assert.True(t, cookies[0].HttpOnly)
+		assert.True(t, cookies[0].Secure)
+		token, err := Open(testKey, cookies[0].Value)
+		assert.Nil(t, err)
+		assert.Equal(t, testToken.AccessToken, token.AccessToken)
+	}
+}
+
+func TestSealHandler_Errors(t *testing.T) {
+	large := *testToken
+	large.AccessToken = strings.Repeat("a", maxCookieValueSize)
+	cases := []struct {
+		name  string
+		token *oauth2.Token
+		err   error
+	}{
+		{"missing token", nil, nil},
+		{"too large", &large, ErrTokenTooLarge},
+	}
+	for _, c := range cases {
+		failure := func(w http.ResponseWriter, req *http.Request) {
+			ctx := req.Context()
+			err := gologin.ErrorFromContext(ctx)
+			assert.NotNil(t, err, c.name)
+			if c.err != nil {
+				assert.Equal(t, c.err, err, c.name)
+			}
+			assert.Equal(t, http.StatusInternalServerError, gologin.StatusCodeFromContext(ctx), c.name)
+			fmt.Fprintf(w, "failure handler called")
+		}
+		// SealHandler without a sealable token, assert that:
+		// - the failure handler is called and no cookie is set
+		handler := SealHandler(testConfig, testutils.AssertSuccessNotCalled(t), http.HandlerFunc(failure))
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/callback", nil)
+		if c.token != nil {
+			req = req.WithContext(oauth2Login.WithToken(req.Context(), c.token))
+		}
+		handler.ServeHTTP(w, req)
+		assert.Equal(t, "failure handler called", w.Body.String(), c.name)
+		assert.Empty(t, w.Header().Get("Set-Cookie"), c.name)
+	}
+}
+
+func TestOpenMiddleware(t *testing.T) {
+	oldSealed, err := Seal(testOldKey, testToken)
+	assert.Nil(t, err)
+	next := func(w http.ResponseWriter, req *http.Request) {
+		token, err := oauth2Login.TokenFromContext(req.Context())
+		assert.Nil(t, err)
+		assert.Equal(t, testToken.AccessToken, token.AccessToken)
+		assert.Equal(t, testToken.RefreshToken, token.RefreshToken)
+		fmt.Fprintf(w, "next handler called")
+	}
+
+	// OpenMiddleware with a cookie sealed by a rotated key, assert that:
+	// - the token is added to the ctx of the next handler
+	handler := OpenMiddleware(testConfig, testutils.AssertFailureNotCalled(t))(http.HandlerFunc(next))
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/repos", nil)
+	req.AddCookie(&http.Cookie{Name: "sealed-token", Value: oldSealed})
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "next handler called", w.Body.String())
+}
+
+func TestOpenMiddleware_Errors(t *testing.T) {
+	sealed, err := Seal(testKey, testToken)
+	assert.Nil(t, err)
+	wrongKeySealed, err := Seal(testWrongKey, testToken)
+	assert.Nil(t, err)
+	tampered := []byte(sealed)
+	tampered[10] ^= 0x01
+	cases := []struct {
+		name    string
+		value   string
+		err     error
+		expired bool
+	}{
+		{"missing cookie", "", ErrMissingToken, false},
+		{"tampered cookie", string(tampered), ErrInvalidToken, true},
+		{"wrong key", wrongKeySealed, ErrInvalidToken, true},
+	}
+	for _, c := range cases {
+		failure := func(w http.ResponseWriter, req *http.Request) {
+			ctx := req.Context()
+			assert.Equal(t, c.err, gologin.ErrorFromContext(ctx), c.name)
+			_, err := oauth2Login.TokenFromContext(ctx)
+			assert.NotNil(t, err, c.name)
+			w.WriteHeader(gologin.StatusCodeFromContext(ctx))
+		}
+		// OpenMiddleware without a valid sealed token, assert that:
+		// - the failure handler is called with a 401 and no ctx token
+		// - invalid cookies are expired
+		handler := OpenMiddleware(testConfig, http.HandlerFunc(failure))(testutils.AssertSuccessNotCalled(t))
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/repos", nil)
+		if c.value != "" {
+			req.AddCookie(&http.Cookie{Name: "sealed-token", Value: c.value})
+		}
+		handler.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code, c.name)
+		cookies := (&http.Response{Header: w.Header()}).Cookies()
+		if c.expired && assert.Len(t, cookies, 1, c.name) {
+			assert.True(t, cookies[0].MaxAge < 0, c.name)
+		}
+	}
+}
+
+func TestConfig_Panics(t *testing.T) {
+	assert.Panics(t, func() { SealHandler(Config{}, nil, nil) })
+	assert.Panics(t, func() { OpenMiddleware(Config{Keys: KeyRing{[]byte("short")}}, nil) })
+}
@@ -0,0 +1,131 @@
+package tokenseal
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+
+	"golang.org/x/oauth2"
+)
+
+// version1 prefixes tokens sealed with AES-GCM and a random 12 byte nonce.
+const version1 byte = 1
+
+// Errors which may occur when sealing or opening tokens.
+var (
+	ErrInvalidKey         = errors.New("tokenseal: key must be 16, 24, or 32 bytes")
+	ErrMissingKey         = errors.New("tokenseal: key ring has no keys")
+	ErrMissingToken       = errors.New("tokenseal: missing token")
+	ErrInvalidToken       = errors.New("tokenseal: invalid sealed token")
+	ErrUnsupportedVersion = errors.New("tokenseal: unsupported sealed token version")
+)
+
+// Seal returns the token encrypted and authenticated with AES-GCM using the
+// key (16, 24, or 32 bytes for AES-128, AES-192, or AES-256), encoded as
+// base64url. The access token, token type, refresh token, and expiry are
+// sealed. Provider extra fields are not.
+func Seal(key []byte, token *oauth2.Token) (string, error) {
+	if token == nil {
+		return "", ErrMissingToken
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := json.Marshal(token)
+	if err != nil {
+		return "", err
+	}
+	sealed := make([]byte, 1+aead.NonceSize(), 1+aead.NonceSize()+len(plaintext)+aead.Overhead())
+	sealed[0] = version1
+	nonce := sealed[1:]
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	// authenticate the version byte as additional data
+	sealed = aead.Seal(sealed, nonce, plaintext, sealed[:1])
+	return base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// Open returns the token sealed with the key. Tampered, truncated, or
+// malformed values and values sealed with another key return
+// ErrInvalidToken, values of an unknown version return
+// ErrUnsupportedVersion, and no token is returned with an error.
+func Open(key []byte, sealed string) (*oauth2.Token, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := base64.RawURLEncoding.DecodeString(sealed)
+	if err != nil || len(data) == 0 {
+		return nil, ErrInvalidToken
+	}
+	if data[0] != version1 {
+		return nil, ErrUnsupportedVersion
+	}
+	if len(data) < 1+aead.NonceSize()+aead.Overhead() {
+		return nil, ErrInvalidToken
+	}
+	nonce, ciphertext := data[1:1+aead.NonceSize()], data[1+aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, data[:1])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	token := new(oauth2.Token)
+	if err := json.Unmarshal(plaintext, token); err != nil || token.AccessToken == "" {
+		return nil, ErrInvalidToken
+	}
+	return token, nil
+}
+
+// KeyRing is a list of keys, newest first, for rotating keys.
+type KeyRing [][]byte
+
+// Seal seals the token with the first key.
+func (r KeyRing) Seal(token *oauth2.Token) (string, error) {
+	if len(r) == 0 {
+		return "", ErrMissingKey
+	}
+	return Seal(r[0], token)
+}
+
+// Open opens the token with the first key which authenticates it, or returns
+// ErrInvalidToken if none do.
+func (r KeyRing) Open(sealed string) (*oauth2.Token, error) {
+	if len(r) == 0 {
+		return nil, ErrMissingKey
+	}
+	for _, key := range r {
+		token, err := Open(key, sealed)
+		if err != ErrInvalidToken {
+			return token, err
+		}
+	}
+	return nil, ErrInvalidToken
+}
+
+// validate returns an error if the ring is empty or has an invalid key.
+func (r KeyRing) validate() error {
+	if len(r) == 0 {
+		return ErrMissingKey
+	}
+	for _, key := range r {
+		if _, err := newAEAD(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newAEAD returns an AES-GCM AEAD with the key.
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, ErrInvalidKey
+	}
+	return cipher.NewGCM(block)
+}
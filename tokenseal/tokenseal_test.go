@@ -0,0 +1,119 @@
+package tokenseal
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+var (
+	testKey      = bytes.Repeat([]byte("k"), 32)
+	testOldKey   = bytes.Repeat([]byte("o"), 16)
+	testWrongKey = bytes.Repeat([]byte("w"), 32)
+	testToken    = &oauth2.Token{
+		AccessToken:  "2YotnFZFEjr1zCsicMWpAA",
+		TokenType:    "Bearer",
+		RefreshToken: "tGzv3JOkF0XG5Qx2TlKWIA",
+		Expiry:       time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+)
+
+func TestSealOpen(t *testing.T) {
+	sealed, err := Seal(testKey, testToken)
+	assert.Nil(t, err)
+	assert.NotContains(t, sealed, testToken.AccessToken)
+	token, err := Open(testKey, sealed)
+	assert.Nil(t, err)
+	assert.Equal(t, testToken.AccessToken, token.AccessToken)
+	assert.Equal(t, testToken.TokenType, token.TokenType)
+	assert.Equal(t, testToken.RefreshToken, token.RefreshToken)
+	assert.True(t, testToken.Expiry.Equal(token.Expiry))
+
+	// random nonces seal the same token differently
+	again, err := Seal(testKey, testToken)
+	assert.Nil(t, err)
+	assert.NotEqual(t, sealed, again)
+}
+
+func TestSeal_Errors(t *testing.T) {
+	_, err := Seal([]byte("short"), testToken)
+	assert.Equal(t, ErrInvalidKey, err)
+	_, err = Seal(testKey, nil)
+	assert.Equal(t, ErrMissingToken, err)
+	_, err = KeyRing{}.Seal(testToken)
+	assert.Equal(t, ErrMissingKey, err)
+}
+
+func TestOpen_Tampered(t *testing.T) {
+	sealed, err := Seal(testKey, testToken)
+	assert.Nil(t, err)
+	data, err := base64.RawURLEncoding.DecodeString(sealed)
+	assert.Nil(t, err)
+
+	// Open of a sealed token with any bit flipped (except the version byte),
+	// assert that:
+	// - ErrInvalidToken is returned without a token
+	for i := 1; i < len(data); i++ {
+		tampered := append([]byte(nil), data...)
+		tampered[i] ^= 0x01
+		token, err := Open(testKey, base64.RawURLEncoding.EncodeToString(tampered))
+		assert.Nil(t, token, "byte %d", i)
+		assert.Equal(t, ErrInvalidToken, err, "byte %d", i)
+	}
+
+	cases := []struct {
+		sealed string
+		err    error
+	}{
+		{"", ErrInvalidToken},
+		{"not base64!", ErrInvalidToken},
+		{sealed[:len(sealed)-4], ErrInvalidToken},
+		{base64.RawURLEncoding.EncodeToString(data[:20]), ErrInvalidToken},
+		{base64.RawURLEncoding.EncodeToString(append([]byte{2}, data[1:]...)), ErrUnsupportedVersion},
+	}
+	for _, c := range cases {
+		token, err := Open(testKey, c.sealed)
+		assert.Nil(t, token, c.sealed)
+		assert.Equal(t, c.err, err, c.sealed)
+	}
+}
+
+func TestOpen_WrongKey(t *testing.T) {
+	sealed, err := Seal(testKey, testToken)
+	assert.Nil(t, err)
+	token, err := Open(testWrongKey, sealed)
+	assert.Nil(t, token)
+	assert.Equal(t, ErrInvalidToken, err)
+	token, err = Open([]byte("short"), sealed)
+	assert.Nil(t, token)
+	assert.Equal(t, ErrInvalidKey, err)
+}
+
+func TestKeyRing(t *testing.T) {
+	oldSealed, err := Seal(testOldKey, testToken)
+	assert.Nil(t, err)
+	ring := KeyRing{testKey, testOldKey}
+
+	// KeyRing, assert that:
+	// - tokens are sealed with the first key
+	// - tokens sealed with any key are opened
+	// - tokens sealed with a key not in the ring are invalid
+	sealed, err := ring.Seal(testToken)
+	assert.Nil(t, err)
+	_, err = Open(testKey, sealed)
+	assert.Nil(t, err)
+	for _, s := range []string{sealed, oldSealed} {
+		token, err := ring.Open(s)
+		assert.Nil(t, err)
+		assert.Equal(t, testToken.AccessToken, token.AccessToken)
+	}
+	token, err := KeyRing{testKey}.Open(oldSealed)
+	assert.Nil(t, token)
+	assert.Equal(t, ErrInvalidToken, err)
+	_, err = KeyRing{}.Open(sealed)
+	assert.Equal(t, ErrMissingKey, err)
+}
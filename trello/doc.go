@@ -0,0 +1,2 @@
+// Package trello provides Trello OAuth1 login and callback handlers.
+package trello
@@ -0,0 +1,180 @@
+package trello
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/dghubble/gologin"
+	oauth1Login "github.com/dghubble/gologin/oauth1"
+	"github.com/dghubble/oauth1"
+)
+
+// ProviderName is the provider name set in the ctx (see
+// gologin.ProviderFromContext) and the Profile.
+const ProviderName = "trello"
+
+// Trello login errors
+var (
+	ErrUnableToGetTrelloUser = errors.New("trello: unable to get Trello User")
+)
+
+// Trello token expirations
+const (
+	Expiration1Hour  = "1hour"
+	Expiration1Day   = "1day"
+	Expiration30Days = "30days"
+	ExpirationNever  = "never"
+)
+
+// Endpoint is Trello's OAuth1 endpoint.
+var Endpoint = oauth1.Endpoint{
+	RequestTokenURL: "https://trello.com/1/OAuthGetRequestToken",
+	AuthorizeURL:    "https://trello.com/1/OAuthAuthorizeToken",
+	AccessTokenURL:  "https://trello.com/1/OAuthGetAccessToken",
+}
+
+// Config configures the Trello authorization page.
+type Config struct {
+	// Name is the application name Trello shows users
+	Name string
+	// Scopes are the requested scopes (e.g. "read", "write", "account").
+	// Trello defaults to read.
+	Scopes []string
+	// Expiration is how long the access token is valid (e.g.
+	// ExpirationNever for persistent access). Trello defaults to 30 days.
+	Expiration string
+}
+
+// LoginHandler handles Trello login requests by obtaining a request token,
+// setting a temporary token secret cookie, and redirecting to the
+// authorization URL with the Config name, scope, and expiration.
+func LoginHandler(config *oauth1.Config, trelloConfig Config, cookieConfig gologin.CookieConfig, failure http.Handler) http.Handler {
+	// oauth1.LoginHandler -> oauth1.CookieTempHandler -> authRedirectHandler
+	success := authRedirectHandler(config, trelloConfig, failure)
+	success = oauth1Login.CookieTempHandler(cookieConfig, success, failure)
+	return oauth1Login.LoginHandler(config, success, failure)
+}
+
+// CallbackHandler handles Trello callback requests by parsing the oauth token
+// and verifier and adding the Trello access token and User to the ctx. If
+// authentication succeeds, handling delegates to the success handler,
+// otherwise to the failure handler.
+//
+// Trello redirects users who deny authorization without an oauth_verifier,
+// which fails with the oauth1 ErrAccessDenied.
+func CallbackHandler(config *oauth1.Config, cookieConfig gologin.CookieConfig, success, failure http.Handler) http.Handler {
+	// deniedHandler -> oauth1.CookieTempHandler -> oauth1.CallbackHandler -> trelloHandler -> success
+	success = trelloHandler(config, success, failure)
+	success = oauth1Login.CallbackHandler(config, success, failure)
+	success = oauth1Login.CookieTempHandler(cookieConfig, success, failure)
+	return deniedHandler(success, failure)
+}
+
+// authRedirectHandler reads the request token from the ctx and redirects to
+// the authorization URL with the Config name, scope, and expiration.
+func authRedirectHandler(config *oauth1.Config, trelloConfig Config, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		requestToken, _, err := oauth1Login.RequestTokenFromContext(ctx)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		authorizationURL, err := config.AuthorizationURL(requestToken)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		query := authorizationURL.Query()
+		if trelloConfig.Name != "" {
+			query.Set("name", trelloConfig.Name)
+		}
+		if len(trelloConfig.Scopes) > 0 {
+			query.Set("scope", strings.Join(trelloConfig.Scopes, ","))
+		}
+		if trelloConfig.Expiration != "" {
+			query.Set("expiration", trelloConfig.Expiration)
+		}
+		authorizationURL.RawQuery = query.Encode()
+		http.Redirect(w, req, authorizationURL.String(), http.StatusFound)
+	}
+	return http.HandlerFunc(fn)
+}
+
+// deniedHandler calls the failure handler with the oauth1 ErrAccessDenied if
+// the callback has an oauth_token, but no oauth_verifier (i.e. the user
+// denied authorization). Otherwise, the success handler is called.
+func deniedHandler(success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		query := req.URL.Query()
+		if query.Get("oauth_token") != "" && query.Get("oauth_verifier") == "" {
+			ctx = gologin.WithError(ctx, oauth1Login.ErrAccessDenied)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		success.ServeHTTP(w, req)
+	}
+	return http.HandlerFunc(fn)
+}
+
+// trelloHandler is a http.Handler that gets the OAuth1 access token from the
+// ctx and obtains the Trello User from members/me. If successful, the User is
+// added to the ctx and the success handler is called. Otherwise, the failure
+// handler is called.
+func trelloHandler(config *oauth1.Config, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		accessToken, _, err := oauth1Login.AccessTokenFromContext(ctx)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		// members/me accepts the key and token as parameters, so requests
+		// needn't be signed
+		httpClient, ok := ctx.Value(oauth1.HTTPClient).(*http.Client)
+		if !ok || httpClient == nil {
+			httpClient = http.DefaultClient
+		}
+		user, resp, err := newClient(httpClient).Me(ctx, config.ConsumerKey, accessToken)
+		err = validateResponse(user, resp, err)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		ctx = WithUser(ctx, user)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// validateResponse returns an error if the given Trello User, raw
+// http.Response, or error are unexpected. Returns nil if they are valid, or a
+// *gologin.Error which preserves the cause and status code.
+func validateResponse(user *User, resp *http.Response, err error) error {
+	var status int
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	if err != nil || status != http.StatusOK {
+		return &gologin.Error{Provider: "trello", Op: "get user", StatusCode: status, Err: err, Kind: ErrUnableToGetTrelloUser}
+	}
+	if user == nil || user.ID == "" {
+		return &gologin.Error{Provider: "trello", Op: "get user", StatusCode: status, Kind: ErrUnableToGetTrelloUser}
+	}
+	return nil
+}
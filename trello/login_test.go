@@ -0,0 +1,203 @@
+package trello
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/dghubble/gologin"
+	oauth1Login "github.com/dghubble/gologin/oauth1"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/dghubble/oauth1"
+	"github.com/stretchr/testify/assert"
+)
+
+var testCookieConfig = gologin.CookieConfig{
+	Name:   "trello-temp",
+	Path:   "/",
+	MaxAge: 60,
+}
+
+// testConfig returns an oauth1 Config for the test server URL.
+func testConfig(serverURL string) *oauth1.Config {
+	return &oauth1.Config{
+		ConsumerKey:    "consumer_key",
+		ConsumerSecret: "consumer_secret",
+		CallbackURL:    "https://example.com/trello/callback",
+		Endpoint: oauth1.Endpoint{
+			RequestTokenURL: serverURL + "/1/OAuthGetRequestToken",
+			AuthorizeURL:    Endpoint.AuthorizeURL,
+			AccessTokenURL:  serverURL + "/1/OAuthGetAccessToken",
+		},
+	}
+}
+
+func TestLoginHandler(t *testing.T) {
+	_, server := newTrelloTestServer(testMemberJSON)
+	defer server.Close()
+
+	trelloConfig := Config{
+		Name:       "Gologin Example",
+		Scopes:     []string{"read", "write"},
+		Expiration: ExpirationNever,
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// LoginHandler assert that:
+	// - redirects to the Trello authorization URL with the request token
+	// - the Config name, scope, and expiration are set
+	// - the request token secret is kept in a temp cookie
+	loginHandler := LoginHandler(testConfig(server.URL), trelloConfig, testCookieConfig, failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	loginHandler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusFound, w.Code)
+	location, err := url.Parse(w.HeaderMap.Get("Location"))
+	if assert.Nil(t, err) {
+		assert.Equal(t, "trello.com", location.Host)
+		assert.Equal(t, "/1/OAuthAuthorizeToken", location.Path)
+		query := location.Query()
+		assert.Equal(t, "request_token", query.Get("oauth_token"))
+		assert.Equal(t, "Gologin Example", query.Get("name"))
+		assert.Equal(t, "read,write", query.Get("scope"))
+		assert.Equal(t, "never", query.Get("expiration"))
+	}
+	cookies := (&http.Response{Header: w.Header()}).Cookies()
+	if assert.Len(t, cookies, 1) {
+		assert.Equal(t, "trello-temp", cookies[0].Name)
+		assert.Equal(t, "request_secret", cookies[0].Value)
+	}
+}
+
+func TestLoginHandler_DefaultConfig(t *testing.T) {
+	_, server := newTrelloTestServer(testMemberJSON)
+	defer server.Close()
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// LoginHandler with an empty Config, assert that:
+	// - Trello's default name, scope, and expiration are used
+	loginHandler := LoginHandler(testConfig(server.URL), Config{}, testCookieConfig, failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	loginHandler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "https://trello.com/1/OAuthAuthorizeToken?oauth_token=request_token", w.HeaderMap.Get("Location"))
+}
+
+func TestCallbackHandler(t *testing.T) {
+	proxyClient, server := newTrelloTestServer(testMemberJSON)
+	defer server.Close()
+	// Trello requests use the ctx client's Transport
+	ctx := context.WithValue(context.Background(), oauth1.HTTPClient, proxyClient)
+
+	expectedUser := &User{
+		ID:        "5abbe4b7ddc1b351ef961414",
+		Username:  "trelloinc",
+		FullName:  "Trello Inc",
+		Email:     "trello@example.com",
+		AvatarURL: "https://trello-members.s3.amazonaws.com/5abbe4b7ddc1b351ef961414/6b2c1b5f0f5e8a3a1b6e1c4b5a2d7e8f",
+	}
+	success := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		accessToken, accessSecret, err := oauth1Login.AccessTokenFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "access_token", accessToken)
+			assert.Equal(t, "access_secret", accessSecret)
+		}
+		user, err := UserFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, expectedUser, user)
+		}
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// CallbackHandler assert that:
+	// - the request secret is read from the temp cookie
+	// - success handler is called
+	// - Trello access token and User are added to the ctx of the success
+	// handler
+	callbackHandler := CallbackHandler(testConfig(server.URL), testCookieConfig, http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/trello/callback?oauth_token=request_token&oauth_verifier=any_verifier", nil)
+	req.AddCookie(&http.Cookie{Name: "trello-temp", Value: "request_secret"})
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestCallbackHandler_Denied(t *testing.T) {
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		assert.Equal(t, oauth1Login.ErrAccessDenied, err)
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// CallbackHandler for a denied authorization (no oauth_verifier), assert
+	// that:
+	// - failure handler is called with ErrAccessDenied
+	callbackHandler := CallbackHandler(testConfig(""), testCookieConfig, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/trello/callback?oauth_token=request_token", nil)
+	req.AddCookie(&http.Cookie{Name: "trello-temp", Value: "request_secret"})
+	callbackHandler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestTrelloHandler_MissingCtxToken(t *testing.T) {
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "oauth1: Context missing access token or secret", err.Error())
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// TrelloHandler called without an access token in ctx, assert that:
+	// - failure handler is called
+	handler := trelloHandler(testConfig(""), success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestTrelloHandler_ErrorGettingUser(t *testing.T) {
+	proxyClient, server := newTrelloTestServer(testMemberJSON)
+	defer server.Close()
+	// Trello requests use the ctx client's Transport
+	ctx := context.WithValue(context.Background(), oauth1.HTTPClient, proxyClient)
+	ctx = oauth1Login.WithAccessToken(ctx, "revoked_token", "access_secret")
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		assert.True(t, errors.Is(err, ErrUnableToGetTrelloUser))
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// TrelloHandler with a token Trello rejects, assert that:
+	// - failure handler is called
+	// - error cannot get Trello User is added to the ctx
+	handler := trelloHandler(testConfig(server.URL), success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	handler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestValidateResponse(t *testing.T) {
+	validUser := &User{ID: "5abbe4b7ddc1b351ef961414", Username: "trelloinc"}
+	validResponse := &http.Response{StatusCode: 200}
+	invalidResponse := &http.Response{StatusCode: 401}
+	assert.Equal(t, nil, validateResponse(validUser, validResponse, nil))
+	assert.True(t, errors.Is(validateResponse(validUser, validResponse, fmt.Errorf("Server error")), ErrUnableToGetTrelloUser))
+	assert.True(t, errors.Is(validateResponse(validUser, invalidResponse, nil), ErrUnableToGetTrelloUser))
+	assert.True(t, errors.Is(validateResponse(&User{}, validResponse, nil), ErrUnableToGetTrelloUser))
+	assert.True(t, errors.Is(validateResponse(nil, validResponse, nil), ErrUnableToGetTrelloUser))
+}
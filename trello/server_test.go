@@ -0,0 +1,41 @@
+package trello
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/dghubble/gologin/testutils"
+)
+
+// testMemberJSON is a Trello members/me response.
+const testMemberJSON = `{"id": "5abbe4b7ddc1b351ef961414", "username": "trelloinc", "fullName": "Trello Inc", "initials": "TI", "email": "trello@example.com", "avatarUrl": "https://trello-members.s3.amazonaws.com/5abbe4b7ddc1b351ef961414/6b2c1b5f0f5e8a3a1b6e1c4b5a2d7e8f", "url": "https://trello.com/trelloinc", "idBoards": ["5abbe4b7ddc1b351ef961415"]}`
+
+// newTrelloTestServer returns a new httptest.Server which mocks the Trello
+// OAuth1 token endpoints and members/me, and a client which proxies requests
+// to the server. members/me responds with the given json data for the
+// "consumer_key" key and "access_token" token. The caller must close the
+// server.
+func newTrelloTestServer(jsonData string) (*http.Client, *httptest.Server) {
+	client, mux, server := testutils.TestServer()
+	mux.HandleFunc("/1/OAuthGetRequestToken", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-www-form-urlencoded")
+		w.Write([]byte("oauth_token=request_token&oauth_token_secret=request_secret&oauth_callback_confirmed=true"))
+	})
+	mux.HandleFunc("/1/OAuthGetAccessToken", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-www-form-urlencoded")
+		w.Write([]byte("oauth_token=access_token&oauth_token_secret=access_secret"))
+	})
+	mux.HandleFunc("/1/members/me", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("key") != "consumer_key" || query.Get("token") != "access_token" {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprintf(w, "invalid token")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		fmt.Fprintf(w, jsonData)
+	})
+	return client, server
+}
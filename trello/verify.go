@@ -0,0 +1,42 @@
+package trello
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/dghubble/gologin/internal/jsonclient"
+)
+
+const trelloAPI = "https://api.trello.com/1/"
+
+// User is a Trello member.
+type User struct {
+	ID        string `json:"id"`
+	Username  string `json:"username"`
+	FullName  string `json:"fullName"`
+	Email     string `json:"email"`
+	AvatarURL string `json:"avatarUrl"`
+}
+
+// client is a Trello client for obtaining the current User.
+type client struct {
+	json *jsonclient.Client
+}
+
+// newClient returns a new Trello client.
+func newClient(httpClient *http.Client) *client {
+	return &client{
+		json: jsonclient.New(httpClient, trelloAPI),
+	}
+}
+
+// Me returns the Trello User of the member token.
+// https://developer.atlassian.com/cloud/trello/rest/api-group-members/#api-members-id-get
+func (c *client) Me(ctx context.Context, key, token string) (*User, *http.Response, error) {
+	user := new(User)
+	// Trello accepts the application key and member token as parameters
+	params := url.Values{"key": {key}, "token": {token}}
+	resp, err := c.json.Get(ctx, "members/me", params, user, nil)
+	return user, resp, err
+}
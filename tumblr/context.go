@@ -3,6 +3,8 @@ package tumblr
 import (
 	"context"
 	"fmt"
+
+	"github.com/dghubble/gologin"
 )
 
 // unexported key type prevents collisions
@@ -12,8 +14,10 @@ const (
 	userKey key = iota
 )
 
-// WithUser returns a copy of ctx that stores the Tumblr User.
+// WithUser returns a copy of ctx that stores the Tumblr User and its gologin
+// Profile.
 func WithUser(ctx context.Context, user *User) context.Context {
+	ctx = gologin.WithProfile(ctx, newProfile(user))
 	return context.WithValue(ctx, userKey, user)
 }
 
@@ -25,3 +29,17 @@ func UserFromContext(ctx context.Context) (*User, error) {
 	}
 	return user, nil
 }
+
+// newProfile returns the gologin Profile of the Tumblr User. Tumblr Users
+// have no ID, so the unique user name is used.
+func newProfile(user *User) *gologin.Profile {
+	if user == nil {
+		return nil
+	}
+	return &gologin.Profile{
+		Provider: ProviderName,
+		ID:       user.Name,
+		Name:     user.Name,
+		Raw:      gologin.ProfileRaw(user, "name"),
+	}
+}
@@ -5,10 +5,15 @@ import (
 	"net/http"
 
 	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/internal"
 	oauth1Login "github.com/dghubble/gologin/oauth1"
 	"github.com/dghubble/oauth1"
 )
 
+// ProviderName is the provider name set in the ctx (see
+// gologin.ProviderFromContext) and the Profile.
+const ProviderName = "tumblr"
+
 // Tumblr login errors
 var (
 	ErrUnableToGetTumblrUser = errors.New("tumblr: unable to get Tumblr User")
@@ -36,7 +41,7 @@ func CallbackHandler(config *oauth1.Config, cookieConfig gologin.CookieConfig, s
 }
 
 // tumblrHandler is a http.Handler that gets the OAuth1 access token from
-// the ctx and obtains the Tumblr User. If successful, the User is added to
+// the ctx and obtains the Tumblr User, including its Blogs, from user/info. If successful, the User is added to
 // the ctx and the success handler is called. Otherwise, the failure handler
 // is called.
 func tumblrHandler(config *oauth1.Config, success, failure http.Handler) http.Handler {
@@ -51,9 +56,9 @@ func tumblrHandler(config *oauth1.Config, success, failure http.Handler) http.Ha
 			failure.ServeHTTP(w, req.WithContext(ctx))
 			return
 		}
-		httpClient := config.Client(ctx, oauth1.NewToken(accessToken, accessSecret))
+		httpClient := internal.OAuth1Client(ctx, config, oauth1.NewToken(accessToken, accessSecret))
 		tumblrClient := newClient(httpClient)
-		user, resp, err := tumblrClient.UserInfo()
+		user, resp, err := tumblrClient.UserInfo(ctx)
 		err = validateResponse(user, resp, err)
 		if err != nil {
 			ctx = gologin.WithError(ctx, err)
@@ -67,13 +72,19 @@ func tumblrHandler(config *oauth1.Config, success, failure http.Handler) http.Ha
 }
 
 // validateResponse returns an error if the given Tumblr User, raw
-// http.Response, or error are unexpected. Returns nil if they are valid.
+// http.Response, or error are unexpected. Returns nil if they are valid, or a
+// *gologin.Error which preserves the cause and status code. Users without
+// Blogs (e.g. new accounts) are valid, Users without a name are not.
 func validateResponse(user *User, resp *http.Response, err error) error {
-	if err != nil || resp.StatusCode != http.StatusOK {
-		return ErrUnableToGetTumblrUser
+	var status int
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	if err != nil || status != http.StatusOK {
+		return &gologin.Error{Provider: "tumblr", Op: "get user", StatusCode: status, Err: err, Kind: ErrUnableToGetTumblrUser}
 	}
 	if user == nil || user.Name == "" {
-		return ErrUnableToGetTumblrUser
+		return &gologin.Error{Provider: "tumblr", Op: "get user", StatusCode: status, Kind: ErrUnableToGetTumblrUser}
 	}
 	return nil
 }
@@ -0,0 +1,116 @@
+package tumblr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dghubble/gologin"
+	oauth1Login "github.com/dghubble/gologin/oauth1"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/dghubble/oauth1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTumblrHandler(t *testing.T) {
+	proxyClient, server := newTumblrUserInfoServer(testUserInfoJSON)
+	defer server.Close()
+	// oauth1 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth1.HTTPClient, proxyClient)
+	ctx = oauth1Login.WithAccessToken(ctx, "access_token", "access_secret")
+
+	config := &oauth1.Config{}
+	success := func(w http.ResponseWriter, req *http.Request) {
+		user, err := UserFromContext(req.Context())
+		if assert.Nil(t, err) {
+			assert.Equal(t, "gopher", user.Name)
+			assert.Equal(t, int64(12), user.Following)
+			assert.Equal(t, "html", user.DefaultPostFormat)
+			assert.Len(t, user.Blogs, 2)
+			blog := user.PrimaryBlog()
+			if assert.NotNil(t, blog) {
+				assert.Equal(t, "https://gopher.tumblr.com/", blog.URL)
+				assert.Equal(t, int64(128), blog.Followers)
+				assert.Equal(t, Avatar{Width: 512, Height: 512, URL: "https://64.media.tumblr.com/avatar_512.png"}, blog.Avatar[0])
+			}
+		}
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// TumblrHandler assert that:
+	// - the User and its Blogs are decoded from the response envelope
+	// - success handler is called
+	handler := tumblrHandler(config, http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	handler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestTumblrHandler_NoBlogs(t *testing.T) {
+	proxyClient, server := newTumblrUserInfoServer(testNewUserInfoJSON)
+	defer server.Close()
+	// oauth1 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth1.HTTPClient, proxyClient)
+	ctx = oauth1Login.WithAccessToken(ctx, "access_token", "access_secret")
+
+	config := &oauth1.Config{}
+	success := func(w http.ResponseWriter, req *http.Request) {
+		user, err := UserFromContext(req.Context())
+		if assert.Nil(t, err) {
+			assert.Equal(t, "newgopher", user.Name)
+			assert.Empty(t, user.Blogs)
+			assert.Nil(t, user.PrimaryBlog())
+		}
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// TumblrHandler for a new account without blogs, assert that:
+	// - success handler is called
+	handler := tumblrHandler(config, http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	handler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestTumblrHandler_ErrorGettingUser(t *testing.T) {
+	proxyClient, server := testutils.NewErrorServer("Tumblr Service Down", http.StatusInternalServerError)
+	defer server.Close()
+	// oauth1 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth1.HTTPClient, proxyClient)
+	ctx = oauth1Login.WithAccessToken(ctx, "access_token", "access_secret")
+
+	config := &oauth1.Config{}
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		assert.True(t, errors.Is(err, ErrUnableToGetTumblrUser))
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// TumblrHandler cannot get Tumblr User, assert that:
+	// - failure handler is called
+	// - error cannot be retrieved from Tumblr is added to the ctx
+	handler := tumblrHandler(config, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	handler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestValidateResponse(t *testing.T) {
+	validUser := &User{Name: "gopher", Blogs: []Blog{{Name: "gopher", Primary: true}}}
+	validResponse := &http.Response{StatusCode: 200}
+	invalidResponse := &http.Response{StatusCode: 500}
+	assert.Equal(t, nil, validateResponse(validUser, validResponse, nil))
+	assert.Equal(t, nil, validateResponse(&User{Name: "newgopher"}, validResponse, nil))
+	assert.True(t, errors.Is(validateResponse(validUser, validResponse, fmt.Errorf("Server error")), ErrUnableToGetTumblrUser))
+	assert.True(t, errors.Is(validateResponse(validUser, invalidResponse, nil), ErrUnableToGetTumblrUser))
+	assert.True(t, errors.Is(validateResponse(&User{Blogs: validUser.Blogs}, validResponse, nil), ErrUnableToGetTumblrUser))
+}
@@ -0,0 +1,61 @@
+package tumblr
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/dghubble/gologin/testutils"
+)
+
+// testUserInfoJSON is a Tumblr user/info response for a user with blogs.
+const testUserInfoJSON = `{
+	"meta": {"status": 200, "msg": "OK"},
+	"response": {
+		"user": {
+			"name": "gopher",
+			"following": 12,
+			"likes": 34,
+			"default_post_format": "html",
+			"blogs": [
+				{
+					"name": "gopher-drafts",
+					"title": "Drafts",
+					"url": "https://gopher-drafts.tumblr.com/",
+					"primary": false,
+					"followers": 3
+				},
+				{
+					"name": "gopher",
+					"title": "Gopher",
+					"url": "https://gopher.tumblr.com/",
+					"primary": true,
+					"followers": 128,
+					"avatar": [
+						{"width": 512, "height": 512, "url": "https://64.media.tumblr.com/avatar_512.png"},
+						{"width": 128, "height": 128, "url": "https://64.media.tumblr.com/avatar_128.png"}
+					]
+				}
+			]
+		}
+	}
+}`
+
+// testNewUserInfoJSON is a Tumblr user/info response for a new user without
+// blogs.
+const testNewUserInfoJSON = `{
+	"meta": {"status": 200, "msg": "OK"},
+	"response": {"user": {"name": "newgopher", "following": 0, "likes": 0, "blogs": []}}
+}`
+
+// newTumblrUserInfoServer returns a new httptest.Server which mocks the Tumblr
+// user info endpoint and a client which proxies requests to the server. The
+// server responds with the given json data. The caller must close the server.
+func newTumblrUserInfoServer(jsonData string) (*http.Client, *httptest.Server) {
+	client, mux, server := testutils.TestServer()
+	mux.HandleFunc("/v2/user/info", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, jsonData)
+	})
+	return client, server
+}
@@ -1,9 +1,10 @@
 package tumblr
 
 import (
+	"context"
 	"net/http"
 
-	"github.com/dghubble/sling"
+	"github.com/dghubble/gologin/internal/jsonclient"
 )
 
 const tumblrAPI = "https://api.tumblr.com/v2/"
@@ -20,9 +21,39 @@ type userInfoResponse struct {
 //
 // Note that Tumblr does not provide stable user identifiers.
 type User struct {
-	Name      string `json:"name"`
-	Following int64  `json:"following"`
-	Likes     int64  `json:"likes"`
+	Name              string `json:"name"`
+	Following         int64  `json:"following"`
+	Likes             int64  `json:"likes"`
+	DefaultPostFormat string `json:"default_post_format"`
+	Blogs             []Blog `json:"blogs"`
+}
+
+// Blog is a Tumblr blog of a User.
+type Blog struct {
+	Name      string   `json:"name"`
+	Title     string   `json:"title"`
+	URL       string   `json:"url"`
+	Primary   bool     `json:"primary"`
+	Followers int64    `json:"followers"`
+	Avatar    []Avatar `json:"avatar"`
+}
+
+// Avatar is a size of a Tumblr blog avatar image.
+type Avatar struct {
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	URL    string `json:"url"`
+}
+
+// PrimaryBlog returns the User's primary Blog or nil if the User has none
+// (e.g. new accounts).
+func (u *User) PrimaryBlog() *Blog {
+	for i := range u.Blogs {
+		if u.Blogs[i].Primary {
+			return &u.Blogs[i]
+		}
+	}
+	return nil
 }
 
 // meta is a metadata struct Tumblr includes in responses.
@@ -33,18 +64,17 @@ type meta struct {
 
 // client is a Tumblr client for obtaining a User.
 type client struct {
-	sling *sling.Sling
+	json *jsonclient.Client
 }
 
 func newClient(httpClient *http.Client) *client {
-	base := sling.New().Client(httpClient).Base(tumblrAPI)
 	return &client{
-		sling: base,
+		json: jsonclient.New(httpClient, tumblrAPI),
 	}
 }
 
-func (c *client) UserInfo() (*User, *http.Response, error) {
+func (c *client) UserInfo(ctx context.Context) (*User, *http.Response, error) {
 	userResp := new(userInfoResponse)
-	resp, err := c.sling.New().Get("user/info").ReceiveSuccess(userResp)
+	resp, err := c.json.Get(ctx, "user/info", nil, userResp, nil)
 	return &userResp.Response.User, resp, err
 }
@@ -0,0 +1,2 @@
+// Package twitch provides Twitch OAuth2 login and callback handlers.
+package twitch
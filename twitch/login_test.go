@@ -0,0 +1,140 @@
+package twitch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dghubble/gologin"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func testConfig() *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     "client_id",
+		ClientSecret: "client_secret",
+		RedirectURL:  "https://example.com/twitch/callback",
+		Endpoint:     Endpoint,
+		Scopes:       []string{"user:read:email"},
+	}
+}
+
+func TestCallbackHandler(t *testing.T) {
+	proxyClient, server := newTwitchTestServer(testUsersJSON, "")
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithState(ctx, "d4e5f6")
+
+	expectedUser := &User{
+		ID:              "141981764",
+		Login:           "twitchdev",
+		DisplayName:     "TwitchDev",
+		Email:           "not-real@email.com",
+		ProfileImageURL: "https://static-cdn.jtvnw.net/jtv_user_pictures/8a6381c7-d0c0-4576-b179-38bd5ce1d6af-profile_image-300x300.png",
+		BroadcasterType: "partner",
+	}
+	success := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "any-token", token.AccessToken)
+			assert.Equal(t, "any-refresh", token.RefreshToken)
+		}
+		user, err := UserFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, expectedUser, user)
+		}
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// CallbackHandler assert that:
+	// - the Helix users request sends the Bearer token and Client-Id
+	// - success handler is called
+	// - Twitch Token and User are added to the ctx of the success handler
+	callbackHandler := CallbackHandler(testConfig(), http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestTwitchHandler_MissingCtxToken(t *testing.T) {
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "oauth2: Context missing Token", err.Error())
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// TwitchHandler called without Token in ctx, assert that:
+	// - failure handler is called
+	// - error about ctx missing token is added to the failure handler ctx
+	twitchHandler := twitchHandler(testConfig(), success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	twitchHandler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestTwitchHandler_Errors(t *testing.T) {
+	cases := []struct {
+		name     string
+		clientID string
+		users    string
+		err      error
+		status   int
+	}{
+		{"missing Client-Id", "", testUsersJSON, ErrUnableToGetTwitchUser, http.StatusUnauthorized},
+		{"empty data", "client_id", `{"data": []}`, ErrNoTwitchUser, 0},
+	}
+	for _, c := range cases {
+		proxyClient, server := newTwitchTestServer(c.users, "")
+		// oauth2 Client will use the proxy client's base Transport
+		ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+		ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+		config := testConfig()
+		config.ClientID = c.clientID
+
+		success := testutils.AssertSuccessNotCalled(t)
+		failure := func(w http.ResponseWriter, req *http.Request) {
+			err := gologin.ErrorFromContext(req.Context())
+			assert.True(t, errors.Is(err, c.err), c.name)
+			var gologinErr *gologin.Error
+			if c.status != 0 && assert.True(t, errors.As(err, &gologinErr), c.name) {
+				assert.Equal(t, c.status, gologinErr.StatusCode, c.name)
+			}
+			fmt.Fprintf(w, "failure handler called")
+		}
+
+		// TwitchHandler without a usable users response, assert that:
+		// - a 401 (e.g. missing Client-Id) fails with ErrUnableToGetTwitchUser
+		// - an empty data array fails with ErrNoTwitchUser
+		twitchHandler := twitchHandler(config, success, http.HandlerFunc(failure))
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		twitchHandler.ServeHTTP(w, req.WithContext(ctx))
+		assert.Equal(t, "failure handler called", w.Body.String(), c.name)
+		server.Close()
+	}
+}
+
+func TestValidateResponse(t *testing.T) {
+	validUser := &User{ID: "141981764", Login: "twitchdev"}
+	validResponse := &http.Response{StatusCode: 200}
+	invalidResponse := &http.Response{StatusCode: 401}
+	assert.Equal(t, nil, validateResponse(validUser, validResponse, nil))
+	assert.True(t, errors.Is(validateResponse(validUser, validResponse, fmt.Errorf("Server error")), ErrUnableToGetTwitchUser))
+	assert.True(t, errors.Is(validateResponse(validUser, invalidResponse, nil), ErrUnableToGetTwitchUser))
+	assert.True(t, errors.Is(validateResponse(&User{}, validResponse, nil), ErrUnableToGetTwitchUser))
+	assert.Equal(t, ErrNoTwitchUser, validateResponse(nil, validResponse, nil))
+}
@@ -0,0 +1,49 @@
+package twitch
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/dghubble/gologin/testutils"
+)
+
+const (
+	// testUsersJSON is a Helix users response with the user:read:email scope.
+	testUsersJSON = `{"data": [{"id": "141981764", "login": "twitchdev", "display_name": "TwitchDev", "type": "", "broadcaster_type": "partner", "description": "Supporting third-party developers building Twitch integrations from chatbots to game integrations.", "profile_image_url": "https://static-cdn.jtvnw.net/jtv_user_pictures/8a6381c7-d0c0-4576-b179-38bd5ce1d6af-profile_image-300x300.png", "offline_image_url": "https://static-cdn.jtvnw.net/jtv_user_pictures/3f13ab61-ec78-4fe6-8481-8682cb3b0ac2-channel_offline_image-1920x1080.png", "view_count": 0, "email": "not-real@email.com", "created_at": "2016-12-14T20:32:28Z"}]}`
+	// testValidateJSON is a token validation response of the test client.
+	testValidateJSON = `{"client_id": "client_id", "login": "twitchdev", "scopes": ["user:read:email"], "user_id": "141981764", "expires_in": 5520838}`
+)
+
+// newTwitchTestServer returns a new httptest.Server which mocks the Twitch
+// token, validate, and Helix users endpoints and a client which proxies
+// requests to the server. The users endpoint responds with the given json
+// data to requests with the Bearer token and Client-Id and the validate
+// endpoint with the validate json data. The caller must close the server.
+func newTwitchTestServer(usersJSON, validateJSON string) (*http.Client, *httptest.Server) {
+	client, mux, server := testutils.TestServer()
+	mux.HandleFunc("/oauth2/token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token": "any-token", "token_type": "bearer", "expires_in": 14346, "refresh_token": "any-refresh", "scope": ["user:read:email"]}`)
+	})
+	mux.HandleFunc("/oauth2/validate", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Header.Get("Authorization") != "OAuth mobile-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprintf(w, `{"status": 401, "message": "invalid access token"}`)
+			return
+		}
+		fmt.Fprintf(w, validateJSON)
+	})
+	mux.HandleFunc("/helix/users", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		authorization := r.Header.Get("Authorization")
+		if (authorization != "Bearer any-token" && authorization != "Bearer mobile-token") || r.Header.Get("Client-Id") != "client_id" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprintf(w, `{"error": "Unauthorized", "status": 401, "message": "Client ID and OAuth token do not match"}`)
+			return
+		}
+		fmt.Fprintf(w, usersJSON)
+	})
+	return client, server
+}
@@ -0,0 +1,117 @@
+package twitch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/internal"
+	"github.com/dghubble/gologin/internal/jsonclient"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"golang.org/x/oauth2"
+)
+
+const (
+	validateURL      = "https://id.twitch.tv/oauth2/validate"
+	accessTokenField = "access_token"
+)
+
+// Twitch token errors
+var (
+	ErrMissingToken          = fmt.Errorf("twitch: missing token field %s", accessTokenField)
+	ErrUnableToValidateToken = errors.New("twitch: unable to validate access token")
+	ErrTokenClientMismatch   = errors.New("twitch: access token was issued to a different client")
+)
+
+// tokenInfo is a Twitch token validation response.
+type tokenInfo struct {
+	ClientID  string   `json:"client_id"`
+	Login     string   `json:"login"`
+	Scopes    []string `json:"scopes"`
+	UserID    string   `json:"user_id"`
+	ExpiresIn int64    `json:"expires_in"`
+}
+
+// ValidateHandler receives a Twitch access token obtained natively by a
+// mobile app as a POSTed "access_token" form or JSON field and validates it
+// with the Twitch validate endpoint. The token must be issued to the config
+// ClientID. If so, the Token and User are added to the ctx like
+// CallbackHandler and the success handler is called. Otherwise, the failure
+// handler is called with ErrMissingToken, ErrTokenClientMismatch, or
+// ErrUnableToValidateToken. Requests are limited to form or JSON POSTs of at
+// most 64KB by gologin.BodyHandler.
+func ValidateHandler(config *oauth2.Config, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	success = twitchHandler(config, success, failure)
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		accessToken := parseAccessToken(req)
+		if accessToken == "" {
+			ctx = gologin.WithError(ctx, ErrMissingToken)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		token, err := validateToken(ctx, internal.ContextClient(ctx), config, accessToken)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		ctx = oauth2Login.WithToken(ctx, token)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return gologin.BodyHandler(gologin.BodyConfig{}, http.HandlerFunc(fn), failure)
+}
+
+// parseAccessToken returns the "access_token" field of a JSON or form body.
+func parseAccessToken(req *http.Request) string {
+	if strings.HasPrefix(req.Header.Get("Content-Type"), "application/json") {
+		var body struct {
+			AccessToken string `json:"access_token"`
+		}
+		json.NewDecoder(req.Body).Decode(&body)
+		return body.AccessToken
+	}
+	return req.PostFormValue(accessTokenField)
+}
+
+// validateToken validates the access token with GET /oauth2/validate and
+// returns it as a Token if it was issued to the config ClientID.
+// https://dev.twitch.tv/docs/authentication/validate-tokens/
+func validateToken(ctx context.Context, httpClient *http.Client, config *oauth2.Config, accessToken string) (*oauth2.Token, error) {
+	info := new(tokenInfo)
+	apiErr := new(APIError)
+	jsonClient := jsonclient.New(httpClient, "")
+	req, err := jsonClient.NewRequest(ctx, "GET", validateURL, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	// the validate endpoint expects the "OAuth" authorization scheme
+	req.Header.Set("Authorization", "OAuth "+accessToken)
+	resp, err := jsonClient.Do(req, info, apiErr)
+	if err == nil && apiErr.Status != 0 {
+		err = apiErr
+	}
+	var status int
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	if err != nil || status != http.StatusOK {
+		return nil, &gologin.Error{Provider: "twitch", Op: "validate token", StatusCode: status, Err: err, Kind: ErrUnableToValidateToken}
+	}
+	if info.ClientID != config.ClientID {
+		return nil, ErrTokenClientMismatch
+	}
+	token := &oauth2.Token{AccessToken: accessToken, TokenType: "Bearer"}
+	if info.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(info.ExpiresIn) * time.Second)
+	}
+	return token, nil
+}
@@ -0,0 +1,94 @@
+package twitch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dghubble/gologin"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func TestValidateHandler(t *testing.T) {
+	proxyClient, server := newTwitchTestServer(testUsersJSON, testValidateJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+
+	success := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "mobile-token", token.AccessToken)
+			assert.WithinDuration(t, time.Now().Add(5520838*time.Second), token.Expiry, time.Minute)
+		}
+		user, err := UserFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "141981764", user.ID)
+		}
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+	handler := ValidateHandler(testConfig(), http.HandlerFunc(success), failure)
+
+	// ValidateHandler with a form or JSON access_token, assert that:
+	// - the access token is validated with the "OAuth" scheme
+	// - the Token and User are added to the ctx
+	// - success handler is called
+	form := url.Values{"access_token": {"mobile-token"}}
+	req, _ := http.NewRequest("POST", "/", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+
+	req, _ = http.NewRequest("POST", "/", strings.NewReader(`{"access_token": "mobile-token"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestValidateHandler_Errors(t *testing.T) {
+	cases := []struct {
+		name         string
+		validateJSON string
+		token        string
+		err          error
+	}{
+		{"missing token", testValidateJSON, "", ErrMissingToken},
+		{"other client", `{"client_id": "other_client", "login": "twitchdev", "scopes": [], "user_id": "141981764", "expires_in": 5520838}`, "mobile-token", ErrTokenClientMismatch},
+		{"invalid token", testValidateJSON, "revoked-token", ErrUnableToValidateToken},
+	}
+	success := testutils.AssertSuccessNotCalled(t)
+	for _, c := range cases {
+		proxyClient, server := newTwitchTestServer(testUsersJSON, c.validateJSON)
+		// oauth2 Client will use the proxy client's base Transport
+		ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+		failure := func(w http.ResponseWriter, req *http.Request) {
+			err := gologin.ErrorFromContext(req.Context())
+			assert.True(t, errors.Is(err, c.err), c.name)
+			fmt.Fprintf(w, "failure handler called")
+		}
+
+		// ValidateHandler with an unusable access token, assert that:
+		// - failure handler is called with the error
+		handler := ValidateHandler(testConfig(), success, http.HandlerFunc(failure))
+		form := url.Values{"access_token": {c.token}}
+		req, _ := http.NewRequest("POST", "/", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req.WithContext(ctx))
+		assert.Equal(t, "failure handler called", w.Body.String(), c.name)
+		server.Close()
+	}
+}
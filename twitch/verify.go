@@ -0,0 +1,68 @@
+package twitch
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/dghubble/gologin/internal/jsonclient"
+)
+
+const twitchAPI = "https://api.twitch.tv/helix/"
+
+// User is a Twitch user from the Helix users endpoint.
+type User struct {
+	ID          string `json:"id"`
+	Login       string `json:"login"`
+	DisplayName string `json:"display_name"`
+	// Email is only present with the user:read:email scope
+	Email           string `json:"email"`
+	ProfileImageURL string `json:"profile_image_url"`
+	// BroadcasterType is "partner", "affiliate", or empty
+	BroadcasterType string `json:"broadcaster_type"`
+}
+
+// usersResponse is a Helix users response.
+type usersResponse struct {
+	Data []User `json:"data"`
+}
+
+// APIError is a Twitch API error response.
+type APIError struct {
+	Err     string `json:"error"`
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("twitch: %s (%d)", e.Message, e.Status)
+}
+
+// client is a Twitch Helix client for obtaining a User.
+type client struct {
+	json *jsonclient.Client
+}
+
+// newClient returns a new Twitch client. Helix requires the Client-Id header
+// of the app, in addition to the Bearer token.
+func newClient(httpClient *http.Client, clientID string) *client {
+	return &client{
+		json: jsonclient.New(httpClient, twitchAPI).Set("Client-Id", clientID),
+	}
+}
+
+// Me gets the User of the access token, or nil if the users response has no
+// User.
+// https://dev.twitch.tv/docs/api/reference/#get-users
+func (c *client) Me(ctx context.Context) (*User, *http.Response, error) {
+	users := new(usersResponse)
+	apiErr := new(APIError)
+	resp, err := c.json.Get(ctx, "users", nil, users, apiErr)
+	if err == nil && apiErr.Status != 0 {
+		err = apiErr
+	}
+	if err != nil || len(users.Data) == 0 {
+		return nil, resp, err
+	}
+	return &users.Data[0], resp, nil
+}
@@ -3,8 +3,10 @@ package twitter
 import (
 	"context"
 	"fmt"
+	"strconv"
 
 	"github.com/dghubble/go-twitter/twitter"
+	"github.com/dghubble/gologin"
 )
 
 // unexported key type prevents collisions
@@ -14,8 +16,10 @@ const (
 	userKey key = iota
 )
 
-// WithUser returns a copy of ctx that stores the Twitter User.
+// WithUser returns a copy of ctx that stores the Twitter User and its gologin
+// Profile.
 func WithUser(ctx context.Context, user *twitter.User) context.Context {
+	ctx = gologin.WithProfile(ctx, newProfile(user))
 	return context.WithValue(ctx, userKey, user)
 }
 
@@ -27,3 +31,30 @@ func UserFromContext(ctx context.Context) (*twitter.User, error) {
 	}
 	return user, nil
 }
+
+// newProfile returns the gologin Profile of the Twitter User. Twitter only
+// returns emails to apps allowed to request them.
+func newProfile(user *twitter.User) *gologin.Profile {
+	if user == nil {
+		return nil
+	}
+	return &gologin.Profile{
+		Provider:  ProviderName,
+		ID:        strconv.FormatInt(user.ID, 10),
+		Email:     user.Email,
+		Name:      gologin.DisplayName(user.Name, user.ScreenName),
+		AvatarURL: user.ProfileImageURLHttps,
+		Raw:       gologin.ProfileRaw(user, "id", "id_str", "email", "name", "profile_image_url_https"),
+	}
+}
+
+// EmailFromContext returns the email address of the Twitter User from the
+// ctx, if Twitter returned one (see Config IncludeEmail). Returns false
+// otherwise; an empty email does not fail login since it is optional.
+func EmailFromContext(ctx context.Context) (string, bool) {
+	user, err := UserFromContext(ctx)
+	if err != nil || user.Email == "" {
+		return "", false
+	}
+	return user.Email, true
+}
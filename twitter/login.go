@@ -2,19 +2,66 @@ package twitter
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/dghubble/go-twitter/twitter"
 	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/internal"
 	oauth1Login "github.com/dghubble/gologin/oauth1"
 	"github.com/dghubble/oauth1"
 )
 
+// ProviderName is the provider name set in the ctx (see
+// gologin.ProviderFromContext) and the Profile.
+const ProviderName = "twitter"
+
 // Twitter login errors
 var (
 	ErrUnableToGetTwitterUser = errors.New("twitter: unable to get Twitter User")
+	ErrInvalidToken           = errors.New("twitter: invalid or expired Twitter access token")
+	ErrAccountSuspended       = errors.New("twitter: Twitter account is suspended or locked")
+	ErrRateLimited            = errors.New("twitter: Twitter rate limit exceeded")
+)
+
+// Twitter API error codes
+// https://developer.twitter.com/en/support/twitter-api/error-troubleshooting
+const (
+	errorCodeSuspended = 64
+	errorCodeLocked    = 326
 )
 
+// RateLimitError is the cause of rate limited Twitter User lookups.
+type RateLimitError struct {
+	// Reset is when the rate limit window resets (zero if unknown)
+	Reset time.Time
+	// Err is the Twitter API error, if any
+	Err error
+}
+
+func (e *RateLimitError) Error() string {
+	msg := ErrRateLimited.Error()
+	if !e.Reset.IsZero() {
+		msg = fmt.Sprintf("%s until %s", msg, e.Reset.UTC().Format(time.RFC3339))
+	}
+	if e.Err != nil {
+		msg = fmt.Sprintf("%s: %v", msg, e.Err)
+	}
+	return msg
+}
+
+// Unwrap returns the Twitter API error.
+func (e *RateLimitError) Unwrap() error {
+	return e.Err
+}
+
+// Is returns true for ErrRateLimited.
+func (e *RateLimitError) Is(target error) bool {
+	return target == ErrRateLimited
+}
+
 // LoginHandler handles Twitter login requests by obtaining a request token and
 // redirecting to the authorization URL.
 func LoginHandler(config *oauth1.Config, failure http.Handler) http.Handler {
@@ -23,22 +70,58 @@ func LoginHandler(config *oauth1.Config, failure http.Handler) http.Handler {
 	return oauth1Login.LoginHandler(config, success, failure)
 }
 
+// Config configures the Twitter User lookup.
+type Config struct {
+	// IncludeEmail requests the User's email address. Twitter only returns it
+	// for apps with the "Request email addresses" permission and users with
+	// a confirmed email. See EmailFromContext.
+	IncludeEmail bool
+}
+
 // CallbackHandler handles Twitter callback requests by parsing the oauth token
 // and verifier and adding the Twitter access token and User to the ctx. If
 // authentication succeeds, handling delegates to the success handler,
 // otherwise to the failure handler.
 func CallbackHandler(config *oauth1.Config, success, failure http.Handler) http.Handler {
+	return CallbackHandlerWithConfig(config, Config{}, success, failure)
+}
+
+// CallbackHandlerWithConfig handles Twitter callback requests like
+// CallbackHandler, but looks up the Twitter User according to the Config.
+func CallbackHandlerWithConfig(config *oauth1.Config, twitterConfig Config, success, failure http.Handler) http.Handler {
 	// oauth1.EmptyTempHandler -> oauth1.CallbackHandler -> TwitterHandler -> success
-	success = twitterHandler(config, success, failure)
+	success = twitterHandler(config, twitterConfig, success, failure)
 	success = oauth1Login.CallbackHandler(config, success, failure)
 	return oauth1Login.EmptyTempHandler(success)
 }
 
+// LoginHandlerWithStore handles Twitter login requests like LoginHandler, but
+// saves the request token secret in the RequestSecretStore before
+// redirecting to the authorization URL. Use it with CallbackHandlerWithStore.
+func LoginHandlerWithStore(config *oauth1.Config, store oauth1Login.RequestSecretStore, failure http.Handler) http.Handler {
+	// oauth1.LoginHandler -> oauth1.StoreTempHandler -> oauth1.AuthRedirectHandler
+	success := oauth1Login.AuthRedirectHandler(config, failure)
+	success = oauth1Login.StoreTempHandler(store, success, failure)
+	return oauth1Login.LoginHandler(config, success, failure)
+}
+
+// CallbackHandlerWithStore handles Twitter callback requests like
+// CallbackHandler, but reads the request token secret from the
+// RequestSecretStore. If the secret is missing or does not match the
+// oauth_token, the failure handler is called with the store's error (e.g.
+// oauth1 ErrMissingRequestSecret or ErrRequestSecretMismatch).
+func CallbackHandlerWithStore(config *oauth1.Config, store oauth1Login.RequestSecretStore, success, failure http.Handler) http.Handler {
+	// oauth1.StoreTempHandler -> oauth1.CallbackHandler -> TwitterHandler -> success
+	success = twitterHandler(config, Config{}, success, failure)
+	success = oauth1Login.CallbackHandler(config, success, failure)
+	return oauth1Login.StoreTempHandler(store, success, failure)
+}
+
 // twitterHandler is a http.Handler that gets the OAuth1 access token from
 // the ctx and calls Twitter verify_credentials to get the corresponding User.
 // If successful, the User is added to the ctx and the success handler is
 // called. Otherwise, the failure handler is called.
-func twitterHandler(config *oauth1.Config, success, failure http.Handler) http.Handler {
+func twitterHandler(config *oauth1.Config, twitterConfig Config, success, failure http.Handler) http.Handler {
 	if failure == nil {
 		failure = gologin.DefaultFailureHandler
 	}
@@ -50,12 +133,12 @@ func twitterHandler(config *oauth1.Config, success, failure http.Handler) http.H
 			failure.ServeHTTP(w, req.WithContext(ctx))
 			return
 		}
-		httpClient := config.Client(ctx, oauth1.NewToken(accessToken, accessSecret))
+		httpClient := internal.OAuth1Client(ctx, config, oauth1.NewToken(accessToken, accessSecret))
 		twitterClient := twitter.NewClient(httpClient)
 		accountVerifyParams := &twitter.AccountVerifyParams{
 			IncludeEntities: twitter.Bool(false),
 			SkipStatus:      twitter.Bool(true),
-			IncludeEmail:    twitter.Bool(false),
+			IncludeEmail:    twitter.Bool(twitterConfig.IncludeEmail),
 		}
 		user, resp, err := twitterClient.Accounts.VerifyCredentials(accountVerifyParams)
 		err = validateResponse(user, resp, err)
@@ -71,13 +154,62 @@ func twitterHandler(config *oauth1.Config, success, failure http.Handler) http.H
 }
 
 // validateResponse returns an error if the given Twitter user, raw
-// http.Response, or error are unexpected. Returns nil if they are valid.
+// http.Response, or error are unexpected. Returns nil if they are valid, or a
+// *gologin.Error which preserves the cause and status code. Invalid tokens,
+// suspended accounts, and rate limits also match ErrInvalidToken,
+// ErrAccountSuspended, and ErrRateLimited (with a *RateLimitError cause).
 func validateResponse(user *twitter.User, resp *http.Response, err error) error {
-	if err != nil || resp.StatusCode != http.StatusOK {
-		return ErrUnableToGetTwitterUser
+	var status int
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	if err != nil || status != http.StatusOK {
+		return &gologin.Error{Provider: "twitter", Op: "get user", StatusCode: status, Err: classifyError(resp, err), Kind: ErrUnableToGetTwitterUser}
 	}
 	if user == nil || user.ID == 0 || user.IDStr == "" {
-		return ErrUnableToGetTwitterUser
+		return &gologin.Error{Provider: "twitter", Op: "get user", StatusCode: status, Kind: ErrUnableToGetTwitterUser}
 	}
 	return nil
 }
+
+// classifyError returns the cause of a failed Twitter User lookup, wrapping
+// the error with ErrInvalidToken, ErrAccountSuspended, or a *RateLimitError
+// when the response shows one.
+func classifyError(resp *http.Response, err error) error {
+	if resp == nil {
+		return err
+	}
+	switch resp.StatusCode {
+	case http.StatusUnauthorized:
+		return wrapError(ErrInvalidToken, err)
+	case http.StatusTooManyRequests:
+		rateLimitErr := &RateLimitError{Err: err}
+		if reset, perr := strconv.ParseInt(resp.Header.Get("X-Rate-Limit-Reset"), 10, 64); perr == nil {
+			rateLimitErr.Reset = time.Unix(reset, 0)
+		}
+		return rateLimitErr
+	}
+	var details []twitter.ErrorDetail
+	var apiErr twitter.APIError
+	var apiErrPtr *twitter.APIError
+	if errors.As(err, &apiErr) {
+		details = apiErr.Errors
+	} else if errors.As(err, &apiErrPtr) {
+		details = apiErrPtr.Errors
+	}
+	for _, detail := range details {
+		if detail.Code == errorCodeSuspended || detail.Code == errorCodeLocked {
+			return wrapError(ErrAccountSuspended, err)
+		}
+	}
+	return err
+}
+
+// wrapError returns an error which matches the sentinel error and describes
+// the Twitter API error, if any.
+func wrapError(sentinel, err error) error {
+	if err == nil {
+		return sentinel
+	}
+	return fmt.Errorf("%w: %v", sentinel, err)
+}
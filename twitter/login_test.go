@@ -0,0 +1,103 @@
+package twitter
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dghubble/gologin"
+	oauth1Login "github.com/dghubble/gologin/oauth1"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/dghubble/oauth1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCallbackHandler_Denied(t *testing.T) {
+	config := &oauth1.Config{}
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, oauth1Login.ErrAccessDenied, gologin.ErrorFromContext(req.Context()))
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// CallbackHandler called after the user hit "Deny", assert that:
+	// - failure handler is called with oauth1 ErrAccessDenied
+	callbackHandler := CallbackHandler(config, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/callback?denied=any_token", nil)
+	callbackHandler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestCallbackHandlerWithStore_MissingSecret(t *testing.T) {
+	config := &oauth1.Config{}
+	store := oauth1Login.NewMemorySecretStore()
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, oauth1Login.ErrMissingRequestSecret, gologin.ErrorFromContext(req.Context()))
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// CallbackHandlerWithStore without a saved request secret, assert that:
+	// - failure handler is called with oauth1 ErrMissingRequestSecret
+	callbackHandler := CallbackHandlerWithStore(config, store, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/callback?oauth_token=any_token&oauth_verifier=any_verifier", nil)
+	callbackHandler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestCallbackHandlerWithConfig_IncludeEmail(t *testing.T) {
+	cases := []struct {
+		userJSON string
+		email    string
+		ok       bool
+	}{
+		// app with the email permission
+		{`{"id": 1234, "id_str": "1234", "screen_name": "gopher", "email": "gopher@example.com"}`, "gopher@example.com", true},
+		// app without the email permission (or user without a confirmed email)
+		{testTwitterUserJSON, "", false},
+	}
+	for _, c := range cases {
+		proxyClient, mux, server := testutils.TestServer()
+		mux.HandleFunc("/access_token", func(w http.ResponseWriter, req *http.Request) {
+			w.Header().Set("Content-Type", "application/x-www-form-urlencoded")
+			w.Write([]byte("oauth_token=access_token&oauth_token_secret=access_secret"))
+		})
+		mux.HandleFunc("/1.1/account/verify_credentials.json", func(w http.ResponseWriter, req *http.Request) {
+			assert.Equal(t, "true", req.URL.Query().Get("include_email"))
+			assert.Equal(t, "true", req.URL.Query().Get("skip_status"))
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, c.userJSON)
+		})
+		// oauth1 Client will use the proxy client's base Transport
+		ctx := context.WithValue(context.Background(), oauth1.HTTPClient, proxyClient)
+
+		config := &oauth1.Config{
+			Endpoint: oauth1.Endpoint{AccessTokenURL: server.URL + "/access_token"},
+		}
+		success := func(w http.ResponseWriter, req *http.Request) {
+			ctx := req.Context()
+			user, err := UserFromContext(ctx)
+			assert.Nil(t, err)
+			assert.Equal(t, expectedUserID, user.ID)
+			email, ok := EmailFromContext(ctx)
+			assert.Equal(t, c.email, email)
+			assert.Equal(t, c.ok, ok)
+			fmt.Fprintf(w, "success handler called")
+		}
+		failure := testutils.AssertFailureNotCalled(t)
+
+		// CallbackHandlerWithConfig with IncludeEmail, assert that:
+		// - verify_credentials is called with include_email and skip_status
+		// - success handler is called, with or without an email
+		callbackHandler := CallbackHandlerWithConfig(config, Config{IncludeEmail: true}, http.HandlerFunc(success), failure)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/callback?oauth_token=any_token&oauth_verifier=any_verifier", nil)
+		callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+		assert.Equal(t, "success handler called", w.Body.String())
+		server.Close()
+	}
+}
@@ -20,3 +20,20 @@ func newTwitterVerifyServer(jsonData string) (*http.Client, *http.ServeMux, *htt
 	})
 	return client, mux, server
 }
+
+// newTwitterVerifyErrorServer returns a new httptest.Server which responds to
+// verify credentials with the given status code, headers, and json data and a
+// client which proxies requests to the server. The caller must close the
+// server.
+func newTwitterVerifyErrorServer(status int, header http.Header, jsonData string) (*http.Client, *httptest.Server) {
+	client, mux, server := testutils.TestServer()
+	mux.HandleFunc("/1.1/account/verify_credentials.json", func(w http.ResponseWriter, r *http.Request) {
+		for key, values := range header {
+			w.Header()[key] = values
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		fmt.Fprintf(w, jsonData)
+	})
+	return client, server
+}
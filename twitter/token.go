@@ -1,8 +1,10 @@
 package twitter
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/dghubble/gologin"
 	oauth1Login "github.com/dghubble/gologin/oauth1"
@@ -24,21 +26,21 @@ var (
 // verify_credentials to get the corresponding User. If successful, the access
 // token/secret and User are added to the ctx and the success handler is
 // called. Otherwise, the failure handler is called.
+//
+// The twitterToken and twitterTokenSecret are read from a form or JSON POST
+// body (e.g. obtained natively by mobile apps). Verification failures are
+// reported like CallbackHandler, so invalid tokens, suspended accounts, and
+// rate limits match ErrInvalidToken, ErrAccountSuspended, and ErrRateLimited.
+// Requests are hardened with gologin.BodyHandler, so non-POST requests, other
+// content types, and bodies over 64KB fail with a typed gologin error.
 func TokenHandler(config *oauth1.Config, success, failure http.Handler) http.Handler {
-	success = twitterHandler(config, success, failure)
+	success = twitterHandler(config, Config{}, success, failure)
 	if failure == nil {
 		failure = gologin.DefaultFailureHandler
 	}
 	fn := func(w http.ResponseWriter, req *http.Request) {
 		ctx := req.Context()
-		if req.Method != "POST" {
-			ctx = gologin.WithError(ctx, fmt.Errorf("Method not allowed"))
-			failure.ServeHTTP(w, req.WithContext(ctx))
-			return
-		}
-		req.ParseForm()
-		accessToken := req.PostForm.Get(accessTokenField)
-		accessSecret := req.PostForm.Get(accessTokenSecretField)
+		accessToken, accessSecret := readToken(req)
 		err := validateToken(accessToken, accessSecret)
 		if err != nil {
 			ctx = gologin.WithError(ctx, err)
@@ -48,7 +50,21 @@ func TokenHandler(config *oauth1.Config, success, failure http.Handler) http.Han
 		ctx = oauth1Login.WithAccessToken(ctx, accessToken, accessSecret)
 		success.ServeHTTP(w, req.WithContext(ctx))
 	}
-	return http.HandlerFunc(fn)
+	return gologin.BodyHandler(gologin.BodyConfig{}, http.HandlerFunc(fn), failure)
+}
+
+// readToken returns the access token and secret POST'ed as JSON or a form.
+func readToken(req *http.Request) (token, tokenSecret string) {
+	if strings.HasPrefix(req.Header.Get("Content-Type"), "application/json") {
+		var body struct {
+			Token       string `json:"twitterToken"`
+			TokenSecret string `json:"twitterTokenSecret"`
+		}
+		json.NewDecoder(req.Body).Decode(&body)
+		return body.Token, body.TokenSecret
+	}
+	req.ParseForm()
+	return req.PostForm.Get(accessTokenField), req.PostForm.Get(accessTokenSecretField)
 }
 
 // validateToken returns an error if the token or token secret is missing.
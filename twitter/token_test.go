@@ -2,12 +2,15 @@ package twitter
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/dghubble/gologin"
 	oauth1Login "github.com/dghubble/gologin/oauth1"
@@ -73,7 +76,7 @@ func TestTokenHandler_ErrorVerifyingToken(t *testing.T) {
 		ctx := req.Context()
 		err := gologin.ErrorFromContext(ctx)
 		if assert.Error(t, err) {
-			assert.Equal(t, err, ErrUnableToGetTwitterUser)
+			assert.True(t, errors.Is(err, ErrUnableToGetTwitterUser))
 		}
 		fmt.Fprintf(w, "failure handler called")
 	}
@@ -90,6 +93,84 @@ func TestTokenHandler_ErrorVerifyingToken(t *testing.T) {
 	assert.Equal(t, "failure handler called", w.Body.String())
 }
 
+func TestTokenHandler_JSON(t *testing.T) {
+	proxyClient, _, server := newTwitterVerifyServer(testTwitterUserJSON)
+	defer server.Close()
+
+	// oauth1 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth1.HTTPClient, proxyClient)
+
+	config := &oauth1.Config{}
+	success := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		accessToken, accessSecret, err := oauth1Login.AccessTokenFromContext(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, testTwitterToken, accessToken)
+		assert.Equal(t, testTwitterTokenSecret, accessSecret)
+		user, err := UserFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, expectedUserID, user.ID)
+		}
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// TokenHandler with a JSON body, assert that:
+	// - access token/secret are read from the JSON body
+	// - success handler is called with the token pair and User in the ctx
+	tokenHandler := TokenHandler(config, http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	body := fmt.Sprintf(`{"twitterToken": %q, "twitterTokenSecret": %q}`, testTwitterToken, testTwitterTokenSecret)
+	req, _ := http.NewRequest("POST", "/", strings.NewReader(body))
+	req.Header.Add("Content-Type", "application/json")
+	tokenHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestTokenHandler_VerifyErrors(t *testing.T) {
+	reset := time.Now().Add(15 * time.Minute).Truncate(time.Second)
+	cases := []struct {
+		status int
+		header http.Header
+		body   string
+		err    error
+	}{
+		{http.StatusUnauthorized, nil, `{"errors": [{"code": 89, "message": "Invalid or expired token."}]}`, ErrInvalidToken},
+		{http.StatusForbidden, nil, `{"errors": [{"code": 64, "message": "Your account is suspended and is not permitted to access this feature."}]}`, ErrAccountSuspended},
+		{http.StatusForbidden, nil, `{"errors": [{"code": 326, "message": "To protect our users from spam and other malicious activity, this account is temporarily locked."}]}`, ErrAccountSuspended},
+		{http.StatusTooManyRequests, http.Header{"X-Rate-Limit-Reset": {strconv.FormatInt(reset.Unix(), 10)}}, `{"errors": [{"code": 88, "message": "Rate limit exceeded"}]}`, ErrRateLimited},
+	}
+	config := &oauth1.Config{}
+	for _, c := range cases {
+		proxyClient, server := newTwitterVerifyErrorServer(c.status, c.header, c.body)
+		// oauth1 Client will use the proxy client's base Transport
+		ctx := context.WithValue(context.Background(), oauth1.HTTPClient, proxyClient)
+		failure := func(w http.ResponseWriter, req *http.Request) {
+			err := gologin.ErrorFromContext(req.Context())
+			assert.True(t, errors.Is(err, c.err), "expected %v, got %v", c.err, err)
+			assert.True(t, errors.Is(err, ErrUnableToGetTwitterUser))
+			var rateLimitErr *RateLimitError
+			if errors.As(err, &rateLimitErr) {
+				assert.Equal(t, ErrRateLimited, c.err)
+				assert.True(t, reset.Equal(rateLimitErr.Reset))
+			}
+			fmt.Fprintf(w, "failure handler called")
+		}
+
+		// TokenHandler with rejected Twitter credentials, assert that:
+		// - failure handler is called
+		// - invalid tokens, suspended accounts, and rate limits have distinct errors
+		tokenHandler := TokenHandler(config, testutils.AssertSuccessNotCalled(t), http.HandlerFunc(failure))
+		w := httptest.NewRecorder()
+		form := url.Values{accessTokenField: {testTwitterToken}, accessTokenSecretField: {testTwitterTokenSecret}}
+		req, _ := http.NewRequest("POST", "/", strings.NewReader(form.Encode()))
+		req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+		tokenHandler.ServeHTTP(w, req.WithContext(ctx))
+		assert.Equal(t, "failure handler called", w.Body.String())
+		server.Close()
+	}
+}
+
 func TestTokenHandler_NonPost(t *testing.T) {
 	config := &oauth1.Config{}
 	ts := httptest.NewServer(TokenHandler(config, testutils.AssertSuccessNotCalled(t), nil))
@@ -97,8 +178,8 @@ func TestTokenHandler_NonPost(t *testing.T) {
 	assert.Nil(t, err)
 	// assert that default (nil) failure handler returns a 405 Method Not Allowed
 	if assert.NotNil(t, resp) {
-		// TODO: change to 405
-		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+		assert.Equal(t, "POST", resp.Header.Get("Allow"))
 	}
 }
 
@@ -109,7 +190,7 @@ func TestTokenHandler_NonPostPassesError(t *testing.T) {
 		// assert that Method not allowed error passed through ctx
 		err := gologin.ErrorFromContext(ctx)
 		if assert.Error(t, err) {
-			assert.Equal(t, err, fmt.Errorf("Method not allowed"))
+			assert.Equal(t, gologin.ErrMethodNotAllowed, err)
 		}
 	}
 	ts := httptest.NewServer(TokenHandler(config, testutils.AssertSuccessNotCalled(t), http.HandlerFunc(failure)))
@@ -0,0 +1,45 @@
+package twitterv2
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dghubble/gologin"
+)
+
+// unexported key type prevents collisions
+type key int
+
+const (
+	userKey key = iota
+)
+
+// WithUser returns a copy of ctx that stores the Twitter v2 User and its gologin
+// Profile.
+func WithUser(ctx context.Context, user *User) context.Context {
+	ctx = gologin.WithProfile(ctx, newProfile(user))
+	return context.WithValue(ctx, userKey, user)
+}
+
+// UserFromContext returns the Twitter v2 User from the ctx.
+func UserFromContext(ctx context.Context) (*User, error) {
+	user, ok := ctx.Value(userKey).(*User)
+	if !ok {
+		return nil, fmt.Errorf("twitterv2: Context missing Twitter User")
+	}
+	return user, nil
+}
+
+// newProfile returns the gologin Profile of the Twitter v2 User.
+func newProfile(user *User) *gologin.Profile {
+	if user == nil {
+		return nil
+	}
+	return &gologin.Profile{
+		Provider:  ProviderName,
+		ID:        user.ID,
+		Name:      gologin.DisplayName(user.Name, user.Username),
+		AvatarURL: user.ProfileImageURL,
+		Raw:       gologin.ProfileRaw(user, "id", "name", "profile_image_url"),
+	}
+}
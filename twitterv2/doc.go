@@ -0,0 +1,7 @@
+// Package twitterv2 provides Twitter OAuth2 (API v2) login and callback
+// handlers which use PKCE.
+//
+// Unlike the OAuth1 twitter package, Users are obtained from the API v2
+// users/me endpoint. The packages use distinct ctx keys so both may be used
+// while migrating.
+package twitterv2
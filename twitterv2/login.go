@@ -0,0 +1,107 @@
+package twitterv2
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/internal"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"golang.org/x/oauth2"
+)
+
+// ProviderName is the provider name set in the ctx (see
+// gologin.ProviderFromContext) and the Profile.
+const ProviderName = "twitterv2"
+
+// Twitter login errors
+var (
+	ErrUnableToGetTwitterUser = errors.New("twitterv2: unable to get Twitter User")
+)
+
+// Endpoint is Twitter's OAuth2 endpoint.
+var Endpoint = oauth2.Endpoint{
+	AuthURL:  "https://twitter.com/i/oauth2/authorize",
+	TokenURL: "https://api.twitter.com/2/oauth2/token",
+}
+
+// StateHandler checks for a state cookie. If found, the state value is read
+// and added to the ctx. Otherwise, a non-guessable value is added to the ctx
+// and to a (short-lived) state cookie issued to the requester.
+//
+// Implements OAuth 2 RFC 6749 10.12 CSRF Protection. If you wish to issue
+// state params differently, write a http.Handler which sets the ctx state,
+// using oauth2 WithState(ctx, state) since it is required by LoginHandler
+// and CallbackHandler.
+func StateHandler(config gologin.CookieConfig, success http.Handler) http.Handler {
+	return oauth2Login.StateHandler(config, success)
+}
+
+// LoginHandler handles Twitter login requests by reading the state value from
+// the ctx and redirecting requests to the AuthURL with that state value and a
+// PKCE code challenge, which Twitter requires. The PKCE code verifier is kept
+// in a cookie per the pkceConfig, whose Name must differ from the state cookie.
+// Any AuthCodeOptions are added to the AuthURL.
+//
+// The config Endpoint should be the twitterv2 Endpoint and Scopes must include
+// "users.read" and "tweet.read" to get the Twitter User.
+func LoginHandler(config *oauth2.Config, pkceConfig gologin.CookieConfig, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	return oauth2Login.LoginHandlerWithPKCE(config, pkceConfig, failure, opts...)
+}
+
+// CallbackHandler handles Twitter redirection URI requests and adds the
+// Twitter access token and User to the ctx. The PKCE code verifier cookie set
+// by LoginHandler is sent with the token exchange. If authentication
+// succeeds, handling delegates to the success handler, otherwise to the
+// failure handler.
+func CallbackHandler(config *oauth2.Config, pkceConfig gologin.CookieConfig, success, failure http.Handler) http.Handler {
+	success = twitterHandler(config, success, failure)
+	return oauth2Login.CallbackHandlerWithPKCE(config, pkceConfig, success, failure)
+}
+
+// twitterHandler is a http.Handler that gets the OAuth2 Token from the ctx to
+// get the corresponding Twitter v2 User. If successful, the User is added to
+// the ctx and the success handler is called. Otherwise, the failure handler
+// is called.
+func twitterHandler(config *oauth2.Config, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		httpClient := internal.OAuth2Client(ctx, config, token)
+		user, resp, err := newClient(httpClient).Me(ctx)
+		err = validateResponse(user, resp, err)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		ctx = WithUser(ctx, user)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// validateResponse returns an error if the given Twitter v2 User, raw
+// http.Response, or error are unexpected. Returns nil if they are valid, or a
+// *gologin.Error which preserves the cause and status code.
+func validateResponse(user *User, resp *http.Response, err error) error {
+	var status int
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	if err != nil || status != http.StatusOK {
+		return &gologin.Error{Provider: "twitter", Op: "get user", StatusCode: status, Err: err, Kind: ErrUnableToGetTwitterUser}
+	}
+	if user == nil || user.ID == "" {
+		return &gologin.Error{Provider: "twitter", Op: "get user", StatusCode: status, Kind: ErrUnableToGetTwitterUser}
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package twitterv2
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/dghubble/gologin"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+var testPKCEConfig = gologin.CookieConfig{
+	Name:   "twitter-pkce",
+	Path:   "/",
+	MaxAge: 60,
+}
+
+const testUserJSON = `{"data": {"id": "2244994945", "name": "Twitter Dev", "username": "TwitterDev", "profile_image_url": "https://pbs.twimg.com/profile_images/dev_normal.jpg", "verified": true}}`
+
+func testConfig() *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:    "client_id",
+		RedirectURL: "https://example.com/twitter/callback",
+		Endpoint:    Endpoint,
+		Scopes:      []string{"users.read", "tweet.read"},
+	}
+}
+
+func TestLoginHandler(t *testing.T) {
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// LoginHandler assert that:
+	// - redirects to the Twitter OAuth2 AuthURL with the state
+	// - the S256 PKCE code challenge is sent and its verifier kept in a cookie
+	loginHandler := LoginHandler(testConfig(), testPKCEConfig, failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	ctx := oauth2Login.WithState(context.Background(), "d4e5f6")
+	loginHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, http.StatusFound, w.Code)
+	location, err := url.Parse(w.HeaderMap.Get("Location"))
+	if assert.Nil(t, err) {
+		assert.Equal(t, "twitter.com", location.Host)
+		assert.Equal(t, "/i/oauth2/authorize", location.Path)
+		assert.Equal(t, "d4e5f6", location.Query().Get("state"))
+		assert.Equal(t, "S256", location.Query().Get("code_challenge_method"))
+		assert.NotEmpty(t, location.Query().Get("code_challenge"))
+	}
+	cookies := (&http.Response{Header: w.Header()}).Cookies()
+	if assert.Len(t, cookies, 1) {
+		assert.Equal(t, "twitter-pkce", cookies[0].Name)
+		assert.NotEmpty(t, cookies[0].Value)
+	}
+}
+
+func TestCallbackHandler(t *testing.T) {
+	proxyClient, server := newTwitterTestServer("some_verifier", testUserJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithState(ctx, "d4e5f6")
+
+	success := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "any-token", token.AccessToken)
+		}
+		user, err := UserFromContext(ctx)
+		if assert.Nil(t, err) {
+			expectedUser := &User{ID: "2244994945", Name: "Twitter Dev", Username: "TwitterDev", ProfileImageURL: "https://pbs.twimg.com/profile_images/dev_normal.jpg", Verified: true}
+			assert.Equal(t, expectedUser, user)
+		}
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// CallbackHandler assert that:
+	// - the PKCE code verifier is sent with the token exchange
+	// - the Twitter v2 User is obtained from users/me
+	// - success handler is called with the Token and User in the ctx
+	callbackHandler := CallbackHandler(testConfig(), testPKCEConfig, http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	req.AddCookie(&http.Cookie{Name: "twitter-pkce", Value: "some_verifier"})
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestCallbackHandler_InvalidVerifier(t *testing.T) {
+	proxyClient, server := newTwitterTestServer("some_verifier", testUserJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithState(ctx, "d4e5f6")
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		var retrieveErr *oauth2.RetrieveError
+		assert.True(t, errors.As(gologin.ErrorFromContext(req.Context()), &retrieveErr))
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// CallbackHandler with the wrong code verifier, assert that:
+	// - the token exchange fails
+	// - failure handler is called
+	callbackHandler := CallbackHandler(testConfig(), testPKCEConfig, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	req.AddCookie(&http.Cookie{Name: "twitter-pkce", Value: "other_verifier"})
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestTwitterHandler_ErrorGettingUser(t *testing.T) {
+	proxyClient, server := testutils.NewErrorServer("Twitter Service Down", http.StatusInternalServerError)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.True(t, errors.Is(err, ErrUnableToGetTwitterUser))
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// TwitterHandler cannot get Twitter User, assert that:
+	// - failure handler is called
+	// - error cannot get Twitter User added to the failure handler ctx
+	twitterHandler := twitterHandler(testConfig(), success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	twitterHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestValidateResponse(t *testing.T) {
+	validUser := &User{ID: "2244994945"}
+	validResponse := &http.Response{StatusCode: 200}
+	invalidResponse := &http.Response{StatusCode: 500}
+	assert.Equal(t, nil, validateResponse(validUser, validResponse, nil))
+	assert.True(t, errors.Is(validateResponse(validUser, validResponse, fmt.Errorf("Server error")), ErrUnableToGetTwitterUser))
+	assert.True(t, errors.Is(validateResponse(validUser, invalidResponse, nil), ErrUnableToGetTwitterUser))
+	assert.True(t, errors.Is(validateResponse(&User{}, validResponse, nil), ErrUnableToGetTwitterUser))
+	assert.True(t, errors.Is(validateResponse(nil, validResponse, nil), ErrUnableToGetTwitterUser))
+}
@@ -0,0 +1,37 @@
+package twitterv2
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/dghubble/gologin/testutils"
+)
+
+// newTwitterTestServer returns a new httptest.Server which mocks the Twitter
+// OAuth2 token and API v2 users/me endpoints and a client which proxies
+// requests to the server. The token endpoint requires the codeVerifier and
+// users/me responds with the given json data. The caller must close the
+// server.
+func newTwitterTestServer(codeVerifier, jsonData string) (*http.Client, *httptest.Server) {
+	client, mux, server := testutils.TestServer()
+	mux.HandleFunc("/2/oauth2/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.PostFormValue("code_verifier") != codeVerifier {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, `{"error": "invalid_request", "error_description": "Value passed for the authorization code was invalid."}`)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"token_type": "bearer", "expires_in": 7200, "access_token": "any-token", "scope": "users.read tweet.read"}`)
+	})
+	mux.HandleFunc("/2/users/me", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer any-token" || r.URL.Query().Get("user.fields") != "profile_image_url,verified" {
+			http.Error(w, "unexpected users/me request", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, jsonData)
+	})
+	return client, server
+}
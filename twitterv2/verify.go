@@ -0,0 +1,49 @@
+package twitterv2
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/dghubble/gologin/internal/jsonclient"
+)
+
+const (
+	twitterAPI = "https://api.twitter.com/2/"
+	userFields = "profile_image_url,verified"
+)
+
+// User is a Twitter API v2 user.
+type User struct {
+	ID              string `json:"id"`
+	Name            string `json:"name"`
+	Username        string `json:"username"`
+	ProfileImageURL string `json:"profile_image_url"`
+	Verified        bool   `json:"verified"`
+}
+
+// userResponse is a Twitter API v2 users/me response.
+type userResponse struct {
+	Data *User `json:"data"`
+}
+
+// client is a Twitter API v2 client for obtaining a User.
+type client struct {
+	json *jsonclient.Client
+}
+
+// newClient returns a new Twitter API v2 client.
+func newClient(httpClient *http.Client) *client {
+	return &client{
+		json: jsonclient.New(httpClient, twitterAPI),
+	}
+}
+
+// Me gets the authenticated user.
+// https://developer.twitter.com/en/docs/twitter-api/users/lookup/api-reference/get-users-me
+func (c *client) Me(ctx context.Context) (*User, *http.Response, error) {
+	userResp := new(userResponse)
+	params := url.Values{"user.fields": {userFields}}
+	resp, err := c.json.Get(ctx, "users/me", params, userResp, nil)
+	return userResp.Data, resp, err
+}
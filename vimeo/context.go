@@ -0,0 +1,51 @@
+package vimeo
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/dghubble/gologin"
+)
+
+// unexported key type prevents collisions
+type key int
+
+const (
+	userKey key = iota
+)
+
+// WithUser returns a copy of ctx that stores the Vimeo User and its gologin
+// Profile.
+func WithUser(ctx context.Context, user *User) context.Context {
+	ctx = gologin.WithProfile(ctx, newProfile(user))
+	return context.WithValue(ctx, userKey, user)
+}
+
+// UserFromContext returns the Vimeo User from the ctx.
+func UserFromContext(ctx context.Context) (*User, error) {
+	user, ok := ctx.Value(userKey).(*User)
+	if !ok {
+		return nil, fmt.Errorf("vimeo: Context missing Vimeo User")
+	}
+	return user, nil
+}
+
+// newProfile returns the gologin Profile of the Vimeo User.
+func newProfile(user *User) *gologin.Profile {
+	if user == nil {
+		return nil
+	}
+	// Vimeo lists pictures from smallest to largest
+	var avatarURL string
+	if len(user.Pictures) > 0 {
+		avatarURL = user.Pictures[len(user.Pictures)-1].Link
+	}
+	return &gologin.Profile{
+		Provider:  ProviderName,
+		ID:        strconv.FormatInt(user.ID, 10),
+		Name:      user.Name,
+		AvatarURL: avatarURL,
+		Raw:       gologin.ProfileRaw(user, "ID", "Name"),
+	}
+}
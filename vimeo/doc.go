@@ -0,0 +1,2 @@
+// Package vimeo provides Vimeo OAuth2 login and callback handlers.
+package vimeo
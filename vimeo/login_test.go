@@ -0,0 +1,157 @@
+package vimeo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dghubble/gologin"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+const (
+	testTokenJSON   = `{"access_token": "any-token", "token_type": "bearer", "scope": "public private", "app": {"name": "gologin", "uri": "/apps/123456"}, "user": ` + testUserJSON + `}`
+	testNoUserJSON  = `{"access_token": "any-token", "token_type": "bearer", "scope": "public private"}`
+	testBadUserJSON = `{"access_token": "any-token", "token_type": "bearer", "user": {"uri": "/channels/staffpicks", "name": "Staff Picks"}}`
+)
+
+var testUser = &User{
+	ID:   152184,
+	URI:  "/users/152184",
+	Name: "Staff Picks",
+	Link: "https://vimeo.com/staff",
+	Pictures: []Picture{
+		{Width: 30, Height: 30, Link: "https://i.vimeocdn.com/portrait/15497822_30x30"},
+		{Width: 300, Height: 300, Link: "https://i.vimeocdn.com/portrait/15497822_300x300"},
+	},
+}
+
+func testConfig() *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     "client_id",
+		ClientSecret: "client_secret",
+		RedirectURL:  "https://example.com/vimeo/callback",
+		Endpoint:     Endpoint,
+		Scopes:       []string{"public", "private"},
+	}
+}
+
+func TestCallbackHandler(t *testing.T) {
+	cases := []struct {
+		name      string
+		tokenJSON string
+	}{
+		{"token user", testTokenJSON},
+		{"no token user", testNoUserJSON},
+		{"invalid token user", testBadUserJSON},
+	}
+	for _, c := range cases {
+		proxyClient, server := newVimeoTestServer(c.tokenJSON, testUserJSON)
+		// oauth2 Client will use the proxy client's base Transport
+		ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+		ctx = oauth2Login.WithState(ctx, "d4e5f6")
+
+		success := func(w http.ResponseWriter, req *http.Request) {
+			ctx := req.Context()
+			token, err := oauth2Login.TokenFromContext(ctx)
+			if assert.Nil(t, err, c.name) {
+				assert.Equal(t, "any-token", token.AccessToken)
+			}
+			user, err := UserFromContext(ctx)
+			if assert.Nil(t, err, c.name) {
+				assert.Equal(t, testUser, user, c.name)
+			}
+			fmt.Fprintf(w, "success handler called")
+		}
+		failure := testutils.AssertFailureNotCalled(t)
+
+		// CallbackHandler assert that:
+		// - success handler is called
+		// - Vimeo Token and User (from the token response, or else /me) are
+		// added to the ctx of the success handler
+		// - the User ID is parsed from the URI
+		callbackHandler := CallbackHandler(testConfig(), http.HandlerFunc(success), failure)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+		callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+		assert.Equal(t, "success handler called", w.Body.String(), c.name)
+		server.Close()
+	}
+}
+
+func TestVimeoHandler_MissingCtxToken(t *testing.T) {
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "oauth2: Context missing Token", err.Error())
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// VimeoHandler called without Token in ctx, assert that:
+	// - failure handler is called
+	// - error about ctx missing token is added to the failure handler ctx
+	vimeoHandler := vimeoHandler(testConfig(), success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	vimeoHandler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestVimeoHandler_ErrorGettingUser(t *testing.T) {
+	proxyClient, server := newVimeoTestServer(testNoUserJSON, testUserJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "invalid-token"})
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		assert.True(t, errors.Is(err, ErrUnableToGetVimeoUser))
+		var apiErr *APIError
+		if assert.True(t, errors.As(err, &apiErr)) {
+			assert.Equal(t, 8003, apiErr.ErrorCode)
+			assert.Equal(t, "No user credentials were provided.", apiErr.DeveloperMessage)
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// VimeoHandler with an invalid token, assert that:
+	// - failure handler is called
+	// - error cannot get Vimeo User, caused by the APIError, is added to the
+	// failure handler ctx
+	vimeoHandler := vimeoHandler(testConfig(), success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	vimeoHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestParseUserID(t *testing.T) {
+	id, err := parseUserID("/users/152184")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(152184), id)
+	for _, uri := range []string{"", "/users/", "/users/staff", "/channels/152184", "/users/-1"} {
+		_, err := parseUserID(uri)
+		assert.NotNil(t, err, uri)
+	}
+}
+
+func TestValidateResponse(t *testing.T) {
+	validUser := &User{ID: 152184}
+	validResponse := &http.Response{StatusCode: 200}
+	invalidResponse := &http.Response{StatusCode: 500}
+	assert.Equal(t, nil, validateResponse(validUser, validResponse, nil))
+	assert.True(t, errors.Is(validateResponse(validUser, validResponse, fmt.Errorf("Server error")), ErrUnableToGetVimeoUser))
+	assert.True(t, errors.Is(validateResponse(validUser, invalidResponse, nil), ErrUnableToGetVimeoUser))
+	assert.True(t, errors.Is(validateResponse(&User{}, validResponse, nil), ErrUnableToGetVimeoUser))
+	assert.True(t, errors.Is(validateResponse(nil, validResponse, nil), ErrUnableToGetVimeoUser))
+}
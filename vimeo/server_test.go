@@ -0,0 +1,50 @@
+package vimeo
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/dghubble/gologin/testutils"
+)
+
+const (
+	// testUserJSON is a Vimeo user object.
+	testUserJSON = `{"uri": "/users/152184", "name": "Staff Picks", "link": "https://vimeo.com/staff", "location": "New York, NY", "pictures": {"uri": "/users/152184/pictures/15497822", "active": true, "type": "custom", "sizes": [{"width": 30, "height": 30, "link": "https://i.vimeocdn.com/portrait/15497822_30x30"}, {"width": 300, "height": 300, "link": "https://i.vimeocdn.com/portrait/15497822_300x300"}]}, "resource_key": "2c61b3b1b26c8f0c5b7b5c9d2b3b1a8e"}`
+	// testInvalidTokenJSON is a Vimeo error response.
+	testInvalidTokenJSON = `{"error": "Something strange occurred. Please contact the app owners.", "link": null, "developer_message": "No user credentials were provided.", "error_code": 8003}`
+)
+
+// newVimeoTestServer returns a new httptest.Server which mocks the Vimeo
+// token and /me endpoints and a client which proxies requests to the server.
+// The token endpoint responds with the given token json data for Basic client
+// credentials. The /me endpoint responds with the user json data as a Vimeo
+// user media type, or an error for tokens other than "any-token". The caller
+// must close the server.
+func newVimeoTestServer(tokenJSON, userJSON string) (*http.Client, *httptest.Server) {
+	client, mux, server := testutils.TestServer()
+	mux.HandleFunc("/oauth/access_token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if username, password, ok := r.BasicAuth(); !ok || username != "client_id" || password != "client_secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprintf(w, `{"error": "invalid_client", "error_description": "Client authentication failed"}`)
+			return
+		}
+		fmt.Fprintf(w, tokenJSON)
+	})
+	mux.HandleFunc("/me", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer any-token" {
+			w.Header().Set("Content-Type", "application/vnd.vimeo.error+json")
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprintf(w, testInvalidTokenJSON)
+			return
+		}
+		if r.Header.Get("Accept") != userMediaType {
+			w.WriteHeader(http.StatusNotAcceptable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/vnd.vimeo.user+json")
+		fmt.Fprintf(w, userJSON)
+	})
+	return client, server
+}
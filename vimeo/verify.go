@@ -0,0 +1,159 @@
+package vimeo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/dghubble/gologin/internal/jsonclient"
+)
+
+const (
+	vimeoAPI = "https://api.vimeo.com/"
+	// userMediaType is the Vimeo media type of user responses.
+	userMediaType = "application/vnd.vimeo.user+json"
+	// userURIPrefix is the prefix of Vimeo user URIs (e.g. /users/12345).
+	userURIPrefix = "/users/"
+)
+
+// User is a Vimeo user.
+type User struct {
+	// ID is the numeric user ID parsed from the URI
+	ID   int64
+	URI  string
+	Name string
+	Link string
+	// Pictures are the sizes of the user's profile picture
+	Pictures []Picture
+}
+
+// Picture is a size of a Vimeo profile picture.
+type Picture struct {
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	Link   string `json:"link"`
+}
+
+// APIError is a Vimeo API error response.
+type APIError struct {
+	Message          string `json:"error"`
+	DeveloperMessage string `json:"developer_message"`
+	ErrorCode        int    `json:"error_code"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("vimeo: %s (%d)", e.Message, e.ErrorCode)
+}
+
+// vimeoUser is a Vimeo user object, from /me or embedded in token responses.
+type vimeoUser struct {
+	URI      string `json:"uri"`
+	Name     string `json:"name"`
+	Link     string `json:"link"`
+	Pictures *struct {
+		Sizes []Picture `json:"sizes"`
+	} `json:"pictures"`
+}
+
+// user returns the User, with the ID parsed from the URI, or nil if the URI
+// is not a Vimeo user URI.
+func (u *vimeoUser) user() *User {
+	id, err := parseUserID(u.URI)
+	if err != nil {
+		return nil
+	}
+	user := &User{
+		ID:   id,
+		URI:  u.URI,
+		Name: u.Name,
+		Link: u.Link,
+	}
+	if u.Pictures != nil {
+		user.Pictures = u.Pictures.Sizes
+	}
+	return user
+}
+
+// parseUserID returns the numeric user ID of a Vimeo user URI.
+func parseUserID(uri string) (int64, error) {
+	if !strings.HasPrefix(uri, userURIPrefix) {
+		return 0, fmt.Errorf("vimeo: invalid user URI %q", uri)
+	}
+	id, err := strconv.ParseInt(strings.TrimPrefix(uri, userURIPrefix), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("vimeo: invalid user URI %q", uri)
+	}
+	return id, nil
+}
+
+// userFromToken returns the User from the user object the Vimeo token
+// response embeds or nil if the user is absent or invalid.
+func userFromToken(extra interface{}) *User {
+	if extra == nil {
+		return nil
+	}
+	data, err := json.Marshal(extra)
+	if err != nil {
+		return nil
+	}
+	u := new(vimeoUser)
+	if err := json.Unmarshal(data, u); err != nil {
+		return nil
+	}
+	return u.user()
+}
+
+// client is a Vimeo client for obtaining the current User.
+type client struct {
+	json *jsonclient.Client
+}
+
+// newClient returns a new Vimeo client.
+func newClient(httpClient *http.Client) *client {
+	vimeoClient := *httpClient
+	vimeoClient.Transport = &vimeoTransport{base: httpClient.Transport}
+	return &client{
+		json: jsonclient.New(&vimeoClient, vimeoAPI).Set("Accept", userMediaType),
+	}
+}
+
+// vimeoTransport is a http.RoundTripper which rewrites the Content-Type of
+// Vimeo JSON (e.g. application/vnd.vimeo.user+json) responses to
+// application/json, so they're decoded as JSON.
+type vimeoTransport struct {
+	base http.RoundTripper
+}
+
+// RoundTrip calls the base RoundTripper (or http.DefaultTransport) and
+// rewrites the Content-Type of Vimeo JSON responses.
+func (t *vimeoTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	mediaType := resp.Header.Get("Content-Type")
+	if strings.HasPrefix(mediaType, "application/vnd.vimeo.") && strings.Contains(mediaType, "+json") {
+		resp.Header.Set("Content-Type", "application/json")
+	}
+	return resp, nil
+}
+
+// Me returns the current Vimeo User, or nil if the user URI is invalid. If
+// Vimeo responds with an error, it is returned as an *APIError.
+// https://developer.vimeo.com/api/reference/users#get_user
+func (c *client) Me(ctx context.Context) (*User, *http.Response, error) {
+	u := new(vimeoUser)
+	apiErr := new(APIError)
+	resp, err := c.json.Get(ctx, "me", nil, u, apiErr)
+	if err == nil && apiErr.Message != "" {
+		err = apiErr
+	}
+	return u.user(), resp, err
+}
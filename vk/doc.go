@@ -0,0 +1,2 @@
+// Package vk provides VK (VKontakte) OAuth2 login and callback handlers.
+package vk
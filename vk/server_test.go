@@ -0,0 +1,44 @@
+package vk
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/dghubble/gologin/testutils"
+)
+
+const (
+	// testTokenJSON is a VK token response with the email and user_id extras.
+	testTokenJSON = `{"access_token": "any-token", "expires_in": 86400, "user_id": 210700286, "email": "lindsey@example.com"}`
+	// testUsersJSON is a users.get response.
+	testUsersJSON = `{"response": [{"id": 210700286, "first_name": "Lindsey", "last_name": "Stirling", "can_access_closed": true, "is_closed": false, "screen_name": "lindseystirling", "photo_200": "https://sun1-99.userapi.com/photo_200.jpg"}]}`
+	// testInvalidTokenJSON is a users.get error response (with 200 OK).
+	testInvalidTokenJSON = `{"error": {"error_code": 5, "error_msg": "User authorization failed: invalid access_token (4).", "request_params": [{"key": "method", "value": "users.get"}, {"key": "v", "value": "5.131"}]}}`
+)
+
+// newVKTestServer returns a new httptest.Server which mocks the VK
+// access_token and users.get endpoints and a client which proxies requests to
+// the server. The users.get endpoint requires the v parameter and responds
+// (200 OK) with the given json data. The caller must close the server.
+func newVKTestServer(jsonData string) (*http.Client, *httptest.Server) {
+	client, mux, server := testutils.TestServer()
+	mux.HandleFunc("/access_token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, testTokenJSON)
+	})
+	mux.HandleFunc("/method/users.get", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		w.Header().Set("Content-Type", "application/json")
+		if query.Get("v") != "5.131" {
+			fmt.Fprintf(w, `{"error": {"error_code": 8, "error_msg": "Invalid request: v (version) is required"}}`)
+			return
+		}
+		if query.Get("access_token") != "any-token" || query.Get("fields") != "photo_200,screen_name" {
+			fmt.Fprintf(w, testInvalidTokenJSON)
+			return
+		}
+		fmt.Fprintf(w, jsonData)
+	})
+	return client, server
+}
@@ -0,0 +1,89 @@
+package vk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/dghubble/gologin/internal/jsonclient"
+)
+
+const (
+	vkAPI = "https://api.vk.com/method/"
+	// apiVersion is the (mandatory) VK API version
+	apiVersion = "5.131"
+)
+
+// User is a VK user.
+type User struct {
+	ID         int64  `json:"id"`
+	FirstName  string `json:"first_name"`
+	LastName   string `json:"last_name"`
+	ScreenName string `json:"screen_name"`
+	Photo200   string `json:"photo_200"`
+	// Email is read from the token response (if the email scope was granted)
+	// since users.get does not return it
+	Email string `json:"-"`
+}
+
+// userFields are the additional User fields requested from users.get.
+const userFields = "photo_200,screen_name"
+
+// APIError is a VK API error, which VK returns in 200 OK responses.
+// https://dev.vk.com/reference/errors
+type APIError struct {
+	Code    int    `json:"error_code"`
+	Message string `json:"error_msg"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("vk: %s (code %d)", e.Message, e.Code)
+}
+
+// errCodeInvalidToken is the VK API error code for failed user authorization
+// (e.g. an invalid or expired access token).
+const errCodeInvalidToken = 5
+
+// IsInvalidToken returns true if the error wraps an *APIError for a failed
+// user authorization (code 5), such as an expired or revoked token.
+func IsInvalidToken(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.Code == errCodeInvalidToken
+}
+
+// usersGetResponse is a VK users.get response, which has either a response
+// or an error.
+type usersGetResponse struct {
+	Response []User    `json:"response"`
+	Error    *APIError `json:"error"`
+}
+
+// client is a VK client for obtaining the current User.
+type client struct {
+	json *jsonclient.Client
+}
+
+// newClient returns a new VK client.
+func newClient(httpClient *http.Client) *client {
+	return &client{
+		json: jsonclient.New(httpClient, vkAPI),
+	}
+}
+
+// UsersGet returns the User of the access token. If the VK API responds with
+// an error, it is returned as an *APIError.
+// https://dev.vk.com/method/users.get
+func (c *client) UsersGet(ctx context.Context, accessToken string) (*User, *http.Response, error) {
+	usersResp := new(usersGetResponse)
+	params := url.Values{"fields": {userFields}, "access_token": {accessToken}, "v": {apiVersion}}
+	resp, err := c.json.Get(ctx, "users.get", params, usersResp, nil)
+	if err == nil && usersResp.Error != nil {
+		err = usersResp.Error
+	}
+	if len(usersResp.Response) == 0 {
+		return nil, resp, err
+	}
+	return &usersResp.Response[0], resp, err
+}
@@ -0,0 +1,46 @@
+package wechat
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dghubble/gologin"
+)
+
+// unexported key type prevents collisions
+type key int
+
+const (
+	userKey key = iota
+)
+
+// WithUser returns a copy of ctx that stores the WeChat User and its gologin
+// Profile.
+func WithUser(ctx context.Context, user *User) context.Context {
+	ctx = gologin.WithProfile(ctx, newProfile(user))
+	return context.WithValue(ctx, userKey, user)
+}
+
+// UserFromContext returns the WeChat User from the ctx.
+func UserFromContext(ctx context.Context) (*User, error) {
+	user, ok := ctx.Value(userKey).(*User)
+	if !ok {
+		return nil, fmt.Errorf("wechat: Context missing WeChat User")
+	}
+	return user, nil
+}
+
+// newProfile returns the gologin Profile of the WeChat User. The OpenID is
+// per app, the Raw unionid identifies users across apps.
+func newProfile(user *User) *gologin.Profile {
+	if user == nil {
+		return nil
+	}
+	return &gologin.Profile{
+		Provider:  ProviderName,
+		ID:        user.OpenID,
+		Name:      user.Nickname,
+		AvatarURL: user.HeadImgURL,
+		Raw:       gologin.ProfileRaw(user, "openid", "nickname", "headimgurl"),
+	}
+}
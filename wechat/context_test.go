@@ -0,0 +1,24 @@
+package wechat
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContextUser(t *testing.T) {
+	expectedUser := &User{OpenID: "oLVPpjqs9BhvzwPj5A-vTYAX3GLc", UnionID: "o6_bmasdasdsad6_2sgVt7hMZOPfL"}
+	ctx := WithUser(context.Background(), expectedUser)
+	user, err := UserFromContext(ctx)
+	assert.Equal(t, expectedUser, user)
+	assert.Nil(t, err)
+}
+
+func TestContextUser_Error(t *testing.T) {
+	user, err := UserFromContext(context.Background())
+	assert.Nil(t, user)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "wechat: Context missing WeChat User", err.Error())
+	}
+}
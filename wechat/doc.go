@@ -0,0 +1,2 @@
+// Package wechat provides WeChat OAuth2 login and callback handlers.
+package wechat
@@ -0,0 +1,194 @@
+package wechat
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/internal"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+)
+
+// ProviderName is the provider name set in the ctx (see
+// gologin.ProviderFromContext) and the Profile.
+const ProviderName = "wechat"
+
+// WeChat login errors
+var (
+	ErrUnableToGetWeChatToken = errors.New("wechat: unable to get WeChat access token")
+	ErrUnableToGetWeChatUser  = errors.New("wechat: unable to get WeChat User")
+	ErrMissingCode            = errors.New("wechat: Request missing code or state")
+)
+
+const (
+	// qrConnectURL is the authorization URL of website apps, which shows a
+	// QR code to scan with the WeChat app.
+	qrConnectURL = "https://open.weixin.qq.com/connect/qrconnect"
+	// officialAccountAuthURL is the authorization URL of official account
+	// web pages, opened within the WeChat app.
+	officialAccountAuthURL = "https://open.weixin.qq.com/connect/oauth2/authorize"
+)
+
+// Config configures WeChat login. WeChat's OAuth2 flow is incompatible with
+// golang.org/x/oauth2 (the token exchange is a GET with appid and secret
+// parameters), so the handlers take a Config instead of an oauth2.Config.
+type Config struct {
+	AppID       string
+	AppSecret   string
+	RedirectURL string
+	// Scopes defaults to snsapi_login for website apps and snsapi_userinfo
+	// for official accounts.
+	Scopes []string
+	// OfficialAccount selects the official account authorization URL,
+	// instead of the website app QR code login.
+	OfficialAccount bool
+}
+
+// AuthCodeURL returns the WeChat authorization URL with the state.
+func (c Config) AuthCodeURL(state string) string {
+	scopes := c.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"snsapi_login"}
+		if c.OfficialAccount {
+			scopes = []string{"snsapi_userinfo"}
+		}
+	}
+	// WeChat requires the parameters in this order
+	params := []string{
+		"appid=" + url.QueryEscape(c.AppID),
+		"redirect_uri=" + url.QueryEscape(c.RedirectURL),
+		"response_type=code",
+		"scope=" + url.QueryEscape(strings.Join(scopes, ",")),
+		"state=" + url.QueryEscape(state),
+	}
+	if c.OfficialAccount {
+		return officialAccountAuthURL + "?" + strings.Join(params, "&") + "#wechat_redirect"
+	}
+	return qrConnectURL + "?" + strings.Join(params, "&")
+}
+
+// StateHandler checks for a state cookie. If found, the state value is read
+// and added to the ctx. Otherwise, a non-guessable value is added to the ctx
+// and to a (short-lived) state cookie issued to the requester.
+//
+// Implements OAuth 2 RFC 6749 10.12 CSRF Protection. If you wish to issue
+// state params differently, write a http.Handler which sets the ctx state,
+// using oauth2 WithState(ctx, state) since it is required by LoginHandler
+// and CallbackHandler.
+func StateHandler(config gologin.CookieConfig, success http.Handler) http.Handler {
+	return oauth2Login.StateHandler(config, success)
+}
+
+// LoginHandler handles WeChat login requests by reading the state value from
+// the ctx and redirecting requests to the WeChat authorization URL with that
+// state value.
+func LoginHandler(config Config, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		state, err := oauth2Login.StateFromContext(ctx)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		http.Redirect(w, req, config.AuthCodeURL(state), http.StatusFound)
+	}
+	return http.HandlerFunc(fn)
+}
+
+// CallbackHandler handles WeChat redirection URI requests by checking the
+// state, exchanging the code for an access token (a GET request with the
+// appid and secret), and adding the Token and WeChat User to the ctx. If
+// authentication succeeds, handling delegates to the success handler,
+// otherwise to the failure handler.
+//
+// The Token has the "openid" and "unionid" as extras. WeChat responds to
+// failed requests with HTTP 200 and an errcode, which fail as an *APIError.
+func CallbackHandler(config Config, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		params := req.URL.Query()
+		// users who deny access are redirected without a code
+		code, state := params.Get("code"), params.Get("state")
+		if code == "" || state == "" {
+			ctx = gologin.WithError(ctx, ErrMissingCode)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		ownerState, err := oauth2Login.StateFromContext(ctx)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		if !internal.EqualSecrets(state, ownerState) {
+			ctx = gologin.WithError(ctx, oauth2Login.ErrInvalidState)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		wechatClient := newClient(internal.ContextClient(ctx))
+		tokenResp, resp, err := wechatClient.Exchange(ctx, config.AppID, config.AppSecret, code)
+		err = validateToken(tokenResp, resp, err)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		ctx = oauth2Login.WithToken(ctx, tokenResp.token())
+		user, resp, err := wechatClient.UserInfo(ctx, tokenResp.AccessToken, tokenResp.OpenID)
+		err = validateResponse(user, tokenResp.OpenID, resp, err)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		if user.UnionID == "" {
+			user.UnionID = tokenResp.UnionID
+		}
+		ctx = WithUser(ctx, user)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// validateToken returns an error if the given WeChat token response, raw
+// http.Response, or error are unexpected. Returns nil if they are valid, or a
+// *gologin.Error which preserves the cause and status code.
+func validateToken(tokenResp *tokenResponse, resp *http.Response, err error) error {
+	var status int
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	if err != nil || status != http.StatusOK {
+		return &gologin.Error{Provider: "wechat", Op: "get token", StatusCode: status, Err: err, Kind: ErrUnableToGetWeChatToken}
+	}
+	if tokenResp == nil || tokenResp.AccessToken == "" || tokenResp.OpenID == "" {
+		return &gologin.Error{Provider: "wechat", Op: "get token", StatusCode: status, Kind: ErrUnableToGetWeChatToken}
+	}
+	return nil
+}
+
+// validateResponse returns an error if the given WeChat User, raw
+// http.Response, or error are unexpected. Returns nil if they are valid, or a
+// *gologin.Error which preserves the cause and status code.
+func validateResponse(user *User, openID string, resp *http.Response, err error) error {
+	var status int
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	if err != nil || status != http.StatusOK {
+		return &gologin.Error{Provider: "wechat", Op: "get user", StatusCode: status, Err: err, Kind: ErrUnableToGetWeChatUser}
+	}
+	if user == nil || user.OpenID != openID {
+		return &gologin.Error{Provider: "wechat", Op: "get user", StatusCode: status, Kind: ErrUnableToGetWeChatUser}
+	}
+	return nil
+}
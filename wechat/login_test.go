@@ -0,0 +1,237 @@
+package wechat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/dghubble/gologin"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+var testWeChatConfig = Config{
+	AppID:       "app_id",
+	AppSecret:   "app_secret",
+	RedirectURL: "https://example.com/wechat/callback",
+}
+
+func TestLoginHandler(t *testing.T) {
+	cases := []struct {
+		config Config
+		url    string
+		scope  string
+	}{
+		{testWeChatConfig, qrConnectURL, "snsapi_login"},
+		{Config{AppID: "app_id", RedirectURL: testWeChatConfig.RedirectURL, OfficialAccount: true}, officialAccountAuthURL, "snsapi_userinfo"},
+		{Config{AppID: "app_id", RedirectURL: testWeChatConfig.RedirectURL, OfficialAccount: true, Scopes: []string{"snsapi_base"}}, officialAccountAuthURL, "snsapi_base"},
+	}
+	for _, c := range cases {
+		failure := testutils.AssertFailureNotCalled(t)
+
+		// LoginHandler assert that:
+		// - redirects to the website app or official account authorization URL
+		// - the appid, redirect_uri, scope, and state are set
+		loginHandler := LoginHandler(c.config, failure)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		ctx := oauth2Login.WithState(context.Background(), "d4e5f6")
+		loginHandler.ServeHTTP(w, req.WithContext(ctx))
+		assert.Equal(t, http.StatusFound, w.Code)
+		location, err := url.Parse(w.HeaderMap.Get("Location"))
+		if assert.Nil(t, err) {
+			assert.True(t, strings.HasPrefix(location.String(), c.url+"?appid=app_id&"))
+			assert.Equal(t, c.config.OfficialAccount, location.Fragment == "wechat_redirect")
+			query := location.Query()
+			assert.Equal(t, "https://example.com/wechat/callback", query.Get("redirect_uri"))
+			assert.Equal(t, "code", query.Get("response_type"))
+			assert.Equal(t, c.scope, query.Get("scope"))
+			assert.Equal(t, "d4e5f6", query.Get("state"))
+		}
+	}
+}
+
+func TestLoginHandler_MissingCtxState(t *testing.T) {
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "oauth2: Context missing state value", err.Error())
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// LoginHandler called without state in ctx, assert that:
+	// - failure handler is called
+	loginHandler := LoginHandler(testWeChatConfig, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	loginHandler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestCallbackHandler(t *testing.T) {
+	proxyClient, server := newWeChatTestServer(testTokenJSON, testUserJSON)
+	defer server.Close()
+	// WeChat requests use the ctx client's Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithState(ctx, "d4e5f6")
+
+	expectedUser := &User{
+		OpenID:     testOpenID,
+		UnionID:    "o6_bmasdasdsad6_2sgVt7hMZOPfL",
+		Nickname:   "Lin",
+		HeadImgURL: "https://thirdwx.qlogo.cn/mmopen/g3MonUZtNHkdmzicIlibx6iaFqAc56vxLSUfpb6n5WKSYVY0ChQKkiaJSgQ1dZuTOgvLLrhJbERQQ4eMsv84eavHiaiceqxibJxCfHe/0",
+		Sex:        2,
+		Country:    "CN",
+	}
+	success := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "ACCESS_TOKEN", token.AccessToken)
+			assert.Equal(t, "REFRESH_TOKEN", token.RefreshToken)
+			assert.False(t, token.Expiry.IsZero())
+			assert.Equal(t, testOpenID, token.Extra("openid"))
+			assert.Equal(t, "o6_bmasdasdsad6_2sgVt7hMZOPfL", token.Extra("unionid"))
+		}
+		user, err := UserFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, expectedUser, user)
+		}
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// CallbackHandler assert that:
+	// - the code is exchanged with a GET request
+	// - success handler is called
+	// - WeChat Token and User are added to the ctx of the success handler
+	callbackHandler := CallbackHandler(testWeChatConfig, http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestCallbackHandler_TokenUnionID(t *testing.T) {
+	// userinfo responses of apps not bound to an Open Platform account have
+	// no unionid
+	proxyClient, server := newWeChatTestServer(testTokenJSON, `{"openid": "oLVPpjqs9BhvzwPj5A-vTYAX3GLc", "nickname": "Lin"}`)
+	defer server.Close()
+	// WeChat requests use the ctx client's Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithState(ctx, "d4e5f6")
+
+	success := func(w http.ResponseWriter, req *http.Request) {
+		user, err := UserFromContext(req.Context())
+		if assert.Nil(t, err) {
+			assert.Equal(t, "o6_bmasdasdsad6_2sgVt7hMZOPfL", user.UnionID)
+		}
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// CallbackHandler with a userinfo response without a unionid, assert
+	// that:
+	// - the User UnionID is read from the token response
+	callbackHandler := CallbackHandler(testWeChatConfig, http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestCallbackHandler_Errors(t *testing.T) {
+	cases := []struct {
+		name     string
+		config   Config
+		query    string
+		userJSON string
+		err      error
+		errCode  int
+	}{
+		{"denied", testWeChatConfig, "?state=d4e5f6", testUserJSON, ErrMissingCode, 0},
+		{"invalid state", testWeChatConfig, "?code=any_code&state=other", testUserJSON, oauth2Login.ErrInvalidState, 0},
+		{"invalid code", testWeChatConfig, "?code=other_code&state=d4e5f6", testUserJSON, ErrUnableToGetWeChatToken, 40029},
+		{"invalid secret", Config{AppID: "app_id", AppSecret: "other"}, "?code=any_code&state=d4e5f6", testUserJSON, ErrUnableToGetWeChatToken, 40029},
+		{"userinfo error", testWeChatConfig, "?code=any_code&state=d4e5f6", testInvalidTokenJSON, ErrUnableToGetWeChatUser, 40001},
+		{"other openid", testWeChatConfig, "?code=any_code&state=d4e5f6", `{"openid": "oLVPpjqs9BhvzwPj5A-other"}`, ErrUnableToGetWeChatUser, 0},
+	}
+	for _, c := range cases {
+		proxyClient, server := newWeChatTestServer(testTokenJSON, c.userJSON)
+		// WeChat requests use the ctx client's Transport
+		ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+		ctx = oauth2Login.WithState(ctx, "d4e5f6")
+
+		success := testutils.AssertSuccessNotCalled(t)
+		failure := func(w http.ResponseWriter, req *http.Request) {
+			err := gologin.ErrorFromContext(req.Context())
+			assert.True(t, errors.Is(err, c.err), c.name)
+			var apiErr *APIError
+			if c.errCode != 0 && assert.True(t, errors.As(err, &apiErr), c.name) {
+				assert.Equal(t, c.errCode, apiErr.ErrCode)
+			}
+			fmt.Fprintf(w, "failure handler called")
+		}
+
+		// CallbackHandler with an invalid callback or WeChat errcode, assert
+		// that:
+		// - failure handler is called with the distinct error
+		// - WeChat errcode errors are kept as an *APIError
+		callbackHandler := CallbackHandler(c.config, success, http.HandlerFunc(failure))
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/"+c.query, nil)
+		callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+		assert.Equal(t, "failure handler called", w.Body.String(), c.name)
+		server.Close()
+	}
+}
+
+func TestCallbackHandler_MissingCtxState(t *testing.T) {
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "oauth2: Context missing state value", err.Error())
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// CallbackHandler called without state in ctx, assert that:
+	// - failure handler is called
+	// - error about ctx missing state is added to the failure handler ctx
+	callbackHandler := CallbackHandler(testWeChatConfig, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	callbackHandler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestValidateToken(t *testing.T) {
+	validToken := &tokenResponse{AccessToken: "ACCESS_TOKEN", OpenID: testOpenID}
+	validResponse := &http.Response{StatusCode: 200}
+	invalidResponse := &http.Response{StatusCode: 500}
+	assert.Equal(t, nil, validateToken(validToken, validResponse, nil))
+	assert.True(t, errors.Is(validateToken(validToken, validResponse, fmt.Errorf("Server error")), ErrUnableToGetWeChatToken))
+	assert.True(t, errors.Is(validateToken(validToken, invalidResponse, nil), ErrUnableToGetWeChatToken))
+	assert.True(t, errors.Is(validateToken(nil, validResponse, nil), ErrUnableToGetWeChatToken))
+	assert.True(t, errors.Is(validateToken(&tokenResponse{AccessToken: "ACCESS_TOKEN"}, validResponse, nil), ErrUnableToGetWeChatToken))
+}
+
+func TestValidateResponse(t *testing.T) {
+	validUser := &User{OpenID: testOpenID, Nickname: "Lin"}
+	validResponse := &http.Response{StatusCode: 200}
+	invalidResponse := &http.Response{StatusCode: 500}
+	assert.Equal(t, nil, validateResponse(validUser, testOpenID, validResponse, nil))
+	assert.True(t, errors.Is(validateResponse(validUser, testOpenID, validResponse, fmt.Errorf("Server error")), ErrUnableToGetWeChatUser))
+	assert.True(t, errors.Is(validateResponse(validUser, testOpenID, invalidResponse, nil), ErrUnableToGetWeChatUser))
+	assert.True(t, errors.Is(validateResponse(nil, testOpenID, validResponse, nil), ErrUnableToGetWeChatUser))
+	assert.True(t, errors.Is(validateResponse(validUser, "oLVPpjqs9BhvzwPj5A-other", validResponse, nil), ErrUnableToGetWeChatUser))
+}
@@ -0,0 +1,54 @@
+package wechat
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/dghubble/gologin/testutils"
+)
+
+const (
+	// testOpenID is the openid of the test user.
+	testOpenID = "oLVPpjqs9BhvzwPj5A-vTYAX3GLc"
+	// testTokenJSON is an access_token response.
+	testTokenJSON = `{"access_token": "ACCESS_TOKEN", "expires_in": 7200, "refresh_token": "REFRESH_TOKEN", "openid": "oLVPpjqs9BhvzwPj5A-vTYAX3GLc", "scope": "snsapi_login", "unionid": "o6_bmasdasdsad6_2sgVt7hMZOPfL"}`
+	// testUserJSON is a userinfo response.
+	testUserJSON = `{"openid": "oLVPpjqs9BhvzwPj5A-vTYAX3GLc", "nickname": "Lin", "sex": 2, "province": "Guangdong", "city": "Shenzhen", "country": "CN", "headimgurl": "https://thirdwx.qlogo.cn/mmopen/g3MonUZtNHkdmzicIlibx6iaFqAc56vxLSUfpb6n5WKSYVY0ChQKkiaJSgQ1dZuTOgvLLrhJbERQQ4eMsv84eavHiaiceqxibJxCfHe/0", "privilege": [], "unionid": "o6_bmasdasdsad6_2sgVt7hMZOPfL"}`
+	// testInvalidCodeJSON is a WeChat error response to an invalid code.
+	testInvalidCodeJSON = `{"errcode": 40029, "errmsg": "invalid code"}`
+	// testInvalidTokenJSON is a WeChat error response to an invalid access
+	// token.
+	testInvalidTokenJSON = `{"errcode": 40001, "errmsg": "invalid credential, access_token is invalid or not latest"}`
+)
+
+// newWeChatTestServer returns a new httptest.Server which mocks the WeChat
+// access_token and userinfo endpoints and a client which proxies requests to
+// the server. Like WeChat, the server responds with text/plain JSON and with
+// HTTP 200 errcode errors. access_token responds with the tokenJSON for the
+// "any_code" code of appid "app_id" and secret "app_secret". userinfo
+// responds with the userJSON for the "ACCESS_TOKEN" and testOpenID. The
+// caller must close the server.
+func newWeChatTestServer(tokenJSON, userJSON string) (*http.Client, *httptest.Server) {
+	client, mux, server := testutils.TestServer()
+	mux.HandleFunc("/sns/oauth2/access_token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		query := r.URL.Query()
+		if r.Method != "GET" || query.Get("appid") != "app_id" || query.Get("secret") != "app_secret" ||
+			query.Get("code") != "any_code" || query.Get("grant_type") != "authorization_code" {
+			fmt.Fprintf(w, testInvalidCodeJSON)
+			return
+		}
+		fmt.Fprintf(w, tokenJSON)
+	})
+	mux.HandleFunc("/sns/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		query := r.URL.Query()
+		if query.Get("access_token") != "ACCESS_TOKEN" || query.Get("openid") != testOpenID {
+			fmt.Fprintf(w, testInvalidTokenJSON)
+			return
+		}
+		fmt.Fprintf(w, userJSON)
+	})
+	return client, server
+}
@@ -0,0 +1,145 @@
+package wechat
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/dghubble/gologin/internal/jsonclient"
+	"golang.org/x/oauth2"
+)
+
+const wechatAPI = "https://api.weixin.qq.com/sns/"
+
+// User is a WeChat user. The UnionID identifies the user across all apps of
+// the same WeChat Open Platform account, while the OpenID is specific to the
+// app.
+type User struct {
+	OpenID     string `json:"openid"`
+	UnionID    string `json:"unionid"`
+	Nickname   string `json:"nickname"`
+	HeadImgURL string `json:"headimgurl"`
+	// Sex is 1 for male, 2 for female, and 0 if unknown
+	Sex     int    `json:"sex"`
+	Country string `json:"country"`
+}
+
+// APIError is a WeChat API error. WeChat responds to failed requests with
+// HTTP 200 and a non-zero errcode.
+type APIError struct {
+	ErrCode int    `json:"errcode"`
+	ErrMsg  string `json:"errmsg"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("wechat: %s (%d)", e.ErrMsg, e.ErrCode)
+}
+
+// tokenResponse is a WeChat access_token response.
+type tokenResponse struct {
+	APIError
+	AccessToken  string `json:"access_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+	OpenID       string `json:"openid"`
+	Scope        string `json:"scope"`
+	UnionID      string `json:"unionid"`
+}
+
+// token returns the tokenResponse as an oauth2.Token, with the "openid",
+// "unionid", and "scope" as extras.
+func (t *tokenResponse) token() *oauth2.Token {
+	token := &oauth2.Token{
+		AccessToken:  t.AccessToken,
+		TokenType:    "Bearer",
+		RefreshToken: t.RefreshToken,
+	}
+	if t.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(t.ExpiresIn) * time.Second)
+	}
+	return token.WithExtra(map[string]interface{}{
+		"openid":  t.OpenID,
+		"unionid": t.UnionID,
+		"scope":   t.Scope,
+	})
+}
+
+// userResponse is a WeChat userinfo response.
+type userResponse struct {
+	APIError
+	User
+}
+
+// client is a WeChat client for obtaining a Token and User.
+type client struct {
+	json *jsonclient.Client
+}
+
+// newClient returns a new WeChat client.
+func newClient(httpClient *http.Client) *client {
+	wechatClient := *httpClient
+	wechatClient.Transport = &jsonTransport{base: httpClient.Transport}
+	return &client{
+		json: jsonclient.New(&wechatClient, wechatAPI),
+	}
+}
+
+// jsonTransport is a http.RoundTripper which rewrites the Content-Type of
+// WeChat (text/plain) responses to application/json, so they're decoded as
+// JSON.
+type jsonTransport struct {
+	base http.RoundTripper
+}
+
+// RoundTrip calls the base RoundTripper (or http.DefaultTransport) and
+// rewrites the Content-Type of text/plain responses.
+func (t *jsonTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/plain") {
+		resp.Header.Set("Content-Type", "application/json")
+	}
+	return resp, nil
+}
+
+// Exchange uses a GET request to exchange the authorization code for an
+// access token. If WeChat responds with an errcode, it is returned as an
+// *APIError.
+// https://developers.weixin.qq.com/doc/oplatform/en/Website_App/WeChat_Login/Wechat_Login.html
+func (c *client) Exchange(ctx context.Context, appID, secret, code string) (*tokenResponse, *http.Response, error) {
+	tokenResp := new(tokenResponse)
+	// WeChat names the client credentials appid and secret
+	params := url.Values{
+		"appid":      {appID},
+		"secret":     {secret},
+		"code":       {code},
+		"grant_type": {"authorization_code"},
+	}
+	resp, err := c.json.Get(ctx, "oauth2/access_token", params, tokenResp, nil)
+	if err == nil && tokenResp.ErrCode != 0 {
+		err = &APIError{ErrCode: tokenResp.ErrCode, ErrMsg: tokenResp.ErrMsg}
+	}
+	return tokenResp, resp, err
+}
+
+// UserInfo gets the WeChat User of the access token and openid. If WeChat
+// responds with an errcode, it is returned as an *APIError.
+// https://developers.weixin.qq.com/doc/oplatform/en/Website_App/WeChat_Login/Authorized_Interface_Calling_UnionID.html
+func (c *client) UserInfo(ctx context.Context, accessToken, openID string) (*User, *http.Response, error) {
+	userResp := new(userResponse)
+	params := url.Values{"access_token": {accessToken}, "openid": {openID}}
+	resp, err := c.json.Get(ctx, "userinfo", params, userResp, nil)
+	if err == nil && userResp.ErrCode != 0 {
+		err = &APIError{ErrCode: userResp.ErrCode, ErrMsg: userResp.ErrMsg}
+	}
+	return &userResp.User, resp, err
+}
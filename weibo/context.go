@@ -0,0 +1,57 @@
+package weibo
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/dghubble/gologin"
+)
+
+// unexported key type prevents collisions
+type key int
+
+const (
+	userKey key = iota
+)
+
+// WithUser returns a copy of ctx that stores the Weibo User and its gologin
+// Profile.
+func WithUser(ctx context.Context, user *User) context.Context {
+	ctx = gologin.WithProfile(ctx, newProfile(user))
+	return context.WithValue(ctx, userKey, user)
+}
+
+// UserFromContext returns the Weibo User from the ctx.
+func UserFromContext(ctx context.Context) (*User, error) {
+	user, ok := ctx.Value(userKey).(*User)
+	if !ok {
+		return nil, fmt.Errorf("weibo: Context missing Weibo User")
+	}
+	return user, nil
+}
+
+// newProfile returns the gologin Profile of the Weibo User.
+func newProfile(user *User) *gologin.Profile {
+	if user == nil {
+		return nil
+	}
+	return &gologin.Profile{
+		Provider:  ProviderName,
+		ID:        userID(user),
+		Name:      gologin.DisplayName(user.ScreenName, user.Name),
+		AvatarURL: user.ProfileImageURL,
+		Raw:       gologin.ProfileRaw(user, "id", "idstr", "screen_name", "profile_image_url"),
+	}
+}
+
+// userID returns the User's IDStr, or its ID if the IDStr is missing.
+func userID(user *User) string {
+	if user.IDStr != "" {
+		return user.IDStr
+	}
+	if user.ID != 0 {
+		return strconv.FormatInt(user.ID, 10)
+	}
+	return ""
+}
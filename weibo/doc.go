@@ -0,0 +1,2 @@
+// Package weibo provides Sina Weibo OAuth2 login and callback handlers.
+package weibo
@@ -0,0 +1,123 @@
+package weibo
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/internal"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"golang.org/x/oauth2"
+)
+
+// ProviderName is the provider name set in the ctx (see
+// gologin.ProviderFromContext) and the Profile.
+const ProviderName = "weibo"
+
+// Weibo login errors
+var (
+	ErrUnableToGetWeiboUser = errors.New("weibo: unable to get Weibo User")
+	ErrMissingUID           = errors.New("weibo: token response missing uid")
+)
+
+// Endpoint is Weibo's OAuth2 endpoint.
+var Endpoint = oauth2.Endpoint{
+	AuthURL:   "https://api.weibo.com/oauth2/authorize",
+	TokenURL:  "https://api.weibo.com/oauth2/access_token",
+	AuthStyle: oauth2.AuthStyleInParams,
+}
+
+// StateHandler checks for a state cookie. If found, the state value is read
+// and added to the ctx. Otherwise, a non-guessable value is added to the ctx
+// and to a (short-lived) state cookie issued to the requester.
+//
+// Implements OAuth 2 RFC 6749 10.12 CSRF Protection. If you wish to issue
+// state params differently, write a http.Handler which sets the ctx state,
+// using oauth2 WithState(ctx, state) since it is required by LoginHandler
+// and CallbackHandler.
+func StateHandler(config gologin.CookieConfig, success http.Handler) http.Handler {
+	return oauth2Login.StateHandler(config, success)
+}
+
+// LoginHandler handles Weibo login requests by reading the state value from
+// the ctx and redirecting requests to the AuthURL with that state value.
+// Any AuthCodeOptions are added to the AuthURL.
+func LoginHandler(config *oauth2.Config, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	return oauth2Login.LoginHandler(config, failure, opts...)
+}
+
+// CallbackHandler handles Weibo redirection URI requests and adds the Weibo
+// access token and User to the ctx. If authentication succeeds, handling
+// delegates to the success handler, otherwise to the failure handler.
+// Any AuthCodeOptions are sent with the token exchange.
+func CallbackHandler(config *oauth2.Config, success, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	success = weiboHandler(success, failure)
+	return oauth2Login.CallbackHandler(config, success, failure, opts...)
+}
+
+// weiboHandler is a http.Handler that gets the OAuth2 Token from the ctx to
+// get the Weibo User of the token response uid. If successful, the User is
+// added to the ctx and the success handler is called. Otherwise, the failure
+// handler is called.
+func weiboHandler(success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		uid := tokenUID(token)
+		if uid == "" {
+			ctx = gologin.WithError(ctx, ErrMissingUID)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		// Weibo API requests pass the access_token as a parameter
+		user, resp, err := newClient(internal.ContextClient(ctx)).UsersShow(ctx, token.AccessToken, uid)
+		err = validateResponse(user, resp, err)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		ctx = WithUser(ctx, user)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// tokenUID returns the uid of the Weibo token response, which is a string
+// (or, from some clients, a number).
+func tokenUID(token *oauth2.Token) string {
+	switch uid := token.Extra("uid").(type) {
+	case string:
+		return uid
+	case float64:
+		return strconv.FormatFloat(uid, 'f', -1, 64)
+	}
+	return ""
+}
+
+// validateResponse returns an error if the given Weibo User, raw
+// http.Response, or error are unexpected. Returns nil if they are valid, or a
+// *gologin.Error which preserves the cause (e.g. an *APIError) and status
+// code.
+func validateResponse(user *User, resp *http.Response, err error) error {
+	var status int
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	if err != nil || status != http.StatusOK {
+		return &gologin.Error{Provider: "weibo", Op: "get user", StatusCode: status, Err: err, Kind: ErrUnableToGetWeiboUser}
+	}
+	if user == nil || userID(user) == "" {
+		return &gologin.Error{Provider: "weibo", Op: "get user", StatusCode: status, Kind: ErrUnableToGetWeiboUser}
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package weibo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dghubble/gologin"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func testConfig() *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     "client_id",
+		ClientSecret: "client_secret",
+		RedirectURL:  "https://example.com/weibo/callback",
+		Endpoint:     Endpoint,
+	}
+}
+
+func TestCallbackHandler(t *testing.T) {
+	cases := []struct {
+		name      string
+		tokenJSON string
+	}{
+		{"string uid", testTokenJSON},
+		{"number uid", `{"access_token": "any-token", "expires_in": 157679999, "uid": 5901669835}`},
+	}
+	for _, c := range cases {
+		proxyClient, server := newWeiboTestServer(c.tokenJSON, testUserJSON)
+		// oauth2 Client will use the proxy client's base Transport
+		ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+		ctx = oauth2Login.WithState(ctx, "d4e5f6")
+
+		success := func(w http.ResponseWriter, req *http.Request) {
+			ctx := req.Context()
+			token, err := oauth2Login.TokenFromContext(ctx)
+			if assert.Nil(t, err, c.name) {
+				assert.Equal(t, "any-token", token.AccessToken, c.name)
+			}
+			user, err := UserFromContext(ctx)
+			if assert.Nil(t, err, c.name) {
+				expectedUser := &User{ID: 5901669835, IDStr: "5901669835", ScreenName: "weibo-sparkle", Name: "Sparkle", ProfileImageURL: "https://tvax1.sinaimg.cn/crop.0.0.180.180.50/006rHmKrly8g.jpg", Verified: true}
+				assert.Equal(t, expectedUser, user, c.name)
+			}
+			profile, err := gologin.ProfileFromContext(ctx)
+			if assert.Nil(t, err, c.name) {
+				assert.Equal(t, "5901669835", profile.ID, c.name)
+			}
+			fmt.Fprintf(w, "success handler called")
+		}
+		failure := testutils.AssertFailureNotCalled(t)
+
+		// CallbackHandler assert that:
+		// - the token response uid is captured
+		// - the Weibo User is obtained from users/show.json with the access
+		// token and uid
+		// - success handler is called with the Token and User in the ctx
+		callbackHandler := CallbackHandler(testConfig(), http.HandlerFunc(success), failure)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+		callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+		assert.Equal(t, "success handler called", w.Body.String(), c.name)
+		server.Close()
+	}
+}
+
+func TestWeiboHandler_MissingUID(t *testing.T) {
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, ErrMissingUID, gologin.ErrorFromContext(req.Context()))
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// WeiboHandler with a Token without a uid, assert that:
+	// - failure handler is called with ErrMissingUID
+	weiboHandler := weiboHandler(success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	ctx := oauth2Login.WithToken(req.Context(), &oauth2.Token{AccessToken: "any-token"})
+	weiboHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestWeiboHandler_APIError(t *testing.T) {
+	proxyClient, server := newWeiboTestServer(testTokenJSON, testUserJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	token := (&oauth2.Token{AccessToken: "expired-token"}).WithExtra(map[string]interface{}{"uid": "5901669835"})
+	ctx = oauth2Login.WithToken(ctx, token)
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.True(t, errors.Is(err, ErrUnableToGetWeiboUser))
+			var apiErr *APIError
+			if assert.True(t, errors.As(err, &apiErr)) {
+				assert.Equal(t, &APIError{Message: "expired_token", Code: 21327, Request: "/2/users/show.json"}, apiErr)
+				assert.Equal(t, "weibo: expired_token (error_code 21327)", apiErr.Error())
+			}
+			var gologinErr *gologin.Error
+			if assert.True(t, errors.As(err, &gologinErr)) {
+				assert.Equal(t, http.StatusForbidden, gologinErr.StatusCode)
+			}
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// WeiboHandler gets a Weibo error response, assert that:
+	// - failure handler is called
+	// - the error wraps the Weibo *APIError
+	weiboHandler := weiboHandler(success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	weiboHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestWeiboHandler_MissingCtxToken(t *testing.T) {
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "oauth2: Context missing Token", err.Error())
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// WeiboHandler called without Token in ctx, assert that:
+	// - failure handler is called
+	// - error about ctx missing token is added to the failure handler ctx
+	weiboHandler := weiboHandler(success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	weiboHandler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestWeiboHandler_ErrorGettingUser(t *testing.T) {
+	proxyClient, server := testutils.NewErrorServer("Weibo Service Down", http.StatusInternalServerError)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	token := (&oauth2.Token{AccessToken: "any-token"}).WithExtra(map[string]interface{}{"uid": "5901669835"})
+	ctx = oauth2Login.WithToken(ctx, token)
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.True(t, errors.Is(err, ErrUnableToGetWeiboUser))
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// WeiboHandler cannot get Weibo User, assert that:
+	// - failure handler is called
+	// - error cannot get Weibo User added to the failure handler ctx
+	weiboHandler := weiboHandler(success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	weiboHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestValidateResponse(t *testing.T) {
+	validUser := &User{IDStr: "5901669835"}
+	validResponse := &http.Response{StatusCode: 200}
+	invalidResponse := &http.Response{StatusCode: 500}
+	assert.Equal(t, nil, validateResponse(validUser, validResponse, nil))
+	assert.Equal(t, nil, validateResponse(&User{ID: 5901669835}, validResponse, nil))
+	assert.True(t, errors.Is(validateResponse(validUser, validResponse, &APIError{Code: 21327}), ErrUnableToGetWeiboUser))
+	assert.True(t, errors.Is(validateResponse(validUser, invalidResponse, nil), ErrUnableToGetWeiboUser))
+	assert.True(t, errors.Is(validateResponse(&User{}, validResponse, nil), ErrUnableToGetWeiboUser))
+	assert.True(t, errors.Is(validateResponse(nil, validResponse, nil), ErrUnableToGetWeiboUser))
+}
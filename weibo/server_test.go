@@ -0,0 +1,52 @@
+package weibo
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/dghubble/gologin/testutils"
+)
+
+const (
+	// testTokenJSON is a Weibo token response with the uid.
+	testTokenJSON = `{"access_token": "any-token", "expires_in": 157679999, "remind_in": "157679999", "uid": "5901669835"}`
+	// testUserJSON is a users/show.json response.
+	testUserJSON = `{"id": 5901669835, "idstr": "5901669835", "screen_name": "weibo-sparkle", "name": "Sparkle", "location": "Beijing", "profile_image_url": "https://tvax1.sinaimg.cn/crop.0.0.180.180.50/006rHmKrly8g.jpg", "verified": true, "followers_count": 42}`
+	// testInvalidTokenJSON is a users/show.json error response.
+	testInvalidTokenJSON = `{"error": "expired_token", "error_code": 21327, "request": "/2/users/show.json"}`
+)
+
+// newWeiboTestServer returns a new httptest.Server which mocks the Weibo
+// access_token and users/show.json endpoints and a client which proxies
+// requests to the server. The token endpoint requires a POST and responds
+// with the tokenJSON. The users/show.json endpoint requires the access_token
+// and the uid 5901669835 and responds with the given json data. The caller
+// must close the server.
+func newWeiboTestServer(tokenJSON, userJSON string) (*http.Client, *httptest.Server) {
+	client, mux, server := testutils.TestServer()
+	mux.HandleFunc("/oauth2/access_token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != "POST" {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		fmt.Fprintf(w, tokenJSON)
+	})
+	mux.HandleFunc("/2/users/show.json", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		w.Header().Set("Content-Type", "application/json")
+		if query.Get("access_token") != "any-token" {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprintf(w, testInvalidTokenJSON)
+			return
+		}
+		if query.Get("uid") != "5901669835" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, `{"error": "miss required parameter (uid)", "error_code": 10016, "request": "/2/users/show.json"}`)
+			return
+		}
+		fmt.Fprintf(w, userJSON)
+	})
+	return client, server
+}
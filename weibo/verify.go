@@ -0,0 +1,65 @@
+package weibo
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/dghubble/gologin/internal/jsonclient"
+)
+
+const weiboAPI = "https://api.weibo.com/2/"
+
+// User is a Weibo user.
+type User struct {
+	ID int64 `json:"id"`
+	// IDStr is the ID as a string, which is preferred since JSON decoders may
+	// lose int64 precision
+	IDStr           string `json:"idstr"`
+	ScreenName      string `json:"screen_name"`
+	Name            string `json:"name"`
+	ProfileImageURL string `json:"profile_image_url"`
+	Verified        bool   `json:"verified"`
+}
+
+// APIError is a Weibo API error.
+// https://open.weibo.com/wiki/Error_code
+type APIError struct {
+	Message string `json:"error"`
+	Code    int    `json:"error_code"`
+	Request string `json:"request"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("weibo: %s (error_code %d)", e.Message, e.Code)
+}
+
+// client is a Weibo client for obtaining a User.
+type client struct {
+	json *jsonclient.Client
+}
+
+// newClient returns a new Weibo client.
+func newClient(httpClient *http.Client) *client {
+	return &client{
+		json: jsonclient.New(httpClient, weiboAPI),
+	}
+}
+
+// UsersShow returns the User with the uid. Weibo requires both the access
+// token and the uid (from the token response). If Weibo responds with an
+// error, it is returned as an *APIError.
+// https://open.weibo.com/wiki/2/users/show
+func (c *client) UsersShow(ctx context.Context, accessToken, uid string) (*User, *http.Response, error) {
+	params := url.Values{}
+	params.Set("access_token", accessToken)
+	params.Set("uid", uid)
+	user := new(User)
+	apiErr := new(APIError)
+	resp, err := c.json.Get(ctx, "users/show.json", params, user, apiErr)
+	if err == nil && apiErr.Code != 0 {
+		err = apiErr
+	}
+	return user, resp, err
+}
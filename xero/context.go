@@ -0,0 +1,63 @@
+package xero
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/dghubble/gologin"
+)
+
+// unexported key type prevents collisions
+type key int
+
+const (
+	userKey key = iota
+	tenantsKey
+)
+
+// WithUser returns a copy of ctx that stores the Xero User and its gologin
+// Profile.
+func WithUser(ctx context.Context, user *User) context.Context {
+	ctx = gologin.WithProfile(ctx, newProfile(user))
+	return context.WithValue(ctx, userKey, user)
+}
+
+// UserFromContext returns the Xero User from the ctx.
+func UserFromContext(ctx context.Context) (*User, error) {
+	user, ok := ctx.Value(userKey).(*User)
+	if !ok {
+		return nil, fmt.Errorf("xero: Context missing Xero User")
+	}
+	return user, nil
+}
+
+// newProfile returns the gologin Profile of the Xero User.
+func newProfile(user *User) *gologin.Profile {
+	if user == nil {
+		return nil
+	}
+	return &gologin.Profile{
+		Provider: ProviderName,
+		ID:       user.ID,
+		Email:    user.Email,
+		Name:     strings.TrimSpace(user.GivenName + " " + user.FamilyName),
+		Raw:      gologin.ProfileRaw(user, "ID", "Email"),
+	}
+}
+
+// WithTenants returns a copy of ctx that stores the Xero Tenants.
+func WithTenants(ctx context.Context, tenants []Tenant) context.Context {
+	return context.WithValue(ctx, tenantsKey, tenants)
+}
+
+// TenantsFromContext returns the Xero Tenants (organisations) the User
+// connected from the ctx. Xero API requests must send a tenant's TenantID in
+// the Xero-tenant-id header.
+func TenantsFromContext(ctx context.Context) ([]Tenant, error) {
+	tenants, ok := ctx.Value(tenantsKey).([]Tenant)
+	if !ok {
+		return nil, fmt.Errorf("xero: Context missing Xero Tenants")
+	}
+	return tenants, nil
+}
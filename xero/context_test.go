@@ -0,0 +1,40 @@
+package xero
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContextUser(t *testing.T) {
+	expectedUser := &User{ID: "e5d6b6c5-0a6b-4b9e-8f3c-5e6f7a8b9c0d", Email: "jane@example.com"}
+	ctx := WithUser(context.Background(), expectedUser)
+	user, err := UserFromContext(ctx)
+	assert.Equal(t, expectedUser, user)
+	assert.Nil(t, err)
+}
+
+func TestContextUser_Error(t *testing.T) {
+	user, err := UserFromContext(context.Background())
+	assert.Nil(t, user)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "xero: Context missing Xero User", err.Error())
+	}
+}
+
+func TestContextTenants(t *testing.T) {
+	expectedTenants := []Tenant{{TenantID: "70784a63-d24b-46a9-a4db-0e70a274b056", TenantName: "Demo Company (US)", TenantType: TenantTypeOrganisation}}
+	ctx := WithTenants(context.Background(), expectedTenants)
+	tenants, err := TenantsFromContext(ctx)
+	assert.Equal(t, expectedTenants, tenants)
+	assert.Nil(t, err)
+}
+
+func TestContextTenants_Error(t *testing.T) {
+	tenants, err := TenantsFromContext(context.Background())
+	assert.Nil(t, tenants)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "xero: Context missing Xero Tenants", err.Error())
+	}
+}
@@ -0,0 +1,2 @@
+// Package xero provides Xero OAuth2 login and callback handlers.
+package xero
@@ -0,0 +1,143 @@
+package xero
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/dghubble/gologin"
+	"github.com/dghubble/gologin/internal"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/dghubble/gologin/oidc"
+	"golang.org/x/oauth2"
+)
+
+// ProviderName is the provider name set in the ctx (see
+// gologin.ProviderFromContext) and the Profile.
+const ProviderName = "xero"
+
+const (
+	xeroIssuer  = "https://identity.xero.com"
+	xeroJWKSURL = "https://identity.xero.com/.well-known/openid-configuration/jwks"
+)
+
+// Xero login errors
+var (
+	ErrUnableToGetXeroTenants = errors.New("xero: unable to get Xero tenants")
+	ErrNoTenants              = errors.New("xero: Xero User connected no tenants")
+)
+
+// Endpoint is Xero's OAuth2 endpoint.
+var Endpoint = oauth2.Endpoint{
+	AuthURL:   "https://login.xero.com/identity/connect/authorize",
+	TokenURL:  "https://identity.xero.com/connect/token",
+	AuthStyle: oauth2.AuthStyleInHeader,
+}
+
+// Scopes are the OpenID Connect scopes of the User and the
+// accounting.settings scope. Add "offline_access" for a refresh token.
+var Scopes = []string{"openid", "profile", "email", "accounting.settings"}
+
+// StateHandler checks for a state cookie. If found, the state value is read
+// and added to the ctx. Otherwise, a non-guessable value is added to the ctx
+// and to a (short-lived) state cookie issued to the requester.
+//
+// Implements OAuth 2 RFC 6749 10.12 CSRF Protection. If you wish to issue
+// state params differently, write a http.Handler which sets the ctx state,
+// using oauth2 WithState(ctx, state) since it is required by LoginHandler
+// and CallbackHandler.
+func StateHandler(config gologin.CookieConfig, success http.Handler) http.Handler {
+	return oauth2Login.StateHandler(config, success)
+}
+
+// LoginHandler handles Xero login requests by reading the state value from
+// the ctx and redirecting requests to the AuthURL with that state value.
+// Any AuthCodeOptions are added to the AuthURL.
+//
+// The config Endpoint should be the xero Endpoint and Scopes should include
+// the xero Scopes.
+func LoginHandler(config *oauth2.Config, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	return oauth2Login.LoginHandler(config, failure, opts...)
+}
+
+// CallbackHandler handles Xero redirection URI requests and adds the Xero
+// Token, id_token Claims (see oidc IDTokenFromContext), User, and Tenants to
+// the ctx. If authentication succeeds, handling delegates to the success
+// handler, otherwise to the failure handler.
+// Any AuthCodeOptions are sent with the token exchange.
+//
+// Xero access tokens expire after 30 minutes and refresh tokens rotate, so
+// store the full ctx Token. Users who connected no tenants fail with
+// ErrNoTenants.
+func CallbackHandler(config *oauth2.Config, success, failure http.Handler, opts ...oauth2.AuthCodeOption) http.Handler {
+	success = xeroHandler(config, success, failure)
+	return oauth2Login.CallbackHandler(config, success, failure, opts...)
+}
+
+// xeroHandler is a http.Handler that gets the OAuth2 Token from the ctx,
+// verifies its id_token, and gets the Tenants the User connected. If
+// successful, the Claims, User, and Tenants are added to the ctx and the
+// success handler is called. Otherwise, the failure handler is called.
+func xeroHandler(config *oauth2.Config, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	verifier := oidc.NewIDTokenVerifier(xeroJWKSURL, config.ClientID, xeroIssuer)
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		rawIDToken, ok := token.Extra("id_token").(string)
+		if !ok || rawIDToken == "" {
+			ctx = gologin.WithError(ctx, oidc.ErrMissingIDToken)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		claims, err := verifier.Verify(ctx, rawIDToken)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		nonce, _ := oauth2Login.NonceFromContext(ctx)
+		if nonce != "" && claims.Nonce != nonce {
+			ctx = gologin.WithError(ctx, oidc.ErrInvalidNonce)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		httpClient := internal.OAuth2Client(ctx, config, token)
+		tenants, resp, err := newClient(httpClient).Connections(ctx)
+		err = validateResponse(tenants, resp, err)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		ctx = oidc.WithIDToken(ctx, claims)
+		ctx = WithUser(ctx, newUser(claims))
+		ctx = WithTenants(ctx, tenants)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// validateResponse returns an error if the given Xero Tenants, raw
+// http.Response, or error are unexpected. Returns nil if they are valid,
+// ErrNoTenants if there are no Tenants, or a *gologin.Error which preserves
+// the cause and status code.
+func validateResponse(tenants []Tenant, resp *http.Response, err error) error {
+	var status int
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	if err != nil || status != http.StatusOK {
+		return &gologin.Error{Provider: "xero", Op: "get connections", StatusCode: status, Err: err, Kind: ErrUnableToGetXeroTenants}
+	}
+	if len(tenants) == 0 {
+		return ErrNoTenants
+	}
+	return nil
+}
@@ -0,0 +1,203 @@
+package xero
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dghubble/gologin"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/dghubble/gologin/oidc"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func testConfig() *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     "client_id",
+		ClientSecret: "client_secret",
+		RedirectURL:  "https://example.com/xero/callback",
+		Endpoint:     Endpoint,
+		Scopes:       []string{"openid", "profile", "email", "accounting.settings", "offline_access"},
+	}
+}
+
+func TestCallbackHandler(t *testing.T) {
+	proxyClient, server := newXeroTestServer(testIDToken(testClaims()), testConnectionsJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithState(ctx, "d4e5f6")
+
+	expectedUser := &User{
+		ID:         "e5d6b6c5-0a6b-4b9e-8f3c-5e6f7a8b9c0d",
+		Email:      "jane@example.com",
+		GivenName:  "Jane",
+		FamilyName: "Doe",
+	}
+	expectedTenants := []Tenant{
+		{
+			ID:          "e1eede29-f875-4a5d-8470-17f6a29a88b1",
+			TenantID:    "70784a63-d24b-46a9-a4db-0e70a274b056",
+			TenantName:  "Demo Company (US)",
+			TenantType:  TenantTypeOrganisation,
+			CreatedDate: "2024-01-02T03:04:05.1234567",
+			UpdatedDate: "2024-01-02T03:04:05.1234567",
+			AuthEventID: "d99ecdfe-391d-43d2-b834-17636ba90e8d",
+		},
+	}
+	success := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "any-token", token.AccessToken)
+			assert.Equal(t, "any-refresh", token.RefreshToken)
+			assert.False(t, token.Expiry.IsZero())
+		}
+		claims, err := oidc.IDTokenFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "1c4f3e2a-8b7d-4e6f-9a0b-c1d2e3f4a5b6", claims.Extra["xero_userid"])
+		}
+		user, err := UserFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, expectedUser, user)
+		}
+		tenants, err := TenantsFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, expectedTenants, tenants)
+		}
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// CallbackHandler assert that:
+	// - the id_token is verified with Xero's keys
+	// - success handler is called
+	// - Xero Token, id_token Claims, User, and Tenants are added to the ctx of
+	// the success handler
+	callbackHandler := CallbackHandler(testConfig(), http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestXeroHandler_NoTenants(t *testing.T) {
+	proxyClient, server := newXeroTestServer("", `[]`)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	token := (&oauth2.Token{AccessToken: "any-token"}).WithExtra(map[string]interface{}{"id_token": testIDToken(testClaims())})
+	ctx = oauth2Login.WithToken(ctx, token)
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, ErrNoTenants, gologin.ErrorFromContext(req.Context()))
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// XeroHandler gets no connected tenants, assert that:
+	// - failure handler is called with ErrNoTenants
+	xeroHandler := xeroHandler(testConfig(), success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	xeroHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestXeroHandler_IDTokenErrors(t *testing.T) {
+	proxyClient, server := newXeroTestServer("", testConnectionsJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+
+	cases := []struct {
+		name  string
+		token *oauth2.Token
+		err   error
+	}{
+		{"missing id_token", &oauth2.Token{AccessToken: "any-token"}, oidc.ErrMissingIDToken},
+		{"invalid id_token", (&oauth2.Token{AccessToken: "any-token"}).WithExtra(map[string]interface{}{"id_token": "not.a.jwt"}), oidc.ErrInvalidIDToken},
+	}
+	for _, c := range cases {
+		success := testutils.AssertSuccessNotCalled(t)
+		failure := func(w http.ResponseWriter, req *http.Request) {
+			assert.Equal(t, c.err, gologin.ErrorFromContext(req.Context()), c.name)
+			fmt.Fprintf(w, "failure handler called")
+		}
+
+		// XeroHandler with a Token without a valid id_token, assert that:
+		// - failure handler is called with the id_token error
+		xeroHandler := xeroHandler(testConfig(), success, http.HandlerFunc(failure))
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		xeroHandler.ServeHTTP(w, req.WithContext(oauth2Login.WithToken(ctx, c.token)))
+		assert.Equal(t, "failure handler called", w.Body.String(), c.name)
+	}
+}
+
+func TestXeroHandler_MissingCtxToken(t *testing.T) {
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "oauth2: Context missing Token", err.Error())
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// XeroHandler called without Token in ctx, assert that:
+	// - failure handler is called
+	// - error about ctx missing token is added to the failure handler ctx
+	xeroHandler := xeroHandler(testConfig(), success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	xeroHandler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestXeroHandler_ErrorGettingTenants(t *testing.T) {
+	proxyClient, server := newXeroTestServer("", testConnectionsJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	token := (&oauth2.Token{AccessToken: "invalid-token"}).WithExtra(map[string]interface{}{"id_token": testIDToken(testClaims())})
+	ctx = oauth2Login.WithToken(ctx, token)
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.True(t, errors.Is(err, ErrUnableToGetXeroTenants))
+			var apiErr *APIError
+			if assert.True(t, errors.As(err, &apiErr)) {
+				assert.Equal(t, "AuthenticationUnsuccessful", apiErr.Detail)
+			}
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// XeroHandler cannot get Xero Tenants, assert that:
+	// - failure handler is called
+	// - error cannot get Xero Tenants (and the API error) added to the
+	// failure handler ctx
+	xeroHandler := xeroHandler(testConfig(), success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	xeroHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestValidateResponse(t *testing.T) {
+	validTenants := []Tenant{{TenantID: "70784a63-d24b-46a9-a4db-0e70a274b056"}}
+	validResponse := &http.Response{StatusCode: 200}
+	invalidResponse := &http.Response{StatusCode: 500}
+	assert.Equal(t, nil, validateResponse(validTenants, validResponse, nil))
+	assert.True(t, errors.Is(validateResponse(validTenants, validResponse, fmt.Errorf("Server error")), ErrUnableToGetXeroTenants))
+	assert.True(t, errors.Is(validateResponse(validTenants, invalidResponse, nil), ErrUnableToGetXeroTenants))
+	assert.Equal(t, ErrNoTenants, validateResponse(nil, validResponse, nil))
+}
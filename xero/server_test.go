@@ -0,0 +1,77 @@
+package xero
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/dghubble/gologin/testutils"
+)
+
+const (
+	// testConnectionsJSON is a connections response of one organisation.
+	testConnectionsJSON = `[{"id": "e1eede29-f875-4a5d-8470-17f6a29a88b1", "authEventId": "d99ecdfe-391d-43d2-b834-17636ba90e8d", "tenantId": "70784a63-d24b-46a9-a4db-0e70a274b056", "tenantType": "ORGANISATION", "tenantName": "Demo Company (US)", "createdDateUtc": "2024-01-02T03:04:05.1234567", "updatedDateUtc": "2024-01-02T03:04:05.1234567"}]`
+	// testUnauthorizedJSON is a Xero API error response.
+	testUnauthorizedJSON = `{"Type": null, "Title": "Unauthorized", "Status": 401, "Detail": "AuthenticationUnsuccessful", "Instance": "0d3a1c6b-1d84-4c3c-8f2f-3ee3e3b5e6a1", "Extensions": {}}`
+)
+
+// testXeroKey signs test id_tokens and is served by newXeroTestServer.
+var testXeroKey, _ = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+// testIDToken returns an id_token with the given JSON claims signed by the
+// testXeroKey.
+func testIDToken(claims string) string {
+	signingInput := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"ES256","kid":"xero-key"}`)) + "." + base64.RawURLEncoding.EncodeToString([]byte(claims))
+	digest := sha256.Sum256([]byte(signingInput))
+	r, s, _ := ecdsa.Sign(rand.Reader, testXeroKey, digest[:])
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// testClaims returns valid Xero id_token JSON claims.
+func testClaims() string {
+	return fmt.Sprintf(`{"iss":"https://identity.xero.com","aud":"client_id","sub":"e5d6b6c5-0a6b-4b9e-8f3c-5e6f7a8b9c0d","exp":%d,"iat":%d,"email":"jane@example.com","given_name":"Jane","family_name":"Doe","xero_userid":"1c4f3e2a-8b7d-4e6f-9a0b-c1d2e3f4a5b6"}`,
+		time.Now().Add(5*time.Minute).Unix(), time.Now().Unix())
+}
+
+// testJWKS returns the JSON Web Key Set of the testXeroKey.
+func testJWKS() string {
+	x := base64.RawURLEncoding.EncodeToString(testXeroKey.X.FillBytes(make([]byte, 32)))
+	y := base64.RawURLEncoding.EncodeToString(testXeroKey.Y.FillBytes(make([]byte, 32)))
+	return fmt.Sprintf(`{"keys": [{"kty": "EC", "kid": "xero-key", "use": "sig", "crv": "P-256", "x": %q, "y": %q}]}`, x, y)
+}
+
+// newXeroTestServer returns a new httptest.Server which mocks the Xero
+// token, keys, and connections endpoints and a client which proxies requests
+// to the server. The token endpoint responds with the idToken and the
+// connections endpoint with the given json data. The caller must close the
+// server.
+func newXeroTestServer(idToken, jsonData string) (*http.Client, *httptest.Server) {
+	client, mux, server := testutils.TestServer()
+	mux.HandleFunc("/connect/token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token": "any-token", "token_type": "Bearer", "expires_in": 1800, "refresh_token": "any-refresh", "scope": "openid profile email accounting.settings offline_access", "id_token": %q}`, idToken)
+	})
+	mux.HandleFunc("/.well-known/openid-configuration/jwks", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, testJWKS())
+	})
+	mux.HandleFunc("/connections", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		if r.Header.Get("Authorization") != "Bearer any-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprintf(w, testUnauthorizedJSON)
+			return
+		}
+		fmt.Fprintf(w, jsonData)
+	})
+	return client, server
+}
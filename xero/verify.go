@@ -0,0 +1,86 @@
+package xero
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/dghubble/gologin/internal/jsonclient"
+	"github.com/dghubble/gologin/oidc"
+)
+
+const xeroAPI = "https://api.xero.com/"
+
+// Xero tenant types
+const (
+	TenantTypeOrganisation = "ORGANISATION"
+	TenantTypePractice     = "PRACTICE"
+)
+
+// User is a Xero user from the id_token claims.
+type User struct {
+	ID         string
+	Email      string
+	GivenName  string
+	FamilyName string
+}
+
+// newUser returns the User of the verified id_token claims.
+func newUser(claims *oidc.Claims) *User {
+	extra := func(name string) string {
+		value, _ := claims.Extra[name].(string)
+		return value
+	}
+	return &User{
+		ID:         claims.Subject,
+		Email:      claims.Email,
+		GivenName:  extra("given_name"),
+		FamilyName: extra("family_name"),
+	}
+}
+
+// Tenant is a Xero tenant (organisation or practice) connected to the app.
+type Tenant struct {
+	ID          string `json:"id"`
+	TenantID    string `json:"tenantId"`
+	TenantName  string `json:"tenantName"`
+	TenantType  string `json:"tenantType"`
+	CreatedDate string `json:"createdDateUtc"`
+	UpdatedDate string `json:"updatedDateUtc"`
+	AuthEventID string `json:"authEventId"`
+}
+
+// APIError is a Xero API error response.
+type APIError struct {
+	Title  string `json:"Title"`
+	Status int    `json:"Status"`
+	Detail string `json:"Detail"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("xero: %s: %s (status %d)", e.Title, e.Detail, e.Status)
+}
+
+// client is a Xero client for obtaining Tenants.
+type client struct {
+	json *jsonclient.Client
+}
+
+// newClient returns a new Xero client.
+func newClient(httpClient *http.Client) *client {
+	return &client{
+		json: jsonclient.New(httpClient, xeroAPI),
+	}
+}
+
+// Connections gets the Tenants the user connected to the app.
+// https://developer.xero.com/documentation/guides/oauth2/auth-flow/#5-check-the-tenants-youre-authorized-to-access
+func (c *client) Connections(ctx context.Context) ([]Tenant, *http.Response, error) {
+	var tenants []Tenant
+	apiErr := new(APIError)
+	resp, err := c.json.Get(ctx, "connections", nil, &tenants, apiErr)
+	if err == nil && apiErr.Title != "" {
+		err = apiErr
+	}
+	return tenants, resp, err
+}
@@ -0,0 +1,2 @@
+// Package yahoo provides Yahoo OAuth2 login and callback handlers.
+package yahoo
@@ -0,0 +1,39 @@
+package yahoo
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/dghubble/gologin/testutils"
+)
+
+// newYahooTestServer returns a new httptest.Server which mocks the Yahoo
+// get_token and userinfo endpoints and a client which proxies requests to the
+// server. Like Yahoo, the get_token endpoint requires HTTP Basic client
+// authentication and rejects client credentials in the form body. The
+// userinfo endpoint responds with the given json data. The caller must close
+// the server.
+func newYahooTestServer(jsonData string) (*http.Client, *httptest.Server) {
+	client, mux, server := testutils.TestServer()
+	mux.HandleFunc("/oauth2/get_token", func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		if !ok || username != "client_id" || password != "client_secret" || r.PostFormValue("client_id") != "" || r.PostFormValue("client_secret") != "" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprintf(w, `{"error": "invalid_client", "error_description": "client authentication failed"}`)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token": "any-token", "token_type": "bearer", "expires_in": 3600, "refresh_token": "any-refresh", "xoauth_yahoo_guid": "LKZCZ4TUNZESQ5JEFDYLYWHGDQ"}`)
+	})
+	mux.HandleFunc("/openid/v1/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer any-token" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, jsonData)
+	})
+	return client, server
+}
@@ -0,0 +1,40 @@
+package yahoo
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/dghubble/gologin/internal/jsonclient"
+)
+
+const yahooAPI = "https://api.login.yahoo.com/"
+
+// User is a Yahoo user from the OpenID Connect userinfo endpoint.
+type User struct {
+	ID            string `json:"sub"`
+	Name          string `json:"name"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Picture       string `json:"picture"`
+}
+
+// client is a Yahoo client for obtaining a User.
+type client struct {
+	json *jsonclient.Client
+}
+
+// newClient returns a new Yahoo client.
+func newClient(httpClient *http.Client) *client {
+	return &client{
+		json: jsonclient.New(httpClient, yahooAPI),
+	}
+}
+
+// UserInfo gets the current Yahoo User (requires the openid scope, plus the
+// profile and email scopes for the name, picture, and email).
+// https://developer.yahoo.com/oauth2/guide/openid_connect/
+func (c *client) UserInfo(ctx context.Context) (*User, *http.Response, error) {
+	user := new(User)
+	resp, err := c.json.Get(ctx, "openid/v1/userinfo", nil, user, nil)
+	return user, resp, err
+}
@@ -0,0 +1,46 @@
+package yandex
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dghubble/gologin"
+)
+
+// unexported key type prevents collisions
+type key int
+
+const (
+	userKey key = iota
+)
+
+// WithUser returns a copy of ctx that stores the Yandex User and its gologin
+// Profile.
+func WithUser(ctx context.Context, user *User) context.Context {
+	ctx = gologin.WithProfile(ctx, newProfile(user))
+	return context.WithValue(ctx, userKey, user)
+}
+
+// UserFromContext returns the Yandex User from the ctx.
+func UserFromContext(ctx context.Context) (*User, error) {
+	user, ok := ctx.Value(userKey).(*User)
+	if !ok {
+		return nil, fmt.Errorf("yandex: Context missing Yandex User")
+	}
+	return user, nil
+}
+
+// newProfile returns the gologin Profile of the Yandex User.
+func newProfile(user *User) *gologin.Profile {
+	if user == nil {
+		return nil
+	}
+	return &gologin.Profile{
+		Provider:  ProviderName,
+		ID:        user.ID,
+		Email:     user.DefaultEmail,
+		Name:      gologin.DisplayName(user.RealName, user.Login),
+		AvatarURL: user.AvatarURL("islands-200"),
+		Raw:       gologin.ProfileRaw(user, "id", "default_email", "real_name"),
+	}
+}
@@ -0,0 +1,2 @@
+// Package yandex provides Yandex OAuth2 login and callback handlers.
+package yandex
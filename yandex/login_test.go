@@ -0,0 +1,165 @@
+package yandex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dghubble/gologin"
+	oauth2Login "github.com/dghubble/gologin/oauth2"
+	"github.com/dghubble/gologin/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+const testUserJSON = `{"id": "1000034426", "login": "ivan", "client_id": "client_id", "display_name": "Ivan", "real_name": "Ivan Ivanov", "first_name": "Ivan", "last_name": "Ivanov", "sex": "male", "default_email": "ivan@yandex.ru", "emails": ["ivan@yandex.ru", "ivan@example.com"], "default_avatar_id": "131652443", "is_avatar_empty": false, "psuid": "1.AAceCw.tbHgw5DtJ9_zeqPrk-Ba2w.qPWSRC5v2t2IaksPJgnge"}`
+
+func testConfig() *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     "client_id",
+		ClientSecret: "client_secret",
+		RedirectURL:  "https://example.com/yandex/callback",
+		Endpoint:     Endpoint,
+		Scopes:       []string{"login:info", "login:email", "login:avatar"},
+	}
+}
+
+func TestCallbackHandler(t *testing.T) {
+	proxyClient, server := newYandexTestServer(testUserJSON)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithState(ctx, "d4e5f6")
+
+	success := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "any-token", token.AccessToken)
+		}
+		user, err := UserFromContext(ctx)
+		if assert.Nil(t, err) {
+			expectedUser := &User{ID: "1000034426", Login: "ivan", DefaultEmail: "ivan@yandex.ru", Emails: []string{"ivan@yandex.ru", "ivan@example.com"}, RealName: "Ivan Ivanov", DefaultAvatarID: "131652443"}
+			assert.Equal(t, expectedUser, user)
+			assert.Equal(t, "https://avatars.yandex.net/get-yapic/131652443/islands-200", user.AvatarURL("islands-200"))
+		}
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// CallbackHandler assert that:
+	// - the Yandex User is obtained with the "OAuth" Authorization scheme
+	// - success handler is called with the Token and User in the ctx
+	callbackHandler := CallbackHandler(testConfig(), http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestClientInfo_AuthorizationScheme(t *testing.T) {
+	proxyClient, mux, server := testutils.TestServer()
+	defer server.Close()
+	mux.HandleFunc("/info", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "OAuth any-token", r.Header.Get("Authorization"))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, testUserJSON)
+	})
+
+	// Info assert that:
+	// - the access token is sent with the "OAuth" (not "Bearer") scheme
+	user, resp, err := newClient(proxyClient).Info(context.Background(), "any-token")
+	assert.Nil(t, validateResponse(user, resp, err))
+}
+
+func TestUser_AvatarURL(t *testing.T) {
+	assert.Equal(t, "https://avatars.yandex.net/get-yapic/131652443/islands-retina-50", (&User{DefaultAvatarID: "131652443"}).AvatarURL("islands-retina-50"))
+	assert.Equal(t, "", (&User{DefaultAvatarID: "0/0-0", IsAvatarEmpty: true}).AvatarURL("islands-200"))
+	assert.Equal(t, "", (&User{}).AvatarURL("islands-200"))
+}
+
+func TestYandexHandler_MissingID(t *testing.T) {
+	proxyClient, server := newYandexTestServer(`{"login": "ivan", "default_email": "ivan@yandex.ru"}`)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.True(t, errors.Is(err, ErrUnableToGetYandexUser))
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// YandexHandler gets info without an id, assert that:
+	// - failure handler is called
+	// - error cannot get Yandex User added to the failure handler ctx
+	yandexHandler := yandexHandler(success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	yandexHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestYandexHandler_MissingCtxToken(t *testing.T) {
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "oauth2: Context missing Token", err.Error())
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// YandexHandler called without Token in ctx, assert that:
+	// - failure handler is called
+	// - error about ctx missing token is added to the failure handler ctx
+	yandexHandler := yandexHandler(success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	yandexHandler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestYandexHandler_ErrorGettingUser(t *testing.T) {
+	proxyClient, server := testutils.NewErrorServer("Yandex Service Down", http.StatusInternalServerError)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	ctx = oauth2Login.WithToken(ctx, &oauth2.Token{AccessToken: "any-token"})
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.NotNil(t, err) {
+			assert.True(t, errors.Is(err, ErrUnableToGetYandexUser))
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// YandexHandler cannot get Yandex User, assert that:
+	// - failure handler is called
+	// - error cannot get Yandex User added to the failure handler ctx
+	yandexHandler := yandexHandler(success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	yandexHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestValidateResponse(t *testing.T) {
+	validUser := &User{ID: "1000034426"}
+	validResponse := &http.Response{StatusCode: 200}
+	invalidResponse := &http.Response{StatusCode: 500}
+	assert.Equal(t, nil, validateResponse(validUser, validResponse, nil))
+	assert.True(t, errors.Is(validateResponse(validUser, validResponse, fmt.Errorf("Server error")), ErrUnableToGetYandexUser))
+	assert.True(t, errors.Is(validateResponse(validUser, invalidResponse, nil), ErrUnableToGetYandexUser))
+	assert.True(t, errors.Is(validateResponse(&User{}, validResponse, nil), ErrUnableToGetYandexUser))
+	assert.True(t, errors.Is(validateResponse(nil, validResponse, nil), ErrUnableToGetYandexUser))
+}
@@ -0,0 +1,35 @@
+package yandex
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/dghubble/gologin/testutils"
+)
+
+// newYandexTestServer returns a new httptest.Server which mocks the Yandex
+// token and info endpoints and a client which proxies requests to the server.
+// Like Yandex, the info endpoint requires the "OAuth" Authorization scheme
+// and responds to format=json requests with the given json data. The caller
+// must close the server.
+func newYandexTestServer(jsonData string) (*http.Client, *httptest.Server) {
+	client, mux, server := testutils.TestServer()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token": "any-token", "token_type": "bearer", "expires_in": 31536000, "refresh_token": "any-refresh"}`)
+	})
+	mux.HandleFunc("/info", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "OAuth any-token" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Query().Get("format") != "json" {
+			http.Error(w, "unexpected format", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, jsonData)
+	})
+	return client, server
+}
@@ -0,0 +1,61 @@
+package yandex
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/dghubble/gologin/internal/jsonclient"
+)
+
+const (
+	yandexAPI = "https://login.yandex.ru/"
+	avatarURL = "https://avatars.yandex.net/get-yapic/"
+)
+
+// User is a Yandex user from the Yandex ID (login.yandex.ru) info endpoint.
+type User struct {
+	ID              string   `json:"id"`
+	Login           string   `json:"login"`
+	DefaultEmail    string   `json:"default_email"`
+	Emails          []string `json:"emails"`
+	RealName        string   `json:"real_name"`
+	IsAvatarEmpty   bool     `json:"is_avatar_empty"`
+	DefaultAvatarID string   `json:"default_avatar_id"`
+}
+
+// AvatarURL returns the URL of the User's avatar in the given size (e.g.
+// "islands-200") or an empty string if the User has no avatar.
+// https://yandex.com/dev/id/doc/en/user-information#avatar-access
+func (u *User) AvatarURL(size string) string {
+	if u.IsAvatarEmpty || u.DefaultAvatarID == "" {
+		return ""
+	}
+	return avatarURL + u.DefaultAvatarID + "/" + size
+}
+
+// client is a Yandex client for obtaining the current User.
+type client struct {
+	json *jsonclient.Client
+}
+
+// newClient returns a new Yandex client.
+func newClient(httpClient *http.Client) *client {
+	return &client{
+		json: jsonclient.New(httpClient, yandexAPI),
+	}
+}
+
+// Info returns the User of the access token. Yandex expects the token with
+// the "OAuth" authorization scheme, rather than "Bearer".
+// https://yandex.com/dev/id/doc/en/user-information
+func (c *client) Info(ctx context.Context, accessToken string) (*User, *http.Response, error) {
+	user := new(User)
+	req, err := c.json.NewRequest(ctx, "GET", "info", url.Values{"format": {"json"}}, nil)
+	if err != nil {
+		return user, nil, err
+	}
+	req.Header.Set("Authorization", "OAuth "+accessToken)
+	resp, err := c.json.Do(req, user, nil)
+	return user, resp, err
+}
@@ -0,0 +1,2 @@
+// Package zoom provides Zoom OAuth2 login and callback handlers.
+package zoom
@@ -0,0 +1,45 @@
+package zoom
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/dghubble/gologin/testutils"
+)
+
+const (
+	// testUserJSON is a users/me response of a licensed user.
+	testUserJSON = `{"id": "KDcuGIm1QgePTO8WbOqwIQ", "first_name": "Jill", "last_name": "Chill", "display_name": "Jill Chill", "email": "jchill@example.com", "type": 2, "role_name": "Owner", "pmi": 3542471135, "timezone": "Asia/Shanghai", "verified": 1, "dept": "", "pic_url": "https://example.com/photo.jpg", "account_id": "q6gBJVO5TzexKYTb_I2rpg", "status": "active"}`
+	// testInvalidTokenJSON is a Zoom error response.
+	testInvalidTokenJSON = `{"code": 124, "message": "Invalid access token."}`
+)
+
+// newZoomTestServer returns a new httptest.Server which mocks the Zoom token
+// and users/me endpoints and a client which proxies requests to the server.
+// Like Zoom, the token endpoint requires HTTP Basic client authentication.
+// The users/me endpoint responds with the given json data, or a code 124
+// error for tokens other than "any-token". The caller must close the server.
+func newZoomTestServer(jsonData string) (*http.Client, *httptest.Server) {
+	client, mux, server := testutils.TestServer()
+	mux.HandleFunc("/oauth/token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		username, password, ok := r.BasicAuth()
+		if !ok || username != "client_id" || password != "client_secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprintf(w, `{"reason": "Invalid client_id or client_secret", "error": "invalid_client"}`)
+			return
+		}
+		fmt.Fprintf(w, `{"access_token": "any-token", "token_type": "bearer", "refresh_token": "any-refresh", "expires_in": 3599, "scope": "user:read"}`)
+	})
+	mux.HandleFunc("/v2/users/me", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json;charset=UTF-8")
+		if r.Header.Get("Authorization") != "Bearer any-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprintf(w, testInvalidTokenJSON)
+			return
+		}
+		fmt.Fprintf(w, jsonData)
+	})
+	return client, server
+}
@@ -0,0 +1,72 @@
+package zoom
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/dghubble/gologin/internal/jsonclient"
+)
+
+const zoomAPI = "https://api.zoom.us/v2/"
+
+// UserType is a Zoom user's plan type.
+// https://developers.zoom.us/docs/api/users/#tag/users/GET/users/{userId}
+type UserType int
+
+// Zoom user types
+const (
+	UserTypeBasic    UserType = 1
+	UserTypeLicensed UserType = 2
+)
+
+// User is a Zoom user.
+type User struct {
+	ID        string   `json:"id"`
+	Email     string   `json:"email"`
+	FirstName string   `json:"first_name"`
+	LastName  string   `json:"last_name"`
+	Type      UserType `json:"type"`
+	AccountID string   `json:"account_id"`
+	PicURL    string   `json:"pic_url"`
+}
+
+// IsLicensed returns true if the User has a paid (licensed) plan.
+func (u *User) IsLicensed() bool {
+	return u.Type == UserTypeLicensed
+}
+
+// APIError is a Zoom API error response.
+// https://developers.zoom.us/docs/api/rest/error-definitions/
+type APIError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("zoom: %s (code %d)", e.Message, e.Code)
+}
+
+// client is a Zoom client for obtaining the current User.
+type client struct {
+	json *jsonclient.Client
+}
+
+// newClient returns a new Zoom client.
+func newClient(httpClient *http.Client) *client {
+	return &client{
+		json: jsonclient.New(httpClient, zoomAPI),
+	}
+}
+
+// Me returns the current Zoom User. If Zoom responds with an error, it is
+// returned as an *APIError.
+func (c *client) Me(ctx context.Context) (*User, *http.Response, error) {
+	user := new(User)
+	apiErr := new(APIError)
+	resp, err := c.json.Get(ctx, "users/me", nil, user, apiErr)
+	if err == nil && apiErr.Code != 0 {
+		err = apiErr
+	}
+	return user, resp, err
+}